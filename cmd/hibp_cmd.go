@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"bufio"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/supabase/auth/internal/utilities"
+)
+
+var hibpFalsePositives float64
+
+func hibpCmd() *cobra.Command {
+	var hibpCmd = &cobra.Command{
+		Use: "hibp",
+	}
+
+	hibpCmd.AddCommand(&hibpBloomCmd)
+	hibpBloomCmd.Flags().Float64Var(&hibpFalsePositives, "false-positives", 0.000001, "The false positive rate of the bloom filter")
+
+	return hibpCmd
+}
+
+var hibpBloomCmd = cobra.Command{
+	Use:  "bloom <source> <bloom filter>",
+	Long: "Build the bloom filter of the Pwned Passwords list used by offline pwned passwords checks. The source is either a file with a full SHA1 hash per line, or a directory of range files, as downloaded by the Pwned Passwords downloader.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			logrus.Fatal("Not enough arguments to bloom command. Expected the source and the bloom filter paths")
+			return
+		}
+
+		hibpBloom(args)
+	},
+}
+
+func hibpBloom(args []string) {
+	filter, err := utilities.BuildHIBPBloomFilter(args[0], hibpFalsePositives)
+	if err != nil {
+		logrus.Fatalf("Error building the bloom filter: %+v", err)
+	}
+
+	file, err := os.Create(args[1])
+	if err != nil {
+		logrus.Fatalf("Error creating the bloom filter: %+v", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	if _, err := filter.WriteTo(writer); err != nil {
+		logrus.Fatalf("Error writing the bloom filter: %+v", err)
+	}
+	if err := writer.Flush(); err != nil {
+		logrus.Fatalf("Error writing the bloom filter: %+v", err)
+	}
+
+	logrus.Infof("Wrote the bloom filter of %d bits to %s", filter.Cap(), args[1])
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/supabase/auth/internal/api"
 	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/mailer"
 	"github.com/supabase/auth/internal/storage"
 	"github.com/supabase/auth/internal/utilities"
 )
@@ -34,6 +35,49 @@ func serve(ctx context.Context) {
 
 	api := api.NewAPIWithVersion(config, db, utilities.Version)
 
+	// the email templates are fetched in the background so the first emails
+	// sent don't wait on them
+	go mailer.PrefetchTemplates(config)
+
+	// messages the send email and send SMS hooks failed to deliver are
+	// retried in the background
+	go api.RetryHooks(ctx)
+
+	// emails and SMS messages are sent from the outbox in the background
+	// when it's enabled
+	go api.ProcessOutbox(ctx)
+
+	// users imported in bulk are created in the background when imports
+	// are enabled
+	go api.ProcessUserImports(ctx)
+
+	// soft deleted users are purged after their retention period when
+	// retention is enabled
+	go api.PurgeDeletedUsers(ctx)
+
+	// accounts are deleted once the grace period of their scheduled
+	// deletion is over when account deletion is enabled
+	go api.DeleteScheduledAccounts(ctx)
+
+	// exports of the data of users are generated, and removed once they
+	// expire, when data export is enabled
+	go api.ProcessDataExports(ctx)
+
+	// bans are lifted and recorded in the audit log as they expire
+	go api.LiftExpiredBans(ctx)
+
+	// audit log entries are streamed to the external sinks that are
+	// enabled
+	go api.StreamAuditLog(ctx)
+
+	// revocations of sessions and users are received from the other
+	// instances when they're propagated
+	go api.PropagateRevocations(ctx)
+
+	// the rate limits of the groups of endpoints are reloaded when the
+	// config file changes
+	go api.WatchConfig(ctx, configFile)
+
 	addr := net.JoinHostPort(config.API.Host, config.API.Port)
 	logrus.Infof("GoTrue API started on: %s", addr)
 
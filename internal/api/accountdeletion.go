@@ -0,0 +1,337 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/hooks"
+	mail "github.com/supabase/auth/internal/mailer"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+	"github.com/supabase/auth/internal/utilities"
+)
+
+const accountDeletionCancelledMessage = "Account deletion cancelled"
+
+var deletedAccountsCounter = observability.ObtainMetricCounter("gotrue_deleted_accounts", "Number of accounts deleted after the grace period of their scheduled deletion")
+
+// AccountDeletionParams are the parameters the UserDelete method accepts
+type AccountDeletionParams struct {
+	Nonce string `json:"nonce"`
+}
+
+// runAccountDeletionHook invokes the account deletion hook, when enabled,
+// with an event of the deletion of the account of the user.
+func (a *API) runAccountDeletionHook(r *http.Request, tx *storage.Connection, event string, user *models.User, deleteAt time.Time) error {
+	if !a.config.Hook.AccountDeletion.Enabled {
+		return nil
+	}
+
+	input := hooks.AccountDeletionInput{
+		Event:    event,
+		User:     user,
+		DeleteAt: deleteAt,
+	}
+	output := hooks.AccountDeletionOutput{}
+	return a.invokeHook(tx, r, &input, &output)
+}
+
+// sendAccountDeletionConfirmation confirms to the user that the deletion of
+// their account was scheduled, with a link to cancel it.
+func (a *API) sendAccountDeletionConfirmation(r *http.Request, tx *storage.Connection, user *models.User, deletion *models.AccountDeletion) error {
+	config := a.config
+
+	if user.GetEmail() == "" {
+		return nil
+	}
+
+	if config.Hook.SendEmail.Enabled {
+		input := hooks.SendEmailInput{
+			User: user,
+			EmailData: mail.EmailData{
+				EmailActionType: mail.AccountDeletionNotification,
+				RedirectTo:      utilities.GetReferrer(r, config),
+				SiteURL:         getExternalHost(r.Context()).String(),
+				TokenHash:       deletion.TokenHash,
+				DeleteAt:        deletion.DeleteAt.UTC().Format(time.RFC3339),
+			},
+		}
+		output := hooks.SendEmailOutput{}
+		return a.invokeHook(tx, r, &input, &output)
+	}
+
+	if err := a.Mailer().AccountDeletionMail(r, user, deletion.DeleteAt, deletion.TokenHash, getExternalHost(r.Context())); err != nil {
+		return internalServerError("Error sending account deletion confirmation").WithInternalError(err)
+	}
+
+	return nil
+}
+
+// UserDelete schedules the deletion of the account of the user after the
+// grace period, and sends them a link to cancel it. It requires the nonce
+// of a reauthentication. Requesting the deletion of an account already
+// scheduled for deletion returns the scheduled deletion.
+func (a *API) UserDelete(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+	user := getUser(ctx)
+
+	params := &AccountDeletionParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if deletion, err := models.FindAccountDeletionByUserID(db, user.ID); err == nil {
+		return sendJSON(w, http.StatusOK, deletion)
+	} else if !models.IsNotFoundError(err) {
+		return internalServerError("Database error finding account deletion").WithInternalError(err)
+	}
+
+	if params.Nonce == "" {
+		return badRequestError(ErrorCodeReauthenticationNeeded, "Account deletion requires reauthentication")
+	}
+
+	deletion := models.NewAccountDeletion(user, crypto.SecureToken(), time.Now().Add(config.AccountDeletion.GracePeriod))
+
+	err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := a.verifyReauthentication(params.Nonce, tx, config, user); terr != nil {
+			return terr
+		}
+
+		if terr := tx.Create(deletion); terr != nil {
+			return internalServerError("Database error scheduling account deletion").WithInternalError(terr)
+		}
+
+		if terr := models.NewAuditLogEntry(r, tx, user, models.AccountDeletionRequestedAction, "", map[string]interface{}{
+			"delete_at": deletion.DeleteAt,
+		}); terr != nil {
+			return internalServerError("Error recording audit log entry").WithInternalError(terr)
+		}
+
+		if terr := a.runAccountDeletionHook(r, tx, hooks.AccountDeletionRequested, user, deletion.DeleteAt); terr != nil {
+			return terr
+		}
+
+		return a.sendAccountDeletionConfirmation(r, tx, user, deletion)
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, deletion)
+}
+
+// UserDeletionGet returns the scheduled deletion of the account of the
+// user.
+func (a *API) UserDeletionGet(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+
+	deletion, err := models.FindAccountDeletionByUserID(db, user.ID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return notFoundError(ErrorCodeAccountDeletionNotFound, "Account isn't scheduled for deletion")
+		}
+		return internalServerError("Database error finding account deletion").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, deletion)
+}
+
+// cancelAccountDeletion cancels the scheduled deletion of the account of
+// the user.
+func (a *API) cancelAccountDeletion(r *http.Request, tx *storage.Connection, user *models.User, deletion *models.AccountDeletion) error {
+	if err := tx.Destroy(deletion); err != nil {
+		return internalServerError("Database error cancelling account deletion").WithInternalError(err)
+	}
+
+	if err := models.NewAuditLogEntry(r, tx, user, models.AccountDeletionCancelledAction, "", map[string]interface{}{
+		"delete_at": deletion.DeleteAt,
+	}); err != nil {
+		return internalServerError("Error recording audit log entry").WithInternalError(err)
+	}
+
+	return a.runAccountDeletionHook(r, tx, hooks.AccountDeletionCancelled, user, deletion.DeleteAt)
+}
+
+// UserDeletionCancel cancels the scheduled deletion of the account of the
+// user.
+func (a *API) UserDeletionCancel(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+
+	err := db.Transaction(func(tx *storage.Connection) error {
+		deletion, terr := models.FindAccountDeletionByUserID(tx, user.ID)
+		if terr != nil {
+			if models.IsNotFoundError(terr) {
+				return notFoundError(ErrorCodeAccountDeletionNotFound, "Account isn't scheduled for deletion")
+			}
+			return internalServerError("Database error finding account deletion").WithInternalError(terr)
+		}
+
+		return a.cancelAccountDeletion(r, tx, user, deletion)
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+// CancelAccountDeletion cancels the scheduled deletion of an account with
+// the link sent to its user, and redirects to the site.
+func (a *API) CancelAccountDeletion(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+
+	tokenHash := r.FormValue("token_hash")
+	redirectTo := utilities.GetReferrer(r, config)
+
+	err := db.Transaction(func(tx *storage.Connection) error {
+		if tokenHash == "" {
+			return badRequestError(ErrorCodeValidationFailed, "Cancelling an account deletion requires a token hash")
+		}
+
+		deletion, terr := models.FindAccountDeletionByTokenHash(tx, tokenHash)
+		if terr != nil {
+			if models.IsNotFoundError(terr) {
+				return notFoundError(ErrorCodeAccountDeletionNotFound, "Account deletion link is invalid or the account was already deleted")
+			}
+			return internalServerError("Database error finding account deletion").WithInternalError(terr)
+		}
+
+		user, terr := models.FindUserByID(tx, deletion.UserID)
+		if terr != nil {
+			return internalServerError("Database error finding user").WithInternalError(terr)
+		}
+
+		return a.cancelAccountDeletion(r, tx, user, deletion)
+	})
+
+	rurl := ""
+	if err != nil {
+		var herr *HTTPError
+		if !errors.As(err, &herr) {
+			return err
+		}
+
+		rurl, err = a.prepErrorRedirectURL(herr, r, redirectTo, models.ImplicitFlow)
+	} else {
+		rurl, err = a.prepRedirectURL(accountDeletionCancelledMessage, redirectTo, models.ImplicitFlow)
+	}
+	if err != nil {
+		return err
+	}
+
+	http.Redirect(w, r, rurl, http.StatusSeeOther)
+	return nil
+}
+
+// DeleteScheduledAccounts deletes the accounts past the grace period of
+// their scheduled deletion until the context is done.
+func (a *API) DeleteScheduledAccounts(ctx context.Context) {
+	config := a.config
+	if !config.AccountDeletion.Enabled {
+		return
+	}
+
+	ticker := time.NewTicker(config.AccountDeletion.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			a.deleteScheduledAccounts(ctx)
+		}
+	}
+}
+
+func (a *API) deleteScheduledAccounts(ctx context.Context) {
+	for ctx.Err() == nil {
+		deleted, err := a.deleteScheduledAccount(ctx)
+		if err != nil {
+			logrus.WithError(err).Error("Unable to delete scheduled account")
+			return
+		}
+
+		if !deleted {
+			return
+		}
+
+		deletedAccountsCounter.Add(ctx, 1)
+	}
+}
+
+// deleteScheduledAccount deletes an account past the grace period of its
+// scheduled deletion, and returns false when there is none. The account is
+// soft deleted when user deletion retention is enabled, so it can be
+// undeleted by admins until it's purged. The deletion is retried later when
+// the account deletion hook fails.
+func (a *API) deleteScheduledAccount(ctx context.Context) (bool, error) {
+	db := a.db.WithContext(ctx)
+	config := a.config
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", nil)
+	if err != nil {
+		return false, err
+	}
+
+	deleted := false
+	err = db.Transaction(func(tx *storage.Connection) error {
+		deletion, terr := models.FindDueAccountDeletion(tx, time.Now())
+		if terr != nil {
+			if models.IsNotFoundError(terr) {
+				return nil
+			}
+			return terr
+		}
+
+		user, terr := models.FindUserByID(tx, deletion.UserID)
+		if terr != nil {
+			return terr
+		}
+
+		if terr := a.runAccountDeletionHook(r, tx, hooks.AccountDeletionCompleted, user, deletion.DeleteAt); terr != nil {
+			return terr
+		}
+
+		if terr := models.NewAuditLogEntry(r, tx, user, models.UserDeletedAction, "", map[string]interface{}{
+			"user_id":    user.ID,
+			"user_email": user.Email,
+			"user_phone": user.Phone,
+			"scheduled":  true,
+		}); terr != nil {
+			return terr
+		}
+
+		if config.UserDeletion.Enabled {
+			if terr := tx.Destroy(deletion); terr != nil {
+				return terr
+			}
+
+			if terr := a.softDeleteUser(tx, user); terr != nil {
+				return terr
+			}
+		} else if terr := tx.Destroy(user); terr != nil {
+			return terr
+		}
+
+		logrus.WithField("user_id", user.ID).Info("Deleted scheduled account")
+		deleted = true
+		return nil
+	})
+
+	return deleted, err
+}
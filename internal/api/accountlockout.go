@@ -0,0 +1,246 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/hooks"
+	mail "github.com/supabase/auth/internal/mailer"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+	"github.com/supabase/auth/internal/utilities"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	accountLockedMessage   = "Account is locked after too many failed sign-in attempts"
+	accountUnlockedMessage = "Account unlocked"
+)
+
+var (
+	accountLockoutCounter = observability.ObtainMetricCounter("gotrue_account_lockouts", "Number of accounts locked after too many failed password attempts")
+	accountUnlockCounter  = observability.ObtainMetricCounter("gotrue_account_unlocks", "Number of locked accounts unlocked by method")
+)
+
+// isAccountLocked reports whether password sign-ins to the account of the
+// user are locked.
+func (a *API) isAccountLocked(tx *storage.Connection, user *models.User) (bool, error) {
+	if !a.config.AccountLockout.Enabled {
+		return false, nil
+	}
+
+	lockout, err := models.FindAccountLockoutByUserID(tx, user.ID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return false, nil
+		}
+		return false, internalServerError("Database error finding account lockout").WithInternalError(err)
+	}
+
+	return lockout.IsLocked(time.Now()), nil
+}
+
+// recordFailedPasswordAttempt counts a failed password attempt on the
+// account of the user, and locks it once there were too many within the
+// window, telling the user with a link to unlock it. It reports whether
+// the account was locked by this attempt.
+func (a *API) recordFailedPasswordAttempt(r *http.Request, user *models.User) (bool, error) {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config.AccountLockout
+
+	if !config.Enabled {
+		return false, nil
+	}
+
+	var lockout *models.AccountLockout
+	now := time.Now()
+	locked := false
+
+	err := db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		lockout, terr = models.RecordFailedPasswordAttempt(tx, user.ID, now, now.Add(-config.Window))
+		if terr != nil {
+			return terr
+		}
+
+		if lockout.FailedAttempts < config.MaxAttempts || lockout.IsLocked(now) {
+			return nil
+		}
+
+		if terr := lockout.Lock(tx, user, crypto.SecureToken(), now.Add(config.Duration)); terr != nil {
+			return terr
+		}
+
+		if terr := models.NewAuditLogEntry(r, tx, user, models.AccountLockedAction, "", map[string]interface{}{
+			"failed_attempts": lockout.FailedAttempts,
+			"locked_until":    lockout.LockedUntil,
+		}); terr != nil {
+			return terr
+		}
+
+		locked = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if locked {
+		accountLockoutCounter.Add(ctx, 1)
+
+		// the account stays locked when the user can't be told, as
+		// admins can still unlock it
+		if err := a.sendAccountLockedNotification(r, db, user, lockout); err != nil {
+			observability.GetLogEntry(r).Entry.WithError(err).Warn("Unable to send account locked notification")
+		}
+	}
+
+	return locked, nil
+}
+
+// sendAccountLockedNotification tells the user that password sign-ins to
+// their account were locked, with a link to unlock it.
+func (a *API) sendAccountLockedNotification(r *http.Request, tx *storage.Connection, user *models.User, lockout *models.AccountLockout) error {
+	config := a.config
+
+	if user.GetEmail() == "" || lockout.TokenHash == nil || lockout.LockedUntil == nil {
+		return nil
+	}
+
+	if config.Hook.SendEmail.Enabled {
+		input := hooks.SendEmailInput{
+			User: user,
+			EmailData: mail.EmailData{
+				EmailActionType: mail.AccountLockedNotification,
+				RedirectTo:      utilities.GetReferrer(r, config),
+				SiteURL:         getExternalHost(r.Context()).String(),
+				TokenHash:       *lockout.TokenHash,
+				LockedUntil:     lockout.LockedUntil.UTC().Format(time.RFC3339),
+			},
+		}
+		output := hooks.SendEmailOutput{}
+		return a.invokeHook(tx, r, &input, &output)
+	}
+
+	if err := a.Mailer().AccountLockedMail(r, user, *lockout.LockedUntil, *lockout.TokenHash, getExternalHost(r.Context())); err != nil {
+		return internalServerError("Error sending account locked notification").WithInternalError(err)
+	}
+
+	return nil
+}
+
+// unlockAccount unlocks the account of the user, recording who unlocked it
+// and how.
+func (a *API) unlockAccount(r *http.Request, tx *storage.Connection, actor, user *models.User, method string) error {
+	if err := models.ClearAccountLockoutForUser(tx, user.ID); err != nil {
+		return internalServerError("Database error unlocking account").WithInternalError(err)
+	}
+
+	if err := models.NewAuditLogEntry(r, tx, actor, models.AccountUnlockedAction, "", map[string]interface{}{
+		"user_id": user.ID,
+		"method":  method,
+	}); err != nil {
+		return internalServerError("Error recording audit log entry").WithInternalError(err)
+	}
+
+	accountUnlockCounter.Add(r.Context(), 1, metric.WithAttributeSet(attribute.NewSet(attribute.String("method", method))))
+	return nil
+}
+
+// UnlockAccount unlocks an account with the link sent to its user when it
+// was locked, and redirects to the site.
+func (a *API) UnlockAccount(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+
+	tokenHash := r.FormValue("token_hash")
+	redirectTo := utilities.GetReferrer(r, config)
+
+	err := db.Transaction(func(tx *storage.Connection) error {
+		if tokenHash == "" {
+			return badRequestError(ErrorCodeValidationFailed, "Unlocking an account requires a token hash")
+		}
+
+		lockout, terr := models.FindAccountLockoutByTokenHash(tx, tokenHash)
+		if terr != nil {
+			if models.IsNotFoundError(terr) {
+				return notFoundError(ErrorCodeAccountLockoutNotFound, "Account unlock link is invalid or the account was already unlocked")
+			}
+			return internalServerError("Database error finding account lockout").WithInternalError(terr)
+		}
+
+		user, terr := models.FindUserByID(tx, lockout.UserID)
+		if terr != nil {
+			return internalServerError("Database error finding user").WithInternalError(terr)
+		}
+
+		return a.unlockAccount(r, tx, user, user, "link")
+	})
+
+	rurl := ""
+	if err != nil {
+		var herr *HTTPError
+		if !errors.As(err, &herr) {
+			return err
+		}
+
+		rurl, err = a.prepErrorRedirectURL(herr, r, redirectTo, models.ImplicitFlow)
+	} else {
+		rurl, err = a.prepRedirectURL(accountUnlockedMessage, redirectTo, models.ImplicitFlow)
+	}
+	if err != nil {
+		return err
+	}
+
+	http.Redirect(w, r, rurl, http.StatusSeeOther)
+	return nil
+}
+
+// adminUserLockoutGet returns the failed password attempts on the account
+// of the user, and whether it's locked.
+func (a *API) adminUserLockoutGet(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+
+	lockout, err := models.FindAccountLockoutByUserID(db, user.ID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return notFoundError(ErrorCodeAccountLockoutNotFound, "Account has no failed password attempts")
+		}
+		return internalServerError("Database error finding account lockout").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, lockout)
+}
+
+// adminUserUnlock unlocks the account of the user and forgets its failed
+// password attempts.
+func (a *API) adminUserUnlock(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	adminUser := getAdminUser(ctx)
+	user := getUser(ctx)
+
+	err := db.Transaction(func(tx *storage.Connection) error {
+		if _, terr := models.FindAccountLockoutByUserID(tx, user.ID); terr != nil {
+			if models.IsNotFoundError(terr) {
+				return notFoundError(ErrorCodeAccountLockoutNotFound, "Account has no failed password attempts")
+			}
+			return internalServerError("Database error finding account lockout").WithInternalError(terr)
+		}
+
+		return a.unlockAccount(r, tx, adminUser, user, "admin")
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
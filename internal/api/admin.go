@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
-	"time"
 
 	"github.com/fatih/structs"
 	"github.com/go-chi/chi/v5"
@@ -14,6 +13,7 @@ import (
 	"github.com/supabase/auth/internal/api/provider"
 	"github.com/supabase/auth/internal/models"
 	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/revocation"
 	"github.com/supabase/auth/internal/storage"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -24,6 +24,7 @@ type AdminUserParams struct {
 	Role         string                 `json:"role"`
 	Email        string                 `json:"email"`
 	Phone        string                 `json:"phone"`
+	Username     string                 `json:"username"`
 	Password     *string                `json:"password"`
 	PasswordHash string                 `json:"password_hash"`
 	EmailConfirm bool                   `json:"email_confirm"`
@@ -31,6 +32,15 @@ type AdminUserParams struct {
 	UserMetaData map[string]interface{} `json:"user_metadata"`
 	AppMetaData  map[string]interface{} `json:"app_metadata"`
 	BanDuration  string                 `json:"ban_duration"`
+	BanReason    string                 `json:"ban_reason"`
+
+	// PasswordExpired marks the password as expired, so it has to be
+	// reset before signing in with it, or no longer expired.
+	PasswordExpired *bool `json:"password_expired"`
+}
+
+type adminUsersExpirePasswordsResponse struct {
+	Expired int `json:"expired"`
 }
 
 type adminUserDeleteParams struct {
@@ -43,8 +53,9 @@ type adminUserUpdateFactorParams struct {
 }
 
 type AdminListUsersResponse struct {
-	Users []*models.User `json:"users"`
-	Aud   string         `json:"aud"`
+	Users      []*models.User `json:"users"`
+	Aud        string         `json:"aud"`
+	NextCursor string         `json:"next_cursor,omitempty"`
 }
 
 func (a *API) loadUser(w http.ResponseWriter, r *http.Request) (context.Context, error) {
@@ -100,6 +111,28 @@ func (a *API) getAdminParams(r *http.Request) (*AdminUserParams, error) {
 	return params, nil
 }
 
+// checkPrivilegeChanges requires the roles:manage scope from scoped admin
+// credentials to set the role or the app_metadata of users, as they grant
+// privileges, up to the admin roles.
+func checkPrivilegeChanges(ctx context.Context, role string, appMetaData map[string]interface{}) error {
+	if role == "" && appMetaData == nil {
+		return nil
+	}
+
+	return checkAdminScope(ctx, models.AdminScopeRolesManage)
+}
+
+// checkAdminTargetSignIn requires the roles:manage scope from scoped admin
+// credentials to change how users of the admin roles sign in, like their
+// password, email or phone, as signing in as them grants all the scopes.
+func (a *API) checkAdminTargetSignIn(ctx context.Context, user *models.User) error {
+	if !isStringInSlice(user.Role, a.config.JWT.AdminRoles) {
+		return nil
+	}
+
+	return checkAdminScope(ctx, models.AdminScopeRolesManage)
+}
+
 // adminUsers responds with a list of all users in a given audience
 func (a *API) adminUsers(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
@@ -111,12 +144,39 @@ func (a *API) adminUsers(w http.ResponseWriter, r *http.Request) error {
 		return badRequestError(ErrorCodeValidationFailed, "Bad Pagination Parameters: %v", err).WithInternalError(err)
 	}
 
-	sortParams, err := sort(r, map[string]bool{models.CreatedAt: true}, []models.SortField{{Name: models.CreatedAt, Dir: models.Descending}})
+	sortParams, err := sort(r, models.UserSortFields, []models.SortField{{Name: models.CreatedAt, Dir: models.Descending}})
 	if err != nil {
 		return badRequestError(ErrorCodeValidationFailed, "Bad Sort Parameters: %v", err)
 	}
 
-	filter := r.URL.Query().Get("filter")
+	filter, err := parseUserFilter(r.URL.Query())
+	if err != nil {
+		return err
+	}
+
+	// cursors go through the users sorted by a single field
+	if cursorValue := r.URL.Query().Get("cursor"); cursorValue != "" {
+		if len(sortParams.Fields) != 1 {
+			return badRequestError(ErrorCodeValidationFailed, "Cursors can only be used when sorting by a single field")
+		}
+		field := sortParams.Fields[0]
+
+		cursor, err := decodeUserCursor(cursorValue, field)
+		if err != nil {
+			return err
+		}
+
+		users, err := models.FindUsersInAudienceByCursor(db, aud, field, cursor, int(pageParams.PerPage), filter) // #nosec G115
+		if err != nil {
+			return internalServerError("Database error finding users").WithInternalError(err)
+		}
+
+		return sendJSON(w, http.StatusOK, AdminListUsersResponse{
+			Users:      users,
+			Aud:        aud,
+			NextCursor: nextUserCursor(users, sortParams, pageParams.PerPage),
+		})
+	}
 
 	users, err := models.FindUsersInAudience(db, aud, pageParams, sortParams, filter)
 	if err != nil {
@@ -125,11 +185,22 @@ func (a *API) adminUsers(w http.ResponseWriter, r *http.Request) error {
 	addPaginationHeaders(w, r, pageParams)
 
 	return sendJSON(w, http.StatusOK, AdminListUsersResponse{
-		Users: users,
-		Aud:   aud,
+		Users:      users,
+		Aud:        aud,
+		NextCursor: nextUserCursor(users, sortParams, pageParams.PerPage),
 	})
 }
 
+// nextUserCursor returns the cursor of the users after a full page, when
+// they're sorted by a single field.
+func nextUserCursor(users []*models.User, sortParams *models.SortParams, perPage uint64) string {
+	if len(sortParams.Fields) != 1 || len(users) == 0 || uint64(len(users)) < perPage {
+		return ""
+	}
+
+	return encodeUserCursor(users[len(users)-1], sortParams.Fields[0])
+}
+
 // adminUserGet returns information about a single user
 func (a *API) adminUserGet(w http.ResponseWriter, r *http.Request) error {
 	user := getUser(r.Context())
@@ -163,25 +234,52 @@ func (a *API) adminUserUpdate(w http.ResponseWriter, r *http.Request) error {
 		}
 	}
 
-	var banDuration *time.Duration
-	if params.BanDuration != "" {
-		duration := time.Duration(0)
-		if params.BanDuration != "none" {
-			duration, err = time.ParseDuration(params.BanDuration)
-			if err != nil {
-				return badRequestError(ErrorCodeValidationFailed, "invalid format for ban duration: %v", err)
-			}
+	if params.Username != "" {
+		if params.Username, err = a.validateAdminUsername(params.Username); err != nil {
+			return err
 		}
-		banDuration = &duration
+		if err := checkUsernameAvailable(db, params.Username, user); err != nil {
+			return err
+		}
+	}
+
+	banDuration, err := parseBanDuration(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	if err := checkPrivilegeChanges(ctx, params.Role, params.AppMetaData); err != nil {
+		return err
 	}
 
+	if params.Password != nil || params.Email != "" || params.Phone != "" {
+		if err := a.checkAdminTargetSignIn(ctx, user); err != nil {
+			return err
+		}
+	}
+
+	if err := a.validateMetadataUpdates(user, params.UserMetaData, params.AppMetaData); err != nil {
+		return err
+	}
+
+	if params.PasswordExpired != nil && *params.PasswordExpired && params.Password == nil && !user.HasPassword() {
+		return badRequestError(ErrorCodeValidationFailed, "User has no password to expire")
+	}
+
+	var previousPassword *string
 	if params.Password != nil {
 		password := *params.Password
 
-		if err := a.checkPasswordStrength(ctx, password); err != nil {
+		userInputs := append(passwordUserInputs(params.Email, params.Phone, params.Username, params.UserMetaData), userPasswordInputs(user)...)
+		if err := a.checkPasswordStrength(ctx, password, userInputs...); err != nil {
+			return err
+		}
+
+		if err := a.checkPasswordHistory(ctx, db, user, password); err != nil {
 			return err
 		}
 
+		previousPassword = user.EncryptedPassword
 		if err := user.SetPassword(ctx, password, config.Security.DBEncryption.Encrypt, config.Security.DBEncryption.EncryptionKeyID, config.Security.DBEncryption.EncryptionKey); err != nil {
 			return err
 		}
@@ -210,6 +308,28 @@ func (a *API) adminUserUpdate(w http.ResponseWriter, r *http.Request) error {
 			if terr := user.UpdatePassword(tx, nil); terr != nil {
 				return terr
 			}
+
+			if terr := a.recordPasswordHistory(tx, user, previousPassword); terr != nil {
+				return terr
+			}
+		}
+
+		if params.PasswordExpired != nil {
+			wasExpired := user.IsPasswordExpired()
+
+			if terr := user.SetPasswordExpired(tx, *params.PasswordExpired); terr != nil {
+				return terr
+			}
+
+			if *params.PasswordExpired && !wasExpired {
+				if terr := models.NewAuditLogEntry(r, tx, adminUser, models.UserPasswordExpiredAction, "", map[string]interface{}{
+					"user_id":    user.ID,
+					"user_email": user.Email,
+					"user_phone": user.Phone,
+				}); terr != nil {
+					return terr
+				}
+			}
 		}
 
 		var identities []models.Identity
@@ -283,6 +403,13 @@ func (a *API) adminUserUpdate(w http.ResponseWriter, r *http.Request) error {
 				return terr
 			}
 		}
+
+		if params.Username != "" && params.Username != user.GetUsername() {
+			if terr := a.updateUsername(tx, user, params.Username); terr != nil {
+				return terr
+			}
+		}
+
 		user.Identities = append(user.Identities, identities...)
 
 		if params.AppMetaData != nil {
@@ -298,7 +425,7 @@ func (a *API) adminUserUpdate(w http.ResponseWriter, r *http.Request) error {
 		}
 
 		if banDuration != nil {
-			if terr := user.Ban(tx, *banDuration); terr != nil {
+			if terr := banUser(r, tx, adminUser, user, *banDuration, params.BanReason); terr != nil {
 				return terr
 			}
 		}
@@ -317,9 +444,41 @@ func (a *API) adminUserUpdate(w http.ResponseWriter, r *http.Request) error {
 		return internalServerError("Error updating user").WithInternalError(err)
 	}
 
+	// the access tokens of banned users are denied right away
+	if banDuration != nil && *banDuration > 0 {
+		a.propagateRevocation(r, revocation.UserRevocation(user.ID, nil))
+	}
+
 	return sendJSON(w, http.StatusOK, user)
 }
 
+// adminUsersExpirePasswords marks the passwords of all the users as expired,
+// like after a breach, so they have to be reset before signing in.
+func (a *API) adminUsersExpirePasswords(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	adminUser := getAdminUser(ctx)
+
+	var expired int
+	err := db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if expired, terr = models.ExpireAllPasswords(tx); terr != nil {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, adminUser, models.PasswordsExpiredAction, "", map[string]interface{}{
+			"expired": expired,
+		})
+	})
+	if err != nil {
+		return internalServerError("Error expiring passwords").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, &adminUsersExpirePasswordsResponse{
+		Expired: expired,
+	})
+}
+
 // adminUserCreate creates a new user based on the provided data
 func (a *API) adminUserCreate(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
@@ -337,8 +496,8 @@ func (a *API) adminUserCreate(w http.ResponseWriter, r *http.Request) error {
 		aud = params.Aud
 	}
 
-	if params.Email == "" && params.Phone == "" {
-		return badRequestError(ErrorCodeValidationFailed, "Cannot create a user without either an email or phone")
+	if params.Email == "" && params.Phone == "" && params.Username == "" {
+		return badRequestError(ErrorCodeValidationFailed, "Cannot create a user without either an email, phone or username")
 	}
 
 	var providers []string
@@ -368,10 +527,36 @@ func (a *API) adminUserCreate(w http.ResponseWriter, r *http.Request) error {
 		providers = append(providers, "phone")
 	}
 
+	if params.Username != "" {
+		if params.Username, err = a.validateAdminUsername(params.Username); err != nil {
+			return err
+		}
+		if err := checkUsernameAvailable(db, params.Username, nil); err != nil {
+			return err
+		}
+		// users only sign in with their username identity when they have
+		// no email or phone to sign in with
+		if len(providers) == 0 {
+			providers = append(providers, "username")
+		}
+	}
+
 	if params.Password != nil && params.PasswordHash != "" {
 		return badRequestError(ErrorCodeValidationFailed, "Only a password or a password hash should be provided")
 	}
 
+	if err := a.validateUserMetadata(params.UserMetaData); err != nil {
+		return err
+	}
+
+	if err := a.validateAppMetadata(params.AppMetaData); err != nil {
+		return err
+	}
+
+	if err := checkPrivilegeChanges(ctx, params.Role, params.AppMetaData); err != nil {
+		return err
+	}
+
 	if (params.Password == nil || *params.Password == "") && params.PasswordHash == "" {
 		password, err := password.Generate(64, 10, 0, false, true)
 		if err != nil {
@@ -395,16 +580,16 @@ func (a *API) adminUserCreate(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	if params.Id != "" {
-		customId, err := uuid.FromString(params.Id)
+		user.ID, err = parseUserID(params.Id)
 		if err != nil {
-			return badRequestError(ErrorCodeValidationFailed, "ID must conform to the uuid v4 format")
+			return err
 		}
-		if customId == uuid.Nil {
-			return badRequestError(ErrorCodeValidationFailed, "ID cannot be a nil uuid")
+		if err := checkUserIDAvailable(db, user.ID); err != nil {
+			return err
 		}
-		user.ID = customId
 	}
 
+	user.Username = storage.NullString(params.Username)
 	user.AppMetaData = map[string]interface{}{
 		// TODO: Deprecate "provider" field
 		// default to the first provider in the providers slice
@@ -412,16 +597,9 @@ func (a *API) adminUserCreate(w http.ResponseWriter, r *http.Request) error {
 		"providers": providers,
 	}
 
-	var banDuration *time.Duration
-	if params.BanDuration != "" {
-		duration := time.Duration(0)
-		if params.BanDuration != "none" {
-			duration, err = time.ParseDuration(params.BanDuration)
-			if err != nil {
-				return badRequestError(ErrorCodeValidationFailed, "invalid format for ban duration: %v", err)
-			}
-		}
-		banDuration = &duration
+	banDuration, err := parseBanDuration(ctx, params)
+	if err != nil {
+		return err
 	}
 
 	err = db.Transaction(func(tx *storage.Connection) error {
@@ -454,6 +632,18 @@ func (a *API) adminUserCreate(w http.ResponseWriter, r *http.Request) error {
 			identities = append(identities, *identity)
 		}
 
+		if providers[0] == "username" {
+			identity, terr := a.createNewIdentity(tx, user, "username", map[string]interface{}{
+				"sub":      user.ID.String(),
+				"username": user.GetUsername(),
+			})
+
+			if terr != nil {
+				return terr
+			}
+			identities = append(identities, *identity)
+		}
+
 		user.Identities = identities
 
 		if terr := models.NewAuditLogEntry(r, tx, adminUser, models.UserSignedUpAction, "", map[string]interface{}{
@@ -491,7 +681,7 @@ func (a *API) adminUserCreate(w http.ResponseWriter, r *http.Request) error {
 		}
 
 		if banDuration != nil {
-			if terr := user.Ban(tx, *banDuration); terr != nil {
+			if terr := banUser(r, tx, adminUser, user, *banDuration, params.BanReason); terr != nil {
 				return terr
 			}
 		}
@@ -506,6 +696,31 @@ func (a *API) adminUserCreate(w http.ResponseWriter, r *http.Request) error {
 	return sendJSON(w, http.StatusOK, user)
 }
 
+// parseUserID parses the ID given to a new user, like the one it had in the
+// system it's migrated from, so that references to it stay valid.
+func parseUserID(id string) (uuid.UUID, error) {
+	userID, err := uuid.FromString(id)
+	if err != nil {
+		return uuid.Nil, badRequestError(ErrorCodeValidationFailed, "ID must conform to the uuid v4 format")
+	}
+	if userID == uuid.Nil {
+		return uuid.Nil, badRequestError(ErrorCodeValidationFailed, "ID cannot be a nil uuid")
+	}
+	return userID, nil
+}
+
+// checkUserIDAvailable checks that no user, including soft deleted users,
+// has the ID.
+func checkUserIDAvailable(tx *storage.Connection, id uuid.UUID) error {
+	if _, err := models.FindUserByID(tx, id); err == nil {
+		return unprocessableEntityError(ErrorCodeUserAlreadyExists, "A user with this ID already exists")
+	} else if !models.IsNotFoundError(err) {
+		return internalServerError("Database error finding user").WithInternalError(err)
+	}
+
+	return nil
+}
+
 // adminUserDelete deletes a user
 func (a *API) adminUserDelete(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
@@ -540,21 +755,9 @@ func (a *API) adminUserDelete(w http.ResponseWriter, r *http.Request) error {
 				// user has been soft deleted already
 				return nil
 			}
-			if terr := user.SoftDeleteUser(tx); terr != nil {
-				return internalServerError("Error soft deleting user").WithInternalError(terr)
-			}
-
-			if terr := user.SoftDeleteUserIdentities(tx); terr != nil {
-				return internalServerError("Error soft deleting user identities").WithInternalError(terr)
-			}
 
-			// hard delete all associated factors
-			if terr := models.DeleteFactorsByUserId(tx, user.ID); terr != nil {
-				return internalServerError("Error deleting user's factors").WithInternalError(terr)
-			}
-			// hard delete all associated sessions
-			if terr := models.Logout(tx, user.ID); terr != nil {
-				return internalServerError("Error deleting user's sessions").WithInternalError(terr)
+			if terr := a.softDeleteUser(tx, user); terr != nil {
+				return terr
 			}
 		} else {
 			if terr := tx.Destroy(user); terr != nil {
@@ -568,6 +771,8 @@ func (a *API) adminUserDelete(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	a.propagateRevocation(r, revocation.UserRevocation(user.ID, nil))
+
 	return sendJSON(w, http.StatusOK, map[string]interface{}{})
 }
 
@@ -17,6 +17,7 @@ import (
 	"github.com/stretchr/testify/suite"
 	"github.com/supabase/auth/internal/conf"
 	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/revocation"
 )
 
 type AdminTestSuite struct {
@@ -493,6 +494,327 @@ func (ts *AdminTestSuite) TestAdminUserUpdate() {
 	}
 }
 
+func (ts *AdminTestSuite) TestAdminUserExpirePassword() {
+	u, err := models.NewUser("", "test1@example.com", "test", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err, "Error making new user")
+	require.NoError(ts.T(), ts.API.db.Create(u), "Error creating user")
+
+	update := func(params map[string]interface{}) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(params))
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/admin/users/%s", u.ID), &buffer)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := update(map[string]interface{}{"password_expired": true})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	u, err = models.FindUserByID(ts.API.db, u.ID)
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), u.IsPasswordExpired())
+
+	w = update(map[string]interface{}{"password_expired": false})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	u, err = models.FindUserByID(ts.API.db, u.ID)
+	require.NoError(ts.T(), err)
+	require.False(ts.T(), u.IsPasswordExpired())
+
+	// a temporary password has to be reset before signing in with it
+	w = update(map[string]interface{}{"password": "temporary-password", "password_expired": true})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	u, err = models.FindUserByID(ts.API.db, u.ID)
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), u.IsPasswordExpired())
+}
+
+func (ts *AdminTestSuite) TestAdminUsersExpirePasswords() {
+	withPassword, err := models.NewUser("", "test1@example.com", "test", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err, "Error making new user")
+	require.NoError(ts.T(), ts.API.db.Create(withPassword), "Error creating user")
+
+	withoutPassword, err := models.NewUser("", "test2@example.com", "", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err, "Error making new user")
+	require.NoError(ts.T(), ts.API.db.Create(withoutPassword), "Error creating user")
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/admin/users/expire_passwords", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var data adminUsersExpirePasswordsResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	require.Equal(ts.T(), 1, data.Expired)
+
+	withPassword, err = models.FindUserByID(ts.API.db, withPassword.ID)
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), withPassword.IsPasswordExpired())
+
+	withoutPassword, err = models.FindUserByID(ts.API.db, withoutPassword.ID)
+	require.NoError(ts.T(), err)
+	require.False(ts.T(), withoutPassword.IsPasswordExpired())
+}
+
+func (ts *AdminTestSuite) TestAdminUserCreateWithExistingID() {
+	u, err := models.NewUser("", "test1@example.com", "test", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err, "Error making new user")
+	require.NoError(ts.T(), ts.API.db.Create(u), "Error creating user")
+
+	var buffer bytes.Buffer
+	require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+		"id":    u.ID.String(),
+		"email": "test2@example.com",
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/users", &buffer)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+
+	var data HTTPError
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	require.Equal(ts.T(), ErrorCodeUserAlreadyExists, data.ErrorCode)
+
+	_, err = models.FindUserByEmailAndAudience(ts.API.db, "test2@example.com", ts.Config.JWT.Aud)
+	require.True(ts.T(), models.IsNotFoundError(err))
+}
+
+func (ts *AdminTestSuite) TestAdminUserAnnotations() {
+	u, err := models.NewUser("", "test1@example.com", "test", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err, "Error making new user")
+	require.NoError(ts.T(), ts.API.db.Create(u), "Error creating user")
+
+	other, err := models.NewUser("", "test2@example.com", "test", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err, "Error making new user")
+	require.NoError(ts.T(), ts.API.db.Create(other), "Error creating user")
+
+	request := func(method, path string, body map[string]interface{}) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		if body != nil {
+			require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(body))
+		}
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, &buffer)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+		req.Header.Set("Content-Type", "application/json")
+
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	notesPath := fmt.Sprintf("/admin/users/%s/notes", u.ID)
+
+	w := request(http.MethodPost, notesPath, map[string]interface{}{"body": " "})
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+
+	w = request(http.MethodPost, notesPath, map[string]interface{}{"body": "Asked for a refund"})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var note models.UserNote
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&note))
+	require.Equal(ts.T(), "Asked for a refund", note.Body)
+
+	w = request(http.MethodGet, notesPath, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var notes struct {
+		Notes []*models.UserNote `json:"notes"`
+	}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&notes))
+	require.Len(ts.T(), notes.Notes, 1)
+
+	w = request(http.MethodDelete, fmt.Sprintf("/admin/users/%s/notes/%s", other.ID, note.ID), nil)
+	require.Equal(ts.T(), http.StatusNotFound, w.Code)
+
+	w = request(http.MethodDelete, fmt.Sprintf("%s/%s", notesPath, note.ID), nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	tagsPath := fmt.Sprintf("/admin/users/%s/tags", u.ID)
+
+	w = request(http.MethodPut, tagsPath+"/Not%20Valid", map[string]interface{}{})
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+
+	w = request(http.MethodPut, tagsPath+"/vip", map[string]interface{}{})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	w = request(http.MethodPut, tagsPath+"/review", map[string]interface{}{"value": "pending"})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	w = request(http.MethodPut, tagsPath+"/review", map[string]interface{}{"value": "fraud"})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	w = request(http.MethodGet, tagsPath, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var tags struct {
+		Tags []*models.UserTag `json:"tags"`
+	}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&tags))
+	require.Len(ts.T(), tags.Tags, 2)
+	require.Equal(ts.T(), "review", tags.Tags[0].Name)
+	require.Equal(ts.T(), "fraud", tags.Tags[0].Value)
+
+	for query, expected := range map[string]int{
+		"tag=vip":                  1,
+		"tag=vip&tag.review=fraud": 1,
+		"tag.review=pending":       0,
+		"tag=vip&tag=unknown":      0,
+	} {
+		w = request(http.MethodGet, "/admin/users?"+query, nil)
+		require.Equal(ts.T(), http.StatusOK, w.Code)
+
+		var data AdminListUsersResponse
+		require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+		require.Len(ts.T(), data.Users, expected, query)
+	}
+
+	w = request(http.MethodDelete, tagsPath+"/vip", nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	w = request(http.MethodDelete, tagsPath+"/vip", nil)
+	require.Equal(ts.T(), http.StatusNotFound, w.Code)
+}
+
+func (ts *AdminTestSuite) TestAdminUserLockout() {
+	ts.Config.AccountLockout.Enabled = true
+	ts.Config.AccountLockout.MaxAttempts = 1
+	ts.Config.AccountLockout.Window = 15 * time.Minute
+	ts.Config.AccountLockout.Duration = 30 * time.Minute
+	defer func() {
+		ts.Config.AccountLockout.Enabled = false
+	}()
+
+	u, err := models.NewUser("", "test1@example.com", "test", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err, "Error making new user")
+	require.NoError(ts.T(), ts.API.db.Create(u), "Error creating user")
+
+	lockout := func(method string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, fmt.Sprintf("/admin/users/%s/lockout", u.ID), nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := lockout(http.MethodGet)
+	require.Equal(ts.T(), http.StatusNotFound, w.Code)
+
+	now := time.Now()
+	l, err := models.RecordFailedPasswordAttempt(ts.API.db, u.ID, now, now.Add(-ts.Config.AccountLockout.Window))
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), l.Lock(ts.API.db, u, "token", now.Add(ts.Config.AccountLockout.Duration)))
+
+	w = lockout(http.MethodGet)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var data models.AccountLockout
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	require.Equal(ts.T(), 1, data.FailedAttempts)
+	require.True(ts.T(), data.IsLocked(now))
+
+	w = lockout(http.MethodDelete)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	_, err = models.FindAccountLockoutByUserID(ts.API.db, u.ID)
+	require.True(ts.T(), models.IsNotFoundError(err))
+
+	w = lockout(http.MethodDelete)
+	require.Equal(ts.T(), http.StatusNotFound, w.Code)
+}
+
+func (ts *AdminTestSuite) TestAdminBulkInvite() {
+	ts.Config.Outbox.Enabled = true
+	ts.Config.BulkInvite.Enabled = true
+	ts.Config.BulkInvite.MaxInvitations = 3
+	defer func() {
+		ts.Config.Outbox.Enabled = false
+		ts.Config.BulkInvite.Enabled = false
+	}()
+
+	u, err := models.NewUser("", "confirmed@example.com", "test", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err, "Error making new user")
+	u.EmailConfirmedAt = &time.Time{}
+	require.NoError(ts.T(), ts.API.db.Create(u), "Error creating user")
+
+	invite := func(body map[string]interface{}) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(body))
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/admin/invite/bulk", &buffer)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+		req.Header.Set("Content-Type", "application/json")
+
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := invite(map[string]interface{}{"invitations": []interface{}{}})
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+
+	w = invite(map[string]interface{}{
+		"invitations": []interface{}{
+			map[string]interface{}{"email": "a@example.com"},
+			map[string]interface{}{"email": "b@example.com"},
+			map[string]interface{}{"email": "c@example.com"},
+			map[string]interface{}{"email": "d@example.com"},
+		},
+	})
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+
+	w = invite(map[string]interface{}{
+		"data": map[string]interface{}{"team": "sales", "seat": "standard"},
+		"invitations": []interface{}{
+			map[string]interface{}{"email": "bulk@example.com", "data": map[string]interface{}{"seat": "owner"}},
+			map[string]interface{}{"email": "BULK@example.com"},
+			map[string]interface{}{"email": "confirmed@example.com"},
+		},
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var data BulkInviteResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	require.Len(ts.T(), data.Invitations, 3)
+	require.Equal(ts.T(), models.BulkInviteInvited, data.Invitations[0].Status)
+	require.NotNil(ts.T(), data.Invitations[0].UserID)
+	require.Equal(ts.T(), models.OutboxStatusPending, data.Invitations[0].EmailStatus.String())
+	require.Equal(ts.T(), models.BulkInviteFailed, data.Invitations[1].Status)
+	require.Equal(ts.T(), models.BulkInviteFailed, data.Invitations[2].Status)
+
+	invited, err := models.FindUserByID(ts.API.db, *data.Invitations[0].UserID)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), "sales", invited.UserMetaData["team"])
+	require.Equal(ts.T(), "owner", invited.UserMetaData["seat"])
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/admin/invite/bulk/%s", data.BatchID), nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var batch BulkInviteResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&batch))
+	require.Len(ts.T(), batch.Invitations, 3)
+	require.Equal(ts.T(), models.OutboxStatusPending, batch.Invitations[0].EmailStatus.String())
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/admin/invite/bulk/%s", uuid.Must(uuid.NewV4())), nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusNotFound, w.Code)
+}
+
 func (ts *AdminTestSuite) TestAdminUserUpdatePasswordFailed() {
 	u, err := models.NewUser("12345678", "test1@example.com", "test", ts.Config.JWT.Aud, nil)
 	require.NoError(ts.T(), err, "Error making new user")
@@ -539,6 +861,284 @@ func (ts *AdminTestSuite) TestAdminUserUpdateBannedUntilFailed() {
 	})
 }
 
+func (ts *AdminTestSuite) TestAdminUserBans() {
+	u, err := models.NewUser("", "test1@example.com", "test", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err, "Error making new user")
+	require.NoError(ts.T(), ts.API.db.Create(u), "Error creating user")
+
+	update := func(body map[string]interface{}) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(body))
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/admin/users/%s", u.ID), &buffer)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	bans := func() []*models.UserBan {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/admin/users/%s/bans", u.ID), nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+		ts.API.handler.ServeHTTP(w, req)
+		require.Equal(ts.T(), http.StatusOK, w.Code)
+
+		data := struct {
+			Bans []*models.UserBan `json:"bans"`
+		}{}
+		require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+		return data.Bans
+	}
+
+	require.Equal(ts.T(), http.StatusBadRequest, update(map[string]interface{}{"ban_reason": "Spam"}).Code)
+
+	require.Equal(ts.T(), http.StatusOK, update(map[string]interface{}{"ban_duration": "24h", "ban_reason": "Spam"}).Code)
+	history := bans()
+	require.Len(ts.T(), history, 1)
+	require.Equal(ts.T(), "Spam", *history[0].Reason)
+	require.Nil(ts.T(), history[0].LiftedAt)
+
+	require.Equal(ts.T(), http.StatusOK, update(map[string]interface{}{"ban_duration": "none"}).Code)
+	history = bans()
+	require.Len(ts.T(), history, 1)
+	require.NotNil(ts.T(), history[0].LiftedAt)
+}
+
+func (ts *AdminTestSuite) TestAdminUserRevocations() {
+	revocations, err := revocation.NewPropagator(&conf.RevocationsConfiguration{URL: "redis://127.0.0.1:1", Channel: "gotrue.revocations"}, time.Hour)
+	require.NoError(ts.T(), err)
+	ts.API.revocations = revocations
+	defer func() {
+		ts.API.revocations = nil
+	}()
+
+	u, err := models.NewUser("", "test1@example.com", "test", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err, "Error making new user")
+	require.NoError(ts.T(), ts.API.db.Create(u), "Error creating user")
+
+	session, err := models.NewSession(u.ID, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(session))
+
+	req := httptest.NewRequest(http.MethodPost, "/token?grant_type=password", nil)
+	token, _, err := ts.API.generateAccessToken(req, ts.API.db, u, &session.ID, models.PasswordGrant)
+	require.NoError(ts.T(), err)
+
+	getUser := func() int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/user", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		ts.API.handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	require.Equal(ts.T(), http.StatusOK, getUser())
+
+	// revoked access tokens are denied from the deny list, even though the
+	// session is still in the database, and even when the revocation
+	// couldn't be published to the unreachable server
+	ts.API.propagateRevocation(req, revocation.SessionRevocation(u.ID, session.ID))
+	require.Equal(ts.T(), http.StatusForbidden, getUser())
+}
+
+func (ts *AdminTestSuite) TestAdminUserImpersonate() {
+	ts.Config.Impersonation.Enabled = true
+	ts.Config.Impersonation.SessionDuration = time.Hour
+	defer func() {
+		ts.Config.Impersonation.Enabled = false
+	}()
+
+	u, err := models.NewUser("", "test1@example.com", "test", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err, "Error making new user")
+	require.NoError(ts.T(), ts.API.db.Create(u), "Error creating user")
+
+	request := func(method, path, token string, body map[string]interface{}) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(body))
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, &buffer)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	impersonatePath := fmt.Sprintf("/admin/users/%s/impersonate", u.ID)
+	require.Equal(ts.T(), http.StatusBadRequest, request(http.MethodPost, impersonatePath, ts.token, map[string]interface{}{}).Code)
+
+	w := request(http.MethodPost, impersonatePath, ts.token, map[string]interface{}{"reason": "Support ticket 123"})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	token := AccessTokenResponse{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&token))
+
+	claims := &AccessTokenClaims{}
+	_, err = jwt.NewParser().ParseWithClaims(token.Token, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(ts.Config.JWT.Secret), nil
+	})
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), u.ID.String(), claims.Subject)
+	require.Equal(ts.T(), &models.ActorClaim{Subject: "supabase_admin"}, claims.Actor)
+
+	// the requests of the impersonation session are audited
+	require.Equal(ts.T(), http.StatusOK, request(http.MethodGet, "/user", token.Token, nil).Code)
+	entries, err := models.FindAuditLogEntries(ts.API.db, nil, "", models.AuditLogFilter{Actions: []string{string(models.ImpersonatedRequestAction)}}, nil)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), entries, 1)
+
+	// and can't change the credentials or identities of the user
+	require.Equal(ts.T(), http.StatusForbidden, request(http.MethodPut, "/user", token.Token, map[string]interface{}{"password": "new-password"}).Code)
+	require.Equal(ts.T(), http.StatusForbidden, request(http.MethodGet, "/user/provider_token", token.Token, nil).Code)
+	require.Equal(ts.T(), http.StatusForbidden, request(http.MethodPost, fmt.Sprintf("/user/identities/%s/sync", uuid.Must(uuid.NewV4())), token.Token, nil).Code)
+
+	w = request(http.MethodDelete, impersonatePath, ts.token, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	require.JSONEq(ts.T(), `{"revoked": 1}`, w.Body.String())
+
+	require.Equal(ts.T(), http.StatusForbidden, request(http.MethodGet, "/user", token.Token, nil).Code)
+
+	// admins can't be impersonated
+	admin, err := models.NewUser("", "admin@example.com", "test", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err, "Error making new user")
+	admin.Role = "service_role"
+	require.NoError(ts.T(), ts.API.db.Create(admin), "Error creating user")
+
+	w = request(http.MethodPost, fmt.Sprintf("/admin/users/%s/impersonate", admin.ID), ts.token, map[string]interface{}{"reason": "Support ticket 123"})
+	require.Equal(ts.T(), http.StatusForbidden, w.Code)
+}
+
+func (ts *AdminTestSuite) TestAdminUserSessions() {
+	u, err := models.NewUser("", "test1@example.com", "test", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err, "Error making new user")
+	require.NoError(ts.T(), ts.API.db.Create(u), "Error creating user")
+
+	session, err := models.NewSession(u.ID, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(session))
+
+	request := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	sessionsPath := fmt.Sprintf("/admin/users/%s/sessions", u.ID)
+	w := request(http.MethodGet, sessionsPath)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var list struct {
+		Sessions []*SessionResponse `json:"sessions"`
+	}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&list))
+	require.Len(ts.T(), list.Sessions, 1)
+	require.Equal(ts.T(), session.ID, list.Sessions[0].ID)
+
+	require.Equal(ts.T(), http.StatusNotFound, request(http.MethodDelete, sessionsPath+"/"+uuid.Must(uuid.NewV4()).String()).Code)
+	require.Equal(ts.T(), http.StatusOK, request(http.MethodDelete, sessionsPath+"/"+session.ID.String()).Code)
+
+	entries, err := models.FindAuditLogEntries(ts.API.db, nil, "", models.AuditLogFilter{Actions: []string{string(models.SessionRevokedAction)}}, nil)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), entries, 1)
+
+	w = request(http.MethodGet, sessionsPath)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&list))
+	require.Empty(ts.T(), list.Sessions)
+}
+
+func (ts *AdminTestSuite) TestAdminCredentials() {
+	u, err := models.NewUser("", "test1@example.com", "test", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err, "Error making new user")
+	require.NoError(ts.T(), ts.API.db.Create(u), "Error creating user")
+
+	request := func(method, path, token string, body map[string]interface{}) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(body))
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, &buffer)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(ts.T(), http.StatusBadRequest, request(http.MethodPost, "/admin/credentials", ts.token, map[string]interface{}{
+		"name":   "Support",
+		"scopes": []string{"users:everything"},
+	}).Code)
+
+	w := request(http.MethodPost, "/admin/credentials", ts.token, map[string]interface{}{
+		"name":   "Support",
+		"scopes": []string{models.AdminScopeUsersRead, models.AdminScopeUsersWrite},
+	})
+	require.Equal(ts.T(), http.StatusCreated, w.Code, w.Body.String())
+
+	var credential AdminCredentialResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&credential))
+	require.NotEmpty(ts.T(), credential.Token)
+
+	userPath := fmt.Sprintf("/admin/users/%s", u.ID)
+	require.Equal(ts.T(), http.StatusOK, request(http.MethodGet, userPath, credential.Token, nil).Code)
+	require.Equal(ts.T(), http.StatusOK, request(http.MethodPut, userPath, credential.Token, map[string]interface{}{"user_metadata": map[string]interface{}{"plan": "pro"}}).Code)
+
+	// endpoints and changes outside of the scopes are forbidden
+	for _, w := range []*httptest.ResponseRecorder{
+		request(http.MethodPut, userPath, credential.Token, map[string]interface{}{"ban_duration": "24h"}),
+		request(http.MethodPut, userPath, credential.Token, map[string]interface{}{"role": "service_role"}),
+		request(http.MethodPut, userPath, credential.Token, map[string]interface{}{"app_metadata": map[string]interface{}{"plan": "pro"}}),
+		request(http.MethodPost, "/admin/users", credential.Token, map[string]interface{}{"email": "test2@example.com", "role": "service_role"}),
+		request(http.MethodGet, userPath+"/provider_token", credential.Token, nil),
+		request(http.MethodDelete, userPath, credential.Token, nil),
+		request(http.MethodGet, "/admin/sso/providers", credential.Token, nil),
+		request(http.MethodGet, "/admin/credentials", credential.Token, nil),
+	} {
+		require.Equal(ts.T(), http.StatusForbidden, w.Code, w.Body.String())
+	}
+
+	w = request(http.MethodGet, "/admin/credentials", ts.token, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var list AdminListCredentialsResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&list))
+	require.Len(ts.T(), list.Credentials, 1)
+	require.NotNil(ts.T(), list.Credentials[0].LastUsedAt)
+
+	require.Equal(ts.T(), http.StatusOK, request(http.MethodDelete, "/admin/credentials/"+credential.ID.String(), ts.token, nil).Code)
+	require.Equal(ts.T(), http.StatusForbidden, request(http.MethodGet, userPath, credential.Token, nil).Code)
+
+	// how users of the admin roles sign in can only be changed with the
+	// roles:manage scope, as signing in as them grants all the scopes
+	serviceUser, err := models.NewUser("", "service@example.com", "test", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err)
+	serviceUser.Role = "service_role"
+	require.NoError(ts.T(), ts.API.db.Create(serviceUser))
+
+	w = request(http.MethodPost, "/admin/credentials", ts.token, map[string]interface{}{
+		"name":   "Support",
+		"scopes": []string{models.AdminScopeUsersRead, models.AdminScopeUsersWrite, models.AdminScopeLinksGenerate},
+	})
+	require.Equal(ts.T(), http.StatusCreated, w.Code, w.Body.String())
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&credential))
+
+	servicePath := fmt.Sprintf("/admin/users/%s", serviceUser.ID)
+	for _, w := range []*httptest.ResponseRecorder{
+		request(http.MethodPut, servicePath, credential.Token, map[string]interface{}{"password": "new-password"}),
+		request(http.MethodPut, servicePath, credential.Token, map[string]interface{}{"email": "attacker@example.com"}),
+		request(http.MethodPut, servicePath, credential.Token, map[string]interface{}{"phone": "123456789"}),
+		request(http.MethodPost, "/admin/generate_link", credential.Token, map[string]interface{}{"type": "recovery", "email": "service@example.com"}),
+		request(http.MethodPost, "/admin/generate_link", credential.Token, map[string]interface{}{"type": "magiclink", "email": "service@example.com"}),
+	} {
+		require.Equal(ts.T(), http.StatusForbidden, w.Code, w.Body.String())
+	}
+
+	// but the ones of other users can
+	require.Equal(ts.T(), http.StatusOK, request(http.MethodPut, userPath, credential.Token, map[string]interface{}{"password": "new-password"}).Code)
+	require.Equal(ts.T(), http.StatusOK, request(http.MethodPost, "/admin/generate_link", credential.Token, map[string]interface{}{"type": "recovery", "email": "test1@example.com"}).Code)
+}
+
 // TestAdminUserDelete tests API /admin/users route (DELETE)
 func (ts *AdminTestSuite) TestAdminUserDelete() {
 	type expected struct {
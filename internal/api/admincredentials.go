@@ -0,0 +1,186 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+)
+
+type AdminCredentialParams struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// AdminCredentialResponse is a created admin credential with its token,
+// which is only returned once.
+type AdminCredentialResponse struct {
+	*models.AdminCredential
+
+	Token string `json:"token"`
+}
+
+type AdminListCredentialsResponse struct {
+	Credentials []*models.AdminCredential `json:"credentials"`
+}
+
+func (p *AdminCredentialParams) validate(ctx context.Context) error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return badRequestError(ErrorCodeValidationFailed, "name is required")
+	}
+	if utf8.RuneCountInString(p.Name) > 256 {
+		return badRequestError(ErrorCodeValidationFailed, "name must be at most 256 characters")
+	}
+
+	if len(p.Scopes) == 0 {
+		return badRequestError(ErrorCodeValidationFailed, "scopes are required")
+	}
+
+	for _, scope := range p.Scopes {
+		if !models.IsValidAdminScope(scope) {
+			return badRequestError(ErrorCodeValidationFailed, "Unknown scope %q, must be one of %s", scope, strings.Join(models.AdminScopes, ", "))
+		}
+
+		// credentials can't create credentials with more scopes than
+		// they have
+		if err := checkAdminScope(ctx, scope); err != nil {
+			return err
+		}
+	}
+
+	if p.ExpiresAt != nil && !p.ExpiresAt.After(time.Now()) {
+		return badRequestError(ErrorCodeValidationFailed, "expires_at must be in the future")
+	}
+
+	return nil
+}
+
+// findAdminCredential finds the admin credential with the credential_id in
+// the URL route. It isn't loaded into the context, which holds the
+// credential the request is made with.
+func findAdminCredential(r *http.Request, db *storage.Connection) (*models.AdminCredential, error) {
+	credentialID, err := uuid.FromString(chi.URLParam(r, "credential_id"))
+	if err != nil {
+		return nil, notFoundError(ErrorCodeValidationFailed, "credential_id must be an UUID")
+	}
+
+	observability.LogEntrySetField(r, "credential_id", credentialID)
+
+	credential, err := models.FindAdminCredentialByID(db, credentialID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, notFoundError(ErrorCodeAdminCredentialNotFound, "Admin credential not found")
+		}
+		return nil, internalServerError("Database error finding admin credential").WithInternalError(err)
+	}
+
+	return credential, nil
+}
+
+// adminCredentialsList lists the admin credentials, the most recently
+// created first, without their tokens.
+func (a *API) adminCredentialsList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	credentials, err := models.FindAdminCredentials(db)
+	if err != nil {
+		return internalServerError("Database error finding admin credentials").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, AdminListCredentialsResponse{
+		Credentials: credentials,
+	})
+}
+
+// adminCredentialsCreate creates an admin credential with the scopes, and
+// returns its token. Only the hash of the token is stored.
+func (a *API) adminCredentialsCreate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	adminUser := getAdminUser(ctx)
+
+	params := &AdminCredentialParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if err := params.validate(ctx); err != nil {
+		return err
+	}
+
+	token := crypto.SecureToken(32)
+
+	credential, err := models.NewAdminCredential(params.Name, token, params.Scopes, adminUser.Email.String(), params.ExpiresAt)
+	if err != nil {
+		return internalServerError("Error creating admin credential").WithInternalError(err)
+	}
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := tx.Create(credential); terr != nil {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, adminUser, models.AdminCredentialCreatedAction, "", map[string]interface{}{
+			"credential_id":   credential.ID,
+			"credential_name": credential.Name,
+			"scopes":          credential.Scopes,
+		})
+	}); err != nil {
+		return internalServerError("Database error creating admin credential").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusCreated, &AdminCredentialResponse{
+		AdminCredential: credential,
+		Token:           token,
+	})
+}
+
+func (a *API) adminCredentialGet(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	credential, err := findAdminCredential(r, db)
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, credential)
+}
+
+// adminCredentialDelete revokes the admin credential, whose token stops
+// working right away.
+func (a *API) adminCredentialDelete(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	credential, err := findAdminCredential(r, db)
+	if err != nil {
+		return err
+	}
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := tx.Destroy(credential); terr != nil {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, getAdminUser(ctx), models.AdminCredentialRevokedAction, "", map[string]interface{}{
+			"credential_id":   credential.ID,
+			"credential_name": credential.Name,
+		})
+	}); err != nil {
+		return internalServerError("Database error revoking admin credential").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
@@ -32,6 +32,7 @@ func (a *API) SignupAnonymously(w http.ResponseWriter, r *http.Request) error {
 
 	var grantParams models.GrantParams
 	grantParams.FillGrantParams(r)
+	grantParams.Provider = params.Provider
 
 	var token *AccessTokenResponse
 	err = db.Transaction(func(tx *storage.Connection) error {
@@ -209,6 +209,154 @@ func (ts *AnonymousTestSuite) TestConvertAnonymousUserToPermanent() {
 	}
 }
 
+func (ts *AnonymousTestSuite) TestMergeAnonymousUserClaimingExistingAccount() {
+	ts.Config.External.AnonymousUsers.Enabled = true
+	ts.Config.External.AnonymousUsers.MergeEnabled = true
+	ts.Config.External.AnonymousUsers.MergeMetadata = conf.AnonymousMergeAnonymousWins
+	ts.Config.Mailer.Autoconfirm = true
+	ts.Config.Sms.Autoconfirm = true
+	ts.Config.Sms.TestOTP = map[string]string{"1234567890": "000000"}
+	// test OTPs still require setting up an sms provider
+	ts.Config.Sms.Provider = "twilio"
+	ts.Config.Sms.Twilio.AccountSid = "fake-sid"
+	ts.Config.Sms.Twilio.AuthToken = "fake-token"
+	ts.Config.Sms.Twilio.MessageServiceSid = "fake-message-service-sid"
+	defer func() {
+		ts.Config.External.AnonymousUsers.MergeEnabled = false
+		ts.Config.Mailer.Autoconfirm = false
+		ts.Config.Sms.Autoconfirm = false
+	}()
+
+	existing, err := models.NewUser("1234567890", "existing@example.com", "", ts.Config.JWT.Aud, map[string]interface{}{"theme": "dark"})
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(existing))
+
+	cases := []struct {
+		desc             string
+		body             map[string]interface{}
+		verificationType string
+	}{
+		{
+			desc: "anonymous user claiming the email of an existing account",
+			body: map[string]interface{}{
+				"email": "existing@example.com",
+			},
+			verificationType: mail.EmailChangeVerification,
+		},
+		{
+			desc: "anonymous user claiming the phone of an existing account",
+			body: map[string]interface{}{
+				"phone": "1234567890",
+			},
+			verificationType: phoneChangeVerification,
+		},
+	}
+
+	for _, c := range cases {
+		ts.Run(c.desc, func() {
+			var buffer bytes.Buffer
+			require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+				"data": map[string]interface{}{
+					"theme": "light",
+					"cart":  []interface{}{"sku-1"},
+				},
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/signup", &buffer)
+			req.Header.Set("Content-Type", "application/json")
+
+			w := httptest.NewRecorder()
+			ts.API.handler.ServeHTTP(w, req)
+			require.Equal(ts.T(), http.StatusOK, w.Code)
+
+			signupResponse := &AccessTokenResponse{}
+			require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&signupResponse))
+
+			// app_metadata set by the admins for the anonymous user isn't merged
+			anonymous, err := models.FindUserByID(ts.API.db, signupResponse.User.ID)
+			require.NoError(ts.T(), err)
+			require.NoError(ts.T(), anonymous.UpdateAppMetaData(ts.API.db, map[string]interface{}{"plan": "pro"}))
+
+			require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(c.body))
+
+			req = httptest.NewRequest(http.MethodPut, "/user", &buffer)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", signupResponse.Token))
+
+			w = httptest.NewRecorder()
+			ts.API.handler.ServeHTTP(w, req)
+			require.Equal(ts.T(), http.StatusOK, w.Code)
+
+			// the claim must be confirmed, even with automatic confirmation
+			anonymous, err = models.FindUserByID(ts.API.db, signupResponse.User.ID)
+			require.NoError(ts.T(), err)
+			require.True(ts.T(), anonymous.IsAnonymous)
+
+			switch c.verificationType {
+			case mail.EmailChangeVerification:
+				require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+					"token_hash": anonymous.EmailChangeTokenNew,
+					"type":       c.verificationType,
+				}))
+			case phoneChangeVerification:
+				require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+					"phone": anonymous.PhoneChange,
+					"token": "000000",
+					"type":  c.verificationType,
+				}))
+			}
+
+			req = httptest.NewRequest(http.MethodPost, "/verify", &buffer)
+			req.Header.Set("Content-Type", "application/json")
+
+			w = httptest.NewRecorder()
+			ts.API.handler.ServeHTTP(w, req)
+			require.Equal(ts.T(), http.StatusOK, w.Code)
+
+			data := &AccessTokenResponse{}
+			require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+
+			// the anonymous user is signed in to the existing account
+			assert.Equal(ts.T(), existing.ID, data.User.ID)
+			assert.False(ts.T(), data.User.IsAnonymous)
+			assert.Equal(ts.T(), "light", data.User.UserMetaData["theme"])
+			assert.Equal(ts.T(), []interface{}{"sku-1"}, data.User.UserMetaData["cart"])
+			assert.NotContains(ts.T(), data.User.AppMetaData, "plan")
+
+			_, err = models.FindUserByID(ts.API.db, signupResponse.User.ID)
+			require.True(ts.T(), models.IsNotFoundError(err))
+		})
+	}
+
+	// without merging, the email of an existing account can't be claimed
+	ts.Config.External.AnonymousUsers.MergeEnabled = false
+
+	var buffer bytes.Buffer
+	require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{}))
+
+	req := httptest.NewRequest(http.MethodPost, "/signup", &buffer)
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	signupResponse := &AccessTokenResponse{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&signupResponse))
+
+	require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+		"email": "existing@example.com",
+	}))
+
+	req = httptest.NewRequest(http.MethodPut, "/user", &buffer)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", signupResponse.Token))
+
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+}
+
 func (ts *AnonymousTestSuite) TestRateLimitAnonymousSignups() {
 	var buffer bytes.Buffer
 	ts.Config.External.AnonymousUsers.Enabled = true
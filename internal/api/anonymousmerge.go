@@ -0,0 +1,123 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+
+	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// mergeAnonymousMetadata returns the updates merging the user_metadata of
+// an anonymous user into the user_metadata of the existing account with the
+// strategy, and the keys both have with different values.
+func mergeAnonymousMetadata(strategy string, existing, anonymous map[string]interface{}) (map[string]interface{}, []string) {
+	updates := make(map[string]interface{})
+	conflicts := []string{}
+
+	for key, value := range anonymous {
+		if value == nil {
+			continue
+		}
+
+		if current, ok := existing[key]; ok && !reflect.DeepEqual(current, value) {
+			conflicts = append(conflicts, key)
+
+			if strategy == conf.AnonymousMergeExistingWins {
+				continue
+			}
+		}
+
+		if strategy != conf.AnonymousMergeDiscard {
+			updates[key] = value
+		}
+	}
+
+	slices.Sort(conflicts)
+
+	return updates, conflicts
+}
+
+// claimedAccount returns the existing account of the email or phone an
+// anonymous user confirmed, which it's merged into, or nil when merging is
+// disabled or no other account has them.
+func (a *API) claimedAccount(tx *storage.Connection, user *models.User, email, phone string) (*models.User, error) {
+	if !user.IsAnonymous || !a.config.External.AnonymousUsers.MergeEnabled {
+		return nil, nil
+	}
+
+	if email != "" {
+		return a.findDuplicateEmail(tx, email, user.Aud, user)
+	}
+
+	account, err := models.FindUserByPhoneAndAudience(tx, phone, user.Aud)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if account.ID == user.ID {
+		return nil, nil
+	}
+
+	return account, nil
+}
+
+// mergeAnonymousUser merges an anonymous user into the existing account of
+// an identity it linked, or of an email or phone it confirmed, so what it
+// did before signing in, like filling a cart, isn't lost. The identities and
+// user_metadata of the anonymous user move to the account, and the anonymous
+// user is deleted. Its app_metadata is never merged, as it's managed by the
+// admins. The audit log entry of the merge has the ID of the anonymous user,
+// and the user_metadata keys that conflicted.
+func (a *API) mergeAnonymousUser(r *http.Request, tx *storage.Connection, anonymousUser *models.User, userID uuid.UUID) (*models.User, error) {
+	config := a.config
+
+	user, err := models.FindUserByID(tx, userID)
+	if err != nil {
+		return nil, internalServerError("Database error finding user").WithInternalError(err)
+	}
+
+	if user.IsBanned() {
+		return nil, forbiddenError(ErrorCodeUserBanned, "User is banned")
+	}
+
+	strategy := config.External.AnonymousUsers.MergeMetadata
+	userMetadata, userMetadataConflicts := mergeAnonymousMetadata(strategy, user.UserMetaData, anonymousUser.UserMetaData)
+
+	transferred, err := models.TransferIdentities(tx, anonymousUser.ID, user.ID)
+	if err != nil {
+		return nil, internalServerError("Database error transferring identities").WithInternalError(err)
+	}
+
+	if len(userMetadata) > 0 {
+		if err := user.UpdateUserMetaData(tx, userMetadata); err != nil {
+			return nil, internalServerError("Database error updating user").WithInternalError(err)
+		}
+	}
+
+	if transferred > 0 {
+		if err := user.UpdateAppMetaDataProviders(tx); err != nil {
+			return nil, internalServerError("Database error updating user").WithInternalError(err)
+		}
+	}
+
+	if err := models.NewAuditLogEntry(r, tx, user, models.AnonymousUserMergedAction, "", map[string]interface{}{
+		"anonymous_user_id":       anonymousUser.ID,
+		"identities":              transferred,
+		"merge_metadata":          strategy,
+		"user_metadata_conflicts": userMetadataConflicts,
+	}); err != nil {
+		return nil, internalServerError("Error recording audit log entry").WithInternalError(err)
+	}
+
+	if err := tx.Destroy(anonymousUser); err != nil {
+		return nil, internalServerError("Database error deleting anonymous user").WithInternalError(err)
+	}
+
+	return user, nil
+}
@@ -3,6 +3,8 @@ package api
 import (
 	"net/http"
 	"regexp"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/didip/tollbooth/v5"
@@ -10,10 +12,13 @@ import (
 	"github.com/rs/cors"
 	"github.com/sebest/xff"
 	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/api/provider"
 	"github.com/supabase/auth/internal/conf"
 	"github.com/supabase/auth/internal/mailer"
 	"github.com/supabase/auth/internal/models"
 	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/ratelimit"
+	"github.com/supabase/auth/internal/revocation"
 	"github.com/supabase/auth/internal/storage"
 	"github.com/supabase/auth/internal/utilities"
 	"github.com/supabase/hibp"
@@ -33,7 +38,45 @@ type API struct {
 	config  *conf.GlobalConfiguration
 	version string
 
-	hibpClient *hibp.PwnedClient
+	// hibpClient checks passwords against the Pwned Passwords list, with
+	// the HaveIBeenPwned.org API or its local copies
+	hibpClient pwnedPasswordsChecker
+
+	// ldapAuthenticator verifies passwords against the directory when the
+	// LDAP backend of the password grant is enabled
+	ldapAuthenticator provider.LDAPAuthenticator
+
+	// samlReplayCache rejects SAML assertions that were already accepted
+	samlReplayCache samlReplayCache
+
+	// rateLimitStore counts the rate limits across the instances, when
+	// they aren't counted in memory
+	rateLimitStore ratelimit.Store
+
+	// endpointLimits are the rate limits of the groups of endpoints once
+	// the configuration file was reloaded
+	endpointLimits atomic.Pointer[conf.EndpointRateLimitsConfiguration]
+
+	// endpointLimiters are the rate limiters of the groups of endpoints
+	// with a configured rate limit
+	endpointLimitersMutex sync.Mutex
+	endpointLimiters      map[string]*endpointLimiter
+
+	// recipientLimiter limits the messages sent to each email address and
+	// phone number
+	recipientLimiter *recipientLimiter
+
+	// geoIP locates the IP addresses of sessions when a GeoIP database is
+	// configured
+	geoIP geoIPLocator
+
+	// revocations denies the access tokens of the sessions and users
+	// revoked on any instance, when revocations are propagated
+	revocations *revocation.Propagator
+
+	// blockedDomains caches the email domains loaded from the blocked
+	// domains source
+	blockedDomains *blockedDomains
 
 	// overrideTime can be used to override the clock used by handlers. Should only be used in tests!
 	overrideTime func() time.Time
@@ -70,39 +113,81 @@ func (a *API) deprecationNotices() {
 func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Connection, version string) *API {
 	api := &API{config: globalConfig, db: db, version: version}
 
-	if api.config.Password.HIBP.Enabled {
+	rateLimitStore, err := ratelimit.NewStore(&globalConfig.RateLimitStore)
+	if err != nil {
+		// the limits are counted in memory instead
+		logrus.WithError(err).Error("Unable to count rate limits in the store")
+	}
+	api.rateLimitStore = rateLimitStore
+
+	api.recipientLimiter = newRecipientLimiter(globalConfig, rateLimitStore)
+	api.blockedDomains = newBlockedDomains()
+
+	if api.config.Password.HIBP.Enabled && api.config.Password.HIBP.Offline.Enabled {
+		api.hibpClient = &utilities.HIBPOfflineChecker{
+			BloomFilter: api.config.Password.HIBP.Offline.BloomFilter,
+			RangesDir:   api.config.Password.HIBP.Offline.RangesDir,
+		}
+	} else if api.config.Password.HIBP.Enabled {
 		httpClient := &http.Client{
 			// all HIBP API requests should finish quickly to avoid
 			// unnecessary slowdowns
 			Timeout: 5 * time.Second,
 		}
 
-		api.hibpClient = &hibp.PwnedClient{
+		hibpClient := &hibp.PwnedClient{
 			UserAgent: api.config.Password.HIBP.UserAgent,
 			HTTP:      httpClient,
 		}
+		api.hibpClient = hibpClient
 
 		if api.config.Password.HIBP.Bloom.Enabled {
 			cache := utilities.NewHIBPBloomCache(api.config.Password.HIBP.Bloom.Items, api.config.Password.HIBP.Bloom.FalsePositives)
-			api.hibpClient.Cache = cache
+			hibpClient.Cache = cache
 
 			logrus.Infof("Pwned passwords cache is %.2f KB", float64(cache.Cap())/(8*1024.0))
 		}
 	}
 
+	if api.config.GeoIP.Enabled {
+		api.geoIP = &utilities.GeoIPCSVDatabase{
+			Path: api.config.GeoIP.Database,
+		}
+	}
+
+	if api.config.Revocations.Enabled {
+		// revocations are kept for as long as the access tokens they deny
+		propagator, err := revocation.NewPropagator(&api.config.Revocations, time.Duration(api.config.JWT.Exp)*time.Second)
+		if err != nil {
+			logrus.WithError(err).Error("Unable to propagate revocations")
+		}
+		api.revocations = propagator
+	}
+
+	if api.config.External.LDAP.Enabled {
+		api.ldapAuthenticator = provider.NewLDAPAuthenticator(api.config.External.LDAP)
+	}
+
+	if api.config.SAML.Enabled {
+		api.samlReplayCache = newSAMLReplayCache(&api.config.SAML, db)
+	}
+
 	api.deprecationNotices()
 
-	xffmw, _ := xff.Default()
+	// the trusted proxies are validated when the configuration is loaded
+	xffmw, _ := xff.New(xff.Options{AllowedSubnets: globalConfig.API.TrustedProxies})
 	logger := observability.NewStructuredLogger(logrus.StandardLogger(), globalConfig)
 
 	r := newRouter()
 	r.UseBypass(observability.AddRequestID(globalConfig))
 	r.UseBypass(logger)
+	r.UseBypass(recordSocketAddr)
 	r.UseBypass(xffmw.Handler)
 	r.UseBypass(recoverer)
 
 	if globalConfig.API.MaxRequestDuration > 0 {
-		r.UseBypass(timeoutMiddleware(globalConfig.API.MaxRequestDuration))
+		// exports of all the users are streamed
+		r.UseBypass(timeoutMiddleware(globalConfig.API.MaxRequestDuration, "/admin/users/export"))
 	}
 
 	// request tracing should be added only when tracing or metrics is enabled
@@ -115,6 +200,8 @@ func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Conne
 		r.UseBypass(api.databaseCleanup(cleanup))
 	}
 
+	r.Use(api.ipFilterHandler("global"))
+
 	r.Get("/health", api.HealthCheck)
 	r.Get("/.well-known/jwks.json", api.Jwks)
 
@@ -134,34 +221,36 @@ func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Conne
 		r.Get("/authorize", api.ExternalProviderRedirect)
 
 		sharedLimiter := api.limitEmailOrPhoneSentHandler()
-		r.With(sharedLimiter).With(api.requireAdminCredentials).Post("/invite", api.Invite)
-		r.With(sharedLimiter).With(api.verifyCaptcha).Route("/signup", func(r *router) {
+		r.With(sharedLimiter).With(api.requireAdminCredentials).With(api.requireAdminScope(models.AdminScopeUsersWrite)).Post("/invite", api.Invite)
+		r.With(api.ipFilterHandler("signup")).With(sharedLimiter).With(api.verifyCaptcha).Route("/signup", func(r *router) {
 			// rate limit per hour
 			limitAnonymousSignIns := tollbooth.NewLimiter(api.config.RateLimitAnonymousUsers/(60*60), &limiter.ExpirableOptions{
 				DefaultExpirationTTL: time.Hour,
 			}).SetBurst(int(api.config.RateLimitAnonymousUsers)).SetMethods([]string{"POST"})
 
-			limitSignups := tollbooth.NewLimiter(api.config.RateLimitOtp/(60*5), &limiter.ExpirableOptions{
-				DefaultExpirationTTL: time.Hour,
-			}).SetBurst(30)
+			limitSignups := api.endpointLimitHandler("signup", api.limitHandler("signup",
+				tollbooth.NewLimiter(api.config.RateLimitOtp/(60*5), &limiter.ExpirableOptions{
+					DefaultExpirationTTL: time.Hour,
+				}).SetBurst(30),
+			))
 
 			r.Post("/", func(w http.ResponseWriter, r *http.Request) error {
 				params := &SignupParams{}
 				if err := retrieveRequestParams(r, params); err != nil {
 					return err
 				}
-				if params.Email == "" && params.Phone == "" {
+				if params.Email == "" && params.Phone == "" && params.Username == "" {
 					if !api.config.External.AnonymousUsers.Enabled {
 						return unprocessableEntityError(ErrorCodeAnonymousProviderDisabled, "Anonymous sign-ins are disabled")
 					}
-					if _, err := api.limitHandler(limitAnonymousSignIns)(w, r); err != nil {
+					if _, err := api.limitHandler("anonymous_sign_ins", limitAnonymousSignIns)(w, r); err != nil {
 						return err
 					}
 					return api.SignupAnonymously(w, r)
 				}
 
 				// apply ip-based rate limiting on otps
-				if _, err := api.limitHandler(limitSignups)(w, r); err != nil {
+				if _, err := limitSignups(w, r); err != nil {
 					return err
 				}
 				// apply shared rate limiting on email / phone
@@ -171,84 +260,205 @@ func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Conne
 				return api.Signup(w, r)
 			})
 		})
-		r.With(api.limitHandler(
+		r.With(api.ipFilterHandler("otp")).With(api.endpointLimitHandler("otp", api.limitHandler("recover",
 			// Allow requests at the specified rate per 5 minutes
 			tollbooth.NewLimiter(api.config.RateLimitOtp/(60*5), &limiter.ExpirableOptions{
 				DefaultExpirationTTL: time.Hour,
 			}).SetBurst(30),
-		)).With(sharedLimiter).With(api.verifyCaptcha).With(api.requireEmailProvider).Post("/recover", api.Recover)
+		))).With(sharedLimiter).With(api.verifyCaptcha).With(api.requireEmailProvider).Post("/recover", api.Recover)
 
-		r.With(api.limitHandler(
+		r.With(api.ipFilterHandler("otp")).With(api.endpointLimitHandler("otp", api.limitHandler("resend",
 			// Allow requests at the specified rate per 5 minutes
 			tollbooth.NewLimiter(api.config.RateLimitOtp/(60*5), &limiter.ExpirableOptions{
 				DefaultExpirationTTL: time.Hour,
 			}).SetBurst(30),
-		)).With(sharedLimiter).With(api.verifyCaptcha).Post("/resend", api.Resend)
+		))).With(sharedLimiter).With(api.verifyCaptcha).Post("/resend", api.Resend)
 
-		r.With(api.limitHandler(
+		r.With(api.ipFilterHandler("otp")).With(api.endpointLimitHandler("otp", api.limitHandler("magiclink",
 			// Allow requests at the specified rate per 5 minutes
 			tollbooth.NewLimiter(api.config.RateLimitOtp/(60*5), &limiter.ExpirableOptions{
 				DefaultExpirationTTL: time.Hour,
 			}).SetBurst(30),
-		)).With(sharedLimiter).With(api.verifyCaptcha).Post("/magiclink", api.MagicLink)
+		))).With(sharedLimiter).With(api.verifyCaptcha).Post("/magiclink", api.MagicLink)
 
-		r.With(api.limitHandler(
+		r.With(api.ipFilterHandler("otp")).With(api.endpointLimitHandler("otp", api.limitHandler("otp",
 			// Allow requests at the specified rate per 5 minutes
 			tollbooth.NewLimiter(api.config.RateLimitOtp/(60*5), &limiter.ExpirableOptions{
 				DefaultExpirationTTL: time.Hour,
 			}).SetBurst(30),
-		)).With(sharedLimiter).With(api.verifyCaptcha).Post("/otp", api.Otp)
+		))).With(sharedLimiter).With(api.verifyCaptcha).Post("/otp", api.Otp)
 
-		r.With(api.limitHandler(
+		r.With(api.ipFilterHandler("token")).With(api.endpointLimitHandler("token", api.limitHandler("token",
 			// Allow requests at the specified rate per 5 minutes.
 			tollbooth.NewLimiter(api.config.RateLimitTokenRefresh/(60*5), &limiter.ExpirableOptions{
 				DefaultExpirationTTL: time.Hour,
 			}).SetBurst(30),
-		)).With(api.verifyCaptcha).Post("/token", api.Token)
+		))).With(api.verifyCaptcha).Post("/token", api.Token)
 
-		r.With(api.limitHandler(
+		r.With(api.limitHandler("web3_nonce",
+			// Allow requests at the specified rate per 5 minutes.
+			tollbooth.NewLimiter(api.config.RateLimitWeb3/(60*5), &limiter.ExpirableOptions{
+				DefaultExpirationTTL: time.Hour,
+			}).SetBurst(30),
+		)).Post("/web3/nonce", api.Web3Nonce)
+
+		r.With(api.ipFilterHandler("verify")).With(api.endpointLimitHandler("verify", api.limitHandler("verify",
 			// Allow requests at the specified rate per 5 minutes.
 			tollbooth.NewLimiter(api.config.RateLimitVerify/(60*5), &limiter.ExpirableOptions{
 				DefaultExpirationTTL: time.Hour,
 			}).SetBurst(30),
-		)).Route("/verify", func(r *router) {
+		))).Route("/verify", func(r *router) {
 			r.Get("/", api.Verify)
 			r.Post("/", api.Verify)
 		})
 
+		r.With(api.ipFilterHandler("verify")).With(api.endpointLimitHandler("verify", api.limitHandler("email_change_undo",
+			// Allow requests at the specified rate per 5 minutes.
+			tollbooth.NewLimiter(api.config.RateLimitVerify/(60*5), &limiter.ExpirableOptions{
+				DefaultExpirationTTL: time.Hour,
+			}).SetBurst(30),
+		))).Get("/email_change/undo", api.UndoEmailChange)
+
+		r.With(api.ipFilterHandler("verify")).With(api.endpointLimitHandler("verify", api.limitHandler("phone_change_undo",
+			// Allow requests at the specified rate per 5 minutes.
+			tollbooth.NewLimiter(api.config.RateLimitVerify/(60*5), &limiter.ExpirableOptions{
+				DefaultExpirationTTL: time.Hour,
+			}).SetBurst(30),
+		))).Get("/phone_change/undo", api.UndoPhoneChange)
+
+		r.With(api.ipFilterHandler("verify")).With(api.endpointLimitHandler("verify", api.limitHandler("account_deletion_cancel",
+			// Allow requests at the specified rate per 5 minutes.
+			tollbooth.NewLimiter(api.config.RateLimitVerify/(60*5), &limiter.ExpirableOptions{
+				DefaultExpirationTTL: time.Hour,
+			}).SetBurst(30),
+		))).With(api.requireAccountDeletionEnabled).Get("/account_deletion/cancel", api.CancelAccountDeletion)
+
+		r.With(api.ipFilterHandler("verify")).With(api.endpointLimitHandler("verify", api.limitHandler("account_lockout_unlock",
+			// Allow requests at the specified rate per 5 minutes.
+			tollbooth.NewLimiter(api.config.RateLimitVerify/(60*5), &limiter.ExpirableOptions{
+				DefaultExpirationTTL: time.Hour,
+			}).SetBurst(30),
+		))).With(api.requireAccountLockoutEnabled).Get("/account_lockout/unlock", api.UnlockAccount)
+
+		r.With(api.limitHandler("short_links",
+			// Allow requests at the specified rate per 5 minutes.
+			tollbooth.NewLimiter(api.config.RateLimitVerify/(60*5), &limiter.ExpirableOptions{
+				DefaultExpirationTTL: time.Hour,
+			}).SetBurst(30),
+		)).With(api.requireShortLinksEnabled).Get("/s/{slug}", api.RedirectShortLink)
+
+		r.With(api.limitHandler("username_availability",
+			// Allow requests at the specified rate per 5 minutes.
+			tollbooth.NewLimiter(api.config.RateLimitVerify/(60*5), &limiter.ExpirableOptions{
+				DefaultExpirationTTL: time.Hour,
+			}).SetBurst(30),
+		)).With(api.requireUsernameEnabled).Get("/username/availability", api.UsernameAvailability)
+
+		r.With(api.requireEmailSuppressionEnabled).Post("/webhooks/email/{provider}", api.EmailSuppressionWebhook)
+		r.With(api.requireDeliveryTrackingEnabled).Post("/webhooks/delivery/{provider}", api.DeliveryWebhook)
+
 		r.With(api.requireAuthentication).Post("/logout", api.Logout)
 
 		r.With(api.requireAuthentication).Route("/reauthenticate", func(r *router) {
+			r.Use(api.requireNotImpersonated)
 			r.Get("/", api.Reauthenticate)
 		})
 
 		r.With(api.requireAuthentication).Route("/user", func(r *router) {
 			r.Get("/", api.UserGet)
-			r.With(api.limitHandler(
+			r.With(api.limitHandler("user_update",
 				// Allow requests at the specified rate per 5 minutes
 				tollbooth.NewLimiter(api.config.RateLimitOtp/(60*5), &limiter.ExpirableOptions{
 					DefaultExpirationTTL: time.Hour,
 				}).SetBurst(30),
-			)).With(sharedLimiter).Put("/", api.UserUpdate)
+			)).With(sharedLimiter).With(api.requireNotImpersonated).Put("/", api.UserUpdate)
+			r.With(api.requireAccountDeletionEnabled).With(api.requireNotImpersonated).With(api.requireNotAnonymous).Delete("/", api.UserDelete)
+
+			r.With(api.requireNotImpersonated).Get("/provider_token", api.ProviderTokenGet)
+			r.With(api.requireConsentEnabled).With(api.requireNotImpersonated).Post("/consent", api.UserConsentAccept)
 
 			r.Route("/identities", func(r *router) {
-				r.Use(api.requireManualLinkingEnabled)
-				r.Get("/authorize", api.LinkIdentity)
-				r.Delete("/{identity_id}", api.DeleteIdentity)
+				r.Use(api.requireNotImpersonated)
+				r.With(api.requireManualLinkingEnabled).Get("/authorize", api.LinkIdentity)
+				r.With(api.requireManualLinkingEnabled).Delete("/{identity_id}", api.DeleteIdentity)
+				r.Post("/{identity_id}/sync", api.IdentitySync)
+			})
+
+			r.Route("/sessions", func(r *router) {
+				r.Get("/", api.UserSessionsList)
+				r.With(api.requireNotImpersonated).Delete("/{session_id}", api.UserSessionRevoke)
+			})
+
+			r.With(api.requireAccountDeletionEnabled).Route("/deletion", func(r *router) {
+				r.Get("/", api.UserDeletionGet)
+				r.With(api.requireNotImpersonated).Post("/cancel", api.UserDeletionCancel)
+			})
+
+			r.With(api.requireDataExportEnabled).With(api.requireNotImpersonated).Route("/data_exports", func(r *router) {
+				r.Get("/", api.DataExportList)
+				r.Post("/", api.DataExportCreate)
+				r.Get("/{export_id}", api.DataExportGet)
+				r.Get("/{export_id}/download", api.DataExportDownload)
+			})
+
+			r.With(api.requireSecondaryEmailsEnabled).With(api.requireNotImpersonated).Route("/emails", func(r *router) {
+				r.Get("/", api.UserEmailList)
+				r.With(sharedLimiter).Post("/", api.UserEmailAdd)
+				r.With(api.endpointLimitHandler("verify", api.limitHandler("user_email_verify",
+					// Allow requests at the specified rate per 5 minutes.
+					tollbooth.NewLimiter(api.config.RateLimitVerify/(60*5), &limiter.ExpirableOptions{
+						DefaultExpirationTTL: time.Hour,
+					}).SetBurst(30),
+				))).Post("/verify", api.UserEmailVerify)
+				r.Post("/{email_id}/primary", api.UserEmailSetPrimary)
+				r.Delete("/{email_id}", api.UserEmailRemove)
+			})
+
+			r.With(api.requireSignInHistoryEnabled).Get("/sign-in-activity", api.UserSignInActivity)
+		})
+
+		r.With(api.requireAuthentication).Route("/organizations", func(r *router) {
+			r.Use(api.requireOrganizationsEnabled)
+			r.Use(api.requireNotAnonymous)
+
+			r.Get("/", api.UserOrganizationsList)
+			r.With(api.requireNotImpersonated).Post("/", api.UserOrganizationsCreate)
+
+			r.Route("/invitations", func(r *router) {
+				r.Get("/", api.UserOrganizationInvitationsPending)
+				r.With(api.requireNotImpersonated).Post("/{invitation_id}/accept", api.UserOrganizationInvitationAccept)
+				r.With(api.requireNotImpersonated).Delete("/{invitation_id}", api.UserOrganizationInvitationDecline)
+			})
+
+			r.Route("/{organization_id}", func(r *router) {
+				r.Use(api.loadOrganizationMembership)
+
+				r.Get("/", api.UserOrganizationGet)
+				r.With(api.requireNotImpersonated).Put("/", api.UserOrganizationUpdate)
+				r.With(api.requireNotImpersonated).Delete("/", api.UserOrganizationDelete)
+
+				r.Get("/members", api.UserOrganizationMembersList)
+				r.With(api.requireNotImpersonated).Put("/members/{user_id}", api.UserOrganizationMemberUpdate)
+				r.With(api.requireNotImpersonated).Delete("/members/{user_id}", api.UserOrganizationMemberDelete)
+
+				r.Get("/invitations", api.UserOrganizationInvitationsList)
+				r.With(api.requireNotImpersonated).Post("/invitations", api.UserOrganizationInvitationsCreate)
+				r.With(api.requireNotImpersonated).Delete("/invitations/{invitation_id}", api.UserOrganizationInvitationDelete)
 			})
 		})
 
 		r.With(api.requireAuthentication).Route("/factors", func(r *router) {
 			r.Use(api.requireNotAnonymous)
+			r.Use(api.requireNotImpersonated)
 			r.Post("/", api.EnrollFactor)
 			r.Route("/{factor_id}", func(r *router) {
 				r.Use(api.loadFactor)
 
-				r.With(api.limitHandler(
+				r.With(api.limitHandler("mfa_verify",
 					tollbooth.NewLimiter(api.config.MFA.RateLimitChallengeAndVerify/60, &limiter.ExpirableOptions{
 						DefaultExpirationTTL: time.Minute,
 					}).SetBurst(30))).Post("/verify", api.VerifyFactor)
-				r.With(api.limitHandler(
+				r.With(api.limitHandler("mfa_challenge",
 					tollbooth.NewLimiter(api.config.MFA.RateLimitChallengeAndVerify/60, &limiter.ExpirableOptions{
 						DefaultExpirationTTL: time.Minute,
 					}).SetBurst(30))).Post("/challenge", api.ChallengeFactor)
@@ -258,8 +468,8 @@ func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Conne
 		})
 
 		r.Route("/sso", func(r *router) {
-			r.Use(api.requireSAMLEnabled)
-			r.With(api.limitHandler(
+			r.Use(api.requireSSOEnabled)
+			r.With(api.limitHandler("sso",
 				// Allow requests at the specified rate per 5 minutes.
 				tollbooth.NewLimiter(api.config.RateLimitSso/(60*5), &limiter.ExpirableOptions{
 					DefaultExpirationTTL: time.Hour,
@@ -267,27 +477,119 @@ func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Conne
 			)).With(api.verifyCaptcha).Post("/", api.SingleSignOn)
 
 			r.Route("/saml", func(r *router) {
+				r.Use(api.requireSAMLEnabled)
+
 				r.Get("/metadata", api.SAMLMetadata)
+				r.With(api.requireAuthentication).Post("/logout", api.SAMLLogout)
 
-				r.With(api.limitHandler(
+				assertionLimiter := api.limitHandler("saml_assertion",
 					// Allow requests at the specified rate per 5 minutes.
 					tollbooth.NewLimiter(api.config.SAML.RateLimitAssertion/(60*5), &limiter.ExpirableOptions{
 						DefaultExpirationTTL: time.Hour,
 					}).SetBurst(30),
-				)).Post("/acs", api.SamlAcs)
+				)
+
+				r.With(assertionLimiter).Post("/acs", api.SamlAcs)
+				// the HTTP-Artifact binding redirects with the artifact
+				r.With(assertionLimiter).Get("/acs", api.SamlAcs)
+				r.With(assertionLimiter).Get("/slo", api.SAMLSingleLogout)
+				r.With(assertionLimiter).Post("/slo", api.SAMLSingleLogout)
+			})
+
+			r.Route("/oidc", func(r *router) {
+				r.Use(api.requireSSOOIDCEnabled)
+
+				r.With(api.limitHandler("sso_oidc_callback",
+					// Allow requests at the specified rate per 5 minutes.
+					tollbooth.NewLimiter(api.config.RateLimitSso/(60*5), &limiter.ExpirableOptions{
+						DefaultExpirationTTL: time.Hour,
+					}).SetBurst(30),
+				)).Get("/callback", api.SSOOIDCCallback)
+			})
+		})
+
+		r.Route("/scim/v2", func(r *router) {
+			r.UseBypass(scimMiddleware(api.requireSCIMAuthentication))
+
+			r.Get("/ServiceProviderConfig", scimHandler(api.SCIMServiceProviderConfig))
+			r.Get("/ResourceTypes", scimHandler(api.SCIMResourceTypes))
+
+			r.Route("/Users", func(r *router) {
+				r.Get("/", scimHandler(api.SCIMUsersList))
+				r.Post("/", scimHandler(api.SCIMUsersCreate))
+
+				r.Route("/{user_id}", func(r *router) {
+					r.Get("/", scimHandler(api.SCIMUsersGet))
+					r.Put("/", scimHandler(api.SCIMUsersReplace))
+					r.Patch("/", scimHandler(api.SCIMUsersPatch))
+					r.Delete("/", scimHandler(api.SCIMUsersDelete))
+				})
 			})
+
+			r.Route("/Groups", func(r *router) {
+				r.Get("/", scimHandler(api.SCIMGroupsList))
+				r.Post("/", scimHandler(api.SCIMGroupsCreate))
+
+				r.Route("/{group_id}", func(r *router) {
+					r.Get("/", scimHandler(api.SCIMGroupsGet))
+					r.Put("/", scimHandler(api.SCIMGroupsReplace))
+					r.Patch("/", scimHandler(api.SCIMGroupsPatch))
+					r.Delete("/", scimHandler(api.SCIMGroupsDelete))
+				})
+			})
+		})
+
+		r.Route("/kerberos", func(r *router) {
+			r.Use(api.requireKerberosEnabled)
+			r.With(api.limitHandler("kerberos",
+				// Allow requests at the specified rate per 5 minutes.
+				tollbooth.NewLimiter(api.config.RateLimitSso/(60*5), &limiter.ExpirableOptions{
+					DefaultExpirationTTL: time.Hour,
+				}).SetBurst(30),
+			)).Get("/", api.KerberosSSO)
 		})
 
 		r.Route("/admin", func(r *router) {
+			r.Use(api.ipFilterHandler("admin"))
 			r.Use(api.requireAdminCredentials)
+			r.Use(api.endpointLimitHandler("admin", nil))
 
 			r.Route("/audit", func(r *router) {
+				r.Use(api.requireAdminScope(models.AdminScopeAuditRead))
+
 				r.Get("/", api.adminAuditLog)
 			})
 
+			r.With(api.requireBulkInviteEnabled).Route("/invite/bulk", func(r *router) {
+				r.Use(api.requireAdminScope(models.AdminScopeUsersWrite))
+
+				r.Post("/", api.adminBulkInvite)
+				r.Get("/{batch_id}", api.adminBulkInviteGet)
+			})
+
+			r.Route("/credentials", func(r *router) {
+				r.Use(api.requireAdminScope(models.AdminScopeCredentials))
+
+				r.Get("/", api.adminCredentialsList)
+				r.Post("/", api.adminCredentialsCreate)
+				r.Get("/{credential_id}", api.adminCredentialGet)
+				r.Delete("/{credential_id}", api.adminCredentialDelete)
+			})
+
 			r.Route("/users", func(r *router) {
+				r.Use(api.requireAdminScopes(models.AdminScopeUsersRead, models.AdminScopeUsersWrite))
+
 				r.Get("/", api.adminUsers)
 				r.Post("/", api.adminUserCreate)
+				r.With(api.requireAdminScope(models.AdminScopeUsersExport)).Get("/export", api.adminUsersExport)
+				r.Post("/expire_passwords", api.adminUsersExpirePasswords)
+
+				r.Route("/import", func(r *router) {
+					r.Use(api.requireUserImportEnabled)
+
+					r.Post("/", api.adminUsersImport)
+					r.Get("/{job_id}", api.adminUserImportJobGet)
+				})
 
 				r.Route("/{user_id}", func(r *router) {
 					r.Use(api.loadUser)
@@ -302,13 +604,127 @@ func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Conne
 
 					r.Get("/", api.adminUserGet)
 					r.Put("/", api.adminUserUpdate)
-					r.Delete("/", api.adminUserDelete)
+					r.With(api.requireAdminScope(models.AdminScopeUsersDelete)).Delete("/", api.adminUserDelete)
+					r.With(api.requireUserDeletionEnabled).Post("/undelete", api.adminUserUndelete)
+					r.Get("/bans", api.adminUserBans)
+					r.Route("/notes", func(r *router) {
+						r.Get("/", api.adminUserNotesList)
+						r.Post("/", api.adminUserNoteCreate)
+						r.Delete("/{note_id}", api.adminUserNoteDelete)
+					})
+					r.Route("/tags", func(r *router) {
+						r.Get("/", api.adminUserTagsList)
+						r.Put("/{name}", api.adminUserTagSet)
+						r.Delete("/{name}", api.adminUserTagRemove)
+					})
+					r.With(api.requireSignInHistoryEnabled).Get("/sign-in-activity", api.adminUserSignInActivity)
+					r.With(api.requireAccountLockoutEnabled).Route("/lockout", func(r *router) {
+						r.Get("/", api.adminUserLockoutGet)
+						r.Delete("/", api.adminUserUnlock)
+					})
+					r.With(api.requireImpersonationEnabled).With(api.requireAdminScope(models.AdminScopeUsersImpersonate)).Route("/impersonate", func(r *router) {
+						r.Post("/", api.adminUserImpersonate)
+						r.Delete("/", api.adminUserImpersonationsRevoke)
+					})
+					r.Route("/sessions", func(r *router) {
+						r.Get("/", api.adminUserSessionsList)
+						r.Delete("/{session_id}", api.adminUserSessionRevoke)
+					})
+					r.With(api.requireRolesEnabled).With(api.requireAdminScope(models.AdminScopeRolesManage)).Route("/roles", func(r *router) {
+						r.Get("/", api.adminUserRolesList)
+						r.Post("/", api.adminUserRoleAssign)
+						r.With(api.loadRole).Delete("/{role_id}", api.adminUserRoleRemove)
+					})
+					r.With(api.requireAdminScope(models.AdminScopeProviderTokens)).Get("/provider_token", api.ProviderTokenGet)
+					r.With(api.requireAdminScope(models.AdminScopeIdentitiesSync)).Post("/identities/{identity_id}/sync", api.IdentitySync)
+					r.With(api.requireDataExportEnabled).With(api.requireAdminScope(models.AdminScopeUsersExport)).Route("/data_exports", func(r *router) {
+						r.Get("/", api.DataExportList)
+						r.Post("/", api.DataExportCreate)
+						r.Get("/{export_id}", api.DataExportGet)
+						r.Get("/{export_id}/download", api.DataExportDownload)
+					})
+				})
+			})
+
+			r.With(api.requireAdminScope(models.AdminScopeLinksGenerate)).Post("/generate_link", api.adminGenerateLink)
+
+			r.Route("/hooks/dead_letters", func(r *router) {
+				r.Use(api.requireAdminScope(models.AdminScopeHooksManage))
+
+				r.Get("/", api.adminHookDeadLettersList)
+				r.Get("/{dead_letter_id}", api.adminHookDeadLetterGet)
+				r.Delete("/{dead_letter_id}", api.adminHookDeadLetterDelete)
+			})
+
+			r.Route("/outbox", func(r *router) {
+				r.Use(api.requireAdminScope(models.AdminScopeEmailManage))
+
+				r.Get("/", api.adminOutboxMessagesList)
+				r.Get("/{message_id}", api.adminOutboxMessageGet)
+				r.Post("/{message_id}/retry", api.adminOutboxMessageRetry)
+			})
+
+			r.Route("/email_suppressions", func(r *router) {
+				r.Use(api.requireEmailSuppressionEnabled)
+				r.Use(api.requireAdminScope(models.AdminScopeEmailManage))
+
+				r.Get("/", api.adminEmailSuppressionsList)
+				r.Post("/", api.adminEmailSuppressionCreate)
+				r.Delete("/{suppression_id}", api.adminEmailSuppressionDelete)
+			})
+
+			r.With(api.requireDeliveryTrackingEnabled).With(api.requireAdminScope(models.AdminScopeEmailManage)).Get("/deliveries", api.adminMessageDeliveriesList)
+
+			r.Route("/templates/{template_type}", func(r *router) {
+				r.Use(api.requireAdminScope(models.AdminScopeEmailManage))
+
+				r.Post("/preview", api.adminEmailTemplatePreview)
+				r.Post("/send-test", api.adminEmailTemplateSendTest)
+			})
+
+			r.Route("/organizations", func(r *router) {
+				r.Use(api.requireOrganizationsEnabled)
+				r.Use(api.requireAdminScope(models.AdminScopeOrganizations))
+
+				r.Get("/", api.adminOrganizationsList)
+				r.Post("/", api.adminOrganizationsCreate)
+
+				r.Route("/{organization_id}", func(r *router) {
+					r.Use(api.loadOrganization)
+
+					r.Get("/", api.adminOrganizationGet)
+					r.Put("/", api.adminOrganizationUpdate)
+					r.Delete("/", api.adminOrganizationDelete)
+
+					r.Get("/members", api.adminOrganizationMembersList)
+					r.Put("/members/{user_id}", api.adminOrganizationMemberUpdate)
+					r.Delete("/members/{user_id}", api.adminOrganizationMemberDelete)
+
+					r.Get("/invitations", api.adminOrganizationInvitationsList)
+					r.Post("/invitations", api.adminOrganizationInvitationsCreate)
+					r.Delete("/invitations/{invitation_id}", api.adminOrganizationInvitationDelete)
 				})
 			})
 
-			r.Post("/generate_link", api.adminGenerateLink)
+			r.Route("/roles", func(r *router) {
+				r.Use(api.requireRolesEnabled)
+				r.Use(api.requireAdminScope(models.AdminScopeRolesManage))
+
+				r.Get("/", api.adminRolesList)
+				r.Post("/", api.adminRolesCreate)
+
+				r.Route("/{role_id}", func(r *router) {
+					r.Use(api.loadRole)
+
+					r.Get("/", api.adminRoleGet)
+					r.Put("/", api.adminRoleUpdate)
+					r.Delete("/", api.adminRoleDelete)
+				})
+			})
 
 			r.Route("/sso", func(r *router) {
+				r.Use(api.requireAdminScopes(models.AdminScopeSSORead, models.AdminScopeSSOManage))
+
 				r.Route("/providers", func(r *router) {
 					r.Get("/", api.adminSSOProvidersList)
 					r.Post("/", api.adminSSOProvidersCreate)
@@ -319,6 +735,19 @@ func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Conne
 						r.Get("/", api.adminSSOProvidersGet)
 						r.Put("/", api.adminSSOProvidersUpdate)
 						r.Delete("/", api.adminSSOProvidersDelete)
+						r.Post("/test", api.adminSSOProvidersTest)
+
+						r.Route("/domains/{domain}/verification", func(r *router) {
+							r.Post("/", api.adminSSODomainVerificationInitiate)
+							r.Post("/confirm", api.adminSSODomainVerificationConfirm)
+						})
+
+						r.Route("/scim/token", func(r *router) {
+							r.Use(api.requireSCIMEnabled)
+
+							r.Post("/", api.adminSSOProvidersSCIMTokenCreate)
+							r.Delete("/", api.adminSSOProvidersSCIMTokenDelete)
+						})
 					})
 				})
 			})
@@ -328,7 +757,7 @@ func NewAPIWithVersion(globalConfig *conf.GlobalConfiguration, db *storage.Conne
 
 	corsHandler := cors.New(cors.Options{
 		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
-		AllowedHeaders:   globalConfig.CORS.AllAllowedHeaders([]string{"Accept", "Authorization", "Content-Type", "X-Client-IP", "X-Client-Info", audHeaderName, useCookieHeader, APIVersionHeaderName}),
+		AllowedHeaders:   globalConfig.CORS.AllAllowedHeaders([]string{"Accept", "Authorization", "Content-Type", "X-Client-IP", "X-Client-Info", utilities.DeviceIDHeader, audHeaderName, useCookieHeader, APIVersionHeaderName}),
 		ExposedHeaders:   []string{"X-Total-Count", "Link", APIVersionHeaderName},
 		AllowCredentials: true,
 	})
@@ -354,6 +783,34 @@ func (a *API) HealthCheck(w http.ResponseWriter, r *http.Request) error {
 
 // Mailer returns NewMailer with the current tenant config
 func (a *API) Mailer() mailer.Mailer {
+	return a.newMailer(a.enqueueEmail)
+}
+
+// newMailer returns a mailer which queues emails with enqueue when the
+// outbox is enabled. Without enqueue, emails are always sent right away.
+func (a *API) newMailer(enqueue func(*mailer.OutboxEmail) error) mailer.Mailer {
 	config := a.config
-	return mailer.NewMailer(config)
+
+	var m *mailer.TemplateMailer
+	if config.Outbox.Enabled && enqueue != nil {
+		m = mailer.NewOutboxMailer(config, enqueue).(*mailer.TemplateMailer)
+	} else {
+		m = mailer.NewMailer(config).(*mailer.TemplateMailer)
+	}
+
+	if config.Mailer.Suppression.Enabled {
+		m.IsSuppressed = func(address string) (bool, error) {
+			return models.IsEmailSuppressed(a.db, address)
+		}
+	}
+
+	if config.DeliveryTracking.Enabled {
+		m.OnSent = a.recordEmailDelivery
+	}
+
+	if config.ShortLinks.Enabled {
+		m.ShortenURL = a.shortenURL
+	}
+
+	return m
 }
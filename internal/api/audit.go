@@ -1,9 +1,14 @@
 package api
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
+	"github.com/gofrs/uuid"
 	"github.com/supabase/auth/internal/models"
 )
 
@@ -13,9 +18,82 @@ var filterColumnMap = map[string][]string{
 	"type":   {"log_type"},
 }
 
+// parseAuditLogFilter parses the filters of the entries from the query,
+// like actor_id=...&action=login,logout&created_after=2024-01-01T00:00:00Z.
+func parseAuditLogFilter(query url.Values) (models.AuditLogFilter, error) {
+	filter := models.AuditLogFilter{
+		IPAddress: query.Get("ip_address"),
+	}
+
+	for name, id := range map[string]*string{
+		"actor_id": &filter.ActorID,
+		"user_id":  &filter.UserID,
+	} {
+		if value := query.Get(name); value != "" {
+			if _, err := uuid.FromString(value); err != nil {
+				return filter, badRequestError(ErrorCodeValidationFailed, "%s must be a UUID", name)
+			}
+			*id = value
+		}
+	}
+
+	if value := query.Get("action"); value != "" {
+		for _, action := range strings.Split(value, ",") {
+			if action = strings.TrimSpace(action); action != "" {
+				filter.Actions = append(filter.Actions, action)
+			}
+		}
+	}
+
+	for name, bound := range map[string]**time.Time{
+		"created_after":  &filter.CreatedAfter,
+		"created_before": &filter.CreatedBefore,
+	} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, badRequestError(ErrorCodeValidationFailed, "%s must be an RFC 3339 time", name)
+			}
+			*bound = &t
+		}
+	}
+
+	return filter, nil
+}
+
+// auditLogCursor is the opaque cursor of the next entries.
+type auditLogCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        uuid.UUID `json:"id"`
+}
+
+func encodeAuditLogCursor(entry *models.AuditLogEntry) string {
+	data, _ := json.Marshal(&auditLogCursor{
+		CreatedAt: entry.CreatedAt,
+		ID:        entry.ID,
+	})
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeAuditLogCursor(value string) (*models.AuditLogCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, badRequestError(ErrorCodeValidationFailed, "Invalid cursor")
+	}
+
+	var cursor auditLogCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, badRequestError(ErrorCodeValidationFailed, "Invalid cursor")
+	}
+
+	return &models.AuditLogCursor{CreatedAt: cursor.CreatedAt, ID: cursor.ID}, nil
+}
+
 func (a *API) adminAuditLog(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	db := a.db.WithContext(ctx)
+	query := r.URL.Query()
 
 	// aud := a.requestAud(ctx, r)
 	pageParams, err := paginate(r)
@@ -25,7 +103,7 @@ func (a *API) adminAuditLog(w http.ResponseWriter, r *http.Request) error {
 
 	var col []string
 	var qval string
-	q := r.URL.Query().Get("query")
+	q := query.Get("query")
 	if q != "" {
 		var exists bool
 		qparts := strings.SplitN(q, ":", 2)
@@ -36,12 +114,35 @@ func (a *API) adminAuditLog(w http.ResponseWriter, r *http.Request) error {
 		qval = qparts[1]
 	}
 
-	logs, err := models.FindAuditLogEntries(db, col, qval, pageParams)
+	filter, err := parseAuditLogFilter(query)
 	if err != nil {
-		return internalServerError("Error searching for audit logs").WithInternalError(err)
+		return err
 	}
 
-	addPaginationHeaders(w, r, pageParams)
+	var logs []*models.AuditLogEntry
+	if cursorValue := query.Get("cursor"); cursorValue != "" {
+		cursor, err := decodeAuditLogCursor(cursorValue)
+		if err != nil {
+			return err
+		}
+
+		logs, err = models.FindAuditLogEntriesByCursor(db, col, qval, filter, cursor, int(pageParams.PerPage)) // #nosec G115
+		if err != nil {
+			return internalServerError("Error searching for audit logs").WithInternalError(err)
+		}
+	} else {
+		logs, err = models.FindAuditLogEntries(db, col, qval, filter, pageParams)
+		if err != nil {
+			return internalServerError("Error searching for audit logs").WithInternalError(err)
+		}
+
+		addPaginationHeaders(w, r, pageParams)
+	}
+
+	// full pages have the cursor of the next entries
+	if len(logs) > 0 && uint64(len(logs)) == pageParams.PerPage {
+		w.Header().Set("X-Next-Cursor", encodeAuditLogCursor(logs[len(logs)-1]))
+	}
 
 	return sendJSON(w, http.StatusOK, logs)
 }
@@ -123,6 +123,79 @@ func (ts *AuditTestSuite) TestAuditFilters() {
 	}
 }
 
+func (ts *AuditTestSuite) TestAuditStructuredFilters() {
+	ts.prepareDeleteEvent()
+
+	logs := []models.AuditLogEntry{}
+	require.NoError(ts.T(), ts.API.db.All(&logs))
+	require.Len(ts.T(), logs, 1)
+	traits := logs[0].Payload["traits"].(map[string]interface{})
+
+	cases := map[string]int{
+		"/admin/audit?action=user_deleted,logout":                         1,
+		"/admin/audit?action=login":                                       0,
+		"/admin/audit?user_id=" + traits["user_id"].(string):              1,
+		"/admin/audit?actor_id=" + traits["user_id"].(string):             0,
+		"/admin/audit?created_after=2000-01-01T00:00:00Z":                 1,
+		"/admin/audit?created_before=2000-01-01T00:00:00Z":                0,
+		"/admin/audit?ip_address=203.0.113.7":                             0,
+		"/admin/audit?query=type:team&created_after=2000-01-01T00:00:00Z": 1,
+	}
+
+	for q, expected := range cases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, q, nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+
+		ts.API.handler.ServeHTTP(w, req)
+		require.Equal(ts.T(), http.StatusOK, w.Code, q)
+
+		logs := []models.AuditLogEntry{}
+		require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&logs))
+		require.Len(ts.T(), logs, expected, q)
+	}
+
+	for _, q := range []string{"/admin/audit?user_id=me", "/admin/audit?created_after=yesterday", "/admin/audit?cursor=invalid"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, q, nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+
+		ts.API.handler.ServeHTTP(w, req)
+		require.Equal(ts.T(), http.StatusBadRequest, w.Code, q)
+	}
+}
+
+func (ts *AuditTestSuite) TestAuditCursor() {
+	ts.prepareDeleteEvent()
+	ts.prepareDeleteEvent()
+	ts.prepareDeleteEvent()
+
+	var ids []string
+	path := "/admin/audit?per_page=2"
+	for path != "" {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.token))
+
+		ts.API.handler.ServeHTTP(w, req)
+		require.Equal(ts.T(), http.StatusOK, w.Code)
+
+		logs := []models.AuditLogEntry{}
+		require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&logs))
+		for _, log := range logs {
+			ids = append(ids, log.ID.String())
+		}
+
+		path = ""
+		if cursor := w.Header().Get("X-Next-Cursor"); cursor != "" {
+			path = "/admin/audit?per_page=2&cursor=" + cursor
+		}
+	}
+
+	require.Len(ts.T(), ids, 3)
+	require.NotEqual(ts.T(), ids[0], ids[2])
+}
+
 func (ts *AuditTestSuite) prepareDeleteEvent() {
 	// DELETE USER
 	u, err := models.NewUser("12345678", "test-delete@example.com", "test", ts.Config.JWT.Aud, nil)
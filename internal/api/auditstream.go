@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/auditsink"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+)
+
+var streamedAuditLogEntriesCounter = observability.ObtainMetricCounter("gotrue_audit_log_streamed_entries", "Number of audit log entries streamed to the sinks")
+
+// StreamAuditLog streams the audit log entries to the enabled sinks until
+// the context is done. Entries are read from the database once their
+// transactions are committed, and are sent at least once to each sink, by
+// one instance at a time.
+func (a *API) StreamAuditLog(ctx context.Context) {
+	config := a.config
+
+	sinks, err := auditsink.NewSinks(&config.AuditLog)
+	if err != nil {
+		logrus.WithError(err).Error("Unable to start the audit log sinks")
+		return
+	}
+
+	for _, sink := range sinks {
+		go a.streamAuditLogToSink(ctx, sink)
+	}
+}
+
+func (a *API) streamAuditLogToSink(ctx context.Context, sink auditsink.Sink) {
+	config := a.config
+	defer sink.Close()
+
+	ticker := time.NewTicker(config.AuditLog.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			for ctx.Err() == nil {
+				streamed, err := a.streamAuditLogBatch(ctx, sink)
+				if err != nil {
+					logrus.WithError(err).WithField("sink", sink.Name()).Error("Unable to stream audit log entries")
+					break
+				}
+
+				if streamed < config.AuditLog.BatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// streamAuditLogBatch sends the next entries to the sink, and returns how
+// many were sent. The cursor of the sink is only advanced once the sink
+// has received them.
+func (a *API) streamAuditLogBatch(ctx context.Context, sink auditsink.Sink) (int, error) {
+	db := a.db.WithContext(ctx)
+	config := a.config
+
+	var streamed int
+	err := db.Transaction(func(tx *storage.Connection) error {
+		cursor, terr := models.ClaimAuditLogSinkCursor(tx, sink.Name())
+		if terr != nil || cursor == nil {
+			return terr
+		}
+
+		entries, terr := models.FindAuditLogEntriesAfterCursor(tx, cursor, time.Now().Add(-config.AuditLog.Delay), config.AuditLog.BatchSize)
+		if terr != nil || len(entries) == 0 {
+			return terr
+		}
+
+		batch := make([]auditsink.Entry, len(entries))
+		for i, entry := range entries {
+			batch[i] = auditsink.NewEntry(entry)
+		}
+
+		if terr := sink.Send(ctx, batch); terr != nil {
+			return terr
+		}
+
+		streamed = len(entries)
+		return cursor.Advance(tx, entries[len(entries)-1])
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if streamed > 0 {
+		streamedAuditLogEntriesCounter.Add(ctx, int64(streamed))
+	}
+
+	return streamed, nil
+}
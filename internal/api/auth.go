@@ -5,12 +5,15 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gofrs/uuid"
 	jwt "github.com/golang-jwt/jwt/v5"
 	"github.com/supabase/auth/internal/conf"
 	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
 	"github.com/supabase/auth/internal/storage"
+	"github.com/supabase/auth/internal/utilities"
 )
 
 // requireAuthentication checks incoming requests for tokens presented using the Authorization header
@@ -29,6 +32,19 @@ func (a *API) requireAuthentication(w http.ResponseWriter, r *http.Request) (con
 	if err != nil {
 		return ctx, err
 	}
+
+	// every request of an admin impersonating a user is audited
+	if session := getSession(ctx); session != nil && session.ImpersonatedBy != nil {
+		if err := models.NewAuditLogEntry(r, a.db.WithContext(ctx), getUser(ctx), models.ImpersonatedRequestAction, utilities.GetIPAddress(r), map[string]interface{}{
+			"session_id":      session.ID,
+			"impersonated_by": *session.ImpersonatedBy,
+			"method":          r.Method,
+			"path":            r.URL.Path,
+		}); err != nil {
+			return ctx, internalServerError("Database error recording impersonated request").WithInternalError(err)
+		}
+	}
+
 	return ctx, err
 }
 
@@ -58,6 +74,51 @@ func (a *API) requireAdmin(ctx context.Context) (context.Context, error) {
 	return nil, forbiddenError(ErrorCodeNotAdmin, "User not allowed").WithInternalMessage(fmt.Sprintf("this token needs to have one of the following roles: %v", strings.Join(adminRoles, ", ")))
 }
 
+// adminCredentialRole is the role of the admin users of scoped admin
+// credentials.
+const adminCredentialRole = "admin_credential"
+
+// requireScopedAdminCredential authenticates the token of a scoped admin
+// credential.
+func (a *API) requireScopedAdminCredential(r *http.Request, token string) (context.Context, error) {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	credential, err := models.FindAdminCredentialByToken(db, token)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, forbiddenError(ErrorCodeBadJWT, "Invalid token")
+		}
+		return nil, internalServerError("Database error finding admin credential").WithInternalError(err)
+	}
+
+	now := time.Now()
+	if credential.IsExpired(now) {
+		return nil, forbiddenError(ErrorCodeBadJWT, "Admin credential has expired")
+	}
+
+	if err := credential.UpdateLastUsedAt(db, now); err != nil {
+		return nil, internalServerError("Database error updating admin credential").WithInternalError(err)
+	}
+
+	observability.LogEntrySetField(r, "admin_credential_id", credential.ID.String())
+
+	ctx = withAdminCredential(ctx, credential)
+	return withAdminUser(ctx, &models.User{Role: adminCredentialRole, Email: storage.NullString(adminCredentialRole + ":" + credential.ID.String())}), nil
+}
+
+// checkAdminScope returns an error if the request is made with a scoped
+// admin credential without the scope. The tokens of the admin roles have
+// every scope.
+func checkAdminScope(ctx context.Context, scope string) error {
+	credential := getAdminCredential(ctx)
+	if credential == nil || credential.HasScope(scope) {
+		return nil
+	}
+
+	return forbiddenError(ErrorCodeInsufficientAdminScope, "This admin credential needs the %s scope", scope)
+}
+
 func (a *API) extractBearerToken(r *http.Request) (string, error) {
 	authHeader := r.Header.Get("Authorization")
 	matches := bearerRegexp.FindStringSubmatch(authHeader)
@@ -106,6 +167,12 @@ func (a *API) maybeLoadUserOrSession(ctx context.Context) (context.Context, erro
 		return nil, forbiddenError(ErrorCodeBadJWT, "invalid claim: missing sub claim")
 	}
 
+	// access tokens revoked on any instance are denied right away, without
+	// reading the database
+	if a.isTokenRevoked(claims) {
+		return ctx, forbiddenError(ErrorCodeSessionNotFound, "Session from session_id claim in JWT has been revoked")
+	}
+
 	var user *models.User
 	if claims.Subject != "" {
 		userId, err := uuid.FromString(claims.Subject)
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+	"github.com/supabase/auth/internal/utilities"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var bulkInviteCounter = observability.ObtainMetricCounter("gotrue_bulk_invites", "Number of users invited in bulk by result")
+
+// BulkInvitation is a user to invite in bulk. Its metadata is merged over
+// the one of the batch, and its redirect URL overrides the one of the
+// batch.
+type BulkInvitation struct {
+	Email      string                 `json:"email"`
+	Data       map[string]interface{} `json:"data"`
+	RedirectTo string                 `json:"redirect_to"`
+}
+
+// BulkInviteParams are the parameters the adminBulkInvite endpoint accepts
+type BulkInviteParams struct {
+	Invitations []BulkInvitation       `json:"invitations"`
+	Data        map[string]interface{} `json:"data"`
+	RedirectTo  string                 `json:"redirect_to"`
+}
+
+// BulkInviteResponse is the response of the bulk invite endpoints
+type BulkInviteResponse struct {
+	BatchID     uuid.UUID            `json:"batch_id"`
+	Invitations []*models.BulkInvite `json:"invitations"`
+}
+
+// adminBulkInvite invites users in bulk. Each user is invited on its own,
+// so that the users who can't be invited don't fail the batch, and their
+// invite emails are queued in the outbox.
+func (a *API) adminBulkInvite(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	config := a.config
+
+	params := &BulkInviteParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if len(params.Invitations) == 0 {
+		return badRequestError(ErrorCodeValidationFailed, "At least one invitation is required")
+	}
+
+	if len(params.Invitations) > config.BulkInvite.MaxInvitations {
+		return badRequestError(ErrorCodeValidationFailed, "At most %d users can be invited at once", config.BulkInvite.MaxInvitations)
+	}
+
+	if params.RedirectTo != "" && !utilities.IsRedirectURLValid(config, params.RedirectTo) {
+		return badRequestError(ErrorCodeValidationFailed, "redirect_to is not allowed")
+	}
+
+	if err := a.validateUserMetadata(params.Data); err != nil {
+		return err
+	}
+
+	batchID := uuid.Must(uuid.NewV4())
+	invited := make(map[string]bool, len(params.Invitations))
+	invites := make([]*models.BulkInvite, 0, len(params.Invitations))
+
+	for i, invitation := range params.Invitations {
+		invite := a.bulkInviteUser(r, batchID, i+1, params, invitation, invited)
+		if err := a.db.WithContext(ctx).Create(invite); err != nil {
+			return internalServerError("Database error saving bulk invite").WithInternalError(err)
+		}
+
+		bulkInviteCounter.Add(ctx, 1, metric.WithAttributeSet(attribute.NewSet(attribute.String("result", invite.Status))))
+		invites = append(invites, invite)
+	}
+
+	return sendJSON(w, http.StatusOK, &BulkInviteResponse{
+		BatchID:     batchID,
+		Invitations: invites,
+	})
+}
+
+// bulkInviteUser invites the user of the invitation at the position in the
+// batch, and returns whether it was invited or why it wasn't. Emails
+// already invited in the batch are recorded in invited.
+func (a *API) bulkInviteUser(r *http.Request, batchID uuid.UUID, position int, params *BulkInviteParams, invitation BulkInvitation, invited map[string]bool) *models.BulkInvite {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+	adminUser := getAdminUser(ctx)
+
+	redirectTo := invitation.RedirectTo
+	if redirectTo == "" {
+		redirectTo = params.RedirectTo
+	}
+
+	invite := models.NewBulkInvite(batchID, position, invitation.Email, redirectTo)
+
+	data := make(map[string]interface{}, len(params.Data)+len(invitation.Data))
+	for k, v := range params.Data {
+		data[k] = v
+	}
+	for k, v := range invitation.Data {
+		data[k] = v
+	}
+
+	var outboxMessageID *uuid.UUID
+	err := func() error {
+		email, err := a.validateEmail(invitation.Email)
+		if err != nil {
+			return err
+		}
+		invite.Email = email
+
+		if invited[email] {
+			return badRequestError(ErrorCodeValidationFailed, "Email address is invited more than once in the batch")
+		}
+		invited[email] = true
+
+		if invitation.RedirectTo != "" && !utilities.IsRedirectURLValid(config, invitation.RedirectTo) {
+			return badRequestError(ErrorCodeValidationFailed, "redirect_to is not allowed")
+		}
+
+		if err := a.validateUserMetadata(data); err != nil {
+			return err
+		}
+
+		// the invite email is sent to the redirect URL of the invitation,
+		// and its outbox message is recorded to track its delivery
+		req := r.Clone(withOutboxQueued(ctx, func(messageID string) {
+			if id, err := uuid.FromString(messageID); err == nil {
+				outboxMessageID = &id
+			}
+		}))
+		if redirectTo != "" {
+			req.Header.Set("redirect_to", redirectTo)
+		}
+
+		return db.Transaction(func(tx *storage.Connection) error {
+			user, terr := a.inviteUser(req, tx, adminUser, email, data, a.requestAud(ctx, r))
+			if terr != nil {
+				return terr
+			}
+
+			invite.Invited(user, outboxMessageID)
+			return nil
+		})
+	}()
+	if err != nil {
+		message := "Database error inviting user"
+		if httpErr, ok := err.(*HTTPError); ok && httpErr.HTTPStatus < http.StatusInternalServerError {
+			message = httpErr.Message
+		} else {
+			observability.GetLogEntry(r).Entry.WithError(err).WithField("position", position).Error("Unable to invite user")
+		}
+		invite.Failed(message)
+	}
+
+	return invite
+}
+
+// adminBulkInviteGet returns the invites of a batch, with the status of
+// their invite emails.
+func (a *API) adminBulkInviteGet(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	batchID, err := uuid.FromString(chi.URLParam(r, "batch_id"))
+	if err != nil {
+		return notFoundError(ErrorCodeValidationFailed, "batch_id must be an UUID")
+	}
+
+	invites, err := models.FindBulkInvitesByBatchID(db, batchID)
+	if err != nil {
+		return internalServerError("Database error finding bulk invites").WithInternalError(err)
+	}
+
+	if len(invites) == 0 {
+		return notFoundError(ErrorCodeBulkInviteNotFound, "Bulk invite not found")
+	}
+
+	return sendJSON(w, http.StatusOK, &BulkInviteResponse{
+		BatchID:     batchID,
+		Invitations: invites,
+	})
+}
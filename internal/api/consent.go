@@ -0,0 +1,97 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// ConsentParams are the versions of the terms of service and privacy
+// policy a user accepts, at signup or later.
+type ConsentParams struct {
+	TermsVersion         string `json:"terms_version"`
+	PrivacyPolicyVersion string `json:"privacy_policy_version"`
+}
+
+// validateConsentParams checks that the versions accepted are the current
+// ones, as older versions can't be accepted anymore.
+func (a *API) validateConsentParams(params *ConsentParams) error {
+	config := a.config.Consent
+
+	if params.TermsVersion == "" && params.PrivacyPolicyVersion == "" {
+		return nil
+	}
+
+	if !config.Enabled {
+		return badRequestError(ErrorCodeConsentDisabled, "Consent tracking is disabled")
+	}
+
+	if params.TermsVersion != "" && params.TermsVersion != config.TermsVersion {
+		return badRequestError(ErrorCodeValidationFailed, "terms_version must be the current version of the terms of service")
+	}
+
+	if params.PrivacyPolicyVersion != "" && params.PrivacyPolicyVersion != config.PrivacyPolicyVersion {
+		return badRequestError(ErrorCodeValidationFailed, "privacy_policy_version must be the current version of the privacy policy")
+	}
+
+	return nil
+}
+
+// acceptConsent records the versions the user accepted, when there are
+// any.
+func (a *API) acceptConsent(r *http.Request, tx *storage.Connection, user *models.User, params *ConsentParams) error {
+	if params.TermsVersion == "" && params.PrivacyPolicyVersion == "" {
+		return nil
+	}
+
+	if err := user.AcceptConsent(tx, params.TermsVersion, params.PrivacyPolicyVersion); err != nil {
+		return internalServerError("Database error recording consent").WithInternalError(err)
+	}
+
+	if err := models.NewAuditLogEntry(r, tx, user, models.ConsentAcceptedAction, "", map[string]interface{}{
+		"terms_version":          params.TermsVersion,
+		"privacy_policy_version": params.PrivacyPolicyVersion,
+	}); err != nil {
+		return internalServerError("Error recording audit log entry").WithInternalError(err)
+	}
+
+	return nil
+}
+
+// isConsentRequired checks if the user has to accept the current versions
+// of the documents to keep refreshing their sessions.
+func (a *API) isConsentRequired(user *models.User) bool {
+	config := a.config.Consent
+
+	return config.Enabled && config.RequireAcceptance && !user.HasAcceptedConsent(config.TermsVersion, config.PrivacyPolicyVersion)
+}
+
+// UserConsentAccept records that the user accepted the current versions
+// of the terms of service and privacy policy, and returns the user.
+func (a *API) UserConsentAccept(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+
+	params := &ConsentParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if params.TermsVersion == "" && params.PrivacyPolicyVersion == "" {
+		return badRequestError(ErrorCodeValidationFailed, "terms_version or privacy_policy_version is required")
+	}
+
+	if err := a.validateConsentParams(params); err != nil {
+		return err
+	}
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		return a.acceptConsent(r, tx, user, params)
+	}); err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, user)
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"net/url"
 
-	"github.com/didip/tollbooth/v5/limiter"
 	jwt "github.com/golang-jwt/jwt/v5"
 	"github.com/supabase/auth/internal/models"
 )
@@ -25,6 +24,8 @@ const (
 	factorKey               = contextKey("factor")
 	sessionKey              = contextKey("session")
 	externalReferrerKey     = contextKey("external_referrer")
+	customStateKey          = contextKey("custom_state")
+	requestedScopesKey      = contextKey("requested_scopes")
 	functionHooksKey        = contextKey("function_hooks")
 	adminUserKey            = contextKey("admin_user")
 	oauthTokenKey           = contextKey("oauth_token") // for OAuth1.0, also known as request token
@@ -33,6 +34,12 @@ const (
 	externalHostKey         = contextKey("external_host")
 	flowStateKey            = contextKey("flow_state_id")
 	sharedLimiterKey        = contextKey("shared_limiter")
+	organizationKey         = contextKey("organization")
+	roleKey                 = contextKey("role")
+	adminCredentialKey      = contextKey("admin_credential")
+	organizationMemberKey   = contextKey("organization_member")
+	outboxQueuedKey         = contextKey("outbox_queued")
+	socketAddrKey           = contextKey("socket_addr")
 )
 
 // withToken adds the JWT token to the context.
@@ -181,6 +188,36 @@ func getExternalReferrer(ctx context.Context) string {
 	return obj.(string)
 }
 
+// withCustomState adds the custom state of the OAuth state to the context.
+func withCustomState(ctx context.Context, customState string) context.Context {
+	return context.WithValue(ctx, customStateKey, customState)
+}
+
+// getCustomState reads the custom state of the OAuth state from the context.
+func getCustomState(ctx context.Context) string {
+	obj := ctx.Value(customStateKey)
+	if obj == nil {
+		return ""
+	}
+
+	return obj.(string)
+}
+
+// withRequestedScopes adds the scopes requested on authorize to the context.
+func withRequestedScopes(ctx context.Context, scopes string) context.Context {
+	return context.WithValue(ctx, requestedScopesKey, scopes)
+}
+
+// getRequestedScopes reads the scopes requested on authorize from the context.
+func getRequestedScopes(ctx context.Context) string {
+	obj := ctx.Value(requestedScopesKey)
+	if obj == nil {
+		return ""
+	}
+
+	return obj.(string)
+}
+
 // withAdminUser adds the admin user to the context.
 func withAdminUser(ctx context.Context, u *models.User) context.Context {
 	return context.WithValue(ctx, adminUserKey, u)
@@ -195,6 +232,22 @@ func getAdminUser(ctx context.Context) *models.User {
 	return obj.(*models.User)
 }
 
+// withAdminCredential adds the scoped admin credential of the request to
+// the context.
+func withAdminCredential(ctx context.Context, credential *models.AdminCredential) context.Context {
+	return context.WithValue(ctx, adminCredentialKey, credential)
+}
+
+// getAdminCredential reads the scoped admin credential from the context,
+// which is nil for the tokens of the admin roles.
+func getAdminCredential(ctx context.Context) *models.AdminCredential {
+	obj := ctx.Value(adminCredentialKey)
+	if obj == nil {
+		return nil
+	}
+	return obj.(*models.AdminCredential)
+}
+
 // withRequestToken adds the request token to the context
 func withRequestToken(ctx context.Context, token string) context.Context {
 	return context.WithValue(ctx, oauthTokenKey, token)
@@ -232,6 +285,72 @@ func getSSOProvider(ctx context.Context) *models.SSOProvider {
 	return obj.(*models.SSOProvider)
 }
 
+func withOrganization(ctx context.Context, organization *models.Organization) context.Context {
+	return context.WithValue(ctx, organizationKey, organization)
+}
+
+func getOrganization(ctx context.Context) *models.Organization {
+	obj := ctx.Value(organizationKey)
+	if obj == nil {
+		return nil
+	}
+	return obj.(*models.Organization)
+}
+
+func withRole(ctx context.Context, role *models.Role) context.Context {
+	return context.WithValue(ctx, roleKey, role)
+}
+
+func getRole(ctx context.Context) *models.Role {
+	obj := ctx.Value(roleKey)
+	if obj == nil {
+		return nil
+	}
+	return obj.(*models.Role)
+}
+
+// withOrganizationMember adds the membership of the authenticated user in
+// the organization to the context.
+func withOrganizationMember(ctx context.Context, member *models.OrganizationMember) context.Context {
+	return context.WithValue(ctx, organizationMemberKey, member)
+}
+
+func getOrganizationMember(ctx context.Context) *models.OrganizationMember {
+	obj := ctx.Value(organizationMemberKey)
+	if obj == nil {
+		return nil
+	}
+	return obj.(*models.OrganizationMember)
+}
+
+// withOutboxQueued adds a function to the context which is called with the
+// ID of each email queued in the outbox while handling the request.
+func withOutboxQueued(ctx context.Context, fn func(messageID string)) context.Context {
+	return context.WithValue(ctx, outboxQueuedKey, fn)
+}
+
+func getOutboxQueued(ctx context.Context) func(messageID string) {
+	obj := ctx.Value(outboxQueuedKey)
+	if obj == nil {
+		return nil
+	}
+	return obj.(func(messageID string))
+}
+
+// withSocketAddr adds the address of the connection of the request to the
+// context.
+func withSocketAddr(ctx context.Context, addr string) context.Context {
+	return context.WithValue(ctx, socketAddrKey, addr)
+}
+
+func getSocketAddr(ctx context.Context) string {
+	obj := ctx.Value(socketAddrKey)
+	if obj == nil {
+		return ""
+	}
+	return obj.(string)
+}
+
 func withExternalHost(ctx context.Context, u *url.URL) context.Context {
 	return context.WithValue(ctx, externalHostKey, u)
 }
@@ -245,8 +364,8 @@ func getExternalHost(ctx context.Context) *url.URL {
 }
 
 type SharedLimiter struct {
-	EmailLimiter *limiter.Limiter
-	PhoneLimiter *limiter.Limiter
+	EmailLimiter *rateLimiter
+	PhoneLimiter *rateLimiter
 }
 
 func withLimiter(ctx context.Context, limiter *SharedLimiter) context.Context {
@@ -0,0 +1,264 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// dataExportLease is how long an export being generated is hidden from
+// other instances.
+const dataExportLease = 5 * time.Minute
+
+var dataExportCounter = observability.ObtainMetricCounter("gotrue_data_exports", "Number of exports of the data of users generated by result")
+
+// DataExportArchive is all the data held about a user. The identities and
+// factors of the user are in its profile, like the user endpoints return
+// them. The audit log entries are the ones the user is the actor of, and
+// the ones about it.
+type DataExportArchive struct {
+	ExportedAt      time.Time               `json:"exported_at"`
+	User            *models.User            `json:"user"`
+	Sessions        []*models.Session       `json:"sessions"`
+	AuditLogEntries []*models.AuditLogEntry `json:"audit_log_entries"`
+}
+
+// dataExportActor is who requested or downloaded the export, the admin on
+// the admin endpoints and the user otherwise.
+func dataExportActor(ctx context.Context) *models.User {
+	if adminUser := getAdminUser(ctx); adminUser != nil {
+		return adminUser
+	}
+	return getUser(ctx)
+}
+
+// findDataExport returns the export of the user with the ID in the URL.
+func findDataExport(r *http.Request, db *storage.Connection, user *models.User) (*models.DataExport, error) {
+	exportID, err := uuid.FromString(chi.URLParam(r, "export_id"))
+	if err != nil {
+		return nil, notFoundError(ErrorCodeValidationFailed, "export_id must be an UUID")
+	}
+
+	export, err := models.FindDataExportByUserIDAndID(db, user.ID, exportID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, notFoundError(ErrorCodeDataExportNotFound, "Data export not found")
+		}
+		return nil, internalServerError("Database error finding data export").WithInternalError(err)
+	}
+
+	return export, nil
+}
+
+// DataExportCreate requests an export of all the data held about the
+// user, which is generated in the background. Requesting an export while
+// one is being generated returns that export. It serves both the
+// authenticated user and the admin endpoints.
+func (a *API) DataExportCreate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+
+	exports, err := models.FindDataExportsByUserID(db, user.ID)
+	if err != nil {
+		return internalServerError("Database error finding data exports").WithInternalError(err)
+	}
+
+	for _, export := range exports {
+		if export.Status == models.DataExportPending || export.Status == models.DataExportRunning {
+			return sendJSON(w, http.StatusAccepted, export)
+		}
+	}
+
+	export := models.NewDataExport(user)
+	err = db.Transaction(func(tx *storage.Connection) error {
+		if terr := tx.Create(export); terr != nil {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, dataExportActor(ctx), models.DataExportRequestedAction, "", map[string]interface{}{
+			"user_id":        user.ID,
+			"data_export_id": export.ID,
+		})
+	})
+	if err != nil {
+		return internalServerError("Database error creating data export").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusAccepted, export)
+}
+
+// DataExportList lists the exports of the user, the latest first.
+func (a *API) DataExportList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	exports, err := models.FindDataExportsByUserID(db, getUser(ctx).ID)
+	if err != nil {
+		return internalServerError("Database error finding data exports").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"exports": exports,
+	})
+}
+
+// DataExportGet returns the status of an export of the user.
+func (a *API) DataExportGet(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	export, err := findDataExport(r, db, getUser(ctx))
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, export)
+}
+
+// DataExportDownload downloads the archive of a completed export of the
+// user as a JSON file.
+func (a *API) DataExportDownload(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+	user := getUser(ctx)
+
+	export, err := findDataExport(r, db, user)
+	if err != nil {
+		return err
+	}
+
+	if export.Status != models.DataExportCompleted || export.IsExpired(time.Now()) {
+		return unprocessableEntityError(ErrorCodeDataExportNotReady, "Data export is %s and can't be downloaded", export.Status)
+	}
+
+	archive, err := export.GetArchive(config.Security.DBEncryption.DecryptionKeys)
+	if err != nil {
+		return internalServerError("Error reading data export").WithInternalError(err)
+	}
+
+	if err := models.NewAuditLogEntry(r, db, dataExportActor(ctx), models.DataExportDownloadedAction, "", map[string]interface{}{
+		"user_id":        user.ID,
+		"data_export_id": export.ID,
+	}); err != nil {
+		return internalServerError("Error recording audit log entry").WithInternalError(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"data-export-"+export.ID.String()+".json\"")
+	w.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(archive); err != nil {
+		logrus.WithError(err).WithField("data_export_id", export.ID).Warn("Unable to write data export")
+	}
+
+	return nil
+}
+
+// ProcessDataExports generates the pending exports, and removes the
+// expired ones, until the context is done.
+func (a *API) ProcessDataExports(ctx context.Context) {
+	config := a.config
+	if !config.DataExport.Enabled {
+		return
+	}
+
+	ticker := time.NewTicker(config.DataExport.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			a.processDataExports(ctx)
+		}
+	}
+}
+
+func (a *API) processDataExports(ctx context.Context) {
+	db := a.db.WithContext(ctx)
+
+	if deleted, err := models.DeleteExpiredDataExports(db, time.Now()); err != nil {
+		logrus.WithError(err).Error("Unable to delete expired data exports")
+	} else if deleted > 0 {
+		logrus.WithField("deleted", deleted).Info("Deleted expired data exports")
+	}
+
+	for ctx.Err() == nil {
+		export, err := models.ClaimDataExport(db, dataExportLease)
+		if err != nil {
+			logrus.WithError(err).Error("Unable to claim data export")
+			return
+		}
+		if export == nil {
+			return
+		}
+
+		a.runDataExport(ctx, export)
+	}
+}
+
+func (a *API) runDataExport(ctx context.Context, export *models.DataExport) {
+	db := a.db.WithContext(ctx)
+	config := a.config
+	log := logrus.WithField("data_export_id", export.ID)
+
+	result := "completed"
+	archive, err := a.buildDataExportArchive(db, export.UserID)
+	if err == nil {
+		err = export.Complete(db, archive, config.DataExport.Expiry, config.Security.DBEncryption.Encrypt, config.Security.DBEncryption.EncryptionKeyID, config.Security.DBEncryption.EncryptionKey)
+	}
+	if err != nil {
+		result = "failed"
+		log.WithError(err).Error("Unable to generate data export")
+
+		if err := export.Fail(db, config.DataExport.Expiry); err != nil {
+			log.WithError(err).Error("Unable to fail data export")
+		}
+	}
+
+	dataExportCounter.Add(ctx, 1, metric.WithAttributeSet(attribute.NewSet(attribute.String("result", result))))
+}
+
+// buildDataExportArchive assembles the archive of all the data held about
+// the user.
+func (a *API) buildDataExportArchive(db *storage.Connection, userID uuid.UUID) ([]byte, error) {
+	user, err := models.FindUserByID(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := models.FindAllSessionsForUser(db, user.ID, false)
+	if err != nil {
+		return nil, err
+	}
+	if sessions == nil {
+		sessions = []*models.Session{}
+	}
+
+	entries, err := models.FindAuditLogEntries(db, nil, "", models.AuditLogFilter{UserID: user.ID.String()}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&DataExportArchive{
+		ExportedAt:      time.Now().UTC(),
+		User:            user,
+		Sessions:        sessions,
+		AuditLogEntries: entries,
+	})
+}
@@ -0,0 +1,556 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var messageDeliveryCounter = observability.ObtainMetricCounter("gotrue_message_deliveries", "Number of delivery receipts of messages by channel, provider and status")
+
+// deliveryEvent is the delivery status of a message, reported by the
+// webhook of a provider.
+type deliveryEvent struct {
+	MessageID string
+	Status    string
+	Details   string
+}
+
+// deliveryChannels are the channels of the providers with delivery
+// webhooks.
+var deliveryChannels = map[string]string{
+	"ses":         models.OutboxChannelEmail,
+	"sendgrid":    models.OutboxChannelEmail,
+	"postmark":    models.OutboxChannelEmail,
+	"mailgun":     models.OutboxChannelEmail,
+	"twilio":      models.OutboxChannelSMS,
+	"vonage":      models.OutboxChannelSMS,
+	"messagebird": models.OutboxChannelSMS,
+	"sinch":       models.OutboxChannelSMS,
+}
+
+type sesDeliveryNotification struct {
+	NotificationType string `json:"notificationType"`
+	EventType        string `json:"eventType"`
+
+	Mail struct {
+		MessageID string `json:"messageId"`
+	} `json:"mail"`
+
+	Bounce struct {
+		BounceType        string `json:"bounceType"`
+		BouncedRecipients []struct {
+			DiagnosticCode string `json:"diagnosticCode"`
+		} `json:"bouncedRecipients"`
+	} `json:"bounce"`
+
+	Reject struct {
+		Reason string `json:"reason"`
+	} `json:"reject"`
+}
+
+type sendGridDeliveryEvent struct {
+	SGMessageID string `json:"sg_message_id"`
+	Event       string `json:"event"`
+	Reason      string `json:"reason"`
+}
+
+type postmarkDeliveryEvent struct {
+	RecordType  string `json:"RecordType"`
+	MessageID   string `json:"MessageID"`
+	Type        string `json:"Type"`
+	Description string `json:"Description"`
+}
+
+type mailgunDeliveryEvent struct {
+	EventData struct {
+		Event    string `json:"event"`
+		Severity string `json:"severity"`
+		Message  struct {
+			Headers struct {
+				MessageID string `json:"message-id"`
+			} `json:"headers"`
+		} `json:"message"`
+		DeliveryStatus struct {
+			Description string `json:"description"`
+			Message     string `json:"message"`
+		} `json:"delivery-status"`
+	} `json:"event-data"`
+}
+
+type vonageDeliveryReceipt struct {
+	// MessageID and ErrCode are set by the receipts of the SMS API, and
+	// MessageUUID and Error by the ones of the messages API.
+	MessageID   string `json:"messageId"`
+	MessageUUID string `json:"message_uuid"`
+	Status      string `json:"status"`
+	ErrCode     string `json:"err-code"`
+	Error       struct {
+		Title  string `json:"title"`
+		Detail string `json:"detail"`
+	} `json:"error"`
+}
+
+type sinchDeliveryReport struct {
+	BatchID string `json:"batch_id"`
+	Status  string `json:"status"`
+	Code    int    `json:"code"`
+}
+
+// parseSESDeliveryEvents returns the deliveries, bounces and rejections of
+// an SES notification, or the URL to confirm the SNS subscription with.
+func parseSESDeliveryEvents(body []byte) ([]deliveryEvent, string, error) {
+	var message snsMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, "", err
+	}
+
+	payload := body
+	switch message.Type {
+	case "SubscriptionConfirmation":
+		return nil, message.SubscribeURL, nil
+	case "Notification":
+		payload = []byte(message.Message)
+	case "UnsubscribeConfirmation":
+		return nil, "", nil
+	}
+
+	var notification sesDeliveryNotification
+	if err := json.Unmarshal(payload, &notification); err != nil {
+		return nil, "", err
+	}
+
+	event := deliveryEvent{MessageID: notification.Mail.MessageID}
+
+	switch notification.NotificationType + notification.EventType {
+	case "Delivery":
+		event.Status = models.MessageDeliveryDelivered
+
+	case "Bounce":
+		event.Status = models.MessageDeliveryFailed
+		event.Details = notification.Bounce.BounceType
+		if len(notification.Bounce.BouncedRecipients) > 0 && notification.Bounce.BouncedRecipients[0].DiagnosticCode != "" {
+			event.Details += ": " + notification.Bounce.BouncedRecipients[0].DiagnosticCode
+		}
+
+	case "Reject":
+		event.Status = models.MessageDeliveryFailed
+		event.Details = notification.Reject.Reason
+
+	default:
+		return nil, "", nil
+	}
+
+	return []deliveryEvent{event}, "", nil
+}
+
+// parseSendGridDeliveryEvents returns the deliveries, bounces and drops of
+// the events of a SendGrid event webhook.
+func parseSendGridDeliveryEvents(body []byte) ([]deliveryEvent, error) {
+	var sendGridEvents []sendGridDeliveryEvent
+	if err := json.Unmarshal(body, &sendGridEvents); err != nil {
+		return nil, err
+	}
+
+	var events []deliveryEvent
+	for _, event := range sendGridEvents {
+		// the ID of the message is the X-Message-Id of the response it was
+		// sent with, followed by the ID of the recipient
+		messageID, _, _ := strings.Cut(event.SGMessageID, ".")
+
+		switch event.Event {
+		case "delivered":
+			events = append(events, deliveryEvent{
+				MessageID: messageID,
+				Status:    models.MessageDeliveryDelivered,
+			})
+
+		case "bounce", "dropped":
+			events = append(events, deliveryEvent{
+				MessageID: messageID,
+				Status:    models.MessageDeliveryFailed,
+				Details:   event.Reason,
+			})
+		}
+	}
+
+	return events, nil
+}
+
+// parsePostmarkDeliveryEvents returns the delivery or bounce of a Postmark
+// webhook.
+func parsePostmarkDeliveryEvents(body []byte) ([]deliveryEvent, error) {
+	var event postmarkDeliveryEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+
+	switch event.RecordType {
+	case "Delivery":
+		return []deliveryEvent{{
+			MessageID: event.MessageID,
+			Status:    models.MessageDeliveryDelivered,
+		}}, nil
+
+	case "Bounce":
+		return []deliveryEvent{{
+			MessageID: event.MessageID,
+			Status:    models.MessageDeliveryFailed,
+			Details:   fmt.Sprintf("%s: %s", event.Type, event.Description),
+		}}, nil
+	}
+
+	return nil, nil
+}
+
+// parseMailgunDeliveryEvents returns the delivery or permanent failure of
+// a Mailgun webhook. Temporary failures are retried by Mailgun.
+func parseMailgunDeliveryEvents(body []byte) ([]deliveryEvent, error) {
+	var event mailgunDeliveryEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+
+	data := event.EventData
+	switch {
+	case data.Event == "delivered":
+		return []deliveryEvent{{
+			MessageID: data.Message.Headers.MessageID,
+			Status:    models.MessageDeliveryDelivered,
+		}}, nil
+
+	case data.Event == "failed" && data.Severity == "permanent":
+		details := data.DeliveryStatus.Description
+		if details == "" {
+			details = data.DeliveryStatus.Message
+		}
+
+		return []deliveryEvent{{
+			MessageID: data.Message.Headers.MessageID,
+			Status:    models.MessageDeliveryFailed,
+			Details:   details,
+		}}, nil
+	}
+
+	return nil, nil
+}
+
+// parseTwilioDeliveryEvents returns the final status of a message of a
+// Twilio status callback.
+func parseTwilioDeliveryEvents(values url.Values) ([]deliveryEvent, error) {
+	event := deliveryEvent{MessageID: values.Get("MessageSid")}
+
+	switch values.Get("MessageStatus") {
+	case "delivered":
+		event.Status = models.MessageDeliveryDelivered
+	case "undelivered", "failed":
+		event.Status = models.MessageDeliveryFailed
+		if code := values.Get("ErrorCode"); code != "" {
+			event.Details = "error code " + code
+		}
+	default:
+		return nil, nil
+	}
+
+	return []deliveryEvent{event}, nil
+}
+
+// parseVonageDeliveryEvents returns the final status of a message of a
+// delivery receipt of the Vonage SMS or messages API.
+func parseVonageDeliveryEvents(body []byte, values url.Values) ([]deliveryEvent, error) {
+	var receipt vonageDeliveryReceipt
+	if len(body) > 0 && body[0] == '{' {
+		if err := json.Unmarshal(body, &receipt); err != nil {
+			return nil, err
+		}
+	} else {
+		receipt.MessageID = values.Get("messageId")
+		receipt.Status = values.Get("status")
+		receipt.ErrCode = values.Get("err-code")
+	}
+
+	event := deliveryEvent{MessageID: receipt.MessageID}
+	if event.MessageID == "" {
+		event.MessageID = receipt.MessageUUID
+	}
+
+	switch receipt.Status {
+	case "delivered", "read":
+		event.Status = models.MessageDeliveryDelivered
+	case "failed", "rejected", "expired", "undeliverable":
+		event.Status = models.MessageDeliveryFailed
+		event.Details = receipt.Status
+		if receipt.ErrCode != "" && receipt.ErrCode != "0" {
+			event.Details += ": error code " + receipt.ErrCode
+		} else if receipt.Error.Title != "" {
+			event.Details += ": " + receipt.Error.Title
+		}
+	default:
+		return nil, nil
+	}
+
+	return []deliveryEvent{event}, nil
+}
+
+// parseMessagebirdDeliveryEvents returns the final status of a message of
+// a MessageBird status report.
+func parseMessagebirdDeliveryEvents(values url.Values) ([]deliveryEvent, error) {
+	event := deliveryEvent{MessageID: values.Get("id")}
+
+	switch values.Get("status") {
+	case "delivered":
+		event.Status = models.MessageDeliveryDelivered
+	case "delivery_failed", "expired":
+		event.Status = models.MessageDeliveryFailed
+		event.Details = values.Get("status")
+		if code := values.Get("statusErrorCode"); code != "" {
+			event.Details += ": error code " + code
+		}
+	default:
+		return nil, nil
+	}
+
+	return []deliveryEvent{event}, nil
+}
+
+// parseSinchDeliveryEvents returns the final status of a batch of a Sinch
+// recipient delivery report, as each batch is sent to a single recipient.
+func parseSinchDeliveryEvents(body []byte) ([]deliveryEvent, error) {
+	var report sinchDeliveryReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		return nil, err
+	}
+
+	event := deliveryEvent{MessageID: report.BatchID}
+
+	switch report.Status {
+	case "Delivered":
+		event.Status = models.MessageDeliveryDelivered
+	case "Failed", "Rejected", "Expired", "Aborted":
+		event.Status = models.MessageDeliveryFailed
+		event.Details = fmt.Sprintf("%s: error code %d", report.Status, report.Code)
+	default:
+		return nil, nil
+	}
+
+	return []deliveryEvent{event}, nil
+}
+
+// parseDeliveryEvents returns the delivery events of the webhook of the
+// provider, or the URL to confirm the SNS subscription of SES with.
+func parseDeliveryEvents(provider string, r *http.Request, body []byte) ([]deliveryEvent, string, error) {
+	// receipts sent as forms can also be sent as query parameters
+	values := r.URL.Query()
+	if form, err := url.ParseQuery(string(body)); err == nil && !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		for key, value := range form {
+			values[key] = value
+		}
+	}
+
+	var events []deliveryEvent
+	var err error
+
+	switch provider {
+	case "ses":
+		return parseSESDeliveryEvents(body)
+	case "sendgrid":
+		events, err = parseSendGridDeliveryEvents(body)
+	case "postmark":
+		events, err = parsePostmarkDeliveryEvents(body)
+	case "mailgun":
+		events, err = parseMailgunDeliveryEvents(body)
+	case "twilio":
+		events, err = parseTwilioDeliveryEvents(values)
+	case "vonage":
+		events, err = parseVonageDeliveryEvents(body, values)
+	case "messagebird":
+		events, err = parseMessagebirdDeliveryEvents(values)
+	case "sinch":
+		events, err = parseSinchDeliveryEvents(body)
+	}
+
+	return events, "", err
+}
+
+// DeliveryWebhook ingests the delivery receipts of the email and SMS
+// providers, and updates the delivery status of the messages they refer
+// to. Webhooks authenticate with the webhook secret as the password of
+// basic authentication, which all the providers support in the webhook URL.
+func (a *API) DeliveryWebhook(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+
+	if _, password, ok := r.BasicAuth(); !ok || subtle.ConstantTimeCompare([]byte(password), []byte(config.DeliveryTracking.WebhookSecret)) != 1 {
+		return httpError(http.StatusUnauthorized, ErrorCodeNoAuthorization, "Invalid webhook credentials")
+	}
+
+	provider := chi.URLParam(r, "provider")
+	channel, ok := deliveryChannels[provider]
+	if !ok {
+		return notFoundError(ErrorCodeValidationFailed, "Provider %q has no delivery webhook", provider)
+	}
+
+	body, err := getBodyBytes(r)
+	if err != nil {
+		return internalServerError("Could not read body into byte slice").WithInternalError(err)
+	}
+
+	events, subscribeURL, err := parseDeliveryEvents(provider, r, body)
+	if err != nil {
+		return badRequestError(ErrorCodeBadJSON, "Could not parse the %s webhook: %v", provider, err)
+	}
+
+	if subscribeURL != "" {
+		if err := confirmSNSSubscription(subscribeURL); err != nil {
+			return badRequestError(ErrorCodeValidationFailed, "Unable to confirm the SNS subscription").WithInternalError(err)
+		}
+	}
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		for _, event := range events {
+			if event.MessageID == "" {
+				continue
+			}
+
+			delivery, err := models.UpdateMessageDeliveryStatus(tx, channel, event.MessageID, event.Status, event.Details)
+			if err != nil {
+				return err
+			}
+			if delivery == nil {
+				continue
+			}
+
+			messageDeliveryCounter.Add(ctx, 1, metric.WithAttributeSet(attribute.NewSet(attribute.String("channel", channel), attribute.String("provider", provider), attribute.String("status", delivery.Status))))
+
+			logrus.WithFields(logrus.Fields{
+				"channel":             channel,
+				"provider":            provider,
+				"provider_message_id": delivery.ProviderMessageID,
+				"status":              delivery.Status,
+			}).Info("Message delivery status updated")
+
+			if delivery.UserID == nil {
+				continue
+			}
+
+			user, err := models.FindUserByID(tx, *delivery.UserID)
+			if err != nil {
+				if models.IsNotFoundError(err) {
+					continue
+				}
+				return err
+			}
+
+			action := models.MessageDeliveredAction
+			if delivery.Status == models.MessageDeliveryFailed {
+				action = models.MessageDeliveryFailedAction
+			}
+
+			if err := models.NewAuditLogEntry(r, tx, user, action, "", map[string]interface{}{
+				"channel":             delivery.Channel,
+				"provider":            delivery.Provider,
+				"provider_message_id": delivery.ProviderMessageID,
+				"message_type":        delivery.MessageType,
+				"details":             delivery.Details,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return internalServerError("Database error updating message deliveries").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+// recordMessageDelivery records a message the provider accepted, so its
+// delivery status is updated by the provider's webhook. Failing to record
+// it doesn't fail sending the message, which was already sent.
+func (a *API) recordMessageDelivery(channel, provider, providerMessageID, recipient string, userID *uuid.UUID, messageType string) {
+	if !a.config.DeliveryTracking.Enabled || providerMessageID == "" {
+		return
+	}
+
+	delivery := models.NewMessageDelivery(channel, provider, providerMessageID, recipient, userID, messageType)
+	if err := a.db.Create(delivery); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"channel":             channel,
+			"provider":            provider,
+			"provider_message_id": providerMessageID,
+		}).Warn("Unable to record message delivery")
+	}
+}
+
+// recordEmailDelivery records an email the mail provider accepted, with the
+// user the address belongs to, if any.
+func (a *API) recordEmailDelivery(emailType, to, messageID string) {
+	var userID *uuid.UUID
+	if user, err := models.FindUserByEmailAndAudience(a.db, to, a.config.JWT.Aud); err == nil {
+		userID = &user.ID
+	}
+
+	a.recordMessageDelivery(models.OutboxChannelEmail, a.config.Mailer.Provider, messageID, to, userID, emailType)
+}
+
+type AdminListMessageDeliveriesResponse struct {
+	Deliveries []*models.MessageDelivery `json:"deliveries"`
+}
+
+// adminMessageDeliveriesList lists the deliveries of the messages sent, the
+// most recent first. They can be filtered with the status, channel,
+// recipient and user_id query parameters.
+func (a *API) adminMessageDeliveriesList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	query := r.URL.Query()
+
+	status := query.Get("status")
+	switch status {
+	case "", models.MessageDeliverySent, models.MessageDeliveryDelivered, models.MessageDeliveryFailed:
+	default:
+		return badRequestError(ErrorCodeValidationFailed, "status must be either %s, %s or %s", models.MessageDeliverySent, models.MessageDeliveryDelivered, models.MessageDeliveryFailed)
+	}
+
+	channel := query.Get("channel")
+	if channel != "" && channel != models.OutboxChannelEmail && channel != models.OutboxChannelSMS {
+		return badRequestError(ErrorCodeValidationFailed, "channel must be either %s or %s", models.OutboxChannelEmail, models.OutboxChannelSMS)
+	}
+
+	var userID *uuid.UUID
+	if value := query.Get("user_id"); value != "" {
+		id, err := uuid.FromString(value)
+		if err != nil {
+			return badRequestError(ErrorCodeValidationFailed, "user_id must be an UUID")
+		}
+		userID = &id
+	}
+
+	pageParams, err := paginate(r)
+	if err != nil {
+		return badRequestError(ErrorCodeValidationFailed, "Bad Pagination Parameters: %v", err).WithInternalError(err)
+	}
+
+	deliveries, err := models.FindMessageDeliveries(db, status, channel, query.Get("recipient"), userID, pageParams)
+	if err != nil {
+		return internalServerError("Database error finding message deliveries").WithInternalError(err)
+	}
+	addPaginationHeaders(w, r, pageParams)
+
+	return sendJSON(w, http.StatusOK, AdminListMessageDeliveriesResponse{
+		Deliveries: deliveries,
+	})
+}
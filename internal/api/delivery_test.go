@@ -0,0 +1,154 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/models"
+)
+
+func TestParseSESDeliveryEvents(t *testing.T) {
+	notification, err := json.Marshal(map[string]interface{}{
+		"notificationType": "Bounce",
+		"mail":             map[string]interface{}{"messageId": "message-id"},
+		"bounce": map[string]interface{}{
+			"bounceType": "Permanent",
+			"bouncedRecipients": []map[string]interface{}{
+				{"emailAddress": "user@example.com", "diagnosticCode": "smtp; 550 5.1.1 user unknown"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	// notifications are wrapped in SNS messages
+	body, err := json.Marshal(map[string]interface{}{
+		"Type":    "Notification",
+		"Message": string(notification),
+	})
+	require.NoError(t, err)
+
+	events, subscribeURL, err := parseSESDeliveryEvents(body)
+	require.NoError(t, err)
+	assert.Empty(t, subscribeURL)
+	assert.Equal(t, []deliveryEvent{{
+		MessageID: "message-id",
+		Status:    models.MessageDeliveryFailed,
+		Details:   "Permanent: smtp; 550 5.1.1 user unknown",
+	}}, events)
+
+	// or received as is with raw message delivery
+	events, _, err = parseSESDeliveryEvents([]byte(`{"eventType":"Delivery","mail":{"messageId":"message-id"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, []deliveryEvent{{MessageID: "message-id", Status: models.MessageDeliveryDelivered}}, events)
+
+	_, subscribeURL, err = parseSESDeliveryEvents([]byte(`{"Type":"SubscriptionConfirmation","SubscribeURL":"https://sns.us-east-1.amazonaws.com/confirm"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "https://sns.us-east-1.amazonaws.com/confirm", subscribeURL)
+}
+
+func TestParseDeliveryEvents(t *testing.T) {
+	cases := []struct {
+		provider    string
+		contentType string
+		query       string
+		body        string
+		expected    []deliveryEvent
+	}{
+		{
+			provider:    "sendgrid",
+			contentType: "application/json",
+			body:        `[{"sg_message_id":"message-id.filter0001","event":"delivered"},{"sg_message_id":"other-id.filter0002","event":"bounce","reason":"550 5.1.1 user unknown"},{"sg_message_id":"message-id.filter0001","event":"open"}]`,
+			expected: []deliveryEvent{
+				{MessageID: "message-id", Status: models.MessageDeliveryDelivered},
+				{MessageID: "other-id", Status: models.MessageDeliveryFailed, Details: "550 5.1.1 user unknown"},
+			},
+		},
+		{
+			provider:    "postmark",
+			contentType: "application/json",
+			body:        `{"RecordType":"Bounce","MessageID":"message-id","Type":"HardBounce","Description":"The server was unable to deliver your message"}`,
+			expected: []deliveryEvent{
+				{MessageID: "message-id", Status: models.MessageDeliveryFailed, Details: "HardBounce: The server was unable to deliver your message"},
+			},
+		},
+		{
+			provider:    "mailgun",
+			contentType: "application/json",
+			body:        `{"event-data":{"event":"delivered","message":{"headers":{"message-id":"message-id@mg.example.com"}}}}`,
+			expected: []deliveryEvent{
+				{MessageID: "message-id@mg.example.com", Status: models.MessageDeliveryDelivered},
+			},
+		},
+		{
+			// temporary failures are retried
+			provider:    "mailgun",
+			contentType: "application/json",
+			body:        `{"event-data":{"event":"failed","severity":"temporary","message":{"headers":{"message-id":"message-id@mg.example.com"}}}}`,
+		},
+		{
+			provider:    "twilio",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "MessageSid=SM123&MessageStatus=undelivered&ErrorCode=30003",
+			expected: []deliveryEvent{
+				{MessageID: "SM123", Status: models.MessageDeliveryFailed, Details: "error code 30003"},
+			},
+		},
+		{
+			provider:    "twilio",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "MessageSid=SM123&MessageStatus=sent",
+		},
+		{
+			provider:    "vonage",
+			contentType: "application/json",
+			body:        `{"messageId":"message-id","status":"delivered","err-code":"0"}`,
+			expected: []deliveryEvent{
+				{MessageID: "message-id", Status: models.MessageDeliveryDelivered},
+			},
+		},
+		{
+			provider:    "vonage",
+			contentType: "application/json",
+			body:        `{"message_uuid":"message-uuid","status":"rejected","error":{"title":"Throttled"}}`,
+			expected: []deliveryEvent{
+				{MessageID: "message-uuid", Status: models.MessageDeliveryFailed, Details: "rejected: Throttled"},
+			},
+		},
+		{
+			provider: "vonage",
+			query:    "messageId=message-id&status=failed&err-code=6",
+			expected: []deliveryEvent{
+				{MessageID: "message-id", Status: models.MessageDeliveryFailed, Details: "failed: error code 6"},
+			},
+		},
+		{
+			provider: "messagebird",
+			query:    "id=message-id&status=delivered",
+			expected: []deliveryEvent{
+				{MessageID: "message-id", Status: models.MessageDeliveryDelivered},
+			},
+		},
+		{
+			provider:    "sinch",
+			contentType: "application/json",
+			body:        `{"type":"recipient_delivery_report_sms","batch_id":"batch-id","status":"Failed","code":402}`,
+			expected: []deliveryEvent{
+				{MessageID: "batch-id", Status: models.MessageDeliveryFailed, Details: "Failed: error code 402"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest("POST", "/webhooks/delivery/"+c.provider+"?"+c.query, strings.NewReader(c.body))
+		r.Header.Set("Content-Type", c.contentType)
+
+		events, subscribeURL, err := parseDeliveryEvents(c.provider, r, []byte(c.body))
+		require.NoError(t, err, c.body)
+		assert.Empty(t, subscribeURL)
+		assert.Equal(t, c.expected, events, c.provider+" "+c.body+c.query)
+	}
+}
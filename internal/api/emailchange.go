@@ -0,0 +1,136 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/hooks"
+	mail "github.com/supabase/auth/internal/mailer"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+	"github.com/supabase/auth/internal/utilities"
+)
+
+const emailChangeUndoneMessage = "Email change undone. Please sign in again and review the security of your account"
+
+// notifyEmailChanged notifies the old email address of the user that it was
+// changed, with a link to undo the change valid for the configured undo
+// expiry.
+func (a *API) notifyEmailChanged(r *http.Request, tx *storage.Connection, user *models.User, oldEmail string) error {
+	config := a.config
+
+	if !config.Mailer.EmailChange.NotifyOld || oldEmail == "" || oldEmail == user.GetEmail() {
+		return nil
+	}
+
+	undo := models.NewEmailChangeUndo(user, oldEmail, crypto.SecureToken(), time.Now().Add(config.Mailer.EmailChange.UndoExpiry))
+	if err := tx.Create(undo); err != nil {
+		return internalServerError("Error notifying email change").WithInternalError(err)
+	}
+
+	if config.Hook.SendEmail.Enabled {
+		input := hooks.SendEmailInput{
+			User: user,
+			EmailData: mail.EmailData{
+				EmailActionType: mail.EmailChangedNotification,
+				RedirectTo:      utilities.GetReferrer(r, config),
+				SiteURL:         getExternalHost(r.Context()).String(),
+				TokenHash:       undo.TokenHash,
+				OldEmail:        oldEmail,
+			},
+		}
+		output := hooks.SendEmailOutput{}
+		return a.invokeHook(tx, r, &input, &output)
+	}
+
+	if err := a.Mailer().EmailChangedMail(r, user, oldEmail, undo.TokenHash, getExternalHost(r.Context())); err != nil {
+		return internalServerError("Error sending email change notification").WithInternalError(err)
+	}
+
+	return nil
+}
+
+// UndoEmailChange restores the old email address of a user with the link
+// sent to it after the change. All sessions of the user are revoked, as the
+// change may have been made by someone who took over the account.
+func (a *API) UndoEmailChange(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+
+	tokenHash := r.FormValue("token_hash")
+	redirectTo := utilities.GetReferrer(r, config)
+
+	err := db.Transaction(func(tx *storage.Connection) error {
+		if tokenHash == "" {
+			return badRequestError(ErrorCodeValidationFailed, "Undoing an email change requires a token hash")
+		}
+
+		undo, terr := models.FindEmailChangeUndoByTokenHash(tx, tokenHash)
+		if terr != nil {
+			if models.IsNotFoundError(terr) {
+				return forbiddenError(ErrorCodeOTPExpired, "Email change undo link is invalid or has expired")
+			}
+			return internalServerError("Database error finding email change undo").WithInternalError(terr)
+		}
+
+		if undo.IsExpired(time.Now()) {
+			return forbiddenError(ErrorCodeOTPExpired, "Email change undo link is invalid or has expired")
+		}
+
+		user, terr := models.FindUserByID(tx, undo.UserID)
+		if terr != nil {
+			return internalServerError("Database error finding user").WithInternalError(terr)
+		}
+
+		if duplicateUser, terr := models.IsDuplicatedEmail(tx, undo.OldEmail, user.Aud, user); terr != nil {
+			return internalServerError("Database error checking email").WithInternalError(terr)
+		} else if duplicateUser != nil {
+			return unprocessableEntityError(ErrorCodeEmailExists, "The old email address is used by another user")
+		}
+
+		// restoring the old address reuses the confirmation of an email
+		// change, which cancels any change in progress
+		user.EmailChange = undo.OldEmail
+		if terr := user.ConfirmEmailChange(tx, zeroConfirmation); terr != nil {
+			return internalServerError("Error undoing email change").WithInternalError(terr)
+		}
+
+		if terr := models.ClearEmailChangeUndosForUser(tx, user.ID); terr != nil {
+			return internalServerError("Error undoing email change").WithInternalError(terr)
+		}
+
+		if terr := models.Logout(tx, user.ID); terr != nil {
+			return internalServerError("Error revoking sessions").WithInternalError(terr)
+		}
+
+		if terr := models.NewAuditLogEntry(r, tx, user, models.EmailChangeUndoneAction, "", map[string]interface{}{
+			"old_email": undo.NewEmail,
+			"new_email": undo.OldEmail,
+		}); terr != nil {
+			return internalServerError("Error recording audit log entry").WithInternalError(terr)
+		}
+
+		return nil
+	})
+
+	rurl := ""
+	if err != nil {
+		var herr *HTTPError
+		if !errors.As(err, &herr) {
+			return err
+		}
+
+		rurl, err = a.prepErrorRedirectURL(herr, r, redirectTo, models.ImplicitFlow)
+	} else {
+		rurl, err = a.prepRedirectURL(emailChangeUndoneMessage, redirectTo, models.ImplicitFlow)
+	}
+	if err != nil {
+		return err
+	}
+
+	http.Redirect(w, r, rurl, http.StatusSeeOther)
+	return nil
+}
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+const (
+	// blockedDomainsFetchTimeout limits how long fetching the blocked
+	// domains from a URL can take.
+	blockedDomainsFetchTimeout = 10 * time.Second
+
+	// blockedDomainsRetryInterval is how long the last good list of blocked
+	// domains is used after it could not be reloaded, before trying again.
+	blockedDomainsRetryInterval = 10 * time.Second
+
+	// blockedDomainsMaxSize limits the size of the list of blocked domains.
+	blockedDomainsMaxSize = 16 << 20
+)
+
+// blockedDomains is the list of domains loaded from the blocked domains
+// source, such as a list of disposable email domains. It's reloaded once
+// expired, so the list can be updated without a restart. When it can't be
+// reloaded the last good list is used.
+type blockedDomains struct {
+	mutex     sync.Mutex
+	source    string
+	domains   map[string]bool
+	expiresAt time.Time
+
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+func newBlockedDomains() *blockedDomains {
+	return &blockedDomains{
+		httpClient: &http.Client{
+			Timeout: blockedDomainsFetchTimeout,
+		},
+		now: time.Now,
+	}
+}
+
+// get returns the domains of the source, loading them when they're not
+// loaded or expired.
+func (b *blockedDomains) get(source string, ttl time.Duration) map[string]bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.source == source && b.now().Before(b.expiresAt) {
+		return b.domains
+	}
+
+	domains, err := b.load(source)
+	if err != nil {
+		logrus.WithError(err).WithField("source", source).Warn("Unable to load the blocked email domains, using the last good list")
+
+		if b.source != source {
+			b.source = source
+			b.domains = nil
+		}
+		b.expiresAt = b.now().Add(blockedDomainsRetryInterval)
+
+		return b.domains
+	}
+
+	b.source = source
+	b.domains = domains
+	b.expiresAt = b.now().Add(ttl)
+
+	return b.domains
+}
+
+// load reads the domains from the file or URL of the source, one per line.
+// Empty lines and lines starting with # are ignored.
+func (b *blockedDomains) load(source string) (map[string]bool, error) {
+	var body []byte
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		resp, err := b.httpClient.Get(source)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("blocked domains responded with HTTP status %d", resp.StatusCode)
+		}
+
+		if body, err = io.ReadAll(io.LimitReader(resp.Body, blockedDomainsMaxSize+1)); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if body, err = os.ReadFile(strings.TrimPrefix(source, "file://")); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(body) > blockedDomainsMaxSize {
+		return nil, errors.New("blocked domains list is too large")
+	}
+
+	domains := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		domains[strings.ToLower(line)] = true
+	}
+
+	return domains, scanner.Err()
+}
+
+// isBlockedDomain returns whether the domain, or a domain it's a subdomain
+// of, is in the configured or loaded blocked domains.
+func (a *API) isBlockedDomain(domain string) bool {
+	config := &a.config.External.Email
+
+	var loaded map[string]bool
+	if config.BlockedDomainsSource != "" {
+		loaded = a.blockedDomains.get(config.BlockedDomainsSource, config.BlockedDomainsRefreshInterval)
+	}
+
+	for domain != "" {
+		if loaded[domain] {
+			return true
+		}
+
+		for _, blocked := range config.BlockedDomains {
+			if strings.EqualFold(domain, blocked) {
+				return true
+			}
+		}
+
+		_, domain, _ = strings.Cut(domain, ".")
+	}
+
+	return false
+}
+
+// validateEmailDomain rejects emails of blocked domains, which can't be
+// signed up with or changed to.
+func (a *API) validateEmailDomain(email string) error {
+	config := &a.config.External.Email
+	if len(config.BlockedDomains) == 0 && config.BlockedDomainsSource == "" {
+		return nil
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return nil
+	}
+
+	if a.isBlockedDomain(strings.ToLower(email[at+1:])) {
+		return badRequestError(ErrorCodeEmailDomainBlocked, "Email addresses of this domain are not allowed")
+	}
+
+	return nil
+}
+
+// gmailDomains are the domains of Gmail addresses, which ignore the dots
+// in and the label after the local part.
+var gmailDomains = map[string]bool{
+	"gmail.com":      true,
+	"googlemail.com": true,
+}
+
+// foldGmailAddress returns the local part of a Gmail address without its
+// dots and label, which delivers to the same inbox as the address.
+func foldGmailAddress(email string) (string, bool) {
+	localPart, domain, ok := strings.Cut(strings.ToLower(email), "@")
+	if !ok || !gmailDomains[domain] {
+		return "", false
+	}
+
+	localPart, _, _ = strings.Cut(localPart, "+")
+	return strings.ReplaceAll(localPart, ".", ""), true
+}
+
+// findDuplicateEmail returns a user other than the current user with the
+// email, or with a Gmail address delivered to the same inbox when Gmail
+// addresses are normalized.
+func (a *API) findDuplicateEmail(tx *storage.Connection, email, aud string, currentUser *models.User) (*models.User, error) {
+	user, err := models.IsDuplicatedEmail(tx, email, aud, currentUser)
+	if err != nil || user != nil {
+		return user, err
+	}
+
+	if !a.config.External.Email.NormalizeGmail {
+		return nil, nil
+	}
+
+	if localPart, ok := foldGmailAddress(email); ok {
+		return models.IsDuplicatedGmail(tx, localPart, aud, currentUser)
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+)
+
+func TestFoldGmailAddress(t *testing.T) {
+	cases := []struct {
+		email    string
+		expected string
+		ok       bool
+	}{
+		{email: "jdoe@gmail.com", expected: "jdoe", ok: true},
+		{email: "J.Doe+news@Gmail.com", expected: "jdoe", ok: true},
+		{email: "j.d.o.e@googlemail.com", expected: "jdoe", ok: true},
+		{email: "j.doe+news@example.com", ok: false},
+		{email: "jdoe@mail.gmail.com", ok: false},
+	}
+
+	for _, c := range cases {
+		localPart, ok := foldGmailAddress(c.email)
+		assert.Equal(t, c.ok, ok, c.email)
+		assert.Equal(t, c.expected, localPart, c.email)
+	}
+}
+
+func TestValidateEmailDomain(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "blocked.txt")
+	require.NoError(t, os.WriteFile(source, []byte("# disposable domains\nMailinator.com\n\ntrashmail.net\n"), 0600))
+
+	a := &API{
+		config: &conf.GlobalConfiguration{
+			External: conf.ProviderConfiguration{
+				Email: conf.EmailProviderConfiguration{
+					BlockedDomains:                []string{"Example.org"},
+					BlockedDomainsSource:          source,
+					BlockedDomainsRefreshInterval: time.Hour,
+				},
+			},
+		},
+		blockedDomains: newBlockedDomains(),
+	}
+
+	for _, email := range []string{"user@example.org", "user@mail.example.org", "user@mailinator.com", "user@TrashMail.net"} {
+		assert.Error(t, a.validateEmailDomain(email), email)
+	}
+
+	for _, email := range []string{"user@example.com", "user@notmailinator.com", "user@gmail.com"} {
+		assert.NoError(t, a.validateEmailDomain(email), email)
+	}
+}
+
+func TestBlockedDomainsReload(t *testing.T) {
+	list := "mailinator.com\n"
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, list)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	b := newBlockedDomains()
+	b.now = func() time.Time { return now }
+
+	assert.Equal(t, map[string]bool{"mailinator.com": true}, b.get(server.URL, time.Hour))
+
+	// the list isn't reloaded until it expires
+	list = "trashmail.net\n"
+	assert.Equal(t, map[string]bool{"mailinator.com": true}, b.get(server.URL, time.Hour))
+
+	now = now.Add(time.Hour)
+	assert.Equal(t, map[string]bool{"trashmail.net": true}, b.get(server.URL, time.Hour))
+
+	// the last good list is used when it can't be reloaded
+	now = now.Add(time.Hour)
+	status = http.StatusInternalServerError
+	assert.Equal(t, map[string]bool{"trashmail.net": true}, b.get(server.URL, time.Hour))
+
+	now = now.Add(blockedDomainsRetryInterval)
+	status = http.StatusOK
+	list = "mailinator.com\n"
+	assert.Equal(t, map[string]bool{"mailinator.com": true}, b.get(server.URL, time.Hour))
+}
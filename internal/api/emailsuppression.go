@@ -0,0 +1,366 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+	"github.com/supabase/auth/internal/utilities"
+)
+
+// emailSuppressionEvent is a bounce or complaint of an email address,
+// reported by the webhook of a provider.
+type emailSuppressionEvent struct {
+	Email   string
+	Reason  string
+	Details string
+}
+
+// snsMessage is a message of Amazon SNS, which SES publishes its bounce and
+// complaint notifications with.
+type snsMessage struct {
+	Type         string `json:"Type"`
+	Message      string `json:"Message"`
+	SubscribeURL string `json:"SubscribeURL"`
+}
+
+type sesNotification struct {
+	// NotificationType is set by notifications, and EventType by event
+	// publishing.
+	NotificationType string `json:"notificationType"`
+	EventType        string `json:"eventType"`
+
+	Bounce struct {
+		BounceType        string `json:"bounceType"`
+		BouncedRecipients []struct {
+			EmailAddress   string `json:"emailAddress"`
+			DiagnosticCode string `json:"diagnosticCode"`
+		} `json:"bouncedRecipients"`
+	} `json:"bounce"`
+
+	Complaint struct {
+		ComplaintFeedbackType string `json:"complaintFeedbackType"`
+		ComplainedRecipients  []struct {
+			EmailAddress string `json:"emailAddress"`
+		} `json:"complainedRecipients"`
+	} `json:"complaint"`
+}
+
+type sendGridEvent struct {
+	Email  string `json:"email"`
+	Event  string `json:"event"`
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+type postmarkEvent struct {
+	RecordType  string `json:"RecordType"`
+	Type        string `json:"Type"`
+	Email       string `json:"Email"`
+	Description string `json:"Description"`
+}
+
+// parseSESEvents returns the bounces and complaints of an SES notification,
+// or the URL to confirm the SNS subscription with.
+func parseSESEvents(body []byte) ([]emailSuppressionEvent, string, error) {
+	var message snsMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, "", err
+	}
+
+	payload := body
+	switch message.Type {
+	case "SubscriptionConfirmation":
+		return nil, message.SubscribeURL, nil
+	case "Notification":
+		payload = []byte(message.Message)
+	case "UnsubscribeConfirmation":
+		return nil, "", nil
+	}
+
+	// notifications are received as is with raw message delivery
+	var notification sesNotification
+	if err := json.Unmarshal(payload, &notification); err != nil {
+		return nil, "", err
+	}
+
+	var events []emailSuppressionEvent
+
+	switch notification.NotificationType + notification.EventType {
+	case "Bounce":
+		// transient bounces, like full mailboxes, are not suppressed
+		if notification.Bounce.BounceType != "Permanent" {
+			return nil, "", nil
+		}
+
+		for _, recipient := range notification.Bounce.BouncedRecipients {
+			events = append(events, emailSuppressionEvent{
+				Email:   recipient.EmailAddress,
+				Reason:  models.EmailSuppressionBounce,
+				Details: recipient.DiagnosticCode,
+			})
+		}
+
+	case "Complaint":
+		for _, recipient := range notification.Complaint.ComplainedRecipients {
+			events = append(events, emailSuppressionEvent{
+				Email:   recipient.EmailAddress,
+				Reason:  models.EmailSuppressionComplaint,
+				Details: notification.Complaint.ComplaintFeedbackType,
+			})
+		}
+	}
+
+	return events, "", nil
+}
+
+// parseSendGridEvents returns the bounces and spam reports of the events of
+// a SendGrid event webhook.
+func parseSendGridEvents(body []byte) ([]emailSuppressionEvent, error) {
+	var sendGridEvents []sendGridEvent
+	if err := json.Unmarshal(body, &sendGridEvents); err != nil {
+		return nil, err
+	}
+
+	var events []emailSuppressionEvent
+	for _, event := range sendGridEvents {
+		switch event.Event {
+		case "bounce":
+			// blocked messages are temporary failures
+			if event.Type == "blocked" {
+				continue
+			}
+
+			events = append(events, emailSuppressionEvent{
+				Email:   event.Email,
+				Reason:  models.EmailSuppressionBounce,
+				Details: event.Reason,
+			})
+
+		case "spamreport":
+			events = append(events, emailSuppressionEvent{
+				Email:  event.Email,
+				Reason: models.EmailSuppressionComplaint,
+			})
+		}
+	}
+
+	return events, nil
+}
+
+// parsePostmarkEvents returns the hard bounce or spam complaint of a
+// Postmark webhook.
+func parsePostmarkEvents(body []byte) ([]emailSuppressionEvent, error) {
+	var event postmarkEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+
+	switch event.RecordType {
+	case "Bounce":
+		if event.Type != "HardBounce" && event.Type != "BadEmailAddress" {
+			return nil, nil
+		}
+
+		return []emailSuppressionEvent{{
+			Email:   event.Email,
+			Reason:  models.EmailSuppressionBounce,
+			Details: event.Description,
+		}}, nil
+
+	case "SpamComplaint":
+		return []emailSuppressionEvent{{
+			Email:  event.Email,
+			Reason: models.EmailSuppressionComplaint,
+		}}, nil
+	}
+
+	return nil, nil
+}
+
+// confirmSNSSubscription confirms the subscription of the webhook to the SNS
+// topic SES publishes to.
+func confirmSNSSubscription(subscribeURL string) error {
+	u, err := url.Parse(subscribeURL)
+	if err != nil {
+		return err
+	}
+
+	// only SNS is called, so the webhook can't be used to make requests
+	// elsewhere
+	if u.Scheme != "https" || !strings.HasPrefix(u.Hostname(), "sns.") || !strings.HasSuffix(u.Hostname(), ".amazonaws.com") {
+		return fmt.Errorf("subscribe URL %q is not an Amazon SNS URL", subscribeURL)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	res, err := client.Get(u.String())
+	if err != nil {
+		return err
+	}
+	defer utilities.SafeClose(res.Body)
+
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected response status %d confirming the SNS subscription", res.StatusCode)
+	}
+
+	return nil
+}
+
+// EmailSuppressionWebhook ingests the bounce and complaint webhooks of SES,
+// SendGrid and Postmark, and adds the addresses to the suppression list.
+// Webhooks authenticate with the webhook secret as the password of basic
+// authentication, which all the providers support in the webhook URL.
+func (a *API) EmailSuppressionWebhook(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+
+	if _, password, ok := r.BasicAuth(); !ok || subtle.ConstantTimeCompare([]byte(password), []byte(config.Mailer.Suppression.WebhookSecret)) != 1 {
+		return httpError(http.StatusUnauthorized, ErrorCodeNoAuthorization, "Invalid webhook credentials")
+	}
+
+	body, err := getBodyBytes(r)
+	if err != nil {
+		return internalServerError("Could not read body into byte slice").WithInternalError(err)
+	}
+
+	provider := chi.URLParam(r, "provider")
+
+	var events []emailSuppressionEvent
+	switch provider {
+	case "ses":
+		var subscribeURL string
+		events, subscribeURL, err = parseSESEvents(body)
+		if err == nil && subscribeURL != "" {
+			if err := confirmSNSSubscription(subscribeURL); err != nil {
+				return badRequestError(ErrorCodeValidationFailed, "Unable to confirm the SNS subscription").WithInternalError(err)
+			}
+		}
+	case "sendgrid":
+		events, err = parseSendGridEvents(body)
+	case "postmark":
+		events, err = parsePostmarkEvents(body)
+	default:
+		return notFoundError(ErrorCodeValidationFailed, "Email provider %q has no suppression webhook", provider)
+	}
+	if err != nil {
+		return badRequestError(ErrorCodeBadJSON, "Could not parse the %s webhook: %v", provider, err)
+	}
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		for _, event := range events {
+			if event.Email == "" {
+				continue
+			}
+
+			if err := models.SuppressEmail(tx, event.Email, event.Reason, provider, event.Details); err != nil {
+				return err
+			}
+
+			logrus.WithFields(logrus.Fields{
+				"provider": provider,
+				"reason":   event.Reason,
+			}).Info("Email address suppressed")
+		}
+		return nil
+	}); err != nil {
+		return internalServerError("Database error suppressing emails").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+type AdminEmailSuppressionParams struct {
+	Email   string `json:"email"`
+	Details string `json:"details"`
+}
+
+type AdminListEmailSuppressionsResponse struct {
+	Suppressions []*models.EmailSuppression `json:"suppressions"`
+}
+
+// adminEmailSuppressionsList lists the suppressed email addresses, the most
+// recent first. They can be filtered with the reason query parameter.
+func (a *API) adminEmailSuppressionsList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	reason := r.URL.Query().Get("reason")
+	switch reason {
+	case "", models.EmailSuppressionBounce, models.EmailSuppressionComplaint, models.EmailSuppressionManual:
+	default:
+		return badRequestError(ErrorCodeValidationFailed, "reason must be either %s, %s or %s", models.EmailSuppressionBounce, models.EmailSuppressionComplaint, models.EmailSuppressionManual)
+	}
+
+	pageParams, err := paginate(r)
+	if err != nil {
+		return badRequestError(ErrorCodeValidationFailed, "Bad Pagination Parameters: %v", err).WithInternalError(err)
+	}
+
+	suppressions, err := models.FindEmailSuppressions(db, reason, pageParams)
+	if err != nil {
+		return internalServerError("Database error finding email suppressions").WithInternalError(err)
+	}
+	addPaginationHeaders(w, r, pageParams)
+
+	return sendJSON(w, http.StatusOK, AdminListEmailSuppressionsResponse{
+		Suppressions: suppressions,
+	})
+}
+
+// adminEmailSuppressionCreate suppresses an email address manually.
+func (a *API) adminEmailSuppressionCreate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	params := &AdminEmailSuppressionParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	email, err := a.validateEmail(params.Email)
+	if err != nil {
+		return err
+	}
+
+	if err := models.SuppressEmail(db, email, models.EmailSuppressionManual, "admin", params.Details); err != nil {
+		return internalServerError("Database error suppressing email").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+// adminEmailSuppressionDelete removes an email address from the suppression
+// list, so emails are sent to it again.
+func (a *API) adminEmailSuppressionDelete(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	suppressionID, err := uuid.FromString(chi.URLParam(r, "suppression_id"))
+	if err != nil {
+		return notFoundError(ErrorCodeValidationFailed, "suppression_id must be an UUID")
+	}
+
+	suppression, err := models.FindEmailSuppressionByID(db, suppressionID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return notFoundError(ErrorCodeEmailSuppressionNotFound, "Email suppression not found")
+		}
+		return internalServerError("Database error finding email suppression").WithInternalError(err)
+	}
+
+	if err := db.Destroy(suppression); err != nil {
+		return internalServerError("Database error deleting email suppression").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
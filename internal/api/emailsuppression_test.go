@@ -0,0 +1,198 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+)
+
+type EmailSuppressionTestSuite struct {
+	suite.Suite
+	API    *API
+	Config *conf.GlobalConfiguration
+
+	token string
+}
+
+func TestEmailSuppression(t *testing.T) {
+	api, config, err := setupAPIForTest()
+	require.NoError(t, err)
+
+	ts := &EmailSuppressionTestSuite{
+		API:    api,
+		Config: config,
+	}
+	defer api.db.Close()
+
+	suite.Run(t, ts)
+}
+
+func (ts *EmailSuppressionTestSuite) SetupTest() {
+	models.TruncateAll(ts.API.db)
+
+	ts.Config.Mailer.Suppression = conf.EmailSuppressionConfiguration{
+		Enabled:       true,
+		WebhookSecret: "webhook_secret",
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &AccessTokenClaims{
+		Role: "supabase_admin",
+	}).SignedString([]byte(ts.Config.JWT.Secret))
+	require.NoError(ts.T(), err)
+	ts.token = token
+}
+
+func (ts *EmailSuppressionTestSuite) TearDownTest() {
+	ts.Config.Mailer.Suppression = conf.EmailSuppressionConfiguration{}
+}
+
+func (ts *EmailSuppressionTestSuite) webhook(provider, password string, body interface{}) *httptest.ResponseRecorder {
+	var buffer bytes.Buffer
+	require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(body))
+
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/email/"+provider, &buffer)
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth("auth", password)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	return w
+}
+
+func (ts *EmailSuppressionTestSuite) TestWebhooks() {
+	// SES notifications are wrapped in SNS messages
+	sesBounce, err := json.Marshal(map[string]interface{}{
+		"notificationType": "Bounce",
+		"bounce": map[string]interface{}{
+			"bounceType": "Permanent",
+			"bouncedRecipients": []map[string]interface{}{
+				{"emailAddress": "Bounced@example.com", "diagnosticCode": "smtp; 550 5.1.1 user unknown"},
+			},
+		},
+	})
+	require.NoError(ts.T(), err)
+
+	w := ts.webhook("ses", "webhook_secret", map[string]interface{}{
+		"Type":    "Notification",
+		"Message": string(sesBounce),
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	w = ts.webhook("sendgrid", "webhook_secret", []map[string]interface{}{
+		{"email": "spam@example.com", "event": "spamreport"},
+		{"email": "blocked@example.com", "event": "bounce", "type": "blocked"},
+		{"email": "delivered@example.com", "event": "delivered"},
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	w = ts.webhook("postmark", "webhook_secret", map[string]interface{}{
+		"RecordType":  "Bounce",
+		"Type":        "HardBounce",
+		"Email":       "hard@example.com",
+		"Description": "The server was unable to deliver your message",
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	for email, expected := range map[string]bool{
+		"bounced@example.com":   true,
+		"spam@example.com":      true,
+		"hard@example.com":      true,
+		"blocked@example.com":   false,
+		"delivered@example.com": false,
+	} {
+		suppressed, err := models.IsEmailSuppressed(ts.API.db, email)
+		require.NoError(ts.T(), err)
+		require.Equal(ts.T(), expected, suppressed, email)
+	}
+
+	// webhooks must be authenticated with the secret
+	w = ts.webhook("postmark", "wrong_secret", map[string]interface{}{
+		"RecordType": "SpamComplaint",
+		"Email":      "other@example.com",
+	})
+	require.Equal(ts.T(), http.StatusUnauthorized, w.Code)
+
+	w = ts.webhook("unknown", "webhook_secret", map[string]interface{}{})
+	require.Equal(ts.T(), http.StatusNotFound, w.Code)
+}
+
+func (ts *EmailSuppressionTestSuite) TestAdmin() {
+	var buffer bytes.Buffer
+	require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+		"email":   "manual@example.com",
+		"details": "Requested by the user",
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/email_suppressions", &buffer)
+	req.Header.Set("Authorization", "Bearer "+ts.token)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	require.NoError(ts.T(), models.SuppressEmail(ts.API.db, "bounced@example.com", models.EmailSuppressionBounce, "ses", ""))
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/email_suppressions?reason=manual", nil)
+	req.Header.Set("Authorization", "Bearer "+ts.token)
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var list AdminListEmailSuppressionsResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&list))
+	require.Len(ts.T(), list.Suppressions, 1)
+	require.Equal(ts.T(), "manual@example.com", list.Suppressions[0].Email)
+	require.Equal(ts.T(), "admin", list.Suppressions[0].Provider)
+
+	// deleting a suppression sends emails to the address again
+	req = httptest.NewRequest(http.MethodDelete, "/admin/email_suppressions/"+list.Suppressions[0].ID.String(), nil)
+	req.Header.Set("Authorization", "Bearer "+ts.token)
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	suppressed, err := models.IsEmailSuppressed(ts.API.db, "manual@example.com")
+	require.NoError(ts.T(), err)
+	require.False(ts.T(), suppressed)
+
+	req = httptest.NewRequest(http.MethodDelete, "/admin/email_suppressions/"+list.Suppressions[0].ID.String(), nil)
+	req.Header.Set("Authorization", "Bearer "+ts.token)
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusNotFound, w.Code)
+}
+
+func TestParseSESEvents(t *testing.T) {
+	// raw message delivery sends the notification without the SNS message
+	events, subscribeURL, err := parseSESEvents([]byte(`{"eventType":"Complaint","complaint":{"complaintFeedbackType":"abuse","complainedRecipients":[{"emailAddress":"user@example.com"}]}}`))
+	require.NoError(t, err)
+	assert.Empty(t, subscribeURL)
+	assert.Equal(t, []emailSuppressionEvent{{Email: "user@example.com", Reason: models.EmailSuppressionComplaint, Details: "abuse"}}, events)
+
+	// transient bounces aren't suppressed
+	events, _, err = parseSESEvents([]byte(`{"notificationType":"Bounce","bounce":{"bounceType":"Transient","bouncedRecipients":[{"emailAddress":"user@example.com"}]}}`))
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	_, subscribeURL, err = parseSESEvents([]byte(`{"Type":"SubscriptionConfirmation","SubscribeURL":"https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription", subscribeURL)
+}
+
+func TestConfirmSNSSubscriptionOnlyCallsSNS(t *testing.T) {
+	for _, subscribeURL := range []string{
+		"http://sns.us-east-1.amazonaws.com/",
+		"https://example.com/",
+		"https://sns.us-east-1.amazonaws.com.example.com/",
+		"https://s3.amazonaws.com/",
+	} {
+		assert.Error(t, confirmSNSSubscription(subscribeURL), subscribeURL)
+	}
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/supabase/auth/internal/mailer"
+	"github.com/supabase/auth/internal/models"
+)
+
+type AdminEmailTemplateParams struct {
+	Email string                 `json:"email"`
+	Data  map[string]interface{} `json:"data"`
+}
+
+func getEmailTemplateParams(r *http.Request) (*AdminEmailTemplateParams, error) {
+	params := &AdminEmailTemplateParams{}
+
+	body, err := getBodyBytes(r)
+	if err != nil {
+		return nil, internalServerError("Could not read body into byte slice").WithInternalError(err)
+	}
+
+	// the sample data is optional when previewing
+	if len(body) > 0 {
+		if err := retrieveRequestParams(r, params); err != nil {
+			return nil, err
+		}
+	}
+
+	return params, nil
+}
+
+func emailTemplateError(err error) error {
+	if errors.Is(err, mailer.ErrTemplateNotFound) {
+		return notFoundError(ErrorCodeEmailTemplateNotFound, "Email template not found")
+	}
+
+	// the errors of templates are returned to fix them
+	return unprocessableEntityError(ErrorCodeValidationFailed, "Error rendering email template: %v", err)
+}
+
+// adminEmailTemplatePreview renders an email with its template and sample
+// data, without sending it.
+func (a *API) adminEmailTemplatePreview(w http.ResponseWriter, r *http.Request) error {
+	params, err := getEmailTemplateParams(r)
+	if err != nil {
+		return err
+	}
+
+	email, err := a.Mailer().RenderTemplate(r, chi.URLParam(r, "template_type"), params.Data, getExternalHost(r.Context()))
+	if err != nil {
+		return emailTemplateError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, email)
+}
+
+// adminEmailTemplateSendTest sends an email rendered with its template and
+// sample data to an address, to check how it's delivered. Test emails are
+// sent right away rather than queued in the outbox, so that the errors of
+// the mail provider are returned.
+func (a *API) adminEmailTemplateSendTest(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+
+	params, err := getEmailTemplateParams(r)
+	if err != nil {
+		return err
+	}
+
+	address, err := a.validateEmail(params.Email)
+	if err != nil {
+		return err
+	}
+
+	// the mailer silently skips suppressed addresses, which would look
+	// like the test email was sent
+	if config.Mailer.Suppression.Enabled {
+		suppressed, err := models.IsEmailSuppressed(db, address)
+		if err != nil {
+			return internalServerError("Database error checking email suppression").WithInternalError(err)
+		}
+		if suppressed {
+			return unprocessableEntityError(ErrorCodeEmailAddressSuppressed, "Email address is suppressed, emails aren't sent to it until it's removed from the suppressions")
+		}
+	}
+
+	templateType := chi.URLParam(r, "template_type")
+	m := a.newMailer(nil)
+
+	// the template is rendered first, so that its errors aren't mistaken
+	// for errors sending the email
+	if _, err := m.RenderTemplate(r, templateType, params.Data, getExternalHost(r.Context())); err != nil {
+		return emailTemplateError(err)
+	}
+
+	if err := m.SendTestMail(r, templateType, address, params.Data, getExternalHost(r.Context())); err != nil {
+		return internalServerError("Error sending test email").WithInternalError(err)
+	}
+
+	if err := models.NewAuditLogEntry(r, db, getAdminUser(ctx), models.EmailTemplateTestSentAction, "", map[string]interface{}{
+		"template_type": templateType,
+		"email":         address,
+	}); err != nil {
+		return internalServerError("Error recording audit log entry").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
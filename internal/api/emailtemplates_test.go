@@ -0,0 +1,115 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/mailer"
+	"github.com/supabase/auth/internal/models"
+)
+
+type EmailTemplatesTestSuite struct {
+	suite.Suite
+	API    *API
+	Config *conf.GlobalConfiguration
+
+	token string
+}
+
+func TestEmailTemplates(t *testing.T) {
+	api, config, err := setupAPIForTest()
+	require.NoError(t, err)
+
+	ts := &EmailTemplatesTestSuite{
+		API:    api,
+		Config: config,
+	}
+	defer api.db.Close()
+
+	suite.Run(t, ts)
+}
+
+func (ts *EmailTemplatesTestSuite) SetupTest() {
+	models.TruncateAll(ts.API.db)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &AccessTokenClaims{
+		Role: "supabase_admin",
+	}).SignedString([]byte(ts.Config.JWT.Secret))
+	require.NoError(ts.T(), err)
+	ts.token = token
+}
+
+func (ts *EmailTemplatesTestSuite) request(path string, body interface{}) *httptest.ResponseRecorder {
+	var buffer bytes.Buffer
+	require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(body))
+
+	req := httptest.NewRequest(http.MethodPost, path, &buffer)
+	req.Header.Set("Authorization", "Bearer "+ts.token)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	return w
+}
+
+func (ts *EmailTemplatesTestSuite) TestPreview() {
+	w := ts.request("/admin/templates/recovery/preview", map[string]interface{}{
+		"data": map[string]interface{}{
+			"Token": "987654",
+		},
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var email mailer.RenderedEmail
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&email))
+	assert.Equal(ts.T(), "Reset Your Password", email.Subject)
+	assert.Contains(ts.T(), email.Body, "987654")
+
+	w = ts.request("/admin/templates/unknown/preview", map[string]interface{}{})
+	assert.Equal(ts.T(), http.StatusNotFound, w.Code)
+}
+
+func (ts *EmailTemplatesTestSuite) TestPreviewTemplateError() {
+	ts.Config.Mailer.Subjects.Recovery = "{{ .Missing"
+	defer func() { ts.Config.Mailer.Subjects.Recovery = "" }()
+
+	w := ts.request("/admin/templates/recovery/preview", map[string]interface{}{})
+	assert.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+}
+
+func (ts *EmailTemplatesTestSuite) TestSendTest() {
+	w := ts.request("/admin/templates/magic_link/send-test", map[string]interface{}{
+		"email": "admin@example.com",
+	})
+	assert.Equal(ts.T(), http.StatusOK, w.Code)
+
+	w = ts.request("/admin/templates/magic_link/send-test", map[string]interface{}{})
+	assert.Equal(ts.T(), http.StatusBadRequest, w.Code)
+
+	entries, err := models.FindAuditLogEntries(ts.API.db, nil, "", models.AuditLogFilter{Actions: []string{string(models.EmailTemplateTestSentAction)}}, nil)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), entries, 1)
+}
+
+func (ts *EmailTemplatesTestSuite) TestSendTestSuppressed() {
+	ts.Config.Mailer.Suppression.Enabled = true
+	defer func() { ts.Config.Mailer.Suppression.Enabled = false }()
+
+	require.NoError(ts.T(), models.SuppressEmail(ts.API.db, "bounced@example.com", models.EmailSuppressionBounce, "smtp", ""))
+
+	w := ts.request("/admin/templates/magic_link/send-test", map[string]interface{}{
+		"email": "bounced@example.com",
+	})
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+
+	var data HTTPError
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	assert.Equal(ts.T(), ErrorCodeEmailAddressSuppressed, data.ErrorCode)
+}
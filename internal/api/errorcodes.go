@@ -57,12 +57,23 @@ const (
 	ErrorCodeSAMLMetadataFetchFailed           ErrorCode = "saml_metadata_fetch_failed"
 	ErrorCodeSAMLIdPAlreadyExists              ErrorCode = "saml_idp_already_exists"
 	ErrorCodeSSODomainAlreadyExists            ErrorCode = "sso_domain_already_exists"
+	ErrorCodeSSODomainNotFound                 ErrorCode = "sso_domain_not_found"
+	ErrorCodeSSODomainVerificationFailed       ErrorCode = "sso_domain_verification_failed"
 	ErrorCodeSAMLEntityIDMismatch              ErrorCode = "saml_entity_id_mismatch"
+	ErrorCodeSAMLSingleLogoutNotEnabled        ErrorCode = "saml_single_logout_not_enabled"
+	ErrorCodeSAMLIdPInitiatedNotAllowed        ErrorCode = "saml_idp_initiated_not_allowed"
+	ErrorCodeSAMLAssertionReplayed             ErrorCode = "saml_assertion_replayed"
+	ErrorCodeSSOOIDCProviderDisabled           ErrorCode = "sso_oidc_provider_disabled"
+	ErrorCodeSSOOIDCIssuerAlreadyExists        ErrorCode = "sso_oidc_issuer_already_exists"
+	ErrorCodeSSOOIDCDiscoveryFailed            ErrorCode = "sso_oidc_discovery_failed"
 	ErrorCodeConflict                          ErrorCode = "conflict"
 	ErrorCodeProviderDisabled                  ErrorCode = "provider_disabled"
 	ErrorCodeUserSSOManaged                    ErrorCode = "user_sso_managed"
 	ErrorCodeReauthenticationNeeded            ErrorCode = "reauthentication_needed"
 	ErrorCodeSamePassword                      ErrorCode = "same_password"
+	ErrorCodePasswordReused                    ErrorCode = "password_reused"
+	ErrorCodePasswordTooRecent                 ErrorCode = "password_too_recent"
+	ErrorCodePasswordExpired                   ErrorCode = "password_expired"
 	ErrorCodeReauthenticationNotValid          ErrorCode = "reauthentication_not_valid"
 	ErrorCodeOTPExpired                        ErrorCode = "otp_expired"
 	ErrorCodeOTPDisabled                       ErrorCode = "otp_disabled"
@@ -82,6 +93,68 @@ const (
 	ErrorCodeMFATOTPEnrollDisabled             ErrorCode = "mfa_totp_enroll_not_enabled"
 	ErrorCodeMFATOTPVerifyDisabled             ErrorCode = "mfa_totp_verify_not_enabled"
 	ErrorCodeMFAVerifiedFactorExists           ErrorCode = "mfa_verified_factor_exists"
+	ErrorCodeKerberosProviderDisabled          ErrorCode = "kerberos_provider_disabled"
+	ErrorCodeProviderTokenNotFound             ErrorCode = "provider_token_not_found"
+	ErrorCodeProviderTokenExpired              ErrorCode = "provider_token_expired"
+	ErrorCodeIdentitySyncMismatch              ErrorCode = "identity_sync_mismatch"
+	ErrorCodeSCIMDisabled                      ErrorCode = "scim_disabled"
+	ErrorCodeOrganizationsDisabled             ErrorCode = "organizations_disabled"
+	ErrorCodeOrganizationCreationDisabled      ErrorCode = "organization_creation_disabled"
+	ErrorCodeOrganizationNotFound              ErrorCode = "organization_not_found"
+	ErrorCodeOrganizationSlugExists            ErrorCode = "organization_slug_exists"
+	ErrorCodeOrganizationMemberNotFound        ErrorCode = "organization_member_not_found"
+	ErrorCodeOrganizationMemberExists          ErrorCode = "organization_member_exists"
+	ErrorCodeOrganizationLastOwner             ErrorCode = "organization_last_owner"
+	ErrorCodeInsufficientOrganizationRole      ErrorCode = "insufficient_organization_role"
+	ErrorCodeOrganizationInvitationNotFound    ErrorCode = "organization_invitation_not_found"
+	ErrorCodeOrganizationInvitationExpired     ErrorCode = "organization_invitation_expired"
+	ErrorCodeHookDeadLetterNotFound            ErrorCode = "hook_dead_letter_not_found"
+	ErrorCodeEmailLinkVerificationDisabled     ErrorCode = "email_link_verification_disabled"
+	ErrorCodeOutboxMessageNotFound             ErrorCode = "outbox_message_not_found"
+	ErrorCodeEmailSuppressionDisabled          ErrorCode = "email_suppression_disabled"
+	ErrorCodeEmailSuppressionNotFound          ErrorCode = "email_suppression_not_found"
+	ErrorCodeEmailAddressSuppressed            ErrorCode = "email_address_suppressed"
+	ErrorCodeEmailTemplateNotFound             ErrorCode = "email_template_not_found"
+	ErrorCodeEmailDomainBlocked                ErrorCode = "email_domain_blocked"
+	ErrorCodeDeliveryTrackingDisabled          ErrorCode = "delivery_tracking_disabled"
+	ErrorCodeShortLinksDisabled                ErrorCode = "short_links_disabled"
+	ErrorCodeUserImportDisabled                ErrorCode = "user_import_disabled"
+	ErrorCodeUserImportJobNotFound             ErrorCode = "user_import_job_not_found"
+	ErrorCodeUserDeletionDisabled              ErrorCode = "user_deletion_disabled"
+	ErrorCodeUserDeletionNotFound              ErrorCode = "user_deletion_not_found"
+	ErrorCodeImpersonationDisabled             ErrorCode = "impersonation_disabled"
+	ErrorCodeImpersonationNotAllowed           ErrorCode = "impersonation_not_allowed"
+	ErrorCodeRolesDisabled                     ErrorCode = "roles_disabled"
+	ErrorCodeRoleNotFound                      ErrorCode = "role_not_found"
+	ErrorCodeRoleExists                        ErrorCode = "role_exists"
+	ErrorCodeAdminCredentialNotFound           ErrorCode = "admin_credential_not_found"
+	ErrorCodeInsufficientAdminScope            ErrorCode = "insufficient_admin_scope"
+	ErrorCodeInvalidMetadata                   ErrorCode = "invalid_metadata"
+	ErrorCodeAccountDeletionDisabled           ErrorCode = "account_deletion_disabled"
+	ErrorCodeAccountDeletionNotFound           ErrorCode = "account_deletion_not_found"
+	ErrorCodeDataExportDisabled                ErrorCode = "data_export_disabled"
+	ErrorCodeDataExportNotFound                ErrorCode = "data_export_not_found"
+	ErrorCodeDataExportNotReady                ErrorCode = "data_export_not_ready"
+	ErrorCodeUsernameProviderDisabled          ErrorCode = "username_provider_disabled"
+	ErrorCodeUsernameExists                    ErrorCode = "username_exists"
+	ErrorCodeUsernameReserved                  ErrorCode = "username_reserved"
+	ErrorCodeSecondaryEmailsDisabled           ErrorCode = "secondary_emails_disabled"
+	ErrorCodeSecondaryEmailNotFound            ErrorCode = "secondary_email_not_found"
+	ErrorCodeSecondaryEmailNotVerified         ErrorCode = "secondary_email_not_verified"
+	ErrorCodeSecondaryEmailLimitReached        ErrorCode = "secondary_email_limit_reached"
+	ErrorCodeSignInHistoryDisabled             ErrorCode = "sign_in_history_disabled"
+	ErrorCodeAccountLocked                     ErrorCode = "account_locked"
+	ErrorCodeAccountLockoutDisabled            ErrorCode = "account_lockout_disabled"
+	ErrorCodeAccountLockoutNotFound            ErrorCode = "account_lockout_not_found"
+	ErrorCodeBulkInviteDisabled                ErrorCode = "bulk_invite_disabled"
+	ErrorCodeBulkInviteNotFound                ErrorCode = "bulk_invite_not_found"
+	ErrorCodeUserNoteNotFound                  ErrorCode = "user_note_not_found"
+	ErrorCodeUserTagNotFound                   ErrorCode = "user_tag_not_found"
+	ErrorCodeConsentDisabled                   ErrorCode = "consent_disabled"
+	ErrorCodeConsentRequired                   ErrorCode = "consent_required"
+	ErrorCodeSessionLimitReached               ErrorCode = "session_limit_reached"
+	ErrorCodeIPAddressBlocked                  ErrorCode = "ip_address_blocked"
+	ErrorCodeProviderAccessDenied              ErrorCode = "provider_access_denied"
 	//#nosec G101 -- Not a secret value.
 	ErrorCodeInvalidCredentials        ErrorCode = "invalid_credentials"
 	ErrorCodeEmailAddressNotAuthorized ErrorCode = "email_address_not_authorized"
@@ -10,6 +10,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/security"
 	"github.com/supabase/auth/internal/utilities"
 )
 
@@ -20,7 +21,7 @@ var (
 	UserExistsError   error = errors.New("user already exists")
 )
 
-const InvalidChannelError = "Invalid channel, supported values are 'sms' or 'whatsapp'"
+const InvalidChannelError = "Invalid channel, supported values are 'sms', 'whatsapp', 'viber' or 'call'"
 
 var oauthErrorMap = map[int]string{
 	http.StatusBadRequest:          "invalid_request",
@@ -153,6 +154,11 @@ func recoverer(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if rvr := recover(); rvr != nil {
+				// aborts of streamed responses are handled by the server
+				if rvr == http.ErrAbortHandler {
+					panic(rvr)
+				}
+
 				logEntry := observability.GetLogEntry(r)
 				if logEntry != nil {
 					logEntry.Panic(rvr, debug.Stack())
@@ -201,13 +207,15 @@ func HandleResponseError(err error, w http.ResponseWriter, r *http.Request) {
 			var output struct {
 				HTTPErrorResponse20240101
 				Payload struct {
-					Reasons []string `json:"reasons,omitempty"`
+					Reasons  []string                   `json:"reasons,omitempty"`
+					Feedback *security.PasswordFeedback `json:"feedback,omitempty"`
 				} `json:"weak_password,omitempty"`
 			}
 
 			output.Code = ErrorCodeWeakPassword
 			output.Message = e.Message
 			output.Payload.Reasons = e.Reasons
+			output.Payload.Feedback = e.Feedback
 
 			if jsonErr := sendJSON(w, http.StatusUnprocessableEntity, output); jsonErr != nil && jsonErr != context.DeadlineExceeded {
 				log.WithError(jsonErr).Warn("Failed to send JSON on ResponseWriter")
@@ -217,7 +225,8 @@ func HandleResponseError(err error, w http.ResponseWriter, r *http.Request) {
 			var output struct {
 				HTTPError
 				Payload struct {
-					Reasons []string `json:"reasons,omitempty"`
+					Reasons  []string                   `json:"reasons,omitempty"`
+					Feedback *security.PasswordFeedback `json:"feedback,omitempty"`
 				} `json:"weak_password,omitempty"`
 			}
 
@@ -225,6 +234,38 @@ func HandleResponseError(err error, w http.ResponseWriter, r *http.Request) {
 			output.ErrorCode = ErrorCodeWeakPassword
 			output.Message = e.Message
 			output.Payload.Reasons = e.Reasons
+			output.Payload.Feedback = e.Feedback
+
+			if jsonErr := sendJSON(w, output.HTTPStatus, output); jsonErr != nil && jsonErr != context.DeadlineExceeded {
+				log.WithError(jsonErr).Warn("Failed to send JSON on ResponseWriter")
+			}
+		}
+
+	case *InvalidMetadataError:
+		if apiVersion.Compare(APIVersion20240101) >= 0 {
+			var output struct {
+				HTTPErrorResponse20240101
+				Payload *InvalidMetadataError `json:"invalid_metadata"`
+			}
+
+			output.Code = ErrorCodeInvalidMetadata
+			output.Message = e.Message
+			output.Payload = e
+
+			if jsonErr := sendJSON(w, http.StatusUnprocessableEntity, output); jsonErr != nil && jsonErr != context.DeadlineExceeded {
+				log.WithError(jsonErr).Warn("Failed to send JSON on ResponseWriter")
+			}
+
+		} else {
+			var output struct {
+				HTTPError
+				Payload *InvalidMetadataError `json:"invalid_metadata"`
+			}
+
+			output.HTTPStatus = http.StatusUnprocessableEntity
+			output.ErrorCode = ErrorCodeInvalidMetadata
+			output.Message = e.Message
+			output.Payload = e
 
 			if jsonErr := sendJSON(w, output.HTTPStatus, output); jsonErr != nil && jsonErr != context.DeadlineExceeded {
 				log.WithError(jsonErr).Warn("Failed to send JSON on ResponseWriter")
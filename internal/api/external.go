@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/fatih/structs"
 	"github.com/gofrs/uuid"
@@ -30,6 +31,8 @@ type ExternalProviderClaims struct {
 	Referrer        string `json:"referrer,omitempty"`
 	FlowStateID     string `json:"flow_state_id"`
 	LinkingTargetID string `json:"linking_target_id,omitempty"`
+	CustomState     string `json:"custom_state,omitempty"`
+	Scopes          string `json:"scopes,omitempty"`
 }
 
 // ExternalProviderRedirect redirects the request to the oauth provider
@@ -53,12 +56,17 @@ func (a *API) GetExternalProviderRedirectURL(w http.ResponseWriter, r *http.Requ
 	scopes := query.Get("scopes")
 	codeChallenge := query.Get("code_challenge")
 	codeChallengeMethod := query.Get("code_challenge_method")
+	customState := query.Get("custom_state")
 
 	p, err := a.Provider(ctx, providerType, scopes)
 	if err != nil {
 		return "", badRequestError(ErrorCodeValidationFailed, "Unsupported provider: %+v", err).WithInternalError(err)
 	}
 
+	if err := validateRequestedScopes(config, providerType, scopes); err != nil {
+		return "", err
+	}
+
 	inviteToken := query.Get("invite_token")
 	if inviteToken != "" {
 		_, userErr := models.FindUserByConfirmationToken(db, inviteToken)
@@ -70,6 +78,10 @@ func (a *API) GetExternalProviderRedirectURL(w http.ResponseWriter, r *http.Requ
 		}
 	}
 
+	if err := validateCustomState(config, customState); err != nil {
+		return "", err
+	}
+
 	redirectURL := utilities.GetReferrer(r, config)
 	log := observability.GetLogEntry(r).Entry
 	log.WithField("provider", providerType).Info("Redirecting to external provider")
@@ -90,7 +102,7 @@ func (a *API) GetExternalProviderRedirectURL(w http.ResponseWriter, r *http.Requ
 	claims := ExternalProviderClaims{
 		AuthMicroserviceClaims: AuthMicroserviceClaims{
 			RegisteredClaims: jwt.RegisteredClaims{
-				ExpiresAt: jwt.NewNumericDate(time.Now().Add(5 * time.Minute)),
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(config.External.StateExpiryDuration)),
 			},
 			SiteURL:    config.SiteURL,
 			InstanceID: uuid.Nil.String(),
@@ -99,6 +111,8 @@ func (a *API) GetExternalProviderRedirectURL(w http.ResponseWriter, r *http.Requ
 		InviteToken: inviteToken,
 		Referrer:    redirectURL,
 		FlowStateID: flowStateID,
+		CustomState: customState,
+		Scopes:      scopes,
 	}
 
 	if linkingTargetUser != nil {
@@ -116,6 +130,7 @@ func (a *API) GetExternalProviderRedirectURL(w http.ResponseWriter, r *http.Requ
 	query.Del("provider")
 	query.Del("code_challenge")
 	query.Del("code_challenge_method")
+	query.Del("custom_state")
 	for key := range query {
 		if key == "workos_provider" {
 			// See https://workos.com/docs/reference/sso/authorize/get
@@ -130,6 +145,18 @@ func (a *API) GetExternalProviderRedirectURL(w http.ResponseWriter, r *http.Requ
 	return authURL, nil
 }
 
+// providersWithoutEmail are the external providers that may not return an
+// email address, users signing in with them are created without one.
+var providersWithoutEmail = map[string]bool{
+	"line":     true,
+	"steam":    true,
+	"telegram": true,
+	"vk":       true,
+	"wechat":   true,
+	"web3":     true,
+	"kerberos": true,
+}
+
 // ExternalProviderCallback handles the callback endpoint in the external oauth provider flow
 func (a *API) ExternalProviderCallback(w http.ResponseWriter, r *http.Request) error {
 	rurl := a.getExternalRedirectURL(r)
@@ -137,6 +164,10 @@ func (a *API) ExternalProviderCallback(w http.ResponseWriter, r *http.Request) e
 	if err != nil {
 		return err
 	}
+	if a.config.External.HostedPages.Enabled {
+		a.hostedPageErrors(a.internalExternalProviderCallback, w, r, u)
+		return nil
+	}
 	redirectErrors(a.internalExternalProviderCallback, w, r, u)
 	return nil
 }
@@ -151,6 +182,9 @@ func (a *API) handleOAuthCallback(r *http.Request) (*OAuthProviderData, error) {
 	case "twitter":
 		// future OAuth1.0 providers will use this method
 		oAuthResponseData, err = a.oAuth1Callback(ctx, providerType)
+	case "steam":
+		// future OpenID 2.0 providers will use this method
+		oAuthResponseData, err = a.openIDCallback(ctx, r, providerType)
 	default:
 		oAuthResponseData, err = a.oAuthCallback(ctx, r, providerType)
 	}
@@ -163,18 +197,20 @@ func (a *API) handleOAuthCallback(r *http.Request) (*OAuthProviderData, error) {
 func (a *API) internalExternalProviderCallback(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	db := a.db.WithContext(ctx)
+	config := a.config
 
 	var grantParams models.GrantParams
 	grantParams.FillGrantParams(r)
 
 	providerType := getExternalProviderType(ctx)
+	grantParams.Provider = providerType
 	data, err := a.handleOAuthCallback(r)
 	if err != nil {
 		return err
 	}
 
 	userData := data.userData
-	if len(userData.Emails) <= 0 {
+	if len(userData.Emails) <= 0 && !providersWithoutEmail[providerType] {
 		return internalServerError("Error getting user email from external provider")
 	}
 	userData.Metadata.EmailVerified = false
@@ -188,6 +224,10 @@ func (a *API) internalExternalProviderCallback(w http.ResponseWriter, r *http.Re
 			userData.Metadata.EmailVerified = email.Verified
 		}
 	}
+	if err := a.applyClaimMapping(providerType, userData); err != nil {
+		return internalServerError("Error mapping claims of external provider").WithInternalError(err)
+	}
+
 	providerAccessToken := data.token
 	providerRefreshToken := data.refreshToken
 
@@ -220,6 +260,16 @@ func (a *API) internalExternalProviderCallback(w http.ResponseWriter, r *http.Re
 				return terr
 			}
 		}
+		if data.scopes != "" {
+			if terr = a.saveIdentityScopes(tx, user, providerType, data); terr != nil {
+				return terr
+			}
+		}
+		if config.External.ProviderTokens.Enabled && providerAccessToken != "" {
+			if terr = a.saveProviderToken(tx, user, providerType, data); terr != nil {
+				return terr
+			}
+		}
 		if flowState != nil {
 			// This means that the callback is using PKCE
 			flowState.ProviderAccessToken = providerAccessToken
@@ -264,6 +314,10 @@ func (a *API) internalExternalProviderCallback(w http.ResponseWriter, r *http.Re
 
 	}
 
+	if config.External.HostedPages.Enabled {
+		return a.renderHostedPage(w, r, http.StatusOK, config.External.HostedPages.InterstitialTemplate, rurl, nil)
+	}
+
 	http.Redirect(w, r, rurl, http.StatusFound)
 	return nil
 }
@@ -279,6 +333,7 @@ func (a *API) createAccountFromExternalIdentity(tx *storage.Connection, r *http.
 	if userData.Metadata != nil {
 		identityData = structs.Map(userData.Metadata)
 	}
+	userMetaData := userMetaDataFromIdentity(identityData, userData)
 
 	decision, terr := models.DetermineAccountLinking(tx, config, userData.Emails, aud, providerType, userData.Metadata.Subject)
 	if terr != nil {
@@ -293,7 +348,7 @@ func (a *API) createAccountFromExternalIdentity(tx *storage.Connection, r *http.
 			return nil, terr
 		}
 
-		if terr = user.UpdateUserMetaData(tx, identityData); terr != nil {
+		if terr = user.UpdateUserMetaData(tx, userMetaData); terr != nil {
 			return nil, terr
 		}
 
@@ -310,7 +365,7 @@ func (a *API) createAccountFromExternalIdentity(tx *storage.Connection, r *http.
 			Provider: providerType,
 			Email:    decision.CandidateEmail.Email,
 			Aud:      aud,
-			Data:     identityData,
+			Data:     userMetaData,
 		}
 
 		isSSOUser := false
@@ -342,7 +397,7 @@ func (a *API) createAccountFromExternalIdentity(tx *storage.Connection, r *http.
 		if terr = tx.UpdateOnly(identity, "identity_data", "last_sign_in_at"); terr != nil {
 			return nil, terr
 		}
-		if terr = user.UpdateUserMetaData(tx, identityData); terr != nil {
+		if terr = user.UpdateUserMetaData(tx, userMetaData); terr != nil {
 			return nil, terr
 		}
 		if terr = user.UpdateAppMetaDataProviders(tx); terr != nil {
@@ -356,6 +411,12 @@ func (a *API) createAccountFromExternalIdentity(tx *storage.Connection, r *http.
 		return nil, internalServerError("Unknown automatic linking decision: %v", decision.Decision)
 	}
 
+	if len(userData.AppMetadata) > 0 {
+		if terr = user.UpdateAppMetaData(tx, userData.AppMetadata); terr != nil {
+			return nil, terr
+		}
+	}
+
 	if user.IsBanned() {
 		return nil, forbiddenError(ErrorCodeUserBanned, "User is banned")
 	}
@@ -368,7 +429,10 @@ func (a *API) createAccountFromExternalIdentity(tx *storage.Connection, r *http.
 		if terr = user.RemoveUnconfirmedIdentities(tx, identity); terr != nil {
 			return nil, internalServerError("Error updating user").WithInternalError(terr)
 		}
-		if decision.CandidateEmail.Verified || config.Mailer.Autoconfirm {
+		// providers that never return an email address have nothing
+		// left to verify, the identity itself is proof of ownership
+		withoutEmail := decision.CandidateEmail.Email == "" && providersWithoutEmail[providerType]
+		if decision.CandidateEmail.Verified || withoutEmail || config.Mailer.Autoconfirm {
 			if terr := models.NewAuditLogEntry(r, tx, user, models.UserSignedUpAction, "", map[string]interface{}{
 				"provider": providerType,
 			}); terr != nil {
@@ -443,7 +507,12 @@ func (a *API) processInvite(r *http.Request, tx *storage.Connection, userData *p
 	if err := user.UpdateAppMetaDataProviders(tx); err != nil {
 		return nil, err
 	}
-	if err := user.UpdateUserMetaData(tx, identityData); err != nil {
+	if len(userData.AppMetadata) > 0 {
+		if err := user.UpdateAppMetaData(tx, userData.AppMetadata); err != nil {
+			return nil, err
+		}
+	}
+	if err := user.UpdateUserMetaData(tx, userMetaDataFromIdentity(identityData, userData)); err != nil {
 		return nil, internalServerError("Database error updating user").WithInternalError(err)
 	}
 
@@ -513,6 +582,12 @@ func (a *API) loadExternalState(ctx context.Context, r *http.Request) (context.C
 	if claims.FlowStateID != "" {
 		ctx = withFlowStateID(ctx, claims.FlowStateID)
 	}
+	if claims.CustomState != "" {
+		ctx = withCustomState(ctx, claims.CustomState)
+	}
+	if claims.Scopes != "" {
+		ctx = withRequestedScopes(ctx, claims.Scopes)
+	}
 	if claims.LinkingTargetID != "" {
 		linkingTargetUserID, err := uuid.FromString(claims.LinkingTargetID)
 		if err != nil {
@@ -537,6 +612,8 @@ func (a *API) Provider(ctx context.Context, name string, scopes string) (provide
 	name = strings.ToLower(name)
 
 	switch name {
+	case "amazon":
+		return provider.NewAmazonProvider(config.External.Amazon, scopes)
 	case "apple":
 		return provider.NewAppleProvider(ctx, config.External.Apple)
 	case "azure":
@@ -556,17 +633,27 @@ func (a *API) Provider(ctx context.Context, name string, scopes string) (provide
 	case "gitlab":
 		return provider.NewGitlabProvider(config.External.Gitlab, scopes)
 	case "google":
-		return provider.NewGoogleProvider(ctx, config.External.Google, scopes)
+		return provider.NewGoogleProvider(ctx, config.External.Google.OAuthProviderConfiguration, scopes)
 	case "kakao":
 		return provider.NewKakaoProvider(config.External.Kakao, scopes)
 	case "keycloak":
 		return provider.NewKeycloakProvider(config.External.Keycloak, scopes)
+	case "line":
+		return provider.NewLineProvider(config.External.Line, scopes)
 	case "linkedin":
 		return provider.NewLinkedinProvider(config.External.Linkedin, scopes)
 	case "linkedin_oidc":
 		return provider.NewLinkedinOIDCProvider(config.External.LinkedinOIDC, scopes)
 	case "notion":
 		return provider.NewNotionProvider(config.External.Notion)
+	case "okta":
+		return provider.NewOktaProvider(config.External.Okta, scopes)
+	case "paypal":
+		return provider.NewPayPalProvider(config.External.PayPal, scopes)
+	case "salesforce":
+		return provider.NewSalesforceProvider(config.External.Salesforce, scopes)
+	case "steam":
+		return provider.NewSteamProvider(config.External.Steam)
 	case "spotify":
 		return provider.NewSpotifyProvider(config.External.Spotify, scopes)
 	case "slack":
@@ -577,6 +664,10 @@ func (a *API) Provider(ctx context.Context, name string, scopes string) (provide
 		return provider.NewTwitchProvider(config.External.Twitch, scopes)
 	case "twitter":
 		return provider.NewTwitterProvider(config.External.Twitter, scopes)
+	case "vk":
+		return provider.NewVKProvider(config.External.VK, scopes)
+	case "wechat":
+		return provider.NewWeChatProvider(config.External.WeChat)
 	case "vercel_marketplace":
 		return provider.NewVercelMarketplaceProvider(config.External.VercelMarketplace, scopes)
 	case "workos":
@@ -584,35 +675,47 @@ func (a *API) Provider(ctx context.Context, name string, scopes string) (provide
 	case "zoom":
 		return provider.NewZoomProvider(config.External.Zoom)
 	default:
+		if factory, ok := provider.Lookup(name); ok {
+			return factory(ctx, config, scopes)
+		}
+		if remote, ok := config.External.Remote[name]; ok {
+			return provider.NewRemoteProvider(name, remote, scopes)
+		}
 		return nil, fmt.Errorf("Provider %s could not be found", name)
 	}
 }
 
 func redirectErrors(handler apiHandler, w http.ResponseWriter, r *http.Request, u *url.URL) {
-	ctx := r.Context()
-	log := observability.GetLogEntry(r).Entry
-	errorID := utilities.GetRequestID(ctx)
 	err := handler(w, r)
 	if err != nil {
-		q := getErrorQueryString(err, errorID, log, u.Query())
-		u.RawQuery = q.Encode()
-
-		// TODO: deprecate returning error details in the query fragment
-		hq := url.Values{}
-		if q.Get("error") != "" {
-			hq.Set("error", q.Get("error"))
-		}
-		if q.Get("error_description") != "" {
-			hq.Set("error_description", q.Get("error_description"))
-		}
-		if q.Get("error_code") != "" {
-			hq.Set("error_code", q.Get("error_code"))
-		}
-		u.Fragment = hq.Encode()
+		setErrorRedirectURL(err, r, u)
 		http.Redirect(w, r, u.String(), http.StatusFound)
 	}
 }
 
+// setErrorRedirectURL adds the details of the error to the query and the
+// fragment of the redirect URL.
+func setErrorRedirectURL(err error, r *http.Request, u *url.URL) {
+	log := observability.GetLogEntry(r).Entry
+	errorID := utilities.GetRequestID(r.Context())
+
+	q := getErrorQueryString(err, errorID, log, u.Query())
+	u.RawQuery = q.Encode()
+
+	// TODO: deprecate returning error details in the query fragment
+	hq := url.Values{}
+	if q.Get("error") != "" {
+		hq.Set("error", q.Get("error"))
+	}
+	if q.Get("error_description") != "" {
+		hq.Set("error_description", q.Get("error_description"))
+	}
+	if q.Get("error_code") != "" {
+		hq.Set("error_code", q.Get("error_code"))
+	}
+	u.Fragment = hq.Encode()
+}
+
 func getErrorQueryString(err error, errorID string, log logrus.FieldLogger, q url.Values) *url.Values {
 	switch e := err.(type) {
 	case *HTTPError:
@@ -662,13 +765,151 @@ func getErrorQueryString(err error, errorID string, log logrus.FieldLogger, q ur
 func (a *API) getExternalRedirectURL(r *http.Request) string {
 	ctx := r.Context()
 	config := a.config
+
+	rurl := config.SiteURL
 	if config.External.RedirectURL != "" {
-		return config.External.RedirectURL
+		rurl = config.External.RedirectURL
+	} else if er := getExternalReferrer(ctx); er != "" {
+		rurl = er
 	}
-	if er := getExternalReferrer(ctx); er != "" {
-		return er
+
+	if customState := getCustomState(ctx); customState != "" {
+		u, err := url.Parse(rurl)
+		if err != nil {
+			return rurl
+		}
+		q := u.Query()
+		q.Set("custom_state", customState)
+		u.RawQuery = q.Encode()
+		return u.String()
 	}
-	return config.SiteURL
+
+	return rurl
+}
+
+// applyClaimMapping maps the claims of the user data with the claim mapping
+// configured for the provider.
+func (a *API) applyClaimMapping(providerType string, userData *provider.UserProvidedData) error {
+	providerConfig, ok := a.config.External.OAuthProvider(strings.ToLower(providerType))
+	if !ok || providerConfig.ClaimMapping.IsEmpty() || userData.Metadata == nil {
+		return nil
+	}
+
+	mapped, err := providerConfig.ClaimMapping.Apply(structs.Map(userData.Metadata))
+	if err != nil {
+		return err
+	}
+
+	if mapped.Name != "" {
+		userData.Metadata.Name = mapped.Name
+		userData.Metadata.FullName = mapped.Name
+	}
+	if mapped.AvatarURL != "" {
+		userData.Metadata.Picture = mapped.AvatarURL
+		userData.Metadata.AvatarURL = mapped.AvatarURL
+	}
+
+	if len(mapped.UserMetadata) > 0 {
+		if userData.UserMetadata == nil {
+			userData.UserMetadata = make(map[string]interface{}, len(mapped.UserMetadata))
+		}
+		for key, value := range mapped.UserMetadata {
+			userData.UserMetadata[key] = value
+		}
+	}
+
+	for key, value := range mapped.AppMetadata {
+		if value == nil {
+			continue
+		}
+		if userData.AppMetadata == nil {
+			userData.AppMetadata = make(map[string]interface{}, len(mapped.AppMetadata))
+		}
+		userData.AppMetadata[key] = value
+	}
+
+	return nil
+}
+
+// userMetaDataFromIdentity returns the user metadata for the identity data,
+// with the fields mapped from the claims on top. Mapped fields without a
+// value keep the claim of the same name out of the user metadata.
+func userMetaDataFromIdentity(identityData map[string]interface{}, userData *provider.UserProvidedData) map[string]interface{} {
+	if len(userData.UserMetadata) == 0 {
+		return identityData
+	}
+
+	userMetaData := make(map[string]interface{}, len(identityData)+len(userData.UserMetadata))
+	for key, value := range identityData {
+		userMetaData[key] = value
+	}
+	for key, value := range userData.UserMetadata {
+		if value == nil {
+			delete(userMetaData, key)
+		} else {
+			userMetaData[key] = value
+		}
+	}
+
+	return userMetaData
+}
+
+// validateRequestedScopes checks that the additional scopes requested on
+// authorize are allowed for the provider.
+func validateRequestedScopes(config *conf.GlobalConfiguration, providerType, scopes string) error {
+	providerConfig, ok := config.External.OAuthProvider(strings.ToLower(providerType))
+	if !ok {
+		return nil
+	}
+	for _, scope := range splitScopes(scopes) {
+		if !providerConfig.IsScopeAllowed(scope) {
+			return badRequestError(ErrorCodeValidationFailed, "Scope %q is not allowed for provider %s", scope, providerType)
+		}
+	}
+	return nil
+}
+
+// splitScopes splits scopes separated by commas, as requested on authorize,
+// or by spaces, as returned by most providers.
+func splitScopes(scopes string) []string {
+	return strings.FieldsFunc(scopes, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
+
+// saveIdentityScopes records the scopes the provider granted on the identity
+// the user signed in with.
+func (a *API) saveIdentityScopes(tx *storage.Connection, user *models.User, providerType string, data *OAuthProviderData) error {
+	identity, err := models.FindIdentityByIdAndProvider(tx, data.userData.Metadata.Subject, providerType)
+	if err != nil {
+		return err
+	}
+
+	// the identity may have been linked to the user being signed in to
+	if identity.UserID != user.ID {
+		return nil
+	}
+
+	return identity.UpdateScopes(tx, data.scopes)
+}
+
+// validateCustomState checks the custom state a client attached to the
+// authorize request. It's returned as is on callback, so it only needs to be
+// small and decodable as a query string by the client.
+func validateCustomState(config *conf.GlobalConfiguration, customState string) error {
+	if customState == "" {
+		return nil
+	}
+	if !config.External.CustomState.Enabled {
+		return badRequestError(ErrorCodeValidationFailed, "custom_state is not enabled")
+	}
+	if len(customState) > config.External.CustomState.MaxSize {
+		return badRequestError(ErrorCodeValidationFailed, "custom_state must be at most %d bytes", config.External.CustomState.MaxSize)
+	}
+	if _, err := url.ParseQuery(customState); err != nil {
+		return badRequestError(ErrorCodeValidationFailed, "custom_state must be URL encoded key-value pairs")
+	}
+	return nil
 }
 
 func (a *API) createNewIdentity(tx *storage.Connection, user *models.User, providerType string, identityData map[string]interface{}) (*models.Identity, error) {
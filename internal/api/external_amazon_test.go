@@ -0,0 +1,146 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	amazonUser        string = `{"user_id":"amzn1.account.AAAAAAAAAAAAAAAAAAAAAAAAAAAA","name":"Amazon Test","email":"amazon@example.com","postal_code":"98109"}`
+	amazonUserNoEmail string = `{"user_id":"amzn1.account.AAAAAAAAAAAAAAAAAAAAAAAAAAAA","name":"Amazon Test"}`
+)
+
+func (ts *ExternalTestSuite) TestSignupExternalAmazon() {
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/authorize?provider=amazon", nil)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Require().Equal(http.StatusFound, w.Code)
+	u, err := url.Parse(w.Header().Get("Location"))
+	ts.Require().NoError(err, "redirect url parse failed")
+	q := u.Query()
+	ts.Equal(ts.Config.External.Amazon.RedirectURI, q.Get("redirect_uri"))
+	ts.Equal(ts.Config.External.Amazon.ClientID, []string{q.Get("client_id")})
+	ts.Equal("code", q.Get("response_type"))
+	ts.Equal("profile", q.Get("scope"))
+
+	claims := ExternalProviderClaims{}
+	p := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
+	_, err = p.ParseWithClaims(q.Get("state"), &claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(ts.Config.JWT.Secret), nil
+	})
+	ts.Require().NoError(err)
+
+	ts.Equal("amazon", claims.Provider)
+	ts.Equal(ts.Config.SiteURL, claims.SiteURL)
+}
+
+func AmazonTestSignupSetup(ts *ExternalTestSuite, tokenCount *int, userCount *int, code string, user string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/auth/o2/token":
+			*tokenCount++
+			ts.Equal(code, r.FormValue("code"))
+			ts.Equal("authorization_code", r.FormValue("grant_type"))
+			ts.Equal(ts.Config.External.Amazon.RedirectURI, r.FormValue("redirect_uri"))
+
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token":"amazon_token","token_type":"bearer","expires_in":3600,"refresh_token":"amazon_refresh_token"}`)
+		case "/user/profile":
+			*userCount++
+			ts.Equal("Bearer amazon_token", r.Header.Get("Authorization"))
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprint(w, user)
+		default:
+			w.WriteHeader(500)
+			ts.Fail("unknown amazon oauth call %s", r.URL.Path)
+		}
+	}))
+
+	ts.Config.External.Amazon.URL = server.URL
+	ts.Config.External.Amazon.ApiURL = server.URL
+
+	return server
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalAmazon_AuthorizationCode() {
+	ts.Config.DisableSignup = false
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := AmazonTestSignupSetup(ts, &tokenCount, &userCount, code, amazonUser)
+	defer server.Close()
+
+	u := performAuthorization(ts, "amazon", code, "")
+
+	assertAuthorizationSuccess(ts, u, tokenCount, userCount, "amazon@example.com", "Amazon Test", "amzn1.account.AAAAAAAAAAAAAAAAAAAAAAAAAAAA", "")
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalAmazonDisableSignupErrorWhenNoUser() {
+	ts.Config.DisableSignup = true
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := AmazonTestSignupSetup(ts, &tokenCount, &userCount, code, amazonUser)
+	defer server.Close()
+
+	u := performAuthorization(ts, "amazon", code, "")
+
+	assertAuthorizationFailure(ts, u, "Signups not allowed for this instance", "access_denied", "amazon@example.com")
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalAmazonDisableSignupErrorWhenNoEmail() {
+	ts.Config.DisableSignup = true
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := AmazonTestSignupSetup(ts, &tokenCount, &userCount, code, amazonUserNoEmail)
+	defer server.Close()
+
+	u := performAuthorization(ts, "amazon", code, "")
+
+	assertAuthorizationFailure(ts, u, "Error getting user email from external provider", "server_error", "amazon@example.com")
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalAmazonDisableSignupSuccessWithPrimaryEmail() {
+	ts.Config.DisableSignup = true
+
+	ts.createUser("amzn1.account.AAAAAAAAAAAAAAAAAAAAAAAAAAAA", "amazon@example.com", "Amazon Test", "", "")
+
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := AmazonTestSignupSetup(ts, &tokenCount, &userCount, code, amazonUser)
+	defer server.Close()
+
+	u := performAuthorization(ts, "amazon", code, "")
+
+	assertAuthorizationSuccess(ts, u, tokenCount, userCount, "amazon@example.com", "Amazon Test", "amzn1.account.AAAAAAAAAAAAAAAAAAAAAAAAAAAA", "")
+}
+
+func (ts *ExternalTestSuite) TestInviteTokenExternalAmazonSuccessWhenMatchingToken() {
+	// name should be populated from the Amazon profile
+	ts.createUser("amzn1.account.AAAAAAAAAAAAAAAAAAAAAAAAAAAA", "amazon@example.com", "", "", "invite_token")
+
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := AmazonTestSignupSetup(ts, &tokenCount, &userCount, code, amazonUser)
+	defer server.Close()
+
+	u := performAuthorization(ts, "amazon", code, "invite_token")
+
+	assertAuthorizationSuccess(ts, u, tokenCount, userCount, "amazon@example.com", "Amazon Test", "amzn1.account.AAAAAAAAAAAAAAAAAAAAAAAAAAAA", "")
+}
+
+func (ts *ExternalTestSuite) TestInviteTokenExternalAmazonErrorWhenEmailDoesntMatch() {
+	ts.createUser("amzn1.account.AAAAAAAAAAAAAAAAAAAAAAAAAAAA", "amazon@example.com", "", "", "invite_token")
+
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	amazonUser := `{"user_id":"amzn1.account.AAAAAAAAAAAAAAAAAAAAAAAAAAAA","name":"Amazon Test","email":"other@example.com"}`
+	server := AmazonTestSignupSetup(ts, &tokenCount, &userCount, code, amazonUser)
+	defer server.Close()
+
+	u := performAuthorization(ts, "amazon", code, "invite_token")
+
+	assertAuthorizationFailure(ts, u, "Invited email does not match emails from external provider", "invalid_request", "")
+}
@@ -17,11 +17,14 @@ import (
 	"github.com/coreos/go-oidc/v3/oidc"
 	jwt "github.com/golang-jwt/jwt/v5"
 	"github.com/supabase/auth/internal/api/provider"
+	"github.com/supabase/auth/internal/models"
 )
 
 const (
-	azureUser        string = `{"name":"Azure Test","email":"azure@example.com","sub":"azuretestid"}`
-	azureUserNoEmail string = `{"name":"Azure Test","sub":"azuretestid"}`
+	azureUser           string = `{"name":"Azure Test","email":"azure@example.com","sub":"azuretestid"}`
+	azureUserNoEmail    string = `{"name":"Azure Test","sub":"azuretestid"}`
+	azureUserWithGroups string = `{"name":"Azure Test","email":"azure@example.com","sub":"azuretestid","tid":"9188040d-6c67-4c5b-b112-36a304b66dad","groups":["group-a","group-b"],"roles":["Admin"]}`
+	azureUserOverage    string = `{"name":"Azure Test","email":"azure@example.com","sub":"azuretestid","tid":"9188040d-6c67-4c5b-b112-36a304b66dad","_claim_names":{"groups":"src1"}}`
 )
 
 func idTokenPrivateKey() *rsa.PrivateKey {
@@ -68,6 +71,11 @@ func mintIDToken(user string) string {
 		Name    string `json:"name,omitempty"`
 		Email   string `json:"email,omitempty"`
 		XmsEdov any    `json:"xms_edov,omitempty"`
+
+		TenantID   string            `json:"tid,omitempty"`
+		Groups     []string          `json:"groups,omitempty"`
+		Roles      []string          `json:"roles,omitempty"`
+		ClaimNames map[string]string `json:"_claim_names,omitempty"`
 	}
 
 	if err := json.Unmarshal([]byte(user), &idToken); err != nil {
@@ -137,6 +145,11 @@ func AzureTestSignupSetup(ts *ExternalTestSuite, tokenCount *int, code string, u
 
 			w.Header().Add("Content-Type", "application/json")
 			fmt.Fprintf(w, `{"access_token":"azure_token","expires_in":100000,"id_token":%q}`, mintIDToken(user))
+		case "/v1.0/me/transitiveMemberOf/microsoft.graph.group":
+			ts.Equal("Bearer azure_token", r.Header.Get("Authorization"))
+
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprint(w, `{"value":[{"id":"graph-group-a"},{"id":"graph-group-b"}]}`)
 		default:
 			w.WriteHeader(500)
 			ts.Fail("unknown azure oauth call %s", r.URL.Path)
@@ -145,6 +158,7 @@ func AzureTestSignupSetup(ts *ExternalTestSuite, tokenCount *int, code string, u
 
 	ts.Config.External.Azure.URL = server.URL
 	ts.Config.External.Azure.ApiURL = server.URL
+	ts.Config.External.Azure.GraphURL = server.URL
 
 	return server
 }
@@ -267,3 +281,75 @@ func (ts *ExternalTestSuite) TestInviteTokenExternalAzureErrorWhenEmailDoesntMat
 
 	assertAuthorizationFailure(ts, u, "Invited email does not match emails from external provider", "invalid_request", "")
 }
+
+func (ts *ExternalTestSuite) TestSignupExternalAzureSyncGroups() {
+	setupAzureOverrideVerifiers()
+
+	ts.Config.External.Azure.SyncGroups = true
+	defer func() {
+		ts.Config.External.Azure.SyncGroups = false
+	}()
+
+	tokenCount := 0
+	code := "authcode"
+	server := AzureTestSignupSetup(ts, &tokenCount, code, azureUserWithGroups)
+	defer server.Close()
+
+	u := performAuthorization(ts, "azure", code, "")
+
+	assertAuthorizationSuccess(ts, u, tokenCount, -1, "azure@example.com", "Azure Test", "azuretestid", "")
+
+	user, err := models.FindUserByEmailAndAudience(ts.API.db, "azure@example.com", ts.Config.JWT.Aud)
+	ts.Require().NoError(err)
+	ts.Require().Equal(map[string]interface{}{
+		"tenant_id": "9188040d-6c67-4c5b-b112-36a304b66dad",
+		"groups":    []interface{}{"group-a", "group-b"},
+		"roles":     []interface{}{"Admin"},
+	}, user.AppMetaData["azure"])
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalAzureGraphGroupsFallback() {
+	setupAzureOverrideVerifiers()
+
+	ts.Config.External.Azure.SyncGroups = true
+	ts.Config.External.Azure.GraphGroupsFallback = true
+	defer func() {
+		ts.Config.External.Azure.SyncGroups = false
+		ts.Config.External.Azure.GraphGroupsFallback = false
+	}()
+
+	tokenCount := 0
+	code := "authcode"
+	server := AzureTestSignupSetup(ts, &tokenCount, code, azureUserOverage)
+	defer server.Close()
+
+	u := performAuthorization(ts, "azure", code, "")
+
+	assertAuthorizationSuccess(ts, u, tokenCount, -1, "azure@example.com", "Azure Test", "azuretestid", "")
+
+	user, err := models.FindUserByEmailAndAudience(ts.API.db, "azure@example.com", ts.Config.JWT.Aud)
+	ts.Require().NoError(err)
+	ts.Require().Equal(map[string]interface{}{
+		"tenant_id": "9188040d-6c67-4c5b-b112-36a304b66dad",
+		"groups":    []interface{}{"graph-group-a", "graph-group-b"},
+		"roles":     []interface{}{},
+	}, user.AppMetaData["azure"])
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalAzureTenantNotAllowed() {
+	setupAzureOverrideVerifiers()
+
+	ts.Config.External.Azure.AllowedTenants = []string{"72f988bf-86f1-41af-91ab-2d7cd011db47"}
+	defer func() {
+		ts.Config.External.Azure.AllowedTenants = nil
+	}()
+
+	tokenCount := 0
+	code := "authcode"
+	server := AzureTestSignupSetup(ts, &tokenCount, code, azureUserWithGroups)
+	defer server.Close()
+
+	u := performAuthorization(ts, "azure", code, "")
+
+	assertAuthorizationFailure(ts, u, "User is not allowed to sign in with this provider", "access_denied", "azure@example.com")
+}
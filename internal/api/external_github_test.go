@@ -13,6 +13,7 @@ import (
 
 	jwt "github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
 	"github.com/supabase/auth/internal/models"
 )
 
@@ -81,6 +82,32 @@ func (ts *ExternalTestSuite) TestSignupExternalGitHub_AuthorizationCode() {
 	assertAuthorizationSuccess(ts, u, tokenCount, userCount, "github@example.com", "GitHub Test", "123", "http://example.com/avatar")
 }
 
+func (ts *ExternalTestSuite) TestSignupExternalGitHubClaimMapping() {
+	ts.Require().NoError(ts.Config.External.Github.ClaimMapping.Decode(`{
+		"name": "{{ .full_name }} (GitHub)",
+		"user_metadata": {"github_id": "{{ .provider_id }}", "avatar_url": ""},
+		"app_metadata": {"source": "github"}
+	}`))
+	defer func() {
+		ts.Config.External.Github.ClaimMapping = conf.ClaimMapping{}
+	}()
+
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	emails := `[{"email":"github@example.com", "primary": true, "verified": true}]`
+	server := GitHubTestSignupSetup(ts, &tokenCount, &userCount, code, emails)
+	defer server.Close()
+
+	performAuthorization(ts, "github", code, "")
+
+	user, err := models.FindUserByEmailAndAudience(ts.API.db, "github@example.com", ts.Config.JWT.Aud)
+	ts.Require().NoError(err)
+	ts.Equal("GitHub Test (GitHub)", user.UserMetaData["full_name"])
+	ts.Equal("123", user.UserMetaData["github_id"])
+	ts.NotContains(user.UserMetaData, "avatar_url")
+	ts.Equal("github", user.AppMetaData["source"])
+}
+
 func (ts *ExternalTestSuite) TestSignupExternalGitHub_PKCE() {
 	tokenCount, userCount := 0, 0
 	code := "authcode"
@@ -298,3 +325,115 @@ func (ts *ExternalTestSuite) TestSignupExternalGitHubErrorWhenUserBanned() {
 	u = performAuthorization(ts, "github", code, "")
 	assertAuthorizationFailure(ts, u, "User is banned", "access_denied", "")
 }
+
+// GitHubEnterpriseTestSignupSetup mocks a GitHub Enterprise Server instance
+// serving its API from apiPath, including the memberships of the user.
+func GitHubEnterpriseTestSignupSetup(ts *ExternalTestSuite, tokenCount *int, userCount *int, code string, apiPath string, orgs string, teams string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/login/oauth/access_token":
+			*tokenCount++
+			ts.Equal(code, r.FormValue("code"))
+			fmt.Fprint(w, `{"access_token":"github_token","expires_in":100000}`)
+		case apiPath + "/user":
+			*userCount++
+			fmt.Fprint(w, `{"id":123,"login":"ghtest","name":"GitHub Test","avatar_url":"http://example.com/avatar"}`)
+		case apiPath + "/user/emails":
+			fmt.Fprint(w, `[{"email":"github@example.com","primary":true,"verified":true}]`)
+		case apiPath + "/user/memberships/orgs":
+			ts.Equal("active", r.URL.Query().Get("state"))
+			fmt.Fprint(w, orgs)
+		case apiPath + "/user/teams":
+			fmt.Fprint(w, teams)
+		default:
+			w.WriteHeader(500)
+			ts.Fail("unknown github enterprise call %s", r.URL.Path)
+		}
+	}))
+
+	ts.Config.External.Github.URL = server.URL
+
+	return server
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalGitHubEnterpriseAPIURL() {
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := GitHubEnterpriseTestSignupSetup(ts, &tokenCount, &userCount, code, "/custom/api", `[]`, `[]`)
+	defer server.Close()
+
+	ts.Config.External.Github.ApiURL = server.URL + "/custom/api"
+	defer func() {
+		ts.Config.External.Github.ApiURL = ""
+	}()
+
+	u := performAuthorization(ts, "github", code, "")
+
+	assertAuthorizationSuccess(ts, u, tokenCount, userCount, "github@example.com", "GitHub Test", "123", "http://example.com/avatar")
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalGitHubMembership() {
+	defer func() {
+		ts.Config.External.Github.AllowedOrganizations = nil
+		ts.Config.External.Github.AllowedTeams = nil
+	}()
+
+	cases := []struct {
+		desc    string
+		orgs    []string
+		teams   []string
+		allowed bool
+	}{
+		{
+			desc:    "member of an allowed organization",
+			orgs:    []string{"Acme"},
+			allowed: true,
+		},
+		{
+			desc:    "member of an allowed team",
+			teams:   []string{"acme/platform"},
+			allowed: true,
+		},
+		{
+			desc:    "not a member",
+			orgs:    []string{"other"},
+			teams:   []string{"acme/security"},
+			allowed: false,
+		},
+	}
+
+	for _, c := range cases {
+		ts.Run(c.desc, func() {
+			models.TruncateAll(ts.API.db)
+			ts.Config.External.Github.AllowedOrganizations = c.orgs
+			ts.Config.External.Github.AllowedTeams = c.teams
+
+			w := performAuthorizationRequest(ts, "github", "")
+			ts.Require().Equal(http.StatusFound, w.Code)
+			u, err := url.Parse(w.Header().Get("Location"))
+			ts.Require().NoError(err)
+			ts.Equal("user:email read:org", u.Query().Get("scope"))
+
+			tokenCount, userCount := 0, 0
+			code := "authcode"
+			server := GitHubEnterpriseTestSignupSetup(ts, &tokenCount, &userCount, code, "/api/v3",
+				`[{"state":"active","organization":{"login":"acme"}},{"state":"pending","organization":{"login":"other"}}]`,
+				`[{"slug":"platform","organization":{"login":"acme"}}]`)
+			defer server.Close()
+
+			u = performAuthorization(ts, "github", code, "")
+
+			if c.allowed {
+				v, err := url.ParseQuery(u.Fragment)
+				ts.Require().NoError(err)
+				ts.NotEmpty(v.Get("access_token"))
+			} else {
+				v, err := url.ParseQuery(u.RawQuery)
+				ts.Require().NoError(err)
+				ts.Equal("access_denied", v.Get("error"))
+				ts.Equal("User is not allowed to sign in with this provider", v.Get("error_description"))
+			}
+		})
+	}
+}
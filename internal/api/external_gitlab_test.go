@@ -7,12 +7,14 @@ import (
 	"net/url"
 
 	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/supabase/auth/internal/models"
 )
 
 const (
 	gitlabUser           string = `{"id":123,"email":"gitlab@example.com","name":"GitLab Test","avatar_url":"http://example.com/avatar","confirmed_at":"2012-05-23T09:05:22Z"}`
 	gitlabUserWrongEmail string = `{"id":123,"email":"other@example.com","name":"GitLab Test","avatar_url":"http://example.com/avatar","confirmed_at":"2012-05-23T09:05:22Z"}`
 	gitlabUserNoEmail    string = `{"id":123,"name":"Gitlab Test","avatar_url":"http://example.com/avatar"}`
+	gitlabUserInfo       string = `{"sub":"123","groups":["acme","acme/platform"]}`
 )
 
 func (ts *ExternalTestSuite) TestSignupExternalGitlab() {
@@ -57,6 +59,9 @@ func GitlabTestSignupSetup(ts *ExternalTestSuite, tokenCount *int, userCount *in
 		case "/api/v4/user/emails":
 			w.Header().Add("Content-Type", "application/json")
 			fmt.Fprint(w, emails)
+		case "/oauth/userinfo":
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprint(w, gitlabUserInfo)
 		default:
 			w.WriteHeader(500)
 			ts.Fail("unknown gitlab oauth call %s", r.URL.Path)
@@ -197,3 +202,65 @@ func (ts *ExternalTestSuite) TestInviteTokenExternalGitLabErrorWhenEmailDoesntMa
 
 	assertAuthorizationFailure(ts, u, "Invited email does not match emails from external provider", "invalid_request", "")
 }
+
+func (ts *ExternalTestSuite) TestSignupExternalGitlabAllowedGroups() {
+	ts.Config.Mailer.Autoconfirm = true
+	defer func() {
+		ts.Config.External.Gitlab.AllowedGroups = nil
+		ts.Config.External.Gitlab.SyncGroups = false
+	}()
+
+	cases := []struct {
+		desc    string
+		groups  []string
+		allowed bool
+	}{
+		{
+			desc:    "member of an allowed group",
+			groups:  []string{"other", "ACME/Platform"},
+			allowed: true,
+		},
+		{
+			desc:    "not a member",
+			groups:  []string{"acme/security"},
+			allowed: false,
+		},
+	}
+
+	for _, c := range cases {
+		ts.Run(c.desc, func() {
+			models.TruncateAll(ts.API.db)
+			ts.Config.External.Gitlab.AllowedGroups = c.groups
+			ts.Config.External.Gitlab.SyncGroups = true
+
+			w := performAuthorizationRequest(ts, "gitlab", "")
+			ts.Require().Equal(http.StatusFound, w.Code)
+			u, err := url.Parse(w.Header().Get("Location"))
+			ts.Require().NoError(err)
+			ts.Equal("read_user openid", u.Query().Get("scope"))
+
+			tokenCount, userCount := 0, 0
+			code := "authcode"
+			emails := `[{"id":1,"email":"gitlab@example.com"}]`
+			server := GitlabTestSignupSetup(ts, &tokenCount, &userCount, code, gitlabUser, emails)
+			defer server.Close()
+
+			u = performAuthorization(ts, "gitlab", code, "")
+
+			if !c.allowed {
+				v, err := url.ParseQuery(u.RawQuery)
+				ts.Require().NoError(err)
+				ts.Equal("access_denied", v.Get("error"))
+				return
+			}
+
+			assertAuthorizationSuccess(ts, u, tokenCount, userCount, "gitlab@example.com", "GitLab Test", "123", "http://example.com/avatar")
+
+			user, err := models.FindUserByEmailAndAudience(ts.API.db, "gitlab@example.com", ts.Config.JWT.Aud)
+			ts.Require().NoError(err)
+			ts.Equal(map[string]interface{}{
+				"groups": []interface{}{"acme", "acme/platform"},
+			}, user.AppMetaData["gitlab"])
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/supabase/auth/internal/api/provider"
+	"github.com/supabase/auth/internal/models"
+)
+
+const lineSubject string = "U1234567890abcdef1234567890abcdef"
+
+func (ts *ExternalTestSuite) TestSignupExternalLine() {
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/authorize?provider=line", nil)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Require().Equal(http.StatusFound, w.Code)
+	u, err := url.Parse(w.Header().Get("Location"))
+	ts.Require().NoError(err, "redirect url parse failed")
+	q := u.Query()
+	ts.Equal(ts.Config.External.Line.RedirectURI, q.Get("redirect_uri"))
+	ts.Equal(ts.Config.External.Line.ClientID, []string{q.Get("client_id")})
+	ts.Equal("code", q.Get("response_type"))
+	ts.Equal("openid profile email", q.Get("scope"))
+
+	claims := ExternalProviderClaims{}
+	p := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
+	_, err = p.ParseWithClaims(q.Get("state"), &claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(ts.Config.JWT.Secret), nil
+	})
+	ts.Require().NoError(err)
+
+	ts.Equal("line", claims.Provider)
+	ts.Equal(ts.Config.SiteURL, claims.SiteURL)
+}
+
+// lineIDToken signs an ID token with the channel secret, as LINE does for
+// the ID tokens returned by the token endpoint.
+func lineIDToken(ts *ExternalTestSuite, secret string, email string) string {
+	claims := jwt.MapClaims{
+		"iss":     provider.IssuerLine,
+		"sub":     lineSubject,
+		"aud":     ts.Config.External.Line.ClientID[0],
+		"exp":     time.Now().Add(time.Hour).Unix(),
+		"iat":     time.Now().Unix(),
+		"amr":     []string{"linesso"},
+		"name":    "LINE Test",
+		"picture": "http://example.com/avatar",
+	}
+	if email != "" {
+		claims["email"] = email
+	}
+
+	idToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	ts.Require().NoError(err)
+	return idToken
+}
+
+func LineTestSignupSetup(ts *ExternalTestSuite, tokenCount *int, code string, idToken string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth2/v2.1/token":
+			*tokenCount++
+			ts.Equal(code, r.FormValue("code"))
+			ts.Equal("authorization_code", r.FormValue("grant_type"))
+			ts.Equal(ts.Config.External.Line.RedirectURI, r.FormValue("redirect_uri"))
+			ts.Equal(ts.Config.External.Line.ClientID[0], r.FormValue("client_id"))
+			ts.Equal(ts.Config.External.Line.Secret, r.FormValue("client_secret"))
+
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"access_token":"line_token","token_type":"Bearer","expires_in":2592000,"refresh_token":"line_refresh_token","scope":"openid profile email","id_token":%q}`, idToken)
+		default:
+			w.WriteHeader(500)
+			ts.Fail("unknown line oauth call %s", r.URL.Path)
+		}
+	}))
+
+	ts.Config.External.Line.URL = server.URL
+	ts.Config.External.Line.ApiURL = server.URL
+
+	return server
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalLine_AuthorizationCode() {
+	ts.Config.DisableSignup = false
+	tokenCount := 0
+	code := "authcode"
+	server := LineTestSignupSetup(ts, &tokenCount, code, lineIDToken(ts, ts.Config.External.Line.Secret, "line@example.com"))
+	defer server.Close()
+
+	u := performAuthorization(ts, "line", code, "")
+
+	assertAuthorizationSuccess(ts, u, tokenCount, -1, "line@example.com", "LINE Test", lineSubject, "http://example.com/avatar")
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalLineWithoutEmail() {
+	ts.Config.DisableSignup = false
+	tokenCount := 0
+	code := "authcode"
+	server := LineTestSignupSetup(ts, &tokenCount, code, lineIDToken(ts, ts.Config.External.Line.Secret, ""))
+	defer server.Close()
+
+	u := performAuthorization(ts, "line", code, "")
+
+	v, err := url.ParseQuery(u.Fragment)
+	ts.Require().NoError(err)
+	ts.NotEmpty(v.Get("access_token"))
+
+	identity, err := models.FindIdentityByIdAndProvider(ts.API.db, lineSubject, "line")
+	ts.Require().NoError(err)
+
+	user, err := models.FindUserByID(ts.API.db, identity.UserID)
+	ts.Require().NoError(err)
+	ts.Empty(user.GetEmail())
+	ts.Equal("LINE Test", user.UserMetaData["full_name"])
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalLineErrorWhenIDTokenInvalid() {
+	ts.Config.DisableSignup = false
+	tokenCount := 0
+	code := "authcode"
+	server := LineTestSignupSetup(ts, &tokenCount, code, lineIDToken(ts, "othersecret", "line@example.com"))
+	defer server.Close()
+
+	u := performAuthorization(ts, "line", code, "")
+
+	v, err := url.ParseQuery(u.RawQuery)
+	ts.Require().NoError(err)
+	ts.Equal("server_error", v.Get("error"))
+
+	_, err = models.FindIdentityByIdAndProvider(ts.API.db, lineSubject, "line")
+	ts.Require().Error(err)
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalLineDisableSignupErrorWhenNoUser() {
+	ts.Config.DisableSignup = true
+	tokenCount := 0
+	code := "authcode"
+	server := LineTestSignupSetup(ts, &tokenCount, code, lineIDToken(ts, ts.Config.External.Line.Secret, "line@example.com"))
+	defer server.Close()
+
+	u := performAuthorization(ts, "line", code, "")
+
+	assertAuthorizationFailure(ts, u, "Signups not allowed for this instance", "access_denied", "line@example.com")
+}
@@ -2,9 +2,12 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/mrjones/oauth"
 	"github.com/sirupsen/logrus"
@@ -18,6 +21,9 @@ type OAuthProviderData struct {
 	userData     *provider.UserProvidedData
 	token        string
 	refreshToken string
+	tokenType    string
+	expiry       time.Time
+	scopes       string
 	code         string
 }
 
@@ -86,6 +92,9 @@ func (a *API) oAuthCallback(ctx context.Context, r *http.Request, providerType s
 
 	userData, err := oAuthProvider.GetUserData(ctx, token)
 	if err != nil {
+		if errors.Is(err, provider.ErrAccessDenied) {
+			return nil, forbiddenError(ErrorCodeProviderAccessDenied, "User is not allowed to sign in with this provider").WithInternalError(err)
+		}
 		return nil, internalServerError("Error getting user profile from external provider").WithInternalError(err)
 	}
 
@@ -105,10 +114,24 @@ func (a *API) oAuthCallback(ctx context.Context, r *http.Request, providerType s
 		userData:     userData,
 		token:        token.AccessToken,
 		refreshToken: token.RefreshToken,
+		tokenType:    token.TokenType,
+		expiry:       token.Expiry,
+		scopes:       grantedScopes(token.Extra("scope"), getRequestedScopes(ctx)),
 		code:         oauthCode,
 	}, nil
 }
 
+// grantedScopes returns the scopes the provider granted, space separated.
+// Providers that don't return the scope with the token granted the
+// requested ones.
+func grantedScopes(tokenScope interface{}, requestedScopes string) string {
+	scopes := requestedScopes
+	if s, ok := tokenScope.(string); ok && s != "" {
+		scopes = s
+	}
+	return strings.Join(splitScopes(scopes), " ")
+}
+
 func (a *API) oAuth1Callback(ctx context.Context, providerType string) (*OAuthProviderData, error) {
 	oAuthProvider, err := a.OAuthProvider(ctx, providerType)
 	if err != nil {
@@ -139,6 +162,30 @@ func (a *API) oAuth1Callback(ctx context.Context, providerType string) (*OAuthPr
 
 }
 
+func (a *API) openIDCallback(ctx context.Context, r *http.Request, providerType string) (*OAuthProviderData, error) {
+	oAuthProvider, err := a.OAuthProvider(ctx, providerType)
+	if err != nil {
+		return nil, badRequestError(ErrorCodeOAuthProviderNotSupported, "Unsupported provider: %+v", err).WithInternalError(err)
+	}
+
+	rq := r.URL.Query()
+	if rq.Get("openid.mode") == "cancel" {
+		return nil, oauthError("access_denied", "User cancelled the sign in")
+	}
+
+	var userData *provider.UserProvidedData
+	if steamProvider, ok := oAuthProvider.(*provider.SteamProvider); ok {
+		userData, err = steamProvider.VerifyAssertion(ctx, rq.Get("state"), rq)
+		if err != nil {
+			return nil, internalServerError("Unable to verify OpenID assertion").WithInternalError(err)
+		}
+	}
+
+	return &OAuthProviderData{
+		userData: userData,
+	}, nil
+}
+
 // OAuthProvider returns the corresponding oauth provider as an OAuthProvider interface
 func (a *API) OAuthProvider(ctx context.Context, name string) (provider.OAuthProvider, error) {
 	providerCandidate, err := a.Provider(ctx, name, "")
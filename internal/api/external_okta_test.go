@@ -0,0 +1,184 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/supabase/auth/internal/models"
+)
+
+const (
+	oktaUser           string = `{"sub":"oktatestid","name":"Okta Test","given_name":"Okta","family_name":"Test","email":"okta@example.com","email_verified":true}`
+	oktaUserNoEmail    string = `{"sub":"oktatestid","name":"Okta Test","email_verified":false}`
+	oktaUserWithGroups string = `{"sub":"oktatestid","name":"Okta Test","email":"okta@example.com","email_verified":true,"groups":["Everyone","Engineering"]}`
+)
+
+func (ts *ExternalTestSuite) TestSignupExternalOkta() {
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/authorize?provider=okta", nil)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Require().Equal(http.StatusFound, w.Code)
+	u, err := url.Parse(w.Header().Get("Location"))
+	ts.Require().NoError(err, "redirect url parse failed")
+	ts.Equal("/oauth2/default/v1/authorize", u.Path)
+	q := u.Query()
+	ts.Equal(ts.Config.External.Okta.RedirectURI, q.Get("redirect_uri"))
+	ts.Equal(ts.Config.External.Okta.ClientID, []string{q.Get("client_id")})
+	ts.Equal("code", q.Get("response_type"))
+	ts.Equal("openid profile email", q.Get("scope"))
+
+	claims := ExternalProviderClaims{}
+	p := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
+	_, err = p.ParseWithClaims(q.Get("state"), &claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(ts.Config.JWT.Secret), nil
+	})
+	ts.Require().NoError(err)
+
+	ts.Equal("okta", claims.Provider)
+	ts.Equal(ts.Config.SiteURL, claims.SiteURL)
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalOktaOrgAuthorizationServer() {
+	oktaURL := ts.Config.External.Okta.URL
+	ts.Config.External.Okta.URL = "https://example.okta.com/"
+	defer func() {
+		ts.Config.External.Okta.URL = oktaURL
+	}()
+
+	w := performAuthorizationRequest(ts, "okta", "")
+	ts.Require().Equal(http.StatusFound, w.Code)
+	u, err := url.Parse(w.Header().Get("Location"))
+	ts.Require().NoError(err, "redirect url parse failed")
+	ts.Equal("example.okta.com", u.Host)
+	ts.Equal("/oauth2/v1/authorize", u.Path)
+}
+
+func OktaTestSignupSetup(ts *ExternalTestSuite, tokenCount *int, userCount *int, code string, user string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth2/default/v1/token":
+			*tokenCount++
+			ts.Equal(code, r.FormValue("code"))
+			ts.Equal("authorization_code", r.FormValue("grant_type"))
+			ts.Equal(ts.Config.External.Okta.RedirectURI, r.FormValue("redirect_uri"))
+
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token":"okta_token","expires_in":100000}`)
+		case "/oauth2/default/v1/userinfo":
+			*userCount++
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprint(w, user)
+		default:
+			w.WriteHeader(500)
+			ts.Fail("unknown okta oauth call %s", r.URL.Path)
+		}
+	}))
+
+	ts.Config.External.Okta.URL = server.URL + "/oauth2/default"
+
+	return server
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalOkta_AuthorizationCode() {
+	ts.Config.DisableSignup = false
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := OktaTestSignupSetup(ts, &tokenCount, &userCount, code, oktaUser)
+	defer server.Close()
+
+	u := performAuthorization(ts, "okta", code, "")
+
+	assertAuthorizationSuccess(ts, u, tokenCount, userCount, "okta@example.com", "Okta Test", "oktatestid", "")
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalOktaDisableSignupErrorWhenNoUser() {
+	ts.Config.DisableSignup = true
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := OktaTestSignupSetup(ts, &tokenCount, &userCount, code, oktaUser)
+	defer server.Close()
+
+	u := performAuthorization(ts, "okta", code, "")
+
+	assertAuthorizationFailure(ts, u, "Signups not allowed for this instance", "access_denied", "okta@example.com")
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalOktaDisableSignupErrorWhenNoEmail() {
+	ts.Config.DisableSignup = true
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := OktaTestSignupSetup(ts, &tokenCount, &userCount, code, oktaUserNoEmail)
+	defer server.Close()
+
+	u := performAuthorization(ts, "okta", code, "")
+
+	assertAuthorizationFailure(ts, u, "Error getting user email from external provider", "server_error", "okta@example.com")
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalOktaDisableSignupSuccessWithPrimaryEmail() {
+	ts.Config.DisableSignup = true
+
+	ts.createUser("oktatestid", "okta@example.com", "Okta Test", "", "")
+
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := OktaTestSignupSetup(ts, &tokenCount, &userCount, code, oktaUser)
+	defer server.Close()
+
+	u := performAuthorization(ts, "okta", code, "")
+
+	assertAuthorizationSuccess(ts, u, tokenCount, userCount, "okta@example.com", "Okta Test", "oktatestid", "")
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalOktaSyncGroups() {
+	ts.Config.External.Okta.SyncGroups = true
+	defer func() {
+		ts.Config.External.Okta.SyncGroups = false
+	}()
+
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := OktaTestSignupSetup(ts, &tokenCount, &userCount, code, oktaUserWithGroups)
+	defer server.Close()
+
+	u := performAuthorization(ts, "okta", code, "")
+
+	assertAuthorizationSuccess(ts, u, tokenCount, userCount, "okta@example.com", "Okta Test", "oktatestid", "")
+
+	user, err := models.FindUserByEmailAndAudience(ts.API.db, "okta@example.com", ts.Config.JWT.Aud)
+	ts.Require().NoError(err)
+	ts.Require().Equal(map[string]interface{}{
+		"groups": []interface{}{"Everyone", "Engineering"},
+	}, user.AppMetaData["okta"])
+}
+
+func (ts *ExternalTestSuite) TestInviteTokenExternalOktaSuccessWhenMatchingToken() {
+	// name should be populated from the Okta user info endpoint
+	ts.createUser("oktatestid", "okta@example.com", "", "", "invite_token")
+
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := OktaTestSignupSetup(ts, &tokenCount, &userCount, code, oktaUser)
+	defer server.Close()
+
+	u := performAuthorization(ts, "okta", code, "invite_token")
+
+	assertAuthorizationSuccess(ts, u, tokenCount, userCount, "okta@example.com", "Okta Test", "oktatestid", "")
+}
+
+func (ts *ExternalTestSuite) TestInviteTokenExternalOktaErrorWhenEmailDoesntMatch() {
+	ts.createUser("oktatestid", "okta@example.com", "", "", "invite_token")
+
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	oktaUser := `{"sub":"oktatestid","name":"Okta Test","email":"other@example.com","email_verified":true}`
+	server := OktaTestSignupSetup(ts, &tokenCount, &userCount, code, oktaUser)
+	defer server.Close()
+
+	u := performAuthorization(ts, "okta", code, "invite_token")
+
+	assertAuthorizationFailure(ts, u, "Invited email does not match emails from external provider", "invalid_request", "")
+}
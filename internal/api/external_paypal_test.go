@@ -0,0 +1,147 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	paypalUser        string = `{"user_id":"https://www.paypal.com/webapps/auth/identity/user/mWq6_1sU85v5EG9yHdPxJRrhGHrnMJ-1PQKtX6pcsmA","name":"PayPal Test","payer_id":"WDJJHEBZ4X2LY","verified_account":"true","emails":[{"value":"paypal@example.com","primary":true,"confirmed":true}]}`
+	paypalUserNoEmail string = `{"user_id":"https://www.paypal.com/webapps/auth/identity/user/mWq6_1sU85v5EG9yHdPxJRrhGHrnMJ-1PQKtX6pcsmA","name":"PayPal Test","payer_id":"WDJJHEBZ4X2LY","verified_account":"true"}`
+)
+
+func (ts *ExternalTestSuite) TestSignupExternalPayPal() {
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/authorize?provider=paypal", nil)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Require().Equal(http.StatusFound, w.Code)
+	u, err := url.Parse(w.Header().Get("Location"))
+	ts.Require().NoError(err, "redirect url parse failed")
+	q := u.Query()
+	ts.Equal(ts.Config.External.PayPal.RedirectURI, q.Get("redirect_uri"))
+	ts.Equal(ts.Config.External.PayPal.ClientID, []string{q.Get("client_id")})
+	ts.Equal("code", q.Get("response_type"))
+	ts.Equal("openid email https://uri.paypal.com/services/paypalattributes", q.Get("scope"))
+
+	claims := ExternalProviderClaims{}
+	p := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
+	_, err = p.ParseWithClaims(q.Get("state"), &claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(ts.Config.JWT.Secret), nil
+	})
+	ts.Require().NoError(err)
+
+	ts.Equal("paypal", claims.Provider)
+	ts.Equal(ts.Config.SiteURL, claims.SiteURL)
+}
+
+func PayPalTestSignupSetup(ts *ExternalTestSuite, tokenCount *int, userCount *int, code string, user string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/oauth2/token":
+			*tokenCount++
+			ts.Equal(code, r.FormValue("code"))
+			ts.Equal("authorization_code", r.FormValue("grant_type"))
+			ts.Equal(ts.Config.External.PayPal.RedirectURI, r.FormValue("redirect_uri"))
+
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token":"paypal_token","token_type":"Bearer","expires_in":28800,"refresh_token":"paypal_refresh_token"}`)
+		case "/v1/identity/oauth2/userinfo":
+			ts.Equal("paypalv1.1", r.URL.Query().Get("schema"))
+			*userCount++
+			ts.Equal("Bearer paypal_token", r.Header.Get("Authorization"))
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprint(w, user)
+		default:
+			w.WriteHeader(500)
+			ts.Fail("unknown paypal oauth call %s", r.URL.Path)
+		}
+	}))
+
+	ts.Config.External.PayPal.URL = server.URL
+	ts.Config.External.PayPal.ApiURL = server.URL
+
+	return server
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalPayPal_AuthorizationCode() {
+	ts.Config.DisableSignup = false
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := PayPalTestSignupSetup(ts, &tokenCount, &userCount, code, paypalUser)
+	defer server.Close()
+
+	u := performAuthorization(ts, "paypal", code, "")
+
+	assertAuthorizationSuccess(ts, u, tokenCount, userCount, "paypal@example.com", "PayPal Test", "https://www.paypal.com/webapps/auth/identity/user/mWq6_1sU85v5EG9yHdPxJRrhGHrnMJ-1PQKtX6pcsmA", "")
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalPayPalDisableSignupErrorWhenNoUser() {
+	ts.Config.DisableSignup = true
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := PayPalTestSignupSetup(ts, &tokenCount, &userCount, code, paypalUser)
+	defer server.Close()
+
+	u := performAuthorization(ts, "paypal", code, "")
+
+	assertAuthorizationFailure(ts, u, "Signups not allowed for this instance", "access_denied", "paypal@example.com")
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalPayPalDisableSignupErrorWhenNoEmail() {
+	ts.Config.DisableSignup = true
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := PayPalTestSignupSetup(ts, &tokenCount, &userCount, code, paypalUserNoEmail)
+	defer server.Close()
+
+	u := performAuthorization(ts, "paypal", code, "")
+
+	assertAuthorizationFailure(ts, u, "Error getting user email from external provider", "server_error", "paypal@example.com")
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalPayPalDisableSignupSuccessWithPrimaryEmail() {
+	ts.Config.DisableSignup = true
+
+	ts.createUser("https://www.paypal.com/webapps/auth/identity/user/mWq6_1sU85v5EG9yHdPxJRrhGHrnMJ-1PQKtX6pcsmA", "paypal@example.com", "PayPal Test", "", "")
+
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := PayPalTestSignupSetup(ts, &tokenCount, &userCount, code, paypalUser)
+	defer server.Close()
+
+	u := performAuthorization(ts, "paypal", code, "")
+
+	assertAuthorizationSuccess(ts, u, tokenCount, userCount, "paypal@example.com", "PayPal Test", "https://www.paypal.com/webapps/auth/identity/user/mWq6_1sU85v5EG9yHdPxJRrhGHrnMJ-1PQKtX6pcsmA", "")
+}
+
+func (ts *ExternalTestSuite) TestInviteTokenExternalPayPalSuccessWhenMatchingToken() {
+	// name should be populated from the PayPal user info
+	ts.createUser("https://www.paypal.com/webapps/auth/identity/user/mWq6_1sU85v5EG9yHdPxJRrhGHrnMJ-1PQKtX6pcsmA", "paypal@example.com", "", "", "invite_token")
+
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := PayPalTestSignupSetup(ts, &tokenCount, &userCount, code, paypalUser)
+	defer server.Close()
+
+	u := performAuthorization(ts, "paypal", code, "invite_token")
+
+	assertAuthorizationSuccess(ts, u, tokenCount, userCount, "paypal@example.com", "PayPal Test", "https://www.paypal.com/webapps/auth/identity/user/mWq6_1sU85v5EG9yHdPxJRrhGHrnMJ-1PQKtX6pcsmA", "")
+}
+
+func (ts *ExternalTestSuite) TestInviteTokenExternalPayPalErrorWhenEmailDoesntMatch() {
+	ts.createUser("https://www.paypal.com/webapps/auth/identity/user/mWq6_1sU85v5EG9yHdPxJRrhGHrnMJ-1PQKtX6pcsmA", "paypal@example.com", "", "", "invite_token")
+
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	paypalUser := `{"user_id":"https://www.paypal.com/webapps/auth/identity/user/mWq6_1sU85v5EG9yHdPxJRrhGHrnMJ-1PQKtX6pcsmA","name":"PayPal Test","emails":[{"value":"other@example.com","primary":true,"confirmed":true}]}`
+	server := PayPalTestSignupSetup(ts, &tokenCount, &userCount, code, paypalUser)
+	defer server.Close()
+
+	u := performAuthorization(ts, "paypal", code, "invite_token")
+
+	assertAuthorizationFailure(ts, u, "Invited email does not match emails from external provider", "invalid_request", "")
+}
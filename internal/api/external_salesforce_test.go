@@ -0,0 +1,146 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	salesforceUser        string = `{"user_id":"005xx000001SwiUAAS","organization_id":"00Dxx0000001gPLEAY","username":"salesforce@example.com.org","display_name":"Salesforce Test","first_name":"Salesforce","last_name":"Test","email":"salesforce@example.com","email_verified":true,"photos":{"picture":"http://example.com/avatar"}}`
+	salesforceUserNoEmail string = `{"user_id":"005xx000001SwiUAAS","organization_id":"00Dxx0000001gPLEAY","display_name":"Salesforce Test","photos":{"picture":"http://example.com/avatar"}}`
+)
+
+func (ts *ExternalTestSuite) TestSignupExternalSalesforce() {
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/authorize?provider=salesforce", nil)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Require().Equal(http.StatusFound, w.Code)
+	u, err := url.Parse(w.Header().Get("Location"))
+	ts.Require().NoError(err, "redirect url parse failed")
+	q := u.Query()
+	ts.Equal(ts.Config.External.Salesforce.RedirectURI, q.Get("redirect_uri"))
+	ts.Equal(ts.Config.External.Salesforce.ClientID, []string{q.Get("client_id")})
+	ts.Equal("code", q.Get("response_type"))
+	ts.Equal("id", q.Get("scope"))
+
+	claims := ExternalProviderClaims{}
+	p := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
+	_, err = p.ParseWithClaims(q.Get("state"), &claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(ts.Config.JWT.Secret), nil
+	})
+	ts.Require().NoError(err)
+
+	ts.Equal("salesforce", claims.Provider)
+	ts.Equal(ts.Config.SiteURL, claims.SiteURL)
+}
+
+func SalesforceTestSignupSetup(ts *ExternalTestSuite, tokenCount *int, userCount *int, code string, user string) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services/oauth2/token":
+			*tokenCount++
+			ts.Equal(code, r.FormValue("code"))
+			ts.Equal("authorization_code", r.FormValue("grant_type"))
+			ts.Equal(ts.Config.External.Salesforce.RedirectURI, r.FormValue("redirect_uri"))
+
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"access_token":"salesforce_token","instance_url":%q,"id":%q,"token_type":"Bearer"}`, server.URL, server.URL+"/id/00Dxx0000001gPLEAY/005xx000001SwiUAAS")
+		case "/id/00Dxx0000001gPLEAY/005xx000001SwiUAAS":
+			*userCount++
+			ts.Equal("Bearer salesforce_token", r.Header.Get("Authorization"))
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprint(w, user)
+		default:
+			w.WriteHeader(500)
+			ts.Fail("unknown salesforce oauth call %s", r.URL.Path)
+		}
+	}))
+
+	ts.Config.External.Salesforce.URL = server.URL
+
+	return server
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalSalesforce_AuthorizationCode() {
+	ts.Config.DisableSignup = false
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := SalesforceTestSignupSetup(ts, &tokenCount, &userCount, code, salesforceUser)
+	defer server.Close()
+
+	u := performAuthorization(ts, "salesforce", code, "")
+
+	assertAuthorizationSuccess(ts, u, tokenCount, userCount, "salesforce@example.com", "Salesforce Test", "005xx000001SwiUAAS", "http://example.com/avatar")
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalSalesforceDisableSignupErrorWhenNoUser() {
+	ts.Config.DisableSignup = true
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := SalesforceTestSignupSetup(ts, &tokenCount, &userCount, code, salesforceUser)
+	defer server.Close()
+
+	u := performAuthorization(ts, "salesforce", code, "")
+
+	assertAuthorizationFailure(ts, u, "Signups not allowed for this instance", "access_denied", "salesforce@example.com")
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalSalesforceDisableSignupErrorWhenNoEmail() {
+	ts.Config.DisableSignup = true
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := SalesforceTestSignupSetup(ts, &tokenCount, &userCount, code, salesforceUserNoEmail)
+	defer server.Close()
+
+	u := performAuthorization(ts, "salesforce", code, "")
+
+	assertAuthorizationFailure(ts, u, "Error getting user email from external provider", "server_error", "salesforce@example.com")
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalSalesforceDisableSignupSuccessWithPrimaryEmail() {
+	ts.Config.DisableSignup = true
+
+	ts.createUser("005xx000001SwiUAAS", "salesforce@example.com", "Salesforce Test", "http://example.com/avatar", "")
+
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := SalesforceTestSignupSetup(ts, &tokenCount, &userCount, code, salesforceUser)
+	defer server.Close()
+
+	u := performAuthorization(ts, "salesforce", code, "")
+
+	assertAuthorizationSuccess(ts, u, tokenCount, userCount, "salesforce@example.com", "Salesforce Test", "005xx000001SwiUAAS", "http://example.com/avatar")
+}
+
+func (ts *ExternalTestSuite) TestInviteTokenExternalSalesforceSuccessWhenMatchingToken() {
+	// name and avatar should be populated from the Salesforce identity URL
+	ts.createUser("005xx000001SwiUAAS", "salesforce@example.com", "", "", "invite_token")
+
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := SalesforceTestSignupSetup(ts, &tokenCount, &userCount, code, salesforceUser)
+	defer server.Close()
+
+	u := performAuthorization(ts, "salesforce", code, "invite_token")
+
+	assertAuthorizationSuccess(ts, u, tokenCount, userCount, "salesforce@example.com", "Salesforce Test", "005xx000001SwiUAAS", "http://example.com/avatar")
+}
+
+func (ts *ExternalTestSuite) TestInviteTokenExternalSalesforceErrorWhenEmailDoesntMatch() {
+	ts.createUser("005xx000001SwiUAAS", "salesforce@example.com", "", "", "invite_token")
+
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	salesforceUser := `{"user_id":"005xx000001SwiUAAS","display_name":"Salesforce Test","email":"other@example.com","email_verified":true}`
+	server := SalesforceTestSignupSetup(ts, &tokenCount, &userCount, code, salesforceUser)
+	defer server.Close()
+
+	u := performAuthorization(ts, "salesforce", code, "invite_token")
+
+	assertAuthorizationFailure(ts, u, "Invited email does not match emails from external provider", "invalid_request", "")
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/supabase/auth/internal/models"
+)
+
+const (
+	steamID     string = "76561197960435530"
+	steamPlayer string = `{"response":{"players":[{"steamid":"76561197960435530","personaname":"Steam Test","profileurl":"https://steamcommunity.com/id/steamtest/","avatarfull":"http://example.com/avatar","loccountrycode":"US"}]}}`
+)
+
+func (ts *ExternalTestSuite) TestSignupExternalSteam() {
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/authorize?provider=steam", nil)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Require().Equal(http.StatusFound, w.Code)
+	u, err := url.Parse(w.Header().Get("Location"))
+	ts.Require().NoError(err, "redirect url parse failed")
+	q := u.Query()
+	ts.Equal("http://specs.openid.net/auth/2.0", q.Get("openid.ns"))
+	ts.Equal("checkid_setup", q.Get("openid.mode"))
+	ts.Equal("https://identity.services.netlify.com", q.Get("openid.realm"))
+
+	returnTo, err := url.Parse(q.Get("openid.return_to"))
+	ts.Require().NoError(err)
+	ts.Equal(ts.Config.External.Steam.RedirectURI, returnTo.Scheme+"://"+returnTo.Host+returnTo.Path)
+
+	claims := ExternalProviderClaims{}
+	p := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
+	_, err = p.ParseWithClaims(returnTo.Query().Get("state"), &claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(ts.Config.JWT.Secret), nil
+	})
+	ts.Require().NoError(err)
+
+	ts.Equal("steam", claims.Provider)
+	ts.Equal(ts.Config.SiteURL, claims.SiteURL)
+}
+
+func SteamTestSignupSetup(ts *ExternalTestSuite, verifyCount *int, userCount *int, isValid bool) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/openid/login":
+			*verifyCount++
+			ts.Equal(http.MethodPost, r.Method)
+			ts.Equal("check_authentication", r.FormValue("openid.mode"))
+			ts.Equal("signature", r.FormValue("openid.sig"))
+
+			fmt.Fprintf(w, "ns:http://specs.openid.net/auth/2.0\nis_valid:%t\n", isValid)
+		case "/ISteamUser/GetPlayerSummaries/v0002/":
+			*userCount++
+			ts.Equal(ts.Config.External.Steam.APIKey, r.URL.Query().Get("key"))
+			ts.Equal(steamID, r.URL.Query().Get("steamids"))
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprint(w, steamPlayer)
+		default:
+			w.WriteHeader(500)
+			ts.Fail("unknown steam openid call %s", r.URL.Path)
+		}
+	}))
+
+	ts.Config.External.Steam.URL = server.URL
+	ts.Config.External.Steam.ApiURL = server.URL
+
+	return server
+}
+
+// performSteamAuthorization follows the redirect to Steam and calls back
+// with a positive assertion for the claimed SteamID, as Steam would.
+func performSteamAuthorization(ts *ExternalTestSuite, claimedID string) *url.URL {
+	w := performAuthorizationRequest(ts, "steam", "")
+	ts.Require().Equal(http.StatusFound, w.Code)
+	u, err := url.Parse(w.Header().Get("Location"))
+	ts.Require().NoError(err, "redirect url parse failed")
+	q := u.Query()
+
+	returnTo, err := url.Parse(q.Get("openid.return_to"))
+	ts.Require().NoError(err)
+
+	testURL, err := url.Parse("http://localhost/callback")
+	ts.Require().NoError(err)
+	v := returnTo.Query()
+	v.Set("openid.ns", "http://specs.openid.net/auth/2.0")
+	v.Set("openid.mode", "id_res")
+	v.Set("openid.op_endpoint", ts.Config.External.Steam.URL+"/openid/login")
+	v.Set("openid.claimed_id", claimedID)
+	v.Set("openid.identity", claimedID)
+	v.Set("openid.return_to", q.Get("openid.return_to"))
+	v.Set("openid.response_nonce", "2024-01-01T00:00:00Zabcdef")
+	v.Set("openid.assoc_handle", "1234567890")
+	v.Set("openid.signed", "signed,op_endpoint,claimed_id,identity,return_to,response_nonce,assoc_handle")
+	v.Set("openid.sig", "signature")
+	testURL.RawQuery = v.Encode()
+
+	req := httptest.NewRequest(http.MethodGet, testURL.String(), nil)
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Require().Equal(http.StatusFound, w.Code)
+	u, err = url.Parse(w.Header().Get("Location"))
+	ts.Require().NoError(err, "redirect url parse failed")
+	ts.Require().Equal("/admin", u.Path)
+
+	return u
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalSteam_Assertion() {
+	ts.Config.DisableSignup = false
+	verifyCount, userCount := 0, 0
+	server := SteamTestSignupSetup(ts, &verifyCount, &userCount, true)
+	defer server.Close()
+
+	u := performSteamAuthorization(ts, server.URL+"/openid/id/"+steamID)
+
+	v, err := url.ParseQuery(u.Fragment)
+	ts.Require().NoError(err)
+	ts.NotEmpty(v.Get("access_token"))
+	ts.NotEmpty(v.Get("refresh_token"))
+	ts.Equal(1, verifyCount)
+	ts.Equal(1, userCount)
+
+	identity, err := models.FindIdentityByIdAndProvider(ts.API.db, steamID, "steam")
+	ts.Require().NoError(err)
+	ts.Equal("Steam Test", identity.IdentityData["full_name"])
+	ts.Equal("http://example.com/avatar", identity.IdentityData["avatar_url"])
+
+	user, err := models.FindUserByID(ts.API.db, identity.UserID)
+	ts.Require().NoError(err)
+	ts.Empty(user.GetEmail())
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalSteamErrorWhenAssertionInvalid() {
+	ts.Config.DisableSignup = false
+	verifyCount, userCount := 0, 0
+	server := SteamTestSignupSetup(ts, &verifyCount, &userCount, false)
+	defer server.Close()
+
+	u := performSteamAuthorization(ts, server.URL+"/openid/id/"+steamID)
+
+	v, err := url.ParseQuery(u.RawQuery)
+	ts.Require().NoError(err)
+	ts.Equal("server_error", v.Get("error"))
+	ts.Equal(1, verifyCount)
+	ts.Equal(0, userCount)
+
+	_, err = models.FindIdentityByIdAndProvider(ts.API.db, steamID, "steam")
+	ts.Require().Error(err)
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalSteamErrorWhenClaimedIDFromOtherProvider() {
+	ts.Config.DisableSignup = false
+	verifyCount, userCount := 0, 0
+	server := SteamTestSignupSetup(ts, &verifyCount, &userCount, true)
+	defer server.Close()
+
+	u := performSteamAuthorization(ts, "https://example.com/openid/id/"+steamID)
+
+	v, err := url.ParseQuery(u.RawQuery)
+	ts.Require().NoError(err)
+	ts.Equal("server_error", v.Get("error"))
+	ts.Equal(0, verifyCount)
+	ts.Equal(0, userCount)
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalSteamDisableSignupErrorWhenNoUser() {
+	ts.Config.DisableSignup = true
+	verifyCount, userCount := 0, 0
+	server := SteamTestSignupSetup(ts, &verifyCount, &userCount, true)
+	defer server.Close()
+
+	u := performSteamAuthorization(ts, server.URL+"/openid/id/"+steamID)
+
+	v, err := url.ParseQuery(u.RawQuery)
+	ts.Require().NoError(err)
+	ts.Equal("Signups not allowed for this instance", v.Get("error_description"))
+	ts.Equal("access_denied", v.Get("error"))
+}
@@ -1,9 +1,11 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -252,3 +254,198 @@ func (ts *ExternalTestSuite) TestRedirectErrorsShouldPreserveParams() {
 		}
 	}
 }
+
+func (ts *ExternalTestSuite) TestCustomState() {
+	ts.Config.External.CustomState.Enabled = true
+	ts.Config.External.CustomState.MaxSize = 32
+	defer func() {
+		ts.Config.External.CustomState.Enabled = false
+	}()
+
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	emails := `[{"email":"github@example.com", "primary": true, "verified": true}]`
+	server := GitHubTestSignupSetup(ts, &tokenCount, &userCount, code, emails)
+	defer server.Close()
+
+	customState := url.Values{"cart": {"42"}, "tab": {"billing"}}.Encode()
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/authorize?provider=github&custom_state="+url.QueryEscape(customState), nil)
+	req.Header.Set("Referer", "https://example.netlify.com/admin")
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Require().Equal(http.StatusFound, w.Code)
+
+	u, err := url.Parse(w.Header().Get("Location"))
+	ts.Require().NoError(err)
+	// the custom state is only sent to the provider inside the signed state
+	ts.Empty(u.Query().Get("custom_state"))
+
+	testURL, err := url.Parse("http://localhost/callback")
+	ts.Require().NoError(err)
+	v := testURL.Query()
+	v.Set("code", code)
+	v.Set("state", u.Query().Get("state"))
+	testURL.RawQuery = v.Encode()
+
+	req = httptest.NewRequest(http.MethodGet, testURL.String(), nil)
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Require().Equal(http.StatusFound, w.Code)
+
+	u, err = url.Parse(w.Header().Get("Location"))
+	ts.Require().NoError(err)
+	ts.Equal("/admin", u.Path)
+	ts.Equal(customState, u.Query().Get("custom_state"))
+
+	fragment, err := url.ParseQuery(u.Fragment)
+	ts.Require().NoError(err)
+	ts.NotEmpty(fragment.Get("access_token"))
+}
+
+func (ts *ExternalTestSuite) TestCustomStateRejected() {
+	cases := []struct {
+		desc        string
+		enabled     bool
+		customState string
+	}{
+		{
+			desc:        "disabled",
+			enabled:     false,
+			customState: "cart=42",
+		},
+		{
+			desc:        "too large",
+			enabled:     true,
+			customState: "cart=" + strings.Repeat("4", 32),
+		},
+		{
+			desc:        "not url encoded",
+			enabled:     true,
+			customState: "cart=%zz",
+		},
+	}
+
+	defer func() {
+		ts.Config.External.CustomState.Enabled = false
+	}()
+
+	for _, c := range cases {
+		ts.Run(c.desc, func() {
+			ts.Config.External.CustomState.Enabled = c.enabled
+			ts.Config.External.CustomState.MaxSize = 32
+
+			req := httptest.NewRequest(http.MethodGet, "http://localhost/authorize?provider=github&custom_state="+url.QueryEscape(c.customState), nil)
+			w := httptest.NewRecorder()
+			ts.API.handler.ServeHTTP(w, req)
+			ts.Equal(http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func (ts *ExternalTestSuite) TestRequestedScopes() {
+	ts.Config.External.Github.AllowedScopes = []string{"repo"}
+	defer func() {
+		ts.Config.External.Github.AllowedScopes = nil
+	}()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/login/oauth/access_token":
+			fmt.Fprint(w, `{"access_token":"github_token","scope":"repo,user:email","token_type":"bearer"}`)
+		case "/api/v3/user":
+			fmt.Fprint(w, `{"id":123,"name":"GitHub Test","avatar_url":"http://example.com/avatar"}`)
+		case "/api/v3/user/emails":
+			fmt.Fprint(w, `[{"email":"github@example.com","primary":true,"verified":true}]`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			ts.Fail("unknown github oauth call %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	ts.Config.External.Github.URL = server.URL
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/authorize?provider=github&scopes=gist", nil)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Require().Equal(http.StatusBadRequest, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/authorize?provider=github&scopes=repo", nil)
+	req.Header.Set("Referer", "https://example.netlify.com/admin")
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Require().Equal(http.StatusFound, w.Code)
+
+	u, err := url.Parse(w.Header().Get("Location"))
+	ts.Require().NoError(err)
+	ts.Equal("user:email repo", u.Query().Get("scope"))
+
+	testURL, err := url.Parse("http://localhost/callback")
+	ts.Require().NoError(err)
+	v := testURL.Query()
+	v.Set("code", "authcode")
+	v.Set("state", u.Query().Get("state"))
+	testURL.RawQuery = v.Encode()
+
+	req = httptest.NewRequest(http.MethodGet, testURL.String(), nil)
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Require().Equal(http.StatusFound, w.Code)
+
+	identity, err := models.FindIdentityByIdAndProvider(ts.API.db, "123", "github")
+	ts.Require().NoError(err)
+	ts.Equal("repo user:email", identity.Scopes.String())
+}
+
+func (ts *ExternalTestSuite) TestHostedPages() {
+	ts.Config.External.HostedPages.Enabled = true
+	ts.Config.External.HostedPages.SiteName = "Acme"
+	ts.Require().NoError(ts.Config.External.HostedPages.PopulateFields())
+	defer func() {
+		ts.Config.External.HostedPages.Enabled = false
+	}()
+
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	emails := `[{"email":"github@example.com", "primary": true, "verified": true}]`
+	server := GitHubTestSignupSetup(ts, &tokenCount, &userCount, code, emails)
+	defer server.Close()
+
+	w := performAuthorizationRequest(ts, "github", "")
+	ts.Require().Equal(http.StatusFound, w.Code)
+	u, err := url.Parse(w.Header().Get("Location"))
+	ts.Require().NoError(err)
+
+	testURL, err := url.Parse("http://localhost/callback")
+	ts.Require().NoError(err)
+	v := testURL.Query()
+	v.Set("code", code)
+	v.Set("state", u.Query().Get("state"))
+	testURL.RawQuery = v.Encode()
+
+	// signing in renders the interstitial linking back to the app
+	req := httptest.NewRequest(http.MethodGet, testURL.String(), nil)
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Require().Equal(http.StatusOK, w.Code)
+	ts.Equal("text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	ts.Equal("no-store", w.Header().Get("Cache-Control"))
+	ts.Contains(w.Body.String(), "Return to Acme")
+	ts.Contains(w.Body.String(), `href="https://example.netlify.com/admin#access_token=`)
+
+	// errors returned by the provider render the error page
+	v = testURL.Query()
+	v.Set("error", "access_denied")
+	v.Set("error_description", "The user denied the request")
+	v.Set("state", u.Query().Get("state"))
+	testURL.RawQuery = v.Encode()
+
+	req = httptest.NewRequest(http.MethodGet, testURL.String(), nil)
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Require().Equal(http.StatusBadRequest, w.Code)
+	ts.Contains(w.Body.String(), "Unable to sign in")
+	ts.Contains(w.Body.String(), "The user denied the request")
+	ts.Contains(w.Body.String(), "error=access_denied")
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/supabase/auth/internal/models"
+)
+
+const (
+	vkUser        string = `{"response":[{"id":123456789,"first_name":"VK","last_name":"Test","screen_name":"vktest","photo_200":"http://example.com/avatar","sex":1}]}`
+	vkTokenFields string = `"access_token":"vk_token","expires_in":86400,"user_id":123456789`
+)
+
+func (ts *ExternalTestSuite) TestSignupExternalVK() {
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/authorize?provider=vk", nil)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Require().Equal(http.StatusFound, w.Code)
+	u, err := url.Parse(w.Header().Get("Location"))
+	ts.Require().NoError(err, "redirect url parse failed")
+	q := u.Query()
+	ts.Equal(ts.Config.External.VK.RedirectURI, q.Get("redirect_uri"))
+	ts.Equal(ts.Config.External.VK.ClientID, []string{q.Get("client_id")})
+	ts.Equal("code", q.Get("response_type"))
+	ts.Equal("email", q.Get("scope"))
+
+	claims := ExternalProviderClaims{}
+	p := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
+	_, err = p.ParseWithClaims(q.Get("state"), &claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(ts.Config.JWT.Secret), nil
+	})
+	ts.Require().NoError(err)
+
+	ts.Equal("vk", claims.Provider)
+	ts.Equal(ts.Config.SiteURL, claims.SiteURL)
+}
+
+// VKTestSignupSetup mocks VK, which returns the email address with the
+// access token when the user granted the email permission.
+func VKTestSignupSetup(ts *ExternalTestSuite, tokenCount *int, userCount *int, code string, email string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/access_token":
+			*tokenCount++
+			ts.Equal(code, r.FormValue("code"))
+			ts.Equal(ts.Config.External.VK.RedirectURI, r.FormValue("redirect_uri"))
+			ts.Equal(ts.Config.External.VK.ClientID[0], r.FormValue("client_id"))
+
+			w.Header().Add("Content-Type", "application/json")
+			if email != "" {
+				fmt.Fprintf(w, `{%s,"email":%q}`, vkTokenFields, email)
+			} else {
+				fmt.Fprintf(w, `{%s}`, vkTokenFields)
+			}
+		case "/method/users.get":
+			*userCount++
+			ts.Equal("vk_token", r.URL.Query().Get("access_token"))
+			ts.NotEmpty(r.URL.Query().Get("v"))
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprint(w, vkUser)
+		default:
+			w.WriteHeader(500)
+			ts.Fail("unknown vk oauth call %s", r.URL.Path)
+		}
+	}))
+
+	ts.Config.External.VK.URL = server.URL
+	ts.Config.External.VK.ApiURL = server.URL
+
+	return server
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalVK_AuthorizationCode() {
+	ts.Config.DisableSignup = false
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := VKTestSignupSetup(ts, &tokenCount, &userCount, code, "vk@example.com")
+	defer server.Close()
+
+	u := performAuthorization(ts, "vk", code, "")
+
+	assertAuthorizationSuccess(ts, u, tokenCount, userCount, "vk@example.com", "VK Test", "123456789", "http://example.com/avatar")
+
+	user, err := models.FindUserByEmailAndAudience(ts.API.db, "vk@example.com", ts.Config.JWT.Aud)
+	ts.Require().NoError(err)
+	ts.Equal("vktest", user.UserMetaData["preferred_username"])
+	ts.Equal("female", user.UserMetaData["gender"])
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalVKWithoutEmailPermission() {
+	ts.Config.DisableSignup = false
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := VKTestSignupSetup(ts, &tokenCount, &userCount, code, "")
+	defer server.Close()
+
+	u := performAuthorization(ts, "vk", code, "")
+
+	v, err := url.ParseQuery(u.Fragment)
+	ts.Require().NoError(err)
+	ts.NotEmpty(v.Get("access_token"))
+
+	identity, err := models.FindIdentityByIdAndProvider(ts.API.db, "123456789", "vk")
+	ts.Require().NoError(err)
+
+	user, err := models.FindUserByID(ts.API.db, identity.UserID)
+	ts.Require().NoError(err)
+	ts.Empty(user.GetEmail())
+	ts.Equal("VK Test", user.UserMetaData["full_name"])
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalVKDisableSignupErrorWhenNoUser() {
+	ts.Config.DisableSignup = true
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := VKTestSignupSetup(ts, &tokenCount, &userCount, code, "vk@example.com")
+	defer server.Close()
+
+	u := performAuthorization(ts, "vk", code, "")
+
+	assertAuthorizationFailure(ts, u, "Signups not allowed for this instance", "access_denied", "vk@example.com")
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalVKDisableSignupSuccessWithPrimaryEmail() {
+	ts.Config.DisableSignup = true
+
+	ts.createUser("123456789", "vk@example.com", "VK Test", "http://example.com/avatar", "")
+
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := VKTestSignupSetup(ts, &tokenCount, &userCount, code, "vk@example.com")
+	defer server.Close()
+
+	u := performAuthorization(ts, "vk", code, "")
+
+	assertAuthorizationSuccess(ts, u, tokenCount, userCount, "vk@example.com", "VK Test", "123456789", "http://example.com/avatar")
+}
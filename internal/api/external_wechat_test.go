@@ -0,0 +1,220 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/supabase/auth/internal/models"
+)
+
+const (
+	weChatUnionID string = "o6_bmasdasdsad6_2sgVt7hMZOPfL"
+	weChatUser    string = `{"openid":"OPENID","nickname":"WeChat Test","sex":1,"province":"Guangdong","city":"Shenzhen","country":"CN","headimgurl":"http://example.com/avatar","privilege":[],"unionid":"o6_bmasdasdsad6_2sgVt7hMZOPfL"}`
+	// weChatUserNoUnionID is a user of an application that isn't bound
+	// to an Open Platform account
+	weChatUserNoUnionID string = `{"openid":"OPENID","nickname":"WeChat Test","headimgurl":"http://example.com/avatar","privilege":[]}`
+)
+
+func (ts *ExternalTestSuite) TestSignupExternalWeChat() {
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/authorize?provider=wechat", nil)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	ts.Require().Equal(http.StatusFound, w.Code)
+	u, err := url.Parse(w.Header().Get("Location"))
+	ts.Require().NoError(err, "redirect url parse failed")
+	ts.Equal("/connect/qrconnect", u.Path)
+	ts.Equal("wechat_redirect", u.Fragment)
+
+	q := u.Query()
+	ts.Equal(ts.Config.External.WeChat.RedirectURI, q.Get("redirect_uri"))
+	ts.Equal(ts.Config.External.WeChat.ClientID, []string{q.Get("appid")})
+	ts.Empty(q.Get("client_id"))
+	ts.Equal("code", q.Get("response_type"))
+	ts.Equal("snsapi_login", q.Get("scope"))
+
+	claims := ExternalProviderClaims{}
+	p := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
+	_, err = p.ParseWithClaims(q.Get("state"), &claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(ts.Config.JWT.Secret), nil
+	})
+	ts.Require().NoError(err)
+
+	ts.Equal("wechat", claims.Provider)
+	ts.Equal(ts.Config.SiteURL, claims.SiteURL)
+}
+
+func WeChatTestSignupSetup(ts *ExternalTestSuite, tokenCount *int, userCount *int, appID string, code string, user string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/sns/oauth2/access_token":
+			*tokenCount++
+			q := r.URL.Query()
+			ts.Equal(appID, q.Get("appid"))
+			ts.Equal("authorization_code", q.Get("grant_type"))
+			if q.Get("code") != code {
+				// WeChat returns errors with a 200 status
+				fmt.Fprint(w, `{"errcode":40029,"errmsg":"invalid code"}`)
+				return
+			}
+
+			fmt.Fprint(w, `{"access_token":"wechat_token","expires_in":7200,"refresh_token":"wechat_refresh_token","openid":"OPENID","scope":"snsapi_login"}`)
+		case "/sns/userinfo":
+			*userCount++
+			ts.Equal("wechat_token", r.URL.Query().Get("access_token"))
+			ts.Equal("OPENID", r.URL.Query().Get("openid"))
+			fmt.Fprint(w, user)
+		default:
+			w.WriteHeader(500)
+			ts.Fail("unknown wechat oauth call %s", r.URL.Path)
+		}
+	}))
+
+	ts.Config.External.WeChat.URL = server.URL
+	ts.Config.External.WeChat.ApiURL = server.URL
+
+	return server
+}
+
+func (ts *ExternalTestSuite) assertWeChatUser(subject string) *models.User {
+	identity, err := models.FindIdentityByIdAndProvider(ts.API.db, subject, "wechat")
+	ts.Require().NoError(err)
+	ts.Equal("WeChat Test", identity.IdentityData["full_name"])
+	ts.Equal("http://example.com/avatar", identity.IdentityData["avatar_url"])
+
+	user, err := models.FindUserByID(ts.API.db, identity.UserID)
+	ts.Require().NoError(err)
+	ts.Empty(user.GetEmail())
+
+	return user
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalWeChat_AuthorizationCode() {
+	ts.Config.DisableSignup = false
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := WeChatTestSignupSetup(ts, &tokenCount, &userCount, ts.Config.External.WeChat.ClientID[0], code, weChatUser)
+	defer server.Close()
+
+	u := performAuthorization(ts, "wechat", code, "")
+
+	v, err := url.ParseQuery(u.Fragment)
+	ts.Require().NoError(err)
+	ts.NotEmpty(v.Get("access_token"))
+	ts.NotEmpty(v.Get("refresh_token"))
+	ts.Equal(1, tokenCount)
+	ts.Equal(1, userCount)
+
+	user := ts.assertWeChatUser(weChatUnionID)
+	ts.Equal(weChatUnionID, user.UserMetaData["provider_id"])
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalWeChatWithoutUnionID() {
+	ts.Config.DisableSignup = false
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := WeChatTestSignupSetup(ts, &tokenCount, &userCount, ts.Config.External.WeChat.ClientID[0], code, weChatUserNoUnionID)
+	defer server.Close()
+
+	performAuthorization(ts, "wechat", code, "")
+
+	ts.assertWeChatUser("OPENID")
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalWeChatErrorWhenCodeInvalid() {
+	ts.Config.DisableSignup = false
+	tokenCount, userCount := 0, 0
+	server := WeChatTestSignupSetup(ts, &tokenCount, &userCount, ts.Config.External.WeChat.ClientID[0], "authcode", weChatUser)
+	defer server.Close()
+
+	u := performAuthorization(ts, "wechat", "othercode", "")
+
+	v, err := url.ParseQuery(u.RawQuery)
+	ts.Require().NoError(err)
+	ts.Equal("server_error", v.Get("error"))
+	ts.Equal(1, tokenCount)
+	ts.Equal(0, userCount)
+}
+
+func (ts *ExternalTestSuite) TestSignupExternalWeChatDisableSignupErrorWhenNoUser() {
+	ts.Config.DisableSignup = true
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := WeChatTestSignupSetup(ts, &tokenCount, &userCount, ts.Config.External.WeChat.ClientID[0], code, weChatUser)
+	defer server.Close()
+
+	u := performAuthorization(ts, "wechat", code, "")
+
+	v, err := url.ParseQuery(u.RawQuery)
+	ts.Require().NoError(err)
+	ts.Equal("Signups not allowed for this instance", v.Get("error_description"))
+	ts.Equal("access_denied", v.Get("error"))
+}
+
+func (ts *ExternalTestSuite) weChatGrant(code string) *httptest.ResponseRecorder {
+	var buffer bytes.Buffer
+	ts.Require().NoError(json.NewEncoder(&buffer).Encode(map[string]interface{}{
+		"code": code,
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/token?grant_type=wechat", &buffer)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	return w
+}
+
+func (ts *ExternalTestSuite) TestWeChatGrant() {
+	ts.Config.DisableSignup = false
+	tokenCount, userCount := 0, 0
+	code := "mobilecode"
+	server := WeChatTestSignupSetup(ts, &tokenCount, &userCount, ts.Config.External.WeChat.MobileAppID, code, weChatUser)
+	defer server.Close()
+
+	w := ts.weChatGrant(code)
+	ts.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var token AccessTokenResponse
+	ts.Require().NoError(json.NewDecoder(w.Body).Decode(&token))
+	ts.NotEmpty(token.Token)
+	ts.NotEmpty(token.RefreshToken)
+
+	user := ts.assertWeChatUser(weChatUnionID)
+	ts.Equal(user.ID, token.User.ID)
+}
+
+func (ts *ExternalTestSuite) TestWeChatGrantSameIdentityAsWebsite() {
+	ts.Config.DisableSignup = false
+	tokenCount, userCount := 0, 0
+	code := "authcode"
+	server := WeChatTestSignupSetup(ts, &tokenCount, &userCount, ts.Config.External.WeChat.ClientID[0], code, weChatUser)
+	defer server.Close()
+
+	performAuthorization(ts, "wechat", code, "")
+	websiteUser := ts.assertWeChatUser(weChatUnionID)
+
+	mobileServer := WeChatTestSignupSetup(ts, &tokenCount, &userCount, ts.Config.External.WeChat.MobileAppID, code, weChatUser)
+	defer mobileServer.Close()
+
+	w := ts.weChatGrant(code)
+	ts.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var token AccessTokenResponse
+	ts.Require().NoError(json.NewDecoder(w.Body).Decode(&token))
+	ts.Equal(websiteUser.ID, token.User.ID)
+}
+
+func (ts *ExternalTestSuite) TestWeChatGrantErrorWhenCodeInvalid() {
+	tokenCount, userCount := 0, 0
+	server := WeChatTestSignupSetup(ts, &tokenCount, &userCount, ts.Config.External.WeChat.MobileAppID, "mobilecode", weChatUser)
+	defer server.Close()
+
+	w := ts.weChatGrant("othercode")
+	ts.Equal(http.StatusBadRequest, w.Code)
+	ts.Equal(0, userCount)
+}
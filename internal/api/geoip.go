@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/utilities"
+)
+
+// geoIPLocator locates IP addresses with a GeoIP database.
+type geoIPLocator interface {
+	Locate(ip string) (*utilities.GeoLocation, error)
+}
+
+// locateIP returns where the IP address is, when there's a GeoIP database
+// and it knows the address. Errors are only logged, as sessions don't need
+// their location.
+func (a *API) locateIP(r *http.Request, ip string) *utilities.GeoLocation {
+	if a.geoIP == nil || ip == "" {
+		return nil
+	}
+
+	location, err := a.geoIP.Locate(ip)
+	if err != nil {
+		observability.GetLogEntry(r).Entry.WithError(err).Warn("Unable to locate IP address")
+		return nil
+	}
+
+	return location
+}
@@ -63,25 +63,47 @@ func getBodyBytes(req *http.Request) ([]byte, error) {
 }
 
 type RequestParams interface {
-	AdminUserParams |
+	AccountDeletionParams |
+		AdminUserParams |
+		AdminCredentialParams |
+		AdminEmailSuppressionParams |
+		AdminEmailTemplateParams |
+		BulkInviteParams |
+		ConsentParams |
 		CreateSSOProviderParams |
 		EnrollFactorParams |
 		GenerateLinkParams |
 		IdTokenGrantParams |
+		IdentitySyncParams |
 		InviteParams |
+		OrganizationInvitationParams |
+		OrganizationMemberParams |
+		OrganizationParams |
 		OtpParams |
 		PKCEGrantParams |
 		PasswordGrantParams |
 		RecoverParams |
 		RefreshTokenGrantParams |
 		ResendConfirmationParams |
+		RoleParams |
 		SignupParams |
+		SAMLLogoutParams |
 		SingleSignOnParams |
 		SmsParams |
+		TelegramGrantParams |
+		TestSSOProviderParams |
+		UserEmailAddParams |
+		UserEmailVerifyParams |
+		UserNoteParams |
+		UserRoleParams |
+		UserTagParams |
 		UserUpdateParams |
 		VerifyFactorParams |
 		VerifyParams |
+		Web3GrantParams |
+		WeChatGrantParams |
 		adminUserUpdateFactorParams |
+		adminUserImpersonateParams |
 		ChallengeFactorParams |
 		struct {
 			Email string `json:"email"`
@@ -0,0 +1,291 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/hooks"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	// hookRetryBatchSize is how many queued messages are retried at once.
+	hookRetryBatchSize = 10
+
+	// hookRetryLease is how long the messages being retried are hidden from
+	// other instances.
+	hookRetryLease = time.Minute
+)
+
+var hookRetryCounter = observability.ObtainMetricCounter("gotrue_hook_retries", "Number of retried hook messages by hook and result")
+
+// queueHookRetry queues the message of a send email or send SMS HTTP hook
+// that couldn't be reached, so it's retried in the background instead of
+// being lost. It returns the error of the hook when the message can't be
+// queued, and nil when it was.
+func (a *API) queueHookRetry(conn *storage.Connection, hookConfig conf.ExtensibilityPointConfiguration, input any, hookErr error) error {
+	config := a.config
+
+	// postgres hooks run in the transaction of the request, which is aborted
+	// when they fail
+	if !config.Hook.Retry.Enabled || !(strings.HasPrefix(hookConfig.URI, "http:") || strings.HasPrefix(hookConfig.URI, "https:")) {
+		return hookErr
+	}
+
+	if conn == nil {
+		conn = a.db
+	}
+
+	var hookName string
+	var user *models.User
+	var expiresIn time.Duration
+
+	switch input := input.(type) {
+	case *hooks.SendSMSInput:
+		hookName, user = models.HookSendSMS, input.User
+		expiresIn = time.Duration(config.Sms.OtpExp) * time.Second
+		if input.SMS.SMSType == "mfa" {
+			expiresIn = time.Duration(config.MFA.ChallengeExpiryDuration) * time.Second
+		}
+	case *hooks.SendEmailInput:
+		hookName, user = models.HookSendEmail, input.User
+		expiresIn = time.Duration(config.Mailer.OtpExp) * time.Second
+	default:
+		return hookErr
+	}
+
+	payload, err := json.Marshal(input)
+	if err != nil {
+		return hookErr
+	}
+
+	var userID *uuid.UUID
+	if user != nil {
+		userID = &user.ID
+	}
+
+	now := a.Now()
+	delivery, err := models.NewHookDelivery(hookName, userID, payload, hookErr.Error(), now.Add(config.Hook.Retry.Backoff(1)), now.Add(expiresIn), config.Security.DBEncryption.Encrypt, config.Security.DBEncryption.EncryptionKeyID, config.Security.DBEncryption.EncryptionKey)
+	if err != nil {
+		logrus.WithError(err).Error("Unable to queue hook message for retry")
+		return hookErr
+	}
+
+	if err := conn.Create(delivery); err != nil {
+		logrus.WithError(err).Error("Unable to queue hook message for retry")
+		return hookErr
+	}
+
+	logrus.WithError(hookErr).WithFields(logrus.Fields{
+		"hook":        hookName,
+		"delivery_id": delivery.ID,
+	}).Warn("Hook failed, message queued for retry")
+
+	return nil
+}
+
+// RetryHooks retries the queued messages of the send email and send SMS
+// hooks until the context is done.
+func (a *API) RetryHooks(ctx context.Context) {
+	if !a.config.Hook.Retry.Enabled {
+		return
+	}
+
+	ticker := time.NewTicker(a.config.Hook.Retry.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			a.retryHookDeliveries(ctx)
+		}
+	}
+}
+
+func (a *API) retryHookDeliveries(ctx context.Context) {
+	db := a.db.WithContext(ctx)
+
+	deliveries, err := models.ClaimDueHookDeliveries(db, hookRetryBatchSize, hookRetryLease)
+	if err != nil {
+		logrus.WithError(err).Error("Unable to claim hook messages to retry")
+		return
+	}
+
+	for _, delivery := range deliveries {
+		result, err := a.retryHookDelivery(ctx, db, delivery)
+		if err != nil {
+			logrus.WithError(err).WithField("delivery_id", delivery.ID).Error("Unable to retry hook message")
+			continue
+		}
+
+		hookRetryCounter.Add(ctx, 1, metric.WithAttributeSet(attribute.NewSet(attribute.String("hook", delivery.HookName), attribute.String("result", result))))
+	}
+}
+
+// retryHookDelivery attempts to deliver the message to its hook again. It
+// returns whether the message was delivered, retried later or moved to the
+// dead letters.
+func (a *API) retryHookDelivery(ctx context.Context, db *storage.Connection, delivery *models.HookDelivery) (string, error) {
+	config := a.config
+	now := a.Now()
+
+	// the OTP of expired messages can't be used anymore
+	if now.After(delivery.ExpiresAt) {
+		return models.HookDeadLetterExpired, delivery.DeadLetter(db, models.HookDeadLetterExpired)
+	}
+
+	hookConfig := config.Hook.SendEmail
+	if delivery.HookName == models.HookSendSMS {
+		hookConfig = config.Hook.SendSMS
+	}
+
+	payload, err := delivery.GetPayload(config.Security.DBEncryption.DecryptionKeys)
+	if err != nil {
+		return "", err
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", nil)
+	if err != nil {
+		return "", err
+	}
+
+	response, err := a.runHTTPHook(r, hookConfig, json.RawMessage(payload))
+	if err != nil {
+		if delivery.Attempts+1 >= config.Hook.Retry.MaxAttempts {
+			delivery.Attempts += 1
+			delivery.LastError = storage.NullString(err.Error())
+			return models.HookDeadLetterMaxAttempts, delivery.DeadLetter(db, models.HookDeadLetterMaxAttempts)
+		}
+
+		return "retried", delivery.Retry(db, err.Error(), now.Add(config.Hook.Retry.Backoff(delivery.Attempts+1)))
+	}
+
+	// errors returned by the hook are deliberate, so the message is not
+	// retried. The send email and send SMS hooks respond with the same
+	// output.
+	output := hooks.SendEmailOutput{}
+	if len(response) > 0 {
+		if err := json.Unmarshal(response, &output); err != nil {
+			return "", err
+		}
+	}
+
+	if output.IsError() {
+		delivery.Attempts += 1
+		delivery.LastError = storage.NullString(output.HookError.Message)
+		return models.HookDeadLetterRejected, delivery.DeadLetter(db, models.HookDeadLetterRejected)
+	}
+
+	if err := db.Destroy(delivery); err != nil {
+		return "", err
+	}
+
+	return "delivered", nil
+}
+
+type AdminListHookDeadLettersResponse struct {
+	DeadLetters []*models.HookDeadLetter `json:"dead_letters"`
+}
+
+type AdminHookDeadLetterResponse struct {
+	*models.HookDeadLetter
+
+	Payload json.RawMessage `json:"payload"`
+}
+
+func (a *API) loadHookDeadLetter(db *storage.Connection, r *http.Request) (*models.HookDeadLetter, error) {
+	deadLetterID, err := uuid.FromString(chi.URLParam(r, "dead_letter_id"))
+	if err != nil {
+		return nil, notFoundError(ErrorCodeValidationFailed, "dead_letter_id must be an UUID")
+	}
+
+	deadLetter, err := models.FindHookDeadLetterByID(db, deadLetterID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, notFoundError(ErrorCodeHookDeadLetterNotFound, "Hook dead letter not found")
+		}
+		return nil, internalServerError("Database error finding hook dead letter").WithInternalError(err)
+	}
+
+	return deadLetter, nil
+}
+
+// adminHookDeadLettersList lists the messages of the send email and send SMS
+// hooks that could not be delivered, the most recent first. They can be
+// filtered with the hook_name query parameter.
+func (a *API) adminHookDeadLettersList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	hookName := r.URL.Query().Get("hook_name")
+	if hookName != "" && hookName != models.HookSendEmail && hookName != models.HookSendSMS {
+		return badRequestError(ErrorCodeValidationFailed, "hook_name must be either %s or %s", models.HookSendEmail, models.HookSendSMS)
+	}
+
+	pageParams, err := paginate(r)
+	if err != nil {
+		return badRequestError(ErrorCodeValidationFailed, "Bad Pagination Parameters: %v", err).WithInternalError(err)
+	}
+
+	deadLetters, err := models.FindHookDeadLetters(db, hookName, pageParams)
+	if err != nil {
+		return internalServerError("Database error finding hook dead letters").WithInternalError(err)
+	}
+	addPaginationHeaders(w, r, pageParams)
+
+	return sendJSON(w, http.StatusOK, AdminListHookDeadLettersResponse{
+		DeadLetters: deadLetters,
+	})
+}
+
+// adminHookDeadLetterGet returns a dead letter with the input that was sent
+// to the hook.
+func (a *API) adminHookDeadLetterGet(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	deadLetter, err := a.loadHookDeadLetter(db, r)
+	if err != nil {
+		return err
+	}
+
+	payload, err := deadLetter.GetPayload(a.config.Security.DBEncryption.DecryptionKeys)
+	if err != nil {
+		return internalServerError("Unable to decrypt hook dead letter").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, AdminHookDeadLetterResponse{
+		HookDeadLetter: deadLetter,
+		Payload:        payload,
+	})
+}
+
+func (a *API) adminHookDeadLetterDelete(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	deadLetter, err := a.loadHookDeadLetter(db, r)
+	if err != nil {
+		return err
+	}
+
+	if err := db.Destroy(deadLetter); err != nil {
+		return internalServerError("Database error deleting hook dead letter").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
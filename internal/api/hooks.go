@@ -197,7 +197,7 @@ func (a *API) invokeHook(conn *storage.Connection, r *http.Request, input, outpu
 			panic("output should be *hooks.SendSMSOutput")
 		}
 		if response, err = a.runHook(r, conn, a.config.Hook.SendSMS, input, output); err != nil {
-			return err
+			return a.queueHookRetry(conn, a.config.Hook.SendSMS, input, err)
 		}
 		if err := json.Unmarshal(response, hookOutput); err != nil {
 			return internalServerError("Error unmarshaling Send SMS output.").WithInternalError(err)
@@ -221,7 +221,7 @@ func (a *API) invokeHook(conn *storage.Connection, r *http.Request, input, outpu
 			panic("output should be *hooks.SendEmailOutput")
 		}
 		if response, err = a.runHook(r, conn, a.config.Hook.SendEmail, input, output); err != nil {
-			return err
+			return a.queueHookRetry(conn, a.config.Hook.SendEmail, input, err)
 		}
 		if err := json.Unmarshal(response, hookOutput); err != nil {
 			return internalServerError("Error unmarshaling Send Email output.").WithInternalError(err)
@@ -335,6 +335,34 @@ func (a *API) invokeHook(conn *storage.Connection, r *http.Request, input, outpu
 			return httpError
 		}
 		return nil
+	case *hooks.AccountDeletionInput:
+		hookOutput, ok := output.(*hooks.AccountDeletionOutput)
+		if !ok {
+			panic("output should be *hooks.AccountDeletionOutput")
+		}
+		if response, err = a.runHook(r, conn, a.config.Hook.AccountDeletion, input, output); err != nil {
+			return err
+		}
+		if len(response) > 0 {
+			if err := json.Unmarshal(response, hookOutput); err != nil {
+				return internalServerError("Error unmarshaling Account Deletion output.").WithInternalError(err)
+			}
+		}
+		if hookOutput.IsError() {
+			httpCode := hookOutput.HookError.HTTPCode
+
+			if httpCode == 0 {
+				httpCode = http.StatusInternalServerError
+			}
+
+			httpError := &HTTPError{
+				HTTPStatus: httpCode,
+				Message:    hookOutput.HookError.Message,
+			}
+
+			return httpError.WithInternalError(&hookOutput.HookError)
+		}
+		return nil
 	}
 	return nil
 }
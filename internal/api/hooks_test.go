@@ -1,12 +1,15 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"testing"
+	"time"
 
 	"net/http/httptest"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -285,3 +288,98 @@ func (ts *HooksTestSuite) TestInvokeHookIntegration() {
 	// Ensure that all expected HTTP interactions (mocks) have been called
 	require.True(ts.T(), gock.IsDone(), "Expected all mocks to have been called including retry")
 }
+
+func (ts *HooksTestSuite) TestHookRetryQueue() {
+	defer gock.OffAll()
+
+	ts.Config.Hook.SendSMS.Enabled = true
+	ts.Config.Hook.SendSMS.URI = "http://localhost:54321/functions/v1/custom-sms-sender"
+	require.NoError(ts.T(), ts.Config.Hook.SendSMS.PopulateExtensibilityPoint())
+	ts.Config.Hook.Retry = conf.HookRetryConfiguration{
+		Enabled:        true,
+		MaxAttempts:    2,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		Interval:       time.Second,
+	}
+	defer func() {
+		ts.Config.Hook.Retry.Enabled = false
+	}()
+
+	input := &hooks.SendSMSInput{
+		User: ts.TestUser,
+		SMS: hooks.SMS{
+			OTP: "123456",
+		},
+	}
+
+	makeDue := func() {
+		require.NoError(ts.T(), ts.API.db.RawQuery("update hook_deliveries set next_attempt_at = now() - interval '1 second'").Exec())
+	}
+
+	// messages the hook fails to receive are queued instead of failing
+	gock.New(ts.Config.Hook.SendSMS.URI).Post("/").Reply(http.StatusBadRequest).JSON(map[string]interface{}{})
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/otp", nil)
+	require.NoError(ts.T(), ts.API.invokeHook(nil, req, input, &hooks.SendSMSOutput{}))
+
+	var deliveries []*models.HookDelivery
+	require.NoError(ts.T(), ts.API.db.All(&deliveries))
+	require.Len(ts.T(), deliveries, 1)
+	require.Equal(ts.T(), models.HookSendSMS, deliveries[0].HookName)
+	require.Equal(ts.T(), ts.TestUser.ID, *deliveries[0].UserID)
+	require.Equal(ts.T(), 1, deliveries[0].Attempts)
+
+	// and delivered when retried
+	gock.New(ts.Config.Hook.SendSMS.URI).Post("/").MatchType("json").Reply(http.StatusOK).JSON(hooks.SendSMSOutput{})
+
+	makeDue()
+	ts.API.retryHookDeliveries(context.Background())
+	require.NoError(ts.T(), ts.API.db.All(&deliveries))
+	require.Len(ts.T(), deliveries, 0)
+
+	// messages that keep failing are moved to the dead letters
+	gock.New(ts.Config.Hook.SendSMS.URI).Post("/").Times(2).Reply(http.StatusBadRequest).JSON(map[string]interface{}{})
+	require.NoError(ts.T(), ts.API.invokeHook(nil, req, input, &hooks.SendSMSOutput{}))
+
+	makeDue()
+	ts.API.retryHookDeliveries(context.Background())
+	require.NoError(ts.T(), ts.API.db.All(&deliveries))
+	require.Len(ts.T(), deliveries, 0)
+
+	deadLetters, err := models.FindHookDeadLetters(ts.API.db, models.HookSendSMS, nil)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), deadLetters, 1)
+	require.Equal(ts.T(), models.HookDeadLetterMaxAttempts, deadLetters[0].Reason)
+	require.Equal(ts.T(), 2, deadLetters[0].Attempts)
+
+	// which admins can inspect
+	adminToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &AccessTokenClaims{
+		Role: "supabase_admin",
+	}).SignedString([]byte(ts.Config.JWT.Secret))
+	require.NoError(ts.T(), err)
+
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/admin/hooks/dead_letters/"+deadLetters[0].ID.String(), nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var deadLetter struct {
+		Reason  string             `json:"reason"`
+		Payload hooks.SendSMSInput `json:"payload"`
+	}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&deadLetter))
+	require.Equal(ts.T(), models.HookDeadLetterMaxAttempts, deadLetter.Reason)
+	require.Equal(ts.T(), "123456", deadLetter.Payload.SMS.OTP)
+
+	req = httptest.NewRequest(http.MethodDelete, "http://localhost/admin/hooks/dead_letters/"+deadLetters[0].ID.String(), nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	deadLetters, err = models.FindHookDeadLetters(ts.API.db, "", nil)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), deadLetters, 0)
+}
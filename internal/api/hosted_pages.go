@@ -0,0 +1,99 @@
+package api
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/url"
+)
+
+// HostedPageData is the data the hosted page templates are executed with.
+type HostedPageData struct {
+	SiteName string
+	LogoURL  string
+	SiteURL  string
+	Provider string
+
+	// RedirectURL returns the user to the app, with the result of the
+	// flow in its query or fragment like the redirect would have.
+	RedirectURL template.URL
+
+	Error            string
+	ErrorDescription string
+	ErrorCode        string
+}
+
+// hostedPageErrors is like redirectErrors, but renders the error page
+// linking back to the app instead of redirecting to it.
+func (a *API) hostedPageErrors(handler apiHandler, w http.ResponseWriter, r *http.Request, u *url.URL) {
+	if err := handler(w, r); err != nil {
+		if rerr := a.renderHostedErrorPage(w, r, err, u); rerr != nil {
+			HandleResponseError(rerr, w, r)
+		}
+	}
+}
+
+// renderHostedErrorPage renders the error page, or the confirmation page
+// when the user has to confirm their email address before signing in.
+func (a *API) renderHostedErrorPage(w http.ResponseWriter, r *http.Request, err error, u *url.URL) error {
+	config := a.config
+
+	setErrorRedirectURL(err, r, u)
+
+	status, errorCode := hostedPageError(err)
+	page := config.External.HostedPages.ErrorTemplate
+	if errorCode == ErrorCodeProviderEmailNeedsVerification {
+		page = config.External.HostedPages.ConfirmationTemplate
+		status = http.StatusOK
+	}
+
+	q := u.Query()
+	return a.renderHostedPage(w, r, status, page, u.String(), &HostedPageData{
+		Error:            q.Get("error"),
+		ErrorDescription: q.Get("error_description"),
+		ErrorCode:        string(errorCode),
+	})
+}
+
+func hostedPageError(err error) (int, ErrorCode) {
+	switch e := err.(type) {
+	case *HTTPError:
+		return e.HTTPStatus, e.ErrorCode
+	case *OAuthError:
+		return http.StatusBadRequest, ""
+	case ErrorCause:
+		return hostedPageError(e.Cause())
+	default:
+		return http.StatusInternalServerError, ""
+	}
+}
+
+// renderHostedPage renders the page with the branding of the hosted pages
+// and the URL returning the user to the app.
+func (a *API) renderHostedPage(w http.ResponseWriter, r *http.Request, status int, page *template.Template, rurl string, data *HostedPageData) error {
+	config := a.config
+
+	if data == nil {
+		data = &HostedPageData{}
+	}
+	data.SiteName = config.External.HostedPages.SiteName
+	data.LogoURL = config.External.HostedPages.LogoURL
+	data.SiteURL = config.SiteURL
+	data.Provider = getExternalProviderType(r.Context())
+	// the redirect URL has been validated against the allow list, and
+	// may use the custom scheme of a mobile app
+	data.RedirectURL = template.URL(rurl) // #nosec G203
+
+	var buf bytes.Buffer
+	if err := page.Execute(&buf, data); err != nil {
+		return internalServerError("Error rendering page").WithInternalError(err)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// the page may link to the app with the tokens
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(status)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
 	"github.com/fatih/structs"
@@ -10,8 +11,23 @@ import (
 	"github.com/supabase/auth/internal/api/provider"
 	"github.com/supabase/auth/internal/models"
 	"github.com/supabase/auth/internal/storage"
+	"golang.org/x/oauth2"
 )
 
+const (
+	// identitySyncProviderWins overwrites the user metadata with the
+	// profile of the provider, like signing in with it does.
+	identitySyncProviderWins = "provider"
+	// identitySyncUserWins only adds the fields of the profile the user
+	// metadata doesn't have yet.
+	identitySyncUserWins = "user"
+)
+
+// IdentitySyncParams are the parameters the IdentitySync method accepts
+type IdentitySyncParams struct {
+	OnConflict string `json:"on_conflict"`
+}
+
 func (a *API) DeleteIdentity(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 
@@ -117,6 +133,13 @@ func (a *API) linkIdentityToUser(r *http.Request, ctx context.Context, tx *stora
 		if identity.UserID == targetUser.ID {
 			return nil, unprocessableEntityError(ErrorCodeIdentityAlreadyExists, "Identity is already linked")
 		}
+		if targetUser.IsAnonymous && a.config.External.AnonymousUsers.MergeEnabled {
+			identity.IdentityData = structs.Map(userData.Metadata)
+			if terr := tx.UpdateOnly(identity, "identity_data", "last_sign_in_at"); terr != nil {
+				return nil, terr
+			}
+			return a.mergeAnonymousUser(r, tx, targetUser, identity.UserID)
+		}
 		return nil, unprocessableEntityError(ErrorCodeIdentityAlreadyExists, "Identity is already linked to another user")
 	}
 	if _, terr := a.createNewIdentity(tx, targetUser, providerType, structs.Map(userData.Metadata)); terr != nil {
@@ -151,5 +174,141 @@ func (a *API) linkIdentityToUser(r *http.Request, ctx context.Context, tx *stora
 	if terr := targetUser.UpdateAppMetaDataProviders(tx); terr != nil {
 		return nil, terr
 	}
+	if len(userData.AppMetadata) > 0 {
+		if terr := targetUser.UpdateAppMetaData(tx, userData.AppMetadata); terr != nil {
+			return nil, terr
+		}
+	}
 	return targetUser, nil
 }
+
+// IdentitySync refetches the profile of the user from the provider of the
+// identity with the stored provider token, and updates the identity data and
+// the user metadata with it. It serves both the authenticated user and the
+// admin endpoints.
+func (a *API) IdentitySync(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+	user := getUser(ctx)
+
+	params := &IdentitySyncParams{}
+	if r.ContentLength != 0 {
+		if err := retrieveRequestParams(r, params); err != nil {
+			return err
+		}
+	}
+
+	switch params.OnConflict {
+	case "":
+		params.OnConflict = identitySyncProviderWins
+	case identitySyncProviderWins, identitySyncUserWins:
+	default:
+		return badRequestError(ErrorCodeValidationFailed, "on_conflict must be either %q or %q", identitySyncProviderWins, identitySyncUserWins)
+	}
+
+	identityID, err := uuid.FromString(chi.URLParam(r, "identity_id"))
+	if err != nil {
+		return notFoundError(ErrorCodeValidationFailed, "identity_id must be an UUID")
+	}
+
+	var identity *models.Identity
+	for i := range user.Identities {
+		if user.Identities[i].ID == identityID {
+			identity = &user.Identities[i]
+			break
+		}
+	}
+	if identity == nil {
+		return notFoundError(ErrorCodeIdentityNotFound, "Identity doesn't exist")
+	}
+
+	if !config.External.ProviderTokens.Enabled {
+		return unprocessableEntityError(ErrorCodeProviderTokenNotFound, "Identities can only be synced when provider tokens are stored")
+	}
+
+	oAuthProvider, err := a.OAuthProvider(ctx, identity.Provider)
+	if err != nil {
+		return unprocessableEntityError(ErrorCodeValidationFailed, "Identities of provider %s can't be synced", identity.Provider).WithInternalError(err)
+	}
+
+	var accessToken string
+	var token *models.ProviderToken
+	err = db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		accessToken, token, terr = a.freshProviderToken(r, tx, identity)
+		return terr
+	})
+	if err != nil {
+		return err
+	}
+
+	userData, err := oAuthProvider.GetUserData(ctx, &oauth2.Token{
+		AccessToken: accessToken,
+		TokenType:   string(token.TokenType),
+	})
+	if err != nil {
+		if errors.Is(err, provider.ErrAccessDenied) {
+			return forbiddenError(ErrorCodeProviderAccessDenied, "User is no longer allowed by this provider").WithInternalError(err)
+		}
+		return internalServerError("Error getting user profile from external provider").WithInternalError(err)
+	}
+
+	// the token must still belong to the same account of the provider
+	if userData.Metadata == nil || userData.Metadata.Subject != identity.ProviderID {
+		return unprocessableEntityError(ErrorCodeIdentitySyncMismatch, "Provider returned the profile of a different user than the one linked to the identity")
+	}
+
+	if err := a.applyClaimMapping(identity.Provider, userData); err != nil {
+		return internalServerError("Error mapping claims of external provider").WithInternalError(err)
+	}
+
+	identityData := structs.Map(userData.Metadata)
+
+	err = db.Transaction(func(tx *storage.Connection) error {
+		identity.IdentityData = identityData
+		if terr := tx.UpdateOnly(identity, "identity_data", "updated_at"); terr != nil {
+			return internalServerError("Database error updating identity").WithInternalError(terr)
+		}
+
+		userMetaData := userMetaDataFromIdentity(identityData, userData)
+		if params.OnConflict == identitySyncUserWins {
+			mapped := userMetaData
+			userMetaData = make(map[string]interface{})
+			for key, value := range mapped {
+				if _, ok := user.UserMetaData[key]; !ok {
+					userMetaData[key] = value
+				}
+			}
+		}
+		if terr := user.UpdateUserMetaData(tx, userMetaData); terr != nil {
+			return internalServerError("Database error updating user").WithInternalError(terr)
+		}
+
+		if len(userData.AppMetadata) > 0 {
+			if terr := user.UpdateAppMetaData(tx, userData.AppMetadata); terr != nil {
+				return internalServerError("Database error updating user").WithInternalError(terr)
+			}
+		}
+
+		actor := user
+		if adminUser := getAdminUser(ctx); adminUser != nil {
+			actor = adminUser
+		}
+		if terr := models.NewAuditLogEntry(r, tx, actor, models.IdentitySyncAction, "", map[string]interface{}{
+			"user_id":     user.ID,
+			"identity_id": identity.ID,
+			"provider":    identity.Provider,
+			"on_conflict": params.OnConflict,
+		}); terr != nil {
+			return internalServerError("Error recording audit log entry").WithInternalError(terr)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, user)
+}
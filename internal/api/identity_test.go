@@ -105,6 +105,58 @@ func (ts *IdentityTestSuite) TestLinkIdentityToUser() {
 	require.Nil(ts.T(), u)
 }
 
+func (ts *IdentityTestSuite) TestLinkIdentityMergesAnonymousUser() {
+	ts.Config.External.AnonymousUsers.MergeEnabled = true
+	ts.Config.External.AnonymousUsers.MergeMetadata = conf.AnonymousMergeExistingWins
+	defer func() {
+		ts.Config.External.AnonymousUsers.MergeEnabled = false
+	}()
+
+	existing, err := models.FindUserByEmailAndAudience(ts.API.db, "one@example.com", ts.Config.JWT.Aud)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), existing.UpdateUserMetaData(ts.API.db, map[string]interface{}{"theme": "dark"}))
+
+	anonymous, err := models.NewUser("", "", "", ts.Config.JWT.Aud, map[string]interface{}{
+		"theme": "light",
+		"cart":  []interface{}{"sku-1"},
+	})
+	require.NoError(ts.T(), err)
+	anonymous.IsAnonymous = true
+	anonymous.AppMetaData = map[string]interface{}{"plan": "pro"}
+	require.NoError(ts.T(), ts.API.db.Create(anonymous))
+
+	// the identity of the existing account is linked by the anonymous user
+	userData := &provider.UserProvidedData{
+		Metadata: &provider.Claims{
+			Subject: existing.ID.String(),
+		},
+	}
+	r := httptest.NewRequest(http.MethodGet, "/callback", nil)
+	u, err := ts.API.linkIdentityToUser(r, withTargetUser(context.Background(), anonymous), ts.API.db, userData, "email")
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), existing.ID, u.ID)
+
+	merged, err := models.FindUserByID(ts.API.db, existing.ID)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), "dark", merged.UserMetaData["theme"])
+	require.Equal(ts.T(), []interface{}{"sku-1"}, merged.UserMetaData["cart"])
+	require.NotContains(ts.T(), merged.AppMetaData, "plan")
+
+	_, err = models.FindUserByID(ts.API.db, anonymous.ID)
+	require.True(ts.T(), models.IsNotFoundError(err))
+
+	// without merging, the identity can't be linked
+	ts.Config.External.AnonymousUsers.MergeEnabled = false
+
+	anonymous, err = models.NewUser("", "", "", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err)
+	anonymous.IsAnonymous = true
+	require.NoError(ts.T(), ts.API.db.Create(anonymous))
+
+	_, err = ts.API.linkIdentityToUser(r, withTargetUser(context.Background(), anonymous), ts.API.db, userData, "email")
+	require.ErrorIs(ts.T(), err, unprocessableEntityError(ErrorCodeIdentityAlreadyExists, "Identity is already linked to another user"))
+}
+
 func (ts *IdentityTestSuite) TestUnlinkIdentityError() {
 	ts.Config.Security.ManualLinkingEnabled = true
 	userWithOneIdentity, err := models.FindUserByEmailAndAudience(ts.API.db, "one@example.com", ts.Config.JWT.Aud)
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// adminUserImpersonateParams are the parameters of an impersonation.
+type adminUserImpersonateParams struct {
+	Reason string `json:"reason"`
+}
+
+// adminUserImpersonate starts a session on behalf of the user, so that
+// admins can reproduce issues as the user sees them. The access tokens of
+// impersonation sessions have an act claim naming the admin, every request
+// made with them is recorded in the audit log, and they can't be used to
+// change the credentials of the user.
+func (a *API) adminUserImpersonate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+	user := getUser(ctx)
+	adminUser := getAdminUser(ctx)
+	claims := getClaims(ctx)
+
+	params := &adminUserImpersonateParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	params.Reason = strings.TrimSpace(params.Reason)
+	if params.Reason == "" {
+		return badRequestError(ErrorCodeValidationFailed, "A reason is required to impersonate a user")
+	}
+
+	if user.DeletedAt != nil {
+		return unprocessableEntityError(ErrorCodeUserNotFound, "Deleted users can't be impersonated")
+	}
+
+	// impersonating admins would grant their admin role to whoever can
+	// impersonate users
+	if isStringInSlice(user.Role, config.JWT.AdminRoles) {
+		return forbiddenError(ErrorCodeImpersonationNotAllowed, "Admins can't be impersonated")
+	}
+
+	// admins are named by the subject of their token when there's one,
+	// like when they sign in with the admin role, and by their role or
+	// admin credential otherwise, like with the service role key
+	impersonatedBy := adminUser.Email.String()
+	if claims != nil && claims.Subject != "" {
+		impersonatedBy = claims.Subject
+	}
+
+	notAfter := time.Now().Add(config.Impersonation.SessionDuration)
+	grantParams := models.GrantParams{
+		SessionNotAfter: &notAfter,
+		ImpersonatedBy:  &impersonatedBy,
+	}
+	grantParams.FillGrantParams(r)
+	grantParams.Location = a.locateIP(r, grantParams.IP)
+
+	var token *AccessTokenResponse
+	err := db.Transaction(func(tx *storage.Connection) error {
+		refreshToken, terr := models.GrantAuthenticatedUser(tx, user, grantParams)
+		if terr != nil {
+			return internalServerError("Database error granting user").WithInternalError(terr)
+		}
+
+		if terr := models.AddClaimToSession(tx, *refreshToken.SessionId, models.Impersonation); terr != nil {
+			return terr
+		}
+
+		tokenString, expiresAt, terr := a.generateAccessToken(r, tx, user, refreshToken.SessionId, models.Impersonation)
+		if terr != nil {
+			if httpErr, ok := terr.(*HTTPError); ok {
+				return httpErr
+			}
+			return internalServerError("error generating jwt token").WithInternalError(terr)
+		}
+
+		if terr := models.NewAuditLogEntry(r, tx, adminUser, models.UserImpersonatedAction, "", map[string]interface{}{
+			"user_id":         user.ID,
+			"user_email":      user.Email,
+			"user_phone":      user.Phone,
+			"session_id":      refreshToken.SessionId,
+			"impersonated_by": impersonatedBy,
+			"reason":          params.Reason,
+			"not_after":       notAfter,
+		}); terr != nil {
+			return terr
+		}
+
+		token = &AccessTokenResponse{
+			Token:        tokenString,
+			TokenType:    "bearer",
+			ExpiresIn:    int(time.Until(time.Unix(expiresAt, 0)).Round(time.Second).Seconds()),
+			ExpiresAt:    expiresAt,
+			RefreshToken: refreshToken.Token,
+			User:         user,
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, token)
+}
+
+// adminUserImpersonationsRevoke ends the impersonation sessions of the
+// user, along with their access and refresh tokens.
+func (a *API) adminUserImpersonationsRevoke(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+	adminUser := getAdminUser(ctx)
+
+	var revoked int
+	err := db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		revoked, terr = models.LogoutImpersonationSessions(tx, user.ID)
+		if terr != nil {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, adminUser, models.ImpersonationRevokedAction, "", map[string]interface{}{
+			"user_id":    user.ID,
+			"user_email": user.Email,
+			"user_phone": user.Phone,
+			"sessions":   revoked,
+		})
+	})
+	if err != nil {
+		return internalServerError("Database error revoking impersonation sessions").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"revoked": revoked,
+	})
+}
@@ -31,62 +31,78 @@ func (a *API) Invite(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	if err := a.validateUserMetadata(params.Data); err != nil {
+		return err
+	}
+
 	aud := a.requestAud(ctx, r)
-	user, err := models.FindUserByEmailAndAudience(db, params.Email, aud)
+
+	var user *models.User
+	err = db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		user, terr = a.inviteUser(r, tx, adminUser, params.Email, params.Data, aud)
+		return terr
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, user)
+}
+
+// inviteUser invites the user with the email, creating it with the
+// metadata when it doesn't exist, and sends them the invite email. Users
+// who didn't confirm their email yet are invited again.
+func (a *API) inviteUser(r *http.Request, tx *storage.Connection, adminUser *models.User, email string, data map[string]interface{}, aud string) (*models.User, error) {
+	user, err := models.FindUserByEmailAndAudience(tx, email, aud)
 	if err != nil && !models.IsNotFoundError(err) {
-		return internalServerError("Database error finding user").WithInternalError(err)
+		return nil, internalServerError("Database error finding user").WithInternalError(err)
 	}
 
-	err = db.Transaction(func(tx *storage.Connection) error {
-		if user != nil {
-			if user.IsConfirmed() {
-				return unprocessableEntityError(ErrorCodeEmailExists, DuplicateEmailMsg)
-			}
-		} else {
-			signupParams := SignupParams{
-				Email:    params.Email,
-				Data:     params.Data,
-				Aud:      aud,
-				Provider: "email",
-			}
-
-			// because params above sets no password, this method
-			// is not computationally hard so it can be used within
-			// a database transaction
-			user, err = signupParams.ToUserModel(false /* <- isSSOUser */)
-			if err != nil {
-				return err
-			}
-
-			user, err = a.signupNewUser(tx, user)
-			if err != nil {
-				return err
-			}
-			identity, err := a.createNewIdentity(tx, user, "email", structs.Map(provider.Claims{
-				Subject: user.ID.String(),
-				Email:   user.GetEmail(),
-			}))
-			if err != nil {
-				return err
-			}
-			user.Identities = []models.Identity{*identity}
+	if user != nil {
+		if user.IsConfirmed() {
+			return nil, unprocessableEntityError(ErrorCodeEmailExists, DuplicateEmailMsg)
+		}
+	} else {
+		signupParams := SignupParams{
+			Email:    email,
+			Data:     data,
+			Aud:      aud,
+			Provider: "email",
 		}
 
-		if terr := models.NewAuditLogEntry(r, tx, adminUser, models.UserInvitedAction, "", map[string]interface{}{
-			"user_id":    user.ID,
-			"user_email": user.Email,
-		}); terr != nil {
-			return terr
+		// because params above sets no password, this method
+		// is not computationally hard so it can be used within
+		// a database transaction
+		user, err = signupParams.ToUserModel(false /* <- isSSOUser */)
+		if err != nil {
+			return nil, err
 		}
 
-		if err := a.sendInvite(r, tx, user); err != nil {
-			return err
+		user, err = a.signupNewUser(tx, user)
+		if err != nil {
+			return nil, err
 		}
-		return nil
-	})
-	if err != nil {
-		return err
+		identity, err := a.createNewIdentity(tx, user, "email", structs.Map(provider.Claims{
+			Subject: user.ID.String(),
+			Email:   user.GetEmail(),
+		}))
+		if err != nil {
+			return nil, err
+		}
+		user.Identities = []models.Identity{*identity}
 	}
 
-	return sendJSON(w, http.StatusOK, user)
+	if err := models.NewAuditLogEntry(r, tx, adminUser, models.UserInvitedAction, "", map[string]interface{}{
+		"user_id":    user.ID,
+		"user_email": user.Email,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := a.sendInvite(r, tx, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
 }
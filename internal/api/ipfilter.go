@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/didip/tollbooth/v5"
+	"github.com/didip/tollbooth/v5/limiter"
+	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+)
+
+// ipBlockAuditInterval is how often the blocked requests of an IP address
+// are recorded in the audit log, so that floods of them don't flood it.
+const ipBlockAuditInterval = time.Minute
+
+// ipRules are the IP addresses and CIDR ranges allowed and denied.
+type ipRules struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// allows returns whether the rules allow the IP address. Invalid
+// addresses are only allowed when there are no allowed ranges.
+func (r *ipRules) allows(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	for _, prefix := range r.deny {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	if len(r.allow) == 0 {
+		return true
+	}
+
+	for _, prefix := range r.allow {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// recordSocketAddr keeps the address of the connection of the requests,
+// before the X-Forwarded-For header replaces it.
+func recordSocketAddr(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(withSocketAddr(r.Context(), r.RemoteAddr)))
+	})
+}
+
+// clientAddr returns the IP address of the client. It's the one of the
+// X-Forwarded-For header of the trusted proxies when they're forwarded,
+// and the one of the connection otherwise, as the header of any client is
+// trusted when there are no trusted proxies.
+func clientAddr(r *http.Request, forwarded bool) netip.Addr {
+	remoteAddr := r.RemoteAddr
+	if socketAddr := getSocketAddr(r.Context()); !forwarded && socketAddr != "" {
+		remoteAddr = socketAddr
+	}
+
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+
+	return addr
+}
+
+// ipFilterHandler rejects the requests of the IP addresses the rules of the
+// surface, global or a group of endpoints, don't allow, and records them in
+// the audit log.
+func (a *API) ipFilterHandler(surface string) middlewareHandler {
+	passthrough := func(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+		return r.Context(), nil
+	}
+
+	config := a.config.IPFilter.Rules(surface)
+	if !a.config.IPFilter.Enabled || config == nil || config.IsEmpty() {
+		return passthrough
+	}
+
+	allow, deny, err := config.Prefixes()
+	if err != nil {
+		// the rules are validated when the configuration is loaded
+		logrus.WithError(err).WithField("surface", surface).Error("Invalid IP filter rules")
+		return passthrough
+	}
+	rules := &ipRules{allow: allow, deny: deny}
+
+	// the X-Forwarded-For header can only be used to filter requests when
+	// it's only trusted from the trusted proxies
+	forwarded := len(a.config.API.TrustedProxies) > 0
+
+	audits := tollbooth.NewLimiter(1/ipBlockAuditInterval.Seconds(), &limiter.ExpirableOptions{
+		DefaultExpirationTTL: ipBlockAuditInterval,
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+		ctx := r.Context()
+
+		// health checks come from the load balancers
+		if surface == "global" && r.URL.Path == "/health" {
+			return ctx, nil
+		}
+
+		addr := clientAddr(r, forwarded)
+		if rules.allows(addr) {
+			return ctx, nil
+		}
+
+		ip := addr.String()
+		if !addr.IsValid() {
+			ip = r.RemoteAddr
+		}
+
+		if tollbooth.LimitByKeys(audits, []string{ip}) == nil {
+			if err := models.NewAuditLogEntry(r, a.db.WithContext(ctx), &models.User{}, models.IPAddressBlockedAction, ip, map[string]interface{}{
+				"surface": surface,
+				"method":  r.Method,
+				"path":    r.URL.Path,
+			}); err != nil {
+				observability.GetLogEntry(r).Entry.WithError(err).Warn("Unable to record blocked IP address in the audit log")
+			}
+		}
+
+		return ctx, forbiddenError(ErrorCodeIPAddressBlocked, "Requests from this IP address are not allowed")
+	}
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIPRules(t *testing.T) {
+	rules := &ipRules{
+		allow: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("2001:db8::/32")},
+		deny:  []netip.Prefix{netip.MustParsePrefix("10.0.0.7/32")},
+	}
+
+	assert.True(t, rules.allows(netip.MustParseAddr("10.1.2.3")))
+	assert.True(t, rules.allows(netip.MustParseAddr("::ffff:10.1.2.3")))
+	assert.True(t, rules.allows(netip.MustParseAddr("2001:db8::1")))
+	assert.False(t, rules.allows(netip.MustParseAddr("10.0.0.7")))
+	assert.False(t, rules.allows(netip.MustParseAddr("192.0.2.1")))
+	assert.False(t, rules.allows(netip.Addr{}))
+
+	// without allowed ranges, all but the denied ones are allowed
+	rules.allow = nil
+	assert.True(t, rules.allows(netip.MustParseAddr("192.0.2.1")))
+	assert.True(t, rules.allows(netip.Addr{}))
+	assert.False(t, rules.allows(netip.MustParseAddr("10.0.0.7")))
+}
+
+func TestClientAddr(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+
+	req.RemoteAddr = "192.0.2.1:1234"
+	assert.Equal(t, netip.MustParseAddr("192.0.2.1"), clientAddr(req, true))
+
+	req.RemoteAddr = "[2001:db8::1]:1234"
+	assert.Equal(t, netip.MustParseAddr("2001:db8::1"), clientAddr(req, true))
+
+	req.RemoteAddr = "@"
+	assert.False(t, clientAddr(req, true).IsValid())
+
+	// the address of the connection is used when the X-Forwarded-For
+	// header of any client is trusted
+	req = req.WithContext(withSocketAddr(req.Context(), "198.51.100.7:1234"))
+	req.RemoteAddr = "192.0.2.1:1234"
+	assert.Equal(t, netip.MustParseAddr("192.0.2.1"), clientAddr(req, true))
+	assert.Equal(t, netip.MustParseAddr("198.51.100.7"), clientAddr(req, false))
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/supabase/auth/internal/api/provider"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+	"github.com/supabase/auth/internal/utilities"
+)
+
+// KerberosSSO signs in the user of a domain-joined machine with the Kerberos
+// ticket its browser negotiates with SPNEGO. Without a redirect_to the
+// session is returned as JSON, otherwise the browser is redirected with it
+// in the URL fragment.
+func (a *API) KerberosSSO(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+
+	negotiate, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Negotiate ")
+	if !ok {
+		// challenges the browser to retry with a ticket
+		w.Header().Set("WWW-Authenticate", "Negotiate")
+		return httpError(http.StatusUnauthorized, ErrorCodeNoAuthorization, "This endpoint requires Kerberos authentication")
+	}
+
+	creds, err := provider.VerifySPNEGO(config.Kerberos, negotiate)
+	if err != nil {
+		if errors.Is(err, provider.ErrKerberosNoMechanism) {
+			return httpError(http.StatusUnauthorized, ErrorCodeInvalidCredentials, "Kerberos authentication is not available on this device").WithInternalError(err)
+		}
+		if errors.Is(err, provider.ErrKerberosRealmNotAllowed) {
+			return forbiddenError(ErrorCodeInvalidCredentials, "Users of this Kerberos realm are not allowed").WithInternalError(err)
+		}
+		return httpError(http.StatusUnauthorized, ErrorCodeInvalidCredentials, "Kerberos ticket could not be verified").WithInternalError(err)
+	}
+
+	observability.GetLogEntry(r).Entry.WithField("principal", creds.UserName()+"@"+creds.Domain()).Info("Verified Kerberos ticket")
+
+	userData := provider.KerberosUserData(config.Kerberos, creds)
+
+	var token *AccessTokenResponse
+	var grantParams models.GrantParams
+
+	grantParams.FillGrantParams(r)
+	grantParams.Provider = "kerberos"
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		user, terr := a.createAccountFromExternalIdentity(tx, r, userData, "kerberos")
+		if terr != nil {
+			return terr
+		}
+
+		token, terr = a.issueRefreshToken(r, tx, user, models.SSOKerberos, grantParams)
+		return terr
+	}); err != nil {
+		switch err.(type) {
+		case *storage.CommitWithError:
+			return err
+		case *HTTPError, *OAuthError:
+			return err
+		default:
+			return internalServerError("Error signing in with Kerberos").WithInternalError(err)
+		}
+	}
+
+	if r.URL.Query().Get("redirect_to") == "" {
+		return sendJSON(w, http.StatusOK, token)
+	}
+
+	http.Redirect(w, r, token.AsRedirectURL(utilities.GetReferrer(r, config), url.Values{}), http.StatusFound)
+	return nil
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/supabase/auth/internal/api/provider"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// ldapPasswordGrant verifies the password against the directory, creating
+// the user on its first sign in. It reports whether the directory contains
+// the user, when it doesn't the password is verified against the database.
+func (a *API) ldapPasswordGrant(ctx context.Context, w http.ResponseWriter, r *http.Request, params *PasswordGrantParams, grantParams models.GrantParams) (bool, error) {
+	db := a.db.WithContext(ctx)
+	config := a.config
+
+	ldapUser, err := a.ldapAuthenticator.Authenticate(ctx, params.Email, params.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, provider.ErrLDAPUserNotFound):
+			return false, nil
+		case errors.Is(err, provider.ErrLDAPInvalidCredentials):
+			return true, badRequestError(ErrorCodeInvalidCredentials, InvalidLoginMessage)
+		default:
+			return true, internalServerError("Error authenticating with the directory").WithInternalError(err)
+		}
+	}
+
+	if ldapUser.Email == "" {
+		observability.GetLogEntry(r).Entry.WithField("dn", ldapUser.DN).Warn("Directory user has no email address")
+		return true, badRequestError(ErrorCodeInvalidCredentials, InvalidLoginMessage)
+	}
+
+	userData := ldapUser.UserData(config.External.LDAP)
+	grantParams.Provider = "ldap"
+
+	var token *AccessTokenResponse
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		user, terr := a.createAccountFromExternalIdentity(tx, r, userData, "ldap")
+		if terr != nil {
+			return terr
+		}
+
+		if len(config.External.LDAP.GroupRoles) > 0 {
+			role := provider.LDAPRole(config.External.LDAP, ldapUser.Groups)
+			if role == "" {
+				role = config.JWT.DefaultGroupName
+			}
+
+			if user.Role != role {
+				if terr := user.SetRole(tx, role); terr != nil {
+					return terr
+				}
+			}
+		}
+
+		token, terr = a.issueRefreshToken(r, tx, user, models.PasswordGrant, grantParams)
+		return terr
+	}); err != nil {
+		return true, err
+	}
+
+	return true, sendJSON(w, http.StatusOK, token)
+}
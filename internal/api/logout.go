@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/revocation"
 	"github.com/supabase/auth/internal/storage"
 )
 
@@ -62,6 +63,18 @@ func (a *API) Logout(w http.ResponseWriter, r *http.Request) error {
 		return internalServerError("Error logging out user").WithInternalError(err)
 	}
 
+	//exhaustive:ignore Default case is handled below.
+	switch scope {
+	case LogoutLocal:
+		a.propagateRevocation(r, revocation.SessionRevocation(u.ID, s.ID))
+
+	case LogoutOthers:
+		a.propagateRevocation(r, revocation.UserRevocation(u.ID, &s.ID))
+
+	default:
+		a.propagateRevocation(r, revocation.UserRevocation(u.ID, nil))
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 
 	return nil
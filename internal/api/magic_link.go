@@ -61,6 +61,10 @@ func (a *API) MagicLink(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	if err := a.validateUserMetadata(params.Data); err != nil {
+		return err
+	}
+
 	if params.Data == nil {
 		params.Data = make(map[string]interface{})
 	}
@@ -69,7 +73,7 @@ func (a *API) MagicLink(w http.ResponseWriter, r *http.Request) error {
 
 	var isNewUser bool
 	aud := a.requestAud(ctx, r)
-	user, err := models.FindUserByEmailAndAudience(db, params.Email, aud)
+	user, err := a.findUserByLoginEmail(db, params.Email, aud)
 	if err != nil {
 		if models.IsNotFoundError(err) {
 			isNewUser = true
@@ -141,7 +145,8 @@ func (a *API) MagicLink(w http.ResponseWriter, r *http.Request) error {
 		if terr := models.NewAuditLogEntry(r, tx, user, models.UserRecoveryRequestedAction, "", nil); terr != nil {
 			return terr
 		}
-		return a.sendMagicLink(r, tx, user, flowType)
+		// signing in with a secondary email address sends the link there
+		return a.sendMagicLink(r, tx, withRecipientEmail(user, params.Email), flowType)
 	})
 	if err != nil {
 		return err
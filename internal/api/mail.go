@@ -6,7 +6,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/didip/tollbooth/v5"
 	"github.com/supabase/auth/internal/hooks"
 	mail "github.com/supabase/auth/internal/mailer"
 	"go.opentelemetry.io/otel/attribute"
@@ -87,6 +86,16 @@ func (a *API) adminGenerateLink(w http.ResponseWriter, r *http.Request) error {
 		}
 	}
 
+	if user != nil {
+		switch params.Type {
+		case mail.MagicLinkVerification, mail.RecoveryVerification, mail.EmailChangeCurrentVerification, mail.EmailChangeNewVerification:
+			// the links sign in as the user, or change its email
+			if err := a.checkAdminTargetSignIn(ctx, user); err != nil {
+				return err
+			}
+		}
+	}
+
 	var url string
 	now := time.Now()
 	otp, err := crypto.GenerateOtp(config.Mailer.OtpLength)
@@ -236,7 +245,10 @@ func (a *API) adminGenerateLink(w http.ResponseWriter, r *http.Request) error {
 			if terr != nil {
 				return terr
 			}
-			if duplicateUser, terr := models.IsDuplicatedEmail(tx, params.NewEmail, user.Aud, user); terr != nil {
+			if terr := a.validateEmailDomain(params.NewEmail); terr != nil {
+				return terr
+			}
+			if duplicateUser, terr := a.findDuplicateEmail(tx, params.NewEmail, user.Aud, user); terr != nil {
 				return internalServerError("Database error checking email").WithInternalError(terr)
 			} else if duplicateUser != nil {
 				return unprocessableEntityError(ErrorCodeEmailExists, DuplicateEmailMsg)
@@ -588,16 +600,13 @@ func validateSentWithinFrequencyLimit(sentAt *time.Time, frequency time.Duration
 	return nil
 }
 
-func (a *API) sendEmail(r *http.Request, tx *storage.Connection, u *models.User, emailActionType, otp, otpNew, tokenHashWithPrefix string) error {
-	mailer := a.Mailer()
+// limitEmail applies the rate limits of emails before an email is sent out
+// to the recipients.
+func (a *API) limitEmail(r *http.Request, recipients []string) error {
 	ctx := r.Context()
-	config := a.config
-	referrerURL := utilities.GetReferrer(r, config)
-	externalURL := getExternalHost(ctx)
 
-	// apply rate limiting before the email is sent out
 	if limiter := getLimiter(ctx); limiter != nil {
-		if err := tollbooth.LimitByKeys(limiter.EmailLimiter, []string{"email_functions"}); err != nil {
+		if !limiter.EmailLimiter.allow(ctx, "email_functions") {
 			emailRateLimitCounter.Add(
 				ctx,
 				1,
@@ -607,6 +616,52 @@ func (a *API) sendEmail(r *http.Request, tx *storage.Connection, u *models.User,
 		}
 	}
 
+	for _, recipient := range recipients {
+		if !a.recipientLimiter.allowEmail(ctx, recipient) {
+			return EmailRateLimitExceeded
+		}
+	}
+
+	return nil
+}
+
+// requestMailer returns the mailer of the request, which tells the request
+// the IDs of the emails it queues in the outbox when it asked for them.
+func (a *API) requestMailer(r *http.Request) mail.Mailer {
+	onQueued := getOutboxQueued(r.Context())
+	if onQueued == nil {
+		return a.Mailer()
+	}
+
+	return a.newMailer(func(email *mail.OutboxEmail) error {
+		messageID, err := a.enqueueEmailWithID(email)
+		if err != nil {
+			return err
+		}
+
+		onQueued(messageID)
+		return nil
+	})
+}
+
+func (a *API) sendEmail(r *http.Request, tx *storage.Connection, u *models.User, emailActionType, otp, otpNew, tokenHashWithPrefix string) error {
+	mailer := a.requestMailer(r)
+	ctx := r.Context()
+	config := a.config
+	referrerURL := utilities.GetReferrer(r, config)
+	externalURL := getExternalHost(ctx)
+
+	recipients := []string{u.GetEmail()}
+	if emailActionType == mail.EmailChangeVerification {
+		recipients = []string{u.EmailChange}
+		if config.Mailer.SecureEmailChangeEnabled && u.GetEmail() != "" {
+			recipients = append(recipients, u.GetEmail())
+		}
+	}
+	if err := a.limitEmail(r, recipients); err != nil {
+		return err
+	}
+
 	if config.Hook.SendEmail.Enabled {
 		emailData := mail.EmailData{
 			Token:           otp,
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// MetadataValidationError is why metadata is invalid, at the path of the
+// invalid value, like address.country.
+type MetadataValidationError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// InvalidMetadataError encodes an error that metadata doesn't satisfy the
+// configured schema, size or denied keys. It is handled specially in
+// errors.go as it gets transformed to a HTTPError with a special
+// invalid_metadata field that encodes the Errors slice.
+type InvalidMetadataError struct {
+	Message string                    `json:"message,omitempty"`
+	Field   string                    `json:"field"`
+	Errors  []MetadataValidationError `json:"errors,omitempty"`
+}
+
+func (e *InvalidMetadataError) Error() string {
+	return e.Message
+}
+
+// mergeMetadata returns the metadata with the updates, like they're merged
+// when they're saved: keys updated to null are removed.
+func mergeMetadata(metadata, updates map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(metadata)+len(updates))
+	for key, value := range metadata {
+		merged[key] = value
+	}
+
+	for key, value := range updates {
+		if value != nil {
+			merged[key] = value
+		} else {
+			delete(merged, key)
+		}
+	}
+
+	return merged
+}
+
+// deniedMetadataKeys appends the paths of the denied keys in the value, at
+// any depth.
+func deniedMetadataKeys(paths []MetadataValidationError, path string, value interface{}, denied []string) []MetadataValidationError {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+
+			if slices.Contains(denied, key) {
+				paths = append(paths, MetadataValidationError{Path: keyPath, Message: fmt.Sprintf("%s is not allowed", key)})
+				continue
+			}
+
+			paths = deniedMetadataKeys(paths, keyPath, v[key], denied)
+		}
+
+	case []interface{}:
+		for i, item := range v {
+			paths = deniedMetadataKeys(paths, fmt.Sprintf("%s.%d", path, i), item, denied)
+		}
+	}
+
+	return paths
+}
+
+// validateMetadata returns an InvalidMetadataError when the metadata
+// doesn't satisfy the configuration of the field, user_metadata or
+// app_metadata.
+func validateMetadata(field string, config *conf.MetadataConfiguration, metadata map[string]interface{}) error {
+	if metadata == nil {
+		return nil
+	}
+
+	if config.MaxSize > 0 {
+		data, err := json.Marshal(metadata)
+		if err != nil {
+			return badRequestError(ErrorCodeValidationFailed, "%s must be JSON", field)
+		}
+
+		if len(data) > config.MaxSize {
+			return &InvalidMetadataError{
+				Message: fmt.Sprintf("%s must be at most %d bytes", field, config.MaxSize),
+				Field:   field,
+			}
+		}
+	}
+
+	if len(config.DeniedKeys) > 0 {
+		if errs := deniedMetadataKeys(nil, "", map[string]interface{}(metadata), config.DeniedKeys); len(errs) > 0 {
+			return &InvalidMetadataError{
+				Message: fmt.Sprintf("%s has keys that are not allowed", field),
+				Field:   field,
+				Errors:  errs,
+			}
+		}
+	}
+
+	schema, err := config.JSONSchema()
+	if err != nil {
+		return internalServerError("Error loading %s schema", field).WithInternalError(err)
+	}
+
+	if schema == nil {
+		return nil
+	}
+
+	result, err := schema.Validate(gojsonschema.NewGoLoader(metadata))
+	if err != nil {
+		return badRequestError(ErrorCodeValidationFailed, "%s must be JSON", field).WithInternalError(err)
+	}
+
+	if !result.Valid() {
+		errs := make([]MetadataValidationError, 0, len(result.Errors()))
+		for _, resultErr := range result.Errors() {
+			path := resultErr.Field()
+			if path == gojsonschema.STRING_ROOT_SCHEMA_PROPERTY {
+				path = ""
+			}
+
+			errs = append(errs, MetadataValidationError{
+				Path:    path,
+				Message: resultErr.Description(),
+			})
+		}
+
+		return &InvalidMetadataError{
+			Message: fmt.Sprintf("%s doesn't match its schema", field),
+			Field:   field,
+			Errors:  errs,
+		}
+	}
+
+	return nil
+}
+
+// validateUserMetadata validates user_metadata set by users or admins.
+func (a *API) validateUserMetadata(metadata map[string]interface{}) error {
+	return validateMetadata("user_metadata", &a.config.UserMetadata, metadata)
+}
+
+// validateAppMetadata validates app_metadata set by admins.
+func (a *API) validateAppMetadata(metadata map[string]interface{}) error {
+	return validateMetadata("app_metadata", &a.config.AppMetadata, metadata)
+}
+
+// validateMetadataUpdates validates the metadata of the user as it is once
+// the updates are saved.
+func (a *API) validateMetadataUpdates(user *models.User, userMetadata, appMetadata map[string]interface{}) error {
+	if userMetadata != nil {
+		if err := a.validateUserMetadata(mergeMetadata(user.UserMetaData, userMetadata)); err != nil {
+			return err
+		}
+	}
+
+	if appMetadata != nil {
+		if err := a.validateAppMetadata(mergeMetadata(user.AppMetaData, appMetadata)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+)
+
+func TestValidateMetadata(t *testing.T) {
+	config := &conf.MetadataConfiguration{
+		Schema:     `{"type": "object", "properties": {"age": {"type": "integer", "minimum": 0}, "address": {"type": "object", "properties": {"country": {"type": "string", "maxLength": 2}}}}}`,
+		MaxSize:    128,
+		DeniedKeys: []string{"role"},
+	}
+	require.NoError(t, config.Validate())
+
+	require.NoError(t, validateMetadata("user_metadata", config, nil))
+	require.NoError(t, validateMetadata("user_metadata", config, map[string]interface{}{
+		"age":     30,
+		"address": map[string]interface{}{"country": "NZ"},
+	}))
+
+	cases := []struct {
+		desc     string
+		metadata map[string]interface{}
+		paths    []string
+	}{
+		{
+			desc:     "Schema",
+			metadata: map[string]interface{}{"age": -1, "address": map[string]interface{}{"country": "New Zealand"}},
+			paths:    []string{"address.country", "age"},
+		},
+		{
+			desc:     "Denied Keys",
+			metadata: map[string]interface{}{"profile": map[string]interface{}{"role": "admin"}, "items": []interface{}{map[string]interface{}{"role": "owner"}}},
+			paths:    []string{"items.0.role", "profile.role"},
+		},
+		{
+			desc:     "Max Size",
+			metadata: map[string]interface{}{"bio": string(make([]byte, 128))},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			err := validateMetadata("user_metadata", config, c.metadata)
+			require.Error(t, err)
+
+			metadataErr, ok := err.(*InvalidMetadataError)
+			require.True(t, ok, err)
+			assert.Equal(t, "user_metadata", metadataErr.Field)
+
+			paths := []string{}
+			for _, e := range metadataErr.Errors {
+				paths = append(paths, e.Path)
+			}
+			assert.ElementsMatch(t, c.paths, paths)
+		})
+	}
+}
+
+func TestMergeMetadata(t *testing.T) {
+	merged := mergeMetadata(map[string]interface{}{"a": 1, "b": 2}, map[string]interface{}{"b": nil, "c": 3})
+	assert.Equal(t, map[string]interface{}{"a": 1, "c": 3}, merged)
+}
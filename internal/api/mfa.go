@@ -310,6 +310,9 @@ func (a *API) challengePhoneFactor(w http.ResponseWriter, r *http.Request) error
 			return tooManyRequestsError(ErrorCodeOverSMSSendRateLimit, generateFrequencyLimitErrorMessage(factor.LastChallengedAt, config.MFA.Phone.MaxFrequency))
 		}
 	}
+	if factor.IsPhoneFactor() && !a.recipientLimiter.allowSMS(ctx, factor.Phone.String()) {
+		return tooManyRequestsError(ErrorCodeOverSMSSendRateLimit, "SMS rate limit exceeded")
+	}
 	otp, err := crypto.GenerateOtp(config.MFA.Phone.OtpLength)
 	if err != nil {
 		panic(err)
@@ -319,7 +322,12 @@ func (a *API) challengePhoneFactor(w http.ResponseWriter, r *http.Request) error
 		return internalServerError("error creating SMS Challenge")
 	}
 
-	message, err := generateSMSFromTemplate(config.MFA.Phone.SMSTemplate, otp)
+	smsTemplate := config.MFA.Phone.SMSTemplate
+	if localized := config.Localization.MFASMSTemplate(a.userLocale(r, user)); localized != nil {
+		smsTemplate = localized
+	}
+
+	message, err := generateSMSFromTemplate(smsTemplate, otp)
 	if err != nil {
 		return internalServerError("error generating sms template").WithInternalError(err)
 	}
@@ -338,14 +346,17 @@ func (a *API) challengePhoneFactor(w http.ResponseWriter, r *http.Request) error
 			return internalServerError("error invoking hook")
 		}
 	} else {
-		smsProvider, err := sms_provider.GetSmsProvider(*config)
+		smsProvider, err := a.smsProvider()
 		if err != nil {
 			return internalServerError("Failed to get SMS provider").WithInternalError(err)
 		}
-		// We omit messageID for now, can consider reinstating if there are requests.
-		if _, err = smsProvider.SendMessage(factor.Phone.String(), message, channel, otp); err != nil {
+		messageID, err := smsProvider.SendMessage(factor.Phone.String(), message, channel, otp)
+		if err != nil {
 			return internalServerError("error sending message").WithInternalError(err)
 		}
+		if !config.Outbox.Enabled {
+			a.recordMessageDelivery(models.OutboxChannelSMS, config.Sms.Provider, messageID, factor.Phone.String(), &user.ID, "mfa")
+		}
 	}
 	if err := db.Transaction(func(tx *storage.Connection) error {
 		if terr := factor.WriteChallengeToDatabase(tx, challenge); terr != nil {
@@ -54,7 +54,12 @@ func (f *FunctionHooks) UnmarshalJSON(b []byte) error {
 
 var emailRateLimitCounter = observability.ObtainMetricCounter("gotrue_email_rate_limit_counter", "Number of times an email rate limit has been triggered")
 
-func (a *API) limitHandler(lmt *limiter.Limiter) middlewareHandler {
+// limitHandler limits the requests of each value of the rate limit header.
+// The name of the limiter keeps its counts apart from the other limiters
+// in the rate limit store.
+func (a *API) limitHandler(name string, lmt *limiter.Limiter) middlewareHandler {
+	rateLimiter := newRateLimiter(name, lmt, a.rateLimitStore)
+
 	return func(w http.ResponseWriter, req *http.Request) (context.Context, error) {
 		c := req.Context()
 
@@ -66,8 +71,7 @@ func (a *API) limitHandler(lmt *limiter.Limiter) middlewareHandler {
 				log.WithField("header", limitHeader).Warn("request does not have a value for the rate limiting header, rate limiting is not applied")
 				return c, nil
 			} else {
-				err := tollbooth.LimitByKeys(lmt, []string{key})
-				if err != nil {
+				if !rateLimiter.allow(c, key) {
 					return c, tooManyRequestsError(ErrorCodeOverRequestRateLimit, "Request rate limit reached")
 				}
 			}
@@ -89,6 +93,11 @@ func (a *API) limitEmailOrPhoneSentHandler() middlewareHandler {
 		DefaultExpirationTTL: time.Hour,
 	}).SetBurst(int(a.config.RateLimitSmsSent)).SetMethods([]string{"PUT", "POST"})
 
+	sharedLimiter := &SharedLimiter{
+		EmailLimiter: newRateLimiter("email_sent", emailLimiter, a.rateLimitStore),
+		PhoneLimiter: newRateLimiter("sms_sent", phoneLimiter, a.rateLimitStore),
+	}
+
 	return func(w http.ResponseWriter, req *http.Request) (context.Context, error) {
 		c := req.Context()
 		config := a.config
@@ -98,10 +107,7 @@ func (a *API) limitEmailOrPhoneSentHandler() middlewareHandler {
 		if shouldRateLimitEmail || shouldRateLimitPhone {
 			if req.Method == "PUT" || req.Method == "POST" {
 				// store rate limiter in request context
-				c = withLimiter(c, &SharedLimiter{
-					EmailLimiter: emailLimiter,
-					PhoneLimiter: phoneLimiter,
-				})
+				c = withLimiter(c, sharedLimiter)
 			}
 		}
 
@@ -115,6 +121,11 @@ func (a *API) requireAdminCredentials(w http.ResponseWriter, req *http.Request)
 		return nil, err
 	}
 
+	// tokens that aren't JWTs are the tokens of scoped admin credentials
+	if strings.Count(t, ".") != 2 {
+		return a.requireScopedAdminCredential(req, t)
+	}
+
 	ctx, err := a.parseJWTClaims(t, req)
 	if err != nil {
 		return nil, err
@@ -123,6 +134,29 @@ func (a *API) requireAdminCredentials(w http.ResponseWriter, req *http.Request)
 	return a.requireAdmin(ctx)
 }
 
+// requireAdminScope requires the scope from scoped admin credentials.
+func (a *API) requireAdminScope(scope string) middlewareHandler {
+	return func(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+		ctx := req.Context()
+		if err := checkAdminScope(ctx, scope); err != nil {
+			return nil, err
+		}
+		return ctx, nil
+	}
+}
+
+// requireAdminScopes requires the read scope from scoped admin credentials
+// for GET requests, and the write scope for the others.
+func (a *API) requireAdminScopes(read, write string) middlewareHandler {
+	return func(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+		scope := write
+		if req.Method == http.MethodGet || req.Method == http.MethodHead {
+			scope = read
+		}
+		return a.requireAdminScope(scope)(w, req)
+	}
+}
+
 func (a *API) requireEmailProvider(w http.ResponseWriter, req *http.Request) (context.Context, error) {
 	ctx := req.Context()
 	config := a.config
@@ -204,6 +238,57 @@ func (a *API) requireSAMLEnabled(w http.ResponseWriter, req *http.Request) (cont
 	return ctx, nil
 }
 
+func (a *API) requireSSOOIDCEnabled(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+	ctx := req.Context()
+	if !a.config.SSOOIDC.Enabled {
+		return nil, notFoundError(ErrorCodeSSOOIDCProviderDisabled, "OpenID Connect single sign-on is disabled")
+	}
+	return ctx, nil
+}
+
+// requireSSOEnabled requires either SAML 2.0 or OpenID Connect single
+// sign-on to be enabled. It fails with the SAML error, which is what clients
+// got before OpenID Connect was supported.
+func (a *API) requireSSOEnabled(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+	ctx := req.Context()
+	if !a.config.SAML.Enabled && !a.config.SSOOIDC.Enabled {
+		return nil, notFoundError(ErrorCodeSAMLProviderDisabled, "SAML 2.0 is disabled")
+	}
+	return ctx, nil
+}
+
+func (a *API) requireSCIMEnabled(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+	ctx := req.Context()
+	if !a.config.SCIM.Enabled {
+		return nil, notFoundError(ErrorCodeSCIMDisabled, "SCIM is disabled")
+	}
+	return ctx, nil
+}
+
+func (a *API) requireOrganizationsEnabled(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+	ctx := req.Context()
+	if !a.config.Organizations.Enabled {
+		return nil, notFoundError(ErrorCodeOrganizationsDisabled, "Organizations are disabled")
+	}
+	return ctx, nil
+}
+
+func (a *API) requireRolesEnabled(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+	ctx := req.Context()
+	if !a.config.Roles.Enabled {
+		return nil, notFoundError(ErrorCodeRolesDisabled, "Roles are disabled")
+	}
+	return ctx, nil
+}
+
+func (a *API) requireKerberosEnabled(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+	ctx := req.Context()
+	if !a.config.Kerberos.Enabled {
+		return nil, notFoundError(ErrorCodeKerberosProviderDisabled, "Kerberos single sign-on is disabled")
+	}
+	return ctx, nil
+}
+
 func (a *API) requireManualLinkingEnabled(w http.ResponseWriter, req *http.Request) (context.Context, error) {
 	ctx := req.Context()
 	if !a.config.Security.ManualLinkingEnabled {
@@ -212,6 +297,128 @@ func (a *API) requireManualLinkingEnabled(w http.ResponseWriter, req *http.Reque
 	return ctx, nil
 }
 
+func (a *API) requireEmailSuppressionEnabled(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+	ctx := req.Context()
+	if !a.config.Mailer.Suppression.Enabled {
+		return nil, notFoundError(ErrorCodeEmailSuppressionDisabled, "Email suppression is disabled")
+	}
+	return ctx, nil
+}
+
+func (a *API) requireDeliveryTrackingEnabled(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+	ctx := req.Context()
+	if !a.config.DeliveryTracking.Enabled {
+		return nil, notFoundError(ErrorCodeDeliveryTrackingDisabled, "Delivery tracking is disabled")
+	}
+	return ctx, nil
+}
+
+func (a *API) requireShortLinksEnabled(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+	ctx := req.Context()
+	if !a.config.ShortLinks.Enabled {
+		return nil, notFoundError(ErrorCodeShortLinksDisabled, "Short links are disabled")
+	}
+	return ctx, nil
+}
+
+func (a *API) requireUserImportEnabled(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+	ctx := req.Context()
+	if !a.config.UserImport.Enabled {
+		return nil, notFoundError(ErrorCodeUserImportDisabled, "User import is disabled")
+	}
+	return ctx, nil
+}
+
+func (a *API) requireUserDeletionEnabled(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+	ctx := req.Context()
+	if !a.config.UserDeletion.Enabled {
+		return nil, notFoundError(ErrorCodeUserDeletionDisabled, "User deletion retention is disabled")
+	}
+	return ctx, nil
+}
+
+func (a *API) requireAccountDeletionEnabled(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+	ctx := req.Context()
+	if !a.config.AccountDeletion.Enabled {
+		return nil, notFoundError(ErrorCodeAccountDeletionDisabled, "Account deletion is disabled")
+	}
+	return ctx, nil
+}
+
+func (a *API) requireDataExportEnabled(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+	ctx := req.Context()
+	if !a.config.DataExport.Enabled {
+		return nil, notFoundError(ErrorCodeDataExportDisabled, "Data export is disabled")
+	}
+	return ctx, nil
+}
+
+func (a *API) requireUsernameEnabled(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+	ctx := req.Context()
+	if !a.config.Username.Enabled {
+		return nil, notFoundError(ErrorCodeUsernameProviderDisabled, "Usernames are disabled")
+	}
+	return ctx, nil
+}
+
+func (a *API) requireSignInHistoryEnabled(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+	ctx := req.Context()
+	if !a.config.SignInHistory.Enabled {
+		return nil, notFoundError(ErrorCodeSignInHistoryDisabled, "Sign in history is disabled")
+	}
+	return ctx, nil
+}
+
+func (a *API) requireConsentEnabled(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+	ctx := req.Context()
+	if !a.config.Consent.Enabled {
+		return nil, notFoundError(ErrorCodeConsentDisabled, "Consent tracking is disabled")
+	}
+	return ctx, nil
+}
+
+func (a *API) requireAccountLockoutEnabled(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+	ctx := req.Context()
+	if !a.config.AccountLockout.Enabled {
+		return nil, notFoundError(ErrorCodeAccountLockoutDisabled, "Account lockout is disabled")
+	}
+	return ctx, nil
+}
+
+func (a *API) requireBulkInviteEnabled(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+	ctx := req.Context()
+	if !a.config.BulkInvite.Enabled {
+		return nil, notFoundError(ErrorCodeBulkInviteDisabled, "Bulk invites are disabled")
+	}
+	return ctx, nil
+}
+
+func (a *API) requireSecondaryEmailsEnabled(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+	ctx := req.Context()
+	if !a.config.SecondaryEmails.Enabled {
+		return nil, notFoundError(ErrorCodeSecondaryEmailsDisabled, "Secondary email addresses are disabled")
+	}
+	return ctx, nil
+}
+
+func (a *API) requireImpersonationEnabled(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+	ctx := req.Context()
+	if !a.config.Impersonation.Enabled {
+		return nil, notFoundError(ErrorCodeImpersonationDisabled, "Impersonation is disabled")
+	}
+	return ctx, nil
+}
+
+// requireNotImpersonated prevents admins impersonating users from changing
+// their credentials.
+func (a *API) requireNotImpersonated(w http.ResponseWriter, req *http.Request) (context.Context, error) {
+	ctx := req.Context()
+	if session := getSession(ctx); session != nil && session.ImpersonatedBy != nil {
+		return nil, forbiddenError(ErrorCodeImpersonationNotAllowed, "Not allowed while impersonating the user")
+	}
+	return ctx, nil
+}
+
 func (a *API) databaseCleanup(cleanup *models.Cleanup) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -308,9 +515,19 @@ func (t *timeoutResponseWriter) finallyWrite(w http.ResponseWriter) {
 	}
 }
 
-func timeoutMiddleware(timeout time.Duration) func(http.Handler) http.Handler {
+// timeoutMiddleware buffers the responses and cuts them off after the
+// timeout, except the responses of the streaming paths which are written as
+// they're produced for as long as they take.
+func timeoutMiddleware(timeout time.Duration, streamingPaths ...string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, path := range streamingPaths {
+				if strings.TrimSuffix(r.URL.Path, "/") == path {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
 			ctx, cancel := context.WithTimeout(r.Context(), timeout)
 			defer cancel()
 
@@ -18,6 +18,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
 )
 
 const (
@@ -290,6 +291,42 @@ func (ts *MiddlewareTestSuite) TestRequireSAMLEnabled() {
 	}
 }
 
+func (ts *MiddlewareTestSuite) TestRequireSSOEnabled() {
+	cases := []struct {
+		desc           string
+		samlEnabled    bool
+		ssoOIDCEnabled bool
+		expectedErr    error
+	}{
+		{
+			desc:        "SAML and OIDC not enabled",
+			expectedErr: notFoundError(ErrorCodeSAMLProviderDisabled, "SAML 2.0 is disabled"),
+		},
+		{
+			desc:        "SAML enabled",
+			samlEnabled: true,
+			expectedErr: nil,
+		},
+		{
+			desc:           "OIDC enabled",
+			ssoOIDCEnabled: true,
+			expectedErr:    nil,
+		},
+	}
+
+	for _, c := range cases {
+		ts.Run(c.desc, func() {
+			ts.Config.SAML.Enabled = c.samlEnabled
+			ts.Config.SSOOIDC.Enabled = c.ssoOIDCEnabled
+			req := httptest.NewRequest("GET", "http://localhost", nil)
+			w := httptest.NewRecorder()
+
+			_, err := ts.API.requireSSOEnabled(w, req)
+			require.Equal(ts.T(), c.expectedErr, err)
+		})
+	}
+}
+
 func TestFunctionHooksUnmarshalJSON(t *testing.T) {
 	tests := []struct {
 		in string
@@ -356,6 +393,23 @@ func TestTimeoutResponseWriter(t *testing.T) {
 	require.Equal(t, w1.Result(), w2.Result())
 }
 
+func TestTimeoutMiddlewareStreamingPaths(t *testing.T) {
+	timeoutHandler := timeoutMiddleware(time.Microsecond, "/admin/users/export")
+
+	slowHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	timeoutHandler(slowHandler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://localhost/admin/users/export", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	timeoutHandler(slowHandler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://localhost/admin/users", nil))
+	require.Equal(t, http.StatusGatewayTimeout, w.Code)
+}
+
 func (ts *MiddlewareTestSuite) TestLimitHandler() {
 	ts.Config.RateLimitHeader = "X-Rate-Limit"
 	lmt := tollbooth.NewLimiter(5, &limiter.ExpirableOptions{
@@ -372,7 +426,7 @@ func (ts *MiddlewareTestSuite) TestLimitHandler() {
 		req := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
 		req.Header.Add(ts.Config.RateLimitHeader, "0.0.0.0")
 		w := httptest.NewRecorder()
-		ts.API.limitHandler(lmt).handler(okHandler).ServeHTTP(w, req)
+		ts.API.limitHandler("test", lmt).handler(okHandler).ServeHTTP(w, req)
 		require.Equal(ts.T(), http.StatusOK, w.Code)
 
 		var data map[string]interface{}
@@ -384,7 +438,7 @@ func (ts *MiddlewareTestSuite) TestLimitHandler() {
 	req := httptest.NewRequest(http.MethodGet, "http://localhost", nil)
 	req.Header.Add(ts.Config.RateLimitHeader, "0.0.0.0")
 	w := httptest.NewRecorder()
-	ts.API.limitHandler(lmt).handler(okHandler).ServeHTTP(w, req)
+	ts.API.limitHandler("test", lmt).handler(okHandler).ServeHTTP(w, req)
 	require.Equal(ts.T(), http.StatusTooManyRequests, w.Code)
 }
 
@@ -413,7 +467,7 @@ func (ts *MiddlewareTestSuite) TestLimitHandlerWithSharedLimiter() {
 			require.NoError(ts.T(), err)
 
 			if requestBody.Email != "" {
-				if err := tollbooth.LimitByKeys(limiter.EmailLimiter, []string{"email_functions"}); err != nil {
+				if !limiter.EmailLimiter.allow(r.Context(), "email_functions") {
 					sendJSON(w, http.StatusTooManyRequests, HTTPError{
 						HTTPStatus: http.StatusTooManyRequests,
 						ErrorCode:  ErrorCodeOverEmailSendRateLimit,
@@ -422,7 +476,7 @@ func (ts *MiddlewareTestSuite) TestLimitHandlerWithSharedLimiter() {
 				}
 			}
 			if requestBody.Phone != "" {
-				if err := tollbooth.LimitByKeys(limiter.EmailLimiter, []string{"phone_functions"}); err != nil {
+				if !limiter.EmailLimiter.allow(r.Context(), "phone_functions") {
 					sendJSON(w, http.StatusTooManyRequests, HTTPError{
 						HTTPStatus: http.StatusTooManyRequests,
 						ErrorCode:  ErrorCodeOverSMSSendRateLimit,
@@ -483,7 +537,7 @@ func (ts *MiddlewareTestSuite) TestLimitHandlerWithSharedLimiter() {
 		ts.Run(c.desc, func() {
 			ts.Config.RateLimitEmailSent = c.sharedLimiterConfig.RateLimitEmailSent
 			ts.Config.RateLimitSmsSent = c.sharedLimiterConfig.RateLimitSmsSent
-			lmt := ts.API.limitHandler(ipBasedLimiter(c.ipBasedLimiterConfig))
+			lmt := ts.API.limitHandler("test", ipBasedLimiter(c.ipBasedLimiterConfig))
 			sharedLimiter := ts.API.limitEmailOrPhoneSentHandler()
 
 			// get the minimum amount to reach the threshold just before the rate limit is exceeded
@@ -515,3 +569,56 @@ func (ts *MiddlewareTestSuite) TestLimitHandlerWithSharedLimiter() {
 		})
 	}
 }
+
+func (ts *MiddlewareTestSuite) TestIPFilterHandler() {
+	require.NoError(ts.T(), models.TruncateAll(ts.API.db))
+
+	ts.Config.IPFilter = conf.IPFilterConfiguration{
+		Enabled: true,
+		Global:  conf.IPFilterRulesConfiguration{Deny: []string{"198.51.100.0/24"}},
+		Admin:   conf.IPFilterRulesConfiguration{Allow: []string{"10.0.0.0/8"}},
+	}
+	defer func() {
+		ts.Config.IPFilter = conf.IPFilterConfiguration{}
+	}()
+
+	okHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	handlers := map[string]http.Handler{}
+	for _, surface := range []string{"global", "admin", "token"} {
+		handlers[surface] = ts.API.ipFilterHandler(surface).handler(okHandler)
+	}
+
+	request := func(surface, path, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		handlers[surface].ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(ts.T(), http.StatusOK, request("global", "/user", "203.0.113.1").Code)
+	require.Equal(ts.T(), http.StatusOK, request("admin", "/admin/users", "10.1.2.3").Code)
+	require.Equal(ts.T(), http.StatusOK, request("token", "/token", "198.51.100.7").Code)
+
+	// health checks aren't filtered
+	require.Equal(ts.T(), http.StatusOK, request("global", "/health", "198.51.100.7").Code)
+
+	w := request("global", "/user", "198.51.100.7")
+	require.Equal(ts.T(), http.StatusForbidden, w.Code)
+	data := HTTPError{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	require.Equal(ts.T(), ErrorCodeIPAddressBlocked, data.ErrorCode)
+
+	require.Equal(ts.T(), http.StatusForbidden, request("admin", "/admin/users", "203.0.113.1").Code)
+
+	// repeated blocks of an IP address are only recorded once in a while
+	require.Equal(ts.T(), http.StatusForbidden, request("global", "/user", "198.51.100.7").Code)
+
+	entries, err := models.FindAuditLogEntries(ts.API.db, nil, "", models.AuditLogFilter{Actions: []string{string(models.IPAddressBlockedAction)}}, nil)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), entries, 2)
+	require.ElementsMatch(ts.T(), []string{"198.51.100.7", "203.0.113.1"}, []string{entries[0].IPAddress, entries[1].IPAddress})
+}
@@ -0,0 +1,768 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// organizationSlugPattern matches slugs of lowercase letters, digits and
+// hyphens, like DNS labels.
+var organizationSlugPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+type OrganizationParams struct {
+	Name     string                 `json:"name"`
+	Slug     string                 `json:"slug"`
+	Metadata map[string]interface{} `json:"metadata"`
+
+	// OwnerID is the user owning an organization created with the admin
+	// API.
+	OwnerID *uuid.UUID `json:"owner_id"`
+}
+
+func (p *OrganizationParams) validate(forUpdate bool) error {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Slug = strings.ToLower(strings.TrimSpace(p.Slug))
+
+	if !forUpdate && p.Name == "" {
+		return badRequestError(ErrorCodeValidationFailed, "name is required")
+	}
+
+	if !forUpdate && p.Slug == "" {
+		return badRequestError(ErrorCodeValidationFailed, "slug is required")
+	}
+
+	if utf8.RuneCountInString(p.Name) > 256 {
+		return badRequestError(ErrorCodeValidationFailed, "name must be at most 256 characters")
+	}
+
+	if p.Slug != "" && !organizationSlugPattern.MatchString(p.Slug) {
+		return badRequestError(ErrorCodeValidationFailed, "slug must be at most 63 lowercase letters, digits or hyphens, starting and ending with a letter or digit")
+	}
+
+	if forUpdate && p.OwnerID != nil {
+		return badRequestError(ErrorCodeValidationFailed, "owner_id can only be set when creating an organization")
+	}
+
+	return nil
+}
+
+type OrganizationMemberParams struct {
+	Role string `json:"role"`
+}
+
+func (p *OrganizationMemberParams) validate() error {
+	if !models.IsValidOrganizationRole(p.Role) {
+		return badRequestError(ErrorCodeValidationFailed, "role must be one of owner, admin, member")
+	}
+
+	return nil
+}
+
+type OrganizationInvitationParams struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+func (a *API) validateOrganizationInvitationParams(p *OrganizationInvitationParams) error {
+	var err error
+	if p.Email, err = a.validateEmail(p.Email); err != nil {
+		return err
+	}
+
+	if p.Role == "" {
+		p.Role = models.OrganizationRoleMember
+	}
+
+	if !models.IsValidOrganizationRole(p.Role) {
+		return badRequestError(ErrorCodeValidationFailed, "role must be one of owner, admin, member")
+	}
+
+	return nil
+}
+
+// UserOrganization is an organization with the role of the authenticated
+// user in it.
+type UserOrganization struct {
+	*models.Organization
+
+	Role string `json:"role"`
+}
+
+type UserOrganizationsResponse struct {
+	Organizations []UserOrganization `json:"organizations"`
+}
+
+type OrganizationMembersResponse struct {
+	Members []models.OrganizationMember `json:"members"`
+}
+
+type OrganizationInvitationsResponse struct {
+	Invitations []models.OrganizationInvitation `json:"invitations"`
+}
+
+// requireOrganizationRole returns an error if the authenticated user is not
+// an owner, or an admin when owner is false, of the organization. A nil
+// member is an administrator of the project, which may do anything.
+func requireOrganizationRole(member *models.OrganizationMember, owner bool) error {
+	if member == nil {
+		return nil
+	}
+
+	if owner && !member.IsOwner() {
+		return forbiddenError(ErrorCodeInsufficientOrganizationRole, "Only owners of the organization can perform this action")
+	}
+
+	if !member.CanManage() {
+		return forbiddenError(ErrorCodeInsufficientOrganizationRole, "Only owners and admins of the organization can perform this action")
+	}
+
+	return nil
+}
+
+// checkOrganizationSlug returns an error if the slug is used by another
+// organization.
+func checkOrganizationSlug(db *storage.Connection, slug string) error {
+	existing, err := models.FindOrganizationBySlug(db, slug)
+	if err != nil && !models.IsNotFoundError(err) {
+		return internalServerError("Database error finding organization").WithInternalError(err)
+	}
+
+	if existing != nil {
+		return badRequestError(ErrorCodeOrganizationSlugExists, "An organization with the slug '%s' already exists", slug)
+	}
+
+	return nil
+}
+
+// createOrganization creates an organization owned by the user.
+func (a *API) createOrganization(r *http.Request, db *storage.Connection, actor *models.User, params *OrganizationParams, ownerID uuid.UUID) (*models.Organization, error) {
+	if err := checkOrganizationSlug(db, params.Slug); err != nil {
+		return nil, err
+	}
+
+	organization, err := models.NewOrganization(params.Name, params.Slug, params.Metadata)
+	if err != nil {
+		return nil, internalServerError("Error creating organization").WithInternalError(err)
+	}
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := tx.Create(organization); terr != nil {
+			return terr
+		}
+
+		if _, terr := models.AddOrganizationMember(tx, organization.ID, ownerID, models.OrganizationRoleOwner); terr != nil {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, actor, models.OrganizationCreatedAction, "", map[string]interface{}{
+			"organization_id":   organization.ID,
+			"organization_slug": organization.Slug,
+			"owner_id":          ownerID,
+		})
+	}); err != nil {
+		return nil, internalServerError("Database error creating organization").WithInternalError(err)
+	}
+
+	return organization, nil
+}
+
+// updateOrganization updates the name, slug and metadata of the
+// organization.
+func updateOrganization(db *storage.Connection, organization *models.Organization, params *OrganizationParams) error {
+	if params.Slug != "" && params.Slug != organization.Slug {
+		if err := checkOrganizationSlug(db, params.Slug); err != nil {
+			return err
+		}
+
+		organization.Slug = params.Slug
+	}
+
+	if params.Name != "" {
+		organization.Name = params.Name
+	}
+
+	if params.Metadata != nil {
+		organization.Metadata = params.Metadata
+	}
+
+	if err := db.Update(organization); err != nil {
+		return internalServerError("Database error updating organization").WithInternalError(err)
+	}
+
+	return nil
+}
+
+func deleteOrganization(r *http.Request, db *storage.Connection, actor *models.User, organization *models.Organization) error {
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := tx.Destroy(organization); terr != nil {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, actor, models.OrganizationDeletedAction, "", map[string]interface{}{
+			"organization_id":   organization.ID,
+			"organization_slug": organization.Slug,
+		})
+	}); err != nil {
+		return internalServerError("Database error deleting organization").WithInternalError(err)
+	}
+
+	return nil
+}
+
+// findOrganizationMember finds the member in the user_id URL parameter.
+func findOrganizationMember(r *http.Request, db *storage.Connection, organization *models.Organization) (*models.OrganizationMember, error) {
+	userID, err := uuid.FromString(chi.URLParam(r, "user_id"))
+	if err != nil {
+		return nil, notFoundError(ErrorCodeValidationFailed, "user_id must be an UUID")
+	}
+
+	member, err := models.FindOrganizationMember(db, organization.ID, userID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, notFoundError(ErrorCodeOrganizationMemberNotFound, "User is not a member of the organization")
+		}
+		return nil, internalServerError("Database error finding organization member").WithInternalError(err)
+	}
+
+	return member, nil
+}
+
+// checkOrganizationHasOtherOwner returns an error if the member is the last
+// owner of the organization, which always has at least one owner.
+func checkOrganizationHasOtherOwner(tx *storage.Connection, member *models.OrganizationMember) error {
+	if !member.IsOwner() {
+		return nil
+	}
+
+	owners, err := models.CountOrganizationOwners(tx, member.OrganizationID)
+	if err != nil {
+		return internalServerError("Database error counting organization owners").WithInternalError(err)
+	}
+
+	if owners <= 1 {
+		return badRequestError(ErrorCodeOrganizationLastOwner, "The last owner of an organization can't be removed or demoted")
+	}
+
+	return nil
+}
+
+// updateOrganizationMember changes the role of a member. The actor is the
+// membership of the authenticated user, or nil for administrators.
+func updateOrganizationMember(r *http.Request, db *storage.Connection, actorUser *models.User, actor, member *models.OrganizationMember, role string) error {
+	if err := requireOrganizationRole(actor, member.IsOwner() || role == models.OrganizationRoleOwner); err != nil {
+		return err
+	}
+
+	if member.Role == role {
+		return nil
+	}
+
+	return db.Transaction(func(tx *storage.Connection) error {
+		if role != models.OrganizationRoleOwner {
+			if terr := checkOrganizationHasOtherOwner(tx, member); terr != nil {
+				return terr
+			}
+		}
+
+		member.Role = role
+		if terr := tx.UpdateOnly(member, "role"); terr != nil {
+			return internalServerError("Database error updating organization member").WithInternalError(terr)
+		}
+
+		if terr := models.NewAuditLogEntry(r, tx, actorUser, models.OrganizationMemberUpdatedAction, "", map[string]interface{}{
+			"organization_id": member.OrganizationID,
+			"user_id":         member.UserID,
+			"role":            role,
+		}); terr != nil {
+			return internalServerError("Database error updating organization member").WithInternalError(terr)
+		}
+
+		return nil
+	})
+}
+
+// removeOrganizationMember removes a member from the organization. Members
+// can always leave organizations themselves.
+func removeOrganizationMember(r *http.Request, db *storage.Connection, actorUser *models.User, actor, member *models.OrganizationMember) error {
+	if actor == nil || actor.UserID != member.UserID {
+		if err := requireOrganizationRole(actor, member.IsOwner()); err != nil {
+			return err
+		}
+	}
+
+	return db.Transaction(func(tx *storage.Connection) error {
+		if terr := checkOrganizationHasOtherOwner(tx, member); terr != nil {
+			return terr
+		}
+
+		if terr := tx.Destroy(member); terr != nil {
+			return internalServerError("Database error removing organization member").WithInternalError(terr)
+		}
+
+		if terr := models.NewAuditLogEntry(r, tx, actorUser, models.OrganizationMemberRemovedAction, "", map[string]interface{}{
+			"organization_id": member.OrganizationID,
+			"user_id":         member.UserID,
+		}); terr != nil {
+			return internalServerError("Database error removing organization member").WithInternalError(terr)
+		}
+
+		return nil
+	})
+}
+
+// createOrganizationInvitation invites the email address to join the
+// organization, replacing any previous invitation for it.
+func (a *API) createOrganizationInvitation(r *http.Request, db *storage.Connection, actorUser *models.User, actor *models.OrganizationMember, organization *models.Organization, params *OrganizationInvitationParams) (*models.OrganizationInvitation, error) {
+	if err := requireOrganizationRole(actor, params.Role == models.OrganizationRoleOwner); err != nil {
+		return nil, err
+	}
+
+	var invitation *models.OrganizationInvitation
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := models.DeleteOrganizationInvitationsForEmail(tx, organization.ID, params.Email); terr != nil {
+			return terr
+		}
+
+		id, terr := uuid.NewV4()
+		if terr != nil {
+			return terr
+		}
+
+		invitation = &models.OrganizationInvitation{
+			ID:             id,
+			OrganizationID: organization.ID,
+			Email:          params.Email,
+			Role:           params.Role,
+			ExpiresAt:      a.Now().Add(a.config.Organizations.InvitationExpiry),
+		}
+
+		if actor != nil {
+			invitation.InvitedBy = &actor.UserID
+		}
+
+		if terr := tx.Create(invitation); terr != nil {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, actorUser, models.OrganizationInvitationCreatedAction, "", map[string]interface{}{
+			"organization_id": organization.ID,
+			"invitation_id":   invitation.ID,
+			"email":           invitation.Email,
+			"role":            invitation.Role,
+		})
+	}); err != nil {
+		return nil, internalServerError("Database error creating organization invitation").WithInternalError(err)
+	}
+
+	return invitation, nil
+}
+
+// findOrganizationInvitation finds the invitation in the invitation_id URL
+// parameter among the invitations of the organization.
+func findOrganizationInvitation(r *http.Request, db *storage.Connection, organizationID uuid.UUID) (*models.OrganizationInvitation, error) {
+	invitationID, err := uuid.FromString(chi.URLParam(r, "invitation_id"))
+	if err != nil {
+		return nil, notFoundError(ErrorCodeValidationFailed, "invitation_id must be an UUID")
+	}
+
+	invitation, err := models.FindOrganizationInvitationByID(db, invitationID)
+	if err != nil && !models.IsNotFoundError(err) {
+		return nil, internalServerError("Database error finding organization invitation").WithInternalError(err)
+	}
+
+	if invitation == nil || (organizationID != uuid.Nil && invitation.OrganizationID != organizationID) {
+		return nil, notFoundError(ErrorCodeOrganizationInvitationNotFound, "Organization invitation not found")
+	}
+
+	return invitation, nil
+}
+
+// loadOrganizationMembership loads the organization in the organization_id
+// URL parameter and the membership of the authenticated user in it.
+// Organizations the user is not a member of are not found.
+func (a *API) loadOrganizationMembership(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+
+	organizationID, err := uuid.FromString(chi.URLParam(r, "organization_id"))
+	if err != nil {
+		return nil, notFoundError(ErrorCodeValidationFailed, "organization_id must be an UUID")
+	}
+
+	observability.LogEntrySetField(r, "organization_id", organizationID)
+
+	member, err := models.FindOrganizationMember(db, organizationID, user.ID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, notFoundError(ErrorCodeOrganizationNotFound, "Organization not found")
+		}
+		return nil, internalServerError("Database error finding organization member").WithInternalError(err)
+	}
+
+	organization, err := models.FindOrganizationByID(db, organizationID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, notFoundError(ErrorCodeOrganizationNotFound, "Organization not found")
+		}
+		return nil, internalServerError("Database error finding organization").WithInternalError(err)
+	}
+
+	return withOrganizationMember(withOrganization(ctx, organization), member), nil
+}
+
+// UserOrganizationsList lists the organizations of the authenticated user.
+func (a *API) UserOrganizationsList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+
+	organizations, err := models.FindOrganizationsForUser(db, user.ID)
+	if err != nil {
+		return internalServerError("Database error finding organizations").WithInternalError(err)
+	}
+
+	memberships, err := models.FindOrganizationMembershipsForUser(db, user.ID)
+	if err != nil {
+		return internalServerError("Database error finding organization memberships").WithInternalError(err)
+	}
+
+	roles := make(map[uuid.UUID]string, len(memberships))
+	for _, membership := range memberships {
+		roles[membership.OrganizationID] = membership.Role
+	}
+
+	response := UserOrganizationsResponse{
+		Organizations: make([]UserOrganization, 0, len(organizations)),
+	}
+
+	for _, organization := range organizations {
+		response.Organizations = append(response.Organizations, UserOrganization{
+			Organization: organization,
+			Role:         roles[organization.ID],
+		})
+	}
+
+	return sendJSON(w, http.StatusOK, response)
+}
+
+// UserOrganizationsCreate creates an organization owned by the authenticated
+// user.
+func (a *API) UserOrganizationsCreate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+
+	if !a.config.Organizations.AllowUserCreation {
+		return forbiddenError(ErrorCodeOrganizationCreationDisabled, "Organizations can only be created by administrators")
+	}
+
+	params := &OrganizationParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if params.OwnerID != nil {
+		return badRequestError(ErrorCodeValidationFailed, "owner_id can only be set by administrators")
+	}
+
+	if err := params.validate(false); err != nil {
+		return err
+	}
+
+	organization, err := a.createOrganization(r, db, user, params, user.ID)
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusCreated, UserOrganization{
+		Organization: organization,
+		Role:         models.OrganizationRoleOwner,
+	})
+}
+
+func (a *API) UserOrganizationGet(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	return sendJSON(w, http.StatusOK, UserOrganization{
+		Organization: getOrganization(ctx),
+		Role:         getOrganizationMember(ctx).Role,
+	})
+}
+
+// UserOrganizationUpdate updates an organization the authenticated user is
+// an owner or admin of.
+func (a *API) UserOrganizationUpdate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	organization := getOrganization(ctx)
+	member := getOrganizationMember(ctx)
+
+	if err := requireOrganizationRole(member, false); err != nil {
+		return err
+	}
+
+	params := &OrganizationParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if err := params.validate(true); err != nil {
+		return err
+	}
+
+	if err := updateOrganization(db, organization, params); err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, UserOrganization{
+		Organization: organization,
+		Role:         member.Role,
+	})
+}
+
+// UserOrganizationDelete deletes an organization the authenticated user
+// owns.
+func (a *API) UserOrganizationDelete(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	if err := requireOrganizationRole(getOrganizationMember(ctx), true); err != nil {
+		return err
+	}
+
+	if err := deleteOrganization(r, db, getUser(ctx), getOrganization(ctx)); err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+func (a *API) UserOrganizationMembersList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	members, err := models.FindOrganizationMembers(db, getOrganization(ctx).ID)
+	if err != nil {
+		return internalServerError("Database error finding organization members").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, OrganizationMembersResponse{
+		Members: members,
+	})
+}
+
+// UserOrganizationMemberUpdate changes the role of a member of the
+// organization. Only owners can make other members owners or change the
+// role of owners.
+func (a *API) UserOrganizationMemberUpdate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	params := &OrganizationMemberParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if err := params.validate(); err != nil {
+		return err
+	}
+
+	member, err := findOrganizationMember(r, db, getOrganization(ctx))
+	if err != nil {
+		return err
+	}
+
+	if err := updateOrganizationMember(r, db, getUser(ctx), getOrganizationMember(ctx), member, params.Role); err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, member)
+}
+
+// UserOrganizationMemberDelete removes a member from the organization, or
+// lets the authenticated user leave it.
+func (a *API) UserOrganizationMemberDelete(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	member, err := findOrganizationMember(r, db, getOrganization(ctx))
+	if err != nil {
+		return err
+	}
+
+	if err := removeOrganizationMember(r, db, getUser(ctx), getOrganizationMember(ctx), member); err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+func (a *API) UserOrganizationInvitationsList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	if err := requireOrganizationRole(getOrganizationMember(ctx), false); err != nil {
+		return err
+	}
+
+	invitations, err := models.FindOrganizationInvitations(db, getOrganization(ctx).ID)
+	if err != nil {
+		return internalServerError("Database error finding organization invitations").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, OrganizationInvitationsResponse{
+		Invitations: invitations,
+	})
+}
+
+func (a *API) UserOrganizationInvitationsCreate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	params := &OrganizationInvitationParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if err := a.validateOrganizationInvitationParams(params); err != nil {
+		return err
+	}
+
+	invitation, err := a.createOrganizationInvitation(r, db, getUser(ctx), getOrganizationMember(ctx), getOrganization(ctx), params)
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusCreated, invitation)
+}
+
+func (a *API) UserOrganizationInvitationDelete(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	if err := requireOrganizationRole(getOrganizationMember(ctx), false); err != nil {
+		return err
+	}
+
+	invitation, err := findOrganizationInvitation(r, db, getOrganization(ctx).ID)
+	if err != nil {
+		return err
+	}
+
+	if err := db.Destroy(invitation); err != nil {
+		return internalServerError("Database error deleting organization invitation").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+// findUserOrganizationInvitation finds the invitation in the invitation_id
+// URL parameter for the confirmed email address of the authenticated user.
+func findUserOrganizationInvitation(r *http.Request, db *storage.Connection, user *models.User) (*models.OrganizationInvitation, error) {
+	invitation, err := findOrganizationInvitation(r, db, uuid.Nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.EmailConfirmedAt == nil || !strings.EqualFold(invitation.Email, user.GetEmail()) {
+		return nil, notFoundError(ErrorCodeOrganizationInvitationNotFound, "Organization invitation not found")
+	}
+
+	return invitation, nil
+}
+
+// UserOrganizationInvitationsPending lists the invitations for the confirmed
+// email address of the authenticated user.
+func (a *API) UserOrganizationInvitationsPending(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+
+	invitations := []models.OrganizationInvitation{}
+
+	if user.EmailConfirmedAt != nil && user.GetEmail() != "" {
+		var err error
+		if invitations, err = models.FindOrganizationInvitationsForEmail(db, user.GetEmail(), a.Now()); err != nil {
+			return internalServerError("Database error finding organization invitations").WithInternalError(err)
+		}
+	}
+
+	return sendJSON(w, http.StatusOK, OrganizationInvitationsResponse{
+		Invitations: invitations,
+	})
+}
+
+// UserOrganizationInvitationAccept adds the authenticated user to the
+// organization with the role of the invitation.
+func (a *API) UserOrganizationInvitationAccept(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+
+	invitation, err := findUserOrganizationInvitation(r, db, user)
+	if err != nil {
+		return err
+	}
+
+	if invitation.IsExpired(a.Now()) {
+		return badRequestError(ErrorCodeOrganizationInvitationExpired, "Organization invitation has expired")
+	}
+
+	if _, err := models.FindOrganizationMember(db, invitation.OrganizationID, user.ID); err == nil {
+		return badRequestError(ErrorCodeOrganizationMemberExists, "User is already a member of the organization")
+	} else if !models.IsNotFoundError(err) {
+		return internalServerError("Database error finding organization member").WithInternalError(err)
+	}
+
+	var member *models.OrganizationMember
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if member, terr = models.AddOrganizationMember(tx, invitation.OrganizationID, user.ID, invitation.Role); terr != nil {
+			return terr
+		}
+
+		if terr := tx.Destroy(invitation); terr != nil {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, user, models.OrganizationMemberAddedAction, "", map[string]interface{}{
+			"organization_id": invitation.OrganizationID,
+			"invitation_id":   invitation.ID,
+			"role":            invitation.Role,
+		})
+	}); err != nil {
+		return internalServerError("Database error accepting organization invitation").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, member)
+}
+
+// UserOrganizationInvitationDecline deletes an invitation for the
+// authenticated user.
+func (a *API) UserOrganizationInvitationDecline(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	invitation, err := findUserOrganizationInvitation(r, db, getUser(ctx))
+	if err != nil {
+		return err
+	}
+
+	if err := db.Destroy(invitation); err != nil {
+		return internalServerError("Database error deleting organization invitation").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
@@ -0,0 +1,284 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+type OrganizationsTestSuite struct {
+	suite.Suite
+	API    *API
+	Config *conf.GlobalConfiguration
+}
+
+func TestOrganizations(t *testing.T) {
+	api, config, err := setupAPIForTestWithCallback(func(config *conf.GlobalConfiguration, conn *storage.Connection) {
+		if config != nil {
+			config.Organizations.Enabled = true
+			config.Organizations.AllowUserCreation = true
+		}
+	})
+	require.NoError(t, err)
+
+	ts := &OrganizationsTestSuite{
+		API:    api,
+		Config: config,
+	}
+	defer api.db.Close()
+
+	suite.Run(t, ts)
+}
+
+func (ts *OrganizationsTestSuite) SetupTest() {
+	models.TruncateAll(ts.API.db)
+}
+
+func (ts *OrganizationsTestSuite) createUser(email string) *models.User {
+	u, err := models.NewUser("", email, "password", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(u))
+	require.NoError(ts.T(), u.Confirm(ts.API.db))
+	return u
+}
+
+func (ts *OrganizationsTestSuite) generateAccessTokenAndSession(u *models.User) string {
+	s, err := models.NewSession(u.ID, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(s))
+
+	req := httptest.NewRequest(http.MethodPost, "/token?grant_type=password", nil)
+	token, _, err := ts.API.generateAccessToken(req, ts.API.db, u, &s.ID, models.PasswordGrant)
+	require.NoError(ts.T(), err)
+	return token
+}
+
+func (ts *OrganizationsTestSuite) request(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	var buffer bytes.Buffer
+	if body != nil {
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(body))
+	}
+
+	req := httptest.NewRequest(method, "http://localhost"+path, &buffer)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	ts.API.handler.ServeHTTP(w, req)
+
+	return w
+}
+
+func (ts *OrganizationsTestSuite) TestOrganizationInvitationFlow() {
+	owner := ts.createUser("owner@example.com")
+	invitee := ts.createUser("invitee@example.com")
+	other := ts.createUser("other@example.com")
+
+	ownerToken := ts.generateAccessTokenAndSession(owner)
+
+	w := ts.request(http.MethodPost, "/organizations", ownerToken, map[string]interface{}{
+		"name": "Acme",
+		"slug": "Acme",
+	})
+	require.Equal(ts.T(), http.StatusCreated, w.Code, w.Body.String())
+
+	var organization UserOrganization
+	require.NoError(ts.T(), json.Unmarshal(w.Body.Bytes(), &organization))
+	require.Equal(ts.T(), "acme", organization.Slug)
+	require.Equal(ts.T(), models.OrganizationRoleOwner, organization.Role)
+
+	// slugs are unique
+	w = ts.request(http.MethodPost, "/organizations", ownerToken, map[string]interface{}{
+		"name": "Acme 2",
+		"slug": "acme",
+	})
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+
+	organizationPath := "/organizations/" + organization.ID.String()
+
+	w = ts.request(http.MethodPost, organizationPath+"/invitations", ownerToken, map[string]interface{}{
+		"email": "Invitee@example.com",
+		"role":  models.OrganizationRoleAdmin,
+	})
+	require.Equal(ts.T(), http.StatusCreated, w.Code, w.Body.String())
+
+	var invitation models.OrganizationInvitation
+	require.NoError(ts.T(), json.Unmarshal(w.Body.Bytes(), &invitation))
+	require.Equal(ts.T(), "invitee@example.com", invitation.Email)
+
+	// organizations are not found by non-members, and invitations are only
+	// visible to the invited email address
+	otherToken := ts.generateAccessTokenAndSession(other)
+	require.Equal(ts.T(), http.StatusNotFound, ts.request(http.MethodGet, organizationPath, otherToken, nil).Code)
+	require.Equal(ts.T(), http.StatusNotFound, ts.request(http.MethodPost, "/organizations/invitations/"+invitation.ID.String()+"/accept", otherToken, nil).Code)
+
+	inviteeToken := ts.generateAccessTokenAndSession(invitee)
+
+	w = ts.request(http.MethodGet, "/organizations/invitations", inviteeToken, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var pending OrganizationInvitationsResponse
+	require.NoError(ts.T(), json.Unmarshal(w.Body.Bytes(), &pending))
+	require.Len(ts.T(), pending.Invitations, 1)
+
+	w = ts.request(http.MethodPost, "/organizations/invitations/"+invitation.ID.String()+"/accept", inviteeToken, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	// access tokens include the memberships of the user
+	inviteeToken = ts.generateAccessTokenAndSession(invitee)
+
+	claims := &AccessTokenClaims{}
+	_, _, err := jwt.NewParser().ParseUnverified(inviteeToken, claims)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), []models.OrganizationMembership{
+		{
+			OrganizationID: organization.ID,
+			Slug:           "acme",
+			Role:           models.OrganizationRoleAdmin,
+		},
+	}, claims.Organizations)
+
+	// admins can't make themselves owners or remove owners
+	inviteePath := organizationPath + "/members/" + invitee.ID.String()
+	ownerPath := organizationPath + "/members/" + owner.ID.String()
+
+	require.Equal(ts.T(), http.StatusForbidden, ts.request(http.MethodPut, inviteePath, inviteeToken, map[string]interface{}{
+		"role": models.OrganizationRoleOwner,
+	}).Code)
+	require.Equal(ts.T(), http.StatusForbidden, ts.request(http.MethodDelete, ownerPath, inviteeToken, nil).Code)
+
+	// the last owner can't leave
+	require.Equal(ts.T(), http.StatusBadRequest, ts.request(http.MethodDelete, ownerPath, ownerToken, nil).Code)
+
+	require.Equal(ts.T(), http.StatusOK, ts.request(http.MethodPut, inviteePath, ownerToken, map[string]interface{}{
+		"role": models.OrganizationRoleOwner,
+	}).Code)
+	require.Equal(ts.T(), http.StatusOK, ts.request(http.MethodDelete, ownerPath, ownerToken, nil).Code)
+
+	w = ts.request(http.MethodGet, organizationPath+"/members", inviteeToken, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var members OrganizationMembersResponse
+	require.NoError(ts.T(), json.Unmarshal(w.Body.Bytes(), &members))
+	require.Len(ts.T(), members.Members, 1)
+	require.Equal(ts.T(), invitee.ID, members.Members[0].UserID)
+}
+
+func (ts *OrganizationsTestSuite) TestAdminOrganizations() {
+	owner := ts.createUser("owner@example.com")
+	member := ts.createUser("member@example.com")
+
+	adminToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &AccessTokenClaims{
+		Role: "supabase_admin",
+	}).SignedString([]byte(ts.Config.JWT.Secret))
+	require.NoError(ts.T(), err)
+
+	// organizations created with the admin API need an owner
+	require.Equal(ts.T(), http.StatusBadRequest, ts.request(http.MethodPost, "/admin/organizations", adminToken, map[string]interface{}{
+		"name": "Acme",
+		"slug": "acme",
+	}).Code)
+
+	w := ts.request(http.MethodPost, "/admin/organizations", adminToken, map[string]interface{}{
+		"name":     "Acme",
+		"slug":     "acme",
+		"owner_id": owner.ID,
+	})
+	require.Equal(ts.T(), http.StatusCreated, w.Code, w.Body.String())
+
+	var organization models.Organization
+	require.NoError(ts.T(), json.Unmarshal(w.Body.Bytes(), &organization))
+
+	membersPath := "/admin/organizations/" + organization.ID.String() + "/members/"
+
+	require.Equal(ts.T(), http.StatusCreated, ts.request(http.MethodPut, membersPath+member.ID.String(), adminToken, map[string]interface{}{
+		"role": models.OrganizationRoleMember,
+	}).Code)
+	require.Equal(ts.T(), http.StatusOK, ts.request(http.MethodPut, membersPath+member.ID.String(), adminToken, map[string]interface{}{
+		"role": models.OrganizationRoleAdmin,
+	}).Code)
+
+	memberOfOrganization, err := models.FindOrganizationMember(ts.API.db, organization.ID, member.ID)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), models.OrganizationRoleAdmin, memberOfOrganization.Role)
+
+	w = ts.request(http.MethodGet, "/admin/organizations", adminToken, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var list AdminListOrganizationsResponse
+	require.NoError(ts.T(), json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(ts.T(), list.Organizations, 1)
+
+	require.Equal(ts.T(), http.StatusOK, ts.request(http.MethodDelete, "/admin/organizations/"+organization.ID.String(), adminToken, nil).Code)
+
+	_, err = models.FindOrganizationMember(ts.API.db, organization.ID, member.ID)
+	require.True(ts.T(), models.IsNotFoundError(err))
+}
+
+func TestOrganizationParamsValidation(t *testing.T) {
+	examples := []struct {
+		Params    OrganizationParams
+		ForUpdate bool
+		Valid     bool
+	}{
+		{
+			Params: OrganizationParams{Name: "Acme", Slug: "acme-inc"},
+			Valid:  true,
+		},
+		{
+			Params: OrganizationParams{Name: " Acme ", Slug: " ACME "},
+			Valid:  true,
+		},
+		{
+			Params: OrganizationParams{Slug: "acme"},
+			Valid:  false,
+		},
+		{
+			Params: OrganizationParams{Name: "Acme"},
+			Valid:  false,
+		},
+		{
+			Params: OrganizationParams{Name: "Acme", Slug: "-acme"},
+			Valid:  false,
+		},
+		{
+			Params: OrganizationParams{Name: "Acme", Slug: "acme.inc"},
+			Valid:  false,
+		},
+		{
+			Params:    OrganizationParams{Name: "Acme"},
+			ForUpdate: true,
+			Valid:     true,
+		},
+	}
+
+	for i, example := range examples {
+		err := example.Params.validate(example.ForUpdate)
+		if example.Valid {
+			require.NoError(t, err, "Example %d failed", i)
+		} else {
+			require.Error(t, err, "Example %d failed", i)
+		}
+	}
+}
+
+func TestRequireOrganizationRole(t *testing.T) {
+	owner := &models.OrganizationMember{Role: models.OrganizationRoleOwner}
+	admin := &models.OrganizationMember{Role: models.OrganizationRoleAdmin}
+	member := &models.OrganizationMember{Role: models.OrganizationRoleMember}
+
+	require.NoError(t, requireOrganizationRole(nil, true))
+	require.NoError(t, requireOrganizationRole(owner, true))
+	require.NoError(t, requireOrganizationRole(admin, false))
+	require.Error(t, requireOrganizationRole(admin, true))
+	require.Error(t, requireOrganizationRole(member, false))
+}
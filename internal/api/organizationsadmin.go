@@ -0,0 +1,272 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+)
+
+type AdminListOrganizationsResponse struct {
+	Organizations []*models.Organization `json:"organizations"`
+}
+
+// loadOrganization looks for an organization_id parameter in the URL route
+// and loads the organization with that ID into the context.
+func (a *API) loadOrganization(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	organizationID, err := uuid.FromString(chi.URLParam(r, "organization_id"))
+	if err != nil {
+		return nil, notFoundError(ErrorCodeValidationFailed, "organization_id must be an UUID")
+	}
+
+	observability.LogEntrySetField(r, "organization_id", organizationID)
+
+	organization, err := models.FindOrganizationByID(db, organizationID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, notFoundError(ErrorCodeOrganizationNotFound, "Organization not found")
+		}
+		return nil, internalServerError("Database error finding organization").WithInternalError(err)
+	}
+
+	return withOrganization(ctx, organization), nil
+}
+
+// adminOrganizationsList lists all organizations, the most recently created
+// first.
+func (a *API) adminOrganizationsList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	pageParams, err := paginate(r)
+	if err != nil {
+		return badRequestError(ErrorCodeValidationFailed, "Bad Pagination Parameters: %v", err).WithInternalError(err)
+	}
+
+	organizations, err := models.FindOrganizations(db, pageParams)
+	if err != nil {
+		return internalServerError("Database error finding organizations").WithInternalError(err)
+	}
+	addPaginationHeaders(w, r, pageParams)
+
+	return sendJSON(w, http.StatusOK, AdminListOrganizationsResponse{
+		Organizations: organizations,
+	})
+}
+
+// adminOrganizationsCreate creates an organization owned by the user with
+// the owner_id.
+func (a *API) adminOrganizationsCreate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	params := &OrganizationParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if err := params.validate(false); err != nil {
+		return err
+	}
+
+	if params.OwnerID == nil {
+		return badRequestError(ErrorCodeValidationFailed, "owner_id is required")
+	}
+
+	if _, err := models.FindUserByID(db, *params.OwnerID); err != nil {
+		if models.IsNotFoundError(err) {
+			return notFoundError(ErrorCodeUserNotFound, "User not found")
+		}
+		return internalServerError("Database error loading user").WithInternalError(err)
+	}
+
+	organization, err := a.createOrganization(r, db, getAdminUser(ctx), params, *params.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusCreated, organization)
+}
+
+func (a *API) adminOrganizationGet(w http.ResponseWriter, r *http.Request) error {
+	return sendJSON(w, http.StatusOK, getOrganization(r.Context()))
+}
+
+func (a *API) adminOrganizationUpdate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	organization := getOrganization(ctx)
+
+	params := &OrganizationParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if err := params.validate(true); err != nil {
+		return err
+	}
+
+	if err := updateOrganization(db, organization, params); err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, organization)
+}
+
+func (a *API) adminOrganizationDelete(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	if err := deleteOrganization(r, db, getAdminUser(ctx), getOrganization(ctx)); err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+func (a *API) adminOrganizationMembersList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	members, err := models.FindOrganizationMembers(db, getOrganization(ctx).ID)
+	if err != nil {
+		return internalServerError("Database error finding organization members").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, OrganizationMembersResponse{
+		Members: members,
+	})
+}
+
+// adminOrganizationMemberUpdate adds the user to the organization with the
+// role, or changes the role of a member.
+func (a *API) adminOrganizationMemberUpdate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	organization := getOrganization(ctx)
+	adminUser := getAdminUser(ctx)
+
+	params := &OrganizationMemberParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if err := params.validate(); err != nil {
+		return err
+	}
+
+	userID, err := uuid.FromString(chi.URLParam(r, "user_id"))
+	if err != nil {
+		return notFoundError(ErrorCodeValidationFailed, "user_id must be an UUID")
+	}
+
+	member, err := models.FindOrganizationMember(db, organization.ID, userID)
+	if err == nil {
+		if err := updateOrganizationMember(r, db, adminUser, nil, member, params.Role); err != nil {
+			return err
+		}
+
+		return sendJSON(w, http.StatusOK, member)
+	} else if !models.IsNotFoundError(err) {
+		return internalServerError("Database error finding organization member").WithInternalError(err)
+	}
+
+	user, err := models.FindUserByID(db, userID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return notFoundError(ErrorCodeUserNotFound, "User not found")
+		}
+		return internalServerError("Database error loading user").WithInternalError(err)
+	}
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if member, terr = models.AddOrganizationMember(tx, organization.ID, user.ID, params.Role); terr != nil {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, adminUser, models.OrganizationMemberAddedAction, "", map[string]interface{}{
+			"organization_id": organization.ID,
+			"user_id":         user.ID,
+			"role":            params.Role,
+		})
+	}); err != nil {
+		return internalServerError("Database error adding organization member").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusCreated, member)
+}
+
+func (a *API) adminOrganizationMemberDelete(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	member, err := findOrganizationMember(r, db, getOrganization(ctx))
+	if err != nil {
+		return err
+	}
+
+	if err := removeOrganizationMember(r, db, getAdminUser(ctx), nil, member); err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+func (a *API) adminOrganizationInvitationsList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	invitations, err := models.FindOrganizationInvitations(db, getOrganization(ctx).ID)
+	if err != nil {
+		return internalServerError("Database error finding organization invitations").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, OrganizationInvitationsResponse{
+		Invitations: invitations,
+	})
+}
+
+func (a *API) adminOrganizationInvitationsCreate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	params := &OrganizationInvitationParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if err := a.validateOrganizationInvitationParams(params); err != nil {
+		return err
+	}
+
+	invitation, err := a.createOrganizationInvitation(r, db, getAdminUser(ctx), nil, getOrganization(ctx), params)
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusCreated, invitation)
+}
+
+func (a *API) adminOrganizationInvitationDelete(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	invitation, err := findOrganizationInvitation(r, db, getOrganization(ctx).ID)
+	if err != nil {
+		return err
+	}
+
+	if err := db.Destroy(invitation); err != nil {
+		return internalServerError("Database error deleting organization invitation").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
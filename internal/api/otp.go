@@ -122,6 +122,10 @@ func (a *API) SmsOtp(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	if err := a.validateUserMetadata(params.Data); err != nil {
+		return err
+	}
+
 	var isNewUser bool
 	aud := a.requestAud(ctx, r)
 	user, err := models.FindUserByPhoneAndAudience(db, params.Phone, aud)
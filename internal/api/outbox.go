@@ -0,0 +1,353 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/api/sms_provider"
+	"github.com/supabase/auth/internal/mailer"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// outboxBatchSize is how many messages a worker claims at once.
+	outboxBatchSize = 10
+
+	// outboxLease is how long the messages being sent are hidden from other
+	// workers.
+	outboxLease = time.Minute
+)
+
+var outboxMessageCounter = observability.ObtainMetricCounter("gotrue_outbox_messages", "Number of outbox messages sent by channel, provider and result")
+
+// outboxSMS is an SMS message queued in the outbox, with what the SMS
+// provider is called with when it's sent.
+type outboxSMS struct {
+	Phone   string `json:"phone"`
+	Message string `json:"message"`
+	Channel string `json:"channel"`
+	OTP     string `json:"otp"`
+}
+
+// enqueueOutboxMessage queues a message of the channel in the outbox, and
+// returns its ID.
+func (a *API) enqueueOutboxMessage(channel, provider, recipient string, payload interface{}) (string, error) {
+	config := a.config
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	message, err := models.NewOutboxMessage(channel, provider, recipient, nil, data, config.Security.DBEncryption.Encrypt, config.Security.DBEncryption.EncryptionKeyID, config.Security.DBEncryption.EncryptionKey)
+	if err != nil {
+		return "", err
+	}
+
+	if err := a.db.Create(message); err != nil {
+		return "", fmt.Errorf("error queueing %s in the outbox: %w", channel, err)
+	}
+
+	return message.ID.String(), nil
+}
+
+func (a *API) enqueueEmail(email *mailer.OutboxEmail) error {
+	_, err := a.enqueueEmailWithID(email)
+	return err
+}
+
+// enqueueEmailWithID queues the email in the outbox, and returns the ID of
+// its outbox message.
+func (a *API) enqueueEmailWithID(email *mailer.OutboxEmail) (string, error) {
+	provider := a.config.Mailer.Provider
+	if provider == "" {
+		provider = "smtp"
+	}
+
+	return a.enqueueOutboxMessage(models.OutboxChannelEmail, provider, email.To, email)
+}
+
+// outboxSmsProvider queues SMS messages in the outbox instead of sending
+// them, and returns the ID of the outbox message as the message ID.
+type outboxSmsProvider struct {
+	api *API
+}
+
+func (p *outboxSmsProvider) SendMessage(phone, message, channel, otp string) (string, error) {
+	return p.api.enqueueOutboxMessage(models.OutboxChannelSMS, p.api.config.Sms.Provider, phone, &outboxSMS{
+		Phone:   phone,
+		Message: message,
+		Channel: channel,
+		OTP:     otp,
+	})
+}
+
+// smsProvider returns the SMS provider messages are sent with, which queues
+// them in the outbox when it's enabled.
+func (a *API) smsProvider() (sms_provider.SmsProvider, error) {
+	if a.config.Outbox.Enabled {
+		return &outboxSmsProvider{api: a}, nil
+	}
+
+	return sms_provider.GetSmsProvider(*a.config)
+}
+
+// outboxRateLimiters limits the messages sent with each provider, across
+// the workers of an instance.
+type outboxRateLimiters struct {
+	mutex    sync.Mutex
+	limits   map[string]float64
+	limiters map[string]*rate.Limiter
+}
+
+func newOutboxRateLimiters(limits map[string]float64) *outboxRateLimiters {
+	return &outboxRateLimiters{
+		limits:   limits,
+		limiters: make(map[string]*rate.Limiter),
+	}
+}
+
+// delay reserves the sending of a message with the provider, and returns
+// how long to wait before it can be sent when it can't be sent now.
+func (l *outboxRateLimiters) delay(provider string) time.Duration {
+	limit, ok := l.limits[provider]
+	if !ok {
+		return 0
+	}
+
+	l.mutex.Lock()
+	limiter, ok := l.limiters[provider]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(limit), max(1, int(limit)))
+		l.limiters[provider] = limiter
+	}
+	l.mutex.Unlock()
+
+	reservation := limiter.Reserve()
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return delay
+	}
+
+	return 0
+}
+
+// ProcessOutbox sends the messages of the outbox with the configured
+// workers until the context is done.
+func (a *API) ProcessOutbox(ctx context.Context) {
+	config := a.config
+	if !config.Outbox.Enabled {
+		return
+	}
+
+	limiters := newOutboxRateLimiters(config.Outbox.RateLimits)
+
+	var wg sync.WaitGroup
+	for i := 0; i < config.Outbox.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ticker := time.NewTicker(config.Outbox.Interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+
+				case <-ticker.C:
+					a.processOutboxMessages(ctx, limiters)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func (a *API) processOutboxMessages(ctx context.Context, limiters *outboxRateLimiters) {
+	db := a.db.WithContext(ctx)
+
+	messages, err := models.ClaimDueOutboxMessages(db, outboxBatchSize, outboxLease)
+	if err != nil {
+		logrus.WithError(err).Error("Unable to claim outbox messages")
+		return
+	}
+
+	for _, message := range messages {
+		result, err := a.sendOutboxMessage(db, message, limiters)
+		if err != nil {
+			logrus.WithError(err).WithField("outbox_message_id", message.ID).Error("Unable to send outbox message")
+			continue
+		}
+
+		outboxMessageCounter.Add(ctx, 1, metric.WithAttributeSet(attribute.NewSet(attribute.String("channel", message.Channel), attribute.String("provider", message.Provider), attribute.String("result", result))))
+	}
+}
+
+// sendOutboxMessage sends the message with its provider. It returns whether
+// the message was sent, postponed by the rate limit of the provider, retried
+// later or failed.
+func (a *API) sendOutboxMessage(db *storage.Connection, message *models.OutboxMessage, limiters *outboxRateLimiters) (string, error) {
+	config := a.config
+	now := time.Now()
+
+	if delay := limiters.delay(message.Provider); delay > 0 {
+		return "postponed", message.Postpone(db, now.Add(delay))
+	}
+
+	payload, err := message.GetPayload(config.Security.DBEncryption.DecryptionKeys)
+	if err != nil {
+		return "", err
+	}
+
+	// the type of SMS messages isn't queued with them
+	var providerMessageID, messageType string
+	switch message.Channel {
+	case models.OutboxChannelEmail:
+		var email mailer.OutboxEmail
+		if err := json.Unmarshal(payload, &email); err != nil {
+			return "", err
+		}
+		providerMessageID, err = email.Send(mailer.NewMailClient(config))
+		messageType = email.Type
+
+	case models.OutboxChannelSMS:
+		var sms outboxSMS
+		if err := json.Unmarshal(payload, &sms); err != nil {
+			return "", err
+		}
+
+		smsProvider, perr := sms_provider.GetSmsProvider(*config)
+		if perr != nil {
+			return "", perr
+		}
+		providerMessageID, err = smsProvider.SendMessage(sms.Phone, sms.Message, sms.Channel, sms.OTP)
+		messageType = "sms"
+
+	default:
+		return "", fmt.Errorf("unknown outbox channel %q", message.Channel)
+	}
+
+	if err != nil {
+		if merr := message.MarkFailed(db, err.Error(), config.Outbox.MaxAttempts, now.Add(config.Outbox.Backoff(message.Attempts+1))); merr != nil {
+			return "", merr
+		}
+
+		if message.Status == models.OutboxStatusFailed {
+			return models.OutboxStatusFailed, nil
+		}
+		return "retried", nil
+	}
+
+	if err := message.MarkSent(db, providerMessageID); err != nil {
+		return "", err
+	}
+
+	a.recordMessageDelivery(message.Channel, message.Provider, providerMessageID, message.Recipient, message.UserID, messageType)
+
+	return models.OutboxStatusSent, nil
+}
+
+type AdminListOutboxMessagesResponse struct {
+	Messages []*models.OutboxMessage `json:"messages"`
+}
+
+func (a *API) loadOutboxMessage(db *storage.Connection, r *http.Request) (*models.OutboxMessage, error) {
+	messageID, err := uuid.FromString(chi.URLParam(r, "message_id"))
+	if err != nil {
+		return nil, notFoundError(ErrorCodeValidationFailed, "message_id must be an UUID")
+	}
+
+	message, err := models.FindOutboxMessageByID(db, messageID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, notFoundError(ErrorCodeOutboxMessageNotFound, "Outbox message not found")
+		}
+		return nil, internalServerError("Database error finding outbox message").WithInternalError(err)
+	}
+
+	return message, nil
+}
+
+// adminOutboxMessagesList lists the messages of the outbox with their
+// delivery status, the most recent first. They can be filtered with the
+// status and channel query parameters.
+func (a *API) adminOutboxMessagesList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", models.OutboxStatusPending, models.OutboxStatusSent, models.OutboxStatusFailed:
+	default:
+		return badRequestError(ErrorCodeValidationFailed, "status must be either %s, %s or %s", models.OutboxStatusPending, models.OutboxStatusSent, models.OutboxStatusFailed)
+	}
+
+	channel := r.URL.Query().Get("channel")
+	if channel != "" && channel != models.OutboxChannelEmail && channel != models.OutboxChannelSMS {
+		return badRequestError(ErrorCodeValidationFailed, "channel must be either %s or %s", models.OutboxChannelEmail, models.OutboxChannelSMS)
+	}
+
+	pageParams, err := paginate(r)
+	if err != nil {
+		return badRequestError(ErrorCodeValidationFailed, "Bad Pagination Parameters: %v", err).WithInternalError(err)
+	}
+
+	messages, err := models.FindOutboxMessages(db, status, channel, pageParams)
+	if err != nil {
+		return internalServerError("Database error finding outbox messages").WithInternalError(err)
+	}
+	addPaginationHeaders(w, r, pageParams)
+
+	return sendJSON(w, http.StatusOK, AdminListOutboxMessagesResponse{
+		Messages: messages,
+	})
+}
+
+func (a *API) adminOutboxMessageGet(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	message, err := a.loadOutboxMessage(db, r)
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, message)
+}
+
+// adminOutboxMessageRetry sends a failed message again, with its attempts
+// reset.
+func (a *API) adminOutboxMessageRetry(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	message, err := a.loadOutboxMessage(db, r)
+	if err != nil {
+		return err
+	}
+
+	if message.Status != models.OutboxStatusFailed {
+		return unprocessableEntityError(ErrorCodeValidationFailed, "Only failed outbox messages can be retried")
+	}
+
+	if err := message.Retry(db); err != nil {
+		return internalServerError("Database error retrying outbox message").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, message)
+}
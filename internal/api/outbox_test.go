@@ -0,0 +1,204 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+	"gopkg.in/h2non/gock.v1"
+)
+
+type OutboxTestSuite struct {
+	suite.Suite
+	API    *API
+	Config *conf.GlobalConfiguration
+
+	token string
+}
+
+func TestOutbox(t *testing.T) {
+	api, config, err := setupAPIForTest()
+	require.NoError(t, err)
+
+	ts := &OutboxTestSuite{
+		API:    api,
+		Config: config,
+	}
+	defer api.db.Close()
+
+	suite.Run(t, ts)
+}
+
+func (ts *OutboxTestSuite) SetupTest() {
+	models.TruncateAll(ts.API.db)
+
+	ts.Config.Outbox = conf.OutboxConfiguration{
+		Enabled:        true,
+		Workers:        1,
+		MaxAttempts:    2,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		Interval:       time.Second,
+	}
+	ts.Config.Sms.Provider = "twilio"
+	ts.Config.Sms.Twilio = conf.TwilioProviderConfiguration{
+		AccountSid:        "test_account_sid",
+		AuthToken:         "test_auth_token",
+		MessageServiceSid: "test_message_service_sid",
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &AccessTokenClaims{
+		Role: "supabase_admin",
+	}).SignedString([]byte(ts.Config.JWT.Secret))
+	require.NoError(ts.T(), err)
+	ts.token = token
+}
+
+func (ts *OutboxTestSuite) TearDownTest() {
+	ts.Config.Outbox.Enabled = false
+}
+
+func (ts *OutboxTestSuite) makeDue() {
+	require.NoError(ts.T(), ts.API.db.RawQuery("update outbox_messages set next_attempt_at = now() - interval '1 second'").Exec())
+}
+
+func (ts *OutboxTestSuite) TestSendSMS() {
+	defer gock.OffAll()
+
+	twilioURL := "https://api.twilio.com/2010-04-01/Accounts/test_account_sid/Messages.json"
+
+	// messages are queued instead of being sent during the request
+	provider, err := ts.API.smsProvider()
+	require.NoError(ts.T(), err)
+	messageID, err := provider.SendMessage("123456789", "Your code is 123456", "sms", "123456")
+	require.NoError(ts.T(), err)
+
+	messages, err := models.FindOutboxMessages(ts.API.db, models.OutboxStatusPending, models.OutboxChannelSMS, nil)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), messages, 1)
+	require.Equal(ts.T(), messageID, messages[0].ID.String())
+	require.Equal(ts.T(), "twilio", messages[0].Provider)
+
+	// the payload holds the OTP, so it's encrypted
+	require.NotContains(ts.T(), messages[0].Payload, "123456")
+
+	// failures are retried
+	gock.New(twilioURL).Post("").Reply(http.StatusInternalServerError).JSON(map[string]interface{}{"code": 20500, "message": "Internal Server Error"})
+
+	ts.API.processOutboxMessages(context.Background(), newOutboxRateLimiters(nil))
+	message, err := models.FindOutboxMessageByID(ts.API.db, messages[0].ID)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), models.OutboxStatusPending, message.Status)
+	require.Equal(ts.T(), 1, message.Attempts)
+	require.NotEmpty(ts.T(), message.LastError)
+
+	// and the delivery status is tracked once sent
+	gock.New(twilioURL).Post("").Reply(http.StatusCreated).JSON(map[string]interface{}{"sid": "SM123", "status": "queued"})
+
+	ts.makeDue()
+	ts.API.processOutboxMessages(context.Background(), newOutboxRateLimiters(nil))
+	message, err = models.FindOutboxMessageByID(ts.API.db, messages[0].ID)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), models.OutboxStatusSent, message.Status)
+	require.Equal(ts.T(), 2, message.Attempts)
+	require.Equal(ts.T(), "SM123", message.ProviderMessageID.String())
+	require.NotNil(ts.T(), message.SentAt)
+}
+
+func (ts *OutboxTestSuite) TestFailedMessagesCanBeRetried() {
+	defer gock.OffAll()
+
+	twilioURL := "https://api.twilio.com/2010-04-01/Accounts/test_account_sid/Messages.json"
+
+	provider, err := ts.API.smsProvider()
+	require.NoError(ts.T(), err)
+	messageID, err := provider.SendMessage("123456789", "Your code is 123456", "sms", "123456")
+	require.NoError(ts.T(), err)
+
+	// messages fail after the max attempts
+	gock.New(twilioURL).Post("").Times(2).Reply(http.StatusInternalServerError).JSON(map[string]interface{}{"code": 20500, "message": "Internal Server Error"})
+
+	ts.API.processOutboxMessages(context.Background(), newOutboxRateLimiters(nil))
+	ts.makeDue()
+	ts.API.processOutboxMessages(context.Background(), newOutboxRateLimiters(nil))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/outbox?status=failed", nil)
+	req.Header.Set("Authorization", "Bearer "+ts.token)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var list AdminListOutboxMessagesResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&list))
+	require.Len(ts.T(), list.Messages, 1)
+	require.Equal(ts.T(), messageID, list.Messages[0].ID.String())
+	require.Equal(ts.T(), 2, list.Messages[0].Attempts)
+
+	// admins can send them again
+	req = httptest.NewRequest(http.MethodPost, "/admin/outbox/"+messageID+"/retry", nil)
+	req.Header.Set("Authorization", "Bearer "+ts.token)
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	gock.New(twilioURL).Post("").Reply(http.StatusCreated).JSON(map[string]interface{}{"sid": "SM123", "status": "queued"})
+
+	ts.API.processOutboxMessages(context.Background(), newOutboxRateLimiters(nil))
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/outbox/"+messageID, nil)
+	req.Header.Set("Authorization", "Bearer "+ts.token)
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var message models.OutboxMessage
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&message))
+	require.Equal(ts.T(), models.OutboxStatusSent, message.Status)
+
+	// only failed messages can be retried
+	req = httptest.NewRequest(http.MethodPost, "/admin/outbox/"+messageID+"/retry", nil)
+	req.Header.Set("Authorization", "Bearer "+ts.token)
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+}
+
+func (ts *OutboxTestSuite) TestRateLimitedMessagesArePostponed() {
+	provider, err := ts.API.smsProvider()
+	require.NoError(ts.T(), err)
+	_, err = provider.SendMessage("123456789", "Your code is 123456", "sms", "123456")
+	require.NoError(ts.T(), err)
+
+	limiters := newOutboxRateLimiters(map[string]float64{"twilio": 1})
+	require.Zero(ts.T(), limiters.delay("twilio"))
+
+	ts.API.processOutboxMessages(context.Background(), limiters)
+
+	messages, err := models.FindOutboxMessages(ts.API.db, models.OutboxStatusPending, "", nil)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), messages, 1)
+	require.Equal(ts.T(), 0, messages[0].Attempts)
+	require.True(ts.T(), messages[0].NextAttemptAt.After(time.Now()))
+}
+
+func TestOutboxRateLimiters(t *testing.T) {
+	limiters := newOutboxRateLimiters(map[string]float64{"smtp": 2})
+
+	// providers without a rate limit are unlimited
+	for i := 0; i < 10; i++ {
+		assert.Zero(t, limiters.delay("twilio"))
+	}
+
+	assert.Zero(t, limiters.delay("smtp"))
+	assert.Zero(t, limiters.delay("smtp"))
+	assert.Positive(t, limiters.delay("smtp"))
+}
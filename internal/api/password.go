@@ -4,8 +4,13 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/security"
+	"github.com/supabase/auth/internal/storage"
 )
 
 // BCrypt hashed passwords have a 72 character limit
@@ -14,60 +19,269 @@ const MaxPasswordLength = 72
 // WeakPasswordError encodes an error that a password does not meet strength
 // requirements. It is handled specially in errors.go as it gets transformed to
 // a HTTPError with a special weak_password field that encodes the Reasons
-// slice.
+// slice, and the Feedback of the strength estimate clients can show users.
 type WeakPasswordError struct {
-	Message string   `json:"message,omitempty"`
-	Reasons []string `json:"reasons,omitempty"`
+	Message  string                     `json:"message,omitempty"`
+	Reasons  []string                   `json:"reasons,omitempty"`
+	Feedback *security.PasswordFeedback `json:"feedback,omitempty"`
 }
 
 func (e *WeakPasswordError) Error() string {
 	return e.Message
 }
 
-func (a *API) checkPasswordStrength(ctx context.Context, password string) error {
+// pwnedPasswordsChecker checks whether passwords are in the Pwned Passwords
+// list of breached passwords.
+type pwnedPasswordsChecker interface {
+	Check(ctx context.Context, password string) (bool, error)
+}
+
+// passwordViolation is a requirement a password does not meet.
+type passwordViolation struct {
+	Reason   string
+	Message  string
+	Feedback *security.PasswordFeedback
+}
+
+// passwordPolicy checks a password meets one of the requirements of
+// passwords. The user inputs are the details of the user, like their email
+// or name, passwords shouldn't be made of.
+type passwordPolicy func(ctx context.Context, password string, userInputs []string) (*passwordViolation, error)
+
+// passwordPolicies returns the enabled policies, cheapest first.
+func (a *API) passwordPolicies() []passwordPolicy {
 	config := a.config
 
+	policies := []passwordPolicy{
+		a.checkPasswordLength,
+		a.checkPasswordCharacters,
+	}
+
+	if config.Password.Strength.Enabled {
+		policies = append(policies, a.checkPasswordScore)
+	}
+
+	if config.Password.HIBP.Enabled {
+		policies = append(policies, a.checkPasswordPwned)
+	}
+
+	return policies
+}
+
+func (a *API) checkPasswordStrength(ctx context.Context, password string, userInputs ...string) error {
 	if len(password) > MaxPasswordLength {
 		return badRequestError(ErrorCodeValidationFailed, fmt.Sprintf("Password cannot be longer than %v characters", MaxPasswordLength))
 	}
 
 	var messages, reasons []string
+	var feedback *security.PasswordFeedback
+
+	for _, policy := range a.passwordPolicies() {
+		violation, err := policy(ctx, password, userInputs)
+		if err != nil {
+			return err
+		}
+		if violation == nil {
+			continue
+		}
 
-	if len(password) < config.Password.MinLength {
-		reasons = append(reasons, "length")
-		messages = append(messages, fmt.Sprintf("Password should be at least %d characters.", config.Password.MinLength))
+		reasons = append(reasons, violation.Reason)
+		messages = append(messages, violation.Message)
+		if violation.Feedback != nil {
+			feedback = violation.Feedback
+		}
 	}
 
-	for _, characterSet := range config.Password.RequiredCharacters {
+	if len(reasons) > 0 {
+		return &WeakPasswordError{
+			Message:  strings.Join(messages, " "),
+			Reasons:  reasons,
+			Feedback: feedback,
+		}
+	}
+
+	return nil
+}
+
+func (a *API) checkPasswordLength(ctx context.Context, password string, userInputs []string) (*passwordViolation, error) {
+	minLength := a.config.Password.MinLength
+
+	if len(password) < minLength {
+		return &passwordViolation{
+			Reason:  "length",
+			Message: fmt.Sprintf("Password should be at least %d characters.", minLength),
+		}, nil
+	}
+
+	return nil, nil
+}
+
+func (a *API) checkPasswordCharacters(ctx context.Context, password string, userInputs []string) (*passwordViolation, error) {
+	requiredCharacters := a.config.Password.RequiredCharacters
+
+	for _, characterSet := range requiredCharacters {
 		if characterSet != "" && !strings.ContainsAny(password, characterSet) {
-			reasons = append(reasons, "characters")
+			return &passwordViolation{
+				Reason:  "characters",
+				Message: fmt.Sprintf("Password should contain at least one character of each: %s.", strings.Join(requiredCharacters, ", ")),
+			}, nil
+		}
+	}
+
+	return nil, nil
+}
 
-			messages = append(messages, fmt.Sprintf("Password should contain at least one character of each: %s.", strings.Join(config.Password.RequiredCharacters, ", ")))
+// checkPasswordScore estimates how many guesses attackers trying common
+// passwords, patterns and the details of the user first would need to find
+// the password, and rejects it when its score is under the minimum.
+func (a *API) checkPasswordScore(ctx context.Context, password string, userInputs []string) (*passwordViolation, error) {
+	strength := security.EstimatePasswordStrength(password, userInputs)
 
-			break
+	if strength.Score < a.config.Password.Strength.MinScore {
+		message := "Password is too easy to guess."
+		if strength.Feedback.Warning != "" {
+			message = fmt.Sprintf("Password is too easy to guess: %s", strength.Feedback.Warning)
 		}
+
+		return &passwordViolation{
+			Reason:   "strength",
+			Message:  message,
+			Feedback: &strength.Feedback,
+		}, nil
 	}
 
-	if config.Password.HIBP.Enabled {
-		pwned, err := a.hibpClient.Check(ctx, password)
+	return nil, nil
+}
+
+func (a *API) checkPasswordPwned(ctx context.Context, password string, userInputs []string) (*passwordViolation, error) {
+	config := a.config
+
+	source := "HaveIBeenPwned.org"
+	if config.Password.HIBP.Offline.Enabled {
+		source = "the local Pwned Passwords list"
+	}
+
+	pwned, err := a.hibpClient.Check(ctx, password)
+	if err != nil {
+		if config.Password.HIBP.FailClosed {
+			return nil, internalServerError("Unable to perform password strength check with " + source + ".").WithInternalError(err)
+		}
+
+		logrus.WithError(err).Warn("Unable to perform password strength check with " + source + ", pwned passwords are being allowed")
+		return nil, nil
+	}
+
+	if pwned {
+		return &passwordViolation{
+			Reason:  "pwned",
+			Message: "Password is known to be weak and easy to guess, please choose a different one.",
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// passwordUserInputs returns the details of a user attackers would try in
+// their password first: the email and the words of its local part, the
+// phone, the username and the names in the user metadata.
+func passwordUserInputs(email, phone, username string, metadata map[string]interface{}) []string {
+	var inputs []string
+
+	if email != "" {
+		inputs = append(inputs, email)
+		if local, _, found := strings.Cut(email, "@"); found {
+			inputs = append(inputs, local)
+			inputs = append(inputs, strings.FieldsFunc(local, func(r rune) bool {
+				return !unicode.IsLetter(r)
+			})...)
+		}
+	}
+
+	if phone != "" {
+		inputs = append(inputs, phone)
+	}
+
+	if username != "" {
+		inputs = append(inputs, username)
+	}
+
+	for _, key := range []string{"name", "full_name", "first_name", "last_name", "user_name", "preferred_username", "nickname"} {
+		if value, ok := metadata[key].(string); ok && value != "" {
+			inputs = append(inputs, value)
+			inputs = append(inputs, strings.Fields(value)...)
+		}
+	}
+
+	return inputs
+}
+
+// userPasswordInputs returns the details of the user attackers would try in
+// their password first.
+func userPasswordInputs(user *models.User) []string {
+	if user == nil {
+		return nil
+	}
+
+	return passwordUserInputs(user.GetEmail(), user.GetPhone(), user.GetUsername(), user.UserMetaData)
+}
+
+// checkPasswordHistory returns an error when the password is one of the
+// previous passwords of the user kept in its history.
+func (a *API) checkPasswordHistory(ctx context.Context, db *storage.Connection, user *models.User, password string) error {
+	config := a.config
+	depth := config.Password.History.Depth
+
+	if depth == 0 || password == "" {
+		return nil
+	}
+
+	history, err := models.FindPasswordHistory(db, user.ID, depth)
+	if err != nil {
+		return internalServerError("Database error finding password history").WithInternalError(err)
+	}
+
+	for _, previous := range history {
+		matches, err := previous.Matches(ctx, password, config.Security.DBEncryption.DecryptionKeys)
 		if err != nil {
-			if config.Password.HIBP.FailClosed {
-				return internalServerError("Unable to perform password strength check with HaveIBeenPwned.org.").WithInternalError(err)
-			} else {
-				logrus.WithError(err).Warn("Unable to perform password strength check with HaveIBeenPwned.org, pwned passwords are being allowed")
-			}
-		} else if pwned {
-			reasons = append(reasons, "pwned")
-			messages = append(messages, "Password is known to be weak and easy to guess, please choose a different one.")
+			return internalServerError("Error checking password history").WithInternalError(err)
+		}
+		if matches {
+			return unprocessableEntityError(ErrorCodePasswordReused, "New password should be different from the last %d passwords.", depth)
 		}
 	}
 
-	if len(reasons) > 0 {
-		return &WeakPasswordError{
-			Message: strings.Join(messages, " "),
-			Reasons: reasons,
+	return nil
+}
+
+// checkPasswordMinAge returns an error when the password of the user was
+// changed more recently than the minimum age of passwords.
+func (a *API) checkPasswordMinAge(db *storage.Connection, user *models.User) error {
+	minAge := a.config.Password.History.MinAge
+	if minAge == 0 {
+		return nil
+	}
+
+	history, err := models.FindPasswordHistory(db, user.ID, 1)
+	if err != nil {
+		return internalServerError("Database error finding password history").WithInternalError(err)
+	}
+
+	if len(history) > 0 {
+		if changeableAt := history[0].CreatedAt.Add(minAge); time.Now().Before(changeableAt) {
+			return unprocessableEntityError(ErrorCodePasswordTooRecent, "Password was changed too recently, it can be changed again after %s.", changeableAt.UTC().Format(time.RFC3339))
 		}
 	}
 
 	return nil
 }
+
+// recordPasswordHistory keeps the password the user replaced in its history,
+// when the history is enabled.
+func (a *API) recordPasswordHistory(tx *storage.Connection, user *models.User, previousPassword *string) error {
+	depth := a.config.Password.History.Depth
+	if depth == 0 || previousPassword == nil || *previousPassword == "" {
+		return nil
+	}
+
+	return models.AddPasswordHistory(tx, user.ID, *previousPassword, depth)
+}
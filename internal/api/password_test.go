@@ -115,3 +115,62 @@ func TestPasswordStrengthChecks(t *testing.T) {
 		}
 	}
 }
+
+func TestPasswordStrengthScoreCheck(t *testing.T) {
+	api := &API{
+		config: &conf.GlobalConfiguration{
+			Password: conf.PasswordConfiguration{
+				MinLength: 6,
+				Strength: conf.PasswordStrengthConfiguration{
+					Enabled:  true,
+					MinScore: 3,
+				},
+			},
+		},
+	}
+
+	userInputs := passwordUserInputs("jane.doe@example.com", "", "", map[string]interface{}{
+		"full_name": "Jane Doe",
+	})
+
+	examples := []struct {
+		Password string
+		Reasons  []string
+		Warning  string
+	}{
+		{
+			Password: "password",
+			Reasons:  []string{"strength"},
+			Warning:  "This is a top-10 common password.",
+		},
+		{
+			Password: "janedoe",
+			Reasons:  []string{"strength"},
+			Warning:  "Passwords containing your email, name or username are easy to guess.",
+		},
+		{
+			Password: "abc",
+			Reasons:  []string{"length", "strength"},
+			Warning:  "Sequences like abc or 6543 are easy to guess.",
+		},
+		{
+			Password: "correct horse battery staple",
+		},
+	}
+
+	for i, example := range examples {
+		err := api.checkPasswordStrength(context.Background(), example.Password, userInputs...)
+
+		if example.Reasons == nil {
+			require.NoError(t, err, "Example %d failed with error", i)
+			continue
+		}
+
+		e, ok := err.(*WeakPasswordError)
+		require.True(t, ok, "Example %d failed with error %v", i, err)
+		require.Equal(t, example.Reasons, e.Reasons, "Example %d failed with wrong reasons", i)
+		require.NotNil(t, e.Feedback, "Example %d failed without feedback", i)
+		require.Equal(t, example.Warning, e.Feedback.Warning, "Example %d failed with wrong warning", i)
+		require.NotEmpty(t, e.Feedback.Suggestions, "Example %d failed without suggestions", i)
+	}
+}
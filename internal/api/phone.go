@@ -8,11 +8,10 @@ import (
 	"text/template"
 	"time"
 
-	"github.com/didip/tollbooth/v5"
 	"github.com/supabase/auth/internal/hooks"
 
 	"github.com/pkg/errors"
-	"github.com/supabase/auth/internal/api/sms_provider"
+	"github.com/supabase/auth/internal/conf"
 	"github.com/supabase/auth/internal/crypto"
 	"github.com/supabase/auth/internal/models"
 	"github.com/supabase/auth/internal/storage"
@@ -23,6 +22,9 @@ var e164Format = regexp.MustCompile("^[1-9][0-9]{1,14}$")
 const (
 	phoneConfirmationOtp     = "confirmation"
 	phoneReauthenticationOtp = "reauthentication"
+	// phoneChangeCurrentOtp is sent to the current phone number of the
+	// user when it confirms phone changes too
+	phoneChangeCurrentOtp = "phone_change_current"
 )
 
 func validatePhone(phone string) (string, error) {
@@ -57,7 +59,13 @@ func (a *API) sendPhoneConfirmation(r *http.Request, tx *storage.Connection, use
 		token = &user.PhoneChangeToken
 		sentAt = user.PhoneChangeSentAt
 		user.PhoneChange = phone
-		includeFields = append(includeFields, "phone_change", "phone_change_token", "phone_change_sent_at")
+		user.PhoneChangeTokenCurrent = ""
+		user.PhoneChangeConfirmStatus = zeroConfirmation
+		includeFields = append(includeFields, "phone_change", "phone_change_token", "phone_change_sent_at", "phone_change_token_current", "phone_change_confirm_status")
+	case phoneChangeCurrentOtp:
+		// sent right after the OTP to the new number, which is rate limited
+		token = &user.PhoneChangeTokenCurrent
+		includeFields = append(includeFields, "phone_change_token_current")
 	case phoneConfirmationOtp:
 		token = &user.ConfirmationToken
 		sentAt = user.ConfirmationSentAt
@@ -91,10 +99,13 @@ func (a *API) sendPhoneConfirmation(r *http.Request, tx *storage.Connection, use
 		// apply rate limiting before the sms is sent out
 		limiter := getLimiter(ctx)
 		if limiter != nil {
-			if err := tollbooth.LimitByKeys(limiter.PhoneLimiter, []string{"phone_functions"}); err != nil {
+			if !limiter.PhoneLimiter.allow(ctx, "phone_functions") {
 				return "", tooManyRequestsError(ErrorCodeOverSMSSendRateLimit, "SMS rate limit exceeded")
 			}
 		}
+		if !a.recipientLimiter.allowSMS(ctx, phone) {
+			return "", tooManyRequestsError(ErrorCodeOverSMSSendRateLimit, "SMS rate limit exceeded")
+		}
 		otp, err = crypto.GenerateOtp(config.Sms.OtpLength)
 		if err != nil {
 			return "", internalServerError("error generating otp").WithInternalError(err)
@@ -106,17 +117,21 @@ func (a *API) sendPhoneConfirmation(r *http.Request, tx *storage.Connection, use
 					OTP: otp,
 				},
 			}
+			if otpType == phoneChangeCurrentOtp {
+				input.SMS.SMSType = phoneChangeCurrentOtp
+				input.SMS.Phone = phone
+			}
 			output := hooks.SendSMSOutput{}
 			err := a.invokeHook(tx, r, &input, &output)
 			if err != nil {
 				return "", err
 			}
 		} else {
-			smsProvider, err := sms_provider.GetSmsProvider(*config)
+			smsProvider, err := a.smsProvider()
 			if err != nil {
 				return "", internalServerError("Unable to get SMS provider").WithInternalError(err)
 			}
-			message, err := generateSMSFromTemplate(config.Sms.SMSTemplate, otp)
+			message, err := generateSMSFromTemplate(a.smsTemplate(r, user, otpType), otp)
 			if err != nil {
 				return "", internalServerError("error generating sms template").WithInternalError(err)
 			}
@@ -124,6 +139,10 @@ func (a *API) sendPhoneConfirmation(r *http.Request, tx *storage.Connection, use
 			if err != nil {
 				return messageID, unprocessableEntityError(ErrorCodeSMSSendFailed, "Error sending %s OTP to provider: %v", otpType, err)
 			}
+			// messages queued in the outbox are recorded once they're sent
+			if !config.Outbox.Enabled {
+				a.recordMessageDelivery(models.OutboxChannelSMS, config.Sms.Provider, messageID, phone, &user.ID, otpType)
+			}
 		}
 	}
 
@@ -163,6 +182,36 @@ func (a *API) sendPhoneConfirmation(r *http.Request, tx *storage.Connection, use
 	return messageID, nil
 }
 
+// userLocale returns the locale of the user that localized templates are
+// sent in, or an empty string when localization is not enabled.
+func (a *API) userLocale(r *http.Request, user *models.User) string {
+	return a.config.Localization.UserLocale(user.UserMetaData, r.Header.Get("Accept-Language"))
+}
+
+// smsTypes are the types of the SMS message templates of the OTP types.
+var smsTypes = map[string]string{
+	phoneConfirmationOtp:     conf.SMSTypeSignup,
+	phoneReauthenticationOtp: conf.SMSTypeReauthentication,
+	phoneChangeVerification:  conf.SMSTypePhoneChange,
+	phoneChangeCurrentOtp:    conf.SMSTypePhoneChange,
+}
+
+// smsTemplate returns the template of the SMS message of the OTP type, from
+// the most specific of the localized and configured templates.
+func (a *API) smsTemplate(r *http.Request, user *models.User, otpType string) *template.Template {
+	smsType := smsTypes[otpType]
+
+	if localized := a.config.Localization.SMSTemplate(a.userLocale(r, user), smsType); localized != nil {
+		return localized
+	}
+
+	if t := a.config.Sms.TypeTemplates[smsType]; t != nil {
+		return t
+	}
+
+	return a.config.Sms.SMSTemplate
+}
+
 func generateSMSFromTemplate(SMSTemplate *template.Template, otp string) (string, error) {
 	var message bytes.Buffer
 	if err := SMSTemplate.Execute(&message, struct {
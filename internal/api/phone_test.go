@@ -229,12 +229,14 @@ func (ts *PhoneTestSuite) TestMissingSmsProviderConfig() {
 		},
 	}
 
-	smsProviders := []string{"twilio", "messagebird", "textlocal", "vonage"}
+	smsProviders := []string{"twilio", "messagebird", "textlocal", "vonage", "sns", "sinch"}
 	ts.Config.External.Phone.Enabled = true
 	ts.Config.Sms.Twilio.AccountSid = ""
 	ts.Config.Sms.Messagebird.AccessKey = ""
 	ts.Config.Sms.Textlocal.ApiKey = ""
 	ts.Config.Sms.Vonage.ApiKey = ""
+	ts.Config.Sms.SNS.Region = ""
+	ts.Config.Sms.Sinch.APIToken = ""
 
 	for _, c := range cases {
 		for _, provider := range smsProviders {
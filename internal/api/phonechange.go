@@ -0,0 +1,191 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/supabase/auth/internal/api/sms_provider"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/hooks"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+	"github.com/supabase/auth/internal/utilities"
+)
+
+const (
+	phoneChangedNotification = "phone_changed"
+	phoneChangeUndoneMessage = "Phone change undone. Please sign in again and review the security of your account"
+)
+
+// phoneChangeUndoURL returns the link to undo a phone change, shortened when
+// short links are enabled as it's sent in an SMS message.
+func (a *API) phoneChangeUndoURL(r *http.Request, tokenHash string) (string, error) {
+	path, err := url.Parse("/phone_change/undo")
+	if err != nil {
+		return "", err
+	}
+	path.RawQuery = url.Values{"token_hash": {tokenHash}}.Encode()
+
+	undoURL := getExternalHost(r.Context()).ResolveReference(path).String()
+	if a.config.ShortLinks.Enabled {
+		return a.shortenURL(undoURL)
+	}
+
+	return undoURL, nil
+}
+
+// notifyPhoneChanged notifies the old phone number of the user that it was
+// changed, with a link to undo the change valid for the configured undo
+// expiry.
+func (a *API) notifyPhoneChanged(r *http.Request, tx *storage.Connection, user *models.User, oldPhone string) error {
+	config := a.config
+
+	if !config.Sms.PhoneChange.NotifyOld || oldPhone == "" || oldPhone == user.GetPhone() {
+		return nil
+	}
+
+	undo := models.NewPhoneChangeUndo(user, oldPhone, crypto.SecureToken(), time.Now().Add(config.Sms.PhoneChange.UndoExpiry))
+	if err := tx.Create(undo); err != nil {
+		return internalServerError("Error notifying phone change").WithInternalError(err)
+	}
+
+	undoURL, err := a.phoneChangeUndoURL(r, undo.TokenHash)
+	if err != nil {
+		return internalServerError("Error notifying phone change").WithInternalError(err)
+	}
+
+	if config.Hook.SendSMS.Enabled {
+		input := hooks.SendSMSInput{
+			User: user,
+			SMS: hooks.SMS{
+				SMSType: phoneChangedNotification,
+				Phone:   oldPhone,
+				UndoURL: undoURL,
+			},
+		}
+		output := hooks.SendSMSOutput{}
+		return a.invokeHook(tx, r, &input, &output)
+	}
+
+	var message bytes.Buffer
+	if err := config.Sms.PhoneChange.NotifySMSTemplate.Execute(&message, map[string]interface{}{
+		"Phone":    user.GetPhone(),
+		"OldPhone": oldPhone,
+		"UndoURL":  undoURL,
+	}); err != nil {
+		return internalServerError("Error generating phone change notification").WithInternalError(err)
+	}
+
+	smsProvider, err := a.smsProvider()
+	if err != nil {
+		return internalServerError("Unable to get SMS provider").WithInternalError(err)
+	}
+
+	messageID, err := smsProvider.SendMessage(oldPhone, message.String(), sms_provider.SMSProvider, "")
+	if err != nil {
+		return unprocessableEntityError(ErrorCodeSMSSendFailed, "Error sending phone change notification to provider: %v", err)
+	}
+	// messages queued in the outbox are recorded once they're sent
+	if !config.Outbox.Enabled {
+		a.recordMessageDelivery(models.OutboxChannelSMS, config.Sms.Provider, messageID, oldPhone, &user.ID, phoneChangedNotification)
+	}
+
+	return nil
+}
+
+// UndoPhoneChange restores the old phone number of a user with the link
+// sent to it after the change. All sessions of the user are revoked, as the
+// change may have been made by someone who took over the account.
+func (a *API) UndoPhoneChange(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+
+	tokenHash := r.FormValue("token_hash")
+	redirectTo := utilities.GetReferrer(r, config)
+
+	err := db.Transaction(func(tx *storage.Connection) error {
+		if tokenHash == "" {
+			return badRequestError(ErrorCodeValidationFailed, "Undoing a phone change requires a token hash")
+		}
+
+		undo, terr := models.FindPhoneChangeUndoByTokenHash(tx, tokenHash)
+		if terr != nil {
+			if models.IsNotFoundError(terr) {
+				return forbiddenError(ErrorCodeOTPExpired, "Phone change undo link is invalid or has expired")
+			}
+			return internalServerError("Database error finding phone change undo").WithInternalError(terr)
+		}
+
+		if undo.IsExpired(time.Now()) {
+			return forbiddenError(ErrorCodeOTPExpired, "Phone change undo link is invalid or has expired")
+		}
+
+		user, terr := models.FindUserByID(tx, undo.UserID)
+		if terr != nil {
+			return internalServerError("Database error finding user").WithInternalError(terr)
+		}
+
+		if exists, terr := models.IsDuplicatedPhone(tx, undo.OldPhone, user.Aud); terr != nil {
+			return internalServerError("Database error checking phone").WithInternalError(terr)
+		} else if exists {
+			return unprocessableEntityError(ErrorCodePhoneExists, "The old phone number is used by another user")
+		}
+
+		// restoring the old number reuses the confirmation of a phone
+		// change, which cancels any change in progress
+		user.PhoneChange = undo.OldPhone
+		if terr := user.ConfirmPhoneChange(tx); terr != nil {
+			return internalServerError("Error undoing phone change").WithInternalError(terr)
+		}
+
+		if identity, terr := models.FindIdentityByIdAndProvider(tx, user.ID.String(), "phone"); terr != nil {
+			if !models.IsNotFoundError(terr) {
+				return internalServerError("Database error finding identity").WithInternalError(terr)
+			}
+		} else if terr := identity.UpdateIdentityData(tx, map[string]interface{}{
+			"phone":          undo.OldPhone,
+			"phone_verified": true,
+		}); terr != nil {
+			return internalServerError("Error undoing phone change").WithInternalError(terr)
+		}
+
+		if terr := models.ClearPhoneChangeUndosForUser(tx, user.ID); terr != nil {
+			return internalServerError("Error undoing phone change").WithInternalError(terr)
+		}
+
+		if terr := models.Logout(tx, user.ID); terr != nil {
+			return internalServerError("Error revoking sessions").WithInternalError(terr)
+		}
+
+		if terr := models.NewAuditLogEntry(r, tx, user, models.PhoneChangeUndoneAction, "", map[string]interface{}{
+			"old_phone": undo.NewPhone,
+			"new_phone": undo.OldPhone,
+		}); terr != nil {
+			return internalServerError("Error recording audit log entry").WithInternalError(terr)
+		}
+
+		return nil
+	})
+
+	rurl := ""
+	if err != nil {
+		var herr *HTTPError
+		if !errors.As(err, &herr) {
+			return err
+		}
+
+		rurl, err = a.prepErrorRedirectURL(herr, r, redirectTo, models.ImplicitFlow)
+	} else {
+		rurl, err = a.prepRedirectURL(phoneChangeUndoneMessage, redirectTo, models.ImplicitFlow)
+	}
+	if err != nil {
+		return err
+	}
+
+	http.Redirect(w, r, rurl, http.StatusSeeOther)
+	return nil
+}
@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"context"
+	"strings"
+
+	"github.com/supabase/auth/internal/conf"
+	"golang.org/x/oauth2"
+)
+
+// Login with Amazon
+
+const (
+	defaultAmazonAuthBase = "www.amazon.com"
+	defaultAmazonAPIBase  = "api.amazon.com"
+)
+
+type amazonProvider struct {
+	*oauth2.Config
+	APIPath string
+}
+
+// amazonUser is the customer profile returned by Login with Amazon. See:
+// https://developer.amazon.com/docs/login-with-amazon/obtain-customer-profile.html
+type amazonUser struct {
+	ID         string `json:"user_id"`
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+	PostalCode string `json:"postal_code"`
+}
+
+// NewAmazonProvider creates a Login with Amazon account provider.
+func NewAmazonProvider(ext conf.OAuthProviderConfiguration, scopes string) (OAuthProvider, error) {
+	if err := ext.ValidateOAuth(); err != nil {
+		return nil, err
+	}
+
+	authHost := chooseHost(ext.URL, defaultAmazonAuthBase)
+	apiHost := chooseHost(ext.ApiURL, defaultAmazonAPIBase)
+
+	// the profile scope grants access to the user_id, name and email
+	// fields, postal_code requires its own scope
+	oauthScopes := []string{
+		"profile",
+	}
+
+	if scopes != "" {
+		oauthScopes = append(oauthScopes, strings.Split(scopes, ",")...)
+	}
+
+	return &amazonProvider{
+		Config: &oauth2.Config{
+			ClientID:     ext.ClientID[0],
+			ClientSecret: ext.Secret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  authHost + "/ap/oa",
+				TokenURL: apiHost + "/auth/o2/token",
+			},
+			RedirectURL: ext.RedirectURI,
+			Scopes:      oauthScopes,
+		},
+		APIPath: apiHost,
+	}, nil
+}
+
+func (g amazonProvider) GetOAuthToken(code string) (*oauth2.Token, error) {
+	return g.Exchange(context.Background(), code)
+}
+
+func (g amazonProvider) GetUserData(ctx context.Context, tok *oauth2.Token) (*UserProvidedData, error) {
+	var u amazonUser
+	if err := makeRequest(ctx, tok, g.Config, g.APIPath+"/user/profile", &u); err != nil {
+		return nil, err
+	}
+
+	data := &UserProvidedData{}
+	if u.Email != "" {
+		data.Emails = []Email{{
+			Email: u.Email,
+			// Amazon requires customers to verify their email
+			// address before the account can be used
+			Verified: true,
+			Primary:  true,
+		}}
+	}
+
+	data.Metadata = &Claims{
+		Issuer:  g.APIPath,
+		Subject: u.ID,
+		Name:    u.Name,
+
+		// To be deprecated
+		FullName:   u.Name,
+		ProviderId: u.ID,
+	}
+
+	if u.PostalCode != "" {
+		data.Metadata.CustomClaims = map[string]interface{}{
+			"postal_code": u.PostalCode,
+		}
+	}
+
+	return data, nil
+}
@@ -27,6 +27,8 @@ const (
 type azureProvider struct {
 	*oauth2.Config
 
+	ext conf.AzureProviderConfiguration
+
 	// ExpectedIssuer contains the OIDC issuer that should be expected when
 	// the authorize flow completes. For example, when using the "common"
 	// endpoint the authorization flow will end with an ID token that
@@ -50,7 +52,7 @@ func IsAzureCIAMIssuer(issuer string) bool {
 }
 
 // NewAzureProvider creates a Azure account provider.
-func NewAzureProvider(ext conf.OAuthProviderConfiguration, scopes string) (OAuthProvider, error) {
+func NewAzureProvider(ext conf.AzureProviderConfiguration, scopes string) (OAuthProvider, error) {
 	if err := ext.ValidateOAuth(); err != nil {
 		return nil, err
 	}
@@ -87,6 +89,7 @@ func NewAzureProvider(ext conf.OAuthProviderConfiguration, scopes string) (OAuth
 			RedirectURL: ext.RedirectURI,
 			Scopes:      oauthScopes,
 		},
+		ext:            ext,
 		ExpectedIssuer: expectedIssuer,
 	}, nil
 }
@@ -146,7 +149,7 @@ func (g azureProvider) GetUserData(ctx context.Context, tok *oauth2.Token) (*Use
 			return nil, err
 		}
 
-		_, data, err := ParseIDToken(ctx, provider, &oidc.Config{
+		token, data, err := ParseIDToken(ctx, provider, &oidc.Config{
 			ClientID: g.ClientID,
 		}, idToken.(string), ParseIDTokenOptions{
 			AccessToken: tok.AccessToken,
@@ -155,6 +158,16 @@ func (g azureProvider) GetUserData(ctx context.Context, tok *oauth2.Token) (*Use
 			return nil, err
 		}
 
+		if err := CheckAzureTenant(g.ext, token); err != nil {
+			return nil, err
+		}
+
+		appMetadata, err := AzureAppMetadata(ctx, g.ext, token, tok.AccessToken)
+		if err != nil {
+			return nil, err
+		}
+		data.AppMetadata = appMetadata
+
 		return data, nil
 	}
 
@@ -162,3 +175,127 @@ func (g azureProvider) GetUserData(ctx context.Context, tok *oauth2.Token) (*Use
 
 	return nil, fmt.Errorf("azure: no OIDC ID token present in response")
 }
+
+// azureAuthorizationClaims are the ID token claims used for tenant
+// restrictions and group-driven authorization. See:
+// https://learn.microsoft.com/en-us/entra/identity-platform/id-token-claims-reference
+type azureAuthorizationClaims struct {
+	TenantID string   `json:"tid"`
+	Groups   []string `json:"groups"`
+	Roles    []string `json:"roles"`
+
+	// When a user is a member of more groups than fit in a token, Azure
+	// omits the groups claim and instead points to it with _claim_names
+	// (authorization code flow) or sets hasgroups (implicit flow).
+	ClaimNames map[string]string `json:"_claim_names"`
+	HasGroups  bool              `json:"hasgroups"`
+}
+
+func (c *azureAuthorizationClaims) hasGroupsOverage() bool {
+	if c.HasGroups {
+		return true
+	}
+
+	_, ok := c.ClaimNames["groups"]
+	return ok
+}
+
+// CheckAzureTenant returns an error if the tenant that issued the ID token is
+// not in the configured list of allowed tenants.
+func CheckAzureTenant(ext conf.AzureProviderConfiguration, idToken *oidc.IDToken) error {
+	if len(ext.AllowedTenants) == 0 {
+		return nil
+	}
+
+	var claims azureAuthorizationClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return err
+	}
+
+	for _, tenant := range ext.AllowedTenants {
+		if claims.TenantID != "" && strings.EqualFold(tenant, claims.TenantID) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("azure: ID token tenant %q is not allowed: %w", claims.TenantID, ErrAccessDenied)
+}
+
+// AzureAppMetadata returns the groups and app roles of the user as a value
+// suitable for merging into app_metadata, or nil if group syncing is
+// disabled. When the ID token reports a groups overage and the Graph
+// fallback is enabled, the groups are fetched from the Graph API using the
+// provided access token.
+func AzureAppMetadata(ctx context.Context, ext conf.AzureProviderConfiguration, idToken *oidc.IDToken, accessToken string) (map[string]interface{}, error) {
+	if !ext.SyncGroups {
+		return nil, nil
+	}
+
+	var claims azureAuthorizationClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	groups := claims.Groups
+	if claims.hasGroupsOverage() && ext.GraphGroupsFallback {
+		if accessToken == "" {
+			return nil, fmt.Errorf("azure: groups overage in ID token but no access token to query the Graph API")
+		}
+
+		var err error
+		groups, err = fetchAzureGraphGroups(ctx, ext.GraphURL, accessToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if groups == nil {
+		groups = []string{}
+	}
+
+	roles := claims.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+
+	return map[string]interface{}{
+		"azure": map[string]interface{}{
+			"tenant_id": claims.TenantID,
+			"groups":    groups,
+			"roles":     roles,
+		},
+	}, nil
+}
+
+// maxAzureGraphPages bounds the number of pages followed when listing group
+// memberships, as a user with an unreasonable amount of groups should not
+// hold up the sign in.
+const maxAzureGraphPages = 50
+
+type azureGraphMemberOfPage struct {
+	Value []struct {
+		ID string `json:"id"`
+	} `json:"value"`
+	NextLink string `json:"@odata.nextLink"`
+}
+
+func fetchAzureGraphGroups(ctx context.Context, graphURL, accessToken string) ([]string, error) {
+	tok := &oauth2.Token{AccessToken: accessToken}
+	next := chooseHost(graphURL, "graph.microsoft.com") + "/v1.0/me/transitiveMemberOf/microsoft.graph.group?$select=id"
+
+	groups := []string{}
+	for i := 0; next != "" && i < maxAzureGraphPages; i += 1 {
+		var page azureGraphMemberOfPage
+		if err := makeRequest(ctx, tok, &oauth2.Config{}, next, &page); err != nil {
+			return nil, fmt.Errorf("azure: unable to fetch groups from Graph API: %w", err)
+		}
+
+		for _, group := range page.Value {
+			groups = append(groups, group.ID)
+		}
+
+		next = page.NextLink
+	}
+
+	return groups, nil
+}
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -14,11 +15,16 @@ import (
 const (
 	defaultGitHubAuthBase = "github.com"
 	defaultGitHubAPIBase  = "api.github.com"
+
+	// githubPageSize is the number of memberships requested per page.
+	githubPageSize = 100
 )
 
 type githubProvider struct {
 	*oauth2.Config
 	APIHost string
+
+	ext conf.GitHubProviderConfiguration
 }
 
 type githubUser struct {
@@ -35,8 +41,24 @@ type githubUserEmail struct {
 	Verified bool   `json:"verified"`
 }
 
-// NewGithubProvider creates a Github account provider.
-func NewGithubProvider(ext conf.OAuthProviderConfiguration, scopes string) (OAuthProvider, error) {
+type githubOrgMembership struct {
+	State        string `json:"state"`
+	Organization struct {
+		Login string `json:"login"`
+	} `json:"organization"`
+}
+
+type githubTeam struct {
+	Slug         string `json:"slug"`
+	Organization struct {
+		Login string `json:"login"`
+	} `json:"organization"`
+}
+
+// NewGithubProvider creates a Github account provider. When the URL is set
+// to a GitHub Enterprise Server instance its API is used, which is served
+// under /api/v3 unless the API URL is set.
+func NewGithubProvider(ext conf.GitHubProviderConfiguration, scopes string) (OAuthProvider, error) {
 	if err := ext.ValidateOAuth(); err != nil {
 		return nil, err
 	}
@@ -46,11 +68,19 @@ func NewGithubProvider(ext conf.OAuthProviderConfiguration, scopes string) (OAut
 	if !strings.HasSuffix(apiHost, defaultGitHubAPIBase) {
 		apiHost += "/api/v3"
 	}
+	if ext.ApiURL != "" {
+		apiHost = chooseHost(ext.ApiURL, defaultGitHubAPIBase)
+	}
 
 	oauthScopes := []string{
 		"user:email",
 	}
 
+	if len(ext.AllowedOrganizations) > 0 || len(ext.AllowedTeams) > 0 {
+		// private memberships are only listed with read:org
+		oauthScopes = append(oauthScopes, "read:org")
+	}
+
 	if scopes != "" {
 		oauthScopes = append(oauthScopes, strings.Split(scopes, ",")...)
 	}
@@ -67,6 +97,7 @@ func NewGithubProvider(ext conf.OAuthProviderConfiguration, scopes string) (OAut
 			Scopes:      oauthScopes,
 		},
 		APIHost: apiHost,
+		ext:     ext,
 	}, nil
 }
 
@@ -106,5 +137,66 @@ func (g githubProvider) GetUserData(ctx context.Context, tok *oauth2.Token) (*Us
 		}
 	}
 
+	if err := g.checkMembership(ctx, tok, u.UserName); err != nil {
+		return nil, err
+	}
+
 	return data, nil
 }
+
+// checkMembership returns an error if organizations or teams are allowed and
+// the user is an active member of none of them.
+func (g githubProvider) checkMembership(ctx context.Context, tok *oauth2.Token, login string) error {
+	if len(g.ext.AllowedOrganizations) == 0 && len(g.ext.AllowedTeams) == 0 {
+		return nil
+	}
+
+	if len(g.ext.AllowedOrganizations) > 0 {
+		for page := 1; ; page++ {
+			var memberships []githubOrgMembership
+			if err := makeRequest(ctx, tok, g.Config, fmt.Sprintf("%s/user/memberships/orgs?state=active&per_page=%d&page=%d", g.APIHost, githubPageSize, page), &memberships); err != nil {
+				return err
+			}
+
+			for _, m := range memberships {
+				if m.State == "active" && containsFold(g.ext.AllowedOrganizations, m.Organization.Login) {
+					return nil
+				}
+			}
+
+			if len(memberships) < githubPageSize {
+				break
+			}
+		}
+	}
+
+	if len(g.ext.AllowedTeams) > 0 {
+		for page := 1; ; page++ {
+			var teams []githubTeam
+			if err := makeRequest(ctx, tok, g.Config, fmt.Sprintf("%s/user/teams?per_page=%d&page=%d", g.APIHost, githubPageSize, page), &teams); err != nil {
+				return err
+			}
+
+			for _, t := range teams {
+				if containsFold(g.ext.AllowedTeams, t.Organization.Login+"/"+t.Slug) {
+					return nil
+				}
+			}
+
+			if len(teams) < githubPageSize {
+				break
+			}
+		}
+	}
+
+	return fmt.Errorf("github: user %q is not a member of an allowed organization or team: %w", login, ErrAccessDenied)
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
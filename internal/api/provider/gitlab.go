@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -16,6 +17,8 @@ const defaultGitLabAuthBase = "gitlab.com"
 type gitlabProvider struct {
 	*oauth2.Config
 	Host string
+
+	ext conf.GitLabProviderConfiguration
 }
 
 type gitlabUser struct {
@@ -31,8 +34,15 @@ type gitlabUserEmail struct {
 	Email string `json:"email"`
 }
 
-// NewGitlabProvider creates a Gitlab account provider.
-func NewGitlabProvider(ext conf.OAuthProviderConfiguration, scopes string) (OAuthProvider, error) {
+// gitlabUserInfo is the response of the OpenID Connect userinfo endpoint,
+// the only endpoint listing the user's groups with the openid scope alone.
+type gitlabUserInfo struct {
+	Groups []string `json:"groups"`
+}
+
+// NewGitlabProvider creates a Gitlab account provider. Set the URL to a
+// self-managed instance to use it instead of gitlab.com.
+func NewGitlabProvider(ext conf.GitLabProviderConfiguration, scopes string) (OAuthProvider, error) {
 	if err := ext.ValidateOAuth(); err != nil {
 		return nil, err
 	}
@@ -41,6 +51,10 @@ func NewGitlabProvider(ext conf.OAuthProviderConfiguration, scopes string) (OAut
 		"read_user",
 	}
 
+	if len(ext.AllowedGroups) > 0 || ext.SyncGroups {
+		oauthScopes = append(oauthScopes, "openid")
+	}
+
 	if scopes != "" {
 		oauthScopes = append(oauthScopes, strings.Split(scopes, ",")...)
 	}
@@ -58,6 +72,7 @@ func NewGitlabProvider(ext conf.OAuthProviderConfiguration, scopes string) (OAut
 			Scopes:      oauthScopes,
 		},
 		Host: host,
+		ext:  ext,
 	}, nil
 }
 
@@ -103,5 +118,45 @@ func (g gitlabProvider) GetUserData(ctx context.Context, tok *oauth2.Token) (*Us
 		ProviderId: strconv.Itoa(u.ID),
 	}
 
+	if len(g.ext.AllowedGroups) > 0 || g.ext.SyncGroups {
+		var info gitlabUserInfo
+		if err := makeRequest(ctx, tok, g.Config, g.Host+"/oauth/userinfo", &info); err != nil {
+			return nil, err
+		}
+
+		if !gitlabMemberOfAny(info.Groups, g.ext.AllowedGroups) {
+			return nil, fmt.Errorf("gitlab: user %d is not a member of an allowed group: %w", u.ID, ErrAccessDenied)
+		}
+
+		if g.ext.SyncGroups {
+			groups := info.Groups
+			if groups == nil {
+				groups = []string{}
+			}
+			data.AppMetadata = map[string]interface{}{
+				"gitlab": map[string]interface{}{
+					"groups": groups,
+				},
+			}
+		}
+	}
+
 	return data, nil
 }
+
+// gitlabMemberOfAny reports whether any of the groups is allowed. Group paths
+// are matched case-insensitively, and no allowed groups means any user is
+// allowed.
+func gitlabMemberOfAny(groups []string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	for _, group := range groups {
+		if containsFold(allowed, group) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"fmt"
 	"strings"
 
 	"github.com/coreos/go-oidc/v3/oidc"
@@ -28,6 +29,60 @@ func (u googleUser) IsEmailVerified() bool {
 
 const IssuerGoogle = "https://accounts.google.com"
 
+// issuerGoogleNoScheme is the issuer of some Google ID tokens, such as the
+// credentials of Google Identity Services, which go-oidc accepts in place of
+// IssuerGoogle.
+const issuerGoogleNoScheme = "accounts.google.com"
+
+// IsGoogleIssuer reports whether the issuer is one of the issuers of Google
+// ID tokens.
+func IsGoogleIssuer(issuer string) bool {
+	return issuer == IssuerGoogle || issuer == issuerGoogleNoScheme
+}
+
+// googleSelectBy are the values of select_by in the credential responses of
+// Google Identity Services, which tell how the user selected the credential
+// with One Tap, FedCM or the Sign in with Google button. See:
+// https://developers.google.com/identity/gsi/web/reference/js-reference#select_by
+var googleSelectBy = map[string]bool{
+	"auto":                    true,
+	"user":                    true,
+	"fedcm":                   true,
+	"fedcm_auto":              true,
+	"user_1tap":               true,
+	"user_2tap":               true,
+	"itp":                     true,
+	"itp_confirm":             true,
+	"itp_add_session":         true,
+	"itp_confirm_add_session": true,
+	"btn":                     true,
+	"btn_confirm":             true,
+	"btn_add_session":         true,
+	"btn_confirm_add_session": true,
+}
+
+// CheckGoogleSelectBy returns an error if the select_by value of a Google
+// Identity Services credential response is unknown, or not in the configured
+// list of allowed values. Credentials without one are only accepted when no
+// list is configured.
+func CheckGoogleSelectBy(ext conf.GoogleProviderConfiguration, selectBy string) error {
+	if selectBy != "" && !googleSelectBy[selectBy] {
+		return fmt.Errorf("google: unknown select_by %q", selectBy)
+	}
+
+	if len(ext.AllowedSelectBy) == 0 {
+		return nil
+	}
+
+	for _, allowed := range ext.AllowedSelectBy {
+		if selectBy != "" && allowed == selectBy {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("google: select_by %q is not allowed", selectBy)
+}
+
 var internalIssuerGoogle = IssuerGoogle
 
 type googleProvider struct {
@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+)
+
+func TestIsGoogleIssuer(t *testing.T) {
+	require.True(t, IsGoogleIssuer("https://accounts.google.com"))
+	require.True(t, IsGoogleIssuer("accounts.google.com"))
+	require.False(t, IsGoogleIssuer("https://accounts.google.com.example.com"))
+	require.False(t, IsGoogleIssuer(""))
+}
+
+func TestCheckGoogleSelectBy(t *testing.T) {
+	cases := []struct {
+		desc     string
+		allowed  []string
+		selectBy string
+		ok       bool
+	}{
+		{
+			desc:     "not a credential response",
+			selectBy: "",
+			ok:       true,
+		},
+		{
+			desc:     "one tap",
+			selectBy: "user_1tap",
+			ok:       true,
+		},
+		{
+			desc:     "unknown",
+			selectBy: "magic",
+			ok:       false,
+		},
+		{
+			desc:     "allowed",
+			allowed:  []string{"fedcm", "user_1tap"},
+			selectBy: "fedcm",
+			ok:       true,
+		},
+		{
+			desc:     "not allowed",
+			allowed:  []string{"fedcm", "user_1tap"},
+			selectBy: "auto",
+			ok:       false,
+		},
+		{
+			desc:     "missing when restricted",
+			allowed:  []string{"fedcm", "user_1tap"},
+			selectBy: "",
+			ok:       false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			err := CheckGoogleSelectBy(conf.GoogleProviderConfiguration{
+				AllowedSelectBy: c.allowed,
+			}, c.selectBy)
+			if c.ok {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package provider
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/gssapi"
+	"github.com/jcmturner/gokrb5/v8/service"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+	"github.com/supabase/auth/internal/conf"
+)
+
+// Kerberos (SPNEGO)
+
+// ErrKerberosNoMechanism is returned when the client offers no Kerberos
+// mechanism, such as NTLM when the machine is not joined to the domain.
+var ErrKerberosNoMechanism = errors.New("kerberos: client does not support kerberos")
+
+// ErrKerberosRealmNotAllowed is returned when the client principal is of a
+// realm other than the allowed realms, like a realm trusted by them.
+var ErrKerberosRealmNotAllowed = errors.New("kerberos: realm of the client is not allowed")
+
+// VerifySPNEGO verifies the token of a "Negotiate" Authorization header and
+// returns the credentials of the client principal.
+func VerifySPNEGO(ext conf.KerberosConfiguration, negotiate string) (*credentials.Credentials, error) {
+	b, err := base64.StdEncoding.DecodeString(negotiate)
+	if err != nil {
+		return nil, errors.New("kerberos: negotiate token not in standard Base64 format")
+	}
+
+	var token spnego.SPNEGOToken
+	if err := token.Unmarshal(b); err != nil {
+		return nil, fmt.Errorf("kerberos: invalid negotiate token: %w", err)
+	}
+
+	if !token.Init {
+		return nil, errors.New("kerberos: negotiate token is not an initial token")
+	}
+
+	supported := false
+	for _, oid := range token.NegTokenInit.MechTypes {
+		if oid.Equal(gssapi.OIDKRB5.OID()) || oid.Equal(gssapi.OIDMSLegacyKRB5.OID()) {
+			supported = true
+			break
+		}
+	}
+	if !supported || len(token.NegTokenInit.MechTokenBytes) == 0 {
+		return nil, ErrKerberosNoMechanism
+	}
+
+	var mt spnego.KRB5Token
+	if err := mt.Unmarshal(token.NegTokenInit.MechTokenBytes); err != nil {
+		return nil, fmt.Errorf("kerberos: invalid mechanism token: %w", err)
+	}
+
+	if !mt.IsAPReq() {
+		return nil, errors.New("kerberos: mechanism token is not an AP-REQ")
+	}
+
+	options := []func(*service.Settings){
+		service.MaxClockSkew(ext.MaxClockSkew),
+		service.DecodePAC(false),
+	}
+	if ext.ServicePrincipal != "" {
+		options = append(options, service.KeytabPrincipal(ext.ServicePrincipal))
+	}
+
+	ok, creds, err := service.VerifyAPREQ(&mt.APReq, service.NewSettings(ext.KeytabData, options...))
+	if err != nil {
+		return nil, fmt.Errorf("kerberos: unable to verify ticket: %w", err)
+	}
+	if !ok {
+		return nil, errors.New("kerberos: ticket is not valid")
+	}
+
+	if !ext.AllowsRealm(creds.Domain()) {
+		return nil, ErrKerberosRealmNotAllowed
+	}
+
+	return creds, nil
+}
+
+// KerberosUserData returns the identity data of the client principal. Users
+// are keyed by their principal name, username@REALM.
+func KerberosUserData(ext conf.KerberosConfiguration, creds *credentials.Credentials) *UserProvidedData {
+	principal := creds.UserName() + "@" + creds.Domain()
+
+	name := creds.DisplayName()
+	if name == "" {
+		name = creds.UserName()
+	}
+
+	data := &UserProvidedData{}
+	if ext.EmailDomain != "" && ext.AllowsRealm(creds.Domain()) {
+		email := strings.ToLower(creds.UserName()) + "@" + ext.EmailDomain
+		data.Emails = []Email{{
+			Email: email,
+			// the allowed realms are the source of truth for their users
+			Verified: true,
+			Primary:  true,
+		}}
+	}
+
+	data.Metadata = &Claims{
+		Issuer:            "krb5:" + creds.Domain(),
+		Subject:           principal,
+		Name:              name,
+		PreferredUsername: creds.UserName(),
+		CustomClaims: map[string]interface{}{
+			"principal": principal,
+			"realm":     creds.Domain(),
+		},
+
+		// To be deprecated
+		FullName:    name,
+		ProviderId:  principal,
+		UserNameKey: creds.UserName(),
+	}
+
+	if len(data.Emails) > 0 {
+		data.Metadata.Email = data.Emails[0].Email
+		data.Metadata.EmailVerified = true
+	}
+
+	return data
+}
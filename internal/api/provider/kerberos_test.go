@@ -0,0 +1,43 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+)
+
+func TestKerberosUserData(t *testing.T) {
+	creds := credentials.New("Jane", "EXAMPLE.COM")
+	creds.SetDisplayName("Jane Doe")
+
+	data := KerberosUserData(conf.KerberosConfiguration{}, creds)
+	require.Empty(t, data.Emails)
+	require.Equal(t, "Jane@EXAMPLE.COM", data.Metadata.Subject)
+	require.Equal(t, "Jane Doe", data.Metadata.Name)
+	require.Equal(t, "Jane", data.Metadata.PreferredUsername)
+	require.Equal(t, "EXAMPLE.COM", data.Metadata.CustomClaims["realm"])
+
+	data = KerberosUserData(conf.KerberosConfiguration{EmailDomain: "example.com", Realms: []string{"example.com"}}, creds)
+	require.Len(t, data.Emails, 1)
+	require.Equal(t, "jane@example.com", data.Emails[0].Email)
+	require.True(t, data.Emails[0].Verified)
+	require.Equal(t, "jane@example.com", data.Metadata.Email)
+
+	// the users of other realms don't get the addresses of the email domain
+	data = KerberosUserData(conf.KerberosConfiguration{EmailDomain: "example.com", Realms: []string{"CORP.EXAMPLE.COM"}}, creds)
+	require.Empty(t, data.Emails)
+	require.Empty(t, data.Metadata.Email)
+}
+
+func TestVerifySPNEGOInvalidToken(t *testing.T) {
+	ext := conf.KerberosConfiguration{Enabled: true}
+
+	_, err := VerifySPNEGO(ext, "not base64!")
+	require.Error(t, err)
+
+	// an NTLM negotiate message, as sent by machines outside the domain
+	_, err = VerifySPNEGO(ext, "TlRMTVNTUAABAAAAB4IIogAAAAAAAAAAAAAAAAAAAAAKAGFKAAAADw==")
+	require.Error(t, err)
+}
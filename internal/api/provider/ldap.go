@@ -0,0 +1,198 @@
+package provider
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/supabase/auth/internal/conf"
+)
+
+// LDAP / Active Directory
+
+var (
+	// ErrLDAPUserNotFound is returned when no user matches the username.
+	ErrLDAPUserNotFound = errors.New("ldap: user not found")
+
+	// ErrLDAPInvalidCredentials is returned when the password of the user
+	// is wrong.
+	ErrLDAPInvalidCredentials = errors.New("ldap: invalid credentials")
+)
+
+// LDAPUser is a user entry found in the directory.
+type LDAPUser struct {
+	DN     string
+	Email  string
+	Name   string
+	Groups []string
+}
+
+// LDAPAuthenticator verifies the passwords of users against a directory.
+type LDAPAuthenticator interface {
+	Authenticate(ctx context.Context, username, password string) (*LDAPUser, error)
+}
+
+type ldapAuthenticator struct {
+	ext conf.LDAPProviderConfiguration
+}
+
+// NewLDAPAuthenticator creates an authenticator for the configured
+// directory. The configuration must have been validated.
+func NewLDAPAuthenticator(ext conf.LDAPProviderConfiguration) LDAPAuthenticator {
+	return &ldapAuthenticator{ext: ext}
+}
+
+func (l *ldapAuthenticator) dial() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(l.ext.URL, ldap.DialWithDialer(&net.Dialer{Timeout: defaultTimeout}))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(defaultTimeout)
+
+	if l.ext.StartTLS {
+		u, err := url.Parse(l.ext.URL)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		if err := conn.StartTLS(&tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// Authenticate finds the user matching the username and binds as it with
+// the password.
+func (l *ldapAuthenticator) Authenticate(ctx context.Context, username, password string) (*LDAPUser, error) {
+	// an empty password would be an unauthenticated bind, which most
+	// directories accept for any DN
+	if password == "" {
+		return nil, ErrLDAPInvalidCredentials
+	}
+
+	conn, err := l.dial()
+	if err != nil {
+		return nil, fmt.Errorf("ldap: unable to connect: %w", err)
+	}
+	defer conn.Close()
+
+	if l.ext.BindDN != "" {
+		if err := conn.Bind(l.ext.BindDN, l.ext.BindPassword); err != nil {
+			return nil, fmt.Errorf("ldap: unable to bind as service account: %w", err)
+		}
+	}
+
+	filter := strings.ReplaceAll(l.ext.UserFilter, "{username}", ldap.EscapeFilter(username))
+	res, err := conn.Search(ldap.NewSearchRequest(
+		l.ext.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		2, // only one entry is expected, two detect ambiguous filters
+		int(defaultTimeout.Seconds()),
+		false,
+		filter,
+		[]string{l.ext.EmailAttribute, l.ext.NameAttribute, l.ext.GroupAttribute},
+		nil,
+	))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("ldap: unable to search for user: %w", err)
+	}
+
+	switch {
+	case res == nil || len(res.Entries) == 0:
+		return nil, ErrLDAPUserNotFound
+	case len(res.Entries) > 1:
+		return nil, errors.New("ldap: user filter matches more than one user")
+	}
+
+	entry := res.Entries[0]
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrLDAPInvalidCredentials
+		}
+		return nil, fmt.Errorf("ldap: unable to bind as user: %w", err)
+	}
+
+	return &LDAPUser{
+		DN:     entry.DN,
+		Email:  entry.GetAttributeValue(l.ext.EmailAttribute),
+		Name:   entry.GetAttributeValue(l.ext.NameAttribute),
+		Groups: entry.GetAttributeValues(l.ext.GroupAttribute),
+	}, nil
+}
+
+// UserData returns the identity data of the directory user. The groups of
+// the user are synced into app_metadata under the "ldap" key.
+func (u *LDAPUser) UserData(ext conf.LDAPProviderConfiguration) *UserProvidedData {
+	data := &UserProvidedData{}
+	if u.Email != "" {
+		data.Emails = []Email{{
+			Email: u.Email,
+			// the directory is the source of truth for its users
+			Verified: true,
+			Primary:  true,
+		}}
+	}
+
+	data.Metadata = &Claims{
+		Issuer:  ext.URL,
+		Subject: u.DN,
+		Name:    u.Name,
+		Email:   u.Email,
+
+		// To be deprecated
+		FullName:   u.Name,
+		ProviderId: u.DN,
+	}
+
+	groups := u.Groups
+	if groups == nil {
+		groups = []string{}
+	}
+
+	data.AppMetadata = map[string]interface{}{
+		"ldap": map[string]interface{}{
+			"groups": groups,
+		},
+	}
+
+	return data
+}
+
+// LDAPRole returns the role mapped to the first group of the user matching
+// the configured group roles, or an empty string when none matches.
+func LDAPRole(ext conf.LDAPProviderConfiguration, groups []string) string {
+	names := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		names[strings.ToLower(ldapGroupName(group))] = true
+	}
+
+	for _, pair := range ext.GroupRoles {
+		group, role, _ := strings.Cut(pair, "=")
+		if names[strings.ToLower(group)] {
+			return role
+		}
+	}
+
+	return ""
+}
+
+// ldapGroupName returns the common name of the group, which is the value
+// of the first RDN of its DN.
+func ldapGroupName(group string) string {
+	dn, err := ldap.ParseDN(group)
+	if err != nil || len(dn.RDNs) == 0 || len(dn.RDNs[0].Attributes) == 0 {
+		return group
+	}
+
+	return dn.RDNs[0].Attributes[0].Value
+}
@@ -0,0 +1,97 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+)
+
+func TestLDAPRole(t *testing.T) {
+	ext := conf.LDAPProviderConfiguration{
+		GroupRoles: []string{"Admins=admin", "support=support_agent"},
+	}
+
+	cases := []struct {
+		desc   string
+		groups []string
+		role   string
+	}{
+		{
+			desc:   "no groups",
+			groups: nil,
+			role:   "",
+		},
+		{
+			desc:   "unmapped group",
+			groups: []string{"cn=engineering,ou=groups,dc=example,dc=com"},
+			role:   "",
+		},
+		{
+			desc:   "group DN",
+			groups: []string{"cn=Support,ou=groups,dc=example,dc=com"},
+			role:   "support_agent",
+		},
+		{
+			desc:   "plain group name",
+			groups: []string{"admins"},
+			role:   "admin",
+		},
+		{
+			desc: "first configured mapping wins",
+			groups: []string{
+				"cn=support,ou=groups,dc=example,dc=com",
+				"cn=admins,ou=groups,dc=example,dc=com",
+			},
+			role: "admin",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			require.Equal(t, c.role, LDAPRole(ext, c.groups))
+		})
+	}
+}
+
+func TestLDAPUserData(t *testing.T) {
+	ext := conf.LDAPProviderConfiguration{
+		URL: "ldap://ldap.example.com",
+	}
+
+	u := &LDAPUser{
+		DN:     "uid=jane,ou=people,dc=example,dc=com",
+		Email:  "jane@example.com",
+		Name:   "Jane Doe",
+		Groups: []string{"cn=admins,ou=groups,dc=example,dc=com"},
+	}
+
+	data := u.UserData(ext)
+	require.Len(t, data.Emails, 1)
+	require.Equal(t, "jane@example.com", data.Emails[0].Email)
+	require.True(t, data.Emails[0].Verified)
+	require.Equal(t, u.DN, data.Metadata.Subject)
+	require.Equal(t, "Jane Doe", data.Metadata.Name)
+	require.Equal(t, ext.URL, data.Metadata.Issuer)
+	require.Equal(t, map[string]interface{}{
+		"ldap": map[string]interface{}{
+			"groups": u.Groups,
+		},
+	}, data.AppMetadata)
+
+	data = (&LDAPUser{DN: "uid=john,ou=people,dc=example,dc=com"}).UserData(ext)
+	require.Empty(t, data.Emails)
+	require.Equal(t, []string{}, data.AppMetadata["ldap"].(map[string]interface{})["groups"])
+}
+
+func TestLDAPAuthenticateRejectsEmptyPassword(t *testing.T) {
+	a := NewLDAPAuthenticator(conf.LDAPProviderConfiguration{
+		Enabled: true,
+		URL:     "ldap://127.0.0.1:1",
+		BaseDN:  "dc=example,dc=com",
+	})
+
+	_, err := a.Authenticate(context.Background(), "jane@example.com", "")
+	require.ErrorIs(t, err, ErrLDAPInvalidCredentials)
+}
@@ -0,0 +1,182 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/supabase/auth/internal/conf"
+	"golang.org/x/oauth2"
+)
+
+// LINE Login v2.1
+
+const (
+	defaultLineAuthBase = "access.line.me"
+	defaultLineAPIBase  = "api.line.me"
+	IssuerLine          = "https://access.line.me"
+)
+
+type lineProvider struct {
+	*oauth2.Config
+	APIPath string
+}
+
+// lineProfile is the response of the profile endpoint, which is used when
+// the openid scope wasn't granted and there is no ID token.
+type lineProfile struct {
+	UserID      string `json:"userId"`
+	DisplayName string `json:"displayName"`
+	PictureURL  string `json:"pictureUrl"`
+}
+
+type LineIDTokenClaims struct {
+	jwt.RegisteredClaims
+
+	Name    string   `json:"name"`
+	Picture string   `json:"picture"`
+	Email   string   `json:"email"`
+	AMR     []string `json:"amr"`
+}
+
+// NewLineProvider creates a LINE Login account provider. The client ID and
+// secret are the channel ID and the channel secret of the LINE Login
+// channel.
+func NewLineProvider(ext conf.OAuthProviderConfiguration, scopes string) (OAuthProvider, error) {
+	if err := ext.ValidateOAuth(); err != nil {
+		return nil, err
+	}
+
+	authHost := chooseHost(ext.URL, defaultLineAuthBase)
+	apiHost := chooseHost(ext.ApiURL, defaultLineAPIBase)
+
+	oauthScopes := []string{
+		"openid",
+		"profile",
+		"email",
+	}
+
+	if scopes != "" {
+		oauthScopes = append(oauthScopes, strings.Split(scopes, ",")...)
+	}
+
+	return &lineProvider{
+		Config: &oauth2.Config{
+			ClientID:     ext.ClientID[0],
+			ClientSecret: ext.Secret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:   authHost + "/oauth2/v2.1/authorize",
+				TokenURL:  apiHost + "/oauth2/v2.1/token",
+				AuthStyle: oauth2.AuthStyleInParams,
+			},
+			RedirectURL: ext.RedirectURI,
+			Scopes:      oauthScopes,
+		},
+		APIPath: apiHost,
+	}, nil
+}
+
+func (p lineProvider) GetOAuthToken(code string) (*oauth2.Token, error) {
+	return p.Exchange(context.Background(), code)
+}
+
+func (p lineProvider) GetUserData(ctx context.Context, tok *oauth2.Token) (*UserProvidedData, error) {
+	if idToken, ok := tok.Extra("id_token").(string); ok && idToken != "" {
+		claims, err := p.verifyIDToken(idToken)
+		if err != nil {
+			return nil, err
+		}
+		return lineUserData(claims), nil
+	}
+
+	var u lineProfile
+	if err := makeRequest(ctx, tok, p.Config, p.APIPath+"/v2/profile", &u); err != nil {
+		return nil, err
+	}
+
+	return lineUserData(&LineIDTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:  IssuerLine,
+			Subject: u.UserID,
+		},
+		Name:    u.DisplayName,
+		Picture: u.PictureURL,
+	}), nil
+}
+
+// verifyIDToken verifies the ID token returned with the access token. Unlike
+// the ID tokens the LINE SDK gets for native apps, which are signed with the
+// keys published in LINE's discovery document, these are signed with HS256
+// and the channel secret, so they can't be verified with go-oidc.
+func (p lineProvider) verifyIDToken(idToken string) (*LineIDTokenClaims, error) {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}),
+		jwt.WithIssuer(IssuerLine),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+	}
+	if OverrideClock != nil {
+		options = append(options, jwt.WithTimeFunc(OverrideClock))
+	}
+
+	var claims LineIDTokenClaims
+	if _, err := jwt.ParseWithClaims(idToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(p.ClientSecret), nil
+	}, options...); err != nil {
+		return nil, err
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("line: ID token has no subject")
+	}
+
+	return &claims, nil
+}
+
+func parseLineIDToken(token *oidc.IDToken) (*oidc.IDToken, *UserProvidedData, error) {
+	var claims LineIDTokenClaims
+	if err := token.Claims(&claims); err != nil {
+		return nil, nil, err
+	}
+
+	claims.Issuer = token.Issuer
+	claims.Subject = token.Subject
+
+	return token, lineUserData(&claims), nil
+}
+
+func lineUserData(claims *LineIDTokenClaims) *UserProvidedData {
+	data := &UserProvidedData{}
+
+	// LINE only returns the email address when the user agreed to share
+	// it, and users register it by verifying it.
+	if claims.Email != "" {
+		data.Emails = append(data.Emails, Email{
+			Email:    claims.Email,
+			Verified: true,
+			Primary:  true,
+		})
+	}
+
+	data.Metadata = &Claims{
+		Issuer:  claims.Issuer,
+		Subject: claims.Subject,
+		Name:    claims.Name,
+		Picture: claims.Picture,
+
+		// To be deprecated
+		AvatarURL:  claims.Picture,
+		FullName:   claims.Name,
+		ProviderId: claims.Subject,
+	}
+
+	if len(claims.AMR) > 0 {
+		data.Metadata.CustomClaims = map[string]interface{}{
+			"amr": claims.AMR,
+		}
+	}
+
+	return data
+}
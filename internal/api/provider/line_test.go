@@ -0,0 +1,90 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+	"golang.org/x/oauth2"
+)
+
+func signLineIDToken(t *testing.T, secret string, claims jwt.MapClaims) string {
+	idToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	require.NoError(t, err)
+	return idToken
+}
+
+func TestLineIDToken(t *testing.T) {
+	p, err := NewLineProvider(conf.OAuthProviderConfiguration{
+		Enabled:     true,
+		ClientID:    []string{"1234567890"},
+		Secret:      "channelsecret",
+		RedirectURI: "https://example.com/callback",
+	}, "")
+	require.NoError(t, err)
+
+	claims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":     IssuerLine,
+			"sub":     "U1234567890abcdef1234567890abcdef",
+			"aud":     "1234567890",
+			"exp":     time.Now().Add(time.Hour).Unix(),
+			"iat":     time.Now().Unix(),
+			"amr":     []string{"linesso"},
+			"name":    "LINE Test",
+			"picture": "https://profile.line-scdn.net/abcdefghijklmn",
+			"email":   "line@example.com",
+		}
+	}
+
+	idToken := signLineIDToken(t, "channelsecret", claims())
+	data, err := p.GetUserData(context.Background(), (&oauth2.Token{AccessToken: "line_token"}).WithExtra(map[string]interface{}{
+		"id_token": idToken,
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "U1234567890abcdef1234567890abcdef", data.Metadata.Subject)
+	require.Equal(t, "LINE Test", data.Metadata.Name)
+	require.Equal(t, []Email{{Email: "line@example.com", Verified: true, Primary: true}}, data.Emails)
+
+	cases := []struct {
+		desc   string
+		secret string
+		modify func(jwt.MapClaims)
+	}{
+		{
+			desc:   "signed with another secret",
+			secret: "othersecret",
+			modify: func(jwt.MapClaims) {},
+		},
+		{
+			desc:   "issued for another channel",
+			secret: "channelsecret",
+			modify: func(c jwt.MapClaims) { c["aud"] = "0987654321" },
+		},
+		{
+			desc:   "issued by another issuer",
+			secret: "channelsecret",
+			modify: func(c jwt.MapClaims) { c["iss"] = "https://example.com" },
+		},
+		{
+			desc:   "expired",
+			secret: "channelsecret",
+			modify: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() },
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			claims := claims()
+			c.modify(claims)
+
+			_, err := p.GetUserData(context.Background(), (&oauth2.Token{AccessToken: "line_token"}).WithExtra(map[string]interface{}{
+				"id_token": signLineIDToken(t, c.secret, claims),
+			}))
+			require.Error(t, err)
+		})
+	}
+}
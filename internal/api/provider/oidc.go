@@ -53,7 +53,7 @@ func ParseIDToken(ctx context.Context, provider *oidc.Provider, config *oidc.Con
 	var data *UserProvidedData
 
 	switch token.Issuer {
-	case IssuerGoogle:
+	case IssuerGoogle, issuerGoogleNoScheme:
 		token, data, err = parseGoogleIDToken(token)
 	case IssuerApple:
 		token, data, err = parseAppleIDToken(token)
@@ -61,6 +61,8 @@ func ParseIDToken(ctx context.Context, provider *oidc.Provider, config *oidc.Con
 		token, data, err = parseLinkedinIDToken(token)
 	case IssuerKakao:
 		token, data, err = parseKakaoIDToken(token)
+	case IssuerLine:
+		token, data, err = parseLineIDToken(token)
 	case IssuerVercelMarketplace:
 		token, data, err = parseVercelMarketplaceIDToken(token)
 	default:
@@ -0,0 +1,172 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/supabase/auth/internal/conf"
+	"golang.org/x/oauth2"
+)
+
+// Okta
+
+type oktaProvider struct {
+	*oauth2.Config
+
+	// Issuer is the URL of the authorization server, either the Okta org
+	// (https://example.okta.com) or a custom authorization server
+	// (https://example.okta.com/oauth2/default).
+	Issuer string
+
+	// APIPath is the base path of the OIDC endpoints of the authorization
+	// server.
+	APIPath string
+
+	ext conf.OktaProviderConfiguration
+}
+
+type oktaUser struct {
+	Sub               string `json:"sub"`
+	Name              string `json:"name"`
+	GivenName         string `json:"given_name"`
+	FamilyName        string `json:"family_name"`
+	PreferredUsername string `json:"preferred_username"`
+	Locale            string `json:"locale"`
+	ZoneInfo          string `json:"zoneinfo"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+
+	// Groups is usually an array of group names, but may be a single
+	// string when the claim is defined by an expression on a custom
+	// authorization server.
+	Groups interface{} `json:"groups"`
+}
+
+// isOktaCustomAuthorizationServer reports whether the URL points to a custom
+// authorization server, whose endpoints live under /oauth2/{id}/v1 instead
+// of the org authorization server's /oauth2/v1.
+func isOktaCustomAuthorizationServer(issuer string) bool {
+	return strings.Contains(issuer, "/oauth2/")
+}
+
+// NewOktaProvider creates an Okta account provider.
+func NewOktaProvider(ext conf.OktaProviderConfiguration, scopes string) (OAuthProvider, error) {
+	if err := ext.ValidateOAuth(); err != nil {
+		return nil, err
+	}
+
+	if ext.URL == "" {
+		return nil, errors.New("unable to find URL for the Okta provider")
+	}
+
+	oauthScopes := []string{
+		"openid",
+		"profile",
+		"email",
+	}
+
+	if ext.SyncGroups {
+		// the org authorization server only returns the groups claim
+		// when the groups scope is requested
+		oauthScopes = append(oauthScopes, "groups")
+	}
+
+	if scopes != "" {
+		oauthScopes = append(oauthScopes, strings.Split(scopes, ",")...)
+	}
+
+	issuer := chooseHost(ext.URL, "")
+
+	apiPath := issuer + "/oauth2/v1"
+	if isOktaCustomAuthorizationServer(issuer) {
+		apiPath = issuer + "/v1"
+	}
+
+	return &oktaProvider{
+		Config: &oauth2.Config{
+			ClientID:     ext.ClientID[0],
+			ClientSecret: ext.Secret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  apiPath + "/authorize",
+				TokenURL: apiPath + "/token",
+			},
+			RedirectURL: ext.RedirectURI,
+			Scopes:      oauthScopes,
+		},
+		Issuer:  issuer,
+		APIPath: apiPath,
+		ext:     ext,
+	}, nil
+}
+
+func (g oktaProvider) GetOAuthToken(code string) (*oauth2.Token, error) {
+	return g.Exchange(context.Background(), code)
+}
+
+func (g oktaProvider) GetUserData(ctx context.Context, tok *oauth2.Token) (*UserProvidedData, error) {
+	// The user info endpoint is used instead of the ID token so that the
+	// profile attributes are always fresh, as the ID token may only
+	// contain a subset of them depending on the authorization server's
+	// claim configuration.
+	var u oktaUser
+	if err := makeRequest(ctx, tok, g.Config, g.APIPath+"/userinfo", &u); err != nil {
+		return nil, err
+	}
+
+	data := &UserProvidedData{}
+	if u.Email != "" {
+		data.Emails = []Email{{
+			Email:    u.Email,
+			Verified: u.EmailVerified,
+			Primary:  true,
+		}}
+	}
+
+	data.Metadata = &Claims{
+		Issuer:            g.Issuer,
+		Subject:           u.Sub,
+		Name:              u.Name,
+		GivenName:         u.GivenName,
+		FamilyName:        u.FamilyName,
+		PreferredUsername: u.PreferredUsername,
+		Locale:            u.Locale,
+		ZoneInfo:          u.ZoneInfo,
+		Email:             u.Email,
+		EmailVerified:     u.EmailVerified,
+
+		// To be deprecated
+		FullName:   u.Name,
+		ProviderId: u.Sub,
+	}
+
+	if g.ext.SyncGroups {
+		data.AppMetadata = map[string]interface{}{
+			"okta": map[string]interface{}{
+				"groups": oktaGroups(u.Groups),
+			},
+		}
+	}
+
+	return data, nil
+}
+
+func oktaGroups(claim interface{}) []string {
+	groups := []string{}
+
+	switch v := claim.(type) {
+	case string:
+		if v != "" {
+			groups = append(groups, v)
+		}
+
+	case []interface{}:
+		for _, group := range v {
+			if s, ok := group.(string); ok && s != "" {
+				groups = append(groups, s)
+			}
+		}
+	}
+
+	return groups
+}
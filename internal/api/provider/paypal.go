@@ -0,0 +1,116 @@
+package provider
+
+import (
+	"context"
+	"strings"
+
+	"github.com/supabase/auth/internal/conf"
+	"golang.org/x/oauth2"
+)
+
+// Log in with PayPal
+
+const (
+	defaultPayPalAuthBase = "www.paypal.com"
+	defaultPayPalAPIBase  = "api-m.paypal.com"
+
+	// payPalAttributesScope grants access to the payer_id and
+	// verified_account attributes.
+	payPalAttributesScope = "https://uri.paypal.com/services/paypalattributes"
+)
+
+type paypalProvider struct {
+	*oauth2.Config
+	APIPath string
+}
+
+// paypalUser is the response of the user info endpoint with the paypalv1.1
+// schema. See:
+// https://developer.paypal.com/docs/api/identity/v1/#userinfo_get
+type paypalUser struct {
+	UserID          string `json:"user_id"`
+	Name            string `json:"name"`
+	PayerID         string `json:"payer_id"`
+	VerifiedAccount string `json:"verified_account"`
+	Emails          []struct {
+		Value     string `json:"value"`
+		Primary   bool   `json:"primary"`
+		Confirmed bool   `json:"confirmed"`
+	} `json:"emails"`
+}
+
+// NewPayPalProvider creates a Log in with PayPal account provider. The URL
+// and API URL default to the live environment, set them to
+// https://www.sandbox.paypal.com and https://api-m.sandbox.paypal.com to use
+// the sandbox.
+func NewPayPalProvider(ext conf.OAuthProviderConfiguration, scopes string) (OAuthProvider, error) {
+	if err := ext.ValidateOAuth(); err != nil {
+		return nil, err
+	}
+
+	authHost := chooseHost(ext.URL, defaultPayPalAuthBase)
+	apiHost := chooseHost(ext.ApiURL, defaultPayPalAPIBase)
+
+	oauthScopes := []string{
+		"openid",
+		"email",
+		payPalAttributesScope,
+	}
+
+	if scopes != "" {
+		oauthScopes = append(oauthScopes, strings.Split(scopes, ",")...)
+	}
+
+	return &paypalProvider{
+		Config: &oauth2.Config{
+			ClientID:     ext.ClientID[0],
+			ClientSecret: ext.Secret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  authHost + "/connect",
+				TokenURL: apiHost + "/v1/oauth2/token",
+			},
+			RedirectURL: ext.RedirectURI,
+			Scopes:      oauthScopes,
+		},
+		APIPath: apiHost,
+	}, nil
+}
+
+func (g paypalProvider) GetOAuthToken(code string) (*oauth2.Token, error) {
+	return g.Exchange(context.Background(), code)
+}
+
+func (g paypalProvider) GetUserData(ctx context.Context, tok *oauth2.Token) (*UserProvidedData, error) {
+	var u paypalUser
+	if err := makeRequest(ctx, tok, g.Config, g.APIPath+"/v1/identity/oauth2/userinfo?schema=paypalv1.1", &u); err != nil {
+		return nil, err
+	}
+
+	data := &UserProvidedData{}
+	for _, e := range u.Emails {
+		if e.Value != "" {
+			data.Emails = append(data.Emails, Email{
+				Email:    e.Value,
+				Verified: e.Confirmed,
+				Primary:  e.Primary,
+			})
+		}
+	}
+
+	data.Metadata = &Claims{
+		Issuer:  g.APIPath,
+		Subject: u.UserID,
+		Name:    u.Name,
+		CustomClaims: map[string]interface{}{
+			"payer_id": u.PayerID,
+			// PayPal returns the verified account status as a string
+			"verified_account": u.VerifiedAccount == "true",
+		},
+
+		// To be deprecated
+		FullName:   u.Name,
+		ProviderId: u.UserID,
+	}
+
+	return data, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
 	"net/http"
@@ -16,6 +17,10 @@ import (
 
 var defaultTimeout time.Duration = time.Second * 10
 
+// ErrAccessDenied is wrapped by the errors of users the configuration of a
+// provider doesn't allow, like users outside of its allowed tenants.
+var ErrAccessDenied = errors.New("access denied")
+
 func init() {
 	timeoutStr := os.Getenv("GOTRUE_INTERNAL_HTTP_TIMEOUT")
 	if timeoutStr != "" {
@@ -77,6 +82,13 @@ type Email struct {
 type UserProvidedData struct {
 	Emails   []Email
 	Metadata *Claims
+
+	// AppMetadata holds provider sourced authorization data, such as
+	// groups or roles, that is merged into the user's app_metadata.
+	AppMetadata map[string]interface{}
+	// UserMetadata holds fields mapped from the claims that are merged
+	// into the user's user_metadata on top of the claims.
+	UserMetadata map[string]interface{}
 }
 
 // Provider is an interface for interacting with external account providers
@@ -91,6 +103,13 @@ type OAuthProvider interface {
 	GetOAuthToken(string) (*oauth2.Token, error)
 }
 
+// RefreshableProvider is implemented by the providers whose access tokens
+// can be refreshed, which is the case of the providers embedding an
+// *oauth2.Config.
+type RefreshableProvider interface {
+	TokenSource(context.Context, *oauth2.Token) oauth2.TokenSource
+}
+
 func chooseHost(base, defaultHost string) string {
 	if base == "" {
 		return "https://" + defaultHost
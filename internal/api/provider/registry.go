@@ -0,0 +1,50 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/supabase/auth/internal/conf"
+)
+
+// Factory creates a provider with the scopes requested by the user. The
+// returned provider must implement OAuthProvider to complete the
+// authorization code flow in the callback.
+type Factory func(ctx context.Context, config *conf.GlobalConfiguration, scopes string) (Provider, error)
+
+var registry = struct {
+	sync.RWMutex
+	factories map[string]Factory
+}{
+	factories: make(map[string]Factory),
+}
+
+// Register makes a provider available under the name, so that it can be
+// added in its own file or package without changing the built-in providers.
+// It is meant to be called from an init function, and panics if the name is
+// already registered. Built-in providers take precedence over registered
+// ones.
+func Register(name string, factory Factory) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if factory == nil {
+		panic("provider: Register factory is nil")
+	}
+
+	if _, dup := registry.factories[name]; dup {
+		panic(fmt.Sprintf("provider: Register called twice for provider %q", name))
+	}
+
+	registry.factories[name] = factory
+}
+
+// Lookup returns the factory of the provider registered under the name.
+func Lookup(name string) (Factory, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	factory, ok := registry.factories[name]
+	return factory, ok
+}
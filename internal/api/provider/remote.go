@@ -0,0 +1,148 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/utilities"
+	"golang.org/x/oauth2"
+)
+
+// Remote providers
+
+type remoteProvider struct {
+	*oauth2.Config
+	Name         string
+	DriverURL    string
+	DriverSecret string
+}
+
+// remoteDriverRequest is sent to the driver after exchanging the
+// authorization code with the provider.
+type remoteDriverRequest struct {
+	Provider     string     `json:"provider"`
+	AccessToken  string     `json:"access_token"`
+	RefreshToken string     `json:"refresh_token,omitempty"`
+	TokenType    string     `json:"token_type,omitempty"`
+	Expiry       *time.Time `json:"expiry,omitempty"`
+	IDToken      string     `json:"id_token,omitempty"`
+}
+
+type remoteDriverEmail struct {
+	Email    string `json:"email"`
+	Verified bool   `json:"verified"`
+	Primary  bool   `json:"primary"`
+}
+
+// remoteDriverResponse is the data of the user the driver responds with.
+type remoteDriverResponse struct {
+	Emails      []remoteDriverEmail    `json:"emails"`
+	Metadata    *Claims                `json:"metadata"`
+	AppMetadata map[string]interface{} `json:"app_metadata"`
+}
+
+// NewRemoteProvider creates a provider whose user data is mapped from its
+// tokens by a driver service.
+func NewRemoteProvider(name string, ext conf.RemoteProviderConfiguration, scopes string) (OAuthProvider, error) {
+	if err := ext.Validate(); err != nil {
+		return nil, err
+	}
+
+	oauthScopes := append([]string{}, ext.Scopes...)
+	if scopes != "" {
+		oauthScopes = append(oauthScopes, strings.Split(scopes, ",")...)
+	}
+
+	return &remoteProvider{
+		Config: &oauth2.Config{
+			ClientID:     ext.ClientID[0],
+			ClientSecret: ext.Secret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  ext.AuthURL,
+				TokenURL: ext.TokenURL,
+			},
+			RedirectURL: ext.RedirectURI,
+			Scopes:      oauthScopes,
+		},
+		Name:         name,
+		DriverURL:    ext.DriverURL,
+		DriverSecret: ext.DriverSecret,
+	}, nil
+}
+
+func (p remoteProvider) GetOAuthToken(code string) (*oauth2.Token, error) {
+	return p.Exchange(context.Background(), code)
+}
+
+func (p remoteProvider) GetUserData(ctx context.Context, tok *oauth2.Token) (*UserProvidedData, error) {
+	payload := remoteDriverRequest{
+		Provider:     p.Name,
+		AccessToken:  tok.AccessToken,
+		RefreshToken: tok.RefreshToken,
+		TokenType:    tok.TokenType,
+	}
+	if !tok.Expiry.IsZero() {
+		payload.Expiry = &tok.Expiry
+	}
+	if idToken, ok := tok.Extra("id_token").(string); ok {
+		payload.IDToken = idToken
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.DriverURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.DriverSecret != "" {
+		req.Header.Set("Authorization", "Bearer "+p.DriverSecret)
+	}
+
+	client := &http.Client{Timeout: defaultTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer utilities.SafeClose(resp.Body)
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("a %v error occurred with retrieving user from the %s driver", resp.StatusCode, p.Name)
+	}
+
+	var u remoteDriverResponse
+	if err := json.NewDecoder(resp.Body).Decode(&u); err != nil {
+		return nil, err
+	}
+
+	if u.Metadata == nil || u.Metadata.Subject == "" {
+		return nil, errors.New("remote: driver response is missing the subject of the user")
+	}
+
+	data := &UserProvidedData{
+		Metadata:    u.Metadata,
+		AppMetadata: u.AppMetadata,
+	}
+
+	if data.Metadata.ProviderId == "" {
+		data.Metadata.ProviderId = data.Metadata.Subject
+	}
+
+	for _, e := range u.Emails {
+		if e.Email != "" {
+			data.Emails = append(data.Emails, Email{Email: e.Email, Verified: e.Verified, Primary: e.Primary})
+		}
+	}
+
+	return data, nil
+}
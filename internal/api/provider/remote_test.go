@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+	"golang.org/x/oauth2"
+)
+
+func TestRemoteProviderGetUserData(t *testing.T) {
+	var got remoteDriverRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "Bearer driver-secret", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"emails": [{"email": "jane@acme.test", "verified": true, "primary": true}],
+			"metadata": {"sub": "1234", "name": "Jane Doe"},
+			"app_metadata": {"acme": {"department": "engineering"}}
+		}`))
+	}))
+	defer server.Close()
+
+	p, err := NewRemoteProvider("acme", conf.RemoteProviderConfiguration{
+		OAuthProviderConfiguration: conf.OAuthProviderConfiguration{
+			Enabled:     true,
+			ClientID:    []string{"acme-client"},
+			Secret:      "acme-secret",
+			RedirectURI: "http://localhost:9999/callback",
+		},
+		AuthURL:      "https://sso.acme.test/authorize",
+		TokenURL:     "https://sso.acme.test/token",
+		Scopes:       []string{"openid"},
+		DriverURL:    server.URL,
+		DriverSecret: "driver-secret",
+	}, "profile")
+	require.NoError(t, err)
+	require.Contains(t, p.AuthCodeURL("state"), "scope=openid+profile")
+
+	data, err := p.GetUserData(context.Background(), &oauth2.Token{AccessToken: "access-token"})
+	require.NoError(t, err)
+
+	require.Equal(t, "acme", got.Provider)
+	require.Equal(t, "access-token", got.AccessToken)
+
+	require.Equal(t, []Email{{Email: "jane@acme.test", Verified: true, Primary: true}}, data.Emails)
+	require.Equal(t, "1234", data.Metadata.Subject)
+	require.Equal(t, "1234", data.Metadata.ProviderId)
+	require.Equal(t, "Jane Doe", data.Metadata.Name)
+	require.Equal(t, map[string]interface{}{"department": "engineering"}, data.AppMetadata["acme"])
+}
+
+func TestRemoteProviderRequiresSubject(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"emails": [{"email": "jane@acme.test"}], "metadata": {}}`))
+	}))
+	defer server.Close()
+
+	p, err := NewRemoteProvider("acme", conf.RemoteProviderConfiguration{
+		OAuthProviderConfiguration: conf.OAuthProviderConfiguration{
+			Enabled:     true,
+			ClientID:    []string{"acme-client"},
+			Secret:      "acme-secret",
+			RedirectURI: "http://localhost:9999/callback",
+		},
+		AuthURL:   "https://sso.acme.test/authorize",
+		TokenURL:  "https://sso.acme.test/token",
+		DriverURL: server.URL,
+	}, "")
+	require.NoError(t, err)
+
+	_, err = p.GetUserData(context.Background(), &oauth2.Token{AccessToken: "access-token"})
+	require.Error(t, err)
+}
+
+func TestRegister(t *testing.T) {
+	factory := func(ctx context.Context, config *conf.GlobalConfiguration, scopes string) (Provider, error) {
+		return nil, nil
+	}
+
+	Register("test_registered", factory)
+
+	_, ok := Lookup("test_registered")
+	require.True(t, ok)
+
+	_, ok = Lookup("test_unregistered")
+	require.False(t, ok)
+
+	require.Panics(t, func() {
+		Register("test_registered", factory)
+	})
+}
@@ -0,0 +1,120 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/supabase/auth/internal/conf"
+	"golang.org/x/oauth2"
+)
+
+// Salesforce
+
+const defaultSalesforceAuthBase = "login.salesforce.com"
+
+type salesforceProvider struct {
+	*oauth2.Config
+	Host string
+}
+
+// salesforceUser is the response of the identity URL. See:
+// https://help.salesforce.com/s/articleView?id=sf.remoteaccess_using_openid.htm
+type salesforceUser struct {
+	ID             string `json:"user_id"`
+	OrganizationID string `json:"organization_id"`
+	Username       string `json:"username"`
+	NickName       string `json:"nick_name"`
+	DisplayName    string `json:"display_name"`
+	FirstName      string `json:"first_name"`
+	LastName       string `json:"last_name"`
+	Email          string `json:"email"`
+	EmailVerified  bool   `json:"email_verified"`
+	Locale         string `json:"locale"`
+	Photos         struct {
+		Picture   string `json:"picture"`
+		Thumbnail string `json:"thumbnail"`
+	} `json:"photos"`
+}
+
+// NewSalesforceProvider creates a Salesforce account provider. The URL
+// defaults to https://login.salesforce.com, but can be set to
+// https://test.salesforce.com for sandboxes or to the org's My Domain.
+func NewSalesforceProvider(ext conf.OAuthProviderConfiguration, scopes string) (OAuthProvider, error) {
+	if err := ext.ValidateOAuth(); err != nil {
+		return nil, err
+	}
+
+	oauthScopes := []string{
+		"id",
+	}
+
+	if scopes != "" {
+		oauthScopes = append(oauthScopes, strings.Split(scopes, ",")...)
+	}
+
+	host := chooseHost(ext.URL, defaultSalesforceAuthBase)
+	return &salesforceProvider{
+		Config: &oauth2.Config{
+			ClientID:     ext.ClientID[0],
+			ClientSecret: ext.Secret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  host + "/services/oauth2/authorize",
+				TokenURL: host + "/services/oauth2/token",
+			},
+			RedirectURL: ext.RedirectURI,
+			Scopes:      oauthScopes,
+		},
+		Host: host,
+	}, nil
+}
+
+func (g salesforceProvider) GetOAuthToken(code string) (*oauth2.Token, error) {
+	return g.Exchange(context.Background(), code)
+}
+
+func (g salesforceProvider) GetUserData(ctx context.Context, tok *oauth2.Token) (*UserProvidedData, error) {
+	// The token response contains the identity URL of the user, which
+	// differs per org and user, so it can't be derived from the host.
+	identityURL, ok := tok.Extra("id").(string)
+	if !ok || identityURL == "" {
+		return nil, errors.New("salesforce: no identity URL present in token response")
+	}
+
+	var u salesforceUser
+	if err := makeRequest(ctx, tok, g.Config, identityURL, &u); err != nil {
+		return nil, err
+	}
+
+	data := &UserProvidedData{}
+	if u.Email != "" {
+		data.Emails = []Email{{
+			Email:    u.Email,
+			Verified: u.EmailVerified,
+			Primary:  true,
+		}}
+	}
+
+	data.Metadata = &Claims{
+		Issuer:            g.Host,
+		Subject:           u.ID,
+		Name:              u.DisplayName,
+		GivenName:         u.FirstName,
+		FamilyName:        u.LastName,
+		NickName:          u.NickName,
+		PreferredUsername: u.Username,
+		Picture:           u.Photos.Picture,
+		Locale:            u.Locale,
+		CustomClaims: map[string]interface{}{
+			"organization_id": u.OrganizationID,
+		},
+
+		// To be deprecated
+		AvatarURL:   u.Photos.Picture,
+		FullName:    u.DisplayName,
+		ProviderId:  u.ID,
+		UserNameKey: u.Username,
+	}
+
+	return data, nil
+}
@@ -0,0 +1,269 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/utilities"
+	"golang.org/x/oauth2"
+)
+
+// Steam
+
+const (
+	defaultSteamAuthBase = "steamcommunity.com"
+	defaultSteamAPIBase  = "api.steampowered.com"
+
+	openIDNamespace        = "http://specs.openid.net/auth/2.0"
+	openIDIdentifierSelect = "http://specs.openid.net/auth/2.0/identifier_select"
+)
+
+var steamIDPattern = regexp.MustCompile(`^[0-9]{17}$`)
+
+// SteamProvider authenticates users with Steam's OpenID 2.0 endpoint. As
+// OpenID 2.0 has no authorization code, the callback is handled by
+// VerifyAssertion instead of GetOAuthToken and GetUserData.
+type SteamProvider struct {
+	RedirectURI     string
+	Endpoint        string
+	ClaimedIDPrefix string
+	APIPath         string
+	APIKey          string
+}
+
+type steamPlayer struct {
+	SteamID     string `json:"steamid"`
+	PersonaName string `json:"personaname"`
+	RealName    string `json:"realname"`
+	ProfileURL  string `json:"profileurl"`
+	AvatarFull  string `json:"avatarfull"`
+	CountryCode string `json:"loccountrycode"`
+}
+
+// NewSteamProvider creates a Steam account provider.
+func NewSteamProvider(ext conf.SteamProviderConfiguration) (OAuthProvider, error) {
+	if err := ext.Validate(); err != nil {
+		return nil, err
+	}
+
+	authHost := chooseHost(ext.URL, defaultSteamAuthBase)
+	apiHost := chooseHost(ext.ApiURL, defaultSteamAPIBase)
+
+	return &SteamProvider{
+		RedirectURI:     ext.RedirectURI,
+		Endpoint:        authHost + "/openid/login",
+		ClaimedIDPrefix: authHost + "/openid/id/",
+		APIPath:         apiHost,
+		APIKey:          ext.APIKey,
+	}, nil
+}
+
+// AuthCodeURL returns the URL of the Steam sign in page. The state is passed
+// through the return_to URL as OpenID 2.0 has no state parameter.
+func (p SteamProvider) AuthCodeURL(state string, args ...oauth2.AuthCodeOption) string {
+	returnTo := p.returnTo(state)
+
+	q := url.Values{}
+	q.Set("openid.ns", openIDNamespace)
+	q.Set("openid.mode", "checkid_setup")
+	q.Set("openid.return_to", returnTo)
+	q.Set("openid.realm", realmFromURL(p.RedirectURI))
+	q.Set("openid.identity", openIDIdentifierSelect)
+	q.Set("openid.claimed_id", openIDIdentifierSelect)
+
+	return p.Endpoint + "?" + q.Encode()
+}
+
+// GetOAuthToken is a stub method for OAuthProvider interface, unused in OpenID 2.0
+func (p SteamProvider) GetOAuthToken(_ string) (*oauth2.Token, error) {
+	return &oauth2.Token{}, nil
+}
+
+// GetUserData is a stub method for OAuthProvider interface, unused in OpenID 2.0
+func (p SteamProvider) GetUserData(ctx context.Context, tok *oauth2.Token) (*UserProvidedData, error) {
+	return &UserProvidedData{}, nil
+}
+
+// VerifyAssertion checks the positive assertion Steam redirected back with
+// directly with Steam, and returns the data of the player it identifies.
+func (p SteamProvider) VerifyAssertion(ctx context.Context, state string, params url.Values) (*UserProvidedData, error) {
+	if mode := params.Get("openid.mode"); mode != "id_res" {
+		return nil, fmt.Errorf("steam: unexpected openid.mode %q", mode)
+	}
+
+	if params.Get("openid.ns") != openIDNamespace {
+		return nil, errors.New("steam: unexpected openid.ns")
+	}
+
+	if params.Get("openid.op_endpoint") != p.Endpoint {
+		return nil, errors.New("steam: unexpected openid.op_endpoint")
+	}
+
+	if params.Get("openid.return_to") != p.returnTo(state) {
+		return nil, errors.New("steam: openid.return_to does not match the redirect URI")
+	}
+
+	signed := make(map[string]bool)
+	for _, field := range strings.Split(params.Get("openid.signed"), ",") {
+		signed[field] = true
+	}
+	for _, field := range []string{"op_endpoint", "claimed_id", "identity", "return_to", "response_nonce"} {
+		if !signed[field] {
+			return nil, fmt.Errorf("steam: openid.%s is not signed", field)
+		}
+	}
+
+	claimedID := params.Get("openid.claimed_id")
+	if params.Get("openid.identity") != claimedID || !strings.HasPrefix(claimedID, p.ClaimedIDPrefix) {
+		return nil, errors.New("steam: unexpected openid.claimed_id")
+	}
+
+	steamID := strings.TrimPrefix(claimedID, p.ClaimedIDPrefix)
+	if !steamIDPattern.MatchString(steamID) {
+		return nil, errors.New("steam: invalid SteamID in openid.claimed_id")
+	}
+
+	// Steam does not support associations, so the signature can only be
+	// verified by Steam itself. Steam verifies each assertion only once,
+	// which also protects against replaying it.
+	if err := p.checkAuthentication(ctx, params); err != nil {
+		return nil, err
+	}
+
+	player, err := p.fetchPlayer(ctx, steamID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &UserProvidedData{}
+	data.Metadata = &Claims{
+		Issuer:            p.Endpoint,
+		Subject:           steamID,
+		Name:              player.PersonaName,
+		PreferredUsername: player.PersonaName,
+		Picture:           player.AvatarFull,
+		Profile:           player.ProfileURL,
+		CustomClaims: map[string]interface{}{
+			"steam_id": steamID,
+		},
+
+		// To be deprecated
+		AvatarURL:   player.AvatarFull,
+		FullName:    player.PersonaName,
+		ProviderId:  steamID,
+		UserNameKey: player.PersonaName,
+	}
+
+	if player.RealName != "" {
+		data.Metadata.CustomClaims["real_name"] = player.RealName
+	}
+
+	if player.CountryCode != "" {
+		data.Metadata.CustomClaims["country_code"] = player.CountryCode
+	}
+
+	return data, nil
+}
+
+func (p SteamProvider) returnTo(state string) string {
+	return p.RedirectURI + "?state=" + url.QueryEscape(state)
+}
+
+func (p SteamProvider) checkAuthentication(ctx context.Context, params url.Values) error {
+	form := url.Values{}
+	for key := range params {
+		if strings.HasPrefix(key, "openid.") {
+			form.Set(key, params.Get(key))
+		}
+	}
+	form.Set("openid.mode", "check_authentication")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := &http.Client{Timeout: defaultTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer utilities.SafeClose(resp.Body)
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("a %v error occurred with verifying the assertion with steam", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	// the response is in key-value form encoding, one key:value per line
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.TrimSpace(line) == "is_valid:true" {
+			return nil
+		}
+	}
+
+	return errors.New("steam: assertion is not valid")
+}
+
+func (p SteamProvider) fetchPlayer(ctx context.Context, steamID string) (*steamPlayer, error) {
+	q := url.Values{}
+	q.Set("key", p.APIKey)
+	q.Set("steamids", steamID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.APIPath+"/ISteamUser/GetPlayerSummaries/v0002/?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: defaultTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer utilities.SafeClose(resp.Body)
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("a %v error occurred with retrieving player from steam", resp.StatusCode)
+	}
+
+	var summaries struct {
+		Response struct {
+			Players []steamPlayer `json:"players"`
+		} `json:"response"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&summaries); err != nil {
+		return nil, err
+	}
+
+	for _, player := range summaries.Response.Players {
+		if player.SteamID == steamID {
+			return &player, nil
+		}
+	}
+
+	return nil, fmt.Errorf("steam: player %s not found", steamID)
+}
+
+// realmFromURL returns the scheme and host of the URL, which is the trust
+// root the user is asked to sign in to.
+func realmFromURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return u
+	}
+
+	return parsed.Scheme + "://" + parsed.Host
+}
@@ -0,0 +1,128 @@
+package provider
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/supabase/auth/internal/conf"
+)
+
+// Telegram
+
+const IssuerTelegram = "https://oauth.telegram.org"
+
+// telegramMaxClockSkew is how far in the future the auth_date of login data
+// can be, as the clocks of Telegram and of the server can differ a little.
+const telegramMaxClockSkew = 30 * time.Second
+
+// ParseTelegramLogin verifies the user object the Telegram Login Widget
+// passes to its callback, and returns the data of the user it identifies.
+// See: https://core.telegram.org/widgets/login#checking-authorization
+func ParseTelegramLogin(ext conf.TelegramProviderConfiguration, payload map[string]interface{}, now time.Time) (*UserProvidedData, error) {
+	if err := ext.Validate(); err != nil {
+		return nil, err
+	}
+
+	hash, ok := payload["hash"].(string)
+	if !ok || hash == "" {
+		return nil, errors.New("telegram: missing hash")
+	}
+
+	fields := make(map[string]string, len(payload))
+	keys := make([]string, 0, len(payload))
+	for key, value := range payload {
+		if key == "hash" {
+			continue
+		}
+
+		s, err := telegramFieldString(value)
+		if err != nil {
+			return nil, fmt.Errorf("telegram: invalid field %q: %w", key, err)
+		}
+
+		fields[key] = s
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lines := make([]string, 0, len(keys))
+	for _, key := range keys {
+		lines = append(lines, key+"="+fields[key])
+	}
+
+	secret := sha256.Sum256([]byte(ext.BotToken))
+	mac := hmac.New(sha256.New, secret[:])
+	mac.Write([]byte(strings.Join(lines, "\n")))
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(hash))) {
+		return nil, errors.New("telegram: hash does not match")
+	}
+
+	authDate, err := strconv.ParseInt(fields["auth_date"], 10, 64)
+	if err != nil {
+		return nil, errors.New("telegram: invalid auth_date")
+	}
+
+	age := now.Sub(time.Unix(authDate, 0))
+	if age > ext.MaxAuthAge {
+		return nil, errors.New("telegram: login data has expired")
+	}
+
+	// login data dated in the future would only expire later, so it
+	// could be replayed for longer
+	if age < -telegramMaxClockSkew {
+		return nil, errors.New("telegram: auth_date is in the future")
+	}
+
+	id := fields["id"]
+	if id == "" {
+		return nil, errors.New("telegram: missing id")
+	}
+
+	name := strings.TrimSpace(fields["first_name"] + " " + fields["last_name"])
+
+	data := &UserProvidedData{}
+	data.Metadata = &Claims{
+		Issuer:            IssuerTelegram,
+		Subject:           id,
+		Name:              name,
+		GivenName:         fields["first_name"],
+		FamilyName:        fields["last_name"],
+		PreferredUsername: fields["username"],
+		Picture:           fields["photo_url"],
+
+		// To be deprecated
+		AvatarURL:   fields["photo_url"],
+		FullName:    name,
+		ProviderId:  id,
+		UserNameKey: fields["username"],
+	}
+
+	return data, nil
+}
+
+// telegramFieldString formats a field of the decoded user object the way it
+// was formatted in the data-check-string Telegram signed.
+func telegramFieldString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+
+	case bool:
+		return strconv.FormatBool(v), nil
+
+	default:
+		return "", fmt.Errorf("unsupported type %T", value)
+	}
+}
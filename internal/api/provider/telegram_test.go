@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+)
+
+func signTelegramLogin(botToken, dataCheckString string) string {
+	secret := sha256.Sum256([]byte(botToken))
+	mac := hmac.New(sha256.New, secret[:])
+	mac.Write([]byte(dataCheckString))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestParseTelegramLogin(t *testing.T) {
+	ext := conf.TelegramProviderConfiguration{
+		Enabled:    true,
+		BotToken:   "123456:testbottoken",
+		MaxAuthAge: 5 * time.Minute,
+	}
+
+	now := time.Unix(1700000060, 0)
+
+	payload := func() map[string]interface{} {
+		return map[string]interface{}{
+			"id":         float64(123456789),
+			"first_name": "Jane",
+			"last_name":  "Doe",
+			"username":   "jane",
+			"photo_url":  "https://t.me/i/userpic/320/jane.jpg",
+			"auth_date":  float64(1700000000),
+			"hash":       signTelegramLogin(ext.BotToken, "auth_date=1700000000\nfirst_name=Jane\nid=123456789\nlast_name=Doe\nphoto_url=https://t.me/i/userpic/320/jane.jpg\nusername=jane"),
+		}
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		data, err := ParseTelegramLogin(ext, payload(), now)
+		require.NoError(t, err)
+		require.Empty(t, data.Emails)
+		require.Equal(t, "123456789", data.Metadata.Subject)
+		require.Equal(t, "Jane Doe", data.Metadata.Name)
+		require.Equal(t, "jane", data.Metadata.PreferredUsername)
+		require.Equal(t, "https://t.me/i/userpic/320/jane.jpg", data.Metadata.Picture)
+	})
+
+	t.Run("TamperedField", func(t *testing.T) {
+		p := payload()
+		p["id"] = float64(987654321)
+		_, err := ParseTelegramLogin(ext, p, now)
+		require.Error(t, err)
+	})
+
+	t.Run("WrongBotToken", func(t *testing.T) {
+		other := ext
+		other.BotToken = "654321:otherbottoken"
+		_, err := ParseTelegramLogin(other, payload(), now)
+		require.Error(t, err)
+	})
+
+	t.Run("MissingHash", func(t *testing.T) {
+		p := payload()
+		delete(p, "hash")
+		_, err := ParseTelegramLogin(ext, p, now)
+		require.Error(t, err)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		_, err := ParseTelegramLogin(ext, payload(), now.Add(10*time.Minute))
+		require.Error(t, err)
+	})
+
+	t.Run("FutureAuthDate", func(t *testing.T) {
+		// a little clock skew is allowed
+		_, err := ParseTelegramLogin(ext, payload(), time.Unix(1700000000, 0).Add(-10*time.Second))
+		require.NoError(t, err)
+
+		_, err = ParseTelegramLogin(ext, payload(), time.Unix(1700000000, 0).Add(-time.Minute))
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,153 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/supabase/auth/internal/conf"
+	"golang.org/x/oauth2"
+)
+
+// VK
+
+const (
+	defaultVKAuthBase = "oauth.vk.com"
+	defaultVKAPIBase  = "api.vk.com"
+
+	// vkAPIVersion is the version of the VK API the user data is fetched
+	// with.
+	vkAPIVersion = "5.199"
+)
+
+type vkProvider struct {
+	*oauth2.Config
+	APIPath string
+}
+
+type vkUser struct {
+	ID         int64  `json:"id"`
+	FirstName  string `json:"first_name"`
+	LastName   string `json:"last_name"`
+	ScreenName string `json:"screen_name"`
+	Photo      string `json:"photo_200"`
+	Sex        int    `json:"sex"`
+}
+
+type vkUsersResponse struct {
+	Response []vkUser `json:"response"`
+	Error    *struct {
+		Code    int    `json:"error_code"`
+		Message string `json:"error_msg"`
+	} `json:"error"`
+}
+
+// NewVKProvider creates a VK account provider.
+func NewVKProvider(ext conf.OAuthProviderConfiguration, scopes string) (OAuthProvider, error) {
+	if err := ext.ValidateOAuth(); err != nil {
+		return nil, err
+	}
+
+	authHost := chooseHost(ext.URL, defaultVKAuthBase)
+	apiHost := chooseHost(ext.ApiURL, defaultVKAPIBase)
+
+	oauthScopes := []string{
+		"email",
+	}
+
+	if scopes != "" {
+		oauthScopes = append(oauthScopes, strings.Split(scopes, ",")...)
+	}
+
+	return &vkProvider{
+		Config: &oauth2.Config{
+			ClientID:     ext.ClientID[0],
+			ClientSecret: ext.Secret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:   authHost + "/authorize",
+				TokenURL:  authHost + "/access_token",
+				AuthStyle: oauth2.AuthStyleInParams,
+			},
+			RedirectURL: ext.RedirectURI,
+			Scopes:      oauthScopes,
+		},
+		APIPath: apiHost,
+	}, nil
+}
+
+func (p vkProvider) GetOAuthToken(code string) (*oauth2.Token, error) {
+	return p.Exchange(context.Background(), code)
+}
+
+// GetUserData fetches the profile of the user. VK doesn't return the email
+// address with the profile, it's returned with the access token when the
+// user granted the email permission.
+func (p vkProvider) GetUserData(ctx context.Context, tok *oauth2.Token) (*UserProvidedData, error) {
+	q := url.Values{}
+	q.Set("fields", "screen_name,photo_200,sex")
+	q.Set("access_token", tok.AccessToken)
+	q.Set("v", vkAPIVersion)
+
+	var resp vkUsersResponse
+	if err := makeRequest(ctx, tok, p.Config, p.APIPath+"/method/users.get?"+q.Encode(), &resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("vk: unable to get user: %d %s", resp.Error.Code, resp.Error.Message)
+	}
+	if len(resp.Response) == 0 {
+		return nil, errors.New("vk: no user returned")
+	}
+	u := resp.Response[0]
+
+	data := &UserProvidedData{}
+
+	// the email address is only bound to a VK account once it's confirmed
+	if email, ok := tok.Extra("email").(string); ok && email != "" {
+		data.Emails = append(data.Emails, Email{
+			Email:    email,
+			Verified: true,
+			Primary:  true,
+		})
+	}
+
+	subject := strconv.FormatInt(u.ID, 10)
+	name := strings.TrimSpace(u.FirstName + " " + u.LastName)
+
+	data.Metadata = &Claims{
+		Issuer:            p.APIPath,
+		Subject:           subject,
+		Name:              name,
+		GivenName:         u.FirstName,
+		FamilyName:        u.LastName,
+		PreferredUsername: u.ScreenName,
+		Picture:           u.Photo,
+		Gender:            vkGender(u.Sex),
+
+		// To be deprecated
+		AvatarURL:   u.Photo,
+		FullName:    name,
+		ProviderId:  subject,
+		UserNameKey: u.ScreenName,
+	}
+
+	if u.ScreenName != "" {
+		data.Metadata.Profile = "https://vk.com/" + u.ScreenName
+	}
+
+	return data, nil
+}
+
+func vkGender(sex int) string {
+	switch sex {
+	case 1:
+		return "female"
+	case 2:
+		return "male"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,242 @@
+package provider
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"golang.org/x/crypto/sha3"
+)
+
+// Web3 (Sign-In with Ethereum, EIP-4361)
+
+const siweHeaderSuffix = " wants you to sign in with your Ethereum account:"
+
+var (
+	ethereumAddressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+	siweNoncePattern       = regexp.MustCompile(`^[a-zA-Z0-9]{8,}$`)
+)
+
+// SIWEMessage is a parsed Sign-In with Ethereum message. See:
+// https://eips.ethereum.org/EIPS/eip-4361
+type SIWEMessage struct {
+	Raw string
+
+	Scheme         string
+	Domain         string
+	Address        string
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        int
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+// ParseSIWEMessage parses a Sign-In with Ethereum message.
+func ParseSIWEMessage(raw string) (*SIWEMessage, error) {
+	lines := strings.Split(raw, "\n")
+	if len(lines) < 2 {
+		return nil, errors.New("siwe: message is too short")
+	}
+
+	header, ok := strings.CutSuffix(lines[0], siweHeaderSuffix)
+	if !ok {
+		return nil, errors.New("siwe: invalid message header")
+	}
+
+	m := &SIWEMessage{Raw: raw}
+	if scheme, domain, found := strings.Cut(header, "://"); found {
+		m.Scheme = scheme
+		m.Domain = domain
+	} else {
+		m.Domain = header
+	}
+
+	if m.Domain == "" {
+		return nil, errors.New("siwe: missing domain")
+	}
+
+	m.Address = lines[1]
+	if !ethereumAddressPattern.MatchString(m.Address) {
+		return nil, errors.New("siwe: invalid address")
+	}
+
+	i := 2
+	for i < len(lines) && lines[i] == "" {
+		i++
+	}
+
+	if i < len(lines) && !strings.HasPrefix(lines[i], "URI: ") {
+		m.Statement = lines[i]
+		i++
+	}
+
+	for i < len(lines) && lines[i] == "" {
+		i++
+	}
+
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if line == "Resources:" {
+			for _, resource := range lines[i+1:] {
+				uri, ok := strings.CutPrefix(resource, "- ")
+				if !ok {
+					return nil, errors.New("siwe: invalid resource")
+				}
+				m.Resources = append(m.Resources, uri)
+			}
+			break
+		}
+
+		key, value, found := strings.Cut(line, ": ")
+		if !found {
+			return nil, fmt.Errorf("siwe: invalid line %q", line)
+		}
+
+		var err error
+		switch key {
+		case "URI":
+			m.URI = value
+		case "Version":
+			m.Version = value
+		case "Chain ID":
+			m.ChainID, err = strconv.Atoi(value)
+		case "Nonce":
+			m.Nonce = value
+		case "Issued At":
+			m.IssuedAt, err = time.Parse(time.RFC3339, value)
+		case "Expiration Time":
+			var t time.Time
+			t, err = time.Parse(time.RFC3339, value)
+			m.ExpirationTime = &t
+		case "Not Before":
+			var t time.Time
+			t, err = time.Parse(time.RFC3339, value)
+			m.NotBefore = &t
+		case "Request ID":
+			m.RequestID = value
+		default:
+			return nil, fmt.Errorf("siwe: unknown field %q", key)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("siwe: invalid %s: %w", key, err)
+		}
+	}
+
+	switch {
+	case m.URI == "":
+		return nil, errors.New("siwe: missing URI")
+	case m.Version != "1":
+		return nil, errors.New("siwe: unsupported version")
+	case m.ChainID == 0:
+		return nil, errors.New("siwe: missing chain ID")
+	case !siweNoncePattern.MatchString(m.Nonce):
+		return nil, errors.New("siwe: invalid nonce")
+	case m.IssuedAt.IsZero():
+		return nil, errors.New("siwe: missing issued at")
+	}
+
+	return m, nil
+}
+
+// VerifyTime checks that the message is valid at the given time.
+func (m *SIWEMessage) VerifyTime(now time.Time) error {
+	if m.ExpirationTime != nil && !now.Before(*m.ExpirationTime) {
+		return errors.New("siwe: message has expired")
+	}
+
+	if m.NotBefore != nil && now.Before(*m.NotBefore) {
+		return errors.New("siwe: message is not yet valid")
+	}
+
+	return nil
+}
+
+// VerifySignature checks that the message was signed by its address with
+// personal_sign (EIP-191).
+func (m *SIWEMessage) VerifySignature(signature string) error {
+	address, err := RecoverEthereumAddress(m.Raw, signature)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(address, m.Address) {
+		return errors.New("siwe: signature does not match address")
+	}
+
+	return nil
+}
+
+// UserData returns the data of the wallet that signed the message. Users are
+// keyed by the lowercase address, so the same wallet signs in to the same
+// user on any chain.
+func (m *SIWEMessage) UserData() *UserProvidedData {
+	address := strings.ToLower(m.Address)
+
+	return &UserProvidedData{
+		Metadata: &Claims{
+			Issuer:  "web3:ethereum",
+			Subject: address,
+			CustomClaims: map[string]interface{}{
+				"address":  m.Address,
+				"chain_id": m.ChainID,
+				"domain":   m.Domain,
+				"uri":      m.URI,
+			},
+
+			// To be deprecated
+			ProviderId: address,
+		},
+	}
+}
+
+// RecoverEthereumAddress returns the address that signed the message with
+// personal_sign (EIP-191).
+func RecoverEthereumAddress(message, signature string) (string, error) {
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "0x"))
+	if err != nil || len(sig) != 65 {
+		return "", errors.New("web3: invalid signature")
+	}
+
+	v := sig[64]
+	if v >= 27 {
+		v -= 27
+	}
+	if v > 1 {
+		return "", errors.New("web3: invalid signature recovery id")
+	}
+
+	// the compact format puts the recovery code first, and uses 27 for
+	// uncompressed public keys
+	compact := make([]byte, 65)
+	compact[0] = 27 + v
+	copy(compact[1:], sig[:64])
+
+	hash := keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)))
+
+	pub, _, err := ecdsa.RecoverCompact(compact, hash)
+	if err != nil {
+		return "", fmt.Errorf("web3: unable to recover public key: %w", err)
+	}
+
+	// the address is the last 20 bytes of the hash of the uncompressed
+	// public key without its 0x04 prefix
+	return "0x" + hex.EncodeToString(keccak256(pub.SerializeUncompressed()[1:])[12:]), nil
+}
+
+func keccak256(data []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(data)
+	return h.Sum(nil)
+}
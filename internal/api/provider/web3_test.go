@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/stretchr/testify/require"
+)
+
+// signPersonalMessage signs the message like a wallet's personal_sign.
+func signPersonalMessage(key *secp256k1.PrivateKey, message string) string {
+	hash := keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)))
+	compact := ecdsa.SignCompact(key, hash, false)
+
+	// move the recovery code to the end, as Ethereum does
+	sig := append(compact[1:], compact[0])
+	return "0x" + hex.EncodeToString(sig)
+}
+
+func TestRecoverEthereumAddress(t *testing.T) {
+	// the well known address of the private key 1
+	key := secp256k1.PrivKeyFromBytes([]byte{1})
+
+	address, err := RecoverEthereumAddress("hello", signPersonalMessage(key, "hello"))
+	require.NoError(t, err)
+	require.Equal(t, "0x7e5f4552091a69125d5dfcb7b8c2659029395bdf", address)
+
+	_, err = RecoverEthereumAddress("hello", "0x1234")
+	require.Error(t, err)
+}
+
+func TestParseSIWEMessage(t *testing.T) {
+	raw := "https://example.com wants you to sign in with your Ethereum account:\n" +
+		"0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf\n" +
+		"\n" +
+		"Sign in to Example\n" +
+		"\n" +
+		"URI: https://example.com/login\n" +
+		"Version: 1\n" +
+		"Chain ID: 137\n" +
+		"Nonce: 3f4c2a1b9e8d7c6b\n" +
+		"Issued At: 2024-09-01T00:00:00Z\n" +
+		"Expiration Time: 2024-09-01T00:10:00Z\n" +
+		"Resources:\n" +
+		"- https://example.com/terms"
+
+	m, err := ParseSIWEMessage(raw)
+	require.NoError(t, err)
+	require.Equal(t, "https", m.Scheme)
+	require.Equal(t, "example.com", m.Domain)
+	require.Equal(t, "0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf", m.Address)
+	require.Equal(t, "Sign in to Example", m.Statement)
+	require.Equal(t, "https://example.com/login", m.URI)
+	require.Equal(t, 137, m.ChainID)
+	require.Equal(t, "3f4c2a1b9e8d7c6b", m.Nonce)
+	require.Equal(t, []string{"https://example.com/terms"}, m.Resources)
+
+	require.NoError(t, m.VerifyTime(time.Date(2024, 9, 1, 0, 5, 0, 0, time.UTC)))
+	require.Error(t, m.VerifyTime(time.Date(2024, 9, 1, 0, 10, 0, 0, time.UTC)))
+
+	key := secp256k1.PrivKeyFromBytes([]byte{1})
+	require.NoError(t, m.VerifySignature(signPersonalMessage(key, raw)))
+
+	other := secp256k1.PrivKeyFromBytes([]byte{2})
+	require.Error(t, m.VerifySignature(signPersonalMessage(other, raw)))
+
+	data := m.UserData()
+	require.Equal(t, "0x7e5f4552091a69125d5dfcb7b8c2659029395bdf", data.Metadata.Subject)
+	require.Equal(t, 137, data.Metadata.CustomClaims["chain_id"])
+
+	withoutStatement := "example.com wants you to sign in with your Ethereum account:\n" +
+		"0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf\n" +
+		"\n" +
+		"\n" +
+		"URI: https://example.com/login\n" +
+		"Version: 1\n" +
+		"Chain ID: 1\n" +
+		"Nonce: 3f4c2a1b9e8d7c6b\n" +
+		"Issued At: 2024-09-01T00:00:00Z"
+
+	m, err = ParseSIWEMessage(withoutStatement)
+	require.NoError(t, err)
+	require.Equal(t, "", m.Scheme)
+	require.Equal(t, "", m.Statement)
+
+	invalid := []string{
+		"",
+		"example.com wants you to sign in:\n0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf",
+		"example.com wants you to sign in with your Ethereum account:\n0x1234\n\n\nURI: https://example.com\nVersion: 1\nChain ID: 1\nNonce: 3f4c2a1b9e8d7c6b\nIssued At: 2024-09-01T00:00:00Z",
+		"example.com wants you to sign in with your Ethereum account:\n0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf\n\n\nURI: https://example.com\nVersion: 2\nChain ID: 1\nNonce: 3f4c2a1b9e8d7c6b\nIssued At: 2024-09-01T00:00:00Z",
+		"example.com wants you to sign in with your Ethereum account:\n0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf\n\n\nURI: https://example.com\nVersion: 1\nChain ID: 1\nNonce: short\nIssued At: 2024-09-01T00:00:00Z",
+	}
+
+	for _, example := range invalid {
+		_, err := ParseSIWEMessage(example)
+		require.Error(t, err, "example %q should not parse", example)
+	}
+}
@@ -0,0 +1,265 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/utilities"
+	"golang.org/x/oauth2"
+)
+
+// WeChat
+
+const (
+	defaultWeChatAuthBase = "open.weixin.qq.com"
+	defaultWeChatAPIBase  = "api.weixin.qq.com"
+
+	// weChatWebsiteScope is the only scope of website applications, which
+	// sign users in by scanning a QR code with the WeChat app.
+	weChatWebsiteScope = "snsapi_login"
+)
+
+// WeChatProvider signs users in with a WeChat Open Platform website
+// application, or exchanges the code the WeChat SDK returns to a mobile
+// application. WeChat doesn't follow OAuth2 for the token exchange, the app
+// credentials are passed as appid and secret query parameters and errors are
+// returned with a 200 status.
+type WeChatProvider struct {
+	AppID        string
+	Secret       string
+	RedirectURI  string
+	AuthURL      string
+	APIPath      string
+	MobileAppID  string
+	MobileSecret string
+}
+
+type weChatToken struct {
+	AccessToken  string `json:"access_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	OpenID       string `json:"openid"`
+	Scope        string `json:"scope"`
+	UnionID      string `json:"unionid"`
+
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+type weChatUser struct {
+	OpenID     string `json:"openid"`
+	UnionID    string `json:"unionid"`
+	Nickname   string `json:"nickname"`
+	Sex        int    `json:"sex"`
+	Province   string `json:"province"`
+	City       string `json:"city"`
+	Country    string `json:"country"`
+	HeadImgURL string `json:"headimgurl"`
+
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// NewWeChatProvider creates a WeChat account provider.
+func NewWeChatProvider(ext conf.WeChatProviderConfiguration) (OAuthProvider, error) {
+	if err := ext.Validate(); err != nil {
+		return nil, err
+	}
+
+	p := &WeChatProvider{
+		Secret:       ext.Secret,
+		RedirectURI:  ext.RedirectURI,
+		AuthURL:      chooseHost(ext.URL, defaultWeChatAuthBase) + "/connect/qrconnect",
+		APIPath:      chooseHost(ext.ApiURL, defaultWeChatAPIBase),
+		MobileAppID:  ext.MobileAppID,
+		MobileSecret: ext.MobileSecret,
+	}
+	if len(ext.ClientID) > 0 {
+		p.AppID = ext.ClientID[0]
+	}
+
+	return p, nil
+}
+
+// AuthCodeURL returns the URL of the QR code sign in page. WeChat rejects
+// the request unless appid, redirect_uri, response_type, scope and state
+// come first and in this order, and the URL ends with #wechat_redirect.
+func (p WeChatProvider) AuthCodeURL(state string, args ...oauth2.AuthCodeOption) string {
+	config := &oauth2.Config{
+		ClientID:    p.AppID,
+		RedirectURL: p.RedirectURI,
+		Scopes:      []string{weChatWebsiteScope},
+		Endpoint:    oauth2.Endpoint{AuthURL: p.AuthURL},
+	}
+
+	extra := url.Values{}
+	if u, err := url.Parse(config.AuthCodeURL(state, args...)); err == nil {
+		extra = u.Query()
+	}
+	for _, key := range []string{"client_id", "redirect_uri", "response_type", "scope", "state"} {
+		extra.Del(key)
+	}
+
+	var b strings.Builder
+	b.WriteString(p.AuthURL)
+	b.WriteString("?appid=" + url.QueryEscape(p.AppID))
+	b.WriteString("&redirect_uri=" + url.QueryEscape(p.RedirectURI))
+	b.WriteString("&response_type=code")
+	b.WriteString("&scope=" + weChatWebsiteScope)
+	b.WriteString("&state=" + url.QueryEscape(state))
+
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range extra[key] {
+			b.WriteString("&" + url.QueryEscape(key) + "=" + url.QueryEscape(value))
+		}
+	}
+
+	b.WriteString("#wechat_redirect")
+	return b.String()
+}
+
+// GetOAuthToken exchanges the code of the website application.
+func (p WeChatProvider) GetOAuthToken(code string) (*oauth2.Token, error) {
+	if p.AppID == "" {
+		return nil, errors.New("wechat: the website application is not configured")
+	}
+	return p.exchange(context.Background(), p.AppID, p.Secret, code)
+}
+
+// ExchangeMobileCode exchanges the code the WeChat SDK returned to the
+// mobile application, which has its own AppID and AppSecret.
+func (p WeChatProvider) ExchangeMobileCode(ctx context.Context, code string) (*oauth2.Token, error) {
+	if p.MobileAppID == "" {
+		return nil, errors.New("wechat: the mobile application is not configured")
+	}
+	return p.exchange(ctx, p.MobileAppID, p.MobileSecret, code)
+}
+
+func (p WeChatProvider) exchange(ctx context.Context, appID, secret, code string) (*oauth2.Token, error) {
+	q := url.Values{}
+	q.Set("appid", appID)
+	q.Set("secret", secret)
+	q.Set("code", code)
+	q.Set("grant_type", "authorization_code")
+
+	var t weChatToken
+	if err := p.get(ctx, p.APIPath+"/sns/oauth2/access_token?"+q.Encode(), &t); err != nil {
+		return nil, err
+	}
+	if t.ErrCode != 0 {
+		return nil, fmt.Errorf("wechat: unable to exchange code: %d %s", t.ErrCode, t.ErrMsg)
+	}
+	if t.AccessToken == "" || t.OpenID == "" {
+		return nil, errors.New("wechat: token response is missing the access token or the openid")
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  t.AccessToken,
+		RefreshToken: t.RefreshToken,
+	}
+	if t.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+
+	return token.WithExtra(map[string]interface{}{
+		"openid":  t.OpenID,
+		"unionid": t.UnionID,
+		"scope":   t.Scope,
+	}), nil
+}
+
+// GetUserData fetches the profile of the user. The unionid, which is the
+// same for all the applications of an Open Platform account, is used as the
+// subject when there is one, so that users signing in on the website and in
+// the mobile application get the same identity. Otherwise the openid of the
+// application is used.
+func (p WeChatProvider) GetUserData(ctx context.Context, tok *oauth2.Token) (*UserProvidedData, error) {
+	openID, _ := tok.Extra("openid").(string)
+	if openID == "" {
+		return nil, errors.New("wechat: the token has no openid")
+	}
+
+	q := url.Values{}
+	q.Set("access_token", tok.AccessToken)
+	q.Set("openid", openID)
+
+	var u weChatUser
+	if err := p.get(ctx, p.APIPath+"/sns/userinfo?"+q.Encode(), &u); err != nil {
+		return nil, err
+	}
+	if u.ErrCode != 0 {
+		return nil, fmt.Errorf("wechat: unable to get user info: %d %s", u.ErrCode, u.ErrMsg)
+	}
+
+	unionID := u.UnionID
+	if unionID == "" {
+		unionID, _ = tok.Extra("unionid").(string)
+	}
+
+	subject := openID
+	if unionID != "" {
+		subject = unionID
+	}
+
+	// WeChat doesn't share the user's email address
+	data := &UserProvidedData{}
+	data.Metadata = &Claims{
+		Issuer:   p.APIPath,
+		Subject:  subject,
+		Name:     u.Nickname,
+		NickName: u.Nickname,
+		Picture:  u.HeadImgURL,
+		CustomClaims: map[string]interface{}{
+			"openid":   openID,
+			"unionid":  unionID,
+			"province": u.Province,
+			"city":     u.City,
+			"country":  u.Country,
+		},
+
+		// To be deprecated
+		AvatarURL:  u.HeadImgURL,
+		FullName:   u.Nickname,
+		ProviderId: subject,
+	}
+
+	return data, nil
+}
+
+func (p WeChatProvider) get(ctx context.Context, endpoint string, dst interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: defaultTimeout}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer utilities.SafeClose(res.Body)
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return httpError(res.StatusCode, string(body))
+	}
+
+	return json.Unmarshal(body, dst)
+}
@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+	"golang.org/x/oauth2"
+)
+
+func TestWeChatAuthCodeURL(t *testing.T) {
+	p, err := NewWeChatProvider(conf.WeChatProviderConfiguration{
+		OAuthProviderConfiguration: conf.OAuthProviderConfiguration{
+			Enabled:     true,
+			ClientID:    []string{"wxappid"},
+			Secret:      "secret",
+			RedirectURI: "https://example.com/callback",
+		},
+	})
+	require.NoError(t, err)
+
+	authURL := p.AuthCodeURL("state", oauth2.SetAuthURLParam("lang", "en"))
+	require.Equal(t, "https://open.weixin.qq.com/connect/qrconnect?appid=wxappid&redirect_uri=https%3A%2F%2Fexample.com%2Fcallback&response_type=code&scope=snsapi_login&state=state&lang=en#wechat_redirect", authURL)
+}
+
+func TestWeChatProviderConfiguration(t *testing.T) {
+	cases := []struct {
+		desc   string
+		config conf.WeChatProviderConfiguration
+		err    string
+	}{
+		{
+			desc:   "disabled",
+			config: conf.WeChatProviderConfiguration{},
+			err:    "not enabled",
+		},
+		{
+			desc: "no application",
+			config: conf.WeChatProviderConfiguration{
+				OAuthProviderConfiguration: conf.OAuthProviderConfiguration{Enabled: true},
+			},
+			err: "missing WeChat website or mobile application",
+		},
+		{
+			desc: "mobile application only",
+			config: conf.WeChatProviderConfiguration{
+				OAuthProviderConfiguration: conf.OAuthProviderConfiguration{Enabled: true},
+				MobileAppID:                "wxmobileappid",
+				MobileSecret:               "secret",
+			},
+		},
+		{
+			desc: "mobile application without secret",
+			config: conf.WeChatProviderConfiguration{
+				OAuthProviderConfiguration: conf.OAuthProviderConfiguration{Enabled: true},
+				MobileAppID:                "wxmobileappid",
+			},
+			err: "missing WeChat mobile application secret",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			_, err := NewWeChatProvider(c.config)
+			if c.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				require.True(t, strings.Contains(err.Error(), c.err), err.Error())
+			}
+		})
+	}
+}
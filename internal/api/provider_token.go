@@ -0,0 +1,170 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/supabase/auth/internal/api/provider"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+	"golang.org/x/oauth2"
+)
+
+// ProviderTokenResponse is a fresh access token of an external provider.
+// The refresh token is never returned, it stays in the store.
+type ProviderTokenResponse struct {
+	Provider    string `json:"provider"`
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type,omitempty"`
+	ExpiresIn   int    `json:"expires_in,omitempty"`
+	ExpiresAt   int64  `json:"expires_at,omitempty"`
+}
+
+// saveProviderToken stores the tokens the provider issued on sign in for the
+// identity of the user.
+func (a *API) saveProviderToken(tx *storage.Connection, user *models.User, providerType string, data *OAuthProviderData) error {
+	config := a.config
+
+	identity, err := models.FindIdentityByIdAndProvider(tx, data.userData.Metadata.Subject, providerType)
+	if err != nil {
+		return err
+	}
+
+	// the identity may have been linked to the user being signed in to
+	if identity.UserID != user.ID {
+		return nil
+	}
+
+	var expiresAt *time.Time
+	if !data.expiry.IsZero() {
+		expiresAt = &data.expiry
+	}
+
+	return models.SaveProviderToken(tx, identity, data.token, data.refreshToken, data.tokenType, expiresAt, config.Security.DBEncryption.EncryptionKeyID, config.Security.DBEncryption.EncryptionKey)
+}
+
+// ProviderTokenGet returns a fresh access token of the provider for the
+// user, refreshing it when it is about to expire. It serves both the
+// authenticated user and the admin endpoints.
+func (a *API) ProviderTokenGet(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+	user := getUser(ctx)
+
+	if !config.External.ProviderTokens.Enabled {
+		return notFoundError(ErrorCodeProviderTokenNotFound, "Provider tokens are not stored")
+	}
+
+	providerType := r.URL.Query().Get("provider")
+	if providerType == "" {
+		return badRequestError(ErrorCodeValidationFailed, "provider is required")
+	}
+
+	var resp *ProviderTokenResponse
+	err := db.Transaction(func(tx *storage.Connection) error {
+		identities, terr := models.FindIdentitiesByUserID(tx, user.ID)
+		if terr != nil {
+			return internalServerError("Database error finding identities").WithInternalError(terr)
+		}
+
+		var identity *models.Identity
+		for _, i := range identities {
+			if i.Provider == providerType {
+				identity = i
+				break
+			}
+		}
+		if identity == nil {
+			return notFoundError(ErrorCodeIdentityNotFound, "User has no identity for this provider")
+		}
+
+		accessToken, token, terr := a.freshProviderToken(r, tx, identity)
+		if terr != nil {
+			return terr
+		}
+
+		resp = &ProviderTokenResponse{
+			Provider:    providerType,
+			AccessToken: accessToken,
+			TokenType:   string(token.TokenType),
+		}
+		if token.ExpiresAt != nil {
+			resp.ExpiresAt = token.ExpiresAt.Unix()
+			resp.ExpiresIn = int(time.Until(*token.ExpiresAt).Seconds())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, resp)
+}
+
+// freshProviderToken returns the decrypted access token stored for the
+// identity, refreshing it first when it is about to expire.
+func (a *API) freshProviderToken(r *http.Request, tx *storage.Connection, identity *models.Identity) (string, *models.ProviderToken, error) {
+	config := a.config
+
+	token, err := models.FindProviderTokenByIdentityID(tx, identity.ID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return "", nil, notFoundError(ErrorCodeProviderTokenNotFound, "No token is stored for this provider, the user needs to sign in with it again")
+		}
+		return "", nil, internalServerError("Database error finding provider token").WithInternalError(err)
+	}
+
+	accessToken, refreshToken, err := token.GetTokens(config.Security.DBEncryption.DecryptionKeys)
+	if err != nil {
+		return "", nil, internalServerError("Error decrypting provider token").WithInternalError(err)
+	}
+
+	if token.ExpiresAt == nil || time.Until(*token.ExpiresAt) >= config.External.ProviderTokens.RefreshMargin {
+		return accessToken, token, nil
+	}
+
+	if refreshToken == "" {
+		return "", nil, unprocessableEntityError(ErrorCodeProviderTokenExpired, "Provider token has expired and can't be refreshed, the user needs to sign in with the provider again")
+	}
+
+	refreshed, err := a.refreshProviderToken(r, identity.Provider, refreshToken)
+	if err != nil {
+		observability.GetLogEntry(r).Entry.WithError(err).WithField("provider", identity.Provider).Warn("Unable to refresh provider token")
+		return "", nil, unprocessableEntityError(ErrorCodeProviderTokenExpired, "Provider token has expired and could not be refreshed, the user needs to sign in with the provider again").WithInternalError(err)
+	}
+
+	var expiresAt *time.Time
+	if !refreshed.Expiry.IsZero() {
+		expiresAt = &refreshed.Expiry
+	}
+
+	if err := token.SetTokens(refreshed.AccessToken, refreshed.RefreshToken, refreshed.TokenType, expiresAt, config.Security.DBEncryption.EncryptionKeyID, config.Security.DBEncryption.EncryptionKey); err != nil {
+		return "", nil, internalServerError("Error encrypting provider token").WithInternalError(err)
+	}
+
+	if err := tx.UpdateOnly(token, "access_token", "refresh_token", "token_type", "expires_at", "updated_at"); err != nil {
+		return "", nil, internalServerError("Database error updating provider token").WithInternalError(err)
+	}
+
+	return refreshed.AccessToken, token, nil
+}
+
+func (a *API) refreshProviderToken(r *http.Request, providerType, refreshToken string) (*oauth2.Token, error) {
+	ctx := r.Context()
+
+	p, err := a.Provider(ctx, providerType, "")
+	if err != nil {
+		return nil, err
+	}
+
+	refreshable, ok := p.(provider.RefreshableProvider)
+	if !ok {
+		return nil, badRequestError(ErrorCodeValidationFailed, "Provider %s does not support refreshing tokens", providerType)
+	}
+
+	// without an access token the token source always refreshes
+	return refreshable.TokenSource(ctx, &oauth2.Token{RefreshToken: refreshToken}).Token()
+}
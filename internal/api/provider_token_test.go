@@ -0,0 +1,248 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+)
+
+type ProviderTokenTestSuite struct {
+	suite.Suite
+	API    *API
+	Config *conf.GlobalConfiguration
+}
+
+func TestProviderToken(t *testing.T) {
+	api, config, err := setupAPIForTest()
+	require.NoError(t, err)
+
+	ts := &ProviderTokenTestSuite{
+		API:    api,
+		Config: config,
+	}
+	defer api.db.Close()
+
+	suite.Run(t, ts)
+}
+
+func (ts *ProviderTokenTestSuite) SetupTest() {
+	models.TruncateAll(ts.API.db)
+
+	ts.Config.External.ProviderTokens.Enabled = true
+	ts.Config.External.ProviderTokens.RefreshMargin = time.Minute
+}
+
+func (ts *ProviderTokenTestSuite) createUserWithToken(expiresAt time.Time, refreshToken string) *models.User {
+	u, err := models.NewUser("", "github@example.com", "", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(u))
+
+	i, err := models.NewIdentity(u, "github", map[string]interface{}{
+		"sub":   "123",
+		"email": u.GetEmail(),
+	})
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(i))
+
+	require.NoError(ts.T(), models.SaveProviderToken(ts.API.db, i, "access-token", refreshToken, "bearer", &expiresAt, ts.Config.Security.DBEncryption.EncryptionKeyID, ts.Config.Security.DBEncryption.EncryptionKey))
+
+	return u
+}
+
+func (ts *ProviderTokenTestSuite) getProviderToken(u *models.User) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/token?grant_type=password", nil)
+	token, _, err := ts.API.generateAccessToken(req, ts.API.db, u, nil, models.OAuth)
+	require.NoError(ts.T(), err)
+
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/user/provider_token?provider=github", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	return w
+}
+
+func (ts *ProviderTokenTestSuite) TestProviderTokenIsEncrypted() {
+	u := ts.createUserWithToken(time.Now().Add(time.Hour), "refresh-token")
+
+	identities, err := models.FindIdentitiesByUserID(ts.API.db, u.ID)
+	require.NoError(ts.T(), err)
+
+	token, err := models.FindProviderTokenByIdentityID(ts.API.db, identities[0].ID)
+	require.NoError(ts.T(), err)
+	require.NotContains(ts.T(), token.AccessToken, "access-token")
+	require.NotContains(ts.T(), string(token.RefreshToken), "refresh-token")
+
+	accessToken, refreshToken, err := token.GetTokens(ts.Config.Security.DBEncryption.DecryptionKeys)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), "access-token", accessToken)
+	require.Equal(ts.T(), "refresh-token", refreshToken)
+}
+
+func (ts *ProviderTokenTestSuite) TestProviderTokenGet() {
+	u := ts.createUserWithToken(time.Now().Add(time.Hour), "refresh-token")
+
+	w := ts.getProviderToken(u)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var resp ProviderTokenResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&resp))
+	require.Equal(ts.T(), "github", resp.Provider)
+	require.Equal(ts.T(), "access-token", resp.AccessToken)
+	require.NotContains(ts.T(), w.Body.String(), "refresh-token")
+}
+
+func (ts *ProviderTokenTestSuite) TestProviderTokenRefresh() {
+	refreshCount := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login/oauth/access_token":
+			refreshCount++
+			ts.Require().NoError(r.ParseForm())
+			ts.Equal("refresh_token", r.PostForm.Get("grant_type"))
+			ts.Equal("refresh-token", r.PostForm.Get("refresh_token"))
+
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token":"new-access-token","refresh_token":"new-refresh-token","token_type":"bearer","expires_in":3600}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			ts.Fail("unknown github oauth call %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	ts.Config.External.Github.URL = server.URL
+	u := ts.createUserWithToken(time.Now().Add(-time.Minute), "refresh-token")
+
+	w := ts.getProviderToken(u)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	require.Equal(ts.T(), 1, refreshCount)
+
+	var resp ProviderTokenResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&resp))
+	require.Equal(ts.T(), "new-access-token", resp.AccessToken)
+	require.Greater(ts.T(), resp.ExpiresIn, 3500)
+
+	// the refreshed token is stored and served until it expires
+	w = ts.getProviderToken(u)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	require.Equal(ts.T(), 1, refreshCount)
+}
+
+func (ts *ProviderTokenTestSuite) TestProviderTokenExpiredWithoutRefreshToken() {
+	u := ts.createUserWithToken(time.Now().Add(-time.Minute), "")
+
+	w := ts.getProviderToken(u)
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+}
+
+func (ts *ProviderTokenTestSuite) syncIdentity(u *models.User, body string) *httptest.ResponseRecorder {
+	identities, err := models.FindIdentitiesByUserID(ts.API.db, u.ID)
+	require.NoError(ts.T(), err)
+
+	req := httptest.NewRequest(http.MethodPost, "/token?grant_type=password", nil)
+	token, _, err := ts.API.generateAccessToken(req, ts.API.db, u, nil, models.OAuth)
+	require.NoError(ts.T(), err)
+
+	req = httptest.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost/user/identities/%s/sync", identities[0].ID), strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	return w
+}
+
+func (ts *ProviderTokenTestSuite) setupGitHubProfile(id int) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts.Equal("Bearer access-token", r.Header.Get("Authorization"))
+
+		w.Header().Add("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v3/user":
+			fmt.Fprintf(w, `{"id":%d,"name":"New Name","avatar_url":"http://example.com/new-avatar"}`, id)
+		case "/api/v3/user/emails":
+			fmt.Fprint(w, `[{"email":"github@example.com","primary":true,"verified":true}]`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			ts.Fail("unknown github call %s", r.URL.Path)
+		}
+	}))
+	ts.T().Cleanup(server.Close)
+
+	ts.Config.External.Github.URL = server.URL
+}
+
+func (ts *ProviderTokenTestSuite) TestIdentitySync() {
+	cases := []struct {
+		desc       string
+		body       string
+		expectName string
+	}{
+		{
+			desc:       "provider wins by default",
+			body:       "",
+			expectName: "New Name",
+		},
+		{
+			desc:       "user wins",
+			body:       `{"on_conflict":"user"}`,
+			expectName: "Old Name",
+		},
+	}
+
+	for _, c := range cases {
+		ts.Run(c.desc, func() {
+			models.TruncateAll(ts.API.db)
+			ts.setupGitHubProfile(123)
+
+			u := ts.createUserWithToken(time.Now().Add(time.Hour), "refresh-token")
+			require.NoError(ts.T(), u.UpdateUserMetaData(ts.API.db, map[string]interface{}{
+				"full_name": "Old Name",
+			}))
+
+			w := ts.syncIdentity(u, c.body)
+			require.Equal(ts.T(), http.StatusOK, w.Code)
+
+			u, err := models.FindUserByID(ts.API.db, u.ID)
+			require.NoError(ts.T(), err)
+			require.Equal(ts.T(), c.expectName, u.UserMetaData["full_name"])
+			require.Equal(ts.T(), "http://example.com/new-avatar", u.UserMetaData["avatar_url"])
+			require.Equal(ts.T(), "github@example.com", u.GetEmail())
+
+			require.Len(ts.T(), u.Identities, 1)
+			require.Equal(ts.T(), "New Name", u.Identities[0].IdentityData["full_name"])
+			require.Equal(ts.T(), "http://example.com/new-avatar", u.Identities[0].IdentityData["avatar_url"])
+		})
+	}
+}
+
+func (ts *ProviderTokenTestSuite) TestIdentitySyncDifferentSubject() {
+	ts.setupGitHubProfile(456)
+
+	u := ts.createUserWithToken(time.Now().Add(time.Hour), "refresh-token")
+
+	w := ts.syncIdentity(u, "")
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+
+	identities, err := models.FindIdentitiesByUserID(ts.API.db, u.ID)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), "123", identities[0].IdentityData["sub"])
+	require.Nil(ts.T(), identities[0].IdentityData["full_name"])
+}
+
+func (ts *ProviderTokenTestSuite) TestIdentitySyncInvalidConflictRule() {
+	u := ts.createUserWithToken(time.Now().Add(time.Hour), "refresh-token")
+
+	w := ts.syncIdentity(u, `{"on_conflict":"nobody"}`)
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/didip/tollbooth/v5"
+	"github.com/didip/tollbooth/v5/limiter"
+	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/ratelimit"
+	"github.com/supabase/auth/internal/utilities"
+)
+
+// rateLimiter limits the events of each key, like the requests of each IP
+// address. They're counted in the rate limit store shared by the instances
+// when there's one, and in memory otherwise, or when the store can't be
+// reached.
+type rateLimiter struct {
+	name   string
+	memory *limiter.Limiter
+	store  ratelimit.Store
+}
+
+// newRateLimiter creates a rate limiter with the rate and burst of the in
+// memory limiter. The name keeps the keys of the limiter apart from the
+// keys of the others in the store.
+func newRateLimiter(name string, lmt *limiter.Limiter, store ratelimit.Store) *rateLimiter {
+	return &rateLimiter{
+		name:   name,
+		memory: lmt,
+		store:  store,
+	}
+}
+
+// allow returns whether another event of the key is allowed, counting it
+// when it is.
+func (l *rateLimiter) allow(ctx context.Context, key string) bool {
+	if l.store != nil {
+		limit := ratelimit.Limit{
+			Rate:  l.memory.GetMax(),
+			Burst: l.memory.GetBurst(),
+		}
+
+		allowed, err := l.store.Allow(ctx, l.name+":"+key, limit)
+		if err == nil {
+			return allowed
+		}
+
+		logrus.WithError(err).WithField("limiter", l.name).Warn("Unable to count rate limit in the store, counting it in memory")
+	}
+
+	return tollbooth.LimitByKeys(l.memory, []string{key}) == nil
+}
+
+// endpointLimiter is the rate limiter of a group of endpoints, with the
+// limit it was created for.
+type endpointLimiter struct {
+	config  conf.EndpointRateLimitConfiguration
+	limiter *rateLimiter
+}
+
+// currentEndpointLimits returns the rate limits of the groups of endpoints,
+// the reloaded ones once the configuration file was reloaded.
+func (a *API) currentEndpointLimits() *conf.EndpointRateLimitsConfiguration {
+	if limits := a.endpointLimits.Load(); limits != nil {
+		return limits
+	}
+
+	return &a.config.RateLimitEndpoints
+}
+
+// ReloadEndpointLimits replaces the rate limits of the groups of endpoints.
+// The limiters of the groups whose limit changed are recreated on their
+// next request, so their counts start over.
+func (a *API) ReloadEndpointLimits(limits *conf.EndpointRateLimitsConfiguration) {
+	a.endpointLimits.Store(limits)
+}
+
+// groupLimiter returns the rate limiter of the group for its limit,
+// creating it when the group has none yet or its limit changed.
+func (a *API) groupLimiter(group string, config *conf.EndpointRateLimitConfiguration) *rateLimiter {
+	a.endpointLimitersMutex.Lock()
+	defer a.endpointLimitersMutex.Unlock()
+
+	if a.endpointLimiters == nil {
+		a.endpointLimiters = make(map[string]*endpointLimiter)
+	}
+
+	if l, ok := a.endpointLimiters[group]; ok && l.config == *config {
+		return l.limiter
+	}
+
+	burst := config.Burst
+	if burst == 0 {
+		burst = max(1, int(config.Requests))
+	}
+
+	lmt := tollbooth.NewLimiter(config.Requests/config.Period.Seconds(), &limiter.ExpirableOptions{
+		DefaultExpirationTTL: config.Period,
+	}).SetBurst(burst)
+
+	rateLimiter := newRateLimiter("group:"+group, lmt, a.rateLimitStore)
+	a.endpointLimiters[group] = &endpointLimiter{
+		config:  *config,
+		limiter: rateLimiter,
+	}
+
+	return rateLimiter
+}
+
+// endpointLimitHandler limits the requests of an endpoint of the group
+// with the rate limit of the group when one is configured, which is shared
+// by all of its endpoints, and with the limit of the endpoint otherwise.
+// Endpoints without a limit of their own pass nil. The limit of the group
+// is read on each request, so reloading it applies to the next requests.
+func (a *API) endpointLimitHandler(group string, endpointLimit middlewareHandler) middlewareHandler {
+	return func(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+		ctx := r.Context()
+
+		config := a.currentEndpointLimits().Group(group)
+		if config == nil || !config.IsEnabled() {
+			if endpointLimit == nil {
+				return ctx, nil
+			}
+
+			return endpointLimit(w, r)
+		}
+
+		key, err := a.rateLimitKey(r, config.Key)
+		if err != nil {
+			return ctx, err
+		}
+
+		if key != "" && !a.groupLimiter(group, config).allow(ctx, key) {
+			return ctx, tooManyRequestsError(ErrorCodeOverRequestRateLimit, "Request rate limit reached")
+		}
+
+		return ctx, nil
+	}
+}
+
+// rateLimitKey returns what the request is counted by. The IP address is
+// the value of the rate limit header when there's one, and requests
+// without it aren't counted, like with the limits of the endpoints.
+func (a *API) rateLimitKey(r *http.Request, key string) (string, error) {
+	ctx := r.Context()
+
+	switch key {
+	case conf.RateLimitKeyUser:
+		if claims := getClaims(ctx); claims != nil && claims.Subject != "" {
+			return "user:" + claims.Subject, nil
+		}
+		if credential := getAdminCredential(ctx); credential != nil {
+			return "user:" + credential.ID.String(), nil
+		}
+
+	case conf.RateLimitKeyDestination:
+		body, err := getBodyBytes(r)
+		if err != nil {
+			return "", internalServerError("Could not read body into byte slice").WithInternalError(err)
+		}
+
+		destination := struct {
+			Email string `json:"email"`
+			Phone string `json:"phone"`
+		}{}
+
+		// requests that aren't JSON are counted by IP, and rejected by
+		// their handlers
+		if json.Unmarshal(body, &destination) == nil {
+			if destination.Email != "" {
+				return "email:" + strings.ToLower(destination.Email), nil
+			}
+			if destination.Phone != "" {
+				return "phone:" + destination.Phone, nil
+			}
+		}
+	}
+
+	if limitHeader := a.config.RateLimitHeader; limitHeader != "" {
+		if value := r.Header.Get(limitHeader); value != "" {
+			return "ip:" + value, nil
+		}
+		return "", nil
+	}
+
+	return "ip:" + utilities.GetIPAddress(r), nil
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/didip/tollbooth/v5"
+	"github.com/didip/tollbooth/v5/limiter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/ratelimit"
+)
+
+type fakeRateLimitStore struct {
+	keys   []string
+	limits []ratelimit.Limit
+	err    error
+}
+
+func (s *fakeRateLimitStore) Allow(ctx context.Context, key string, limit ratelimit.Limit) (bool, error) {
+	s.keys = append(s.keys, key)
+	s.limits = append(s.limits, limit)
+	return len(s.keys) <= 1, s.err
+}
+
+func TestRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	newLimiter := func() *limiter.Limiter {
+		return tollbooth.NewLimiter(1, &limiter.ExpirableOptions{
+			DefaultExpirationTTL: time.Hour,
+		}).SetBurst(2)
+	}
+
+	// limits are counted in the store, with the rate and burst of the in
+	// memory limiter
+	store := &fakeRateLimitStore{}
+	l := newRateLimiter("token", newLimiter(), store)
+	assert.True(t, l.allow(ctx, "127.0.0.1"))
+	assert.False(t, l.allow(ctx, "127.0.0.1"))
+	assert.Equal(t, []string{"token:127.0.0.1", "token:127.0.0.1"}, store.keys)
+	assert.Equal(t, ratelimit.Limit{Rate: 1, Burst: 2}, store.limits[0])
+
+	// and in memory when the store can't be reached
+	l = newRateLimiter("token", newLimiter(), &fakeRateLimitStore{err: errors.New("connection refused")})
+	assert.True(t, l.allow(ctx, "127.0.0.1"))
+	assert.True(t, l.allow(ctx, "127.0.0.1"))
+	assert.False(t, l.allow(ctx, "127.0.0.1"))
+
+	// or without a store
+	l = newRateLimiter("token", newLimiter(), nil)
+	assert.True(t, l.allow(ctx, "127.0.0.1"))
+	assert.True(t, l.allow(ctx, "127.0.0.1"))
+	assert.False(t, l.allow(ctx, "127.0.0.1"))
+}
+
+func TestEndpointLimitHandler(t *testing.T) {
+	a := &API{config: &conf.GlobalConfiguration{
+		RateLimitHeader: "X-Rate-Limit",
+		RateLimitEndpoints: conf.EndpointRateLimitsConfiguration{
+			OTP: conf.EndpointRateLimitConfiguration{Requests: 2, Period: time.Hour, Key: conf.RateLimitKeyDestination},
+		},
+	}}
+
+	request := func(handler middlewareHandler, ip, body string) error {
+		req := httptest.NewRequest(http.MethodPost, "/otp", strings.NewReader(body))
+		req.Header.Set("X-Rate-Limit", ip)
+		_, err := handler(httptest.NewRecorder(), req)
+		return err
+	}
+
+	// the endpoints of the group share its limit, whichever IP addresses
+	// request them
+	otp := a.endpointLimitHandler("otp", nil)
+	magicLink := a.endpointLimitHandler("otp", nil)
+	require.NoError(t, request(otp, "1.1.1.1", `{"email":"victim@example.com"}`))
+	require.NoError(t, request(magicLink, "2.2.2.2", `{"email":"Victim@example.com"}`))
+	require.Error(t, request(otp, "3.3.3.3", `{"email":"victim@example.com"}`))
+	require.NoError(t, request(otp, "3.3.3.3", `{"email":"user@example.com"}`))
+
+	// and the requests without a destination are counted by IP
+	require.NoError(t, request(otp, "4.4.4.4", `{}`))
+	require.NoError(t, request(otp, "4.4.4.4", `{}`))
+	require.Error(t, request(otp, "4.4.4.4", `{}`))
+
+	// groups without a limit keep the limits of their endpoints
+	calls := 0
+	endpointLimit := func(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+		calls++
+		return r.Context(), nil
+	}
+	require.NoError(t, request(a.endpointLimitHandler("token", endpointLimit), "1.1.1.1", `{}`))
+	require.Equal(t, 1, calls)
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, request(a.endpointLimitHandler("admin", nil), "1.1.1.1", `{}`))
+	}
+
+	// reloaded limits apply to the next requests of the handlers, with
+	// counts starting over when the limit of a group changed
+	a.ReloadEndpointLimits(&conf.EndpointRateLimitsConfiguration{
+		OTP:   conf.EndpointRateLimitConfiguration{Requests: 3, Period: time.Hour, Key: conf.RateLimitKeyDestination},
+		Token: conf.EndpointRateLimitConfiguration{Requests: 1, Period: time.Hour, Key: conf.RateLimitKeyIP},
+	})
+	for i := 0; i < 3; i++ {
+		require.NoError(t, request(otp, "3.3.3.3", `{"email":"victim@example.com"}`))
+	}
+	require.Error(t, request(otp, "3.3.3.3", `{"email":"victim@example.com"}`))
+
+	tokenLimit := a.endpointLimitHandler("token", endpointLimit)
+	require.NoError(t, request(tokenLimit, "1.1.1.1", `{}`))
+	require.Error(t, request(tokenLimit, "1.1.1.1", `{}`))
+	require.Equal(t, 1, calls)
+
+	// and groups whose limit was removed keep the limits of their endpoints
+	a.ReloadEndpointLimits(&conf.EndpointRateLimitsConfiguration{})
+	require.NoError(t, request(tokenLimit, "1.1.1.1", `{}`))
+	require.Equal(t, 2, calls)
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/didip/tollbooth/v5"
+	"github.com/didip/tollbooth/v5/limiter"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/ratelimit"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var recipientRateLimitCounter = observability.ObtainMetricCounter("gotrue_recipient_rate_limit_counter", "Number of times the rate limit of an email address or phone number has been triggered")
+
+// recipientLimiter limits the emails and SMS messages sent to each email
+// address and phone number, unlike the IP based limits which can be evaded
+// by rotating IPs, and which throttle all the users behind a shared NAT.
+type recipientLimiter struct {
+	email *rateLimiter
+	phone *rateLimiter
+}
+
+func newRecipientLimiter(config *conf.GlobalConfiguration, store ratelimit.Store) *recipientLimiter {
+	return &recipientLimiter{
+		email: newRecipientChannelLimiter("email_recipient", config.RateLimitEmailRecipient, config.RateLimitEmailRecipientWindow, store),
+		phone: newRecipientChannelLimiter("sms_recipient", config.RateLimitSmsRecipient, config.RateLimitSmsRecipientWindow, store),
+	}
+}
+
+func newRecipientChannelLimiter(name string, limit float64, window time.Duration, store ratelimit.Store) *rateLimiter {
+	if limit <= 0 || window <= 0 {
+		return nil
+	}
+
+	lmt := tollbooth.NewLimiter(limit/window.Seconds(), &limiter.ExpirableOptions{
+		DefaultExpirationTTL: window,
+	}).SetBurst(max(1, int(limit)))
+
+	return newRateLimiter(name, lmt, store)
+}
+
+// allow returns whether a message can be sent to the recipient, counting
+// it when it can.
+func (l *recipientLimiter) allow(ctx context.Context, lmt *rateLimiter, channel, recipient string) bool {
+	if lmt == nil || recipient == "" {
+		return true
+	}
+
+	if !lmt.allow(ctx, strings.ToLower(recipient)) {
+		recipientRateLimitCounter.Add(ctx, 1, metric.WithAttributeSet(attribute.NewSet(attribute.String("channel", channel))))
+		return false
+	}
+
+	return true
+}
+
+// allowEmail returns whether an email can be sent to the address.
+func (l *recipientLimiter) allowEmail(ctx context.Context, address string) bool {
+	return l.allow(ctx, l.email, "email", address)
+}
+
+// allowSMS returns whether an SMS message can be sent to the phone number.
+func (l *recipientLimiter) allowSMS(ctx context.Context, phone string) bool {
+	return l.allow(ctx, l.phone, "sms", phone)
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/supabase/auth/internal/conf"
+)
+
+func TestRecipientLimiter(t *testing.T) {
+	ctx := context.Background()
+
+	l := newRecipientLimiter(&conf.GlobalConfiguration{
+		RateLimitEmailRecipient:       2,
+		RateLimitEmailRecipientWindow: time.Hour,
+	}, nil)
+
+	assert.True(t, l.allowEmail(ctx, "victim@example.com"))
+	assert.True(t, l.allowEmail(ctx, "Victim@example.com"))
+	assert.False(t, l.allowEmail(ctx, "VICTIM@example.com"))
+
+	// other addresses have their own limit
+	assert.True(t, l.allowEmail(ctx, "user@example.com"))
+
+	// phone numbers aren't limited without a limit
+	for i := 0; i < 10; i++ {
+		assert.True(t, l.allowSMS(ctx, "12345678901"))
+	}
+}
@@ -46,13 +46,15 @@ func (a *API) Recover(w http.ResponseWriter, r *http.Request) error {
 	var err error
 	aud := a.requestAud(ctx, r)
 
-	user, err = models.FindUserByEmailAndAudience(db, params.Email, aud)
+	user, err = a.findUserByLoginEmail(db, params.Email, aud)
 	if err != nil {
 		if models.IsNotFoundError(err) {
 			return sendJSON(w, http.StatusOK, map[string]string{})
 		}
 		return internalServerError("Unable to process request").WithInternalError(err)
 	}
+	// recovering with a secondary email address sends the email there
+	user = withRecipientEmail(user, params.Email)
 	if isPKCEFlow(flowType) {
 		if _, err := generateFlowState(db, models.Recovery.String(), models.Recovery, params.CodeChallengeMethod, params.CodeChallenge, &(user.ID)); err != nil {
 			return err
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/conf"
+)
+
+// configReloadInterval is how often the config file is checked for
+// changes.
+const configReloadInterval = 10 * time.Second
+
+// WatchConfig reloads the rate limits of the groups of endpoints from the
+// config file when it changes, so they can be tuned without a restart. The
+// rest of the configuration is only read on startup. Configurations that
+// fail to load are logged, and the current limits are kept.
+func (a *API) WatchConfig(ctx context.Context, filename string) {
+	if filename == "" {
+		return
+	}
+
+	ticker := time.NewTicker(configReloadInterval)
+	defer ticker.Stop()
+
+	modTime := configModTime(filename)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			if current := configModTime(filename); !current.Equal(modTime) {
+				modTime = current
+				a.reloadConfig(filename)
+			}
+		}
+	}
+}
+
+// configModTime returns when the config file was last modified, or the
+// zero time when it can't be read.
+func configModTime(filename string) time.Time {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
+
+func (a *API) reloadConfig(filename string) {
+	config, err := conf.LoadGlobal(filename)
+	if err != nil {
+		logrus.WithError(err).WithField("config", filename).Error("Unable to reload the config file, keeping the current rate limits")
+		return
+	}
+
+	a.ReloadEndpointLimits(&config.RateLimitEndpoints)
+	logrus.WithField("config", filename).Info("Reloaded the rate limits of the groups of endpoints")
+}
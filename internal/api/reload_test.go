@@ -0,0 +1,39 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+)
+
+func TestReloadConfig(t *testing.T) {
+	// loading the config file sets its variables, which are restored once
+	// the test is over
+	t.Setenv("GOTRUE_RATE_LIMIT_ENDPOINTS_OTP_REQUESTS", "0")
+	t.Setenv("GOTRUE_RATE_LIMIT_ENDPOINTS_OTP_KEY", "ip")
+
+	config, err := conf.LoadGlobal(apiTestConfig)
+	require.NoError(t, err)
+
+	source, err := os.ReadFile(apiTestConfig)
+	require.NoError(t, err)
+
+	filename := filepath.Join(t.TempDir(), "test.env")
+	a := &API{config: config}
+
+	// the limits are reloaded from the config file
+	require.NoError(t, os.WriteFile(filename, append(source, "\nGOTRUE_RATE_LIMIT_ENDPOINTS_OTP_REQUESTS=5\nGOTRUE_RATE_LIMIT_ENDPOINTS_OTP_KEY=destination\n"...), 0600))
+	a.reloadConfig(filename)
+	assert.Equal(t, float64(5), a.currentEndpointLimits().OTP.Requests)
+	assert.Equal(t, conf.RateLimitKeyDestination, a.currentEndpointLimits().OTP.Key)
+
+	// and the current ones are kept when it's invalid
+	require.NoError(t, os.WriteFile(filename, append(source, "\nGOTRUE_RATE_LIMIT_ENDPOINTS_OTP_REQUESTS=10\nGOTRUE_RATE_LIMIT_ENDPOINTS_OTP_KEY=country\n"...), 0600))
+	a.reloadConfig(filename)
+	assert.Equal(t, float64(5), a.currentEndpointLimits().OTP.Requests)
+	assert.Equal(t, conf.RateLimitKeyDestination, a.currentEndpointLimits().OTP.Key)
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/revocation"
+)
+
+// PropagateRevocations receives the revocations of sessions and users of
+// the other instances until the context is done, when revocations are
+// propagated.
+func (a *API) PropagateRevocations(ctx context.Context) {
+	if a.revocations == nil {
+		return
+	}
+
+	a.revocations.Run(ctx)
+}
+
+// propagateRevocation denies the access tokens of the revocation on all
+// instances, once it's committed. Errors are only logged, as the sessions
+// are revoked in the database already.
+func (a *API) propagateRevocation(r *http.Request, rev revocation.Revocation) {
+	if a.revocations == nil {
+		return
+	}
+
+	if err := a.revocations.Revoke(r.Context(), rev); err != nil {
+		observability.GetLogEntry(r).Entry.WithError(err).Warn("Unable to propagate revocation")
+	}
+}
+
+// isTokenRevoked reports whether the access token of the claims was
+// revoked on any instance, without reading the database.
+func (a *API) isTokenRevoked(claims *AccessTokenClaims) bool {
+	if a.revocations == nil || claims.IssuedAt == nil {
+		return false
+	}
+
+	userID, err := uuid.FromString(claims.Subject)
+	if err != nil {
+		return false
+	}
+
+	var sessionID *uuid.UUID
+	if id, err := uuid.FromString(claims.SessionId); err == nil && id != uuid.Nil {
+		sessionID = &id
+	}
+
+	return a.revocations.IsDenied(userID, sessionID, claims.IssuedAt.Time)
+}
@@ -0,0 +1,323 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// roleNamePattern matches names of lowercase letters, digits and the
+// separators used in role names, like billing-admin or app:editor.
+var roleNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9_.:-]{0,62})$`)
+
+// permissionPattern matches permissions like posts:write or reports.*.
+var permissionPattern = regexp.MustCompile(`^[a-zA-Z0-9_.:/*-]{1,128}$`)
+
+type RoleParams struct {
+	Name        string    `json:"name"`
+	Description *string   `json:"description"`
+	Permissions *[]string `json:"permissions"`
+}
+
+func (p *RoleParams) validate(forUpdate bool) error {
+	p.Name = strings.ToLower(strings.TrimSpace(p.Name))
+
+	if !forUpdate && p.Name == "" {
+		return badRequestError(ErrorCodeValidationFailed, "name is required")
+	}
+
+	if p.Name != "" && !roleNamePattern.MatchString(p.Name) {
+		return badRequestError(ErrorCodeValidationFailed, "name must be at most 63 lowercase letters, digits, or _ . : - characters, starting with a letter or digit")
+	}
+
+	if p.Description != nil && utf8.RuneCountInString(*p.Description) > 1024 {
+		return badRequestError(ErrorCodeValidationFailed, "description must be at most 1024 characters")
+	}
+
+	if p.Permissions != nil {
+		for _, permission := range *p.Permissions {
+			if !permissionPattern.MatchString(permission) {
+				return badRequestError(ErrorCodeValidationFailed, "Invalid permission %q", permission)
+			}
+		}
+	}
+
+	return nil
+}
+
+type UserRoleParams struct {
+	Role string `json:"role"`
+}
+
+type AdminListRolesResponse struct {
+	Roles []*models.Role `json:"roles"`
+}
+
+// loadRole looks for a role_id parameter in the URL route and loads the
+// role with that ID into the context.
+func (a *API) loadRole(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	roleID, err := uuid.FromString(chi.URLParam(r, "role_id"))
+	if err != nil {
+		return nil, notFoundError(ErrorCodeValidationFailed, "role_id must be an UUID")
+	}
+
+	observability.LogEntrySetField(r, "role_id", roleID)
+
+	role, err := models.FindRoleByID(db, roleID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, notFoundError(ErrorCodeRoleNotFound, "Role not found")
+		}
+		return nil, internalServerError("Database error finding role").WithInternalError(err)
+	}
+
+	return withRole(ctx, role), nil
+}
+
+// checkRoleName returns an error if a role with the name already exists.
+func checkRoleName(db *storage.Connection, name string) error {
+	if _, err := models.FindRoleByName(db, name); err == nil {
+		return badRequestError(ErrorCodeRoleExists, "A role with the name '%s' already exists", name)
+	} else if !models.IsNotFoundError(err) {
+		return internalServerError("Database error finding role").WithInternalError(err)
+	}
+
+	return nil
+}
+
+// adminRolesList lists all roles, ordered by name.
+func (a *API) adminRolesList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	roles, err := models.FindRoles(db)
+	if err != nil {
+		return internalServerError("Database error finding roles").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, AdminListRolesResponse{
+		Roles: roles,
+	})
+}
+
+func (a *API) adminRolesCreate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	params := &RoleParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if err := params.validate(false); err != nil {
+		return err
+	}
+
+	if err := checkRoleName(db, params.Name); err != nil {
+		return err
+	}
+
+	var description string
+	if params.Description != nil {
+		description = *params.Description
+	}
+
+	var permissions []string
+	if params.Permissions != nil {
+		permissions = *params.Permissions
+	}
+
+	role, err := models.NewRole(params.Name, description, permissions)
+	if err != nil {
+		return internalServerError("Error creating role").WithInternalError(err)
+	}
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := tx.Create(role); terr != nil {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, getAdminUser(ctx), models.RoleCreatedAction, "", map[string]interface{}{
+			"role_id":     role.ID,
+			"role_name":   role.Name,
+			"permissions": role.Permissions,
+		})
+	}); err != nil {
+		return internalServerError("Database error creating role").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusCreated, role)
+}
+
+func (a *API) adminRoleGet(w http.ResponseWriter, r *http.Request) error {
+	return sendJSON(w, http.StatusOK, getRole(r.Context()))
+}
+
+// adminRoleUpdate updates the name, description and permissions of the
+// role. The access tokens of its users have the new name and permissions
+// once they're refreshed.
+func (a *API) adminRoleUpdate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	role := getRole(ctx)
+
+	params := &RoleParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if err := params.validate(true); err != nil {
+		return err
+	}
+
+	if params.Name != "" && params.Name != role.Name {
+		if err := checkRoleName(db, params.Name); err != nil {
+			return err
+		}
+
+		role.Name = params.Name
+	}
+
+	if params.Description != nil {
+		role.Description = storage.NullString(*params.Description)
+	}
+
+	if params.Permissions != nil {
+		role.Permissions = *params.Permissions
+	}
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := tx.Update(role); terr != nil {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, getAdminUser(ctx), models.RoleUpdatedAction, "", map[string]interface{}{
+			"role_id":     role.ID,
+			"role_name":   role.Name,
+			"permissions": role.Permissions,
+		})
+	}); err != nil {
+		return internalServerError("Database error updating role").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, role)
+}
+
+// adminRoleDelete deletes the role, which is removed from its users.
+func (a *API) adminRoleDelete(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	role := getRole(ctx)
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := tx.Destroy(role); terr != nil {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, getAdminUser(ctx), models.RoleDeletedAction, "", map[string]interface{}{
+			"role_id":   role.ID,
+			"role_name": role.Name,
+		})
+	}); err != nil {
+		return internalServerError("Database error deleting role").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+// adminUserRolesList lists the roles of the user, ordered by name.
+func (a *API) adminUserRolesList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	roles, err := models.FindRolesForUser(db, getUser(ctx).ID)
+	if err != nil {
+		return internalServerError("Database error finding roles").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, AdminListRolesResponse{
+		Roles: roles,
+	})
+}
+
+// adminUserRoleAssign assigns the role with the name to the user, and
+// returns the roles of the user.
+func (a *API) adminUserRoleAssign(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+
+	params := &UserRoleParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if params.Role == "" {
+		return badRequestError(ErrorCodeValidationFailed, "role is required")
+	}
+
+	role, err := models.FindRoleByName(db, strings.ToLower(strings.TrimSpace(params.Role)))
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return notFoundError(ErrorCodeRoleNotFound, "Role not found")
+		}
+		return internalServerError("Database error finding role").WithInternalError(err)
+	}
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := models.AssignUserRole(tx, user.ID, role.ID); terr != nil {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, getAdminUser(ctx), models.UserRoleAssignedAction, "", map[string]interface{}{
+			"user_id":   user.ID,
+			"role_id":   role.ID,
+			"role_name": role.Name,
+		})
+	}); err != nil {
+		return internalServerError("Database error assigning role").WithInternalError(err)
+	}
+
+	return a.adminUserRolesList(w, r)
+}
+
+// adminUserRoleRemove removes the role with the role_id from the user.
+func (a *API) adminUserRoleRemove(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+	role := getRole(ctx)
+
+	removed := false
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if removed, terr = models.RemoveUserRole(tx, user.ID, role.ID); terr != nil || !removed {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, getAdminUser(ctx), models.UserRoleRemovedAction, "", map[string]interface{}{
+			"user_id":   user.ID,
+			"role_id":   role.ID,
+			"role_name": role.Name,
+		})
+	}); err != nil {
+		return internalServerError("Database error removing role").WithInternalError(err)
+	}
+
+	if !removed {
+		return notFoundError(ErrorCodeRoleNotFound, "User doesn't have the role")
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+type RolesTestSuite struct {
+	suite.Suite
+	API    *API
+	Config *conf.GlobalConfiguration
+
+	adminToken string
+}
+
+func TestRoles(t *testing.T) {
+	api, config, err := setupAPIForTestWithCallback(func(config *conf.GlobalConfiguration, conn *storage.Connection) {
+		if config != nil {
+			config.Roles.Enabled = true
+			config.Roles.IncludePermissions = true
+		}
+	})
+	require.NoError(t, err)
+
+	ts := &RolesTestSuite{
+		API:    api,
+		Config: config,
+	}
+	defer api.db.Close()
+
+	suite.Run(t, ts)
+}
+
+func (ts *RolesTestSuite) SetupTest() {
+	models.TruncateAll(ts.API.db)
+
+	adminToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &AccessTokenClaims{
+		Role: "supabase_admin",
+	}).SignedString([]byte(ts.Config.JWT.Secret))
+	require.NoError(ts.T(), err)
+	ts.adminToken = adminToken
+}
+
+func (ts *RolesTestSuite) request(method, path string, body interface{}) *httptest.ResponseRecorder {
+	var buffer bytes.Buffer
+	if body != nil {
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(body))
+	}
+
+	req := httptest.NewRequest(method, "http://localhost"+path, &buffer)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+ts.adminToken)
+	w := httptest.NewRecorder()
+
+	ts.API.handler.ServeHTTP(w, req)
+
+	return w
+}
+
+func (ts *RolesTestSuite) createRole(name string, permissions []string) *models.Role {
+	w := ts.request(http.MethodPost, "/admin/roles", map[string]interface{}{
+		"name":        name,
+		"permissions": permissions,
+	})
+	require.Equal(ts.T(), http.StatusCreated, w.Code, w.Body.String())
+
+	var role models.Role
+	require.NoError(ts.T(), json.Unmarshal(w.Body.Bytes(), &role))
+	return &role
+}
+
+func (ts *RolesTestSuite) TestAdminRoles() {
+	editor := ts.createRole("Editor", []string{"posts:read", "posts:write"})
+	require.Equal(ts.T(), "editor", editor.Name)
+
+	// names are unique, and permissions are validated
+	require.Equal(ts.T(), http.StatusBadRequest, ts.request(http.MethodPost, "/admin/roles", map[string]interface{}{"name": "editor"}).Code)
+	require.Equal(ts.T(), http.StatusBadRequest, ts.request(http.MethodPost, "/admin/roles", map[string]interface{}{
+		"name":        "viewer",
+		"permissions": []string{"posts read"},
+	}).Code)
+
+	w := ts.request(http.MethodPut, "/admin/roles/"+editor.ID.String(), map[string]interface{}{
+		"description": "Writes posts",
+		"permissions": []string{"posts:read", "posts:write", "posts:publish"},
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	var updated models.Role
+	require.NoError(ts.T(), json.Unmarshal(w.Body.Bytes(), &updated))
+	require.Equal(ts.T(), "editor", updated.Name)
+	require.Equal(ts.T(), "Writes posts", updated.Description.String())
+	require.Len(ts.T(), updated.Permissions, 3)
+
+	w = ts.request(http.MethodGet, "/admin/roles", nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var list AdminListRolesResponse
+	require.NoError(ts.T(), json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(ts.T(), list.Roles, 1)
+
+	require.Equal(ts.T(), http.StatusOK, ts.request(http.MethodDelete, "/admin/roles/"+editor.ID.String(), nil).Code)
+	require.Equal(ts.T(), http.StatusNotFound, ts.request(http.MethodGet, "/admin/roles/"+editor.ID.String(), nil).Code)
+}
+
+func (ts *RolesTestSuite) TestAdminUserRoles() {
+	u, err := models.NewUser("", "test@example.com", "password", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(u))
+
+	ts.createRole("editor", []string{"posts:read", "posts:write"})
+	viewer := ts.createRole("viewer", []string{"posts:read"})
+
+	rolesPath := "/admin/users/" + u.ID.String() + "/roles"
+	require.Equal(ts.T(), http.StatusNotFound, ts.request(http.MethodPost, rolesPath, map[string]interface{}{"role": "owner"}).Code)
+
+	for _, role := range []string{"editor", "viewer", "viewer"} {
+		require.Equal(ts.T(), http.StatusOK, ts.request(http.MethodPost, rolesPath, map[string]interface{}{"role": role}).Code)
+	}
+
+	w := ts.request(http.MethodGet, rolesPath, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var list AdminListRolesResponse
+	require.NoError(ts.T(), json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(ts.T(), list.Roles, 2)
+
+	// access tokens include the roles and the permissions they grant
+	session, err := models.NewSession(u.ID, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(session))
+
+	req := httptest.NewRequest(http.MethodPost, "/token?grant_type=password", nil)
+	token, _, err := ts.API.generateAccessToken(req, ts.API.db, u, &session.ID, models.PasswordGrant)
+	require.NoError(ts.T(), err)
+
+	claims := &AccessTokenClaims{}
+	_, _, err = jwt.NewParser().ParseUnverified(token, claims)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), []string{"editor", "viewer"}, claims.Roles)
+	require.Equal(ts.T(), []string{"posts:read", "posts:write"}, claims.Permissions)
+	require.Equal(ts.T(), "authenticated", claims.Role)
+
+	require.Equal(ts.T(), http.StatusOK, ts.request(http.MethodDelete, rolesPath+"/"+viewer.ID.String(), nil).Code)
+	require.Equal(ts.T(), http.StatusNotFound, ts.request(http.MethodDelete, rolesPath+"/"+viewer.ID.String(), nil).Code)
+}
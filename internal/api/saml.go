@@ -9,6 +9,7 @@ import (
 
 	"github.com/crewjam/saml"
 	"github.com/crewjam/saml/samlsp"
+	"github.com/supabase/auth/internal/conf"
 )
 
 // getSAMLServiceProvider generates a new service provider object with the
@@ -40,20 +41,106 @@ func (a *API) getSAMLServiceProvider(identityProvider *saml.EntityDescriptor, id
 
 	externalURL.Path += "sso/"
 
+	key, certificate := a.config.SAML.ActiveKey()
+
 	provider := samlsp.DefaultServiceProvider(samlsp.Options{
 		URL:               *externalURL,
-		Key:               a.config.SAML.RSAPrivateKey,
-		Certificate:       a.config.SAML.Certificate,
-		SignRequest:       true,
+		Key:               key,
+		Certificate:       certificate,
+		SignRequest:       a.config.SAML.SignAuthnRequests,
 		AllowIDPInitiated: idpInitiated,
 		IDPMetadata:       identityProvider,
 	})
 
+	provider.EntityID = a.config.SAML.EntityID
 	provider.AuthnNameIDFormat = saml.PersistentNameIDFormat
 
+	if len(a.config.SAML.NameIDFormats) > 0 {
+		provider.AuthnNameIDFormat = a.samlNameIDFormats()[0]
+	}
+
 	return &provider
 }
 
+// samlInactiveKeyServiceProvider returns a copy of the service provider using
+// the key that is not used for signing while keys are being rotated, or nil
+// if only one key is configured.
+func (a *API) samlInactiveKeyServiceProvider(serviceProvider *saml.ServiceProvider) *saml.ServiceProvider {
+	key, certificate := a.config.SAML.InactiveKey()
+	if key == nil {
+		return nil
+	}
+
+	inactiveProvider := *serviceProvider
+	inactiveProvider.Key = key
+	inactiveProvider.Certificate = certificate
+
+	return &inactiveProvider
+}
+
+// samlNameIDFormats returns the NameID formats advertised in the metadata,
+// either persistent or email address unless configured.
+func (a *API) samlNameIDFormats() []saml.NameIDFormat {
+	if len(a.config.SAML.NameIDFormats) == 0 {
+		return []saml.NameIDFormat{
+			saml.EmailAddressNameIDFormat,
+			saml.PersistentNameIDFormat,
+		}
+	}
+
+	var formats []saml.NameIDFormat
+	for _, format := range a.config.SAML.NameIDFormats {
+		formats = append(formats, saml.NameIDFormat(conf.SAMLNameIDFormat(format)))
+	}
+
+	return formats
+}
+
+// samlOrganization returns the organization published in the metadata, if
+// configured.
+func (a *API) samlOrganization() *saml.Organization {
+	config := a.config.SAML
+	if config.OrganizationName == "" {
+		return nil
+	}
+
+	displayName := config.OrganizationDisplayName
+	if displayName == "" {
+		displayName = config.OrganizationName
+	}
+
+	return &saml.Organization{
+		OrganizationNames:        []saml.LocalizedName{{Lang: "en", Value: config.OrganizationName}},
+		OrganizationDisplayNames: []saml.LocalizedName{{Lang: "en", Value: displayName}},
+		OrganizationURLs:         []saml.LocalizedURI{{Lang: "en", Value: config.OrganizationURL}},
+	}
+}
+
+// samlContactPerson returns the contact published in the metadata, if
+// configured.
+func (a *API) samlContactPerson() *saml.ContactPerson {
+	config := a.config.SAML
+	if config.ContactName == "" && config.ContactEmail == "" && config.ContactCompany == "" {
+		return nil
+	}
+
+	contact := &saml.ContactPerson{
+		ContactType: config.ContactType,
+		Company:     config.ContactCompany,
+		GivenName:   config.ContactName,
+	}
+
+	if contact.ContactType == "" {
+		contact.ContactType = "technical"
+	}
+
+	if config.ContactEmail != "" {
+		contact.EmailAddresses = []string{"mailto:" + config.ContactEmail}
+	}
+
+	return contact
+}
+
 // SAMLMetadata serves GoTrue's SAML Service Provider metadata file.
 func (a *API) SAMLMetadata(w http.ResponseWriter, r *http.Request) error {
 	serviceProvider := a.getSAMLServiceProvider(nil, true)
@@ -66,18 +153,28 @@ func (a *API) SAMLMetadata(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	for i := range metadata.SPSSODescriptors {
-		// we set this to false since the IdP initiated flow can only
-		// sign the Assertion, and not the full Request
-		// unfortunately this is hardcoded in the crewjam library if
-		// signatures (instead of encryption) are supported
-		// https://github.com/crewjam/saml/blob/v0.4.8/service_provider.go#L217
-		metadata.SPSSODescriptors[i].AuthnRequestsSigned = nil
-
-		// advertize the requested NameID formats (either persistent or email address)
-		metadata.SPSSODescriptors[i].NameIDFormats = []saml.NameIDFormat{
-			saml.EmailAddressNameIDFormat,
-			saml.PersistentNameIDFormat,
+		if !a.config.SAML.SignAuthnRequests || !a.config.SAML.AdvertiseSignedAuthnRequests {
+			// we set this to false since the IdP initiated flow can only
+			// sign the Assertion, and not the full Request
+			// unfortunately this is hardcoded in the crewjam library if
+			// signatures (instead of encryption) are supported
+			// https://github.com/crewjam/saml/blob/v0.4.8/service_provider.go#L217
+			metadata.SPSSODescriptors[i].AuthnRequestsSigned = nil
 		}
+
+		// advertize the requested NameID formats
+		metadata.SPSSODescriptors[i].NameIDFormats = a.samlNameIDFormats()
+	}
+
+	metadata.Organization = a.samlOrganization()
+	metadata.ContactPerson = a.samlContactPerson()
+
+	// both keys are published while they are being rotated, so that
+	// identity providers trust the next key before it's activated and
+	// still trust the previous key until they pick up the change
+	var inactiveKeyDescriptors []saml.KeyDescriptor
+	if inactiveProvider := a.samlInactiveKeyServiceProvider(serviceProvider); inactiveProvider != nil {
+		inactiveKeyDescriptors = inactiveProvider.Metadata().SPSSODescriptors[0].KeyDescriptors
 	}
 
 	for i := range metadata.SPSSODescriptors {
@@ -85,8 +182,15 @@ func (a *API) SAMLMetadata(w http.ResponseWriter, r *http.Request) error {
 
 		var keyDescriptors []saml.KeyDescriptor
 
-		for _, kd := range spd.KeyDescriptors {
+		for _, kd := range append(spd.KeyDescriptors, inactiveKeyDescriptors...) {
 			// only advertize key as usable for encryption if allowed
+			if kd.Use == "encryption" && a.config.SAML.AllowEncryptedAssertions {
+				kd.EncryptionMethods = nil
+				for _, algorithm := range samlEncryptionMethods {
+					kd.EncryptionMethods = append(kd.EncryptionMethods, saml.EncryptionMethod{Algorithm: algorithm})
+				}
+			}
+
 			if kd.Use == "signing" || (a.config.SAML.AllowEncryptedAssertions && kd.Use == "encryption") {
 				keyDescriptors = append(keyDescriptors, kd)
 			}
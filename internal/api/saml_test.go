@@ -4,6 +4,7 @@ import (
 	tst "testing"
 	"time"
 
+	"encoding/base64"
 	"encoding/xml"
 	"net/http"
 	"net/http/httptest"
@@ -13,12 +14,16 @@ import (
 	"github.com/supabase/auth/internal/conf"
 )
 
+const samlTestPrivateKey = "MIIEowIBAAKCAQEAszrVveMQcSsa0Y+zN1ZFb19cRS0jn4UgIHTprW2tVBmO2PABzjY3XFCfx6vPirMAPWBYpsKmXrvm1tr0A6DZYmA8YmJd937VUQ67fa6DMyppBYTjNgGEkEhmKuszvF3MARsIKCGtZqUrmS7UG4404wYxVppnr2EYm3RGtHlkYsXu20MBqSDXP47bQP+PkJqC3BuNGk3xt5UHl2FSFpTHelkI6lBynw16B+lUT1F96SERNDaMqi/TRsZdGe5mB/29ngC/QBMpEbRBLNRir5iUevKS7Pn4aph9Qjaxx/97siktK210FJT23KjHpgcUfjoQ6BgPBTLtEeQdRyDuc/CgfwIDAQABAoIBAGYDWOEpupQPSsZ4mjMnAYJwrp4ZISuMpEqVAORbhspVeb70bLKonT4IDcmiexCg7cQBcLQKGpPVM4CbQ0RFazXZPMVq470ZDeWDEyhoCfk3bGtdxc1Zc9CDxNMs6FeQs6r1beEZug6weG5J/yRn/qYxQife3qEuDMl+lzfl2EN3HYVOSnBmdt50dxRuX26iW3nqqbMRqYn9OHuJ1LvRRfYeyVKqgC5vgt/6Tf7DAJwGe0dD7q08byHV8DBZ0pnMVU0bYpf1GTgMibgjnLjK//EVWafFHtN+RXcjzGmyJrk3+7ZyPUpzpDjO21kpzUQLrpEkkBRnmg6bwHnSrBr8avECgYEA3pq1PTCAOuLQoIm1CWR9/dhkbJQiKTJevlWV8slXQLR50P0WvI2RdFuSxlWmA4xZej8s4e7iD3MYye6SBsQHygOVGc4efvvEZV8/XTlDdyj7iLVGhnEmu2r7AFKzy8cOvXx0QcLg+zNd7vxZv/8D3Qj9Jje2LjLHKM5n/dZ3RzUCgYEAzh5Lo2anc4WN8faLGt7rPkGQF+7/18ImQE11joHWa3LzAEy7FbeOGpE/vhOv5umq5M/KlWFIRahMEQv4RusieHWI19ZLIP+JwQFxWxS+cPp3xOiGcquSAZnlyVSxZ//dlVgaZq2o2MfrxECcovRlaknl2csyf+HjFFwKlNxHm2MCgYAr//R3BdEy0oZeVRndo2lr9YvUEmu2LOihQpWDCd0fQw0ZDA2kc28eysL2RROte95r1XTvq6IvX5a0w11FzRWlDpQ4J4/LlcQ6LVt+98SoFwew+/PWuyLmxLycUbyMOOpm9eSc4wJJZNvaUzMCSkvfMtmm5jgyZYMMQ9A2Ul/9SQKBgB9mfh9mhBwVPIqgBJETZMMXOdxrjI5SBYHGSyJqpT+5Q0vIZLfqPrvNZOiQFzwWXPJ+tV4Mc/YorW3rZOdo6tdvEGnRO6DLTTEaByrY/io3/gcBZXoSqSuVRmxleqFdWWRnB56c1hwwWLqNHU+1671FhL6pNghFYVK4suP6qu4BAoGBAMk+VipXcIlD67mfGrET/xDqiWWBZtgTzTMjTpODhDY1GZck1eb4CQMP5j5V3gFJ4cSgWDJvnWg8rcz0unz/q4aeMGl1rah5WNDWj1QKWMS6vJhMHM/rqN1WHWR0ZnV83svYgtg0zDnQKlLujqW4JmGXLMU7ur6a+e6lpa1fvLsP"
+
+const samlTestNextPrivateKey = "MIIEpAIBAAKCAQEAsnXDDxuzwu0f7cqJ4SqK7W2Z+OfOcCBCu7hPS+QXh8Z+OQvShVgwe9V40TlWAJmgQcFP5t0T7jt94sK1SclRXx7RIjXoUOgtFN0JVW7d1GukmRSzkOxzlk/zKsAICRwuT6nXd/nSMHYxqzEKNu6G7GFikosnH8R8Hg+rsf8yjNAVkbvELAoQ0UVwbNqMk+5Zswbn2/lcGBHEaTSa8y5KhDhiWUYbDqEykhIGITtfBOsKs953lIiCNvIjcggSOTsti93QOWDHTodRQ5OB56z3goh2jPdNcBTLAlM0IkZpAv7hxznbH6KtoozAECDrVgGAVRf38QyqqT2IZSV7bLOVJQIDAQABAoIBAAVg+qyZZqa9s+rCyIeyjkM178adrs5LwqDBOOHUmAfEUII5D3+fMIyC9IqSolUPsrglcsDGfnb9o9kyVvl7qZazqWHRFlWRJRuBLNDxr83BVgUBJ6DHtHvEbbom1V2RumACgmQmccXhKIHi6M5oDEWfcWbX7kMNKCDvbJR6vyDMVeRDNBZshVdRQpcvKeg00ZJgEiYYYdUgYKf6Ms4XjsUV2ED8Nk69JN9m5xySvUbpMPMShnQRWv0x1795yShIUwFMRzuGlUpmvpHh7cfLaU8UeTiAote64obKEqE0SJfI/FUZUARKgDSq4mxHkdSiwNJ9rljHxk122utyuMc1wqECgYEA3NNsBJLdIGUW5C5WUxHCi1RSvHSlAcUp4FEttjYkEpWOX+CzuCqLhQIT0N/PF4SbR1FGJf7OSq8qK8QTCcv3bJLRRNg7gAN8eqKUord45LUQ7MyqaUqlIfZha2xpCovJP587MAViTlMd5fU50C/U/p3aOwnfZ2NqGlgKvX+kINECgYEAzuLLchf5w7FFkFjHjaLXECs/MF8pIJ/jIwv0O7pCIhN4ATJvuBVxXm90F0g1GhHYG6/NI/q2BgDm1TQQPqfiqJ420PLT3H3Gw1CeE/VnmiiXZNFwa/wnokw9SW7DniR5q+Qn8bIM4eZhTAdVgu67mqh+AH6ms2KaSoOoXbGHpBUCgYB70z31zpiaSrUsXGNhj0rkr/L44/uG45UYyMx2KSPPG/fLAYglVA4KFmEgNe+9Q0mDEyrfsWLh9BkUk40NaEckpasdDJdIsCvD5JiuKW1r6bBE6HsCMa4pPivgfdjk5f+CYnpg8hPu0aKpSPoTKE8qOz+c7WtNYSyk+wuCuSlCkQKBgQC2gMEBy/6rz/TSyk53NoBLpire7ANm4UTIhOIV0lBGqTACtlBxbpEMwnsBktQtRDKE8gegRqlOEHuQ9pWiAA3lu3QVzTBx4I53e+WHK8QR7N7otA1dZRo9hM3HuqtcFUc0CFfoUa4llUqdfBbGIv1iCGLm4tMBiekb9JqQ1GwYpQKBgQCzO0F57EFV0BqZrMwwVLL57qi5ypX9z3ohzh72wGgBkpAM5qxH3D6JfJKEZgVoKEARf1EeaBI1I6ZRQ3LW5cWjg3IBlOSQ/sO5Qh5NmfslAFFk1SfHrLHAnq+4L1RBe9moLKC8H9N0bT9PaHSPiBit4pQsRNuDdaVqdbg71GcfRw=="
+
 func TestSAMLMetadataWithAPI(t *tst.T) {
 	config, err := conf.LoadGlobal(apiTestConfig)
 	require.NoError(t, err)
 	config.API.ExternalURL = "https://projectref.supabase.co/auth/v1/"
 	config.SAML.Enabled = true
-	config.SAML.PrivateKey = "MIIEowIBAAKCAQEAszrVveMQcSsa0Y+zN1ZFb19cRS0jn4UgIHTprW2tVBmO2PABzjY3XFCfx6vPirMAPWBYpsKmXrvm1tr0A6DZYmA8YmJd937VUQ67fa6DMyppBYTjNgGEkEhmKuszvF3MARsIKCGtZqUrmS7UG4404wYxVppnr2EYm3RGtHlkYsXu20MBqSDXP47bQP+PkJqC3BuNGk3xt5UHl2FSFpTHelkI6lBynw16B+lUT1F96SERNDaMqi/TRsZdGe5mB/29ngC/QBMpEbRBLNRir5iUevKS7Pn4aph9Qjaxx/97siktK210FJT23KjHpgcUfjoQ6BgPBTLtEeQdRyDuc/CgfwIDAQABAoIBAGYDWOEpupQPSsZ4mjMnAYJwrp4ZISuMpEqVAORbhspVeb70bLKonT4IDcmiexCg7cQBcLQKGpPVM4CbQ0RFazXZPMVq470ZDeWDEyhoCfk3bGtdxc1Zc9CDxNMs6FeQs6r1beEZug6weG5J/yRn/qYxQife3qEuDMl+lzfl2EN3HYVOSnBmdt50dxRuX26iW3nqqbMRqYn9OHuJ1LvRRfYeyVKqgC5vgt/6Tf7DAJwGe0dD7q08byHV8DBZ0pnMVU0bYpf1GTgMibgjnLjK//EVWafFHtN+RXcjzGmyJrk3+7ZyPUpzpDjO21kpzUQLrpEkkBRnmg6bwHnSrBr8avECgYEA3pq1PTCAOuLQoIm1CWR9/dhkbJQiKTJevlWV8slXQLR50P0WvI2RdFuSxlWmA4xZej8s4e7iD3MYye6SBsQHygOVGc4efvvEZV8/XTlDdyj7iLVGhnEmu2r7AFKzy8cOvXx0QcLg+zNd7vxZv/8D3Qj9Jje2LjLHKM5n/dZ3RzUCgYEAzh5Lo2anc4WN8faLGt7rPkGQF+7/18ImQE11joHWa3LzAEy7FbeOGpE/vhOv5umq5M/KlWFIRahMEQv4RusieHWI19ZLIP+JwQFxWxS+cPp3xOiGcquSAZnlyVSxZ//dlVgaZq2o2MfrxECcovRlaknl2csyf+HjFFwKlNxHm2MCgYAr//R3BdEy0oZeVRndo2lr9YvUEmu2LOihQpWDCd0fQw0ZDA2kc28eysL2RROte95r1XTvq6IvX5a0w11FzRWlDpQ4J4/LlcQ6LVt+98SoFwew+/PWuyLmxLycUbyMOOpm9eSc4wJJZNvaUzMCSkvfMtmm5jgyZYMMQ9A2Ul/9SQKBgB9mfh9mhBwVPIqgBJETZMMXOdxrjI5SBYHGSyJqpT+5Q0vIZLfqPrvNZOiQFzwWXPJ+tV4Mc/YorW3rZOdo6tdvEGnRO6DLTTEaByrY/io3/gcBZXoSqSuVRmxleqFdWWRnB56c1hwwWLqNHU+1671FhL6pNghFYVK4suP6qu4BAoGBAMk+VipXcIlD67mfGrET/xDqiWWBZtgTzTMjTpODhDY1GZck1eb4CQMP5j5V3gFJ4cSgWDJvnWg8rcz0unz/q4aeMGl1rah5WNDWj1QKWMS6vJhMHM/rqN1WHWR0ZnV83svYgtg0zDnQKlLujqW4JmGXLMU7ur6a+e6lpa1fvLsP"
+	config.SAML.PrivateKey = samlTestPrivateKey
 	config.API.MaxRequestDuration = 5 * time.Second
 
 	require.NoError(t, config.ApplyDefaults())
@@ -57,3 +62,158 @@ func TestSAMLMetadataWithAPI(t *tst.T) {
 	require.Equal(t, metadata.SPSSODescriptors[0].NameIDFormats[0], saml.EmailAddressNameIDFormat)
 	require.Equal(t, metadata.SPSSODescriptors[0].NameIDFormats[1], saml.PersistentNameIDFormat)
 }
+
+func TestSAMLMetadataCustomized(t *tst.T) {
+	config, err := conf.LoadGlobal(apiTestConfig)
+	require.NoError(t, err)
+	config.API.ExternalURL = "https://projectref.supabase.co/auth/v1/"
+	config.SAML.Enabled = true
+	config.SAML.PrivateKey = samlTestPrivateKey
+	config.SAML.EntityID = "https://auth.example.com/saml"
+	config.SAML.NameIDFormats = []string{"emailAddress", "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"}
+	config.SAML.AdvertiseSignedAuthnRequests = true
+	config.SAML.OrganizationName = "Example"
+	config.SAML.OrganizationURL = "https://example.com"
+	config.SAML.ContactName = "Example Support"
+	config.SAML.ContactEmail = "support@example.com"
+	config.SAML.ContactType = "support"
+	config.API.MaxRequestDuration = 5 * time.Second
+
+	require.NoError(t, config.ApplyDefaults())
+	require.NoError(t, config.SAML.Validate())
+	require.NoError(t, config.SAML.PopulateFields(config.API.ExternalURL))
+
+	api := NewAPI(config, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/sso/saml/metadata", nil)
+
+	w := httptest.NewRecorder()
+	api.handler.ServeHTTP(w, req)
+	require.Equal(t, w.Code, http.StatusOK)
+
+	metadata := saml.EntityDescriptor{}
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &metadata))
+
+	require.Equal(t, "https://auth.example.com/saml", metadata.EntityID)
+	require.Equal(t, len(metadata.SPSSODescriptors), 1)
+
+	require.True(t, *(metadata.SPSSODescriptors[0].AuthnRequestsSigned))
+	require.Equal(t, []saml.NameIDFormat{saml.EmailAddressNameIDFormat, saml.UnspecifiedNameIDFormat}, metadata.SPSSODescriptors[0].NameIDFormats)
+
+	require.NotNil(t, metadata.Organization)
+	require.Equal(t, "Example", metadata.Organization.OrganizationNames[0].Value)
+	require.Equal(t, "Example", metadata.Organization.OrganizationDisplayNames[0].Value)
+	require.Equal(t, "https://example.com", metadata.Organization.OrganizationURLs[0].Value)
+
+	require.NotNil(t, metadata.ContactPerson)
+	require.Equal(t, "support", metadata.ContactPerson.ContactType)
+	require.Equal(t, "Example Support", metadata.ContactPerson.GivenName)
+	require.Equal(t, []string{"mailto:support@example.com"}, metadata.ContactPerson.EmailAddresses)
+
+	sp := api.getSAMLServiceProvider(nil, false)
+	require.Equal(t, saml.EmailAddressNameIDFormat, sp.AuthnNameIDFormat)
+	require.NotEmpty(t, sp.SignatureMethod)
+
+	config.SAML.SignAuthnRequests = false
+	sp = api.getSAMLServiceProvider(nil, false)
+	require.Empty(t, sp.SignatureMethod)
+}
+
+func TestSAMLMetadataEncryption(t *tst.T) {
+	config, err := conf.LoadGlobal(apiTestConfig)
+	require.NoError(t, err)
+	config.API.ExternalURL = "https://projectref.supabase.co/auth/v1/"
+	config.SAML.Enabled = true
+	config.SAML.PrivateKey = samlTestPrivateKey
+	config.SAML.AllowEncryptedAssertions = true
+	config.API.MaxRequestDuration = 5 * time.Second
+
+	require.NoError(t, config.ApplyDefaults())
+	require.NoError(t, config.SAML.PopulateFields(config.API.ExternalURL))
+
+	api := NewAPI(config, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/sso/saml/metadata", nil)
+
+	w := httptest.NewRecorder()
+	api.handler.ServeHTTP(w, req)
+	require.Equal(t, w.Code, http.StatusOK)
+
+	metadata := saml.EntityDescriptor{}
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &metadata))
+
+	keyDescriptors := metadata.SPSSODescriptors[0].KeyDescriptors
+	require.Equal(t, len(keyDescriptors), 2)
+	require.Equal(t, keyDescriptors[0].Use, "encryption")
+	require.Equal(t, keyDescriptors[1].Use, "signing")
+
+	var algorithms []string
+	for _, method := range keyDescriptors[0].EncryptionMethods {
+		algorithms = append(algorithms, method.Algorithm)
+	}
+	require.Equal(t, samlEncryptionMethods, algorithms)
+}
+
+func TestSAMLMetadataKeyRotation(t *tst.T) {
+	config, err := conf.LoadGlobal(apiTestConfig)
+	require.NoError(t, err)
+	config.API.ExternalURL = "https://projectref.supabase.co/auth/v1/"
+	config.SAML.Enabled = true
+	config.SAML.PrivateKey = samlTestPrivateKey
+	config.SAML.NextPrivateKey = samlTestNextPrivateKey
+	config.SAML.AllowEncryptedAssertions = true
+	config.API.MaxRequestDuration = 5 * time.Second
+
+	require.NoError(t, config.ApplyDefaults())
+	require.NoError(t, config.SAML.PopulateFields(config.API.ExternalURL))
+
+	currentCertificate := base64.StdEncoding.EncodeToString(config.SAML.Certificate.Raw)
+	nextCertificate := base64.StdEncoding.EncodeToString(config.SAML.NextCertificate.Raw)
+
+	for _, activateNextKey := range []bool{false, true} {
+		config.SAML.ActivateNextKey = activateNextKey
+
+		api := NewAPI(config, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "http://localhost/sso/saml/metadata", nil)
+
+		w := httptest.NewRecorder()
+		api.handler.ServeHTTP(w, req)
+		require.Equal(t, w.Code, http.StatusOK)
+
+		metadata := saml.EntityDescriptor{}
+		require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &metadata))
+
+		var certificates []string
+		for _, keyDescriptor := range metadata.SPSSODescriptors[0].KeyDescriptors {
+			certificates = append(certificates, keyDescriptor.Use+" "+keyDescriptor.KeyInfo.X509Data.X509Certificates[0].Data)
+		}
+
+		serviceProvider := api.getSAMLServiceProvider(nil, false)
+		inactiveProvider := api.samlInactiveKeyServiceProvider(serviceProvider)
+		require.NotNil(t, inactiveProvider)
+
+		// the active key is published first
+		if activateNextKey {
+			require.Equal(t, []string{
+				"encryption " + nextCertificate,
+				"signing " + nextCertificate,
+				"encryption " + currentCertificate,
+				"signing " + currentCertificate,
+			}, certificates)
+
+			require.Equal(t, config.SAML.NextRSAPrivateKey, serviceProvider.Key)
+			require.Equal(t, config.SAML.RSAPrivateKey, inactiveProvider.Key)
+		} else {
+			require.Equal(t, []string{
+				"encryption " + currentCertificate,
+				"signing " + currentCertificate,
+				"encryption " + nextCertificate,
+				"signing " + nextCertificate,
+			}, certificates)
+
+			require.Equal(t, config.SAML.RSAPrivateKey, serviceProvider.Key)
+			require.Equal(t, config.SAML.NextRSAPrivateKey, inactiveProvider.Key)
+		}
+	}
+}
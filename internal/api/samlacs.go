@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"net/http"
 	"net/url"
 	"time"
@@ -16,7 +17,6 @@ import (
 	"github.com/supabase/auth/internal/models"
 	"github.com/supabase/auth/internal/observability"
 	"github.com/supabase/auth/internal/storage"
-	"github.com/supabase/auth/internal/utilities"
 )
 
 func (a *API) samlDestroyRelayState(ctx context.Context, relayState *models.SAMLRelayState) error {
@@ -45,14 +45,7 @@ func IsSAMLMetadataStale(idpMetadata *saml.EntityDescriptor, samlProvider models
 
 func (a *API) SamlAcs(w http.ResponseWriter, r *http.Request) error {
 	if err := a.handleSamlAcs(w, r); err != nil {
-		u, uerr := url.Parse(a.config.SiteURL)
-		if uerr != nil {
-			return internalServerError("site url is improperly formattted").WithInternalError(err)
-		}
-
-		q := getErrorQueryString(err, utilities.GetRequestID(r.Context()), observability.GetLogEntry(r).Entry, u.Query())
-		u.RawQuery = q.Encode()
-		http.Redirect(w, r, u.String(), http.StatusSeeOther)
+		return a.redirectSSOError(w, r, err)
 	}
 	return nil
 }
@@ -62,7 +55,6 @@ func (a *API) handleSamlAcs(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 
 	db := a.db.WithContext(ctx)
-	config := a.config
 	log := observability.GetLogEntry(r).Entry
 
 	relayStateValue := r.FormValue("RelayState")
@@ -72,6 +64,7 @@ func (a *API) handleSamlAcs(w http.ResponseWriter, r *http.Request) error {
 	entityId := ""
 	initiatedBy := ""
 	redirectTo := ""
+	unsolicitedInResponseTo := ""
 	var requestIds []string
 
 	var flowState *models.FlowState
@@ -141,6 +134,7 @@ func (a *API) handleSamlAcs(w http.ResponseWriter, r *http.Request) error {
 
 		initiatedBy = "idp"
 		entityId = peekResponse.Issuer.Value
+		unsolicitedInResponseTo = peekResponse.InResponseTo
 		redirectTo = relayStateValue
 	} else {
 		// RelayState can't be identified, so SAML flow can't continue
@@ -154,6 +148,11 @@ func (a *API) handleSamlAcs(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	assertionPolicy := &ssoProvider.SAMLProvider.AssertionPolicy
+	if initiatedBy == "idp" && !assertionPolicy.IDPInitiatedAllowed() {
+		return unprocessableEntityError(ErrorCodeSAMLIdPInitiatedNotAllowed, "This Identity Provider only allows signing in from this application, not from the Identity Provider")
+	}
+
 	idpMetadata, err := ssoProvider.SAMLProvider.EntityDescriptor()
 	if err != nil {
 		return err
@@ -186,7 +185,22 @@ func (a *API) handleSamlAcs(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	serviceProvider := a.getSAMLServiceProvider(idpMetadata, initiatedBy == "idp")
-	spAssertion, err := serviceProvider.ParseResponse(r, requestIds)
+
+	var spAssertion *saml.Assertion
+	if artifact := r.FormValue("SAMLart"); artifact != "" {
+		spAssertion, err = a.samlParseArtifactResponse(ctx, serviceProvider, artifact, requestIds)
+	} else {
+		spAssertion, err = serviceProvider.ParseResponse(r, requestIds)
+		if err != nil {
+			// the assertion may be encrypted for the other key while keys
+			// are being rotated
+			if inactiveProvider := a.samlInactiveKeyServiceProvider(serviceProvider); inactiveProvider != nil {
+				if inactiveAssertion, inactiveErr := inactiveProvider.ParseResponse(r, requestIds); inactiveErr == nil {
+					spAssertion, err = inactiveAssertion, nil
+				}
+			}
+		}
+	}
 	if err != nil {
 		if ire, ok := err.(*saml.InvalidResponseError); ok {
 			return badRequestError(ErrorCodeValidationFailed, "SAML Assertion is not valid").WithInternalError(ire.PrivateErr)
@@ -195,6 +209,30 @@ func (a *API) handleSamlAcs(w http.ResponseWriter, r *http.Request) error {
 		return badRequestError(ErrorCodeValidationFailed, "SAML Assertion is not valid").WithInternalError(err)
 	}
 
+	now := a.Now()
+	maxClockSkew := a.samlMaxClockSkew(&ssoProvider.SAMLProvider)
+
+	if err := validateSAMLAssertionTimes(spAssertion, maxClockSkew, now); err != nil {
+		return badRequestError(ErrorCodeValidationFailed, "SAML Assertion is not valid").WithInternalError(err)
+	}
+
+	if initiatedBy == "idp" && assertionPolicy.RejectUnsolicitedInResponseTo {
+		if unsolicitedInResponseTo != "" || samlAssertionInResponseTo(spAssertion) != "" {
+			return badRequestError(ErrorCodeValidationFailed, "SAML Assertion is not valid").WithInternalError(errors.New("IdP initiated response has an InResponseTo"))
+		}
+	}
+
+	if spAssertion.ID == "" {
+		return badRequestError(ErrorCodeValidationFailed, "SAML Assertion is not valid").WithInternalError(errors.New("assertion has no ID"))
+	}
+
+	expiresAt := samlAssertionReplayExpiry(spAssertion, maxClockSkew, a.samlReplayWindow(&ssoProvider.SAMLProvider), now)
+	if recorded, err := a.samlReplayCache.Record(ctx, ssoProvider.ID, spAssertion.ID, now, expiresAt); err != nil {
+		return internalServerError("Unable to check SAML Assertion for replays").WithInternalError(err)
+	} else if !recorded {
+		return unprocessableEntityError(ErrorCodeSAMLAssertionReplayed, "SAML Assertion has already been used, try logging in again?")
+	}
+
 	assertion := SAMLAssertion{
 		spAssertion,
 	}
@@ -218,6 +256,12 @@ func (a *API) handleSamlAcs(w http.ResponseWriter, r *http.Request) error {
 		claims["email"] = email
 	}
 
+	// the provisioning rules can reference all mapped attributes
+	attributes := make(map[string]interface{}, len(claims))
+	for key, value := range claims {
+		attributes[key] = value
+	}
+
 	jsonClaims, err := json.Marshal(claims)
 	if err != nil {
 		return internalServerError("Mapped claims from provider could not be serialized into JSON").WithInternalError(err)
@@ -273,55 +317,38 @@ func (a *API) handleSamlAcs(w http.ResponseWriter, r *http.Request) error {
 		grantParams.SessionNotAfter = &notAfter
 	}
 
-	var token *AccessTokenResponse
+	if authnContextClassRef := assertion.AuthnContextClassRef(); authnContextClassRef != "" {
+		grantParams.AuthnContextClassRef = &authnContextClassRef
+		grantParams.AAL = ssoProvider.SAMLProvider.AuthnRequestPolicy.AAL(authnContextClassRef)
+	}
+
 	if samlMetadataModified {
 		if err := db.UpdateColumns(&ssoProvider.SAMLProvider, "metadata_xml", "updated_at"); err != nil {
 			return err
 		}
 	}
 
-	if err := db.Transaction(func(tx *storage.Connection) error {
-		var terr error
-		var user *models.User
+	return a.completeSSOSignIn(w, r, ssoProvider, &userProvidedData, attributes, models.SSOSAML, grantParams, flowState, redirectTo, func(tx *storage.Connection, user *models.User) error {
+		if ssoProvider.SAMLProvider.SingleLogoutEnabled && assertion.Subject != nil && assertion.Subject.NameID != nil && assertion.Subject.NameID.Value != "" {
+			samlSession := &models.SAMLSession{
+				UserID:        user.ID,
+				SSOProviderID: ssoProvider.ID,
+				NameID:        assertion.Subject.NameID.Value,
+			}
 
-		// accounts potentially created via SAML can contain non-unique email addresses in the auth.users table
-		if user, terr = a.createAccountFromExternalIdentity(tx, r, &userProvidedData, "sso:"+ssoProvider.ID.String()); terr != nil {
-			return terr
-		}
-		if flowState != nil {
-			// This means that the callback is using PKCE
-			flowState.UserID = &(user.ID)
-			if terr := tx.Update(flowState); terr != nil {
-				return terr
+			if assertion.Subject.NameID.Format != "" {
+				samlSession.NameIDFormat = &assertion.Subject.NameID.Format
 			}
-		}
 
-		token, terr = a.issueRefreshToken(r, tx, user, models.SSOSAML, grantParams)
+			if sessionIndex := assertion.SessionIndex(); sessionIndex != "" {
+				samlSession.SessionIndex = &sessionIndex
+			}
 
-		if terr != nil {
-			return internalServerError("Unable to issue refresh token from SAML Assertion").WithInternalError(terr)
+			if terr := tx.Create(samlSession); terr != nil {
+				return internalServerError("Unable to record SAML session").WithInternalError(terr)
+			}
 		}
 
 		return nil
-	}); err != nil {
-		return err
-	}
-
-	if !utilities.IsRedirectURLValid(config, redirectTo) {
-		redirectTo = config.SiteURL
-	}
-	if flowState != nil {
-		// This means that the callback is using PKCE
-		// Set the flowState.AuthCode to the query param here
-		redirectTo, err = a.prepPKCERedirectURL(redirectTo, flowState.AuthCode)
-		if err != nil {
-			return err
-		}
-		http.Redirect(w, r, redirectTo, http.StatusFound)
-		return nil
-
-	}
-	http.Redirect(w, r, token.AsRedirectURL(redirectTo, url.Values{}), http.StatusFound)
-
-	return nil
+	})
 }
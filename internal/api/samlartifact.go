@@ -0,0 +1,100 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/crewjam/saml"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+// samlArtifactResolutionTimeout limits how long the identity provider has to
+// resolve an artifact, as the user is waiting on the response.
+const samlArtifactResolutionTimeout = 10 * time.Second
+
+// samlArtifactResponseMaxSize limits the size of the SOAP responses of the
+// artifact resolution service.
+const samlArtifactResponseMaxSize = 1 << 20
+
+var samlArtifactHTTPClient = &http.Client{
+	Timeout: samlArtifactResolutionTimeout,
+}
+
+// samlParseArtifactResponse resolves the artifact sent by the identity
+// provider with the HTTP-Artifact binding over the SOAP back-channel, and
+// returns the validated assertion of the response.
+func (a *API) samlParseArtifactResponse(ctx context.Context, serviceProvider *saml.ServiceProvider, artifact string, requestIDs []string) (*saml.Assertion, error) {
+	location := serviceProvider.GetArtifactBindingLocation(saml.SOAPBinding)
+	if location == "" {
+		return nil, errors.New("saml: identity provider has no SOAP ArtifactResolutionService")
+	}
+
+	// identity providers authenticate the back-channel with the signature
+	// of the request, so it's signed even if authentication requests are not
+	signingProvider := *serviceProvider
+	if signingProvider.SignatureMethod == "" {
+		signingProvider.SignatureMethod = dsig.RSASHA256SignatureMethod
+	}
+
+	artifactResolve, err := signingProvider.MakeArtifactResolveRequest(artifact)
+	if err != nil {
+		return nil, fmt.Errorf("saml: unable to create ArtifactResolve request: %w", err)
+	}
+
+	doc := etree.NewDocument()
+	doc.SetRoot(artifactResolve.SoapRequest())
+
+	requestBody, err := doc.WriteToBytes()
+	if err != nil {
+		return nil, fmt.Errorf("saml: unable to serialize ArtifactResolve request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, location, bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("saml: unable to create artifact resolution request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+	req.Header.Set("SOAPAction", "http://www.oasis-open.org/committees/security")
+
+	resp, err := samlArtifactHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("saml: unable to resolve artifact: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("saml: artifact resolution service responded with HTTP status %d", resp.StatusCode)
+	}
+
+	responseBody, err := io.ReadAll(io.LimitReader(resp.Body, samlArtifactResponseMaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("saml: unable to read artifact resolution response: %w", err)
+	}
+
+	if len(responseBody) > samlArtifactResponseMaxSize {
+		return nil, errors.New("saml: artifact resolution response is too large")
+	}
+
+	// artifacts can only be resolved once, so the response is parsed with
+	// the inactive key too while keys are being rotated instead of
+	// resolving the artifact again
+	assertion, err := serviceProvider.ParseXMLArtifactResponse(responseBody, requestIDs, artifactResolve.ID)
+	if err != nil {
+		if inactiveProvider := a.samlInactiveKeyServiceProvider(serviceProvider); inactiveProvider != nil {
+			if inactiveAssertion, inactiveErr := inactiveProvider.ParseXMLArtifactResponse(responseBody, requestIDs, artifactResolve.ID); inactiveErr == nil {
+				return inactiveAssertion, nil
+			}
+		}
+
+		return nil, err
+	}
+
+	return assertion, nil
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	tst "testing"
+
+	"github.com/beevik/etree"
+	"github.com/crewjam/saml"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+)
+
+func TestSAMLParseArtifactResponse(t *tst.T) {
+	config, err := conf.LoadGlobal(apiTestConfig)
+	require.NoError(t, err)
+	config.API.ExternalURL = "https://projectref.supabase.co/auth/v1/"
+	config.SAML.Enabled = true
+	config.SAML.PrivateKey = samlTestPrivateKey
+
+	require.NoError(t, config.ApplyDefaults())
+	require.NoError(t, config.SAML.PopulateFields(config.API.ExternalURL))
+
+	api := &API{config: config}
+
+	var artifactResolve *etree.Element
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Contains(t, r.Header.Get("Content-Type"), "text/xml")
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		doc := etree.NewDocument()
+		require.NoError(t, doc.ReadFromBytes(body))
+		artifactResolve = doc.FindElement("//ArtifactResolve")
+
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	idpMetadata := &saml.EntityDescriptor{
+		EntityID: "https://idp.example.com",
+		IDPSSODescriptors: []saml.IDPSSODescriptor{
+			{
+				ArtifactResolutionServices: []saml.Endpoint{
+					{
+						Binding:  saml.SOAPBinding,
+						Location: server.URL,
+					},
+				},
+			},
+		},
+	}
+
+	require.True(t, hasSAMLArtifactResolutionService(idpMetadata))
+
+	serviceProvider := api.getSAMLServiceProvider(idpMetadata, false)
+
+	_, err = api.samlParseArtifactResponse(context.Background(), serviceProvider, "AAQAAMFbLinlXaCM+FIxiDwGOLAy2T71gbpO7ZhNzAgEANlB90ECfpNEVLg=", []string{"request-id"})
+	require.ErrorContains(t, err, "HTTP status 500")
+
+	// the ArtifactResolve request is always signed
+	require.NotNil(t, artifactResolve)
+	require.Equal(t, "AAQAAMFbLinlXaCM+FIxiDwGOLAy2T71gbpO7ZhNzAgEANlB90ECfpNEVLg=", artifactResolve.FindElement("./Artifact").Text())
+	require.NotNil(t, artifactResolve.FindElement("./Signature"))
+
+	// identity providers without an artifact resolution service can't
+	// resolve artifacts
+	idpMetadata.IDPSSODescriptors[0].ArtifactResolutionServices = nil
+	require.False(t, hasSAMLArtifactResolutionService(idpMetadata))
+
+	_, err = api.samlParseArtifactResponse(context.Background(), api.getSAMLServiceProvider(idpMetadata, false), "AAQAAMFbLinlXaCM+FIxiDwGOLAy2T71gbpO7ZhNzAgEANlB90ECfpNEVLg=", []string{"request-id"})
+	require.ErrorContains(t, err, "no SOAP ArtifactResolutionService")
+}
@@ -186,3 +186,27 @@ func (a *SAMLAssertion) NotAfter() time.Time {
 
 	return notOnOrAfter
 }
+
+// SessionIndex extracts the index of the session at the identity provider
+// this assertion was issued for, used in Single Logout.
+func (a *SAMLAssertion) SessionIndex() string {
+	for _, statement := range a.AuthnStatements {
+		if statement.SessionIndex != "" {
+			return statement.SessionIndex
+		}
+	}
+
+	return ""
+}
+
+// AuthnContextClassRef extracts how the identity provider authenticated the
+// user, for example with a password or multiple factors.
+func (a *SAMLAssertion) AuthnContextClassRef() string {
+	for _, statement := range a.AuthnStatements {
+		if statement.AuthnContext.AuthnContextClassRef != nil && statement.AuthnContext.AuthnContextClassRef.Value != "" {
+			return statement.AuthnContext.AuthnContextClassRef.Value
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rsa"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/beevik/etree"
+	"github.com/crewjam/saml/xmlenc"
+)
+
+// Identity providers such as Microsoft Entra ID, AD FS and Okta encrypt
+// assertions with algorithms from XML Encryption 1.1, which the xmlenc
+// package of crewjam/saml doesn't implement. They are registered here so
+// EncryptedAssertion elements using them can be decrypted with the SP key.
+
+const (
+	xmlencAES128CBC = "http://www.w3.org/2001/04/xmlenc#aes128-cbc"
+	xmlencAES256CBC = "http://www.w3.org/2001/04/xmlenc#aes256-cbc"
+	xmlencAES128GCM = "http://www.w3.org/2009/xmlenc11#aes128-gcm"
+	xmlencAES192GCM = "http://www.w3.org/2009/xmlenc11#aes192-gcm"
+	xmlencAES256GCM = "http://www.w3.org/2009/xmlenc11#aes256-gcm"
+
+	xmlencRSAOAEPMGF1P = "http://www.w3.org/2001/04/xmlenc#rsa-oaep-mgf1p"
+	xmlencRSAOAEP      = "http://www.w3.org/2009/xmlenc11#rsa-oaep"
+)
+
+// samlEncryptionMethods are the encryption methods advertised in the
+// metadata, in order of preference.
+var samlEncryptionMethods = []string{
+	xmlencAES256GCM,
+	xmlencAES128GCM,
+	xmlencAES256CBC,
+	xmlencAES128CBC,
+	xmlencRSAOAEP,
+	xmlencRSAOAEPMGF1P,
+}
+
+var samlDigestMethods = map[string]crypto.Hash{
+	"http://www.w3.org/2000/09/xmldsig#sha1":        crypto.SHA1,
+	"http://www.w3.org/2001/04/xmlenc#sha256":       crypto.SHA256,
+	"http://www.w3.org/2001/04/xmldsig-more#sha384": crypto.SHA384,
+	"http://www.w3.org/2001/04/xmlenc#sha512":       crypto.SHA512,
+
+	// used by crewjam/saml instead of the xmlenc#sha256 URI
+	"http://www.w3.org/2000/09/xmldsig#sha256": crypto.SHA256,
+}
+
+var samlMaskGenerationFunctions = map[string]crypto.Hash{
+	"http://www.w3.org/2009/xmlenc11#mgf1sha1":   crypto.SHA1,
+	"http://www.w3.org/2009/xmlenc11#mgf1sha224": crypto.SHA224,
+	"http://www.w3.org/2009/xmlenc11#mgf1sha256": crypto.SHA256,
+	"http://www.w3.org/2009/xmlenc11#mgf1sha384": crypto.SHA384,
+	"http://www.w3.org/2009/xmlenc11#mgf1sha512": crypto.SHA512,
+}
+
+func init() {
+	xmlenc.RegisterDecrypter(samlAESGCM{algorithm: xmlencAES192GCM, keySize: 24})
+	xmlenc.RegisterDecrypter(samlAESGCM{algorithm: xmlencAES256GCM, keySize: 32})
+	xmlenc.RegisterDecrypter(samlRSAOAEP{algorithm: xmlencRSAOAEP})
+
+	// replaces the crewjam/saml implementation, which doesn't use SHA-1
+	// for MGF1 when the digest method is set
+	xmlenc.RegisterDecrypter(samlRSAOAEP{algorithm: xmlencRSAOAEPMGF1P})
+}
+
+// samlAESGCM decrypts EncryptedData elements encrypted with AES-GCM.
+type samlAESGCM struct {
+	algorithm string
+	keySize   int
+}
+
+func (d samlAESGCM) Algorithm() string {
+	return d.algorithm
+}
+
+func (d samlAESGCM) Decrypt(key interface{}, ciphertextEl *etree.Element) ([]byte, error) {
+	if encryptedKeyEl := ciphertextEl.FindElement("./KeyInfo/EncryptedKey"); encryptedKeyEl != nil {
+		var err error
+		key, err = xmlenc.Decrypt(key, encryptedKeyEl)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	keyBuf, ok := key.([]byte)
+	if !ok {
+		return nil, xmlenc.ErrIncorrectKeyType("[]byte")
+	}
+	if len(keyBuf) != d.keySize {
+		return nil, xmlenc.ErrIncorrectKeyLength(d.keySize)
+	}
+
+	block, err := aes.NewCipher(keyBuf)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := samlCipherValue(ciphertextEl)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	return aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], nil)
+}
+
+// samlRSAOAEP decrypts EncryptedKey elements encrypted with RSA-OAEP. The
+// digest method defaults to SHA-1, and so does the mask generation function,
+// which can only be changed with the XML Encryption 1.1 algorithm.
+type samlRSAOAEP struct {
+	algorithm string
+}
+
+func (d samlRSAOAEP) Algorithm() string {
+	return d.algorithm
+}
+
+func (d samlRSAOAEP) Decrypt(key interface{}, ciphertextEl *etree.Element) ([]byte, error) {
+	privateKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, xmlenc.ErrIncorrectKeyType("*rsa.PrivateKey")
+	}
+
+	ciphertext, err := samlCipherValue(ciphertextEl)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &rsa.OAEPOptions{
+		Hash:    crypto.SHA1,
+		MGFHash: crypto.SHA1,
+	}
+
+	if el := ciphertextEl.FindElement("./EncryptionMethod/DigestMethod"); el != nil {
+		algorithm := el.SelectAttrValue("Algorithm", "")
+		hash, ok := samlDigestMethods[algorithm]
+		if !ok {
+			return nil, xmlenc.ErrAlgorithmNotImplemented(algorithm)
+		}
+		options.Hash = hash
+	}
+
+	if d.algorithm == xmlencRSAOAEP {
+		if el := ciphertextEl.FindElement("./EncryptionMethod/MGF"); el != nil {
+			algorithm := el.SelectAttrValue("Algorithm", "")
+			hash, ok := samlMaskGenerationFunctions[algorithm]
+			if !ok {
+				return nil, xmlenc.ErrAlgorithmNotImplemented(algorithm)
+			}
+			options.MGFHash = hash
+		}
+	}
+
+	plaintext, err := privateKey.Decrypt(nil, ciphertext, options)
+	if err != nil && d.algorithm == xmlencRSAOAEPMGF1P && options.Hash != crypto.SHA1 {
+		// some implementations use the digest method for MGF1 too
+		options.MGFHash = options.Hash
+		plaintext, err = privateKey.Decrypt(nil, ciphertext, options)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt key: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+func samlCipherValue(ciphertextEl *etree.Element) ([]byte, error) {
+	el := ciphertextEl.FindElement("./CipherData/CipherValue")
+	if el == nil {
+		return nil, xmlenc.ErrCannotFindRequiredElement("CipherData/CipherValue")
+	}
+
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(el.Text()), ""))
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"hash"
+	"testing"
+
+	"github.com/beevik/etree"
+	"github.com/crewjam/saml/xmlenc"
+	"github.com/stretchr/testify/require"
+)
+
+func samlTestEncryptedData(t *testing.T, privateKey *rsa.PrivateKey, dataAlgorithm string, keySize int, keyAlgorithm string, digest string, mgf string, oaepHash hash.Hash, plaintext []byte) *etree.Element {
+	key := make([]byte, keySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	require.NoError(t, err)
+
+	encryptedKey, err := rsa.EncryptOAEP(oaepHash, rand.Reader, &privateKey.PublicKey, key, nil)
+	require.NoError(t, err)
+
+	doc := etree.NewDocument()
+	encryptedData := doc.CreateElement("xenc:EncryptedData")
+	encryptedData.CreateElement("xenc:EncryptionMethod").CreateAttr("Algorithm", dataAlgorithm)
+
+	encryptedKeyEl := encryptedData.CreateElement("ds:KeyInfo").CreateElement("xenc:EncryptedKey")
+	keyMethod := encryptedKeyEl.CreateElement("xenc:EncryptionMethod")
+	keyMethod.CreateAttr("Algorithm", keyAlgorithm)
+	if digest != "" {
+		keyMethod.CreateElement("ds:DigestMethod").CreateAttr("Algorithm", digest)
+	}
+	if mgf != "" {
+		keyMethod.CreateElement("xenc11:MGF").CreateAttr("Algorithm", mgf)
+	}
+	encryptedKeyEl.CreateElement("xenc:CipherData").CreateElement("xenc:CipherValue").SetText(base64.StdEncoding.EncodeToString(encryptedKey))
+
+	ciphertext := aead.Seal(nonce, nonce, plaintext, nil)
+	encryptedData.CreateElement("xenc:CipherData").CreateElement("xenc:CipherValue").SetText(base64.StdEncoding.EncodeToString(ciphertext))
+
+	return encryptedData
+}
+
+func TestSAMLDecryptXMLEncryption11(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	plaintext := []byte("<saml:Assertion></saml:Assertion>")
+
+	cases := []struct {
+		desc          string
+		dataAlgorithm string
+		keySize       int
+		keyAlgorithm  string
+		digest        string
+		mgf           string
+		oaepHash      hash.Hash
+		key           *rsa.PrivateKey
+		err           bool
+	}{
+		{
+			desc:          "aes256-gcm with rsa-oaep and mgf1sha256",
+			dataAlgorithm: xmlencAES256GCM,
+			keySize:       32,
+			keyAlgorithm:  xmlencRSAOAEP,
+			digest:        "http://www.w3.org/2001/04/xmlenc#sha256",
+			mgf:           "http://www.w3.org/2009/xmlenc11#mgf1sha256",
+			oaepHash:      sha256.New(),
+			key:           privateKey,
+		},
+		{
+			desc:          "aes192-gcm with rsa-oaep-mgf1p",
+			dataAlgorithm: xmlencAES192GCM,
+			keySize:       24,
+			keyAlgorithm:  xmlencRSAOAEPMGF1P,
+			oaepHash:      sha1.New(),
+			key:           privateKey,
+		},
+		{
+			desc:          "aes256-gcm with rsa-oaep-mgf1p and the digest method used for MGF1",
+			dataAlgorithm: xmlencAES256GCM,
+			keySize:       32,
+			keyAlgorithm:  xmlencRSAOAEPMGF1P,
+			digest:        "http://www.w3.org/2001/04/xmlenc#sha256",
+			oaepHash:      sha256.New(),
+			key:           privateKey,
+		},
+		{
+			desc:          "unsupported mask generation function",
+			dataAlgorithm: xmlencAES256GCM,
+			keySize:       32,
+			keyAlgorithm:  xmlencRSAOAEP,
+			mgf:           "http://www.w3.org/2009/xmlenc11#mgf1md5",
+			oaepHash:      sha1.New(),
+			key:           privateKey,
+			err:           true,
+		},
+		{
+			desc:          "wrong key",
+			dataAlgorithm: xmlencAES256GCM,
+			keySize:       32,
+			keyAlgorithm:  xmlencRSAOAEP,
+			oaepHash:      sha1.New(),
+			key:           otherKey,
+			err:           true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			el := samlTestEncryptedData(t, privateKey, c.dataAlgorithm, c.keySize, c.keyAlgorithm, c.digest, c.mgf, c.oaepHash, plaintext)
+
+			decrypted, err := xmlenc.Decrypt(c.key, el)
+			if c.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, plaintext, decrypted)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+func init() {
+	// The validity periods of assertions are checked against the clock
+	// skew of each SSO provider by validateSAMLAssertionTimes, so the
+	// library only needs to reject those outside of the largest one.
+	saml.MaxClockSkew = conf.SAMLMaxClockSkewLimit
+}
+
+// samlReplayCache remembers the IDs of accepted assertions so that they can't
+// be used again while they are valid.
+type samlReplayCache interface {
+	// Record records the assertion ID until expiresAt, returning false if
+	// it was recorded before and has not expired by now.
+	Record(ctx context.Context, ssoProviderID uuid.UUID, assertionID string, now, expiresAt time.Time) (bool, error)
+}
+
+func newSAMLReplayCache(config *conf.SAMLConfiguration, db *storage.Connection) samlReplayCache {
+	if config.ReplayCache == "database" {
+		return &samlDatabaseReplayCache{db: db}
+	}
+
+	return &samlMemoryReplayCache{
+		entries: make(map[string]time.Time),
+	}
+}
+
+// samlMemoryReplayCache keeps the assertion IDs in memory, which is only
+// effective when running a single instance.
+type samlMemoryReplayCache struct {
+	mu        sync.Mutex
+	entries   map[string]time.Time
+	lastPrune time.Time
+}
+
+func (c *samlMemoryReplayCache) Record(ctx context.Context, ssoProviderID uuid.UUID, assertionID string, now, expiresAt time.Time) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.lastPrune) >= time.Minute {
+		for key, entryExpiresAt := range c.entries {
+			if entryExpiresAt.Before(now) {
+				delete(c.entries, key)
+			}
+		}
+
+		c.lastPrune = now
+	}
+
+	key := ssoProviderID.String() + "/" + assertionID
+
+	if entryExpiresAt, ok := c.entries[key]; ok && !entryExpiresAt.Before(now) {
+		return false, nil
+	}
+
+	c.entries[key] = expiresAt
+
+	return true, nil
+}
+
+// samlDatabaseReplayCache keeps the assertion IDs in the database, so that
+// they are shared between instances.
+type samlDatabaseReplayCache struct {
+	db *storage.Connection
+}
+
+func (c *samlDatabaseReplayCache) Record(ctx context.Context, ssoProviderID uuid.UUID, assertionID string, now, expiresAt time.Time) (bool, error) {
+	return models.RecordSAMLAssertion(c.db.WithContext(ctx), ssoProviderID, assertionID, now, expiresAt)
+}
+
+// samlMaxClockSkew returns the clock skew allowed for assertions from the
+// SAML provider.
+func (a *API) samlMaxClockSkew(samlProvider *models.SAMLProvider) time.Duration {
+	if maxClockSkew := samlProvider.AssertionPolicy.MaxClockSkew; maxClockSkew != nil {
+		return time.Duration(*maxClockSkew) * time.Second
+	}
+
+	return a.config.SAML.MaxClockSkew
+}
+
+// samlReplayWindow returns the minimum time the IDs of assertions from the
+// SAML provider are remembered.
+func (a *API) samlReplayWindow(samlProvider *models.SAMLProvider) time.Duration {
+	if replayWindow := samlProvider.AssertionPolicy.ReplayWindow; replayWindow != nil {
+		return time.Duration(*replayWindow) * time.Second
+	}
+
+	return a.config.SAML.ReplayWindow
+}
+
+// validateSAMLAssertionTimes checks the validity period of the assertion
+// allowing for the clock skew.
+func validateSAMLAssertionTimes(assertion *saml.Assertion, maxClockSkew time.Duration, now time.Time) error {
+	if assertion.Subject != nil {
+		for _, subjectConfirmation := range assertion.Subject.SubjectConfirmations {
+			if subjectConfirmation.SubjectConfirmationData != nil && subjectConfirmation.SubjectConfirmationData.NotOnOrAfter.Add(maxClockSkew).Before(now) {
+				return errors.New("assertion SubjectConfirmationData is expired")
+			}
+		}
+	}
+
+	if assertion.Conditions != nil {
+		if assertion.Conditions.NotBefore.Add(-maxClockSkew).After(now) {
+			return errors.New("assertion Conditions is not yet valid")
+		}
+
+		if assertion.Conditions.NotOnOrAfter.Add(maxClockSkew).Before(now) {
+			return errors.New("assertion Conditions is expired")
+		}
+	}
+
+	return nil
+}
+
+// samlAssertionInResponseTo returns the request ID the assertion was issued
+// in response to, if any.
+func samlAssertionInResponseTo(assertion *saml.Assertion) string {
+	if assertion.Subject != nil {
+		for _, subjectConfirmation := range assertion.Subject.SubjectConfirmations {
+			if subjectConfirmation.SubjectConfirmationData != nil && subjectConfirmation.SubjectConfirmationData.InResponseTo != "" {
+				return subjectConfirmation.SubjectConfirmationData.InResponseTo
+			}
+		}
+	}
+
+	return ""
+}
+
+// samlAssertionReplayExpiry returns until when the ID of the assertion needs
+// to be remembered: the end of the replay window or of its validity period,
+// whichever is later.
+func samlAssertionReplayExpiry(assertion *saml.Assertion, maxClockSkew, replayWindow time.Duration, now time.Time) time.Time {
+	expiresAt := now.Add(replayWindow)
+
+	if assertion.Subject != nil {
+		for _, subjectConfirmation := range assertion.Subject.SubjectConfirmations {
+			if subjectConfirmation.SubjectConfirmationData != nil {
+				if notOnOrAfter := subjectConfirmation.SubjectConfirmationData.NotOnOrAfter.Add(maxClockSkew); notOnOrAfter.After(expiresAt) {
+					expiresAt = notOnOrAfter
+				}
+			}
+		}
+	}
+
+	if assertion.Conditions != nil {
+		if notOnOrAfter := assertion.Conditions.NotOnOrAfter.Add(maxClockSkew); notOnOrAfter.After(expiresAt) {
+			expiresAt = notOnOrAfter
+		}
+	}
+
+	return expiresAt
+}
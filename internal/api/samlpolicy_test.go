@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+)
+
+func samlTestPolicyAssertion(now time.Time) *saml.Assertion {
+	return &saml.Assertion{
+		ID: "_assertion",
+		Subject: &saml.Subject{
+			SubjectConfirmations: []saml.SubjectConfirmation{
+				{
+					SubjectConfirmationData: &saml.SubjectConfirmationData{
+						InResponseTo: "_request",
+						NotOnOrAfter: now.Add(5 * time.Minute),
+					},
+				},
+			},
+		},
+		Conditions: &saml.Conditions{
+			NotBefore:    now.Add(-time.Minute),
+			NotOnOrAfter: now.Add(time.Hour),
+		},
+	}
+}
+
+func TestSAMLAssertionTimes(t *testing.T) {
+	now := time.Date(2024, 9, 30, 12, 0, 0, 0, time.UTC)
+	assertion := samlTestPolicyAssertion(now)
+
+	require.NoError(t, validateSAMLAssertionTimes(assertion, 0, now))
+
+	// the subject confirmation expires first
+	require.Error(t, validateSAMLAssertionTimes(assertion, time.Minute, now.Add(7*time.Minute)))
+	require.NoError(t, validateSAMLAssertionTimes(assertion, 3*time.Minute, now.Add(7*time.Minute)))
+
+	// the identity provider's clock is ahead
+	require.Error(t, validateSAMLAssertionTimes(assertion, 0, now.Add(-2*time.Minute)))
+	require.NoError(t, validateSAMLAssertionTimes(assertion, 3*time.Minute, now.Add(-2*time.Minute)))
+}
+
+func TestSAMLAssertionInResponseTo(t *testing.T) {
+	now := time.Now()
+
+	assertion := samlTestPolicyAssertion(now)
+	require.Equal(t, "_request", samlAssertionInResponseTo(assertion))
+
+	assertion.Subject.SubjectConfirmations[0].SubjectConfirmationData.InResponseTo = ""
+	require.Equal(t, "", samlAssertionInResponseTo(assertion))
+
+	assertion.Subject = nil
+	require.Equal(t, "", samlAssertionInResponseTo(assertion))
+}
+
+func TestSAMLAssertionReplayExpiry(t *testing.T) {
+	now := time.Date(2024, 9, 30, 12, 0, 0, 0, time.UTC)
+	assertion := samlTestPolicyAssertion(now)
+
+	// assertions are remembered for as long as they are valid
+	require.Equal(t, now.Add(time.Hour+3*time.Minute), samlAssertionReplayExpiry(assertion, 3*time.Minute, 10*time.Minute, now))
+
+	// or for the replay window, if longer
+	require.Equal(t, now.Add(2*time.Hour), samlAssertionReplayExpiry(assertion, 3*time.Minute, 2*time.Hour, now))
+}
+
+func TestSAMLAssertionPolicyOverrides(t *testing.T) {
+	api := &API{
+		config: &conf.GlobalConfiguration{
+			SAML: conf.SAMLConfiguration{
+				MaxClockSkew: 3 * time.Minute,
+				ReplayWindow: 10 * time.Minute,
+			},
+		},
+	}
+
+	samlProvider := &models.SAMLProvider{}
+	require.True(t, samlProvider.AssertionPolicy.IDPInitiatedAllowed())
+	require.Equal(t, 3*time.Minute, api.samlMaxClockSkew(samlProvider))
+	require.Equal(t, 10*time.Minute, api.samlReplayWindow(samlProvider))
+
+	allowIDPInitiated := false
+	maxClockSkew := 30
+	replayWindow := 3600
+
+	samlProvider.AssertionPolicy = models.SAMLAssertionPolicy{
+		AllowIDPInitiated: &allowIDPInitiated,
+		MaxClockSkew:      &maxClockSkew,
+		ReplayWindow:      &replayWindow,
+	}
+	require.False(t, samlProvider.AssertionPolicy.IDPInitiatedAllowed())
+	require.Equal(t, 30*time.Second, api.samlMaxClockSkew(samlProvider))
+	require.Equal(t, time.Hour, api.samlReplayWindow(samlProvider))
+}
+
+func TestSAMLMemoryReplayCache(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 9, 30, 12, 0, 0, 0, time.UTC)
+
+	cache := newSAMLReplayCache(&conf.SAMLConfiguration{ReplayCache: "memory"}, nil)
+
+	providerA := uuid.Must(uuid.NewV4())
+	providerB := uuid.Must(uuid.NewV4())
+
+	recorded, err := cache.Record(ctx, providerA, "_assertion", now, now.Add(10*time.Minute))
+	require.NoError(t, err)
+	require.True(t, recorded)
+
+	recorded, err = cache.Record(ctx, providerA, "_assertion", now.Add(5*time.Minute), now.Add(15*time.Minute))
+	require.NoError(t, err)
+	require.False(t, recorded, "Replayed assertion was recorded")
+
+	recorded, err = cache.Record(ctx, providerB, "_assertion", now, now.Add(10*time.Minute))
+	require.NoError(t, err)
+	require.True(t, recorded, "Assertion IDs are scoped to the SSO provider")
+
+	recorded, err = cache.Record(ctx, providerA, "_assertion", now.Add(11*time.Minute), now.Add(21*time.Minute))
+	require.NoError(t, err)
+	require.True(t, recorded, "Expired assertion ID was not forgotten")
+}
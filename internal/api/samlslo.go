@@ -0,0 +1,656 @@
+package api
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/crewjam/saml"
+	"github.com/gofrs/uuid"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+	"github.com/supabase/auth/internal/utilities"
+)
+
+// samlMaxMessageSize limits the size of inflated HTTP-Redirect binding
+// messages.
+const samlMaxMessageSize = 128 * 1024
+
+var samlRedirectSignatureMethods = map[string]crypto.Hash{
+	dsig.RSASHA1SignatureMethod:   crypto.SHA1,
+	dsig.RSASHA256SignatureMethod: crypto.SHA256,
+	dsig.RSASHA384SignatureMethod: crypto.SHA384,
+	dsig.RSASHA512SignatureMethod: crypto.SHA512,
+}
+
+type SAMLLogoutParams struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+// SAMLLogout logs the user out and starts a SP initiated Single Logout with
+// the identity provider the user signed in with. The returned URL should be
+// opened in the browser so that the identity provider can terminate its
+// session too.
+func (a *API) SAMLLogout(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+
+	params := &SAMLLogoutParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	samlSession, err := models.FindLatestSAMLSessionForUser(db, user.ID)
+	if models.IsNotFoundError(err) {
+		return unprocessableEntityError(ErrorCodeSAMLSingleLogoutNotEnabled, "User did not sign in with a SAML identity provider that has Single Logout enabled")
+	} else if err != nil {
+		return internalServerError("Unable to find SAML session").WithInternalError(err)
+	}
+
+	ssoProvider, err := models.FindSSOProviderByID(db, samlSession.SSOProviderID)
+	if err != nil {
+		return internalServerError("Unable to find SSO provider from SAML session").WithInternalError(err)
+	}
+
+	if !ssoProvider.SAMLProvider.SingleLogoutEnabled {
+		return unprocessableEntityError(ErrorCodeSAMLSingleLogoutNotEnabled, "Single Logout is not enabled for this SAML identity provider")
+	}
+
+	idpMetadata, err := ssoProvider.SAMLProvider.EntityDescriptor()
+	if err != nil {
+		return internalServerError("Error parsing SAML Metadata for SAML provider").WithInternalError(err)
+	}
+
+	serviceProvider := a.getSAMLServiceProvider(idpMetadata, false /* <- idpInitiated */)
+
+	location := serviceProvider.GetSLOBindingLocation(saml.HTTPRedirectBinding)
+	if location == "" {
+		return unprocessableEntityError(ErrorCodeSAMLSingleLogoutNotEnabled, "SAML identity provider does not support Single Logout with the HTTP-Redirect binding")
+	}
+
+	logoutRequest, err := serviceProvider.MakeLogoutRequest(location, samlSession.NameID)
+	if err != nil {
+		return internalServerError("Error creating SAML Logout Request").WithInternalError(err)
+	}
+
+	// the HTTP-Redirect binding signs the query string instead
+	logoutRequest.Signature = nil
+
+	logoutRequest.NameID = &saml.NameID{
+		Value: samlSession.NameID,
+	}
+
+	if samlSession.NameIDFormat != nil {
+		logoutRequest.NameID.Format = *samlSession.NameIDFormat
+	}
+
+	if samlSession.SessionIndex != nil {
+		logoutRequest.SessionIndex = &saml.SessionIndex{
+			Value: *samlSession.SessionIndex,
+		}
+	}
+
+	relayState := models.SAMLRelayState{
+		SSOProviderID: ssoProvider.ID,
+		RequestID:     logoutRequest.ID,
+		RedirectTo:    params.RedirectTo,
+	}
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := models.NewAuditLogEntry(r, tx, user, models.LogoutAction, "", map[string]interface{}{
+			"provider_id": ssoProvider.ID.String(),
+		}); terr != nil {
+			return terr
+		}
+
+		if terr := models.Logout(tx, user.ID); terr != nil {
+			return terr
+		}
+
+		if terr := models.DestroySAMLSessionsForUser(tx, user.ID); terr != nil {
+			return terr
+		}
+
+		return tx.Create(&relayState)
+	}); err != nil {
+		return internalServerError("Error logging out user").WithInternalError(err)
+	}
+
+	logoutURL, err := samlRedirectBindingURL(serviceProvider, location, "SAMLRequest", logoutRequest.Element(), relayState.ID.String())
+	if err != nil {
+		return internalServerError("Error creating SAML Logout Request redirect URL").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, SingleSignOnResponse{
+		URL: logoutURL,
+	})
+}
+
+// SAMLSingleLogout implements the Single Logout Service endpoint with the
+// HTTP-Redirect and HTTP-POST bindings. It receives Logout Requests from
+// identity providers (IdP initiated Single Logout) and Logout Responses to
+// requests sent by SAMLLogout.
+func (a *API) SAMLSingleLogout(w http.ResponseWriter, r *http.Request) error {
+	if err := a.handleSAMLSingleLogout(w, r); err != nil {
+		u, uerr := url.Parse(a.config.SiteURL)
+		if uerr != nil {
+			return internalServerError("site url is improperly formattted").WithInternalError(err)
+		}
+
+		q := getErrorQueryString(err, utilities.GetRequestID(r.Context()), observability.GetLogEntry(r).Entry, u.Query())
+		u.RawQuery = q.Encode()
+		http.Redirect(w, r, u.String(), http.StatusSeeOther)
+	}
+	return nil
+}
+
+func (a *API) handleSAMLSingleLogout(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return badRequestError(ErrorCodeValidationFailed, "Could not parse request").WithInternalError(err)
+	}
+
+	if r.FormValue("SAMLRequest") != "" {
+		return a.handleSAMLLogoutRequest(w, r)
+	} else if r.FormValue("SAMLResponse") != "" {
+		return a.handleSAMLLogoutResponse(w, r)
+	}
+
+	return badRequestError(ErrorCodeValidationFailed, "SAMLRequest or SAMLResponse is missing")
+}
+
+// handleSAMLLogoutRequest terminates the sessions of the user identified in
+// a Logout Request from an identity provider and responds to it.
+func (a *API) handleSAMLLogoutRequest(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	rawRequest, err := samlDecodeMessage(r, "SAMLRequest")
+	if err != nil {
+		return badRequestError(ErrorCodeValidationFailed, "SAMLRequest is not valid").WithInternalError(err)
+	}
+
+	var peekRequest saml.LogoutRequest
+	if err := xml.Unmarshal(rawRequest, &peekRequest); err != nil {
+		return badRequestError(ErrorCodeValidationFailed, "SAMLRequest is not a valid XML SAML Logout Request").WithInternalError(err)
+	}
+
+	if peekRequest.Issuer == nil {
+		return badRequestError(ErrorCodeValidationFailed, "SAML Logout Request does not contain an Issuer")
+	}
+
+	ssoProvider, err := models.FindSAMLProviderByEntityID(db, peekRequest.Issuer.Value)
+	if models.IsNotFoundError(err) {
+		return notFoundError(ErrorCodeSAMLIdPNotFound, "A SAML connection has not been established with this Identity Provider")
+	} else if err != nil {
+		return err
+	}
+
+	if !ssoProvider.SAMLProvider.SingleLogoutEnabled {
+		return unprocessableEntityError(ErrorCodeSAMLSingleLogoutNotEnabled, "Single Logout is not enabled for this SAML identity provider")
+	}
+
+	idpMetadata, err := ssoProvider.SAMLProvider.EntityDescriptor()
+	if err != nil {
+		return err
+	}
+
+	serviceProvider := a.getSAMLServiceProvider(idpMetadata, true /* <- idpInitiated */)
+
+	// only the signed message is used from here on
+	rawRequest, err = samlVerifyMessage(r, "SAMLRequest", rawRequest, idpMetadata, true /* <- required */)
+	if err != nil {
+		return badRequestError(ErrorCodeValidationFailed, "SAML Logout Request signature is not valid").WithInternalError(err)
+	}
+
+	var logoutRequest saml.LogoutRequest
+	if err := xml.Unmarshal(rawRequest, &logoutRequest); err != nil {
+		return badRequestError(ErrorCodeValidationFailed, "SAMLRequest is not a valid XML SAML Logout Request").WithInternalError(err)
+	}
+
+	if logoutRequest.Issuer == nil || logoutRequest.Issuer.Value != ssoProvider.SAMLProvider.EntityID {
+		return badRequestError(ErrorCodeValidationFailed, "SAML Logout Request Issuer does not match the identity provider")
+	}
+
+	if logoutRequest.Destination != "" && logoutRequest.Destination != serviceProvider.SloURL.String() {
+		return badRequestError(ErrorCodeValidationFailed, "SAML Logout Request Destination does not match the Single Logout Service URL")
+	}
+
+	if logoutRequest.NotOnOrAfter != nil && !time.Now().Before(*logoutRequest.NotOnOrAfter) {
+		return badRequestError(ErrorCodeValidationFailed, "SAML Logout Request has expired")
+	}
+
+	if logoutRequest.NameID == nil || logoutRequest.NameID.Value == "" {
+		return badRequestError(ErrorCodeValidationFailed, "SAML Logout Request does not contain a NameID")
+	}
+
+	// encoding/xml keeps only the last SessionIndex element
+	var sessionIndexes []string
+	if logoutRequest.SessionIndex != nil && logoutRequest.SessionIndex.Value != "" {
+		sessionIndexes = append(sessionIndexes, logoutRequest.SessionIndex.Value)
+	}
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		samlSessions, terr := models.FindSAMLSessionsByNameID(tx, ssoProvider.ID, logoutRequest.NameID.Value, sessionIndexes)
+		if terr != nil {
+			return terr
+		}
+
+		loggedOut := make(map[uuid.UUID]bool)
+
+		for _, samlSession := range samlSessions {
+			if loggedOut[samlSession.UserID] {
+				continue
+			}
+
+			loggedOut[samlSession.UserID] = true
+
+			user, terr := models.FindUserByID(tx, samlSession.UserID)
+			if terr != nil {
+				return terr
+			}
+
+			if terr := models.NewAuditLogEntry(r, tx, user, models.LogoutAction, "", map[string]interface{}{
+				"provider_id":  ssoProvider.ID.String(),
+				"initiated_by": "idp",
+			}); terr != nil {
+				return terr
+			}
+
+			if terr := models.Logout(tx, user.ID); terr != nil {
+				return terr
+			}
+
+			if terr := models.DestroySAMLSessionsForUser(tx, user.ID); terr != nil {
+				return terr
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return internalServerError("Error logging out user").WithInternalError(err)
+	}
+
+	relayState := r.FormValue("RelayState")
+
+	if location := samlSingleLogoutResponseLocation(idpMetadata, saml.HTTPRedirectBinding); location != "" {
+		logoutResponse, err := serviceProvider.MakeLogoutResponse(location, logoutRequest.ID)
+		if err != nil {
+			return internalServerError("Error creating SAML Logout Response").WithInternalError(err)
+		}
+
+		// the HTTP-Redirect binding signs the query string instead
+		logoutResponse.Signature = nil
+
+		responseURL, err := samlRedirectBindingURL(serviceProvider, location, "SAMLResponse", logoutResponse.Element(), relayState)
+		if err != nil {
+			return internalServerError("Error creating SAML Logout Response redirect URL").WithInternalError(err)
+		}
+
+		http.Redirect(w, r, responseURL, http.StatusFound)
+		return nil
+	}
+
+	if location := samlSingleLogoutResponseLocation(idpMetadata, saml.HTTPPostBinding); location != "" {
+		logoutResponse, err := serviceProvider.MakeLogoutResponse(location, logoutRequest.ID)
+		if err != nil {
+			return internalServerError("Error creating SAML Logout Response").WithInternalError(err)
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, err = fmt.Fprintf(w, "<!DOCTYPE html><html><body>%s</body></html>", logoutResponse.Post(relayState))
+		return err
+	}
+
+	return unprocessableEntityError(ErrorCodeSAMLSingleLogoutNotEnabled, "SAML identity provider does not have a Single Logout Service")
+}
+
+// handleSAMLLogoutResponse completes a SP initiated Single Logout by taking
+// the user to the URL they requested.
+func (a *API) handleSAMLLogoutResponse(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+	log := observability.GetLogEntry(r).Entry
+
+	relayStateUUID := uuid.FromStringOrNil(r.FormValue("RelayState"))
+	if relayStateUUID == uuid.Nil {
+		return badRequestError(ErrorCodeValidationFailed, "SAML RelayState is not a valid UUID")
+	}
+
+	relayState, err := models.FindSAMLRelayStateByID(db, relayStateUUID)
+	if models.IsNotFoundError(err) {
+		return notFoundError(ErrorCodeSAMLRelayStateNotFound, "SAML RelayState does not exist")
+	} else if err != nil {
+		return err
+	}
+
+	if err := a.samlDestroyRelayState(ctx, relayState); err != nil {
+		return err
+	}
+
+	if time.Since(relayState.CreatedAt) >= config.SAML.RelayStateValidityPeriod {
+		return unprocessableEntityError(ErrorCodeSAMLRelayStateExpired, "SAML RelayState has expired")
+	}
+
+	ssoProvider, err := models.FindSSOProviderByID(db, relayState.SSOProviderID)
+	if err != nil {
+		return internalServerError("Unable to find SSO Provider from SAML RelayState").WithInternalError(err)
+	}
+
+	idpMetadata, err := ssoProvider.SAMLProvider.EntityDescriptor()
+	if err != nil {
+		return err
+	}
+
+	rawResponse, err := samlDecodeMessage(r, "SAMLResponse")
+	if err != nil {
+		return badRequestError(ErrorCodeValidationFailed, "SAMLResponse is not valid").WithInternalError(err)
+	}
+
+	// the user has been logged out already, so unsigned responses are
+	// accepted but ones with invalid signatures aren't
+	rawResponse, err = samlVerifyMessage(r, "SAMLResponse", rawResponse, idpMetadata, false /* <- required */)
+	if err != nil {
+		return badRequestError(ErrorCodeValidationFailed, "SAML Logout Response signature is not valid").WithInternalError(err)
+	}
+
+	var logoutResponse saml.LogoutResponse
+	if err := xml.Unmarshal(rawResponse, &logoutResponse); err != nil {
+		return badRequestError(ErrorCodeValidationFailed, "SAMLResponse is not a valid XML SAML Logout Response").WithInternalError(err)
+	}
+
+	if logoutResponse.Issuer == nil || logoutResponse.Issuer.Value != ssoProvider.SAMLProvider.EntityID {
+		return badRequestError(ErrorCodeValidationFailed, "SAML Logout Response Issuer does not match the identity provider")
+	}
+
+	if logoutResponse.InResponseTo != relayState.RequestID {
+		return badRequestError(ErrorCodeValidationFailed, "SAML Logout Response is not for the Logout Request sent with this RelayState")
+	}
+
+	if logoutResponse.Status.StatusCode.Value != saml.StatusSuccess {
+		logentry := log.WithField("sso_provider_id", ssoProvider.ID.String())
+		logentry = logentry.WithField("status_code", logoutResponse.Status.StatusCode.Value)
+		logentry.Warn("SAML identity provider did not complete Single Logout")
+	}
+
+	redirectTo := relayState.RedirectTo
+	if !utilities.IsRedirectURLValid(config, redirectTo) {
+		redirectTo = config.SiteURL
+	}
+
+	http.Redirect(w, r, redirectTo, http.StatusSeeOther)
+	return nil
+}
+
+// samlDecodeMessage decodes the SAML message in the parameter, which is
+// deflated when the HTTP-Redirect binding is used.
+func samlDecodeMessage(r *http.Request, parameter string) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(r.FormValue(parameter))
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Method != http.MethodGet {
+		return raw, nil
+	}
+
+	reader := flate.NewReader(bytes.NewReader(raw))
+	defer utilities.SafeClose(reader)
+
+	message, err := io.ReadAll(io.LimitReader(reader, samlMaxMessageSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(message) > samlMaxMessageSize {
+		return nil, errors.New("message is too large")
+	}
+
+	return message, nil
+}
+
+// samlVerifyMessage verifies the signature of a SAML message with the
+// signing certificates of the identity provider, returning the signed
+// message. With the HTTP-Redirect binding the query string is signed,
+// otherwise the signature is embedded in the message.
+func samlVerifyMessage(r *http.Request, parameter string, message []byte, idpMetadata *saml.EntityDescriptor, required bool) ([]byte, error) {
+	certificates, err := samlIDPSigningCertificates(idpMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Method == http.MethodGet {
+		if r.URL.Query().Get("Signature") == "" {
+			if required {
+				return nil, errors.New("message is not signed")
+			}
+
+			return message, nil
+		}
+
+		return message, samlVerifyRedirectSignature(r.URL.RawQuery, parameter, certificates)
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(message); err != nil {
+		return nil, err
+	}
+
+	if doc.Root() == nil {
+		return nil, errors.New("message is empty")
+	}
+
+	if doc.Root().FindElement("./Signature") == nil {
+		if required {
+			return nil, errors.New("message is not signed")
+		}
+
+		return message, nil
+	}
+
+	validationContext := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{
+		Roots: certificates,
+	})
+	validationContext.IdAttribute = "ID"
+
+	signed, err := validationContext.Validate(doc.Root())
+	if err != nil {
+		return nil, err
+	}
+
+	signedDoc := etree.NewDocument()
+	signedDoc.SetRoot(signed)
+
+	return signedDoc.WriteToBytes()
+}
+
+// samlVerifyRedirectSignature verifies the signature of the query string of
+// a HTTP-Redirect binding message, which is computed over the URL encoded
+// parameter, RelayState and SigAlg values as received.
+func samlVerifyRedirectSignature(rawQuery string, parameter string, certificates []*x509.Certificate) error {
+	values := make(map[string]string)
+	for _, part := range strings.Split(rawQuery, "&") {
+		key, value, _ := strings.Cut(part, "=")
+		if _, ok := values[key]; !ok {
+			values[key] = value
+		}
+	}
+
+	signatureMethod, err := url.QueryUnescape(values["SigAlg"])
+	if err != nil {
+		return err
+	}
+
+	hash, ok := samlRedirectSignatureMethods[signatureMethod]
+	if !ok {
+		return fmt.Errorf("unsupported signature algorithm %q", signatureMethod)
+	}
+
+	encodedSignature, err := url.QueryUnescape(values["Signature"])
+	if err != nil {
+		return err
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return err
+	}
+
+	signed := parameter + "=" + values[parameter]
+	if relayState, ok := values["RelayState"]; ok {
+		signed += "&RelayState=" + relayState
+	}
+	signed += "&SigAlg=" + values["SigAlg"]
+
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	for _, certificate := range certificates {
+		publicKey, ok := certificate.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			continue
+		}
+
+		if rsa.VerifyPKCS1v15(publicKey, hash, digest, signature) == nil {
+			return nil
+		}
+	}
+
+	return errors.New("signature does not match any signing certificate of the identity provider")
+}
+
+// samlRedirectBindingURL encodes the SAML message in the parameter of a URL
+// for the HTTP-Redirect binding, signing the query string if requests are
+// signed.
+func samlRedirectBindingURL(serviceProvider *saml.ServiceProvider, location string, parameter string, el *etree.Element, relayState string) (string, error) {
+	doc := etree.NewDocument()
+	doc.SetRoot(el)
+
+	var buf bytes.Buffer
+	encoder := base64.NewEncoder(base64.StdEncoding, &buf)
+	writer, err := flate.NewWriter(encoder, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := doc.WriteTo(writer); err != nil {
+		return "", err
+	}
+
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return "", err
+	}
+
+	// the order of the parameters matters for the signature
+	query := parameter + "=" + url.QueryEscape(buf.String())
+	if relayState != "" {
+		query += "&RelayState=" + url.QueryEscape(relayState)
+	}
+
+	if serviceProvider.SignatureMethod != "" {
+		query += "&SigAlg=" + url.QueryEscape(serviceProvider.SignatureMethod)
+
+		signingContext, err := saml.GetSigningContext(serviceProvider)
+		if err != nil {
+			return "", err
+		}
+
+		signature, err := signingContext.SignString(query)
+		if err != nil {
+			return "", err
+		}
+
+		query += "&Signature=" + url.QueryEscape(base64.StdEncoding.EncodeToString(signature))
+	}
+
+	if u.RawQuery != "" {
+		query = u.RawQuery + "&" + query
+	}
+
+	u.RawQuery = query
+
+	return u.String(), nil
+}
+
+// samlSingleLogoutResponseLocation returns the URL of the identity
+// provider's Single Logout Service for responses with the binding.
+func samlSingleLogoutResponseLocation(idpMetadata *saml.EntityDescriptor, binding string) string {
+	for _, idpSSODescriptor := range idpMetadata.IDPSSODescriptors {
+		for _, singleLogoutService := range idpSSODescriptor.SingleLogoutServices {
+			if singleLogoutService.Binding != binding {
+				continue
+			}
+
+			if singleLogoutService.ResponseLocation != "" {
+				return singleLogoutService.ResponseLocation
+			}
+
+			return singleLogoutService.Location
+		}
+	}
+
+	return ""
+}
+
+var samlCertificateWhitespace = regexp.MustCompile(`\s+`)
+
+// samlIDPSigningCertificates returns the certificates the identity provider
+// signs messages with.
+func samlIDPSigningCertificates(idpMetadata *saml.EntityDescriptor) ([]*x509.Certificate, error) {
+	var certificates []*x509.Certificate
+
+	for _, idpSSODescriptor := range idpMetadata.IDPSSODescriptors {
+		for _, keyDescriptor := range idpSSODescriptor.KeyDescriptors {
+			if keyDescriptor.Use != "" && keyDescriptor.Use != "signing" {
+				continue
+			}
+
+			for _, x509Certificate := range keyDescriptor.KeyInfo.X509Data.X509Certificates {
+				data, err := base64.StdEncoding.DecodeString(samlCertificateWhitespace.ReplaceAllString(x509Certificate.Data, ""))
+				if err != nil {
+					return nil, err
+				}
+
+				certificate, err := x509.ParseCertificate(data)
+				if err != nil {
+					return nil, err
+				}
+
+				certificates = append(certificates, certificate)
+			}
+		}
+	}
+
+	if len(certificates) == 0 {
+		return nil, errors.New("SAML Metadata does not contain any signing certificates")
+	}
+
+	return certificates, nil
+}
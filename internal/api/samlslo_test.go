@@ -0,0 +1,167 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/crewjam/saml"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+)
+
+func samlTestSingleLogoutAPI(t *testing.T) *API {
+	config, err := conf.LoadGlobal(apiTestConfig)
+	require.NoError(t, err)
+	config.API.ExternalURL = "https://projectref.supabase.co/auth/v1/"
+	config.SAML.Enabled = true
+	config.SAML.PrivateKey = samlTestPrivateKey
+	config.SAML.SignAuthnRequests = true
+	config.API.MaxRequestDuration = 5 * time.Second
+
+	require.NoError(t, config.ApplyDefaults())
+	require.NoError(t, config.SAML.PopulateFields(config.API.ExternalURL))
+
+	return NewAPI(config, nil)
+}
+
+// samlTestIDPMetadata returns metadata of an identity provider signing with
+// the SP certificate, so that messages created by the SP verify.
+func samlTestIDPMetadata(api *API) *saml.EntityDescriptor {
+	return &saml.EntityDescriptor{
+		EntityID: "https://idp.example.com/metadata",
+		IDPSSODescriptors: []saml.IDPSSODescriptor{
+			{
+				SSODescriptor: saml.SSODescriptor{
+					RoleDescriptor: saml.RoleDescriptor{
+						KeyDescriptors: []saml.KeyDescriptor{
+							{
+								Use: "signing",
+								KeyInfo: saml.KeyInfo{
+									X509Data: saml.X509Data{
+										X509Certificates: []saml.X509Certificate{
+											{Data: base64.StdEncoding.EncodeToString(api.config.SAML.Certificate.Raw)},
+										},
+									},
+								},
+							},
+						},
+					},
+					SingleLogoutServices: []saml.Endpoint{
+						{
+							Binding:          saml.HTTPRedirectBinding,
+							Location:         "https://idp.example.com/slo",
+							ResponseLocation: "https://idp.example.com/slo/response",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestSAMLRedirectBindingSignature(t *testing.T) {
+	api := samlTestSingleLogoutAPI(t)
+	idpMetadata := samlTestIDPMetadata(api)
+
+	serviceProvider := api.getSAMLServiceProvider(idpMetadata, false)
+	require.NotEmpty(t, serviceProvider.SignatureMethod)
+
+	logoutRequest, err := serviceProvider.MakeLogoutRequest(serviceProvider.GetSLOBindingLocation(saml.HTTPRedirectBinding), "user@example.com")
+	require.NoError(t, err)
+	logoutRequest.Signature = nil
+
+	logoutURL, err := samlRedirectBindingURL(serviceProvider, "https://idp.example.com/slo?tenant=example", "SAMLRequest", logoutRequest.Element(), "relay-state")
+	require.NoError(t, err)
+
+	u, err := url.Parse(logoutURL)
+	require.NoError(t, err)
+	require.Equal(t, "example", u.Query().Get("tenant"))
+	require.Equal(t, "relay-state", u.Query().Get("RelayState"))
+	require.NotEmpty(t, u.Query().Get("Signature"))
+
+	// the tenant parameter of the location is not signed
+	query := strings.TrimPrefix(u.RawQuery, "tenant=example&")
+
+	certificates, err := samlIDPSigningCertificates(idpMetadata)
+	require.NoError(t, err)
+	require.NoError(t, samlVerifyRedirectSignature(query, "SAMLRequest", certificates))
+
+	tampered := strings.Replace(query, "RelayState=relay-state", "RelayState=other-state", 1)
+	require.Error(t, samlVerifyRedirectSignature(tampered, "SAMLRequest", certificates))
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/sso/saml/slo?"+query, nil)
+
+	message, err := samlDecodeMessage(req, "SAMLRequest")
+	require.NoError(t, err)
+
+	message, err = samlVerifyMessage(req, "SAMLRequest", message, idpMetadata, true)
+	require.NoError(t, err)
+
+	var decoded saml.LogoutRequest
+	require.NoError(t, xml.Unmarshal(message, &decoded))
+	require.Equal(t, logoutRequest.ID, decoded.ID)
+	require.Equal(t, "user@example.com", decoded.NameID.Value)
+
+	unsigned := httptest.NewRequest(http.MethodGet, "http://localhost/sso/saml/slo?"+strings.Split(query, "&SigAlg=")[0], nil)
+	_, err = samlVerifyMessage(unsigned, "SAMLRequest", message, idpMetadata, true)
+	require.Error(t, err)
+
+	_, err = samlVerifyMessage(unsigned, "SAMLRequest", message, idpMetadata, false)
+	require.NoError(t, err)
+}
+
+func TestSAMLPostBindingSignature(t *testing.T) {
+	api := samlTestSingleLogoutAPI(t)
+	idpMetadata := samlTestIDPMetadata(api)
+
+	serviceProvider := api.getSAMLServiceProvider(idpMetadata, false)
+
+	logoutResponse, err := serviceProvider.MakeLogoutResponse("https://idp.example.com/slo", "id-request")
+	require.NoError(t, err)
+	require.NotNil(t, logoutResponse.Signature)
+
+	doc := etree.NewDocument()
+	doc.SetRoot(logoutResponse.Element())
+	signed, err := doc.WriteToBytes()
+	require.NoError(t, err)
+
+	form := url.Values{
+		"SAMLResponse": []string{base64.StdEncoding.EncodeToString(signed)},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/sso/saml/slo", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	message, err := samlDecodeMessage(req, "SAMLResponse")
+	require.NoError(t, err)
+
+	message, err = samlVerifyMessage(req, "SAMLResponse", message, idpMetadata, true)
+	require.NoError(t, err)
+
+	var decoded saml.LogoutResponse
+	require.NoError(t, xml.Unmarshal(message, &decoded))
+	require.Equal(t, "id-request", decoded.InResponseTo)
+
+	tampered := []byte(strings.Replace(string(signed), "id-request", "id-other", 1))
+	_, err = samlVerifyMessage(req, "SAMLResponse", tampered, idpMetadata, true)
+	require.Error(t, err)
+}
+
+func TestSAMLSingleLogoutResponseLocation(t *testing.T) {
+	api := samlTestSingleLogoutAPI(t)
+	idpMetadata := samlTestIDPMetadata(api)
+
+	require.Equal(t, "https://idp.example.com/slo/response", samlSingleLogoutResponseLocation(idpMetadata, saml.HTTPRedirectBinding))
+	require.Equal(t, "", samlSingleLogoutResponseLocation(idpMetadata, saml.HTTPPostBinding))
+	require.True(t, hasSAMLSingleLogoutService(idpMetadata))
+
+	idpMetadata.IDPSSODescriptors[0].SingleLogoutServices = nil
+	require.False(t, hasSAMLSingleLogoutService(idpMetadata))
+}
@@ -0,0 +1,1325 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+)
+
+const (
+	scimUserSchema                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimGroupSchema                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimListResponseSchema          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimErrorSchema                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	scimServiceProviderConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	scimResourceTypeSchema          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+
+	scimContentType = "application/scim+json"
+
+	// scimDeactivationDuration is how long users deactivated with SCIM
+	// are banned for, which is until they are activated again.
+	scimDeactivationDuration = 100 * 365 * 24 * time.Hour
+)
+
+// scimBoolean is a boolean that also accepts the "True" and "False"
+// strings sent by some identity providers.
+type scimBoolean bool
+
+func (b *scimBoolean) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	parsed, ok := scimBooleanValue(value)
+	if !ok {
+		return fmt.Errorf("invalid boolean %s", string(data))
+	}
+
+	*b = scimBoolean(parsed)
+
+	return nil
+}
+
+type scimName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type scimMultiValue struct {
+	Value   string      `json:"value"`
+	Type    string      `json:"type,omitempty"`
+	Primary scimBoolean `json:"primary,omitempty"`
+}
+
+type scimReference struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+type scimMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+}
+
+// scimUser is the SCIM representation of a user of an SSO provider. The
+// userName is the ID of the user with the provider, which is the NameID of
+// the user's SAML assertions.
+type scimUser struct {
+	Schemas     []string         `json:"schemas"`
+	ID          string           `json:"id,omitempty"`
+	ExternalID  string           `json:"externalId,omitempty"`
+	UserName    string           `json:"userName"`
+	Name        *scimName        `json:"name,omitempty"`
+	DisplayName string           `json:"displayName,omitempty"`
+	Emails      []scimMultiValue `json:"emails,omitempty"`
+	Active      *scimBoolean     `json:"active,omitempty"`
+	Groups      []scimReference  `json:"groups,omitempty"`
+	Meta        *scimMeta        `json:"meta,omitempty"`
+}
+
+func (u *scimUser) primaryEmail() string {
+	for _, email := range u.Emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+
+	if len(u.Emails) > 0 {
+		return u.Emails[0].Value
+	}
+
+	return ""
+}
+
+// metadata returns the user_metadata and identity_data of the user, with
+// nil values for attributes that are not set so they are removed.
+func (u *scimUser) metadata() map[string]interface{} {
+	metadata := map[string]interface{}{
+		"name":        nil,
+		"full_name":   nil,
+		"given_name":  nil,
+		"family_name": nil,
+	}
+
+	if u.DisplayName != "" {
+		metadata["name"] = u.DisplayName
+	}
+
+	if u.Name != nil {
+		if u.Name.Formatted != "" {
+			metadata["full_name"] = u.Name.Formatted
+		}
+
+		if u.Name.GivenName != "" {
+			metadata["given_name"] = u.Name.GivenName
+		}
+
+		if u.Name.FamilyName != "" {
+			metadata["family_name"] = u.Name.FamilyName
+		}
+	}
+
+	return metadata
+}
+
+// scimGroup is the SCIM representation of a group provisioned by an SSO
+// provider.
+type scimGroup struct {
+	Schemas     []string        `json:"schemas"`
+	ID          string          `json:"id,omitempty"`
+	ExternalID  string          `json:"externalId,omitempty"`
+	DisplayName string          `json:"displayName"`
+	Members     []scimReference `json:"members"`
+	Meta        *scimMeta       `json:"meta,omitempty"`
+}
+
+type scimListResponse struct {
+	Schemas      []string                 `json:"schemas"`
+	TotalResults int                      `json:"totalResults"`
+	StartIndex   int                      `json:"startIndex"`
+	ItemsPerPage int                      `json:"itemsPerPage"`
+	Resources    []map[string]interface{} `json:"Resources"`
+}
+
+type scimPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []scimPatchOperation `json:"Operations"`
+}
+
+// scimError is an error response of the SCIM endpoints (RFC 7644 section
+// 3.12).
+type scimError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	SCIMType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+
+	httpStatus int
+}
+
+func (e *scimError) Error() string {
+	return e.Detail
+}
+
+func newSCIMError(httpStatus int, scimType string, fmtString string, args ...interface{}) *scimError {
+	return &scimError{
+		Schemas:    []string{scimErrorSchema},
+		Status:     strconv.Itoa(httpStatus),
+		SCIMType:   scimType,
+		Detail:     fmt.Sprintf(fmtString, args...),
+		httpStatus: httpStatus,
+	}
+}
+
+func sendSCIM(w http.ResponseWriter, status int, obj interface{}) error {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", scimContentType)
+	w.WriteHeader(status)
+	_, err = w.Write(b)
+	return err
+}
+
+// handleSCIMError responds with the SCIM representation of the error.
+func handleSCIMError(err error, w http.ResponseWriter, r *http.Request) {
+	log := observability.GetLogEntry(r).Entry
+
+	var response *scimError
+
+	switch e := err.(type) {
+	case *scimError:
+		log.WithError(e).Info(e.Error())
+		response = e
+
+	case *scimPatchError:
+		log.WithError(e).Info(e.Error())
+		response = newSCIMError(http.StatusBadRequest, e.scimType, "%s", e.message)
+
+	case *HTTPError:
+		if e.HTTPStatus >= http.StatusInternalServerError {
+			log.WithError(e.Cause()).Error(e.Error())
+		} else {
+			log.WithError(e.Cause()).Info(e.Error())
+		}
+
+		response = newSCIMError(e.HTTPStatus, "", "%s", e.Message)
+
+	default:
+		log.WithError(e).Errorf("Unhandled server error: %s", e.Error())
+
+		response = newSCIMError(http.StatusInternalServerError, "", "Unexpected failure, please check server logs for more information")
+	}
+
+	if jsonErr := sendSCIM(w, response.httpStatus, response); jsonErr != nil && jsonErr != context.DeadlineExceeded {
+		log.WithError(jsonErr).Warn("Failed to send JSON on ResponseWriter")
+	}
+}
+
+// scimHandler adapts a handler to respond with SCIM errors.
+func scimHandler(fn apiHandler) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if err := fn(w, r); err != nil {
+			handleSCIMError(err, w, r)
+		}
+
+		return nil
+	}
+}
+
+// scimMiddleware adapts a middleware to respond with SCIM errors.
+func scimMiddleware(fn middlewareHandler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, err := fn(w, r)
+			if err != nil {
+				handleSCIMError(err, w, r)
+				return
+			}
+
+			if ctx != nil {
+				r = r.WithContext(ctx)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireSCIMAuthentication loads the SSO provider the SCIM bearer token of
+// the request was issued for.
+func (a *API) requireSCIMAuthentication(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	if !a.config.SCIM.Enabled {
+		return nil, newSCIMError(http.StatusNotFound, "", "SCIM is disabled")
+	}
+
+	token, err := a.extractBearerToken(r)
+	if err != nil {
+		return nil, newSCIMError(http.StatusUnauthorized, "", "This endpoint requires a Bearer token")
+	}
+
+	provider, err := models.FindSSOProviderBySCIMToken(db, token)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, newSCIMError(http.StatusUnauthorized, "", "Invalid SCIM token")
+		}
+
+		return nil, internalServerError("Database error finding SSO provider").WithInternalError(err)
+	}
+
+	observability.LogEntrySetField(r, "sso_provider_id", provider.ID.String())
+
+	return withSSOProvider(ctx, provider), nil
+}
+
+func (a *API) scimLocation(resourceType, id string) string {
+	return strings.TrimSuffix(a.config.API.ExternalURL, "/") + "/scim/v2/" + resourceType + "/" + id
+}
+
+func readSCIMBody(r *http.Request, v interface{}) error {
+	body, err := getBodyBytes(r)
+	if err != nil {
+		return internalServerError("Could not read body").WithInternalError(err)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return newSCIMError(http.StatusBadRequest, "invalidSyntax", "Could not parse request body: %v", err)
+	}
+
+	return nil
+}
+
+// scimResourceMap returns the JSON representation of a resource, as used by
+// filters and PATCH operations.
+func scimResourceMap(resource interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(b, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func scimResourceFromMap(resource map[string]interface{}, into interface{}) error {
+	b, err := json.Marshal(resource)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(b, into); err != nil {
+		return newSCIMError(http.StatusBadRequest, "invalidValue", "Invalid resource: %v", err)
+	}
+
+	return nil
+}
+
+// scimProject applies the attributes and excludedAttributes query
+// parameters to a resource. The id and schemas are always returned.
+func scimProject(r *http.Request, resource map[string]interface{}) map[string]interface{} {
+	query := r.URL.Query()
+
+	names := func(param string) map[string]bool {
+		result := make(map[string]bool)
+		for _, name := range strings.Split(query.Get(param), ",") {
+			attribute, _ := splitSCIMAttributePath(strings.TrimSpace(name))
+			if attribute != "" {
+				result[strings.ToLower(attribute)] = true
+			}
+		}
+		return result
+	}
+
+	attributes := names("attributes")
+	excluded := names("excludedAttributes")
+
+	for key := range resource {
+		lower := strings.ToLower(key)
+		if lower == "id" || lower == "schemas" {
+			continue
+		}
+
+		if (len(attributes) > 0 && !attributes[lower]) || excluded[lower] {
+			delete(resource, key)
+		}
+	}
+
+	return resource
+}
+
+// scimList responds with a page of the resources matching the filter
+// query parameter.
+func (a *API) scimList(w http.ResponseWriter, r *http.Request, filter scimFilter, resources []map[string]interface{}) error {
+	query := r.URL.Query()
+
+	maxResults := a.config.SCIM.MaxResults
+	if maxResults <= 0 {
+		maxResults = 100
+	}
+
+	startIndex := 1
+	if value := query.Get("startIndex"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return newSCIMError(http.StatusBadRequest, "invalidValue", "startIndex must be an integer")
+		}
+
+		if parsed > 1 {
+			startIndex = parsed
+		}
+	}
+
+	count := maxResults
+	if value := query.Get("count"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return newSCIMError(http.StatusBadRequest, "invalidValue", "count must be an integer")
+		}
+
+		if parsed < 0 {
+			parsed = 0
+		}
+
+		if parsed < count {
+			count = parsed
+		}
+	}
+
+	matching := []map[string]interface{}{}
+	for _, resource := range resources {
+		if filter == nil || filter.matches(resource) {
+			matching = append(matching, resource)
+		}
+	}
+
+	page := []map[string]interface{}{}
+	for i := startIndex - 1; i < len(matching) && len(page) < count; i += 1 {
+		page = append(page, scimProject(r, matching[i]))
+	}
+
+	return sendSCIM(w, http.StatusOK, &scimListResponse{
+		Schemas:      []string{scimListResponseSchema},
+		TotalResults: len(matching),
+		StartIndex:   startIndex,
+		ItemsPerPage: len(page),
+		Resources:    page,
+	})
+}
+
+func parseSCIMFilterParam(r *http.Request) (scimFilter, error) {
+	value := r.URL.Query().Get("filter")
+	if value == "" {
+		return nil, nil
+	}
+
+	filter, err := parseSCIMFilter(value)
+	if err != nil {
+		return nil, newSCIMError(http.StatusBadRequest, "invalidFilter", "Invalid filter: %v", err)
+	}
+
+	return filter, nil
+}
+
+// SCIMServiceProviderConfig describes the supported SCIM features.
+func (a *API) SCIMServiceProviderConfig(w http.ResponseWriter, r *http.Request) error {
+	supported := func(value bool) map[string]interface{} {
+		return map[string]interface{}{"supported": value}
+	}
+
+	return sendSCIM(w, http.StatusOK, map[string]interface{}{
+		"schemas":        []string{scimServiceProviderConfigSchema},
+		"patch":          supported(true),
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": a.config.SCIM.MaxResults},
+		"changePassword": supported(false),
+		"sort":           supported(false),
+		"etag":           supported(false),
+		"authenticationSchemes": []map[string]interface{}{
+			{
+				"type":        "oauthbearertoken",
+				"name":        "OAuth Bearer Token",
+				"description": "Authentication with the SCIM token of the SSO provider",
+				"primary":     true,
+			},
+		},
+		"meta": map[string]interface{}{
+			"resourceType": "ServiceProviderConfig",
+			"location":     strings.TrimSuffix(a.config.API.ExternalURL, "/") + "/scim/v2/ServiceProviderConfig",
+		},
+	})
+}
+
+// SCIMResourceTypes lists the supported SCIM resource types.
+func (a *API) SCIMResourceTypes(w http.ResponseWriter, r *http.Request) error {
+	resourceTypes := []map[string]interface{}{
+		{
+			"schemas":  []string{scimResourceTypeSchema},
+			"id":       "User",
+			"name":     "User",
+			"endpoint": "/Users",
+			"schema":   scimUserSchema,
+			"meta": map[string]interface{}{
+				"resourceType": "ResourceType",
+				"location":     a.scimLocation("ResourceTypes", "User"),
+			},
+		},
+		{
+			"schemas":  []string{scimResourceTypeSchema},
+			"id":       "Group",
+			"name":     "Group",
+			"endpoint": "/Groups",
+			"schema":   scimGroupSchema,
+			"meta": map[string]interface{}{
+				"resourceType": "ResourceType",
+				"location":     a.scimLocation("ResourceTypes", "Group"),
+			},
+		},
+	}
+
+	return sendSCIM(w, http.StatusOK, &scimListResponse{
+		Schemas:      []string{scimListResponseSchema},
+		TotalResults: len(resourceTypes),
+		StartIndex:   1,
+		ItemsPerPage: len(resourceTypes),
+		Resources:    resourceTypes,
+	})
+}
+
+func (a *API) scimUserResource(tx *storage.Connection, user *models.User, identity *models.Identity) (*scimUser, error) {
+	active := scimBoolean(!user.IsBanned())
+
+	resource := &scimUser{
+		Schemas:  []string{scimUserSchema},
+		ID:       user.ID.String(),
+		UserName: identity.ProviderID,
+		Active:   &active,
+		Meta: &scimMeta{
+			ResourceType: "User",
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+			Location:     a.scimLocation("Users", user.ID.String()),
+		},
+	}
+
+	if externalID, ok := identity.IdentityData["external_id"].(string); ok {
+		resource.ExternalID = externalID
+	}
+
+	if name, ok := user.UserMetaData["name"].(string); ok {
+		resource.DisplayName = name
+	}
+
+	name := &scimName{}
+	name.Formatted, _ = user.UserMetaData["full_name"].(string)
+	name.GivenName, _ = user.UserMetaData["given_name"].(string)
+	name.FamilyName, _ = user.UserMetaData["family_name"].(string)
+
+	if *name != (scimName{}) {
+		resource.Name = name
+	}
+
+	if email := user.GetEmail(); email != "" {
+		resource.Emails = []scimMultiValue{
+			{
+				Value:   email,
+				Type:    "work",
+				Primary: true,
+			},
+		}
+	}
+
+	groups, err := models.FindSCIMGroupsForUser(tx, user.ID)
+	if err != nil {
+		return nil, internalServerError("Database error loading SCIM groups").WithInternalError(err)
+	}
+
+	for _, group := range groups {
+		resource.Groups = append(resource.Groups, scimReference{
+			Value:   group.ID.String(),
+			Ref:     a.scimLocation("Groups", group.ID.String()),
+			Display: group.DisplayName,
+		})
+	}
+
+	return resource, nil
+}
+
+func (a *API) scimLoadUser(tx *storage.Connection, r *http.Request) (*models.User, *models.Identity, error) {
+	provider := getSSOProvider(r.Context())
+
+	userID, err := uuid.FromString(chi.URLParam(r, "user_id"))
+	if err != nil {
+		return nil, nil, newSCIMError(http.StatusNotFound, "", "User not found")
+	}
+
+	user, identity, err := models.FindSCIMUserByID(tx, provider.ID, userID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, nil, newSCIMError(http.StatusNotFound, "", "User not found")
+		}
+
+		return nil, nil, internalServerError("Database error finding user").WithInternalError(err)
+	}
+
+	return user, identity, nil
+}
+
+func (a *API) validateSCIMUser(in *scimUser) (string, error) {
+	in.UserName = strings.TrimSpace(in.UserName)
+	if in.UserName == "" {
+		return "", newSCIMError(http.StatusBadRequest, "invalidValue", "userName is required")
+	}
+
+	email := in.primaryEmail()
+	if email == "" {
+		return "", nil
+	}
+
+	email, err := a.validateEmail(email)
+	if err != nil {
+		return "", newSCIMError(http.StatusBadRequest, "invalidValue", "Invalid email address %q", in.primaryEmail())
+	}
+
+	return email, nil
+}
+
+// applySCIMUser updates the user and its identity from the SCIM
+// representation.
+func (a *API) applySCIMUser(tx *storage.Connection, provider *models.SSOProvider, user *models.User, identity *models.Identity, in *scimUser) error {
+	email, err := a.validateSCIMUser(in)
+	if err != nil {
+		return err
+	}
+
+	if in.UserName != identity.ProviderID {
+		if !strings.EqualFold(in.UserName, identity.ProviderID) {
+			existing, err := models.FindSCIMUsers(tx, provider.ID, in.UserName)
+			if err != nil {
+				return internalServerError("Database error finding user").WithInternalError(err)
+			}
+
+			if len(existing) > 0 {
+				return newSCIMError(http.StatusConflict, "uniqueness", "A user with userName %q already exists", in.UserName)
+			}
+		}
+
+		if err := identity.UpdateProviderID(tx, in.UserName); err != nil {
+			return internalServerError("Database error updating identity").WithInternalError(err)
+		}
+	}
+
+	metadata := in.metadata()
+
+	identityData := map[string]interface{}{
+		"sub":         in.UserName,
+		"email":       nil,
+		"external_id": nil,
+	}
+
+	for key, value := range metadata {
+		identityData[key] = value
+	}
+
+	if email != "" {
+		identityData["email"] = email
+	}
+
+	if in.ExternalID != "" {
+		identityData["external_id"] = in.ExternalID
+	}
+
+	if err := identity.UpdateIdentityData(tx, identityData); err != nil {
+		return internalServerError("Database error updating identity").WithInternalError(err)
+	}
+
+	if err := user.UpdateUserMetaData(tx, metadata); err != nil {
+		return internalServerError("Database error updating user").WithInternalError(err)
+	}
+
+	if email != user.GetEmail() {
+		if err := user.SetEmail(tx, email); err != nil {
+			return internalServerError("Database error updating user").WithInternalError(err)
+		}
+
+		if email != "" && !user.IsConfirmed() {
+			if err := user.Confirm(tx); err != nil {
+				return internalServerError("Database error updating user").WithInternalError(err)
+			}
+		}
+	}
+
+	if in.Active != nil {
+		if !bool(*in.Active) && !user.IsBanned() {
+			if err := user.Ban(tx, scimDeactivationDuration); err != nil {
+				return internalServerError("Database error deactivating user").WithInternalError(err)
+			}
+
+			if err := models.Logout(tx, user.ID); err != nil {
+				return internalServerError("Database error signing out user").WithInternalError(err)
+			}
+		} else if bool(*in.Active) && user.IsBanned() {
+			if err := user.Ban(tx, 0); err != nil {
+				return internalServerError("Database error activating user").WithInternalError(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// SCIMUsersList lists the users of the SSO provider.
+func (a *API) SCIMUsersList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	provider := getSSOProvider(ctx)
+
+	filter, err := parseSCIMFilterParam(r)
+	if err != nil {
+		return err
+	}
+
+	// the usual userName eq "..." lookup of identity providers is done in
+	// the database
+	userName := ""
+	if f, ok := filter.(*scimAttributeFilter); ok && f.operator == "eq" && f.subAttribute == "" && strings.EqualFold(f.attribute, "userName") {
+		if value, ok := f.value.(string); ok {
+			userName = value
+		}
+	}
+
+	users, err := models.FindSCIMUsers(db, provider.ID, userName)
+	if err != nil {
+		return internalServerError("Database error finding users").WithInternalError(err)
+	}
+
+	resources := make([]map[string]interface{}, 0, len(users))
+	for _, user := range users {
+		_, identity, err := models.FindSCIMUserByID(db, provider.ID, user.ID)
+		if err != nil {
+			return internalServerError("Database error finding user").WithInternalError(err)
+		}
+
+		resource, err := a.scimUserResource(db, user, identity)
+		if err != nil {
+			return err
+		}
+
+		m, err := scimResourceMap(resource)
+		if err != nil {
+			return err
+		}
+
+		resources = append(resources, m)
+	}
+
+	return a.scimList(w, r, filter, resources)
+}
+
+// SCIMUsersGet returns a user of the SSO provider.
+func (a *API) SCIMUsersGet(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	user, identity, err := a.scimLoadUser(db, r)
+	if err != nil {
+		return err
+	}
+
+	resource, err := a.scimUserResource(db, user, identity)
+	if err != nil {
+		return err
+	}
+
+	m, err := scimResourceMap(resource)
+	if err != nil {
+		return err
+	}
+
+	return sendSCIM(w, http.StatusOK, scimProject(r, m))
+}
+
+// SCIMUsersCreate provisions a user of the SSO provider. The user signs in
+// with SAML when the NameID of the assertions matches the userName.
+func (a *API) SCIMUsersCreate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	provider := getSSOProvider(ctx)
+	providerType := models.SSOProviderIdentity(provider.ID)
+
+	in := &scimUser{}
+	if err := readSCIMBody(r, in); err != nil {
+		return err
+	}
+
+	if _, err := a.validateSCIMUser(in); err != nil {
+		return err
+	}
+
+	var resource *scimUser
+
+	err := db.Transaction(func(tx *storage.Connection) error {
+		existing, terr := models.FindSCIMUsers(tx, provider.ID, in.UserName)
+		if terr != nil {
+			return internalServerError("Database error finding user").WithInternalError(terr)
+		}
+
+		if len(existing) > 0 {
+			return newSCIMError(http.StatusConflict, "uniqueness", "A user with userName %q already exists", in.UserName)
+		}
+
+		// the email address is set and confirmed with the other attributes
+		user, terr := models.NewUser("", "", "", a.config.JWT.Aud, nil)
+		if terr != nil {
+			return internalServerError("Error creating user").WithInternalError(terr)
+		}
+
+		user.IsSSOUser = true
+
+		if user, terr = a.signupNewUser(tx, user); terr != nil {
+			return terr
+		}
+
+		identity, terr := a.createNewIdentity(tx, user, providerType, map[string]interface{}{
+			"sub": in.UserName,
+		})
+		if terr != nil {
+			return terr
+		}
+
+		if terr := user.UpdateAppMetaDataProviders(tx); terr != nil {
+			return internalServerError("Database error updating user").WithInternalError(terr)
+		}
+
+		if role := provider.Provisioning().DefaultRole; role != "" {
+			if terr := user.SetRole(tx, role); terr != nil {
+				return internalServerError("Database error updating user").WithInternalError(terr)
+			}
+		}
+
+		if terr := a.applySCIMUser(tx, provider, user, identity, in); terr != nil {
+			return terr
+		}
+
+		if terr := models.NewAuditLogEntry(r, tx, user, models.UserSignedUpAction, "", map[string]interface{}{
+			"provider": providerType,
+			"scim":     true,
+		}); terr != nil {
+			return internalServerError("Error recording audit log entry").WithInternalError(terr)
+		}
+
+		resource, terr = a.scimUserResource(tx, user, identity)
+		return terr
+	})
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Location", resource.Meta.Location)
+
+	return sendSCIM(w, http.StatusCreated, resource)
+}
+
+func (a *API) scimUpdateUser(w http.ResponseWriter, r *http.Request, update func(current *scimUser) (*scimUser, error)) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	provider := getSSOProvider(ctx)
+
+	var resource *scimUser
+
+	err := db.Transaction(func(tx *storage.Connection) error {
+		user, identity, terr := a.scimLoadUser(tx, r)
+		if terr != nil {
+			return terr
+		}
+
+		current, terr := a.scimUserResource(tx, user, identity)
+		if terr != nil {
+			return terr
+		}
+
+		in, terr := update(current)
+		if terr != nil {
+			return terr
+		}
+
+		if terr := a.applySCIMUser(tx, provider, user, identity, in); terr != nil {
+			return terr
+		}
+
+		if terr := models.NewAuditLogEntry(r, tx, user, models.UserModifiedAction, "", map[string]interface{}{
+			"provider": models.SSOProviderIdentity(provider.ID),
+			"scim":     true,
+		}); terr != nil {
+			return internalServerError("Error recording audit log entry").WithInternalError(terr)
+		}
+
+		resource, terr = a.scimUserResource(tx, user, identity)
+		return terr
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendSCIM(w, http.StatusOK, resource)
+}
+
+// SCIMUsersReplace replaces the attributes of a user of the SSO provider.
+func (a *API) SCIMUsersReplace(w http.ResponseWriter, r *http.Request) error {
+	in := &scimUser{}
+	if err := readSCIMBody(r, in); err != nil {
+		return err
+	}
+
+	return a.scimUpdateUser(w, r, func(current *scimUser) (*scimUser, error) {
+		return in, nil
+	})
+}
+
+// SCIMUsersPatch applies PATCH operations to a user of the SSO provider,
+// which is how identity providers usually deactivate users.
+func (a *API) SCIMUsersPatch(w http.ResponseWriter, r *http.Request) error {
+	patch := &scimPatchRequest{}
+	if err := readSCIMBody(r, patch); err != nil {
+		return err
+	}
+
+	return a.scimUpdateUser(w, r, func(current *scimUser) (*scimUser, error) {
+		m, err := scimResourceMap(current)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := applySCIMPatch(m, patch.Operations); err != nil {
+			return nil, err
+		}
+
+		in := &scimUser{}
+		if err := scimResourceFromMap(m, in); err != nil {
+			return nil, err
+		}
+
+		return in, nil
+	})
+}
+
+// SCIMUsersDelete deprovisions a user of the SSO provider by deleting it.
+func (a *API) SCIMUsersDelete(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	provider := getSSOProvider(ctx)
+
+	err := db.Transaction(func(tx *storage.Connection) error {
+		user, _, terr := a.scimLoadUser(tx, r)
+		if terr != nil {
+			return terr
+		}
+
+		if terr := models.NewAuditLogEntry(r, tx, user, models.UserDeletedAction, "", map[string]interface{}{
+			"user_id":  user.ID,
+			"provider": models.SSOProviderIdentity(provider.ID),
+			"scim":     true,
+		}); terr != nil {
+			return internalServerError("Error recording audit log entry").WithInternalError(terr)
+		}
+
+		if terr := tx.Destroy(user); terr != nil {
+			return internalServerError("Database error deleting user").WithInternalError(terr)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (a *API) scimGroupResource(tx *storage.Connection, provider *models.SSOProvider, group *models.SCIMGroup) (*scimGroup, error) {
+	resource := &scimGroup{
+		Schemas:     []string{scimGroupSchema},
+		ID:          group.ID.String(),
+		DisplayName: group.DisplayName,
+		Members:     []scimReference{},
+		Meta: &scimMeta{
+			ResourceType: "Group",
+			Created:      group.CreatedAt,
+			LastModified: group.UpdatedAt,
+			Location:     a.scimLocation("Groups", group.ID.String()),
+		},
+	}
+
+	if group.ExternalID != nil {
+		resource.ExternalID = *group.ExternalID
+	}
+
+	members, err := models.FindSCIMGroupMembers(tx, group.ID)
+	if err != nil {
+		return nil, internalServerError("Database error loading SCIM group members").WithInternalError(err)
+	}
+
+	for _, member := range members {
+		reference := scimReference{
+			Value: member.String(),
+			Ref:   a.scimLocation("Users", member.String()),
+		}
+
+		if _, identity, err := models.FindSCIMUserByID(tx, provider.ID, member); err == nil {
+			reference.Display = identity.ProviderID
+		} else if !models.IsNotFoundError(err) {
+			return nil, internalServerError("Database error finding user").WithInternalError(err)
+		}
+
+		resource.Members = append(resource.Members, reference)
+	}
+
+	return resource, nil
+}
+
+func (a *API) scimLoadGroup(tx *storage.Connection, r *http.Request) (*models.SCIMGroup, error) {
+	provider := getSSOProvider(r.Context())
+
+	groupID, err := uuid.FromString(chi.URLParam(r, "group_id"))
+	if err != nil {
+		return nil, newSCIMError(http.StatusNotFound, "", "Group not found")
+	}
+
+	group, err := models.FindSCIMGroupByID(tx, provider.ID, groupID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, newSCIMError(http.StatusNotFound, "", "Group not found")
+		}
+
+		return nil, internalServerError("Database error finding group").WithInternalError(err)
+	}
+
+	return group, nil
+}
+
+// syncSCIMGroups records the names of the groups of the users in their
+// app_metadata, so that they are available in the access token claims.
+func syncSCIMGroups(tx *storage.Connection, userIDs []uuid.UUID) error {
+	for _, userID := range userIDs {
+		user, err := models.FindUserByID(tx, userID)
+		if err != nil {
+			if models.IsNotFoundError(err) {
+				continue
+			}
+
+			return internalServerError("Database error finding user").WithInternalError(err)
+		}
+
+		groups, err := models.FindSCIMGroupsForUser(tx, userID)
+		if err != nil {
+			return internalServerError("Database error loading SCIM groups").WithInternalError(err)
+		}
+
+		names := make([]string, 0, len(groups))
+		for _, group := range groups {
+			names = append(names, group.DisplayName)
+		}
+		slices.Sort(names)
+
+		if err := user.UpdateAppMetaData(tx, map[string]interface{}{
+			"scim": map[string]interface{}{
+				"groups": names,
+			},
+		}); err != nil {
+			return internalServerError("Database error updating user").WithInternalError(err)
+		}
+	}
+
+	return nil
+}
+
+// applySCIMGroup updates the group and its members from the SCIM
+// representation.
+func (a *API) applySCIMGroup(tx *storage.Connection, provider *models.SSOProvider, group *models.SCIMGroup, in *scimGroup, create bool) error {
+	in.DisplayName = strings.TrimSpace(in.DisplayName)
+	if in.DisplayName == "" {
+		return newSCIMError(http.StatusBadRequest, "invalidValue", "displayName is required")
+	}
+
+	groups, err := models.FindSCIMGroups(tx, provider.ID)
+	if err != nil {
+		return internalServerError("Database error finding groups").WithInternalError(err)
+	}
+
+	for _, existing := range groups {
+		if existing.ID != group.ID && strings.EqualFold(existing.DisplayName, in.DisplayName) {
+			return newSCIMError(http.StatusConflict, "uniqueness", "A group with displayName %q already exists", in.DisplayName)
+		}
+	}
+
+	var memberIDs []uuid.UUID
+	for _, member := range in.Members {
+		userID, err := uuid.FromString(member.Value)
+		if err != nil {
+			return newSCIMError(http.StatusBadRequest, "invalidValue", "Member %q is not a user", member.Value)
+		}
+
+		if _, _, err := models.FindSCIMUserByID(tx, provider.ID, userID); err != nil {
+			if models.IsNotFoundError(err) {
+				return newSCIMError(http.StatusBadRequest, "invalidValue", "Member %q is not a user", member.Value)
+			}
+
+			return internalServerError("Database error finding user").WithInternalError(err)
+		}
+
+		memberIDs = append(memberIDs, userID)
+	}
+
+	group.DisplayName = in.DisplayName
+	group.ExternalID = nil
+	if in.ExternalID != "" {
+		group.ExternalID = &in.ExternalID
+	}
+
+	if create {
+		if err := tx.Create(group); err != nil {
+			return internalServerError("Database error creating group").WithInternalError(err)
+		}
+	} else if err := tx.Update(group); err != nil {
+		return internalServerError("Database error updating group").WithInternalError(err)
+	}
+
+	previousIDs, err := models.FindSCIMGroupMembers(tx, group.ID)
+	if err != nil {
+		return internalServerError("Database error loading SCIM group members").WithInternalError(err)
+	}
+
+	if err := models.SetSCIMGroupMembers(tx, group.ID, memberIDs); err != nil {
+		return internalServerError("Database error updating SCIM group members").WithInternalError(err)
+	}
+
+	return syncSCIMGroups(tx, append(previousIDs, memberIDs...))
+}
+
+// SCIMGroupsList lists the groups of the SSO provider.
+func (a *API) SCIMGroupsList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	provider := getSSOProvider(ctx)
+
+	filter, err := parseSCIMFilterParam(r)
+	if err != nil {
+		return err
+	}
+
+	groups, err := models.FindSCIMGroups(db, provider.ID)
+	if err != nil {
+		return internalServerError("Database error finding groups").WithInternalError(err)
+	}
+
+	resources := make([]map[string]interface{}, 0, len(groups))
+	for i := range groups {
+		resource, err := a.scimGroupResource(db, provider, &groups[i])
+		if err != nil {
+			return err
+		}
+
+		m, err := scimResourceMap(resource)
+		if err != nil {
+			return err
+		}
+
+		resources = append(resources, m)
+	}
+
+	return a.scimList(w, r, filter, resources)
+}
+
+// SCIMGroupsGet returns a group of the SSO provider.
+func (a *API) SCIMGroupsGet(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	provider := getSSOProvider(ctx)
+
+	group, err := a.scimLoadGroup(db, r)
+	if err != nil {
+		return err
+	}
+
+	resource, err := a.scimGroupResource(db, provider, group)
+	if err != nil {
+		return err
+	}
+
+	m, err := scimResourceMap(resource)
+	if err != nil {
+		return err
+	}
+
+	return sendSCIM(w, http.StatusOK, scimProject(r, m))
+}
+
+// SCIMGroupsCreate provisions a group of the SSO provider.
+func (a *API) SCIMGroupsCreate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	provider := getSSOProvider(ctx)
+
+	in := &scimGroup{}
+	if err := readSCIMBody(r, in); err != nil {
+		return err
+	}
+
+	var resource *scimGroup
+
+	err := db.Transaction(func(tx *storage.Connection) error {
+		group := &models.SCIMGroup{
+			ID:            uuid.Must(uuid.NewV4()),
+			SSOProviderID: provider.ID,
+		}
+
+		if terr := a.applySCIMGroup(tx, provider, group, in, true); terr != nil {
+			return terr
+		}
+
+		var terr error
+		resource, terr = a.scimGroupResource(tx, provider, group)
+		return terr
+	})
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Location", resource.Meta.Location)
+
+	return sendSCIM(w, http.StatusCreated, resource)
+}
+
+func (a *API) scimUpdateGroup(w http.ResponseWriter, r *http.Request, update func(current *scimGroup) (*scimGroup, error)) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	provider := getSSOProvider(ctx)
+
+	var resource *scimGroup
+
+	err := db.Transaction(func(tx *storage.Connection) error {
+		group, terr := a.scimLoadGroup(tx, r)
+		if terr != nil {
+			return terr
+		}
+
+		current, terr := a.scimGroupResource(tx, provider, group)
+		if terr != nil {
+			return terr
+		}
+
+		in, terr := update(current)
+		if terr != nil {
+			return terr
+		}
+
+		if terr := a.applySCIMGroup(tx, provider, group, in, false); terr != nil {
+			return terr
+		}
+
+		resource, terr = a.scimGroupResource(tx, provider, group)
+		return terr
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendSCIM(w, http.StatusOK, resource)
+}
+
+// SCIMGroupsReplace replaces the attributes and members of a group of the
+// SSO provider.
+func (a *API) SCIMGroupsReplace(w http.ResponseWriter, r *http.Request) error {
+	in := &scimGroup{}
+	if err := readSCIMBody(r, in); err != nil {
+		return err
+	}
+
+	return a.scimUpdateGroup(w, r, func(current *scimGroup) (*scimGroup, error) {
+		return in, nil
+	})
+}
+
+// SCIMGroupsPatch applies PATCH operations to a group of the SSO provider,
+// which is how identity providers usually add and remove members.
+func (a *API) SCIMGroupsPatch(w http.ResponseWriter, r *http.Request) error {
+	patch := &scimPatchRequest{}
+	if err := readSCIMBody(r, patch); err != nil {
+		return err
+	}
+
+	return a.scimUpdateGroup(w, r, func(current *scimGroup) (*scimGroup, error) {
+		m, err := scimResourceMap(current)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := applySCIMPatch(m, patch.Operations); err != nil {
+			return nil, err
+		}
+
+		in := &scimGroup{}
+		if err := scimResourceFromMap(m, in); err != nil {
+			return nil, err
+		}
+
+		return in, nil
+	})
+}
+
+// SCIMGroupsDelete deletes a group of the SSO provider.
+func (a *API) SCIMGroupsDelete(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	err := db.Transaction(func(tx *storage.Connection) error {
+		group, terr := a.scimLoadGroup(tx, r)
+		if terr != nil {
+			return terr
+		}
+
+		memberIDs, terr := models.FindSCIMGroupMembers(tx, group.ID)
+		if terr != nil {
+			return internalServerError("Database error loading SCIM group members").WithInternalError(terr)
+		}
+
+		if terr := tx.Destroy(group); terr != nil {
+			return internalServerError("Database error deleting group").WithInternalError(terr)
+		}
+
+		return syncSCIMGroups(tx, memberIDs)
+	})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
@@ -0,0 +1,360 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+)
+
+type SCIMTestSuite struct {
+	suite.Suite
+	API      *API
+	Config   *conf.GlobalConfiguration
+	AdminJWT string
+}
+
+func TestSCIM(t *testing.T) {
+	api, config, err := setupAPIForTest()
+	require.NoError(t, err)
+
+	config.SCIM.Enabled = true
+
+	ts := &SCIMTestSuite{
+		API:    api,
+		Config: config,
+	}
+	defer api.db.Close()
+
+	if config.SAML.Enabled {
+		suite.Run(t, ts)
+	}
+}
+
+func (ts *SCIMTestSuite) SetupTest() {
+	models.TruncateAll(ts.API.db)
+
+	claims := &AccessTokenClaims{
+		Role: "supabase_admin",
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(ts.Config.JWT.Secret))
+	require.NoError(ts.T(), err, "Error generating admin jwt")
+
+	ts.AdminJWT = token
+}
+
+// createProvider creates an SSO provider and returns its ID and SCIM token.
+func (ts *SCIMTestSuite) createProvider(entityID string) (string, string) {
+	body, err := json.Marshal(map[string]interface{}{
+		"type":         "saml",
+		"metadata_xml": validSAMLIDPMetadata(entityID),
+	})
+	require.NoError(ts.T(), err)
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/admin/sso/providers", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer "+ts.AdminJWT)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusCreated, w.Code)
+
+	var provider struct {
+		ID string `json:"id"`
+	}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&provider))
+
+	req = httptest.NewRequest(http.MethodPost, "http://localhost/admin/sso/providers/"+provider.ID+"/scim/token", nil)
+	req.Header.Set("Authorization", "Bearer "+ts.AdminJWT)
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusCreated, w.Code)
+
+	var token SCIMTokenResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&token))
+	require.NotEmpty(ts.T(), token.Token)
+	require.Equal(ts.T(), "http://localhost/scim/v2", token.URL)
+
+	return provider.ID, token.Token
+}
+
+func (ts *SCIMTestSuite) scimRequest(method, path, token string, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+	var buffer bytes.Buffer
+	if body != nil {
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(body))
+	}
+
+	req := httptest.NewRequest(method, "http://localhost/scim/v2"+path, &buffer)
+	req.Header.Set("Content-Type", scimContentType)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	if w.Body.Len() > 0 {
+		require.Equal(ts.T(), scimContentType, w.Header().Get("Content-Type"))
+		require.NoError(ts.T(), json.Unmarshal(w.Body.Bytes(), &response))
+	}
+
+	return w, response
+}
+
+func (ts *SCIMTestSuite) createUser(token, userName string) string {
+	w, user := ts.scimRequest(http.MethodPost, "/Users", token, map[string]interface{}{
+		"schemas":  []string{scimUserSchema},
+		"userName": userName,
+		"name": map[string]interface{}{
+			"givenName":  "Barbara",
+			"familyName": "Jensen",
+		},
+		"emails": []interface{}{
+			map[string]interface{}{"value": userName, "type": "work", "primary": true},
+		},
+		"active": true,
+	})
+	require.Equal(ts.T(), http.StatusCreated, w.Code)
+
+	return user["id"].(string)
+}
+
+func (ts *SCIMTestSuite) TestAuthentication() {
+	_, token := ts.createProvider("https://example.com/saml/metadata")
+
+	w, response := ts.scimRequest(http.MethodGet, "/Users", "", nil)
+	require.Equal(ts.T(), http.StatusUnauthorized, w.Code)
+	require.Equal(ts.T(), []interface{}{scimErrorSchema}, response["schemas"])
+	require.Equal(ts.T(), "401", response["status"])
+
+	w, _ = ts.scimRequest(http.MethodGet, "/Users", "not-a-token", nil)
+	require.Equal(ts.T(), http.StatusUnauthorized, w.Code)
+
+	w, _ = ts.scimRequest(http.MethodGet, "/ServiceProviderConfig", token, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	ts.Config.SCIM.Enabled = false
+	defer func() {
+		ts.Config.SCIM.Enabled = true
+	}()
+
+	w, _ = ts.scimRequest(http.MethodGet, "/Users", token, nil)
+	require.Equal(ts.T(), http.StatusNotFound, w.Code)
+}
+
+func (ts *SCIMTestSuite) TestRevokeToken() {
+	providerID, token := ts.createProvider("https://example.com/saml/metadata")
+
+	req := httptest.NewRequest(http.MethodDelete, "http://localhost/admin/sso/providers/"+providerID+"/scim/token", nil)
+	req.Header.Set("Authorization", "Bearer "+ts.AdminJWT)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	w, _ = ts.scimRequest(http.MethodGet, "/Users", token, nil)
+	require.Equal(ts.T(), http.StatusUnauthorized, w.Code)
+}
+
+func (ts *SCIMTestSuite) TestUsers() {
+	providerID, token := ts.createProvider("https://example.com/saml/metadata")
+
+	userID := ts.createUser(token, "bjensen@example.com")
+
+	user, err := models.FindUserByID(ts.API.db, uuid.FromStringOrNil(userID))
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), user.IsSSOUser)
+	require.True(ts.T(), user.IsConfirmed())
+	require.Equal(ts.T(), "bjensen@example.com", user.GetEmail())
+	require.Equal(ts.T(), "Barbara", user.UserMetaData["given_name"])
+
+	identity, err := models.FindIdentityByIdAndProvider(ts.API.db, "bjensen@example.com", "sso:"+providerID)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), user.ID, identity.UserID)
+
+	// userName is unique within the provider
+	w, response := ts.scimRequest(http.MethodPost, "/Users", token, map[string]interface{}{
+		"userName": "BJENSEN@example.com",
+	})
+	require.Equal(ts.T(), http.StatusConflict, w.Code)
+	require.Equal(ts.T(), "uniqueness", response["scimType"])
+
+	ts.createUser(token, "other@example.com")
+
+	w, response = ts.scimRequest(http.MethodGet, `/Users?filter=userName+eq+%22bjensen%40example.com%22`, token, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	require.Equal(ts.T(), float64(1), response["totalResults"])
+	require.Equal(ts.T(), userID, response["Resources"].([]interface{})[0].(map[string]interface{})["id"])
+
+	w, response = ts.scimRequest(http.MethodGet, `/Users?filter=name.givenName+eq+%22Barbara%22&count=1`, token, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	require.Equal(ts.T(), float64(2), response["totalResults"])
+	require.Equal(ts.T(), float64(1), response["itemsPerPage"])
+
+	w, response = ts.scimRequest(http.MethodGet, `/Users?filter=userName+xx+%22a%22`, token, nil)
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+	require.Equal(ts.T(), "invalidFilter", response["scimType"])
+
+	// deactivation, as sent by Microsoft Entra ID
+	w, response = ts.scimRequest(http.MethodPatch, "/Users/"+userID, token, map[string]interface{}{
+		"schemas": []string{"urn:ietf:params:scim:api:messages:2.0:PatchOp"},
+		"Operations": []interface{}{
+			map[string]interface{}{"op": "Replace", "path": "active", "value": "False"},
+		},
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	require.Equal(ts.T(), false, response["active"])
+
+	user, err = models.FindUserByID(ts.API.db, user.ID)
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), user.IsBanned())
+
+	w, response = ts.scimRequest(http.MethodPatch, "/Users/"+userID, token, map[string]interface{}{
+		"Operations": []interface{}{
+			map[string]interface{}{"op": "replace", "value": map[string]interface{}{"active": true}},
+			map[string]interface{}{"op": "replace", "path": `emails[type eq "work"].value`, "value": "barbara@example.com"},
+		},
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	require.Equal(ts.T(), true, response["active"])
+
+	user, err = models.FindUserByID(ts.API.db, user.ID)
+	require.NoError(ts.T(), err)
+	require.False(ts.T(), user.IsBanned())
+	require.Equal(ts.T(), "barbara@example.com", user.GetEmail())
+
+	w, response = ts.scimRequest(http.MethodPut, "/Users/"+userID, token, map[string]interface{}{
+		"userName":    "barbara@example.com",
+		"externalId":  "00u1",
+		"displayName": "Babs Jensen",
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	require.Equal(ts.T(), "barbara@example.com", response["userName"])
+	require.Equal(ts.T(), "00u1", response["externalId"])
+	require.Equal(ts.T(), "Babs Jensen", response["displayName"])
+	require.NotContains(ts.T(), response, "name")
+
+	_, err = models.FindIdentityByIdAndProvider(ts.API.db, "barbara@example.com", "sso:"+providerID)
+	require.NoError(ts.T(), err)
+
+	w, _ = ts.scimRequest(http.MethodDelete, "/Users/"+userID, token, nil)
+	require.Equal(ts.T(), http.StatusNoContent, w.Code)
+
+	w, _ = ts.scimRequest(http.MethodGet, "/Users/"+userID, token, nil)
+	require.Equal(ts.T(), http.StatusNotFound, w.Code)
+
+	_, err = models.FindUserByID(ts.API.db, user.ID)
+	require.True(ts.T(), models.IsNotFoundError(err))
+}
+
+func (ts *SCIMTestSuite) TestUsersOfOtherProviders() {
+	_, tokenA := ts.createProvider("https://a.example.com/saml/metadata")
+	_, tokenB := ts.createProvider("https://b.example.com/saml/metadata")
+
+	userID := ts.createUser(tokenA, "bjensen@example.com")
+
+	w, _ := ts.scimRequest(http.MethodGet, "/Users/"+userID, tokenB, nil)
+	require.Equal(ts.T(), http.StatusNotFound, w.Code)
+
+	w, _ = ts.scimRequest(http.MethodDelete, "/Users/"+userID, tokenB, nil)
+	require.Equal(ts.T(), http.StatusNotFound, w.Code)
+
+	w, response := ts.scimRequest(http.MethodGet, "/Users", tokenB, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	require.Equal(ts.T(), float64(0), response["totalResults"])
+
+	// the same userName can be used with another provider
+	ts.createUser(tokenB, "bjensen@example.com")
+
+	w, response = ts.scimRequest(http.MethodPost, "/Groups", tokenB, map[string]interface{}{
+		"displayName": "Admins",
+		"members": []interface{}{
+			map[string]interface{}{"value": userID},
+		},
+	})
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+	require.Equal(ts.T(), "invalidValue", response["scimType"])
+}
+
+func (ts *SCIMTestSuite) TestGroups() {
+	_, token := ts.createProvider("https://example.com/saml/metadata")
+
+	userA := ts.createUser(token, "a@example.com")
+	userB := ts.createUser(token, "b@example.com")
+
+	w, group := ts.scimRequest(http.MethodPost, "/Groups", token, map[string]interface{}{
+		"schemas":     []string{scimGroupSchema},
+		"displayName": "Engineering",
+		"members": []interface{}{
+			map[string]interface{}{"value": userA},
+		},
+	})
+	require.Equal(ts.T(), http.StatusCreated, w.Code)
+	groupID := group["id"].(string)
+	require.Len(ts.T(), group["members"], 1)
+
+	w, response := ts.scimRequest(http.MethodPost, "/Groups", token, map[string]interface{}{
+		"displayName": "engineering",
+	})
+	require.Equal(ts.T(), http.StatusConflict, w.Code)
+	require.Equal(ts.T(), "uniqueness", response["scimType"])
+
+	groupsOf := func(userID string) interface{} {
+		user, err := models.FindUserByID(ts.API.db, uuid.FromStringOrNil(userID))
+		require.NoError(ts.T(), err)
+		return user.AppMetaData["scim"].(map[string]interface{})["groups"]
+	}
+
+	require.Equal(ts.T(), []interface{}{"Engineering"}, groupsOf(userA))
+
+	w, response = ts.scimRequest(http.MethodGet, "/Users/"+userA, token, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	require.Equal(ts.T(), groupID, response["groups"].([]interface{})[0].(map[string]interface{})["value"])
+
+	w, _ = ts.scimRequest(http.MethodPatch, "/Groups/"+groupID, token, map[string]interface{}{
+		"Operations": []interface{}{
+			map[string]interface{}{"op": "Add", "path": "members", "value": []interface{}{map[string]interface{}{"value": userB}}},
+			map[string]interface{}{"op": "Remove", "path": "members", "value": []interface{}{map[string]interface{}{"value": userA}}},
+			map[string]interface{}{"op": "Replace", "path": "displayName", "value": "Platform"},
+		},
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	require.Equal(ts.T(), []interface{}{}, groupsOf(userA))
+	require.Equal(ts.T(), []interface{}{"Platform"}, groupsOf(userB))
+
+	w, response = ts.scimRequest(http.MethodGet, `/Groups?filter=displayName+eq+%22platform%22&excludedAttributes=members`, token, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	require.Equal(ts.T(), float64(1), response["totalResults"])
+	require.NotContains(ts.T(), response["Resources"].([]interface{})[0], "members")
+
+	w, _ = ts.scimRequest(http.MethodPatch, "/Groups/"+groupID, token, map[string]interface{}{
+		"Operations": []interface{}{
+			map[string]interface{}{"op": "remove", "path": `members[value eq "` + userB + `"]`},
+		},
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	require.Equal(ts.T(), []interface{}{}, groupsOf(userB))
+
+	w, _ = ts.scimRequest(http.MethodPut, "/Groups/"+groupID, token, map[string]interface{}{
+		"displayName": "Platform",
+		"members": []interface{}{
+			map[string]interface{}{"value": userA},
+		},
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	require.Equal(ts.T(), []interface{}{"Platform"}, groupsOf(userA))
+
+	w, _ = ts.scimRequest(http.MethodDelete, "/Groups/"+groupID, token, nil)
+	require.Equal(ts.T(), http.StatusNoContent, w.Code)
+	require.Equal(ts.T(), []interface{}{}, groupsOf(userA))
+
+	w, _ = ts.scimRequest(http.MethodGet, "/Groups/"+groupID, token, nil)
+	require.Equal(ts.T(), http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,909 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// scimFilter is a parsed SCIM filter expression (RFC 7644 section
+// 3.4.2.2), evaluated against the JSON representation of a resource.
+type scimFilter interface {
+	matches(resource map[string]interface{}) bool
+}
+
+type scimLogicalFilter struct {
+	and   bool
+	left  scimFilter
+	right scimFilter
+}
+
+func (f *scimLogicalFilter) matches(resource map[string]interface{}) bool {
+	if f.and {
+		return f.left.matches(resource) && f.right.matches(resource)
+	}
+
+	return f.left.matches(resource) || f.right.matches(resource)
+}
+
+type scimNotFilter struct {
+	filter scimFilter
+}
+
+func (f *scimNotFilter) matches(resource map[string]interface{}) bool {
+	return !f.filter.matches(resource)
+}
+
+// scimAttributeFilter compares the values of an attribute, such as
+// userName eq "bjensen" or name.familyName pr.
+type scimAttributeFilter struct {
+	attribute    string
+	subAttribute string
+	operator     string
+	value        interface{}
+}
+
+func (f *scimAttributeFilter) matches(resource map[string]interface{}) bool {
+	values := scimAttributeValues(resource, f.attribute, f.subAttribute)
+
+	if f.operator == "pr" {
+		for _, value := range values {
+			if scimValuePresent(value) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if f.operator == "ne" {
+		for _, value := range values {
+			if scimCompare(value, "eq", f.value) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	if f.value == nil && f.operator == "eq" {
+		for _, value := range values {
+			if scimValuePresent(value) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	for _, value := range values {
+		if scimCompare(value, f.operator, f.value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scimValuePathFilter matches multi-valued complex attributes having at
+// least one value matching the filter, such as emails[type eq "work"].
+type scimValuePathFilter struct {
+	attribute string
+	filter    scimFilter
+}
+
+func (f *scimValuePathFilter) matches(resource map[string]interface{}) bool {
+	for _, element := range scimAttributeElements(resource, f.attribute) {
+		if f.filter.matches(element) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var scimComparisonOperators = map[string]bool{
+	"eq": true,
+	"ne": true,
+	"co": true,
+	"sw": true,
+	"ew": true,
+	"gt": true,
+	"ge": true,
+	"lt": true,
+	"le": true,
+}
+
+type scimFilterToken struct {
+	value  string
+	quoted bool
+}
+
+func tokenizeSCIMFilter(filter string) ([]scimFilterToken, error) {
+	var tokens []scimFilterToken
+
+	for i := 0; i < len(filter); {
+		c := filter[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i += 1
+
+		case c == '(' || c == ')' || c == '[' || c == ']':
+			tokens = append(tokens, scimFilterToken{value: string(c)})
+			i += 1
+
+		case c == '"':
+			end := i + 1
+			for ; end < len(filter); end += 1 {
+				if filter[end] == '\\' {
+					end += 1
+				} else if filter[end] == '"' {
+					break
+				}
+			}
+
+			if end >= len(filter) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+
+			value, err := strconv.Unquote(filter[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at position %d", i)
+			}
+
+			tokens = append(tokens, scimFilterToken{value: value, quoted: true})
+			i = end + 1
+
+		default:
+			end := i
+			for ; end < len(filter); end += 1 {
+				if strings.ContainsRune(" \t\n\r()[]\"", rune(filter[end])) {
+					break
+				}
+			}
+
+			tokens = append(tokens, scimFilterToken{value: filter[i:end]})
+			i = end
+		}
+	}
+
+	return tokens, nil
+}
+
+type scimFilterParser struct {
+	tokens   []scimFilterToken
+	position int
+}
+
+// parseSCIMFilter parses a SCIM filter expression. Attribute names are
+// matched case-insensitively and may be prefixed with their schema URN.
+func parseSCIMFilter(filter string) (scimFilter, error) {
+	tokens, err := tokenizeSCIMFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("filter is empty")
+	}
+
+	p := &scimFilterParser{tokens: tokens}
+
+	result, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.position < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.position].value)
+	}
+
+	return result, nil
+}
+
+func (p *scimFilterParser) peek() (scimFilterToken, bool) {
+	if p.position >= len(p.tokens) {
+		return scimFilterToken{}, false
+	}
+
+	return p.tokens[p.position], true
+}
+
+func (p *scimFilterParser) next() (scimFilterToken, error) {
+	token, ok := p.peek()
+	if !ok {
+		return token, fmt.Errorf("unexpected end of filter")
+	}
+
+	p.position += 1
+
+	return token, nil
+}
+
+func (p *scimFilterParser) peekKeyword(keyword string) bool {
+	token, ok := p.peek()
+	return ok && !token.quoted && strings.EqualFold(token.value, keyword)
+}
+
+func (p *scimFilterParser) expect(value string) error {
+	token, err := p.next()
+	if err != nil {
+		return err
+	}
+
+	if token.quoted || token.value != value {
+		return fmt.Errorf("expected %q but got %q", value, token.value)
+	}
+
+	return nil
+}
+
+func (p *scimFilterParser) parseOr() (scimFilter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peekKeyword("or") {
+		p.position += 1
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &scimLogicalFilter{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *scimFilterParser) parseAnd() (scimFilter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peekKeyword("and") {
+		p.position += 1
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &scimLogicalFilter{and: true, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *scimFilterParser) parseUnary() (scimFilter, error) {
+	if p.peekKeyword("not") {
+		p.position += 1
+
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+
+		return &scimNotFilter{filter: filter}, nil
+	}
+
+	token, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.quoted && token.value == "(" {
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+
+		return filter, nil
+	}
+
+	if token.quoted || strings.ContainsAny(token.value, "()[]") {
+		return nil, fmt.Errorf("expected an attribute but got %q", token.value)
+	}
+
+	if next, ok := p.peek(); ok && !next.quoted && next.value == "[" {
+		p.position += 1
+
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+
+		return &scimValuePathFilter{
+			attribute: scimAttributeName(token.value),
+			filter:    filter,
+		}, nil
+	}
+
+	attribute, subAttribute := splitSCIMAttributePath(token.value)
+
+	operatorToken, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	operator := strings.ToLower(operatorToken.value)
+
+	if !operatorToken.quoted && operator == "pr" {
+		return &scimAttributeFilter{
+			attribute:    attribute,
+			subAttribute: subAttribute,
+			operator:     operator,
+		}, nil
+	}
+
+	if operatorToken.quoted || !scimComparisonOperators[operator] {
+		return nil, fmt.Errorf("unsupported operator %q", operatorToken.value)
+	}
+
+	valueToken, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := parseSCIMFilterValue(valueToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &scimAttributeFilter{
+		attribute:    attribute,
+		subAttribute: subAttribute,
+		operator:     operator,
+		value:        value,
+	}, nil
+}
+
+func parseSCIMFilterValue(token scimFilterToken) (interface{}, error) {
+	if token.quoted {
+		return token.value, nil
+	}
+
+	switch strings.ToLower(token.value) {
+	case "true":
+		return true, nil
+
+	case "false":
+		return false, nil
+
+	case "null":
+		return nil, nil
+	}
+
+	number, err := strconv.ParseFloat(token.value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q", token.value)
+	}
+
+	return number, nil
+}
+
+// scimAttributeName strips the schema URN from an attribute path, so that
+// urn:ietf:params:scim:schemas:core:2.0:User:userName becomes userName.
+func scimAttributeName(path string) string {
+	if i := strings.LastIndex(path, ":"); i >= 0 {
+		return path[i+1:]
+	}
+
+	return path
+}
+
+func splitSCIMAttributePath(path string) (string, string) {
+	path = scimAttributeName(path)
+
+	if i := strings.Index(path, "."); i >= 0 {
+		return path[:i], path[i+1:]
+	}
+
+	return path, ""
+}
+
+// scimLookup returns the key of the map matching the attribute name
+// case-insensitively.
+func scimLookup(resource map[string]interface{}, name string) (string, bool) {
+	if _, ok := resource[name]; ok {
+		return name, true
+	}
+
+	for key := range resource {
+		if strings.EqualFold(key, name) {
+			return key, true
+		}
+	}
+
+	return name, false
+}
+
+func scimGet(resource map[string]interface{}, name string) interface{} {
+	key, ok := scimLookup(resource, name)
+	if !ok {
+		return nil
+	}
+
+	return resource[key]
+}
+
+// scimAttributeElements returns the complex values of a multi-valued
+// attribute.
+func scimAttributeElements(resource map[string]interface{}, attribute string) []map[string]interface{} {
+	var elements []map[string]interface{}
+
+	switch value := scimGet(resource, attribute).(type) {
+	case []interface{}:
+		for _, item := range value {
+			if element, ok := item.(map[string]interface{}); ok {
+				elements = append(elements, element)
+			}
+		}
+
+	case map[string]interface{}:
+		elements = append(elements, value)
+	}
+
+	return elements
+}
+
+// scimAttributeValues returns the simple values of the attribute. The
+// primary value sub-attribute is used for multi-valued complex attributes
+// when no sub-attribute is given.
+func scimAttributeValues(resource map[string]interface{}, attribute, subAttribute string) []interface{} {
+	var values []interface{}
+
+	var collect func(value interface{})
+	collect = func(value interface{}) {
+		switch v := value.(type) {
+		case []interface{}:
+			for _, item := range v {
+				collect(item)
+			}
+
+		case map[string]interface{}:
+			if subAttribute != "" {
+				values = append(values, scimGet(v, subAttribute))
+			} else {
+				values = append(values, scimGet(v, "value"))
+			}
+
+		default:
+			if subAttribute == "" {
+				values = append(values, v)
+			}
+		}
+	}
+
+	collect(scimGet(resource, attribute))
+
+	return values
+}
+
+func scimValuePresent(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+
+	case string:
+		return v != ""
+
+	case []interface{}:
+		return len(v) > 0
+
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+
+	return true
+}
+
+func scimCompare(value interface{}, operator string, expected interface{}) bool {
+	switch e := expected.(type) {
+	case string:
+		v, ok := value.(string)
+		if !ok {
+			return false
+		}
+
+		v = strings.ToLower(v)
+		e = strings.ToLower(e)
+
+		switch operator {
+		case "eq":
+			return v == e
+		case "co":
+			return strings.Contains(v, e)
+		case "sw":
+			return strings.HasPrefix(v, e)
+		case "ew":
+			return strings.HasSuffix(v, e)
+		case "gt":
+			return v > e
+		case "ge":
+			return v >= e
+		case "lt":
+			return v < e
+		case "le":
+			return v <= e
+		}
+
+	case bool:
+		v, ok := scimBooleanValue(value)
+		return ok && operator == "eq" && v == e
+
+	case float64:
+		v, ok := value.(float64)
+		if !ok {
+			return false
+		}
+
+		switch operator {
+		case "eq":
+			return v == e
+		case "gt":
+			return v > e
+		case "ge":
+			return v >= e
+		case "lt":
+			return v < e
+		case "le":
+			return v <= e
+		}
+	}
+
+	return false
+}
+
+// scimBooleanValue accepts booleans as well as the "True" and "False"
+// strings some identity providers send.
+func scimBooleanValue(value interface{}) (bool, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+
+	case string:
+		if b, err := strconv.ParseBool(strings.ToLower(v)); err == nil {
+			return b, true
+		}
+	}
+
+	return false, false
+}
+
+// scimPatchOperation is a single operation of a SCIM PATCH request (RFC
+// 7644 section 3.5.2).
+type scimPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// scimPatchPath is a parsed PATCH path of the form attr, attr.sub,
+// attr[filter] or attr[filter].sub.
+type scimPatchPath struct {
+	attribute    string
+	filter       scimFilter
+	subAttribute string
+}
+
+func parseSCIMPatchPath(path string) (*scimPatchPath, error) {
+	open := strings.Index(path, "[")
+	if open < 0 {
+		attribute, subAttribute := splitSCIMAttributePath(path)
+		if attribute == "" {
+			return nil, fmt.Errorf("invalid path %q", path)
+		}
+
+		return &scimPatchPath{
+			attribute:    attribute,
+			subAttribute: subAttribute,
+		}, nil
+	}
+
+	closing := strings.LastIndex(path, "]")
+	if closing < open {
+		return nil, fmt.Errorf("invalid path %q", path)
+	}
+
+	attribute := scimAttributeName(path[:open])
+	if attribute == "" {
+		return nil, fmt.Errorf("invalid path %q", path)
+	}
+
+	filter, err := parseSCIMFilter(path[open+1 : closing])
+	if err != nil {
+		return nil, err
+	}
+
+	rest := path[closing+1:]
+	if rest != "" && (!strings.HasPrefix(rest, ".") || len(rest) == 1) {
+		return nil, fmt.Errorf("invalid path %q", path)
+	}
+
+	return &scimPatchPath{
+		attribute:    attribute,
+		filter:       filter,
+		subAttribute: strings.TrimPrefix(rest, "."),
+	}, nil
+}
+
+// scimPatchError reports an operation that cannot be applied, with the
+// scimType of the error response.
+type scimPatchError struct {
+	scimType string
+	message  string
+}
+
+func (e *scimPatchError) Error() string {
+	return e.message
+}
+
+// applySCIMPatch applies the operations to the JSON representation of a
+// resource. Operation names are case-insensitive and operations without a
+// path accept attribute paths as keys of their value.
+func applySCIMPatch(resource map[string]interface{}, operations []scimPatchOperation) error {
+	for _, operation := range operations {
+		op := strings.ToLower(operation.Op)
+
+		switch op {
+		case "add", "replace":
+			if operation.Path == "" {
+				values, ok := operation.Value.(map[string]interface{})
+				if !ok {
+					return &scimPatchError{scimType: "invalidValue", message: fmt.Sprintf("%s operations without a path require an object value", operation.Op)}
+				}
+
+				for key, value := range values {
+					if err := applySCIMPatchPath(resource, op, key, value); err != nil {
+						return err
+					}
+				}
+			} else if err := applySCIMPatchPath(resource, op, operation.Path, operation.Value); err != nil {
+				return err
+			}
+
+		case "remove":
+			if operation.Path == "" {
+				return &scimPatchError{scimType: "noTarget", message: "remove operations require a path"}
+			}
+
+			if err := applySCIMPatchPath(resource, op, operation.Path, operation.Value); err != nil {
+				return err
+			}
+
+		default:
+			return &scimPatchError{scimType: "invalidSyntax", message: fmt.Sprintf("unsupported operation %q", operation.Op)}
+		}
+	}
+
+	return nil
+}
+
+func applySCIMPatchPath(resource map[string]interface{}, op, rawPath string, value interface{}) error {
+	path, err := parseSCIMPatchPath(rawPath)
+	if err != nil {
+		return &scimPatchError{scimType: "invalidPath", message: err.Error()}
+	}
+
+	key, _ := scimLookup(resource, path.attribute)
+
+	if path.filter != nil {
+		return applySCIMPatchFilter(resource, key, op, path, value)
+	}
+
+	if path.subAttribute != "" {
+		switch parent := resource[key].(type) {
+		case nil:
+			if op != "remove" {
+				resource[key] = map[string]interface{}{path.subAttribute: value}
+			}
+
+		case map[string]interface{}:
+			subKey, _ := scimLookup(parent, path.subAttribute)
+			if op == "remove" {
+				delete(parent, subKey)
+			} else {
+				parent[subKey] = scimMergeValue(parent[subKey], value, op)
+			}
+
+		case []interface{}:
+			for _, item := range parent {
+				if element, ok := item.(map[string]interface{}); ok {
+					subKey, _ := scimLookup(element, path.subAttribute)
+					if op == "remove" {
+						delete(element, subKey)
+					} else {
+						element[subKey] = value
+					}
+				}
+			}
+
+		default:
+			return &scimPatchError{scimType: "invalidPath", message: fmt.Sprintf("attribute %q has no sub-attributes", path.attribute)}
+		}
+
+		return nil
+	}
+
+	if op == "remove" {
+		// members of a group are removed by value, as in
+		// {"op": "remove", "path": "members", "value": [{"value": "<id>"}]}
+		if existing, ok := resource[key].([]interface{}); ok {
+			if removals, ok := value.([]interface{}); ok && len(removals) > 0 {
+				resource[key] = scimRemoveValues(existing, removals)
+				return nil
+			}
+		}
+
+		delete(resource, key)
+		return nil
+	}
+
+	resource[key] = scimMergeValue(resource[key], value, op)
+
+	return nil
+}
+
+func applySCIMPatchFilter(resource map[string]interface{}, key, op string, path *scimPatchPath, value interface{}) error {
+	var existing []interface{}
+	switch v := resource[key].(type) {
+	case nil:
+	case []interface{}:
+		existing = v
+	default:
+		return &scimPatchError{scimType: "invalidFilter", message: fmt.Sprintf("attribute %q is not multi-valued", path.attribute)}
+	}
+
+	matched := false
+	var kept []interface{}
+
+	for _, item := range existing {
+		element, ok := item.(map[string]interface{})
+		if !ok || !path.filter.matches(element) {
+			kept = append(kept, item)
+			continue
+		}
+
+		matched = true
+
+		switch {
+		case op == "remove" && path.subAttribute == "":
+			continue
+
+		case op == "remove":
+			subKey, _ := scimLookup(element, path.subAttribute)
+			delete(element, subKey)
+
+		case path.subAttribute != "":
+			subKey, _ := scimLookup(element, path.subAttribute)
+			element[subKey] = value
+
+		default:
+			values, ok := value.(map[string]interface{})
+			if !ok {
+				return &scimPatchError{scimType: "invalidValue", message: fmt.Sprintf("values of %q must be objects", path.attribute)}
+			}
+
+			for k, v := range values {
+				subKey, _ := scimLookup(element, k)
+				element[subKey] = v
+			}
+		}
+
+		kept = append(kept, element)
+	}
+
+	if !matched && op != "remove" {
+		// the value is added when the filter only consists of equality
+		// comparisons, as in emails[type eq "work"].value
+		element := make(map[string]interface{})
+		if !scimFilterEqualities(path.filter, element) {
+			return &scimPatchError{scimType: "noTarget", message: fmt.Sprintf("no values of %q match the filter", path.attribute)}
+		}
+
+		if path.subAttribute != "" {
+			element[path.subAttribute] = value
+		} else if values, ok := value.(map[string]interface{}); ok {
+			for k, v := range values {
+				element[k] = v
+			}
+		} else {
+			return &scimPatchError{scimType: "invalidValue", message: fmt.Sprintf("values of %q must be objects", path.attribute)}
+		}
+
+		kept = append(kept, element)
+	}
+
+	resource[key] = kept
+
+	return nil
+}
+
+// scimFilterEqualities collects the attributes of a filter made only of
+// eq comparisons joined with and.
+func scimFilterEqualities(filter scimFilter, into map[string]interface{}) bool {
+	switch f := filter.(type) {
+	case *scimLogicalFilter:
+		return f.and && scimFilterEqualities(f.left, into) && scimFilterEqualities(f.right, into)
+
+	case *scimAttributeFilter:
+		if f.operator != "eq" || f.subAttribute != "" {
+			return false
+		}
+
+		into[f.attribute] = f.value
+		return true
+	}
+
+	return false
+}
+
+func scimMergeValue(existing, value interface{}, op string) interface{} {
+	switch e := existing.(type) {
+	case []interface{}:
+		if op == "add" {
+			if values, ok := value.([]interface{}); ok {
+				return append(e, values...)
+			}
+
+			return append(e, value)
+		}
+
+	case map[string]interface{}:
+		if values, ok := value.(map[string]interface{}); ok {
+			for k, v := range values {
+				key, _ := scimLookup(e, k)
+				e[key] = v
+			}
+
+			return e
+		}
+	}
+
+	return value
+}
+
+func scimRemoveValues(existing, removals []interface{}) []interface{} {
+	remove := make(map[string]bool)
+	for _, removal := range removals {
+		if element, ok := removal.(map[string]interface{}); ok {
+			if value, ok := scimGet(element, "value").(string); ok {
+				remove[strings.ToLower(value)] = true
+			}
+		}
+	}
+
+	var kept []interface{}
+	for _, item := range existing {
+		if element, ok := item.(map[string]interface{}); ok {
+			if value, ok := scimGet(element, "value").(string); ok && remove[strings.ToLower(value)] {
+				continue
+			}
+		}
+
+		kept = append(kept, item)
+	}
+
+	return kept
+}
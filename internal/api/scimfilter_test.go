@@ -0,0 +1,227 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func scimTestResource(t *testing.T, resource string) map[string]interface{} {
+	var m map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(resource), &m))
+	return m
+}
+
+const scimTestUser = `{
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+	"id": "2819c223-7f76-453a-919d-413861904646",
+	"externalId": "bjensen",
+	"userName": "Bjensen@example.com",
+	"name": {
+		"givenName": "Barbara",
+		"familyName": "Jensen"
+	},
+	"emails": [
+		{"value": "bjensen@example.com", "type": "work", "primary": true},
+		{"value": "babs@jensen.org", "type": "home"}
+	],
+	"active": true,
+	"meta": {
+		"lastModified": "2024-05-13T04:42:34Z"
+	}
+}`
+
+func TestSCIMFilter(t *testing.T) {
+	resource := scimTestResource(t, scimTestUser)
+
+	cases := []struct {
+		filter  string
+		matches bool
+	}{
+		{`userName eq "bjensen@example.com"`, true},
+		{`UserName EQ "BJENSEN@EXAMPLE.COM"`, true},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName eq "bjensen@example.com"`, true},
+		{`userName eq "other@example.com"`, false},
+		{`userName ne "other@example.com"`, true},
+		{`userName sw "bjensen"`, true},
+		{`userName ew "example.com"`, true},
+		{`userName co "jensen@"`, true},
+		{`name.familyName eq "Jensen"`, true},
+		{`name.familyName eq "Smith"`, false},
+		{`name.formatted pr`, false},
+		{`title pr`, false},
+		{`externalId pr`, true},
+		{`emails co "jensen.org"`, true},
+		{`emails.type eq "home"`, true},
+		{`emails[type eq "work" and value co "@example.com"]`, true},
+		{`emails[type eq "work" and value co "@jensen.org"]`, false},
+		{`active eq true`, true},
+		{`active eq false`, false},
+		{`meta.lastModified gt "2024-01-01T00:00:00Z"`, true},
+		{`meta.lastModified lt "2024-01-01T00:00:00Z"`, false},
+		{`title eq null`, true},
+		{`userName eq "x" or name.givenName eq "Barbara"`, true},
+		{`userName eq "x" or name.givenName eq "Barbara" and active eq false`, false},
+		{`(userName eq "x" or name.givenName eq "Barbara") and active eq true`, true},
+		{`not (userName eq "bjensen@example.com")`, false},
+		{`userName eq "a \"quoted\" name"`, false},
+	}
+
+	for _, c := range cases {
+		filter, err := parseSCIMFilter(c.filter)
+		require.NoError(t, err, c.filter)
+		require.Equal(t, c.matches, filter.matches(resource), c.filter)
+	}
+}
+
+func TestSCIMFilterInvalid(t *testing.T) {
+	invalid := []string{
+		``,
+		`userName`,
+		`userName eq`,
+		`userName xx "a"`,
+		`userName eq "a`,
+		`userName eq unquoted`,
+		`(userName eq "a"`,
+		`userName eq "a" and`,
+		`emails[type eq "work"`,
+		`not userName eq "a"`,
+		`userName eq "a" "b"`,
+	}
+
+	for _, filter := range invalid {
+		_, err := parseSCIMFilter(filter)
+		require.Error(t, err, filter)
+	}
+}
+
+func TestSCIMPatch(t *testing.T) {
+	cases := []struct {
+		desc       string
+		operations string
+		check      func(t *testing.T, resource map[string]interface{})
+	}{
+		{
+			desc:       "Replace Without Path",
+			operations: `[{"op": "replace", "value": {"active": false, "name.givenName": "Babs"}}]`,
+			check: func(t *testing.T, resource map[string]interface{}) {
+				require.Equal(t, false, resource["active"])
+				require.Equal(t, "Babs", resource["name"].(map[string]interface{})["givenName"])
+				require.Equal(t, "Jensen", resource["name"].(map[string]interface{})["familyName"])
+			},
+		},
+		{
+			desc:       "Capitalized Operation And String Boolean",
+			operations: `[{"op": "Replace", "path": "active", "value": "False"}]`,
+			check: func(t *testing.T, resource map[string]interface{}) {
+				require.Equal(t, "False", resource["active"])
+			},
+		},
+		{
+			desc:       "Replace Filtered Sub-Attribute",
+			operations: `[{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "barbara@example.com"}]`,
+			check: func(t *testing.T, resource map[string]interface{}) {
+				emails := resource["emails"].([]interface{})
+				require.Len(t, emails, 2)
+				require.Equal(t, "barbara@example.com", emails[0].(map[string]interface{})["value"])
+				require.Equal(t, "babs@jensen.org", emails[1].(map[string]interface{})["value"])
+			},
+		},
+		{
+			desc:       "Add Filtered Sub-Attribute Without Match",
+			operations: `[{"op": "add", "path": "emails[type eq \"other\"].value", "value": "b@example.org"}]`,
+			check: func(t *testing.T, resource map[string]interface{}) {
+				emails := resource["emails"].([]interface{})
+				require.Len(t, emails, 3)
+				require.Equal(t, map[string]interface{}{"type": "other", "value": "b@example.org"}, emails[2])
+			},
+		},
+		{
+			desc:       "Remove Filtered Values",
+			operations: `[{"op": "remove", "path": "emails[type eq \"home\"]"}]`,
+			check: func(t *testing.T, resource map[string]interface{}) {
+				require.Len(t, resource["emails"].([]interface{}), 1)
+			},
+		},
+		{
+			desc:       "Remove Attribute",
+			operations: `[{"op": "remove", "path": "name.familyName"}, {"op": "remove", "path": "externalId"}]`,
+			check: func(t *testing.T, resource map[string]interface{}) {
+				require.NotContains(t, resource["name"], "familyName")
+				require.NotContains(t, resource, "externalId")
+			},
+		},
+		{
+			desc:       "Add Values",
+			operations: `[{"op": "add", "path": "emails", "value": [{"value": "c@example.com"}]}, {"op": "add", "path": "title", "value": "Tour Guide"}]`,
+			check: func(t *testing.T, resource map[string]interface{}) {
+				require.Len(t, resource["emails"].([]interface{}), 3)
+				require.Equal(t, "Tour Guide", resource["title"])
+			},
+		},
+		{
+			desc:       "Remove Values By Value",
+			operations: `[{"op": "remove", "path": "emails", "value": [{"value": "BABS@jensen.org"}]}]`,
+			check: func(t *testing.T, resource map[string]interface{}) {
+				emails := resource["emails"].([]interface{})
+				require.Len(t, emails, 1)
+				require.Equal(t, "bjensen@example.com", emails[0].(map[string]interface{})["value"])
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			resource := scimTestResource(t, scimTestUser)
+
+			var operations []scimPatchOperation
+			require.NoError(t, json.Unmarshal([]byte(c.operations), &operations))
+
+			require.NoError(t, applySCIMPatch(resource, operations))
+			c.check(t, resource)
+		})
+	}
+}
+
+func TestSCIMPatchInvalid(t *testing.T) {
+	invalid := map[string]string{
+		`[{"op": "move", "path": "active"}]`:                                 "invalidSyntax",
+		`[{"op": "remove"}]`:                                                 "noTarget",
+		`[{"op": "replace", "value": "x"}]`:                                  "invalidValue",
+		`[{"op": "replace", "path": "emails[type eq", "value": "x"}]`:        "invalidPath",
+		`[{"op": "replace", "path": "emails[type co \"x\"]", "value": 1}]`:   "noTarget",
+		`[{"op": "replace", "path": "userName[type eq \"x\"]", "value": 1}]`: "invalidFilter",
+	}
+
+	for operations, scimType := range invalid {
+		resource := scimTestResource(t, scimTestUser)
+
+		var ops []scimPatchOperation
+		require.NoError(t, json.Unmarshal([]byte(operations), &ops))
+
+		err := applySCIMPatch(resource, ops)
+		require.Error(t, err, operations)
+		require.Equal(t, scimType, err.(*scimPatchError).scimType, operations)
+	}
+}
+
+func TestSCIMUserFromPatchedResource(t *testing.T) {
+	resource := scimTestResource(t, scimTestUser)
+
+	require.NoError(t, applySCIMPatch(resource, []scimPatchOperation{
+		{Op: "Replace", Path: "active", Value: "False"},
+	}))
+
+	user := &scimUser{}
+	require.NoError(t, scimResourceFromMap(resource, user))
+	require.NotNil(t, user.Active)
+	require.False(t, bool(*user.Active))
+	require.Equal(t, "bjensen@example.com", user.primaryEmail())
+	require.Equal(t, map[string]interface{}{
+		"name":        nil,
+		"full_name":   nil,
+		"given_name":  "Barbara",
+		"family_name": "Jensen",
+	}, user.metadata())
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// sessionLimitRevokedReason is the reason of the revocations of sessions
+// evicted for the cap of active sessions, in the audit log.
+const sessionLimitRevokedReason = "session_limit"
+
+// enforceSessionLimit makes room for a new session of the user when it's at
+// its cap of active sessions, by rejecting the sign-in or revoking its
+// oldest or least recently used sessions. Impersonation sessions aren't
+// counted.
+func (a *API) enforceSessionLimit(r *http.Request, tx *storage.Connection, user *models.User) error {
+	config := a.config.Sessions
+
+	if !config.IsLimited() {
+		return nil
+	}
+
+	var roleNames []string
+	if len(config.MaxPerRole) > 0 {
+		roles, err := models.FindRolesForUser(tx, user.ID)
+		if err != nil {
+			return internalServerError("Database error finding roles").WithInternalError(err)
+		}
+		roleNames, _ = models.RoleClaims(roles)
+	}
+
+	max := config.MaxSessions(roleNames)
+	if max == 0 {
+		return nil
+	}
+
+	if err := models.LockUserSessions(tx, user.ID); err != nil {
+		return internalServerError("Database error locking sessions").WithInternalError(err)
+	}
+
+	timebox, inactivityTimeout, err := a.sessionLifetime(tx, user)
+	if err != nil {
+		return internalServerError("Database error finding session lifetime").WithInternalError(err)
+	}
+
+	sessions, err := models.FindAllSessionsForUser(tx, user.ID, false)
+	if err != nil {
+		return internalServerError("Database error finding sessions").WithInternalError(err)
+	}
+
+	now := time.Now()
+	active := make([]*models.Session, 0, len(sessions))
+	for _, session := range sessions {
+		if session.ImpersonatedBy != nil {
+			continue
+		}
+
+		if session.CheckValidity(now, nil, timebox, inactivityTimeout) != models.SessionValid {
+			continue
+		}
+
+		active = append(active, session)
+	}
+
+	if len(active) < max {
+		return nil
+	}
+
+	if config.LimitBehavior == conf.SessionLimitReject {
+		return forbiddenError(ErrorCodeSessionLimitReached, "Maximum number of active sessions reached")
+	}
+
+	if config.LimitBehavior == conf.SessionLimitEvictLeastRecentlyUsed {
+		slices.SortFunc(active, func(a, b *models.Session) int {
+			return a.LastRefreshedAt(nil).Compare(b.LastRefreshedAt(nil))
+		})
+	} else {
+		slices.SortFunc(active, func(a, b *models.Session) int {
+			return a.CreatedAt.Compare(b.CreatedAt)
+		})
+	}
+
+	for _, session := range active[:len(active)-max+1] {
+		if err := models.NewAuditLogEntry(r, tx, user, models.SessionRevokedAction, "", map[string]interface{}{
+			"user_id":    user.ID,
+			"session_id": session.ID,
+			"reason":     sessionLimitRevokedReason,
+		}); err != nil {
+			return internalServerError("Error recording audit log entry").WithInternalError(err)
+		}
+
+		if err := models.LogoutSession(tx, session.ID); err != nil {
+			return internalServerError("Database error revoking session").WithInternalError(err)
+		}
+	}
+
+	return nil
+}
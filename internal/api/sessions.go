@@ -0,0 +1,219 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/revocation"
+	"github.com/supabase/auth/internal/storage"
+	"github.com/supabase/auth/internal/utilities"
+)
+
+// SessionResponse is a session of a user, as listed to the user and to
+// admins.
+type SessionResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	RefreshedAt    time.Time  `json:"refreshed_at"`
+	NotAfter       *time.Time `json:"not_after,omitempty"`
+	UserAgent      *string    `json:"user_agent,omitempty"`
+	DeviceName     string     `json:"device_name,omitempty"`
+	DeviceID       *string    `json:"device_id,omitempty"`
+	IP             *string    `json:"ip,omitempty"`
+	Country        *string    `json:"country,omitempty"`
+	Region         *string    `json:"region,omitempty"`
+	City           *string    `json:"city,omitempty"`
+	AAL            string     `json:"aal"`
+	Tag            *string    `json:"tag,omitempty"`
+	ImpersonatedBy *string    `json:"impersonated_by,omitempty"`
+	Current        bool       `json:"current"`
+}
+
+// sessionLifetime returns the timebox and inactivity timeout of the
+// sessions of the user, which depend on its roles and tags when there are
+// lifetime policies for them.
+func (a *API) sessionLifetime(db *storage.Connection, user *models.User) (timebox, inactivityTimeout *time.Duration, err error) {
+	config := a.config.Sessions
+
+	if !config.HasLifetimePolicies() {
+		return config.Timebox, config.InactivityTimeout, nil
+	}
+
+	var roleNames, tagNames []string
+	if config.HasRoleLifetimePolicies() {
+		roles, err := models.FindRolesForUser(db, user.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		roleNames, _ = models.RoleClaims(roles)
+	}
+
+	if config.HasTagLifetimePolicies() {
+		tags, err := models.FindUserTagsByUserID(db, user.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, tag := range tags {
+			tagNames = append(tagNames, tag.Name)
+		}
+	}
+
+	timebox, inactivityTimeout = config.Lifetime(roleNames, tagNames)
+	return timebox, inactivityTimeout, nil
+}
+
+// listActiveSessions returns the sessions of the user that can still be
+// refreshed, the most recently refreshed first. The current session is
+// marked when there's one.
+func (a *API) listActiveSessions(db *storage.Connection, user *models.User, current *models.Session) ([]*SessionResponse, error) {
+	timebox, inactivityTimeout, err := a.sessionLifetime(db, user)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := models.FindAllSessionsForUser(db, user.ID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	responses := []*SessionResponse{}
+	for _, session := range sessions {
+		if session.CheckValidity(now, nil, timebox, inactivityTimeout) != models.SessionValid {
+			continue
+		}
+
+		aal := models.AAL1.String()
+		if session.AAL != nil {
+			aal = *session.AAL
+		}
+
+		deviceName := ""
+		if session.UserAgent != nil {
+			deviceName = utilities.DeviceName(*session.UserAgent)
+		}
+
+		responses = append(responses, &SessionResponse{
+			ID:             session.ID,
+			CreatedAt:      session.CreatedAt,
+			RefreshedAt:    session.LastRefreshedAt(nil),
+			NotAfter:       session.NotAfter,
+			UserAgent:      session.UserAgent,
+			DeviceName:     deviceName,
+			DeviceID:       session.DeviceID,
+			IP:             session.IP,
+			Country:        session.Country,
+			Region:         session.Region,
+			City:           session.City,
+			AAL:            aal,
+			Tag:            session.Tag,
+			ImpersonatedBy: session.ImpersonatedBy,
+			Current:        current != nil && current.ID == session.ID,
+		})
+	}
+
+	slices.SortFunc(responses, func(a, b *SessionResponse) int {
+		return b.RefreshedAt.Compare(a.RefreshedAt)
+	})
+
+	return responses, nil
+}
+
+// revokeSession deletes the session of the user with the ID in the URL,
+// with its refresh tokens, so it can't be refreshed or used anymore.
+func (a *API) revokeSession(r *http.Request, db *storage.Connection, actor, user *models.User) error {
+	sessionID, err := uuid.FromString(chi.URLParam(r, "session_id"))
+	if err != nil {
+		return notFoundError(ErrorCodeSessionNotFound, "Session not found")
+	}
+
+	session, err := models.FindSessionByID(db, sessionID, false)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return notFoundError(ErrorCodeSessionNotFound, "Session not found")
+		}
+		return internalServerError("Database error finding session").WithInternalError(err)
+	}
+	if session.UserID != user.ID {
+		return notFoundError(ErrorCodeSessionNotFound, "Session not found")
+	}
+
+	err = db.Transaction(func(tx *storage.Connection) error {
+		if terr := models.NewAuditLogEntry(r, tx, actor, models.SessionRevokedAction, "", map[string]interface{}{
+			"user_id":    user.ID,
+			"session_id": session.ID,
+		}); terr != nil {
+			return terr
+		}
+
+		return models.LogoutSession(tx, session.ID)
+	})
+	if err != nil {
+		return internalServerError("Database error revoking session").WithInternalError(err)
+	}
+
+	a.propagateRevocation(r, revocation.SessionRevocation(user.ID, session.ID))
+
+	return nil
+}
+
+// UserSessionsList lists the active sessions of the user.
+func (a *API) UserSessionsList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	sessions, err := a.listActiveSessions(db, getUser(ctx), getSession(ctx))
+	if err != nil {
+		return internalServerError("Database error finding sessions").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"sessions": sessions,
+	})
+}
+
+// UserSessionRevoke revokes one session of the user, like the one of a
+// lost device, without signing the user out of the others. Revoking the
+// current session signs the user out.
+func (a *API) UserSessionRevoke(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+
+	if err := a.revokeSession(r, db, user, user); err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+// adminUserSessionsList lists the active sessions of the user.
+func (a *API) adminUserSessionsList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	sessions, err := a.listActiveSessions(db, getUser(ctx), nil)
+	if err != nil {
+		return internalServerError("Database error finding sessions").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"sessions": sessions,
+	})
+}
+
+// adminUserSessionRevoke revokes one session of the user.
+func (a *API) adminUserSessionRevoke(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	if err := a.revokeSession(r, db, getAdminUser(ctx), getUser(ctx)); err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
@@ -4,6 +4,7 @@ import "net/http"
 
 type ProviderSettings struct {
 	AnonymousUsers bool `json:"anonymous_users"`
+	Amazon         bool `json:"amazon"`
 	Apple          bool `json:"apple"`
 	Azure          bool `json:"azure"`
 	Bitbucket      bool `json:"bitbucket"`
@@ -15,11 +16,21 @@ type ProviderSettings struct {
 	GitLab         bool `json:"gitlab"`
 	Google         bool `json:"google"`
 	Keycloak       bool `json:"keycloak"`
+	LDAP           bool `json:"ldap"`
 	Kakao          bool `json:"kakao"`
+	Line           bool `json:"line"`
 	Linkedin       bool `json:"linkedin"`
 	LinkedinOIDC   bool `json:"linkedin_oidc"`
 	Notion         bool `json:"notion"`
+	Okta           bool `json:"okta"`
+	PayPal         bool `json:"paypal"`
+	Salesforce     bool `json:"salesforce"`
 	Spotify        bool `json:"spotify"`
+	Steam          bool `json:"steam"`
+	Telegram       bool `json:"telegram"`
+	VK             bool `json:"vk"`
+	WeChat         bool `json:"wechat"`
+	Web3           bool `json:"web3"`
 	Slack          bool `json:"slack"`
 	SlackOIDC      bool `json:"slack_oidc"`
 	WorkOS         bool `json:"workos"`
@@ -27,24 +38,57 @@ type ProviderSettings struct {
 	Twitter        bool `json:"twitter"`
 	Email          bool `json:"email"`
 	Phone          bool `json:"phone"`
+	Username       bool `json:"username"`
 	Zoom           bool `json:"zoom"`
+
+	// Remote holds whether each remote provider is enabled.
+	Remote map[string]bool `json:"remote,omitempty"`
 }
 
 type Settings struct {
-	ExternalProviders ProviderSettings `json:"external"`
-	DisableSignup     bool             `json:"disable_signup"`
-	MailerAutoconfirm bool             `json:"mailer_autoconfirm"`
-	PhoneAutoconfirm  bool             `json:"phone_autoconfirm"`
-	SmsProvider       string           `json:"sms_provider"`
-	SAMLEnabled       bool             `json:"saml_enabled"`
+	ExternalProviders        ProviderSettings `json:"external"`
+	DisableSignup            bool             `json:"disable_signup"`
+	MailerAutoconfirm        bool             `json:"mailer_autoconfirm"`
+	MailerVerificationMethod string           `json:"mailer_verification_method"`
+	PhoneAutoconfirm         bool             `json:"phone_autoconfirm"`
+	SmsProvider              string           `json:"sms_provider"`
+	SAMLEnabled              bool             `json:"saml_enabled"`
+	KerberosEnabled          bool             `json:"kerberos_enabled"`
+	SSOOIDCEnabled           bool             `json:"sso_oidc_enabled"`
+	Consent                  *ConsentSettings `json:"consent,omitempty"`
+}
+
+// ConsentSettings are the current versions of the documents users accept.
+type ConsentSettings struct {
+	TermsVersion         string `json:"terms_version,omitempty"`
+	PrivacyPolicyVersion string `json:"privacy_policy_version,omitempty"`
+	RequireAcceptance    bool   `json:"require_acceptance"`
 }
 
 func (a *API) Settings(w http.ResponseWriter, r *http.Request) error {
 	config := a.config
 
+	var remote map[string]bool
+	if len(config.External.Remote) > 0 {
+		remote = make(map[string]bool, len(config.External.Remote))
+		for name, ext := range config.External.Remote {
+			remote[name] = ext.Enabled
+		}
+	}
+
+	var consent *ConsentSettings
+	if config.Consent.Enabled {
+		consent = &ConsentSettings{
+			TermsVersion:         config.Consent.TermsVersion,
+			PrivacyPolicyVersion: config.Consent.PrivacyPolicyVersion,
+			RequireAcceptance:    config.Consent.RequireAcceptance,
+		}
+	}
+
 	return sendJSON(w, http.StatusOK, &Settings{
 		ExternalProviders: ProviderSettings{
 			AnonymousUsers: config.External.AnonymousUsers.Enabled,
+			Amazon:         config.External.Amazon.Enabled,
 			Apple:          config.External.Apple.Enabled,
 			Azure:          config.External.Azure.Enabled,
 			Bitbucket:      config.External.Bitbucket.Enabled,
@@ -57,10 +101,20 @@ func (a *API) Settings(w http.ResponseWriter, r *http.Request) error {
 			Google:         config.External.Google.Enabled,
 			Kakao:          config.External.Kakao.Enabled,
 			Keycloak:       config.External.Keycloak.Enabled,
+			LDAP:           config.External.LDAP.Enabled,
+			Line:           config.External.Line.Enabled,
 			Linkedin:       config.External.Linkedin.Enabled,
 			LinkedinOIDC:   config.External.LinkedinOIDC.Enabled,
 			Notion:         config.External.Notion.Enabled,
+			Okta:           config.External.Okta.Enabled,
+			PayPal:         config.External.PayPal.Enabled,
+			Salesforce:     config.External.Salesforce.Enabled,
 			Spotify:        config.External.Spotify.Enabled,
+			Steam:          config.External.Steam.Enabled,
+			Telegram:       config.External.Telegram.Enabled,
+			VK:             config.External.VK.Enabled,
+			WeChat:         config.External.WeChat.Enabled,
+			Web3:           config.External.Web3.Enabled,
 			Slack:          config.External.Slack.Enabled,
 			SlackOIDC:      config.External.SlackOIDC.Enabled,
 			Twitch:         config.External.Twitch.Enabled,
@@ -68,12 +122,18 @@ func (a *API) Settings(w http.ResponseWriter, r *http.Request) error {
 			WorkOS:         config.External.WorkOS.Enabled,
 			Email:          config.External.Email.Enabled,
 			Phone:          config.External.Phone.Enabled,
+			Username:       config.Username.Enabled,
 			Zoom:           config.External.Zoom.Enabled,
+			Remote:         remote,
 		},
-		DisableSignup:     config.DisableSignup,
-		MailerAutoconfirm: config.Mailer.Autoconfirm,
-		PhoneAutoconfirm:  config.Sms.Autoconfirm,
-		SmsProvider:       config.Sms.Provider,
-		SAMLEnabled:       config.SAML.Enabled,
+		DisableSignup:            config.DisableSignup,
+		MailerAutoconfirm:        config.Mailer.Autoconfirm,
+		MailerVerificationMethod: config.Mailer.VerificationMethod,
+		PhoneAutoconfirm:         config.Sms.Autoconfirm,
+		SmsProvider:              config.Sms.Provider,
+		SAMLEnabled:              config.SAML.Enabled,
+		KerberosEnabled:          config.Kerberos.Enabled,
+		SSOOIDCEnabled:           config.SSOOIDC.Enabled,
+		Consent:                  consent,
 	})
 }
@@ -28,17 +28,28 @@ func TestSettings_DefaultProviders(t *testing.T) {
 
 	require.False(t, p.Phone)
 	require.True(t, p.Email)
+	require.True(t, p.Amazon)
 	require.True(t, p.Azure)
 	require.True(t, p.Bitbucket)
 	require.True(t, p.Discord)
 	require.True(t, p.Facebook)
 	require.True(t, p.Notion)
+	require.True(t, p.Okta)
+	require.True(t, p.PayPal)
+	require.True(t, p.Salesforce)
 	require.True(t, p.Spotify)
+	require.True(t, p.Steam)
+	require.True(t, p.Telegram)
+	require.True(t, p.VK)
+	require.True(t, p.WeChat)
+	require.True(t, p.Web3)
 	require.True(t, p.Slack)
 	require.True(t, p.SlackOIDC)
 	require.True(t, p.Google)
 	require.True(t, p.Kakao)
 	require.True(t, p.Keycloak)
+	require.False(t, p.LDAP)
+	require.True(t, p.Line)
 	require.True(t, p.Linkedin)
 	require.True(t, p.LinkedinOIDC)
 	require.True(t, p.GitHub)
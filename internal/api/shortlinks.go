@@ -0,0 +1,87 @@
+package api
+
+import (
+	"crypto/rand"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/utilities"
+)
+
+// shortLinkAlphabet are the characters of the slugs, which are safe in
+// URLs and not changed by the gateways and filters that mangle long links.
+const shortLinkAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// generateShortLinkSlug returns a random slug of the length.
+func generateShortLinkSlug(length int) (string, error) {
+	max := big.NewInt(int64(len(shortLinkAlphabet)))
+
+	var slug strings.Builder
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		slug.WriteByte(shortLinkAlphabet[n.Int64()])
+	}
+
+	return slug.String(), nil
+}
+
+// shortLinkBaseURL returns the URL the short links start with.
+func (a *API) shortLinkBaseURL() string {
+	config := a.config
+	if config.ShortLinks.BaseURL != "" {
+		return strings.TrimSuffix(config.ShortLinks.BaseURL, "/")
+	}
+
+	return strings.TrimSuffix(config.API.ExternalURL, "/") + "/s"
+}
+
+// shortenURL stores a short link to the long URL, and returns it.
+func (a *API) shortenURL(longURL string) (string, error) {
+	config := a.config
+
+	slug, err := generateShortLinkSlug(config.ShortLinks.SlugLength)
+	if err != nil {
+		return "", err
+	}
+
+	link := models.NewShortLink(slug, longURL, time.Now().Add(config.ShortLinks.Expiry))
+	if err := a.db.Create(link); err != nil {
+		return "", errors.Wrap(err, "Database error creating short link")
+	}
+
+	return a.shortLinkBaseURL() + "/" + slug, nil
+}
+
+// RedirectShortLink redirects to the long URL of a short link, once. Used
+// and expired links redirect to the site URL with an error.
+func (a *API) RedirectShortLink(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+
+	link, err := models.UseShortLink(db, chi.URLParam(r, "slug"))
+	if err != nil {
+		if !models.IsNotFoundError(err) {
+			return internalServerError("Database error finding short link").WithInternalError(err)
+		}
+
+		rurl, err := a.prepErrorRedirectURL(forbiddenError(ErrorCodeOTPExpired, "Link is invalid or has expired"), r, utilities.GetReferrer(r, config), models.ImplicitFlow)
+		if err != nil {
+			return err
+		}
+
+		http.Redirect(w, r, rurl, http.StatusSeeOther)
+		return nil
+	}
+
+	http.Redirect(w, r, link.TargetURL, http.StatusSeeOther)
+	return nil
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+)
+
+func TestGenerateShortLinkSlug(t *testing.T) {
+	slug, err := generateShortLinkSlug(10)
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[a-zA-Z0-9]{10}$`), slug)
+
+	other, err := generateShortLinkSlug(10)
+	require.NoError(t, err)
+	assert.NotEqual(t, slug, other)
+}
+
+func TestShortLinkBaseURL(t *testing.T) {
+	a := &API{
+		config: &conf.GlobalConfiguration{
+			API: conf.APIConfiguration{
+				ExternalURL: "https://auth.example.com/auth/v1/",
+			},
+		},
+	}
+	assert.Equal(t, "https://auth.example.com/auth/v1/s", a.shortLinkBaseURL())
+
+	a.config.ShortLinks.BaseURL = "https://go.example.com/"
+	assert.Equal(t, "https://go.example.com", a.shortLinkBaseURL())
+}
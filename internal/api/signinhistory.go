@@ -0,0 +1,96 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+)
+
+const (
+	// defaultSignInActivityLimit is how many sign-ins are listed when no
+	// limit is given, and maxSignInActivityLimit how many can be.
+	defaultSignInActivityLimit = 50
+	maxSignInActivityLimit     = 200
+)
+
+// recordSignIn keeps the successful sign-in of the user in its history,
+// when the history is enabled.
+func (a *API) recordSignIn(tx *storage.Connection, user *models.User, method models.AuthenticationMethod, grantParams models.GrantParams) error {
+	if !a.config.SignInHistory.Enabled {
+		return nil
+	}
+
+	event := models.NewSignInEvent(user.ID, method, grantParams.Provider, grantParams.IP, grantParams.UserAgent, "")
+	if err := tx.Create(event); err != nil {
+		return internalServerError("Database error recording sign in").WithInternalError(err)
+	}
+
+	return nil
+}
+
+// recordFailedSignIn keeps the failed sign-in of the user in its history,
+// when the history is enabled. It's recorded outside of any transaction as
+// the sign-in fails, and errors are only logged.
+func (a *API) recordFailedSignIn(r *http.Request, user *models.User, method models.AuthenticationMethod, grantParams models.GrantParams, reason string) {
+	if !a.config.SignInHistory.Enabled {
+		return
+	}
+
+	db := a.db.WithContext(r.Context())
+
+	event := models.NewSignInEvent(user.ID, method, grantParams.Provider, grantParams.IP, grantParams.UserAgent, reason)
+	if err := db.Create(event); err != nil {
+		observability.GetLogEntry(r).Entry.WithError(err).WithFields(logrus.Fields{
+			"user_id": user.ID,
+		}).Warn("Unable to record failed sign in")
+	}
+}
+
+// listSignInActivity returns the latest sign-ins of the user, up to the
+// limit query parameter.
+func (a *API) listSignInActivity(r *http.Request, user *models.User) ([]*models.SignInEvent, error) {
+	db := a.db.WithContext(r.Context())
+
+	limit := defaultSignInActivityLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxSignInActivityLimit {
+			return nil, badRequestError(ErrorCodeValidationFailed, "limit must be between 1 and %d", maxSignInActivityLimit)
+		}
+	}
+
+	events, err := models.FindSignInEventsByUserID(db, user.ID, limit)
+	if err != nil {
+		return nil, internalServerError("Database error finding sign in activity").WithInternalError(err)
+	}
+
+	return events, nil
+}
+
+// UserSignInActivity lists the latest sign-ins of the user.
+func (a *API) UserSignInActivity(w http.ResponseWriter, r *http.Request) error {
+	events, err := a.listSignInActivity(r, getUser(r.Context()))
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"events": events,
+	})
+}
+
+// adminUserSignInActivity lists the latest sign-ins of the user.
+func (a *API) adminUserSignInActivity(w http.ResponseWriter, r *http.Request) error {
+	events, err := a.listSignInActivity(r, getUser(r.Context()))
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"events": events,
+	})
+}
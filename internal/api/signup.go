@@ -19,6 +19,7 @@ import (
 type SignupParams struct {
 	Email               string                 `json:"email"`
 	Phone               string                 `json:"phone"`
+	Username            string                 `json:"username"`
 	Password            string                 `json:"password"`
 	Data                map[string]interface{} `json:"data"`
 	Provider            string                 `json:"-"`
@@ -26,6 +27,11 @@ type SignupParams struct {
 	Channel             string                 `json:"channel"`
 	CodeChallengeMethod string                 `json:"code_challenge_method"`
 	CodeChallenge       string                 `json:"code_challenge"`
+
+	// TermsVersion and PrivacyPolicyVersion are the versions of the
+	// documents the user accepts by signing up.
+	TermsVersion         string `json:"terms_version"`
+	PrivacyPolicyVersion string `json:"privacy_policy_version"`
 }
 
 func (a *API) validateSignupParams(ctx context.Context, p *SignupParams) error {
@@ -35,7 +41,7 @@ func (a *API) validateSignupParams(ctx context.Context, p *SignupParams) error {
 		return badRequestError(ErrorCodeValidationFailed, "Signup requires a valid password")
 	}
 
-	if err := a.checkPasswordStrength(ctx, p.Password); err != nil {
+	if err := a.checkPasswordStrength(ctx, p.Password, passwordUserInputs(p.Email, p.Phone, p.Username, p.Data)...); err != nil {
 		return err
 	}
 	if p.Email != "" && p.Phone != "" {
@@ -60,6 +66,8 @@ func (p *SignupParams) ConfigureDefaults() {
 		p.Provider = "email"
 	} else if p.Phone != "" {
 		p.Provider = "phone"
+	} else if p.Username != "" {
+		p.Provider = "username"
 	}
 	if p.Data == nil {
 		p.Data = make(map[string]interface{})
@@ -77,6 +85,8 @@ func (params *SignupParams) ToUserModel(isSSOUser bool) (user *models.User, err
 		user, err = models.NewUser("", params.Email, params.Password, params.Aud, params.Data)
 	case "phone":
 		user, err = models.NewUser(params.Phone, "", params.Password, params.Aud, params.Data)
+	case "username":
+		user, err = models.NewUser("", "", params.Password, params.Aud, params.Data)
 	case "anonymous":
 		user, err = models.NewUser("", "", "", params.Aud, params.Data)
 		user.IsAnonymous = true
@@ -89,6 +99,7 @@ func (params *SignupParams) ToUserModel(isSSOUser bool) (user *models.User, err
 		return
 	}
 	user.IsSSOUser = isSSOUser
+	user.Username = storage.NullString(params.Username)
 	if user.AppMetaData == nil {
 		user.AppMetaData = make(map[string]interface{})
 	}
@@ -126,6 +137,26 @@ func (a *API) Signup(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	if err := a.validateUserMetadata(params.Data); err != nil {
+		return err
+	}
+
+	consent := &ConsentParams{
+		TermsVersion:         params.TermsVersion,
+		PrivacyPolicyVersion: params.PrivacyPolicyVersion,
+	}
+	if err := a.validateConsentParams(consent); err != nil {
+		return err
+	}
+
+	if config.Localization.Enabled {
+		// the locale is kept in the metadata of new users, so the emails
+		// and SMS messages sent later are in their language too
+		if _, ok := params.Data[config.Localization.MetadataKey]; !ok {
+			params.Data[config.Localization.MetadataKey] = config.Localization.UserLocale(nil, r.Header.Get("Accept-Language"))
+		}
+	}
+
 	var err error
 	flowType := getFlowFromChallenge(params.CodeChallenge)
 
@@ -136,6 +167,16 @@ func (a *API) Signup(w http.ResponseWriter, r *http.Request) error {
 
 	params.Aud = a.requestAud(ctx, r)
 
+	if params.Username != "" {
+		if !config.Username.Enabled {
+			return badRequestError(ErrorCodeUsernameProviderDisabled, "Username signups are disabled")
+		}
+		params.Username, err = a.validateUsername(params.Username)
+		if err != nil {
+			return err
+		}
+	}
+
 	switch params.Provider {
 	case "email":
 		if !config.External.Email.Enabled {
@@ -145,7 +186,10 @@ func (a *API) Signup(w http.ResponseWriter, r *http.Request) error {
 		if err != nil {
 			return err
 		}
-		user, err = models.IsDuplicatedEmail(db, params.Email, params.Aud, nil)
+		if err := a.validateEmailDomain(params.Email); err != nil {
+			return err
+		}
+		user, err = a.findDuplicateEmail(db, params.Email, params.Aud, nil)
 	case "phone":
 		if !config.External.Phone.Enabled {
 			return badRequestError(ErrorCodePhoneProviderDisabled, "Phone signups are disabled")
@@ -155,6 +199,10 @@ func (a *API) Signup(w http.ResponseWriter, r *http.Request) error {
 			return err
 		}
 		user, err = models.FindUserByPhoneAndAudience(db, params.Phone, params.Aud)
+	case "username":
+		// users signing up with only a username have nothing to confirm,
+		// so a taken username can't be hidden like a registered email
+		err = checkUsernameAvailable(db, params.Username, nil)
 	default:
 		msg := ""
 		if config.External.Email.Enabled && config.External.Phone.Enabled {
@@ -171,9 +219,18 @@ func (a *API) Signup(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	if err != nil && !models.IsNotFoundError(err) {
+		if _, ok := err.(*HTTPError); ok {
+			return err
+		}
 		return internalServerError("Database error finding user").WithInternalError(err)
 	}
 
+	if params.Username != "" && params.Provider != "username" {
+		if err := checkUsernameAvailable(db, params.Username, user); err != nil {
+			return err
+		}
+	}
+
 	var signupUser *models.User
 	if user == nil {
 		// always call this outside of a database transaction as this method
@@ -196,6 +253,9 @@ func (a *API) Signup(w http.ResponseWriter, r *http.Request) error {
 			if terr != nil {
 				return terr
 			}
+			if terr = a.acceptConsent(r, tx, user, consent); terr != nil {
+				return terr
+			}
 		}
 		identity, terr := models.FindIdentityByIdAndProvider(tx, user.ID.String(), params.Provider)
 		if terr != nil {
@@ -206,6 +266,9 @@ func (a *API) Signup(w http.ResponseWriter, r *http.Request) error {
 				Subject: user.ID.String(),
 				Email:   user.GetEmail(),
 			})
+			if params.Provider == "username" {
+				identityData["username"] = user.GetUsername()
+			}
 			for k, v := range params.Data {
 				if _, ok := identityData[k]; !ok {
 					identityData[k] = v
@@ -268,6 +331,12 @@ func (a *API) Signup(w http.ResponseWriter, r *http.Request) error {
 					return terr
 				}
 			}
+		} else if params.Provider == "username" {
+			if terr = models.NewAuditLogEntry(r, tx, user, models.UserSignedUpAction, "", map[string]interface{}{
+				"provider": params.Provider,
+			}); terr != nil {
+				return terr
+			}
 		}
 
 		return nil
@@ -298,8 +367,9 @@ func (a *API) Signup(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	// handles case where Mailer.Autoconfirm is true or Phone.Autoconfirm is true
-	if user.IsConfirmed() || user.IsPhoneConfirmed() {
+	// handles case where Mailer.Autoconfirm is true or Phone.Autoconfirm is
+	// true, or the user signed up with only a username
+	if user.IsConfirmed() || user.IsPhoneConfirmed() || params.Provider == "username" {
 		var token *AccessTokenResponse
 		err = db.Transaction(func(tx *storage.Connection) error {
 			var terr error
@@ -308,6 +378,7 @@ func (a *API) Signup(w http.ResponseWriter, r *http.Request) error {
 			}); terr != nil {
 				return terr
 			}
+			grantParams.Provider = params.Provider
 			token, terr = a.issueRefreshToken(r, tx, user, models.PasswordGrant, grantParams)
 
 			if terr != nil {
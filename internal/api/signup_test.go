@@ -151,3 +151,52 @@ func (ts *SignupTestSuite) TestVerifySignup() {
 	require.NotEmpty(ts.T(), v.Get("expires_in"))
 	require.NotEmpty(ts.T(), v.Get("refresh_token"))
 }
+
+func (ts *SignupTestSuite) TestSignupUsername() {
+	ts.Config.Username.Enabled = true
+	defer func() {
+		ts.Config.Username.Enabled = false
+	}()
+
+	signup := func(username string) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+			"username": username,
+			"password": "test123",
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "/signup", &buffer)
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := signup(" Jane.Doe ")
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	data := AccessTokenResponse{}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	require.NotEmpty(ts.T(), data.Token)
+	assert.Equal(ts.T(), "jane.doe", data.User.GetUsername())
+	assert.Equal(ts.T(), "username", data.User.AppMetaData["provider"])
+
+	w = signup("jane.doe")
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+
+	w = signup("admin")
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+
+	var buffer bytes.Buffer
+	require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+		"username": "JANE.DOE",
+		"password": "test123",
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/token?grant_type=password", &buffer)
+	req.Header.Set("Content-Type", "application/json")
+
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+}
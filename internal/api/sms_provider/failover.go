@@ -0,0 +1,176 @@
+package sms_provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	// providerFailureThreshold is how many consecutive failures make a
+	// provider unhealthy.
+	providerFailureThreshold = 3
+
+	// providerCooldown is how long an unhealthy provider is tried after the
+	// healthy ones, before it's tried in order again.
+	providerCooldown = 30 * time.Second
+
+	// providerScoreWeight is the weight of the latest send in the health
+	// score of a provider.
+	providerScoreWeight = 0.2
+)
+
+var providerSendCounter = observability.ObtainMetricCounter("gotrue_sms_provider_sends", "Number of SMS sends by provider and result")
+
+// providerHealth is the health of an SMS provider, from the results of the
+// messages sent with it.
+type providerHealth struct {
+	// score is the moving average of successful sends, from 0 to 1.
+	score               float64
+	consecutiveFailures int
+	unhealthyUntil      time.Time
+}
+
+// healthTracker tracks the health of the SMS providers across requests, so
+// providers that are down are tried last until they have cooled down.
+type healthTracker struct {
+	mutex     sync.Mutex
+	providers map[string]*providerHealth
+	now       func() time.Time
+}
+
+var defaultHealthTracker = newHealthTracker()
+
+func newHealthTracker() *healthTracker {
+	return &healthTracker{
+		providers: make(map[string]*providerHealth),
+		now:       time.Now,
+	}
+}
+
+func (h *healthTracker) health(name string) *providerHealth {
+	health, ok := h.providers[name]
+	if !ok {
+		health = &providerHealth{score: 1}
+		h.providers[name] = health
+	}
+	return health
+}
+
+// record updates the health of the provider with the result of a send.
+func (h *healthTracker) record(name string, err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	health := h.health(name)
+	if err == nil {
+		health.score = health.score*(1-providerScoreWeight) + providerScoreWeight
+		health.consecutiveFailures = 0
+		health.unhealthyUntil = time.Time{}
+		return
+	}
+
+	health.score = health.score * (1 - providerScoreWeight)
+	health.consecutiveFailures += 1
+	if health.consecutiveFailures >= providerFailureThreshold {
+		health.unhealthyUntil = h.now().Add(providerCooldown)
+	}
+}
+
+// order returns the providers in the order they should be tried: the healthy
+// ones in their configured order, then the unhealthy ones by health score.
+func (h *healthTracker) order(names []string) []string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	now := h.now()
+
+	var healthy, unhealthy []string
+	for _, name := range names {
+		if now.Before(h.health(name).unhealthyUntil) {
+			unhealthy = append(unhealthy, name)
+		} else {
+			healthy = append(healthy, name)
+		}
+	}
+
+	sort.SliceStable(unhealthy, func(i, j int) bool {
+		return h.providers[unhealthy[i]].score > h.providers[unhealthy[j]].score
+	})
+
+	return append(healthy, unhealthy...)
+}
+
+// FailoverProvider sends messages with the providers of the route of the
+// phone number, failing over to the next one on errors and timeouts.
+type FailoverProvider struct {
+	Config    *conf.SmsProviderConfiguration
+	Providers map[string]SmsProvider
+
+	health *healthTracker
+}
+
+// Creates a SmsProvider failing over through the providers of the Config
+func NewFailoverProvider(config conf.SmsProviderConfiguration) (SmsProvider, error) {
+	names := append([]string{}, config.Providers...)
+	for _, providers := range config.Routes {
+		names = append(names, providers...)
+	}
+
+	providers := make(map[string]SmsProvider, len(names))
+	for _, name := range names {
+		if _, ok := providers[name]; ok {
+			continue
+		}
+
+		provider, err := newProvider(name, config)
+		if err != nil {
+			return nil, fmt.Errorf("sms provider %s: %w", name, err)
+		}
+		providers[name] = provider
+	}
+
+	return &FailoverProvider{
+		Config:    &config,
+		Providers: providers,
+		health:    defaultHealthTracker,
+	}, nil
+}
+
+func (t *FailoverProvider) SendMessage(phone, message, channel, otp string) (string, error) {
+	var failures []string
+
+	for _, name := range t.health.order(t.Config.ProvidersOf(phone)) {
+		if !supportsChannel(name, channel) {
+			continue
+		}
+
+		messageID, err := t.Providers[name].SendMessage(phone, message, channel, otp)
+		t.health.record(name, err)
+
+		if err == nil {
+			providerSendCounter.Add(context.Background(), 1, metric.WithAttributeSet(attribute.NewSet(attribute.String("provider", name), attribute.String("result", "sent"))))
+			return messageID, nil
+		}
+
+		providerSendCounter.Add(context.Background(), 1, metric.WithAttributeSet(attribute.NewSet(attribute.String("provider", name), attribute.String("result", "failed"))))
+		logrus.WithError(err).WithField("sms_provider", name).Warn("Unable to send SMS, failing over to the next provider")
+
+		failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+	}
+
+	if len(failures) == 0 {
+		return "", fmt.Errorf("no SMS provider supports channel type %q", channel)
+	}
+
+	return "", fmt.Errorf("all SMS providers failed: %s", strings.Join(failures, "; "))
+}
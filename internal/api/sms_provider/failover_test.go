@@ -0,0 +1,125 @@
+package sms_provider
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+)
+
+type fakeProvider struct {
+	name string
+	err  error
+	sent *[]string
+}
+
+func (p *fakeProvider) SendMessage(phone, message, channel, otp string) (string, error) {
+	*p.sent = append(*p.sent, p.name)
+	if p.err != nil {
+		return "", p.err
+	}
+	return p.name + "-message-id", nil
+}
+
+func TestFailoverProvider(t *testing.T) {
+	var sent []string
+	twilio := &fakeProvider{name: "twilio", sent: &sent}
+	sinch := &fakeProvider{name: "sinch", sent: &sent}
+	vonage := &fakeProvider{name: "vonage", sent: &sent}
+
+	now := time.Now()
+	health := newHealthTracker()
+	health.now = func() time.Time {
+		return now
+	}
+
+	provider := &FailoverProvider{
+		Config: &conf.SmsProviderConfiguration{
+			Providers: []string{"twilio", "vonage"},
+			Routes: conf.SmsRoutes{
+				"46": {"sinch", "twilio"},
+			},
+		},
+		Providers: map[string]SmsProvider{
+			"twilio": twilio,
+			"sinch":  sinch,
+			"vonage": vonage,
+		},
+		health: health,
+	}
+
+	// phone numbers are routed by prefix
+	messageID, err := provider.SendMessage("46701234567", "code", SMSProvider, "123456")
+	require.NoError(t, err)
+	assert.Equal(t, "sinch-message-id", messageID)
+
+	messageID, err = provider.SendMessage("15551234567", "code", SMSProvider, "123456")
+	require.NoError(t, err)
+	assert.Equal(t, "twilio-message-id", messageID)
+
+	// failing providers fail over to the next one
+	twilio.err = errors.New("timeout")
+	sent = nil
+	messageID, err = provider.SendMessage("15551234567", "code", SMSProvider, "123456")
+	require.NoError(t, err)
+	assert.Equal(t, "vonage-message-id", messageID)
+	assert.Equal(t, []string{"twilio", "vonage"}, sent)
+
+	// unhealthy providers are tried last until they have cooled down
+	for i := 1; i < providerFailureThreshold; i++ {
+		_, err = provider.SendMessage("15551234567", "code", SMSProvider, "123456")
+		require.NoError(t, err)
+	}
+
+	sent = nil
+	_, err = provider.SendMessage("15551234567", "code", SMSProvider, "123456")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vonage"}, sent)
+
+	twilio.err = nil
+	now = now.Add(providerCooldown)
+	sent = nil
+	messageID, err = provider.SendMessage("15551234567", "code", SMSProvider, "123456")
+	require.NoError(t, err)
+	assert.Equal(t, "twilio-message-id", messageID)
+	assert.Equal(t, []string{"twilio"}, sent)
+
+	// providers that don't support the channel are skipped
+	sent = nil
+	messageID, err = provider.SendMessage("46701234567", "code", WhatsappProvider, "123456")
+	require.NoError(t, err)
+	assert.Equal(t, "twilio-message-id", messageID)
+	assert.Equal(t, []string{"twilio"}, sent)
+
+	// errors of all the providers are returned
+	twilio.err = errors.New("twilio is down")
+	vonage.err = errors.New("vonage is down")
+	_, err = provider.SendMessage("15551234567", "code", SMSProvider, "123456")
+	require.EqualError(t, err, "all SMS providers failed: twilio: twilio is down; vonage: vonage is down")
+}
+
+func TestHealthTrackerOrder(t *testing.T) {
+	now := time.Now()
+	health := newHealthTracker()
+	health.now = func() time.Time {
+		return now
+	}
+
+	assert.Equal(t, []string{"twilio", "sinch", "vonage"}, health.order([]string{"twilio", "sinch", "vonage"}))
+
+	for i := 0; i < providerFailureThreshold+2; i++ {
+		health.record("twilio", errors.New("down"))
+	}
+	for i := 0; i < providerFailureThreshold; i++ {
+		health.record("sinch", errors.New("down"))
+	}
+
+	// unhealthy providers are ordered by their health score
+	assert.Equal(t, []string{"vonage", "sinch", "twilio"}, health.order([]string{"twilio", "sinch", "vonage"}))
+
+	now = now.Add(providerCooldown)
+	assert.Equal(t, []string{"twilio", "sinch", "vonage"}, health.order([]string{"twilio", "sinch", "vonage"}))
+}
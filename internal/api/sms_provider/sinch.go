@@ -0,0 +1,97 @@
+package sms_provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/utilities"
+)
+
+type SinchProvider struct {
+	Config  *conf.SinchProviderConfiguration
+	APIPath string
+}
+
+type SinchBatchRequest struct {
+	From string   `json:"from"`
+	To   []string `json:"to"`
+	Body string   `json:"body"`
+}
+
+type SinchBatchResponse struct {
+	ID string `json:"id"`
+}
+
+type SinchErrResponse struct {
+	Code string `json:"code"`
+	Text string `json:"text"`
+}
+
+func (t SinchErrResponse) Error() string {
+	return fmt.Sprintf("sinch error: %v (code: %v)", t.Text, t.Code)
+}
+
+// Creates a SmsProvider with the Sinch Config
+func NewSinchProvider(config conf.SinchProviderConfiguration) (SmsProvider, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	apiPath := "https://" + config.Region + ".sms.api.sinch.com/xms/v1/" + config.ServicePlanID + "/batches"
+	return &SinchProvider{
+		Config:  &config,
+		APIPath: apiPath,
+	}, nil
+}
+
+func (t *SinchProvider) SendMessage(phone, message, channel, otp string) (string, error) {
+	switch channel {
+	case SMSProvider:
+		return t.SendSms(phone, message)
+	default:
+		return "", fmt.Errorf("channel type %q is not supported for Sinch", channel)
+	}
+}
+
+// Send an SMS containing the OTP with Sinch's batches API
+func (t *SinchProvider) SendSms(phone string, message string) (string, error) {
+	body, err := json.Marshal(&SinchBatchRequest{
+		From: t.Config.From,
+		To:   []string{"+" + phone}, // sinch requires E.164 phone numbers
+		Body: message,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	client := &http.Client{Timeout: defaultTimeout}
+	r, err := http.NewRequest("POST", t.APIPath, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	r.Header.Add("Content-Type", "application/json")
+	r.Header.Add("Authorization", "Bearer "+t.Config.APIToken)
+	res, err := client.Do(r)
+	if err != nil {
+		return "", err
+	}
+	defer utilities.SafeClose(res.Body)
+
+	if res.StatusCode/100 != 2 {
+		resp := &SinchErrResponse{}
+		if err := json.NewDecoder(res.Body).Decode(resp); err != nil || resp.Code == "" {
+			return "", fmt.Errorf("sinch error: unexpected response status %d", res.StatusCode)
+		}
+		return "", resp
+	}
+
+	resp := &SinchBatchResponse{}
+	if err := json.NewDecoder(res.Body).Decode(resp); err != nil {
+		return "", err
+	}
+
+	return resp.ID, nil
+}
@@ -16,6 +16,8 @@ var defaultTimeout time.Duration = time.Second * 10
 
 const SMSProvider = "sms"
 const WhatsappProvider = "whatsapp"
+const ViberProvider = "viber"
+const CallProvider = "call"
 
 func init() {
 	timeoutStr := os.Getenv("GOTRUE_INTERNAL_HTTP_TIMEOUT")
@@ -37,33 +39,90 @@ func GetSmsProvider(config conf.GlobalConfiguration) (SmsProvider, error) {
 		return MockProvider, nil
 	}
 
-	switch name := config.Sms.Provider; name {
+	var provider SmsProvider
+	var err error
+	if config.Sms.IsFailoverEnabled() {
+		provider, err = NewFailoverProvider(config.Sms)
+	} else {
+		provider, err = newProvider(config.Sms.Provider, config.Sms)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Sms.ViberSMSFallback {
+		provider = &ViberFallbackProvider{Provider: provider}
+	}
+
+	return provider, nil
+}
+
+func newProvider(name string, config conf.SmsProviderConfiguration) (SmsProvider, error) {
+	switch name {
 	case "twilio":
-		return NewTwilioProvider(config.Sms.Twilio)
+		return NewTwilioProvider(config.Twilio)
 	case "messagebird":
-		return NewMessagebirdProvider(config.Sms.Messagebird)
+		return NewMessagebirdProvider(config.Messagebird)
 	case "textlocal":
-		return NewTextlocalProvider(config.Sms.Textlocal)
+		return NewTextlocalProvider(config.Textlocal)
 	case "vonage":
-		return NewVonageProvider(config.Sms.Vonage)
+		provider, err := NewVonageProvider(config.Vonage)
+		if err != nil {
+			return nil, err
+		}
+		provider.(*VonageProvider).SMSFallback = config.ViberSMSFallback
+		return provider, nil
 	case "twilio_verify":
-		return NewTwilioVerifyProvider(config.Sms.TwilioVerify)
+		return NewTwilioVerifyProvider(config.TwilioVerify)
+	case "sns":
+		return NewSNSProvider(config.SNS)
+	case "sinch":
+		return NewSinchProvider(config.Sinch)
 	default:
 		return nil, fmt.Errorf("sms Provider %s could not be found", name)
 	}
 }
 
-func IsValidMessageChannel(channel string, config *conf.GlobalConfiguration) bool {
-	if config.Hook.SendSMS.Enabled {
-		// channel doesn't matter if SMS hook is enabled
-		return true
-	}
+// supportsChannel reports whether the provider can send messages over the
+// channel.
+func supportsChannel(provider, channel string) bool {
 	switch channel {
 	case SMSProvider:
 		return true
 	case WhatsappProvider:
-		return config.Sms.Provider == "twilio" || config.Sms.Provider == "twilio_verify"
+		return provider == "twilio" || provider == "twilio_verify"
+	case ViberProvider:
+		return provider == "vonage"
+	case CallProvider:
+		return provider == "twilio" || provider == "twilio_verify"
 	default:
 		return false
 	}
 }
+
+func IsValidMessageChannel(channel string, config *conf.GlobalConfiguration) bool {
+	if config.Hook.SendSMS.Enabled {
+		// channel doesn't matter if SMS hook is enabled
+		return true
+	}
+
+	if supportsChannel(config.Sms.Provider, channel) {
+		return true
+	}
+
+	// failover chains support the channels of any of their providers
+	for _, provider := range config.Sms.Providers {
+		if supportsChannel(provider, channel) {
+			return true
+		}
+	}
+	for _, providers := range config.Sms.Routes {
+		for _, provider := range providers {
+			if supportsChannel(provider, channel) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -56,6 +56,19 @@ func TestSmsProvider(t *testing.T) {
 					ApiKey: "test_api_key",
 					Sender: "test_sender",
 				},
+				SNS: conf.SNSProviderConfiguration{
+					Region:          "us-east-1",
+					AccessKeyID:     "test_access_key_id",
+					SecretAccessKey: "test_secret_access_key",
+					SenderID:        "TestSender",
+					SMSType:         "Transactional",
+				},
+				Sinch: conf.SinchProviderConfiguration{
+					ServicePlanID: "test_service_plan_id",
+					APIToken:      "test_api_token",
+					From:          "test_from",
+					Region:        "eu",
+				},
 			},
 		},
 	}
@@ -143,6 +156,43 @@ func (ts *SmsProviderTestSuite) TestTwilioSendSms() {
 	}
 }
 
+func (ts *SmsProviderTestSuite) TestTwilioSendCall() {
+	defer gock.Off()
+	config := ts.Config.Sms.Twilio
+	config.VoiceFrom = "+15551234567"
+
+	provider, err := NewTwilioProvider(config)
+	require.NoError(ts.T(), err)
+
+	twilioProvider, ok := provider.(*TwilioProvider)
+	require.Equal(ts.T(), true, ok)
+
+	phone := "123456789"
+	message := "Your code is 123456"
+
+	body := url.Values{
+		"To":    {"+" + phone},
+		"From":  {"+15551234567"},
+		"Twiml": {`<Response><Say>Your code is 1, 2, 3, 4, 5, 6.</Say><Pause length="1"/><Say>Your code is 1, 2, 3, 4, 5, 6.</Say></Response>`},
+	}
+
+	gock.New(twilioProvider.CallsAPIPath).Post("").
+		MatchHeader("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(twilioProvider.Config.AccountSid+":"+twilioProvider.Config.AuthToken))).
+		MatchType("url").BodyString(body.Encode()).
+		Reply(201).JSON(CallStatus{
+		CallSID: "test_call_sid",
+		Status:  "queued",
+	})
+
+	callSID, err := twilioProvider.SendMessage(phone, message, CallProvider, "123456")
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), "test_call_sid", callSID)
+
+	// calls can't be made from a messaging service
+	_, err = (&TwilioProvider{Config: &ts.Config.Sms.Twilio}).SendCall(phone, message, "123456")
+	require.EqualError(ts.T(), err, "missing Twilio voice phone number")
+}
+
 func (ts *SmsProviderTestSuite) TestMessagebirdSendSms() {
 	defer gock.Off()
 	provider, err := NewMessagebirdProvider(ts.Config.Sms.Messagebird)
@@ -199,6 +249,54 @@ func (ts *SmsProviderTestSuite) TestVonageSendSms() {
 	require.NoError(ts.T(), err)
 }
 
+func (ts *SmsProviderTestSuite) TestVonageSendViber() {
+	defer gock.Off()
+	config := ts.Config.Sms.Vonage
+	config.ViberServiceMessageID = "test_viber_service"
+
+	provider, err := NewVonageProvider(config)
+	require.NoError(ts.T(), err)
+
+	vonageProvider, ok := provider.(*VonageProvider)
+	require.Equal(ts.T(), true, ok)
+	vonageProvider.SMSFallback = true
+
+	phone := "380501234567"
+	message := "This is the sms code: 123456"
+
+	gock.New(vonageProvider.MessagesAPIPath).Post("").
+		MatchHeader("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("test_api_key:test_api_secret"))).
+		MatchType("json").JSON(VonageMessage{
+		MessageType:  "text",
+		Text:         message,
+		To:           phone,
+		From:         "test_viber_service",
+		Channel:      "viber_service",
+		ViberService: &VonageViberService{Category: "transaction"},
+		Failover: []VonageMessage{{
+			MessageType: "text",
+			Text:        message,
+			To:          phone,
+			From:        "test_from",
+			Channel:     "sms",
+		}},
+	}).Reply(202).JSON(VonageMessageResponse{
+		MessageUUID: "test_message_uuid",
+	})
+
+	messageID, err := vonageProvider.SendMessage(phone, message, ViberProvider, "123456")
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), "test_message_uuid", messageID)
+
+	gock.New(vonageProvider.MessagesAPIPath).Post("").Reply(422).JSON(VonageErrResponse{
+		Title:  "Invalid params",
+		Detail: "The value of the `to` parameter is invalid",
+	})
+
+	_, err = vonageProvider.SendViber(phone, message)
+	require.EqualError(ts.T(), err, "vonage error: Invalid params (The value of the `to` parameter is invalid)")
+}
+
 func (ts *SmsProviderTestSuite) TestTextLocalSendSms() {
 	defer gock.Off()
 	provider, err := NewTextlocalProvider(ts.Config.Sms.Textlocal)
@@ -224,6 +322,82 @@ func (ts *SmsProviderTestSuite) TestTextLocalSendSms() {
 	_, err = textlocalProvider.SendSms(phone, message)
 	require.NoError(ts.T(), err)
 }
+
+func (ts *SmsProviderTestSuite) TestSNSSendSms() {
+	defer gock.Off()
+	provider, err := NewSNSProvider(ts.Config.Sms.SNS)
+	require.NoError(ts.T(), err)
+
+	snsProvider, ok := provider.(*SNSProvider)
+	require.Equal(ts.T(), true, ok)
+
+	phone := "123456789"
+	message := "This is the sms code: 123456"
+	body := url.Values{
+		"Action":      {"Publish"},
+		"Version":     {"2010-03-31"},
+		"PhoneNumber": {"+" + phone},
+		"Message":     {message},
+
+		"MessageAttributes.entry.1.Name":              {"AWS.SNS.SMS.SMSType"},
+		"MessageAttributes.entry.1.Value.DataType":    {"String"},
+		"MessageAttributes.entry.1.Value.StringValue": {"Transactional"},
+		"MessageAttributes.entry.2.Name":              {"AWS.SNS.SMS.SenderID"},
+		"MessageAttributes.entry.2.Value.DataType":    {"String"},
+		"MessageAttributes.entry.2.Value.StringValue": {"TestSender"},
+	}
+
+	gock.New(snsProvider.APIPath).Post("").
+		MatchHeader("Authorization", "^AWS4-HMAC-SHA256 Credential=test_access_key_id/[0-9]{8}/us-east-1/sns/aws4_request, ").
+		MatchType("url").BodyString(body.Encode()).
+		Reply(200).BodyString(`<PublishResponse xmlns="http://sns.amazonaws.com/doc/2010-03-31/"><PublishResult><MessageId>test-message-id</MessageId></PublishResult></PublishResponse>`)
+
+	messageID, err := snsProvider.SendSms(phone, message)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), "test-message-id", messageID)
+
+	gock.New(snsProvider.APIPath).Post("").
+		Reply(400).BodyString(`<ErrorResponse><Error><Type>Sender</Type><Code>InvalidParameter</Code><Message>Invalid parameter: PhoneNumber</Message></Error></ErrorResponse>`)
+
+	_, err = snsProvider.SendSms(phone, message)
+	require.EqualError(ts.T(), err, "sns error: Invalid parameter: PhoneNumber (code: InvalidParameter)")
+}
+
+func (ts *SmsProviderTestSuite) TestSinchSendSms() {
+	defer gock.Off()
+	provider, err := NewSinchProvider(ts.Config.Sms.Sinch)
+	require.NoError(ts.T(), err)
+
+	sinchProvider, ok := provider.(*SinchProvider)
+	require.Equal(ts.T(), true, ok)
+	require.Equal(ts.T(), "https://eu.sms.api.sinch.com/xms/v1/test_service_plan_id/batches", sinchProvider.APIPath)
+
+	phone := "123456789"
+	message := "This is the sms code: 123456"
+
+	gock.New(sinchProvider.APIPath).Post("").
+		MatchHeader("Authorization", "Bearer "+sinchProvider.Config.APIToken).
+		MatchType("json").JSON(SinchBatchRequest{
+		From: sinchProvider.Config.From,
+		To:   []string{"+" + phone},
+		Body: message,
+	}).Reply(201).JSON(SinchBatchResponse{
+		ID: "test_batch_id",
+	})
+
+	batchID, err := sinchProvider.SendSms(phone, message)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), "test_batch_id", batchID)
+
+	gock.New(sinchProvider.APIPath).Post("").Reply(400).JSON(SinchErrResponse{
+		Code: "syntax_invalid_parameter_format",
+		Text: "Invalid phone number",
+	})
+
+	_, err = sinchProvider.SendSms(phone, message)
+	require.EqualError(ts.T(), err, "sinch error: Invalid phone number (code: syntax_invalid_parameter_format)")
+}
+
 func (ts *SmsProviderTestSuite) TestTwilioVerifySendSms() {
 	defer gock.Off()
 	provider, err := NewTwilioVerifyProvider(ts.Config.Sms.TwilioVerify)
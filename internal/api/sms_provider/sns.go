@@ -0,0 +1,120 @@
+package sms_provider
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/utilities"
+)
+
+type SNSProvider struct {
+	Config  *conf.SNSProviderConfiguration
+	APIPath string
+}
+
+type SNSPublishResponse struct {
+	MessageID string `xml:"PublishResult>MessageId"`
+}
+
+type SNSErrorResponse struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// Creates a SmsProvider with the SNS Config
+func NewSNSProvider(config conf.SNSProviderConfiguration) (SmsProvider, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	apiPath := "https://sns." + config.Region + ".amazonaws.com/"
+	if config.Endpoint != "" {
+		apiPath = strings.TrimSuffix(config.Endpoint, "/") + "/"
+	}
+
+	return &SNSProvider{
+		Config:  &config,
+		APIPath: apiPath,
+	}, nil
+}
+
+func (t *SNSProvider) SendMessage(phone, message, channel, otp string) (string, error) {
+	switch channel {
+	case SMSProvider:
+		return t.SendSms(phone, message)
+	default:
+		return "", fmt.Errorf("channel type %q is not supported for SNS", channel)
+	}
+}
+
+// Send an SMS containing the OTP with the Publish action of SNS, signed with
+// AWS Signature Version 4
+func (t *SNSProvider) SendSms(phone string, message string) (string, error) {
+	body := url.Values{
+		"Action":      {"Publish"},
+		"Version":     {"2010-03-31"},
+		"PhoneNumber": {"+" + phone}, // sns requires E.164 phone numbers
+		"Message":     {message},
+
+		"MessageAttributes.entry.1.Name":              {"AWS.SNS.SMS.SMSType"},
+		"MessageAttributes.entry.1.Value.DataType":    {"String"},
+		"MessageAttributes.entry.1.Value.StringValue": {t.Config.SMSType},
+	}
+
+	if t.Config.SenderID != "" {
+		body.Set("MessageAttributes.entry.2.Name", "AWS.SNS.SMS.SenderID")
+		body.Set("MessageAttributes.entry.2.Value.DataType", "String")
+		body.Set("MessageAttributes.entry.2.Value.StringValue", t.Config.SenderID)
+	}
+
+	payload := []byte(body.Encode())
+
+	r, err := http.NewRequest("POST", t.APIPath, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	credentials := utilities.AWSCredentialsOrEnv(utilities.AWSCredentials{
+		AccessKeyID:     t.Config.AccessKeyID,
+		SecretAccessKey: t.Config.SecretAccessKey,
+		SessionToken:    t.Config.SessionToken,
+	})
+	if err := utilities.SignAWSRequest(r, payload, "sns", t.Config.Region, credentials, time.Now()); err != nil {
+		return "", fmt.Errorf("sns error: %w", err)
+	}
+
+	client := &http.Client{Timeout: defaultTimeout}
+	res, err := client.Do(r)
+	if err != nil {
+		return "", err
+	}
+	defer utilities.SafeClose(res.Body)
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if res.StatusCode/100 != 2 {
+		resp := &SNSErrorResponse{}
+		if err := xml.Unmarshal(data, resp); err != nil || resp.Code == "" {
+			return "", fmt.Errorf("sns error: unexpected response status %d", res.StatusCode)
+		}
+		return "", fmt.Errorf("sns error: %v (code: %v)", resp.Message, resp.Code)
+	}
+
+	resp := &SNSPublishResponse{}
+	if err := xml.Unmarshal(data, resp); err != nil {
+		return "", err
+	}
+
+	return resp.MessageID, nil
+}
@@ -2,6 +2,8 @@ package sms_provider
 
 import (
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -20,6 +22,10 @@ const (
 type TwilioProvider struct {
 	Config  *conf.TwilioProviderConfiguration
 	APIPath string
+
+	// CallsAPIPath is the path of the Calls API, which reads the OTPs of
+	// the call channel aloud.
+	CallsAPIPath string
 }
 
 var isPhoneNumber = regexp.MustCompile("^[1-9][0-9]{1,14}$")
@@ -56,10 +62,11 @@ func NewTwilioProvider(config conf.TwilioProviderConfiguration) (SmsProvider, er
 		return nil, err
 	}
 
-	apiPath := defaultTwilioApiBase + "/" + apiVersion + "/" + "Accounts" + "/" + config.AccountSid + "/Messages.json"
+	accountPath := defaultTwilioApiBase + "/" + apiVersion + "/" + "Accounts" + "/" + config.AccountSid
 	return &TwilioProvider{
-		Config:  &config,
-		APIPath: apiPath,
+		Config:       &config,
+		APIPath:      accountPath + "/Messages.json",
+		CallsAPIPath: accountPath + "/Calls.json",
 	}, nil
 }
 
@@ -67,6 +74,8 @@ func (t *TwilioProvider) SendMessage(phone, message, channel, otp string) (strin
 	switch channel {
 	case SMSProvider, WhatsappProvider:
 		return t.SendSms(phone, message, channel, otp)
+	case CallProvider:
+		return t.SendCall(phone, message, otp)
 	default:
 		return "", fmt.Errorf("channel type %q is not supported for Twilio", channel)
 	}
@@ -136,3 +145,71 @@ func (t *TwilioProvider) SendSms(phone, message, channel, otp string) (string, e
 
 	return resp.MessageSID, nil
 }
+
+type CallStatus struct {
+	CallSID string `json:"sid"`
+	Status  string `json:"status"`
+}
+
+// voiceMessage returns the TwiML reading the message aloud twice, with the
+// digits of the OTP read one by one.
+func voiceMessage(message, otp string) string {
+	spoken := strings.Join(strings.Split(otp, ""), ", ")
+	if otp != "" && strings.Contains(message, otp) {
+		message = strings.ReplaceAll(message, otp, spoken+".")
+	} else {
+		message = message + " " + spoken + "."
+	}
+
+	var escaped strings.Builder
+	_ = xml.EscapeText(&escaped, []byte(message))
+
+	return "<Response><Say>" + escaped.String() + "</Say><Pause length=\"1\"/><Say>" + escaped.String() + "</Say></Response>"
+}
+
+// Call the phone number and read the OTP aloud with Twilio's API
+func (t *TwilioProvider) SendCall(phone, message, otp string) (string, error) {
+	sender := t.Config.VoiceFrom
+	if sender == "" && isPhoneNumber.MatchString(formatPhoneNumber(t.Config.MessageServiceSid)) {
+		sender = t.Config.MessageServiceSid
+	}
+	if sender == "" {
+		return "", errors.New("missing Twilio voice phone number")
+	}
+
+	body := url.Values{
+		"To":    {"+" + phone},
+		"From":  {sender},
+		"Twiml": {voiceMessage(message, otp)},
+	}
+	client := &http.Client{Timeout: defaultTimeout}
+	r, err := http.NewRequest("POST", t.CallsAPIPath, strings.NewReader(body.Encode()))
+	if err != nil {
+		return "", err
+	}
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	r.SetBasicAuth(t.Config.AccountSid, t.Config.AuthToken)
+	res, err := client.Do(r)
+	if err != nil {
+		return "", err
+	}
+	defer utilities.SafeClose(res.Body)
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
+		resp := &twilioErrResponse{}
+		if err := json.NewDecoder(res.Body).Decode(resp); err != nil {
+			return "", err
+		}
+		return "", resp
+	}
+
+	resp := &CallStatus{}
+	if err := json.NewDecoder(res.Body).Decode(resp); err != nil {
+		return "", err
+	}
+
+	if resp.Status == "failed" {
+		return resp.CallSID, fmt.Errorf("twilio error: call %s failed", resp.CallSID)
+	}
+
+	return resp.CallSID, nil
+}
@@ -55,7 +55,7 @@ func NewTwilioVerifyProvider(config conf.TwilioVerifyProviderConfiguration) (Sms
 
 func (t *TwilioVerifyProvider) SendMessage(phone, message, channel, otp string) (string, error) {
 	switch channel {
-	case SMSProvider, WhatsappProvider:
+	case SMSProvider, WhatsappProvider, CallProvider:
 		return t.SendSms(phone, message, channel)
 	default:
 		return "", fmt.Errorf("channel type %q is not supported for Twilio", channel)
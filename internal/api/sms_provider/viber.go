@@ -0,0 +1,28 @@
+package sms_provider
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ViberFallbackProvider sends the messages of the Viber channel as SMS
+// messages when they can't be sent with Viber, like when no provider
+// supports Viber or the phone number isn't registered with Viber.
+type ViberFallbackProvider struct {
+	Provider SmsProvider
+}
+
+func (t *ViberFallbackProvider) SendMessage(phone, message, channel, otp string) (string, error) {
+	messageID, err := t.Provider.SendMessage(phone, message, channel, otp)
+	if err == nil || channel != ViberProvider {
+		return messageID, err
+	}
+
+	providerSendCounter.Add(context.Background(), 1, metric.WithAttributeSet(attribute.NewSet(attribute.String("provider", "viber"), attribute.String("result", "sms_fallback"))))
+	logrus.WithError(err).Warn("Unable to send Viber message, falling back to SMS")
+
+	return t.Provider.SendMessage(phone, message, SMSProvider, otp)
+}
@@ -0,0 +1,46 @@
+package sms_provider
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type channelProvider struct {
+	channels map[string]bool
+	sent     []string
+}
+
+func (p *channelProvider) SendMessage(phone, message, channel, otp string) (string, error) {
+	p.sent = append(p.sent, channel)
+	if !p.channels[channel] {
+		return "", errors.New("channel not supported")
+	}
+	return channel + "-message-id", nil
+}
+
+func TestViberFallbackProvider(t *testing.T) {
+	viber := &channelProvider{channels: map[string]bool{SMSProvider: true, ViberProvider: true}}
+
+	messageID, err := (&ViberFallbackProvider{Provider: viber}).SendMessage("380501234567", "code", ViberProvider, "123456")
+	require.NoError(t, err)
+	assert.Equal(t, "viber-message-id", messageID)
+	assert.Equal(t, []string{ViberProvider}, viber.sent)
+
+	// messages that can't be sent with Viber are sent as SMS messages
+	sms := &channelProvider{channels: map[string]bool{SMSProvider: true}}
+
+	messageID, err = (&ViberFallbackProvider{Provider: sms}).SendMessage("380501234567", "code", ViberProvider, "123456")
+	require.NoError(t, err)
+	assert.Equal(t, "sms-message-id", messageID)
+	assert.Equal(t, []string{ViberProvider, SMSProvider}, sms.sent)
+
+	// other channels don't fall back
+	whatsapp := &channelProvider{channels: map[string]bool{SMSProvider: true}}
+
+	_, err = (&ViberFallbackProvider{Provider: whatsapp}).SendMessage("380501234567", "code", WhatsappProvider, "123456")
+	require.Error(t, err)
+	assert.Equal(t, []string{WhatsappProvider}, whatsapp.sent)
+}
@@ -1,6 +1,7 @@
 package sms_provider
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,12 +15,21 @@ import (
 )
 
 const (
-	defaultVonageApiBase = "https://rest.nexmo.com"
+	defaultVonageApiBase         = "https://rest.nexmo.com"
+	defaultVonageMessagesApiBase = "https://api.nexmo.com"
 )
 
 type VonageProvider struct {
 	Config  *conf.VonageProviderConfiguration
 	APIPath string
+
+	// MessagesAPIPath is the path of the Messages API, which sends the
+	// messages of the Viber channel.
+	MessagesAPIPath string
+
+	// SMSFallback makes Vonage deliver Viber messages that weren't
+	// delivered as SMS messages.
+	SMSFallback bool
 }
 
 type VonageResponseMessage struct {
@@ -32,6 +42,38 @@ type VonageResponse struct {
 	Messages []VonageResponseMessage `json:"messages"`
 }
 
+// VonageMessage is a message of the Messages API.
+type VonageMessage struct {
+	MessageType  string              `json:"message_type"`
+	Text         string              `json:"text"`
+	To           string              `json:"to"`
+	From         string              `json:"from"`
+	Channel      string              `json:"channel"`
+	ViberService *VonageViberService `json:"viber_service,omitempty"`
+	Failover     []VonageMessage     `json:"failover,omitempty"`
+}
+
+type VonageViberService struct {
+	Category string `json:"category"`
+	TTL      int    `json:"ttl,omitempty"`
+}
+
+type VonageMessageResponse struct {
+	MessageUUID string `json:"message_uuid"`
+}
+
+// VonageErrResponse is an error of the Messages API, in the problem details
+// format of RFC 7807.
+type VonageErrResponse struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+}
+
+func (t VonageErrResponse) Error() string {
+	return fmt.Sprintf("vonage error: %v (%v)", t.Title, t.Detail)
+}
+
 // Creates a SmsProvider with the Vonage Config
 func NewVonageProvider(config conf.VonageProviderConfiguration) (SmsProvider, error) {
 	if err := config.Validate(); err != nil {
@@ -40,8 +82,9 @@ func NewVonageProvider(config conf.VonageProviderConfiguration) (SmsProvider, er
 
 	apiPath := defaultVonageApiBase + "/sms/json"
 	return &VonageProvider{
-		Config:  &config,
-		APIPath: apiPath,
+		Config:          &config,
+		APIPath:         apiPath,
+		MessagesAPIPath: defaultVonageMessagesApiBase + "/v1/messages",
 	}, nil
 }
 
@@ -49,6 +92,8 @@ func (t *VonageProvider) SendMessage(phone, message, channel, otp string) (strin
 	switch channel {
 	case SMSProvider:
 		return t.SendSms(phone, message)
+	case ViberProvider:
+		return t.SendViber(phone, message)
 	default:
 		return "", fmt.Errorf("channel type %q is not supported for Vonage", channel)
 	}
@@ -99,3 +144,67 @@ func (t *VonageProvider) SendSms(phone string, message string) (string, error) {
 
 	return resp.Messages[0].MessageID, nil
 }
+
+// Send a Viber message containing the OTP with Vonage's Messages API. When
+// SMS fallback is enabled, Vonage sends it as an SMS message if it isn't
+// delivered with Viber, like to phone numbers without Viber.
+func (t *VonageProvider) SendViber(phone string, message string) (string, error) {
+	if t.Config.ViberServiceMessageID == "" {
+		return "", errors.New("missing Vonage Viber service message ID")
+	}
+
+	viber := VonageMessage{
+		MessageType: "text",
+		Text:        message,
+		To:          phone,
+		From:        t.Config.ViberServiceMessageID,
+		Channel:     "viber_service",
+		ViberService: &VonageViberService{
+			Category: "transaction",
+		},
+	}
+	if t.SMSFallback {
+		viber.Failover = []VonageMessage{{
+			MessageType: "text",
+			Text:        message,
+			To:          phone,
+			From:        t.Config.From,
+			Channel:     "sms",
+		}}
+	}
+
+	body, err := json.Marshal(viber)
+	if err != nil {
+		return "", err
+	}
+
+	client := &http.Client{Timeout: defaultTimeout}
+	r, err := http.NewRequest("POST", t.MessagesAPIPath, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	r.Header.Add("Content-Type", "application/json")
+	r.Header.Add("Accept", "application/json")
+	r.SetBasicAuth(t.Config.ApiKey, t.Config.ApiSecret)
+	res, err := client.Do(r)
+	if err != nil {
+		return "", err
+	}
+	defer utilities.SafeClose(res.Body)
+
+	if res.StatusCode != http.StatusAccepted && res.StatusCode != http.StatusOK {
+		resp := &VonageErrResponse{}
+		if err := json.NewDecoder(res.Body).Decode(resp); err != nil {
+			return "", fmt.Errorf("vonage error: HTTP status %d", res.StatusCode)
+		}
+		return "", resp
+	}
+
+	resp := &VonageMessageResponse{}
+	if err := json.NewDecoder(res.Body).Decode(resp); err != nil {
+		return "", err
+	}
+
+	return resp.MessageUUID, nil
+}
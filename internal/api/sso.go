@@ -2,20 +2,32 @@ package api
 
 import (
 	"net/http"
+	"net/url"
+	"strings"
 
+	"github.com/badoux/checkmail"
 	"github.com/crewjam/saml"
 	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/api/provider"
 	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
 	"github.com/supabase/auth/internal/storage"
+	"github.com/supabase/auth/internal/utilities"
 )
 
 type SingleSignOnParams struct {
 	ProviderID          uuid.UUID `json:"provider_id"`
 	Domain              string    `json:"domain"`
+	Email               string    `json:"email"`
 	RedirectTo          string    `json:"redirect_to"`
 	SkipHTTPRedirect    *bool     `json:"skip_http_redirect"`
 	CodeChallenge       string    `json:"code_challenge"`
 	CodeChallengeMethod string    `json:"code_challenge_method"`
+
+	// ForceAuthn and RequestedAuthnContext override the authentication
+	// request policy of SAML providers.
+	ForceAuthn            *bool                             `json:"force_authn"`
+	RequestedAuthnContext *models.SAMLRequestedAuthnContext `json:"requested_authn_context"`
 }
 
 type SingleSignOnResponse struct {
@@ -25,16 +37,49 @@ type SingleSignOnResponse struct {
 func (p *SingleSignOnParams) validate() (bool, error) {
 	hasProviderID := p.ProviderID != uuid.Nil
 	hasDomain := p.Domain != ""
+	hasEmail := p.Email != ""
 
-	if hasProviderID && hasDomain {
-		return hasProviderID, badRequestError(ErrorCodeValidationFailed, "Only one of provider_id or domain supported")
-	} else if !hasProviderID && !hasDomain {
-		return hasProviderID, badRequestError(ErrorCodeValidationFailed, "A provider_id or domain needs to be provided")
+	if (hasProviderID && (hasDomain || hasEmail)) || (hasDomain && hasEmail) {
+		return hasProviderID, badRequestError(ErrorCodeValidationFailed, "Only one of provider_id, domain or email supported")
+	} else if !hasProviderID && !hasDomain && !hasEmail {
+		return hasProviderID, badRequestError(ErrorCodeValidationFailed, "A provider_id, domain or email needs to be provided")
+	}
+
+	if hasEmail {
+		if err := checkmail.ValidateFormat(p.Email); err != nil {
+			return hasProviderID, badRequestError(ErrorCodeValidationFailed, "Unable to validate email address: "+err.Error())
+		}
+	}
+
+	if err := validateSAMLRequestedAuthnContext("requested_authn_context", p.RequestedAuthnContext); err != nil {
+		return hasProviderID, err
 	}
 
 	return hasProviderID, nil
 }
 
+// validateSAMLRequestedAuthnContext validates the requested authentication
+// context named name, if set.
+func validateSAMLRequestedAuthnContext(name string, requestedAuthnContext *models.SAMLRequestedAuthnContext) error {
+	if requestedAuthnContext == nil {
+		return nil
+	}
+
+	if strings.TrimSpace(requestedAuthnContext.ClassRef) == "" {
+		return badRequestError(ErrorCodeValidationFailed, "%s.class_ref must be set", name)
+	}
+
+	switch requestedAuthnContext.Comparison {
+	case "", "exact", "minimum", "maximum", "better":
+		// it's valid
+
+	default:
+		return badRequestError(ErrorCodeValidationFailed, "%s.comparison must be unspecified or one of exact, minimum, maximum, better", name)
+	}
+
+	return nil
+}
+
 // SingleSignOn handles the single-sign-on flow for a provided SSO domain or provider.
 func (a *API) SingleSignOn(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
@@ -57,18 +102,9 @@ func (a *API) SingleSignOn(w http.ResponseWriter, r *http.Request) error {
 	if err := validatePKCEParams(codeChallengeMethod, codeChallenge); err != nil {
 		return err
 	}
-	flowType := getFlowFromChallenge(params.CodeChallenge)
-	var flowStateID *uuid.UUID
-	flowStateID = nil
-	if isPKCEFlow(flowType) {
-		flowState, err := generateFlowState(db, models.SSOSAML.String(), models.SSOSAML, codeChallengeMethod, codeChallenge, nil)
-		if err != nil {
-			return err
-		}
-		flowStateID = &flowState.ID
-	}
 
 	var ssoProvider *models.SSOProvider
+	var forEmail *string
 
 	if hasProviderID {
 		ssoProvider, err = models.FindSSOProviderByID(db, params.ProviderID)
@@ -77,8 +113,18 @@ func (a *API) SingleSignOn(w http.ResponseWriter, r *http.Request) error {
 		} else if err != nil {
 			return internalServerError("Unable to find SSO provider by ID").WithInternalError(err)
 		}
+	} else if params.Email != "" {
+		email := strings.ToLower(strings.TrimSpace(params.Email))
+		forEmail = &email
+
+		ssoProvider, err = models.FindSSOProviderForEmailAddress(db, email, a.config.SSODomainVerification.Enabled)
+		if models.IsNotFoundError(err) {
+			return notFoundError(ErrorCodeSSOProviderNotFound, "No SSO provider assigned for the domain of this email address")
+		} else if err != nil {
+			return internalServerError("Unable to find SSO provider by email address").WithInternalError(err)
+		}
 	} else {
-		ssoProvider, err = models.FindSSOProviderByDomain(db, params.Domain)
+		ssoProvider, err = models.ResolveSSOProviderForDomain(db, params.Domain, a.config.SSODomainVerification.Enabled)
 		if models.IsNotFoundError(err) {
 			return notFoundError(ErrorCodeSSOProviderNotFound, "No SSO provider assigned for this domain")
 		} else if err != nil {
@@ -86,9 +132,79 @@ func (a *API) SingleSignOn(w http.ResponseWriter, r *http.Request) error {
 		}
 	}
 
+	authMethod := models.SSOSAML
+	if ssoProvider.Type() == "oidc" {
+		if !a.config.SSOOIDC.Enabled {
+			return notFoundError(ErrorCodeSSOOIDCProviderDisabled, "OpenID Connect single sign-on is disabled")
+		}
+
+		if params.ForceAuthn != nil || params.RequestedAuthnContext != nil {
+			return badRequestError(ErrorCodeValidationFailed, "force_authn and requested_authn_context are only supported by SAML SSO providers")
+		}
+
+		authMethod = models.SSOOIDC
+	} else if !a.config.SAML.Enabled {
+		return notFoundError(ErrorCodeSAMLProviderDisabled, "SAML 2.0 is disabled")
+	}
+
+	flowType := getFlowFromChallenge(params.CodeChallenge)
+	var flowStateID *uuid.UUID
+	flowStateID = nil
+	if isPKCEFlow(flowType) {
+		flowState, err := generateFlowState(db, authMethod.String(), authMethod, codeChallengeMethod, codeChallenge, nil)
+		if err != nil {
+			return err
+		}
+		flowStateID = &flowState.ID
+	}
+
+	relayState := &models.SAMLRelayState{
+		SSOProviderID: ssoProvider.ID,
+		ForEmail:      forEmail,
+		RedirectTo:    params.RedirectTo,
+		FlowStateID:   flowStateID,
+	}
+
+	var ssoRedirectURL *url.URL
+	if authMethod == models.SSOOIDC {
+		ssoRedirectURL, err = a.oidcSingleSignOnURL(ctx, db, ssoProvider, relayState)
+	} else {
+		authnRequestPolicy := ssoProvider.SAMLProvider.AuthnRequestPolicy
+		if params.ForceAuthn != nil {
+			authnRequestPolicy.ForceAuthn = *params.ForceAuthn
+		}
+		if params.RequestedAuthnContext != nil {
+			authnRequestPolicy.RequestedAuthnContext = params.RequestedAuthnContext
+		}
+
+		ssoRedirectURL, err = a.samlSingleSignOnURL(db, ssoProvider, &authnRequestPolicy, relayState)
+	}
+	if err != nil {
+		return err
+	}
+
+	skipHTTPRedirect := false
+
+	if params.SkipHTTPRedirect != nil {
+		skipHTTPRedirect = *params.SkipHTTPRedirect
+	}
+
+	if skipHTTPRedirect {
+		return sendJSON(w, http.StatusOK, SingleSignOnResponse{
+			URL: ssoRedirectURL.String(),
+		})
+	}
+
+	http.Redirect(w, r, ssoRedirectURL.String(), http.StatusSeeOther)
+	return nil
+}
+
+// samlSingleSignOnURL creates the relay state of a SAML sign in and returns
+// the URL of the authentication request to the identity provider.
+func (a *API) samlSingleSignOnURL(db *storage.Connection, ssoProvider *models.SSOProvider, authnRequestPolicy *models.SAMLAuthnRequestPolicy, relayState *models.SAMLRelayState) (*url.URL, error) {
 	entityDescriptor, err := ssoProvider.SAMLProvider.EntityDescriptor()
 	if err != nil {
-		return internalServerError("Error parsing SAML Metadata for SAML provider").WithInternalError(err)
+		return nil, internalServerError("Error parsing SAML Metadata for SAML provider").WithInternalError(err)
 	}
 
 	serviceProvider := a.getSAMLServiceProvider(entityDescriptor, false /* <- idpInitiated */)
@@ -96,10 +212,10 @@ func (a *API) SingleSignOn(w http.ResponseWriter, r *http.Request) error {
 	authnRequest, err := serviceProvider.MakeAuthenticationRequest(
 		serviceProvider.GetSSOBindingLocation(saml.HTTPRedirectBinding),
 		saml.HTTPRedirectBinding,
-		saml.HTTPPostBinding,
+		authnRequestPolicy.ProtocolBinding(),
 	)
 	if err != nil {
-		return internalServerError("Error creating SAML Authentication Request").WithInternalError(err)
+		return nil, internalServerError("Error creating SAML Authentication Request").WithInternalError(err)
 	}
 
 	// Some IdPs do not support the use of the `persistent` NameID format,
@@ -108,40 +224,172 @@ func (a *API) SingleSignOn(w http.ResponseWriter, r *http.Request) error {
 		authnRequest.NameIDPolicy.Format = ssoProvider.SAMLProvider.NameIDFormat
 	}
 
-	relayState := models.SAMLRelayState{
-		SSOProviderID: ssoProvider.ID,
-		RequestID:     authnRequest.ID,
-		RedirectTo:    params.RedirectTo,
-		FlowStateID:   flowStateID,
+	if authnRequestPolicy.ForceAuthn {
+		forceAuthn := true
+		authnRequest.ForceAuthn = &forceAuthn
+	}
+
+	if requestedAuthnContext := authnRequestPolicy.RequestedAuthnContext; requestedAuthnContext != nil {
+		comparison := requestedAuthnContext.Comparison
+		if comparison == "" {
+			comparison = "exact"
+		}
+
+		authnRequest.RequestedAuthnContext = &saml.RequestedAuthnContext{
+			Comparison:           comparison,
+			AuthnContextClassRef: requestedAuthnContext.ClassRef,
+		}
 	}
 
+	relayState.RequestID = authnRequest.ID
+
 	if err := db.Transaction(func(tx *storage.Connection) error {
-		if terr := tx.Create(&relayState); terr != nil {
-			return internalServerError("Error creating SAML relay state from sign up").WithInternalError(err)
+		if terr := tx.Create(relayState); terr != nil {
+			return internalServerError("Error creating SAML relay state from sign up").WithInternalError(terr)
 		}
 
 		return nil
 	}); err != nil {
-		return err
+		return nil, err
 	}
 
 	ssoRedirectURL, err := authnRequest.Redirect(relayState.ID.String(), serviceProvider)
 	if err != nil {
-		return internalServerError("Error creating SAML authentication request redirect URL").WithInternalError(err)
+		return nil, internalServerError("Error creating SAML authentication request redirect URL").WithInternalError(err)
 	}
 
-	skipHTTPRedirect := false
+	return ssoRedirectURL, nil
+}
 
-	if params.SkipHTTPRedirect != nil {
-		skipHTTPRedirect = *params.SkipHTTPRedirect
+// redirectSSOError redirects the user to the site URL with the error of a
+// failed sign in with an SSO provider.
+func (a *API) redirectSSOError(w http.ResponseWriter, r *http.Request, err error) error {
+	u, uerr := url.Parse(a.config.SiteURL)
+	if uerr != nil {
+		return internalServerError("site url is improperly formattted").WithInternalError(err)
 	}
 
-	if skipHTTPRedirect {
-		return sendJSON(w, http.StatusOK, SingleSignOnResponse{
-			URL: ssoRedirectURL.String(),
-		})
+	q := getErrorQueryString(err, utilities.GetRequestID(r.Context()), observability.GetLogEntry(r).Entry, u.Query())
+	u.RawQuery = q.Encode()
+	http.Redirect(w, r, u.String(), http.StatusSeeOther)
+
+	return nil
+}
+
+// completeSSOSignIn signs in the user identified by an SSO provider, creating
+// and provisioning the account as needed, and redirects to redirectTo. The
+// attributes are those the provisioning rules can reference. afterSignIn is
+// called in the same transaction once the user is known.
+func (a *API) completeSSOSignIn(w http.ResponseWriter, r *http.Request, ssoProvider *models.SSOProvider, userProvidedData *provider.UserProvidedData, attributes map[string]interface{}, authMethod models.AuthenticationMethod, grantParams models.GrantParams, flowState *models.FlowState, redirectTo string, afterSignIn func(tx *storage.Connection, user *models.User) error) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+
+	var token *AccessTokenResponse
+
+	grantParams.Provider = models.SSOProviderIdentity(ssoProvider.ID)
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		var user *models.User
+
+		providerType := models.SSOProviderIdentity(ssoProvider.ID)
+		provisioning := ssoProvider.Provisioning()
+
+		existingUser := false
+		if _, terr = models.FindIdentityByIdAndProvider(tx, userProvidedData.Metadata.Subject, providerType); terr == nil {
+			existingUser = true
+		} else if !models.IsNotFoundError(terr) {
+			return terr
+		}
+
+		// accounts potentially created via SSO can contain non-unique email addresses in the auth.users table
+		if user, terr = a.createAccountFromExternalIdentity(tx, r, userProvidedData, providerType); terr != nil {
+			return terr
+		}
+
+		if !provisioning.IsEmpty() && (!existingUser || provisioning.UpdateExistingUsers) {
+			if provisioning.DefaultRole != "" && user.Role != provisioning.DefaultRole {
+				if terr := user.SetRole(tx, provisioning.DefaultRole); terr != nil {
+					return terr
+				}
+			}
+
+			if appMetadata := provisioning.AppMetadataFor(attributes); len(appMetadata) > 0 {
+				if terr := user.UpdateAppMetaData(tx, appMetadata); terr != nil {
+					return terr
+				}
+			}
+		}
+
+		if len(provisioning.GroupMappings) > 0 {
+			groups := provisioning.GroupsFor(attributes)
+
+			if provisioning.MapsGroupRoles() {
+				role := provisioning.GroupRoleFor(groups)
+				if role == "" {
+					role = provisioning.DefaultRole
+				}
+				if role == "" {
+					role = config.JWT.DefaultGroupName
+				}
+
+				if user.Role != role {
+					if terr := user.SetRole(tx, role); terr != nil {
+						return terr
+					}
+				}
+			}
+
+			if appMetadata := provisioning.GroupAppMetadataFor(groups); len(appMetadata) > 0 {
+				if terr := user.UpdateAppMetaData(tx, appMetadata); terr != nil {
+					return terr
+				}
+			}
+		}
+
+		if flowState != nil {
+			// This means that the callback is using PKCE
+			flowState.UserID = &(user.ID)
+			if terr := tx.Update(flowState); terr != nil {
+				return terr
+			}
+		}
+
+		token, terr = a.issueRefreshToken(r, tx, user, authMethod, grantParams)
+
+		if terr != nil {
+			// like when the user is at its cap of active sessions
+			if httpErr, ok := terr.(*HTTPError); ok && httpErr.HTTPStatus < http.StatusInternalServerError {
+				return httpErr
+			}
+			return internalServerError("Unable to issue refresh token from SSO sign in").WithInternalError(terr)
+		}
+
+		if afterSignIn != nil {
+			return afterSignIn(tx, user)
+		}
+
+		return nil
+	}); err != nil {
+		return err
 	}
 
-	http.Redirect(w, r, ssoRedirectURL.String(), http.StatusSeeOther)
+	if !utilities.IsRedirectURLValid(config, redirectTo) {
+		redirectTo = config.SiteURL
+	}
+	if flowState != nil {
+		// This means that the callback is using PKCE
+		// Set the flowState.AuthCode to the query param here
+		redirectTo, err := a.prepPKCERedirectURL(redirectTo, flowState.AuthCode)
+		if err != nil {
+			return err
+		}
+		http.Redirect(w, r, redirectTo, http.StatusFound)
+		return nil
+
+	}
+	http.Redirect(w, r, token.AsRedirectURL(redirectTo, url.Values{}), http.StatusFound)
+
 	return nil
 }
@@ -2,20 +2,24 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/gofrs/uuid"
 	jwt "github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"github.com/supabase/auth/internal/conf"
 	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
 )
 
 const dateInPast = "2001-02-03T04:05:06.789"
@@ -603,6 +607,18 @@ func (ts *SSOTestSuite) TestSingleSignOn() {
 				"metadata_xml": validSAMLIDPMetadata("https://accounts.google.com/o/saml2?idpid=EXAMPLE-B"),
 			},
 		},
+		{
+			// creates a SAML provider (EXAMPLE-C)
+			// does have a domain mapping on example.net and its subdomains
+			Request: map[string]interface{}{
+				"type": "saml",
+				"domains": []string{
+					"example.net",
+					"*.example.net",
+				},
+				"metadata_xml": validSAMLIDPMetadata("https://accounts.google.com/o/saml2?idpid=EXAMPLE-C"),
+			},
+		},
 	}
 
 	for i, example := range providers {
@@ -679,6 +695,67 @@ func (ts *SSOTestSuite) TestSingleSignOn() {
 			},
 			Code: http.StatusNotFound,
 		},
+		{
+			// call /sso with email=Jane@Example.com (provider=EXAMPLE-B)
+			// should be successful and redirect to the EXAMPLE-B SSO URL
+			Request: map[string]interface{}{
+				"email": "Jane@Example.com",
+			},
+			Code: http.StatusSeeOther,
+			URL:  "https://accounts.google.com/o/saml2?idpid=EXAMPLE-B",
+		},
+		{
+			// call /sso with email=jane@eu.example.net (provider=EXAMPLE-C via *.example.net)
+			// should be successful and redirect to the EXAMPLE-C SSO URL
+			Request: map[string]interface{}{
+				"email":              "jane@eu.example.net",
+				"skip_http_redirect": true,
+			},
+			Code: http.StatusOK,
+			URL:  "https://accounts.google.com/o/saml2?idpid=EXAMPLE-C",
+		},
+		{
+			// call /sso with domain=eu.example.net (provider=EXAMPLE-C via *.example.net)
+			// should be successful and redirect to the EXAMPLE-C SSO URL
+			Request: map[string]interface{}{
+				"domain": "eu.example.net",
+			},
+			Code: http.StatusSeeOther,
+			URL:  "https://accounts.google.com/o/saml2?idpid=EXAMPLE-C",
+		},
+		{
+			// call /sso with domain=eu.example.com (no wildcard for example.com)
+			// should be unsuccessful with 404
+			Request: map[string]interface{}{
+				"domain": "eu.example.com",
+			},
+			Code: http.StatusNotFound,
+		},
+		{
+			// call /sso with email=jane@example.org (no such provider)
+			// should be unsuccessful with 404
+			Request: map[string]interface{}{
+				"email": "jane@example.org",
+			},
+			Code: http.StatusNotFound,
+		},
+		{
+			// call /sso with both email and domain
+			// should be unsuccessful with 400
+			Request: map[string]interface{}{
+				"email":  "jane@example.com",
+				"domain": "example.com",
+			},
+			Code: http.StatusBadRequest,
+		},
+		{
+			// call /sso with an invalid email
+			// should be unsuccessful with 400
+			Request: map[string]interface{}{
+				"email": "example.com",
+			},
+			Code: http.StatusBadRequest,
+		},
 		{
 			// call /sso with a provider_id=<random-uuid> (no such provider)
 			// should be unsuccessful with 404
@@ -747,6 +824,516 @@ func (ts *SSOTestSuite) TestSingleSignOn() {
 	}
 }
 
+func (ts *SSOTestSuite) TestAdminSSODomainVerification() {
+	defer func(lookupTXT func(context.Context, string) ([]string, error)) {
+		ssoDomainLookupTXT = lookupTXT
+		ts.Config.SSODomainVerification.Enabled = false
+	}(ssoDomainLookupTXT)
+
+	ts.Config.SSODomainVerification.Enabled = true
+
+	records := map[string][]string{}
+	ssoDomainLookupTXT = func(ctx context.Context, name string) ([]string, error) {
+		return records[name], nil
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"type":         "saml",
+		"metadata_xml": validSAMLIDPMetadata("https://accounts.google.com/o/saml2?idpid=EXAMPLE-DOMAIN-VERIFICATION"),
+		"domains":      []string{"example.com"},
+	})
+	require.NoError(ts.T(), err)
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/admin/sso/providers", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer "+ts.AdminJWT)
+	w := httptest.NewRecorder()
+
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusCreated, w.Code)
+
+	var provider struct {
+		ID string `json:"id"`
+	}
+	require.NoError(ts.T(), json.Unmarshal(w.Body.Bytes(), &provider))
+
+	singleSignOn := func() int {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/sso", strings.NewReader(`{"domain":"example.com","skip_http_redirect":true}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		ts.API.handler.ServeHTTP(w, req)
+
+		return w.Code
+	}
+
+	verification := func(path string, code int) *SSODomainVerificationResponse {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/admin/sso/providers/"+provider.ID+"/domains/"+path, nil)
+		req.Header.Set("Authorization", "Bearer "+ts.AdminJWT)
+		w := httptest.NewRecorder()
+
+		ts.API.handler.ServeHTTP(w, req)
+		require.Equal(ts.T(), code, w.Code, path)
+
+		var response SSODomainVerificationResponse
+		require.NoError(ts.T(), json.Unmarshal(w.Body.Bytes(), &response))
+
+		return &response
+	}
+
+	// unverified domains are not used to find the provider
+	require.Equal(ts.T(), http.StatusNotFound, singleSignOn())
+
+	verification("example.org/verification", http.StatusNotFound)
+	verification("example.com/verification/confirm", http.StatusBadRequest)
+
+	response := verification("example.com/verification", http.StatusOK)
+	require.Equal(ts.T(), "TXT", response.RecordType)
+	require.Equal(ts.T(), "_supabase-auth.example.com", response.RecordName)
+	require.True(ts.T(), strings.HasPrefix(response.RecordValue, ssoDomainVerificationPrefix))
+	require.Nil(ts.T(), response.VerifiedAt)
+
+	// initiating again returns the same record
+	require.Equal(ts.T(), response.RecordValue, verification("example.com/verification", http.StatusOK).RecordValue)
+
+	verification("example.com/verification/confirm", http.StatusBadRequest)
+	require.Equal(ts.T(), http.StatusNotFound, singleSignOn())
+
+	records[response.RecordName] = []string{"v=spf1 -all", response.RecordValue}
+
+	require.NotNil(ts.T(), verification("example.com/verification/confirm", http.StatusOK).VerifiedAt)
+	require.Equal(ts.T(), http.StatusOK, singleSignOn())
+}
+
 func TestSSOCreateParamsValidation(t *testing.T) {
-	// TODO
+	examples := []struct {
+		Domains []string
+		Valid   bool
+	}{
+		{Domains: []string{"example.com", "Corp.Example.COM"}, Valid: true},
+		{Domains: []string{"*.example.com"}, Valid: true},
+		{Domains: []string{"*.eu.example.com", "example-1.co.uk"}, Valid: true},
+		{Domains: []string{"*.com"}, Valid: false},
+		{Domains: []string{"eu.*.example.com"}, Valid: false},
+		{Domains: []string{"localhost"}, Valid: false},
+		{Domains: []string{"-example.com"}, Valid: false},
+		{Domains: []string{"user@example.com"}, Valid: false},
+	}
+
+	for _, example := range examples {
+		params := &CreateSSOProviderParams{
+			Type:        "saml",
+			MetadataXML: "<md:EntityDescriptor/>",
+			Domains:     example.Domains,
+		}
+
+		err := params.validate(false)
+		if example.Valid {
+			require.NoError(t, err, "%v", example.Domains)
+
+			for _, domain := range params.Domains {
+				require.Equal(t, strings.ToLower(domain), domain)
+			}
+		} else {
+			require.Error(t, err, "%v", example.Domains)
+		}
+	}
+}
+
+func TestSSOCreateParamsProvisioningValidation(t *testing.T) {
+	examples := []struct {
+		Provisioning *models.SSOProvisioning
+		Valid        bool
+	}{
+		{
+			Provisioning: &models.SSOProvisioning{
+				DefaultRole: " member ",
+				AppMetadata: map[string]interface{}{
+					"tenant": "{{ organization }}",
+				},
+				AppMetadataAttributes: map[string]string{
+					"groups": "groups",
+				},
+				GroupMappings: []models.SSOGroupMapping{
+					{Group: " admins ", Role: "admin"},
+					{Group: "billing", AppMetadata: map[string]interface{}{"billing": true}},
+				},
+			},
+			Valid: true,
+		},
+		{
+			Provisioning: &models.SSOProvisioning{
+				GroupMappings: []models.SSOGroupMapping{
+					{Group: " ", Role: "admin"},
+				},
+			},
+			Valid: false,
+		},
+		{
+			Provisioning: &models.SSOProvisioning{
+				GroupMappings: []models.SSOGroupMapping{
+					{Group: "admins"},
+				},
+			},
+			Valid: false,
+		},
+		{
+			Provisioning: &models.SSOProvisioning{
+				GroupMappings: []models.SSOGroupMapping{
+					{Group: "admins", AppMetadata: map[string]interface{}{"provider": "okta"}},
+				},
+			},
+			Valid: false,
+		},
+		{
+			Provisioning: &models.SSOProvisioning{
+				AppMetadata: map[string]interface{}{
+					"provider": "okta",
+				},
+			},
+			Valid: false,
+		},
+		{
+			Provisioning: &models.SSOProvisioning{
+				AppMetadataAttributes: map[string]string{
+					"providers": "groups",
+				},
+			},
+			Valid: false,
+		},
+		{
+			Provisioning: &models.SSOProvisioning{
+				AppMetadataAttributes: map[string]string{
+					"groups": "",
+				},
+			},
+			Valid: false,
+		},
+	}
+
+	for i, example := range examples {
+		params := &CreateSSOProviderParams{
+			Type:         "saml",
+			MetadataXML:  "<md:EntityDescriptor/>",
+			Provisioning: example.Provisioning,
+		}
+
+		err := params.validate(false)
+		if example.Valid {
+			require.NoError(t, err, "Example %d failed", i)
+			require.Equal(t, "member", params.Provisioning.DefaultRole)
+		} else {
+			require.Error(t, err, "Example %d failed", i)
+		}
+	}
+}
+
+func TestSSOCreateParamsAssertionPolicyValidation(t *testing.T) {
+	seconds := func(value int) *int {
+		return &value
+	}
+
+	examples := []struct {
+		AssertionPolicy *models.SAMLAssertionPolicy
+		Valid           bool
+	}{
+		{
+			AssertionPolicy: &models.SAMLAssertionPolicy{
+				RejectUnsolicitedInResponseTo: true,
+				MaxClockSkew:                  seconds(0),
+				ReplayWindow:                  seconds(3600),
+			},
+			Valid: true,
+		},
+		{
+			AssertionPolicy: &models.SAMLAssertionPolicy{
+				MaxClockSkew: seconds(600),
+			},
+			Valid: true,
+		},
+		{
+			AssertionPolicy: &models.SAMLAssertionPolicy{
+				MaxClockSkew: seconds(601),
+			},
+			Valid: false,
+		},
+		{
+			AssertionPolicy: &models.SAMLAssertionPolicy{
+				MaxClockSkew: seconds(-1),
+			},
+			Valid: false,
+		},
+		{
+			AssertionPolicy: &models.SAMLAssertionPolicy{
+				ReplayWindow: seconds(-1),
+			},
+			Valid: false,
+		},
+	}
+
+	for i, example := range examples {
+		params := &CreateSSOProviderParams{
+			Type:            "saml",
+			MetadataXML:     "<md:EntityDescriptor/>",
+			AssertionPolicy: example.AssertionPolicy,
+		}
+
+		err := params.validate(false)
+		if example.Valid {
+			require.NoError(t, err, "Example %d failed", i)
+		} else {
+			require.Error(t, err, "Example %d failed", i)
+		}
+	}
+}
+
+func TestSSOCreateParamsAuthnRequestPolicyValidation(t *testing.T) {
+	examples := []struct {
+		AuthnRequestPolicy *models.SAMLAuthnRequestPolicy
+		Valid              bool
+	}{
+		{
+			AuthnRequestPolicy: &models.SAMLAuthnRequestPolicy{
+				ForceAuthn: true,
+				RequestedAuthnContext: &models.SAMLRequestedAuthnContext{
+					Comparison: "minimum",
+					ClassRef:   "urn:oasis:names:tc:SAML:2.0:ac:classes:MultiFactor",
+				},
+				AAL2AuthnContextClassRefs: []string{"urn:oasis:names:tc:SAML:2.0:ac:classes:MultiFactor"},
+			},
+			Valid: true,
+		},
+		{
+			AuthnRequestPolicy: &models.SAMLAuthnRequestPolicy{
+				RequestedAuthnContext: &models.SAMLRequestedAuthnContext{
+					ClassRef: "urn:oasis:names:tc:SAML:2.0:ac:classes:PasswordProtectedTransport",
+				},
+			},
+			Valid: true,
+		},
+		{
+			AuthnRequestPolicy: &models.SAMLAuthnRequestPolicy{
+				RequestedAuthnContext: &models.SAMLRequestedAuthnContext{
+					Comparison: "minimum",
+				},
+			},
+			Valid: false,
+		},
+		{
+			AuthnRequestPolicy: &models.SAMLAuthnRequestPolicy{
+				RequestedAuthnContext: &models.SAMLRequestedAuthnContext{
+					Comparison: "at-least",
+					ClassRef:   "urn:oasis:names:tc:SAML:2.0:ac:classes:MultiFactor",
+				},
+			},
+			Valid: false,
+		},
+		{
+			AuthnRequestPolicy: &models.SAMLAuthnRequestPolicy{
+				ResponseBinding: models.SAMLResponseBindingArtifact,
+			},
+			Valid: true,
+		},
+		{
+			AuthnRequestPolicy: &models.SAMLAuthnRequestPolicy{
+				ResponseBinding: "redirect",
+			},
+			Valid: false,
+		},
+		{
+			AuthnRequestPolicy: &models.SAMLAuthnRequestPolicy{
+				AAL2AuthnContextClassRefs: []string{" "},
+			},
+			Valid: false,
+		},
+	}
+
+	for i, example := range examples {
+		params := &CreateSSOProviderParams{
+			Type:               "saml",
+			MetadataXML:        "<md:EntityDescriptor/>",
+			AuthnRequestPolicy: example.AuthnRequestPolicy,
+		}
+
+		err := params.validate(false)
+		if example.Valid {
+			require.NoError(t, err, "Example %d failed", i)
+		} else {
+			require.Error(t, err, "Example %d failed", i)
+		}
+	}
+}
+
+func TestSSOCreateParamsOIDCValidation(t *testing.T) {
+	scopes := func(value string) *string {
+		return &value
+	}
+
+	examples := []struct {
+		Params    CreateSSOProviderParams
+		ForUpdate bool
+		Valid     bool
+	}{
+		{
+			Params: CreateSSOProviderParams{
+				Type:         "oidc",
+				Issuer:       "https://idp.example.com",
+				ClientID:     "client-id",
+				ClientSecret: "client-secret",
+				Scopes:       scopes("groups  offline_access"),
+				Domains:      []string{"example.com"},
+			},
+			Valid: true,
+		},
+		{
+			Params: CreateSSOProviderParams{
+				Type:   "oidc",
+				Issuer: "https://idp.example.com",
+			},
+			ForUpdate: true,
+			Valid:     true,
+		},
+		{
+			Params: CreateSSOProviderParams{
+				Type:     "oidc",
+				Issuer:   "https://idp.example.com",
+				ClientID: "client-id",
+			},
+			Valid: false,
+		},
+		{
+			Params: CreateSSOProviderParams{
+				Type:         "oidc",
+				Issuer:       "http://idp.example.com",
+				ClientID:     "client-id",
+				ClientSecret: "client-secret",
+			},
+			Valid: false,
+		},
+		{
+			Params: CreateSSOProviderParams{
+				Type:         "oidc",
+				Issuer:       "https://idp.example.com",
+				ClientID:     "client-id",
+				ClientSecret: "client-secret",
+				MetadataURL:  "https://idp.example.com/metadata",
+			},
+			Valid: false,
+		},
+		{
+			Params: CreateSSOProviderParams{
+				Type:        "saml",
+				MetadataXML: "<md:EntityDescriptor/>",
+				ClientID:    "client-id",
+			},
+			Valid: false,
+		},
+		{
+			Params: CreateSSOProviderParams{
+				Type:         "openid",
+				Issuer:       "https://idp.example.com",
+				ClientID:     "client-id",
+				ClientSecret: "client-secret",
+			},
+			Valid: false,
+		},
+	}
+
+	for i := range examples {
+		example := &examples[i]
+
+		err := example.Params.validate(example.ForUpdate)
+		if example.Valid {
+			require.NoError(t, err, "Example %d failed", i)
+		} else {
+			require.Error(t, err, "Example %d failed", i)
+		}
+	}
+
+	require.Equal(t, "groups offline_access", *examples[0].Params.Scopes)
+}
+
+func TestSSOOIDCScopes(t *testing.T) {
+	require.Equal(t, []string{"openid", "email", "profile"}, ssoOIDCScopes(&models.OIDCProvider{}))
+	require.Equal(t, []string{"openid", "email", "profile", "groups"}, ssoOIDCScopes(&models.OIDCProvider{
+		Scopes: storage.NullString("email groups"),
+	}))
+}
+
+func (ts *SSOTestSuite) TestAdminSSOProviderSingleLogout() {
+	metadataWithSLO := strings.Replace(
+		validSAMLIDPMetadata("https://accounts.google.com/o/saml2?idpid=EXAMPLE-SLO"),
+		"  </md:IDPSSODescriptor>",
+		`    <md:SingleLogoutService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://accounts.google.com/o/saml2/slo"/>
+  </md:IDPSSODescriptor>`,
+		1,
+	)
+
+	examples := []struct {
+		Request map[string]interface{}
+		Code    int
+	}{
+		{
+			// metadata without a SingleLogoutService
+			Request: map[string]interface{}{
+				"type":                  "saml",
+				"metadata_xml":          validSAMLIDPMetadata("https://accounts.google.com/o/saml2?idpid=EXAMPLE-NO-SLO"),
+				"single_logout_enabled": true,
+			},
+			Code: http.StatusBadRequest,
+		},
+		{
+			Request: map[string]interface{}{
+				"type":                  "saml",
+				"metadata_xml":          metadataWithSLO,
+				"single_logout_enabled": true,
+			},
+			Code: http.StatusCreated,
+		},
+	}
+
+	var providerID string
+
+	for i, example := range examples {
+		body, err := json.Marshal(example.Request)
+		require.NoError(ts.T(), err)
+
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/admin/sso/providers", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+ts.AdminJWT)
+		w := httptest.NewRecorder()
+
+		ts.API.handler.ServeHTTP(w, req)
+
+		require.Equal(ts.T(), example.Code, w.Code, "Example %d failed", i)
+
+		if w.Code == http.StatusCreated {
+			var payload struct {
+				ID   string `json:"id"`
+				SAML struct {
+					SingleLogoutEnabled bool `json:"single_logout_enabled"`
+				} `json:"saml"`
+			}
+
+			require.NoError(ts.T(), json.Unmarshal(w.Body.Bytes(), &payload))
+			require.True(ts.T(), payload.SAML.SingleLogoutEnabled)
+
+			providerID = payload.ID
+		}
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"single_logout_enabled": false,
+	})
+	require.NoError(ts.T(), err)
+
+	req := httptest.NewRequest(http.MethodPut, "http://localhost/admin/sso/providers/"+providerID, bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer "+ts.AdminJWT)
+	w := httptest.NewRecorder()
+
+	ts.API.handler.ServeHTTP(w, req)
+
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	provider, err := models.FindSSOProviderByID(ts.API.db, uuid.FromStringOrNil(providerID))
+	require.NoError(ts.T(), err)
+	require.False(ts.T(), provider.SAMLProvider.SingleLogoutEnabled)
 }
@@ -3,15 +3,22 @@ package api
 import (
 	"context"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"reflect"
+	"regexp"
 	"strings"
+	"time"
 	"unicode/utf8"
 
+	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/crewjam/saml"
 	"github.com/crewjam/saml/samlsp"
 	"github.com/go-chi/chi/v5"
 	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/crypto"
 	"github.com/supabase/auth/internal/models"
 	"github.com/supabase/auth/internal/observability"
 	"github.com/supabase/auth/internal/storage"
@@ -68,6 +75,10 @@ func (a *API) adminSSOProvidersList(w http.ResponseWriter, r *http.Request) erro
 	})
 }
 
+// ssoDomainPattern matches domains with at least two labels, which may
+// start with a wildcard label matching any subdomain.
+var ssoDomainPattern = regexp.MustCompile(`^(\*\.)?([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
 type CreateSSOProviderParams struct {
 	Type string `json:"type"`
 
@@ -76,11 +87,45 @@ type CreateSSOProviderParams struct {
 	Domains          []string                    `json:"domains"`
 	AttributeMapping models.SAMLAttributeMapping `json:"attribute_mapping"`
 	NameIDFormat     string                      `json:"name_id_format"`
+
+	SingleLogoutEnabled *bool `json:"single_logout_enabled"`
+
+	Provisioning *models.SSOProvisioning `json:"provisioning"`
+
+	AssertionPolicy *models.SAMLAssertionPolicy `json:"assertion_policy"`
+
+	AuthnRequestPolicy *models.SAMLAuthnRequestPolicy `json:"authn_request_policy"`
+
+	Issuer       string  `json:"issuer"`
+	ClientID     string  `json:"client_id"`
+	ClientSecret string  `json:"client_secret"`
+	Scopes       *string `json:"scopes"`
 }
 
+// validate validates the parameters for the type of the provider, which
+// on update needs to be set to the type of the provider being updated.
 func (p *CreateSSOProviderParams) validate(forUpdate bool) error {
-	if !forUpdate && p.Type != "saml" {
-		return badRequestError(ErrorCodeValidationFailed, "Only 'saml' supported for SSO provider type")
+	if p.Type != "saml" && p.Type != "oidc" {
+		return badRequestError(ErrorCodeValidationFailed, "Only 'saml' or 'oidc' supported for SSO provider type")
+	}
+
+	if p.Type == "oidc" {
+		if p.MetadataURL != "" || p.MetadataXML != "" || p.AttributeMapping.Keys != nil || p.NameIDFormat != "" || p.SingleLogoutEnabled != nil || p.AssertionPolicy != nil || p.AuthnRequestPolicy != nil {
+			return badRequestError(ErrorCodeValidationFailed, "metadata_url, metadata_xml, attribute_mapping, name_id_format, single_logout_enabled, assertion_policy and authn_request_policy are only supported by 'saml' SSO providers")
+		} else if !forUpdate && (p.Issuer == "" || p.ClientID == "" || p.ClientSecret == "") {
+			return badRequestError(ErrorCodeValidationFailed, "issuer, client_id and client_secret must be set")
+		} else if p.Issuer != "" {
+			issuerURL, err := url.ParseRequestURI(p.Issuer)
+			if err != nil {
+				return badRequestError(ErrorCodeValidationFailed, "issuer is not a valid URL")
+			}
+
+			if issuerURL.Scheme != "https" {
+				return badRequestError(ErrorCodeValidationFailed, "issuer is not a HTTPS URL")
+			}
+		}
+	} else if p.Issuer != "" || p.ClientID != "" || p.ClientSecret != "" || p.Scopes != nil {
+		return badRequestError(ErrorCodeValidationFailed, "issuer, client_id, client_secret and scopes are only supported by 'oidc' SSO providers")
 	} else if p.MetadataURL != "" && p.MetadataXML != "" {
 		return badRequestError(ErrorCodeValidationFailed, "Only one of metadata_xml or metadata_url needs to be set")
 	} else if !forUpdate && p.MetadataURL == "" && p.MetadataXML == "" {
@@ -96,6 +141,92 @@ func (p *CreateSSOProviderParams) validate(forUpdate bool) error {
 		}
 	}
 
+	if p.Scopes != nil {
+		scopes := strings.Join(strings.Fields(*p.Scopes), " ")
+		p.Scopes = &scopes
+	}
+
+	for i, domain := range p.Domains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if !ssoDomainPattern.MatchString(domain) {
+			return badRequestError(ErrorCodeValidationFailed, "SSO domain '%s' is not a valid domain or wildcard domain (*.example.com)", p.Domains[i])
+		}
+
+		p.Domains[i] = domain
+	}
+
+	if p.Provisioning != nil {
+		p.Provisioning.DefaultRole = strings.TrimSpace(p.Provisioning.DefaultRole)
+
+		for key, attribute := range p.Provisioning.AppMetadataAttributes {
+			if attribute == "" {
+				return badRequestError(ErrorCodeValidationFailed, "provisioning.app_metadata_attributes.%s must name an attribute", key)
+			}
+		}
+
+		for _, key := range []string{"provider", "providers"} {
+			_, inAppMetadata := p.Provisioning.AppMetadata[key]
+			_, inAppMetadataAttributes := p.Provisioning.AppMetadataAttributes[key]
+
+			if inAppMetadata || inAppMetadataAttributes {
+				return badRequestError(ErrorCodeValidationFailed, "provisioning can't set the app_metadata.%s key", key)
+			}
+		}
+
+		p.Provisioning.GroupsAttribute = strings.TrimSpace(p.Provisioning.GroupsAttribute)
+
+		for i := range p.Provisioning.GroupMappings {
+			mapping := &p.Provisioning.GroupMappings[i]
+
+			mapping.Group = strings.TrimSpace(mapping.Group)
+			mapping.Role = strings.TrimSpace(mapping.Role)
+
+			if mapping.Group == "" {
+				return badRequestError(ErrorCodeValidationFailed, "provisioning.group_mappings[%d].group is required", i)
+			}
+
+			if mapping.Role == "" && len(mapping.AppMetadata) == 0 {
+				return badRequestError(ErrorCodeValidationFailed, "provisioning.group_mappings[%d] must set a role or app_metadata", i)
+			}
+
+			for _, key := range []string{"provider", "providers"} {
+				if _, ok := mapping.AppMetadata[key]; ok {
+					return badRequestError(ErrorCodeValidationFailed, "provisioning can't set the app_metadata.%s key", key)
+				}
+			}
+		}
+	}
+
+	if p.AssertionPolicy != nil {
+		maxClockSkewLimit := int(conf.SAMLMaxClockSkewLimit / time.Second)
+
+		if maxClockSkew := p.AssertionPolicy.MaxClockSkew; maxClockSkew != nil && (*maxClockSkew < 0 || *maxClockSkew > maxClockSkewLimit) {
+			return badRequestError(ErrorCodeValidationFailed, "assertion_policy.max_clock_skew must be between 0 and %d seconds", maxClockSkewLimit)
+		}
+
+		if replayWindow := p.AssertionPolicy.ReplayWindow; replayWindow != nil && *replayWindow < 0 {
+			return badRequestError(ErrorCodeValidationFailed, "assertion_policy.replay_window must not be negative")
+		}
+	}
+
+	if p.AuthnRequestPolicy != nil {
+		if err := validateSAMLRequestedAuthnContext("authn_request_policy.requested_authn_context", p.AuthnRequestPolicy.RequestedAuthnContext); err != nil {
+			return err
+		}
+
+		for _, classRef := range p.AuthnRequestPolicy.AAL2AuthnContextClassRefs {
+			if strings.TrimSpace(classRef) == "" {
+				return badRequestError(ErrorCodeValidationFailed, "authn_request_policy.aal2_authn_context_class_refs must not contain empty values")
+			}
+		}
+
+		switch p.AuthnRequestPolicy.ResponseBinding {
+		case "", models.SAMLResponseBindingPost, models.SAMLResponseBindingArtifact:
+		default:
+			return badRequestError(ErrorCodeValidationFailed, "authn_request_policy.response_binding must be one of post or artifact")
+		}
+	}
+
 	switch p.NameIDFormat {
 	case "",
 		string(saml.PersistentNameIDFormat),
@@ -165,6 +296,27 @@ func parseSAMLMetadata(rawMetadata []byte) (*saml.EntityDescriptor, error) {
 	return metadata, nil
 }
 
+// hasSAMLSingleLogoutService returns whether the identity provider has a
+// Single Logout Service with a binding supported for Single Logout.
+func hasSAMLSingleLogoutService(metadata *saml.EntityDescriptor) bool {
+	return samlSingleLogoutResponseLocation(metadata, saml.HTTPRedirectBinding) != "" ||
+		samlSingleLogoutResponseLocation(metadata, saml.HTTPPostBinding) != ""
+}
+
+// hasSAMLArtifactResolutionService returns whether the identity provider
+// resolves artifacts over SOAP.
+func hasSAMLArtifactResolutionService(metadata *saml.EntityDescriptor) bool {
+	for _, descriptor := range metadata.IDPSSODescriptors {
+		for _, service := range descriptor.ArtifactResolutionServices {
+			if service.Binding == saml.SOAPBinding && service.Location != "" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 func fetchSAMLMetadata(ctx context.Context, url string) ([]byte, error) {
 	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
@@ -208,6 +360,10 @@ func (a *API) adminSSOProvidersCreate(w http.ResponseWriter, r *http.Request) er
 		return err
 	}
 
+	if params.Type == "oidc" {
+		return a.adminSSOProvidersCreateOIDC(w, r, params)
+	}
+
 	rawMetadata, metadata, err := params.metadata(ctx)
 	if err != nil {
 		return err
@@ -237,22 +393,62 @@ func (a *API) adminSSOProvidersCreate(w http.ResponseWriter, r *http.Request) er
 		provider.SAMLProvider.NameIDFormat = &params.NameIDFormat
 	}
 
+	if params.SingleLogoutEnabled != nil {
+		if *params.SingleLogoutEnabled && !hasSAMLSingleLogoutService(metadata) {
+			return badRequestError(ErrorCodeValidationFailed, "single_logout_enabled requires the SAML Metadata to contain a SingleLogoutService")
+		}
+
+		provider.SAMLProvider.SingleLogoutEnabled = *params.SingleLogoutEnabled
+	}
+
 	provider.SAMLProvider.AttributeMapping = params.AttributeMapping
 
-	for _, domain := range params.Domains {
+	if params.Provisioning != nil {
+		provider.SAMLProvider.Provisioning = *params.Provisioning
+	}
+
+	if params.AssertionPolicy != nil {
+		provider.SAMLProvider.AssertionPolicy = *params.AssertionPolicy
+	}
+
+	if params.AuthnRequestPolicy != nil {
+		if params.AuthnRequestPolicy.ResponseBinding == models.SAMLResponseBindingArtifact && !hasSAMLArtifactResolutionService(metadata) {
+			return badRequestError(ErrorCodeValidationFailed, "authn_request_policy.response_binding artifact requires the SAML Metadata to contain a SOAP ArtifactResolutionService")
+		}
+
+		provider.SAMLProvider.AuthnRequestPolicy = *params.AuthnRequestPolicy
+	}
+
+	if provider.SSODomains, err = newSSODomains(db, params.Domains); err != nil {
+		return err
+	}
+
+	return a.createSSOProvider(w, db, provider)
+}
+
+// newSSODomains returns the domains of a new SSO provider, which must not
+// be assigned to another provider.
+func newSSODomains(db *storage.Connection, domains []string) ([]models.SSODomain, error) {
+	var ssoDomains []models.SSODomain
+
+	for _, domain := range domains {
 		existingProvider, err := models.FindSSOProviderByDomain(db, domain)
 		if err != nil && !models.IsNotFoundError(err) {
-			return err
+			return nil, err
 		}
 		if existingProvider != nil {
-			return badRequestError(ErrorCodeSSODomainAlreadyExists, "SSO Domain '%s' is already assigned to an SSO identity provider (%s)", domain, existingProvider.ID.String())
+			return nil, badRequestError(ErrorCodeSSODomainAlreadyExists, "SSO Domain '%s' is already assigned to an SSO identity provider (%s)", domain, existingProvider.ID.String())
 		}
 
-		provider.SSODomains = append(provider.SSODomains, models.SSODomain{
+		ssoDomains = append(ssoDomains, models.SSODomain{
 			Domain: domain,
 		})
 	}
 
+	return ssoDomains, nil
+}
+
+func (a *API) createSSOProvider(w http.ResponseWriter, db *storage.Connection, provider *models.SSOProvider) error {
 	if err := db.Transaction(func(tx *storage.Connection) error {
 		if terr := tx.Eager().Create(provider); terr != nil {
 			return terr
@@ -266,6 +462,68 @@ func (a *API) adminSSOProvidersCreate(w http.ResponseWriter, r *http.Request) er
 	return sendJSON(w, http.StatusCreated, provider)
 }
 
+// checkSSOOIDCIssuer checks that the issuer is not used by another SSO
+// provider and that its OpenID Connect configuration can be discovered.
+func checkSSOOIDCIssuer(ctx context.Context, db *storage.Connection, issuer string) error {
+	existingProvider, err := models.FindOIDCProviderByIssuer(db, issuer)
+	if err != nil && !models.IsNotFoundError(err) {
+		return err
+	}
+	if existingProvider != nil {
+		return unprocessableEntityError(ErrorCodeSSOOIDCIssuerAlreadyExists, "OpenID Connect Identity Provider with this issuer (%s) already exists", issuer)
+	}
+
+	if _, err := oidc.NewProvider(ctx, issuer); err != nil {
+		return badRequestError(ErrorCodeSSOOIDCDiscoveryFailed, "Unable to discover the OpenID Connect configuration of issuer '%s'", issuer).WithInternalError(err)
+	}
+
+	return nil
+}
+
+// adminSSOProvidersCreateOIDC creates a new OpenID Connect Identity Provider
+// in the system.
+func (a *API) adminSSOProvidersCreateOIDC(w http.ResponseWriter, r *http.Request, params *CreateSSOProviderParams) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+
+	if err := checkSSOOIDCIssuer(ctx, db, params.Issuer); err != nil {
+		return err
+	}
+
+	oidcProviderID, err := uuid.NewV4()
+	if err != nil {
+		return internalServerError("Error generating unique OpenID Connect provider ID").WithInternalError(err)
+	}
+
+	provider := &models.SSOProvider{
+		OIDCProvider: models.OIDCProvider{
+			ID:       oidcProviderID,
+			Issuer:   params.Issuer,
+			ClientID: params.ClientID,
+		},
+	}
+
+	// the client secret is encrypted with the ID as associated data
+	if err := provider.OIDCProvider.SetClientSecret(params.ClientSecret, config.Security.DBEncryption.Encrypt, config.Security.DBEncryption.EncryptionKeyID, config.Security.DBEncryption.EncryptionKey); err != nil {
+		return internalServerError("Error encrypting OpenID Connect client secret").WithInternalError(err)
+	}
+
+	if params.Scopes != nil {
+		provider.OIDCProvider.Scopes = storage.NullString(*params.Scopes)
+	}
+
+	if params.Provisioning != nil {
+		provider.OIDCProvider.Provisioning = *params.Provisioning
+	}
+
+	if provider.SSODomains, err = newSSODomains(db, params.Domains); err != nil {
+		return err
+	}
+
+	return a.createSSOProvider(w, db, provider)
+}
+
 // adminSSOProvidersGet returns an existing SAML Identity Provider in the system.
 func (a *API) adminSSOProvidersGet(w http.ResponseWriter, r *http.Request) error {
 	provider := getSSOProvider(r.Context())
@@ -283,14 +541,21 @@ func (a *API) adminSSOProvidersUpdate(w http.ResponseWriter, r *http.Request) er
 		return err
 	}
 
+	provider := getSSOProvider(ctx)
+
+	if params.Type != "" && params.Type != provider.Type() {
+		return badRequestError(ErrorCodeValidationFailed, "SSO provider type can't be changed")
+	}
+
+	params.Type = provider.Type()
+
 	if err := params.validate(true /* <- forUpdate */); err != nil {
 		return err
 	}
 
 	modified := false
 	updateSAMLProvider := false
-
-	provider := getSSOProvider(ctx)
+	updateOIDCProvider := false
 
 	if params.MetadataXML != "" || params.MetadataURL != "" {
 		// metadata is being updated
@@ -358,6 +623,73 @@ func (a *API) adminSSOProvidersUpdate(w http.ResponseWriter, r *http.Request) er
 		}
 	}
 
+	if params.Provisioning != nil && !reflect.DeepEqual(*params.Provisioning, *provider.Provisioning()) {
+		modified = true
+		*provider.Provisioning() = *params.Provisioning
+
+		if provider.Type() == "oidc" {
+			updateOIDCProvider = true
+		} else {
+			updateSAMLProvider = true
+		}
+	}
+
+	if params.Issuer != "" && params.Issuer != provider.OIDCProvider.Issuer {
+		if err := checkSSOOIDCIssuer(ctx, db, params.Issuer); err != nil {
+			return err
+		}
+
+		modified = true
+		updateOIDCProvider = true
+		provider.OIDCProvider.Issuer = params.Issuer
+	}
+
+	if params.ClientID != "" && params.ClientID != provider.OIDCProvider.ClientID {
+		modified = true
+		updateOIDCProvider = true
+		provider.OIDCProvider.ClientID = params.ClientID
+	}
+
+	if params.ClientSecret != "" {
+		config := a.config
+
+		if err := provider.OIDCProvider.SetClientSecret(params.ClientSecret, config.Security.DBEncryption.Encrypt, config.Security.DBEncryption.EncryptionKeyID, config.Security.DBEncryption.EncryptionKey); err != nil {
+			return internalServerError("Error encrypting OpenID Connect client secret").WithInternalError(err)
+		}
+
+		modified = true
+		updateOIDCProvider = true
+	}
+
+	if params.Scopes != nil && *params.Scopes != provider.OIDCProvider.Scopes.String() {
+		modified = true
+		updateOIDCProvider = true
+		provider.OIDCProvider.Scopes = storage.NullString(*params.Scopes)
+	}
+
+	if params.AssertionPolicy != nil && !reflect.DeepEqual(*params.AssertionPolicy, provider.SAMLProvider.AssertionPolicy) {
+		modified = true
+		updateSAMLProvider = true
+		provider.SAMLProvider.AssertionPolicy = *params.AssertionPolicy
+	}
+
+	if params.AuthnRequestPolicy != nil && !reflect.DeepEqual(*params.AuthnRequestPolicy, provider.SAMLProvider.AuthnRequestPolicy) {
+		if params.AuthnRequestPolicy.ResponseBinding == models.SAMLResponseBindingArtifact {
+			metadata, err := provider.SAMLProvider.EntityDescriptor()
+			if err != nil {
+				return internalServerError("Error parsing SAML Metadata for SAML provider").WithInternalError(err)
+			}
+
+			if !hasSAMLArtifactResolutionService(metadata) {
+				return badRequestError(ErrorCodeValidationFailed, "authn_request_policy.response_binding artifact requires the SAML Metadata to contain a SOAP ArtifactResolutionService")
+			}
+		}
+
+		modified = true
+		updateSAMLProvider = true
+		provider.SAMLProvider.AuthnRequestPolicy = *params.AuthnRequestPolicy
+	}
+
 	nameIDFormat := ""
 	if provider.SAMLProvider.NameIDFormat != nil {
 		nameIDFormat = *provider.SAMLProvider.NameIDFormat
@@ -373,6 +705,23 @@ func (a *API) adminSSOProvidersUpdate(w http.ResponseWriter, r *http.Request) er
 		}
 	}
 
+	if params.SingleLogoutEnabled != nil && *params.SingleLogoutEnabled != provider.SAMLProvider.SingleLogoutEnabled {
+		if *params.SingleLogoutEnabled {
+			metadata, err := provider.SAMLProvider.EntityDescriptor()
+			if err != nil {
+				return internalServerError("Error parsing SAML Metadata for SAML provider").WithInternalError(err)
+			}
+
+			if !hasSAMLSingleLogoutService(metadata) {
+				return badRequestError(ErrorCodeValidationFailed, "single_logout_enabled requires the SAML Metadata to contain a SingleLogoutService")
+			}
+		}
+
+		modified = true
+		updateSAMLProvider = true
+		provider.SAMLProvider.SingleLogoutEnabled = *params.SingleLogoutEnabled
+	}
+
 	if modified {
 		if err := db.Transaction(func(tx *storage.Connection) error {
 			if terr := tx.Eager().Update(provider); terr != nil {
@@ -395,6 +744,12 @@ func (a *API) adminSSOProvidersUpdate(w http.ResponseWriter, r *http.Request) er
 				}
 			}
 
+			if updateOIDCProvider {
+				if terr := tx.Eager().Update(&provider.OIDCProvider); terr != nil {
+					return terr
+				}
+			}
+
 			return tx.Eager().Load(provider)
 		}); err != nil {
 			return unprocessableEntityError(ErrorCodeConflict, "Updating SSO provider failed, likely due to a conflict. Try again?").WithInternalError(err)
@@ -419,3 +774,163 @@ func (a *API) adminSSOProvidersDelete(w http.ResponseWriter, r *http.Request) er
 
 	return sendJSON(w, http.StatusOK, provider)
 }
+
+// SCIMTokenResponse is returned when a SCIM token is issued for an SSO
+// provider. The token is not stored and can't be retrieved again.
+type SCIMTokenResponse struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// adminSSOProvidersSCIMTokenCreate issues the bearer token the identity
+// provider uses with the SCIM endpoints, replacing any previous token.
+func (a *API) adminSSOProvidersSCIMTokenCreate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	provider := getSSOProvider(ctx)
+
+	token := crypto.SecureToken(32)
+	tokenHash := models.HashSCIMToken(token)
+	provider.SCIMTokenHash = &tokenHash
+
+	if err := db.UpdateOnly(provider, "scim_token_hash"); err != nil {
+		return internalServerError("Database error updating SSO provider").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusCreated, &SCIMTokenResponse{
+		Token: token,
+		URL:   strings.TrimSuffix(a.config.API.ExternalURL, "/") + "/scim/v2",
+	})
+}
+
+// adminSSOProvidersSCIMTokenDelete revokes the SCIM token of the SSO
+// provider.
+func (a *API) adminSSOProvidersSCIMTokenDelete(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	provider := getSSOProvider(ctx)
+	provider.SCIMTokenHash = nil
+
+	if err := db.UpdateOnly(provider, "scim_token_hash"); err != nil {
+		return internalServerError("Database error updating SSO provider").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, provider)
+}
+
+// ssoDomainVerificationPrefix prefixes the token in the DNS TXT record
+// verifying the ownership of an SSO domain.
+const ssoDomainVerificationPrefix = "supabase-auth-domain-verification="
+
+// ssoDomainLookupTXT looks up the TXT records of a DNS name.
+var ssoDomainLookupTXT = net.DefaultResolver.LookupTXT
+
+// SSODomainVerificationResponse describes the DNS TXT record verifying the
+// ownership of an SSO domain.
+type SSODomainVerificationResponse struct {
+	Domain      string     `json:"domain"`
+	VerifiedAt  *time.Time `json:"verified_at,omitempty"`
+	RecordType  string     `json:"record_type"`
+	RecordName  string     `json:"record_name"`
+	RecordValue string     `json:"record_value"`
+}
+
+func (a *API) ssoDomainVerificationResponse(domain *models.SSODomain) *SSODomainVerificationResponse {
+	return &SSODomainVerificationResponse{
+		Domain:      domain.Domain,
+		VerifiedAt:  domain.VerifiedAt,
+		RecordType:  "TXT",
+		RecordName:  domain.VerificationRecordName(a.config.SSODomainVerification.RecordPrefix),
+		RecordValue: ssoDomainVerificationPrefix + *domain.VerificationToken,
+	}
+}
+
+// getSSODomain returns the domain in the URL route among the domains of the
+// SSO provider.
+func getSSODomain(r *http.Request, provider *models.SSOProvider) (*models.SSODomain, error) {
+	domain := strings.ToLower(chi.URLParam(r, "domain"))
+
+	for i := range provider.SSODomains {
+		if strings.ToLower(provider.SSODomains[i].Domain) == domain {
+			return &provider.SSODomains[i], nil
+		}
+	}
+
+	return nil, notFoundError(ErrorCodeSSODomainNotFound, "SSO domain '%s' is not assigned to this SSO provider", domain)
+}
+
+// adminSSODomainVerificationInitiate starts the verification of the ownership
+// of a domain of the SSO provider, returning the DNS TXT record to create.
+// The record stays the same until the domain is removed.
+func (a *API) adminSSODomainVerificationInitiate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	domain, err := getSSODomain(r, getSSOProvider(ctx))
+	if err != nil {
+		return err
+	}
+
+	if domain.VerificationToken == nil {
+		token := crypto.SecureToken()
+		domain.VerificationToken = &token
+
+		if err := db.UpdateOnly(domain, "verification_token"); err != nil {
+			return internalServerError("Database error updating SSO domain").WithInternalError(err)
+		}
+	}
+
+	return sendJSON(w, http.StatusOK, a.ssoDomainVerificationResponse(domain))
+}
+
+// adminSSODomainVerificationConfirm looks up the DNS TXT record of a domain
+// of the SSO provider and marks the domain as verified if it's found.
+func (a *API) adminSSODomainVerificationConfirm(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	domain, err := getSSODomain(r, getSSOProvider(ctx))
+	if err != nil {
+		return err
+	}
+
+	if domain.VerificationToken == nil {
+		return badRequestError(ErrorCodeSSODomainVerificationFailed, "Verification of SSO domain '%s' was not initiated", domain.Domain)
+	}
+
+	response := a.ssoDomainVerificationResponse(domain)
+
+	records, err := ssoDomainLookupTXT(ctx, response.RecordName)
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); !ok || !dnsErr.IsNotFound {
+			return badRequestError(ErrorCodeSSODomainVerificationFailed, "Unable to look up the TXT record '%s'", response.RecordName).WithInternalError(err)
+		}
+	}
+
+	found := false
+	for _, record := range records {
+		if strings.TrimSpace(record) == response.RecordValue {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return badRequestError(ErrorCodeSSODomainVerificationFailed, "No TXT record '%s' with the value '%s' was found", response.RecordName, response.RecordValue)
+	}
+
+	if !domain.IsVerified() {
+		verifiedAt := a.Now()
+		domain.VerifiedAt = &verifiedAt
+
+		if err := db.UpdateOnly(domain, "verified_at"); err != nil {
+			return internalServerError("Database error updating SSO domain").WithInternalError(err)
+		}
+
+		response.VerifiedAt = domain.VerifiedAt
+	}
+
+	return sendJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,294 @@
+package api
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/crewjam/saml"
+	"github.com/supabase/auth/internal/models"
+)
+
+// ssoTestExpiryWarning is how long before metadata or certificates expire
+// the connection test warns about it.
+const ssoTestExpiryWarning = 30 * 24 * time.Hour
+
+const (
+	ssoTestPass = "pass"
+	ssoTestWarn = "warn"
+	ssoTestFail = "fail"
+)
+
+var ssoTestWhitespace = regexp.MustCompile(`\s+`)
+
+type TestSSOProviderParams struct {
+	// SAMLResponse is a sample Base64 encoded SAML Response from the
+	// identity provider, as posted to the Assertion Consumer Service.
+	SAMLResponse string `json:"saml_response"`
+}
+
+// SSOProviderTestCheck is the result of one check of an SSO connection.
+type SSOProviderTestCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// SSOProviderTestReport is the result of testing an SSO connection. OK is
+// true when no check failed.
+type SSOProviderTestReport struct {
+	OK     bool                   `json:"ok"`
+	Checks []SSOProviderTestCheck `json:"checks"`
+
+	// Attributes are the attributes of the sample assertion after the
+	// attribute mapping of the provider was applied.
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+func (r *SSOProviderTestReport) add(name, status, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, SSOProviderTestCheck{
+		Name:    name,
+		Status:  status,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// adminSSOProvidersTest tests the connection with an SSO provider without
+// signing in, checking its metadata, certificates and, given a sample
+// response, the clock of the identity provider and the attribute mapping.
+func (a *API) adminSSOProvidersTest(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	params := &TestSSOProviderParams{}
+	if r.ContentLength != 0 {
+		if err := retrieveRequestParams(r, params); err != nil {
+			return err
+		}
+	}
+
+	provider := getSSOProvider(ctx)
+
+	report := &SSOProviderTestReport{}
+
+	if provider.Type() == "oidc" {
+		if params.SAMLResponse != "" {
+			return badRequestError(ErrorCodeValidationFailed, "saml_response is only supported by 'saml' SSO providers")
+		}
+
+		if _, err := oidc.NewProvider(ctx, provider.OIDCProvider.Issuer); err != nil {
+			report.add("discovery", ssoTestFail, "Unable to discover the OpenID Connect configuration of issuer '%s': %s", provider.OIDCProvider.Issuer, err.Error())
+		} else {
+			report.add("discovery", ssoTestPass, "OpenID Connect configuration of issuer '%s' was discovered", provider.OIDCProvider.Issuer)
+		}
+	} else {
+		metadata := a.testSAMLMetadata(r, provider, report)
+
+		if metadata != nil {
+			testSAMLCertificates(metadata, a.Now(), report)
+
+			if params.SAMLResponse != "" {
+				a.testSAMLResponse(provider, metadata, params.SAMLResponse, report)
+			}
+		}
+	}
+
+	report.OK = !slices.ContainsFunc(report.Checks, func(check SSOProviderTestCheck) bool {
+		return check.Status == ssoTestFail
+	})
+
+	return sendJSON(w, http.StatusOK, report)
+}
+
+// testSAMLMetadata checks the metadata of the identity provider, fetching it
+// again if it has a metadata URL.
+func (a *API) testSAMLMetadata(r *http.Request, provider *models.SSOProvider, report *SSOProviderTestReport) *saml.EntityDescriptor {
+	var metadata *saml.EntityDescriptor
+
+	if provider.SAMLProvider.MetadataURL != nil && *provider.SAMLProvider.MetadataURL != "" {
+		metadataURL := *provider.SAMLProvider.MetadataURL
+
+		rawMetadata, err := fetchSAMLMetadata(r.Context(), metadataURL)
+		if err != nil {
+			report.add("metadata", ssoTestFail, "Unable to fetch SAML Metadata from '%s': %s", metadataURL, err.Error())
+			return nil
+		}
+
+		if metadata, err = parseSAMLMetadata(rawMetadata); err != nil {
+			report.add("metadata", ssoTestFail, "SAML Metadata fetched from '%s' is not valid: %s", metadataURL, err.Error())
+			return nil
+		}
+
+		if metadata.EntityID != provider.SAMLProvider.EntityID {
+			report.add("metadata", ssoTestFail, "SAML Metadata fetched from '%s' is for EntityID '%s' instead of '%s'", metadataURL, metadata.EntityID, provider.SAMLProvider.EntityID)
+			return nil
+		}
+	} else {
+		var err error
+		if metadata, err = parseSAMLMetadata([]byte(provider.SAMLProvider.MetadataXML)); err != nil {
+			report.add("metadata", ssoTestFail, "SAML Metadata is not valid: %s", err.Error())
+			return nil
+		}
+	}
+
+	now := a.Now()
+
+	if !metadata.ValidUntil.IsZero() && !now.Before(metadata.ValidUntil) {
+		report.add("metadata", ssoTestFail, "SAML Metadata expired at %s", metadata.ValidUntil.Format(time.RFC3339))
+	} else if !metadata.ValidUntil.IsZero() && metadata.ValidUntil.Sub(now) <= ssoTestExpiryWarning {
+		report.add("metadata", ssoTestWarn, "SAML Metadata expires at %s", metadata.ValidUntil.Format(time.RFC3339))
+	} else {
+		report.add("metadata", ssoTestPass, "SAML Metadata for EntityID '%s' is valid", metadata.EntityID)
+	}
+
+	if samlSingleSignOnLocation(metadata, saml.HTTPRedirectBinding) == "" {
+		report.add("sso_service", ssoTestFail, "SAML Metadata does not contain a SingleSignOnService with the HTTP-Redirect binding, needed to start sign ins")
+	} else {
+		report.add("sso_service", ssoTestPass, "SAML Metadata contains a SingleSignOnService with the HTTP-Redirect binding")
+	}
+
+	return metadata
+}
+
+func samlSingleSignOnLocation(metadata *saml.EntityDescriptor, binding string) string {
+	for _, idpSSODescriptor := range metadata.IDPSSODescriptors {
+		for _, singleSignOnService := range idpSSODescriptor.SingleSignOnServices {
+			if singleSignOnService.Binding == binding {
+				return singleSignOnService.Location
+			}
+		}
+	}
+
+	return ""
+}
+
+// testSAMLCertificates checks the signing certificates in the metadata of
+// the identity provider.
+func testSAMLCertificates(metadata *saml.EntityDescriptor, now time.Time, report *SSOProviderTestReport) {
+	found := false
+
+	for _, idpSSODescriptor := range metadata.IDPSSODescriptors {
+		for _, keyDescriptor := range idpSSODescriptor.KeyDescriptors {
+			if keyDescriptor.Use != "" && keyDescriptor.Use != "signing" {
+				continue
+			}
+
+			for _, certificate := range keyDescriptor.KeyInfo.X509Data.X509Certificates {
+				found = true
+
+				certBytes, err := base64.StdEncoding.DecodeString(ssoTestWhitespace.ReplaceAllString(certificate.Data, ""))
+				if err != nil {
+					report.add("certificate", ssoTestFail, "Signing certificate is not valid Base64: %s", err.Error())
+					continue
+				}
+
+				cert, err := x509.ParseCertificate(certBytes)
+				if err != nil {
+					report.add("certificate", ssoTestFail, "Signing certificate is not valid: %s", err.Error())
+					continue
+				}
+
+				if !now.Before(cert.NotAfter) {
+					report.add("certificate", ssoTestFail, "Signing certificate '%s' expired at %s", cert.Subject.String(), cert.NotAfter.Format(time.RFC3339))
+				} else if cert.NotAfter.Sub(now) <= ssoTestExpiryWarning {
+					report.add("certificate", ssoTestWarn, "Signing certificate '%s' expires at %s", cert.Subject.String(), cert.NotAfter.Format(time.RFC3339))
+				} else {
+					report.add("certificate", ssoTestPass, "Signing certificate '%s' is valid until %s", cert.Subject.String(), cert.NotAfter.Format(time.RFC3339))
+				}
+			}
+		}
+	}
+
+	if !found {
+		report.add("certificate", ssoTestFail, "SAML Metadata does not contain any signing certificate")
+	}
+}
+
+// testSAMLResponse checks a sample response from the identity provider: its
+// signature and validity, the clock of the identity provider and the
+// attribute mapping.
+func (a *API) testSAMLResponse(provider *models.SSOProvider, metadata *saml.EntityDescriptor, samlResponse string, report *SSOProviderTestReport) {
+	responseXML, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		report.add("assertion", ssoTestFail, "saml_response is not a valid Base64 string")
+		return
+	}
+
+	now := a.Now()
+	maxClockSkew := a.samlMaxClockSkew(&provider.SAMLProvider)
+
+	serviceProvider := a.getSAMLServiceProvider(metadata, true /* <- idpInitiated */)
+
+	spAssertion, err := serviceProvider.ParseXMLResponse(responseXML, nil)
+	if err != nil {
+		if ire, ok := err.(*saml.InvalidResponseError); ok {
+			err = ire.PrivateErr
+		}
+
+		report.add("assertion", ssoTestFail, "SAML Response is not valid: %s", err.Error())
+
+		// the attribute mapping can still be checked on an
+		// unencrypted assertion
+		var response saml.Response
+		if xml.Unmarshal(responseXML, &response) != nil || response.Assertion == nil {
+			return
+		}
+
+		spAssertion = response.Assertion
+	} else if err := validateSAMLAssertionTimes(spAssertion, maxClockSkew, now); err != nil {
+		report.add("assertion", ssoTestFail, "SAML Assertion is not valid: %s", err.Error())
+	} else {
+		report.add("assertion", ssoTestPass, "SAML Assertion is signed by the identity provider and valid")
+	}
+
+	if skew := spAssertion.IssueInstant.Sub(now); skew > maxClockSkew {
+		report.add("clock_skew", ssoTestFail, "SAML Assertion was issued %s in the future, more than the allowed clock skew of %s", skew.Round(time.Second), maxClockSkew)
+	} else if skew > 0 {
+		report.add("clock_skew", ssoTestWarn, "SAML Assertion was issued %s in the future, within the allowed clock skew of %s", skew.Round(time.Second), maxClockSkew)
+	} else {
+		report.add("clock_skew", ssoTestPass, "SAML Assertion was issued %s ago", (-skew).Round(time.Second))
+	}
+
+	assertion := SAMLAssertion{
+		spAssertion,
+	}
+
+	if assertion.UserID() == "" {
+		report.add("attribute_mapping", ssoTestFail, "SAML Assertion does not contain a persistent Subject Identifier attribute or Subject NameID uniquely identifying the user")
+	}
+
+	claims := assertion.Process(provider.SAMLProvider.AttributeMapping)
+
+	if email, ok := claims["email"].(string); !ok || email == "" {
+		if email = assertion.Email(); email == "" {
+			report.add("attribute_mapping", ssoTestFail, "SAML Assertion does not contain an email address")
+		} else {
+			claims["email"] = email
+		}
+	}
+
+	var keys []string
+	for key := range provider.SAMLProvider.AttributeMapping.Keys {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		if _, ok := claims[key]; !ok {
+			report.add("attribute_mapping", ssoTestWarn, "Attribute mapping for '%s' matches no attribute of the SAML Assertion", key)
+		}
+	}
+
+	if !slices.ContainsFunc(report.Checks, func(check SSOProviderTestCheck) bool {
+		return check.Name == "attribute_mapping"
+	}) {
+		report.add("attribute_mapping", ssoTestPass, "SAML Assertion identifies the user and all mapped attributes are present")
+	}
+
+	report.Attributes = claims
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+)
+
+func ssoTestCheckStatuses(report *SSOProviderTestReport, name string) []string {
+	var statuses []string
+	for _, check := range report.Checks {
+		if check.Name == name {
+			statuses = append(statuses, check.Status)
+		}
+	}
+	return statuses
+}
+
+func TestSSOTestSAMLCertificates(t *testing.T) {
+	metadata, err := parseSAMLMetadata([]byte(validSAMLIDPMetadata("https://idp.example.com")))
+	require.NoError(t, err)
+
+	// the certificate of the metadata is valid until 2027-08-11
+	examples := []struct {
+		Now    time.Time
+		Status string
+	}{
+		{Now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), Status: ssoTestPass},
+		{Now: time.Date(2027, 8, 1, 0, 0, 0, 0, time.UTC), Status: ssoTestWarn},
+		{Now: time.Date(2027, 9, 1, 0, 0, 0, 0, time.UTC), Status: ssoTestFail},
+	}
+
+	for _, example := range examples {
+		report := &SSOProviderTestReport{}
+		testSAMLCertificates(metadata, example.Now, report)
+		require.Equal(t, []string{example.Status}, ssoTestCheckStatuses(report, "certificate"), "%v", example.Now)
+	}
+
+	metadata.IDPSSODescriptors[0].KeyDescriptors = nil
+
+	report := &SSOProviderTestReport{}
+	testSAMLCertificates(metadata, examples[0].Now, report)
+	require.Equal(t, []string{ssoTestFail}, ssoTestCheckStatuses(report, "certificate"))
+}
+
+func TestSSOTestSAMLMetadata(t *testing.T) {
+	api := &API{
+		config: &conf.GlobalConfiguration{},
+		overrideTime: func() time.Time {
+			return time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		},
+	}
+
+	provider := &models.SSOProvider{
+		SAMLProvider: models.SAMLProvider{
+			EntityID:    "https://idp.example.com",
+			MetadataXML: validSAMLIDPMetadata("https://idp.example.com"),
+		},
+	}
+
+	report := &SSOProviderTestReport{}
+	require.NotNil(t, api.testSAMLMetadata(nil, provider, report))
+	require.Equal(t, []string{ssoTestPass}, ssoTestCheckStatuses(report, "metadata"))
+	require.Equal(t, []string{ssoTestPass}, ssoTestCheckStatuses(report, "sso_service"))
+
+	provider.SAMLProvider.MetadataXML = configurableSAMLIDPMetadata("https://idp.example.com", dateInPast, oneHour)
+
+	report = &SSOProviderTestReport{}
+	require.NotNil(t, api.testSAMLMetadata(nil, provider, report))
+	require.Equal(t, []string{ssoTestFail}, ssoTestCheckStatuses(report, "metadata"))
+
+	provider.SAMLProvider.MetadataXML = "<md:EntityDescriptor/>"
+
+	report = &SSOProviderTestReport{}
+	require.Nil(t, api.testSAMLMetadata(nil, provider, report))
+	require.Equal(t, []string{ssoTestFail}, ssoTestCheckStatuses(report, "metadata"))
+}
+
+func TestSSOTestSAMLResponse(t *testing.T) {
+	config, err := conf.LoadGlobal(apiTestConfig)
+	require.NoError(t, err)
+	config.API.ExternalURL = "https://projectref.supabase.co/auth/v1/"
+	config.SAML.Enabled = true
+	config.SAML.PrivateKey = samlTestPrivateKey
+	require.NoError(t, config.ApplyDefaults())
+	require.NoError(t, config.SAML.PopulateFields(config.API.ExternalURL))
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	api := &API{
+		config: config,
+		overrideTime: func() time.Time {
+			return now
+		},
+	}
+
+	metadata, err := parseSAMLMetadata([]byte(validSAMLIDPMetadata("https://idp.example.com")))
+	require.NoError(t, err)
+
+	provider := &models.SSOProvider{
+		SAMLProvider: models.SAMLProvider{
+			EntityID: "https://idp.example.com",
+			AttributeMapping: models.SAMLAttributeMapping{
+				Keys: map[string]models.SAMLAttribute{
+					"department": {Name: "department"},
+					"groups":     {Name: "groups"},
+				},
+			},
+		},
+	}
+
+	// an unsigned response fails validation, but its attributes can
+	// still be checked against the attribute mapping
+	responseXML := fmt.Sprintf(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_response" Version="2.0" IssueInstant="%[1]s">
+  <saml:Issuer>https://idp.example.com</saml:Issuer>
+  <saml:Assertion ID="_assertion" Version="2.0" IssueInstant="%[1]s">
+    <saml:Issuer>https://idp.example.com</saml:Issuer>
+    <saml:Subject>
+      <saml:NameID Format="urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress">user@example.com</saml:NameID>
+    </saml:Subject>
+    <saml:AttributeStatement>
+      <saml:Attribute Name="department">
+        <saml:AttributeValue>engineering</saml:AttributeValue>
+      </saml:Attribute>
+    </saml:AttributeStatement>
+  </saml:Assertion>
+</samlp:Response>`, now.Add(-2*time.Minute).Format(time.RFC3339))
+
+	report := &SSOProviderTestReport{}
+	api.testSAMLResponse(provider, metadata, base64.StdEncoding.EncodeToString([]byte(responseXML)), report)
+
+	require.Equal(t, []string{ssoTestFail}, ssoTestCheckStatuses(report, "assertion"))
+	require.Equal(t, []string{ssoTestPass}, ssoTestCheckStatuses(report, "clock_skew"))
+	require.Equal(t, []string{ssoTestWarn}, ssoTestCheckStatuses(report, "attribute_mapping"))
+	require.Equal(t, "engineering", report.Attributes["department"])
+	require.Equal(t, "user@example.com", report.Attributes["email"])
+
+	report = &SSOProviderTestReport{}
+	api.testSAMLResponse(provider, metadata, "not base64", report)
+	require.Equal(t, []string{ssoTestFail}, ssoTestCheckStatuses(report, "assertion"))
+}
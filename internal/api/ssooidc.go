@@ -0,0 +1,210 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/api/provider"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+	"golang.org/x/oauth2"
+)
+
+// ssoOIDCCallbackURL returns the redirect URI registered with the OpenID
+// Connect identity providers of SSO providers.
+func (a *API) ssoOIDCCallbackURL() string {
+	return strings.TrimSuffix(a.config.API.ExternalURL, "/") + "/sso/oidc/callback"
+}
+
+// ssoOIDCScopes returns the scopes requested from the identity provider,
+// which always include openid, email and profile.
+func ssoOIDCScopes(oidcProvider *models.OIDCProvider) []string {
+	scopes := []string{oidc.ScopeOpenID, "email", "profile"}
+
+	for _, scope := range strings.Fields(oidcProvider.Scopes.String()) {
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return scopes
+}
+
+// ssoOIDCConfig discovers the issuer of the OpenID Connect connection and
+// returns the OAuth 2.0 client configuration for it.
+func (a *API) ssoOIDCConfig(ctx context.Context, oidcProvider *models.OIDCProvider) (*oidc.Provider, *oauth2.Config, error) {
+	discoveredProvider, err := oidc.NewProvider(ctx, oidcProvider.Issuer)
+	if err != nil {
+		return nil, nil, internalServerError("Unable to discover the OpenID Connect provider").WithInternalError(err)
+	}
+
+	clientSecret, err := oidcProvider.GetClientSecret(a.config.Security.DBEncryption.DecryptionKeys)
+	if err != nil {
+		return nil, nil, internalServerError("Unable to decrypt the OpenID Connect client secret").WithInternalError(err)
+	}
+
+	return discoveredProvider, &oauth2.Config{
+		ClientID:     oidcProvider.ClientID,
+		ClientSecret: clientSecret,
+		Endpoint:     discoveredProvider.Endpoint(),
+		RedirectURL:  a.ssoOIDCCallbackURL(),
+		Scopes:       ssoOIDCScopes(oidcProvider),
+	}, nil
+}
+
+// oidcSingleSignOnURL creates the relay state of an OpenID Connect sign in
+// and returns the URL of the authorization request to the identity provider.
+// The relay state ID is used as the OAuth state and its request ID as the
+// nonce of the ID token.
+func (a *API) oidcSingleSignOnURL(ctx context.Context, db *storage.Connection, ssoProvider *models.SSOProvider, relayState *models.SAMLRelayState) (*url.URL, error) {
+	_, oauthConfig, err := a.ssoOIDCConfig(ctx, &ssoProvider.OIDCProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	relayState.RequestID = crypto.SecureToken()
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := tx.Create(relayState); terr != nil {
+			return internalServerError("Error creating OpenID Connect state from sign up").WithInternalError(terr)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	authURLParams := []oauth2.AuthCodeOption{
+		oidc.Nonce(relayState.RequestID),
+	}
+
+	if relayState.ForEmail != nil {
+		authURLParams = append(authURLParams, oauth2.SetAuthURLParam("login_hint", *relayState.ForEmail))
+	}
+
+	ssoRedirectURL, err := url.Parse(oauthConfig.AuthCodeURL(relayState.ID.String(), authURLParams...))
+	if err != nil {
+		return nil, internalServerError("Error creating OpenID Connect authorization request URL").WithInternalError(err)
+	}
+
+	return ssoRedirectURL, nil
+}
+
+// SSOOIDCCallback is the redirect URI of the OpenID Connect identity
+// providers of SSO providers.
+func (a *API) SSOOIDCCallback(w http.ResponseWriter, r *http.Request) error {
+	if err := a.handleSSOOIDCCallback(w, r); err != nil {
+		return a.redirectSSOError(w, r, err)
+	}
+	return nil
+}
+
+func (a *API) handleSSOOIDCCallback(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	query := r.URL.Query()
+
+	relayStateUUID := uuid.FromStringOrNil(query.Get("state"))
+	if relayStateUUID == uuid.Nil {
+		return badRequestError(ErrorCodeBadOAuthState, "OAuth state parameter missing or invalid")
+	}
+
+	relayState, err := models.FindSAMLRelayStateByID(db, relayStateUUID)
+	if models.IsNotFoundError(err) {
+		return notFoundError(ErrorCodeBadOAuthState, "OAuth state does not exist, try logging in again?")
+	} else if err != nil {
+		return err
+	}
+
+	// the state can only be used once, whether the sign in succeeds or not
+	if err := a.samlDestroyRelayState(ctx, relayState); err != nil {
+		return err
+	}
+
+	if time.Since(relayState.CreatedAt) >= a.config.SSOOIDC.StateValidityPeriod {
+		return unprocessableEntityError(ErrorCodeBadOAuthState, "OAuth state has expired. Try logging in again?")
+	}
+
+	if errorCode := query.Get("error"); errorCode != "" {
+		return oauthError(errorCode, query.Get("error_description"))
+	}
+
+	ssoProvider, err := models.FindSSOProviderByID(db, relayState.SSOProviderID)
+	if models.IsNotFoundError(err) {
+		return notFoundError(ErrorCodeSSOProviderNotFound, "SSO provider has been removed, try logging in again?")
+	} else if err != nil {
+		return internalServerError("Unable to find SSO provider from OAuth state").WithInternalError(err)
+	}
+
+	if ssoProvider.Type() != "oidc" {
+		return badRequestError(ErrorCodeBadOAuthState, "OAuth state does not belong to an OpenID Connect SSO provider")
+	}
+
+	code := query.Get("code")
+	if code == "" {
+		return badRequestError(ErrorCodeBadOAuthCallback, "OAuth callback with missing authorization code")
+	}
+
+	oidcProvider, oauthConfig, err := a.ssoOIDCConfig(ctx, &ssoProvider.OIDCProvider)
+	if err != nil {
+		return err
+	}
+
+	token, err := oauthConfig.Exchange(ctx, code)
+	if err != nil {
+		return badRequestError(ErrorCodeBadOAuthCallback, "Unable to exchange the authorization code with the OpenID Connect provider").WithInternalError(err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return badRequestError(ErrorCodeBadOAuthCallback, "OpenID Connect provider did not return an ID token")
+	}
+
+	idToken, userData, err := provider.ParseIDToken(ctx, oidcProvider, &oidc.Config{
+		ClientID: ssoProvider.OIDCProvider.ClientID,
+	}, rawIDToken, provider.ParseIDTokenOptions{
+		AccessToken: token.AccessToken,
+	})
+	if err != nil {
+		return badRequestError(ErrorCodeBadOAuthCallback, "ID token from the OpenID Connect provider is not valid").WithInternalError(err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(relayState.RequestID)) != 1 {
+		return badRequestError(ErrorCodeBadOAuthCallback, "ID token from the OpenID Connect provider has an unexpected nonce")
+	}
+
+	if idToken.Subject == "" {
+		return badRequestError(ErrorCodeBadOAuthCallback, "ID token from the OpenID Connect provider does not identify the user")
+	}
+
+	// like with SAML assertions, the identity provider of the
+	// organization is trusted with the email addresses of its users
+	for i := range userData.Emails {
+		userData.Emails[i].Verified = true
+	}
+
+	userData.Metadata.Subject = idToken.Subject
+	userData.Metadata.Issuer = idToken.Issuer
+	userData.Metadata.EmailVerified = true
+
+	// the provisioning rules can reference all claims of the ID token
+	attributes := make(map[string]interface{})
+	if err := idToken.Claims(&attributes); err != nil {
+		return internalServerError("Unable to read the claims of the ID token").WithInternalError(err)
+	}
+
+	var grantParams models.GrantParams
+
+	grantParams.FillGrantParams(r)
+
+	return a.completeSSOSignIn(w, r, ssoProvider, userData, attributes, models.SSOOIDC, grantParams, relayState.FlowState, relayState.RedirectTo, nil)
+}
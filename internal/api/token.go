@@ -32,6 +32,13 @@ type AccessTokenClaims struct {
 	AuthenticationMethodReference []models.AMREntry      `json:"amr,omitempty"`
 	SessionId                     string                 `json:"session_id,omitempty"`
 	IsAnonymous                   bool                   `json:"is_anonymous"`
+	Actor                         *models.ActorClaim     `json:"act,omitempty"`
+	PasswordExpired               bool                   `json:"password_expired,omitempty"`
+	ConsentRequired               bool                   `json:"consent_required,omitempty"`
+
+	Organizations []models.OrganizationMembership `json:"organizations,omitempty"`
+	Roles         []string                        `json:"roles,omitempty"`
+	Permissions   []string                        `json:"permissions,omitempty"`
 }
 
 // AccessTokenResponse represents an OAuth2 success response
@@ -63,6 +70,7 @@ func (r *AccessTokenResponse) AsRedirectURL(redirectURL string, extraParams url.
 type PasswordGrantParams struct {
 	Email    string `json:"email"`
 	Phone    string `json:"phone"`
+	Username string `json:"username"`
 	Password string `json:"password"`
 }
 
@@ -88,6 +96,12 @@ func (a *API) Token(w http.ResponseWriter, r *http.Request) error {
 		return a.IdTokenGrant(ctx, w, r)
 	case "pkce":
 		return a.PKCE(ctx, w, r)
+	case "telegram":
+		return a.TelegramGrant(ctx, w, r)
+	case "web3":
+		return a.Web3Grant(ctx, w, r)
+	case "wechat":
+		return a.WeChatGrant(ctx, w, r)
 	default:
 		return badRequestError(ErrorCodeInvalidCredentials, "unsupported_grant_type")
 	}
@@ -105,8 +119,8 @@ func (a *API) ResourceOwnerPasswordGrant(ctx context.Context, w http.ResponseWri
 	aud := a.requestAud(ctx, r)
 	config := a.config
 
-	if params.Email != "" && params.Phone != "" {
-		return badRequestError(ErrorCodeValidationFailed, "Only an email address or phone number should be provided on login.")
+	if (params.Email != "" && params.Phone != "") || (params.Username != "" && (params.Email != "" || params.Phone != "")) {
+		return badRequestError(ErrorCodeValidationFailed, "Only an email address, phone number or username should be provided on login.")
 	}
 	var user *models.User
 	var grantParams models.GrantParams
@@ -115,12 +129,18 @@ func (a *API) ResourceOwnerPasswordGrant(ctx context.Context, w http.ResponseWri
 
 	grantParams.FillGrantParams(r)
 
+	if params.Email != "" && a.ldapAuthenticator != nil {
+		if handled, err := a.ldapPasswordGrant(ctx, w, r, params, grantParams); handled {
+			return err
+		}
+	}
+
 	if params.Email != "" {
 		provider = "email"
 		if !config.External.Email.Enabled {
 			return unprocessableEntityError(ErrorCodeEmailProviderDisabled, "Email logins are disabled")
 		}
-		user, err = models.FindUserByEmailAndAudience(db, params.Email, aud)
+		user, err = a.findUserByLoginEmail(db, params.Email, aud)
 	} else if params.Phone != "" {
 		provider = "phone"
 		if !config.External.Phone.Enabled {
@@ -128,8 +148,14 @@ func (a *API) ResourceOwnerPasswordGrant(ctx context.Context, w http.ResponseWri
 		}
 		params.Phone = formatPhoneNumber(params.Phone)
 		user, err = models.FindUserByPhoneAndAudience(db, params.Phone, aud)
+	} else if params.Username != "" {
+		provider = "username"
+		if !config.Username.Enabled {
+			return unprocessableEntityError(ErrorCodeUsernameProviderDisabled, "Username logins are disabled")
+		}
+		user, err = models.FindUserByUsernameAndAudience(db, normalizeUsername(params.Username), aud)
 	} else {
-		return badRequestError(ErrorCodeValidationFailed, "missing email or phone")
+		return badRequestError(ErrorCodeValidationFailed, "missing email, phone or username")
 	}
 
 	if err != nil {
@@ -143,10 +169,20 @@ func (a *API) ResourceOwnerPasswordGrant(ctx context.Context, w http.ResponseWri
 		return badRequestError(ErrorCodeInvalidCredentials, InvalidLoginMessage)
 	}
 
+	grantParams.Provider = provider
+
 	if user.IsBanned() {
+		a.recordFailedSignIn(r, user, models.PasswordGrant, grantParams, string(ErrorCodeUserBanned))
 		return badRequestError(ErrorCodeUserBanned, "User is banned")
 	}
 
+	if locked, err := a.isAccountLocked(db, user); err != nil {
+		return err
+	} else if locked {
+		a.recordFailedSignIn(r, user, models.PasswordGrant, grantParams, string(ErrorCodeAccountLocked))
+		return badRequestError(ErrorCodeAccountLocked, accountLockedMessage)
+	}
+
 	isValidPassword, shouldReEncrypt, err := user.Authenticate(ctx, db, params.Password, config.Security.DBEncryption.DecryptionKeys, config.Security.DBEncryption.Encrypt, config.Security.DBEncryption.EncryptionKeyID)
 	if err != nil {
 		return err
@@ -154,7 +190,7 @@ func (a *API) ResourceOwnerPasswordGrant(ctx context.Context, w http.ResponseWri
 
 	var weakPasswordError *WeakPasswordError
 	if isValidPassword {
-		if err := a.checkPasswordStrength(ctx, params.Password); err != nil {
+		if err := a.checkPasswordStrength(ctx, params.Password, userPasswordInputs(user)...); err != nil {
 			if wpe, ok := err.(*WeakPasswordError); ok {
 				weakPasswordError = wpe
 			} else {
@@ -196,10 +232,17 @@ func (a *API) ResourceOwnerPasswordGrant(ctx context.Context, w http.ResponseWri
 					return err
 				}
 			}
+			a.recordFailedSignIn(r, user, models.PasswordGrant, grantParams, string(ErrorCodeInvalidCredentials))
 			return badRequestError(ErrorCodeInvalidCredentials, output.Message)
 		}
 	}
 	if !isValidPassword {
+		a.recordFailedSignIn(r, user, models.PasswordGrant, grantParams, string(ErrorCodeInvalidCredentials))
+		if locked, err := a.recordFailedPasswordAttempt(r, user); err != nil {
+			return internalServerError("Database error recording failed password attempt").WithInternalError(err)
+		} else if locked {
+			return badRequestError(ErrorCodeAccountLocked, accountLockedMessage)
+		}
 		return badRequestError(ErrorCodeInvalidCredentials, InvalidLoginMessage)
 	}
 
@@ -207,6 +250,19 @@ func (a *API) ResourceOwnerPasswordGrant(ctx context.Context, w http.ResponseWri
 		return badRequestError(ErrorCodeEmailNotConfirmed, "Email not confirmed")
 	} else if params.Phone != "" && !user.IsPhoneConfirmed() {
 		return badRequestError(ErrorCodePhoneNotConfirmed, "Phone not confirmed")
+	} else if params.Username != "" && !user.IsConfirmed() && !user.IsPhoneConfirmed() {
+		// users with an email or phone sign in with their username once
+		// they confirmed one of them, like they would with it
+		if user.GetEmail() != "" {
+			return badRequestError(ErrorCodeEmailNotConfirmed, "Email not confirmed")
+		} else if user.GetPhone() != "" {
+			return badRequestError(ErrorCodePhoneNotConfirmed, "Phone not confirmed")
+		}
+	}
+
+	if user.IsPasswordExpired() {
+		a.recordFailedSignIn(r, user, models.PasswordGrant, grantParams, string(ErrorCodePasswordExpired))
+		return badRequestError(ErrorCodePasswordExpired, "Password has expired and needs to be reset before signing in")
 	}
 
 	var token *AccessTokenResponse
@@ -217,6 +273,11 @@ func (a *API) ResourceOwnerPasswordGrant(ctx context.Context, w http.ResponseWri
 		}); terr != nil {
 			return terr
 		}
+		if config.AccountLockout.Enabled {
+			if terr = models.ClearAccountLockoutForUser(tx, user.ID); terr != nil {
+				return terr
+			}
+		}
 		token, terr = a.issueRefreshToken(r, tx, user, models.PasswordGrant, grantParams)
 		if terr != nil {
 			return terr
@@ -272,6 +333,8 @@ func (a *API) PKCE(ctx context.Context, w http.ResponseWriter, r *http.Request)
 		return badRequestError(ErrorBadCodeVerifier, err.Error())
 	}
 
+	grantParams.Provider = flowState.ProviderType
+
 	var token *AccessTokenResponse
 	err = db.Transaction(func(tx *storage.Connection) error {
 		var terr error
@@ -325,6 +388,11 @@ func (a *API) generateAccessToken(r *http.Request, tx *storage.Connection, user
 	issuedAt := time.Now().UTC()
 	expiresAt := issuedAt.Add(time.Second * time.Duration(config.JWT.Exp))
 
+	// the access tokens of impersonation sessions don't outlive them
+	if session.ImpersonatedBy != nil && session.NotAfter != nil && session.NotAfter.Before(expiresAt) {
+		expiresAt = session.NotAfter.UTC()
+	}
+
 	claims := &hooks.AccessTokenClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			Subject:   user.ID.String(),
@@ -342,6 +410,36 @@ func (a *API) generateAccessToken(r *http.Request, tx *storage.Connection, user
 		AuthenticatorAssuranceLevel:   aal.String(),
 		AuthenticationMethodReference: amr,
 		IsAnonymous:                   user.IsAnonymous,
+		PasswordExpired:               user.IsPasswordExpired(),
+		ConsentRequired:               a.isConsentRequired(user),
+	}
+
+	if session.ImpersonatedBy != nil {
+		claims.Actor = &models.ActorClaim{Subject: *session.ImpersonatedBy}
+	}
+
+	if config.Organizations.Enabled {
+		memberships, terr := models.FindOrganizationMembershipsForUser(tx, user.ID)
+		if terr != nil {
+			return "", 0, terr
+		}
+
+		claims.Organizations = memberships
+	}
+
+	if config.Roles.Enabled {
+		roles, terr := models.FindRolesForUser(tx, user.ID)
+		if terr != nil {
+			return "", 0, terr
+		}
+
+		names, permissions := models.RoleClaims(roles)
+		if len(names) > 0 {
+			claims.Roles = names
+		}
+		if config.Roles.IncludePermissions && len(permissions) > 0 {
+			claims.Permissions = permissions
+		}
 	}
 
 	var gotrueClaims jwt.Claims = claims
@@ -379,19 +477,33 @@ func (a *API) issueRefreshToken(r *http.Request, conn *storage.Connection, user
 	var expiresAt int64
 	var refreshToken *models.RefreshToken
 
+	grantParams.Location = a.locateIP(r, grantParams.IP)
+
 	err := conn.Transaction(func(tx *storage.Connection) error {
 		var terr error
 
+		if terr = a.enforceSessionLimit(r, tx, user); terr != nil {
+			return terr
+		}
+
 		refreshToken, terr = models.GrantAuthenticatedUser(tx, user, grantParams)
 		if terr != nil {
 			return internalServerError("Database error granting user").WithInternalError(terr)
 		}
 
+		if terr = models.NewAuditLogEntry(r, tx, user, models.SessionCreatedAction, grantParams.IP, grantParams.ClientTraits(*refreshToken.SessionId)); terr != nil {
+			return terr
+		}
+
 		terr = models.AddClaimToSession(tx, *refreshToken.SessionId, authenticationMethod)
 		if terr != nil {
 			return terr
 		}
 
+		if terr = a.recordSignIn(tx, user, authenticationMethod, grantParams); terr != nil {
+			return terr
+		}
+
 		tokenString, expiresAt, terr = a.generateAccessToken(r, tx, user, refreshToken.SessionId, authenticationMethod)
 		if terr != nil {
 			// Account for Hook Error
@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -22,6 +23,11 @@ type IdTokenGrantParams struct {
 	Provider    string `json:"provider"`
 	ClientID    string `json:"client_id"`
 	Issuer      string `json:"issuer"`
+
+	// SelectBy is the select_by value of the credential response of Google
+	// Identity Services, when the ID token is a credential of One Tap,
+	// FedCM or the Sign in with Google button.
+	SelectBy string `json:"select_by"`
 }
 
 func (p *IdTokenGrantParams) getProvider(ctx context.Context, config *conf.GlobalConfiguration, r *http.Request) (*oidc.Provider, bool, string, []string, error) {
@@ -43,8 +49,8 @@ func (p *IdTokenGrantParams) getProvider(ctx context.Context, config *conf.Globa
 			acceptableClientIDs = append(acceptableClientIDs, config.External.IosBundleId)
 		}
 
-	case p.Provider == "google" || p.Issuer == provider.IssuerGoogle:
-		cfg = &config.External.Google
+	case p.Provider == "google" || provider.IsGoogleIssuer(p.Issuer):
+		cfg = &config.External.Google.OAuthProviderConfiguration
 		providerType = "google"
 		issuer = provider.IssuerGoogle
 		acceptableClientIDs = append(acceptableClientIDs, config.External.Google.ClientID...)
@@ -58,7 +64,7 @@ func (p *IdTokenGrantParams) getProvider(ctx context.Context, config *conf.Globa
 			}
 			issuer = detectedIssuer
 		}
-		cfg = &config.External.Azure
+		cfg = &config.External.Azure.OAuthProviderConfiguration
 		providerType = "azure"
 		acceptableClientIDs = append(acceptableClientIDs, config.External.Azure.ClientID...)
 
@@ -80,6 +86,12 @@ func (p *IdTokenGrantParams) getProvider(ctx context.Context, config *conf.Globa
 		issuer = provider.IssuerKakao
 		acceptableClientIDs = append(acceptableClientIDs, config.External.Kakao.ClientID...)
 
+	case p.Provider == "line" || p.Issuer == provider.IssuerLine:
+		cfg = &config.External.Line
+		providerType = "line"
+		issuer = provider.IssuerLine
+		acceptableClientIDs = append(acceptableClientIDs, config.External.Line.ClientID...)
+
 	case p.Provider == "vercel_marketplace" || p.Issuer == provider.IssuerVercelMarketplace:
 		cfg = &config.External.VercelMarketplace
 		providerType = "vercel_marketplace"
@@ -155,6 +167,26 @@ func (a *API) IdTokenGrant(ctx context.Context, w http.ResponseWriter, r *http.R
 		return oauthError("invalid request", "Bad ID token").WithInternalError(err)
 	}
 
+	if providerType == "azure" {
+		if err := provider.CheckAzureTenant(config.External.Azure, idToken); err != nil {
+			if errors.Is(err, provider.ErrAccessDenied) {
+				return forbiddenError(ErrorCodeProviderAccessDenied, "User is not allowed to sign in with this provider").WithInternalError(err)
+			}
+			return oauthError("invalid request", "Bad ID token").WithInternalError(err)
+		}
+
+		userData.AppMetadata, err = provider.AzureAppMetadata(ctx, config.External.Azure, idToken, params.AccessToken)
+		if err != nil {
+			return oauthError("server_error", "Unable to fetch Azure groups").WithInternalError(err)
+		}
+	}
+
+	if providerType == "google" {
+		if err := provider.CheckGoogleSelectBy(config.External.Google, params.SelectBy); err != nil {
+			return oauthError("invalid request", "Bad Google credential").WithInternalError(err)
+		}
+	}
+
 	userData.Metadata.EmailVerified = false
 	for _, email := range userData.Emails {
 		if email.Primary {
@@ -218,10 +250,15 @@ func (a *API) IdTokenGrant(ctx context.Context, w http.ResponseWriter, r *http.R
 		}
 	}
 
+	if err := a.applyClaimMapping(providerType, userData); err != nil {
+		return internalServerError("Error mapping claims of ID token").WithInternalError(err)
+	}
+
 	var token *AccessTokenResponse
 	var grantParams models.GrantParams
 
 	grantParams.FillGrantParams(r)
+	grantParams.Provider = providerType
 
 	if err := db.Transaction(func(tx *storage.Connection) error {
 		var user *models.User
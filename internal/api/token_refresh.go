@@ -56,8 +56,22 @@ func (a *API) RefreshTokenGrant(ctx context.Context, w http.ResponseWriter, r *h
 			return oauthError("invalid_grant", "Invalid Refresh Token: User Banned")
 		}
 
+		// sessions can't be refreshed until the current terms are
+		// accepted, which their access tokens still allow until they
+		// expire
+		if a.isConsentRequired(user) {
+			return forbiddenError(ErrorCodeConsentRequired, "The current terms of service and privacy policy have to be accepted")
+		}
+
+		// the lifetime of sessions can depend on the roles and tags of
+		// their user, and is evaluated on each refresh
+		timebox, inactivityTimeout, err := a.sessionLifetime(db, user)
+		if err != nil {
+			return internalServerError("Database error finding session lifetime").WithInternalError(err)
+		}
+
 		if session != nil {
-			result := session.CheckValidity(retryStart, &token.UpdatedAt, config.Sessions.Timebox, config.Sessions.InactivityTimeout)
+			result := session.CheckValidity(retryStart, &token.UpdatedAt, timebox, inactivityTimeout)
 
 			switch result {
 			case models.SessionValid:
@@ -127,7 +141,7 @@ func (a *API) RefreshTokenGrant(ctx context.Context, w http.ResponseWriter, r *h
 						continue
 					}
 
-					if s.CheckValidity(retryStart, nil, config.Sessions.Timebox, config.Sessions.InactivityTimeout) != models.SessionValid {
+					if s.CheckValidity(retryStart, nil, timebox, inactivityTimeout) != models.SessionValid {
 						// session is not valid so it
 						// can't be regarded as active
 						// on the user
@@ -195,10 +209,6 @@ func (a *API) RefreshTokenGrant(ctx context.Context, w http.ResponseWriter, r *h
 				}
 			}
 
-			if terr = models.NewAuditLogEntry(r, tx, user, models.TokenRefreshedAction, "", nil); terr != nil {
-				return terr
-			}
-
 			if issuedToken == nil {
 				newToken, terr := models.GrantRefreshTokenSwap(r, tx, user, token)
 				if terr != nil {
@@ -234,10 +244,22 @@ func (a *API) RefreshTokenGrant(ctx context.Context, w http.ResponseWriter, r *h
 				session.IP = nil
 			}
 
+			// clients that don't send the device identifier on every
+			// request keep the one of the session
+			if deviceID := utilities.GetDeviceID(r); deviceID != "" {
+				session.DeviceID = &deviceID
+			}
+
+			session.SetLocation(a.locateIP(r, ipAddress))
+
 			if terr := session.UpdateOnlyRefreshInfo(tx); terr != nil {
 				return internalServerError("failed to update session information").WithInternalError(terr)
 			}
 
+			if terr = models.NewAuditLogEntry(r, tx, user, models.TokenRefreshedAction, ipAddress, session.ClientTraits()); terr != nil {
+				return terr
+			}
+
 			newTokenResponse = &AccessTokenResponse{
 				Token:        tokenString,
 				TokenType:    "bearer",
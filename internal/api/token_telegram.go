@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/supabase/auth/internal/api/provider"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// TelegramGrantParams are the parameters the TelegramGrant method accepts,
+// which is the user object the Telegram Login Widget passes to its callback
+type TelegramGrantParams map[string]interface{}
+
+// TelegramGrant implements the telegram grant type flow
+func (a *API) TelegramGrant(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	db := a.db.WithContext(ctx)
+	config := a.config
+
+	if !config.External.Telegram.Enabled {
+		return badRequestError(ErrorCodeProviderDisabled, "Telegram logins are disabled")
+	}
+
+	params := TelegramGrantParams{}
+	if err := retrieveRequestParams(r, &params); err != nil {
+		return err
+	}
+
+	userData, err := provider.ParseTelegramLogin(config.External.Telegram, params, time.Now())
+	if err != nil {
+		return oauthError("invalid request", "Bad Telegram login data").WithInternalError(err)
+	}
+
+	var token *AccessTokenResponse
+	var grantParams models.GrantParams
+
+	grantParams.FillGrantParams(r)
+	grantParams.Provider = "telegram"
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		user, terr := a.createAccountFromExternalIdentity(tx, r, userData, "telegram")
+		if terr != nil {
+			return terr
+		}
+
+		token, terr = a.issueRefreshToken(r, tx, user, models.OAuth, grantParams)
+		return terr
+	}); err != nil {
+		switch err.(type) {
+		case *storage.CommitWithError:
+			return err
+		case *HTTPError, *OAuthError:
+			return err
+		default:
+			return oauthError("server_error", "Internal Server Error").WithInternalError(err)
+		}
+	}
+
+	return sendJSON(w, http.StatusOK, token)
+}
@@ -10,6 +10,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -19,6 +20,7 @@ import (
 	"github.com/stretchr/testify/suite"
 	"github.com/supabase/auth/internal/conf"
 	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/utilities"
 )
 
 type TokenTestSuite struct {
@@ -98,6 +100,64 @@ func (ts *TokenTestSuite) TestSessionTimebox() {
 	assert.Equal(ts.T(), "Invalid Refresh Token: Session Expired", firstResult.ErrorDescription)
 }
 
+func (ts *TokenTestSuite) TestSessionLifetimePolicies() {
+	timebox := 10 * time.Second
+
+	ts.API.config.Sessions.Timebox = &timebox
+	ts.API.config.Sessions.TimeboxPerTag = map[string]time.Duration{"kiosk": 30 * 24 * time.Hour}
+	ts.API.config.Sessions.TimeboxPerRole = map[string]time.Duration{"admin": 5 * time.Second}
+	ts.API.overrideTime = func() time.Time {
+		return time.Now().Add(timebox).Add(time.Second)
+	}
+
+	defer func() {
+		ts.API.overrideTime = nil
+		ts.API.config.Sessions.Timebox = nil
+		ts.API.config.Sessions.TimeboxPerTag = nil
+		ts.API.config.Sessions.TimeboxPerRole = nil
+	}()
+
+	refresh := func(refreshToken string) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+			"refresh_token": refreshToken,
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/token?grant_type=refresh_token", &buffer)
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// the sessions of kiosk accounts outlive the global timebox
+	_, err := models.SetUserTag(ts.API.db, ts.User.ID, "kiosk", "", nil)
+	require.NoError(ts.T(), err)
+
+	w := refresh(ts.RefreshToken.Token)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var token AccessTokenResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&token))
+
+	// users with several policies get the shortest timebox
+	role, err := models.NewRole("admin", "", nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(role))
+	require.NoError(ts.T(), models.AssignUserRole(ts.API.db, ts.User.ID, role.ID))
+
+	w = refresh(token.RefreshToken)
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+
+	var result struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&result))
+	require.Equal(ts.T(), "Invalid Refresh Token: Session Expired", result.ErrorDescription)
+}
+
 func (ts *TokenTestSuite) TestSessionInactivityTimeout() {
 	inactivityTimeout := 10 * time.Second
 
@@ -299,6 +359,398 @@ func (ts *TokenTestSuite) TestTokenPasswordGrantFailure() {
 	assert.Equal(ts.T(), http.StatusBadRequest, w.Code)
 }
 
+func (ts *TokenTestSuite) TestTokenPasswordGrantExpiredPassword() {
+	require.NoError(ts.T(), ts.User.SetPasswordExpired(ts.API.db, true))
+
+	signIn := func() *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+			"email":    "test@example.com",
+			"password": "password",
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/token?grant_type=password", &buffer)
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := signIn()
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+
+	var errorResponse HTTPError
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&errorResponse))
+	require.Equal(ts.T(), ErrorCodePasswordExpired, errorResponse.ErrorCode)
+
+	// the sessions issued otherwise tell the password has to be reset
+	var buffer bytes.Buffer
+	require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+		"refresh_token": ts.RefreshToken.Token,
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/token?grant_type=refresh_token", &buffer)
+	req.Header.Set("Content-Type", "application/json")
+
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var token AccessTokenResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&token))
+
+	ctx, err := ts.API.parseJWTClaims(token.Token, req)
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), getClaims(ctx).PasswordExpired)
+
+	// resetting the password clears the expiry
+	require.NoError(ts.T(), ts.User.SetPassword(ctx, "password", false, "", ""))
+	require.NoError(ts.T(), ts.User.UpdatePassword(ts.API.db, nil))
+
+	w = signIn()
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+}
+
+func (ts *TokenTestSuite) TestSignInActivity() {
+	ts.Config.SignInHistory.Enabled = true
+	defer func() {
+		ts.Config.SignInHistory.Enabled = false
+	}()
+
+	signIn := func(password string) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+			"email":    "test@example.com",
+			"password": password,
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/token?grant_type=password", &buffer)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "test-agent")
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := signIn("wrong-password")
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+
+	w = signIn("password")
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var token AccessTokenResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&token))
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/user/sign-in-activity", nil)
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var data struct {
+		Events []*models.SignInEvent `json:"events"`
+	}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	require.Len(ts.T(), data.Events, 2)
+
+	require.True(ts.T(), data.Events[0].Success)
+	require.Equal(ts.T(), "password", data.Events[0].Method)
+	require.Equal(ts.T(), "email", *data.Events[0].Provider)
+	require.Equal(ts.T(), "test-agent", *data.Events[0].UserAgent)
+
+	require.False(ts.T(), data.Events[1].Success)
+	require.Equal(ts.T(), string(ErrorCodeInvalidCredentials), *data.Events[1].FailureReason)
+
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/user/sign-in-activity?limit=1000", nil)
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+}
+
+func (ts *TokenTestSuite) TestConsentRequired() {
+	ts.Config.Consent.Enabled = true
+	ts.Config.Consent.TermsVersion = "2024-01"
+	ts.Config.Consent.RequireAcceptance = true
+	defer func() {
+		ts.Config.Consent.Enabled = false
+		ts.Config.Consent.TermsVersion = ""
+		ts.Config.Consent.RequireAcceptance = false
+	}()
+
+	request := func(path, token string, body map[string]interface{}) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(body))
+
+		req := httptest.NewRequest(http.MethodPost, "http://localhost"+path, &buffer)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// the sessions of users who didn't accept the current terms aren't
+	// refreshed
+	w := request("/token?grant_type=refresh_token", "", map[string]interface{}{
+		"refresh_token": ts.RefreshToken.Token,
+	})
+	require.Equal(ts.T(), http.StatusForbidden, w.Code)
+
+	var data HTTPError
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	require.Equal(ts.T(), ErrorCodeConsentRequired, data.ErrorCode)
+
+	// signing in still works, and tells the terms have to be accepted
+	w = request("/token?grant_type=password", "", map[string]interface{}{
+		"email":    "test@example.com",
+		"password": "password",
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var token AccessTokenResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&token))
+
+	ctx, err := ts.API.parseJWTClaims(token.Token, httptest.NewRequest(http.MethodGet, "http://localhost/user", nil))
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), getClaims(ctx).ConsentRequired)
+
+	w = request("/user/consent", token.Token, map[string]interface{}{"terms_version": "2023-01"})
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+
+	w = request("/user/consent", token.Token, map[string]interface{}{"terms_version": "2024-01"})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	user, err := models.FindUserByID(ts.API.db, ts.User.ID)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), "2024-01", user.TermsVersion.String())
+	require.NotNil(ts.T(), user.TermsAcceptedAt)
+
+	w = request("/token?grant_type=refresh_token", "", map[string]interface{}{
+		"refresh_token": token.RefreshToken,
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&token))
+	ctx, err = ts.API.parseJWTClaims(token.Token, httptest.NewRequest(http.MethodGet, "http://localhost/user", nil))
+	require.NoError(ts.T(), err)
+	require.False(ts.T(), getClaims(ctx).ConsentRequired)
+}
+
+func (ts *TokenTestSuite) TestSessionClientInfo() {
+	// requests of httptest come from 192.0.2.1
+	database := filepath.Join(ts.T().TempDir(), "geoip.csv")
+	require.NoError(ts.T(), os.WriteFile(database, []byte("192.0.2.0,192.0.2.255,AU,Queensland,Brisbane\n"), 0600))
+	ts.API.geoIP = &utilities.GeoIPCSVDatabase{Path: database}
+	defer func() {
+		ts.API.geoIP = nil
+	}()
+
+	request := func(path, deviceID string, body map[string]interface{}) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(body))
+
+		req := httptest.NewRequest(http.MethodPost, "http://localhost"+path, &buffer)
+		req.Header.Set("Content-Type", "application/json")
+		if deviceID != "" {
+			req.Header.Set(utilities.DeviceIDHeader, deviceID)
+		}
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("/token?grant_type=password", "device-1", map[string]interface{}{
+		"email":    "test@example.com",
+		"password": "password",
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var token AccessTokenResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&token))
+
+	_, _, session, err := models.FindUserWithRefreshToken(ts.API.db, token.RefreshToken, false)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), "device-1", *session.DeviceID)
+	require.Equal(ts.T(), "AU", *session.Country)
+	require.Equal(ts.T(), "Brisbane", *session.City)
+
+	entries, err := models.FindAuditLogEntries(ts.API.db, nil, "", models.AuditLogFilter{Actions: []string{string(models.SessionCreatedAction)}}, nil)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), entries, 1)
+	require.Equal(ts.T(), "192.0.2.1", entries[0].IPAddress)
+	traits := entries[0].Payload["traits"].(map[string]interface{})
+	require.Equal(ts.T(), session.ID.String(), traits["session_id"])
+	require.Equal(ts.T(), "device-1", traits["device_id"])
+
+	// refreshing without the device identifier keeps the one of the
+	// session
+	w = request("/token?grant_type=refresh_token", "", map[string]interface{}{
+		"refresh_token": token.RefreshToken,
+	})
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	session, err = models.FindSessionByID(ts.API.db, session.ID, false)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), "device-1", *session.DeviceID)
+	require.Equal(ts.T(), "Queensland", *session.Region)
+
+	entries, err = models.FindAuditLogEntries(ts.API.db, nil, "", models.AuditLogFilter{Actions: []string{string(models.TokenRefreshedAction)}}, nil)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), entries, 1)
+	traits = entries[0].Payload["traits"].(map[string]interface{})
+	require.Equal(ts.T(), "device-1", traits["device_id"])
+	require.Equal(ts.T(), "AU", traits["location"].(map[string]interface{})["country"])
+}
+
+func (ts *TokenTestSuite) TestSessionLimit() {
+	ts.Config.Sessions.MaxPerUser = 2
+	ts.Config.Sessions.LimitBehavior = conf.SessionLimitEvictOldest
+	defer func() {
+		ts.Config.Sessions.MaxPerUser = 0
+		ts.Config.Sessions.MaxPerRole = nil
+		ts.Config.Sessions.LimitBehavior = ""
+	}()
+
+	signIn := func() *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+			"email":    "test@example.com",
+			"password": "password",
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/token?grant_type=password", &buffer)
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	countSessions := func() int {
+		sessions, err := models.FindAllSessionsForUser(ts.API.db, ts.User.ID, false)
+		require.NoError(ts.T(), err)
+		return len(sessions)
+	}
+
+	// the session of the setup is the oldest one, and is revoked to make
+	// room for the third one
+	require.Equal(ts.T(), http.StatusOK, signIn().Code)
+	require.Equal(ts.T(), http.StatusOK, signIn().Code)
+	require.Equal(ts.T(), 2, countSessions())
+
+	_, err := models.FindSessionByID(ts.API.db, *ts.RefreshToken.SessionId, false)
+	require.True(ts.T(), models.IsNotFoundError(err))
+
+	entries, err := models.FindAuditLogEntries(ts.API.db, nil, "", models.AuditLogFilter{Actions: []string{string(models.SessionRevokedAction)}}, nil)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), entries, 1)
+	require.Equal(ts.T(), "session_limit", entries[0].Payload["traits"].(map[string]interface{})["reason"])
+
+	ts.Config.Sessions.LimitBehavior = conf.SessionLimitReject
+	w := signIn()
+	require.Equal(ts.T(), http.StatusForbidden, w.Code)
+
+	var data HTTPError
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	require.Equal(ts.T(), ErrorCodeSessionLimitReached, data.ErrorCode)
+	require.Equal(ts.T(), 2, countSessions())
+
+	// roles raise the cap of their users
+	role, err := models.NewRole("team", "", nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(role))
+	require.NoError(ts.T(), models.AssignUserRole(ts.API.db, ts.User.ID, role.ID))
+	ts.Config.Sessions.MaxPerRole = map[string]int{"team": 3}
+
+	require.Equal(ts.T(), http.StatusOK, signIn().Code)
+	require.Equal(ts.T(), http.StatusForbidden, signIn().Code)
+	require.Equal(ts.T(), 3, countSessions())
+}
+
+func (ts *TokenTestSuite) TestAccountLockout() {
+	ts.Config.AccountLockout.Enabled = true
+	ts.Config.AccountLockout.MaxAttempts = 2
+	ts.Config.AccountLockout.Window = 15 * time.Minute
+	ts.Config.AccountLockout.Duration = 30 * time.Minute
+	defer func() {
+		ts.Config.AccountLockout.Enabled = false
+	}()
+
+	signIn := func(password string) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+			"email":    "test@example.com",
+			"password": password,
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/token?grant_type=password", &buffer)
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	errorCode := func(w *httptest.ResponseRecorder) ErrorCode {
+		var data map[string]interface{}
+		require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+		return ErrorCode(data["error_code"].(string))
+	}
+
+	// a successful sign-in forgets the failed attempts before it
+	w := signIn("wrong-password")
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+	require.Equal(ts.T(), ErrorCodeInvalidCredentials, errorCode(w))
+
+	w = signIn("password")
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	_, err := models.FindAccountLockoutByUserID(ts.API.db, ts.User.ID)
+	require.True(ts.T(), models.IsNotFoundError(err))
+
+	w = signIn("wrong-password")
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+	require.Equal(ts.T(), ErrorCodeInvalidCredentials, errorCode(w))
+
+	w = signIn("wrong-password")
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+	require.Equal(ts.T(), ErrorCodeAccountLocked, errorCode(w))
+
+	// the right password doesn't sign in to a locked account
+	w = signIn("password")
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+	require.Equal(ts.T(), ErrorCodeAccountLocked, errorCode(w))
+
+	lockout, err := models.FindAccountLockoutByUserID(ts.API.db, ts.User.ID)
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), lockout.IsLocked(time.Now()))
+	require.NotNil(ts.T(), lockout.TokenHash)
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/account_lockout/unlock?token_hash=invalid", nil)
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusSeeOther, w.Code)
+	require.Contains(ts.T(), w.Header().Get("Location"), "error_code=404")
+
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/account_lockout/unlock?token_hash="+*lockout.TokenHash, nil)
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusSeeOther, w.Code)
+	require.NotContains(ts.T(), w.Header().Get("Location"), "error")
+
+	w = signIn("password")
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+}
+
 func (ts *TokenTestSuite) TestTokenPKCEGrantFailure() {
 	authCode := "1234563"
 	codeVerifier := "4a9505b9-0857-42bb-ab3c-098b4d28ddc2"
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/supabase/auth/internal/api/provider"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// WeChatGrantParams are the parameters the WeChatGrant method accepts
+type WeChatGrantParams struct {
+	// Code is the code the WeChat SDK returned to the mobile application.
+	Code string `json:"code"`
+}
+
+// WeChatGrant implements the wechat grant type flow, which signs in users
+// of mobile applications with the code the WeChat SDK returns
+func (a *API) WeChatGrant(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	db := a.db.WithContext(ctx)
+	config := a.config
+
+	if !config.External.WeChat.Enabled || config.External.WeChat.MobileAppID == "" {
+		return badRequestError(ErrorCodeProviderDisabled, "WeChat mobile logins are disabled")
+	}
+
+	params := &WeChatGrantParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if params.Code == "" {
+		return oauthError("invalid request", "code required")
+	}
+
+	p, err := provider.NewWeChatProvider(config.External.WeChat)
+	if err != nil {
+		return internalServerError("Unable to create WeChat provider").WithInternalError(err)
+	}
+	weChatProvider := p.(*provider.WeChatProvider)
+
+	oauthToken, err := weChatProvider.ExchangeMobileCode(ctx, params.Code)
+	if err != nil {
+		return oauthError("invalid_grant", "Unable to exchange WeChat code").WithInternalError(err)
+	}
+
+	userData, err := weChatProvider.GetUserData(ctx, oauthToken)
+	if err != nil {
+		return internalServerError("Error getting user profile from WeChat").WithInternalError(err)
+	}
+
+	if err := a.applyClaimMapping("wechat", userData); err != nil {
+		return internalServerError("Error mapping claims of WeChat user").WithInternalError(err)
+	}
+
+	var token *AccessTokenResponse
+	var grantParams models.GrantParams
+
+	grantParams.FillGrantParams(r)
+	grantParams.Provider = "wechat"
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		user, terr := a.createAccountFromExternalIdentity(tx, r, userData, "wechat")
+		if terr != nil {
+			return terr
+		}
+
+		if config.External.ProviderTokens.Enabled {
+			if terr := a.saveProviderToken(tx, user, "wechat", &OAuthProviderData{
+				userData:     userData,
+				token:        oauthToken.AccessToken,
+				refreshToken: oauthToken.RefreshToken,
+				expiry:       oauthToken.Expiry,
+			}); terr != nil {
+				return terr
+			}
+		}
+
+		token, terr = a.issueRefreshToken(r, tx, user, models.OAuth, grantParams)
+		return terr
+	}); err != nil {
+		switch err.(type) {
+		case *storage.CommitWithError:
+			return err
+		case *HTTPError, *OAuthError:
+			return err
+		default:
+			return oauthError("server_error", "Internal Server Error").WithInternalError(err)
+		}
+	}
+
+	return sendJSON(w, http.StatusOK, token)
+}
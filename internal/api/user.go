@@ -20,6 +20,7 @@ type UserUpdateParams struct {
 	Data                map[string]interface{} `json:"data"`
 	AppData             map[string]interface{} `json:"app_metadata,omitempty"`
 	Phone               string                 `json:"phone"`
+	Username            string                 `json:"username"`
 	Channel             string                 `json:"channel"`
 	CodeChallenge       string                 `json:"code_challenge"`
 	CodeChallengeMethod string                 `json:"code_challenge_method"`
@@ -48,8 +49,18 @@ func (a *API) validateUserUpdateParams(ctx context.Context, p *UserUpdateParams)
 		}
 	}
 
+	if p.Username != "" {
+		if !config.Username.Enabled {
+			return badRequestError(ErrorCodeUsernameProviderDisabled, "Usernames are disabled")
+		}
+		if p.Username, err = a.validateUsername(p.Username); err != nil {
+			return err
+		}
+	}
+
 	if p.Password != nil {
-		if err := a.checkPasswordStrength(ctx, *p.Password); err != nil {
+		userInputs := append(passwordUserInputs(p.Email, p.Phone, p.Username, p.Data), userPasswordInputs(getUser(ctx))...)
+		if err := a.checkPasswordStrength(ctx, *p.Password, userInputs...); err != nil {
 			return err
 		}
 	}
@@ -100,6 +111,10 @@ func (a *API) UserUpdate(w http.ResponseWriter, r *http.Request) error {
 		}
 	}
 
+	if err := a.validateMetadataUpdates(user, params.Data, params.AppData); err != nil {
+		return err
+	}
+
 	if user.IsAnonymous {
 		if params.Password != nil && *params.Password != "" {
 			if params.Email == "" && params.Phone == "" {
@@ -121,11 +136,22 @@ func (a *API) UserUpdate(w http.ResponseWriter, r *http.Request) error {
 		}
 	}
 
+	// anonymous users claiming the email or phone of an existing account
+	// are merged into it once they confirm it, which is never automatic
+	mergeAnonymous := user.IsAnonymous && config.External.AnonymousUsers.MergeEnabled
+	claimsEmail, claimsPhone := false, false
+
 	if params.Email != "" && user.GetEmail() != params.Email {
-		if duplicateUser, err := models.IsDuplicatedEmail(db, params.Email, aud, user); err != nil {
+		if err := a.validateEmailDomain(params.Email); err != nil {
+			return err
+		}
+		if duplicateUser, err := a.findDuplicateEmail(db, params.Email, aud, user); err != nil {
 			return internalServerError("Database error checking email").WithInternalError(err)
 		} else if duplicateUser != nil {
-			return unprocessableEntityError(ErrorCodeEmailExists, DuplicateEmailMsg)
+			if !mergeAnonymous {
+				return unprocessableEntityError(ErrorCodeEmailExists, DuplicateEmailMsg)
+			}
+			claimsEmail = true
 		}
 	}
 
@@ -133,10 +159,20 @@ func (a *API) UserUpdate(w http.ResponseWriter, r *http.Request) error {
 		if exists, err := models.IsDuplicatedPhone(db, params.Phone, aud); err != nil {
 			return internalServerError("Database error checking phone").WithInternalError(err)
 		} else if exists {
-			return unprocessableEntityError(ErrorCodePhoneExists, DuplicatePhoneMsg)
+			if !mergeAnonymous {
+				return unprocessableEntityError(ErrorCodePhoneExists, DuplicatePhoneMsg)
+			}
+			claimsPhone = true
 		}
 	}
 
+	if params.Username != "" && user.GetUsername() != params.Username {
+		if err := checkUsernameAvailable(db, params.Username, user); err != nil {
+			return err
+		}
+	}
+
+	var previousPassword *string
 	if params.Password != nil {
 		if config.Security.UpdatePasswordRequireReauthentication {
 			now := time.Now()
@@ -167,8 +203,17 @@ func (a *API) UserUpdate(w http.ResponseWriter, r *http.Request) error {
 			if isSamePassword {
 				return unprocessableEntityError(ErrorCodeSamePassword, "New password should be different from the old password.")
 			}
+
+			if err := a.checkPasswordMinAge(db, user); err != nil {
+				return err
+			}
+
+			if err := a.checkPasswordHistory(ctx, db, user, password); err != nil {
+				return err
+			}
 		}
 
+		previousPassword = user.EncryptedPassword
 		if err := user.SetPassword(ctx, password, config.Security.DBEncryption.Encrypt, config.Security.DBEncryption.EncryptionKeyID, config.Security.DBEncryption.EncryptionKey); err != nil {
 			return err
 		}
@@ -186,6 +231,10 @@ func (a *API) UserUpdate(w http.ResponseWriter, r *http.Request) error {
 				return internalServerError("Error during password storage").WithInternalError(terr)
 			}
 
+			if terr = a.recordPasswordHistory(tx, user, previousPassword); terr != nil {
+				return internalServerError("Error during password storage").WithInternalError(terr)
+			}
+
 			if terr := models.NewAuditLogEntry(r, tx, user, models.UserUpdatePasswordAction, "", nil); terr != nil {
 				return terr
 			}
@@ -203,8 +252,14 @@ func (a *API) UserUpdate(w http.ResponseWriter, r *http.Request) error {
 			}
 		}
 
+		if params.Username != "" && params.Username != user.GetUsername() {
+			if terr := a.updateUsername(tx, user, params.Username); terr != nil {
+				return terr
+			}
+		}
+
 		if params.Email != "" && params.Email != user.GetEmail() {
-			if user.IsAnonymous && config.Mailer.Autoconfirm {
+			if user.IsAnonymous && config.Mailer.Autoconfirm && !claimsEmail {
 				// anonymous users can add an email with automatic confirmation, which is similar to signing up
 				// permanent users always need to verify their email address when changing it
 				user.EmailChange = params.Email
@@ -231,7 +286,7 @@ func (a *API) UserUpdate(w http.ResponseWriter, r *http.Request) error {
 		}
 
 		if params.Phone != "" && params.Phone != user.GetPhone() {
-			if config.Sms.Autoconfirm {
+			if config.Sms.Autoconfirm && !claimsPhone {
 				user.PhoneChange = params.Phone
 				if _, terr := a.smsVerify(r, tx, user, &VerifyParams{
 					Type:  phoneChangeVerification,
@@ -243,6 +298,11 @@ func (a *API) UserUpdate(w http.ResponseWriter, r *http.Request) error {
 				if _, terr := a.sendPhoneConfirmation(r, tx, user, params.Phone, phoneChangeVerification, params.Channel); terr != nil {
 					return terr
 				}
+				if config.Sms.PhoneChange.IsConfirmedByBoth() && user.GetPhone() != "" && user.IsPhoneConfirmed() {
+					if _, terr := a.sendPhoneConfirmation(r, tx, user, user.GetPhone(), phoneChangeCurrentOtp, params.Channel); terr != nil {
+						return terr
+					}
+				}
 			}
 		}
 
@@ -422,6 +422,53 @@ func (ts *UserTestSuite) TestUserUpdatePasswordNoReauthenticationRequired() {
 	}
 }
 
+func (ts *UserTestSuite) TestUserUpdatePasswordHistory() {
+	ts.Config.Security.UpdatePasswordRequireReauthentication = false
+	ts.Config.Password.History.Depth = 2
+	defer func() {
+		ts.Config.Password.History.Depth = 0
+		ts.Config.Password.History.MinAge = 0
+	}()
+
+	u, err := models.FindUserByEmailAndAudience(ts.API.db, "test@example.com", ts.Config.JWT.Aud)
+	require.NoError(ts.T(), err)
+
+	updatePassword := func(password string) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]string{"password": password}))
+
+		req := httptest.NewRequest(http.MethodPut, "http://localhost/user", &buffer)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ts.generateAccessTokenAndSession(u)))
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(ts.T(), http.StatusOK, updatePassword("newpassword1").Code)
+	require.Equal(ts.T(), http.StatusOK, updatePassword("newpassword2").Code)
+
+	// the last two passwords can't be reused
+	w := updatePassword("password")
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+	require.Contains(ts.T(), w.Body.String(), string(ErrorCodePasswordReused))
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, updatePassword("newpassword1").Code)
+
+	// older passwords are removed from the history
+	require.Equal(ts.T(), http.StatusOK, updatePassword("newpassword3").Code)
+	history, err := models.FindPasswordHistory(ts.API.db, u.ID, 10)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), history, 2)
+	require.Equal(ts.T(), http.StatusOK, updatePassword("password").Code)
+
+	// passwords can't be changed again before the min age
+	ts.Config.Password.History.MinAge = time.Hour
+	w = updatePassword("newpassword4")
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, w.Code)
+	require.Contains(ts.T(), w.Body.String(), string(ErrorCodePasswordTooRecent))
+}
+
 func (ts *UserTestSuite) TestUserUpdatePasswordReauthentication() {
 	ts.Config.Security.UpdatePasswordRequireReauthentication = true
 
@@ -556,3 +603,322 @@ func (ts *UserTestSuite) TestUserUpdatePasswordLogoutOtherSessions() {
 	ts.API.handler.ServeHTTP(w, req)
 	require.NotEqual(ts.T(), http.StatusOK, w.Code)
 }
+
+func (ts *UserTestSuite) TestUserSessions() {
+	u, err := models.FindUserByEmailAndAudience(ts.API.db, "test@example.com", ts.Config.JWT.Aud)
+	require.NoError(ts.T(), err, "Error finding user")
+
+	other, err := models.NewSession(u.ID, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(other))
+	token := ts.generateAccessTokenAndSession(u)
+
+	request := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "http://localhost"+path, nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request(http.MethodGet, "/user/sessions")
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var list struct {
+		Sessions []*SessionResponse `json:"sessions"`
+	}
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&list))
+	require.Len(ts.T(), list.Sessions, 2)
+	for _, session := range list.Sessions {
+		require.Equal(ts.T(), session.ID != other.ID, session.Current)
+		require.Equal(ts.T(), "aal1", session.AAL)
+	}
+
+	// sessions of other users can't be revoked
+	stranger, err := models.NewUser("", "stranger@example.com", "password", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(stranger))
+	strangerSession, err := models.NewSession(stranger.ID, nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.API.db.Create(strangerSession))
+	require.Equal(ts.T(), http.StatusNotFound, request(http.MethodDelete, "/user/sessions/"+strangerSession.ID.String()).Code)
+
+	require.Equal(ts.T(), http.StatusOK, request(http.MethodDelete, "/user/sessions/"+other.ID.String()).Code)
+	_, err = models.FindSessionByID(ts.API.db, other.ID, false)
+	require.True(ts.T(), models.IsNotFoundError(err))
+
+	w = request(http.MethodGet, "/user/sessions")
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&list))
+	require.Len(ts.T(), list.Sessions, 1)
+	require.True(ts.T(), list.Sessions[0].Current)
+}
+
+func (ts *UserTestSuite) TestUserAccountDeletion() {
+	ts.Config.AccountDeletion.Enabled = true
+	ts.Config.AccountDeletion.GracePeriod = 30 * 24 * time.Hour
+	defer func() {
+		ts.Config.AccountDeletion.Enabled = false
+	}()
+
+	u, err := models.FindUserByEmailAndAudience(ts.API.db, "test@example.com", ts.Config.JWT.Aud)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), u.Confirm(ts.API.db))
+	token := ts.generateAccessTokenAndSession(u)
+
+	reauthenticate := func() {
+		now := time.Now()
+		u.ReauthenticationToken = crypto.GenerateTokenHash(u.GetEmail(), "123456")
+		u.ReauthenticationSentAt = &now
+		require.NoError(ts.T(), ts.API.db.Update(u))
+	}
+
+	request := func(method, path string, body map[string]interface{}) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		if body != nil {
+			require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(body))
+		}
+
+		req := httptest.NewRequest(method, "http://localhost"+path, &buffer)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// deletion requires reauthentication
+	require.Equal(ts.T(), http.StatusBadRequest, request(http.MethodDelete, "/user", map[string]interface{}{}).Code)
+	require.Equal(ts.T(), http.StatusNotFound, request(http.MethodGet, "/user/deletion", nil).Code)
+
+	reauthenticate()
+	w := request(http.MethodDelete, "/user", map[string]interface{}{"nonce": "123456"})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	var deletion models.AccountDeletion
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&deletion))
+	require.WithinDuration(ts.T(), time.Now().Add(ts.Config.AccountDeletion.GracePeriod), deletion.DeleteAt, time.Minute)
+
+	require.Equal(ts.T(), http.StatusOK, request(http.MethodGet, "/user/deletion", nil).Code)
+
+	// accounts aren't deleted before the grace period is over
+	deleted, err := ts.API.deleteScheduledAccount(context.Background())
+	require.NoError(ts.T(), err)
+	require.False(ts.T(), deleted)
+
+	require.Equal(ts.T(), http.StatusOK, request(http.MethodPost, "/user/deletion/cancel", nil).Code)
+	require.Equal(ts.T(), http.StatusNotFound, request(http.MethodGet, "/user/deletion", nil).Code)
+
+	reauthenticate()
+	require.Equal(ts.T(), http.StatusOK, request(http.MethodDelete, "/user", map[string]interface{}{"nonce": "123456"}).Code)
+
+	scheduled, err := models.FindAccountDeletionByUserID(ts.API.db, u.ID)
+	require.NoError(ts.T(), err)
+	scheduled.DeleteAt = time.Now().Add(-time.Minute)
+	require.NoError(ts.T(), ts.API.db.Update(scheduled))
+
+	deleted, err = ts.API.deleteScheduledAccount(context.Background())
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), deleted)
+
+	_, err = models.FindUserByID(ts.API.db, u.ID)
+	require.True(ts.T(), models.IsNotFoundError(err))
+}
+
+func (ts *UserTestSuite) TestUserDataExport() {
+	ts.Config.DataExport.Enabled = true
+	ts.Config.DataExport.Expiry = 24 * time.Hour
+	defer func() {
+		ts.Config.DataExport.Enabled = false
+	}()
+
+	u, err := models.FindUserByEmailAndAudience(ts.API.db, "test@example.com", ts.Config.JWT.Aud)
+	require.NoError(ts.T(), err)
+	token := ts.generateAccessTokenAndSession(u)
+
+	request := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "http://localhost"+path, nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request(http.MethodPost, "/user/data_exports")
+	require.Equal(ts.T(), http.StatusAccepted, w.Code, w.Body.String())
+
+	var export models.DataExport
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&export))
+	require.Equal(ts.T(), models.DataExportPending, export.Status)
+
+	// pending exports are returned instead of requesting another one
+	w = request(http.MethodPost, "/user/data_exports")
+	require.Equal(ts.T(), http.StatusAccepted, w.Code)
+	require.Contains(ts.T(), w.Body.String(), export.ID.String())
+
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, request(http.MethodGet, "/user/data_exports/"+export.ID.String()+"/download").Code)
+
+	ts.API.processDataExports(context.Background())
+
+	w = request(http.MethodGet, "/user/data_exports/"+export.ID.String())
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&export))
+	require.Equal(ts.T(), models.DataExportCompleted, export.Status)
+
+	w = request(http.MethodGet, "/user/data_exports/"+export.ID.String()+"/download")
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+	require.Contains(ts.T(), w.Header().Get("Content-Disposition"), "attachment")
+
+	var archive DataExportArchive
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&archive))
+	require.Equal(ts.T(), u.ID, archive.User.ID)
+	require.Len(ts.T(), archive.Sessions, 1)
+	require.NotEmpty(ts.T(), archive.AuditLogEntries)
+
+	// expired exports are removed with their archives
+	expiresAt := time.Now().Add(-time.Minute)
+	export.ExpiresAt = &expiresAt
+	require.NoError(ts.T(), ts.API.db.UpdateOnly(&export, "expires_at"))
+
+	ts.API.processDataExports(context.Background())
+	require.Equal(ts.T(), http.StatusNotFound, request(http.MethodGet, "/user/data_exports/"+export.ID.String()).Code)
+}
+
+func (ts *UserTestSuite) TestUserSecondaryEmails() {
+	ts.Config.SecondaryEmails.Enabled = true
+	ts.Config.SecondaryEmails.MaxPerUser = 1
+	ts.Config.SMTP.MaxFrequency = 0
+	defer func() {
+		ts.Config.SecondaryEmails.Enabled = false
+	}()
+
+	u, err := models.FindUserByEmailAndAudience(ts.API.db, "test@example.com", ts.Config.JWT.Aud)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), u.Confirm(ts.API.db))
+	token := ts.generateAccessTokenAndSession(u)
+
+	request := func(method, path, token string, body map[string]interface{}) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		if body != nil {
+			require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(body))
+		}
+
+		req := httptest.NewRequest(method, "http://localhost"+path, &buffer)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		}
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request(http.MethodPost, "/user/emails", token, map[string]interface{}{"email": "Work@Example.com"})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	var userEmail models.UserEmail
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&userEmail))
+	require.Equal(ts.T(), "work@example.com", userEmail.Email)
+	require.False(ts.T(), userEmail.IsVerified())
+
+	// the primary email can't be added, and users are limited in how many
+	// addresses they add
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, request(http.MethodPost, "/user/emails", token, map[string]interface{}{"email": "test@example.com"}).Code)
+	require.Equal(ts.T(), http.StatusUnprocessableEntity, request(http.MethodPost, "/user/emails", token, map[string]interface{}{"email": "other@example.com"}).Code)
+
+	// unverified addresses can't be used to sign in
+	passwordGrant := func(email string) int {
+		return request(http.MethodPost, "/token?grant_type=password", "", map[string]interface{}{"email": email, "password": "password"}).Code
+	}
+	require.Equal(ts.T(), http.StatusBadRequest, passwordGrant("work@example.com"))
+
+	stored, err := models.FindUserEmailByUserIDAndEmail(ts.API.db, u.ID, "work@example.com")
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), stored.SetToken(ts.API.db, crypto.GenerateTokenHash("work@example.com", "123456")))
+
+	require.Equal(ts.T(), http.StatusForbidden, request(http.MethodPost, "/user/emails/verify", token, map[string]interface{}{"email": "work@example.com", "token": "654321"}).Code)
+	w = request(http.MethodPost, "/user/emails/verify", token, map[string]interface{}{"email": "work@example.com", "token": "123456"})
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&userEmail))
+	require.True(ts.T(), userEmail.IsVerified())
+
+	require.Equal(ts.T(), http.StatusOK, passwordGrant("work@example.com"))
+
+	// verified addresses are taken, like primary emails
+	duplicate, err := models.IsDuplicatedEmail(ts.API.db, "work@example.com", ts.Config.JWT.Aud, nil)
+	require.NoError(ts.T(), err)
+	require.NotNil(ts.T(), duplicate)
+	require.Equal(ts.T(), u.ID, duplicate.ID)
+
+	// the old primary email becomes a verified secondary address
+	w = request(http.MethodPost, "/user/emails/"+userEmail.ID.String()+"/primary", token, nil)
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	u, err = models.FindUserByID(ts.API.db, u.ID)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), "work@example.com", u.GetEmail())
+
+	previous, err := models.FindUserEmailByUserIDAndEmail(ts.API.db, u.ID, "test@example.com")
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), previous.IsVerified())
+	require.Equal(ts.T(), http.StatusOK, passwordGrant("test@example.com"))
+
+	require.Equal(ts.T(), http.StatusOK, request(http.MethodDelete, "/user/emails/"+previous.ID.String(), token, nil).Code)
+	require.Equal(ts.T(), http.StatusBadRequest, passwordGrant("test@example.com"))
+	require.Equal(ts.T(), http.StatusNotFound, request(http.MethodDelete, "/user/emails/"+previous.ID.String(), token, nil).Code)
+}
+
+func (ts *UserTestSuite) TestUserSecondaryEmailVerifyAttempts() {
+	ts.Config.SecondaryEmails.Enabled = true
+	ts.Config.SMTP.MaxFrequency = 0
+	ts.Config.RateLimitEndpoints.Verify = conf.EndpointRateLimitConfiguration{Requests: userEmailVerifyMaxAttempts + 1, Period: time.Hour, Key: conf.RateLimitKeyUser}
+	defer func() {
+		ts.Config.SecondaryEmails.Enabled = false
+		ts.Config.RateLimitEndpoints.Verify = conf.EndpointRateLimitConfiguration{}
+	}()
+
+	u, err := models.FindUserByEmailAndAudience(ts.API.db, "test@example.com", ts.Config.JWT.Aud)
+	require.NoError(ts.T(), err)
+	token := ts.generateAccessTokenAndSession(u)
+
+	userEmail := models.NewUserEmail(u, "victim@example.com")
+	require.NoError(ts.T(), ts.API.db.Create(userEmail))
+	require.NoError(ts.T(), userEmail.SetToken(ts.API.db, crypto.GenerateTokenHash("victim@example.com", "123456")))
+
+	verify := func(code string) int {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{"email": "victim@example.com", "token": code}))
+
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/user/emails/verify", &buffer)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// the code is cleared once too many wrong codes were entered, so the
+	// right one doesn't verify the address anymore
+	for i := 0; i < userEmailVerifyMaxAttempts; i++ {
+		require.Equal(ts.T(), http.StatusForbidden, verify("000000"))
+	}
+
+	stored, err := models.FindUserEmailByUserIDAndEmail(ts.API.db, u.ID, "victim@example.com")
+	require.NoError(ts.T(), err)
+	require.Nil(ts.T(), stored.TokenHash)
+	require.Equal(ts.T(), userEmailVerifyMaxAttempts, stored.FailedAttempts)
+
+	require.Equal(ts.T(), http.StatusForbidden, verify("123456"))
+
+	// and the attempts are rate limited like /verify
+	require.Equal(ts.T(), http.StatusTooManyRequests, verify("123456"))
+
+	stored, err = models.FindUserEmailByUserIDAndEmail(ts.API.db, u.ID, "victim@example.com")
+	require.NoError(ts.T(), err)
+	require.False(ts.T(), stored.IsVerified())
+}
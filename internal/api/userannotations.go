@@ -0,0 +1,211 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+const (
+	maxUserNoteLength     = 10000
+	maxUserTagValueLength = 256
+)
+
+// userTagNamePattern matches the names of tags, which are used in the
+// tag.<name> filters of the users.
+var userTagNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_:-]{0,63}$`)
+
+type UserNoteParams struct {
+	Body string `json:"body"`
+}
+
+type UserTagParams struct {
+	Value string `json:"value"`
+}
+
+// adminUserNotesList returns the notes on the user, latest first.
+func (a *API) adminUserNotesList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+
+	notes, err := models.FindUserNotesByUserID(db, user.ID)
+	if err != nil {
+		return internalServerError("Database error finding user notes").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"notes": notes,
+	})
+}
+
+// adminUserNoteCreate adds a note on the user, by the admin, and returns
+// it.
+func (a *API) adminUserNoteCreate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	adminUser := getAdminUser(ctx)
+	user := getUser(ctx)
+
+	params := &UserNoteParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	body := strings.TrimSpace(params.Body)
+	if body == "" {
+		return badRequestError(ErrorCodeValidationFailed, "body is required")
+	}
+	if utf8.RuneCountInString(body) > maxUserNoteLength {
+		return badRequestError(ErrorCodeValidationFailed, "body can have at most %d characters", maxUserNoteLength)
+	}
+
+	note := models.NewUserNote(user, body, adminUser)
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		if terr := tx.Create(note); terr != nil {
+			return terr
+		}
+
+		// the body of notes isn't copied to the audit log, whose entries
+		// about users are part of their data exports
+		return models.NewAuditLogEntry(r, tx, adminUser, models.UserNoteAddedAction, "", map[string]interface{}{
+			"user_id": user.ID,
+			"note_id": note.ID,
+		})
+	}); err != nil {
+		return internalServerError("Database error adding user note").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, note)
+}
+
+// adminUserNoteDelete deletes the note with the note_id from the user.
+func (a *API) adminUserNoteDelete(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+
+	noteID, err := uuid.FromString(chi.URLParam(r, "note_id"))
+	if err != nil {
+		return notFoundError(ErrorCodeValidationFailed, "note_id must be an UUID")
+	}
+
+	err = db.Transaction(func(tx *storage.Connection) error {
+		note, terr := models.FindUserNoteByID(tx, user.ID, noteID)
+		if terr != nil {
+			if models.IsNotFoundError(terr) {
+				return notFoundError(ErrorCodeUserNoteNotFound, "User note not found")
+			}
+			return internalServerError("Database error finding user note").WithInternalError(terr)
+		}
+
+		if terr := tx.Destroy(note); terr != nil {
+			return internalServerError("Database error deleting user note").WithInternalError(terr)
+		}
+
+		if terr := models.NewAuditLogEntry(r, tx, getAdminUser(ctx), models.UserNoteDeletedAction, "", map[string]interface{}{
+			"user_id": user.ID,
+			"note_id": note.ID,
+		}); terr != nil {
+			return internalServerError("Error recording audit log entry").WithInternalError(terr)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+// adminUserTagsList returns the tags of the user, by name.
+func (a *API) adminUserTagsList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+
+	tags, err := models.FindUserTagsByUserID(db, user.ID)
+	if err != nil {
+		return internalServerError("Database error finding user tags").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"tags": tags,
+	})
+}
+
+// adminUserTagSet puts the tag with the name on the user, or changes its
+// value, and returns it.
+func (a *API) adminUserTagSet(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	adminUser := getAdminUser(ctx)
+	user := getUser(ctx)
+
+	name := chi.URLParam(r, "name")
+	if !userTagNamePattern.MatchString(name) {
+		return badRequestError(ErrorCodeValidationFailed, "Tag names must have up to 64 lowercase letters, digits, '_', '-' or ':'")
+	}
+
+	params := &UserTagParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if utf8.RuneCountInString(params.Value) > maxUserTagValueLength {
+		return badRequestError(ErrorCodeValidationFailed, "value can have at most %d characters", maxUserTagValueLength)
+	}
+
+	var tag *models.UserTag
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if tag, terr = models.SetUserTag(tx, user.ID, name, params.Value, adminUser); terr != nil {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, adminUser, models.UserTagSetAction, "", map[string]interface{}{
+			"user_id":  user.ID,
+			"tag_name": tag.Name,
+		})
+	}); err != nil {
+		return internalServerError("Database error setting user tag").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, tag)
+}
+
+// adminUserTagRemove removes the tag with the name from the user.
+func (a *API) adminUserTagRemove(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+	name := chi.URLParam(r, "name")
+
+	removed := false
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if removed, terr = models.RemoveUserTag(tx, user.ID, name); terr != nil || !removed {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, getAdminUser(ctx), models.UserTagRemovedAction, "", map[string]interface{}{
+			"user_id":  user.ID,
+			"tag_name": name,
+		})
+	}); err != nil {
+		return internalServerError("Database error removing user tag").WithInternalError(err)
+	}
+
+	if !removed {
+		return notFoundError(ErrorCodeUserTagNotFound, "User doesn't have the tag")
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+)
+
+const (
+	// banLiftInterval is how often expired bans are lifted.
+	banLiftInterval = time.Minute
+
+	// banLiftBatchSize is how many expired bans are lifted at once.
+	banLiftBatchSize = 100
+
+	maxBanReasonLength = 1000
+)
+
+var liftedBansCounter = observability.ObtainMetricCounter("gotrue_lifted_bans", "Number of bans lifted when they expired")
+
+// parseBanDuration parses the ban duration of the params, which is nil when
+// the ban isn't changed and zero when the user is unbanned. Scoped admin
+// credentials need the users:ban scope to change bans.
+func parseBanDuration(ctx context.Context, params *AdminUserParams) (*time.Duration, error) {
+	if params.BanReason != "" && (params.BanDuration == "" || params.BanDuration == "none") {
+		return nil, badRequestError(ErrorCodeValidationFailed, "ban_reason can only be set with a ban_duration")
+	}
+	if len(params.BanReason) > maxBanReasonLength {
+		return nil, badRequestError(ErrorCodeValidationFailed, "ban_reason must be at most %d characters", maxBanReasonLength)
+	}
+
+	if params.BanDuration == "" {
+		return nil, nil
+	}
+
+	if err := checkAdminScope(ctx, models.AdminScopeUsersBan); err != nil {
+		return nil, err
+	}
+
+	duration := time.Duration(0)
+	if params.BanDuration != "none" {
+		var err error
+		duration, err = time.ParseDuration(params.BanDuration)
+		if err != nil {
+			return nil, badRequestError(ErrorCodeValidationFailed, "invalid format for ban duration: %v", err)
+		}
+	}
+
+	return &duration, nil
+}
+
+// banUser bans the user for the duration with the reason, or unbans it when
+// the duration is zero, and records it in the ban history of the user.
+func banUser(r *http.Request, tx *storage.Connection, adminUser, user *models.User, duration time.Duration, reason string) error {
+	wasBanned := user.IsBanned()
+
+	if err := user.Ban(tx, duration); err != nil {
+		return err
+	}
+
+	// the previous ban is replaced by the new one
+	if err := models.LiftUserBans(tx, user.ID); err != nil {
+		return err
+	}
+
+	if duration == 0 {
+		if !wasBanned {
+			return nil
+		}
+
+		return models.NewAuditLogEntry(r, tx, adminUser, models.UserUnbannedAction, "", map[string]interface{}{
+			"user_id":    user.ID,
+			"user_email": user.Email,
+			"user_phone": user.Phone,
+		})
+	}
+
+	ban := models.NewUserBan(user, reason, adminUser)
+	if err := tx.Create(ban); err != nil {
+		return err
+	}
+
+	return models.NewAuditLogEntry(r, tx, adminUser, models.UserBannedAction, "", map[string]interface{}{
+		"user_id":      user.ID,
+		"user_email":   user.Email,
+		"user_phone":   user.Phone,
+		"banned_until": ban.BannedUntil,
+		"reason":       reason,
+	})
+}
+
+// adminUserBans returns the ban history of the user, latest first.
+func (a *API) adminUserBans(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+
+	bans, err := models.FindUserBansByUserID(db, user.ID)
+	if err != nil {
+		return internalServerError("Database error finding user bans").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"bans": bans,
+	})
+}
+
+// LiftExpiredBans lifts the bans as they expire until the context is done,
+// recording them in the audit log.
+func (a *API) LiftExpiredBans(ctx context.Context) {
+	ticker := time.NewTicker(banLiftInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			a.liftExpiredBans(ctx)
+		}
+	}
+}
+
+func (a *API) liftExpiredBans(ctx context.Context) {
+	db := a.db.WithContext(ctx)
+
+	for ctx.Err() == nil {
+		var lifted int
+
+		err := db.Transaction(func(tx *storage.Connection) error {
+			bans, terr := models.LiftExpiredUserBans(tx, banLiftBatchSize)
+			if terr != nil {
+				return terr
+			}
+			lifted = len(bans)
+
+			for _, ban := range bans {
+				user, terr := models.FindUserByID(tx, ban.UserID)
+				if terr != nil {
+					return terr
+				}
+
+				if terr := models.NewAuditLogEntry(nil, tx, user, models.UserUnbannedAction, "", map[string]interface{}{
+					"user_id":      user.ID,
+					"user_email":   user.Email,
+					"user_phone":   user.Phone,
+					"banned_until": ban.BannedUntil,
+					"expired":      true,
+				}); terr != nil {
+					return terr
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			logrus.WithError(err).Error("Unable to lift expired bans")
+			return
+		}
+
+		if lifted > 0 {
+			liftedBansCounter.Add(ctx, int64(lifted))
+			logrus.WithField("count", lifted).Info("Lifted expired bans")
+		}
+
+		if lifted < banLiftBatchSize {
+			return
+		}
+	}
+}
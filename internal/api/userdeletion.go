@@ -0,0 +1,174 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// userPurgeBatchSize is how many users are purged at once, so that the
+// cascades don't overwork the database.
+const userPurgeBatchSize = 10
+
+var purgedUsersCounter = observability.ObtainMetricCounter("gotrue_purged_users", "Number of soft deleted users purged after their retention period")
+
+// retainDeletedUser keeps the personal data of a user being soft deleted,
+// to undelete it until it's purged after the retention period.
+func (a *API) retainDeletedUser(tx *storage.Connection, user *models.User) error {
+	config := a.config
+
+	identities, err := models.FindIdentitiesByUserID(tx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	deletion, err := models.NewUserDeletion(user, identities, config.UserDeletion.RetentionPeriod, config.Security.DBEncryption.Encrypt, config.Security.DBEncryption.EncryptionKeyID, config.Security.DBEncryption.EncryptionKey)
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(deletion)
+}
+
+// softDeleteUser soft deletes the user, and deletes its MFA factors and
+// sessions. Its personal data is kept until it's purged when user deletion
+// retention is enabled, so it can be undeleted.
+func (a *API) softDeleteUser(tx *storage.Connection, user *models.User) error {
+	if a.config.UserDeletion.Enabled {
+		if err := a.retainDeletedUser(tx, user); err != nil {
+			return internalServerError("Error retaining deleted user").WithInternalError(err)
+		}
+	}
+
+	if err := user.SoftDeleteUser(tx); err != nil {
+		return internalServerError("Error soft deleting user").WithInternalError(err)
+	}
+
+	if err := user.SoftDeleteUserIdentities(tx); err != nil {
+		return internalServerError("Error soft deleting user identities").WithInternalError(err)
+	}
+
+	// hard delete all associated factors
+	if err := models.DeleteFactorsByUserId(tx, user.ID); err != nil {
+		return internalServerError("Error deleting user's factors").WithInternalError(err)
+	}
+	// hard delete all associated sessions
+	if err := models.Logout(tx, user.ID); err != nil {
+		return internalServerError("Error deleting user's sessions").WithInternalError(err)
+	}
+
+	return nil
+}
+
+// adminUserUndelete restores a soft deleted user with its personal data,
+// until it's purged. Its sessions and MFA factors were deleted with it and
+// aren't restored.
+func (a *API) adminUserUndelete(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+	user := getUser(ctx)
+	adminUser := getAdminUser(ctx)
+
+	deletion, err := models.FindUserDeletionByUserID(db, user.ID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return notFoundError(ErrorCodeUserDeletionNotFound, "User was not soft deleted, or was deleted without a retention period")
+		}
+		return internalServerError("Database error finding user deletion").WithInternalError(err)
+	}
+
+	snapshot, err := deletion.GetSnapshot(config.Security.DBEncryption.DecryptionKeys)
+	if err != nil {
+		return internalServerError("Error reading user deletion").WithInternalError(err)
+	}
+
+	// the email, phone and identities may have been used by other users
+	// since the user was deleted
+	if snapshot.Email != "" {
+		if duplicate, err := models.IsDuplicatedEmail(db, snapshot.Email, user.Aud, user); err != nil {
+			return internalServerError("Database error checking email").WithInternalError(err)
+		} else if duplicate != nil {
+			return unprocessableEntityError(ErrorCodeEmailExists, DuplicateEmailMsg)
+		}
+	}
+	if snapshot.Phone != "" {
+		if exists, err := models.IsDuplicatedPhone(db, snapshot.Phone, user.Aud); err != nil {
+			return internalServerError("Database error checking phone").WithInternalError(err)
+		} else if exists {
+			return unprocessableEntityError(ErrorCodePhoneExists, "Phone number already registered by another user")
+		}
+	}
+	for _, identity := range snapshot.Identities {
+		if _, err := models.FindIdentityByIdAndProvider(db, identity.ProviderID, identity.Provider); err == nil {
+			return unprocessableEntityError(ErrorCodeIdentityAlreadyExists, "The %s identity is already linked to another user", identity.Provider)
+		} else if !models.IsNotFoundError(err) {
+			return internalServerError("Database error checking identity").WithInternalError(err)
+		}
+	}
+
+	err = db.Transaction(func(tx *storage.Connection) error {
+		if terr := deletion.Restore(tx, user, snapshot); terr != nil {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, adminUser, models.UserUndeletedAction, "", map[string]interface{}{
+			"user_id":    user.ID,
+			"user_email": user.Email,
+			"user_phone": user.Phone,
+		})
+	})
+	if err != nil {
+		return internalServerError("Database error undeleting user").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, user)
+}
+
+// PurgeDeletedUsers deletes the soft deleted users past their retention
+// period until the context is done.
+func (a *API) PurgeDeletedUsers(ctx context.Context) {
+	config := a.config
+	if !config.UserDeletion.Enabled {
+		return
+	}
+
+	ticker := time.NewTicker(config.UserDeletion.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			a.purgeDeletedUsers(ctx)
+		}
+	}
+}
+
+func (a *API) purgeDeletedUsers(ctx context.Context) {
+	db := a.db.WithContext(ctx)
+
+	for ctx.Err() == nil {
+		count, err := models.PurgeDeletedUsers(db, userPurgeBatchSize)
+		if err != nil {
+			logrus.WithError(err).Error("Unable to purge deleted users")
+			return
+		}
+
+		if count > 0 {
+			purgedUsersCounter.Add(ctx, int64(count))
+			logrus.WithField("count", count).Info("Purged deleted users")
+		}
+
+		if count < userPurgeBatchSize {
+			return
+		}
+	}
+}
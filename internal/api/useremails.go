@@ -0,0 +1,371 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/hooks"
+	mail "github.com/supabase/auth/internal/mailer"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+	"github.com/supabase/auth/internal/utilities"
+)
+
+// userEmailVerifyMaxAttempts is how many wrong codes can be entered to
+// verify a secondary email address before its code is cleared, and a new
+// one has to be sent.
+const userEmailVerifyMaxAttempts = 5
+
+// UserEmailAddParams are the parameters the UserEmailAdd method accepts
+type UserEmailAddParams struct {
+	Email string `json:"email"`
+}
+
+// UserEmailVerifyParams are the parameters the UserEmailVerify method
+// accepts
+type UserEmailVerifyParams struct {
+	Email string `json:"email"`
+	Token string `json:"token"`
+}
+
+// findUserByLoginEmail finds the user with the primary email, or with the
+// verified secondary email when secondary emails are enabled, so users can
+// sign in and recover their account with any of their addresses.
+func (a *API) findUserByLoginEmail(tx *storage.Connection, email, aud string) (*models.User, error) {
+	if a.config.SecondaryEmails.Enabled {
+		return models.FindUserByAnyEmailAndAudience(tx, email, aud)
+	}
+	return models.FindUserByEmailAndAudience(tx, email, aud)
+}
+
+// withRecipientEmail returns the user to send emails to the address the
+// user was found with, which is a copy of the user with the address as its
+// email when it's a secondary one.
+func withRecipientEmail(user *models.User, email string) *models.User {
+	if email == "" || strings.EqualFold(user.GetEmail(), email) {
+		return user
+	}
+
+	recipient := *user
+	recipient.Email = storage.NullString(strings.ToLower(email))
+	return &recipient
+}
+
+// findUserEmail returns the secondary email address of the user with the
+// ID in the URL.
+func findUserEmail(r *http.Request, db *storage.Connection, user *models.User) (*models.UserEmail, error) {
+	emailID, err := uuid.FromString(chi.URLParam(r, "email_id"))
+	if err != nil {
+		return nil, notFoundError(ErrorCodeValidationFailed, "email_id must be an UUID")
+	}
+
+	userEmail, err := models.FindUserEmailByUserIDAndID(db, user.ID, emailID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, notFoundError(ErrorCodeSecondaryEmailNotFound, "Email address not found")
+		}
+		return nil, internalServerError("Database error finding email address").WithInternalError(err)
+	}
+
+	return userEmail, nil
+}
+
+// sendSecondaryEmailVerification sends a code to verify the secondary email
+// address to that address.
+func (a *API) sendSecondaryEmailVerification(r *http.Request, tx *storage.Connection, user *models.User, userEmail *models.UserEmail) error {
+	config := a.config
+
+	otp, err := crypto.GenerateOtp(config.Mailer.OtpLength)
+	if err != nil {
+		// OTP generation must succeed
+		panic(err)
+	}
+	tokenHash := crypto.GenerateTokenHash(userEmail.Email, otp)
+
+	if err := a.limitEmail(r, []string{userEmail.Email}); err != nil {
+		return tooManyRequestsError(ErrorCodeOverEmailSendRateLimit, EmailRateLimitExceeded.Error())
+	}
+
+	if config.Hook.SendEmail.Enabled {
+		input := hooks.SendEmailInput{
+			User: user,
+			EmailData: mail.EmailData{
+				Token:           otp,
+				TokenHash:       tokenHash,
+				EmailActionType: mail.SecondaryEmailVerification,
+				RedirectTo:      utilities.GetReferrer(r, config),
+				SiteURL:         getExternalHost(r.Context()).String(),
+				Email:           userEmail.Email,
+			},
+		}
+		output := hooks.SendEmailOutput{}
+		if err := a.invokeHook(tx, r, &input, &output); err != nil {
+			return err
+		}
+	} else if err := a.Mailer().SecondaryEmailMail(r, user, userEmail.Email, otp); err != nil {
+		return internalServerError("Error sending email address verification").WithInternalError(err)
+	}
+
+	if err := userEmail.SetToken(tx, tokenHash); err != nil {
+		return internalServerError("Database error updating email address").WithInternalError(err)
+	}
+
+	return nil
+}
+
+// UserEmailList lists the secondary email addresses of the user.
+func (a *API) UserEmailList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	emails, err := models.FindUserEmailsByUserID(db, getUser(ctx).ID)
+	if err != nil {
+		return internalServerError("Database error finding email addresses").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"emails": emails,
+	})
+}
+
+// UserEmailAdd adds a secondary email address to the user, and sends a code
+// to verify it to that address. Adding an address that wasn't verified yet
+// sends a new code.
+func (a *API) UserEmailAdd(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+	user := getUser(ctx)
+
+	params := &UserEmailAddParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	email, err := a.validateEmail(params.Email)
+	if err != nil {
+		return err
+	}
+	email = strings.ToLower(email)
+	if err := a.validateEmailDomain(email); err != nil {
+		return err
+	}
+
+	if user.GetEmail() == "" || !user.IsConfirmed() {
+		return unprocessableEntityError(ErrorCodeEmailNotConfirmed, "The primary email address must be confirmed before adding other ones")
+	}
+	if strings.EqualFold(user.GetEmail(), email) {
+		return unprocessableEntityError(ErrorCodeEmailExists, "Email address is already the primary email address")
+	}
+
+	var userEmail *models.UserEmail
+	err = db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		userEmail, terr = models.FindUserEmailByUserIDAndEmail(tx, user.ID, email)
+		if terr == nil {
+			if userEmail.IsVerified() {
+				return unprocessableEntityError(ErrorCodeEmailExists, "Email address was already added")
+			}
+			if terr := validateSentWithinFrequencyLimit(userEmail.SentAt, config.SMTP.MaxFrequency); terr != nil {
+				return terr
+			}
+			return a.sendSecondaryEmailVerification(r, tx, user, userEmail)
+		} else if !models.IsNotFoundError(terr) {
+			return internalServerError("Database error finding email address").WithInternalError(terr)
+		}
+
+		count, terr := models.CountUserEmails(tx, user.ID)
+		if terr != nil {
+			return internalServerError("Database error counting email addresses").WithInternalError(terr)
+		}
+		if count >= config.SecondaryEmails.MaxPerUser {
+			return unprocessableEntityError(ErrorCodeSecondaryEmailLimitReached, "Users can't have more than %d secondary email addresses", config.SecondaryEmails.MaxPerUser)
+		}
+
+		if duplicateUser, terr := a.findDuplicateEmail(tx, email, user.Aud, user); terr != nil {
+			return internalServerError("Database error checking email").WithInternalError(terr)
+		} else if duplicateUser != nil {
+			return unprocessableEntityError(ErrorCodeEmailExists, DuplicateEmailMsg)
+		}
+
+		userEmail = models.NewUserEmail(user, email)
+		if terr := tx.Create(userEmail); terr != nil {
+			return internalServerError("Database error adding email address").WithInternalError(terr)
+		}
+
+		if terr := models.NewAuditLogEntry(r, tx, user, models.SecondaryEmailAddedAction, "", map[string]interface{}{
+			"email": userEmail.Email,
+		}); terr != nil {
+			return internalServerError("Error recording audit log entry").WithInternalError(terr)
+		}
+
+		return a.sendSecondaryEmailVerification(r, tx, user, userEmail)
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, userEmail)
+}
+
+// UserEmailVerify verifies a secondary email address of the user with the
+// code sent to it. Once verified, the address can be used to sign in and
+// recover the account.
+func (a *API) UserEmailVerify(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+	user := getUser(ctx)
+
+	params := &UserEmailVerifyParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if params.Email == "" || params.Token == "" {
+		return badRequestError(ErrorCodeValidationFailed, "Verifying an email address requires the email and the token")
+	}
+
+	var userEmail *models.UserEmail
+	err := db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		userEmail, terr = models.FindUserEmailByUserIDAndEmail(tx, user.ID, params.Email)
+		if terr != nil {
+			if models.IsNotFoundError(terr) {
+				return notFoundError(ErrorCodeSecondaryEmailNotFound, "Email address not found")
+			}
+			return internalServerError("Database error finding email address").WithInternalError(terr)
+		}
+
+		if userEmail.IsVerified() {
+			return nil
+		}
+
+		if userEmail.TokenHash == nil {
+			return forbiddenError(ErrorCodeOTPExpired, "Token has expired or is invalid")
+		}
+
+		if !isOtpValid(crypto.GenerateTokenHash(userEmail.Email, params.Token), *userEmail.TokenHash, userEmail.SentAt, config.Mailer.OtpExp) {
+			if terr := userEmail.RecordFailedAttempt(tx, userEmailVerifyMaxAttempts); terr != nil {
+				return internalServerError("Database error updating email address").WithInternalError(terr)
+			}
+			return storage.NewCommitWithError(forbiddenError(ErrorCodeOTPExpired, "Token has expired or is invalid"))
+		}
+
+		// the address could have been taken since it was added
+		if duplicateUser, terr := a.findDuplicateEmail(tx, userEmail.Email, user.Aud, user); terr != nil {
+			return internalServerError("Database error checking email").WithInternalError(terr)
+		} else if duplicateUser != nil {
+			return unprocessableEntityError(ErrorCodeEmailExists, DuplicateEmailMsg)
+		}
+
+		if terr := userEmail.Verify(tx); terr != nil {
+			if pgErr := utilities.NewPostgresError(terr); pgErr != nil && pgErr.IsUniqueConstraintViolated() {
+				return unprocessableEntityError(ErrorCodeEmailExists, DuplicateEmailMsg)
+			}
+			return internalServerError("Database error verifying email address").WithInternalError(terr)
+		}
+
+		if terr := models.NewAuditLogEntry(r, tx, user, models.SecondaryEmailVerifiedAction, "", map[string]interface{}{
+			"email": userEmail.Email,
+		}); terr != nil {
+			return internalServerError("Error recording audit log entry").WithInternalError(terr)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, userEmail)
+}
+
+// UserEmailSetPrimary makes a verified secondary email address the primary
+// email of the user. The old primary email becomes a verified secondary
+// address, so the user can still sign in with it.
+func (a *API) UserEmailSetPrimary(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+
+	userEmail, err := findUserEmail(r, db, user)
+	if err != nil {
+		return err
+	}
+
+	if !userEmail.IsVerified() {
+		return unprocessableEntityError(ErrorCodeSecondaryEmailNotVerified, "Email address must be verified before making it the primary one")
+	}
+
+	oldEmail := user.GetEmail()
+	err = db.Transaction(func(tx *storage.Connection) error {
+		if terr := tx.Destroy(userEmail); terr != nil {
+			return internalServerError("Database error updating email address").WithInternalError(terr)
+		}
+
+		user.EmailChange = userEmail.Email
+		if terr := user.ConfirmEmailChange(tx, zeroConfirmation); terr != nil {
+			return internalServerError("Database error updating user email").WithInternalError(terr)
+		}
+
+		if oldEmail != "" {
+			now := time.Now()
+			previous := models.NewUserEmail(user, oldEmail)
+			previous.VerifiedAt = &now
+			if terr := tx.Create(previous); terr != nil {
+				return internalServerError("Database error updating email address").WithInternalError(terr)
+			}
+		}
+
+		if terr := models.NewAuditLogEntry(r, tx, user, models.PrimaryEmailChangedAction, "", map[string]interface{}{
+			"old_email": oldEmail,
+			"new_email": user.GetEmail(),
+		}); terr != nil {
+			return internalServerError("Error recording audit log entry").WithInternalError(terr)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, user)
+}
+
+// UserEmailRemove removes a secondary email address of the user, which
+// can't be used to sign in anymore.
+func (a *API) UserEmailRemove(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	user := getUser(ctx)
+
+	userEmail, err := findUserEmail(r, db, user)
+	if err != nil {
+		return err
+	}
+
+	err = db.Transaction(func(tx *storage.Connection) error {
+		if terr := tx.Destroy(userEmail); terr != nil {
+			return internalServerError("Database error removing email address").WithInternalError(terr)
+		}
+
+		if terr := models.NewAuditLogEntry(r, tx, user, models.SecondaryEmailRemovedAction, "", map[string]interface{}{
+			"email": userEmail.Email,
+		}); terr != nil {
+			return internalServerError("Error recording audit log entry").WithInternalError(terr)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
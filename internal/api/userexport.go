@@ -0,0 +1,366 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// userExportBatchSize is how many users are read from the database at once
+// while an export is streamed.
+const userExportBatchSize = 1000
+
+// userExportFields are the fields exported by default, in the order of the
+// CSV columns. The password hash is only exported when asked for.
+var userExportFields = []string{
+	"id",
+	"aud",
+	"role",
+	"email",
+	"phone",
+	"email_confirmed_at",
+	"phone_confirmed_at",
+	"last_sign_in_at",
+	"created_at",
+	"updated_at",
+	"banned_until",
+	"deleted_at",
+	"is_anonymous",
+	"is_sso_user",
+	"user_metadata",
+	"app_metadata",
+	"identities",
+}
+
+const userExportPasswordHashField = "password_hash"
+
+// userExportParams are the query parameters of an export.
+type userExportParams struct {
+	Format              string
+	Fields              []string
+	IncludePasswordHash bool
+	Filter              models.UserFilter
+}
+
+func parseUserExportParams(r *http.Request) (*userExportParams, error) {
+	query := r.URL.Query()
+
+	filter, err := parseUserFilter(query)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &userExportParams{
+		Format: query.Get("format"),
+		Filter: filter,
+	}
+
+	switch params.Format {
+	case "":
+		params.Format = "ndjson"
+	case "ndjson", "csv":
+	default:
+		return nil, badRequestError(ErrorCodeValidationFailed, "format must be ndjson or csv")
+	}
+
+	if value := query.Get("include_password_hash"); value != "" {
+		include, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, badRequestError(ErrorCodeValidationFailed, "include_password_hash must be a boolean")
+		}
+		params.IncludePasswordHash = include
+	}
+
+	known := map[string]bool{userExportPasswordHashField: true}
+	for _, field := range userExportFields {
+		known[field] = true
+	}
+
+	if value := query.Get("fields"); value != "" {
+		for _, field := range strings.Split(value, ",") {
+			field = strings.TrimSpace(field)
+			if !known[field] {
+				return nil, badRequestError(ErrorCodeValidationFailed, "Unknown field %q", field)
+			}
+			if field == userExportPasswordHashField && !params.IncludePasswordHash {
+				return nil, badRequestError(ErrorCodeValidationFailed, "Password hashes are only exported with include_password_hash=true")
+			}
+			params.Fields = append(params.Fields, field)
+		}
+	} else {
+		params.Fields = append(params.Fields, userExportFields...)
+		if params.IncludePasswordHash {
+			params.Fields = append(params.Fields, userExportPasswordHashField)
+		}
+	}
+
+	return params, nil
+}
+
+// userExportValue returns the value of a field of the user.
+func userExportValue(user *models.User, field string, identities []*models.Identity, decryptionKeys map[string]string) (interface{}, error) {
+	switch field {
+	case "id":
+		return user.ID.String(), nil
+	case "aud":
+		return user.Aud, nil
+	case "role":
+		return user.Role, nil
+	case "email":
+		return user.GetEmail(), nil
+	case "phone":
+		return user.GetPhone(), nil
+	case "email_confirmed_at":
+		return user.EmailConfirmedAt, nil
+	case "phone_confirmed_at":
+		return user.PhoneConfirmedAt, nil
+	case "last_sign_in_at":
+		return user.LastSignInAt, nil
+	case "created_at":
+		return &user.CreatedAt, nil
+	case "updated_at":
+		return &user.UpdatedAt, nil
+	case "banned_until":
+		return user.BannedUntil, nil
+	case "deleted_at":
+		return user.DeletedAt, nil
+	case "is_anonymous":
+		return user.IsAnonymous, nil
+	case "is_sso_user":
+		return user.IsSSOUser, nil
+	case "user_metadata":
+		return user.UserMetaData, nil
+	case "app_metadata":
+		return user.AppMetaData, nil
+
+	case "identities":
+		if identities == nil {
+			identities = []*models.Identity{}
+		}
+		return identities, nil
+
+	case userExportPasswordHashField:
+		if user.EncryptedPassword == nil {
+			return "", nil
+		}
+
+		// hashes encrypted in the database are exported decrypted
+		hash := *user.EncryptedPassword
+		if es := crypto.ParseEncryptedString(hash); es != nil {
+			decrypted, err := es.Decrypt(user.ID.String(), decryptionKeys)
+			if err != nil {
+				return nil, err
+			}
+			hash = string(decrypted)
+		}
+		return hash, nil
+	}
+
+	return nil, nil
+}
+
+// userExportCSVCell formats a value in a cell, with the metadata and
+// identities as JSON.
+func userExportCSVCell(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case *time.Time:
+		if v == nil {
+			return "", nil
+		}
+		return v.UTC().Format(time.RFC3339Nano), nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// userExportWriter writes the exported users as NDJSON or CSV.
+type userExportWriter struct {
+	fields []string
+	w      io.Writer
+	csv    *csv.Writer
+}
+
+func newUserExportWriter(w io.Writer, format string, fields []string) (*userExportWriter, error) {
+	writer := &userExportWriter{
+		fields: fields,
+		w:      w,
+	}
+
+	if format == "csv" {
+		writer.csv = csv.NewWriter(w)
+		if err := writer.csv.Write(fields); err != nil {
+			return nil, err
+		}
+	}
+
+	return writer, nil
+}
+
+func (e *userExportWriter) write(values []interface{}) error {
+	if e.csv != nil {
+		record := make([]string, len(values))
+		for i, value := range values {
+			cell, err := userExportCSVCell(value)
+			if err != nil {
+				return err
+			}
+			record[i] = cell
+		}
+		return e.csv.Write(record)
+	}
+
+	row := make(map[string]interface{}, len(values))
+	for i, value := range values {
+		row[e.fields[i]] = value
+	}
+
+	data, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.w.Write(append(data, '\n'))
+	return err
+}
+
+func (e *userExportWriter) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		return e.csv.Error()
+	}
+	return nil
+}
+
+// adminUsersExport streams the users of the audience as NDJSON or CSV, for
+// backups and migrations. The users are read in batches ordered by ID
+// rather than paginated, so exports of millions of users don't slow down
+// as they go. Password hashes are only exported with include_password_hash.
+func (a *API) adminUsersExport(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	adminUser := getAdminUser(ctx)
+	aud := a.requestAud(ctx, r)
+
+	params, err := parseUserExportParams(r)
+	if err != nil {
+		return err
+	}
+
+	exportIdentities := false
+	for _, field := range params.Fields {
+		if field == "identities" {
+			exportIdentities = true
+		}
+	}
+
+	if err := models.NewAuditLogEntry(r, db, adminUser, models.UsersExportedAction, "", map[string]interface{}{
+		"format":                params.Format,
+		"fields":                params.Fields,
+		"include_password_hash": params.IncludePasswordHash,
+	}); err != nil {
+		return internalServerError("Database error recording user export").WithInternalError(err)
+	}
+
+	// the first batch is read before the response is started, so that
+	// errors reading it are returned as errors
+	users, err := models.FindUsersInAudienceAfter(db, aud, uuid.Nil, userExportBatchSize, params.Filter)
+	if err != nil {
+		return internalServerError("Database error finding users").WithInternalError(err)
+	}
+
+	contentType := "application/x-ndjson"
+	if params.Format == "csv" {
+		contentType = "text/csv"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename=\"users."+params.Format+"\"")
+	w.WriteHeader(http.StatusOK)
+
+	writer, err := newUserExportWriter(w, params.Format, params.Fields)
+	if err == nil {
+		err = a.writeUsersExport(db, writer, w, users, exportIdentities, aud, params)
+	}
+
+	if err != nil {
+		// the response is aborted rather than ended, so the export isn't
+		// mistaken for a complete one
+		logrus.WithError(err).Error("Unable to export users")
+		panic(http.ErrAbortHandler)
+	}
+
+	return nil
+}
+
+func (a *API) writeUsersExport(db *storage.Connection, writer *userExportWriter, w http.ResponseWriter, users []*models.User, exportIdentities bool, aud string, params *userExportParams) error {
+	config := a.config
+
+	for len(users) > 0 {
+		identities := make(map[uuid.UUID][]*models.Identity)
+		if exportIdentities {
+			userIDs := make([]uuid.UUID, len(users))
+			for i, user := range users {
+				userIDs[i] = user.ID
+			}
+
+			found, err := models.FindIdentitiesByUserIDs(db, userIDs)
+			if err != nil {
+				return err
+			}
+			for _, identity := range found {
+				identities[identity.UserID] = append(identities[identity.UserID], identity)
+			}
+		}
+
+		for _, user := range users {
+			values := make([]interface{}, len(params.Fields))
+			for i, field := range params.Fields {
+				value, err := userExportValue(user, field, identities[user.ID], config.Security.DBEncryption.DecryptionKeys)
+				if err != nil {
+					return err
+				}
+				values[i] = value
+			}
+
+			if err := writer.write(values); err != nil {
+				return err
+			}
+		}
+
+		if err := writer.flush(); err != nil {
+			return err
+		}
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+
+		if len(users) < userExportBatchSize {
+			break
+		}
+
+		var err error
+		users, err = models.FindUsersInAudienceAfter(db, aud, users[len(users)-1].ID, userExportBatchSize, params.Filter)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+func TestParseUserExportParams(t *testing.T) {
+	params, err := parseUserExportParams(httptest.NewRequest("GET", "/admin/users/export", nil))
+	require.NoError(t, err)
+	assert.Equal(t, "ndjson", params.Format)
+	assert.Equal(t, userExportFields, params.Fields)
+	assert.NotContains(t, params.Fields, userExportPasswordHashField)
+
+	params, err = parseUserExportParams(httptest.NewRequest("GET", "/admin/users/export?include_password_hash=true", nil))
+	require.NoError(t, err)
+	assert.Contains(t, params.Fields, userExportPasswordHashField)
+
+	params, err = parseUserExportParams(httptest.NewRequest("GET", "/admin/users/export?format=csv&fields=id,email&filter=example.com&created_after=2024-01-01T00:00:00Z", nil))
+	require.NoError(t, err)
+	assert.Equal(t, "csv", params.Format)
+	assert.Equal(t, []string{"id", "email"}, params.Fields)
+	assert.Equal(t, "example.com", params.Filter.Search)
+	require.NotNil(t, params.Filter.CreatedAfter)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), params.Filter.CreatedAfter.UTC())
+	assert.Nil(t, params.Filter.CreatedBefore)
+
+	for _, query := range []string{
+		"format=xml",
+		"fields=id,password",
+		"fields=id,password_hash",
+		"include_password_hash=maybe",
+		"created_before=yesterday",
+	} {
+		_, err := parseUserExportParams(httptest.NewRequest("GET", "/admin/users/export?"+query, nil))
+		assert.Error(t, err, query)
+	}
+}
+
+func TestUserExportWriter(t *testing.T) {
+	confirmedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	hash := "$2a$10$hash"
+	user := &models.User{
+		ID:                uuid.Must(uuid.FromString("6e5b5ba0-73c8-4c4b-9d0a-1c3f4c1b8c71")),
+		Email:             storage.NullString("user@example.com"),
+		EmailConfirmedAt:  &confirmedAt,
+		EncryptedPassword: &hash,
+		UserMetaData:      models.JSONMap{"name": "User"},
+	}
+	fields := []string{"id", "email", "email_confirmed_at", "phone_confirmed_at", "user_metadata", "password_hash"}
+
+	values := make([]interface{}, len(fields))
+	for i, field := range fields {
+		value, err := userExportValue(user, field, nil, nil)
+		require.NoError(t, err)
+		values[i] = value
+	}
+
+	var out bytes.Buffer
+	writer, err := newUserExportWriter(&out, "csv", fields)
+	require.NoError(t, err)
+	require.NoError(t, writer.write(values))
+	require.NoError(t, writer.flush())
+	assert.Equal(t, "id,email,email_confirmed_at,phone_confirmed_at,user_metadata,password_hash\n"+
+		"6e5b5ba0-73c8-4c4b-9d0a-1c3f4c1b8c71,user@example.com,2024-01-02T03:04:05Z,,\"{\"\"name\"\":\"\"User\"\"}\",$2a$10$hash\n", out.String())
+
+	out.Reset()
+	writer, err = newUserExportWriter(&out, "ndjson", fields)
+	require.NoError(t, err)
+	require.NoError(t, writer.write(values))
+	require.NoError(t, writer.write(values))
+	require.NoError(t, writer.flush())
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+
+	var row map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &row))
+	assert.Equal(t, map[string]interface{}{
+		"id":                 "6e5b5ba0-73c8-4c4b-9d0a-1c3f4c1b8c71",
+		"email":              "user@example.com",
+		"email_confirmed_at": "2024-01-02T03:04:05Z",
+		"phone_confirmed_at": nil,
+		"user_metadata":      map[string]interface{}{"name": "User"},
+		"password_hash":      "$2a$10$hash",
+	}, row)
+}
@@ -0,0 +1,550 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/structs"
+	"github.com/go-chi/chi/v5"
+	"github.com/gofrs/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/api/provider"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"github.com/supabase/auth/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// userImportLease is how long a job being run is hidden from other
+// instances, which is extended after each batch of users.
+const userImportLease = 5 * time.Minute
+
+// maxUserImportLineSize is the longest line of an import, which holds the
+// metadata and identities of a user.
+const maxUserImportLineSize = 1 << 20
+
+var userImportCounter = observability.ObtainMetricCounter("gotrue_user_imports", "Number of users imported in bulk by result")
+
+// userImportIdentity is an identity of an imported user, like the one of an
+// OAuth provider it signed in with before.
+type userImportIdentity struct {
+	Provider     string                 `json:"provider"`
+	ProviderID   string                 `json:"provider_id"`
+	IdentityData map[string]interface{} `json:"identity_data,omitempty"`
+}
+
+// userImportRow is a user to import. The password hash is a bcrypt, argon2
+// or scrypt hash in the PHC string format, with its parameters.
+type userImportRow struct {
+	ID           string                 `json:"id,omitempty"`
+	Email        string                 `json:"email,omitempty"`
+	Phone        string                 `json:"phone,omitempty"`
+	PasswordHash string                 `json:"password_hash,omitempty"`
+	EmailConfirm bool                   `json:"email_confirm,omitempty"`
+	PhoneConfirm bool                   `json:"phone_confirm,omitempty"`
+	Role         string                 `json:"role,omitempty"`
+	UserMetaData map[string]interface{} `json:"user_metadata,omitempty"`
+	AppMetaData  map[string]interface{} `json:"app_metadata,omitempty"`
+	Identities   []userImportIdentity   `json:"identities,omitempty"`
+}
+
+// userImportCSVColumns are the columns of CSV imports. Metadata and
+// identities are JSON in their cells.
+var userImportCSVColumns = map[string]bool{
+	"id":            true,
+	"email":         true,
+	"phone":         true,
+	"password_hash": true,
+	"email_confirm": true,
+	"phone_confirm": true,
+	"role":          true,
+	"user_metadata": true,
+	"app_metadata":  true,
+	"identities":    true,
+}
+
+// parseUserImportNDJSON parses the users of an import with one JSON object
+// per line.
+func parseUserImportNDJSON(body io.Reader, maxRows int) ([]json.RawMessage, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxUserImportLineSize)
+
+	var rows []json.RawMessage
+	line := 0
+	for scanner.Scan() {
+		line += 1
+
+		data := strings.TrimSpace(scanner.Text())
+		if data == "" {
+			continue
+		}
+
+		if len(rows) >= maxRows {
+			return nil, badRequestError(ErrorCodeValidationFailed, "Imports can have at most %d users", maxRows)
+		}
+
+		var row userImportRow
+		if err := json.Unmarshal([]byte(data), &row); err != nil {
+			return nil, badRequestError(ErrorCodeValidationFailed, "Line %d is not a valid user: %v", line, err)
+		}
+
+		normalized, err := json.Marshal(&row)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, normalized)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, badRequestError(ErrorCodeValidationFailed, "Unable to read line %d: %v", line+1, err)
+	}
+
+	return rows, nil
+}
+
+// parseUserImportCSV parses the users of an import with a header naming the
+// columns, and a user per record.
+func parseUserImportCSV(body io.Reader, maxRows int) ([]json.RawMessage, error) {
+	reader := csv.NewReader(body)
+
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, badRequestError(ErrorCodeValidationFailed, "Unable to read the CSV header: %v", err)
+	}
+
+	for i, column := range header {
+		header[i] = strings.TrimSpace(strings.ToLower(column))
+		if !userImportCSVColumns[header[i]] {
+			return nil, badRequestError(ErrorCodeValidationFailed, "Unknown CSV column %q", column)
+		}
+	}
+
+	var rows []json.RawMessage
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			return nil, badRequestError(ErrorCodeValidationFailed, "Unable to read the CSV: %v", err)
+		}
+
+		if len(rows) >= maxRows {
+			return nil, badRequestError(ErrorCodeValidationFailed, "Imports can have at most %d users", maxRows)
+		}
+
+		var row userImportRow
+		for i, value := range record {
+			if err := row.setCSVColumn(header[i], strings.TrimSpace(value)); err != nil {
+				return nil, badRequestError(ErrorCodeValidationFailed, "Line %d has an invalid %s: %v", line, header[i], err)
+			}
+		}
+
+		normalized, err := json.Marshal(&row)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, normalized)
+	}
+
+	return rows, nil
+}
+
+func (row *userImportRow) setCSVColumn(column, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	switch column {
+	case "id":
+		row.ID = value
+	case "email":
+		row.Email = value
+	case "phone":
+		row.Phone = value
+	case "password_hash":
+		row.PasswordHash = value
+	case "role":
+		row.Role = value
+
+	case "email_confirm", "phone_confirm":
+		confirm, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		if column == "email_confirm" {
+			row.EmailConfirm = confirm
+		} else {
+			row.PhoneConfirm = confirm
+		}
+
+	case "user_metadata":
+		return json.Unmarshal([]byte(value), &row.UserMetaData)
+	case "app_metadata":
+		return json.Unmarshal([]byte(value), &row.AppMetaData)
+	case "identities":
+		return json.Unmarshal([]byte(value), &row.Identities)
+	}
+
+	return nil
+}
+
+// adminUsersImport imports the users of an NDJSON or CSV body in the
+// background, and returns the job importing them. The rows that fail are
+// reported in the errors of the job.
+func (a *API) adminUsersImport(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+	adminUser := getAdminUser(ctx)
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return badRequestError(ErrorCodeValidationFailed, "Content-Type must be application/x-ndjson or text/csv")
+	}
+
+	var rows []json.RawMessage
+	switch mediaType {
+	case "application/x-ndjson", "application/jsonl":
+		rows, err = parseUserImportNDJSON(r.Body, config.UserImport.MaxRows)
+	case "text/csv":
+		rows, err = parseUserImportCSV(r.Body, config.UserImport.MaxRows)
+	default:
+		return badRequestError(ErrorCodeValidationFailed, "Content-Type must be application/x-ndjson or text/csv")
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(rows) == 0 {
+		return badRequestError(ErrorCodeValidationFailed, "The import has no users")
+	}
+
+	for _, raw := range rows {
+		var row userImportRow
+		if err := json.Unmarshal(raw, &row); err != nil {
+			// invalid rows are reported in the errors of the job
+			continue
+		}
+		if err := checkPrivilegeChanges(ctx, row.Role, row.AppMetaData); err != nil {
+			return err
+		}
+	}
+
+	aud := a.requestAud(ctx, r)
+	job, err := models.NewUserImportJob(aud, rows, config.Security.DBEncryption.Encrypt, config.Security.DBEncryption.EncryptionKeyID, config.Security.DBEncryption.EncryptionKey)
+	if err != nil {
+		return internalServerError("Error creating user import job").WithInternalError(err)
+	}
+
+	err = db.Transaction(func(tx *storage.Connection) error {
+		if terr := tx.Create(job); terr != nil {
+			return terr
+		}
+
+		return models.NewAuditLogEntry(r, tx, adminUser, models.UsersImportedAction, "", map[string]interface{}{
+			"user_import_job_id": job.ID,
+			"total_rows":         job.TotalRows,
+		})
+	})
+	if err != nil {
+		return internalServerError("Database error creating user import job").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusAccepted, job)
+}
+
+// adminUserImportJobGet returns the progress of an import, and the errors
+// of the rows that failed.
+func (a *API) adminUserImportJobGet(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	jobID, err := uuid.FromString(chi.URLParam(r, "job_id"))
+	if err != nil {
+		return notFoundError(ErrorCodeValidationFailed, "job_id must be an UUID")
+	}
+
+	job, err := models.FindUserImportJobByID(db, jobID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return notFoundError(ErrorCodeUserImportJobNotFound, "User import job not found")
+		}
+		return internalServerError("Database error finding user import job").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, job)
+}
+
+// ProcessUserImports runs the pending user import jobs, one at a time,
+// until the context is done.
+func (a *API) ProcessUserImports(ctx context.Context) {
+	config := a.config
+	if !config.UserImport.Enabled {
+		return
+	}
+
+	ticker := time.NewTicker(config.UserImport.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			a.runUserImportJob(ctx)
+		}
+	}
+}
+
+func (a *API) runUserImportJob(ctx context.Context) {
+	db := a.db.WithContext(ctx)
+	config := a.config
+
+	job, err := models.ClaimUserImportJob(db, userImportLease)
+	if err != nil {
+		logrus.WithError(err).Error("Unable to claim user import job")
+		return
+	}
+	if job == nil {
+		return
+	}
+
+	log := logrus.WithField("user_import_job_id", job.ID)
+
+	rows, err := job.GetRows(config.Security.DBEncryption.DecryptionKeys)
+	if err != nil {
+		log.WithError(err).Error("Unable to read the rows of user import job")
+		if err := job.Complete(db, models.UserImportFailed); err != nil {
+			log.WithError(err).Error("Unable to fail user import job")
+		}
+		return
+	}
+
+	// jobs resume after the rows processed by an instance that stopped
+	for i := job.ProcessedRows; i < len(rows); i++ {
+		if ctx.Err() != nil {
+			break
+		}
+
+		result := "imported"
+		if err := a.importUser(db, job.Aud, rows[i]); err != nil {
+			result = "failed"
+
+			message := "Database error importing user"
+			if httpErr, ok := err.(*HTTPError); ok && httpErr.HTTPStatus < http.StatusInternalServerError {
+				message = httpErr.Message
+			} else {
+				log.WithError(err).WithField("row", i+1).Error("Unable to import user")
+			}
+			job.AddError(i+1, message)
+		} else {
+			job.ImportedRows += 1
+		}
+		job.ProcessedRows += 1
+
+		userImportCounter.Add(ctx, 1, metric.WithAttributeSet(attribute.NewSet(attribute.String("result", result))))
+
+		if job.ProcessedRows%config.UserImport.BatchSize == 0 && job.ProcessedRows < len(rows) {
+			if err := job.SaveProgress(db, userImportLease); err != nil {
+				log.WithError(err).Error("Unable to save the progress of user import job")
+				return
+			}
+		}
+	}
+
+	if job.ProcessedRows < len(rows) {
+		// the job is resumed by the next instance claiming it
+		if err := job.SaveProgress(db, 0); err != nil {
+			log.WithError(err).Error("Unable to save the progress of user import job")
+		}
+		return
+	}
+
+	if err := job.Complete(db, models.UserImportCompleted); err != nil {
+		log.WithError(err).Error("Unable to complete user import job")
+	}
+}
+
+// importUser creates the user of a row, with its identities, the way the
+// admin creates a single user.
+func (a *API) importUser(db *storage.Connection, aud string, data json.RawMessage) error {
+	config := a.config
+
+	var row userImportRow
+	if err := json.Unmarshal(data, &row); err != nil {
+		return badRequestError(ErrorCodeValidationFailed, "Invalid user: %v", err)
+	}
+
+	if row.Email == "" && row.Phone == "" {
+		return badRequestError(ErrorCodeValidationFailed, "Cannot create a user without either an email or phone")
+	}
+
+	var err error
+	var providers []string
+	if row.Email != "" {
+		row.Email, err = a.validateEmail(row.Email)
+		if err != nil {
+			return err
+		}
+		if user, err := models.IsDuplicatedEmail(db, row.Email, aud, nil); err != nil {
+			return internalServerError("Database error checking email").WithInternalError(err)
+		} else if user != nil {
+			return unprocessableEntityError(ErrorCodeEmailExists, DuplicateEmailMsg)
+		}
+		providers = append(providers, "email")
+	}
+
+	if row.Phone != "" {
+		row.Phone, err = validatePhone(row.Phone)
+		if err != nil {
+			return err
+		}
+		if exists, err := models.IsDuplicatedPhone(db, row.Phone, aud); err != nil {
+			return internalServerError("Database error checking phone").WithInternalError(err)
+		} else if exists {
+			return unprocessableEntityError(ErrorCodePhoneExists, "Phone number already registered by another user")
+		}
+		providers = append(providers, "phone")
+	}
+
+	for _, identity := range row.Identities {
+		if identity.Provider == "" || identity.ProviderID == "" {
+			return badRequestError(ErrorCodeValidationFailed, "Identities must have a provider and a provider_id")
+		}
+		if identity.Provider == "email" || identity.Provider == "phone" {
+			return badRequestError(ErrorCodeValidationFailed, "The %s identity is created from the %s of the user", identity.Provider, identity.Provider)
+		}
+		if email, ok := identity.IdentityData["email"]; ok {
+			if _, ok := email.(string); !ok {
+				return badRequestError(ErrorCodeValidationFailed, "The email of the identity_data must be a string")
+			}
+		}
+		providers = append(providers, identity.Provider)
+	}
+
+	if row.UserMetaData == nil {
+		row.UserMetaData = make(map[string]interface{})
+	}
+
+	var user *models.User
+	if row.PasswordHash != "" {
+		user, err = models.NewUserWithPasswordHash(row.Phone, row.Email, row.PasswordHash, aud, row.UserMetaData)
+		if err != nil {
+			return badRequestError(ErrorCodeValidationFailed, "Invalid password hash: %v", err)
+		}
+	} else {
+		// users without a password sign in with a link, an OTP or their
+		// identities, or reset their password
+		user, err = models.NewUser(row.Phone, row.Email, "", aud, row.UserMetaData)
+		if err != nil {
+			return internalServerError("Error creating user").WithInternalError(err)
+		}
+	}
+
+	if row.ID != "" {
+		user.ID, err = parseUserID(row.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	user.AppMetaData = map[string]interface{}{
+		// TODO: Deprecate "provider" field
+		// default to the first provider in the providers slice
+		"provider":  providers[0],
+		"providers": providers,
+	}
+
+	return db.Transaction(func(tx *storage.Connection) error {
+		if row.ID != "" {
+			if terr := checkUserIDAvailable(tx, user.ID); terr != nil {
+				return terr
+			}
+		}
+
+		if terr := tx.Create(user); terr != nil {
+			return terr
+		}
+
+		if user.GetEmail() != "" {
+			if _, terr := a.createNewIdentity(tx, user, "email", structs.Map(provider.Claims{
+				Subject: user.ID.String(),
+				Email:   user.GetEmail(),
+			})); terr != nil {
+				return terr
+			}
+		}
+
+		if user.GetPhone() != "" {
+			if _, terr := a.createNewIdentity(tx, user, "phone", structs.Map(provider.Claims{
+				Subject: user.ID.String(),
+				Phone:   user.GetPhone(),
+			})); terr != nil {
+				return terr
+			}
+		}
+
+		for _, identity := range row.Identities {
+			if _, terr := models.FindIdentityByIdAndProvider(tx, identity.ProviderID, identity.Provider); terr == nil {
+				return unprocessableEntityError(ErrorCodeIdentityAlreadyExists, fmt.Sprintf("The %s identity is already linked to another user", identity.Provider))
+			} else if !models.IsNotFoundError(terr) {
+				return terr
+			}
+
+			identityData := identity.IdentityData
+			if identityData == nil {
+				identityData = map[string]interface{}{}
+			}
+			identityData["sub"] = identity.ProviderID
+
+			if _, terr := a.createNewIdentity(tx, user, identity.Provider, identityData); terr != nil {
+				return terr
+			}
+		}
+
+		role := config.JWT.DefaultGroupName
+		if row.Role != "" {
+			role = row.Role
+		}
+		if terr := user.SetRole(tx, role); terr != nil {
+			return terr
+		}
+
+		if row.AppMetaData != nil {
+			if terr := user.UpdateAppMetaData(tx, row.AppMetaData); terr != nil {
+				return terr
+			}
+		}
+
+		if row.EmailConfirm {
+			if terr := user.Confirm(tx); terr != nil {
+				return terr
+			}
+		}
+
+		if row.PhoneConfirm {
+			if terr := user.ConfirmPhone(tx); terr != nil {
+				return terr
+			}
+		}
+
+		return nil
+	})
+}
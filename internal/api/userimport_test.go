@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUserImportNDJSON(t *testing.T) {
+	body := `{"email":"user@example.com","password_hash":"$2a$10$abc","email_confirm":true}
+
+{"phone":"12345678","identities":[{"provider":"github","provider_id":"1234","identity_data":{"user_name":"user"}}]}
+`
+
+	rows, err := parseUserImportNDJSON(strings.NewReader(body), 10)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+
+	var row userImportRow
+	require.NoError(t, json.Unmarshal(rows[1], &row))
+	assert.Equal(t, "12345678", row.Phone)
+	assert.Equal(t, []userImportIdentity{{Provider: "github", ProviderID: "1234", IdentityData: map[string]interface{}{"user_name": "user"}}}, row.Identities)
+
+	_, err = parseUserImportNDJSON(strings.NewReader(body), 1)
+	require.Error(t, err)
+
+	_, err = parseUserImportNDJSON(strings.NewReader("{\"email\":\"user@example.com\"}\n{\"email\":"), 10)
+	require.ErrorContains(t, err, "Line 2")
+}
+
+func TestParseUserImportCSV(t *testing.T) {
+	body := "email,password_hash,email_confirm,user_metadata\n" +
+		"user@example.com,\"$scrypt$ln=4,r=8,p=1$c2FsdA$aGFzaA\",true,\"{\"\"name\"\":\"\"User\"\"}\"\n" +
+		"other@example.com,,,\n"
+
+	rows, err := parseUserImportCSV(strings.NewReader(body), 10)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+
+	var row userImportRow
+	require.NoError(t, json.Unmarshal(rows[0], &row))
+	assert.Equal(t, userImportRow{
+		Email:        "user@example.com",
+		PasswordHash: "$scrypt$ln=4,r=8,p=1$c2FsdA$aGFzaA",
+		EmailConfirm: true,
+		UserMetaData: map[string]interface{}{"name": "User"},
+	}, row)
+
+	_, err = parseUserImportCSV(strings.NewReader("email,password\nuser@example.com,secret\n"), 10)
+	require.ErrorContains(t, err, "Unknown CSV column")
+
+	_, err = parseUserImportCSV(strings.NewReader("email,email_confirm\nuser@example.com,maybe\n"), 10)
+	require.ErrorContains(t, err, "Line 2 has an invalid email_confirm")
+
+	_, err = parseUserImportCSV(strings.NewReader(body), 1)
+	require.Error(t, err)
+}
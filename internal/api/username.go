@@ -0,0 +1,194 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// usernamePattern are the usernames once normalized: lowercase letters,
+// digits, dots and underscores, starting and ending with a letter or digit.
+var usernamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9._]*[a-z0-9])?$`)
+
+// usernameSeparators are the characters ignored when comparing usernames to
+// the reserved ones, so ad.min is reserved like admin.
+var usernameSeparators = strings.NewReplacer(".", "", "_", "")
+
+// defaultReservedUsernames are the usernames no user can take, as they could
+// be mistaken for the staff or the system of the site.
+var defaultReservedUsernames = []string{
+	"abuse",
+	"admin",
+	"administrator",
+	"anonymous",
+	"api",
+	"auth",
+	"help",
+	"hostmaster",
+	"info",
+	"mail",
+	"moderator",
+	"noreply",
+	"null",
+	"official",
+	"owner",
+	"postmaster",
+	"root",
+	"security",
+	"staff",
+	"support",
+	"system",
+	"undefined",
+	"webmaster",
+	"www",
+}
+
+// UsernameAvailabilityResponse tells whether a username can be taken, and
+// why not when it can't.
+type UsernameAvailabilityResponse struct {
+	Username  string `json:"username"`
+	Available bool   `json:"available"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// normalizeUsername returns the username as it's stored and compared.
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// validateUsernameFormat returns the normalized username, or an error when
+// it doesn't follow the rules of usernames.
+func (a *API) validateUsernameFormat(username string) (string, error) {
+	config := a.config
+
+	username = normalizeUsername(username)
+	if username == "" {
+		return "", badRequestError(ErrorCodeValidationFailed, "A username is required")
+	}
+
+	if len(username) < config.Username.MinLength || len(username) > config.Username.MaxLength {
+		return "", badRequestError(ErrorCodeValidationFailed, "Username must be between %d and %d characters", config.Username.MinLength, config.Username.MaxLength)
+	}
+
+	if !usernamePattern.MatchString(username) {
+		return "", badRequestError(ErrorCodeValidationFailed, "Username can only contain letters, digits, dots and underscores, and must start and end with a letter or digit")
+	}
+
+	return username, nil
+}
+
+// validateUsername returns the normalized username, or an error when it
+// doesn't follow the rules of usernames or is reserved. Admins can give
+// users reserved usernames.
+func (a *API) validateUsername(username string) (string, error) {
+	username, err := a.validateUsernameFormat(username)
+	if err != nil {
+		return "", err
+	}
+
+	if a.isReservedUsername(username) {
+		return "", unprocessableEntityError(ErrorCodeUsernameReserved, "Username is reserved")
+	}
+
+	return username, nil
+}
+
+// validateAdminUsername returns the normalized username set by an admin,
+// which can be reserved.
+func (a *API) validateAdminUsername(username string) (string, error) {
+	if !a.config.Username.Enabled {
+		return "", badRequestError(ErrorCodeUsernameProviderDisabled, "Usernames are disabled")
+	}
+
+	return a.validateUsernameFormat(username)
+}
+
+// isReservedUsername reports whether the normalized username is reserved by
+// default or by the configuration.
+func (a *API) isReservedUsername(username string) bool {
+	folded := usernameSeparators.Replace(username)
+
+	for _, reserved := range defaultReservedUsernames {
+		if folded == reserved {
+			return true
+		}
+	}
+
+	for _, reserved := range a.config.Username.Reserved {
+		if folded == usernameSeparators.Replace(normalizeUsername(reserved)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkUsernameAvailable returns an error when the normalized username is
+// taken by a user other than the one given.
+func checkUsernameAvailable(tx *storage.Connection, username string, user *models.User) error {
+	if exists, err := models.IsDuplicatedUsername(tx, username, user); err != nil {
+		return internalServerError("Database error checking username").WithInternalError(err)
+	} else if exists {
+		return unprocessableEntityError(ErrorCodeUsernameExists, "Username is already taken")
+	}
+
+	return nil
+}
+
+// updateUsername sets the username of the user, and of its username
+// identity when it has one.
+func (a *API) updateUsername(tx *storage.Connection, user *models.User, username string) error {
+	if err := user.SetUsername(tx, username); err != nil {
+		if models.IsUniqueConstraintViolatedError(err) {
+			return unprocessableEntityError(ErrorCodeUsernameExists, "Username is already taken")
+		}
+		return internalServerError("Database error updating username").WithInternalError(err)
+	}
+
+	identity, err := models.FindIdentityByIdAndProvider(tx, user.ID.String(), "username")
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil
+		}
+		return internalServerError("Database error finding identity").WithInternalError(err)
+	}
+
+	if err := identity.UpdateIdentityData(tx, map[string]interface{}{
+		"username": username,
+	}); err != nil {
+		return internalServerError("Database error updating identity").WithInternalError(err)
+	}
+
+	return nil
+}
+
+// UsernameAvailability tells whether a username can be taken, so sign up
+// forms can check it as it's typed.
+func (a *API) UsernameAvailability(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+
+	username, err := a.validateUsernameFormat(r.URL.Query().Get("username"))
+	if err != nil {
+		return err
+	}
+
+	response := &UsernameAvailabilityResponse{
+		Username: username,
+	}
+
+	if a.isReservedUsername(username) {
+		response.Reason = "reserved"
+	} else if exists, err := models.IsDuplicatedUsername(db, username, nil); err != nil {
+		return internalServerError("Database error checking username").WithInternalError(err)
+	} else if exists {
+		response.Reason = "taken"
+	} else {
+		response.Available = true
+	}
+
+	return sendJSON(w, http.StatusOK, response)
+}
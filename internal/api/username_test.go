@@ -0,0 +1,43 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+)
+
+func TestValidateUsername(t *testing.T) {
+	a := &API{
+		config: &conf.GlobalConfiguration{
+			Username: conf.UsernameConfiguration{
+				Enabled:   true,
+				MinLength: 3,
+				MaxLength: 16,
+				Reserved:  []string{"Billing"},
+			},
+		},
+	}
+
+	username, err := a.validateUsername("  Jane.Doe_99 ")
+	require.NoError(t, err)
+	assert.Equal(t, "jane.doe_99", username)
+
+	for _, username := range []string{"", "jd", "jane.doe.the.first", ".jane", "jane_", "jane doe", "jane@doe", "jané"} {
+		_, err := a.validateUsername(username)
+		assert.Error(t, err, username)
+	}
+
+	for _, username := range []string{"admin", "Ad.Min", "sup.port", "sup_port", "billing", "bil.ling"} {
+		_, err := a.validateUsername(username)
+		if assert.Error(t, err, username) {
+			assert.Equal(t, ErrorCodeUsernameReserved, err.(*HTTPError).ErrorCode, username)
+		}
+	}
+
+	// admins can give users reserved usernames
+	username, err = a.validateAdminUsername("Admin")
+	require.NoError(t, err)
+	assert.Equal(t, "admin", username)
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/models"
+)
+
+// parseUserFilter parses the filters of the users from the query, like
+// created_after=2024-01-01T00:00:00Z&provider=github&user_metadata.plan=pro.
+func parseUserFilter(query url.Values) (models.UserFilter, error) {
+	filter := models.UserFilter{
+		Search:   query.Get("filter"),
+		Provider: query.Get("provider"),
+		Tags:     query["tag"],
+	}
+
+	for name, bound := range map[string]**time.Time{
+		"created_after":       &filter.CreatedAfter,
+		"created_before":      &filter.CreatedBefore,
+		"last_sign_in_after":  &filter.LastSignInAfter,
+		"last_sign_in_before": &filter.LastSignInBefore,
+	} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, badRequestError(ErrorCodeValidationFailed, "%s must be an RFC 3339 time", name)
+			}
+			*bound = &t
+		}
+	}
+
+	for name, state := range map[string]**bool{
+		"email_confirmed": &filter.EmailConfirmed,
+		"phone_confirmed": &filter.PhoneConfirmed,
+		"banned":          &filter.Banned,
+		"is_anonymous":    &filter.IsAnonymous,
+		"is_sso_user":     &filter.IsSSOUser,
+	} {
+		if value := query.Get(name); value != "" {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return filter, badRequestError(ErrorCodeValidationFailed, "%s must be a boolean", name)
+			}
+			*state = &b
+		}
+	}
+
+	for name, values := range query {
+		for prefix, metadata := range map[string]*map[string]string{
+			"user_metadata.": &filter.UserMetaData,
+			"app_metadata.":  &filter.AppMetaData,
+			"tag.":           &filter.TagValues,
+		} {
+			key, ok := strings.CutPrefix(name, prefix)
+			if !ok {
+				continue
+			}
+			if key == "" {
+				return filter, badRequestError(ErrorCodeValidationFailed, "%s must name a metadata key or tag", name)
+			}
+
+			if *metadata == nil {
+				*metadata = make(map[string]string)
+			}
+			(*metadata)[key] = values[0]
+		}
+	}
+
+	return filter, nil
+}
+
+// userCursor is the opaque cursor of the next users, with the sort it
+// was returned for.
+type userCursor struct {
+	Sort  string     `json:"sort"`
+	Value *time.Time `json:"value,omitempty"`
+	ID    uuid.UUID  `json:"id"`
+}
+
+func encodeUserCursor(user *models.User, field models.SortField) string {
+	cursor := models.NewUserCursor(user, field.Name)
+
+	data, _ := json.Marshal(&userCursor{
+		Sort:  field.Name + " " + string(field.Dir),
+		Value: cursor.Value,
+		ID:    cursor.ID,
+	})
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeUserCursor(value string, field models.SortField) (*models.UserCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, badRequestError(ErrorCodeValidationFailed, "Invalid cursor")
+	}
+
+	var cursor userCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, badRequestError(ErrorCodeValidationFailed, "Invalid cursor")
+	}
+
+	if cursor.Sort != field.Name+" "+string(field.Dir) {
+		return nil, badRequestError(ErrorCodeValidationFailed, "The cursor was returned for another sort")
+	}
+
+	return &models.UserCursor{Value: cursor.Value, ID: cursor.ID}, nil
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/models"
+)
+
+func TestParseUserFilter(t *testing.T) {
+	query, err := url.ParseQuery("filter=jane&provider=github&created_after=2024-01-01T00:00:00Z&last_sign_in_before=2024-06-01T00:00:00Z&email_confirmed=true&banned=false&user_metadata.plan=pro&app_metadata.tenant=acme&tag=vip&tag.review=fraud")
+	require.NoError(t, err)
+
+	filter, err := parseUserFilter(query)
+	require.NoError(t, err)
+
+	yes, no := true, false
+	createdAfter := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	lastSignInBefore := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, models.UserFilter{
+		Search:           "jane",
+		Provider:         "github",
+		CreatedAfter:     &createdAfter,
+		LastSignInBefore: &lastSignInBefore,
+		EmailConfirmed:   &yes,
+		Banned:           &no,
+		UserMetaData:     map[string]string{"plan": "pro"},
+		AppMetaData:      map[string]string{"tenant": "acme"},
+		Tags:             []string{"vip"},
+		TagValues:        map[string]string{"review": "fraud"},
+	}, filter)
+
+	for _, invalid := range []string{
+		"created_after=2024-01-01",
+		"last_sign_in_after=yesterday",
+		"is_anonymous=maybe",
+		"user_metadata.=pro",
+		"tag.=fraud",
+	} {
+		query, err := url.ParseQuery(invalid)
+		require.NoError(t, err)
+
+		_, err = parseUserFilter(query)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestUserCursor(t *testing.T) {
+	lastSignInAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	user := &models.User{
+		ID:           uuid.Must(uuid.NewV4()),
+		CreatedAt:    time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+		LastSignInAt: &lastSignInAt,
+	}
+
+	field := models.SortField{Name: "last_sign_in_at", Dir: models.Descending}
+	cursor, err := decodeUserCursor(encodeUserCursor(user, field), field)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, cursor.ID)
+	require.NotNil(t, cursor.Value)
+	assert.True(t, lastSignInAt.Equal(*cursor.Value))
+
+	// users that never signed in have no value
+	user.LastSignInAt = nil
+	cursor, err = decodeUserCursor(encodeUserCursor(user, field), field)
+	require.NoError(t, err)
+	assert.Nil(t, cursor.Value)
+
+	_, err = decodeUserCursor(encodeUserCursor(user, field), models.SortField{Name: "last_sign_in_at", Dir: models.Ascending})
+	assert.Error(t, err)
+
+	_, err = decodeUserCursor("not a cursor", field)
+	assert.Error(t, err)
+}
+
+func TestNextUserCursor(t *testing.T) {
+	users := []*models.User{{ID: uuid.Must(uuid.NewV4())}, {ID: uuid.Must(uuid.NewV4())}}
+	sortParams := &models.SortParams{Fields: []models.SortField{{Name: models.CreatedAt, Dir: models.Descending}}}
+
+	assert.NotEmpty(t, nextUserCursor(users, sortParams, 2))
+	assert.Empty(t, nextUserCursor(users, sortParams, 3))
+
+	sortParams.Fields = append(sortParams.Fields, models.SortField{Name: "last_sign_in_at", Dir: models.Ascending})
+	assert.Empty(t, nextUserCursor(users, sortParams, 2))
+}
@@ -35,6 +35,9 @@ const (
 // Only applicable when SECURE_EMAIL_CHANGE_ENABLED
 const singleConfirmationAccepted = "Confirmation link accepted. Please proceed to confirm link sent to the other email"
 
+// Only applicable when both phone numbers confirm phone changes
+const singlePhoneConfirmationAccepted = "Code accepted. Please proceed to confirm the code sent to the other phone number"
+
 // VerifyParams are the parameters the Verify endpoint accepts
 type VerifyParams struct {
 	Type       string `json:"type"`
@@ -50,6 +53,11 @@ func (p *VerifyParams) Validate(r *http.Request, a *API) error {
 	if p.Type == "" {
 		return badRequestError(ErrorCodeValidationFailed, "Verify requires a verification type")
 	}
+	// email links carry the token hash, which can't be used when emails are
+	// only verified with codes
+	if a.config.Mailer.IsCodeVerification() && p.Type != smsVerification && p.Type != phoneChangeVerification && (r.Method == http.MethodGet || p.TokenHash != "") {
+		return badRequestError(ErrorCodeEmailLinkVerificationDisabled, "Email links are disabled, verify with the code sent by email instead")
+	}
 	switch r.Method {
 	case http.MethodGet:
 		if p.Token == "" {
@@ -229,7 +237,7 @@ func (a *API) verifyPost(w http.ResponseWriter, r *http.Request, params *VerifyP
 		grantParams models.GrantParams
 		token       *AccessTokenResponse
 	)
-	var isSingleConfirmationResponse = false
+	var singleConfirmationMessage string
 
 	grantParams.FillGrantParams(r)
 
@@ -254,11 +262,15 @@ func (a *API) verifyPost(w http.ResponseWriter, r *http.Request, params *VerifyP
 		case mail.EmailChangeVerification:
 			user, terr = a.emailChangeVerify(r, tx, params, user)
 			if user == nil && terr == nil {
-				isSingleConfirmationResponse = true
+				singleConfirmationMessage = singleConfirmationAccepted
 				return nil
 			}
 		case smsVerification, phoneChangeVerification:
 			user, terr = a.smsVerify(r, tx, user, params)
+			if user == nil && terr == nil {
+				singleConfirmationMessage = singlePhoneConfirmationAccepted
+				return nil
+			}
 		default:
 			return badRequestError(ErrorCodeValidationFailed, "Unsupported verification type")
 		}
@@ -285,9 +297,9 @@ func (a *API) verifyPost(w http.ResponseWriter, r *http.Request, params *VerifyP
 	if err != nil {
 		return err
 	}
-	if isSingleConfirmationResponse {
+	if singleConfirmationMessage != "" {
 		return sendJSON(w, http.StatusOK, map[string]string{
-			"msg":  singleConfirmationAccepted,
+			"msg":  singleConfirmationMessage,
 			"code": strconv.Itoa(http.StatusOK),
 		})
 	}
@@ -365,6 +377,30 @@ func (a *API) recoverVerify(r *http.Request, conn *storage.Connection, user *mod
 }
 
 func (a *API) smsVerify(r *http.Request, conn *storage.Connection, user *models.User, params *VerifyParams) (*models.User, error) {
+	// the current phone number was sent a code too, and both codes confirm
+	// the change
+	if params.Type == phoneChangeVerification &&
+		user.PhoneChangeConfirmStatus == zeroConfirmation &&
+		user.PhoneChangeTokenCurrent != "" {
+		err := conn.Transaction(func(tx *storage.Connection) error {
+			user.PhoneChangeConfirmStatus = singleConfirmation
+
+			if crypto.GenerateTokenHash(user.GetPhone(), params.Token) == user.PhoneChangeTokenCurrent {
+				user.PhoneChangeTokenCurrent = ""
+			} else {
+				user.PhoneChangeToken = ""
+				if terr := models.ClearOneTimeTokenForUser(tx, user.ID, models.PhoneChangeToken); terr != nil {
+					return terr
+				}
+			}
+
+			return tx.UpdateOnly(user, "phone_change_confirm_status", "phone_change_token_current", "phone_change_token")
+		})
+		if err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
 
 	err := conn.Transaction(func(tx *storage.Connection) error {
 
@@ -376,6 +412,15 @@ func (a *API) smsVerify(r *http.Request, conn *storage.Connection, user *models.
 				return internalServerError("Error confirming user").WithInternalError(terr)
 			}
 		} else if params.Type == phoneChangeVerification {
+			if account, terr := a.claimedAccount(tx, user, "", user.PhoneChange); terr != nil {
+				return internalServerError("Database error checking phone").WithInternalError(terr)
+			} else if account != nil {
+				if user, terr = a.mergeAnonymousUser(r, tx, user, account.ID); terr != nil {
+					return terr
+				}
+				return tx.Load(user, "Identities")
+			}
+
 			if terr := models.NewAuditLogEntry(r, tx, user, models.UserModifiedAction, "", nil); terr != nil {
 				return terr
 			}
@@ -399,9 +444,13 @@ func (a *API) smsVerify(r *http.Request, conn *storage.Connection, user *models.
 					return terr
 				}
 			}
+			oldPhone := user.GetPhone()
 			if terr := user.ConfirmPhoneChange(tx); terr != nil {
 				return internalServerError("Error confirming user").WithInternalError(terr)
 			}
+			if terr := a.notifyPhoneChanged(r, tx, user, oldPhone); terr != nil {
+				return terr
+			}
 		}
 
 		if user.IsAnonymous {
@@ -523,6 +572,15 @@ func (a *API) emailChangeVerify(r *http.Request, conn *storage.Connection, param
 
 	// one email is confirmed at this point if GOTRUE_MAILER_SECURE_EMAIL_CHANGE_ENABLED is enabled
 	err := conn.Transaction(func(tx *storage.Connection) error {
+		if account, terr := a.claimedAccount(tx, user, user.EmailChange, ""); terr != nil {
+			return internalServerError("Database error checking email").WithInternalError(terr)
+		} else if account != nil {
+			if user, terr = a.mergeAnonymousUser(r, tx, user, account.ID); terr != nil {
+				return terr
+			}
+			return tx.Load(user, "Identities")
+		}
+
 		if terr := models.NewAuditLogEntry(r, tx, user, models.UserModifiedAction, "", nil); terr != nil {
 			return terr
 		}
@@ -556,11 +614,12 @@ func (a *API) emailChangeVerify(r *http.Request, conn *storage.Connection, param
 		if terr := tx.Load(user, "Identities"); terr != nil {
 			return internalServerError("Error refetching identities").WithInternalError(terr)
 		}
+		oldEmail := user.GetEmail()
 		if terr := user.ConfirmEmailChange(tx, zeroConfirmation); terr != nil {
 			return internalServerError("Error confirm email").WithInternalError(terr)
 		}
 
-		return nil
+		return a.notifyEmailChanged(r, tx, user, oldEmail)
 	})
 	if err != nil {
 		return nil, err
@@ -642,7 +701,7 @@ func (a *API) verifyUserAndToken(conn *storage.Connection, params *VerifyParams,
 		// the query used has to also check if the token saved in the db contains the pkce_ prefix
 		user, err = models.FindUserForEmailChange(conn, params.Email, tokenHash, aud, config.Mailer.SecureEmailChangeEnabled)
 	default:
-		user, err = models.FindUserByEmailAndAudience(conn, params.Email, aud)
+		user, err = a.findUserByLoginEmail(conn, params.Email, aud)
 	}
 
 	if err != nil {
@@ -701,6 +760,11 @@ func (a *API) verifyUserAndToken(conn *storage.Connection, params *VerifyParams,
 			return user, nil
 		}
 		isValid = isOtpValid(tokenHash, expectedToken, sentAt, config.Sms.OtpExp)
+		if !isValid && params.Type == phoneChangeVerification {
+			// the code sent to the current phone number, when it confirms
+			// the change too
+			isValid = isOtpValid(crypto.GenerateTokenHash(user.GetPhone(), params.Token), user.PhoneChangeTokenCurrent, sentAt, config.Sms.OtpExp)
+		}
 	}
 
 	if !isValid {
@@ -1082,6 +1082,160 @@ func (ts *VerifyTestSuite) TestSecureEmailChangeWithTokenHash() {
 	}
 }
 
+func (ts *VerifyTestSuite) TestUndoEmailChange() {
+	ts.Config.Mailer.SecureEmailChangeEnabled = false
+	ts.Config.Mailer.EmailChange.NotifyOld = true
+	ts.Config.Mailer.EmailChange.UndoExpiry = time.Hour
+	defer func() {
+		ts.Config.Mailer.EmailChange.NotifyOld = false
+	}()
+
+	u, err := models.FindUserByEmailAndAudience(ts.API.db, "test@example.com", ts.Config.JWT.Aud)
+	require.NoError(ts.T(), err)
+
+	newEmailChangeToken := crypto.GenerateTokenHash("new@example.com", "123456")
+	currentTime := time.Now()
+	u.EmailChange = "new@example.com"
+	u.EmailChangeTokenNew = newEmailChangeToken
+	u.EmailChangeSentAt = &currentTime
+	require.NoError(ts.T(), ts.API.db.Update(u))
+	require.NoError(ts.T(), models.CreateOneTimeToken(ts.API.db, u.ID, u.EmailChange, newEmailChangeToken, models.EmailChangeTokenNew))
+
+	var buffer bytes.Buffer
+	require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+		"type":       mail.EmailChangeVerification,
+		"token_hash": newEmailChangeToken,
+	}))
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/verify", &buffer)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	u, err = models.FindUserByID(ts.API.db, u.ID)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), "new@example.com", u.GetEmail())
+
+	// the old email address was sent a link to undo the change
+	undo := &models.EmailChangeUndo{}
+	require.NoError(ts.T(), ts.API.db.Q().Where("user_id = ?", u.ID).First(undo))
+	assert.Equal(ts.T(), "test@example.com", undo.OldEmail)
+	assert.Equal(ts.T(), "new@example.com", undo.NewEmail)
+
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/email_change/undo?token_hash="+undo.TokenHash, nil)
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusSeeOther, w.Code)
+
+	rurl, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(ts.T(), err)
+	f, err := url.ParseQuery(rurl.Fragment)
+	require.NoError(ts.T(), err)
+	assert.Equal(ts.T(), emailChangeUndoneMessage, f.Get("message"))
+
+	u, err = models.FindUserByID(ts.API.db, u.ID)
+	require.NoError(ts.T(), err)
+	assert.Equal(ts.T(), "test@example.com", u.GetEmail())
+
+	// the link can only be used once
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusSeeOther, w.Code)
+
+	rurl, err = url.Parse(w.Header().Get("Location"))
+	require.NoError(ts.T(), err)
+	f, err = url.ParseQuery(rurl.Fragment)
+	require.NoError(ts.T(), err)
+	assert.Equal(ts.T(), "403", f.Get("error_code"))
+}
+
+func (ts *VerifyTestSuite) TestPhoneChangeConfirmedByBoth() {
+	u, err := models.FindUserByEmailAndAudience(ts.API.db, "test@example.com", ts.Config.JWT.Aud)
+	require.NoError(ts.T(), err)
+
+	now := time.Now()
+	u.PhoneConfirmedAt = &now
+	u.PhoneChange = "22222222"
+	u.PhoneChangeToken = crypto.GenerateTokenHash("22222222", "123456")
+	u.PhoneChangeTokenCurrent = crypto.GenerateTokenHash("12345678", "654321")
+	u.PhoneChangeSentAt = &now
+	require.NoError(ts.T(), ts.API.db.Update(u))
+
+	verify := func(token string) *httptest.ResponseRecorder {
+		var buffer bytes.Buffer
+		require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+			"type":  phoneChangeVerification,
+			"phone": "22222222",
+			"token": token,
+		}))
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/verify", &buffer)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		ts.API.handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// the code sent to the current phone number only confirms it
+	w := verify("654321")
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+	assert.Contains(ts.T(), w.Body.String(), singlePhoneConfirmationAccepted)
+
+	u, err = models.FindUserByID(ts.API.db, u.ID)
+	require.NoError(ts.T(), err)
+	assert.Equal(ts.T(), "12345678", u.GetPhone())
+	assert.Equal(ts.T(), singleConfirmation, u.PhoneChangeConfirmStatus)
+
+	// it can't be used twice
+	w = verify("654321")
+	require.Equal(ts.T(), http.StatusForbidden, w.Code)
+
+	w = verify("123456")
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	u, err = models.FindUserByID(ts.API.db, u.ID)
+	require.NoError(ts.T(), err)
+	assert.Equal(ts.T(), "22222222", u.GetPhone())
+	assert.Equal(ts.T(), zeroConfirmation, u.PhoneChangeConfirmStatus)
+}
+
+func (ts *VerifyTestSuite) TestUndoPhoneChange() {
+	u, err := models.FindUserByEmailAndAudience(ts.API.db, "test@example.com", ts.Config.JWT.Aud)
+	require.NoError(ts.T(), err)
+
+	u.PhoneChange = "22222222"
+	require.NoError(ts.T(), u.ConfirmPhoneChange(ts.API.db))
+
+	undo := models.NewPhoneChangeUndo(u, "12345678", crypto.SecureToken(), time.Now().Add(time.Hour))
+	require.NoError(ts.T(), ts.API.db.Create(undo))
+	assert.Equal(ts.T(), "22222222", undo.NewPhone)
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/phone_change/undo?token_hash="+undo.TokenHash, nil)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusSeeOther, w.Code)
+
+	rurl, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(ts.T(), err)
+	f, err := url.ParseQuery(rurl.Fragment)
+	require.NoError(ts.T(), err)
+	assert.Equal(ts.T(), phoneChangeUndoneMessage, f.Get("message"))
+
+	u, err = models.FindUserByID(ts.API.db, u.ID)
+	require.NoError(ts.T(), err)
+	assert.Equal(ts.T(), "12345678", u.GetPhone())
+
+	// the link can only be used once
+	w = httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusSeeOther, w.Code)
+
+	rurl, err = url.Parse(w.Header().Get("Location"))
+	require.NoError(ts.T(), err)
+	f, err = url.ParseQuery(rurl.Fragment)
+	require.NoError(ts.T(), err)
+	assert.Equal(ts.T(), "403", f.Get("error_code"))
+}
+
 func (ts *VerifyTestSuite) TestPrepRedirectURL() {
 	escapedMessage := url.QueryEscape(singleConfirmationAccepted)
 	cases := []struct {
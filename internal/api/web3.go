@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/supabase/auth/internal/api/provider"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// Web3GrantParams are the parameters the Web3Grant method accepts
+type Web3GrantParams struct {
+	Message   string `json:"message"`
+	Signature string `json:"signature"`
+}
+
+// Web3NonceResponse is the response of the Web3Nonce endpoint
+type Web3NonceResponse struct {
+	Nonce     string    `json:"nonce"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Web3Nonce issues a nonce to be included in a Sign-In with Ethereum message
+func (a *API) Web3Nonce(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db := a.db.WithContext(ctx)
+	config := a.config
+
+	if !config.External.Web3.Enabled {
+		return badRequestError(ErrorCodeProviderDisabled, "Web3 logins are disabled")
+	}
+
+	nonce, err := models.NewWeb3Nonce()
+	if err != nil {
+		return internalServerError("Error generating nonce").WithInternalError(err)
+	}
+
+	if err := db.Create(nonce); err != nil {
+		return internalServerError("Database error saving nonce").WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, &Web3NonceResponse{
+		Nonce:     nonce.Nonce,
+		ExpiresAt: nonce.CreatedAt.Add(config.External.Web3.NonceExpiry),
+	})
+}
+
+// Web3Grant implements the web3 grant type flow
+func (a *API) Web3Grant(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	db := a.db.WithContext(ctx)
+	config := a.config
+
+	if !config.External.Web3.Enabled {
+		return badRequestError(ErrorCodeProviderDisabled, "Web3 logins are disabled")
+	}
+
+	params := &Web3GrantParams{}
+	if err := retrieveRequestParams(r, params); err != nil {
+		return err
+	}
+
+	if params.Message == "" || params.Signature == "" {
+		return oauthError("invalid request", "message and signature required")
+	}
+
+	message, err := provider.ParseSIWEMessage(params.Message)
+	if err != nil {
+		return oauthError("invalid request", "Bad Sign-In with Ethereum message").WithInternalError(err)
+	}
+
+	if !a.isAllowedWeb3Domain(message.Domain) {
+		return oauthError("invalid request", "Sign-In with Ethereum message was issued for another domain")
+	}
+
+	if allowed := config.External.Web3.AllowedChainIDs; len(allowed) > 0 {
+		chainAllowed := false
+		for _, chainID := range allowed {
+			if chainID == message.ChainID {
+				chainAllowed = true
+				break
+			}
+		}
+
+		if !chainAllowed {
+			return oauthError("invalid request", "Sign-In with Ethereum message was issued for an unsupported chain")
+		}
+	}
+
+	if err := message.VerifyTime(time.Now()); err != nil {
+		return oauthError("invalid request", "Sign-In with Ethereum message is expired or not yet valid").WithInternalError(err)
+	}
+
+	if err := message.VerifySignature(params.Signature); err != nil {
+		return oauthError("invalid request", "Bad Sign-In with Ethereum signature").WithInternalError(err)
+	}
+
+	userData := message.UserData()
+
+	var token *AccessTokenResponse
+	var grantParams models.GrantParams
+
+	grantParams.FillGrantParams(r)
+	grantParams.Provider = "web3"
+
+	if err := db.Transaction(func(tx *storage.Connection) error {
+		nonce, terr := models.ConsumeWeb3Nonce(tx, message.Nonce)
+		if terr != nil {
+			if models.IsNotFoundError(terr) {
+				return oauthError("invalid request", "Nonce was not issued or has already been used")
+			}
+			return terr
+		}
+
+		if nonce.IsExpired(config.External.Web3.NonceExpiry) {
+			return oauthError("invalid request", "Nonce has expired")
+		}
+
+		user, terr := a.createAccountFromExternalIdentity(tx, r, userData, "web3")
+		if terr != nil {
+			return terr
+		}
+
+		token, terr = a.issueRefreshToken(r, tx, user, models.OAuth, grantParams)
+		return terr
+	}); err != nil {
+		switch err.(type) {
+		case *storage.CommitWithError:
+			return err
+		case *HTTPError, *OAuthError:
+			return err
+		default:
+			return oauthError("server_error", "Internal Server Error").WithInternalError(err)
+		}
+	}
+
+	return sendJSON(w, http.StatusOK, token)
+}
+
+// isAllowedWeb3Domain reports whether a Sign-In with Ethereum message issued
+// for the domain can be used to sign in.
+func (a *API) isAllowedWeb3Domain(domain string) bool {
+	config := a.config
+
+	allowed := config.External.Web3.AllowedDomains
+	if len(allowed) == 0 {
+		u, err := url.Parse(config.SiteURL)
+		if err != nil {
+			return false
+		}
+		allowed = []string{u.Host}
+	}
+
+	return isStringInSlice(domain, allowed)
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+	"golang.org/x/crypto/sha3"
+)
+
+type Web3TestSuite struct {
+	suite.Suite
+	API    *API
+	Config *conf.GlobalConfiguration
+
+	Key     *secp256k1.PrivateKey
+	Address string
+}
+
+func TestWeb3(t *testing.T) {
+	api, config, err := setupAPIForTest()
+	require.NoError(t, err)
+
+	ts := &Web3TestSuite{
+		API:    api,
+		Config: config,
+		// the well known address of the private key 1
+		Key:     secp256k1.PrivKeyFromBytes([]byte{1}),
+		Address: "0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf",
+	}
+	defer api.db.Close()
+
+	suite.Run(t, ts)
+}
+
+func (ts *Web3TestSuite) SetupTest() {
+	models.TruncateAll(ts.API.db)
+
+	ts.Config.External.Web3.Enabled = true
+	ts.Config.External.Web3.AllowedDomains = nil
+	ts.Config.External.Web3.AllowedChainIDs = nil
+}
+
+func (ts *Web3TestSuite) issueNonce() string {
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/web3/nonce", nil)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusOK, w.Code)
+
+	var data Web3NonceResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&data))
+	require.NotEmpty(ts.T(), data.Nonce)
+
+	return data.Nonce
+}
+
+func (ts *Web3TestSuite) message(domain string, chainID int, nonce string) string {
+	return fmt.Sprintf("%s wants you to sign in with your Ethereum account:\n%s\n\nSign in to Example\n\nURI: https://%s\nVersion: 1\nChain ID: %d\nNonce: %s\nIssued At: %s",
+		domain, ts.Address, domain, chainID, nonce, time.Now().UTC().Format(time.RFC3339))
+}
+
+func (ts *Web3TestSuite) sign(message string) string {
+	h := sha3.NewLegacyKeccak256()
+	h.Write([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)))
+	compact := ecdsa.SignCompact(ts.Key, h.Sum(nil), false)
+
+	sig := append(compact[1:], compact[0])
+	return "0x" + hex.EncodeToString(sig)
+}
+
+func (ts *Web3TestSuite) grant(message, signature string) *httptest.ResponseRecorder {
+	var buffer bytes.Buffer
+	require.NoError(ts.T(), json.NewEncoder(&buffer).Encode(map[string]interface{}{
+		"message":   message,
+		"signature": signature,
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/token?grant_type=web3", &buffer)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+
+	return w
+}
+
+func (ts *Web3TestSuite) TestSignIn() {
+	message := ts.message("example.netlify.com", 1, ts.issueNonce())
+	w := ts.grant(message, ts.sign(message))
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	var token AccessTokenResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&token))
+	require.NotEmpty(ts.T(), token.Token)
+
+	identity, err := models.FindIdentityByIdAndProvider(ts.API.db, "0x7e5f4552091a69125d5dfcb7b8c2659029395bdf", "web3")
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), ts.Address, identity.IdentityData["address"])
+	require.Equal(ts.T(), float64(1), identity.IdentityData["chain_id"])
+
+	// the same wallet signs in to the same user on another chain
+	message = ts.message("example.netlify.com", 137, ts.issueNonce())
+	w = ts.grant(message, ts.sign(message))
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	var second AccessTokenResponse
+	require.NoError(ts.T(), json.NewDecoder(w.Body).Decode(&second))
+	require.Equal(ts.T(), token.User.ID, second.User.ID)
+}
+
+func (ts *Web3TestSuite) TestNonceCanOnlyBeUsedOnce() {
+	message := ts.message("example.netlify.com", 1, ts.issueNonce())
+	signature := ts.sign(message)
+
+	w := ts.grant(message, signature)
+	require.Equal(ts.T(), http.StatusOK, w.Code, w.Body.String())
+
+	w = ts.grant(message, signature)
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+}
+
+func (ts *Web3TestSuite) TestUnknownNonce() {
+	message := ts.message("example.netlify.com", 1, "notissuedbythisserver")
+	w := ts.grant(message, ts.sign(message))
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+}
+
+func (ts *Web3TestSuite) TestOtherDomain() {
+	message := ts.message("evil.example.com", 1, ts.issueNonce())
+	w := ts.grant(message, ts.sign(message))
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+}
+
+func (ts *Web3TestSuite) TestUnsupportedChain() {
+	ts.Config.External.Web3.AllowedChainIDs = []int{1}
+
+	message := ts.message("example.netlify.com", 137, ts.issueNonce())
+	w := ts.grant(message, ts.sign(message))
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+}
+
+func (ts *Web3TestSuite) TestBadSignature() {
+	message := ts.message("example.netlify.com", 1, ts.issueNonce())
+	signature := ts.sign(ts.message("example.netlify.com", 1, "anothernonce123"))
+	w := ts.grant(message, signature)
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+}
+
+func (ts *Web3TestSuite) TestDisabled() {
+	ts.Config.External.Web3.Enabled = false
+
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/web3/nonce", nil)
+	w := httptest.NewRecorder()
+	ts.API.handler.ServeHTTP(w, req)
+	require.Equal(ts.T(), http.StatusBadRequest, w.Code)
+}
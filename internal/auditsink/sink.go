@@ -0,0 +1,87 @@
+// Package auditsink streams the audit log entries to external systems,
+// like SIEMs, in addition to the audit_log_entries table.
+package auditsink
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+)
+
+// Entry is the JSON schema of the audit log entries sent to the sinks.
+// The payload is the payload of the entry in the database, with the actor
+// and traits of the action.
+type Entry struct {
+	ID        uuid.UUID              `json:"id"`
+	CreatedAt time.Time              `json:"created_at"`
+	IPAddress string                 `json:"ip_address,omitempty"`
+	Action    interface{}            `json:"action"`
+	LogType   interface{}            `json:"log_type"`
+	ActorID   interface{}            `json:"actor_id"`
+	Payload   map[string]interface{} `json:"payload"`
+}
+
+// NewEntry returns the entry sent to the sinks for the audit log entry.
+func NewEntry(entry *models.AuditLogEntry) Entry {
+	return Entry{
+		ID:        entry.ID,
+		CreatedAt: entry.CreatedAt.UTC(),
+		IPAddress: entry.IPAddress,
+		Action:    entry.Payload["action"],
+		LogType:   entry.Payload["log_type"],
+		ActorID:   entry.Payload["actor_id"],
+		Payload:   entry.Payload,
+	}
+}
+
+// Sink receives the audit log entries, in the order they were created. An
+// error sends the entries again later.
+type Sink interface {
+	// Name identifies the sink, and its cursor in the database.
+	Name() string
+
+	Send(ctx context.Context, entries []Entry) error
+	Close() error
+}
+
+// NewSinks returns the enabled sinks.
+func NewSinks(config *conf.AuditLogConfiguration) ([]Sink, error) {
+	var sinks []Sink
+
+	if config.Webhook.Enabled {
+		sinks = append(sinks, NewWebhookSink(&config.Webhook))
+	}
+
+	if config.Kafka.Enabled {
+		sinks = append(sinks, NewKafkaSink(&config.Kafka))
+	}
+
+	if config.File.Enabled {
+		sink, err := NewFileSink(&config.File)
+		if err != nil {
+			closeSinks(sinks)
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if config.Syslog.Enabled {
+		sink, err := NewSyslogSink(&config.Syslog)
+		if err != nil {
+			closeSinks(sinks)
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	return sinks, nil
+}
+
+func closeSinks(sinks []Sink) {
+	for _, sink := range sinks {
+		_ = sink.Close()
+	}
+}
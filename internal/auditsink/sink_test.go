@@ -0,0 +1,132 @@
+package auditsink
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+)
+
+func testEntries(n int) []Entry {
+	entries := make([]Entry, n)
+	for i := range entries {
+		entries[i] = NewEntry(&models.AuditLogEntry{
+			ID:        uuid.Must(uuid.NewV4()),
+			CreatedAt: time.Now(),
+			Payload: models.JSONMap{
+				"action":   "login",
+				"log_type": "account",
+				"actor_id": "2c3b2a5e-7c4d-4f4e-9d2b-3b1f8c1e5a6d",
+			},
+		})
+	}
+	return entries
+}
+
+func TestNewEntry(t *testing.T) {
+	entry := testEntries(1)[0]
+
+	data, err := json.Marshal(entry)
+	require.NoError(t, err)
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, "login", decoded["action"])
+	assert.Equal(t, "account", decoded["log_type"])
+	assert.Equal(t, "2c3b2a5e-7c4d-4f4e-9d2b-3b1f8c1e5a6d", decoded["actor_id"])
+	assert.NotNil(t, decoded["payload"])
+}
+
+func TestWebhookSink(t *testing.T) {
+	var received struct {
+		Entries []Entry `json:"entries"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NotEmpty(t, r.Header.Get("webhook-id"))
+		assert.True(t, strings.HasPrefix(r.Header.Get("webhook-signature"), "v1,"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(&conf.AuditLogWebhookConfiguration{
+		URL:     server.URL,
+		Secrets: conf.HTTPHookSecrets{"v1,whsec_aWxpa2VzdXBhYmFzZXZlcnltdWNoYW5kaWhvcGV5b3Vkb3Rvbw=="},
+		Timeout: time.Second,
+	})
+
+	entries := testEntries(2)
+	require.NoError(t, sink.Send(context.Background(), entries))
+	require.Len(t, received.Entries, 2)
+	assert.Equal(t, entries[0].ID, received.Entries[0].ID)
+}
+
+func TestKafkaSink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/topics/auth-audit" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error_code":40401,"message":"Topic not found."}`))
+			return
+		}
+
+		assert.Equal(t, "application/vnd.kafka.json.v2+json", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Contains(t, string(body), `"records":[{"key":`)
+	}))
+	defer server.Close()
+
+	sink := NewKafkaSink(&conf.AuditLogKafkaConfiguration{
+		RESTProxyURL: server.URL + "/",
+		Topic:        "auth-audit",
+		Timeout:      time.Second,
+	})
+	require.NoError(t, sink.Send(context.Background(), testEntries(1)))
+
+	// errors of the proxy are returned, so the entries are sent again
+	sink.config.Topic = "unknown"
+	require.Error(t, sink.Send(context.Background(), testEntries(1)))
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.log")
+
+	sink, err := NewFileSink(&conf.AuditLogFileConfiguration{Path: path, MaxSizeMB: 1, MaxBackups: 2})
+	require.NoError(t, err)
+	defer sink.Close()
+
+	// rotates every few entries
+	sink.maxSize = 1000
+
+	for i := 0; i < 20; i++ {
+		require.NoError(t, sink.Send(context.Background(), testEntries(1)))
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, info.Size(), int64(1000))
+	}
+
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var entry Entry
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+	}
+}
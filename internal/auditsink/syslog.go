@@ -0,0 +1,47 @@
+package auditsink
+
+import (
+	"context"
+	"encoding/json"
+	"log/syslog"
+
+	"github.com/supabase/auth/internal/conf"
+)
+
+// SyslogSink sends the entries to a syslog server as JSON messages, with
+// the auth facility.
+type SyslogSink struct {
+	writer *syslog.Writer
+}
+
+func NewSyslogSink(config *conf.AuditLogSyslogConfiguration) (*SyslogSink, error) {
+	writer, err := syslog.Dial(config.Network, config.Address, syslog.LOG_INFO|syslog.LOG_AUTH, config.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SyslogSink{writer: writer}, nil
+}
+
+func (s *SyslogSink) Name() string {
+	return "syslog"
+}
+
+func (s *SyslogSink) Send(ctx context.Context, entries []Entry) error {
+	for _, entry := range entries {
+		message, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+
+		if err := s.writer.Info(string(message)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SyslogSink) Close() error {
+	return s.writer.Close()
+}
@@ -0,0 +1,87 @@
+package auditsink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/crypto"
+)
+
+// WebhookSink posts the entries to a URL as {"entries": [...]}, signed
+// with the secrets like the HTTP hooks (Standard Webhooks).
+type WebhookSink struct {
+	config *conf.AuditLogWebhookConfiguration
+	client *http.Client
+}
+
+func NewWebhookSink(config *conf.AuditLogWebhookConfiguration) *WebhookSink {
+	return &WebhookSink{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+}
+
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookSink) Send(ctx context.Context, entries []Entry) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"entries": entries,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if len(s.config.Secrets) > 0 {
+		msgID := uuid.Must(uuid.NewV4())
+		now := time.Now()
+
+		signatures, err := crypto.GenerateSignatures(s.config.Secrets, msgID, now, body)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("webhook-id", msgID.String())
+		req.Header.Set("webhook-timestamp", fmt.Sprintf("%d", now.Unix()))
+		req.Header.Set("webhook-signature", strings.Join(signatures, ", "))
+	}
+
+	return doSinkRequest(s.client, req)
+}
+
+func (s *WebhookSink) Close() error {
+	return nil
+}
+
+// doSinkRequest sends the request, and returns an error unless it's
+// answered with a 2xx status.
+func doSinkRequest(client *http.Client, req *http.Request) error {
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("auditsink: %s responded with status %d: %s", req.URL.Host, res.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	_, _ = io.Copy(io.Discard, res.Body)
+	return nil
+}
@@ -0,0 +1,129 @@
+package conf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// ClaimMapping maps the claims of an external provider to the user with
+// templates, which are executed with the claims as data. It's decoded from
+// JSON, for example:
+//
+//	{"name": "{{ .given_name }} {{ .family_name }}", "user_metadata": {"department": "{{ .custom_claims.department }}"}}
+type ClaimMapping struct {
+	Name         string            `json:"name,omitempty"`
+	AvatarURL    string            `json:"avatar_url,omitempty"`
+	UserMetadata map[string]string `json:"user_metadata,omitempty"`
+	AppMetadata  map[string]string `json:"app_metadata,omitempty"`
+
+	templates map[string]*template.Template
+}
+
+// MappedClaims is the result of applying a claim mapping. Metadata fields
+// whose template is empty are nil, so that they are removed.
+type MappedClaims struct {
+	Name         string
+	AvatarURL    string
+	UserMetadata map[string]interface{}
+	AppMetadata  map[string]interface{}
+}
+
+// Decode implements the Decoder interface
+func (m *ClaimMapping) Decode(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if err := json.Unmarshal([]byte(value), m); err != nil {
+		return fmt.Errorf("conf: claim mapping is not valid JSON: %w", err)
+	}
+
+	templates, err := m.parse()
+	if err != nil {
+		return err
+	}
+	m.templates = templates
+
+	return nil
+}
+
+// IsEmpty reports whether the mapping doesn't map any claim.
+func (m *ClaimMapping) IsEmpty() bool {
+	return m.Name == "" && m.AvatarURL == "" && len(m.UserMetadata) == 0 && len(m.AppMetadata) == 0
+}
+
+func (m *ClaimMapping) parse() (map[string]*template.Template, error) {
+	sources := map[string]string{}
+	if m.Name != "" {
+		sources["name"] = m.Name
+	}
+	if m.AvatarURL != "" {
+		sources["avatar_url"] = m.AvatarURL
+	}
+	for key, value := range m.UserMetadata {
+		sources["user_metadata."+key] = value
+	}
+	for key, value := range m.AppMetadata {
+		sources["app_metadata."+key] = value
+	}
+
+	templates := make(map[string]*template.Template, len(sources))
+	for key, source := range sources {
+		t, err := template.New(key).Option("missingkey=zero").Parse(source)
+		if err != nil {
+			return nil, fmt.Errorf("conf: claim mapping template for %q is invalid: %w", key, err)
+		}
+		templates[key] = t
+	}
+
+	return templates, nil
+}
+
+// Apply executes the templates of the mapping with the claims.
+func (m *ClaimMapping) Apply(claims map[string]interface{}) (*MappedClaims, error) {
+	templates := m.templates
+	if templates == nil {
+		var err error
+		if templates, err = m.parse(); err != nil {
+			return nil, err
+		}
+	}
+
+	mapped := &MappedClaims{
+		UserMetadata: make(map[string]interface{}, len(m.UserMetadata)),
+		AppMetadata:  make(map[string]interface{}, len(m.AppMetadata)),
+	}
+
+	for key, t := range templates {
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, claims); err != nil {
+			return nil, fmt.Errorf("claim mapping template for %q failed: %w", key, err)
+		}
+
+		// missing claims of the map are printed as <no value>
+		value := strings.TrimSpace(strings.ReplaceAll(buf.String(), "<no value>", ""))
+
+		switch {
+		case key == "name":
+			mapped.Name = value
+		case key == "avatar_url":
+			mapped.AvatarURL = value
+		case strings.HasPrefix(key, "user_metadata."):
+			mapped.UserMetadata[strings.TrimPrefix(key, "user_metadata.")] = valueOrNil(value)
+		case strings.HasPrefix(key, "app_metadata."):
+			mapped.AppMetadata[strings.TrimPrefix(key, "app_metadata.")] = valueOrNil(value)
+		}
+	}
+
+	return mapped, nil
+}
+
+func valueOrNil(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,59 @@
+package conf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimMapping(t *testing.T) {
+	var m ClaimMapping
+	require.NoError(t, m.Decode(`{
+		"name": "{{ .given_name }} {{ .family_name }}",
+		"avatar_url": "{{ .custom_claims.photo }}",
+		"user_metadata": {"department": "{{ .custom_claims.department }}", "phone": ""},
+		"app_metadata": {"plan": "{{ .custom_claims.plan | printf \"plan_%s\" }}", "team": "{{ .custom_claims.team }}"}
+	}`))
+	require.False(t, m.IsEmpty())
+
+	mapped, err := m.Apply(map[string]interface{}{
+		"given_name":  "Jane",
+		"family_name": "Doe",
+		"custom_claims": map[string]interface{}{
+			"photo":      "https://example.com/jane.png",
+			"department": "engineering",
+			"plan":       "pro",
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Jane Doe", mapped.Name)
+	assert.Equal(t, "https://example.com/jane.png", mapped.AvatarURL)
+	assert.Equal(t, map[string]interface{}{
+		"department": "engineering",
+		"phone":      nil,
+	}, mapped.UserMetadata)
+	assert.Equal(t, map[string]interface{}{
+		"plan": "plan_pro",
+		// missing claims map to nothing
+		"team": nil,
+	}, mapped.AppMetadata)
+}
+
+func TestClaimMappingDecode(t *testing.T) {
+	var m ClaimMapping
+	require.NoError(t, m.Decode(""))
+	require.True(t, m.IsEmpty())
+
+	require.Error(t, m.Decode(`{"name": `))
+	require.Error(t, m.Decode(`{"name": "{{ .given_name "}`))
+}
+
+func TestClaimMappingWithoutDecode(t *testing.T) {
+	m := ClaimMapping{Name: "{{ .preferred_username }}"}
+
+	mapped, err := m.Apply(map[string]interface{}{"preferred_username": "jane"})
+	require.NoError(t, err)
+	assert.Equal(t, "jane", mapped.Name)
+}
@@ -1,8 +1,16 @@
 package conf
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"net/netip"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -30,6 +38,76 @@ func TestGlobal(t *testing.T) {
 	assert.Equal(t, "pg-functions://postgres/auth/count_failed_attempts", gc.Hook.MFAVerificationAttempt.URI)
 }
 
+func TestLoadRemoteProviders(t *testing.T) {
+	t.Setenv("GOTRUE_EXTERNAL_ACME_ENABLED", "true")
+	t.Setenv("GOTRUE_EXTERNAL_ACME_CLIENT_ID", "acme-client")
+	t.Setenv("GOTRUE_EXTERNAL_ACME_SECRET", "acme-secret")
+	t.Setenv("GOTRUE_EXTERNAL_ACME_REDIRECT_URI", "http://localhost:9999/callback")
+	t.Setenv("GOTRUE_EXTERNAL_ACME_AUTH_URL", "https://sso.acme.test/authorize")
+	t.Setenv("GOTRUE_EXTERNAL_ACME_TOKEN_URL", "https://sso.acme.test/token")
+	t.Setenv("GOTRUE_EXTERNAL_ACME_SCOPES", "openid,profile")
+	t.Setenv("GOTRUE_EXTERNAL_ACME_DRIVER_URL", "https://driver.acme.test/user")
+
+	p := &ProviderConfiguration{RemoteProviders: []string{"Acme"}}
+	require.NoError(t, p.loadRemoteProviders())
+
+	acme, ok := p.Remote["acme"]
+	require.True(t, ok)
+	require.NoError(t, acme.Validate())
+	assert.Equal(t, []string{"acme-client"}, acme.ClientID)
+	assert.Equal(t, "https://sso.acme.test/authorize", acme.AuthURL)
+	assert.Equal(t, []string{"openid", "profile"}, acme.Scopes)
+
+	p = &ProviderConfiguration{RemoteProviders: []string{"acme-corp"}}
+	require.Error(t, p.loadRemoteProviders())
+}
+
+func TestProviderConfigurationOAuthProvider(t *testing.T) {
+	p := &ProviderConfiguration{
+		Google: GoogleProviderConfiguration{
+			OAuthProviderConfiguration: OAuthProviderConfiguration{
+				AllowedScopes: []string{"https://www.googleapis.com/auth/drive.readonly"},
+			},
+		},
+		Azure: AzureProviderConfiguration{
+			OAuthProviderConfiguration: OAuthProviderConfiguration{
+				ClientID: []string{"azure-client"},
+			},
+		},
+		Remote: map[string]RemoteProviderConfiguration{
+			"acme": {
+				OAuthProviderConfiguration: OAuthProviderConfiguration{
+					ClientID: []string{"acme-client"},
+				},
+			},
+		},
+	}
+
+	google, ok := p.OAuthProvider("google")
+	require.True(t, ok)
+	assert.True(t, google.IsScopeAllowed("https://www.googleapis.com/auth/drive.readonly"))
+	assert.False(t, google.IsScopeAllowed("https://www.googleapis.com/auth/drive"))
+
+	azure, ok := p.OAuthProvider("azure")
+	require.True(t, ok)
+	assert.Equal(t, []string{"azure-client"}, azure.ClientID)
+	// without an allowlist any scope can be requested
+	assert.True(t, azure.IsScopeAllowed("User.Read"))
+
+	acme, ok := p.OAuthProvider("acme")
+	require.True(t, ok)
+	assert.Equal(t, []string{"acme-client"}, acme.ClientID)
+
+	_, ok = p.OAuthProvider("email")
+	assert.False(t, ok)
+
+	_, ok = p.OAuthProvider("redirect_url")
+	assert.False(t, ok)
+
+	_, ok = p.OAuthProvider("unknown")
+	assert.False(t, ok)
+}
+
 func TestPasswordRequiredCharactersDecode(t *testing.T) {
 	examples := []struct {
 		Value  string
@@ -211,3 +289,578 @@ func TestValidateExtensibilityPointSecrets(t *testing.T) {
 	}
 
 }
+
+func TestHostedPagesPopulateFields(t *testing.T) {
+	c := &HostedPagesConfiguration{Enabled: true}
+	require.NoError(t, c.PopulateFields())
+	require.NotNil(t, c.ErrorTemplate)
+	require.NotNil(t, c.ConfirmationTemplate)
+	require.NotNil(t, c.InterstitialTemplate)
+
+	path := filepath.Join(t.TempDir(), "error.html")
+	require.NoError(t, os.WriteFile(path, []byte(`<html><head>{{ template "head" . }}</head><body>{{ .ErrorDescription }}</body></html>`), 0600))
+
+	c = &HostedPagesConfiguration{Enabled: true, ErrorTemplatePath: path}
+	require.NoError(t, c.PopulateFields())
+
+	var buf bytes.Buffer
+	require.NoError(t, c.ErrorTemplate.Execute(&buf, map[string]string{"SiteName": "Acme", "ErrorDescription": "<denied>"}))
+	assert.Contains(t, buf.String(), "<title>Acme</title>")
+	assert.Contains(t, buf.String(), "&lt;denied&gt;")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{{ .ErrorDescription `), 0600))
+	require.Error(t, c.PopulateFields())
+
+	c = &HostedPagesConfiguration{Enabled: true, ErrorTemplatePath: filepath.Join(t.TempDir(), "missing.html")}
+	require.Error(t, c.PopulateFields())
+}
+
+func TestMailerConfigurationValidate(t *testing.T) {
+	valid := []MailerConfiguration{
+		{},
+		{Provider: "smtp"},
+		{Provider: "sendgrid", SendGrid: SendGridConfiguration{APIKey: "SG.test", URL: "https://api.sendgrid.com"}},
+		{Provider: "ses", SES: SESConfiguration{Region: "us-east-1", AccessKeyID: "AKID", SecretAccessKey: "secret"}},
+		{Provider: "ses", SES: SESConfiguration{Region: "us-east-1", AccessKeyID: "AKID", SecretAccessKey: "secret", Endpoint: "https://vpce.email.us-east-1.vpce.amazonaws.com", Tags: map[string]string{"service": "auth"}}},
+		{Provider: "mailgun", Mailgun: MailgunConfiguration{Domain: "mg.example.com", APIKey: "key"}},
+		{Provider: "mailgun", Mailgun: MailgunConfiguration{Domain: "mg.example.com", APIKey: "key", Region: "eu"}},
+		{Provider: "postmark", Postmark: PostmarkConfiguration{ServerToken: "token"}},
+		{VerificationMethod: "both"},
+		{VerificationMethod: "code"},
+		{EmailChange: EmailChangeConfiguration{Confirmation: "new"}},
+		{EmailChange: EmailChangeConfiguration{Confirmation: "both", NotifyOld: true, UndoExpiry: 7 * 24 * time.Hour}},
+	}
+
+	for i, config := range valid {
+		assert.NoError(t, config.Validate(), "Example %d failed", i)
+	}
+
+	invalid := []MailerConfiguration{
+		{Provider: "mandrill"},
+		{VerificationMethod: "link"},
+		{EmailChange: EmailChangeConfiguration{Confirmation: "old"}},
+		{EmailChange: EmailChangeConfiguration{NotifyOld: true}},
+		{Provider: "postmark"},
+		{Provider: "mailgun"},
+		{Provider: "mailgun", Mailgun: MailgunConfiguration{Domain: "mg.example.com"}},
+		{Provider: "mailgun", Mailgun: MailgunConfiguration{Domain: "mg.example.com", APIKey: "key", Region: "ap"}},
+		{Provider: "ses"},
+		{Provider: "ses", SES: SESConfiguration{Region: "us-east-1", AccessKeyID: "AKID"}},
+		{Provider: "ses", SES: SESConfiguration{Region: "us-east-1", AccessKeyID: "AKID", SecretAccessKey: "secret", Endpoint: "http://localhost"}},
+		{Provider: "ses", SES: SESConfiguration{Region: "us-east-1", AccessKeyID: "AKID", SecretAccessKey: "secret", Tags: map[string]string{"service": "auth api"}}},
+		{Provider: "sendgrid", SendGrid: SendGridConfiguration{URL: "https://api.sendgrid.com"}},
+		{Provider: "sendgrid", SendGrid: SendGridConfiguration{APIKey: "SG.test", URL: "http://api.sendgrid.com"}},
+	}
+
+	for i, config := range invalid {
+		assert.Error(t, config.Validate(), "Example %d failed", i)
+	}
+}
+
+func TestSmsProviderConfigurationFailover(t *testing.T) {
+	var routes SmsRoutes
+	require.NoError(t, routes.Decode(`{"46": ["sinch", "twilio"], "4670": ["vonage"]}`))
+	require.Error(t, routes.Decode(`{"46": "sinch"}`))
+
+	config := SmsProviderConfiguration{
+		Provider:  "twilio",
+		Providers: []string{"twilio", "messagebird"},
+		Routes:    routes,
+	}
+	require.NoError(t, config.Validate())
+	require.True(t, config.IsFailoverEnabled())
+
+	assert.Equal(t, []string{"sinch", "twilio"}, config.ProvidersOf("46123456789"))
+	assert.Equal(t, []string{"vonage"}, config.ProvidersOf("+46701234567"))
+	assert.Equal(t, []string{"twilio", "messagebird"}, config.ProvidersOf("15551234567"))
+
+	single := SmsProviderConfiguration{Provider: "twilio"}
+	require.False(t, single.IsFailoverEnabled())
+	assert.Equal(t, []string{"twilio"}, single.ProvidersOf("15551234567"))
+
+	invalid := []SmsProviderConfiguration{
+		{Providers: []string{"twilio_verify"}},
+		{Providers: []string{"twilio", "carrier-pigeon"}},
+		{Providers: []string{"twilio", "twilio"}},
+		{Provider: "twilio_verify", Providers: []string{"twilio"}},
+		{Routes: SmsRoutes{"+46": {"sinch"}}},
+		{Routes: SmsRoutes{"46": {}}},
+	}
+
+	for i, config := range invalid {
+		assert.Error(t, config.Validate(), "Example %d failed", i)
+	}
+}
+
+func TestPhoneChangeConfigurationValidate(t *testing.T) {
+	valid := []SmsProviderConfiguration{
+		{Provider: "twilio"},
+		{Provider: "twilio", PhoneChange: PhoneChangeConfiguration{Confirmation: "new"}},
+		{Provider: "twilio", PhoneChange: PhoneChangeConfiguration{Confirmation: "both", NotifyOld: true, UndoExpiry: 7 * 24 * time.Hour}},
+		{Provider: "twilio_verify", PhoneChange: PhoneChangeConfiguration{Confirmation: "new"}},
+	}
+
+	for i, config := range valid {
+		assert.NoError(t, config.Validate(), "Example %d failed", i)
+	}
+
+	invalid := []SmsProviderConfiguration{
+		{Provider: "twilio", PhoneChange: PhoneChangeConfiguration{Confirmation: "old"}},
+		{Provider: "twilio", PhoneChange: PhoneChangeConfiguration{NotifyOld: true}},
+		{Provider: "twilio_verify", PhoneChange: PhoneChangeConfiguration{Confirmation: "both"}},
+		{Provider: "twilio_verify", PhoneChange: PhoneChangeConfiguration{NotifyOld: true, UndoExpiry: time.Hour}},
+	}
+
+	for i, config := range invalid {
+		assert.Error(t, config.Validate(), "Example %d failed", i)
+	}
+}
+
+func TestHookRetryConfigurationBackoff(t *testing.T) {
+	config := HookRetryConfiguration{
+		Enabled:        true,
+		MaxAttempts:    5,
+		InitialBackoff: 10 * time.Second,
+		MaxBackoff:     time.Minute,
+		Interval:       10 * time.Second,
+	}
+	require.NoError(t, config.Validate())
+
+	assert.Equal(t, 10*time.Second, config.Backoff(1))
+	assert.Equal(t, 20*time.Second, config.Backoff(2))
+	assert.Equal(t, 40*time.Second, config.Backoff(3))
+	assert.Equal(t, time.Minute, config.Backoff(4))
+	assert.Equal(t, time.Minute, config.Backoff(100))
+
+	config.MaxBackoff = time.Second
+	require.Error(t, config.Validate())
+}
+
+func TestEmailChangeConfirmationDefaults(t *testing.T) {
+	cases := []struct {
+		mailer                    MailerConfiguration
+		expectedConfirmation      string
+		expectedSecureEmailChange bool
+	}{
+		{
+			mailer:                    MailerConfiguration{SecureEmailChangeEnabled: true},
+			expectedConfirmation:      "both",
+			expectedSecureEmailChange: true,
+		},
+		{
+			mailer:                    MailerConfiguration{},
+			expectedConfirmation:      "new",
+			expectedSecureEmailChange: false,
+		},
+		{
+			// the confirmation takes precedence over secure email change
+			mailer:                    MailerConfiguration{SecureEmailChangeEnabled: true, EmailChange: EmailChangeConfiguration{Confirmation: "new"}},
+			expectedConfirmation:      "new",
+			expectedSecureEmailChange: false,
+		},
+		{
+			mailer:                    MailerConfiguration{EmailChange: EmailChangeConfiguration{Confirmation: "both"}},
+			expectedConfirmation:      "both",
+			expectedSecureEmailChange: true,
+		},
+	}
+
+	for i, c := range cases {
+		config := GlobalConfiguration{
+			JWT:    JWTConfiguration{Secret: "testsecret"},
+			Mailer: c.mailer,
+		}
+		require.NoError(t, config.ApplyDefaults())
+		assert.Equal(t, c.expectedConfirmation, config.Mailer.EmailChange.Confirmation, "Example %d failed", i)
+		assert.Equal(t, c.expectedSecureEmailChange, config.Mailer.SecureEmailChangeEnabled, "Example %d failed", i)
+	}
+}
+
+func TestOutboxConfigurationValidate(t *testing.T) {
+	valid := []OutboxConfiguration{
+		{},
+		{Enabled: true, Workers: 4, MaxAttempts: 5, InitialBackoff: 10 * time.Second, MaxBackoff: 10 * time.Minute, Interval: time.Second},
+		{Enabled: true, Workers: 1, MaxAttempts: 1, InitialBackoff: time.Second, MaxBackoff: time.Second, Interval: time.Second, RateLimits: map[string]float64{"smtp": 0.5}},
+	}
+
+	for i, config := range valid {
+		assert.NoError(t, config.Validate(), "Example %d failed", i)
+	}
+
+	invalid := []OutboxConfiguration{
+		{Enabled: true, MaxAttempts: 5, InitialBackoff: 10 * time.Second, MaxBackoff: 10 * time.Minute, Interval: time.Second},
+		{Enabled: true, Workers: 4, InitialBackoff: 10 * time.Second, MaxBackoff: 10 * time.Minute, Interval: time.Second},
+		{Enabled: true, Workers: 4, MaxAttempts: 5, InitialBackoff: 10 * time.Minute, MaxBackoff: 10 * time.Second, Interval: time.Second},
+		{Enabled: true, Workers: 4, MaxAttempts: 5, InitialBackoff: 10 * time.Second, MaxBackoff: 10 * time.Minute},
+		{Enabled: true, Workers: 4, MaxAttempts: 5, InitialBackoff: 10 * time.Second, MaxBackoff: 10 * time.Minute, Interval: time.Second, RateLimits: map[string]float64{"smtp": 0}},
+	}
+
+	for i, config := range invalid {
+		assert.Error(t, config.Validate(), "Example %d failed", i)
+	}
+}
+
+func TestEmailSuppressionConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&EmailSuppressionConfiguration{}).Validate())
+	assert.NoError(t, (&EmailSuppressionConfiguration{Enabled: true, WebhookSecret: "secret"}).Validate())
+	assert.Error(t, (&EmailSuppressionConfiguration{Enabled: true}).Validate())
+}
+
+func TestShortLinksConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&ShortLinksConfiguration{}).Validate())
+	assert.NoError(t, (&ShortLinksConfiguration{Enabled: true, SlugLength: 10, Expiry: 24 * time.Hour}).Validate())
+	assert.NoError(t, (&ShortLinksConfiguration{Enabled: true, BaseURL: "https://go.example.com", SlugLength: 8, Expiry: time.Hour}).Validate())
+	assert.Error(t, (&ShortLinksConfiguration{Enabled: true, BaseURL: "go.example.com", SlugLength: 10, Expiry: time.Hour}).Validate())
+	assert.Error(t, (&ShortLinksConfiguration{Enabled: true, SlugLength: 6, Expiry: time.Hour}).Validate())
+	assert.Error(t, (&ShortLinksConfiguration{Enabled: true, SlugLength: 10}).Validate())
+}
+
+func TestUserImportConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&UserImportConfiguration{}).Validate())
+	assert.NoError(t, (&UserImportConfiguration{Enabled: true, MaxRows: 100000, BatchSize: 500, Interval: 5 * time.Second}).Validate())
+	assert.Error(t, (&UserImportConfiguration{Enabled: true, BatchSize: 500, Interval: 5 * time.Second}).Validate())
+	assert.Error(t, (&UserImportConfiguration{Enabled: true, MaxRows: 100000, Interval: 5 * time.Second}).Validate())
+	assert.Error(t, (&UserImportConfiguration{Enabled: true, MaxRows: 100000, BatchSize: 500}).Validate())
+}
+
+func TestBulkInviteConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&BulkInviteConfiguration{}).Validate())
+	assert.NoError(t, (&BulkInviteConfiguration{Enabled: true, MaxInvitations: 1000, RetentionPeriod: 720 * time.Hour}).Validate())
+	assert.Error(t, (&BulkInviteConfiguration{Enabled: true, RetentionPeriod: 720 * time.Hour}).Validate())
+	assert.Error(t, (&BulkInviteConfiguration{Enabled: true, MaxInvitations: 1000}).Validate())
+}
+
+func TestUserDeletionConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&UserDeletionConfiguration{}).Validate())
+	assert.NoError(t, (&UserDeletionConfiguration{Enabled: true, RetentionPeriod: 720 * time.Hour, PurgeInterval: time.Hour}).Validate())
+	assert.Error(t, (&UserDeletionConfiguration{Enabled: true, PurgeInterval: time.Hour}).Validate())
+	assert.Error(t, (&UserDeletionConfiguration{Enabled: true, RetentionPeriod: 720 * time.Hour}).Validate())
+}
+
+func TestAccountDeletionConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&AccountDeletionConfiguration{}).Validate())
+	assert.NoError(t, (&AccountDeletionConfiguration{Enabled: true, GracePeriod: 30 * 24 * time.Hour, Interval: time.Hour}).Validate())
+	assert.NoError(t, (&AccountDeletionConfiguration{Enabled: true, Interval: time.Hour}).Validate())
+	assert.Error(t, (&AccountDeletionConfiguration{Enabled: true, GracePeriod: -time.Hour, Interval: time.Hour}).Validate())
+	assert.Error(t, (&AccountDeletionConfiguration{Enabled: true, GracePeriod: time.Hour}).Validate())
+}
+
+func TestDataExportConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&DataExportConfiguration{}).Validate())
+	assert.NoError(t, (&DataExportConfiguration{Enabled: true, Expiry: 168 * time.Hour, Interval: 5 * time.Second}).Validate())
+	assert.Error(t, (&DataExportConfiguration{Enabled: true, Interval: 5 * time.Second}).Validate())
+	assert.Error(t, (&DataExportConfiguration{Enabled: true, Expiry: 168 * time.Hour}).Validate())
+}
+
+func TestUsernameConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&UsernameConfiguration{}).Validate())
+	assert.NoError(t, (&UsernameConfiguration{Enabled: true, MinLength: 3, MaxLength: 32}).Validate())
+	assert.Error(t, (&UsernameConfiguration{Enabled: true, MaxLength: 32}).Validate())
+	assert.Error(t, (&UsernameConfiguration{Enabled: true, MinLength: 8, MaxLength: 4}).Validate())
+	assert.Error(t, (&UsernameConfiguration{Enabled: true, MinLength: 3, MaxLength: 256}).Validate())
+}
+
+func TestPasswordHistoryConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&PasswordHistoryConfiguration{}).Validate())
+	assert.NoError(t, (&PasswordHistoryConfiguration{Depth: 5, MinAge: 24 * time.Hour}).Validate())
+	assert.Error(t, (&PasswordHistoryConfiguration{Depth: -1}).Validate())
+	assert.Error(t, (&PasswordHistoryConfiguration{Depth: 25}).Validate())
+	assert.Error(t, (&PasswordHistoryConfiguration{Depth: 5, MinAge: -time.Hour}).Validate())
+	assert.Error(t, (&PasswordHistoryConfiguration{MinAge: time.Hour}).Validate())
+}
+
+func TestHIBPOfflineConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&HIBPOfflineConfiguration{}).Validate())
+	assert.NoError(t, (&HIBPOfflineConfiguration{Enabled: true, BloomFilter: "pwned.bloom"}).Validate())
+	assert.NoError(t, (&HIBPOfflineConfiguration{Enabled: true, RangesDir: "pwned"}).Validate())
+	assert.Error(t, (&HIBPOfflineConfiguration{Enabled: true}).Validate())
+}
+
+func TestPasswordStrengthConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&PasswordStrengthConfiguration{}).Validate())
+	assert.NoError(t, (&PasswordStrengthConfiguration{Enabled: true, MinScore: 4}).Validate())
+	assert.Error(t, (&PasswordStrengthConfiguration{Enabled: true, MinScore: -1}).Validate())
+	assert.Error(t, (&PasswordStrengthConfiguration{Enabled: true, MinScore: 5}).Validate())
+}
+
+func TestSecondaryEmailsConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&SecondaryEmailsConfiguration{}).Validate())
+	assert.NoError(t, (&SecondaryEmailsConfiguration{Enabled: true, MaxPerUser: 5}).Validate())
+	assert.Error(t, (&SecondaryEmailsConfiguration{Enabled: true}).Validate())
+}
+
+func TestSessionsConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&SessionsConfiguration{}).Validate())
+	assert.NoError(t, (&SessionsConfiguration{MaxPerUser: 3, MaxPerRole: map[string]int{"admin": 0}, LimitBehavior: SessionLimitReject}).Validate())
+	assert.Error(t, (&SessionsConfiguration{MaxPerUser: -1}).Validate())
+	assert.Error(t, (&SessionsConfiguration{MaxPerRole: map[string]int{"admin": -1}}).Validate())
+	assert.Error(t, (&SessionsConfiguration{MaxPerUser: 3, LimitBehavior: "evict_newest"}).Validate())
+}
+
+func TestSessionsConfigurationMaxSessions(t *testing.T) {
+	config := &SessionsConfiguration{
+		MaxPerUser: 2,
+		MaxPerRole: map[string]int{"team": 5, "pro": 3, "admin": 0},
+	}
+
+	assert.Equal(t, 2, config.MaxSessions(nil))
+	assert.Equal(t, 2, config.MaxSessions([]string{"billing"}))
+	assert.Equal(t, 3, config.MaxSessions([]string{"billing", "pro"}))
+	assert.Equal(t, 5, config.MaxSessions([]string{"pro", "team"}))
+	assert.Equal(t, 0, config.MaxSessions([]string{"team", "admin"}))
+}
+
+func TestSessionsConfigurationLifetime(t *testing.T) {
+	duration := func(d time.Duration) *time.Duration {
+		return &d
+	}
+
+	config := &SessionsConfiguration{
+		Timebox:                  duration(24 * time.Hour),
+		InactivityTimeout:        duration(time.Hour),
+		TimeboxPerRole:           map[string]time.Duration{"admin": 8 * time.Hour, "support": 12 * time.Hour},
+		InactivityTimeoutPerRole: map[string]time.Duration{"admin": 0},
+		TimeboxPerTag:            map[string]time.Duration{"kiosk": 30 * 24 * time.Hour},
+	}
+
+	timebox, inactivityTimeout := config.Lifetime(nil, nil)
+	assert.Equal(t, duration(24*time.Hour), timebox)
+	assert.Equal(t, duration(time.Hour), inactivityTimeout)
+
+	timebox, inactivityTimeout = config.Lifetime([]string{"support", "admin"}, nil)
+	assert.Equal(t, duration(8*time.Hour), timebox)
+	assert.Nil(t, inactivityTimeout)
+
+	timebox, inactivityTimeout = config.Lifetime(nil, []string{"kiosk"})
+	assert.Equal(t, duration(30*24*time.Hour), timebox)
+	assert.Equal(t, duration(time.Hour), inactivityTimeout)
+
+	timebox, _ = config.Lifetime([]string{"admin"}, []string{"kiosk"})
+	assert.Equal(t, duration(8*time.Hour), timebox)
+
+	// the cleanup keeps the sessions of the longest lifetimes
+	timebox, inactivityTimeout = config.LongestLifetime()
+	assert.Equal(t, duration(30*24*time.Hour), timebox)
+	assert.Nil(t, inactivityTimeout)
+
+	assert.Error(t, (&SessionsConfiguration{TimeboxPerTag: map[string]time.Duration{"kiosk": -time.Hour}}).Validate())
+}
+
+func TestSignInHistoryConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&SignInHistoryConfiguration{}).Validate())
+	assert.NoError(t, (&SignInHistoryConfiguration{Enabled: true, RetentionPeriod: 90 * 24 * time.Hour}).Validate())
+	assert.Error(t, (&SignInHistoryConfiguration{Enabled: true}).Validate())
+}
+
+func TestAccountLockoutConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&AccountLockoutConfiguration{}).Validate())
+	assert.NoError(t, (&AccountLockoutConfiguration{Enabled: true, MaxAttempts: 10, Window: 15 * time.Minute, Duration: 30 * time.Minute}).Validate())
+	assert.Error(t, (&AccountLockoutConfiguration{Enabled: true, Window: 15 * time.Minute, Duration: 30 * time.Minute}).Validate())
+	assert.Error(t, (&AccountLockoutConfiguration{Enabled: true, MaxAttempts: 10, Duration: 30 * time.Minute}).Validate())
+	assert.Error(t, (&AccountLockoutConfiguration{Enabled: true, MaxAttempts: 10, Window: 15 * time.Minute}).Validate())
+}
+
+func TestConsentConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&ConsentConfiguration{}).Validate())
+	assert.NoError(t, (&ConsentConfiguration{Enabled: true, TermsVersion: "2024-01"}).Validate())
+	assert.NoError(t, (&ConsentConfiguration{Enabled: true, PrivacyPolicyVersion: "v3", RequireAcceptance: true}).Validate())
+	assert.Error(t, (&ConsentConfiguration{Enabled: true}).Validate())
+}
+
+func TestGeoIPConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&GeoIPConfiguration{}).Validate())
+	assert.NoError(t, (&GeoIPConfiguration{Enabled: true, Database: "/etc/gotrue/geoip.csv"}).Validate())
+	assert.Error(t, (&GeoIPConfiguration{Enabled: true}).Validate())
+}
+
+func TestRevocationsConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&RevocationsConfiguration{}).Validate())
+	assert.NoError(t, (&RevocationsConfiguration{Enabled: true, URL: "redis://:secret@localhost:6379", Channel: "gotrue.revocations"}).Validate())
+	assert.NoError(t, (&RevocationsConfiguration{Enabled: true, URL: "nats://localhost:4222", Channel: "gotrue.revocations"}).Validate())
+	assert.Error(t, (&RevocationsConfiguration{Enabled: true, Channel: "gotrue.revocations"}).Validate())
+	assert.Error(t, (&RevocationsConfiguration{Enabled: true, URL: "amqp://localhost", Channel: "gotrue.revocations"}).Validate())
+	assert.Error(t, (&RevocationsConfiguration{Enabled: true, URL: "nats://localhost:4222", Channel: "gotrue revocations"}).Validate())
+}
+
+func TestRateLimitStoreConfigurationValidate(t *testing.T) {
+	valid := func() *RateLimitStoreConfiguration {
+		return &RateLimitStoreConfiguration{
+			Backend:   RateLimitStoreRedis,
+			URL:       "redis://:secret@localhost:6379/0",
+			Algorithm: RateLimitAlgorithmGCRA,
+			Timeout:   100 * time.Millisecond,
+		}
+	}
+
+	assert.NoError(t, (&RateLimitStoreConfiguration{}).Validate())
+	assert.NoError(t, (&RateLimitStoreConfiguration{Backend: RateLimitStoreMemory}).Validate())
+	assert.Error(t, (&RateLimitStoreConfiguration{Backend: "memcached"}).Validate())
+	assert.NoError(t, valid().Validate())
+
+	config := valid()
+	config.Algorithm = RateLimitAlgorithmSlidingWindow
+	assert.NoError(t, config.Validate())
+
+	config = valid()
+	config.URL = "nats://localhost:4222"
+	assert.Error(t, config.Validate())
+
+	config = valid()
+	config.Algorithm = "leaky_bucket"
+	assert.Error(t, config.Validate())
+
+	config = valid()
+	config.Timeout = 0
+	assert.Error(t, config.Validate())
+}
+
+func TestEndpointRateLimitsConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&EndpointRateLimitsConfiguration{}).Validate())
+
+	config := &EndpointRateLimitsConfiguration{
+		OTP:   EndpointRateLimitConfiguration{Requests: 30, Period: 5 * time.Minute, Burst: 10, Key: RateLimitKeyDestination},
+		Admin: EndpointRateLimitConfiguration{Requests: 100, Period: time.Minute, Key: RateLimitKeyUser},
+	}
+	assert.NoError(t, config.Validate())
+	assert.True(t, config.Group("otp").IsEnabled())
+	assert.False(t, config.Group("token").IsEnabled())
+	assert.Nil(t, config.Group("sso"))
+
+	config.Token = EndpointRateLimitConfiguration{Requests: 30}
+	assert.Error(t, config.Validate())
+
+	config.Token = EndpointRateLimitConfiguration{Requests: 30, Period: time.Minute, Key: "session"}
+	assert.Error(t, config.Validate())
+
+	config.Token = EndpointRateLimitConfiguration{Requests: -1, Period: time.Minute}
+	assert.Error(t, config.Validate())
+}
+
+func TestAPIConfigurationTrustedProxiesValidate(t *testing.T) {
+	assert.NoError(t, (&APIConfiguration{ExternalURL: "http://localhost:9999", TrustedProxies: []string{"10.0.0.0/8", "fd00::/8"}}).Validate())
+	assert.Error(t, (&APIConfiguration{ExternalURL: "http://localhost:9999", TrustedProxies: []string{"10.0.0.1"}}).Validate())
+}
+
+func TestIPFilterConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&IPFilterConfiguration{Global: IPFilterRulesConfiguration{Deny: []string{"invalid"}}}).Validate())
+
+	config := &IPFilterConfiguration{
+		Enabled: true,
+		Global:  IPFilterRulesConfiguration{Deny: []string{"198.51.100.7", "2001:db8::/32"}},
+		Admin:   IPFilterRulesConfiguration{Allow: []string{"10.0.0.0/8"}},
+	}
+	assert.NoError(t, config.Validate())
+	assert.True(t, config.Rules("token").IsEmpty())
+	assert.Nil(t, config.Rules("sso"))
+
+	allow, deny, err := config.Rules("global").Prefixes()
+	require.NoError(t, err)
+	assert.Empty(t, allow)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("198.51.100.7/32"), netip.MustParsePrefix("2001:db8::/32")}, deny)
+
+	config.OTP = IPFilterRulesConfiguration{Allow: []string{"10.0.0.0/33"}}
+	assert.Error(t, config.Validate())
+
+	config.OTP = IPFilterRulesConfiguration{Deny: []string{"example.com"}}
+	assert.Error(t, config.Validate())
+}
+
+func TestImpersonationConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&ImpersonationConfiguration{}).Validate())
+	assert.NoError(t, (&ImpersonationConfiguration{Enabled: true, SessionDuration: time.Hour}).Validate())
+	assert.Error(t, (&ImpersonationConfiguration{Enabled: true}).Validate())
+	assert.Error(t, (&ImpersonationConfiguration{Enabled: true, SessionDuration: 48 * time.Hour}).Validate())
+}
+
+func TestAnonymousProviderConfigurationValidate(t *testing.T) {
+	for _, mergeMetadata := range []string{AnonymousMergeExistingWins, AnonymousMergeAnonymousWins, AnonymousMergeDiscard} {
+		assert.NoError(t, (&AnonymousProviderConfiguration{MergeEnabled: true, MergeMetadata: mergeMetadata}).Validate())
+	}
+	assert.Error(t, (&AnonymousProviderConfiguration{MergeMetadata: "newest"}).Validate())
+}
+
+func TestAuditLogConfigurationValidate(t *testing.T) {
+	valid := func() *AuditLogConfiguration {
+		return &AuditLogConfiguration{
+			Interval:  time.Second,
+			BatchSize: 500,
+			Delay:     5 * time.Second,
+			Webhook:   AuditLogWebhookConfiguration{Enabled: true, URL: "https://siem.example.com/audit", Timeout: 10 * time.Second},
+			Kafka:     AuditLogKafkaConfiguration{Enabled: true, RESTProxyURL: "http://kafka-rest:8082", Topic: "audit", Timeout: 10 * time.Second},
+			File:      AuditLogFileConfiguration{Enabled: true, Path: "/var/log/auth/audit.log", MaxSizeMB: 100, MaxBackups: 5},
+			Syslog:    AuditLogSyslogConfiguration{Enabled: true, Network: "udp", Address: "syslog:514", Tag: "auth"},
+		}
+	}
+
+	assert.NoError(t, (&AuditLogConfiguration{}).Validate())
+	assert.NoError(t, (&AuditLogConfiguration{RetentionPeriod: 90 * 24 * time.Hour}).Validate())
+	assert.Error(t, (&AuditLogConfiguration{RetentionPeriod: -time.Hour}).Validate())
+	assert.NoError(t, valid().Validate())
+
+	invalid := []func(c *AuditLogConfiguration){
+		func(c *AuditLogConfiguration) { c.BatchSize = 0 },
+		func(c *AuditLogConfiguration) { c.Webhook.URL = "siem.example.com" },
+		func(c *AuditLogConfiguration) { c.Kafka.Topic = "" },
+		func(c *AuditLogConfiguration) { c.File.Path = "" },
+		func(c *AuditLogConfiguration) { c.Syslog.Network = "http" },
+		func(c *AuditLogConfiguration) { c.Syslog.Address = "" },
+	}
+	for _, invalidate := range invalid {
+		c := valid()
+		invalidate(c)
+		assert.Error(t, c.Validate())
+	}
+}
+
+func TestSMTPConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&SMTPConfiguration{}).Validate())
+	assert.NoError(t, (&SMTPConfiguration{TLSPolicy: SMTPTLSRequired, MaxConnections: 8, IdleTimeout: time.Minute}).Validate())
+	assert.Error(t, (&SMTPConfiguration{TLSPolicy: "none"}).Validate())
+	assert.Error(t, (&SMTPConfiguration{MaxConnections: -1}).Validate())
+	assert.Error(t, (&SMTPConfiguration{IdleTimeout: -time.Second}).Validate())
+
+	assert.Equal(t, SMTPTLSImplicit, (&SMTPConfiguration{Port: 465}).GetTLSPolicy())
+	assert.Equal(t, SMTPTLSOpportunistic, (&SMTPConfiguration{Port: 587}).GetTLSPolicy())
+	assert.Equal(t, SMTPTLSRequired, (&SMTPConfiguration{Port: 465, TLSPolicy: SMTPTLSRequired}).GetTLSPolicy())
+}
+
+func TestEmailProviderConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&EmailProviderConfiguration{BlockedDomains: []string{"mailinator.com"}}).Validate())
+	assert.NoError(t, (&EmailProviderConfiguration{BlockedDomainsSource: "/etc/auth/blocked.txt", BlockedDomainsRefreshInterval: time.Hour}).Validate())
+	assert.NoError(t, (&EmailProviderConfiguration{BlockedDomainsSource: "https://example.com/blocked.txt", BlockedDomainsRefreshInterval: time.Hour}).Validate())
+	assert.Error(t, (&EmailProviderConfiguration{BlockedDomainsSource: "ftp://example.com/blocked.txt", BlockedDomainsRefreshInterval: time.Hour}).Validate())
+	assert.Error(t, (&EmailProviderConfiguration{BlockedDomainsSource: "/etc/auth/blocked.txt"}).Validate())
+}
+
+func TestDKIMConfigurationValidate(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	encodedKey := base64.StdEncoding.EncodeToString(x509.MarshalPKCS1PrivateKey(privateKey))
+
+	assert.NoError(t, (&DKIMConfiguration{}).Validate())
+	assert.NoError(t, (&DKIMConfiguration{Domain: "example.com", Selector: "auth", PrivateKey: encodedKey}).Validate())
+	assert.Error(t, (&DKIMConfiguration{Domain: "example.com", PrivateKey: encodedKey}).Validate())
+	assert.Error(t, (&DKIMConfiguration{Domain: "example.com", Selector: "auth", PrivateKey: "not a key"}).Validate())
+
+	config := &DKIMConfiguration{Domain: "example.com", Selector: "auth", PrivateKey: encodedKey}
+	require.NoError(t, config.PopulateFields())
+	assert.Equal(t, privateKey.Public(), config.Signer.Public())
+}
+
+func TestMetadataConfigurationValidate(t *testing.T) {
+	assert.NoError(t, (&MetadataConfiguration{}).Validate())
+	assert.Error(t, (&MetadataConfiguration{MaxSize: -1}).Validate())
+	assert.Error(t, (&MetadataConfiguration{Schema: `{"type": "objet"}`}).Validate())
+	assert.Error(t, (&MetadataConfiguration{Schema: "/does/not/exist.json"}).Validate())
+
+	c := &MetadataConfiguration{Schema: `{"type": "object", "properties": {"plan": {"enum": ["free", "pro"]}}}`}
+	require.NoError(t, c.Validate())
+
+	schema, err := c.JSONSchema()
+	require.NoError(t, err)
+	require.NotNil(t, schema)
+
+	path := filepath.Join(t.TempDir(), "schema.json")
+	require.NoError(t, os.WriteFile(path, []byte(c.Schema), 0600))
+	assert.NoError(t, (&MetadataConfiguration{Schema: path}).Validate())
+}
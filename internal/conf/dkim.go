@@ -0,0 +1,92 @@
+package conf
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// DKIMConfiguration holds the key emails sent with SMTP are signed with, for
+// SMTP servers that relay them without signing.
+type DKIMConfiguration struct {
+	// Domain and Selector locate the public key in the DNS, at the TXT
+	// record of <selector>._domainkey.<domain>.
+	Domain   string `json:"domain"`
+	Selector string `json:"selector"`
+
+	// PrivateKey is the Base64 encoded PKCS#1 or PKCS#8 RSA private key, or
+	// PKCS#8 Ed25519 private key.
+	PrivateKey string `json:"-" split_words:"true"`
+
+	// Headers are the headers signed, when they're in the email.
+	Headers []string `json:"headers"`
+
+	Signer crypto.Signer `json:"-"`
+}
+
+// IsEnabled returns whether emails are signed.
+func (c *DKIMConfiguration) IsEnabled() bool {
+	return c.PrivateKey != ""
+}
+
+func (c *DKIMConfiguration) Validate() error {
+	if !c.IsEnabled() {
+		return nil
+	}
+
+	if c.Domain == "" || c.Selector == "" {
+		return errors.New("conf: DKIM requires a domain and a selector")
+	}
+
+	_, err := parseDKIMPrivateKey(c.PrivateKey)
+	return err
+}
+
+// PopulateFields parses the private key into the signer.
+func (c *DKIMConfiguration) PopulateFields() error {
+	signer, err := parseDKIMPrivateKey(c.PrivateKey)
+	if err != nil {
+		return err
+	}
+
+	c.Signer = signer
+	return nil
+}
+
+func parseDKIMPrivateKey(encodedKey string) (crypto.Signer, error) {
+	bytes, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, errors.New("conf: DKIM private key not in standard Base64 format")
+	}
+
+	if privateKey, err := x509.ParsePKCS1PrivateKey(bytes); err == nil {
+		return validateDKIMRSAPrivateKey(privateKey)
+	}
+
+	privateKey, err := x509.ParsePKCS8PrivateKey(bytes)
+	if err != nil {
+		return nil, errors.New("conf: DKIM private key not in PKCS#1 or PKCS#8 format")
+	}
+
+	switch privateKey := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return validateDKIMRSAPrivateKey(privateKey)
+	case ed25519.PrivateKey:
+		return privateKey, nil
+	}
+
+	return nil, fmt.Errorf("conf: DKIM private key of type %T is not supported, use RSA or Ed25519", privateKey)
+}
+
+func validateDKIMRSAPrivateKey(privateKey *rsa.PrivateKey) (crypto.Signer, error) {
+	// RFC 8301 forbids signing with keys shorter than 1024 bits
+	if privateKey.N.BitLen() < 1024 {
+		return nil, errors.New("conf: DKIM RSA private key must be at least 1024 bits")
+	}
+
+	return privateKey, nil
+}
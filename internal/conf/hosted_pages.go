@@ -0,0 +1,113 @@
+package conf
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+)
+
+// HostedPagesConfiguration holds the configuration of the HTML pages served
+// at the end of the external provider flow, instead of redirecting straight
+// back to the app with the result in the URL.
+type HostedPagesConfiguration struct {
+	Enabled bool `json:"enabled"`
+
+	// SiteName and LogoURL brand the default templates.
+	SiteName string `json:"site_name" split_words:"true"`
+	LogoURL  string `json:"logo_url" split_words:"true"`
+
+	// The paths of the html/template files replacing the default
+	// templates of the pages.
+	ErrorTemplatePath        string `json:"error_template_path" split_words:"true"`
+	ConfirmationTemplatePath string `json:"confirmation_template_path" split_words:"true"`
+	InterstitialTemplatePath string `json:"interstitial_template_path" split_words:"true"`
+
+	ErrorTemplate        *template.Template `json:"-"`
+	ConfirmationTemplate *template.Template `json:"-"`
+	InterstitialTemplate *template.Template `json:"-"`
+}
+
+// PopulateFields parses the templates of the pages.
+func (c *HostedPagesConfiguration) PopulateFields() error {
+	var err error
+
+	if c.ErrorTemplate, err = parseHostedPage("error", c.ErrorTemplatePath, defaultErrorPage); err != nil {
+		return err
+	}
+	if c.ConfirmationTemplate, err = parseHostedPage("confirmation", c.ConfirmationTemplatePath, defaultConfirmationPage); err != nil {
+		return err
+	}
+	if c.InterstitialTemplate, err = parseHostedPage("interstitial", c.InterstitialTemplatePath, defaultInterstitialPage); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func parseHostedPage(name, path, defaultPage string) (*template.Template, error) {
+	source := defaultPage
+	if path != "" {
+		bytes, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("conf: unable to read the %s page template: %w", name, err)
+		}
+		source = string(bytes)
+	}
+
+	t, err := template.New(name).Parse(defaultLayout)
+	if err != nil {
+		return nil, err
+	}
+	if t, err = t.Parse(source); err != nil {
+		return nil, fmt.Errorf("conf: the %s page template is invalid: %w", name, err)
+	}
+
+	return t, nil
+}
+
+// defaultLayout defines the head and header templates the default pages
+// use, which custom pages can use as well.
+const defaultLayout = `{{ define "head" }}<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{ if .SiteName }}{{ .SiteName }}{{ else }}Sign in{{ end }}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; max-width: 28rem; margin: 4rem auto; padding: 0 1rem; color: #1c1c1c; text-align: center; }
+img { max-height: 3rem; margin-bottom: 1rem; }
+a.button { display: inline-block; margin-top: 1rem; padding: 0.6rem 1.2rem; border-radius: 0.4rem; background: #1c1c1c; color: #fff; text-decoration: none; }
+p.code { color: #6b6b6b; font-size: 0.8rem; }
+</style>{{ end }}{{ define "header" }}{{ if .LogoURL }}<img src="{{ .LogoURL }}" alt="{{ .SiteName }}">{{ end }}{{ end }}`
+
+const defaultErrorPage = `<!DOCTYPE html>
+<html>
+<head>{{ template "head" . }}</head>
+<body>
+{{ template "header" . }}
+<h1>Unable to sign in</h1>
+<p>{{ .ErrorDescription }}</p>
+{{ if .ErrorCode }}<p class="code">Error code: {{ .ErrorCode }}</p>{{ end }}
+<a class="button" href="{{ .RedirectURL }}">Return to {{ if .SiteName }}{{ .SiteName }}{{ else }}the app{{ end }}</a>
+</body>
+</html>`
+
+const defaultConfirmationPage = `<!DOCTYPE html>
+<html>
+<head>{{ template "head" . }}</head>
+<body>
+{{ template "header" . }}
+<h1>Confirm your email address</h1>
+<p>{{ .ErrorDescription }}</p>
+<a class="button" href="{{ .RedirectURL }}">Return to {{ if .SiteName }}{{ .SiteName }}{{ else }}the app{{ end }}</a>
+</body>
+</html>`
+
+const defaultInterstitialPage = `<!DOCTYPE html>
+<html>
+<head>{{ template "head" . }}
+<meta http-equiv="refresh" content="1;url={{ .RedirectURL }}">
+</head>
+<body>
+{{ template "header" . }}
+<h1>You are signed in</h1>
+<a class="button" href="{{ .RedirectURL }}">Return to {{ if .SiteName }}{{ .SiteName }}{{ else }}the app{{ end }}</a>
+</body>
+</html>`
@@ -0,0 +1,94 @@
+package conf
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jcmturner/gokrb5/v8/keytab"
+)
+
+// KerberosConfiguration holds configuration for Kerberos single sign-on with
+// SPNEGO (HTTP Negotiate authentication), used by the browsers of
+// domain-joined machines to sign in without a password prompt.
+type KerberosConfiguration struct {
+	Enabled bool `json:"enabled"`
+
+	// Keytab is the Base64 encoded keytab holding the keys of the service
+	// principal, as exported with ktpass or kadmin.
+	Keytab string `json:"-"`
+
+	// ServicePrincipal is the principal clients request a ticket for,
+	// usually HTTP/<host of the API external URL>. When empty, the
+	// principal named in the ticket is used if the keytab holds its key.
+	ServicePrincipal string `json:"service_principal" split_words:"true"`
+
+	// EmailDomain, when set, gives each user the email address
+	// <username>@<email domain>. Otherwise users are created without an
+	// email address.
+	EmailDomain string `json:"email_domain" split_words:"true"`
+
+	// Realms are the realms whose users can sign in. Tickets of the users
+	// of realms trusted by the realm of the service are valid too, so it
+	// defaults to the realms of the principals in the keytab.
+	Realms []string `json:"realms"`
+
+	MaxClockSkew time.Duration `json:"max_clock_skew" split_words:"true" default:"5m"`
+
+	KeytabData *keytab.Keytab `json:"-"`
+}
+
+func (c *KerberosConfiguration) Validate() error {
+	if c.Enabled {
+		bytes, err := base64.StdEncoding.DecodeString(c.Keytab)
+		if err != nil {
+			return errors.New("Kerberos keytab not in standard Base64 format")
+		}
+
+		kt := keytab.New()
+		if err := kt.Unmarshal(bytes); err != nil {
+			return fmt.Errorf("Kerberos keytab is not valid: %w", err)
+		}
+
+		if len(kt.Entries) == 0 {
+			return errors.New("Kerberos keytab has no entries")
+		}
+
+		if c.MaxClockSkew < 0 {
+			return errors.New("Kerberos max clock skew should be a positive duration")
+		}
+	}
+
+	return nil
+}
+
+// AllowsRealm returns whether the users of the realm can sign in.
+func (c *KerberosConfiguration) AllowsRealm(realm string) bool {
+	realms := c.Realms
+	if len(realms) == 0 && c.KeytabData != nil {
+		for _, entry := range c.KeytabData.Entries {
+			realms = append(realms, entry.Principal.Realm)
+		}
+	}
+
+	for _, allowed := range realms {
+		if strings.EqualFold(allowed, realm) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PopulateFields parses the keytab. Errors are intentionally ignored since
+// they should have been handled within #Validate().
+func (c *KerberosConfiguration) PopulateFields() {
+	bytes, _ := base64.StdEncoding.DecodeString(c.Keytab)
+
+	kt := keytab.New()
+	_ = kt.Unmarshal(bytes)
+
+	c.KeytabData = kt
+}
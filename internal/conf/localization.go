@@ -0,0 +1,242 @@
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"golang.org/x/text/language"
+)
+
+// LocalizationConfiguration holds the configuration of the localized email
+// and SMS templates, which are sent in the locale of the user.
+type LocalizationConfiguration struct {
+	Enabled bool `json:"enabled"`
+
+	// DefaultLocale is the locale of users without a supported locale.
+	DefaultLocale string `json:"default_locale" split_words:"true" default:"en"`
+
+	// MetadataKey is the user_metadata key holding the locale of a user,
+	// which is set from the Accept-Language header on sign up.
+	MetadataKey string `json:"metadata_key" split_words:"true" default:"locale"`
+
+	// Path is the directory of the locale files, each named after its
+	// locale like fr.json or pt-BR.json.
+	Path string `json:"path"`
+
+	Locales map[string]*Locale `json:"-"`
+
+	tags    []language.Tag
+	matcher language.Matcher
+}
+
+// Locale holds the localized subjects, templates and SMS messages of a
+// locale. Empty ones fall back to the parent locale, like pt for pt-BR, then
+// to the default locale and finally to the mailer and SMS configuration.
+type Locale struct {
+	Subjects  EmailContentConfiguration `json:"subjects"`
+	Templates EmailContentConfiguration `json:"templates"`
+
+	SMS    string `json:"sms"`
+	MFASMS string `json:"mfa_sms"`
+
+	// SMSTemplates are the templates of each type of SMS message, which
+	// take precedence over SMS.
+	SMSTemplates SMSContentConfiguration `json:"sms_templates"`
+
+	SMSTemplate      *template.Template            `json:"-"`
+	MFASMSTemplate   *template.Template            `json:"-"`
+	SMSTypeTemplates map[string]*template.Template `json:"-"`
+}
+
+func (c *LocalizationConfiguration) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.Path == "" {
+		return errors.New("conf: localization path is required")
+	}
+
+	if c.MetadataKey == "" {
+		return errors.New("conf: localization metadata key is required")
+	}
+
+	if _, err := language.Parse(c.DefaultLocale); err != nil {
+		return fmt.Errorf("conf: localization default locale %q is not a valid language tag", c.DefaultLocale)
+	}
+
+	return nil
+}
+
+// PopulateFields reads and parses the locale files.
+func (c *LocalizationConfiguration) PopulateFields() error {
+	paths, err := filepath.Glob(filepath.Join(c.Path, "*.json"))
+	if err != nil {
+		return fmt.Errorf("conf: unable to list the locale files: %w", err)
+	}
+
+	c.Locales = make(map[string]*Locale, len(paths))
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+
+		tag, err := language.Parse(name)
+		if err != nil {
+			return fmt.Errorf("conf: locale file %q is not named after a valid language tag", path)
+		}
+
+		bytes, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("conf: unable to read the locale file %q: %w", path, err)
+		}
+
+		locale := &Locale{}
+		if err := json.Unmarshal(bytes, locale); err != nil {
+			return fmt.Errorf("conf: locale file %q is not valid JSON: %w", path, err)
+		}
+
+		if locale.SMS != "" {
+			if locale.SMSTemplate, err = template.New("").Parse(locale.SMS); err != nil {
+				return fmt.Errorf("conf: SMS template of locale %q is invalid: %w", name, err)
+			}
+		}
+
+		if locale.SMSTypeTemplates, err = parseSMSTemplates(locale.SMSTemplates); err != nil {
+			return fmt.Errorf("conf: SMS templates of locale %q are invalid: %w", name, err)
+		}
+
+		if locale.MFASMS != "" {
+			if locale.MFASMSTemplate, err = template.New("").Parse(locale.MFASMS); err != nil {
+				return fmt.Errorf("conf: MFA SMS template of locale %q is invalid: %w", name, err)
+			}
+		}
+
+		c.Locales[tag.String()] = locale
+	}
+
+	names := make([]string, 0, len(c.Locales))
+	for name := range c.Locales {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// the default locale is listed first so it's matched when nothing else
+	// is
+	defaultTag := language.MustParse(c.DefaultLocale)
+	c.DefaultLocale = defaultTag.String()
+	c.tags = []language.Tag{defaultTag}
+	for _, name := range names {
+		if tag := language.MustParse(name); tag != defaultTag {
+			c.tags = append(c.tags, tag)
+		}
+	}
+	c.matcher = language.NewMatcher(c.tags)
+
+	return nil
+}
+
+// UserLocale returns the supported locale closest to the locale in the user
+// metadata, or to the Accept-Language header when the metadata has none. It
+// returns an empty string when localization is not enabled.
+func (c *LocalizationConfiguration) UserLocale(metadata map[string]interface{}, acceptLanguage string) string {
+	if !c.Enabled || c.matcher == nil {
+		return ""
+	}
+
+	var preferred []language.Tag
+
+	if value, ok := metadata[c.MetadataKey].(string); ok && value != "" {
+		if tag, err := language.Parse(value); err == nil {
+			preferred = []language.Tag{tag}
+		}
+	}
+
+	if len(preferred) == 0 && acceptLanguage != "" {
+		preferred, _, _ = language.ParseAcceptLanguage(acceptLanguage)
+	}
+
+	_, index, confidence := c.matcher.Match(preferred...)
+	if confidence == language.No {
+		return c.tags[0].String()
+	}
+
+	return c.tags[index].String()
+}
+
+// chain returns the locales to look up the templates of the locale in, from
+// the locale itself to its parents and the default locale.
+func (c *LocalizationConfiguration) chain(name string) []*Locale {
+	var locales []*Locale
+
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if locale, ok := c.Locales[name]; ok && !seen[name] {
+			seen[name] = true
+			locales = append(locales, locale)
+		}
+	}
+
+	for name != "" {
+		add(name)
+
+		i := strings.LastIndex(name, "-")
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+
+	add(c.DefaultLocale)
+
+	return locales
+}
+
+// EmailContent returns the subject and template of an email in the locale,
+// as selected by field from the subjects and templates of the locales. They
+// are empty when no locale has one.
+func (c *LocalizationConfiguration) EmailContent(locale string, field func(*EmailContentConfiguration) string) (subject, template string) {
+	for _, l := range c.chain(locale) {
+		if subject == "" {
+			subject = field(&l.Subjects)
+		}
+		if template == "" {
+			template = field(&l.Templates)
+		}
+	}
+
+	return subject, template
+}
+
+// SMSTemplate returns the template of the type of SMS message in the locale,
+// or the SMS template of the locale when it has none for the type. It returns
+// nil when no locale has one.
+func (c *LocalizationConfiguration) SMSTemplate(locale, smsType string) *template.Template {
+	for _, l := range c.chain(locale) {
+		if t := l.SMSTypeTemplates[smsType]; t != nil {
+			return t
+		}
+		if l.SMSTemplate != nil {
+			return l.SMSTemplate
+		}
+	}
+
+	return nil
+}
+
+// MFASMSTemplate returns the MFA SMS template of the locale, or nil when no
+// locale has one.
+func (c *LocalizationConfiguration) MFASMSTemplate(locale string) *template.Template {
+	for _, l := range c.chain(locale) {
+		if l.MFASMSTemplate != nil {
+			return l.MFASMSTemplate
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package conf
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalizationConfiguration(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"en.json":    `{"subjects": {"recovery": "Reset Your Password"}}`,
+		"pt.json":    `{"subjects": {"recovery": "Redefina sua senha", "magic_link": "Seu link mágico"}, "sms": "Seu código é {{ .Code }}"}`,
+		"pt-BR.json": `{"subjects": {"recovery": "Redefina a sua senha"}, "templates": {"recovery": "https://example.com/pt-BR/recovery.html"}, "sms_templates": {"phone_change": "Confirme seu novo número com {{ .Code }}"}}`,
+		"fr.json":    `{"mfa_sms": "Votre code est {{ .Code }}"}`,
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+
+	c := &LocalizationConfiguration{
+		Enabled:       true,
+		DefaultLocale: "en",
+		MetadataKey:   "locale",
+		Path:          dir,
+	}
+	require.NoError(t, c.Validate())
+	require.NoError(t, c.PopulateFields())
+	require.Len(t, c.Locales, 4)
+
+	// the metadata takes precedence over the Accept-Language header
+	assert.Equal(t, "fr", c.UserLocale(map[string]interface{}{"locale": "fr"}, "pt-BR"))
+	assert.Equal(t, "pt-BR", c.UserLocale(map[string]interface{}{}, "pt-BR,pt;q=0.9"))
+	assert.Equal(t, "fr", c.UserLocale(nil, "fr-BE"))
+	assert.Equal(t, "fr", c.UserLocale(nil, "de;q=0.9,fr-CA;q=0.8"))
+	assert.Equal(t, "en", c.UserLocale(nil, "ja"))
+	assert.Equal(t, "en", c.UserLocale(map[string]interface{}{"locale": 1}, ""))
+
+	recovery := func(c *EmailContentConfiguration) string {
+		return c.Recovery
+	}
+	magicLink := func(c *EmailContentConfiguration) string {
+		return c.MagicLink
+	}
+
+	subject, template := c.EmailContent("pt-BR", recovery)
+	assert.Equal(t, "Redefina a sua senha", subject)
+	assert.Equal(t, "https://example.com/pt-BR/recovery.html", template)
+
+	// missing subjects and templates fall back to the parent and default
+	// locales
+	subject, template = c.EmailContent("pt-BR", magicLink)
+	assert.Equal(t, "Seu link mágico", subject)
+	assert.Empty(t, template)
+
+	subject, _ = c.EmailContent("fr", recovery)
+	assert.Equal(t, "Reset Your Password", subject)
+
+	var message bytes.Buffer
+	require.NotNil(t, c.SMSTemplate("pt-BR", SMSTypeSignup))
+	require.NoError(t, c.SMSTemplate("pt-BR", SMSTypeSignup).Execute(&message, map[string]string{"Code": "123456"}))
+	assert.Equal(t, "Seu código é 123456", message.String())
+
+	// the templates of each type take precedence
+	message.Reset()
+	require.NoError(t, c.SMSTemplate("pt-BR", SMSTypePhoneChange).Execute(&message, map[string]string{"Code": "123456"}))
+	assert.Equal(t, "Confirme seu novo número com 123456", message.String())
+
+	assert.Nil(t, c.SMSTemplate("fr", SMSTypeSignup))
+	assert.NotNil(t, c.MFASMSTemplate("fr"))
+	assert.Nil(t, c.MFASMSTemplate("pt"))
+
+	// localization is off unless enabled
+	c.Enabled = false
+	assert.Empty(t, c.UserLocale(map[string]interface{}{"locale": "fr"}, ""))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "invalid.json"), []byte(`{}`), 0600))
+	require.Error(t, c.PopulateFields())
+}
@@ -10,9 +10,28 @@ import (
 	"math/big"
 	"net"
 	"net/url"
+	"strings"
 	"time"
 )
 
+// samlNameIDFormats maps the short names of the NameID formats to their URIs.
+var samlNameIDFormats = map[string]string{
+	"persistent":   "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent",
+	"transient":    "urn:oasis:names:tc:SAML:2.0:nameid-format:transient",
+	"emailaddress": "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress",
+	"unspecified":  "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified",
+}
+
+// SAMLMaxClockSkewLimit is the largest clock skew that can be allowed when
+// validating SAML assertions, globally or by an SSO provider.
+const SAMLMaxClockSkewLimit = 10 * time.Minute
+
+// samlReplayCaches are the supported stores of seen assertion IDs.
+var samlReplayCaches = []string{"memory", "database"}
+
+// samlContactTypes are the contact types allowed by the SAML metadata schema.
+var samlContactTypes = []string{"technical", "support", "administrative", "billing", "other"}
+
 // SAMLConfiguration holds configuration for native SAML support.
 type SAMLConfiguration struct {
 	Enabled                  bool          `json:"enabled"`
@@ -24,38 +43,98 @@ type SAMLConfiguration struct {
 	RSAPublicKey  *rsa.PublicKey    `json:"-"`
 	Certificate   *x509.Certificate `json:"-"`
 
+	// NextPrivateKey is published in the metadata alongside PrivateKey,
+	// so that identity providers can pick it up ahead of a rotation.
+	// ActivateNextKey signs with it instead of PrivateKey. Assertions
+	// encrypted for either key are accepted while both are configured.
+	NextPrivateKey  string `json:"-" split_words:"true"`
+	ActivateNextKey bool   `json:"activate_next_key" split_words:"true"`
+
+	NextRSAPrivateKey *rsa.PrivateKey   `json:"-"`
+	NextCertificate   *x509.Certificate `json:"-"`
+
 	ExternalURL string `json:"external_url,omitempty" split_words:"true"`
 
+	// EntityID overrides the entity ID of the service provider, which
+	// defaults to the URL of the metadata endpoint.
+	EntityID string `json:"entity_id,omitempty" split_words:"true"`
+
+	// NameIDFormats lists the NameID formats advertised in the metadata,
+	// in order of preference. The first one is requested in AuthnRequests
+	// unless the SSO provider sets its own. Short names (persistent,
+	// emailAddress, transient, unspecified) or URIs are accepted.
+	NameIDFormats []string `json:"name_id_formats,omitempty" split_words:"true"`
+
+	// SignAuthnRequests signs the AuthnRequests sent to identity
+	// providers. AdvertiseSignedAuthnRequests publishes this in the
+	// metadata, for identity providers that require it to verify them.
+	SignAuthnRequests            bool `json:"sign_authn_requests" split_words:"true" default:"true"`
+	AdvertiseSignedAuthnRequests bool `json:"advertise_signed_authn_requests" split_words:"true"`
+
+	OrganizationName        string `json:"organization_name,omitempty" split_words:"true"`
+	OrganizationDisplayName string `json:"organization_display_name,omitempty" split_words:"true"`
+	OrganizationURL         string `json:"organization_url,omitempty" split_words:"true"`
+
+	ContactType    string `json:"contact_type,omitempty" split_words:"true" default:"technical"`
+	ContactCompany string `json:"contact_company,omitempty" split_words:"true"`
+	ContactName    string `json:"contact_name,omitempty" split_words:"true"`
+	ContactEmail   string `json:"contact_email,omitempty" split_words:"true"`
+
+	// MaxClockSkew is the leeway given to the clock of identity providers
+	// when validating the validity period of assertions. SSO providers can
+	// override it.
+	MaxClockSkew time.Duration `json:"max_clock_skew" split_words:"true" default:"3m"`
+
+	// ReplayWindow is the minimum time the ID of an accepted assertion is
+	// remembered to reject replays of it, which is extended to the end of
+	// the validity period of the assertion. SSO providers can override it.
+	// ReplayCache is "memory", which is local to each instance, or
+	// "database" for deployments with multiple instances.
+	ReplayWindow time.Duration `json:"replay_window" split_words:"true" default:"10m"`
+	ReplayCache  string        `json:"replay_cache" split_words:"true" default:"memory"`
+
 	RateLimitAssertion float64 `default:"15" split_words:"true"`
 }
 
 func (c *SAMLConfiguration) Validate() error {
 	if c.Enabled {
-		bytes, err := base64.StdEncoding.DecodeString(c.PrivateKey)
-		if err != nil {
-			return errors.New("SAML private key not in standard Base64 format")
+		if err := validateSAMLPrivateKey("SAML private key", c.PrivateKey); err != nil {
+			return err
 		}
 
-		privateKey, err := x509.ParsePKCS1PrivateKey(bytes)
-		if err != nil {
-			return errors.New("SAML private key not in PKCS#1 format")
+		if c.NextPrivateKey != "" {
+			if err := validateSAMLPrivateKey("SAML next private key", c.NextPrivateKey); err != nil {
+				return err
+			}
+
+			if c.NextPrivateKey == c.PrivateKey {
+				return errors.New("SAML next private key should be different from the private key")
+			}
+		} else if c.ActivateNextKey {
+			return errors.New("SAML next private key is required to activate it")
 		}
 
-		err = privateKey.Validate()
-		if err != nil {
-			return errors.New("SAML private key is not valid")
+		if c.RelayStateValidityPeriod < 0 {
+			return errors.New("SAML RelayState validity period should be a positive duration")
 		}
 
-		if privateKey.E != 0x10001 {
-			return errors.New("SAML private key should use the 65537 (0x10001) RSA public exponent")
+		if c.MaxClockSkew < 0 || c.MaxClockSkew > SAMLMaxClockSkewLimit {
+			return fmt.Errorf("SAML max clock skew should be between 0 and %s", SAMLMaxClockSkewLimit)
 		}
 
-		if privateKey.N.BitLen() < 2048 {
-			return errors.New("SAML private key must be at least RSA 2048")
+		if c.ReplayWindow < 0 {
+			return errors.New("SAML replay window should be a positive duration")
 		}
 
-		if c.RelayStateValidityPeriod < 0 {
-			return errors.New("SAML RelayState validity period should be a positive duration")
+		if c.ReplayCache != "" {
+			valid := false
+			for _, replayCache := range samlReplayCaches {
+				valid = valid || c.ReplayCache == replayCache
+			}
+
+			if !valid {
+				return fmt.Errorf("SAML replay cache should be one of %s", strings.Join(samlReplayCaches, ", "))
+			}
 		}
 
 		if c.ExternalURL != "" {
@@ -64,11 +143,87 @@ func (c *SAMLConfiguration) Validate() error {
 				return err
 			}
 		}
+
+		for _, format := range c.NameIDFormats {
+			if SAMLNameIDFormat(format) == "" {
+				return fmt.Errorf("SAML NameID format %q is not supported", format)
+			}
+		}
+
+		if (c.OrganizationName != "" || c.OrganizationDisplayName != "") && c.OrganizationURL == "" {
+			return errors.New("SAML organization URL is required with the organization name")
+		}
+
+		if c.OrganizationURL != "" {
+			if c.OrganizationName == "" {
+				return errors.New("SAML organization name is required with the organization URL")
+			}
+
+			if _, err := url.ParseRequestURI(c.OrganizationURL); err != nil {
+				return fmt.Errorf("SAML organization URL is not valid: %w", err)
+			}
+		}
+
+		if c.ContactType != "" {
+			valid := false
+			for _, contactType := range samlContactTypes {
+				valid = valid || c.ContactType == contactType
+			}
+
+			if !valid {
+				return fmt.Errorf("SAML contact type should be one of %s", strings.Join(samlContactTypes, ", "))
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateSAMLPrivateKey checks that the Base64 encoded PKCS#1 RSA private key
+// is usable for SAML.
+func validateSAMLPrivateKey(name, encodedKey string) error {
+	bytes, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return fmt.Errorf("%s not in standard Base64 format", name)
+	}
+
+	privateKey, err := x509.ParsePKCS1PrivateKey(bytes)
+	if err != nil {
+		return fmt.Errorf("%s not in PKCS#1 format", name)
+	}
+
+	err = privateKey.Validate()
+	if err != nil {
+		return fmt.Errorf("%s is not valid", name)
+	}
+
+	if privateKey.E != 0x10001 {
+		return fmt.Errorf("%s should use the 65537 (0x10001) RSA public exponent", name)
+	}
+
+	if privateKey.N.BitLen() < 2048 {
+		return fmt.Errorf("%s must be at least RSA 2048", name)
 	}
 
 	return nil
 }
 
+// SAMLNameIDFormat returns the URI of a NameID format given by its short name
+// or URI, or an empty string if it isn't supported.
+func SAMLNameIDFormat(format string) string {
+	if uri, ok := samlNameIDFormats[strings.ToLower(format)]; ok {
+		return uri
+	}
+
+	for _, uri := range samlNameIDFormats {
+		if uri == format {
+			return uri
+		}
+	}
+
+	return ""
+}
+
 // PopulateFields fills the configuration details based off the provided
 // parameters.
 func (c *SAMLConfiguration) PopulateFields(externalURL string) error {
@@ -91,6 +246,36 @@ func (c *SAMLConfiguration) PopulateFields(externalURL string) error {
 		host = parsedURL.Host
 	}
 
+	c.Certificate, err = c.certificate(privateKey, host)
+	if err != nil {
+		return err
+	}
+
+	if c.NextPrivateKey != "" {
+		bytes, _ := base64.StdEncoding.DecodeString(c.NextPrivateKey)
+		nextPrivateKey, _ := x509.ParsePKCS1PrivateKey(bytes)
+
+		c.NextRSAPrivateKey = nextPrivateKey
+
+		c.NextCertificate, err = c.certificate(nextPrivateKey, host)
+		if err != nil {
+			return err
+		}
+	} else {
+		c.NextRSAPrivateKey = nil
+		c.NextCertificate = nil
+	}
+
+	if c.RelayStateValidityPeriod == 0 {
+		c.RelayStateValidityPeriod = 2 * time.Minute
+	}
+
+	return nil
+}
+
+// certificate creates the self-signed certificate of the private key, which
+// only depends on the key and the host.
+func (c *SAMLConfiguration) certificate(privateKey *rsa.PrivateKey, host string) (*x509.Certificate, error) {
 	// SAML does not care much about the contents of the certificate, it
 	// only uses it as a vessel for the public key; therefore we set these
 	// fixed values.
@@ -116,21 +301,33 @@ func (c *SAMLConfiguration) PopulateFields(externalURL string) error {
 		certTemplate.KeyUsage = certTemplate.KeyUsage | x509.KeyUsageDataEncipherment
 	}
 
-	certDer, err := x509.CreateCertificate(nil, certTemplate, certTemplate, c.RSAPublicKey, c.RSAPrivateKey)
+	certDer, err := x509.CreateCertificate(nil, certTemplate, certTemplate, privateKey.Public(), privateKey)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	cert, err := x509.ParseCertificate(certDer)
-	if err != nil {
-		return err
+	return x509.ParseCertificate(certDer)
+}
+
+// ActiveKey returns the private key and certificate used for signing.
+func (c *SAMLConfiguration) ActiveKey() (*rsa.PrivateKey, *x509.Certificate) {
+	if c.ActivateNextKey && c.NextRSAPrivateKey != nil {
+		return c.NextRSAPrivateKey, c.NextCertificate
 	}
 
-	c.Certificate = cert
+	return c.RSAPrivateKey, c.Certificate
+}
 
-	if c.RelayStateValidityPeriod == 0 {
-		c.RelayStateValidityPeriod = 2 * time.Minute
+// InactiveKey returns the private key and certificate that are published and
+// used for decryption, but not for signing, while keys are being rotated.
+func (c *SAMLConfiguration) InactiveKey() (*rsa.PrivateKey, *x509.Certificate) {
+	if c.NextRSAPrivateKey == nil {
+		return nil, nil
 	}
 
-	return nil
+	if c.ActivateNextKey {
+		return c.RSAPrivateKey, c.Certificate
+	}
+
+	return c.NextRSAPrivateKey, c.NextCertificate
 }
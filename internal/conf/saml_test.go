@@ -4,6 +4,7 @@ import (
 	tst "testing"
 
 	"encoding/base64"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -64,6 +65,92 @@ func TestSAMLConfigurationValidate(t *tst.T) {
 		err := example.Validate()
 		require.NoError(t, err, "Valid example %d was regarded as invalid", i)
 	}
+
+	metadataExamples := []struct {
+		modify func(c *SAMLConfiguration)
+		valid  bool
+	}{
+		{
+			modify: func(c *SAMLConfiguration) {
+				c.NameIDFormats = []string{"emailAddress", "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent"}
+			},
+			valid: true,
+		},
+		{
+			modify: func(c *SAMLConfiguration) {
+				c.NameIDFormats = []string{"kerberos"}
+			},
+			valid: false,
+		},
+		{
+			modify: func(c *SAMLConfiguration) {
+				c.OrganizationName = "Example"
+				c.OrganizationURL = "https://example.com"
+			},
+			valid: true,
+		},
+		{
+			modify: func(c *SAMLConfiguration) {
+				c.OrganizationName = "Example"
+			},
+			valid: false,
+		},
+		{
+			modify: func(c *SAMLConfiguration) {
+				c.ContactType = "sales"
+			},
+			valid: false,
+		},
+		{
+			modify: func(c *SAMLConfiguration) {
+				c.MaxClockSkew = 5 * time.Minute
+				c.ReplayWindow = time.Hour
+				c.ReplayCache = "database"
+			},
+			valid: true,
+		},
+		{
+			modify: func(c *SAMLConfiguration) {
+				c.MaxClockSkew = time.Hour
+			},
+			valid: false,
+		},
+		{
+			modify: func(c *SAMLConfiguration) {
+				c.ReplayCache = "redis"
+			},
+			valid: false,
+		},
+		{
+			modify: func(c *SAMLConfiguration) {
+				c.ActivateNextKey = true
+			},
+			valid: false,
+		},
+		{
+			modify: func(c *SAMLConfiguration) {
+				c.NextPrivateKey = c.PrivateKey
+			},
+			valid: false,
+		},
+		{
+			modify: func(c *SAMLConfiguration) {
+				c.NextPrivateKey = base64.StdEncoding.EncodeToString([]byte("not PKCS#1"))
+			},
+			valid: false,
+		},
+	}
+
+	for i, example := range metadataExamples {
+		c := *validExamples[1]
+		example.modify(&c)
+
+		if example.valid {
+			require.NoError(t, c.Validate(), "Valid metadata example %d was regarded as invalid", i)
+		} else {
+			require.Error(t, c.Validate(), "Invalid metadata example %d was regarded as valid", i)
+		}
+	}
 }
 
 func TestSAMLConfigurationPopulateFields(t *tst.T) {
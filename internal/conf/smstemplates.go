@@ -0,0 +1,116 @@
+package conf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode/utf16"
+)
+
+// The types of the SMS messages with their own templates.
+const (
+	SMSTypeSignup           = "signup"
+	SMSTypeReauthentication = "reauthentication"
+	SMSTypePhoneChange      = "phone_change"
+)
+
+// SMSContentConfiguration holds the templates of each type of SMS message,
+// which take precedence over the SMS template.
+type SMSContentConfiguration struct {
+	Signup           string `json:"signup"`
+	Reauthentication string `json:"reauthentication"`
+	PhoneChange      string `json:"phone_change" split_words:"true"`
+}
+
+func (c *SMSContentConfiguration) byType() map[string]string {
+	return map[string]string{
+		SMSTypeSignup:           c.Signup,
+		SMSTypeReauthentication: c.Reauthentication,
+		SMSTypePhoneChange:      c.PhoneChange,
+	}
+}
+
+// parseSMSTemplates parses the templates of the SMS types that have one.
+func parseSMSTemplates(content SMSContentConfiguration) (map[string]*template.Template, error) {
+	templates := make(map[string]*template.Template)
+
+	for smsType, source := range content.byType() {
+		if source == "" {
+			continue
+		}
+
+		t, err := template.New("").Parse(source)
+		if err != nil {
+			return nil, fmt.Errorf("conf: %s SMS template is invalid: %w", smsType, err)
+		}
+		templates[smsType] = t
+	}
+
+	return templates, nil
+}
+
+// gsm7Basic and gsm7Extension are the characters of the GSM 03.38 default
+// alphabet and its extension table, whose characters take two septets.
+const (
+	gsm7Basic     = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	gsm7Extension = "\f^{}\\[~]|€"
+)
+
+// SMSSegments returns how many segments the message is sent in, and whether
+// it's encoded with the GSM 7-bit alphabet rather than UCS-2, which fits
+// fewer characters per segment and is filtered by some carriers.
+func SMSSegments(message string) (int, bool) {
+	septets := 0
+	gsm := true
+	for _, r := range message {
+		switch {
+		case strings.ContainsRune(gsm7Basic, r):
+			septets += 1
+		case strings.ContainsRune(gsm7Extension, r):
+			septets += 2
+		default:
+			gsm = false
+		}
+	}
+
+	// concatenated messages lose room to the header of each part
+	if gsm {
+		if septets <= 160 {
+			return 1, true
+		}
+		return (septets + 152) / 153, true
+	}
+
+	units := len(utf16.Encode([]rune(message)))
+	if units <= 70 {
+		return 1, false
+	}
+	return (units + 66) / 67, false
+}
+
+// validateSMSTemplate checks the messages the template renders fit in the
+// maximum number of segments, and use the GSM 7-bit alphabet when it's
+// required.
+func validateSMSTemplate(name string, t *template.Template, otpLength, maxSegments int, requireGSM bool) error {
+	if t == nil || (maxSegments <= 0 && !requireGSM) {
+		return nil
+	}
+
+	var message bytes.Buffer
+	if err := t.Execute(&message, struct {
+		Code string
+	}{Code: strings.Repeat("0", otpLength)}); err != nil {
+		return fmt.Errorf("conf: %s SMS template can't be rendered: %w", name, err)
+	}
+
+	segments, gsm := SMSSegments(message.String())
+	if requireGSM && !gsm {
+		return fmt.Errorf("conf: %s SMS template has characters outside of the GSM 7-bit alphabet", name)
+	}
+	if maxSegments > 0 && segments > maxSegments {
+		return fmt.Errorf("conf: %s SMS template is sent in %d segments, more than the maximum of %d", name, segments, maxSegments)
+	}
+
+	return nil
+}
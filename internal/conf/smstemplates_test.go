@@ -0,0 +1,64 @@
+package conf
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSMSSegments(t *testing.T) {
+	cases := []struct {
+		message  string
+		segments int
+		gsm      bool
+	}{
+		{message: "Your code is 123456", segments: 1, gsm: true},
+		{message: strings.Repeat("a", 160), segments: 1, gsm: true},
+		{message: strings.Repeat("a", 161), segments: 2, gsm: true},
+		// the characters of the extension table take two septets
+		{message: strings.Repeat("€", 80), segments: 1, gsm: true},
+		{message: strings.Repeat("€", 81), segments: 2, gsm: true},
+		{message: "Seu código é 123456", segments: 1, gsm: false},
+		{message: strings.Repeat("ç", 70), segments: 1, gsm: false},
+		{message: strings.Repeat("ç", 71), segments: 2, gsm: false},
+	}
+
+	for _, c := range cases {
+		segments, gsm := SMSSegments(c.message)
+		assert.Equal(t, c.segments, segments, c.message)
+		assert.Equal(t, c.gsm, gsm, c.message)
+	}
+}
+
+func TestSMSTemplateValidation(t *testing.T) {
+	c := &SmsProviderConfiguration{
+		OtpLength:           6,
+		TemplateMaxSegments: 1,
+		TemplateRequireGSM:  true,
+		Templates: SMSContentConfiguration{
+			Signup: "Your code is {{ .Code }}",
+		},
+	}
+
+	var err error
+	c.SMSTemplate = template.Must(template.New("").Parse("Your code is {{ .Code }}"))
+	c.TypeTemplates, err = parseSMSTemplates(c.Templates)
+	require.NoError(t, err)
+	require.NoError(t, c.validateTemplates(&LocalizationConfiguration{}))
+
+	localization := &LocalizationConfiguration{
+		Locales: map[string]*Locale{
+			"pt": {SMSTemplate: template.Must(template.New("").Parse("Seu código é {{ .Code }}"))},
+		},
+	}
+	require.EqualError(t, c.validateTemplates(localization), "conf: pt SMS template has characters outside of the GSM 7-bit alphabet")
+
+	c.TemplateRequireGSM = false
+	require.NoError(t, c.validateTemplates(localization))
+
+	c.TypeTemplates[SMSTypeSignup] = template.Must(template.New("").Parse(strings.Repeat("a", 160) + "{{ .Code }}"))
+	require.EqualError(t, c.validateTemplates(localization), "conf: signup SMS template is sent in 2 segments, more than the maximum of 1")
+}
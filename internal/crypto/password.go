@@ -16,6 +16,7 @@ import (
 
 	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
 )
 
 type HashCost = int
@@ -31,6 +32,7 @@ const (
 	QuickHashCost HashCost = iota
 
 	Argon2Prefix = "$argon2"
+	ScryptPrefix = "$scrypt"
 )
 
 // PasswordHashCost is the current pasword hashing cost
@@ -49,6 +51,7 @@ var (
 )
 
 var ErrArgon2MismatchedHashAndPassword = errors.New("crypto: argon2 hash and password mismatch")
+var ErrScryptMismatchedHashAndPassword = errors.New("crypto: scrypt hash and password mismatch")
 
 // argon2HashRegexp https://github.com/P-H-C/phc-string-format/blob/master/phc-sf-spec.md#argon2-encoding
 var argon2HashRegexp = regexp.MustCompile("^[$](?P<alg>argon2(d|i|id))[$]v=(?P<v>(16|19))[$]m=(?P<m>[0-9]+),t=(?P<t>[0-9]+),p=(?P<p>[0-9]+)(,keyid=(?P<keyid>[^,]+))?(,data=(?P<data>[^$]+))?[$](?P<salt>[^$]+)[$](?P<hash>.+)$")
@@ -172,6 +175,99 @@ func compareHashAndPasswordArgon2(ctx context.Context, hash, password string) er
 	return nil
 }
 
+// scryptHashRegexp https://github.com/P-H-C/phc-string-format/blob/master/phc-sf-spec.md
+var scryptHashRegexp = regexp.MustCompile("^[$]scrypt[$]ln=(?P<ln>[0-9]+),r=(?P<r>[0-9]+),p=(?P<p>[0-9]+)[$](?P<salt>[^$]+)[$](?P<hash>.+)$")
+
+type ScryptHashInput struct {
+	logN    uint64
+	r       uint64
+	p       uint64
+	salt    []byte
+	rawHash []byte
+}
+
+func ParseScryptHash(hash string) (*ScryptHashInput, error) {
+	submatch := scryptHashRegexp.FindStringSubmatchIndex(hash)
+
+	if submatch == nil {
+		return nil, errors.New("crypto: incorrect scrypt hash format")
+	}
+
+	ln := string(scryptHashRegexp.ExpandString(nil, "$ln", hash, submatch))
+	r := string(scryptHashRegexp.ExpandString(nil, "$r", hash, submatch))
+	p := string(scryptHashRegexp.ExpandString(nil, "$p", hash, submatch))
+	saltB64 := string(scryptHashRegexp.ExpandString(nil, "$salt", hash, submatch))
+	hashB64 := string(scryptHashRegexp.ExpandString(nil, "$hash", hash, submatch))
+
+	logN, err := strconv.ParseUint(ln, 10, 8)
+	if err != nil || logN < 1 || logN > 31 {
+		return nil, fmt.Errorf("crypto: scrypt hash has invalid ln parameter %q", ln)
+	}
+
+	blockSize, err := strconv.ParseUint(r, 10, 32)
+	if err != nil || blockSize < 1 {
+		return nil, fmt.Errorf("crypto: scrypt hash has invalid r parameter %q", r)
+	}
+
+	parallelism, err := strconv.ParseUint(p, 10, 32)
+	if err != nil || parallelism < 1 {
+		return nil, fmt.Errorf("crypto: scrypt hash has invalid p parameter %q", p)
+	}
+
+	rawHash, err := base64.RawStdEncoding.DecodeString(hashB64)
+	if err != nil {
+		return nil, fmt.Errorf("crypto: scrypt hash has invalid base64 in the hash section %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(saltB64)
+	if err != nil {
+		return nil, fmt.Errorf("crypto: scrypt hash has invalid base64 in the salt section %w", err)
+	}
+
+	input := ScryptHashInput{logN, blockSize, parallelism, salt, rawHash}
+
+	return &input, nil
+}
+
+func compareHashAndPasswordScrypt(ctx context.Context, hash, password string) error {
+	input, err := ParseScryptHash(hash)
+	if err != nil {
+		return err
+	}
+
+	attributes := []attribute.KeyValue{
+		attribute.String("alg", "scrypt"),
+		attribute.Int64("ln", int64(input.logN)),
+		attribute.Int64("r", int64(input.r)),
+		attribute.Int64("p", int64(input.p)),
+		attribute.Int("len", len(input.rawHash)),
+	} // #nosec G115
+
+	var match bool
+	compareHashAndPasswordSubmittedCounter.Add(ctx, 1, metric.WithAttributes(attributes...))
+	defer func() {
+		attributes = append(attributes, attribute.Bool(
+			"match",
+			match,
+		))
+
+		compareHashAndPasswordCompletedCounter.Add(ctx, 1, metric.WithAttributes(attributes...))
+	}()
+
+	derivedKey, err := scrypt.Key([]byte(password), input.salt, 1<<input.logN, int(input.r), int(input.p), len(input.rawHash)) // #nosec G115
+	if err != nil {
+		return err
+	}
+
+	match = subtle.ConstantTimeCompare(derivedKey, input.rawHash) == 1
+
+	if !match {
+		return ErrScryptMismatchedHashAndPassword
+	}
+
+	return nil
+}
+
 // CompareHashAndPassword compares the hash and
 // password, returns nil if equal otherwise an error. Context can be used to
 // cancel the hashing if the algorithm supports it.
@@ -180,6 +276,10 @@ func CompareHashAndPassword(ctx context.Context, hash, password string) error {
 		return compareHashAndPasswordArgon2(ctx, hash, password)
 	}
 
+	if strings.HasPrefix(hash, ScryptPrefix) {
+		return compareHashAndPasswordScrypt(ctx, hash, password)
+	}
+
 	// assume bcrypt
 	hashCost, err := bcrypt.Cost([]byte(hash))
 	if err != nil {
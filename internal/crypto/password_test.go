@@ -19,3 +19,26 @@ func TestArgon2(t *testing.T) {
 		assert.NoError(t, CompareHashAndPassword(context.Background(), example, "test"))
 	}
 }
+
+func TestScrypt(t *testing.T) {
+	// all of these hash the `test` string with various parameters
+
+	examples := []string{
+		"$scrypt$ln=4,r=8,p=1$c2FsdHNhbHRzYWx0MTIzNA$rjO6aQzjY3c5qDVznWPv3DSr4oE+mSkzevvJcn1T+Fo",
+		"$scrypt$ln=6,r=4,p=2$c2FsdHNhbHRzYWx0MTIzNA$9mTPm7MdER61j5Eb8IIHxI0A2o9P6O9a0SV+pPiW7wwubHDDV4Ym6GVsqpgFlWAj5RIxLoJVDzlaVCfCgX3GLQ",
+	}
+
+	for _, example := range examples {
+		assert.NoError(t, CompareHashAndPassword(context.Background(), example, "test"))
+		assert.ErrorIs(t, CompareHashAndPassword(context.Background(), example, "wrong"), ErrScryptMismatchedHashAndPassword)
+	}
+
+	for _, invalid := range []string{
+		"$scrypt$ln=0,r=8,p=1$c2FsdA$aGFzaA",
+		"$scrypt$ln=4,r=8$c2FsdA$aGFzaA",
+		"$scrypt$ln=4,r=8,p=1$c2FsdA",
+	} {
+		_, err := ParseScryptHash(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
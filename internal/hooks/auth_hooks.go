@@ -1,6 +1,8 @@
 package hooks
 
 import (
+	"time"
+
 	"github.com/gofrs/uuid"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/supabase/auth/internal/mailer"
@@ -36,6 +38,11 @@ type HookOutput interface {
 type SMS struct {
 	OTP     string `json:"otp,omitempty"`
 	SMSType string `json:"sms_type,omitempty"`
+
+	// Phone is the number the message is sent to, when it isn't the phone
+	// of the user, like the old number of the user after a phone change.
+	Phone   string `json:"phone,omitempty"`
+	UndoURL string `json:"undo_url,omitempty"`
 }
 
 // #nosec
@@ -92,6 +99,24 @@ const MinimumViableTokenSchema = `{
     },
     "session_id": {
       "type": "string"
+    },
+    "organizations": {
+      "type": "array",
+      "items": {
+        "type": "object"
+      }
+    },
+    "roles": {
+      "type": "array",
+      "items": {
+        "type": "string"
+      }
+    },
+    "permissions": {
+      "type": "array",
+      "items": {
+        "type": "string"
+      }
     }
   },
   "required": ["aud", "exp", "iat", "sub", "email", "phone", "role", "aal", "session_id", "is_anonymous"]
@@ -109,6 +134,13 @@ type AccessTokenClaims struct {
 	AuthenticationMethodReference []models.AMREntry      `json:"amr,omitempty"`
 	SessionId                     string                 `json:"session_id,omitempty"`
 	IsAnonymous                   bool                   `json:"is_anonymous"`
+	Actor                         *models.ActorClaim     `json:"act,omitempty"`
+	PasswordExpired               bool                   `json:"password_expired,omitempty"`
+	ConsentRequired               bool                   `json:"consent_required,omitempty"`
+
+	Organizations []models.OrganizationMembership `json:"organizations,omitempty"`
+	Roles         []string                        `json:"roles,omitempty"`
+	Permissions   []string                        `json:"permissions,omitempty"`
 }
 
 type MFAVerificationAttemptInput struct {
@@ -165,6 +197,26 @@ type SendEmailOutput struct {
 	HookError AuthHookError `json:"error,omitempty"`
 }
 
+// The events of the account deletion hook.
+const (
+	AccountDeletionRequested = "requested"
+	AccountDeletionCancelled = "cancelled"
+	AccountDeletionCompleted = "completed"
+)
+
+// AccountDeletionInput is sent when a user requests the deletion of their
+// account, cancels it, and when the account is deleted once the grace
+// period is over.
+type AccountDeletionInput struct {
+	Event    string       `json:"event"`
+	User     *models.User `json:"user"`
+	DeleteAt time.Time    `json:"delete_at"`
+}
+
+type AccountDeletionOutput struct {
+	HookError AuthHookError `json:"error,omitempty"`
+}
+
 func (mf *MFAVerificationAttemptOutput) IsError() bool {
 	return mf.HookError.Message != ""
 }
@@ -205,6 +257,14 @@ func (cs *SendEmailOutput) Error() string {
 	return cs.HookError.Message
 }
 
+func (ad *AccountDeletionOutput) IsError() bool {
+	return ad.HookError.Message != ""
+}
+
+func (ad *AccountDeletionOutput) Error() string {
+	return ad.HookError.Message
+}
+
 type AuthHookError struct {
 	HTTPCode int    `json:"http_code,omitempty"`
 	Message  string `json:"message,omitempty"`
@@ -0,0 +1,182 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/supabase/auth/internal/conf"
+)
+
+// dkimDefaultHeaders are the headers signed when none are configured.
+var dkimDefaultHeaders = []string{
+	"From",
+	"Reply-To",
+	"To",
+	"Cc",
+	"Subject",
+	"Date",
+	"Message-ID",
+	"MIME-Version",
+	"Content-Type",
+	"Content-Transfer-Encoding",
+}
+
+var dkimWhitespace = regexp.MustCompile(`[ \t]+`)
+
+// dkimSigner signs emails with DKIM (RFC 6376), with the relaxed header
+// and body canonicalizations.
+type dkimSigner struct {
+	domain   string
+	selector string
+	signer   crypto.Signer
+	headers  []string
+
+	now func() time.Time
+}
+
+func newDKIMSigner(config *conf.DKIMConfiguration) *dkimSigner {
+	headers := config.Headers
+	if len(headers) == 0 {
+		headers = dkimDefaultHeaders
+	}
+
+	return &dkimSigner{
+		domain:   config.Domain,
+		selector: config.Selector,
+		signer:   config.Signer,
+		headers:  headers,
+		now:      time.Now,
+	}
+}
+
+// sign returns the email with the DKIM-Signature header added before its
+// headers.
+func (s *dkimSigner) sign(message []byte) ([]byte, error) {
+	header, body, ok := bytes.Cut(message, []byte("\r\n\r\n"))
+	if !ok {
+		return nil, errors.New("dkim: email has no body")
+	}
+
+	bodyHash := sha256.Sum256(dkimCanonicalBody(body))
+
+	algorithm := "rsa-sha256"
+	if _, ok := s.signer.(ed25519.PrivateKey); ok {
+		algorithm = "ed25519-sha256"
+	}
+
+	// the headers are signed from the last instance of each, as receivers
+	// verify them in that order
+	fields := dkimHeaderFields(string(header))
+	used := make(map[int]bool)
+
+	var signedNames []string
+	hash := sha256.New()
+	for _, name := range s.headers {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if used[i] || !strings.EqualFold(dkimFieldName(fields[i]), name) {
+				continue
+			}
+
+			used[i] = true
+			signedNames = append(signedNames, strings.ToLower(name))
+			hash.Write([]byte(dkimCanonicalHeader(fields[i]) + "\r\n"))
+			break
+		}
+	}
+
+	signature := "DKIM-Signature: v=1; a=" + algorithm + "; c=relaxed/relaxed;\r\n" +
+		"\td=" + s.domain + "; s=" + s.selector + "; t=" + strconv.FormatInt(s.now().Unix(), 10) + ";\r\n" +
+		"\th=" + strings.Join(signedNames, ":") + ";\r\n" +
+		"\tbh=" + base64.StdEncoding.EncodeToString(bodyHash[:]) + ";\r\n" +
+		"\tb="
+
+	// the signature header is signed without its value, and without the
+	// line break that ends it
+	hash.Write([]byte(dkimCanonicalHeader(signature)))
+	digest := hash.Sum(nil)
+
+	var value []byte
+	var err error
+	if algorithm == "ed25519-sha256" {
+		// RFC 8463 signs the hash with PureEdDSA
+		value, err = s.signer.Sign(rand.Reader, digest, crypto.Hash(0))
+	} else {
+		value, err = s.signer.Sign(rand.Reader, digest, crypto.SHA256)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var signed bytes.Buffer
+	signed.WriteString(signature)
+
+	encoded := base64.StdEncoding.EncodeToString(value)
+	for len(encoded) > 72 {
+		signed.WriteString(encoded[:72] + "\r\n\t  ")
+		encoded = encoded[72:]
+	}
+	signed.WriteString(encoded + "\r\n")
+	signed.Write(message)
+
+	return signed.Bytes(), nil
+}
+
+// dkimHeaderFields splits the header into its fields, with their folded
+// lines.
+func dkimHeaderFields(header string) []string {
+	var fields []string
+	for _, line := range strings.Split(header, "\r\n") {
+		if len(fields) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			fields[len(fields)-1] += "\r\n" + line
+			continue
+		}
+
+		fields = append(fields, line)
+	}
+
+	return fields
+}
+
+func dkimFieldName(field string) string {
+	name, _, _ := strings.Cut(field, ":")
+	return strings.TrimRight(name, " \t")
+}
+
+// dkimCanonicalHeader canonicalizes the header field with the relaxed
+// algorithm.
+func dkimCanonicalHeader(field string) string {
+	name, value, _ := strings.Cut(field, ":")
+
+	value = strings.ReplaceAll(value, "\r\n", "")
+	value = dkimWhitespace.ReplaceAllString(value, " ")
+
+	return strings.ToLower(strings.TrimRight(name, " \t")) + ":" + strings.Trim(value, " \t")
+}
+
+// dkimCanonicalBody canonicalizes the body with the relaxed algorithm.
+func dkimCanonicalBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(dkimWhitespace.ReplaceAllString(line, " "), " ")
+	}
+
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
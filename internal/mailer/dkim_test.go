@@ -0,0 +1,148 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+	"gopkg.in/gomail.v2"
+)
+
+func TestDKIMCanonicalization(t *testing.T) {
+	// the examples of RFC 6376 section 3.4.5
+	var header []string
+	for _, field := range dkimHeaderFields("A: X\r\nB : Y\t\r\n\tZ  ") {
+		header = append(header, dkimCanonicalHeader(field))
+	}
+	assert.Equal(t, []string{"a:X", "b:Y Z"}, header)
+
+	assert.Equal(t, " C\r\nD E\r\n", string(dkimCanonicalBody([]byte(" C \r\nD \t E\r\n\r\n\r\n"))))
+	assert.Empty(t, dkimCanonicalBody([]byte("\r\n\r\n")))
+}
+
+// verifyDKIM checks the DKIM-Signature header the signed email starts with.
+func verifyDKIM(t *testing.T, signed []byte, publicKey crypto.PublicKey) map[string]string {
+	header, body, ok := bytes.Cut(signed, []byte("\r\n\r\n"))
+	require.True(t, ok)
+
+	fields := dkimHeaderFields(string(header))
+	require.True(t, strings.HasPrefix(fields[0], "DKIM-Signature:"))
+
+	tags := make(map[string]string)
+	_, value, _ := strings.Cut(dkimCanonicalHeader(fields[0]), ":")
+	for _, tag := range strings.Split(value, ";") {
+		name, value, _ := strings.Cut(tag, "=")
+		tags[strings.TrimSpace(name)] = strings.Join(strings.Fields(value), "")
+	}
+
+	bodyHash := sha256.Sum256(dkimCanonicalBody(body))
+	assert.Equal(t, base64.StdEncoding.EncodeToString(bodyHash[:]), tags["bh"])
+
+	hash := sha256.New()
+	for _, name := range strings.Split(tags["h"], ":") {
+		for i := len(fields) - 1; i > 0; i-- {
+			if strings.EqualFold(dkimFieldName(fields[i]), name) {
+				hash.Write([]byte(dkimCanonicalHeader(fields[i]) + "\r\n"))
+				fields = append(fields[:i], fields[i+1:]...)
+				break
+			}
+		}
+	}
+	unsigned := regexp.MustCompile(`b=[A-Za-z0-9+/=\s]+$`).ReplaceAllString(fields[0], "b=")
+	hash.Write([]byte(dkimCanonicalHeader(unsigned)))
+
+	signature, err := base64.StdEncoding.DecodeString(tags["b"])
+	require.NoError(t, err)
+
+	switch publicKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		assert.NoError(t, rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hash.Sum(nil), signature))
+	case ed25519.PublicKey:
+		assert.True(t, ed25519.Verify(publicKey, hash.Sum(nil), signature))
+	}
+
+	return tags
+}
+
+func newTestDKIMMessage(t *testing.T) []byte {
+	message := gomail.NewMessage()
+	message.SetHeader("From", "Example <auth@example.com>")
+	message.SetHeader("To", "user@example.com")
+	message.SetHeader("Subject", "Confirm   your email")
+	message.SetBody("text/html", "<p>Follow this link to confirm your email:</p>  \n<p><a href=\"https://example.com/verify\">Confirm</a></p>\n\n")
+
+	var buffer bytes.Buffer
+	_, err := message.WriteTo(&buffer)
+	require.NoError(t, err)
+
+	return buffer.Bytes()
+}
+
+func TestDKIMSignerRSA(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	config := &conf.DKIMConfiguration{
+		Domain:     "example.com",
+		Selector:   "auth",
+		PrivateKey: base64.StdEncoding.EncodeToString(x509.MarshalPKCS1PrivateKey(privateKey)),
+	}
+	require.NoError(t, config.Validate())
+	require.NoError(t, config.PopulateFields())
+
+	signer := newDKIMSigner(config)
+	signer.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	message := newTestDKIMMessage(t)
+
+	signed, err := signer.sign(message)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasSuffix(signed, message))
+
+	tags := verifyDKIM(t, signed, &privateKey.PublicKey)
+	assert.Equal(t, "rsa-sha256", tags["a"])
+	assert.Equal(t, "relaxed/relaxed", tags["c"])
+	assert.Equal(t, "example.com", tags["d"])
+	assert.Equal(t, "auth", tags["s"])
+	assert.Equal(t, "1700000000", tags["t"])
+	assert.Equal(t, "from:to:subject:date:mime-version:content-type:content-transfer-encoding", tags["h"])
+
+	for _, line := range strings.Split(string(signed), "\r\n") {
+		assert.LessOrEqual(t, len(line), 78)
+	}
+}
+
+func TestDKIMSignerEd25519(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	encoded, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+
+	config := &conf.DKIMConfiguration{
+		Domain:     "example.com",
+		Selector:   "auth",
+		PrivateKey: base64.StdEncoding.EncodeToString(encoded),
+		Headers:    []string{"From", "Subject"},
+	}
+	require.NoError(t, config.PopulateFields())
+
+	signed, err := newDKIMSigner(config).sign(newTestDKIMMessage(t))
+	require.NoError(t, err)
+
+	tags := verifyDKIM(t, signed, publicKey)
+	assert.Equal(t, "ed25519-sha256", tags["a"])
+	assert.Equal(t, "from:subject", tags["h"])
+}
@@ -4,12 +4,12 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/gofrs/uuid"
 	"github.com/sirupsen/logrus"
 	"github.com/supabase/auth/internal/conf"
 	"github.com/supabase/auth/internal/models"
-	"github.com/supabase/mailme"
 	"gopkg.in/gomail.v2"
 )
 
@@ -21,8 +21,14 @@ type Mailer interface {
 	MagicLinkMail(r *http.Request, user *models.User, otp, referrerURL string, externalURL *url.URL) error
 	EmailChangeMail(r *http.Request, user *models.User, otpNew, otpCurrent, referrerURL string, externalURL *url.URL) error
 	ReauthenticateMail(r *http.Request, user *models.User, otp string) error
+	EmailChangedMail(r *http.Request, user *models.User, oldEmail, undoTokenHash string, externalURL *url.URL) error
+	AccountDeletionMail(r *http.Request, user *models.User, deleteAt time.Time, cancelTokenHash string, externalURL *url.URL) error
+	SecondaryEmailMail(r *http.Request, user *models.User, email, otp string) error
+	AccountLockedMail(r *http.Request, user *models.User, lockedUntil time.Time, unlockTokenHash string, externalURL *url.URL) error
 	ValidateEmail(email string) error
 	GetEmailActionLink(user *models.User, actionType, referrerURL string, externalURL *url.URL) (string, error)
+	RenderTemplate(r *http.Request, templateName string, data map[string]interface{}, externalURL *url.URL) (*RenderedEmail, error)
+	SendTestMail(r *http.Request, templateName, to string, data map[string]interface{}, externalURL *url.URL) error
 }
 
 type EmailParams struct {
@@ -39,10 +45,23 @@ type EmailData struct {
 	SiteURL         string `json:"site_url"`
 	TokenNew        string `json:"token_new"`
 	TokenHashNew    string `json:"token_hash_new"`
+	OldEmail        string `json:"old_email,omitempty"`
+	DeleteAt        string `json:"delete_at,omitempty"`
+	LockedUntil     string `json:"locked_until,omitempty"`
+	Email           string `json:"email,omitempty"`
 }
 
 // NewMailer returns a new gotrue mailer
 func NewMailer(globalConfig *conf.GlobalConfiguration) Mailer {
+	return &TemplateMailer{
+		SiteURL: globalConfig.SiteURL,
+		Config:  globalConfig,
+		Mailer:  NewMailClient(globalConfig),
+	}
+}
+
+// NewMailClient returns the client of the configured mailer provider.
+func NewMailClient(globalConfig *conf.GlobalConfiguration) MailClient {
 	mail := gomail.NewMessage()
 
 	mail.SetHeaders(map[string][]string{
@@ -56,27 +75,22 @@ func NewMailer(globalConfig *conf.GlobalConfiguration) Mailer {
 	u, _ := url.ParseRequestURI(globalConfig.API.ExternalURL)
 
 	var mailClient MailClient
-	if globalConfig.SMTP.Host == "" {
+	if globalConfig.Mailer.Provider == "sendgrid" {
+		mailClient = newSendGridMailClient(globalConfig)
+	} else if globalConfig.Mailer.Provider == "ses" {
+		mailClient = newSESMailClient(globalConfig)
+	} else if globalConfig.Mailer.Provider == "mailgun" {
+		mailClient = newMailgunMailClient(globalConfig)
+	} else if globalConfig.Mailer.Provider == "postmark" {
+		mailClient = newPostmarkMailClient(globalConfig)
+	} else if globalConfig.SMTP.Host == "" {
 		logrus.Infof("Noop mail client being used for %v", globalConfig.SiteURL)
 		mailClient = &noopMailClient{}
 	} else {
-		mailClient = &mailme.Mailer{
-			Host:      globalConfig.SMTP.Host,
-			Port:      globalConfig.SMTP.Port,
-			User:      globalConfig.SMTP.User,
-			Pass:      globalConfig.SMTP.Pass,
-			LocalName: u.Hostname(),
-			From:      from,
-			BaseURL:   globalConfig.SiteURL,
-			Logger:    logrus.StandardLogger(),
-		}
+		mailClient = newSMTPMailClient(globalConfig, from, u.Hostname())
 	}
 
-	return &TemplateMailer{
-		SiteURL: globalConfig.SiteURL,
-		Config:  globalConfig,
-		Mailer:  mailClient,
-	}
+	return mailClient
 }
 
 func withDefault(value, defaultValue string) string {
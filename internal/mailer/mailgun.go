@@ -0,0 +1,164 @@
+package mailer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/supabase/auth/internal/conf"
+)
+
+// mailgunTimeout limits how long sending an email with Mailgun can take.
+const mailgunTimeout = 10 * time.Second
+
+// mailgunMailClient sends emails with the Mailgun messages API. Emails with a
+// Mailgun template are rendered by Mailgun with the template variables, the
+// others are rendered like with SMTP.
+type mailgunMailClient struct {
+	config     *conf.MailgunConfiguration
+	baseURL    string
+	from       string
+	templates  *templateRenderer
+	httpClient *http.Client
+}
+
+func newMailgunMailClient(globalConfig *conf.GlobalConfiguration) *mailgunMailClient {
+	from := &mail.Address{
+		Name:    globalConfig.SMTP.SenderName,
+		Address: globalConfig.SMTP.AdminEmail,
+	}
+
+	baseURL := "https://api.mailgun.net"
+	if globalConfig.Mailer.Mailgun.Region == "eu" {
+		baseURL = "https://api.eu.mailgun.net"
+	}
+
+	return &mailgunMailClient{
+		config:    &globalConfig.Mailer.Mailgun,
+		baseURL:   baseURL,
+		from:      from.String(),
+		templates: newTemplateRenderer(globalConfig),
+		httpClient: &http.Client{
+			Timeout: mailgunTimeout,
+		},
+	}
+}
+
+func (m *mailgunMailClient) Mail(to, subjectTemplate, templateURL, defaultTemplate string, templateData map[string]interface{}) error {
+	return m.MailWithType("", to, subjectTemplate, templateURL, defaultTemplate, templateData)
+}
+
+func (m *mailgunMailClient) MailWithType(emailType, to, subjectTemplate, templateURL, defaultTemplate string, templateData map[string]interface{}) error {
+	_, err := m.MailWithID(emailType, to, subjectTemplate, templateURL, defaultTemplate, templateData)
+	return err
+}
+
+// MailWithID sends the email, and returns the ID Mailgun assigned to it.
+func (m *mailgunMailClient) MailWithID(emailType, to, subjectTemplate, templateURL, defaultTemplate string, templateData map[string]interface{}) (string, error) {
+	if to == "" {
+		return "", errors.New("mailgun: to field cannot be empty")
+	}
+
+	subject, err := renderSubject(subjectTemplate, templateData)
+	if err != nil {
+		return "", err
+	}
+
+	message := url.Values{}
+	message.Set("from", m.from)
+	message.Set("to", to)
+	message.Set("subject", subject)
+
+	if template := m.template(emailType); template != "" {
+		data := make(map[string]interface{}, len(templateData)+1)
+		for key, value := range templateData {
+			data[key] = value
+		}
+		data["Subject"] = subject
+
+		variables, err := json.Marshal(data)
+		if err != nil {
+			return "", err
+		}
+
+		message.Set("template", template)
+		message.Set("t:variables", string(variables))
+	} else {
+		body, err := m.templates.MailBody(templateURL, defaultTemplate, templateData)
+		if err != nil {
+			return "", err
+		}
+
+		message.Set("html", body)
+	}
+
+	return m.send(message)
+}
+
+// template returns the name of the Mailgun template of the email type, if
+// configured.
+func (m *mailgunMailClient) template(emailType string) string {
+	switch emailType {
+	case InviteVerification:
+		return m.config.Templates.Invite
+	case SignupVerification:
+		return m.config.Templates.Confirmation
+	case RecoveryVerification:
+		return m.config.Templates.Recovery
+	case EmailChangeVerification:
+		return m.config.Templates.EmailChange
+	case MagicLinkVerification:
+		return m.config.Templates.MagicLink
+	case ReauthenticationVerification:
+		return m.config.Templates.Reauthentication
+	case EmailChangedNotification:
+		return m.config.Templates.EmailChanged
+	case AccountDeletionNotification:
+		return m.config.Templates.AccountDeletion
+	case SecondaryEmailVerification:
+		return m.config.Templates.SecondaryEmail
+	case AccountLockedNotification:
+		return m.config.Templates.AccountLocked
+	}
+
+	return ""
+}
+
+func (m *mailgunMailClient) send(message url.Values) (string, error) {
+	req, err := http.NewRequest(http.MethodPost, m.baseURL+"/v3/"+url.PathEscape(m.config.Domain)+"/messages", strings.NewReader(message.Encode()))
+	if err != nil {
+		return "", err
+	}
+
+	req.SetBasicAuth("api", m.config.APIKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("mailgun: error sending email: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// the message of the response describes what's wrong with the
+		// email, like a domain of the other region
+		errorBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("mailgun: email not sent, status %d: %s", resp.StatusCode, strings.TrimSpace(string(errorBody)))
+	}
+
+	var result struct {
+		ID string `json:"id"`
+	}
+	// the email was sent even when the response can't be decoded, it just
+	// can't be correlated with its delivery events
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&result)
+
+	// the events of the email have the ID without its angle brackets
+	return strings.Trim(result.ID, "<>"), nil
+}
@@ -0,0 +1,109 @@
+package mailer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+func TestMailgunMailClient(t *testing.T) {
+	var messages []url.Values
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v3/mg.example.com/messages", r.URL.Path)
+
+		user, password, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "api", user)
+		assert.Equal(t, "key-test", password)
+
+		require.NoError(t, r.ParseForm())
+		messages = append(messages, r.PostForm)
+
+		if r.PostForm.Get("to") == "rejected@example.com" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"message":"Domain mg.example.com is not allowed to send"}`))
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"id":"<message-id@mg.example.com>","message":"Queued. Thank you."}`))
+	}))
+	defer server.Close()
+
+	config := &conf.GlobalConfiguration{
+		SiteURL: "https://example.com",
+		SMTP: conf.SMTPConfiguration{
+			AdminEmail: "auth@example.com",
+			SenderName: "Example",
+		},
+		Mailer: conf.MailerConfiguration{
+			Provider: "mailgun",
+			Mailgun: conf.MailgunConfiguration{
+				Domain: "mg.example.com",
+				APIKey: "key-test",
+				Region: "eu",
+				Templates: conf.EmailContentConfiguration{
+					Recovery: "recovery",
+				},
+			},
+		},
+	}
+
+	mailer := NewMailer(config).(*TemplateMailer)
+	require.IsType(t, &mailgunMailClient{}, mailer.Mailer)
+
+	client := mailer.Mailer.(*mailgunMailClient)
+	assert.Equal(t, "https://api.eu.mailgun.net", client.baseURL)
+	client.baseURL = server.URL
+
+	externalURL, err := url.Parse("https://auth.example.com/auth/v1/")
+	require.NoError(t, err)
+
+	user := &models.User{
+		Email:         storage.NullString("user@example.com"),
+		RecoveryToken: "recovery-token-hash",
+	}
+
+	// emails without a Mailgun template are rendered like with SMTP
+	require.NoError(t, mailer.MagicLinkMail(nil, user, "123456", "", externalURL))
+	require.Len(t, messages, 1)
+	assert.Equal(t, `"Example" <auth@example.com>`, messages[0].Get("from"))
+	assert.Equal(t, "user@example.com", messages[0].Get("to"))
+	assert.Equal(t, "Your Magic Link", messages[0].Get("subject"))
+	assert.Empty(t, messages[0].Get("template"))
+	assert.Contains(t, messages[0].Get("html"), "123456")
+
+	// emails with a Mailgun template are sent with the template variables
+	require.NoError(t, mailer.RecoveryMail(nil, user, "654321", "", externalURL))
+	require.Len(t, messages, 2)
+	assert.Equal(t, "recovery", messages[1].Get("template"))
+	assert.Empty(t, messages[1].Get("html"))
+
+	var variables map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(messages[1].Get("t:variables")), &variables))
+	assert.Equal(t, "654321", variables["Token"])
+	assert.Equal(t, "Reset Your Password", variables["Subject"])
+
+	// the ID of the emails is passed on without its angle brackets, like in
+	// the events of the webhooks
+	var sent []string
+	mailer.OnSent = func(emailType, to, messageID string) {
+		sent = append(sent, messageID)
+	}
+	require.NoError(t, mailer.RecoveryMail(nil, user, "654321", "", externalURL))
+	assert.Equal(t, []string{"message-id@mg.example.com"}, sent)
+
+	user.Email = storage.NullString("rejected@example.com")
+	err = mailer.MagicLinkMail(nil, user, "123456", "", externalURL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 403")
+	assert.Contains(t, err.Error(), "not allowed to send")
+}
@@ -0,0 +1,61 @@
+package mailer
+
+import (
+	"github.com/supabase/auth/internal/conf"
+)
+
+// OutboxEmail is an email queued in the outbox, with what the mail client is
+// called with when it's sent.
+type OutboxEmail struct {
+	Type            string                 `json:"type,omitempty"`
+	To              string                 `json:"to"`
+	Subject         string                 `json:"subject"`
+	TemplateURL     string                 `json:"template_url,omitempty"`
+	DefaultTemplate string                 `json:"default_template"`
+	Data            map[string]interface{} `json:"data"`
+}
+
+// Send sends the email with the mail client, and returns the ID the provider
+// assigned to it when the mail client returns one.
+func (e *OutboxEmail) Send(client MailClient) (string, error) {
+	if tracked, ok := client.(TrackedMailClient); ok && e.Type != "" {
+		return tracked.MailWithID(e.Type, e.To, e.Subject, e.TemplateURL, e.DefaultTemplate, e.Data)
+	}
+
+	if typed, ok := client.(TypedMailClient); ok && e.Type != "" {
+		return "", typed.MailWithType(e.Type, e.To, e.Subject, e.TemplateURL, e.DefaultTemplate, e.Data)
+	}
+
+	return "", client.Mail(e.To, e.Subject, e.TemplateURL, e.DefaultTemplate, e.Data)
+}
+
+// outboxMailClient queues emails in the outbox instead of sending them.
+// Templates are rendered when the emails are sent.
+type outboxMailClient struct {
+	enqueue func(*OutboxEmail) error
+}
+
+// NewOutboxMailer returns a mailer which queues emails with enqueue, to be
+// sent in the background.
+func NewOutboxMailer(globalConfig *conf.GlobalConfiguration, enqueue func(*OutboxEmail) error) Mailer {
+	return &TemplateMailer{
+		SiteURL: globalConfig.SiteURL,
+		Config:  globalConfig,
+		Mailer:  &outboxMailClient{enqueue: enqueue},
+	}
+}
+
+func (m *outboxMailClient) Mail(to, subjectTemplate, templateURL, defaultTemplate string, templateData map[string]interface{}) error {
+	return m.MailWithType("", to, subjectTemplate, templateURL, defaultTemplate, templateData)
+}
+
+func (m *outboxMailClient) MailWithType(emailType, to, subjectTemplate, templateURL, defaultTemplate string, templateData map[string]interface{}) error {
+	return m.enqueue(&OutboxEmail{
+		Type:            emailType,
+		To:              to,
+		Subject:         subjectTemplate,
+		TemplateURL:     templateURL,
+		DefaultTemplate: defaultTemplate,
+		Data:            templateData,
+	})
+}
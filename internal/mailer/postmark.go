@@ -0,0 +1,159 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/supabase/auth/internal/conf"
+)
+
+// postmarkTimeout limits how long sending an email with Postmark can take.
+const postmarkTimeout = 10 * time.Second
+
+type postmarkMessage struct {
+	From          string `json:"From"`
+	To            string `json:"To"`
+	Subject       string `json:"Subject"`
+	HtmlBody      string `json:"HtmlBody"`
+	MessageStream string `json:"MessageStream,omitempty"`
+	Tag           string `json:"Tag,omitempty"`
+}
+
+// postmarkMailClient sends emails with the Postmark email API. Emails are
+// rendered like with SMTP, tagged with their type and sent with the message
+// stream of their type.
+type postmarkMailClient struct {
+	config     *conf.PostmarkConfiguration
+	baseURL    string
+	from       string
+	templates  *templateRenderer
+	httpClient *http.Client
+}
+
+func newPostmarkMailClient(globalConfig *conf.GlobalConfiguration) *postmarkMailClient {
+	from := &mail.Address{
+		Name:    globalConfig.SMTP.SenderName,
+		Address: globalConfig.SMTP.AdminEmail,
+	}
+
+	return &postmarkMailClient{
+		config:    &globalConfig.Mailer.Postmark,
+		baseURL:   "https://api.postmarkapp.com",
+		from:      from.String(),
+		templates: newTemplateRenderer(globalConfig),
+		httpClient: &http.Client{
+			Timeout: postmarkTimeout,
+		},
+	}
+}
+
+func (m *postmarkMailClient) Mail(to, subjectTemplate, templateURL, defaultTemplate string, templateData map[string]interface{}) error {
+	return m.MailWithType("", to, subjectTemplate, templateURL, defaultTemplate, templateData)
+}
+
+func (m *postmarkMailClient) MailWithType(emailType, to, subjectTemplate, templateURL, defaultTemplate string, templateData map[string]interface{}) error {
+	_, err := m.MailWithID(emailType, to, subjectTemplate, templateURL, defaultTemplate, templateData)
+	return err
+}
+
+// MailWithID sends the email, and returns the ID Postmark assigned to it.
+func (m *postmarkMailClient) MailWithID(emailType, to, subjectTemplate, templateURL, defaultTemplate string, templateData map[string]interface{}) (string, error) {
+	if to == "" {
+		return "", errors.New("postmark: to field cannot be empty")
+	}
+
+	subject, err := renderSubject(subjectTemplate, templateData)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := m.templates.MailBody(templateURL, defaultTemplate, templateData)
+	if err != nil {
+		return "", err
+	}
+
+	return m.send(&postmarkMessage{
+		From:          m.from,
+		To:            to,
+		Subject:       subject,
+		HtmlBody:      body,
+		MessageStream: m.messageStream(emailType),
+		Tag:           emailType,
+	})
+}
+
+// messageStream returns the message stream of the email type, or the default
+// message stream.
+func (m *postmarkMailClient) messageStream(emailType string) string {
+	var stream string
+
+	switch emailType {
+	case InviteVerification:
+		stream = m.config.MessageStreams.Invite
+	case SignupVerification:
+		stream = m.config.MessageStreams.Confirmation
+	case RecoveryVerification:
+		stream = m.config.MessageStreams.Recovery
+	case EmailChangeVerification:
+		stream = m.config.MessageStreams.EmailChange
+	case MagicLinkVerification:
+		stream = m.config.MessageStreams.MagicLink
+	case ReauthenticationVerification:
+		stream = m.config.MessageStreams.Reauthentication
+	case EmailChangedNotification:
+		stream = m.config.MessageStreams.EmailChanged
+	case AccountDeletionNotification:
+		stream = m.config.MessageStreams.AccountDeletion
+	case SecondaryEmailVerification:
+		stream = m.config.MessageStreams.SecondaryEmail
+	case AccountLockedNotification:
+		stream = m.config.MessageStreams.AccountLocked
+	}
+
+	return withDefault(stream, m.config.MessageStream)
+}
+
+func (m *postmarkMailClient) send(message *postmarkMessage) (string, error) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, m.baseURL+"/email", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Postmark-Server-Token", m.config.ServerToken)
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("postmark: error sending email: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// the message of the response describes what's wrong with the
+		// email, like an inactive recipient or an unknown message stream
+		errorBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("postmark: email not sent, status %d: %s", resp.StatusCode, strings.TrimSpace(string(errorBody)))
+	}
+
+	var result struct {
+		MessageID string `json:"MessageID"`
+	}
+	// the email was sent even when the response can't be decoded, it just
+	// can't be correlated with its delivery events
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&result)
+
+	return result.MessageID, nil
+}
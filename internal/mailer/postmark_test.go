@@ -0,0 +1,87 @@
+package mailer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+func TestPostmarkMailClient(t *testing.T) {
+	var messages []postmarkMessage
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/email", r.URL.Path)
+		assert.Equal(t, "server-token", r.Header.Get("X-Postmark-Server-Token"))
+
+		var message postmarkMessage
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&message))
+		messages = append(messages, message)
+
+		if message.To == "rejected@example.com" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = w.Write([]byte(`{"ErrorCode":406,"Message":"You tried to send to recipient(s) that have been marked as inactive."}`))
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"ErrorCode":0,"Message":"OK"}`))
+	}))
+	defer server.Close()
+
+	config := &conf.GlobalConfiguration{
+		SiteURL: "https://example.com",
+		SMTP: conf.SMTPConfiguration{
+			AdminEmail: "auth@example.com",
+			SenderName: "Example",
+		},
+		Mailer: conf.MailerConfiguration{
+			Provider: "postmark",
+			Postmark: conf.PostmarkConfiguration{
+				ServerToken:   "server-token",
+				MessageStream: "outbound",
+				MessageStreams: conf.EmailContentConfiguration{
+					Invite: "broadcast",
+				},
+			},
+		},
+	}
+
+	mailer := NewMailer(config).(*TemplateMailer)
+	require.IsType(t, &postmarkMailClient{}, mailer.Mailer)
+	mailer.Mailer.(*postmarkMailClient).baseURL = server.URL
+
+	externalURL, err := url.Parse("https://auth.example.com/auth/v1/")
+	require.NoError(t, err)
+
+	user := &models.User{
+		Email: storage.NullString("user@example.com"),
+	}
+
+	require.NoError(t, mailer.MagicLinkMail(nil, user, "123456", "", externalURL))
+	require.Len(t, messages, 1)
+	assert.Equal(t, `"Example" <auth@example.com>`, messages[0].From)
+	assert.Equal(t, "user@example.com", messages[0].To)
+	assert.Equal(t, "Your Magic Link", messages[0].Subject)
+	assert.Contains(t, messages[0].HtmlBody, "123456")
+	assert.Equal(t, "outbound", messages[0].MessageStream)
+	assert.Equal(t, MagicLinkVerification, messages[0].Tag)
+
+	// email types can be sent with their own message stream
+	require.NoError(t, mailer.InviteMail(nil, user, "123456", "", externalURL))
+	require.Len(t, messages, 2)
+	assert.Equal(t, "broadcast", messages[1].MessageStream)
+	assert.Equal(t, InviteVerification, messages[1].Tag)
+
+	user.Email = storage.NullString("rejected@example.com")
+	err = mailer.MagicLinkMail(nil, user, "123456", "", externalURL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 422")
+	assert.Contains(t, err.Error(), "inactive")
+}
@@ -0,0 +1,222 @@
+package mailer
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+)
+
+// ErrTemplateNotFound is returned when previewing an email that has no
+// template.
+var ErrTemplateNotFound = errors.New("mailer: template not found")
+
+// emailTemplate is an email with a configurable template.
+type emailTemplate struct {
+	emailType      string
+	defaultSubject string
+	linkTemplate   string
+	codeTemplate   string
+	field          func(*conf.EmailContentConfiguration) string
+}
+
+// emailTemplates are the emails with templates, by the name of their
+// template in the mailer configuration.
+var emailTemplates = map[string]emailTemplate{
+	"invite": {
+		emailType:      InviteVerification,
+		defaultSubject: "You have been invited",
+		linkTemplate:   defaultInviteMail,
+		codeTemplate:   defaultInviteCodeMail,
+		field:          func(c *conf.EmailContentConfiguration) string { return c.Invite },
+	},
+	"confirmation": {
+		emailType:      SignupVerification,
+		defaultSubject: "Confirm Your Email",
+		linkTemplate:   defaultConfirmationMail,
+		codeTemplate:   defaultConfirmationCodeMail,
+		field:          func(c *conf.EmailContentConfiguration) string { return c.Confirmation },
+	},
+	"recovery": {
+		emailType:      RecoveryVerification,
+		defaultSubject: "Reset Your Password",
+		linkTemplate:   defaultRecoveryMail,
+		codeTemplate:   defaultRecoveryCodeMail,
+		field:          func(c *conf.EmailContentConfiguration) string { return c.Recovery },
+	},
+	"magic_link": {
+		emailType:      MagicLinkVerification,
+		defaultSubject: "Your Magic Link",
+		linkTemplate:   defaultMagicLinkMail,
+		codeTemplate:   defaultMagicLinkCodeMail,
+		field:          func(c *conf.EmailContentConfiguration) string { return c.MagicLink },
+	},
+	"email_change": {
+		emailType:      EmailChangeVerification,
+		defaultSubject: "Confirm Email Change",
+		linkTemplate:   defaultEmailChangeMail,
+		codeTemplate:   defaultEmailChangeCodeMail,
+		field:          func(c *conf.EmailContentConfiguration) string { return c.EmailChange },
+	},
+	"reauthentication": {
+		emailType:      ReauthenticationVerification,
+		defaultSubject: "Confirm reauthentication",
+		linkTemplate:   defaultReauthenticateMail,
+		codeTemplate:   defaultReauthenticateMail,
+		field:          func(c *conf.EmailContentConfiguration) string { return c.Reauthentication },
+	},
+	"email_changed": {
+		emailType:      EmailChangedNotification,
+		defaultSubject: "Your Email Address Was Changed",
+		linkTemplate:   defaultEmailChangedMail,
+		codeTemplate:   defaultEmailChangedMail,
+		field:          func(c *conf.EmailContentConfiguration) string { return c.EmailChanged },
+	},
+	"account_deletion": {
+		emailType:      AccountDeletionNotification,
+		defaultSubject: "Your Account Will Be Deleted",
+		linkTemplate:   defaultAccountDeletionMail,
+		codeTemplate:   defaultAccountDeletionMail,
+		field:          func(c *conf.EmailContentConfiguration) string { return c.AccountDeletion },
+	},
+	"secondary_email": {
+		emailType:      SecondaryEmailVerification,
+		defaultSubject: "Confirm Your Email Address",
+		linkTemplate:   defaultSecondaryEmailMail,
+		codeTemplate:   defaultSecondaryEmailMail,
+		field:          func(c *conf.EmailContentConfiguration) string { return c.SecondaryEmail },
+	},
+	"account_locked": {
+		emailType:      AccountLockedNotification,
+		defaultSubject: "Your Account Was Locked",
+		linkTemplate:   defaultAccountLockedMail,
+		codeTemplate:   defaultAccountLockedMail,
+		field:          func(c *conf.EmailContentConfiguration) string { return c.AccountLocked },
+	},
+}
+
+// RenderedEmail is the subject and body of an email rendered from its
+// template.
+type RenderedEmail struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// sampleData returns the data an email is rendered with, where the
+// supplied data takes precedence over sample values.
+func (m *TemplateMailer) sampleData(data map[string]interface{}, externalURL *url.URL) (map[string]interface{}, error) {
+	path, err := getPath(m.Config.Mailer.URLPaths.Confirmation, &EmailParams{
+		Token:      "sample",
+		Type:       "signup",
+		RedirectTo: m.Config.SiteURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	undoPath, err := url.Parse("/email_change/undo")
+	if err != nil {
+		return nil, err
+	}
+	undoPath.RawQuery = url.Values{"token_hash": {"sample"}}.Encode()
+
+	cancelPath, err := url.Parse("/account_deletion/cancel")
+	if err != nil {
+		return nil, err
+	}
+	cancelPath.RawQuery = url.Values{"token_hash": {"sample"}}.Encode()
+
+	unlockPath, err := url.Parse("/account_lockout/unlock")
+	if err != nil {
+		return nil, err
+	}
+	unlockPath.RawQuery = url.Values{"token_hash": {"sample"}}.Encode()
+
+	sample := map[string]interface{}{
+		"SiteURL":         m.Config.SiteURL,
+		"ConfirmationURL": externalURL.ResolveReference(path).String(),
+		"UndoURL":         externalURL.ResolveReference(undoPath).String(),
+		"CancelURL":       externalURL.ResolveReference(cancelPath).String(),
+		"UnlockURL":       externalURL.ResolveReference(unlockPath).String(),
+		"DeleteAt":        time.Now().Add(30 * 24 * time.Hour),
+		"LockedUntil":     time.Now().Add(30 * time.Minute),
+		"Email":           "user@example.com",
+		"NewEmail":        "new@example.com",
+		"OldEmail":        "old@example.com",
+		"SendingTo":       "user@example.com",
+		"Token":           "123456",
+		"TokenHash":       "sample",
+		"Data":            map[string]interface{}{},
+		"RedirectTo":      m.Config.SiteURL,
+	}
+
+	for key, value := range data {
+		sample[key] = value
+	}
+
+	return sample, nil
+}
+
+// templateContent returns the subject and template of the named template,
+// in the locale of the request.
+func (m *TemplateMailer) templateContent(r *http.Request, templateName string) (*emailTemplate, string, string, error) {
+	t, ok := emailTemplates[templateName]
+	if !ok {
+		return nil, "", "", ErrTemplateNotFound
+	}
+
+	subject, template := m.content(r, &models.User{}, t.field)
+	return &t, withDefault(subject, t.defaultSubject), template, nil
+}
+
+// RenderTemplate renders the email of the named template with the data,
+// without sending it.
+func (m *TemplateMailer) RenderTemplate(r *http.Request, templateName string, data map[string]interface{}, externalURL *url.URL) (*RenderedEmail, error) {
+	t, subjectTemplate, templateURL, err := m.templateContent(r, templateName)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = m.sampleData(data, externalURL)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, err := renderSubject(subjectTemplate, data)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := newTemplateRenderer(m.Config).MailBody(templateURL, m.defaultMail(t.linkTemplate, t.codeTemplate), data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RenderedEmail{Subject: subject, Body: body}, nil
+}
+
+// SendTestMail sends the email of the named template with the data to the
+// address, like it's sent to users.
+func (m *TemplateMailer) SendTestMail(r *http.Request, templateName, to string, data map[string]interface{}, externalURL *url.URL) error {
+	t, subjectTemplate, templateURL, err := m.templateContent(r, templateName)
+	if err != nil {
+		return err
+	}
+
+	data, err = m.sampleData(data, externalURL)
+	if err != nil {
+		return err
+	}
+
+	return m.mail(
+		t.emailType,
+		to,
+		subjectTemplate,
+		templateURL,
+		m.defaultMail(t.linkTemplate, t.codeTemplate),
+		data,
+	)
+}
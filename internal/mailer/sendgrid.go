@@ -0,0 +1,191 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/supabase/auth/internal/conf"
+)
+
+// sendGridTimeout limits how long sending an email with SendGrid can take.
+const sendGridTimeout = 10 * time.Second
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridPersonalization struct {
+	To                  []sendGridAddress      `json:"to"`
+	DynamicTemplateData map[string]interface{} `json:"dynamic_template_data,omitempty"`
+}
+
+type sendGridMessage struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	Subject          string                    `json:"subject,omitempty"`
+	Content          []sendGridContent         `json:"content,omitempty"`
+	TemplateID       string                    `json:"template_id,omitempty"`
+}
+
+// sendGridMailClient sends emails with the SendGrid v3 Mail Send API. Emails
+// with a dynamic template are rendered by SendGrid with the template data,
+// the others are rendered like with SMTP.
+type sendGridMailClient struct {
+	config     *conf.SendGridConfiguration
+	from       sendGridAddress
+	templates  *templateRenderer
+	httpClient *http.Client
+}
+
+func newSendGridMailClient(globalConfig *conf.GlobalConfiguration) *sendGridMailClient {
+	return &sendGridMailClient{
+		config: &globalConfig.Mailer.SendGrid,
+		from: sendGridAddress{
+			Email: globalConfig.SMTP.AdminEmail,
+			Name:  globalConfig.SMTP.SenderName,
+		},
+		templates: newTemplateRenderer(globalConfig),
+		httpClient: &http.Client{
+			Timeout: sendGridTimeout,
+		},
+	}
+}
+
+func (m *sendGridMailClient) Mail(to, subjectTemplate, templateURL, defaultTemplate string, templateData map[string]interface{}) error {
+	return m.MailWithType("", to, subjectTemplate, templateURL, defaultTemplate, templateData)
+}
+
+func (m *sendGridMailClient) MailWithType(emailType, to, subjectTemplate, templateURL, defaultTemplate string, templateData map[string]interface{}) error {
+	_, err := m.MailWithID(emailType, to, subjectTemplate, templateURL, defaultTemplate, templateData)
+	return err
+}
+
+// MailWithID sends the email, and returns the ID SendGrid assigned to it.
+func (m *sendGridMailClient) MailWithID(emailType, to, subjectTemplate, templateURL, defaultTemplate string, templateData map[string]interface{}) (string, error) {
+	if to == "" {
+		return "", errors.New("sendgrid: to field cannot be empty")
+	}
+
+	subject, err := renderSubject(subjectTemplate, templateData)
+	if err != nil {
+		return "", err
+	}
+
+	message := &sendGridMessage{
+		Personalizations: []sendGridPersonalization{
+			{
+				To: []sendGridAddress{{Email: to}},
+			},
+		},
+		From: m.from,
+	}
+
+	if templateID := m.templateID(emailType); templateID != "" {
+		data := make(map[string]interface{}, len(templateData)+1)
+		for key, value := range templateData {
+			data[key] = value
+		}
+		data["Subject"] = subject
+
+		message.TemplateID = templateID
+		message.Personalizations[0].DynamicTemplateData = data
+	} else {
+		body, err := m.templates.MailBody(templateURL, defaultTemplate, templateData)
+		if err != nil {
+			return "", err
+		}
+
+		message.Subject = subject
+		message.Content = []sendGridContent{{Type: "text/html", Value: body}}
+	}
+
+	return m.send(message)
+}
+
+// templateID returns the ID of the dynamic template of the email type, if
+// configured.
+func (m *sendGridMailClient) templateID(emailType string) string {
+	switch emailType {
+	case InviteVerification:
+		return m.config.Templates.Invite
+	case SignupVerification:
+		return m.config.Templates.Confirmation
+	case RecoveryVerification:
+		return m.config.Templates.Recovery
+	case EmailChangeVerification:
+		return m.config.Templates.EmailChange
+	case MagicLinkVerification:
+		return m.config.Templates.MagicLink
+	case ReauthenticationVerification:
+		return m.config.Templates.Reauthentication
+	case EmailChangedNotification:
+		return m.config.Templates.EmailChanged
+	case AccountDeletionNotification:
+		return m.config.Templates.AccountDeletion
+	case SecondaryEmailVerification:
+		return m.config.Templates.SecondaryEmail
+	case AccountLockedNotification:
+		return m.config.Templates.AccountLocked
+	}
+
+	return ""
+}
+
+func (m *sendGridMailClient) send(message *sendGridMessage) (string, error) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(m.config.URL, "/")+"/v3/mail/send", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+m.config.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("sendgrid: error sending email: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// the errors of the response describe what's wrong with the
+		// message, like an unverified sender
+		errorBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("sendgrid: email not sent, status %d: %s", resp.StatusCode, strings.TrimSpace(string(errorBody)))
+	}
+
+	// the events of the email have the ID as the prefix of their
+	// sg_message_id
+	return resp.Header.Get("X-Message-Id"), nil
+}
+
+func renderSubject(subjectTemplate string, templateData map[string]interface{}) (string, error) {
+	tmpl, err := template.New("Subject").Funcs(templateFuncs).Parse(subjectTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	subject := &bytes.Buffer{}
+	if err := tmpl.Execute(subject, templateData); err != nil {
+		return "", err
+	}
+
+	return subject.String(), nil
+}
@@ -0,0 +1,101 @@
+package mailer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+func TestSendGridMailClient(t *testing.T) {
+	var messages []sendGridMessage
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v3/mail/send", r.URL.Path)
+		assert.Equal(t, "Bearer SG.test", r.Header.Get("Authorization"))
+
+		var message sendGridMessage
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&message))
+		messages = append(messages, message)
+
+		if message.Personalizations[0].To[0].Email == "rejected@example.com" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":[{"message":"The from address does not match a verified Sender Identity."}]}`))
+			return
+		}
+
+		w.Header().Set("X-Message-Id", "message-id")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	config := &conf.GlobalConfiguration{
+		SiteURL: "https://example.com",
+		SMTP: conf.SMTPConfiguration{
+			AdminEmail: "auth@example.com",
+			SenderName: "Example",
+		},
+		Mailer: conf.MailerConfiguration{
+			Provider: "sendgrid",
+			SendGrid: conf.SendGridConfiguration{
+				APIKey: "SG.test",
+				URL:    server.URL + "/",
+				Templates: conf.EmailContentConfiguration{
+					Recovery: "d-recovery",
+				},
+			},
+		},
+	}
+
+	mailer := NewMailer(config).(*TemplateMailer)
+	require.IsType(t, &sendGridMailClient{}, mailer.Mailer)
+
+	externalURL, err := url.Parse("https://auth.example.com/auth/v1/")
+	require.NoError(t, err)
+
+	user := &models.User{
+		Email:         storage.NullString("user@example.com"),
+		RecoveryToken: "recovery-token-hash",
+	}
+
+	// emails without a dynamic template are rendered like with SMTP
+	require.NoError(t, mailer.MagicLinkMail(nil, user, "123456", "", externalURL))
+	require.Len(t, messages, 1)
+	assert.Equal(t, sendGridAddress{Email: "auth@example.com", Name: "Example"}, messages[0].From)
+	assert.Equal(t, []sendGridAddress{{Email: "user@example.com"}}, messages[0].Personalizations[0].To)
+	assert.Equal(t, "Your Magic Link", messages[0].Subject)
+	assert.Empty(t, messages[0].TemplateID)
+	require.Len(t, messages[0].Content, 1)
+	assert.Equal(t, "text/html", messages[0].Content[0].Type)
+	assert.Contains(t, messages[0].Content[0].Value, "123456")
+
+	// emails with a dynamic template are sent with the template data
+	require.NoError(t, mailer.RecoveryMail(nil, user, "654321", "", externalURL))
+	require.Len(t, messages, 2)
+	assert.Equal(t, "d-recovery", messages[1].TemplateID)
+	assert.Empty(t, messages[1].Subject)
+	assert.Empty(t, messages[1].Content)
+	assert.Equal(t, "654321", messages[1].Personalizations[0].DynamicTemplateData["Token"])
+	assert.Equal(t, "Reset Your Password", messages[1].Personalizations[0].DynamicTemplateData["Subject"])
+
+	// the ID of the emails is passed on so their delivery can be tracked
+	var sent []string
+	mailer.OnSent = func(emailType, to, messageID string) {
+		sent = append(sent, emailType, to, messageID)
+	}
+	require.NoError(t, mailer.RecoveryMail(nil, user, "654321", "", externalURL))
+	assert.Equal(t, []string{RecoveryVerification, "user@example.com", "message-id"}, sent)
+
+	user.Email = storage.NullString("rejected@example.com")
+	err = mailer.MagicLinkMail(nil, user, "123456", "", externalURL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 400")
+	assert.Contains(t, err.Error(), "verified Sender Identity")
+}
@@ -0,0 +1,212 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/utilities"
+)
+
+// sesTimeout limits how long sending an email with SES can take.
+const sesTimeout = 10 * time.Second
+
+type sesContent struct {
+	Data    string `json:"Data"`
+	Charset string `json:"Charset"`
+}
+
+type sesBody struct {
+	Html sesContent `json:"Html"`
+}
+
+type sesSimpleMessage struct {
+	Subject sesContent `json:"Subject"`
+	Body    sesBody    `json:"Body"`
+}
+
+type sesEmailContent struct {
+	Simple sesSimpleMessage `json:"Simple"`
+}
+
+type sesDestination struct {
+	ToAddresses []string `json:"ToAddresses"`
+}
+
+type sesMessageTag struct {
+	Name  string `json:"Name"`
+	Value string `json:"Value"`
+}
+
+type sesSendEmailRequest struct {
+	FromEmailAddress     string          `json:"FromEmailAddress"`
+	Destination          sesDestination  `json:"Destination"`
+	Content              sesEmailContent `json:"Content"`
+	ConfigurationSetName string          `json:"ConfigurationSetName,omitempty"`
+	EmailTags            []sesMessageTag `json:"EmailTags,omitempty"`
+}
+
+// sesMailClient sends emails with the Amazon SES v2 SendEmail API, signing
+// the requests with AWS Signature Version 4. Emails are rendered like with
+// SMTP and tagged with their type, so the events published by the
+// configuration set can be told apart.
+type sesMailClient struct {
+	config     *conf.SESConfiguration
+	from       string
+	templates  *templateRenderer
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+func newSESMailClient(globalConfig *conf.GlobalConfiguration) *sesMailClient {
+	from := &mail.Address{
+		Name:    globalConfig.SMTP.SenderName,
+		Address: globalConfig.SMTP.AdminEmail,
+	}
+
+	return &sesMailClient{
+		config:    &globalConfig.Mailer.SES,
+		from:      from.String(),
+		templates: newTemplateRenderer(globalConfig),
+		httpClient: &http.Client{
+			Timeout: sesTimeout,
+		},
+		now: time.Now,
+	}
+}
+
+func (m *sesMailClient) Mail(to, subjectTemplate, templateURL, defaultTemplate string, templateData map[string]interface{}) error {
+	return m.MailWithType("", to, subjectTemplate, templateURL, defaultTemplate, templateData)
+}
+
+func (m *sesMailClient) MailWithType(emailType, to, subjectTemplate, templateURL, defaultTemplate string, templateData map[string]interface{}) error {
+	_, err := m.MailWithID(emailType, to, subjectTemplate, templateURL, defaultTemplate, templateData)
+	return err
+}
+
+// MailWithID sends the email, and returns the ID SES assigned to it.
+func (m *sesMailClient) MailWithID(emailType, to, subjectTemplate, templateURL, defaultTemplate string, templateData map[string]interface{}) (string, error) {
+	if to == "" {
+		return "", errors.New("ses: to field cannot be empty")
+	}
+
+	subject, err := renderSubject(subjectTemplate, templateData)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := m.templates.MailBody(templateURL, defaultTemplate, templateData)
+	if err != nil {
+		return "", err
+	}
+
+	message := &sesSendEmailRequest{
+		FromEmailAddress: m.from,
+		Destination: sesDestination{
+			ToAddresses: []string{to},
+		},
+		Content: sesEmailContent{
+			Simple: sesSimpleMessage{
+				Subject: sesContent{Data: subject, Charset: "UTF-8"},
+				Body: sesBody{
+					Html: sesContent{Data: body, Charset: "UTF-8"},
+				},
+			},
+		},
+		ConfigurationSetName: m.config.ConfigurationSet,
+		EmailTags:            m.tags(emailType),
+	}
+
+	return m.send(message)
+}
+
+// tags returns the message tags of an email of the type, sorted by name so
+// the requests are stable.
+func (m *sesMailClient) tags(emailType string) []sesMessageTag {
+	var tags []sesMessageTag
+	for name, value := range m.config.Tags {
+		tags = append(tags, sesMessageTag{Name: name, Value: value})
+	}
+
+	if emailType != "" {
+		tags = append(tags, sesMessageTag{Name: "email_type", Value: emailType})
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+
+	return tags
+}
+
+func (m *sesMailClient) endpoint() string {
+	if m.config.Endpoint != "" {
+		return strings.TrimSuffix(m.config.Endpoint, "/")
+	}
+
+	return "https://email." + m.config.Region + ".amazonaws.com"
+}
+
+func (m *sesMailClient) send(message *sesSendEmailRequest) (string, error) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, m.endpoint()+"/v2/email/outbound-emails", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if err := m.sign(req, body); err != nil {
+		return "", err
+	}
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ses: error sending email: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// the error of the response describes what's wrong with the
+		// message, like an unverified identity
+		errorBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("ses: email not sent, status %d: %s", resp.StatusCode, strings.TrimSpace(string(errorBody)))
+	}
+
+	var result struct {
+		MessageID string `json:"MessageId"`
+	}
+	// the email was sent even when the response can't be decoded, it just
+	// can't be correlated with its delivery events
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&result)
+
+	return result.MessageID, nil
+}
+
+// sign adds the AWS Signature Version 4 headers of the ses service to the
+// request, with the configured credentials or the ones of the environment.
+func (m *sesMailClient) sign(req *http.Request, body []byte) error {
+	credentials := utilities.AWSCredentialsOrEnv(utilities.AWSCredentials{
+		AccessKeyID:     m.config.AccessKeyID,
+		SecretAccessKey: m.config.SecretAccessKey,
+		SessionToken:    m.config.SessionToken,
+	})
+
+	if err := utilities.SignAWSRequest(req, body, "ses", m.config.Region, credentials, m.now()); err != nil {
+		return fmt.Errorf("ses: %w", err)
+	}
+
+	return nil
+}
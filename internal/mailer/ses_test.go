@@ -0,0 +1,116 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+func TestSESMailClient(t *testing.T) {
+	var messages []sesSendEmailRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v2/email/outbound-emails", r.URL.Path)
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"))
+		assert.Contains(t, r.Header.Get("Authorization"), "/eu-west-1/ses/aws4_request")
+		assert.Equal(t, "session-token", r.Header.Get("X-Amz-Security-Token"))
+
+		var message sesSendEmailRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&message))
+		messages = append(messages, message)
+
+		if message.Destination.ToAddresses[0] == "rejected@example.com" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"message":"Email address is not verified."}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"MessageId":"message-id"}`))
+	}))
+	defer server.Close()
+
+	config := &conf.GlobalConfiguration{
+		SiteURL: "https://example.com",
+		SMTP: conf.SMTPConfiguration{
+			AdminEmail: "auth@example.com",
+			SenderName: "Example",
+		},
+		Mailer: conf.MailerConfiguration{
+			Provider: "ses",
+			SES: conf.SESConfiguration{
+				Region:           "eu-west-1",
+				AccessKeyID:      "AKIDEXAMPLE",
+				SecretAccessKey:  "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+				SessionToken:     "session-token",
+				Endpoint:         server.URL + "/",
+				ConfigurationSet: "auth-events",
+				Tags: map[string]string{
+					"service": "auth",
+				},
+			},
+		},
+	}
+
+	mailer := NewMailer(config).(*TemplateMailer)
+	require.IsType(t, &sesMailClient{}, mailer.Mailer)
+
+	externalURL, err := url.Parse("https://auth.example.com/auth/v1/")
+	require.NoError(t, err)
+
+	user := &models.User{
+		Email: storage.NullString("user@example.com"),
+	}
+
+	require.NoError(t, mailer.MagicLinkMail(nil, user, "123456", "", externalURL))
+	require.Len(t, messages, 1)
+	assert.Equal(t, `"Example" <auth@example.com>`, messages[0].FromEmailAddress)
+	assert.Equal(t, []string{"user@example.com"}, messages[0].Destination.ToAddresses)
+	assert.Equal(t, "Your Magic Link", messages[0].Content.Simple.Subject.Data)
+	assert.Contains(t, messages[0].Content.Simple.Body.Html.Data, "123456")
+	assert.Equal(t, "auth-events", messages[0].ConfigurationSetName)
+	assert.Equal(t, []sesMessageTag{
+		{Name: "email_type", Value: MagicLinkVerification},
+		{Name: "service", Value: "auth"},
+	}, messages[0].EmailTags)
+
+	user.Email = storage.NullString("rejected@example.com")
+	err = mailer.MagicLinkMail(nil, user, "123456", "", externalURL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 400")
+	assert.Contains(t, err.Error(), "not verified")
+}
+
+func TestSESMailClientSign(t *testing.T) {
+	client := &sesMailClient{
+		config: &conf.SESConfiguration{
+			Region:          "us-east-1",
+			AccessKeyID:     "AKIDEXAMPLE",
+			SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		},
+		now: func() time.Time {
+			return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		},
+	}
+
+	body := []byte(`{}`)
+
+	req, err := http.NewRequest(http.MethodPost, client.endpoint()+"/v2/email/outbound-emails", bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	require.NoError(t, client.sign(req, body))
+	assert.Equal(t, "20240102T030405Z", req.Header.Get("X-Amz-Date"))
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20240102/us-east-1/ses/aws4_request, SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date, Signature=e6be2c30a1a6be257440d0a464f054906a6755bdcf14fa1b067330e22f4c0581", req.Header.Get("Authorization"))
+}
@@ -0,0 +1,75 @@
+package mailer
+
+import (
+	"bytes"
+	"net/mail"
+
+	"github.com/supabase/auth/internal/conf"
+	"gopkg.in/gomail.v2"
+)
+
+// smtpMailClient sends emails with SMTP, over the connections of the pool
+// shared by the mailers with the same settings.
+type smtpMailClient struct {
+	from        string
+	fromAddress string
+	pool        *smtpPool
+	templates   *templateRenderer
+
+	// dkim signs the emails when a DKIM key is configured.
+	dkim *dkimSigner
+}
+
+func newSMTPMailClient(globalConfig *conf.GlobalConfiguration, from, localName string) *smtpMailClient {
+	fromAddress := globalConfig.SMTP.AdminEmail
+	if address, err := mail.ParseAddress(from); err == nil {
+		fromAddress = address.Address
+	}
+
+	client := &smtpMailClient{
+		from:        from,
+		fromAddress: fromAddress,
+		pool:        defaultSMTPPools.get(&globalConfig.SMTP, localName),
+		templates:   newTemplateRenderer(globalConfig),
+	}
+
+	if globalConfig.SMTP.DKIM.Signer != nil {
+		client.dkim = newDKIMSigner(&globalConfig.SMTP.DKIM)
+	}
+
+	return client
+}
+
+func (m *smtpMailClient) Mail(to, subjectTemplate, templateURL, defaultTemplate string, templateData map[string]interface{}) error {
+	subject, err := renderSubject(subjectTemplate, templateData)
+	if err != nil {
+		return err
+	}
+
+	body, err := m.templates.MailBody(templateURL, defaultTemplate, templateData)
+	if err != nil {
+		return err
+	}
+
+	mail := gomail.NewMessage()
+	mail.SetHeader("From", m.from)
+	mail.SetHeader("To", to)
+	mail.SetHeader("Subject", subject)
+	mail.SetBody("text/html", body)
+
+	if m.dkim == nil {
+		return m.pool.send(m.fromAddress, to, mail)
+	}
+
+	var message bytes.Buffer
+	if _, err := mail.WriteTo(&message); err != nil {
+		return err
+	}
+
+	signed, err := m.dkim.sign(message.Bytes())
+	if err != nil {
+		return err
+	}
+
+	return m.pool.send(m.fromAddress, to, bytes.NewReader(signed))
+}
@@ -0,0 +1,368 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/supabase/auth/internal/conf"
+)
+
+// smtpTimeout limits how long connecting to the SMTP server, and each
+// email sent, can take.
+const smtpTimeout = 30 * time.Second
+
+// smtpPoolKey identifies the pools of connections opened with the same
+// settings.
+type smtpPoolKey struct {
+	host           string
+	port           int
+	user           string
+	pass           string
+	localName      string
+	tlsPolicy      string
+	maxConnections int
+	idleTimeout    time.Duration
+}
+
+// smtpPools shares the connections to the SMTP servers between the mailers,
+// as a mailer is created for each request.
+type smtpPools struct {
+	mutex sync.Mutex
+	pools map[smtpPoolKey]*smtpPool
+}
+
+var defaultSMTPPools = newSMTPPools()
+
+func newSMTPPools() *smtpPools {
+	return &smtpPools{
+		pools: make(map[smtpPoolKey]*smtpPool),
+	}
+}
+
+func (p *smtpPools) get(config *conf.SMTPConfiguration, localName string) *smtpPool {
+	key := smtpPoolKey{
+		host:           config.Host,
+		port:           config.Port,
+		user:           config.User,
+		pass:           config.Pass,
+		localName:      localName,
+		tlsPolicy:      config.GetTLSPolicy(),
+		maxConnections: max(1, config.MaxConnections),
+		idleTimeout:    config.IdleTimeout,
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	pool, ok := p.pools[key]
+	if !ok {
+		pool = newSMTPPool(key)
+		p.pools[key] = pool
+	}
+
+	return pool
+}
+
+// smtpPool keeps the connections to an SMTP server open to send the next
+// emails with, instead of connecting for each email. Connections are checked
+// with NOOP before they're reused, and closed once idle for longer than the
+// idle timeout.
+type smtpPool struct {
+	key       smtpPoolKey
+	tlsConfig *tls.Config
+
+	// slots limits the connections, as each email holds a slot while it's
+	// sent.
+	slots chan struct{}
+
+	mutex sync.Mutex
+	idle  []*smtpConn
+
+	now func() time.Time
+}
+
+func newSMTPPool(key smtpPoolKey) *smtpPool {
+	return &smtpPool{
+		key:       key,
+		tlsConfig: &tls.Config{ServerName: key.host}, // #nosec G402 -- the minimum version is the default
+		slots:     make(chan struct{}, key.maxConnections),
+		now:       time.Now,
+	}
+}
+
+// send sends the message from the address to the address, waiting for a
+// connection when all are in use.
+func (p *smtpPool) send(from, to string, msg io.WriterTo) error {
+	p.slots <- struct{}{}
+	defer func() { <-p.slots }()
+
+	c, err := p.acquire()
+	if err != nil {
+		return err
+	}
+
+	err = c.send(from, to, msg)
+	p.release(c, err)
+
+	return err
+}
+
+// acquire returns the most recently used idle connection that's still
+// healthy, or a new connection.
+func (p *smtpPool) acquire() (*smtpConn, error) {
+	for {
+		p.mutex.Lock()
+		if len(p.idle) == 0 {
+			p.mutex.Unlock()
+			break
+		}
+		c := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		p.mutex.Unlock()
+
+		if p.now().Sub(c.lastUsed) > p.key.idleTimeout {
+			c.close()
+			continue
+		}
+
+		// the server may have closed the connection since it was used
+		c.setDeadline()
+		if err := c.client.Noop(); err != nil {
+			c.close()
+			continue
+		}
+
+		return c, nil
+	}
+
+	return p.dial()
+}
+
+// release returns the connection to the pool after an email was sent with
+// it. Connections are closed after network errors, but are reused after the
+// server rejected the email once the transaction is reset.
+func (p *smtpPool) release(c *smtpConn, err error) {
+	if err != nil {
+		var protocolErr *textproto.Error
+		if !errors.As(err, &protocolErr) || c.client.Reset() != nil {
+			c.close()
+			return
+		}
+	}
+
+	c.lastUsed = p.now()
+
+	p.mutex.Lock()
+	p.idle = append(p.idle, c)
+	p.mutex.Unlock()
+}
+
+func (p *smtpPool) dial() (*smtpConn, error) {
+	key := p.key
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(key.host, strconv.Itoa(key.port)), smtpTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if key.tlsPolicy == conf.SMTPTLSImplicit {
+		conn = tls.Client(conn, p.tlsConfig)
+	}
+
+	c := &smtpConn{conn: conn}
+	c.setDeadline()
+
+	c.client, err = smtp.NewClient(conn, key.host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := c.handshake(key, p.tlsConfig); err != nil {
+		c.close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// smtpConn is a connection to an SMTP server, authenticated and ready to
+// send emails.
+type smtpConn struct {
+	conn   net.Conn
+	client *smtp.Client
+
+	// pipelining is whether the server supports sending the commands of
+	// an email at once, instead of waiting for the reply to each.
+	pipelining bool
+
+	lastUsed time.Time
+}
+
+func (c *smtpConn) handshake(key smtpPoolKey, tlsConfig *tls.Config) error {
+	if key.localName != "" {
+		if err := c.client.Hello(key.localName); err != nil {
+			return err
+		}
+	}
+
+	if key.tlsPolicy != conf.SMTPTLSImplicit {
+		if ok, _ := c.client.Extension("STARTTLS"); ok {
+			if err := c.client.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		} else if key.tlsPolicy == conf.SMTPTLSRequired {
+			return errors.New("smtp: server does not support STARTTLS, which the TLS policy requires")
+		}
+	}
+
+	if key.user != "" {
+		if ok, mechanisms := c.client.Extension("AUTH"); ok {
+			var auth smtp.Auth
+			if strings.Contains(mechanisms, "CRAM-MD5") {
+				auth = smtp.CRAMMD5Auth(key.user, key.pass)
+			} else if strings.Contains(mechanisms, "LOGIN") && !strings.Contains(mechanisms, "PLAIN") {
+				auth = &loginAuth{username: key.user, password: key.pass, host: key.host}
+			} else {
+				auth = smtp.PlainAuth("", key.user, key.pass, key.host)
+			}
+
+			if err := c.client.Auth(auth); err != nil {
+				return err
+			}
+		}
+	}
+
+	c.pipelining, _ = c.client.Extension("PIPELINING")
+
+	return nil
+}
+
+func (c *smtpConn) setDeadline() {
+	_ = c.conn.SetDeadline(time.Now().Add(smtpTimeout))
+}
+
+func (c *smtpConn) close() {
+	// QUIT can't be sent when the connection is broken
+	if err := c.client.Quit(); err != nil {
+		c.client.Close()
+	}
+}
+
+func (c *smtpConn) send(from, to string, msg io.WriterTo) error {
+	if strings.ContainsAny(from+to, "\r\n") {
+		return errors.New("smtp: addresses must not contain line breaks")
+	}
+
+	c.setDeadline()
+
+	if !c.pipelining {
+		if err := c.client.Mail(from); err != nil {
+			return err
+		}
+
+		if err := c.client.Rcpt(to); err != nil {
+			return err
+		}
+
+		w, err := c.client.Data()
+		if err != nil {
+			return err
+		}
+
+		if _, err := msg.WriteTo(w); err != nil {
+			w.Close()
+			return err
+		}
+
+		return w.Close()
+	}
+
+	text := c.client.Text
+
+	// the commands are sent at once, and their replies are read in order
+	// after
+	for _, command := range []string{"MAIL FROM:<" + from + ">", "RCPT TO:<" + to + ">", "DATA"} {
+		if err := text.PrintfLine("%s", command); err != nil {
+			return err
+		}
+	}
+
+	_, _, mailErr := text.ReadResponse(250)
+	_, _, rcptErr := text.ReadResponse(25)
+	_, _, dataErr := text.ReadResponse(354)
+
+	if dataErr == nil && (mailErr != nil || rcptErr != nil) {
+		// the server accepted the data without a sender or recipient, so
+		// it's ended empty
+		if err := text.DotWriter().Close(); err != nil {
+			return err
+		}
+		if _, _, err := text.ReadResponse(250); err != nil {
+			var protocolErr *textproto.Error
+			if !errors.As(err, &protocolErr) {
+				return err
+			}
+		}
+	}
+
+	for _, err := range []error{mailErr, rcptErr, dataErr} {
+		if err != nil {
+			return err
+		}
+	}
+
+	w := text.DotWriter()
+	if _, err := msg.WriteTo(w); err != nil {
+		w.Close()
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	_, _, err := text.ReadResponse(250)
+	return err
+}
+
+// loginAuth authenticates with the LOGIN mechanism, for servers that don't
+// support PLAIN.
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if server.Name != a.host {
+		return "", nil, errors.New("smtp: wrong host name")
+	}
+
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	switch {
+	case bytes.Equal(fromServer, []byte("Username:")):
+		return []byte(a.username), nil
+	case bytes.Equal(fromServer, []byte("Password:")):
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("smtp: unexpected server challenge: %s", fromServer)
+	}
+}
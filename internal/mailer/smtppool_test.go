@@ -0,0 +1,219 @@
+package mailer
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+	"gopkg.in/gomail.v2"
+)
+
+// fakeSMTPServer accepts emails over plain SMTP, rejecting the recipients
+// that start with "rejected".
+type fakeSMTPServer struct {
+	listener   net.Listener
+	pipelining bool
+
+	mutex       sync.Mutex
+	connections int
+	commands    []string
+	messages    []string
+}
+
+func newFakeSMTPServer(t *testing.T, pipelining bool) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &fakeSMTPServer{listener: listener, pipelining: pipelining}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			s.mutex.Lock()
+			s.connections++
+			s.mutex.Unlock()
+
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *fakeSMTPServer) config() *conf.SMTPConfiguration {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+
+	return &conf.SMTPConfiguration{
+		Host:           host,
+		Port:           portNumber,
+		MaxConnections: 2,
+		IdleTimeout:    time.Minute,
+	}
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP")
+
+	recipients := 0
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimRight(line, "\r\n")
+
+		s.mutex.Lock()
+		s.commands = append(s.commands, command)
+		s.mutex.Unlock()
+
+		switch verb := strings.ToUpper(strings.SplitN(command, " ", 2)[0]); verb {
+		case "EHLO":
+			if s.pipelining {
+				reply("250-localhost")
+				reply("250 PIPELINING")
+			} else {
+				reply("250 localhost")
+			}
+		case "MAIL", "NOOP", "RSET":
+			recipients = 0
+			reply("250 OK")
+		case "RCPT":
+			if strings.HasPrefix(command, "RCPT TO:<rejected") {
+				reply("550 No such user")
+			} else {
+				recipients++
+				reply("250 OK")
+			}
+		case "DATA":
+			if recipients == 0 {
+				reply("554 No valid recipients")
+				continue
+			}
+			reply("354 Go ahead")
+
+			var message strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				message.WriteString(line)
+			}
+
+			s.mutex.Lock()
+			s.messages = append(s.messages, message.String())
+			s.mutex.Unlock()
+
+			reply("250 Queued")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Not implemented")
+		}
+	}
+}
+
+func newTestSMTPMessage(to string) *gomail.Message {
+	message := gomail.NewMessage()
+	message.SetHeader("From", "auth@example.com")
+	message.SetHeader("To", to)
+	message.SetHeader("Subject", "Confirm your email")
+	message.SetBody("text/html", "<p>Confirm</p>")
+	return message
+}
+
+func TestSMTPPoolReusesConnections(t *testing.T) {
+	for _, pipelining := range []bool{false, true} {
+		server := newFakeSMTPServer(t, pipelining)
+		pool := newSMTPPools().get(server.config(), "")
+
+		require.NoError(t, pool.send("auth@example.com", "one@example.com", newTestSMTPMessage("one@example.com")))
+		require.Error(t, pool.send("auth@example.com", "rejected@example.com", newTestSMTPMessage("rejected@example.com")))
+		require.NoError(t, pool.send("auth@example.com", "two@example.com", newTestSMTPMessage("two@example.com")))
+
+		server.mutex.Lock()
+		assert.Equal(t, 1, server.connections, "pipelining: %v", pipelining)
+		assert.Len(t, server.messages, 2, "pipelining: %v", pipelining)
+		assert.Contains(t, server.commands, "NOOP")
+		assert.Contains(t, server.commands, "RSET")
+		server.mutex.Unlock()
+	}
+}
+
+func TestSMTPPoolClosesIdleConnections(t *testing.T) {
+	server := newFakeSMTPServer(t, false)
+	pool := newSMTPPools().get(server.config(), "")
+
+	now := time.Now()
+	pool.now = func() time.Time { return now }
+
+	require.NoError(t, pool.send("auth@example.com", "one@example.com", newTestSMTPMessage("one@example.com")))
+
+	now = now.Add(2 * time.Minute)
+
+	require.NoError(t, pool.send("auth@example.com", "two@example.com", newTestSMTPMessage("two@example.com")))
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	assert.Equal(t, 2, server.connections)
+}
+
+func TestSMTPPoolLimitsConnections(t *testing.T) {
+	server := newFakeSMTPServer(t, false)
+	pool := newSMTPPools().get(server.config(), "")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, pool.send("auth@example.com", "user@example.com", newTestSMTPMessage("user@example.com")))
+		}()
+	}
+	wg.Wait()
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	assert.LessOrEqual(t, server.connections, 2)
+	assert.Len(t, server.messages, 10)
+}
+
+func TestSMTPPoolRequiredTLS(t *testing.T) {
+	server := newFakeSMTPServer(t, false)
+
+	config := server.config()
+	config.TLSPolicy = conf.SMTPTLSRequired
+
+	err := newSMTPPools().get(config, "").send("auth@example.com", "one@example.com", newTestSMTPMessage("one@example.com"))
+	require.ErrorContains(t, err, "STARTTLS")
+}
+
+func TestSMTPPoolRejectsLineBreaks(t *testing.T) {
+	server := newFakeSMTPServer(t, true)
+	pool := newSMTPPools().get(server.config(), "")
+
+	require.Error(t, pool.send("auth@example.com", "one@example.com>\r\nRCPT TO:<two@example.com", newTestSMTPMessage("one@example.com")))
+}
@@ -1,27 +1,66 @@
 package mailer
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/badoux/checkmail"
+	"github.com/sirupsen/logrus"
 	"github.com/supabase/auth/internal/conf"
 	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 )
 
+var suppressedEmailCounter = observability.ObtainMetricCounter("gotrue_mailer_suppressed_emails", "Number of emails not sent to suppressed addresses by email type")
+
 type MailClient interface {
 	Mail(string, string, string, string, map[string]interface{}) error
 }
 
+// TypedMailClient is a MailClient that is also told the type of the emails,
+// like SendGrid which sends each type with its own dynamic template, or SES
+// which tags emails with their type.
+type TypedMailClient interface {
+	MailClient
+	MailWithType(string, string, string, string, string, map[string]interface{}) error
+}
+
+// TrackedMailClient is a TypedMailClient that also returns the ID the
+// provider assigned to the emails, which its delivery webhooks refer to.
+type TrackedMailClient interface {
+	TypedMailClient
+	MailWithID(string, string, string, string, string, map[string]interface{}) (string, error)
+}
+
 // TemplateMailer will send mail and use templates from the site for easy mail styling
 type TemplateMailer struct {
 	SiteURL string
 	Config  *conf.GlobalConfiguration
 	Mailer  MailClient
+
+	// IsSuppressed reports whether an address is on the suppression list,
+	// which emails are not sent to.
+	IsSuppressed func(address string) (bool, error)
+
+	// OnSent is called with the ID the provider assigned to each email it
+	// accepted, so its delivery can be tracked.
+	OnSent func(emailType, to, messageID string)
+
+	// ShortenURL returns a short link to the URL, which the links of the
+	// emails are replaced with.
+	ShortenURL func(longURL string) (string, error)
 }
 
+// shortenedLinks are the template data of the links shortened when short
+// links are enabled.
+var shortenedLinks = []string{"ConfirmationURL", "UndoURL"}
+
 func encodeRedirectURL(referrerURL string) string {
 	if len(referrerURL) > 0 {
 		if strings.ContainsAny(referrerURL, "&=#") {
@@ -44,6 +83,23 @@ const (
 	EmailChangeCurrentVerification = "email_change_current"
 	EmailChangeNewVerification     = "email_change_new"
 	ReauthenticationVerification   = "reauthentication"
+
+	// EmailChangedNotification is sent to the old email address after an
+	// email change, and isn't verified.
+	EmailChangedNotification = "email_changed"
+
+	// AccountDeletionNotification is sent when a user schedules the
+	// deletion of their account, and isn't verified.
+	AccountDeletionNotification = "account_deletion"
+
+	// SecondaryEmailVerification is sent to a secondary email address a
+	// user adds, with the code to verify it.
+	SecondaryEmailVerification = "secondary_email"
+
+	// AccountLockedNotification is sent when password sign-ins to an
+	// account are locked after too many failed attempts, and isn't
+	// verified.
+	AccountLockedNotification = "account_locked"
 )
 
 const defaultInviteMail = `<h2>You have been invited</h2>
@@ -81,6 +137,39 @@ const defaultReauthenticateMail = `<h2>Confirm reauthentication</h2>
 
 <p>Enter the code: {{ .Token }}</p>`
 
+// The default templates used when emails are only verified with codes, which
+// don't carry links.
+const defaultInviteCodeMail = `<h2>You have been invited</h2>
+
+<p>You have been invited to create a user on {{ .SiteURL }}. Enter the code to accept the invite: {{ .Token }}</p>`
+
+const defaultConfirmationCodeMail = `<h2>Confirm your email</h2>
+
+<p>Enter the code to confirm your email address: {{ .Token }}</p>
+`
+
+const defaultRecoveryCodeMail = `<h2>Reset password</h2>
+
+<p>Enter the code to reset the password for your user: {{ .Token }}</p>`
+
+const defaultMagicLinkCodeMail = `<h2>Log In</h2>
+
+<p>Enter the code to login: {{ .Token }}</p>`
+
+const defaultEmailChangeCodeMail = `<h2>Confirm email address change</h2>
+
+<p>Enter the code to confirm the update of your email address from {{ .Email }} to {{ .NewEmail }}: {{ .Token }}</p>`
+
+// defaultMail returns the default template of an email, or the one without
+// links when emails are only verified with codes.
+func (m *TemplateMailer) defaultMail(linkTemplate, codeTemplate string) string {
+	if m.Config.Mailer.IsCodeVerification() {
+		return codeTemplate
+	}
+
+	return linkTemplate
+}
+
 // ValidateEmail returns nil if the email is valid,
 // otherwise an error indicating the reason it is invalid
 func (m TemplateMailer) ValidateEmail(email string) error {
@@ -109,11 +198,16 @@ func (m *TemplateMailer) InviteMail(r *http.Request, user *models.User, otp, ref
 		"RedirectTo":      referrerURL,
 	}
 
-	return m.Mailer.Mail(
+	subject, template := m.content(r, user, func(c *conf.EmailContentConfiguration) string {
+		return c.Invite
+	})
+
+	return m.mail(
+		InviteVerification,
 		user.GetEmail(),
-		withDefault(m.Config.Mailer.Subjects.Invite, "You have been invited"),
-		m.Config.Mailer.Templates.Invite,
-		defaultInviteMail,
+		withDefault(subject, "You have been invited"),
+		template,
+		m.defaultMail(defaultInviteMail, defaultInviteCodeMail),
 		data,
 	)
 }
@@ -139,11 +233,16 @@ func (m *TemplateMailer) ConfirmationMail(r *http.Request, user *models.User, ot
 		"RedirectTo":      referrerURL,
 	}
 
-	return m.Mailer.Mail(
+	subject, template := m.content(r, user, func(c *conf.EmailContentConfiguration) string {
+		return c.Confirmation
+	})
+
+	return m.mail(
+		SignupVerification,
 		user.GetEmail(),
-		withDefault(m.Config.Mailer.Subjects.Confirmation, "Confirm Your Email"),
-		m.Config.Mailer.Templates.Confirmation,
-		defaultConfirmationMail,
+		withDefault(subject, "Confirm Your Email"),
+		template,
+		m.defaultMail(defaultConfirmationMail, defaultConfirmationCodeMail),
 		data,
 	)
 }
@@ -157,15 +256,164 @@ func (m *TemplateMailer) ReauthenticateMail(r *http.Request, user *models.User,
 		"Data":    user.UserMetaData,
 	}
 
-	return m.Mailer.Mail(
+	subject, template := m.content(r, user, func(c *conf.EmailContentConfiguration) string {
+		return c.Reauthentication
+	})
+
+	return m.mail(
+		ReauthenticationVerification,
 		user.GetEmail(),
-		withDefault(m.Config.Mailer.Subjects.Reauthentication, "Confirm reauthentication"),
-		m.Config.Mailer.Templates.Reauthentication,
+		withDefault(subject, "Confirm reauthentication"),
+		template,
 		defaultReauthenticateMail,
 		data,
 	)
 }
 
+const defaultEmailChangedMail = `<h2>Your email address was changed</h2>
+
+<p>The email address of your user on {{ .SiteURL }} was changed from {{ .OldEmail }} to {{ .Email }}.</p>
+<p>If you didn't make this change, follow this link to undo it and sign out everywhere:</p>
+<p><a href="{{ .UndoURL }}">Undo the change</a></p>`
+
+// EmailChangedMail notifies the old email address of a user that it was
+// changed, with a link to undo the change.
+func (m *TemplateMailer) EmailChangedMail(r *http.Request, user *models.User, oldEmail, undoTokenHash string, externalURL *url.URL) error {
+	path, err := url.Parse("/email_change/undo")
+	if err != nil {
+		return err
+	}
+	path.RawQuery = url.Values{"token_hash": {undoTokenHash}}.Encode()
+
+	data := map[string]interface{}{
+		"SiteURL":   m.Config.SiteURL,
+		"UndoURL":   externalURL.ResolveReference(path).String(),
+		"Email":     user.GetEmail(),
+		"OldEmail":  oldEmail,
+		"TokenHash": undoTokenHash,
+		"Data":      user.UserMetaData,
+	}
+
+	subject, template := m.content(r, user, func(c *conf.EmailContentConfiguration) string {
+		return c.EmailChanged
+	})
+
+	return m.mail(
+		EmailChangedNotification,
+		oldEmail,
+		withDefault(subject, "Your Email Address Was Changed"),
+		template,
+		defaultEmailChangedMail,
+		data,
+	)
+}
+
+const defaultAccountDeletionMail = `<h2>Your account will be deleted</h2>
+
+<p>The deletion of your account on {{ .SiteURL }} was requested, and it will be deleted on {{ .DeleteAt.Format "January 2, 2006" }}.</p>
+<p>If you want to keep your account, or didn't request its deletion, follow this link to cancel it:</p>
+<p><a href="{{ .CancelURL }}">Keep my account</a></p>`
+
+// AccountDeletionMail confirms to a user that the deletion of their account
+// was scheduled, with a link to cancel it.
+func (m *TemplateMailer) AccountDeletionMail(r *http.Request, user *models.User, deleteAt time.Time, cancelTokenHash string, externalURL *url.URL) error {
+	path, err := url.Parse("/account_deletion/cancel")
+	if err != nil {
+		return err
+	}
+	path.RawQuery = url.Values{"token_hash": {cancelTokenHash}}.Encode()
+
+	data := map[string]interface{}{
+		"SiteURL":   m.Config.SiteURL,
+		"CancelURL": externalURL.ResolveReference(path).String(),
+		"Email":     user.GetEmail(),
+		"DeleteAt":  deleteAt,
+		"TokenHash": cancelTokenHash,
+		"Data":      user.UserMetaData,
+	}
+
+	subject, template := m.content(r, user, func(c *conf.EmailContentConfiguration) string {
+		return c.AccountDeletion
+	})
+
+	return m.mail(
+		AccountDeletionNotification,
+		user.GetEmail(),
+		withDefault(subject, "Your Account Will Be Deleted"),
+		template,
+		defaultAccountDeletionMail,
+		data,
+	)
+}
+
+const defaultSecondaryEmailMail = `<h2>Confirm your email address</h2>
+
+<p>The email address {{ .NewEmail }} was added to your user on {{ .SiteURL }}. Enter the code to confirm it: {{ .Token }}</p>`
+
+// SecondaryEmailMail sends the code to verify a secondary email address to
+// that address.
+func (m *TemplateMailer) SecondaryEmailMail(r *http.Request, user *models.User, email, otp string) error {
+	data := map[string]interface{}{
+		"SiteURL":  m.Config.SiteURL,
+		"Email":    user.GetEmail(),
+		"NewEmail": email,
+		"Token":    otp,
+		"Data":     user.UserMetaData,
+	}
+
+	subject, template := m.content(r, user, func(c *conf.EmailContentConfiguration) string {
+		return c.SecondaryEmail
+	})
+
+	return m.mail(
+		SecondaryEmailVerification,
+		email,
+		withDefault(subject, "Confirm Your Email Address"),
+		template,
+		defaultSecondaryEmailMail,
+		data,
+	)
+}
+
+const defaultAccountLockedMail = `<h2>Your account was locked</h2>
+
+<p>There were too many failed attempts to sign in to your account on {{ .SiteURL }} with a password, so password sign-ins are locked until {{ .LockedUntil.Format "January 2, 2006 15:04 MST" }}.</p>
+<p>If it was you, follow this link to unlock your account:</p>
+<p><a href="{{ .UnlockURL }}">Unlock my account</a></p>
+<p>If it wasn't you, consider changing your password.</p>`
+
+// AccountLockedMail tells a user that password sign-ins to their account
+// were locked, with a link to unlock it.
+func (m *TemplateMailer) AccountLockedMail(r *http.Request, user *models.User, lockedUntil time.Time, unlockTokenHash string, externalURL *url.URL) error {
+	path, err := url.Parse("/account_lockout/unlock")
+	if err != nil {
+		return err
+	}
+	path.RawQuery = url.Values{"token_hash": {unlockTokenHash}}.Encode()
+
+	data := map[string]interface{}{
+		"SiteURL":     m.Config.SiteURL,
+		"UnlockURL":   externalURL.ResolveReference(path).String(),
+		"Email":       user.GetEmail(),
+		"LockedUntil": lockedUntil,
+		"TokenHash":   unlockTokenHash,
+		"Data":        user.UserMetaData,
+	}
+
+	subject, template := m.content(r, user, func(c *conf.EmailContentConfiguration) string {
+		return c.AccountLocked
+	})
+
+	return m.mail(
+		AccountLockedNotification,
+		user.GetEmail(),
+		withDefault(subject, "Your Account Was Locked"),
+		template,
+		defaultAccountLockedMail,
+		data,
+	)
+}
+
 // EmailChangeMail sends an email change confirmation mail to a user
 func (m *TemplateMailer) EmailChangeMail(r *http.Request, user *models.User, otpNew, otpCurrent, referrerURL string, externalURL *url.URL) error {
 	type Email struct {
@@ -175,13 +423,18 @@ func (m *TemplateMailer) EmailChangeMail(r *http.Request, user *models.User, otp
 		Subject   string
 		Template  string
 	}
+
+	subject, template := m.content(r, user, func(c *conf.EmailContentConfiguration) string {
+		return c.EmailChange
+	})
+
 	emails := []Email{
 		{
 			Address:   user.EmailChange,
 			Otp:       otpNew,
 			TokenHash: user.EmailChangeTokenNew,
-			Subject:   withDefault(m.Config.Mailer.Subjects.EmailChange, "Confirm Email Change"),
-			Template:  m.Config.Mailer.Templates.EmailChange,
+			Subject:   withDefault(subject, "Confirm Email Change"),
+			Template:  template,
 		},
 	}
 
@@ -192,7 +445,7 @@ func (m *TemplateMailer) EmailChangeMail(r *http.Request, user *models.User, otp
 			Otp:       otpCurrent,
 			TokenHash: user.EmailChangeTokenCurrent,
 			Subject:   withDefault(m.Config.Mailer.Subjects.Confirmation, "Confirm Email Address"),
-			Template:  m.Config.Mailer.Templates.EmailChange,
+			Template:  template,
 		})
 	}
 
@@ -221,11 +474,12 @@ func (m *TemplateMailer) EmailChangeMail(r *http.Request, user *models.User, otp
 				"Data":            user.UserMetaData,
 				"RedirectTo":      referrerURL,
 			}
-			errors <- m.Mailer.Mail(
+			errors <- m.mail(
+				EmailChangeVerification,
 				address,
-				withDefault(m.Config.Mailer.Subjects.EmailChange, "Confirm Email Change"),
+				withDefault(subject, "Confirm Email Change"),
 				template,
-				defaultEmailChangeMail,
+				m.defaultMail(defaultEmailChangeMail, defaultEmailChangeCodeMail),
 				data,
 			)
 		}(email.Address, email.Otp, email.TokenHash, email.Template)
@@ -261,11 +515,16 @@ func (m *TemplateMailer) RecoveryMail(r *http.Request, user *models.User, otp, r
 		"RedirectTo":      referrerURL,
 	}
 
-	return m.Mailer.Mail(
+	subject, template := m.content(r, user, func(c *conf.EmailContentConfiguration) string {
+		return c.Recovery
+	})
+
+	return m.mail(
+		RecoveryVerification,
 		user.GetEmail(),
-		withDefault(m.Config.Mailer.Subjects.Recovery, "Reset Your Password"),
-		m.Config.Mailer.Templates.Recovery,
-		defaultRecoveryMail,
+		withDefault(subject, "Reset Your Password"),
+		template,
+		m.defaultMail(defaultRecoveryMail, defaultRecoveryCodeMail),
 		data,
 	)
 }
@@ -291,15 +550,92 @@ func (m *TemplateMailer) MagicLinkMail(r *http.Request, user *models.User, otp,
 		"RedirectTo":      referrerURL,
 	}
 
-	return m.Mailer.Mail(
+	subject, template := m.content(r, user, func(c *conf.EmailContentConfiguration) string {
+		return c.MagicLink
+	})
+
+	return m.mail(
+		MagicLinkVerification,
 		user.GetEmail(),
-		withDefault(m.Config.Mailer.Subjects.MagicLink, "Your Magic Link"),
-		m.Config.Mailer.Templates.MagicLink,
-		defaultMagicLinkMail,
+		withDefault(subject, "Your Magic Link"),
+		template,
+		m.defaultMail(defaultMagicLinkMail, defaultMagicLinkCodeMail),
 		data,
 	)
 }
 
+// content returns the subject and template of an email in the locale of the
+// user, as selected by field, falling back to the ones of the mailer
+// configuration.
+func (m *TemplateMailer) content(r *http.Request, user *models.User, field func(*conf.EmailContentConfiguration) string) (string, string) {
+	subject, template := field(&m.Config.Mailer.Subjects), field(&m.Config.Mailer.Templates)
+
+	var acceptLanguage string
+	if r != nil {
+		acceptLanguage = r.Header.Get("Accept-Language")
+	}
+
+	if locale := m.Config.Localization.UserLocale(user.UserMetaData, acceptLanguage); locale != "" {
+		localizedSubject, localizedTemplate := m.Config.Localization.EmailContent(locale, field)
+		subject = withDefault(localizedSubject, subject)
+		template = withDefault(localizedTemplate, template)
+	}
+
+	return subject, template
+}
+
+// mail sends an email of the type, which is only passed to mail clients that
+// need it.
+func (m *TemplateMailer) mail(emailType, to, subjectTemplate, templateURL, defaultTemplate string, templateData map[string]interface{}) error {
+	if m.IsSuppressed != nil {
+		suppressed, err := m.IsSuppressed(to)
+		if err != nil {
+			return err
+		}
+
+		if suppressed {
+			// suppressed addresses bounced or complained, so sending to
+			// them again only hurts the reputation of the sender
+			logrus.WithField("email_type", emailType).Warn("Email not sent to a suppressed address")
+			suppressedEmailCounter.Add(context.Background(), 1, metric.WithAttributeSet(attribute.NewSet(attribute.String("email_type", emailType))))
+			return nil
+		}
+	}
+
+	if m.ShortenURL != nil {
+		for _, key := range shortenedLinks {
+			longURL, ok := templateData[key].(string)
+			if !ok || longURL == "" {
+				continue
+			}
+
+			shortURL, err := m.ShortenURL(longURL)
+			if err != nil {
+				return err
+			}
+			templateData[key] = shortURL
+		}
+	}
+
+	if client, ok := m.Mailer.(TrackedMailClient); ok && m.OnSent != nil {
+		messageID, err := client.MailWithID(emailType, to, subjectTemplate, templateURL, defaultTemplate, templateData)
+		if err != nil {
+			return err
+		}
+
+		if messageID != "" {
+			m.OnSent(emailType, to, messageID)
+		}
+		return nil
+	}
+
+	if client, ok := m.Mailer.(TypedMailClient); ok {
+		return client.MailWithType(emailType, to, subjectTemplate, templateURL, defaultTemplate, templateData)
+	}
+
+	return m.Mailer.Mail(to, subjectTemplate, templateURL, defaultTemplate, templateData)
+}
+
 // Send can be used to send one-off emails to users
 func (m TemplateMailer) Send(user *models.User, subject, body string, data map[string]interface{}) error {
 	return m.Mailer.Mail(
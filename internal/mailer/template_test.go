@@ -0,0 +1,325 @@
+package mailer
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/models"
+	"github.com/supabase/auth/internal/storage"
+)
+
+type recordingMailClient struct {
+	to               []string
+	subjects         []string
+	templates        []string
+	defaultTemplates []string
+	data             []map[string]interface{}
+}
+
+func (c *recordingMailClient) Mail(to, subjectTemplate, templateURL, defaultTemplate string, templateData map[string]interface{}) error {
+	c.to = append(c.to, to)
+	c.subjects = append(c.subjects, subjectTemplate)
+	c.templates = append(c.templates, templateURL)
+	c.defaultTemplates = append(c.defaultTemplates, defaultTemplate)
+	c.data = append(c.data, templateData)
+	return nil
+}
+
+func TestTemplateMailerLocalization(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "fr.json"), []byte(`{
+		"subjects": {"magic_link": "Votre lien magique"},
+		"templates": {"magic_link": "https://example.com/fr/magic-link.html"}
+	}`), 0600))
+
+	config := &conf.GlobalConfiguration{
+		Mailer: conf.MailerConfiguration{
+			Subjects: conf.EmailContentConfiguration{
+				Recovery: "Reset your password",
+			},
+		},
+		Localization: conf.LocalizationConfiguration{
+			Enabled:       true,
+			DefaultLocale: "en",
+			MetadataKey:   "locale",
+			Path:          dir,
+		},
+	}
+	require.NoError(t, config.Localization.PopulateFields())
+
+	client := &recordingMailClient{}
+	mailer := &TemplateMailer{
+		Config: config,
+		Mailer: client,
+	}
+
+	externalURL, err := url.Parse("https://auth.example.com/auth/v1/")
+	require.NoError(t, err)
+
+	user := &models.User{
+		Email: storage.NullString("user@example.com"),
+		UserMetaData: map[string]interface{}{
+			"locale": "fr",
+		},
+	}
+
+	require.NoError(t, mailer.MagicLinkMail(nil, user, "123456", "", externalURL))
+	assert.Equal(t, "Votre lien magique", client.subjects[0])
+	assert.Equal(t, "https://example.com/fr/magic-link.html", client.templates[0])
+
+	// emails without a localized subject fall back to the mailer
+	// configuration
+	require.NoError(t, mailer.RecoveryMail(nil, user, "123456", "", externalURL))
+	assert.Equal(t, "Reset your password", client.subjects[1])
+	assert.Empty(t, client.templates[1])
+
+	// users without a locale get the one of the Accept-Language header
+	user.UserMetaData = nil
+	req := httptest.NewRequest("POST", "/otp", nil)
+	req.Header.Set("Accept-Language", "fr-CA,fr;q=0.9")
+
+	require.NoError(t, mailer.MagicLinkMail(req, user, "123456", "", externalURL))
+	assert.Equal(t, "Votre lien magique", client.subjects[2])
+
+	req.Header.Set("Accept-Language", "de")
+	require.NoError(t, mailer.MagicLinkMail(req, user, "123456", "", externalURL))
+	assert.Equal(t, "Your Magic Link", client.subjects[3])
+}
+
+func TestTemplateMailerCodeVerification(t *testing.T) {
+	config := &conf.GlobalConfiguration{}
+
+	client := &recordingMailClient{}
+	mailer := &TemplateMailer{
+		Config: config,
+		Mailer: client,
+	}
+
+	externalURL, err := url.Parse("https://auth.example.com/auth/v1/")
+	require.NoError(t, err)
+
+	user := &models.User{
+		Email: storage.NullString("user@example.com"),
+	}
+
+	require.NoError(t, mailer.MagicLinkMail(nil, user, "123456", "", externalURL))
+	assert.Equal(t, defaultMagicLinkMail, client.defaultTemplates[0])
+
+	// emails verified with codes only don't carry links
+	config.Mailer.VerificationMethod = "code"
+
+	require.NoError(t, mailer.MagicLinkMail(nil, user, "123456", "", externalURL))
+	require.NoError(t, mailer.RecoveryMail(nil, user, "123456", "", externalURL))
+	require.NoError(t, mailer.ConfirmationMail(nil, user, "123456", "", externalURL))
+	require.NoError(t, mailer.InviteMail(nil, user, "123456", "", externalURL))
+
+	for _, template := range client.defaultTemplates[1:] {
+		assert.Contains(t, template, "{{ .Token }}")
+		assert.NotContains(t, template, "ConfirmationURL")
+	}
+}
+
+func TestTemplateMailerEmailChanged(t *testing.T) {
+	config := &conf.GlobalConfiguration{}
+
+	client := &recordingMailClient{}
+	mailer := &TemplateMailer{
+		Config: config,
+		Mailer: client,
+	}
+
+	externalURL, err := url.Parse("https://auth.example.com/auth/v1/")
+	require.NoError(t, err)
+
+	user := &models.User{
+		Email: storage.NullString("new@example.com"),
+	}
+
+	require.NoError(t, mailer.EmailChangedMail(nil, user, "old@example.com", "token-hash", externalURL))
+	assert.Equal(t, "old@example.com", client.to[0])
+	assert.Equal(t, "Your Email Address Was Changed", client.subjects[0])
+	assert.Equal(t, "https://auth.example.com/email_change/undo?token_hash=token-hash", client.data[0]["UndoURL"])
+}
+
+func TestOutboxMailer(t *testing.T) {
+	var queued []*OutboxEmail
+	mailer := NewOutboxMailer(&conf.GlobalConfiguration{}, func(email *OutboxEmail) error {
+		queued = append(queued, email)
+		return nil
+	})
+
+	externalURL, err := url.Parse("https://auth.example.com/auth/v1/")
+	require.NoError(t, err)
+
+	user := &models.User{
+		Email: storage.NullString("user@example.com"),
+	}
+
+	// emails are queued instead of being sent
+	require.NoError(t, mailer.MagicLinkMail(nil, user, "123456", "", externalURL))
+	require.Len(t, queued, 1)
+	assert.Equal(t, MagicLinkVerification, queued[0].Type)
+	assert.Equal(t, "user@example.com", queued[0].To)
+
+	// and sent with the same arguments later
+	client := &recordingMailClient{}
+	_, err = queued[0].Send(client)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user@example.com"}, client.to)
+	assert.Equal(t, []string{"Your Magic Link"}, client.subjects)
+	assert.Equal(t, "123456", client.data[0]["Token"])
+}
+
+func TestTemplateMailerSuppression(t *testing.T) {
+	client := &recordingMailClient{}
+	mailer := &TemplateMailer{
+		SiteURL: "https://example.com",
+		Config:  &conf.GlobalConfiguration{},
+		Mailer:  client,
+		IsSuppressed: func(address string) (bool, error) {
+			return address == "bounced@example.com", nil
+		},
+	}
+
+	externalURL, err := url.Parse("https://auth.example.com/auth/v1/")
+	require.NoError(t, err)
+
+	// emails to suppressed addresses are dropped without an error
+	require.NoError(t, mailer.MagicLinkMail(nil, &models.User{Email: storage.NullString("bounced@example.com")}, "123456", "", externalURL))
+	assert.Empty(t, client.to)
+
+	require.NoError(t, mailer.MagicLinkMail(nil, &models.User{Email: storage.NullString("user@example.com")}, "123456", "", externalURL))
+	assert.Equal(t, []string{"user@example.com"}, client.to)
+}
+
+func TestTemplateMailerShortLinks(t *testing.T) {
+	client := &recordingMailClient{}
+	mailer := &TemplateMailer{
+		SiteURL: "https://example.com",
+		Config:  &conf.GlobalConfiguration{},
+		Mailer:  client,
+		ShortenURL: func(longURL string) (string, error) {
+			assert.Contains(t, longURL, "token=recovery-token-hash")
+			return "https://go.example.com/abcdefghij", nil
+		},
+	}
+
+	externalURL, err := url.Parse("https://auth.example.com/auth/v1/")
+	require.NoError(t, err)
+
+	user := &models.User{
+		Email:         storage.NullString("user@example.com"),
+		RecoveryToken: "recovery-token-hash",
+	}
+
+	require.NoError(t, mailer.MagicLinkMail(nil, user, "123456", "", externalURL))
+	require.Len(t, client.data, 1)
+	assert.Equal(t, "https://go.example.com/abcdefghij", client.data[0]["ConfirmationURL"])
+	assert.Equal(t, "123456", client.data[0]["Token"])
+}
+
+func TestTemplateMailerRenderTemplate(t *testing.T) {
+	config := &conf.GlobalConfiguration{
+		SiteURL: "https://example.com",
+	}
+	config.Mailer.Subjects.MagicLink = "Log in to {{ .SiteURL }}"
+
+	client := &recordingMailClient{}
+	mailer := &TemplateMailer{
+		Config: config,
+		Mailer: client,
+	}
+
+	externalURL, err := url.Parse("https://auth.example.com/")
+	require.NoError(t, err)
+
+	// the supplied data takes precedence over the sample data
+	email, err := mailer.RenderTemplate(nil, "magic_link", map[string]interface{}{"Token": "654321"}, externalURL)
+	require.NoError(t, err)
+	assert.Equal(t, "Log in to https://example.com", email.Subject)
+	assert.Contains(t, email.Body, "654321")
+	assert.Contains(t, email.Body, "https://auth.example.com/?token=sample&amp;type=signup")
+
+	_, err = mailer.RenderTemplate(nil, "unknown", nil, externalURL)
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+
+	require.NoError(t, mailer.SendTestMail(nil, "email_changed", "admin@example.com", nil, externalURL))
+	assert.Equal(t, []string{"admin@example.com"}, client.to)
+	assert.Equal(t, []string{"Your Email Address Was Changed"}, client.subjects)
+	assert.Equal(t, "old@example.com", client.data[0]["OldEmail"])
+}
+
+func TestTemplateMailerAccountDeletion(t *testing.T) {
+	config := &conf.GlobalConfiguration{}
+
+	client := &recordingMailClient{}
+	mailer := &TemplateMailer{
+		Config: config,
+		Mailer: client,
+	}
+
+	externalURL, err := url.Parse("https://auth.example.com/auth/v1/")
+	require.NoError(t, err)
+
+	user := &models.User{
+		Email: storage.NullString("user@example.com"),
+	}
+
+	deleteAt := time.Date(2024, time.December, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, mailer.AccountDeletionMail(nil, user, deleteAt, "token-hash", externalURL))
+	assert.Equal(t, "user@example.com", client.to[0])
+	assert.Equal(t, "Your Account Will Be Deleted", client.subjects[0])
+	assert.Equal(t, "https://auth.example.com/account_deletion/cancel?token_hash=token-hash", client.data[0]["CancelURL"])
+	assert.Equal(t, deleteAt, client.data[0]["DeleteAt"])
+}
+
+func TestTemplateMailerAccountLocked(t *testing.T) {
+	config := &conf.GlobalConfiguration{}
+
+	client := &recordingMailClient{}
+	mailer := &TemplateMailer{
+		Config: config,
+		Mailer: client,
+	}
+
+	externalURL, err := url.Parse("https://auth.example.com/auth/v1/")
+	require.NoError(t, err)
+
+	user := &models.User{
+		Email: storage.NullString("user@example.com"),
+	}
+
+	lockedUntil := time.Date(2024, time.December, 1, 12, 30, 0, 0, time.UTC)
+	require.NoError(t, mailer.AccountLockedMail(nil, user, lockedUntil, "token-hash", externalURL))
+	assert.Equal(t, "user@example.com", client.to[0])
+	assert.Equal(t, "Your Account Was Locked", client.subjects[0])
+	assert.Equal(t, "https://auth.example.com/account_lockout/unlock?token_hash=token-hash", client.data[0]["UnlockURL"])
+	assert.Equal(t, lockedUntil, client.data[0]["LockedUntil"])
+}
+
+func TestTemplateMailerSecondaryEmail(t *testing.T) {
+	config := &conf.GlobalConfiguration{}
+
+	client := &recordingMailClient{}
+	mailer := &TemplateMailer{
+		Config: config,
+		Mailer: client,
+	}
+
+	user := &models.User{
+		Email: storage.NullString("user@example.com"),
+	}
+
+	require.NoError(t, mailer.SecondaryEmailMail(nil, user, "work@example.com", "123456"))
+	assert.Equal(t, "work@example.com", client.to[0])
+	assert.Equal(t, "Confirm Your Email Address", client.subjects[0])
+	assert.Equal(t, "user@example.com", client.data[0]["Email"])
+	assert.Equal(t, "123456", client.data[0]["Token"])
+}
@@ -0,0 +1,324 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// templateFetchTimeout limits how long fetching a template can take.
+	templateFetchTimeout = 10 * time.Second
+
+	// templateFetchAttempts is how many times a template that was never
+	// fetched is requested before the default template is used instead.
+	templateFetchAttempts = 3
+
+	// templateRetryInterval is how long the last good version of a template
+	// is used after it could not be revalidated, before trying again.
+	templateRetryInterval = 10 * time.Second
+
+	// templateMaxSize limits the size of the fetched templates.
+	templateMaxSize = 1 << 20
+)
+
+var templateFetchCounter = observability.ObtainMetricCounter("gotrue_mailer_template_fetches", "Number of email template fetches by result")
+
+// cachedTemplate is the last good version of a template fetched from a URL.
+// Its source is parsed when rendering, as it's combined with the layout and
+// partials.
+type cachedTemplate struct {
+	source       string
+	etag         string
+	lastModified string
+	expiresAt    time.Time
+}
+
+// templateCache caches the templates fetched from URLs for all mailers, and
+// revalidates them with their ETag or Last-Modified header once expired. When
+// a template can't be fetched its last good version is used, or the default
+// template if it was never fetched. Concurrent misses of a template share
+// one fetch.
+type templateCache struct {
+	mutex     sync.Mutex
+	templates map[string]*cachedTemplate
+	fetches   singleflight.Group
+
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+var defaultTemplateCache = newTemplateCache()
+
+func newTemplateCache() *templateCache {
+	return &templateCache{
+		templates: make(map[string]*cachedTemplate),
+		httpClient: &http.Client{
+			Timeout: templateFetchTimeout,
+		},
+		now: time.Now,
+	}
+}
+
+// get returns the source of the template of the URL, fetching it when it's
+// not cached or expired.
+func (c *templateCache) get(url string, ttl time.Duration) (string, error) {
+	c.mutex.Lock()
+	cached := c.templates[url]
+	c.mutex.Unlock()
+
+	if cached != nil && c.now().Before(cached.expiresAt) {
+		return cached.source, nil
+	}
+
+	source, err, _ := c.fetches.Do(url, func() (interface{}, error) {
+		return c.refresh(url, ttl)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return source.(string), nil
+}
+
+// refresh fetches the template of the URL, unless it was fetched since it
+// was found expired, and caches it.
+func (c *templateCache) refresh(url string, ttl time.Duration) (string, error) {
+	c.mutex.Lock()
+	cached := c.templates[url]
+	c.mutex.Unlock()
+
+	if cached != nil && c.now().Before(cached.expiresAt) {
+		return cached.source, nil
+	}
+
+	attempts := templateFetchAttempts
+	if cached != nil {
+		// the last good version is used rather than making the user wait
+		// on retries
+		attempts = 1
+	}
+
+	var fetched *cachedTemplate
+	var err error
+	for i := 0; i < attempts; i++ {
+		if fetched, err = c.fetch(url, cached); err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		if cached == nil {
+			templateFetchCounter.Add(context.Background(), 1, metric.WithAttributeSet(attribute.NewSet(attribute.String("result", "failed"))))
+			return "", err
+		}
+
+		templateFetchCounter.Add(context.Background(), 1, metric.WithAttributeSet(attribute.NewSet(attribute.String("result", "stale"))))
+		logrus.WithError(err).WithField("url", url).Warn("Unable to revalidate email template, using the last good version")
+
+		fetched = &cachedTemplate{
+			source:       cached.source,
+			etag:         cached.etag,
+			lastModified: cached.lastModified,
+			expiresAt:    c.now().Add(templateRetryInterval),
+		}
+	} else {
+		fetched.expiresAt = c.now().Add(ttl)
+	}
+
+	c.mutex.Lock()
+	c.templates[url] = fetched
+	c.mutex.Unlock()
+
+	return fetched.source, nil
+}
+
+// fetch requests the template of the URL, conditionally when there's a
+// cached version.
+func (c *templateCache) fetch(url string, cached *cachedTemplate) (*cachedTemplate, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached != nil {
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		templateFetchCounter.Add(context.Background(), 1, metric.WithAttributeSet(attribute.NewSet(attribute.String("result", "not_modified"))))
+
+		return &cachedTemplate{
+			source:       cached.source,
+			etag:         withDefault(resp.Header.Get("ETag"), cached.etag),
+			lastModified: withDefault(resp.Header.Get("Last-Modified"), cached.lastModified),
+		}, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("mailer: template responded with HTTP status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, templateMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(body) > templateMaxSize {
+		return nil, errors.New("mailer: template is too large")
+	}
+
+	// templates that don't parse are treated like failed fetches, so the
+	// last good version keeps being used
+	if _, err := template.New(url).Funcs(templateFuncs).Parse(string(body)); err != nil {
+		return nil, fmt.Errorf("mailer: template is invalid: %w", err)
+	}
+
+	templateFetchCounter.Add(context.Background(), 1, metric.WithAttributeSet(attribute.NewSet(attribute.String("result", "fetched"))))
+
+	return &cachedTemplate{
+		source:       string(body),
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// templateRenderer renders the bodies of emails from their templates, which
+// are fetched from their URL or the default ones, within the layout.
+type templateRenderer struct {
+	baseURL  string
+	layout   string
+	partials string
+	ttl      time.Duration
+	cache    *templateCache
+}
+
+func newTemplateRenderer(globalConfig *conf.GlobalConfiguration) *templateRenderer {
+	return &templateRenderer{
+		baseURL:  globalConfig.SiteURL,
+		layout:   globalConfig.Mailer.Layout,
+		partials: globalConfig.Mailer.Partials,
+		ttl:      globalConfig.Mailer.TemplatesCacheTTL,
+		cache:    defaultTemplateCache,
+	}
+}
+
+// absoluteURL resolves template URLs relative to the site URL.
+func (r *templateRenderer) absoluteURL(templateURL string) string {
+	if strings.HasPrefix(templateURL, "http") {
+		return templateURL
+	}
+
+	return r.baseURL + templateURL
+}
+
+// source returns the source of the template of the URL, or the default
+// source when there's no URL or it was never fetched.
+func (r *templateRenderer) source(templateURL, defaultSource string) string {
+	if templateURL == "" {
+		return defaultSource
+	}
+
+	source, err := r.cache.get(r.absoluteURL(templateURL), r.ttl)
+	if err != nil {
+		logrus.WithError(err).WithField("url", templateURL).Error("Unable to fetch email template, using the default template")
+		return defaultSource
+	}
+
+	return source
+}
+
+// MailBody renders the body of an email from the template of the URL, or the
+// default template when there's no URL or it was never fetched. The template
+// is the content block of the layout, and can use the partials.
+func (r *templateRenderer) MailBody(templateURL, defaultTemplate string, data map[string]interface{}) (string, error) {
+	tmpl, err := template.New("layout").Funcs(templateFuncs).Parse(r.source(r.layout, defaultLayout))
+	if err != nil {
+		return "", err
+	}
+
+	if r.partials != "" {
+		if _, err := tmpl.New("partials").Parse(r.source(r.partials, "")); err != nil {
+			return "", err
+		}
+	}
+
+	// templates without a content block are the content block, so the
+	// templates that don't use the layout don't change
+	if _, err := tmpl.New("content").Parse(r.source(templateURL, defaultTemplate)); err != nil {
+		return "", err
+	}
+
+	body := &bytes.Buffer{}
+	if err := tmpl.ExecuteTemplate(body, "layout", data); err != nil {
+		return "", err
+	}
+
+	return body.String(), nil
+}
+
+// PrefetchTemplates fetches the templates, layout and partials of the mailer
+// and localization configuration, so the first emails don't wait on them.
+func PrefetchTemplates(globalConfig *conf.GlobalConfiguration) {
+	renderer := newTemplateRenderer(globalConfig)
+
+	urls := []string{
+		globalConfig.Mailer.Layout,
+		globalConfig.Mailer.Partials,
+	}
+
+	addContent := func(templates *conf.EmailContentConfiguration) {
+		urls = append(urls,
+			templates.Invite,
+			templates.Confirmation,
+			templates.Recovery,
+			templates.EmailChange,
+			templates.MagicLink,
+			templates.Reauthentication,
+			templates.EmailChanged,
+			templates.AccountDeletion,
+			templates.SecondaryEmail,
+			templates.AccountLocked,
+		)
+	}
+
+	addContent(&globalConfig.Mailer.Templates)
+	for _, locale := range globalConfig.Localization.Locales {
+		addContent(&locale.Templates)
+	}
+
+	for _, templateURL := range urls {
+		if templateURL == "" {
+			continue
+		}
+
+		if _, err := renderer.cache.get(renderer.absoluteURL(templateURL), renderer.ttl); err != nil {
+			logrus.WithError(err).WithField("url", templateURL).Warn("Unable to prefetch email template")
+		}
+	}
+}
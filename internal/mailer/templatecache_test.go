@@ -0,0 +1,202 @@
+package mailer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateRenderer(t *testing.T) {
+	var requests, notModified int
+	var failing bool
+	template := `<p>Hello {{ .Name }}</p>`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		if r.Header.Get("If-None-Match") == `"v1"` && template == `<p>Hello {{ .Name }}</p>` {
+			notModified += 1
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		if template == `<p>Bonjour {{ .Name }}</p>` {
+			w.Header().Set("ETag", `"v2"`)
+		} else {
+			w.Header().Set("ETag", `"v1"`)
+		}
+		_, _ = w.Write([]byte(template))
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	cache := newTemplateCache()
+	cache.now = func() time.Time {
+		return now
+	}
+
+	renderer := &templateRenderer{
+		baseURL: server.URL,
+		ttl:     time.Minute,
+		cache:   cache,
+	}
+
+	data := map[string]interface{}{"Name": "Jane"}
+
+	body, err := renderer.MailBody("/welcome.html", "<p>Default</p>", data)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>Hello Jane</p>", body)
+	assert.Equal(t, 1, requests)
+
+	// templates are cached until they expire
+	body, err = renderer.MailBody("/welcome.html", "<p>Default</p>", data)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>Hello Jane</p>", body)
+	assert.Equal(t, 1, requests)
+
+	// expired templates are revalidated with their ETag
+	now = now.Add(2 * time.Minute)
+	body, err = renderer.MailBody("/welcome.html", "<p>Default</p>", data)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>Hello Jane</p>", body)
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, 1, notModified)
+
+	now = now.Add(2 * time.Minute)
+	template = `<p>Bonjour {{ .Name }}</p>`
+	body, err = renderer.MailBody("/welcome.html", "<p>Default</p>", data)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>Bonjour Jane</p>", body)
+	assert.Equal(t, 3, requests)
+
+	// the last good version is used when the template can't be fetched,
+	// and fetched again after the retry interval
+	failing = true
+	now = now.Add(2 * time.Minute)
+	body, err = renderer.MailBody("/welcome.html", "<p>Default</p>", data)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>Bonjour Jane</p>", body)
+	assert.Equal(t, 4, requests)
+
+	body, err = renderer.MailBody("/welcome.html", "<p>Default</p>", data)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>Bonjour Jane</p>", body)
+	assert.Equal(t, 4, requests)
+
+	now = now.Add(templateRetryInterval)
+	_, err = renderer.MailBody("/welcome.html", "<p>Default</p>", data)
+	require.NoError(t, err)
+	assert.Equal(t, 5, requests)
+
+	// templates that were never fetched fall back to the default template
+	// after retrying
+	body, err = renderer.MailBody("/other.html", "<p>Default {{ .Name }}</p>", data)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>Default Jane</p>", body)
+	assert.Equal(t, 5+templateFetchAttempts, requests)
+
+	// invalid templates are not used
+	failing = false
+	template = `<p>Hello {{ .Name </p>`
+	now = now.Add(2 * time.Minute)
+	body, err = renderer.MailBody("/welcome.html", "<p>Default</p>", data)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>Bonjour Jane</p>", body)
+}
+
+func TestTemplateCacheConcurrentMisses(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		_, _ = w.Write([]byte(`<p>Hello {{ .Name }}</p>`))
+	}))
+	defer server.Close()
+
+	cache := newTemplateCache()
+
+	var wg sync.WaitGroup
+	sources := make([]string, 10)
+	for i := range sources {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			source, err := cache.get(server.URL+"/welcome.html", time.Minute)
+			assert.NoError(t, err)
+			sources[i] = source
+		}(i)
+	}
+
+	// the misses wait on the first fetch rather than fetching it too
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), requests.Load())
+	for _, source := range sources {
+		assert.Equal(t, `<p>Hello {{ .Name }}</p>`, source)
+	}
+}
+
+func TestTemplateRendererLayout(t *testing.T) {
+	templates := map[string]string{
+		"/layout.html":   `<html>{{ template "content" . }}{{ template "footer" . }}</html>`,
+		"/partials.html": `{{ define "footer" }}<footer>{{ .SiteURL }}</footer>{{ end }}`,
+		"/block.html":    `{{ define "content" }}<p>{{ if eq (metadata .Data "plan") "pro" }}Pro{{ else }}Free{{ end }}</p>{{ end }}`,
+		"/plain.html":    `<p>Hello {{ default "there" (metadata .Data "name") }}</p>`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(templates[r.URL.Path]))
+	}))
+	defer server.Close()
+
+	renderer := &templateRenderer{
+		baseURL:  server.URL,
+		layout:   "/layout.html",
+		partials: "/partials.html",
+		ttl:      time.Minute,
+		cache:    newTemplateCache(),
+	}
+
+	data := map[string]interface{}{
+		"SiteURL": "https://example.com",
+		"Data": map[string]interface{}{
+			"plan": "pro",
+		},
+	}
+
+	// templates can define the content block, or be the content block
+	body, err := renderer.MailBody("/block.html", "", data)
+	require.NoError(t, err)
+	assert.Equal(t, `<html><p>Pro</p><footer>https://example.com</footer></html>`, body)
+
+	body, err = renderer.MailBody("/plain.html", "", data)
+	require.NoError(t, err)
+	assert.Equal(t, `<html><p>Hello there</p><footer>https://example.com</footer></html>`, body)
+
+	// default templates are rendered in the layout too
+	body, err = renderer.MailBody("", "<p>Default</p>", data)
+	require.NoError(t, err)
+	assert.Equal(t, `<html><p>Default</p><footer>https://example.com</footer></html>`, body)
+
+	// without a layout templates are rendered as is
+	renderer.layout, renderer.partials = "", ""
+	body, err = renderer.MailBody("/plain.html", "", data)
+	require.NoError(t, err)
+	assert.Equal(t, `<p>Hello there</p>`, body)
+}
@@ -0,0 +1,105 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// defaultLayout renders the content of emails as is, when no layout is
+// configured.
+const defaultLayout = `{{ template "content" . }}`
+
+// templateFuncs are the functions available to the subjects, templates,
+// layout and partials of emails.
+var templateFuncs = map[string]interface{}{
+	"now":          time.Now,
+	"formatDate":   formatDate,
+	"urlWithQuery": urlWithQuery,
+	"default":      defaultValue,
+	"metadata":     metadataValue,
+	"lower":        strings.ToLower,
+	"upper":        strings.ToUpper,
+	"trim":         strings.TrimSpace,
+}
+
+// formatDate formats a time, or a RFC 3339 timestamp, with the Go time
+// layout. Empty values are formatted as an empty string.
+func formatDate(layout string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+
+	case time.Time:
+		return v.Format(layout), nil
+
+	case *time.Time:
+		if v == nil {
+			return "", nil
+		}
+		return v.Format(layout), nil
+
+	case string:
+		if v == "" {
+			return "", nil
+		}
+
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return "", fmt.Errorf("formatDate: %q is not a RFC 3339 timestamp", v)
+		}
+		return t.Format(layout), nil
+	}
+
+	return "", fmt.Errorf("formatDate: unable to format %T", value)
+}
+
+// urlWithQuery adds the query parameters, given as pairs of names and
+// values, to the URL.
+func urlWithQuery(rawURL string, pairs ...string) (string, error) {
+	if len(pairs)%2 != 0 {
+		return "", errors.New("urlWithQuery: query parameters must be pairs of names and values")
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	for i := 0; i < len(pairs); i += 2 {
+		query.Set(pairs[i], pairs[i+1])
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// defaultValue returns the value, or the default value when the value is
+// empty.
+func defaultValue(defaultValue, value interface{}) interface{} {
+	if value == nil {
+		return defaultValue
+	}
+
+	if v := reflect.ValueOf(value); v.IsZero() || ((v.Kind() == reflect.Map || v.Kind() == reflect.Slice) && v.Len() == 0) {
+		return defaultValue
+	}
+
+	return value
+}
+
+// metadataValue returns the value of the key in user metadata as a string,
+// or an empty string when it's not set, so it can be compared in
+// conditionals.
+func metadataValue(metadata map[string]interface{}, key string) string {
+	value, ok := metadata[key]
+	if !ok || value == nil {
+		return ""
+	}
+
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	date := time.Date(2024, 3, 14, 15, 9, 26, 0, time.UTC)
+
+	formatted, err := formatDate("2006-01-02", date)
+	require.NoError(t, err)
+	assert.Equal(t, "2024-03-14", formatted)
+
+	formatted, err = formatDate("Jan 2, 2006", &date)
+	require.NoError(t, err)
+	assert.Equal(t, "Mar 14, 2024", formatted)
+
+	formatted, err = formatDate("2006-01-02", "2024-03-14T15:09:26Z")
+	require.NoError(t, err)
+	assert.Equal(t, "2024-03-14", formatted)
+
+	formatted, err = formatDate("2006-01-02", nil)
+	require.NoError(t, err)
+	assert.Empty(t, formatted)
+
+	_, err = formatDate("2006-01-02", "yesterday")
+	require.Error(t, err)
+
+	u, err := urlWithQuery("https://example.com/welcome?ref=email", "plan", "pro", "name", "Jane Doe")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/welcome?name=Jane+Doe&plan=pro&ref=email", u)
+
+	_, err = urlWithQuery("https://example.com", "plan")
+	require.Error(t, err)
+
+	assert.Equal(t, "there", defaultValue("there", ""))
+	assert.Equal(t, "there", defaultValue("there", nil))
+	assert.Equal(t, "Jane", defaultValue("there", "Jane"))
+
+	metadata := map[string]interface{}{"plan": "pro", "seats": 5, "trial": nil}
+	assert.Equal(t, "pro", metadataValue(metadata, "plan"))
+	assert.Equal(t, "5", metadataValue(metadata, "seats"))
+	assert.Empty(t, metadataValue(metadata, "trial"))
+	assert.Empty(t, metadataValue(nil, "plan"))
+}
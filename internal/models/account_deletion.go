@@ -0,0 +1,86 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// AccountDeletion is the deletion of an account scheduled by its user,
+// which can be cancelled with the token sent to the user until it's due.
+type AccountDeletion struct {
+	ID        uuid.UUID `json:"-" db:"id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	TokenHash string    `json:"-" db:"token_hash"`
+	DeleteAt  time.Time `json:"delete_at" db:"delete_at"`
+	CreatedAt time.Time `json:"requested_at" db:"created_at"`
+	UpdatedAt time.Time `json:"-" db:"updated_at"`
+}
+
+func (AccountDeletion) TableName() string {
+	tableName := "account_deletions"
+	return tableName
+}
+
+// NewAccountDeletion schedules the deletion of the account of the user. The
+// token is only stored hashed.
+func NewAccountDeletion(user *User, token string, deleteAt time.Time) *AccountDeletion {
+	return &AccountDeletion{
+		ID:        uuid.Must(uuid.NewV4()),
+		UserID:    user.ID,
+		TokenHash: crypto.GenerateTokenHash(user.GetEmail(), token),
+		DeleteAt:  deleteAt,
+	}
+}
+
+func FindAccountDeletionByUserID(tx *storage.Connection, userID uuid.UUID) (*AccountDeletion, error) {
+	var deletion AccountDeletion
+
+	if err := tx.Q().Where("user_id = ?", userID).First(&deletion); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, AccountDeletionNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding account deletion")
+	}
+
+	return &deletion, nil
+}
+
+func FindAccountDeletionByTokenHash(tx *storage.Connection, tokenHash string) (*AccountDeletion, error) {
+	var deletion AccountDeletion
+
+	if err := tx.Q().Where("token_hash = ?", tokenHash).First(&deletion); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, AccountDeletionNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding account deletion")
+	}
+
+	return &deletion, nil
+}
+
+// FindDueAccountDeletion locks and returns an account deletion that is due,
+// skipping the ones other instances are processing.
+func FindDueAccountDeletion(tx *storage.Connection, now time.Time) (*AccountDeletion, error) {
+	var deletion AccountDeletion
+
+	if err := tx.RawQuery(
+		"select * from "+(&pop.Model{Value: AccountDeletion{}}).TableName()+" where delete_at <= ? order by delete_at asc limit 1 for update skip locked",
+		now,
+	).First(&deletion); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, AccountDeletionNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding due account deletion")
+	}
+
+	return &deletion, nil
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// AccountLockout counts the failed password attempts on an account, and
+// locks its password sign-ins once there were too many. The account can be
+// unlocked with the token sent to the user until the lock expires.
+type AccountLockout struct {
+	ID             uuid.UUID  `json:"-" db:"id"`
+	UserID         uuid.UUID  `json:"user_id" db:"user_id"`
+	FailedAttempts int        `json:"failed_attempts" db:"failed_attempts"`
+	FirstFailedAt  time.Time  `json:"first_failed_at" db:"first_failed_at"`
+	LockedUntil    *time.Time `json:"locked_until" db:"locked_until"`
+	TokenHash      *string    `json:"-" db:"token_hash"`
+	CreatedAt      time.Time  `json:"-" db:"created_at"`
+	UpdatedAt      time.Time  `json:"-" db:"updated_at"`
+}
+
+func (AccountLockout) TableName() string {
+	tableName := "account_lockouts"
+	return tableName
+}
+
+// IsLocked reports whether password sign-ins to the account are locked.
+func (l *AccountLockout) IsLocked(now time.Time) bool {
+	return l.LockedUntil != nil && now.Before(*l.LockedUntil)
+}
+
+// Lock locks password sign-ins to the account of the user until the time.
+// The token to unlock it is only stored hashed.
+func (l *AccountLockout) Lock(tx *storage.Connection, user *User, token string, lockedUntil time.Time) error {
+	tokenHash := crypto.GenerateTokenHash(user.GetEmail(), token)
+
+	l.LockedUntil = &lockedUntil
+	l.TokenHash = &tokenHash
+
+	return errors.Wrap(tx.UpdateOnly(l, "locked_until", "token_hash"), "error locking account")
+}
+
+// RecordFailedPasswordAttempt counts a failed password attempt on the
+// account of the user, and returns its lockout. Attempts are counted again
+// from this one when the first was before the start of the window, or the
+// account was locked and the lock expired.
+func RecordFailedPasswordAttempt(tx *storage.Connection, userID uuid.UUID, now, windowStart time.Time) (*AccountLockout, error) {
+	var lockout AccountLockout
+	tableName := (&pop.Model{Value: AccountLockout{}}).TableName()
+
+	if err := tx.RawQuery(
+		fmt.Sprintf(`insert into %q as l (id, user_id, failed_attempts, first_failed_at, created_at, updated_at)
+		values (?, ?, 1, ?, ?, ?)
+		on conflict (user_id) do update set
+			failed_attempts = case when l.first_failed_at < ? or l.locked_until <= excluded.updated_at then 1 else l.failed_attempts + 1 end,
+			first_failed_at = case when l.first_failed_at < ? or l.locked_until <= excluded.updated_at then excluded.first_failed_at else l.first_failed_at end,
+			locked_until = case when l.locked_until <= excluded.updated_at then null else l.locked_until end,
+			token_hash = case when l.locked_until <= excluded.updated_at then null else l.token_hash end,
+			updated_at = excluded.updated_at
+		returning *`, tableName),
+		uuid.Must(uuid.NewV4()), userID, now, now, now, windowStart, windowStart,
+	).First(&lockout); err != nil {
+		return nil, errors.Wrap(err, "error recording failed password attempt")
+	}
+
+	return &lockout, nil
+}
+
+func FindAccountLockoutByUserID(tx *storage.Connection, userID uuid.UUID) (*AccountLockout, error) {
+	var lockout AccountLockout
+
+	if err := tx.Q().Where("user_id = ?", userID).First(&lockout); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, AccountLockoutNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding account lockout")
+	}
+
+	return &lockout, nil
+}
+
+func FindAccountLockoutByTokenHash(tx *storage.Connection, tokenHash string) (*AccountLockout, error) {
+	var lockout AccountLockout
+
+	if err := tx.Q().Where("token_hash = ?", tokenHash).First(&lockout); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, AccountLockoutNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding account lockout")
+	}
+
+	return &lockout, nil
+}
+
+// ClearAccountLockoutForUser unlocks the account of the user and forgets
+// its failed password attempts.
+func ClearAccountLockoutForUser(tx *storage.Connection, userID uuid.UUID) error {
+	return errors.Wrap(tx.Q().Where("user_id = ?", userID).Delete(AccountLockout{}), "error deleting account lockout")
+}
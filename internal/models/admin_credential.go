@@ -0,0 +1,200 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// Scopes of admin credentials, each allowing a group of admin endpoints.
+const (
+	AdminScopeUsersRead        = "users:read"
+	AdminScopeUsersWrite       = "users:write"
+	AdminScopeUsersDelete      = "users:delete"
+	AdminScopeUsersBan         = "users:ban"
+	AdminScopeUsersExport      = "users:export"
+	AdminScopeUsersImpersonate = "users:impersonate"
+	AdminScopeProviderTokens   = "provider_tokens:read"
+	AdminScopeIdentitiesSync   = "identities:sync"
+	AdminScopeAuditRead        = "audit:read"
+	AdminScopeLinksGenerate    = "links:generate"
+	AdminScopeEmailManage      = "email:manage"
+	AdminScopeHooksManage      = "hooks:manage"
+	AdminScopeOrganizations    = "organizations:manage"
+	AdminScopeRolesManage      = "roles:manage"
+	AdminScopeSSORead          = "sso:read"
+	AdminScopeSSOManage        = "sso:manage"
+	AdminScopeCredentials      = "credentials:manage"
+)
+
+// AdminScopes are all the scopes of admin credentials.
+var AdminScopes = []string{
+	AdminScopeUsersRead,
+	AdminScopeUsersWrite,
+	AdminScopeUsersDelete,
+	AdminScopeUsersBan,
+	AdminScopeUsersExport,
+	AdminScopeUsersImpersonate,
+	AdminScopeProviderTokens,
+	AdminScopeIdentitiesSync,
+	AdminScopeAuditRead,
+	AdminScopeLinksGenerate,
+	AdminScopeEmailManage,
+	AdminScopeHooksManage,
+	AdminScopeOrganizations,
+	AdminScopeRolesManage,
+	AdminScopeSSORead,
+	AdminScopeSSOManage,
+	AdminScopeCredentials,
+}
+
+// IsValidAdminScope returns true if scope is a scope of admin credentials.
+func IsValidAdminScope(scope string) bool {
+	return slices.Contains(AdminScopes, scope)
+}
+
+type AdminCredentialScopes []string
+
+func (s *AdminCredentialScopes) Scan(src interface{}) error {
+	if src == nil {
+		*s = AdminCredentialScopes{}
+		return nil
+	}
+
+	b, ok := src.([]byte)
+	if !ok {
+		return errors.New("scan source was not []byte")
+	}
+	return json.Unmarshal(b, s)
+}
+
+func (s AdminCredentialScopes) Value() (driver.Value, error) {
+	if s == nil {
+		s = AdminCredentialScopes{}
+	}
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// AdminCredential is a bearer token for the admin API that, unlike the
+// tokens of the admin roles, can only use the endpoints of its scopes.
+type AdminCredential struct {
+	ID uuid.UUID `db:"id" json:"id"`
+
+	Name      string                `db:"name" json:"name"`
+	TokenHash string                `db:"token_hash" json:"-"`
+	Scopes    AdminCredentialScopes `db:"scopes" json:"scopes"`
+	CreatedBy storage.NullString    `db:"created_by" json:"created_by,omitempty"`
+
+	ExpiresAt  *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `db:"last_used_at" json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt  time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+func (c AdminCredential) TableName() string {
+	return "admin_credentials"
+}
+
+// HashAdminCredentialToken returns the hash of the token of an admin
+// credential as stored in the database.
+func HashAdminCredentialToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewAdminCredential creates an admin credential with the token, which has
+// to be saved.
+func NewAdminCredential(name, token string, scopes []string, createdBy string, expiresAt *time.Time) (*AdminCredential, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, errors.Wrap(err, "error generating unique admin credential id")
+	}
+
+	return &AdminCredential{
+		ID:        id,
+		Name:      name,
+		TokenHash: HashAdminCredentialToken(token),
+		Scopes:    scopes,
+		CreatedBy: storage.NullString(createdBy),
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// HasScope returns true if the credential has the scope.
+func (c *AdminCredential) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+// IsExpired returns true if the credential can no longer be used.
+func (c *AdminCredential) IsExpired(now time.Time) bool {
+	return c.ExpiresAt != nil && !now.Before(*c.ExpiresAt)
+}
+
+// UpdateLastUsedAt records that the credential was used, at most once a
+// minute so that busy credentials don't update it on every request.
+func (c *AdminCredential) UpdateLastUsedAt(tx *storage.Connection, now time.Time) error {
+	if c.LastUsedAt != nil && now.Sub(*c.LastUsedAt) < time.Minute {
+		return nil
+	}
+
+	c.LastUsedAt = &now
+	return tx.UpdateOnly(c, "last_used_at")
+}
+
+func FindAdminCredentialByID(tx *storage.Connection, id uuid.UUID) (*AdminCredential, error) {
+	var credential AdminCredential
+
+	if err := tx.Q().Where("id = ?", id).First(&credential); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, AdminCredentialNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding admin credential")
+	}
+
+	return &credential, nil
+}
+
+// FindAdminCredentialByToken finds the admin credential of the token.
+func FindAdminCredentialByToken(tx *storage.Connection, token string) (*AdminCredential, error) {
+	var credential AdminCredential
+
+	if err := tx.Q().Where("token_hash = ?", HashAdminCredentialToken(token)).First(&credential); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, AdminCredentialNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding admin credential by token")
+	}
+
+	return &credential, nil
+}
+
+// FindAdminCredentials returns all admin credentials, the most recently
+// created first.
+func FindAdminCredentials(tx *storage.Connection) ([]*AdminCredential, error) {
+	credentials := []*AdminCredential{}
+
+	if err := tx.Q().Order("created_at desc").All(&credentials); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return credentials, nil
+		}
+
+		return nil, errors.Wrap(err, "error loading admin credentials")
+	}
+
+	return credentials, nil
+}
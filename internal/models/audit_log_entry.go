@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/gobuffalo/pop/v6"
 	"github.com/gofrs/uuid"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -41,6 +42,55 @@ const (
 	UpdateFactorAction              AuditAction = "factor_updated"
 	MFACodeLoginAction              AuditAction = "mfa_code_login"
 	IdentityUnlinkAction            AuditAction = "identity_unlinked"
+	IdentitySyncAction              AuditAction = "identity_synced"
+	EmailChangeUndoneAction         AuditAction = "email_change_undone"
+	PhoneChangeUndoneAction         AuditAction = "phone_change_undone"
+	SecondaryEmailAddedAction       AuditAction = "secondary_email_added"
+	SecondaryEmailVerifiedAction    AuditAction = "secondary_email_verified"
+	SecondaryEmailRemovedAction     AuditAction = "secondary_email_removed"
+	PrimaryEmailChangedAction       AuditAction = "primary_email_changed"
+	MessageDeliveredAction          AuditAction = "message_delivered"
+	MessageDeliveryFailedAction     AuditAction = "message_delivery_failed"
+	UsersImportedAction             AuditAction = "users_imported"
+	UsersExportedAction             AuditAction = "users_exported"
+	UserUndeletedAction             AuditAction = "user_undeleted"
+	UserBannedAction                AuditAction = "user_banned"
+	UserUnbannedAction              AuditAction = "user_unbanned"
+	UserPasswordExpiredAction       AuditAction = "user_password_expired"
+	PasswordsExpiredAction          AuditAction = "passwords_expired"
+	UserImpersonatedAction          AuditAction = "user_impersonated"
+	ImpersonationRevokedAction      AuditAction = "impersonation_revoked"
+	ImpersonatedRequestAction       AuditAction = "impersonated_request"
+	SessionRevokedAction            AuditAction = "session_revoked"
+	SessionCreatedAction            AuditAction = "session_created"
+	RoleCreatedAction               AuditAction = "role_created"
+	RoleUpdatedAction               AuditAction = "role_updated"
+	RoleDeletedAction               AuditAction = "role_deleted"
+	UserRoleAssignedAction          AuditAction = "user_role_assigned"
+	UserRoleRemovedAction           AuditAction = "user_role_removed"
+	AdminCredentialCreatedAction    AuditAction = "admin_credential_created"
+	AdminCredentialRevokedAction    AuditAction = "admin_credential_revoked"
+	AnonymousUserMergedAction       AuditAction = "anonymous_user_merged"
+	AccountDeletionRequestedAction  AuditAction = "account_deletion_requested"
+	AccountDeletionCancelledAction  AuditAction = "account_deletion_cancelled"
+	AccountLockedAction             AuditAction = "account_locked"
+	AccountUnlockedAction           AuditAction = "account_unlocked"
+	ConsentAcceptedAction           AuditAction = "consent_accepted"
+	DataExportRequestedAction       AuditAction = "data_export_requested"
+	DataExportDownloadedAction      AuditAction = "data_export_downloaded"
+	UserNoteAddedAction             AuditAction = "user_note_added"
+	UserNoteDeletedAction           AuditAction = "user_note_deleted"
+	UserTagSetAction                AuditAction = "user_tag_set"
+	UserTagRemovedAction            AuditAction = "user_tag_removed"
+	EmailTemplateTestSentAction     AuditAction = "email_template_test_sent"
+	IPAddressBlockedAction          AuditAction = "ip_address_blocked"
+
+	OrganizationCreatedAction           AuditAction = "organization_created"
+	OrganizationDeletedAction           AuditAction = "organization_deleted"
+	OrganizationMemberAddedAction       AuditAction = "organization_member_added"
+	OrganizationMemberUpdatedAction     AuditAction = "organization_member_updated"
+	OrganizationMemberRemovedAction     AuditAction = "organization_member_removed"
+	OrganizationInvitationCreatedAction AuditAction = "organization_invitation_created"
 
 	account       auditLogType = "account"
 	team          auditLogType = "team"
@@ -48,22 +98,64 @@ const (
 	user          auditLogType = "user"
 	factor        auditLogType = "factor"
 	recoveryCodes auditLogType = "recovery_codes"
+	organization  auditLogType = "organization"
 )
 
 var ActionLogTypeMap = map[AuditAction]auditLogType{
 	LoginAction:                     account,
 	LogoutAction:                    account,
+	SessionRevokedAction:            account,
+	SessionCreatedAction:            account,
+	AnonymousUserMergedAction:       account,
+	AccountDeletionRequestedAction:  account,
+	AccountDeletionCancelledAction:  account,
+	AccountLockedAction:             account,
+	AccountUnlockedAction:           account,
+	DataExportRequestedAction:       account,
+	DataExportDownloadedAction:      account,
 	InviteAcceptedAction:            account,
 	UserSignedUpAction:              team,
 	UserInvitedAction:               team,
 	UserDeletedAction:               team,
+	UsersImportedAction:             team,
+	UsersExportedAction:             team,
+	UserUndeletedAction:             team,
+	UserBannedAction:                team,
+	UserUnbannedAction:              team,
+	UserPasswordExpiredAction:       team,
+	PasswordsExpiredAction:          team,
+	UserImpersonatedAction:          team,
+	ImpersonationRevokedAction:      team,
+	ImpersonatedRequestAction:       team,
+	RoleCreatedAction:               team,
+	RoleUpdatedAction:               team,
+	RoleDeletedAction:               team,
+	UserRoleAssignedAction:          team,
+	UserRoleRemovedAction:           team,
+	AdminCredentialCreatedAction:    team,
+	AdminCredentialRevokedAction:    team,
+	UserNoteAddedAction:             team,
+	UserNoteDeletedAction:           team,
+	UserTagSetAction:                team,
+	UserTagRemovedAction:            team,
+	EmailTemplateTestSentAction:     team,
 	TokenRevokedAction:              token,
 	TokenRefreshedAction:            token,
 	UserModifiedAction:              user,
+	ConsentAcceptedAction:           user,
 	UserRecoveryRequestedAction:     user,
 	UserConfirmationRequestedAction: user,
 	UserRepeatedSignUpAction:        user,
 	UserUpdatePasswordAction:        user,
+	EmailChangeUndoneAction:         user,
+	PhoneChangeUndoneAction:         user,
+	SecondaryEmailAddedAction:       user,
+	SecondaryEmailVerifiedAction:    user,
+	SecondaryEmailRemovedAction:     user,
+	PrimaryEmailChangedAction:       user,
+	MessageDeliveredAction:          user,
+	MessageDeliveryFailedAction:     user,
+	IdentitySyncAction:              user,
 	GenerateRecoveryCodesAction:     user,
 	EnrollFactorAction:              factor,
 	UnenrollFactorAction:            factor,
@@ -73,6 +165,13 @@ var ActionLogTypeMap = map[AuditAction]auditLogType{
 	UpdateFactorAction:              factor,
 	MFACodeLoginAction:              factor,
 	DeleteRecoveryCodesAction:       recoveryCodes,
+
+	OrganizationCreatedAction:           organization,
+	OrganizationDeletedAction:           organization,
+	OrganizationMemberAddedAction:       organization,
+	OrganizationMemberUpdatedAction:     organization,
+	OrganizationMemberRemovedAction:     organization,
+	OrganizationInvitationCreatedAction: organization,
 }
 
 // AuditLogEntry is the database model for audit log entries.
@@ -112,9 +211,12 @@ func NewAuditLogEntry(r *http.Request, tx *storage.Connection, actor *User, acti
 		IPAddress: ipAddress,
 	}
 
-	observability.LogEntrySetFields(r, logrus.Fields{
-		"auth_event": logrus.Fields(payload),
-	})
+	// entries of background jobs aren't recorded for a request
+	if r != nil {
+		observability.LogEntrySetFields(r, logrus.Fields{
+			"auth_event": logrus.Fields(payload),
+		})
+	}
 
 	if name, ok := actor.UserMetaData["full_name"]; ok {
 		l.Payload["actor_name"] = name
@@ -131,8 +233,52 @@ func NewAuditLogEntry(r *http.Request, tx *storage.Connection, actor *User, acti
 	return nil
 }
 
-func FindAuditLogEntries(tx *storage.Connection, filterColumns []string, filterValue string, pageParams *Pagination) ([]*AuditLogEntry, error) {
-	q := tx.Q().Order("created_at desc").Where("instance_id = ?", uuid.Nil)
+// AuditLogFilter filters the audit log entries. The entries of a user are
+// the ones it's the actor of, and the ones about it, like the ones of the
+// admins updating or deleting it.
+type AuditLogFilter struct {
+	ActorID       string
+	UserID        string
+	Actions       []string
+	IPAddress     string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+func (f AuditLogFilter) apply(q *pop.Query) *pop.Query {
+	if f.ActorID != "" {
+		q = q.Where("payload->>'actor_id' = ?", f.ActorID)
+	}
+
+	if f.UserID != "" {
+		q = q.Where("(payload->>'actor_id' = ? or payload->'traits'->>'user_id' = ?)", f.UserID, f.UserID)
+	}
+
+	if len(f.Actions) > 0 {
+		actions := make([]interface{}, len(f.Actions))
+		for i, action := range f.Actions {
+			actions[i] = action
+		}
+		q = q.Where("payload->>'action' in (?)", actions...)
+	}
+
+	if f.IPAddress != "" {
+		q = q.Where("ip_address = ?", f.IPAddress)
+	}
+
+	if f.CreatedAfter != nil {
+		q = q.Where("created_at >= ?", *f.CreatedAfter)
+	}
+
+	if f.CreatedBefore != nil {
+		q = q.Where("created_at < ?", *f.CreatedBefore)
+	}
+
+	return q
+}
+
+func auditLogEntriesQuery(tx *storage.Connection, filterColumns []string, filterValue string, filter AuditLogFilter) *pop.Query {
+	q := tx.Q().Where("instance_id = ?", uuid.Nil)
 
 	if len(filterColumns) > 0 && filterValue != "" {
 		lf := "%" + filterValue + "%"
@@ -153,6 +299,12 @@ func FindAuditLogEntries(tx *storage.Connection, filterColumns []string, filterV
 		q = q.Where(builder.String(), values...)
 	}
 
+	return filter.apply(q)
+}
+
+func FindAuditLogEntries(tx *storage.Connection, filterColumns []string, filterValue string, filter AuditLogFilter, pageParams *Pagination) ([]*AuditLogEntry, error) {
+	q := auditLogEntriesQuery(tx, filterColumns, filterValue, filter).Order("created_at desc, id desc")
+
 	logs := []*AuditLogEntry{}
 	var err error
 	if pageParams != nil {
@@ -164,3 +316,29 @@ func FindAuditLogEntries(tx *storage.Connection, filterColumns []string, filterV
 
 	return logs, err
 }
+
+// AuditLogCursor is the position after an entry in the entries sorted from
+// the latest.
+type AuditLogCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// FindAuditLogEntriesByCursor returns up to limit entries after the cursor,
+// or the latest ones without it, from the latest. Unlike pages, cursors
+// don't skip or repeat entries created while going through them, and
+// older entries are as quick to find as the latest.
+func FindAuditLogEntriesByCursor(tx *storage.Connection, filterColumns []string, filterValue string, filter AuditLogFilter, cursor *AuditLogCursor, limit int) ([]*AuditLogEntry, error) {
+	q := auditLogEntriesQuery(tx, filterColumns, filterValue, filter)
+
+	if cursor != nil {
+		q = q.Where("(created_at, id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+
+	logs := []*AuditLogEntry{}
+	if err := q.Order("created_at desc, id desc").Limit(limit).All(&logs); err != nil {
+		return nil, errors.Wrap(err, "error finding audit log entries")
+	}
+
+	return logs, nil
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// AuditLogSinkCursor is the last audit log entry streamed to a sink.
+// Entries are streamed in the order of their created_at and IDs.
+type AuditLogSinkCursor struct {
+	Sink          string    `json:"sink" db:"sink"`
+	LastCreatedAt time.Time `json:"last_created_at" db:"last_created_at"`
+	LastID        uuid.UUID `json:"last_id" db:"last_id"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (AuditLogSinkCursor) TableName() string {
+	tableName := "audit_log_sink_cursors"
+	return tableName
+}
+
+// ClaimAuditLogSinkCursor locks the cursor of the sink until the
+// transaction ends, so that the entries are streamed to it by one instance
+// at a time. Sinks start with the entries created after they're first
+// claimed. It returns nil when another instance has claimed it.
+func ClaimAuditLogSinkCursor(tx *storage.Connection, sink string) (*AuditLogSinkCursor, error) {
+	tableName := (&pop.Model{Value: AuditLogSinkCursor{}}).TableName()
+
+	if err := tx.RawQuery(
+		fmt.Sprintf("insert into %q (sink, last_created_at, last_id, created_at, updated_at) values (?, now(), ?, now(), now()) on conflict (sink) do nothing", tableName),
+		sink,
+		uuid.Nil,
+	).Exec(); err != nil {
+		return nil, errors.Wrap(err, "error creating audit log sink cursor")
+	}
+
+	var cursor AuditLogSinkCursor
+	if err := tx.RawQuery(
+		fmt.Sprintf("select * from %q where sink = ? for update skip locked", tableName),
+		sink,
+	).First(&cursor); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, errors.Wrap(err, "error claiming audit log sink cursor")
+	}
+
+	return &cursor, nil
+}
+
+// FindAuditLogEntriesAfterCursor returns up to limit entries after the
+// cursor, created before the time.
+func FindAuditLogEntriesAfterCursor(tx *storage.Connection, cursor *AuditLogSinkCursor, before time.Time, limit int) ([]*AuditLogEntry, error) {
+	entries := []*AuditLogEntry{}
+
+	if err := tx.Q().
+		Where("(created_at, id) > (?, ?) and created_at < ?", cursor.LastCreatedAt, cursor.LastID, before).
+		Order("created_at asc, id asc").
+		Limit(limit).
+		All(&entries); err != nil {
+		return nil, errors.Wrap(err, "error finding audit log entries")
+	}
+
+	return entries, nil
+}
+
+// Advance moves the cursor past the entry.
+func (c *AuditLogSinkCursor) Advance(tx *storage.Connection, entry *AuditLogEntry) error {
+	c.LastCreatedAt = entry.CreatedAt
+	c.LastID = entry.ID
+
+	// the cursors are keyed by their sink rather than an id, which
+	// UpdateOnly relies on
+	if err := tx.RawQuery(
+		fmt.Sprintf("update %q set last_created_at = ?, last_id = ?, updated_at = now() where sink = ?", c.TableName()),
+		c.LastCreatedAt,
+		c.LastID,
+		c.Sink,
+	).Exec(); err != nil {
+		return errors.Wrap(err, "error advancing audit log sink cursor")
+	}
+
+	return nil
+}
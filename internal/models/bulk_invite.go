@@ -0,0 +1,93 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// Statuses of bulk invites.
+const (
+	BulkInviteInvited = "invited"
+	BulkInviteFailed  = "failed"
+)
+
+// BulkInvite is the invitation of a user in a batch of invitations by an
+// admin. The status of its email is the one of its outbox message, which
+// is only read with the invites of a batch.
+type BulkInvite struct {
+	ID              uuid.UUID          `json:"id" db:"id"`
+	BatchID         uuid.UUID          `json:"batch_id" db:"batch_id"`
+	Position        int                `json:"position" db:"position"`
+	Email           string             `json:"email" db:"email"`
+	RedirectTo      storage.NullString `json:"redirect_to,omitempty" db:"redirect_to"`
+	Status          string             `json:"status" db:"status"`
+	Error           storage.NullString `json:"error,omitempty" db:"error"`
+	UserID          *uuid.UUID         `json:"user_id,omitempty" db:"user_id"`
+	OutboxMessageID *uuid.UUID         `json:"-" db:"outbox_message_id"`
+	EmailStatus     storage.NullString `json:"email_status,omitempty" db:"email_status" rw:"r"`
+	CreatedAt       time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at" db:"updated_at"`
+}
+
+func (BulkInvite) TableName() string {
+	tableName := "bulk_invites"
+	return tableName
+}
+
+// NewBulkInvite creates the invitation of the email at the position in the
+// batch, numbered from 1.
+func NewBulkInvite(batchID uuid.UUID, position int, email, redirectTo string) *BulkInvite {
+	return &BulkInvite{
+		ID:         uuid.Must(uuid.NewV4()),
+		BatchID:    batchID,
+		Position:   position,
+		Email:      email,
+		RedirectTo: storage.NullString(redirectTo),
+	}
+}
+
+// Invited records that the user was invited, with the outbox message of
+// the invite email when it was queued in the outbox.
+func (i *BulkInvite) Invited(user *User, outboxMessageID *uuid.UUID) {
+	i.Status = BulkInviteInvited
+	i.UserID = &user.ID
+	i.OutboxMessageID = outboxMessageID
+	if outboxMessageID != nil {
+		i.EmailStatus = storage.NullString(OutboxStatusPending)
+	}
+}
+
+// Failed records that the user couldn't be invited, and why.
+func (i *BulkInvite) Failed(message string) {
+	i.Status = BulkInviteFailed
+	i.Error = storage.NullString(message)
+}
+
+// FindBulkInvitesByBatchID returns the invites of the batch in their order,
+// with the status of their email.
+func FindBulkInvitesByBatchID(tx *storage.Connection, batchID uuid.UUID) ([]*BulkInvite, error) {
+	invites := []*BulkInvite{}
+
+	if err := tx.RawQuery(
+		fmt.Sprintf("select i.*, o.status as email_status from %q i left join %q o on o.id = i.outbox_message_id where i.batch_id = ? order by i.position asc", (&pop.Model{Value: BulkInvite{}}).TableName(), (&pop.Model{Value: OutboxMessage{}}).TableName()),
+		batchID,
+	).All(&invites); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return invites, nil
+		}
+		return nil, errors.Wrap(err, "error finding bulk invites")
+	}
+
+	return invites, nil
+}
+
+// ClearBulkInvitesForUser deletes the invites of the user.
+func ClearBulkInvitesForUser(tx *storage.Connection, userID uuid.UUID) error {
+	return errors.Wrap(tx.Q().Where("user_id = ?", userID).Delete(BulkInvite{}), "error deleting bulk invites")
+}
@@ -36,6 +36,14 @@ func NewCleanup(config *conf.GlobalConfiguration) *Cleanup {
 	tableFlowStates := FlowState{}.TableName()
 	tableMFAChallenges := Challenge{}.TableName()
 	tableMFAFactors := Factor{}.TableName()
+	tableWeb3Nonces := Web3Nonce{}.TableName()
+	tableSAMLAssertionReplays := SAMLAssertionReplay{}.TableName()
+	tableHookDeadLetters := HookDeadLetter{}.TableName()
+	tableEmailChangeUndos := EmailChangeUndo{}.TableName()
+	tablePhoneChangeUndos := PhoneChangeUndo{}.TableName()
+	tableOutboxMessages := OutboxMessage{}.TableName()
+	tableShortLinks := ShortLink{}.TableName()
+	tableUserImportJobs := UserImportJob{}.TableName()
 
 	c := &Cleanup{}
 
@@ -54,6 +62,17 @@ func NewCleanup(config *conf.GlobalConfiguration) *Cleanup {
 		fmt.Sprintf("delete from %q where id in (select id from %q where created_at < now() - interval '24 hours' limit 100 for update skip locked);", tableFlowStates, tableFlowStates),
 		fmt.Sprintf("delete from %q where id in (select id from %q where created_at < now() - interval '24 hours' limit 100 for update skip locked);", tableMFAChallenges, tableMFAChallenges),
 		fmt.Sprintf("delete from %q where id in (select id from %q where created_at < now() - interval '24 hours' and status = 'unverified' limit 100 for update skip locked);", tableMFAFactors, tableMFAFactors),
+		fmt.Sprintf("delete from %q where id in (select id from %q where created_at < now() - interval '24 hours' limit 100 for update skip locked);", tableWeb3Nonces, tableWeb3Nonces),
+		fmt.Sprintf("delete from %q where (sso_provider_id, assertion_id) in (select sso_provider_id, assertion_id from %q where expires_at < now() limit 100 for update skip locked);", tableSAMLAssertionReplays, tableSAMLAssertionReplays),
+		fmt.Sprintf("delete from %q where id in (select id from %q where dead_at < now() - interval '30 days' limit 100 for update skip locked);", tableHookDeadLetters, tableHookDeadLetters),
+		fmt.Sprintf("delete from %q where id in (select id from %q where expires_at < now() limit 100 for update skip locked);", tableEmailChangeUndos, tableEmailChangeUndos),
+		fmt.Sprintf("delete from %q where id in (select id from %q where expires_at < now() limit 100 for update skip locked);", tablePhoneChangeUndos, tablePhoneChangeUndos),
+		// sent messages are kept a day for their delivery status, failed
+		// ones 30 days to be inspected and retried
+		fmt.Sprintf("delete from %q where id in (select id from %q where status = 'sent' and sent_at < now() - interval '24 hours' limit 100 for update skip locked);", tableOutboxMessages, tableOutboxMessages),
+		fmt.Sprintf("delete from %q where id in (select id from %q where status = 'failed' and updated_at < now() - interval '30 days' limit 100 for update skip locked);", tableOutboxMessages, tableOutboxMessages),
+		fmt.Sprintf("delete from %q where id in (select id from %q where expires_at < now() limit 100 for update skip locked);", tableShortLinks, tableShortLinks),
+		fmt.Sprintf("delete from %q where id in (select id from %q where completed_at < now() - interval '30 days' limit 100 for update skip locked);", tableUserImportJobs, tableUserImportJobs),
 	)
 
 	if config.External.AnonymousUsers.Enabled {
@@ -63,14 +82,46 @@ func NewCleanup(config *conf.GlobalConfiguration) *Cleanup {
 		)
 	}
 
-	if config.Sessions.Timebox != nil {
-		timeboxSeconds := int((*config.Sessions.Timebox).Seconds())
+	if config.AuditLog.RetentionPeriod > 0 {
+		tableAuditLogEntries := AuditLogEntry{}.TableName()
+		retentionSeconds := int(config.AuditLog.RetentionPeriod.Seconds())
+
+		c.cleanupStatements = append(c.cleanupStatements, fmt.Sprintf("delete from %q where id in (select id from %q where created_at < now() - interval '%d seconds' limit 100 for update skip locked);", tableAuditLogEntries, tableAuditLogEntries, retentionSeconds))
+	}
+
+	if config.SignInHistory.Enabled {
+		tableSignInEvents := SignInEvent{}.TableName()
+		retentionSeconds := int(config.SignInHistory.RetentionPeriod.Seconds())
+
+		c.cleanupStatements = append(c.cleanupStatements, fmt.Sprintf("delete from %q where id in (select id from %q where created_at < now() - interval '%d seconds' limit 100 for update skip locked);", tableSignInEvents, tableSignInEvents, retentionSeconds))
+	}
+
+	if config.BulkInvite.Enabled {
+		tableBulkInvites := BulkInvite{}.TableName()
+		retentionSeconds := int(config.BulkInvite.RetentionPeriod.Seconds())
+
+		c.cleanupStatements = append(c.cleanupStatements, fmt.Sprintf("delete from %q where id in (select id from %q where created_at < now() - interval '%d seconds' limit 100 for update skip locked);", tableBulkInvites, tableBulkInvites, retentionSeconds))
+	}
+
+	if config.AccountLockout.Enabled {
+		tableAccountLockouts := AccountLockout{}.TableName()
+		windowSeconds := int(config.AccountLockout.Window.Seconds())
+
+		c.cleanupStatements = append(c.cleanupStatements, fmt.Sprintf("delete from %q where id in (select id from %q where first_failed_at < now() - interval '%d seconds' and (locked_until is null or locked_until < now()) limit 100 for update skip locked);", tableAccountLockouts, tableAccountLockouts, windowSeconds))
+	}
+
+	// sessions are kept for the longest lifetime, as the one of each
+	// session depends on the roles and tags of its user
+	timebox, inactivityTimeout := config.Sessions.LongestLifetime()
+
+	if timebox != nil {
+		timeboxSeconds := int((*timebox).Seconds())
 
 		c.cleanupStatements = append(c.cleanupStatements, fmt.Sprintf("delete from %q where id in (select id from %q where created_at + interval '%d seconds' < now() - interval '24 hours' limit 100 for update skip locked);", tableSessions, tableSessions, timeboxSeconds))
 	}
 
-	if config.Sessions.InactivityTimeout != nil {
-		inactivitySeconds := int((*config.Sessions.InactivityTimeout).Seconds())
+	if inactivityTimeout != nil {
+		inactivitySeconds := int((*inactivityTimeout).Seconds())
 
 		// delete sessions with a refreshed_at column
 		c.cleanupStatements = append(c.cleanupStatements, fmt.Sprintf("delete from %q where id in (select id from %q where refreshed_at is not null and refreshed_at + interval '%d seconds' < now() - interval '24 hours' limit 100 for update skip locked);", tableSessions, tableSessions, inactivitySeconds))
@@ -21,6 +21,7 @@ func TestCleanup(t *testing.T) {
 	globalConfig.Sessions.Timebox = &timebox
 	globalConfig.Sessions.InactivityTimeout = &inactivityTimeout
 	globalConfig.External.AnonymousUsers.Enabled = true
+	globalConfig.AuditLog.RetentionPeriod = 90 * 24 * time.Hour
 
 	cleanup := NewCleanup(globalConfig)
 
@@ -46,9 +46,43 @@ func TruncateAll(conn *storage.Connection) error {
 			(&pop.Model{Value: SSOProvider{}}).TableName(),
 			(&pop.Model{Value: SSODomain{}}).TableName(),
 			(&pop.Model{Value: SAMLProvider{}}).TableName(),
+			(&pop.Model{Value: OIDCProvider{}}).TableName(),
 			(&pop.Model{Value: SAMLRelayState{}}).TableName(),
+			(&pop.Model{Value: SAMLSession{}}).TableName(),
+			(&pop.Model{Value: SAMLAssertionReplay{}}).TableName(),
+			(&pop.Model{Value: SCIMGroupMember{}}).TableName(),
+			(&pop.Model{Value: SCIMGroup{}}).TableName(),
 			(&pop.Model{Value: FlowState{}}).TableName(),
 			(&pop.Model{Value: OneTimeToken{}}).TableName(),
+			(&pop.Model{Value: Web3Nonce{}}).TableName(),
+			(&pop.Model{Value: ProviderToken{}}).TableName(),
+			(&pop.Model{Value: OrganizationInvitation{}}).TableName(),
+			(&pop.Model{Value: OrganizationMember{}}).TableName(),
+			(&pop.Model{Value: Organization{}}).TableName(),
+			(&pop.Model{Value: HookDelivery{}}).TableName(),
+			(&pop.Model{Value: HookDeadLetter{}}).TableName(),
+			(&pop.Model{Value: EmailChangeUndo{}}).TableName(),
+			(&pop.Model{Value: OutboxMessage{}}).TableName(),
+			(&pop.Model{Value: EmailSuppression{}}).TableName(),
+			(&pop.Model{Value: MessageDelivery{}}).TableName(),
+			(&pop.Model{Value: ShortLink{}}).TableName(),
+			(&pop.Model{Value: UserImportJob{}}).TableName(),
+			(&pop.Model{Value: UserDeletion{}}).TableName(),
+			(&pop.Model{Value: UserBan{}}).TableName(),
+			(&pop.Model{Value: AuditLogSinkCursor{}}).TableName(),
+			(&pop.Model{Value: UserRole{}}).TableName(),
+			(&pop.Model{Value: Role{}}).TableName(),
+			(&pop.Model{Value: AdminCredential{}}).TableName(),
+			(&pop.Model{Value: AccountDeletion{}}).TableName(),
+			(&pop.Model{Value: DataExport{}}).TableName(),
+			(&pop.Model{Value: PhoneChangeUndo{}}).TableName(),
+			(&pop.Model{Value: UserEmail{}}).TableName(),
+			(&pop.Model{Value: PasswordHistory{}}).TableName(),
+			(&pop.Model{Value: SignInEvent{}}).TableName(),
+			(&pop.Model{Value: AccountLockout{}}).TableName(),
+			(&pop.Model{Value: BulkInvite{}}).TableName(),
+			(&pop.Model{Value: UserNote{}}).TableName(),
+			(&pop.Model{Value: UserTag{}}).TableName(),
 		}
 
 		for _, tableName := range tables {
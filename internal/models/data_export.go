@@ -0,0 +1,182 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// Statuses of data exports.
+const (
+	DataExportPending   = "pending"
+	DataExportRunning   = "running"
+	DataExportCompleted = "completed"
+	DataExportFailed    = "failed"
+)
+
+// DataExport is an export of all the data held about a user, generated in
+// the background. The archive is JSON, encrypted with the database
+// encryption key when enabled as it holds personal data, and removed with
+// the export once it expires.
+type DataExport struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	UserID         uuid.UUID  `json:"user_id" db:"user_id"`
+	Status         string     `json:"status" db:"status"`
+	Archive        *string    `json:"-" db:"archive"`
+	LeaseExpiresAt *time.Time `json:"-" db:"lease_expires_at"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+func (DataExport) TableName() string {
+	tableName := "data_exports"
+	return tableName
+}
+
+// NewDataExport creates a pending export of the data of the user.
+func NewDataExport(user *User) *DataExport {
+	return &DataExport{
+		ID:     uuid.Must(uuid.NewV4()),
+		UserID: user.ID,
+		Status: DataExportPending,
+	}
+}
+
+// IsExpired reports whether the archive of a completed export was removed.
+func (e *DataExport) IsExpired(now time.Time) bool {
+	return e.ExpiresAt != nil && now.After(*e.ExpiresAt)
+}
+
+// GetArchive returns the archive of the export, decrypting it when it's
+// encrypted.
+func (e *DataExport) GetArchive(decryptionKeys map[string]string) ([]byte, error) {
+	if e.Archive == nil {
+		return nil, errors.New("data export has no archive")
+	}
+
+	data := []byte(*e.Archive)
+	if es := crypto.ParseEncryptedString(*e.Archive); es != nil {
+		var err error
+		data, err = es.Decrypt(e.ID.String(), decryptionKeys)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// Complete records the archive of the export, which can be downloaded
+// until it expires.
+func (e *DataExport) Complete(tx *storage.Connection, archive []byte, expiry time.Duration, encrypt bool, encryptionKeyID, encryptionKey string) error {
+	data := string(archive)
+	if encrypt {
+		es, err := crypto.NewEncryptedString(e.ID.String(), archive, encryptionKeyID, encryptionKey)
+		if err != nil {
+			return err
+		}
+		data = es.String()
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(expiry)
+
+	e.Status = DataExportCompleted
+	e.Archive = &data
+	e.LeaseExpiresAt = nil
+	e.CompletedAt = &now
+	e.ExpiresAt = &expiresAt
+
+	return errors.Wrap(tx.UpdateOnly(e, "status", "archive", "lease_expires_at", "completed_at", "expires_at", "updated_at"), "error updating data export")
+}
+
+// Fail records that the export couldn't be generated. Failed exports
+// expire like completed ones.
+func (e *DataExport) Fail(tx *storage.Connection, expiry time.Duration) error {
+	now := time.Now()
+	expiresAt := now.Add(expiry)
+
+	e.Status = DataExportFailed
+	e.LeaseExpiresAt = nil
+	e.CompletedAt = &now
+	e.ExpiresAt = &expiresAt
+
+	return errors.Wrap(tx.UpdateOnly(e, "status", "lease_expires_at", "completed_at", "expires_at", "updated_at"), "error updating data export")
+}
+
+// ClaimDataExport returns the oldest export that's pending, or running with
+// an expired lease as the instance generating it stopped, and marks it
+// running with a lease so other instances don't generate it at the same
+// time. It returns nil when there's no export to generate.
+func ClaimDataExport(tx *storage.Connection, lease time.Duration) (*DataExport, error) {
+	var export DataExport
+
+	if err := tx.Transaction(func(tx *storage.Connection) error {
+		if err := tx.RawQuery(fmt.Sprintf("select * from %q where status = ? or (status = ? and lease_expires_at < now()) order by created_at limit 1 for update skip locked", (&pop.Model{Value: DataExport{}}).TableName()), DataExportPending, DataExportRunning).First(&export); err != nil {
+			return err
+		}
+
+		leaseExpiresAt := time.Now().Add(lease)
+		export.Status = DataExportRunning
+		export.LeaseExpiresAt = &leaseExpiresAt
+
+		return tx.UpdateOnly(&export, "status", "lease_expires_at", "updated_at")
+	}); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "error claiming data export")
+	}
+
+	return &export, nil
+}
+
+// FindDataExportByUserIDAndID returns the export of the user with the ID.
+func FindDataExportByUserIDAndID(tx *storage.Connection, userID, id uuid.UUID) (*DataExport, error) {
+	var export DataExport
+
+	if err := tx.Q().Where("user_id = ? and id = ?", userID, id).First(&export); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, DataExportNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding data export")
+	}
+
+	return &export, nil
+}
+
+// FindDataExportsByUserID returns the exports of the user, the latest
+// first.
+func FindDataExportsByUserID(tx *storage.Connection, userID uuid.UUID) ([]*DataExport, error) {
+	exports := []*DataExport{}
+
+	if err := tx.Q().Where("user_id = ?", userID).Order("created_at desc").All(&exports); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return exports, nil
+		}
+
+		return nil, errors.Wrap(err, "error finding data exports")
+	}
+
+	return exports, nil
+}
+
+// DeleteExpiredDataExports deletes the exports, with their archives, that
+// expired before now, and returns how many were deleted.
+func DeleteExpiredDataExports(tx *storage.Connection, now time.Time) (int, error) {
+	deleted, err := tx.RawQuery(fmt.Sprintf("delete from %q where expires_at < ?", (&pop.Model{Value: DataExport{}}).TableName()), now).ExecWithCount()
+	if err != nil {
+		return 0, errors.Wrap(err, "error deleting expired data exports")
+	}
+
+	return deleted, nil
+}
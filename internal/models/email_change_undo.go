@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// EmailChangeUndo is a token sent to the old email address of a user after
+// an email change, which restores the old address when the change wasn't
+// made by the user.
+type EmailChangeUndo struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	OldEmail  string    `json:"old_email" db:"old_email"`
+	NewEmail  string    `json:"new_email" db:"new_email"`
+	TokenHash string    `json:"-" db:"token_hash"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+func (EmailChangeUndo) TableName() string {
+	tableName := "email_change_undos"
+	return tableName
+}
+
+// NewEmailChangeUndo creates the undo of the change from the old email to
+// the current email of the user. The token is only stored hashed.
+func NewEmailChangeUndo(user *User, oldEmail, token string, expiresAt time.Time) *EmailChangeUndo {
+	return &EmailChangeUndo{
+		ID:        uuid.Must(uuid.NewV4()),
+		UserID:    user.ID,
+		OldEmail:  oldEmail,
+		NewEmail:  user.GetEmail(),
+		TokenHash: crypto.GenerateTokenHash(oldEmail, token),
+		ExpiresAt: expiresAt,
+	}
+}
+
+// IsExpired reports whether the undo can't be used anymore.
+func (u *EmailChangeUndo) IsExpired(now time.Time) bool {
+	return now.After(u.ExpiresAt)
+}
+
+func FindEmailChangeUndoByTokenHash(tx *storage.Connection, tokenHash string) (*EmailChangeUndo, error) {
+	var undo EmailChangeUndo
+
+	if err := tx.Q().Where("token_hash = ?", tokenHash).First(&undo); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, EmailChangeUndoNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding email change undo")
+	}
+
+	return &undo, nil
+}
+
+// ClearEmailChangeUndosForUser deletes the undos of all email changes of the
+// user.
+func ClearEmailChangeUndosForUser(tx *storage.Connection, userID uuid.UUID) error {
+	return errors.Wrap(tx.Q().Where("user_id = ?", userID).Delete(EmailChangeUndo{}), "error deleting email change undos")
+}
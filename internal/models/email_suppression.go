@@ -0,0 +1,108 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// Reasons email addresses are suppressed.
+const (
+	EmailSuppressionBounce    = "bounce"
+	EmailSuppressionComplaint = "complaint"
+	EmailSuppressionManual    = "manual"
+)
+
+// EmailSuppression is an email address emails aren't sent to, as it bounced
+// or complained, or was added by an admin.
+type EmailSuppression struct {
+	ID        uuid.UUID          `json:"id" db:"id"`
+	Email     string             `json:"email" db:"email"`
+	Reason    string             `json:"reason" db:"reason"`
+	Provider  string             `json:"provider" db:"provider"`
+	Details   storage.NullString `json:"details,omitempty" db:"details"`
+	CreatedAt time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at" db:"updated_at"`
+}
+
+func (EmailSuppression) TableName() string {
+	tableName := "email_suppressions"
+	return tableName
+}
+
+// SuppressEmail adds the email address to the suppression list, or updates
+// the reason it's suppressed for when it already is.
+func SuppressEmail(tx *storage.Connection, email, reason, provider, details string) error {
+	now := time.Now()
+
+	if err := tx.RawQuery(
+		fmt.Sprintf("insert into %q (id, email, reason, provider, details, created_at, updated_at) values (?, ?, ?, ?, ?, ?, ?) on conflict (email) do update set reason = excluded.reason, provider = excluded.provider, details = excluded.details, updated_at = excluded.updated_at", (&pop.Model{Value: EmailSuppression{}}).TableName()),
+		uuid.Must(uuid.NewV4()),
+		strings.ToLower(email),
+		reason,
+		provider,
+		storage.NullString(details),
+		now,
+		now,
+	).Exec(); err != nil {
+		return errors.Wrap(err, "error suppressing email")
+	}
+
+	return nil
+}
+
+// IsEmailSuppressed reports whether emails must not be sent to the email
+// address.
+func IsEmailSuppressed(tx *storage.Connection, email string) (bool, error) {
+	exists, err := tx.Q().Where("email = ?", strings.ToLower(email)).Exists(&EmailSuppression{})
+	if err != nil {
+		return false, errors.Wrap(err, "error checking email suppression")
+	}
+
+	return exists, nil
+}
+
+func FindEmailSuppressionByID(tx *storage.Connection, id uuid.UUID) (*EmailSuppression, error) {
+	var suppression EmailSuppression
+
+	if err := tx.Q().Where("id = ?", id).First(&suppression); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, EmailSuppressionNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding email suppression")
+	}
+
+	return &suppression, nil
+}
+
+// FindEmailSuppressions returns the suppressed email addresses for the
+// reason, or for all reasons when it's empty, the most recent first.
+func FindEmailSuppressions(tx *storage.Connection, reason string, pageParams *Pagination) ([]*EmailSuppression, error) {
+	suppressions := []*EmailSuppression{}
+
+	q := tx.Q().Order("updated_at desc")
+	if reason != "" {
+		q = q.Where("reason = ?", reason)
+	}
+
+	var err error
+	if pageParams != nil {
+		err = q.Paginate(int(pageParams.Page), int(pageParams.PerPage)).All(&suppressions) // #nosec G115
+		pageParams.Count = uint64(q.Paginator.TotalEntriesSize)                            // #nosec G115
+	} else {
+		err = q.All(&suppressions)
+	}
+
+	if err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "error loading email suppressions")
+	}
+
+	return suppressions, nil
+}
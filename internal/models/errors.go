@@ -23,10 +23,54 @@ func IsNotFoundError(err error) bool {
 		return true
 	case SAMLRelayStateNotFoundError, *SAMLRelayStateNotFoundError:
 		return true
+	case SAMLSessionNotFoundError, *SAMLSessionNotFoundError:
+		return true
+	case SCIMGroupNotFoundError, *SCIMGroupNotFoundError:
+		return true
 	case FlowStateNotFoundError, *FlowStateNotFoundError:
 		return true
 	case OneTimeTokenNotFoundError, *OneTimeTokenNotFoundError:
 		return true
+	case Web3NonceNotFoundError, *Web3NonceNotFoundError:
+		return true
+	case ProviderTokenNotFoundError, *ProviderTokenNotFoundError:
+		return true
+	case OrganizationNotFoundError, *OrganizationNotFoundError:
+		return true
+	case OrganizationMemberNotFoundError, *OrganizationMemberNotFoundError:
+		return true
+	case OrganizationInvitationNotFoundError, *OrganizationInvitationNotFoundError:
+		return true
+	case HookDeadLetterNotFoundError, *HookDeadLetterNotFoundError:
+		return true
+	case EmailChangeUndoNotFoundError, *EmailChangeUndoNotFoundError:
+		return true
+	case OutboxMessageNotFoundError, *OutboxMessageNotFoundError:
+		return true
+	case EmailSuppressionNotFoundError, *EmailSuppressionNotFoundError:
+		return true
+	case ShortLinkNotFoundError, *ShortLinkNotFoundError:
+		return true
+	case UserImportJobNotFoundError, *UserImportJobNotFoundError:
+		return true
+	case UserDeletionNotFoundError, *UserDeletionNotFoundError:
+		return true
+	case RoleNotFoundError, *RoleNotFoundError:
+		return true
+	case AdminCredentialNotFoundError, *AdminCredentialNotFoundError:
+		return true
+	case AccountDeletionNotFoundError, *AccountDeletionNotFoundError:
+		return true
+	case AccountLockoutNotFoundError, *AccountLockoutNotFoundError:
+		return true
+	case DataExportNotFoundError, *DataExportNotFoundError:
+		return true
+	case PhoneChangeUndoNotFoundError, *PhoneChangeUndoNotFoundError:
+		return true
+	case UserEmailNotFoundError, *UserEmailNotFoundError:
+		return true
+	case UserNoteNotFoundError, *UserNoteNotFoundError:
+		return true
 	}
 	return false
 }
@@ -102,6 +146,22 @@ func (e SAMLRelayStateNotFoundError) Error() string {
 	return "SAML RelayState not found"
 }
 
+// SAMLSessionNotFoundError represents an error when a SAML session can't be
+// found.
+type SAMLSessionNotFoundError struct{}
+
+func (e SAMLSessionNotFoundError) Error() string {
+	return "SAML session not found"
+}
+
+// SCIMGroupNotFoundError represents an error when a SCIM group can't be
+// found.
+type SCIMGroupNotFoundError struct{}
+
+func (e SCIMGroupNotFoundError) Error() string {
+	return "SCIM group not found"
+}
+
 // FlowStateNotFoundError represents an error when an FlowState can't be
 // found.
 type FlowStateNotFoundError struct{}
@@ -110,6 +170,13 @@ func (e FlowStateNotFoundError) Error() string {
 	return "Flow State not found"
 }
 
+// Web3NonceNotFoundError represents when a web3 nonce is not found.
+type Web3NonceNotFoundError struct{}
+
+func (e Web3NonceNotFoundError) Error() string {
+	return "Web3 nonce not found"
+}
+
 func IsUniqueConstraintViolatedError(err error) bool {
 	switch err.(type) {
 	case UserEmailUniqueConflictError, *UserEmailUniqueConflictError:
@@ -123,3 +190,153 @@ type UserEmailUniqueConflictError struct{}
 func (e UserEmailUniqueConflictError) Error() string {
 	return "User email unique constraint violated"
 }
+
+// ProviderTokenNotFoundError represents when a provider token is not found.
+type ProviderTokenNotFoundError struct{}
+
+func (e ProviderTokenNotFoundError) Error() string {
+	return "Provider token not found"
+}
+
+// OrganizationNotFoundError represents an error when an organization can't
+// be found.
+type OrganizationNotFoundError struct{}
+
+func (e OrganizationNotFoundError) Error() string {
+	return "Organization not found"
+}
+
+// OrganizationMemberNotFoundError represents an error when a user is not a
+// member of an organization.
+type OrganizationMemberNotFoundError struct{}
+
+func (e OrganizationMemberNotFoundError) Error() string {
+	return "Organization member not found"
+}
+
+// OrganizationInvitationNotFoundError represents an error when an invitation
+// to join an organization can't be found.
+type OrganizationInvitationNotFoundError struct{}
+
+func (e OrganizationInvitationNotFoundError) Error() string {
+	return "Organization invitation not found"
+}
+
+// HookDeadLetterNotFoundError represents an error when a dead letter of a
+// hook can't be found.
+type HookDeadLetterNotFoundError struct{}
+
+func (e HookDeadLetterNotFoundError) Error() string {
+	return "Hook dead letter not found"
+}
+
+// EmailChangeUndoNotFoundError represents an error when the token to undo an
+// email change can't be found.
+type EmailChangeUndoNotFoundError struct{}
+
+func (e EmailChangeUndoNotFoundError) Error() string {
+	return "Email change undo not found"
+}
+
+// OutboxMessageNotFoundError represents an error when a message of the outbox
+// can't be found.
+type OutboxMessageNotFoundError struct{}
+
+func (e OutboxMessageNotFoundError) Error() string {
+	return "Outbox message not found"
+}
+
+// EmailSuppressionNotFoundError represents an error when a suppressed email
+// address can't be found.
+type EmailSuppressionNotFoundError struct{}
+
+func (e EmailSuppressionNotFoundError) Error() string {
+	return "Email suppression not found"
+}
+
+// ShortLinkNotFoundError represents an error when a short link can't be
+// found, or was used or expired.
+type ShortLinkNotFoundError struct{}
+
+func (e ShortLinkNotFoundError) Error() string {
+	return "Short link not found"
+}
+
+// UserImportJobNotFoundError represents an error when a user import job
+// can't be found.
+type UserImportJobNotFoundError struct{}
+
+func (e UserImportJobNotFoundError) Error() string {
+	return "User import job not found"
+}
+
+// UserDeletionNotFoundError represents an error when the deletion of a user
+// can't be found, like when it was deleted without a retention period.
+type UserDeletionNotFoundError struct{}
+
+func (e UserDeletionNotFoundError) Error() string {
+	return "User deletion not found"
+}
+
+// RoleNotFoundError represents an error when a role can't be found.
+type RoleNotFoundError struct{}
+
+func (e RoleNotFoundError) Error() string {
+	return "Role not found"
+}
+
+// AdminCredentialNotFoundError represents an error when an admin credential
+// can't be found.
+type AdminCredentialNotFoundError struct{}
+
+func (e AdminCredentialNotFoundError) Error() string {
+	return "Admin credential not found"
+}
+
+// AccountDeletionNotFoundError represents an error when the scheduled
+// deletion of an account can't be found.
+type AccountDeletionNotFoundError struct{}
+
+func (e AccountDeletionNotFoundError) Error() string {
+	return "Account deletion not found"
+}
+
+// AccountLockoutNotFoundError represents an error when the lockout of an
+// account can't be found.
+type AccountLockoutNotFoundError struct{}
+
+func (e AccountLockoutNotFoundError) Error() string {
+	return "Account lockout not found"
+}
+
+// DataExportNotFoundError represents an error when an export of the data of
+// a user can't be found.
+type DataExportNotFoundError struct{}
+
+func (e DataExportNotFoundError) Error() string {
+	return "Data export not found"
+}
+
+// PhoneChangeUndoNotFoundError represents an error when the token to undo a
+// phone change can't be found.
+type PhoneChangeUndoNotFoundError struct{}
+
+func (e PhoneChangeUndoNotFoundError) Error() string {
+	return "Phone change undo not found"
+}
+
+// UserEmailNotFoundError represents an error when a secondary email address
+// of a user can't be found.
+type UserEmailNotFoundError struct{}
+
+func (e UserEmailNotFoundError) Error() string {
+	return "User email not found"
+}
+
+// UserNoteNotFoundError represents an error when a note on a user can't be
+// found.
+type UserNoteNotFoundError struct{}
+
+func (e UserNoteNotFoundError) Error() string {
+	return "User note not found"
+}
@@ -49,6 +49,9 @@ const (
 	EmailChange
 	TokenRefresh
 	Anonymous
+	SSOKerberos
+	SSOOIDC
+	Impersonation
 )
 
 func (authMethod AuthenticationMethod) String() string {
@@ -79,6 +82,12 @@ func (authMethod AuthenticationMethod) String() string {
 		return "anonymous"
 	case MFAPhone:
 		return "mfa/phone"
+	case SSOKerberos:
+		return "sso/kerberos"
+	case SSOOIDC:
+		return "sso/oidc"
+	case Impersonation:
+		return "impersonation"
 	}
 	return ""
 }
@@ -112,6 +121,12 @@ func ParseAuthenticationMethod(authMethod string) (AuthenticationMethod, error)
 		return TokenRefresh, nil
 	case "mfa/sms":
 		return MFAPhone, nil
+	case "sso/kerberos":
+		return SSOKerberos, nil
+	case "sso/oidc":
+		return SSOOIDC, nil
+	case "impersonation":
+		return Impersonation, nil
 	}
 	return 0, fmt.Errorf("unsupported authentication method %q", authMethod)
 }
@@ -0,0 +1,211 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/storage"
+)
+
+const (
+	HookSendEmail = "send_email"
+	HookSendSMS   = "send_sms"
+)
+
+// Reasons messages of hooks are moved to the dead letters.
+const (
+	HookDeadLetterMaxAttempts = "max_attempts"
+	HookDeadLetterExpired     = "expired"
+	HookDeadLetterRejected    = "rejected"
+)
+
+// HookDelivery is a message of the send email or send SMS hook waiting to be
+// retried, as the hook couldn't be reached. The payload is the input of the
+// hook, encrypted with the database encryption key when enabled, as it holds
+// the OTP.
+type HookDelivery struct {
+	ID            uuid.UUID          `json:"id" db:"id"`
+	HookName      string             `json:"hook_name" db:"hook_name"`
+	UserID        *uuid.UUID         `json:"user_id,omitempty" db:"user_id"`
+	Payload       string             `json:"-" db:"payload"`
+	Attempts      int                `json:"attempts" db:"attempts"`
+	LastError     storage.NullString `json:"last_error,omitempty" db:"last_error"`
+	NextAttemptAt time.Time          `json:"next_attempt_at" db:"next_attempt_at"`
+	ExpiresAt     time.Time          `json:"expires_at" db:"expires_at"`
+	CreatedAt     time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at" db:"updated_at"`
+}
+
+func (HookDelivery) TableName() string {
+	tableName := "hook_deliveries"
+	return tableName
+}
+
+// NewHookDelivery creates a delivery of the payload to the hook, which has
+// been attempted once and expires with the OTP it holds.
+func NewHookDelivery(hookName string, userID *uuid.UUID, payload []byte, lastError string, nextAttemptAt, expiresAt time.Time, encrypt bool, encryptionKeyID, encryptionKey string) (*HookDelivery, error) {
+	delivery := &HookDelivery{
+		ID:            uuid.Must(uuid.NewV4()),
+		HookName:      hookName,
+		UserID:        userID,
+		Attempts:      1,
+		LastError:     storage.NullString(lastError),
+		NextAttemptAt: nextAttemptAt,
+		ExpiresAt:     expiresAt,
+	}
+
+	delivery.Payload = string(payload)
+	if encrypt {
+		es, err := crypto.NewEncryptedString(delivery.ID.String(), payload, encryptionKeyID, encryptionKey)
+		if err != nil {
+			return nil, err
+		}
+		delivery.Payload = es.String()
+	}
+
+	return delivery, nil
+}
+
+// GetPayload returns the payload, decrypting it when it's encrypted.
+func (d *HookDelivery) GetPayload(decryptionKeys map[string]string) ([]byte, error) {
+	return decryptHookPayload(d.ID, d.Payload, decryptionKeys)
+}
+
+// Retry records the failure of an attempt and schedules the next one.
+func (d *HookDelivery) Retry(tx *storage.Connection, lastError string, nextAttemptAt time.Time) error {
+	d.Attempts += 1
+	d.LastError = storage.NullString(lastError)
+	d.NextAttemptAt = nextAttemptAt
+
+	return errors.Wrap(tx.UpdateOnly(d, "attempts", "last_error", "next_attempt_at", "updated_at"), "error updating hook delivery")
+}
+
+// DeadLetter moves the delivery to the dead letters for the reason.
+func (d *HookDelivery) DeadLetter(tx *storage.Connection, reason string) error {
+	// the dead letter keeps the ID of the delivery, which the payload is
+	// encrypted with
+	deadLetter := &HookDeadLetter{
+		ID:        d.ID,
+		HookName:  d.HookName,
+		UserID:    d.UserID,
+		Payload:   d.Payload,
+		Attempts:  d.Attempts,
+		LastError: d.LastError,
+		Reason:    reason,
+		CreatedAt: d.CreatedAt,
+		DeadAt:    time.Now(),
+	}
+
+	return tx.Transaction(func(tx *storage.Connection) error {
+		if err := tx.Create(deadLetter); err != nil {
+			return errors.Wrap(err, "error creating hook dead letter")
+		}
+
+		return errors.Wrap(tx.Destroy(d), "error deleting hook delivery")
+	})
+}
+
+// ClaimDueHookDeliveries returns the deliveries whose next attempt is due,
+// the oldest first, and postpones their next attempt by the lease so other
+// instances don't attempt them at the same time.
+func ClaimDueHookDeliveries(tx *storage.Connection, limit int, lease time.Duration) ([]*HookDelivery, error) {
+	deliveries := []*HookDelivery{}
+
+	if err := tx.Transaction(func(tx *storage.Connection) error {
+		if err := tx.RawQuery(fmt.Sprintf("select * from %q where next_attempt_at <= now() order by next_attempt_at limit ? for update skip locked", (&pop.Model{Value: HookDelivery{}}).TableName()), limit).All(&deliveries); err != nil {
+			if errors.Cause(err) == sql.ErrNoRows {
+				return nil
+			}
+			return err
+		}
+
+		for _, delivery := range deliveries {
+			delivery.NextAttemptAt = time.Now().Add(lease)
+			if err := tx.UpdateOnly(delivery, "next_attempt_at"); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error claiming hook deliveries")
+	}
+
+	return deliveries, nil
+}
+
+// HookDeadLetter is a message of the send email or send SMS hook that could
+// not be delivered, kept for inspection by admins.
+type HookDeadLetter struct {
+	ID        uuid.UUID          `json:"id" db:"id"`
+	HookName  string             `json:"hook_name" db:"hook_name"`
+	UserID    *uuid.UUID         `json:"user_id,omitempty" db:"user_id"`
+	Payload   string             `json:"-" db:"payload"`
+	Attempts  int                `json:"attempts" db:"attempts"`
+	LastError storage.NullString `json:"last_error,omitempty" db:"last_error"`
+	Reason    string             `json:"reason" db:"reason"`
+	CreatedAt time.Time          `json:"created_at" db:"created_at"`
+	DeadAt    time.Time          `json:"dead_at" db:"dead_at"`
+}
+
+func (HookDeadLetter) TableName() string {
+	tableName := "hook_dead_letters"
+	return tableName
+}
+
+// GetPayload returns the payload, decrypting it when it's encrypted.
+func (d *HookDeadLetter) GetPayload(decryptionKeys map[string]string) ([]byte, error) {
+	return decryptHookPayload(d.ID, d.Payload, decryptionKeys)
+}
+
+func decryptHookPayload(id uuid.UUID, payload string, decryptionKeys map[string]string) ([]byte, error) {
+	if es := crypto.ParseEncryptedString(payload); es != nil {
+		return es.Decrypt(id.String(), decryptionKeys)
+	}
+
+	return []byte(payload), nil
+}
+
+func FindHookDeadLetterByID(tx *storage.Connection, id uuid.UUID) (*HookDeadLetter, error) {
+	var deadLetter HookDeadLetter
+
+	if err := tx.Q().Where("id = ?", id).First(&deadLetter); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, HookDeadLetterNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding hook dead letter")
+	}
+
+	return &deadLetter, nil
+}
+
+// FindHookDeadLetters returns the dead letters of the hook, or of all hooks
+// when it's empty, the most recent first.
+func FindHookDeadLetters(tx *storage.Connection, hookName string, pageParams *Pagination) ([]*HookDeadLetter, error) {
+	deadLetters := []*HookDeadLetter{}
+
+	q := tx.Q().Order("dead_at desc")
+	if hookName != "" {
+		q = q.Where("hook_name = ?", hookName)
+	}
+
+	var err error
+	if pageParams != nil {
+		err = q.Paginate(int(pageParams.Page), int(pageParams.PerPage)).All(&deadLetters) // #nosec G115
+		pageParams.Count = uint64(q.Paginator.TotalEntriesSize)                           // #nosec G115
+	} else {
+		err = q.All(&deadLetters)
+	}
+
+	if err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "error loading hook dead letters")
+	}
+
+	return deadLetters, nil
+}
@@ -25,6 +25,7 @@ type Identity struct {
 	CreatedAt    time.Time          `json:"created_at" db:"created_at"`
 	UpdatedAt    time.Time          `json:"updated_at" db:"updated_at"`
 	Email        storage.NullString `json:"email,omitempty" db:"email" rw:"r"`
+	Scopes       storage.NullString `json:"scopes,omitempty" db:"scopes"`
 }
 
 func (Identity) TableName() string {
@@ -70,6 +71,12 @@ func (i *Identity) BeforeUpdate(tx *pop.Connection) error {
 	return nil
 }
 
+// UpdateScopes records the scopes the provider granted for the identity.
+func (i *Identity) UpdateScopes(tx *storage.Connection, scopes string) error {
+	i.Scopes = storage.NullString(scopes)
+	return tx.UpdateOnly(i, "scopes")
+}
+
 func (i *Identity) IsForSSOProvider() bool {
 	return strings.HasPrefix(i.Provider, "sso:")
 }
@@ -98,6 +105,27 @@ func FindIdentitiesByUserID(tx *storage.Connection, userID uuid.UUID) ([]*Identi
 	return identities, nil
 }
 
+// FindIdentitiesByUserIDs returns the identities of the users.
+func FindIdentitiesByUserIDs(tx *storage.Connection, userIDs []uuid.UUID) ([]*Identity, error) {
+	identities := []*Identity{}
+	if len(userIDs) == 0 {
+		return identities, nil
+	}
+
+	args := make([]interface{}, len(userIDs))
+	for i, userID := range userIDs {
+		args[i] = userID
+	}
+
+	if err := tx.Q().Where("user_id in (?)", args...).All(&identities); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return identities, nil
+		}
+		return nil, errors.Wrap(err, "error finding identities")
+	}
+	return identities, nil
+}
+
 // FindProvidersByUser returns all providers associated to a user
 func FindProvidersByUser(tx *storage.Connection, user *User) ([]string, error) {
 	identities := []Identity{}
@@ -118,6 +146,17 @@ func FindProvidersByUser(tx *storage.Connection, user *User) ([]string, error) {
 	return providers, nil
 }
 
+// UpdateProviderID changes the ID of the user with the identity provider.
+func (i *Identity) UpdateProviderID(tx *storage.Connection, providerID string) error {
+	i.ProviderID = providerID
+
+	return tx.RawQuery(
+		"update "+(&pop.Model{Value: Identity{}}).TableName()+" set provider_id = ? where id = ?",
+		i.ProviderID,
+		i.ID,
+	).Exec()
+}
+
 // UpdateIdentityData sets all identity_data from a map of updates,
 // ensuring that it doesn't override attributes that are not
 // in the provided map.
@@ -140,3 +179,18 @@ func (i *Identity) UpdateIdentityData(tx *storage.Connection, updates map[string
 		i.ID,
 	).Exec()
 }
+
+// TransferIdentities moves the identities of a user to another user, and
+// returns how many were moved.
+func TransferIdentities(tx *storage.Connection, fromUserID, toUserID uuid.UUID) (int, error) {
+	count, err := tx.RawQuery(
+		"update "+(&pop.Model{Value: Identity{}}).TableName()+" set user_id = ? where user_id = ?",
+		toUserID,
+		fromUserID,
+	).ExecWithCount()
+	if err != nil {
+		return 0, errors.Wrap(err, "error transferring identities")
+	}
+
+	return count, nil
+}
@@ -1,8 +1,10 @@
 package models
 
 import (
+	"fmt"
 	"strings"
 
+	"github.com/gobuffalo/pop/v6"
 	"github.com/supabase/auth/internal/api/provider"
 	"github.com/supabase/auth/internal/conf"
 	"github.com/supabase/auth/internal/storage"
@@ -115,8 +117,9 @@ func DetermineAccountLinking(tx *storage.Connection, config *conf.GlobalConfigur
 
 	if !strings.HasPrefix(providerName, "sso:") {
 		// there can be multiple user accounts with the same email when is_sso_user is true
-		// so we just do not consider those similar user accounts
-		if terr := tx.Q().Eager().Where("email = any (?) and is_sso_user = false", verifiedEmails).All(&similarUsers); terr != nil {
+		// so we just do not consider those similar user accounts. users with
+		// one of the emails as a verified secondary email are similar too
+		if terr := tx.Q().Eager().Where(fmt.Sprintf("(email = any (?) or id in (select user_id from %q where email = any (?) and verified_at is not null)) and is_sso_user = false", (&pop.Model{Value: UserEmail{}}).TableName()), verifiedEmails, verifiedEmails).All(&similarUsers); terr != nil {
 			return AccountLinkingResult{}, terr
 		}
 	}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// Delivery statuses of messages.
+const (
+	MessageDeliverySent      = "sent"
+	MessageDeliveryDelivered = "delivered"
+	MessageDeliveryFailed    = "failed"
+)
+
+// MessageDelivery is an email or SMS message accepted by a provider, with
+// the delivery status its webhook reports. Messages are correlated with the
+// receipts by the ID the provider assigned to them.
+type MessageDelivery struct {
+	ID                uuid.UUID          `json:"id" db:"id"`
+	Channel           string             `json:"channel" db:"channel"`
+	Provider          string             `json:"provider" db:"provider"`
+	ProviderMessageID string             `json:"provider_message_id" db:"provider_message_id"`
+	Recipient         string             `json:"recipient" db:"recipient"`
+	UserID            *uuid.UUID         `json:"user_id,omitempty" db:"user_id"`
+	MessageType       string             `json:"message_type" db:"message_type"`
+	Status            string             `json:"status" db:"status"`
+	Details           storage.NullString `json:"details,omitempty" db:"details"`
+	CreatedAt         time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time          `json:"updated_at" db:"updated_at"`
+}
+
+func (MessageDelivery) TableName() string {
+	tableName := "message_deliveries"
+	return tableName
+}
+
+// NewMessageDelivery creates the delivery of a message the provider just
+// accepted, which is sent until its webhook reports otherwise.
+func NewMessageDelivery(channel, provider, providerMessageID, recipient string, userID *uuid.UUID, messageType string) *MessageDelivery {
+	return &MessageDelivery{
+		ID:                uuid.Must(uuid.NewV4()),
+		Channel:           channel,
+		Provider:          provider,
+		ProviderMessageID: providerMessageID,
+		Recipient:         recipient,
+		UserID:            userID,
+		MessageType:       messageType,
+		Status:            MessageDeliverySent,
+	}
+}
+
+// UpdateMessageDeliveryStatus records the status a provider reported for
+// the message with the ID. It returns the updated delivery, or nil when no
+// message has the ID, like messages sent before delivery tracking was
+// enabled, or when the delivery already has a final status, as receipts
+// can arrive out of order.
+func UpdateMessageDeliveryStatus(tx *storage.Connection, channel, providerMessageID, status, details string) (*MessageDelivery, error) {
+	var delivery MessageDelivery
+
+	if err := tx.Q().Where("channel = ? and provider_message_id = ?", channel, providerMessageID).First(&delivery); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, errors.Wrap(err, "error finding message delivery")
+	}
+
+	if delivery.Status != MessageDeliverySent || status == MessageDeliverySent {
+		return nil, nil
+	}
+
+	delivery.Status = status
+	delivery.Details = storage.NullString(details)
+
+	if err := tx.UpdateOnly(&delivery, "status", "details", "updated_at"); err != nil {
+		return nil, errors.Wrap(err, "error updating message delivery")
+	}
+
+	return &delivery, nil
+}
+
+// FindMessageDeliveries returns the deliveries matching the filters that
+// are set, the most recent first.
+func FindMessageDeliveries(tx *storage.Connection, status, channel, recipient string, userID *uuid.UUID, pageParams *Pagination) ([]*MessageDelivery, error) {
+	deliveries := []*MessageDelivery{}
+
+	q := tx.Q().Order("created_at desc")
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if channel != "" {
+		q = q.Where("channel = ?", channel)
+	}
+	if recipient != "" {
+		q = q.Where("recipient = ?", recipient)
+	}
+	if userID != nil {
+		q = q.Where("user_id = ?", *userID)
+	}
+
+	var err error
+	if pageParams != nil {
+		err = q.Paginate(int(pageParams.Page), int(pageParams.PerPage)).All(&deliveries) // #nosec G115
+		pageParams.Count = uint64(q.Paginator.TotalEntriesSize)                          // #nosec G115
+	} else {
+		err = q.All(&deliveries)
+	}
+
+	if err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "error loading message deliveries")
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,334 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// Roles of the members of an organization, from the most to the least
+// privileged. Owners manage everything, admins manage members and
+// invitations and members can only see the organization.
+const (
+	OrganizationRoleOwner  = "owner"
+	OrganizationRoleAdmin  = "admin"
+	OrganizationRoleMember = "member"
+)
+
+// IsValidOrganizationRole returns true if role is a role of organization
+// members.
+func IsValidOrganizationRole(role string) bool {
+	switch role {
+	case OrganizationRoleOwner, OrganizationRoleAdmin, OrganizationRoleMember:
+		return true
+	}
+
+	return false
+}
+
+// Organization is a group of users, like a company or a team, in which each
+// member has a role.
+type Organization struct {
+	ID uuid.UUID `db:"id" json:"id"`
+
+	Name     string  `db:"name" json:"name"`
+	Slug     string  `db:"slug" json:"slug"`
+	Metadata JSONMap `db:"metadata" json:"metadata"`
+
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+func (o Organization) TableName() string {
+	return "organizations"
+}
+
+// OrganizationMember records the membership and role of a user in an
+// organization.
+type OrganizationMember struct {
+	ID uuid.UUID `db:"id" json:"-"`
+
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
+	UserID         uuid.UUID `db:"user_id" json:"user_id"`
+
+	Role string `db:"role" json:"role"`
+
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+func (m OrganizationMember) TableName() string {
+	return "organization_members"
+}
+
+// CanManage returns true if the member can manage the members and
+// invitations of the organization.
+func (m *OrganizationMember) CanManage() bool {
+	return m.Role == OrganizationRoleOwner || m.Role == OrganizationRoleAdmin
+}
+
+// IsOwner returns true if the member owns the organization.
+func (m *OrganizationMember) IsOwner() bool {
+	return m.Role == OrganizationRoleOwner
+}
+
+// OrganizationInvitation invites the user with the email address to join an
+// organization with a role.
+type OrganizationInvitation struct {
+	ID uuid.UUID `db:"id" json:"id"`
+
+	OrganizationID uuid.UUID `db:"organization_id" json:"organization_id"`
+
+	Email     string     `db:"email" json:"email"`
+	Role      string     `db:"role" json:"role"`
+	InvitedBy *uuid.UUID `db:"invited_by" json:"invited_by,omitempty"`
+
+	ExpiresAt time.Time `db:"expires_at" json:"expires_at"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+func (i OrganizationInvitation) TableName() string {
+	return "organization_invitations"
+}
+
+// IsExpired returns true if the invitation can no longer be accepted.
+func (i *OrganizationInvitation) IsExpired(now time.Time) bool {
+	return !now.Before(i.ExpiresAt)
+}
+
+// OrganizationMembership is the membership of a user in an organization, as
+// included in the user's access tokens.
+type OrganizationMembership struct {
+	OrganizationID uuid.UUID `db:"organization_id" json:"id"`
+	Slug           string    `db:"slug" json:"slug"`
+	Role           string    `db:"role" json:"role"`
+}
+
+// NewOrganization creates an organization, which has to be saved.
+func NewOrganization(name, slug string, metadata map[string]interface{}) (*Organization, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, errors.Wrap(err, "error generating unique organization id")
+	}
+
+	if metadata == nil {
+		metadata = make(map[string]interface{})
+	}
+
+	return &Organization{
+		ID:       id,
+		Name:     name,
+		Slug:     slug,
+		Metadata: metadata,
+	}, nil
+}
+
+func FindOrganizationByID(tx *storage.Connection, id uuid.UUID) (*Organization, error) {
+	var organization Organization
+
+	if err := tx.Q().Where("id = ?", id).First(&organization); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, OrganizationNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding organization")
+	}
+
+	return &organization, nil
+}
+
+func FindOrganizationBySlug(tx *storage.Connection, slug string) (*Organization, error) {
+	var organization Organization
+
+	if err := tx.Q().Where("slug = ?", strings.ToLower(slug)).First(&organization); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, OrganizationNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding organization by slug")
+	}
+
+	return &organization, nil
+}
+
+// FindOrganizations returns all organizations, the most recently created
+// first.
+func FindOrganizations(tx *storage.Connection, pageParams *Pagination) ([]*Organization, error) {
+	organizations := []*Organization{}
+
+	q := tx.Q().Order("created_at desc")
+
+	var err error
+	if pageParams != nil {
+		err = q.Paginate(int(pageParams.Page), int(pageParams.PerPage)).All(&organizations) // #nosec G115
+		pageParams.Count = uint64(q.Paginator.TotalEntriesSize)                             // #nosec G115
+	} else {
+		err = q.All(&organizations)
+	}
+
+	if err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "error loading organizations")
+	}
+
+	return organizations, nil
+}
+
+// FindOrganizationsForUser returns the organizations the user is a member
+// of, ordered by name.
+func FindOrganizationsForUser(tx *storage.Connection, userID uuid.UUID) ([]*Organization, error) {
+	organizations := []*Organization{}
+
+	membersTable := (&pop.Model{Value: OrganizationMember{}}).TableName()
+
+	if err := tx.Q().Where("id in (select organization_id from "+membersTable+" where user_id = ?)", userID).Order("name asc").All(&organizations); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return organizations, nil
+		}
+
+		return nil, errors.Wrap(err, "error loading organizations of user")
+	}
+
+	return organizations, nil
+}
+
+// FindOrganizationMembershipsForUser returns the memberships of the user, in
+// the order the user joined the organizations.
+func FindOrganizationMembershipsForUser(tx *storage.Connection, userID uuid.UUID) ([]OrganizationMembership, error) {
+	memberships := []OrganizationMembership{}
+
+	membersTable := (&pop.Model{Value: OrganizationMember{}}).TableName()
+	organizationsTable := (&pop.Model{Value: Organization{}}).TableName()
+
+	if err := tx.RawQuery("select m.organization_id, o.slug, m.role from "+membersTable+" m join "+organizationsTable+" o on o.id = m.organization_id where m.user_id = ? order by m.created_at asc", userID).All(&memberships); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return memberships, nil
+		}
+
+		return nil, errors.Wrap(err, "error loading organization memberships of user")
+	}
+
+	return memberships, nil
+}
+
+func FindOrganizationMember(tx *storage.Connection, organizationID, userID uuid.UUID) (*OrganizationMember, error) {
+	var member OrganizationMember
+
+	if err := tx.Q().Where("organization_id = ? and user_id = ?", organizationID, userID).First(&member); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, OrganizationMemberNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding organization member")
+	}
+
+	return &member, nil
+}
+
+// FindOrganizationMembers returns the members of the organization, in the
+// order they joined.
+func FindOrganizationMembers(tx *storage.Connection, organizationID uuid.UUID) ([]OrganizationMember, error) {
+	members := []OrganizationMember{}
+
+	if err := tx.Q().Where("organization_id = ?", organizationID).Order("created_at asc").All(&members); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return members, nil
+		}
+
+		return nil, errors.Wrap(err, "error loading organization members")
+	}
+
+	return members, nil
+}
+
+// CountOrganizationOwners returns the number of owners of the organization.
+func CountOrganizationOwners(tx *storage.Connection, organizationID uuid.UUID) (int, error) {
+	count, err := tx.Q().Where("organization_id = ? and role = ?", organizationID, OrganizationRoleOwner).Count(&OrganizationMember{})
+	if err != nil {
+		return 0, errors.Wrap(err, "error counting organization owners")
+	}
+
+	return count, nil
+}
+
+// AddOrganizationMember adds the user to the organization with the role.
+func AddOrganizationMember(tx *storage.Connection, organizationID, userID uuid.UUID, role string) (*OrganizationMember, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, errors.Wrap(err, "error generating unique organization member id")
+	}
+
+	member := &OrganizationMember{
+		ID:             id,
+		OrganizationID: organizationID,
+		UserID:         userID,
+		Role:           role,
+	}
+
+	if err := tx.Create(member); err != nil {
+		return nil, errors.Wrap(err, "error adding organization member")
+	}
+
+	return member, nil
+}
+
+// FindOrganizationInvitations returns the invitations to join the
+// organization, the most recent first.
+func FindOrganizationInvitations(tx *storage.Connection, organizationID uuid.UUID) ([]OrganizationInvitation, error) {
+	invitations := []OrganizationInvitation{}
+
+	if err := tx.Q().Where("organization_id = ?", organizationID).Order("created_at desc").All(&invitations); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return invitations, nil
+		}
+
+		return nil, errors.Wrap(err, "error loading organization invitations")
+	}
+
+	return invitations, nil
+}
+
+func FindOrganizationInvitationByID(tx *storage.Connection, id uuid.UUID) (*OrganizationInvitation, error) {
+	var invitation OrganizationInvitation
+
+	if err := tx.Q().Where("id = ?", id).First(&invitation); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, OrganizationInvitationNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding organization invitation")
+	}
+
+	return &invitation, nil
+}
+
+// FindOrganizationInvitationsForEmail returns the invitations for the email
+// address that have not expired, the most recent first.
+func FindOrganizationInvitationsForEmail(tx *storage.Connection, email string, now time.Time) ([]OrganizationInvitation, error) {
+	invitations := []OrganizationInvitation{}
+
+	if err := tx.Q().Where("email = ? and expires_at > ?", strings.ToLower(email), now).Order("created_at desc").All(&invitations); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return invitations, nil
+		}
+
+		return nil, errors.Wrap(err, "error loading organization invitations for email")
+	}
+
+	return invitations, nil
+}
+
+// DeleteOrganizationInvitationsForEmail deletes the invitations for the email
+// address to join the organization.
+func DeleteOrganizationInvitationsForEmail(tx *storage.Connection, organizationID uuid.UUID, email string) error {
+	if err := tx.RawQuery("DELETE FROM "+(&pop.Model{Value: OrganizationInvitation{}}).TableName()+" WHERE organization_id = ? AND email = ?", organizationID, strings.ToLower(email)).Exec(); err != nil {
+		return errors.Wrap(err, "error deleting organization invitations")
+	}
+
+	return nil
+}
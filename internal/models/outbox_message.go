@@ -0,0 +1,198 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/storage"
+)
+
+const (
+	OutboxChannelEmail = "email"
+	OutboxChannelSMS   = "sms"
+)
+
+// Delivery statuses of outbox messages.
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusSent    = "sent"
+	OutboxStatusFailed  = "failed"
+)
+
+// OutboxMessage is an email or SMS message sent in the background. The
+// payload is what the provider is called with, encrypted with the database
+// encryption key when enabled, as it holds OTPs and links.
+type OutboxMessage struct {
+	ID                uuid.UUID          `json:"id" db:"id"`
+	Channel           string             `json:"channel" db:"channel"`
+	Provider          string             `json:"provider" db:"provider"`
+	Recipient         string             `json:"recipient" db:"recipient"`
+	UserID            *uuid.UUID         `json:"user_id,omitempty" db:"user_id"`
+	Payload           string             `json:"-" db:"payload"`
+	Status            string             `json:"status" db:"status"`
+	Attempts          int                `json:"attempts" db:"attempts"`
+	LastError         storage.NullString `json:"last_error,omitempty" db:"last_error"`
+	ProviderMessageID storage.NullString `json:"provider_message_id,omitempty" db:"provider_message_id"`
+	NextAttemptAt     time.Time          `json:"next_attempt_at" db:"next_attempt_at"`
+	SentAt            *time.Time         `json:"sent_at,omitempty" db:"sent_at"`
+	CreatedAt         time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time          `json:"updated_at" db:"updated_at"`
+}
+
+func (OutboxMessage) TableName() string {
+	tableName := "outbox_messages"
+	return tableName
+}
+
+// NewOutboxMessage creates a pending message of the channel, to be sent to
+// the recipient with the provider as soon as possible.
+func NewOutboxMessage(channel, provider, recipient string, userID *uuid.UUID, payload []byte, encrypt bool, encryptionKeyID, encryptionKey string) (*OutboxMessage, error) {
+	message := &OutboxMessage{
+		ID:            uuid.Must(uuid.NewV4()),
+		Channel:       channel,
+		Provider:      provider,
+		Recipient:     recipient,
+		UserID:        userID,
+		Status:        OutboxStatusPending,
+		NextAttemptAt: time.Now(),
+	}
+
+	message.Payload = string(payload)
+	if encrypt {
+		es, err := crypto.NewEncryptedString(message.ID.String(), payload, encryptionKeyID, encryptionKey)
+		if err != nil {
+			return nil, err
+		}
+		message.Payload = es.String()
+	}
+
+	return message, nil
+}
+
+// GetPayload returns the payload, decrypting it when it's encrypted.
+func (m *OutboxMessage) GetPayload(decryptionKeys map[string]string) ([]byte, error) {
+	if es := crypto.ParseEncryptedString(m.Payload); es != nil {
+		return es.Decrypt(m.ID.String(), decryptionKeys)
+	}
+
+	return []byte(m.Payload), nil
+}
+
+// MarkSent records that the provider accepted the message.
+func (m *OutboxMessage) MarkSent(tx *storage.Connection, providerMessageID string) error {
+	now := time.Now()
+
+	m.Status = OutboxStatusSent
+	m.Attempts += 1
+	m.ProviderMessageID = storage.NullString(providerMessageID)
+	m.SentAt = &now
+
+	return errors.Wrap(tx.UpdateOnly(m, "status", "attempts", "provider_message_id", "sent_at", "updated_at"), "error updating outbox message")
+}
+
+// MarkFailed records the failure of an attempt, and schedules the next one
+// or fails the message when it was the last.
+func (m *OutboxMessage) MarkFailed(tx *storage.Connection, lastError string, maxAttempts int, nextAttemptAt time.Time) error {
+	m.Attempts += 1
+	m.LastError = storage.NullString(lastError)
+	m.NextAttemptAt = nextAttemptAt
+	if m.Attempts >= maxAttempts {
+		m.Status = OutboxStatusFailed
+	}
+
+	return errors.Wrap(tx.UpdateOnly(m, "status", "attempts", "last_error", "next_attempt_at", "updated_at"), "error updating outbox message")
+}
+
+// Postpone moves the next attempt of the message without counting an
+// attempt, like when the provider is rate limited.
+func (m *OutboxMessage) Postpone(tx *storage.Connection, nextAttemptAt time.Time) error {
+	m.NextAttemptAt = nextAttemptAt
+
+	return errors.Wrap(tx.UpdateOnly(m, "next_attempt_at"), "error updating outbox message")
+}
+
+// Retry makes a failed message pending again, to be sent as soon as
+// possible with its attempts reset.
+func (m *OutboxMessage) Retry(tx *storage.Connection) error {
+	m.Status = OutboxStatusPending
+	m.Attempts = 0
+	m.NextAttemptAt = time.Now()
+
+	return errors.Wrap(tx.UpdateOnly(m, "status", "attempts", "next_attempt_at", "updated_at"), "error updating outbox message")
+}
+
+// ClaimDueOutboxMessages returns the pending messages whose next attempt is
+// due, the oldest first, and postpones their next attempt by the lease so
+// other workers don't send them at the same time.
+func ClaimDueOutboxMessages(tx *storage.Connection, limit int, lease time.Duration) ([]*OutboxMessage, error) {
+	messages := []*OutboxMessage{}
+
+	if err := tx.Transaction(func(tx *storage.Connection) error {
+		if err := tx.RawQuery(fmt.Sprintf("select * from %q where status = ? and next_attempt_at <= now() order by next_attempt_at limit ? for update skip locked", (&pop.Model{Value: OutboxMessage{}}).TableName()), OutboxStatusPending, limit).All(&messages); err != nil {
+			if errors.Cause(err) == sql.ErrNoRows {
+				return nil
+			}
+			return err
+		}
+
+		for _, message := range messages {
+			message.NextAttemptAt = time.Now().Add(lease)
+			if err := tx.UpdateOnly(message, "next_attempt_at"); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error claiming outbox messages")
+	}
+
+	return messages, nil
+}
+
+func FindOutboxMessageByID(tx *storage.Connection, id uuid.UUID) (*OutboxMessage, error) {
+	var message OutboxMessage
+
+	if err := tx.Q().Where("id = ?", id).First(&message); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, OutboxMessageNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding outbox message")
+	}
+
+	return &message, nil
+}
+
+// FindOutboxMessages returns the messages with the status and channel, or
+// all of them when they're empty, the most recent first.
+func FindOutboxMessages(tx *storage.Connection, status, channel string, pageParams *Pagination) ([]*OutboxMessage, error) {
+	messages := []*OutboxMessage{}
+
+	q := tx.Q().Order("created_at desc")
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if channel != "" {
+		q = q.Where("channel = ?", channel)
+	}
+
+	var err error
+	if pageParams != nil {
+		err = q.Paginate(int(pageParams.Page), int(pageParams.PerPage)).All(&messages) // #nosec G115
+		pageParams.Count = uint64(q.Paginator.TotalEntriesSize)                        // #nosec G115
+	} else {
+		err = q.All(&messages)
+	}
+
+	if err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "error loading outbox messages")
+	}
+
+	return messages, nil
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// PasswordHistory is a previous password of a user, kept so it can't be
+// reused. The hash is stored like the password of the user was, encrypted
+// when database encryption was enabled, and the entry is created when the
+// password is replaced.
+type PasswordHistory struct {
+	ID                uuid.UUID `json:"id" db:"id"`
+	UserID            uuid.UUID `json:"user_id" db:"user_id"`
+	EncryptedPassword string    `json:"-" db:"encrypted_password"`
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+}
+
+func (PasswordHistory) TableName() string {
+	tableName := "password_history"
+	return tableName
+}
+
+// Matches reports whether the password is the previous password.
+func (h *PasswordHistory) Matches(ctx context.Context, password string, decryptionKeys map[string]string) (bool, error) {
+	hash := h.EncryptedPassword
+	if es := crypto.ParseEncryptedString(hash); es != nil {
+		decrypted, err := es.Decrypt(h.UserID.String(), decryptionKeys)
+		if err != nil {
+			return false, err
+		}
+		hash = string(decrypted)
+	}
+
+	return crypto.CompareHashAndPassword(ctx, hash, password) == nil, nil
+}
+
+// AddPasswordHistory records the replaced password of the user, and removes
+// the previous passwords beyond the depth of the history.
+func AddPasswordHistory(tx *storage.Connection, userID uuid.UUID, encryptedPassword string, depth int) error {
+	entry := &PasswordHistory{
+		ID:                uuid.Must(uuid.NewV4()),
+		UserID:            userID,
+		EncryptedPassword: encryptedPassword,
+	}
+	if err := tx.Create(entry); err != nil {
+		return errors.Wrap(err, "error adding password history")
+	}
+
+	tableName := (&pop.Model{Value: PasswordHistory{}}).TableName()
+	if err := tx.RawQuery(fmt.Sprintf("delete from %q where user_id = ? and id not in (select id from %q where user_id = ? order by created_at desc limit ?)", tableName, tableName), userID, userID, depth).Exec(); err != nil {
+		return errors.Wrap(err, "error pruning password history")
+	}
+
+	return nil
+}
+
+// FindPasswordHistory returns the latest previous passwords of the user, up
+// to the depth, the latest first.
+func FindPasswordHistory(tx *storage.Connection, userID uuid.UUID, depth int) ([]*PasswordHistory, error) {
+	history := []*PasswordHistory{}
+
+	if err := tx.Q().Where("user_id = ?", userID).Order("created_at desc").Limit(depth).All(&history); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return history, nil
+		}
+
+		return nil, errors.Wrap(err, "error finding password history")
+	}
+
+	return history, nil
+}
+
+// ClearPasswordHistoryForUser deletes the previous passwords of the user.
+func ClearPasswordHistoryForUser(tx *storage.Connection, userID uuid.UUID) error {
+	return errors.Wrap(tx.Q().Where("user_id = ?", userID).Delete(PasswordHistory{}), "error deleting password history")
+}
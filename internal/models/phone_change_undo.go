@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// PhoneChangeUndo is a token sent to the old phone number of a user after a
+// phone change, which restores the old number when the change wasn't made by
+// the user.
+type PhoneChangeUndo struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	OldPhone  string    `json:"old_phone" db:"old_phone"`
+	NewPhone  string    `json:"new_phone" db:"new_phone"`
+	TokenHash string    `json:"-" db:"token_hash"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+func (PhoneChangeUndo) TableName() string {
+	tableName := "phone_change_undos"
+	return tableName
+}
+
+// NewPhoneChangeUndo creates the undo of the change from the old phone to
+// the current phone of the user. The token is only stored hashed.
+func NewPhoneChangeUndo(user *User, oldPhone, token string, expiresAt time.Time) *PhoneChangeUndo {
+	return &PhoneChangeUndo{
+		ID:        uuid.Must(uuid.NewV4()),
+		UserID:    user.ID,
+		OldPhone:  oldPhone,
+		NewPhone:  user.GetPhone(),
+		TokenHash: crypto.GenerateTokenHash(oldPhone, token),
+		ExpiresAt: expiresAt,
+	}
+}
+
+// IsExpired reports whether the undo can't be used anymore.
+func (u *PhoneChangeUndo) IsExpired(now time.Time) bool {
+	return now.After(u.ExpiresAt)
+}
+
+func FindPhoneChangeUndoByTokenHash(tx *storage.Connection, tokenHash string) (*PhoneChangeUndo, error) {
+	var undo PhoneChangeUndo
+
+	if err := tx.Q().Where("token_hash = ?", tokenHash).First(&undo); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, PhoneChangeUndoNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding phone change undo")
+	}
+
+	return &undo, nil
+}
+
+// ClearPhoneChangeUndosForUser deletes the undos of all phone changes of the
+// user.
+func ClearPhoneChangeUndosForUser(tx *storage.Connection, userID uuid.UUID) error {
+	return errors.Wrap(tx.Q().Where("user_id = ?", userID).Delete(PhoneChangeUndo{}), "error deleting phone change undos")
+}
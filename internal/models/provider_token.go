@@ -0,0 +1,133 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// ProviderToken holds the access and refresh tokens an external provider
+// issued for an identity. The tokens are encrypted with the database
+// encryption key.
+type ProviderToken struct {
+	ID           uuid.UUID          `json:"id" db:"id"`
+	IdentityID   uuid.UUID          `json:"identity_id" db:"identity_id"`
+	UserID       uuid.UUID          `json:"user_id" db:"user_id"`
+	AccessToken  string             `json:"-" db:"access_token"`
+	RefreshToken storage.NullString `json:"-" db:"refresh_token"`
+	TokenType    storage.NullString `json:"token_type,omitempty" db:"token_type"`
+	ExpiresAt    *time.Time         `json:"expires_at,omitempty" db:"expires_at"`
+	CreatedAt    time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at" db:"updated_at"`
+}
+
+func (ProviderToken) TableName() string {
+	tableName := "provider_tokens"
+	return tableName
+}
+
+// NewProviderToken creates a provider token for the identity.
+func NewProviderToken(identity *Identity) *ProviderToken {
+	return &ProviderToken{
+		ID:         uuid.Must(uuid.NewV4()),
+		IdentityID: identity.ID,
+		UserID:     identity.UserID,
+	}
+}
+
+// SetTokens encrypts and sets the tokens. An empty refresh token keeps the
+// current one, as providers don't always rotate it.
+func (t *ProviderToken) SetTokens(accessToken, refreshToken, tokenType string, expiresAt *time.Time, encryptionKeyID, encryptionKey string) error {
+	es, err := crypto.NewEncryptedString(t.ID.String(), []byte(accessToken), encryptionKeyID, encryptionKey)
+	if err != nil {
+		return err
+	}
+	t.AccessToken = es.String()
+
+	if refreshToken != "" {
+		es, err := crypto.NewEncryptedString(t.ID.String(), []byte(refreshToken), encryptionKeyID, encryptionKey)
+		if err != nil {
+			return err
+		}
+		t.RefreshToken = storage.NullString(es.String())
+	}
+
+	t.TokenType = storage.NullString(tokenType)
+	t.ExpiresAt = expiresAt
+
+	return nil
+}
+
+// GetTokens decrypts the access and refresh tokens.
+func (t *ProviderToken) GetTokens(decryptionKeys map[string]string) (string, string, error) {
+	accessToken, err := t.decrypt(t.AccessToken, decryptionKeys)
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, err := t.decrypt(string(t.RefreshToken), decryptionKeys)
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+func (t *ProviderToken) decrypt(value string, decryptionKeys map[string]string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	es := crypto.ParseEncryptedString(value)
+	if es == nil {
+		return "", errors.New("provider token is not encrypted")
+	}
+
+	bytes, err := es.Decrypt(t.ID.String(), decryptionKeys)
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes), nil
+}
+
+// FindProviderTokenByIdentityID finds the provider token of the identity. The
+// row is locked for update, so that concurrent refreshes don't race.
+func FindProviderTokenByIdentityID(tx *storage.Connection, identityID uuid.UUID) (*ProviderToken, error) {
+	obj := &ProviderToken{}
+	if err := tx.RawQuery("select * from "+(&pop.Model{Value: ProviderToken{}}).TableName()+" where identity_id = ? limit 1 for update", identityID).First(obj); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, ProviderTokenNotFoundError{}
+		}
+		return nil, errors.Wrap(err, "error finding provider token")
+	}
+
+	return obj, nil
+}
+
+// SaveProviderToken stores the tokens the provider issued for the identity,
+// replacing the ones stored before.
+func SaveProviderToken(tx *storage.Connection, identity *Identity, accessToken, refreshToken, tokenType string, expiresAt *time.Time, encryptionKeyID, encryptionKey string) error {
+	token, err := FindProviderTokenByIdentityID(tx, identity.ID)
+	if err != nil && !IsNotFoundError(err) {
+		return err
+	}
+
+	if token == nil {
+		token = NewProviderToken(identity)
+		if err := token.SetTokens(accessToken, refreshToken, tokenType, expiresAt, encryptionKeyID, encryptionKey); err != nil {
+			return err
+		}
+		return errors.Wrap(tx.Create(token), "error creating provider token")
+	}
+
+	if err := token.SetTokens(accessToken, refreshToken, tokenType, expiresAt, encryptionKeyID, encryptionKey); err != nil {
+		return err
+	}
+	return errors.Wrap(tx.Update(token), "error updating provider token")
+}
@@ -44,13 +44,53 @@ type GrantParams struct {
 	SessionNotAfter *time.Time
 	SessionTag      *string
 
+	// AAL and AuthnContextClassRef are set for SSO sign ins where the
+	// identity provider's authentication context determines the
+	// assurance level of the session.
+	AAL                  AuthenticatorAssuranceLevel
+	AuthnContextClassRef *string
+
+	// ImpersonatedBy is set for the sessions admins start on behalf of
+	// users.
+	ImpersonatedBy *string
+
 	UserAgent string
 	IP        string
+
+	// DeviceID is the identifier of the device the client provides, and
+	// Location where the IP address is, when it's known.
+	DeviceID string
+	Location *utilities.GeoLocation
+
+	// Provider is the provider the user signed in with, kept in the
+	// sign-in history.
+	Provider string
 }
 
 func (g *GrantParams) FillGrantParams(r *http.Request) {
 	g.UserAgent = r.Header.Get("User-Agent")
 	g.IP = utilities.GetIPAddress(r)
+	g.DeviceID = utilities.GetDeviceID(r)
+}
+
+// ClientTraits returns where the session with the ID is started from, for
+// the audit log entry about it.
+func (g *GrantParams) ClientTraits(sessionID uuid.UUID) map[string]interface{} {
+	traits := map[string]interface{}{
+		"session_id": sessionID,
+	}
+
+	if g.UserAgent != "" {
+		traits["user_agent"] = g.UserAgent
+	}
+	if g.DeviceID != "" {
+		traits["device_id"] = g.DeviceID
+	}
+	if g.Location != nil {
+		traits["location"] = g.Location
+	}
+
+	return traits
 }
 
 // GrantAuthenticatedUser creates a refresh token for the provided user.
@@ -144,10 +184,26 @@ func createRefreshToken(tx *storage.Connection, user *User, oldToken *RefreshTok
 			session.IP = &params.IP
 		}
 
+		if params.DeviceID != "" {
+			session.DeviceID = &params.DeviceID
+		}
+
+		session.SetLocation(params.Location)
+
 		if params.SessionTag != nil && *params.SessionTag != "" {
 			session.Tag = params.SessionTag
 		}
 
+		if params.AuthnContextClassRef != nil {
+			aal := params.AAL.String()
+			session.AAL = &aal
+			session.AuthnContextClassRef = params.AuthnContextClassRef
+		}
+
+		if params.ImpersonatedBy != nil {
+			session.ImpersonatedBy = params.ImpersonatedBy
+		}
+
 		if err := tx.Create(session); err != nil {
 			return nil, errors.Wrap(err, "error creating new session")
 		}
@@ -159,8 +215,11 @@ func createRefreshToken(tx *storage.Connection, user *User, oldToken *RefreshTok
 		return nil, errors.Wrap(err, "error creating refresh token")
 	}
 
-	if err := user.UpdateLastSignInAt(tx); err != nil {
-		return nil, errors.Wrap(err, "error update user`s last_sign_in field")
+	// admins impersonating users don't sign in as them
+	if params.ImpersonatedBy == nil {
+		if err := user.UpdateLastSignInAt(tx); err != nil {
+			return nil, errors.Wrap(err, "error update user`s last_sign_in field")
+		}
 	}
 	return token, nil
 }
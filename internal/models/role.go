@@ -0,0 +1,208 @@
+package models
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// RolePermissions are the permissions a role grants, like posts:write.
+type RolePermissions []string
+
+func (p *RolePermissions) Scan(src interface{}) error {
+	if src == nil {
+		*p = RolePermissions{}
+		return nil
+	}
+
+	b, ok := src.([]byte)
+	if !ok {
+		return errors.New("scan source was not []byte")
+	}
+	return json.Unmarshal(b, p)
+}
+
+func (p RolePermissions) Value() (driver.Value, error) {
+	if p == nil {
+		p = RolePermissions{}
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Role is a named set of permissions assigned to users. Unlike the role
+// claim, which is the Postgres role requests are made with, users can have
+// any number of roles.
+type Role struct {
+	ID uuid.UUID `db:"id" json:"id"`
+
+	Name        string             `db:"name" json:"name"`
+	Description storage.NullString `db:"description" json:"description,omitempty"`
+	Permissions RolePermissions    `db:"permissions" json:"permissions"`
+
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+func (r Role) TableName() string {
+	return "roles"
+}
+
+// UserRole records the assignment of a role to a user.
+type UserRole struct {
+	ID uuid.UUID `db:"id" json:"-"`
+
+	UserID uuid.UUID `db:"user_id" json:"user_id"`
+	RoleID uuid.UUID `db:"role_id" json:"role_id"`
+
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+func (r UserRole) TableName() string {
+	return "user_roles"
+}
+
+// NewRole creates a role, which has to be saved.
+func NewRole(name, description string, permissions []string) (*Role, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, errors.Wrap(err, "error generating unique role id")
+	}
+
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	return &Role{
+		ID:          id,
+		Name:        name,
+		Description: storage.NullString(description),
+		Permissions: permissions,
+	}, nil
+}
+
+func FindRoleByID(tx *storage.Connection, id uuid.UUID) (*Role, error) {
+	var role Role
+
+	if err := tx.Q().Where("id = ?", id).First(&role); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, RoleNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding role")
+	}
+
+	return &role, nil
+}
+
+func FindRoleByName(tx *storage.Connection, name string) (*Role, error) {
+	var role Role
+
+	if err := tx.Q().Where("name = ?", name).First(&role); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, RoleNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding role by name")
+	}
+
+	return &role, nil
+}
+
+// FindRoles returns all roles, ordered by name.
+func FindRoles(tx *storage.Connection) ([]*Role, error) {
+	roles := []*Role{}
+
+	if err := tx.Q().Order("name asc").All(&roles); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return roles, nil
+		}
+
+		return nil, errors.Wrap(err, "error loading roles")
+	}
+
+	return roles, nil
+}
+
+// FindRolesForUser returns the roles assigned to the user, ordered by
+// name.
+func FindRolesForUser(tx *storage.Connection, userID uuid.UUID) ([]*Role, error) {
+	roles := []*Role{}
+
+	userRolesTable := (&pop.Model{Value: UserRole{}}).TableName()
+
+	if err := tx.Q().Where("id in (select role_id from "+userRolesTable+" where user_id = ?)", userID).Order("name asc").All(&roles); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return roles, nil
+		}
+
+		return nil, errors.Wrap(err, "error loading roles of user")
+	}
+
+	return roles, nil
+}
+
+// RoleClaims returns the names of the roles and the permissions they grant
+// together, without duplicates, as included in access tokens.
+func RoleClaims(roles []*Role) ([]string, []string) {
+	names := make([]string, 0, len(roles))
+	granted := make(map[string]bool)
+	permissions := []string{}
+
+	for _, role := range roles {
+		names = append(names, role.Name)
+
+		for _, permission := range role.Permissions {
+			if !granted[permission] {
+				granted[permission] = true
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+
+	sort.Strings(permissions)
+
+	return names, permissions
+}
+
+// AssignUserRole assigns the role to the user. Assigning a role the user
+// already has does nothing.
+func AssignUserRole(tx *storage.Connection, userID, roleID uuid.UUID) error {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return errors.Wrap(err, "error generating unique user role id")
+	}
+
+	userRolesTable := (&pop.Model{Value: UserRole{}}).TableName()
+
+	if err := tx.RawQuery("insert into "+userRolesTable+" (id, user_id, role_id, created_at, updated_at) values (?, ?, ?, now(), now()) on conflict (user_id, role_id) do nothing", id, userID, roleID).Exec(); err != nil {
+		return errors.Wrap(err, "error assigning role to user")
+	}
+
+	return nil
+}
+
+// RemoveUserRole removes the role from the user, and returns false if the
+// user didn't have it.
+func RemoveUserRole(tx *storage.Connection, userID, roleID uuid.UUID) (bool, error) {
+	userRolesTable := (&pop.Model{Value: UserRole{}}).TableName()
+
+	count, err := tx.RawQuery("delete from "+userRolesTable+" where user_id = ? and role_id = ?", userID, roleID).ExecWithCount()
+	if err != nil {
+		return false, errors.Wrap(err, "error removing role from user")
+	}
+
+	return count > 0, nil
+}
@@ -0,0 +1,207 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// SCIMGroup is a group provisioned by an SSO provider with SCIM.
+type SCIMGroup struct {
+	ID uuid.UUID `db:"id"`
+
+	SSOProviderID uuid.UUID `db:"sso_provider_id"`
+
+	DisplayName string  `db:"display_name"`
+	ExternalID  *string `db:"external_id"`
+
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+func (g SCIMGroup) TableName() string {
+	return "scim_groups"
+}
+
+// SCIMGroupMember records the membership of a user in a SCIM group.
+type SCIMGroupMember struct {
+	GroupID uuid.UUID `db:"group_id"`
+	UserID  uuid.UUID `db:"user_id"`
+
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func (m SCIMGroupMember) TableName() string {
+	return "scim_group_members"
+}
+
+// HashSCIMToken returns the hash of a SCIM bearer token as stored in the
+// database.
+func HashSCIMToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// SSOProviderIdentity returns the identity provider name of users signing
+// in with the SSO provider.
+func SSOProviderIdentity(ssoProviderID uuid.UUID) string {
+	return "sso:" + ssoProviderID.String()
+}
+
+// FindSSOProviderBySCIMToken finds the SSO provider the SCIM bearer token
+// was issued for.
+func FindSSOProviderBySCIMToken(tx *storage.Connection, token string) (*SSOProvider, error) {
+	var ssoProvider SSOProvider
+
+	if err := tx.Eager().Q().Where("scim_token_hash = ?", HashSCIMToken(token)).First(&ssoProvider); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, SSOProviderNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding SSO provider by SCIM token")
+	}
+
+	return &ssoProvider, nil
+}
+
+// FindSCIMUsers returns the users of the SSO provider. If userName is not
+// empty, only the user with that SSO identity (compared case-insensitively)
+// is returned.
+func FindSCIMUsers(tx *storage.Connection, ssoProviderID uuid.UUID, userName string) ([]*User, error) {
+	users := []*User{}
+
+	identityTable := (&pop.Model{Value: Identity{}}).TableName()
+
+	q := tx.Q()
+	if userName != "" {
+		q = q.Where("id in (select user_id from "+identityTable+" where provider = ? and lower(provider_id) = ?)", SSOProviderIdentity(ssoProviderID), strings.ToLower(userName))
+	} else {
+		q = q.Where("id in (select user_id from "+identityTable+" where provider = ?)", SSOProviderIdentity(ssoProviderID))
+	}
+
+	if err := q.Order("created_at asc").All(&users); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return users, nil
+		}
+
+		return nil, errors.Wrap(err, "error loading SCIM users")
+	}
+
+	return users, nil
+}
+
+// FindSCIMUserByID returns the user with the ID and its identity with the
+// SSO provider. Users of other providers are not found.
+func FindSCIMUserByID(tx *storage.Connection, ssoProviderID, userID uuid.UUID) (*User, *Identity, error) {
+	var identity Identity
+
+	if err := tx.Q().Where("user_id = ? and provider = ?", userID, SSOProviderIdentity(ssoProviderID)).First(&identity); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, nil, UserNotFoundError{}
+		}
+
+		return nil, nil, errors.Wrap(err, "error finding SCIM user identity")
+	}
+
+	user, err := FindUserByID(tx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, &identity, nil
+}
+
+// FindSCIMGroups returns the groups of the SSO provider, ordered by their
+// creation.
+func FindSCIMGroups(tx *storage.Connection, ssoProviderID uuid.UUID) ([]SCIMGroup, error) {
+	groups := []SCIMGroup{}
+
+	if err := tx.Q().Where("sso_provider_id = ?", ssoProviderID).Order("created_at asc").All(&groups); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return groups, nil
+		}
+
+		return nil, errors.Wrap(err, "error loading SCIM groups")
+	}
+
+	return groups, nil
+}
+
+// FindSCIMGroupByID returns the group of the SSO provider with the ID.
+func FindSCIMGroupByID(tx *storage.Connection, ssoProviderID, groupID uuid.UUID) (*SCIMGroup, error) {
+	var group SCIMGroup
+
+	if err := tx.Q().Where("id = ? and sso_provider_id = ?", groupID, ssoProviderID).First(&group); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, SCIMGroupNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding SCIM group")
+	}
+
+	return &group, nil
+}
+
+// FindSCIMGroupMembers returns the IDs of the members of the group.
+func FindSCIMGroupMembers(tx *storage.Connection, groupID uuid.UUID) ([]uuid.UUID, error) {
+	members := []SCIMGroupMember{}
+
+	if err := tx.Q().Where("group_id = ?", groupID).Order("created_at asc").All(&members); err != nil {
+		if errors.Cause(err) != sql.ErrNoRows {
+			return nil, errors.Wrap(err, "error loading SCIM group members")
+		}
+	}
+
+	ids := make([]uuid.UUID, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.UserID)
+	}
+
+	return ids, nil
+}
+
+// FindSCIMGroupsForUser returns the groups the user is a member of.
+func FindSCIMGroupsForUser(tx *storage.Connection, userID uuid.UUID) ([]SCIMGroup, error) {
+	groups := []SCIMGroup{}
+
+	membersTable := (&pop.Model{Value: SCIMGroupMember{}}).TableName()
+
+	if err := tx.Q().Where("id in (select group_id from "+membersTable+" where user_id = ?)", userID).Order("display_name asc").All(&groups); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return groups, nil
+		}
+
+		return nil, errors.Wrap(err, "error loading SCIM groups of user")
+	}
+
+	return groups, nil
+}
+
+// SetSCIMGroupMembers replaces the members of the group.
+func SetSCIMGroupMembers(tx *storage.Connection, groupID uuid.UUID, userIDs []uuid.UUID) error {
+	if err := tx.RawQuery("DELETE FROM "+(&pop.Model{Value: SCIMGroupMember{}}).TableName()+" WHERE group_id = ?", groupID).Exec(); err != nil {
+		return errors.Wrap(err, "error removing SCIM group members")
+	}
+
+	seen := make(map[uuid.UUID]bool, len(userIDs))
+	for _, userID := range userIDs {
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+
+		// pop can't create models without an ID column
+		if err := tx.RawQuery("INSERT INTO "+(&pop.Model{Value: SCIMGroupMember{}}).TableName()+" (group_id, user_id, created_at) VALUES (?, ?, ?)", groupID, userID, time.Now()).Exec(); err != nil {
+			return errors.Wrap(err, "error adding SCIM group member")
+		}
+	}
+
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/gofrs/uuid"
 	"github.com/pkg/errors"
 	"github.com/supabase/auth/internal/storage"
+	"github.com/supabase/auth/internal/utilities"
 )
 
 type AuthenticatorAssuranceLevel int
@@ -41,6 +42,12 @@ type AMREntry struct {
 	Provider  string `json:"provider,omitempty"`
 }
 
+// ActorClaim is the act claim of the access tokens of impersonation
+// sessions, naming the admin acting on behalf of the user (RFC 8693).
+type ActorClaim struct {
+	Subject string `json:"sub"`
+}
+
 type sortAMREntries struct {
 	Array []AMREntry
 }
@@ -74,7 +81,25 @@ type Session struct {
 	UserAgent   *string    `json:"user_agent,omitempty" db:"user_agent"`
 	IP          *string    `json:"ip,omitempty" db:"ip"`
 
+	// DeviceID identifies the device of the session, when the client
+	// provides it.
+	DeviceID *string `json:"device_id,omitempty" db:"device_id"`
+
+	// Country, Region and City are where the IP address of the session
+	// is, when the GeoIP database knows it.
+	Country *string `json:"country,omitempty" db:"country"`
+	Region  *string `json:"region,omitempty" db:"region"`
+	City    *string `json:"city,omitempty" db:"city"`
+
 	Tag *string `json:"tag" db:"tag"`
+
+	// AuthnContextClassRef is how the identity provider authenticated the
+	// user of SSO sessions, if it told.
+	AuthnContextClassRef *string `json:"authn_context_class_ref,omitempty" db:"authn_context_class_ref"`
+
+	// ImpersonatedBy is the admin that started the session on behalf of
+	// the user, for impersonation sessions.
+	ImpersonatedBy *string `json:"impersonated_by,omitempty" db:"impersonated_by"`
 }
 
 func (Session) TableName() string {
@@ -103,7 +128,56 @@ func (s *Session) LastRefreshedAt(refreshTokenTime *time.Time) time.Time {
 }
 
 func (s *Session) UpdateOnlyRefreshInfo(tx *storage.Connection) error {
-	return tx.UpdateOnly(s, "refreshed_at", "user_agent", "ip")
+	return tx.UpdateOnly(s, "refreshed_at", "user_agent", "ip", "device_id", "country", "region", "city")
+}
+
+// SetLocation records where the IP address of the session is, or clears
+// it when it's unknown.
+func (s *Session) SetLocation(location *utilities.GeoLocation) {
+	s.Country, s.Region, s.City = nil, nil, nil
+	if location == nil {
+		return
+	}
+
+	if location.Country != "" {
+		s.Country = &location.Country
+	}
+	if location.Region != "" {
+		s.Region = &location.Region
+	}
+	if location.City != "" {
+		s.City = &location.City
+	}
+}
+
+// ClientTraits returns where the session is used from, for the audit log
+// entries about it.
+func (s *Session) ClientTraits() map[string]interface{} {
+	traits := map[string]interface{}{
+		"session_id": s.ID,
+	}
+
+	if s.UserAgent != nil {
+		traits["user_agent"] = *s.UserAgent
+	}
+	if s.DeviceID != nil {
+		traits["device_id"] = *s.DeviceID
+	}
+	if s.Country != nil || s.Region != nil || s.City != nil {
+		location := &utilities.GeoLocation{}
+		if s.Country != nil {
+			location.Country = *s.Country
+		}
+		if s.Region != nil {
+			location.Region = *s.Region
+		}
+		if s.City != nil {
+			location.City = *s.City
+		}
+		traits["location"] = location
+	}
+
+	return traits
 }
 
 type SessionValidityReason = int
@@ -249,6 +323,22 @@ func FindAllSessionsForUser(tx *storage.Connection, userId uuid.UUID, forUpdate
 	return sessions, nil
 }
 
+// LockUserSessions locks the row of the user until the end of the
+// transaction, waiting for other transactions to release it, so that the
+// sessions of the user are counted and created one sign-in at a time.
+func LockUserSessions(tx *storage.Connection, userID uuid.UUID) error {
+	user := &User{}
+	if err := tx.RawQuery(fmt.Sprintf("SELECT id FROM %q WHERE id = ? LIMIT 1 FOR UPDATE;", user.TableName()), userID).First(user); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return UserNotFoundError{}
+		}
+
+		return errors.Wrap(err, "error locking user sessions")
+	}
+
+	return nil
+}
+
 func updateFactorAssociatedSessions(tx *storage.Connection, userID, factorID uuid.UUID, aal string) error {
 	return tx.RawQuery("UPDATE "+(&pop.Model{Value: Session{}}).TableName()+" set aal = ?, factor_id = ? WHERE user_id = ? AND factor_id = ?", aal, nil, userID, factorID).Exec()
 }
@@ -272,6 +362,12 @@ func LogoutAllExceptMe(tx *storage.Connection, sessionId uuid.UUID, userID uuid.
 	return tx.RawQuery("DELETE FROM "+(&pop.Model{Value: Session{}}).TableName()+" WHERE id != ? AND user_id = ?", sessionId, userID).Exec()
 }
 
+// LogoutImpersonationSessions deletes the impersonation sessions of a user,
+// and returns how many were deleted.
+func LogoutImpersonationSessions(tx *storage.Connection, userID uuid.UUID) (int, error) {
+	return tx.RawQuery("DELETE FROM "+(&pop.Model{Value: Session{}}).TableName()+" WHERE user_id = ? AND impersonated_by IS NOT NULL", userID).ExecWithCount()
+}
+
 func (s *Session) UpdateAALAndAssociatedFactor(tx *storage.Connection, aal AuthenticatorAssuranceLevel, factorID *uuid.UUID) error {
 	s.FactorID = factorID
 	aalAsString := aal.String()
@@ -281,6 +377,11 @@ func (s *Session) UpdateAALAndAssociatedFactor(tx *storage.Connection, aal Authe
 
 func (s *Session) CalculateAALAndAMR(user *User) (aal AuthenticatorAssuranceLevel, amr []AMREntry, err error) {
 	amr, aal = []AMREntry{}, AAL1
+	if s.AuthnContextClassRef != nil && s.IsAAL2() {
+		// the identity provider authenticated the user with an
+		// authentication context mapped to AAL2
+		aal = AAL2
+	}
 	for _, claim := range s.AMRClaims {
 		if claim.IsAAL2Claim() {
 			aal = AAL2
@@ -302,8 +403,8 @@ func (s *Session) CalculateAALAndAMR(user *User) (aal AuthenticatorAssuranceLeve
 
 	lastIndex := len(amr) - 1
 
-	if lastIndex > -1 && amr[lastIndex].Method == SSOSAML.String() {
-		// initial AMR claim is from sso/saml, we need to add information
+	if lastIndex > -1 && (amr[lastIndex].Method == SSOSAML.String() || amr[lastIndex].Method == SSOOIDC.String()) {
+		// initial AMR claim is from sso/saml or sso/oidc, we need to add information
 		// about the provider that was used for the authentication
 		identities := user.Identities
 
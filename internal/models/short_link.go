@@ -0,0 +1,65 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// ShortLink is a short link sent in an email instead of a long link with a
+// token, which redirects to the long link once before it expires.
+type ShortLink struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	Slug      string     `json:"-" db:"slug"`
+	TargetURL string     `json:"-" db:"target_url"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+func (ShortLink) TableName() string {
+	tableName := "short_links"
+	return tableName
+}
+
+// NewShortLink creates the short link with the slug, to the target URL.
+func NewShortLink(slug, targetURL string, expiresAt time.Time) *ShortLink {
+	return &ShortLink{
+		ID:        uuid.Must(uuid.NewV4()),
+		Slug:      slug,
+		TargetURL: targetURL,
+		ExpiresAt: expiresAt,
+	}
+}
+
+// UseShortLink marks the short link with the slug as used, and returns it.
+// Links that were used or expired can't be used again.
+func UseShortLink(tx *storage.Connection, slug string) (*ShortLink, error) {
+	var link ShortLink
+
+	if err := tx.Transaction(func(tx *storage.Connection) error {
+		if err := tx.RawQuery(fmt.Sprintf("select * from %q where slug = ? for update", (&pop.Model{Value: ShortLink{}}).TableName()), slug).First(&link); err != nil {
+			if errors.Cause(err) == sql.ErrNoRows {
+				return ShortLinkNotFoundError{}
+			}
+			return errors.Wrap(err, "error finding short link")
+		}
+
+		now := time.Now()
+		if link.UsedAt != nil || now.After(link.ExpiresAt) {
+			return ShortLinkNotFoundError{}
+		}
+
+		link.UsedAt = &now
+		return errors.Wrap(tx.UpdateOnly(&link, "used_at"), "error updating short link")
+	}); err != nil {
+		return nil, err
+	}
+
+	return &link, nil
+}
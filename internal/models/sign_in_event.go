@@ -0,0 +1,76 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// SignInEvent is a sign-in of a user, successful or not, kept so users can
+// spot the sign-ins they didn't make.
+type SignInEvent struct {
+	ID            uuid.UUID `json:"id" db:"id"`
+	UserID        uuid.UUID `json:"-" db:"user_id"`
+	Method        string    `json:"method" db:"method"`
+	Provider      *string   `json:"provider,omitempty" db:"provider"`
+	IP            *string   `json:"ip,omitempty" db:"ip"`
+	UserAgent     *string   `json:"user_agent,omitempty" db:"user_agent"`
+	Success       bool      `json:"success" db:"success"`
+	FailureReason *string   `json:"failure_reason,omitempty" db:"failure_reason"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+func (SignInEvent) TableName() string {
+	tableName := "sign_in_events"
+	return tableName
+}
+
+// NewSignInEvent creates a sign-in of the user with the authentication
+// method. The failure reason is empty for successful sign-ins.
+func NewSignInEvent(userID uuid.UUID, method AuthenticationMethod, provider, ip, userAgent, failureReason string) *SignInEvent {
+	event := &SignInEvent{
+		ID:      uuid.Must(uuid.NewV4()),
+		UserID:  userID,
+		Method:  method.String(),
+		Success: failureReason == "",
+	}
+
+	if provider != "" {
+		event.Provider = &provider
+	}
+	if ip != "" {
+		event.IP = &ip
+	}
+	if userAgent != "" {
+		event.UserAgent = &userAgent
+	}
+	if failureReason != "" {
+		event.FailureReason = &failureReason
+	}
+
+	return event
+}
+
+// FindSignInEventsByUserID returns the latest sign-ins of the user, up to
+// the limit, the latest first.
+func FindSignInEventsByUserID(tx *storage.Connection, userID uuid.UUID, limit int) ([]*SignInEvent, error) {
+	events := []*SignInEvent{}
+
+	if err := tx.Q().Where("user_id = ?", userID).Order("created_at desc").Limit(limit).All(&events); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return events, nil
+		}
+
+		return nil, errors.Wrap(err, "error finding sign in events")
+	}
+
+	return events, nil
+}
+
+// ClearSignInEventsForUser deletes the sign-ins of the user.
+func ClearSignInEventsForUser(tx *storage.Connection, userID uuid.UUID) error {
+	return errors.Wrap(tx.Q().Where("user_id = ?", userID).Delete(SignInEvent{}), "error deleting sign in events")
+}
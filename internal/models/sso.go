@@ -4,14 +4,19 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"encoding/json"
+	"fmt"
 	"reflect"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/crewjam/saml"
 	"github.com/crewjam/saml/samlsp"
+	"github.com/gobuffalo/pop/v6"
 	"github.com/gofrs/uuid"
 	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/crypto"
 	"github.com/supabase/auth/internal/storage"
 )
 
@@ -19,8 +24,11 @@ type SSOProvider struct {
 	ID uuid.UUID `db:"id" json:"id"`
 
 	SAMLProvider SAMLProvider `has_one:"saml_providers" fk_id:"sso_provider_id" json:"saml,omitempty"`
+	OIDCProvider OIDCProvider `has_one:"oidc_providers" fk_id:"sso_provider_id" json:"oidc,omitempty"`
 	SSODomains   []SSODomain  `has_many:"sso_domains" fk_id:"sso_provider_id" json:"domains"`
 
+	SCIMTokenHash *string `db:"scim_token_hash" json:"-"`
+
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
 }
@@ -30,9 +38,48 @@ func (p SSOProvider) TableName() string {
 }
 
 func (p SSOProvider) Type() string {
+	if p.OIDCProvider.ID != uuid.Nil {
+		return "oidc"
+	}
+
 	return "saml"
 }
 
+// MarshalJSON includes the type of the provider and only the configuration
+// of its connection.
+func (p SSOProvider) MarshalJSON() ([]byte, error) {
+	type ssoProvider SSOProvider
+
+	value := struct {
+		ssoProvider
+
+		Type         string        `json:"type"`
+		SAMLProvider *SAMLProvider `json:"saml,omitempty"`
+		OIDCProvider *OIDCProvider `json:"oidc,omitempty"`
+	}{
+		ssoProvider: ssoProvider(p),
+		Type:        p.Type(),
+	}
+
+	if value.Type == "oidc" {
+		value.OIDCProvider = &p.OIDCProvider
+	} else {
+		value.SAMLProvider = &p.SAMLProvider
+	}
+
+	return json.Marshal(value)
+}
+
+// Provisioning returns the provisioning rules of the SAML or OIDC
+// connection of the provider.
+func (p *SSOProvider) Provisioning() *SSOProvisioning {
+	if p.Type() == "oidc" {
+		return &p.OIDCProvider.Provisioning
+	}
+
+	return &p.SAMLProvider.Provisioning
+}
+
 type SAMLAttribute struct {
 	Name    string      `json:"name,omitempty"`
 	Names   []string    `json:"names,omitempty"`
@@ -109,6 +156,243 @@ func (m SAMLAttributeMapping) Value() (driver.Value, error) {
 	return string(b), nil
 }
 
+// SSOProvisioning defines how users signing in with an SSO provider are
+// provisioned. The rules apply to new users, and to existing users on each
+// sign in if UpdateExistingUsers is set.
+type SSOProvisioning struct {
+	// DefaultRole is the role given to users, if set.
+	DefaultRole string `json:"default_role,omitempty"`
+
+	// AppMetadata is merged into the app_metadata of users. String values
+	// can reference the mapped attributes like {{ department }}.
+	AppMetadata map[string]interface{} `json:"app_metadata,omitempty"`
+
+	// AppMetadataAttributes maps app_metadata keys to the names of mapped
+	// attributes whose values are copied into them.
+	AppMetadataAttributes map[string]string `json:"app_metadata_attributes,omitempty"`
+
+	UpdateExistingUsers bool `json:"update_existing_users,omitempty"`
+
+	// GroupsAttribute is the mapped attribute holding the groups of users,
+	// groups if empty.
+	GroupsAttribute string `json:"groups_attribute,omitempty"`
+
+	// GroupMappings give the members of groups a role and app_metadata
+	// entries. Unlike the other rules they are applied on every sign in, so
+	// that users lose what a group gave them once they leave it.
+	GroupMappings []SSOGroupMapping `json:"group_mappings,omitempty"`
+}
+
+// SSOGroupMapping gives the members of a group of the identity provider a
+// role and app_metadata entries.
+type SSOGroupMapping struct {
+	Group string `json:"group"`
+
+	// Role is given to the members of the group. The first mapping of
+	// a group of the user with a role wins.
+	Role string `json:"role,omitempty"`
+
+	// AppMetadata is merged into the app_metadata of the members of the
+	// group. Arrays given by several groups are combined.
+	AppMetadata map[string]interface{} `json:"app_metadata,omitempty"`
+}
+
+var ssoProvisioningTemplateVariable = regexp.MustCompile(`\{\{\s*([^{}\s]+)\s*\}\}`)
+
+// IsEmpty returns whether no provisioning rules, other than group mappings,
+// are defined.
+func (p *SSOProvisioning) IsEmpty() bool {
+	return p.DefaultRole == "" && len(p.AppMetadata) == 0 && len(p.AppMetadataAttributes) == 0
+}
+
+// GroupsFor returns the groups of a user with the attributes.
+func (p *SSOProvisioning) GroupsFor(attributes map[string]interface{}) []string {
+	name := p.GroupsAttribute
+	if name == "" {
+		name = "groups"
+	}
+
+	switch v := attributes[name].(type) {
+	case string:
+		return []string{v}
+
+	case []string:
+		return v
+
+	case []interface{}:
+		groups := make([]string, 0, len(v))
+		for _, item := range v {
+			if group, ok := item.(string); ok {
+				groups = append(groups, group)
+			}
+		}
+		return groups
+	}
+
+	return nil
+}
+
+// MapsGroupRoles returns whether any group mapping gives a role.
+func (p *SSOProvisioning) MapsGroupRoles() bool {
+	for _, mapping := range p.GroupMappings {
+		if mapping.Role != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GroupRoleFor returns the role of the first group mapping with a role
+// matching one of the groups, or an empty string when none matches. Groups
+// are matched case-insensitively.
+func (p *SSOProvisioning) GroupRoleFor(groups []string) string {
+	for _, mapping := range p.GroupMappings {
+		if mapping.Role != "" && hasSSOGroup(groups, mapping.Group) {
+			return mapping.Role
+		}
+	}
+
+	return ""
+}
+
+// GroupAppMetadataFor returns the app_metadata updates for a user in the
+// groups: the entries of the mappings matching the groups, and nil for the
+// keys of the other mappings so that they are removed.
+func (p *SSOProvisioning) GroupAppMetadataFor(groups []string) map[string]interface{} {
+	appMetadata := make(map[string]interface{})
+
+	for _, mapping := range p.GroupMappings {
+		if hasSSOGroup(groups, mapping.Group) {
+			continue
+		}
+
+		for key := range mapping.AppMetadata {
+			appMetadata[key] = nil
+		}
+	}
+
+	for _, mapping := range p.GroupMappings {
+		if !hasSSOGroup(groups, mapping.Group) {
+			continue
+		}
+
+		for key, value := range mapping.AppMetadata {
+			existing, _ := appMetadata[key].([]interface{})
+			values, isArray := value.([]interface{})
+
+			if existing == nil || !isArray {
+				appMetadata[key] = value
+				continue
+			}
+
+			merged := append([]interface{}{}, existing...)
+			for _, item := range values {
+				if !containsSSOGroupValue(merged, item) {
+					merged = append(merged, item)
+				}
+			}
+			appMetadata[key] = merged
+		}
+	}
+
+	return appMetadata
+}
+
+func hasSSOGroup(groups []string, group string) bool {
+	for _, g := range groups {
+		if strings.EqualFold(g, group) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsSSOGroupValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AppMetadataFor returns the app_metadata for a user with the attributes.
+// Attributes that are missing render as empty strings in AppMetadata and
+// are left out of AppMetadataAttributes.
+func (p *SSOProvisioning) AppMetadataFor(attributes map[string]interface{}) map[string]interface{} {
+	appMetadata := make(map[string]interface{})
+
+	for key, value := range p.AppMetadata {
+		appMetadata[key] = renderSSOProvisioningTemplate(value, attributes)
+	}
+
+	for key, attribute := range p.AppMetadataAttributes {
+		if value, ok := attributes[attribute]; ok {
+			appMetadata[key] = value
+		}
+	}
+
+	return appMetadata
+}
+
+func renderSSOProvisioningTemplate(value interface{}, attributes map[string]interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return ssoProvisioningTemplateVariable.ReplaceAllStringFunc(v, func(match string) string {
+			name := ssoProvisioningTemplateVariable.FindStringSubmatch(match)[1]
+
+			switch attribute := attributes[name].(type) {
+			case nil:
+				return ""
+			case string:
+				return attribute
+			default:
+				return fmt.Sprintf("%v", attribute)
+			}
+		})
+
+	case map[string]interface{}:
+		rendered := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			rendered[key] = renderSSOProvisioningTemplate(item, attributes)
+		}
+		return rendered
+
+	case []interface{}:
+		rendered := make([]interface{}, len(v))
+		for i, item := range v {
+			rendered[i] = renderSSOProvisioningTemplate(item, attributes)
+		}
+		return rendered
+	}
+
+	return value
+}
+
+func (p *SSOProvisioning) Scan(src interface{}) error {
+	if src == nil {
+		*p = SSOProvisioning{}
+		return nil
+	}
+
+	b, ok := src.([]byte)
+	if !ok {
+		return errors.New("scan source was not []byte")
+	}
+	return json.Unmarshal(b, p)
+}
+
+func (p SSOProvisioning) Value() (driver.Value, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
 type SAMLProvider struct {
 	ID uuid.UUID `db:"id" json:"-"`
 
@@ -123,6 +407,14 @@ type SAMLProvider struct {
 
 	NameIDFormat *string `db:"name_id_format" json:"name_id_format,omitempty"`
 
+	SingleLogoutEnabled bool `db:"single_logout_enabled" json:"single_logout_enabled"`
+
+	Provisioning SSOProvisioning `db:"provisioning" json:"provisioning"`
+
+	AssertionPolicy SAMLAssertionPolicy `db:"assertion_policy" json:"assertion_policy"`
+
+	AuthnRequestPolicy SAMLAuthnRequestPolicy `db:"authn_request_policy" json:"authn_request_policy"`
+
 	CreatedAt time.Time `db:"created_at" json:"-"`
 	UpdatedAt time.Time `db:"updated_at" json:"-"`
 }
@@ -135,6 +427,178 @@ func (p SAMLProvider) EntityDescriptor() (*saml.EntityDescriptor, error) {
 	return samlsp.ParseMetadata([]byte(p.MetadataXML))
 }
 
+// OIDCProvider is the OpenID Connect connection of an SSO provider, for
+// identity providers that don't support SAML.
+type OIDCProvider struct {
+	ID uuid.UUID `db:"id" json:"-"`
+
+	SSOProvider   *SSOProvider `belongs_to:"sso_providers" json:"-"`
+	SSOProviderID uuid.UUID    `db:"sso_provider_id" json:"-"`
+
+	Issuer       string             `db:"issuer" json:"issuer"`
+	ClientID     string             `db:"client_id" json:"client_id"`
+	ClientSecret string             `db:"client_secret" json:"-"`
+	Scopes       storage.NullString `db:"scopes" json:"scopes,omitempty"`
+
+	Provisioning SSOProvisioning `db:"provisioning" json:"provisioning"`
+
+	CreatedAt time.Time `db:"created_at" json:"-"`
+	UpdatedAt time.Time `db:"updated_at" json:"-"`
+}
+
+func (p OIDCProvider) TableName() string {
+	return "oidc_providers"
+}
+
+// SetClientSecret sets the client secret, encrypting it if enabled. The ID
+// of the provider needs to be set beforehand.
+func (p *OIDCProvider) SetClientSecret(secret string, encrypt bool, encryptionKeyID, encryptionKey string) error {
+	p.ClientSecret = secret
+	if encrypt {
+		es, err := crypto.NewEncryptedString(p.ID.String(), []byte(secret), encryptionKeyID, encryptionKey)
+		if err != nil {
+			return err
+		}
+
+		p.ClientSecret = es.String()
+	}
+
+	return nil
+}
+
+// GetClientSecret returns the decrypted client secret.
+func (p *OIDCProvider) GetClientSecret(decryptionKeys map[string]string) (string, error) {
+	if es := crypto.ParseEncryptedString(p.ClientSecret); es != nil {
+		bytes, err := es.Decrypt(p.ID.String(), decryptionKeys)
+		if err != nil {
+			return "", err
+		}
+
+		return string(bytes), nil
+	}
+
+	return p.ClientSecret, nil
+}
+
+// SAMLAssertionPolicy defines how assertions from a SAML identity provider
+// are validated, beyond their signature.
+type SAMLAssertionPolicy struct {
+	// AllowIDPInitiated accepts assertions that were not requested by a
+	// sign in started here. Defaults to true.
+	AllowIDPInitiated *bool `json:"allow_idp_initiated,omitempty"`
+
+	// RejectUnsolicitedInResponseTo rejects IdP initiated responses with an
+	// InResponseTo, as they refer to a request they were not delivered for.
+	RejectUnsolicitedInResponseTo bool `json:"reject_unsolicited_in_response_to,omitempty"`
+
+	// MaxClockSkew and ReplayWindow override the global configuration
+	// if set, in seconds.
+	MaxClockSkew *int `json:"max_clock_skew,omitempty"`
+	ReplayWindow *int `json:"replay_window,omitempty"`
+}
+
+// IDPInitiatedAllowed returns whether IdP initiated sign ins are accepted.
+func (p *SAMLAssertionPolicy) IDPInitiatedAllowed() bool {
+	return p.AllowIDPInitiated == nil || *p.AllowIDPInitiated
+}
+
+func (p *SAMLAssertionPolicy) Scan(src interface{}) error {
+	if src == nil {
+		*p = SAMLAssertionPolicy{}
+		return nil
+	}
+
+	b, ok := src.([]byte)
+	if !ok {
+		return errors.New("scan source was not []byte")
+	}
+	return json.Unmarshal(b, p)
+}
+
+func (p SAMLAssertionPolicy) Value() (driver.Value, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// SAMLRequestedAuthnContext is the authentication context requested from a
+// SAML identity provider.
+type SAMLRequestedAuthnContext struct {
+	// Comparison is one of exact (the default), minimum, maximum or
+	// better.
+	Comparison string `json:"comparison,omitempty"`
+	ClassRef   string `json:"class_ref"`
+}
+
+// SAMLAuthnRequestPolicy defines how users are asked to authenticate with a
+// SAML identity provider and the assurance level of their sessions.
+type SAMLAuthnRequestPolicy struct {
+	// ForceAuthn asks the identity provider to authenticate the user
+	// again, even if they have a session with it.
+	ForceAuthn bool `json:"force_authn,omitempty"`
+
+	RequestedAuthnContext *SAMLRequestedAuthnContext `json:"requested_authn_context,omitempty"`
+
+	// AAL2AuthnContextClassRefs are the authentication contexts, like
+	// multi-factor authentication, that make sessions AAL2.
+	AAL2AuthnContextClassRefs []string `json:"aal2_authn_context_class_refs,omitempty"`
+
+	// ResponseBinding is the binding the identity provider is asked to
+	// send its response with, post if empty.
+	ResponseBinding string `json:"response_binding,omitempty"`
+}
+
+// The bindings identity providers can send their responses with. With the
+// artifact binding the browser only carries a reference to the response,
+// which is resolved with the identity provider over a SOAP back-channel.
+const (
+	SAMLResponseBindingPost     = "post"
+	SAMLResponseBindingArtifact = "artifact"
+)
+
+// ProtocolBinding returns the SAML binding requested for the response of
+// the identity provider.
+func (p *SAMLAuthnRequestPolicy) ProtocolBinding() string {
+	if p.ResponseBinding == SAMLResponseBindingArtifact {
+		return saml.HTTPArtifactBinding
+	}
+
+	return saml.HTTPPostBinding
+}
+
+// AAL returns the assurance level of sessions authenticated with the
+// authentication context.
+func (p *SAMLAuthnRequestPolicy) AAL(authnContextClassRef string) AuthenticatorAssuranceLevel {
+	if authnContextClassRef != "" && slices.Contains(p.AAL2AuthnContextClassRefs, authnContextClassRef) {
+		return AAL2
+	}
+
+	return AAL1
+}
+
+func (p *SAMLAuthnRequestPolicy) Scan(src interface{}) error {
+	if src == nil {
+		*p = SAMLAuthnRequestPolicy{}
+		return nil
+	}
+
+	b, ok := src.([]byte)
+	if !ok {
+		return errors.New("scan source was not []byte")
+	}
+	return json.Unmarshal(b, p)
+}
+
+func (p SAMLAuthnRequestPolicy) Value() (driver.Value, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
 type SSODomain struct {
 	ID uuid.UUID `db:"id" json:"-"`
 
@@ -143,6 +607,11 @@ type SSODomain struct {
 
 	Domain string `db:"domain" json:"domain"`
 
+	// VerifiedAt is when the ownership of the domain was verified with its
+	// DNS TXT record. VerificationToken is the value expected in the record.
+	VerifiedAt        *time.Time `db:"verified_at" json:"verified_at,omitempty"`
+	VerificationToken *string    `db:"verification_token" json:"-"`
+
 	CreatedAt time.Time `db:"created_at" json:"-"`
 	UpdatedAt time.Time `db:"updated_at" json:"-"`
 }
@@ -151,6 +620,18 @@ func (d SSODomain) TableName() string {
 	return "sso_domains"
 }
 
+// IsVerified returns true if the ownership of the domain was verified.
+func (d *SSODomain) IsVerified() bool {
+	return d.VerifiedAt != nil
+}
+
+// VerificationRecordName returns the name of the DNS TXT record verifying
+// the ownership of the domain. Wildcard domains are verified on their parent
+// domain.
+func (d *SSODomain) VerificationRecordName(prefix string) string {
+	return prefix + "." + strings.TrimPrefix(strings.ToLower(d.Domain), "*.")
+}
+
 type SAMLRelayState struct {
 	ID uuid.UUID `db:"id"`
 
@@ -171,6 +652,60 @@ func (s SAMLRelayState) TableName() string {
 	return "saml_relay_states"
 }
 
+// SAMLSession records the Subject of a sign in with a SAML identity provider
+// that has Single Logout enabled, so that logout requests can be exchanged
+// with it.
+type SAMLSession struct {
+	ID uuid.UUID `db:"id"`
+
+	UserID        uuid.UUID `db:"user_id"`
+	SSOProviderID uuid.UUID `db:"sso_provider_id"`
+
+	NameID       string  `db:"name_id"`
+	NameIDFormat *string `db:"name_id_format"`
+	SessionIndex *string `db:"session_index"`
+
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+func (s SAMLSession) TableName() string {
+	return "saml_sessions"
+}
+
+// SAMLAssertionReplay records the ID of an accepted SAML assertion until it
+// expires, so that it can't be used again.
+type SAMLAssertionReplay struct {
+	SSOProviderID uuid.UUID `db:"sso_provider_id"`
+	AssertionID   string    `db:"assertion_id"`
+
+	ExpiresAt time.Time `db:"expires_at"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func (r SAMLAssertionReplay) TableName() string {
+	return "saml_assertion_replays"
+}
+
+// RecordSAMLAssertion records the ID of an assertion from the SSO provider
+// until expiresAt. It returns false if the ID is already recorded and has not
+// expired by now, meaning the assertion is being replayed.
+func RecordSAMLAssertion(tx *storage.Connection, ssoProviderID uuid.UUID, assertionID string, now, expiresAt time.Time) (bool, error) {
+	tableName := (&pop.Model{Value: SAMLAssertionReplay{}}).TableName()
+
+	count, err := tx.RawQuery(
+		"INSERT INTO "+tableName+" (sso_provider_id, assertion_id, expires_at, created_at) VALUES (?, ?, ?, ?) "+
+			"ON CONFLICT (sso_provider_id, assertion_id) DO UPDATE SET expires_at = excluded.expires_at, created_at = excluded.created_at "+
+			"WHERE "+tableName+".expires_at < ?",
+		ssoProviderID, assertionID, expiresAt.UTC(), now.UTC(), now.UTC(),
+	).ExecWithCount()
+	if err != nil {
+		return false, errors.Wrap(err, "error recording SAML assertion")
+	}
+
+	return count > 0, nil
+}
+
 func FindSAMLProviderByEntityID(tx *storage.Connection, entityId string) (*SSOProvider, error) {
 	var samlProvider SAMLProvider
 	if err := tx.Q().Where("entity_id = ?", entityId).First(&samlProvider); err != nil {
@@ -189,6 +724,24 @@ func FindSAMLProviderByEntityID(tx *storage.Connection, entityId string) (*SSOPr
 	return &ssoProvider, nil
 }
 
+func FindOIDCProviderByIssuer(tx *storage.Connection, issuer string) (*SSOProvider, error) {
+	var oidcProvider OIDCProvider
+	if err := tx.Q().Where("issuer = ?", issuer).First(&oidcProvider); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, SSOProviderNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding OIDC SSO provider by issuer")
+	}
+
+	var ssoProvider SSOProvider
+	if err := tx.Eager().Q().Where("id = ?", oidcProvider.SSOProviderID).First(&ssoProvider); err != nil {
+		return nil, errors.Wrap(err, "error finding OIDC SSO provider by ID (via issuer)")
+	}
+
+	return &ssoProvider, nil
+}
+
 func FindSSOProviderByID(tx *storage.Connection, id uuid.UUID) (*SSOProvider, error) {
 	var ssoProvider SSOProvider
 
@@ -203,17 +756,53 @@ func FindSSOProviderByID(tx *storage.Connection, id uuid.UUID) (*SSOProvider, er
 	return &ssoProvider, nil
 }
 
-func FindSSOProviderForEmailAddress(tx *storage.Connection, emailAddress string) (*SSOProvider, error) {
+func FindSSOProviderForEmailAddress(tx *storage.Connection, emailAddress string, verifiedOnly bool) (*SSOProvider, error) {
 	parts := strings.Split(emailAddress, "@")
-	emailDomain := strings.ToLower(parts[1])
+	emailDomain := strings.ToLower(parts[len(parts)-1])
+
+	return ResolveSSOProviderForDomain(tx, emailDomain, verifiedOnly)
+}
+
+// ResolveSSOProviderForDomain finds the SSO provider assigned to the domain,
+// falling back to the wildcard domains (*.example.com) of its parent
+// domains, the most specific one first. With verifiedOnly, domains whose
+// ownership was not verified are ignored.
+func ResolveSSOProviderForDomain(tx *storage.Connection, domain string, verifiedOnly bool) (*SSOProvider, error) {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	ssoProvider, err := findSSOProviderByDomain(tx, domain, verifiedOnly)
+	if err == nil || !IsNotFoundError(err) {
+		return ssoProvider, err
+	}
+
+	labels := strings.Split(domain, ".")
+
+	// wildcards are followed by at least two labels, so *.com is never used
+	for i := 1; i+2 <= len(labels); i += 1 {
+		ssoProvider, err := findSSOProviderByDomain(tx, "*."+strings.Join(labels[i:], "."), verifiedOnly)
+		if err == nil || !IsNotFoundError(err) {
+			return ssoProvider, err
+		}
+	}
 
-	return FindSSOProviderByDomain(tx, emailDomain)
+	return nil, SSOProviderNotFoundError{}
 }
 
+// FindSSOProviderByDomain finds the SSO provider the domain is assigned to,
+// whether its ownership was verified or not.
 func FindSSOProviderByDomain(tx *storage.Connection, domain string) (*SSOProvider, error) {
+	return findSSOProviderByDomain(tx, domain, false)
+}
+
+func findSSOProviderByDomain(tx *storage.Connection, domain string, verifiedOnly bool) (*SSOProvider, error) {
 	var ssoDomain SSODomain
 
-	if err := tx.Q().Where("domain = ?", domain).First(&ssoDomain); err != nil {
+	query := tx.Q().Where("lower(domain) = ?", strings.ToLower(domain))
+	if verifiedOnly {
+		query = query.Where("verified_at is not null")
+	}
+
+	if err := query.First(&ssoDomain); err != nil {
 		if errors.Cause(err) == sql.ErrNoRows {
 			return nil, SSOProviderNotFoundError{}
 		}
@@ -260,3 +849,46 @@ func FindSAMLRelayStateByID(tx *storage.Connection, id uuid.UUID) (*SAMLRelaySta
 
 	return &state, nil
 }
+
+// FindLatestSAMLSessionForUser returns the SAML session of the most recent
+// sign in of the user.
+func FindLatestSAMLSessionForUser(tx *storage.Connection, userID uuid.UUID) (*SAMLSession, error) {
+	var session SAMLSession
+
+	if err := tx.Q().Where("user_id = ?", userID).Order("created_at desc").First(&session); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, SAMLSessionNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error loading SAML session")
+	}
+
+	return &session, nil
+}
+
+// FindSAMLSessionsByNameID returns the SAML sessions with the provider for
+// the NameID. If session indexes are provided, only sessions with one of
+// them are returned.
+func FindSAMLSessionsByNameID(tx *storage.Connection, ssoProviderID uuid.UUID, nameID string, sessionIndexes []string) ([]SAMLSession, error) {
+	sessions := []SAMLSession{}
+
+	q := tx.Q().Where("sso_provider_id = ? and name_id = ?", ssoProviderID, nameID)
+	if len(sessionIndexes) > 0 {
+		q = q.Where("session_index in (?)", sessionIndexes)
+	}
+
+	if err := q.All(&sessions); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return sessions, nil
+		}
+
+		return nil, errors.Wrap(err, "error loading SAML sessions")
+	}
+
+	return sessions, nil
+}
+
+// DestroySAMLSessionsForUser deletes all SAML sessions of the user.
+func DestroySAMLSessionsForUser(tx *storage.Connection, userID uuid.UUID) error {
+	return tx.RawQuery("DELETE FROM "+(&pop.Model{Value: SAMLSession{}}).TableName()+" WHERE user_id = ?", userID).Exec()
+}
@@ -2,7 +2,9 @@ package models
 
 import (
 	tst "testing"
+	"time"
 
+	"github.com/crewjam/saml"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"github.com/supabase/auth/internal/conf"
@@ -168,7 +170,7 @@ func (ts *SSOTestSuite) TestFindSSOProviderForEmailAddress() {
 	}
 
 	for i, example := range examples {
-		rp, err := FindSSOProviderForEmailAddress(ts.db, example.Address)
+		rp, err := FindSSOProviderForEmailAddress(ts.db, example.Address, false)
 
 		if nil == example.Provider {
 			require.Nil(ts.T(), rp)
@@ -180,6 +182,39 @@ func (ts *SSOTestSuite) TestFindSSOProviderForEmailAddress() {
 	}
 }
 
+func (ts *SSOTestSuite) TestResolveSSOProviderForVerifiedDomain() {
+	verifiedAt := time.Now()
+
+	provider := &SSOProvider{
+		SAMLProvider: SAMLProvider{
+			EntityID:    "https://example.com/saml/metadata",
+			MetadataXML: "<example />",
+		},
+		SSODomains: []SSODomain{
+			{
+				Domain:     "example.com",
+				VerifiedAt: &verifiedAt,
+			},
+			{
+				Domain: "example.org",
+			},
+		},
+	}
+
+	require.NoError(ts.T(), ts.db.Eager().Create(provider), "provider creation failed")
+
+	rp, err := ResolveSSOProviderForDomain(ts.db, "example.com", true)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), provider.ID, rp.ID)
+
+	_, err = ResolveSSOProviderForDomain(ts.db, "example.org", true)
+	require.True(ts.T(), IsNotFoundError(err))
+
+	rp, err = ResolveSSOProviderForDomain(ts.db, "example.org", false)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), provider.ID, rp.ID)
+}
+
 func (ts *SSOTestSuite) TestFindSAMLProviderByEntityID() {
 	provider := &SSOProvider{
 		SAMLProvider: SAMLProvider{
@@ -230,3 +265,127 @@ func (ts *SSOTestSuite) TestFindSAMLProviderByEntityID() {
 		}
 	}
 }
+
+func TestSSOProvisioningAppMetadata(t *tst.T) {
+	provisioning := SSOProvisioning{
+		AppMetadata: map[string]interface{}{
+			"tenant": "acme",
+			"team":   "{{ department }}-{{location}}",
+			"nested": map[string]interface{}{
+				"labels": []interface{}{"sso", "{{ department }}", "{{ missing }}"},
+			},
+			"level": 3.0,
+		},
+		AppMetadataAttributes: map[string]string{
+			"groups":  "groups",
+			"unknown": "missing",
+		},
+	}
+
+	require.False(t, provisioning.IsEmpty())
+	require.True(t, (&SSOProvisioning{UpdateExistingUsers: true}).IsEmpty())
+
+	appMetadata := provisioning.AppMetadataFor(map[string]interface{}{
+		"department": "engineering",
+		"location":   42,
+		"groups":     []interface{}{"admins", "users"},
+	})
+
+	require.Equal(t, map[string]interface{}{
+		"tenant": "acme",
+		"team":   "engineering-42",
+		"nested": map[string]interface{}{
+			"labels": []interface{}{"sso", "engineering", ""},
+		},
+		"level":  3.0,
+		"groups": []interface{}{"admins", "users"},
+	}, appMetadata)
+}
+
+func TestSSOProvisioningGroupMappings(t *tst.T) {
+	provisioning := SSOProvisioning{
+		GroupMappings: []SSOGroupMapping{
+			{
+				Group: "Admins",
+				Role:  "admin",
+				AppMetadata: map[string]interface{}{
+					"permissions": []interface{}{"read", "write"},
+				},
+			},
+			{
+				Group: "Support",
+				Role:  "support",
+				AppMetadata: map[string]interface{}{
+					"permissions": []interface{}{"read", "tickets"},
+				},
+			},
+			{
+				Group: "Billing",
+				AppMetadata: map[string]interface{}{
+					"billing": true,
+				},
+			},
+		},
+	}
+
+	require.True(t, provisioning.IsEmpty())
+	require.True(t, provisioning.MapsGroupRoles())
+	require.False(t, (&SSOProvisioning{GroupMappings: provisioning.GroupMappings[2:]}).MapsGroupRoles())
+
+	require.Equal(t, []string{"support", "admins"}, provisioning.GroupsFor(map[string]interface{}{
+		"groups": []interface{}{"support", "admins", 3},
+	}))
+	require.Equal(t, []string{"admins"}, (&SSOProvisioning{GroupsAttribute: "memberOf"}).GroupsFor(map[string]interface{}{
+		"memberOf": "admins",
+	}))
+	require.Nil(t, provisioning.GroupsFor(map[string]interface{}{}))
+
+	require.Equal(t, "admin", provisioning.GroupRoleFor([]string{"support", "admins"}))
+	require.Equal(t, "support", provisioning.GroupRoleFor([]string{"support", "billing"}))
+	require.Equal(t, "", provisioning.GroupRoleFor([]string{"billing"}))
+
+	require.Equal(t, map[string]interface{}{
+		"permissions": []interface{}{"read", "write", "tickets"},
+		"billing":     nil,
+	}, provisioning.GroupAppMetadataFor([]string{"support", "admins"}))
+
+	require.Equal(t, map[string]interface{}{
+		"permissions": nil,
+		"billing":     true,
+	}, provisioning.GroupAppMetadataFor([]string{"billing"}))
+
+	// the mappings are left unchanged
+	require.Equal(t, []interface{}{"read", "write"}, provisioning.GroupMappings[0].AppMetadata["permissions"])
+}
+
+func TestSSOProvisioningScan(t *tst.T) {
+	var provisioning SSOProvisioning
+	require.NoError(t, provisioning.Scan(nil))
+	require.True(t, provisioning.IsEmpty())
+
+	require.NoError(t, provisioning.Scan([]byte(`{"default_role":"member","update_existing_users":true}`)))
+	require.Equal(t, "member", provisioning.DefaultRole)
+	require.True(t, provisioning.UpdateExistingUsers)
+
+	value, err := provisioning.Value()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"default_role":"member","update_existing_users":true}`, value.(string))
+}
+
+func TestSAMLAuthnRequestPolicyAAL(t *tst.T) {
+	policy := SAMLAuthnRequestPolicy{
+		AAL2AuthnContextClassRefs: []string{"urn:oasis:names:tc:SAML:2.0:ac:classes:MultiFactor"},
+	}
+
+	require.Equal(t, AAL2, policy.AAL("urn:oasis:names:tc:SAML:2.0:ac:classes:MultiFactor"))
+	require.Equal(t, AAL1, policy.AAL("urn:oasis:names:tc:SAML:2.0:ac:classes:PasswordProtectedTransport"))
+	require.Equal(t, AAL1, (&SAMLAuthnRequestPolicy{}).AAL("urn:oasis:names:tc:SAML:2.0:ac:classes:MultiFactor"))
+
+	require.Equal(t, saml.HTTPPostBinding, policy.ProtocolBinding())
+	require.Equal(t, saml.HTTPArtifactBinding, (&SAMLAuthnRequestPolicy{ResponseBinding: SAMLResponseBindingArtifact}).ProtocolBinding())
+}
+
+func TestSSODomainVerificationRecordName(t *tst.T) {
+	require.Equal(t, "_supabase-auth.example.com", (&SSODomain{Domain: "Example.com"}).VerificationRecordName("_supabase-auth"))
+	require.Equal(t, "_supabase-auth.example.com", (&SSODomain{Domain: "*.example.com"}).VerificationRecordName("_supabase-auth"))
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -33,6 +34,8 @@ type User struct {
 	Phone            storage.NullString `json:"phone" db:"phone"`
 	PhoneConfirmedAt *time.Time         `json:"phone_confirmed_at,omitempty" db:"phone_confirmed_at"`
 
+	Username storage.NullString `json:"username,omitempty" db:"username"`
+
 	ConfirmationToken  string     `json:"-" db:"confirmation_token"`
 	ConfirmationSentAt *time.Time `json:"confirmation_sent_at,omitempty" db:"confirmation_sent_at"`
 
@@ -48,9 +51,11 @@ type User struct {
 	EmailChangeSentAt        *time.Time `json:"email_change_sent_at,omitempty" db:"email_change_sent_at"`
 	EmailChangeConfirmStatus int        `json:"-" db:"email_change_confirm_status"`
 
-	PhoneChangeToken  string     `json:"-" db:"phone_change_token"`
-	PhoneChange       string     `json:"new_phone,omitempty" db:"phone_change"`
-	PhoneChangeSentAt *time.Time `json:"phone_change_sent_at,omitempty" db:"phone_change_sent_at"`
+	PhoneChangeTokenCurrent  string     `json:"-" db:"phone_change_token_current"`
+	PhoneChangeToken         string     `json:"-" db:"phone_change_token"`
+	PhoneChange              string     `json:"new_phone,omitempty" db:"phone_change"`
+	PhoneChangeSentAt        *time.Time `json:"phone_change_sent_at,omitempty" db:"phone_change_sent_at"`
+	PhoneChangeConfirmStatus int        `json:"-" db:"phone_change_confirm_status"`
 
 	ReauthenticationToken  string     `json:"-" db:"reauthentication_token"`
 	ReauthenticationSentAt *time.Time `json:"reauthentication_sent_at,omitempty" db:"reauthentication_sent_at"`
@@ -69,6 +74,17 @@ type User struct {
 	DeletedAt   *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 	IsAnonymous bool       `json:"is_anonymous" db:"is_anonymous"`
 
+	// PasswordExpiredAt is when the password was marked as expired. It has
+	// to be reset before signing in with it again.
+	PasswordExpiredAt *time.Time `json:"password_expired_at,omitempty" db:"password_expired_at"`
+
+	// TermsVersion and PrivacyPolicyVersion are the versions of the terms
+	// of service and privacy policy the user accepted, and when.
+	TermsVersion            storage.NullString `json:"terms_version,omitempty" db:"terms_version"`
+	TermsAcceptedAt         *time.Time         `json:"terms_accepted_at,omitempty" db:"terms_accepted_at"`
+	PrivacyPolicyVersion    storage.NullString `json:"privacy_policy_version,omitempty" db:"privacy_policy_version"`
+	PrivacyPolicyAcceptedAt *time.Time         `json:"privacy_policy_accepted_at,omitempty" db:"privacy_policy_accepted_at"`
+
 	DONTUSEINSTANCEID uuid.UUID `json:"-" db:"instance_id"`
 }
 
@@ -78,6 +94,11 @@ func NewUserWithPasswordHash(phone, email, passwordHash, aud string, userData ma
 		if err != nil {
 			return nil, err
 		}
+	} else if strings.HasPrefix(passwordHash, crypto.ScryptPrefix) {
+		_, err := crypto.ParseScryptHash(passwordHash)
+		if err != nil {
+			return nil, err
+		}
 	} else {
 		// verify that the hash is a bcrypt hash
 		_, err := bcrypt.Cost([]byte(passwordHash))
@@ -215,6 +236,11 @@ func (u *User) GetPhone() string {
 	return string(u.Phone)
 }
 
+// GetUsername returns the user's username as a string
+func (u *User) GetUsername() string {
+	return string(u.Username)
+}
+
 // UpdateUserMetaData sets all user data from a map of updates,
 // ensuring that it doesn't override attributes that are not
 // in the provided map.
@@ -319,6 +345,12 @@ func (u *User) SetPhone(tx *storage.Connection, phone string) error {
 	return tx.UpdateOnly(u, "phone")
 }
 
+// SetUsername sets the user's username, which must be normalized
+func (u *User) SetUsername(tx *storage.Connection, username string) error {
+	u.Username = storage.NullString(username)
+	return tx.UpdateOnly(u, "username")
+}
+
 func (u *User) SetPassword(ctx context.Context, password string, encrypt bool, encryptionKeyID, encryptionKey string) error {
 	if password == "" {
 		u.EncryptedPassword = nil
@@ -354,12 +386,14 @@ func (u *User) UpdatePassword(tx *storage.Connection, sessionID *uuid.UUID) erro
 	u.EmailChangeTokenCurrent = ""
 	u.EmailChangeTokenNew = ""
 	u.EmailChangeSentAt = nil
+	u.PhoneChangeTokenCurrent = ""
 	u.PhoneChangeToken = ""
 	u.PhoneChangeSentAt = nil
 	u.ReauthenticationToken = ""
 	u.ReauthenticationSentAt = nil
+	u.PasswordExpiredAt = nil
 
-	if err := tx.UpdateOnly(u, "encrypted_password", "confirmation_token", "confirmation_sent_at", "recovery_token", "recovery_sent_at", "email_change_token_current", "email_change_token_new", "email_change_sent_at", "phone_change_token", "phone_change_sent_at", "reauthentication_token", "reauthentication_sent_at"); err != nil {
+	if err := tx.UpdateOnly(u, "encrypted_password", "confirmation_token", "confirmation_sent_at", "recovery_token", "recovery_sent_at", "email_change_token_current", "email_change_token_new", "email_change_sent_at", "phone_change_token_current", "phone_change_token", "phone_change_sent_at", "reauthentication_token", "reauthentication_sent_at", "password_expired_at"); err != nil {
 		return err
 	}
 
@@ -400,7 +434,7 @@ func (u *User) Authenticate(ctx context.Context, tx *storage.Connection, passwor
 
 	compareErr := crypto.CompareHashAndPassword(ctx, hash, password)
 
-	if !strings.HasPrefix(hash, crypto.Argon2Prefix) {
+	if !strings.HasPrefix(hash, crypto.Argon2Prefix) && !strings.HasPrefix(hash, crypto.ScryptPrefix) {
 		// check if cost exceeds default cost or is too low
 		cost, err := bcrypt.Cost([]byte(hash))
 		if err != nil {
@@ -524,14 +558,18 @@ func (u *User) ConfirmPhoneChange(tx *storage.Connection) error {
 
 	u.Phone = storage.NullString(phone)
 	u.PhoneChange = ""
+	u.PhoneChangeTokenCurrent = ""
 	u.PhoneChangeToken = ""
+	u.PhoneChangeConfirmStatus = 0
 	u.PhoneConfirmedAt = &now
 
 	if err := tx.UpdateOnly(
 		u,
 		"phone",
 		"phone_change",
+		"phone_change_token_current",
 		"phone_change_token",
+		"phone_change_confirm_status",
 		"phone_confirmed_at",
 	); err != nil {
 		return err
@@ -600,6 +638,12 @@ func FindUserByPhoneAndAudience(tx *storage.Connection, phone, aud string) (*Use
 	return findUser(tx, "instance_id = ? and phone = ? and aud = ? and is_sso_user = false", uuid.Nil, phone, aud)
 }
 
+// FindUserByUsernameAndAudience finds a user with the matching normalized
+// username and audience.
+func FindUserByUsernameAndAudience(tx *storage.Connection, username, aud string) (*User, error) {
+	return findUser(tx, "instance_id = ? and username = ? and aud = ? and is_sso_user = false", uuid.Nil, username, aud)
+}
+
 // FindUserByID finds a user matching the provided ID.
 func FindUserByID(tx *storage.Connection, id uuid.UUID) (*User, error) {
 	return findUser(tx, "instance_id = ? and id = ?", uuid.Nil, id)
@@ -664,24 +708,146 @@ func FindUserWithRefreshToken(tx *storage.Connection, token string, forUpdate bo
 	return user, refreshToken, session, nil
 }
 
-// FindUsersInAudience finds users with the matching audience.
-func FindUsersInAudience(tx *storage.Connection, aud string, pageParams *Pagination, sortParams *SortParams, filter string) ([]*User, error) {
-	users := []*User{}
-	q := tx.Q().Where("instance_id = ? and aud = ?", uuid.Nil, aud)
+// UserFilter filters the users of an audience. The search matches their
+// email or full name, and the metadata matches the values of their keys.
+// Users have all the tags, and the tags with values have the values.
+// Unset fields don't filter the users.
+type UserFilter struct {
+	Search           string
+	CreatedAfter     *time.Time
+	CreatedBefore    *time.Time
+	LastSignInAfter  *time.Time
+	LastSignInBefore *time.Time
+	Provider         string
+	EmailConfirmed   *bool
+	PhoneConfirmed   *bool
+	Banned           *bool
+	IsAnonymous      *bool
+	IsSSOUser        *bool
+	UserMetaData     map[string]string
+	AppMetaData      map[string]string
+	Tags             []string
+	TagValues        map[string]string
+}
 
-	if filter != "" {
-		lf := "%" + filter + "%"
+// apply adds the conditions of the filter to the query. The provider and
+// metadata are matched with containment, which the GIN indexes of the
+// metadata support.
+func (f *UserFilter) apply(q *pop.Query) (*pop.Query, error) {
+	if f.Search != "" {
+		lf := "%" + f.Search + "%"
 		// we must specify the collation in order to get case insensitive search for the JSON column
 		q = q.Where("(email LIKE ? OR raw_user_meta_data->>'full_name' ILIKE ?)", lf, lf)
 	}
 
+	if f.CreatedAfter != nil {
+		q = q.Where("created_at >= ?", *f.CreatedAfter)
+	}
+	if f.CreatedBefore != nil {
+		q = q.Where("created_at < ?", *f.CreatedBefore)
+	}
+	if f.LastSignInAfter != nil {
+		q = q.Where("last_sign_in_at >= ?", *f.LastSignInAfter)
+	}
+	if f.LastSignInBefore != nil {
+		q = q.Where("last_sign_in_at < ?", *f.LastSignInBefore)
+	}
+
+	if f.EmailConfirmed != nil {
+		if *f.EmailConfirmed {
+			q = q.Where("email_confirmed_at is not null")
+		} else {
+			q = q.Where("email_confirmed_at is null")
+		}
+	}
+	if f.PhoneConfirmed != nil {
+		if *f.PhoneConfirmed {
+			q = q.Where("phone_confirmed_at is not null")
+		} else {
+			q = q.Where("phone_confirmed_at is null")
+		}
+	}
+	if f.Banned != nil {
+		if *f.Banned {
+			q = q.Where("banned_until > now()")
+		} else {
+			q = q.Where("(banned_until is null or banned_until <= now())")
+		}
+	}
+	if f.IsAnonymous != nil {
+		q = q.Where("is_anonymous = ?", *f.IsAnonymous)
+	}
+	if f.IsSSOUser != nil {
+		q = q.Where("is_sso_user = ?", *f.IsSSOUser)
+	}
+
+	if f.Provider != "" {
+		providers, err := json.Marshal(map[string]interface{}{"providers": []string{f.Provider}})
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where("raw_app_meta_data @> ?::jsonb", string(providers))
+	}
+	if len(f.UserMetaData) > 0 {
+		metadata, err := json.Marshal(f.UserMetaData)
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where("raw_user_meta_data @> ?::jsonb", string(metadata))
+	}
+	if len(f.AppMetaData) > 0 {
+		metadata, err := json.Marshal(f.AppMetaData)
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where("raw_app_meta_data @> ?::jsonb", string(metadata))
+	}
+
+	if len(f.Tags) > 0 || len(f.TagValues) > 0 {
+		hasTag := fmt.Sprintf("exists (select 1 from %q t where t.user_id = %q.id and t.name = ?", (&pop.Model{Value: UserTag{}}).TableName(), (&pop.Model{Value: User{}}).TableName())
+		for _, name := range f.Tags {
+			q = q.Where(hasTag+")", name)
+		}
+		for name, value := range f.TagValues {
+			q = q.Where(hasTag+" and t.value = ?)", name, value)
+		}
+	}
+
+	return q, nil
+}
+
+// UserSortFields are the fields users can be sorted by.
+var UserSortFields = map[string]bool{
+	CreatedAt:         true,
+	"last_sign_in_at": true,
+}
+
+// userOrder returns the order clause of the sort field, with the users
+// without a value first in ascending order and last in descending order,
+// and the ID breaking ties so the order is stable.
+func userOrder(field SortField) string {
+	nulls := "nulls first"
+	if field.Dir == Descending {
+		nulls = "nulls last"
+	}
+
+	return fmt.Sprintf("%s %s %s, id %s", field.Name, field.Dir, nulls, field.Dir)
+}
+
+// FindUsersInAudience finds users with the matching audience.
+func FindUsersInAudience(tx *storage.Connection, aud string, pageParams *Pagination, sortParams *SortParams, filter UserFilter) ([]*User, error) {
+	users := []*User{}
+	q, err := filter.apply(tx.Q().Where("instance_id = ? and aud = ?", uuid.Nil, aud))
+	if err != nil {
+		return nil, err
+	}
+
 	if sortParams != nil && len(sortParams.Fields) > 0 {
 		for _, field := range sortParams.Fields {
-			q = q.Order(field.Name + " " + string(field.Dir))
+			q = q.Order(userOrder(field))
 		}
 	}
 
-	var err error
 	if pageParams != nil {
 		err = q.Paginate(int(pageParams.Page), int(pageParams.PerPage)).All(&users) // #nosec G115
 		pageParams.Count = uint64(q.Paginator.TotalEntriesSize)                     // #nosec G115
@@ -692,6 +858,109 @@ func FindUsersInAudience(tx *storage.Connection, aud string, pageParams *Paginat
 	return users, err
 }
 
+// UserCursor is the position after a user in the users sorted by a field,
+// with the value of the field for the user.
+type UserCursor struct {
+	Value *time.Time
+	ID    uuid.UUID
+}
+
+// NewUserCursor returns the position after the user in the users sorted by
+// the field.
+func NewUserCursor(user *User, field string) *UserCursor {
+	cursor := &UserCursor{ID: user.ID}
+
+	switch field {
+	case CreatedAt:
+		createdAt := user.CreatedAt
+		cursor.Value = &createdAt
+	case "last_sign_in_at":
+		cursor.Value = user.LastSignInAt
+	}
+
+	return cursor
+}
+
+// FindUsersInAudienceByCursor returns up to limit users of the audience
+// after the cursor, or the first ones without it, sorted by the field.
+// Unlike pages, cursors don't skip or repeat users created while going
+// through them, and later users are as quick to find as the first.
+func FindUsersInAudienceByCursor(tx *storage.Connection, aud string, field SortField, cursor *UserCursor, limit int, filter UserFilter) ([]*User, error) {
+	if !UserSortFields[field.Name] {
+		return nil, fmt.Errorf("users can't be sorted by %q", field.Name)
+	}
+
+	users := []*User{}
+	q, err := filter.apply(tx.Q().Where("instance_id = ? and aud = ?", uuid.Nil, aud))
+	if err != nil {
+		return nil, err
+	}
+
+	if cursor != nil {
+		// users without a value are first in ascending order, and last in
+		// descending order
+		column := field.Name
+		switch {
+		case field.Dir == Ascending && cursor.Value == nil:
+			q = q.Where(fmt.Sprintf("((%s is null and id > ?) or %s is not null)", column, column), cursor.ID)
+		case field.Dir == Ascending:
+			q = q.Where(fmt.Sprintf("(%s > ? or (%s = ? and id > ?))", column, column), *cursor.Value, *cursor.Value, cursor.ID)
+		case cursor.Value == nil:
+			q = q.Where(fmt.Sprintf("(%s is null and id < ?)", column), cursor.ID)
+		default:
+			q = q.Where(fmt.Sprintf("(%s < ? or (%s = ? and id < ?) or %s is null)", column, column, column), *cursor.Value, *cursor.Value, cursor.ID)
+		}
+	}
+
+	if err := q.Order(userOrder(field)).Limit(limit).All(&users); err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "error finding users")
+	}
+
+	return users, nil
+}
+
+// FindUsersInAudienceAfter returns up to limit users of the audience with
+// an ID after the cursor, ordered by ID, to go through all the users with
+// the ID of the last user as the next cursor.
+func FindUsersInAudienceAfter(tx *storage.Connection, aud string, after uuid.UUID, limit int, filter UserFilter) ([]*User, error) {
+	users := []*User{}
+	q, err := filter.apply(tx.Q().Where("instance_id = ? and aud = ? and id > ?", uuid.Nil, aud, after))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := q.Order("id asc").Limit(limit).All(&users); err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "error finding users")
+	}
+
+	return users, nil
+}
+
+// IsDuplicatedGmail returns a user other than the current user with a Gmail
+// address of the local part, once the dots and label of its local part are
+// removed, e.g. j.doe+news@gmail.com for jdoe.
+func IsDuplicatedGmail(tx *storage.Connection, localPart, aud string, currentUser *User) (*User, error) {
+	var currentUserID uuid.UUID
+	if currentUser != nil {
+		currentUserID = currentUser.ID
+	}
+
+	user, err := findUser(
+		tx,
+		"instance_id = ? and aud = ? and is_sso_user = false and id <> ? and split_part(lower(email), '@', 2) in ('gmail.com', 'googlemail.com') and replace(split_part(split_part(lower(email), '@', 1), '+', 1), '.', '') = ?",
+		uuid.Nil, aud, currentUserID, localPart,
+	)
+	if err != nil {
+		if IsNotFoundError(err) {
+			return nil, nil
+		}
+
+		return nil, errors.Wrap(err, "unable to find user by Gmail address for duplicates")
+	}
+
+	return user, nil
+}
+
 // IsDuplicatedEmail returns whether a user exists with a matching email and audience.
 // If a currentUser is provided, we will need to filter out any identities that belong to the current user.
 func IsDuplicatedEmail(tx *storage.Connection, email, aud string, currentUser *User) (*User, error) {
@@ -737,10 +1006,47 @@ func IsDuplicatedEmail(tx *storage.Connection, email, aud string, currentUser *U
 	if err != nil && !IsNotFoundError(err) {
 		return nil, errors.Wrap(err, "unable to find user email address for duplicates")
 	}
+	if user != nil {
+		return user, nil
+	}
+
+	// verified secondary email addresses are taken like primary ones
+	userEmail, err := FindVerifiedUserEmail(tx, email)
+	if err != nil {
+		if IsNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "unable to find user secondary email for duplicates")
+	}
+	if userEmail.UserID == currentUserId {
+		return nil, nil
+	}
+
+	user, err = FindUserByID(tx, userEmail.UserID)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find user from secondary email for duplicates")
+	}
+	if user.Aud != aud {
+		return nil, nil
+	}
 
 	return user, nil
 }
 
+// IsDuplicatedUsername checks if the normalized username is taken by a
+// user other than the one given, in any audience as usernames are unique.
+func IsDuplicatedUsername(tx *storage.Connection, username string, user *User) (bool, error) {
+	var found User
+	if err := tx.Q().Where("username = ?", username).First(&found); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, errors.Wrap(err, "error finding user by username")
+	}
+
+	return user == nil || found.ID != user.ID, nil
+}
+
 // IsDuplicatedPhone checks if the phone number already exists in the users table
 func IsDuplicatedPhone(tx *storage.Connection, phone, aud string) (bool, error) {
 	_, err := FindUserByPhoneAndAudience(tx, phone, aud)
@@ -776,6 +1082,60 @@ func (u *User) UpdateBannedUntil(tx *storage.Connection) error {
 	return tx.UpdateOnly(u, "banned_until")
 }
 
+// SetPasswordExpired marks the password of the user as expired, or no
+// longer expired. Setting a new password also clears it.
+func (u *User) SetPasswordExpired(tx *storage.Connection, expired bool) error {
+	if !expired {
+		u.PasswordExpiredAt = nil
+	} else if u.PasswordExpiredAt == nil {
+		now := time.Now()
+		u.PasswordExpiredAt = &now
+	}
+
+	return tx.UpdateOnly(u, "password_expired_at")
+}
+
+// IsPasswordExpired checks if the password of the user has to be reset
+// before signing in with it.
+func (u *User) IsPasswordExpired() bool {
+	return u.PasswordExpiredAt != nil
+}
+
+// AcceptConsent records that the user accepted the versions of the terms
+// of service and privacy policy. Empty versions aren't accepted.
+func (u *User) AcceptConsent(tx *storage.Connection, termsVersion, privacyPolicyVersion string) error {
+	now := time.Now()
+	if termsVersion != "" {
+		u.TermsVersion = storage.NullString(termsVersion)
+		u.TermsAcceptedAt = &now
+	}
+	if privacyPolicyVersion != "" {
+		u.PrivacyPolicyVersion = storage.NullString(privacyPolicyVersion)
+		u.PrivacyPolicyAcceptedAt = &now
+	}
+
+	return tx.UpdateOnly(u, "terms_version", "terms_accepted_at", "privacy_policy_version", "privacy_policy_accepted_at")
+}
+
+// HasAcceptedConsent checks if the user accepted the current versions of
+// the terms of service and privacy policy. Documents without a current
+// version don't have to be accepted.
+func (u *User) HasAcceptedConsent(termsVersion, privacyPolicyVersion string) bool {
+	return (termsVersion == "" || u.TermsVersion.String() == termsVersion) &&
+		(privacyPolicyVersion == "" || u.PrivacyPolicyVersion.String() == privacyPolicyVersion)
+}
+
+// ExpireAllPasswords marks the passwords of all the users having one as
+// expired, like after a breach, and returns how many were expired.
+func ExpireAllPasswords(tx *storage.Connection) (int, error) {
+	count, err := tx.RawQuery(fmt.Sprintf("update %q set password_expired_at = now() where encrypted_password is not null and encrypted_password != '' and password_expired_at is null and deleted_at is null", (&pop.Model{Value: User{}}).TableName())).ExecWithCount()
+	if err != nil {
+		return 0, errors.Wrap(err, "error expiring passwords")
+	}
+
+	return count, nil
+}
+
 // RemoveUnconfirmedIdentities removes potentially malicious unconfirmed identities from a user (if any)
 func (u *User) RemoveUnconfirmedIdentities(tx *storage.Connection, identity *Identity) error {
 	if identity.Provider != "email" && identity.Provider != "phone" {
@@ -816,11 +1176,15 @@ func (u *User) SoftDeleteUser(tx *storage.Connection) error {
 	u.Phone = storage.NullString(obfuscatePhone(u, u.GetPhone()))
 	u.EmailChange = obfuscateEmail(u, u.EmailChange)
 	u.PhoneChange = obfuscatePhone(u, u.PhoneChange)
+	if u.GetUsername() != "" {
+		u.Username = storage.NullString(obfuscateValue(u.ID, u.GetUsername()))
+	}
 	u.EncryptedPassword = nil
 	u.ConfirmationToken = ""
 	u.RecoveryToken = ""
 	u.EmailChangeTokenCurrent = ""
 	u.EmailChangeTokenNew = ""
+	u.PhoneChangeTokenCurrent = ""
 	u.PhoneChangeToken = ""
 
 	// set deleted_at time
@@ -831,6 +1195,7 @@ func (u *User) SoftDeleteUser(tx *storage.Connection) error {
 		u,
 		"email",
 		"phone",
+		"username",
 		"encrypted_password",
 		"email_change",
 		"phone_change",
@@ -838,6 +1203,7 @@ func (u *User) SoftDeleteUser(tx *storage.Connection) error {
 		"recovery_token",
 		"email_change_token_current",
 		"email_change_token_new",
+		"phone_change_token_current",
 		"phone_change_token",
 		"deleted_at",
 	); err != nil {
@@ -848,6 +1214,26 @@ func (u *User) SoftDeleteUser(tx *storage.Connection) error {
 		return err
 	}
 
+	if err := ClearUserEmailsForUser(tx, u.ID); err != nil {
+		return err
+	}
+
+	if err := ClearPasswordHistoryForUser(tx, u.ID); err != nil {
+		return err
+	}
+
+	if err := ClearSignInEventsForUser(tx, u.ID); err != nil {
+		return err
+	}
+
+	if err := ClearAccountLockoutForUser(tx, u.ID); err != nil {
+		return err
+	}
+
+	if err := ClearBulkInvitesForUser(tx, u.ID); err != nil {
+		return err
+	}
+
 	// set raw_user_meta_data to {}
 	userMetaDataUpdates := map[string]interface{}{}
 	for k := range u.UserMetaData {
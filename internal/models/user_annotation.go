@@ -0,0 +1,139 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// UserNote is a note an admin keeps on a user, like the context of a
+// support request or of a trust and safety review. Notes are only seen by
+// admins.
+type UserNote struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	AuthorID  *uuid.UUID `json:"author_id,omitempty" db:"author_id"`
+	Body      string     `json:"body" db:"body"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+func (UserNote) TableName() string {
+	tableName := "user_notes"
+	return tableName
+}
+
+// NewUserNote creates a note on the user, by the admin user when there's
+// one.
+func NewUserNote(user *User, body string, author *User) *UserNote {
+	note := &UserNote{
+		ID:     uuid.Must(uuid.NewV4()),
+		UserID: user.ID,
+		Body:   body,
+	}
+
+	if author != nil {
+		note.AuthorID = &author.ID
+	}
+
+	return note
+}
+
+// FindUserNotesByUserID returns the notes on the user, latest first.
+func FindUserNotesByUserID(tx *storage.Connection, userID uuid.UUID) ([]*UserNote, error) {
+	notes := []*UserNote{}
+
+	if err := tx.Q().Where("user_id = ?", userID).Order("created_at desc").All(&notes); err != nil {
+		return nil, errors.Wrap(err, "error finding user notes")
+	}
+
+	return notes, nil
+}
+
+// FindUserNoteByID finds the note on the user with the ID.
+func FindUserNoteByID(tx *storage.Connection, userID, noteID uuid.UUID) (*UserNote, error) {
+	var note UserNote
+
+	if err := tx.Q().Where("id = ? and user_id = ?", noteID, userID).First(&note); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, UserNoteNotFoundError{}
+		}
+		return nil, errors.Wrap(err, "error finding user note")
+	}
+
+	return &note, nil
+}
+
+// UserTag is a labeled tag an admin puts on a user, like `vip` or
+// `review=fraud`, to find the user by. Users have a tag at most once with
+// each name.
+type UserTag struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	Name      string     `json:"name" db:"name"`
+	Value     string     `json:"value" db:"value"`
+	SetBy     *uuid.UUID `json:"set_by,omitempty" db:"set_by"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+func (UserTag) TableName() string {
+	tableName := "user_tags"
+	return tableName
+}
+
+// SetUserTag puts the tag with the name on the user, or changes its value
+// when the user already has it, by the admin user when there's one.
+func SetUserTag(tx *storage.Connection, userID uuid.UUID, name, value string, setBy *User) (*UserTag, error) {
+	var tag UserTag
+	var setByID *uuid.UUID
+	if setBy != nil {
+		setByID = &setBy.ID
+	}
+
+	now := time.Now()
+	if err := tx.RawQuery(
+		fmt.Sprintf(`insert into %q (id, user_id, name, value, set_by, created_at, updated_at)
+		values (?, ?, ?, ?, ?, ?, ?)
+		on conflict (user_id, name) do update set
+			value = excluded.value,
+			set_by = excluded.set_by,
+			updated_at = excluded.updated_at
+		returning *`, (&pop.Model{Value: UserTag{}}).TableName()),
+		uuid.Must(uuid.NewV4()), userID, name, value, setByID, now, now,
+	).First(&tag); err != nil {
+		return nil, errors.Wrap(err, "error setting user tag")
+	}
+
+	return &tag, nil
+}
+
+// FindUserTagsByUserID returns the tags of the user, by name.
+func FindUserTagsByUserID(tx *storage.Connection, userID uuid.UUID) ([]*UserTag, error) {
+	tags := []*UserTag{}
+
+	if err := tx.Q().Where("user_id = ?", userID).Order("name asc").All(&tags); err != nil {
+		return nil, errors.Wrap(err, "error finding user tags")
+	}
+
+	return tags, nil
+}
+
+// RemoveUserTag removes the tag with the name from the user, and returns
+// false if the user didn't have it.
+func RemoveUserTag(tx *storage.Connection, userID uuid.UUID, name string) (bool, error) {
+	count, err := tx.RawQuery(
+		fmt.Sprintf("delete from %q where user_id = ? and name = ?", (&pop.Model{Value: UserTag{}}).TableName()),
+		userID, name,
+	).ExecWithCount()
+	if err != nil {
+		return false, errors.Wrap(err, "error removing user tag")
+	}
+
+	return count > 0, nil
+}
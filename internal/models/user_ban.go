@@ -0,0 +1,97 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// UserBan is a ban of a user, kept after it's lifted as the ban history of
+// the user. Bans are lifted when they expire, when the user is unbanned
+// and when the user is banned again.
+type UserBan struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	UserID      uuid.UUID  `json:"user_id" db:"user_id"`
+	Reason      *string    `json:"reason,omitempty" db:"reason"`
+	BannedBy    *uuid.UUID `json:"banned_by,omitempty" db:"banned_by"`
+	BannedUntil time.Time  `json:"banned_until" db:"banned_until"`
+	LiftedAt    *time.Time `json:"lifted_at,omitempty" db:"lifted_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+func (UserBan) TableName() string {
+	tableName := "user_bans"
+	return tableName
+}
+
+// NewUserBan records the ban of the user until its banned_until, by the
+// admin user when there's one.
+func NewUserBan(user *User, reason string, bannedBy *User) *UserBan {
+	ban := &UserBan{
+		ID:          uuid.Must(uuid.NewV4()),
+		UserID:      user.ID,
+		BannedUntil: *user.BannedUntil,
+	}
+
+	if reason != "" {
+		ban.Reason = &reason
+	}
+	if bannedBy != nil {
+		ban.BannedBy = &bannedBy.ID
+	}
+
+	return ban
+}
+
+// FindUserBansByUserID returns the ban history of the user, latest first.
+func FindUserBansByUserID(tx *storage.Connection, userID uuid.UUID) ([]*UserBan, error) {
+	bans := []*UserBan{}
+
+	if err := tx.Q().Where("user_id = ?", userID).Order("created_at desc").All(&bans); err != nil {
+		return nil, errors.Wrap(err, "error finding user bans")
+	}
+
+	return bans, nil
+}
+
+// LiftUserBans lifts the bans of the user that aren't lifted yet, the
+// expired ones when they expired.
+func LiftUserBans(tx *storage.Connection, userID uuid.UUID) error {
+	if err := tx.RawQuery(
+		fmt.Sprintf("update %q set lifted_at = least(banned_until, now()), updated_at = now() where user_id = ? and lifted_at is null", (&pop.Model{Value: UserBan{}}).TableName()),
+		userID,
+	).Exec(); err != nil {
+		return errors.Wrap(err, "error lifting user bans")
+	}
+
+	return nil
+}
+
+// LiftExpiredUserBans lifts up to limit bans that have expired, clearing
+// the banned_until of their users, and returns them.
+func LiftExpiredUserBans(tx *storage.Connection, limit int) ([]*UserBan, error) {
+	bans := []*UserBan{}
+	userBansTable := (&pop.Model{Value: UserBan{}}).TableName()
+	usersTable := (&pop.Model{Value: User{}}).TableName()
+
+	if err := tx.RawQuery(
+		fmt.Sprintf(`with lifted as (
+			update %q set lifted_at = banned_until, updated_at = now()
+			where id in (select id from %q where lifted_at is null and banned_until <= now() order by banned_until limit ? for update skip locked)
+			returning *
+		), cleared as (
+			update %q set banned_until = null where id in (select user_id from lifted) and banned_until <= now()
+		)
+		select * from lifted`, userBansTable, userBansTable, usersTable),
+		limit,
+	).All(&bans); err != nil {
+		return nil, errors.Wrap(err, "error lifting expired user bans")
+	}
+
+	return bans, nil
+}
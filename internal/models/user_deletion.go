@@ -0,0 +1,202 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// UserSnapshot is the personal data removed from a soft deleted user and
+// its identities, which is restored when it's undeleted.
+type UserSnapshot struct {
+	Email             string                 `json:"email,omitempty"`
+	Phone             string                 `json:"phone,omitempty"`
+	Username          string                 `json:"username,omitempty"`
+	EmailChange       string                 `json:"email_change,omitempty"`
+	PhoneChange       string                 `json:"phone_change,omitempty"`
+	EncryptedPassword *string                `json:"encrypted_password,omitempty"`
+	UserMetaData      map[string]interface{} `json:"user_metadata,omitempty"`
+	AppMetaData       map[string]interface{} `json:"app_metadata,omitempty"`
+	Identities        []IdentitySnapshot     `json:"identities,omitempty"`
+}
+
+type IdentitySnapshot struct {
+	ID           uuid.UUID              `json:"id"`
+	Provider     string                 `json:"provider"`
+	ProviderID   string                 `json:"provider_id"`
+	IdentityData map[string]interface{} `json:"identity_data,omitempty"`
+}
+
+// UserDeletion is the soft deletion of a user, which can be undone until
+// the user is purged. The snapshot is the personal data removed from the
+// user, encrypted with the database encryption key when enabled.
+type UserDeletion struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	Snapshot  string    `json:"-" db:"snapshot"`
+	DeletedAt time.Time `json:"deleted_at" db:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at" db:"purge_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (UserDeletion) TableName() string {
+	tableName := "user_deletions"
+	return tableName
+}
+
+// NewUserDeletion takes a snapshot of the personal data of the user and its
+// identities before it's soft deleted, to be purged after the retention
+// period.
+func NewUserDeletion(user *User, identities []*Identity, retentionPeriod time.Duration, encrypt bool, encryptionKeyID, encryptionKey string) (*UserDeletion, error) {
+	snapshot := UserSnapshot{
+		Email:             user.GetEmail(),
+		Phone:             user.GetPhone(),
+		Username:          user.GetUsername(),
+		EmailChange:       user.EmailChange,
+		PhoneChange:       user.PhoneChange,
+		EncryptedPassword: user.EncryptedPassword,
+		UserMetaData:      user.UserMetaData,
+		AppMetaData:       user.AppMetaData,
+	}
+	for _, identity := range identities {
+		snapshot.Identities = append(snapshot.Identities, IdentitySnapshot{
+			ID:           identity.ID,
+			Provider:     identity.Provider,
+			ProviderID:   identity.ProviderID,
+			IdentityData: identity.IdentityData,
+		})
+	}
+
+	// the snapshot is taken before soft deleting the user changes it
+	data, err := json.Marshal(&snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	deletion := &UserDeletion{
+		ID:        uuid.Must(uuid.NewV4()),
+		UserID:    user.ID,
+		Snapshot:  string(data),
+		DeletedAt: now,
+		PurgeAt:   now.Add(retentionPeriod),
+	}
+
+	if encrypt {
+		es, err := crypto.NewEncryptedString(deletion.ID.String(), data, encryptionKeyID, encryptionKey)
+		if err != nil {
+			return nil, err
+		}
+		deletion.Snapshot = es.String()
+	}
+
+	return deletion, nil
+}
+
+// GetSnapshot returns the snapshot of the user, decrypting it when it's
+// encrypted.
+func (d *UserDeletion) GetSnapshot(decryptionKeys map[string]string) (*UserSnapshot, error) {
+	data := []byte(d.Snapshot)
+	if es := crypto.ParseEncryptedString(d.Snapshot); es != nil {
+		var err error
+		data, err = es.Decrypt(d.ID.String(), decryptionKeys)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var snapshot UserSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, errors.Wrap(err, "error reading user deletion snapshot")
+	}
+
+	return &snapshot, nil
+}
+
+// Restore undeletes the user with the personal data of the snapshot, and
+// removes the deletion.
+func (d *UserDeletion) Restore(tx *storage.Connection, user *User, snapshot *UserSnapshot) error {
+	user.Email = storage.NullString(snapshot.Email)
+	user.Phone = storage.NullString(snapshot.Phone)
+	user.Username = storage.NullString(snapshot.Username)
+	user.EmailChange = snapshot.EmailChange
+	user.PhoneChange = snapshot.PhoneChange
+	user.EncryptedPassword = snapshot.EncryptedPassword
+	user.UserMetaData = snapshot.UserMetaData
+	user.AppMetaData = snapshot.AppMetaData
+	user.DeletedAt = nil
+
+	if err := tx.UpdateOnly(
+		user,
+		"email",
+		"phone",
+		"username",
+		"email_change",
+		"phone_change",
+		"encrypted_password",
+		"raw_user_meta_data",
+		"raw_app_meta_data",
+		"deleted_at",
+	); err != nil {
+		return errors.Wrap(err, "error restoring user")
+	}
+
+	for _, identity := range snapshot.Identities {
+		identityData, err := json.Marshal(identity.IdentityData)
+		if err != nil {
+			return err
+		}
+
+		// we use RawQuery here instead of UpdateOnly because UpdateOnly relies on the primary key of Identity
+		if err := tx.RawQuery(
+			"update "+
+				(&pop.Model{Value: Identity{}}).TableName()+
+				" set provider_id = ?, identity_data = ? where id = ? and user_id = ?",
+			identity.ProviderID,
+			string(identityData),
+			identity.ID,
+			user.ID,
+		).Exec(); err != nil {
+			return errors.Wrap(err, "error restoring identity")
+		}
+	}
+
+	return errors.Wrap(tx.Destroy(d), "error removing user deletion")
+}
+
+func FindUserDeletionByUserID(tx *storage.Connection, userID uuid.UUID) (*UserDeletion, error) {
+	var deletion UserDeletion
+
+	if err := tx.Q().Where("user_id = ?", userID).First(&deletion); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, UserDeletionNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding user deletion")
+	}
+
+	return &deletion, nil
+}
+
+// PurgeDeletedUsers deletes up to limit soft deleted users past their
+// retention period, with their deletions, and returns how many were
+// deleted.
+func PurgeDeletedUsers(tx *storage.Connection, limit int) (int, error) {
+	count, err := tx.RawQuery(
+		fmt.Sprintf("delete from %q where id in (select user_id from %q where purge_at < now() limit ? for update skip locked)", (&pop.Model{Value: User{}}).TableName(), (&pop.Model{Value: UserDeletion{}}).TableName()),
+		limit,
+	).ExecWithCount()
+	if err != nil {
+		return 0, errors.Wrap(err, "error purging deleted users")
+	}
+
+	return count, nil
+}
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// UserEmail is a secondary email address of a user. Once verified it can be
+// used to sign in and recover the account like the primary email, and no
+// other user can take it.
+type UserEmail struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"-" db:"user_id"`
+	Email      string     `json:"email" db:"email"`
+	TokenHash  *string    `json:"-" db:"token_hash"`
+	SentAt     *time.Time `json:"-" db:"sent_at"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty" db:"verified_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+
+	// FailedAttempts counts the wrong codes entered since the code was
+	// sent.
+	FailedAttempts int `json:"-" db:"failed_attempts"`
+}
+
+func (UserEmail) TableName() string {
+	tableName := "user_emails"
+	return tableName
+}
+
+// NewUserEmail creates an unverified secondary email address of the user.
+func NewUserEmail(user *User, email string) *UserEmail {
+	return &UserEmail{
+		ID:     uuid.Must(uuid.NewV4()),
+		UserID: user.ID,
+		Email:  strings.ToLower(email),
+	}
+}
+
+// IsVerified reports whether the user verified they own the address.
+func (e *UserEmail) IsVerified() bool {
+	return e.VerifiedAt != nil
+}
+
+// SetToken records the hash of the code sent to verify the address.
+func (e *UserEmail) SetToken(tx *storage.Connection, tokenHash string) error {
+	now := time.Now()
+	e.TokenHash = &tokenHash
+	e.SentAt = &now
+	e.FailedAttempts = 0
+
+	return errors.Wrap(tx.UpdateOnly(e, "token_hash", "sent_at", "failed_attempts", "updated_at"), "error updating user email")
+}
+
+// RecordFailedAttempt counts a wrong code, and clears the code once the
+// maximum attempts are reached, so that it can't be guessed.
+func (e *UserEmail) RecordFailedAttempt(tx *storage.Connection, maxAttempts int) error {
+	e.FailedAttempts++
+	if e.FailedAttempts >= maxAttempts {
+		e.TokenHash = nil
+	}
+
+	return errors.Wrap(tx.UpdateOnly(e, "token_hash", "failed_attempts", "updated_at"), "error updating user email")
+}
+
+// Verify marks the address as verified.
+func (e *UserEmail) Verify(tx *storage.Connection) error {
+	now := time.Now()
+	e.TokenHash = nil
+	e.VerifiedAt = &now
+
+	return tx.UpdateOnly(e, "token_hash", "verified_at", "updated_at")
+}
+
+// FindUserEmailsByUserID returns the secondary email addresses of the user,
+// the oldest first.
+func FindUserEmailsByUserID(tx *storage.Connection, userID uuid.UUID) ([]*UserEmail, error) {
+	emails := []*UserEmail{}
+
+	if err := tx.Q().Where("user_id = ?", userID).Order("created_at asc").All(&emails); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return emails, nil
+		}
+
+		return nil, errors.Wrap(err, "error finding user emails")
+	}
+
+	return emails, nil
+}
+
+// FindUserEmailByUserIDAndID returns the secondary email address of the
+// user with the ID.
+func FindUserEmailByUserIDAndID(tx *storage.Connection, userID, id uuid.UUID) (*UserEmail, error) {
+	return findUserEmail(tx, "user_id = ? and id = ?", userID, id)
+}
+
+// FindUserEmailByUserIDAndEmail returns the secondary email address of the
+// user matching the email.
+func FindUserEmailByUserIDAndEmail(tx *storage.Connection, userID uuid.UUID, email string) (*UserEmail, error) {
+	return findUserEmail(tx, "user_id = ? and email = ?", userID, strings.ToLower(email))
+}
+
+// FindVerifiedUserEmail returns the verified secondary email address
+// matching the email, whichever user it belongs to.
+func FindVerifiedUserEmail(tx *storage.Connection, email string) (*UserEmail, error) {
+	return findUserEmail(tx, "email = ? and verified_at is not null", strings.ToLower(email))
+}
+
+func findUserEmail(tx *storage.Connection, query string, args ...interface{}) (*UserEmail, error) {
+	var email UserEmail
+
+	if err := tx.Q().Where(query, args...).First(&email); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, UserEmailNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding user email")
+	}
+
+	return &email, nil
+}
+
+// FindUserByAnyEmailAndAudience finds a user with the matching primary
+// email, or verified secondary email, and audience.
+func FindUserByAnyEmailAndAudience(tx *storage.Connection, email, aud string) (*User, error) {
+	user, err := FindUserByEmailAndAudience(tx, email, aud)
+	if err == nil || !IsNotFoundError(err) {
+		return user, err
+	}
+
+	return findUser(tx, fmt.Sprintf("instance_id = ? and aud = ? and is_sso_user = false and id in (select user_id from %q where email = ? and verified_at is not null)", (&pop.Model{Value: UserEmail{}}).TableName()), uuid.Nil, aud, strings.ToLower(email))
+}
+
+// CountUserEmails returns how many secondary email addresses the user has,
+// verified or not.
+func CountUserEmails(tx *storage.Connection, userID uuid.UUID) (int, error) {
+	count, err := tx.Q().Where("user_id = ?", userID).Count(&UserEmail{})
+	if err != nil {
+		return 0, errors.Wrap(err, "error counting user emails")
+	}
+
+	return count, nil
+}
+
+// ClearUserEmailsForUser deletes the secondary email addresses of the user.
+func ClearUserEmailsForUser(tx *storage.Connection, userID uuid.UUID) error {
+	return errors.Wrap(tx.Q().Where("user_id = ?", userID).Delete(UserEmail{}), "error deleting user emails")
+}
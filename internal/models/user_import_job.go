@@ -0,0 +1,203 @@
+package models
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/crypto"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// Statuses of user import jobs.
+const (
+	UserImportPending   = "pending"
+	UserImportRunning   = "running"
+	UserImportCompleted = "completed"
+	UserImportFailed    = "failed"
+)
+
+// maxUserImportErrors is how many row errors are kept for a job, so a file
+// that's wrong in every row doesn't store an error for each of them.
+const maxUserImportErrors = 1000
+
+// UserImportError is the error importing a row, numbered from 1.
+type UserImportError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+type UserImportErrors []UserImportError
+
+func (e *UserImportErrors) Scan(src interface{}) error {
+	if src == nil {
+		*e = UserImportErrors{}
+		return nil
+	}
+
+	b, ok := src.([]byte)
+	if !ok {
+		return errors.New("scan source was not []byte")
+	}
+	return json.Unmarshal(b, e)
+}
+
+func (e UserImportErrors) Value() (driver.Value, error) {
+	if e == nil {
+		e = UserImportErrors{}
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// UserImportJob imports users in bulk in the background. The rows are the
+// users to import as JSON, encrypted with the database encryption key when
+// enabled as they hold password hashes, and removed once the job is done.
+type UserImportJob struct {
+	ID             uuid.UUID        `json:"id" db:"id"`
+	Status         string           `json:"status" db:"status"`
+	Aud            string           `json:"aud" db:"aud"`
+	Rows           string           `json:"-" db:"rows"`
+	TotalRows      int              `json:"total_rows" db:"total_rows"`
+	ProcessedRows  int              `json:"processed_rows" db:"processed_rows"`
+	ImportedRows   int              `json:"imported_rows" db:"imported_rows"`
+	FailedRows     int              `json:"failed_rows" db:"failed_rows"`
+	Errors         UserImportErrors `json:"errors" db:"errors"`
+	LeaseExpiresAt *time.Time       `json:"-" db:"lease_expires_at"`
+	CompletedAt    *time.Time       `json:"completed_at,omitempty" db:"completed_at"`
+	CreatedAt      time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at" db:"updated_at"`
+}
+
+func (UserImportJob) TableName() string {
+	tableName := "user_import_jobs"
+	return tableName
+}
+
+// NewUserImportJob creates a pending job importing the rows into the
+// audience.
+func NewUserImportJob(aud string, rows []json.RawMessage, encrypt bool, encryptionKeyID, encryptionKey string) (*UserImportJob, error) {
+	job := &UserImportJob{
+		ID:        uuid.Must(uuid.NewV4()),
+		Status:    UserImportPending,
+		Aud:       aud,
+		TotalRows: len(rows),
+		Errors:    UserImportErrors{},
+	}
+
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Rows = string(data)
+	if encrypt {
+		es, err := crypto.NewEncryptedString(job.ID.String(), data, encryptionKeyID, encryptionKey)
+		if err != nil {
+			return nil, err
+		}
+		job.Rows = es.String()
+	}
+
+	return job, nil
+}
+
+// GetRows returns the rows of the job, decrypting them when they're
+// encrypted.
+func (j *UserImportJob) GetRows(decryptionKeys map[string]string) ([]json.RawMessage, error) {
+	data := []byte(j.Rows)
+	if es := crypto.ParseEncryptedString(j.Rows); es != nil {
+		var err error
+		data, err = es.Decrypt(j.ID.String(), decryptionKeys)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var rows []json.RawMessage
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// AddError records the error of a row, past the rows processed so far.
+func (j *UserImportJob) AddError(row int, message string) {
+	j.FailedRows += 1
+	if len(j.Errors) < maxUserImportErrors {
+		j.Errors = append(j.Errors, UserImportError{Row: row, Error: message})
+	}
+}
+
+// SaveProgress records the rows processed so far, and extends the lease of
+// the job.
+func (j *UserImportJob) SaveProgress(tx *storage.Connection, lease time.Duration) error {
+	leaseExpiresAt := time.Now().Add(lease)
+	j.LeaseExpiresAt = &leaseExpiresAt
+
+	return errors.Wrap(tx.UpdateOnly(j, "processed_rows", "imported_rows", "failed_rows", "errors", "lease_expires_at", "updated_at"), "error updating user import job")
+}
+
+// Complete records the end of the job, with the status it ended with, and
+// removes its rows.
+func (j *UserImportJob) Complete(tx *storage.Connection, status string) error {
+	now := time.Now()
+
+	j.Status = status
+	j.Rows = ""
+	j.LeaseExpiresAt = nil
+	j.CompletedAt = &now
+
+	return errors.Wrap(tx.UpdateOnly(j, "status", "rows", "processed_rows", "imported_rows", "failed_rows", "errors", "lease_expires_at", "completed_at", "updated_at"), "error updating user import job")
+}
+
+// ClaimUserImportJob returns the oldest job that's pending, or running with
+// an expired lease as the instance running it stopped, and marks it running
+// with a lease so other instances don't run it at the same time. It returns
+// nil when there's no job to run.
+func ClaimUserImportJob(tx *storage.Connection, lease time.Duration) (*UserImportJob, error) {
+	var job UserImportJob
+
+	if err := tx.Transaction(func(tx *storage.Connection) error {
+		if err := tx.RawQuery(fmt.Sprintf("select * from %q where status = ? or (status = ? and lease_expires_at < now()) order by created_at limit 1 for update skip locked", (&pop.Model{Value: UserImportJob{}}).TableName()), UserImportPending, UserImportRunning).First(&job); err != nil {
+			return err
+		}
+
+		leaseExpiresAt := time.Now().Add(lease)
+		job.Status = UserImportRunning
+		job.LeaseExpiresAt = &leaseExpiresAt
+
+		return tx.UpdateOnly(&job, "status", "lease_expires_at", "updated_at")
+	}); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "error claiming user import job")
+	}
+
+	return &job, nil
+}
+
+func FindUserImportJobByID(tx *storage.Connection, id uuid.UUID) (*UserImportJob, error) {
+	var job UserImportJob
+
+	if err := tx.Q().Where("id = ?", id).First(&job); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, UserImportJobNotFoundError{}
+		}
+
+		return nil, errors.Wrap(err, "error finding user import job")
+	}
+
+	return &job, nil
+}
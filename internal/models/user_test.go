@@ -3,6 +3,7 @@ package models
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -106,7 +107,7 @@ func (ts *UserTestSuite) TestFindUserByEmailAndAudience() {
 func (ts *UserTestSuite) TestFindUsersInAudience() {
 	u := ts.createUser()
 
-	n, err := FindUsersInAudience(ts.db, u.Aud, nil, nil, "")
+	n, err := FindUsersInAudience(ts.db, u.Aud, nil, nil, UserFilter{})
 	require.NoError(ts.T(), err)
 	require.Len(ts.T(), n, 1)
 
@@ -114,7 +115,7 @@ func (ts *UserTestSuite) TestFindUsersInAudience() {
 		Page:    1,
 		PerPage: 50,
 	}
-	n, err = FindUsersInAudience(ts.db, u.Aud, &p, nil, "")
+	n, err = FindUsersInAudience(ts.db, u.Aud, &p, nil, UserFilter{})
 	require.NoError(ts.T(), err)
 	require.Len(ts.T(), n, 1)
 	assert.Equal(ts.T(), uint64(1), p.Count)
@@ -124,11 +125,121 @@ func (ts *UserTestSuite) TestFindUsersInAudience() {
 			{Name: "created_at", Dir: Descending},
 		},
 	}
-	n, err = FindUsersInAudience(ts.db, u.Aud, nil, sp, "")
+	n, err = FindUsersInAudience(ts.db, u.Aud, nil, sp, UserFilter{})
 	require.NoError(ts.T(), err)
 	require.Len(ts.T(), n, 1)
 }
 
+func (ts *UserTestSuite) TestFindUsersInAudienceByCursor() {
+	signedIn := ts.createUserWithEmail("signed-in@example.com")
+	now := time.Now()
+	require.NoError(ts.T(), signedIn.UpdateLastSignInAt(ts.db))
+	signedIn.AppMetaData = JSONMap{"providers": []string{"email", "github"}}
+	require.NoError(ts.T(), ts.db.UpdateOnly(signedIn, "raw_app_meta_data"))
+
+	first := ts.createUserWithEmail("first@example.com")
+	second := ts.createUserWithEmail("second@example.com")
+
+	field := SortField{Name: "last_sign_in_at", Dir: Descending}
+	users, err := FindUsersInAudienceByCursor(ts.db, "test", field, nil, 2, UserFilter{})
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), users, 2)
+	require.Equal(ts.T(), signedIn.ID, users[0].ID)
+
+	// the users that never signed in are last, in the order of their IDs
+	last := first
+	if second.ID.String() < first.ID.String() {
+		last = second
+	}
+
+	users, err = FindUsersInAudienceByCursor(ts.db, "test", field, NewUserCursor(users[1], field.Name), 2, UserFilter{})
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), users, 1)
+	require.Equal(ts.T(), last.ID, users[0].ID)
+
+	after := now.Add(-time.Minute)
+	users, err = FindUsersInAudienceByCursor(ts.db, "test", SortField{Name: CreatedAt, Dir: Ascending}, nil, 10, UserFilter{LastSignInAfter: &after, Provider: "github"})
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), users, 1)
+	require.Equal(ts.T(), signedIn.ID, users[0].ID)
+
+	_, err = FindUsersInAudienceByCursor(ts.db, "test", SortField{Name: "email", Dir: Ascending}, nil, 10, UserFilter{})
+	require.Error(ts.T(), err)
+}
+
+func (ts *UserTestSuite) TestUserDeletionRestoreAndPurge() {
+	u := ts.createUserWithEmail("deleted@example.com")
+	u.UserMetaData = JSONMap{"name": "Jane"}
+	require.NoError(ts.T(), ts.db.UpdateOnly(u, "raw_user_meta_data"))
+
+	deletion, err := NewUserDeletion(u, nil, time.Hour, false, "", "")
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.db.Create(deletion))
+	require.NoError(ts.T(), u.SoftDeleteUser(ts.db))
+	require.NotEqual(ts.T(), "deleted@example.com", u.GetEmail())
+
+	found, err := FindUserDeletionByUserID(ts.db, u.ID)
+	require.NoError(ts.T(), err)
+	snapshot, err := found.GetSnapshot(nil)
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), found.Restore(ts.db, u, snapshot))
+
+	restored, err := FindUserByID(ts.db, u.ID)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), "deleted@example.com", restored.GetEmail())
+	require.Equal(ts.T(), "Jane", restored.UserMetaData["name"])
+	require.Nil(ts.T(), restored.DeletedAt)
+
+	_, err = FindUserDeletionByUserID(ts.db, u.ID)
+	require.True(ts.T(), IsNotFoundError(err))
+
+	// only the users past their retention period are purged
+	deletion, err = NewUserDeletion(u, nil, -time.Minute, false, "", "")
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.db.Create(deletion))
+
+	kept := ts.createUserWithEmail("kept@example.com")
+	deletion, err = NewUserDeletion(kept, nil, time.Hour, false, "", "")
+	require.NoError(ts.T(), err)
+	require.NoError(ts.T(), ts.db.Create(deletion))
+
+	count, err := PurgeDeletedUsers(ts.db, 10)
+	require.NoError(ts.T(), err)
+	require.Equal(ts.T(), 1, count)
+
+	_, err = FindUserByID(ts.db, u.ID)
+	require.True(ts.T(), IsNotFoundError(err))
+	_, err = FindUserByID(ts.db, kept.ID)
+	require.NoError(ts.T(), err)
+}
+
+func (ts *UserTestSuite) TestLiftExpiredUserBans() {
+	expired := ts.createUserWithEmail("expired@example.com")
+	require.NoError(ts.T(), expired.Ban(ts.db, -time.Minute))
+	require.NoError(ts.T(), ts.db.Create(NewUserBan(expired, "Spam", nil)))
+
+	banned := ts.createUserWithEmail("banned@example.com")
+	require.NoError(ts.T(), banned.Ban(ts.db, time.Hour))
+	require.NoError(ts.T(), ts.db.Create(NewUserBan(banned, "", nil)))
+
+	bans, err := LiftExpiredUserBans(ts.db, 10)
+	require.NoError(ts.T(), err)
+	require.Len(ts.T(), bans, 1)
+	require.Equal(ts.T(), expired.ID, bans[0].UserID)
+
+	u, err := FindUserByID(ts.db, expired.ID)
+	require.NoError(ts.T(), err)
+	require.Nil(ts.T(), u.BannedUntil)
+
+	u, err = FindUserByID(ts.db, banned.ID)
+	require.NoError(ts.T(), err)
+	require.True(ts.T(), u.IsBanned())
+
+	bans, err = LiftExpiredUserBans(ts.db, 10)
+	require.NoError(ts.T(), err)
+	require.Empty(ts.T(), bans)
+}
+
 func (ts *UserTestSuite) TestFindUserByID() {
 	u := ts.createUser()
 
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/storage"
+)
+
+// Web3Nonce is a nonce issued for a Sign-In with Ethereum message. Each nonce
+// can only be used to sign in once.
+type Web3Nonce struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	Nonce     string    `json:"nonce" db:"nonce"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+func (Web3Nonce) TableName() string {
+	tableName := "web3_nonces"
+	return tableName
+}
+
+// NewWeb3Nonce creates a nonce made of alphanumeric characters, as required
+// by EIP-4361.
+func NewWeb3Nonce() (*Web3Nonce, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, errors.Wrap(err, "error generating web3 nonce")
+	}
+
+	return &Web3Nonce{
+		ID:    uuid.Must(uuid.NewV4()),
+		Nonce: hex.EncodeToString(b),
+	}, nil
+}
+
+// ConsumeWeb3Nonce finds and deletes the nonce, so that it can't be used
+// again.
+func ConsumeWeb3Nonce(tx *storage.Connection, nonce string) (*Web3Nonce, error) {
+	obj := &Web3Nonce{}
+	if err := tx.Q().Where("nonce = ?", nonce).First(obj); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, Web3NonceNotFoundError{}
+		}
+		return nil, errors.Wrap(err, "error finding web3 nonce")
+	}
+
+	if err := tx.Destroy(obj); err != nil {
+		return nil, errors.Wrap(err, "error deleting web3 nonce")
+	}
+
+	return obj, nil
+}
+
+// IsExpired reports whether the nonce can no longer be used.
+func (n *Web3Nonce) IsExpired(expiry time.Duration) bool {
+	return time.Now().After(n.CreatedAt.Add(expiry))
+}
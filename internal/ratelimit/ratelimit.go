@@ -0,0 +1,74 @@
+// Package ratelimit counts the rate limits in a store shared by the
+// instances, so that they hold across replicas and restarts.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/redis"
+)
+
+// neverReplenished is the interval between events of limits without a
+// rate, whose bursts are never replenished, like the in memory limits
+// keep them.
+const neverReplenished = 87600 * time.Hour
+
+// Limit is a rate of events per second, with bursts of events allowed at
+// once.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// interval is the time it takes to replenish an event of the burst.
+func (l Limit) interval() time.Duration {
+	if l.Rate <= 0 {
+		return neverReplenished
+	}
+
+	return min(time.Duration(float64(time.Second)/l.Rate), neverReplenished)
+}
+
+// burst is the events allowed at once, at least one.
+func (l Limit) burst() int {
+	return max(1, l.Burst)
+}
+
+// window is the time it takes to replenish the whole burst.
+func (l Limit) window() time.Duration {
+	return l.interval() * time.Duration(l.burst())
+}
+
+// Store counts the events of each key.
+type Store interface {
+	// Allow reports whether another event of the key is within the
+	// limit, and counts it when it is.
+	Allow(ctx context.Context, key string, limit Limit) (bool, error)
+}
+
+// NewStore returns the store of the configuration, or nil when the limits
+// are counted in memory.
+func NewStore(config *conf.RateLimitStoreConfiguration) (Store, error) {
+	switch config.Backend {
+	case "", conf.RateLimitStoreMemory:
+		return nil, nil
+
+	case conf.RateLimitStoreRedis:
+		u, err := url.Parse(config.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		return &RedisStore{
+			pool:      redis.NewPool(u, config.Timeout),
+			prefix:    config.KeyPrefix,
+			algorithm: config.Algorithm,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("ratelimit: unsupported store backend %q", config.Backend)
+}
@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/redis"
+)
+
+func TestLimit(t *testing.T) {
+	// 30 requests per 5 minutes, like the default limits of the endpoints
+	limit := Limit{Rate: 30.0 / (60 * 5), Burst: 30}
+	require.Equal(t, 10*time.Second, limit.interval())
+	require.Equal(t, 5*time.Minute, limit.window())
+
+	// limits without a rate are never replenished
+	limit = Limit{}
+	require.Equal(t, neverReplenished, limit.interval())
+	require.Equal(t, 1, limit.burst())
+}
+
+func TestNewStore(t *testing.T) {
+	store, err := NewStore(&conf.RateLimitStoreConfiguration{Backend: conf.RateLimitStoreMemory})
+	require.NoError(t, err)
+	require.Nil(t, store)
+
+	store, err = NewStore(&conf.RateLimitStoreConfiguration{Backend: conf.RateLimitStoreRedis, URL: "redis://localhost:6379", Algorithm: conf.RateLimitAlgorithmGCRA, Timeout: time.Second})
+	require.NoError(t, err)
+	require.IsType(t, &RedisStore{}, store)
+}
+
+func TestRedisStore(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	commands := make(chan []interface{}, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				cached := false
+
+				for {
+					reply, err := redis.ReadReply(reader)
+					if err != nil {
+						return
+					}
+
+					command := reply.([]interface{})
+					commands <- command
+
+					// scripts are cached once they're sent, and allow the
+					// events of the allowed key
+					switch {
+					case command[0] == "EVALSHA" && !cached:
+						io.WriteString(conn, "-NOSCRIPT No matching script\r\n")
+
+					case command[3] == "gotrue:rate_limit:allowed":
+						cached = true
+						io.WriteString(conn, ":1\r\n")
+
+					default:
+						cached = true
+						io.WriteString(conn, ":0\r\n")
+					}
+				}
+			}()
+		}
+	}()
+
+	u := &url.URL{Scheme: "redis", Host: listener.Addr().String()}
+	limit := Limit{Rate: 30.0 / (60 * 5), Burst: 30}
+
+	store := &RedisStore{pool: redis.NewPool(u, time.Second), prefix: "gotrue:rate_limit:", algorithm: conf.RateLimitAlgorithmGCRA}
+
+	allowed, err := store.Allow(context.Background(), "allowed", limit)
+	require.NoError(t, err)
+	require.True(t, allowed)
+
+	evalsha := <-commands
+	require.Equal(t, []interface{}{"EVALSHA", gcraScript.hash, "1", "gotrue:rate_limit:allowed", "10000.000", "30"}, evalsha)
+	eval := <-commands
+	require.Equal(t, []interface{}{"EVAL", gcraScript.source, "1", "gotrue:rate_limit:allowed", "10000.000", "30"}, eval)
+
+	allowed, err = store.Allow(context.Background(), "denied", limit)
+	require.NoError(t, err)
+	require.False(t, allowed)
+	require.Equal(t, "EVALSHA", (<-commands)[0])
+
+	store.algorithm = conf.RateLimitAlgorithmSlidingWindow
+	allowed, err = store.Allow(context.Background(), "allowed", limit)
+	require.NoError(t, err)
+	require.True(t, allowed)
+
+	command := <-commands
+	require.Equal(t, []interface{}{"EVALSHA", slidingWindowScript.hash, "1", "gotrue:rate_limit:allowed", "300000.000", "30"}, command[:6])
+	require.Len(t, command, 7)
+
+	listener.Close()
+	_, err = (&RedisStore{pool: redis.NewPool(u, time.Second), algorithm: conf.RateLimitAlgorithmGCRA}).Allow(context.Background(), "allowed", limit)
+	require.Error(t, err)
+}
@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha1" //#nosec G505 -- Redis identifies cached scripts by their SHA1 hashes.
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/supabase/auth/internal/conf"
+	"github.com/supabase/auth/internal/redis"
+)
+
+// gcraScript allows bursts of events like a token bucket, keeping only
+// the theoretical arrival time of the next event of the key, in
+// milliseconds. The clock of the server is used, so that the clocks of the
+// instances don't matter.
+var gcraScript = newScript(`
+local interval = tonumber(ARGV[1])
+local tolerance = interval * tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + tonumber(time[2]) / 1000
+local tat = math.max(tonumber(redis.call('GET', KEYS[1])) or now, now)
+if tat + interval - now > tolerance then
+	return 0
+end
+tat = tat + interval
+redis.call('SET', KEYS[1], string.format('%.3f', tat), 'PX', math.ceil(tat - now))
+return 1
+`)
+
+// slidingWindowScript allows as many events as the burst within the
+// window, keeping the times of the events of the key within it in a
+// sorted set.
+var slidingWindowScript = newScript(`
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + tonumber(time[2]) / 1000
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+if redis.call('ZCARD', KEYS[1]) >= limit then
+	return 0
+end
+redis.call('ZADD', KEYS[1], now, ARGV[3])
+redis.call('PEXPIRE', KEYS[1], math.ceil(window))
+return 1
+`)
+
+// RedisStore counts the events in Redis, with Lua scripts so that each
+// event is counted atomically.
+type RedisStore struct {
+	pool      *redis.Pool
+	prefix    string
+	algorithm string
+}
+
+func (s *RedisStore) Allow(ctx context.Context, key string, limit Limit) (bool, error) {
+	var reply interface{}
+	var err error
+
+	key = s.prefix + key
+	burst := strconv.Itoa(limit.burst())
+
+	switch s.algorithm {
+	case conf.RateLimitAlgorithmSlidingWindow:
+		member := uuid.Must(uuid.NewV4()).String()
+		reply, err = slidingWindowScript.run(ctx, s.pool, key, milliseconds(limit.window()), burst, member)
+
+	default:
+		reply, err = gcraScript.run(ctx, s.pool, key, milliseconds(limit.interval()), burst)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	allowed, ok := reply.(int64)
+	if !ok {
+		return false, errors.New("ratelimit: unexpected reply from redis")
+	}
+
+	return allowed == 1, nil
+}
+
+func milliseconds(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
+
+// script is a Lua script, which the server caches by its SHA-1 hash.
+type script struct {
+	source string
+	hash   string
+}
+
+func newScript(source string) *script {
+	hash := sha1.Sum([]byte(source)) //#nosec G401
+	return &script{
+		source: source,
+		hash:   hex.EncodeToString(hash[:]),
+	}
+}
+
+// run runs the script with the key and the arguments, sending it to the
+// server when it isn't cached yet, like after restarts.
+func (s *script) run(ctx context.Context, pool *redis.Pool, key string, args ...string) (interface{}, error) {
+	reply, err := pool.Do(ctx, append([]string{"EVALSHA", s.hash, "1", key}, args...)...)
+
+	var replyErr redis.Error
+	if errors.As(err, &replyErr) && strings.HasPrefix(string(replyErr), "NOSCRIPT") {
+		reply, err = pool.Do(ctx, append([]string{"EVAL", s.source, "1", key}, args...)...)
+	}
+
+	return reply, err
+}
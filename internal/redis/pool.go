@@ -0,0 +1,97 @@
+package redis
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// maxIdleConns is how many connections pools keep open between commands.
+const maxIdleConns = 16
+
+// Pool reuses the connections to a server for the commands of concurrent
+// requests.
+type Pool struct {
+	url     *url.URL
+	timeout time.Duration
+
+	mutex sync.Mutex
+	idle  []*Conn
+}
+
+// NewPool creates a pool of connections to the server of the URL, which
+// bounds connecting and each command with the timeout.
+func NewPool(u *url.URL, timeout time.Duration) *Pool {
+	return &Pool{
+		url:     u,
+		timeout: timeout,
+	}
+}
+
+// Do sends the command on an idle connection, or a new one, and returns
+// its reply.
+func (p *Pool) Do(ctx context.Context, args ...string) (interface{}, error) {
+	conn, err := p.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(p.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reply, err := conn.Do(args...)
+
+	// connections are only reused when the whole reply was read, even when
+	// it's an error reply, as they're out of sync otherwise
+	if _, ok := err.(Error); err != nil && !ok {
+		conn.Close()
+		return nil, err
+	}
+
+	p.put(conn)
+	return reply, err
+}
+
+func (p *Pool) get(ctx context.Context) (*Conn, error) {
+	p.mutex.Lock()
+	if n := len(p.idle); n > 0 {
+		conn := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mutex.Unlock()
+		return conn, nil
+	}
+	p.mutex.Unlock()
+
+	return Dial(ctx, p.url, p.timeout)
+}
+
+func (p *Pool) put(conn *Conn) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if len(p.idle) >= maxIdleConns {
+		conn.Close()
+		return
+	}
+
+	p.idle = append(p.idle, conn)
+}
+
+// Close closes the idle connections.
+func (p *Pool) Close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, conn := range p.idle {
+		conn.Close()
+	}
+	p.idle = nil
+}
@@ -0,0 +1,217 @@
+// Package redis is a minimal client of Redis, speaking its RESP protocol,
+// for the features that share state between the instances through a Redis
+// server.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Error is an error reply of the server, like the ones of failed
+// commands.
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// Conn is a connection to a Redis server.
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Dial connects to the server of the URL, like redis://:password@host:6379/0
+// or rediss:// for TLS. It authenticates with the user and password of the
+// URL when it has them, and selects the database of its path.
+func Dial(ctx context.Context, u *url.URL, timeout time.Duration) (*Conn, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "6379")
+	}
+
+	var conn net.Conn
+	var err error
+
+	dialer := &net.Dialer{Timeout: timeout}
+	if u.Scheme == "rediss" {
+		tlsDialer := &tls.Dialer{
+			NetDialer: dialer,
+			Config:    &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12},
+		}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", host)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", host)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "redis: unable to connect")
+	}
+
+	c := &Conn{conn: conn, reader: bufio.NewReader(conn)}
+	if err := c.setup(u, timeout); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Conn) setup(u *url.URL, timeout time.Duration) error {
+	password, hasPassword := u.User.Password()
+	database := strings.TrimPrefix(u.Path, "/")
+	if !hasPassword && database == "" {
+		return nil
+	}
+
+	if err := c.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+
+	if hasPassword {
+		args := []string{"AUTH", password}
+		if username := u.User.Username(); username != "" {
+			args = []string{"AUTH", username, password}
+		}
+
+		if _, err := c.Do(args...); err != nil {
+			return errors.Wrap(err, "redis: unable to authenticate")
+		}
+	}
+
+	if database != "" {
+		if _, err := c.Do("SELECT", database); err != nil {
+			return errors.Wrap(err, "redis: unable to select database")
+		}
+	}
+
+	return c.SetDeadline(time.Time{})
+}
+
+// Do sends the command and returns its reply.
+func (c *Conn) Do(args ...string) (interface{}, error) {
+	if err := c.Send(args...); err != nil {
+		return nil, err
+	}
+
+	return c.Receive()
+}
+
+// Send sends the command, without waiting for its reply.
+func (c *Conn) Send(args ...string) error {
+	return WriteCommand(c.conn, args...)
+}
+
+// Receive reads the next reply, like the messages pushed to subscribers.
+func (c *Conn) Receive() (interface{}, error) {
+	return ReadReply(c.reader)
+}
+
+// SetDeadline bounds the commands sent and replies read until the time.
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// WriteCommand writes the command, as an array of bulk strings.
+func WriteCommand(w io.Writer, args ...string) error {
+	command := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		command = append(command, "$"+strconv.Itoa(len(arg))+"\r\n"...)
+		command = append(command, arg...)
+		command = append(command, "\r\n"...)
+	}
+
+	_, err := w.Write(command)
+	return err
+}
+
+// ReadReply reads a reply, as a string, an int64, nil, or an array of
+// them. Error replies are returned as Error, and so are the first error
+// replies in arrays, once the whole array was read. Other errors leave the
+// rest of the reply unread.
+func ReadReply(reader *bufio.Reader) (interface{}, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: invalid reply %q", line)
+	}
+	kind, value := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return value, nil
+
+	case '-':
+		return nil, Error(value)
+
+	case ':':
+		return strconv.ParseInt(value, 10, 64)
+
+	case '$':
+		length, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid bulk string length %q", value)
+		}
+		if length < 0 {
+			return nil, nil
+		}
+
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+
+		return string(data[:length]), nil
+
+	case '*':
+		length, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid array length %q", value)
+		}
+		if length < 0 {
+			return nil, nil
+		}
+
+		// the elements after an error reply are read too, so that the next
+		// reply is read from its start
+		var replyErr error
+		parts := make([]interface{}, length)
+		for i := range parts {
+			parts[i], err = ReadReply(reader)
+			if _, ok := err.(Error); ok {
+				if replyErr == nil {
+					replyErr = err
+				}
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if replyErr != nil {
+			return nil, replyErr
+		}
+
+		return parts, nil
+	}
+
+	return nil, fmt.Errorf("redis: invalid reply %q", line)
+}
@@ -0,0 +1,141 @@
+package redis
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadReply(t *testing.T) {
+	var buffer bytes.Buffer
+	require.NoError(t, WriteCommand(&buffer, "SET", "key", "a\r\nb"))
+	require.Equal(t, "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$4\r\na\r\nb\r\n", buffer.String())
+
+	reply, err := ReadReply(bufio.NewReader(&buffer))
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"SET", "key", "a\r\nb"}, reply)
+
+	reader := bufio.NewReader(bytes.NewBufferString("+OK\r\n:42\r\n$-1\r\n-NOSCRIPT No matching script\r\n"))
+
+	reply, err = ReadReply(reader)
+	require.NoError(t, err)
+	require.Equal(t, "OK", reply)
+
+	reply, err = ReadReply(reader)
+	require.NoError(t, err)
+	require.Equal(t, int64(42), reply)
+
+	reply, err = ReadReply(reader)
+	require.NoError(t, err)
+	require.Nil(t, reply)
+
+	_, err = ReadReply(reader)
+	require.Equal(t, Error("NOSCRIPT No matching script"), err)
+
+	_, err = ReadReply(bufio.NewReader(bytes.NewBufferString("?\r\n")))
+	require.Error(t, err)
+
+	// arrays are read to their end even when they have error replies
+	reader = bufio.NewReader(bytes.NewBufferString("*3\r\n-ERR first\r\n-ERR second\r\n:1\r\n+OK\r\n"))
+
+	_, err = ReadReply(reader)
+	require.Equal(t, Error("ERR first"), err)
+
+	reply, err = ReadReply(reader)
+	require.NoError(t, err)
+	require.Equal(t, "OK", reply)
+}
+
+func TestPool(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	var connections atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			connections.Add(1)
+
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+
+				for {
+					reply, err := ReadReply(reader)
+					if err != nil {
+						return
+					}
+
+					command := reply.([]interface{})
+					switch command[0] {
+					case "AUTH":
+						if command[1] != "secret" {
+							io.WriteString(conn, "-WRONGPASS invalid password\r\n")
+							continue
+						}
+						io.WriteString(conn, "+OK\r\n")
+
+					case "SELECT":
+						io.WriteString(conn, "+OK\r\n")
+
+					case "INCR":
+						io.WriteString(conn, ":1\r\n")
+
+					case "EXEC":
+						io.WriteString(conn, "*2\r\n-ERR failed\r\n:2\r\n")
+
+					case "GARBAGE":
+						io.WriteString(conn, "?\r\n")
+
+					default:
+						io.WriteString(conn, "-ERR unknown command\r\n")
+					}
+				}
+			}()
+		}
+	}()
+
+	u := &url.URL{Scheme: "redis", Host: listener.Addr().String(), User: url.UserPassword("", "secret"), Path: "/1"}
+	pool := NewPool(u, time.Second)
+	defer pool.Close()
+
+	reply, err := pool.Do(context.Background(), "INCR", "key")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), reply)
+
+	// connections are reused after error replies
+	_, err = pool.Do(context.Background(), "UNKNOWN")
+	require.Equal(t, Error("ERR unknown command"), err)
+
+	_, err = pool.Do(context.Background(), "EXEC")
+	require.Equal(t, Error("ERR failed"), err)
+
+	reply, err = pool.Do(context.Background(), "INCR", "key")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), reply)
+	require.Equal(t, int32(1), connections.Load())
+
+	// but not after invalid replies
+	_, err = pool.Do(context.Background(), "GARBAGE")
+	require.Error(t, err)
+
+	_, err = pool.Do(context.Background(), "INCR", "key")
+	require.NoError(t, err)
+	require.Equal(t, int32(2), connections.Load())
+
+	u.User = url.UserPassword("", "wrong")
+	_, err = NewPool(u, time.Second).Do(context.Background(), "INCR", "key")
+	require.Error(t, err)
+}
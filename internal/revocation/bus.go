@@ -0,0 +1,72 @@
+package revocation
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/supabase/auth/internal/conf"
+)
+
+// busTimeout bounds connecting to the server and publishing to it, so
+// that requests revoking sessions don't wait on it for long.
+const busTimeout = 5 * time.Second
+
+// Bus publishes messages to the other instances and receives theirs.
+type Bus interface {
+	Publish(ctx context.Context, message []byte) error
+
+	// Subscribe calls handle with the messages until the context is done
+	// or the connection to the server is lost.
+	Subscribe(ctx context.Context, handle func(message []byte)) error
+}
+
+// NewBus returns the Redis or NATS bus of the server of the URL.
+func NewBus(config *conf.RevocationsConfiguration) (Bus, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "redis", "rediss":
+		return &RedisBus{url: u, channel: config.Channel}, nil
+
+	case "nats":
+		return &NATSBus{url: u, subject: config.Channel}, nil
+	}
+
+	return nil, fmt.Errorf("revocation: unsupported server URL scheme %q", u.Scheme)
+}
+
+// dial connects to the host of the URL, with the default port when it has
+// none.
+func dial(ctx context.Context, u *url.URL, defaultPort string) (net.Conn, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+
+	dialer := &net.Dialer{Timeout: busTimeout}
+	return dialer.DialContext(ctx, "tcp", host)
+}
+
+// closeOnDone closes the connection when the context is done, to unblock
+// its reads, until the returned function is called.
+func closeOnDone(ctx context.Context, conn io.Closer) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
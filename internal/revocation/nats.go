@@ -0,0 +1,186 @@
+package revocation
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// NATSBus publishes the messages to a NATS subject, speaking the client
+// protocol of NATS. Each message is published on a new connection, as
+// revocations are rare.
+type NATSBus struct {
+	url     *url.URL
+	subject string
+}
+
+func (b *NATSBus) Publish(ctx context.Context, message []byte) error {
+	conn, reader, err := b.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(busTimeout)); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(conn, "PUB %s %d\r\n%s\r\n", b.subject, len(message), message); err != nil {
+		return errors.Wrap(err, "revocation: unable to publish to nats")
+	}
+
+	// the server processes the commands of a connection in order, so the
+	// message is published once the ping is answered
+	if err := natsPing(conn, reader); err != nil {
+		return errors.Wrap(err, "revocation: unable to publish to nats")
+	}
+
+	return nil
+}
+
+func (b *NATSBus) Subscribe(ctx context.Context, handle func(message []byte)) error {
+	conn, reader, err := b.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer closeOnDone(ctx, conn)()
+
+	if _, err := fmt.Fprintf(conn, "SUB %s 1\r\n", b.subject); err != nil {
+		return errors.Wrap(err, "revocation: unable to subscribe to nats")
+	}
+
+	for {
+		line, err := readNATSLine(reader)
+		if err != nil {
+			return errors.Wrap(err, "revocation: unable to read from nats")
+		}
+
+		switch {
+		case line == "PING":
+			if _, err := io.WriteString(conn, "PONG\r\n"); err != nil {
+				return errors.Wrap(err, "revocation: unable to answer nats")
+			}
+
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.Errorf("revocation: nats error %s", strings.TrimSpace(line[4:]))
+
+		case strings.HasPrefix(line, "MSG "):
+			// MSG <subject> <sid> [reply-to] <size>
+			fields := strings.Fields(line)
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil || size < 0 {
+				return errors.Errorf("revocation: invalid nats message %q", line)
+			}
+
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				return errors.Wrap(err, "revocation: unable to read from nats")
+			}
+
+			handle(payload[:size])
+		}
+	}
+}
+
+// connect connects to the server, and authenticates with the user and
+// password, or the token, of the URL when it has them.
+func (b *NATSBus) connect(ctx context.Context) (net.Conn, *bufio.Reader, error) {
+	conn, err := dial(ctx, b.url, "4222")
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "revocation: unable to connect to nats")
+	}
+	reader := bufio.NewReader(conn)
+
+	if err := b.handshake(conn, reader); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	return conn, reader, nil
+}
+
+func (b *NATSBus) handshake(conn net.Conn, reader *bufio.Reader) error {
+	if err := conn.SetDeadline(time.Now().Add(busTimeout)); err != nil {
+		return err
+	}
+
+	// the server greets clients with its info
+	line, err := readNATSLine(reader)
+	if err != nil {
+		return errors.Wrap(err, "revocation: unable to connect to nats")
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return errors.Errorf("revocation: unexpected nats greeting %q", line)
+	}
+
+	options := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "gotrue",
+		"lang":     "go",
+	}
+	if password, ok := b.url.User.Password(); ok {
+		options["user"] = b.url.User.Username()
+		options["pass"] = password
+	} else if token := b.url.User.Username(); token != "" {
+		options["auth_token"] = token
+	}
+
+	connect, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\n", connect); err != nil {
+		return errors.Wrap(err, "revocation: unable to connect to nats")
+	}
+
+	if err := natsPing(conn, reader); err != nil {
+		return errors.Wrap(err, "revocation: unable to connect to nats")
+	}
+
+	return conn.SetDeadline(time.Time{})
+}
+
+// natsPing pings the server and waits for its pong, which follows the
+// errors of the previous commands.
+func natsPing(conn net.Conn, reader *bufio.Reader) error {
+	if _, err := io.WriteString(conn, "PING\r\n"); err != nil {
+		return err
+	}
+
+	for {
+		line, err := readNATSLine(reader)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case line == "PONG":
+			return nil
+
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.Errorf("nats error %s", strings.TrimSpace(line[4:]))
+		}
+	}
+}
+
+// readNATSLine reads a line of the protocol, without its CRLF.
+func readNATSLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
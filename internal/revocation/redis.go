@@ -0,0 +1,70 @@
+package revocation
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/supabase/auth/internal/redis"
+)
+
+// RedisBus publishes the messages to a Redis channel. Each message is
+// published on a new connection, as revocations are rare.
+type RedisBus struct {
+	url     *url.URL
+	channel string
+}
+
+func (b *RedisBus) Publish(ctx context.Context, message []byte) error {
+	conn, err := redis.Dial(ctx, b.url, busTimeout)
+	if err != nil {
+		return errors.Wrap(err, "revocation: unable to connect to redis")
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(busTimeout)); err != nil {
+		return err
+	}
+
+	if _, err := conn.Do("PUBLISH", b.channel, string(message)); err != nil {
+		return errors.Wrap(err, "revocation: unable to publish to redis")
+	}
+
+	return nil
+}
+
+func (b *RedisBus) Subscribe(ctx context.Context, handle func(message []byte)) error {
+	conn, err := redis.Dial(ctx, b.url, busTimeout)
+	if err != nil {
+		return errors.Wrap(err, "revocation: unable to connect to redis")
+	}
+	defer conn.Close()
+	defer closeOnDone(ctx, conn)()
+
+	if err := conn.Send("SUBSCRIBE", b.channel); err != nil {
+		return errors.Wrap(err, "revocation: unable to subscribe to redis")
+	}
+
+	for {
+		reply, err := conn.Receive()
+		if err != nil {
+			return errors.Wrap(err, "revocation: unable to read from redis")
+		}
+
+		// pushed messages are arrays of message, the channel and the
+		// message
+		parts, ok := reply.([]interface{})
+		if !ok || len(parts) != 3 {
+			continue
+		}
+
+		if kind, _ := parts[0].(string); kind != "message" {
+			continue
+		}
+
+		if message, ok := parts[2].(string); ok {
+			handle([]byte(message))
+		}
+	}
+}
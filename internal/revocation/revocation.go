@@ -0,0 +1,211 @@
+// Package revocation propagates the revocations of sessions and users
+// between the instances, over Redis or NATS pub/sub, so that each of them
+// denies the access tokens of the revoked sessions and users right away,
+// instead of when they expire.
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/supabase/auth/internal/conf"
+)
+
+// Revocation is a revoked session of a user, or all of the sessions of the
+// user but the one kept, like when it's banned or signs out everywhere.
+type Revocation struct {
+	UserID    uuid.UUID  `json:"user_id"`
+	SessionID *uuid.UUID `json:"session_id,omitempty"`
+
+	// KeptSessionID is the session of the user that isn't revoked with
+	// the others, like when the user signs out of its other sessions.
+	KeptSessionID *uuid.UUID `json:"kept_session_id,omitempty"`
+
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+// SessionRevocation is the revocation of the session of the user.
+func SessionRevocation(userID, sessionID uuid.UUID) Revocation {
+	return Revocation{
+		UserID:    userID,
+		SessionID: &sessionID,
+		RevokedAt: time.Now(),
+	}
+}
+
+// UserRevocation is the revocation of the sessions of the user, but the
+// kept one when there's one.
+func UserRevocation(userID uuid.UUID, keptSessionID *uuid.UUID) Revocation {
+	return Revocation{
+		UserID:        userID,
+		KeptSessionID: keptSessionID,
+		RevokedAt:     time.Now(),
+	}
+}
+
+type userRevocation struct {
+	revokedAt     time.Time
+	keptSessionID *uuid.UUID
+}
+
+// DenyList keeps the revocations until the access tokens they deny have
+// expired.
+type DenyList struct {
+	ttl time.Duration
+
+	mutex    sync.RWMutex
+	sessions map[uuid.UUID]time.Time
+	users    map[uuid.UUID]userRevocation
+}
+
+// NewDenyList creates a deny list of the revocations of access tokens
+// that are valid for the ttl.
+func NewDenyList(ttl time.Duration) *DenyList {
+	return &DenyList{
+		ttl:      ttl,
+		sessions: make(map[uuid.UUID]time.Time),
+		users:    make(map[uuid.UUID]userRevocation),
+	}
+}
+
+// Add adds the revocation, and removes the ones that expired.
+func (d *DenyList) Add(revocation Revocation) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	expiredBefore := time.Now().Add(-d.ttl)
+	for id, revokedAt := range d.sessions {
+		if revokedAt.Before(expiredBefore) {
+			delete(d.sessions, id)
+		}
+	}
+	for id, user := range d.users {
+		if user.revokedAt.Before(expiredBefore) {
+			delete(d.users, id)
+		}
+	}
+
+	if revocation.SessionID != nil {
+		d.sessions[*revocation.SessionID] = revocation.RevokedAt
+		return
+	}
+
+	if previous, ok := d.users[revocation.UserID]; ok && previous.revokedAt.After(revocation.RevokedAt) {
+		return
+	}
+
+	d.users[revocation.UserID] = userRevocation{
+		revokedAt:     revocation.RevokedAt,
+		keptSessionID: revocation.KeptSessionID,
+	}
+}
+
+// IsDenied reports whether the access token of the user and session,
+// issued at the time, is revoked. Access tokens are issued at whole
+// seconds, so the ones issued in the second of a revocation of the user
+// aren't denied, to keep the ones of its new sessions.
+func (d *DenyList) IsDenied(userID uuid.UUID, sessionID *uuid.UUID, issuedAt time.Time) bool {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	if sessionID != nil {
+		if _, ok := d.sessions[*sessionID]; ok {
+			return true
+		}
+	}
+
+	user, ok := d.users[userID]
+	if !ok || !issuedAt.Before(user.revokedAt.Truncate(time.Second)) {
+		return false
+	}
+
+	return user.keptSessionID == nil || sessionID == nil || *user.keptSessionID != *sessionID
+}
+
+// Propagator publishes the revocations of the instance to the others, and
+// keeps the ones of all of them in its deny list.
+type Propagator struct {
+	bus      Bus
+	denyList *DenyList
+}
+
+// NewPropagator creates a propagator of the revocations of access tokens
+// that are valid for the ttl.
+func NewPropagator(config *conf.RevocationsConfiguration, ttl time.Duration) (*Propagator, error) {
+	bus, err := NewBus(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Propagator{
+		bus:      bus,
+		denyList: NewDenyList(ttl),
+	}, nil
+}
+
+// Revoke denies the access tokens of the revocation on this instance and
+// publishes it to the others.
+func (p *Propagator) Revoke(ctx context.Context, revocation Revocation) error {
+	p.denyList.Add(revocation)
+
+	message, err := json.Marshal(revocation)
+	if err != nil {
+		return err
+	}
+
+	return p.bus.Publish(ctx, message)
+}
+
+// IsDenied reports whether the access token of the user and session,
+// issued at the time, is revoked.
+func (p *Propagator) IsDenied(userID uuid.UUID, sessionID *uuid.UUID, issuedAt time.Time) bool {
+	return p.denyList.IsDenied(userID, sessionID, issuedAt)
+}
+
+const (
+	minResubscribeDelay = time.Second
+	maxResubscribeDelay = 30 * time.Second
+)
+
+// Run receives the revocations of the other instances until the context
+// is done, subscribing again when the connection is lost.
+func (p *Propagator) Run(ctx context.Context) {
+	log := logrus.WithField("component", "revocation")
+	delay := minResubscribeDelay
+
+	for {
+		started := time.Now()
+		err := p.bus.Subscribe(ctx, func(message []byte) {
+			var revocation Revocation
+			if err := json.Unmarshal(message, &revocation); err != nil {
+				log.WithError(err).Warn("Ignoring invalid revocation")
+				return
+			}
+
+			p.denyList.Add(revocation)
+		})
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		// the delay starts over once connections stayed up for a while
+		if time.Since(started) > maxResubscribeDelay {
+			delay = minResubscribeDelay
+		}
+
+		log.WithError(err).Warnf("Revocations subscription lost, subscribing again in %v", delay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay = min(delay*2, maxResubscribeDelay)
+	}
+}
@@ -0,0 +1,197 @@
+package revocation
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/supabase/auth/internal/redis"
+)
+
+func TestDenyList(t *testing.T) {
+	denyList := NewDenyList(time.Hour)
+
+	userID := uuid.Must(uuid.NewV4())
+	sessionID := uuid.Must(uuid.NewV4())
+	otherSessionID := uuid.Must(uuid.NewV4())
+	before := time.Now().Add(-time.Minute)
+
+	require.False(t, denyList.IsDenied(userID, &sessionID, before))
+
+	denyList.Add(SessionRevocation(userID, sessionID))
+	require.True(t, denyList.IsDenied(userID, &sessionID, before))
+	require.False(t, denyList.IsDenied(userID, &otherSessionID, before))
+
+	// signing out of the other sessions keeps the current one, and the
+	// sessions started after
+	denyList.Add(UserRevocation(userID, &sessionID))
+	require.True(t, denyList.IsDenied(userID, &otherSessionID, before))
+	require.False(t, denyList.IsDenied(userID, &otherSessionID, time.Now().Add(time.Second)))
+
+	keptUserID := uuid.Must(uuid.NewV4())
+	denyList.Add(UserRevocation(keptUserID, &otherSessionID))
+	require.False(t, denyList.IsDenied(keptUserID, &otherSessionID, before))
+
+	// revocations are forgotten once the access tokens they deny expired
+	expired := NewDenyList(time.Minute)
+	old := UserRevocation(userID, nil)
+	old.RevokedAt = time.Now().Add(-2 * time.Minute)
+	expired.Add(old)
+	expired.Add(SessionRevocation(keptUserID, sessionID))
+	require.False(t, expired.IsDenied(userID, nil, before.Add(-time.Hour)))
+}
+
+// fakeServer serves each connection to the listener with the function.
+func fakeServer(t *testing.T, serve func(conn net.Conn, reader *bufio.Reader)) *url.URL {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				serve(conn, bufio.NewReader(conn))
+			}()
+		}
+	}()
+
+	return &url.URL{Host: listener.Addr().String()}
+}
+
+func TestRedisBus(t *testing.T) {
+	published := make(chan string, 1)
+	subscribed := make(chan net.Conn, 1)
+
+	u := fakeServer(t, func(conn net.Conn, reader *bufio.Reader) {
+		for {
+			reply, err := redis.ReadReply(reader)
+			if err != nil {
+				return
+			}
+
+			command := reply.([]interface{})
+			switch command[0] {
+			case "AUTH":
+				if command[1] != "secret" {
+					io.WriteString(conn, "-WRONGPASS invalid password\r\n")
+					continue
+				}
+				io.WriteString(conn, "+OK\r\n")
+
+			case "PUBLISH":
+				published <- command[2].(string)
+				io.WriteString(conn, ":1\r\n")
+
+			case "SUBSCRIBE":
+				fmt.Fprintf(conn, "*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:1\r\n", len(command[1].(string)), command[1])
+				subscribed <- conn
+			}
+		}
+	})
+	u.Scheme = "redis"
+	u.User = url.UserPassword("", "secret")
+
+	bus := &RedisBus{url: u, channel: "gotrue.revocations"}
+
+	require.NoError(t, bus.Publish(context.Background(), []byte(`{"user_id":"1"}`)))
+	require.Equal(t, `{"user_id":"1"}`, <-published)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	received := make(chan string, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- bus.Subscribe(ctx, func(message []byte) {
+			received <- string(message)
+		})
+	}()
+
+	conn := <-subscribed
+	fmt.Fprintf(conn, "*3\r\n$7\r\nmessage\r\n$18\r\ngotrue.revocations\r\n$15\r\n{\"user_id\":\"2\"}\r\n")
+	require.Equal(t, `{"user_id":"2"}`, <-received)
+
+	cancel()
+	require.Error(t, <-done)
+
+	u.User = url.UserPassword("", "wrong")
+	require.Error(t, bus.Publish(context.Background(), []byte(`{}`)))
+}
+
+func TestNATSBus(t *testing.T) {
+	published := make(chan string, 1)
+	subscribed := make(chan net.Conn, 1)
+
+	u := fakeServer(t, func(conn net.Conn, reader *bufio.Reader) {
+		io.WriteString(conn, "INFO {\"server_id\":\"test\"}\r\n")
+
+		for {
+			line, err := readNATSLine(reader)
+			if err != nil {
+				return
+			}
+
+			switch {
+			case strings.HasPrefix(line, "CONNECT "):
+				if !strings.Contains(line, `"auth_token":"secret"`) {
+					io.WriteString(conn, "-ERR 'Authorization Violation'\r\n")
+					return
+				}
+
+			case line == "PING":
+				io.WriteString(conn, "PONG\r\n")
+
+			case strings.HasPrefix(line, "PUB "):
+				fields := strings.Fields(line)
+				size, _ := strconv.Atoi(fields[2])
+				payload := make([]byte, size+2)
+				io.ReadFull(reader, payload)
+				published <- string(payload[:size])
+
+			case strings.HasPrefix(line, "SUB "):
+				subscribed <- conn
+			}
+		}
+	})
+	u.Scheme = "nats"
+	u.User = url.User("secret")
+
+	bus := &NATSBus{url: u, subject: "gotrue.revocations"}
+
+	require.NoError(t, bus.Publish(context.Background(), []byte(`{"user_id":"1"}`)))
+	require.Equal(t, `{"user_id":"1"}`, <-published)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	received := make(chan string, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- bus.Subscribe(ctx, func(message []byte) {
+			received <- string(message)
+		})
+	}()
+
+	conn := <-subscribed
+	io.WriteString(conn, "PING\r\nMSG gotrue.revocations 1 15\r\n{\"user_id\":\"2\"}\r\n")
+	require.Equal(t, `{"user_id":"2"}`, <-received)
+
+	cancel()
+	require.Error(t, <-done)
+
+	u.User = url.User("wrong")
+	require.Error(t, bus.Publish(context.Background(), []byte(`{}`)))
+}
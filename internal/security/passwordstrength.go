@@ -0,0 +1,675 @@
+package security
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The guesses above which passwords get each score, from zxcvbn.
+var passwordScoreThresholds = []float64{1e3, 1e6, 1e8, 1e10}
+
+const (
+	// bruteforceCardinality is the guesses per character of the parts of
+	// passwords that match no pattern.
+	bruteforceCardinality = 10
+
+	// minGuessesBeforeGrowingSequence penalizes splitting passwords into
+	// many patterns, as attackers try fewer patterns first.
+	minGuessesBeforeGrowingSequence = 10000
+
+	// minYearSpace is the least guesses of a year, however recent.
+	minYearSpace = 20
+)
+
+// keyboardRows are the rows of keys walked to make patterns like qwerty.
+var keyboardRows = []string{
+	"`1234567890-=",
+	"qwertyuiop[]\\",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+	"~!@#$%^&*()_+",
+}
+
+// l33tSubstitutions are the characters commonly used in place of letters.
+// Characters standing for more than one letter are tried for each.
+var l33tSubstitutions = map[rune][]rune{
+	'4': {'a'},
+	'@': {'a'},
+	'8': {'b'},
+	'(': {'c'},
+	'{': {'c'},
+	'[': {'c'},
+	'<': {'c'},
+	'3': {'e'},
+	'6': {'g'},
+	'9': {'g'},
+	'1': {'i', 'l'},
+	'!': {'i'},
+	'|': {'i', 'l'},
+	'0': {'o'},
+	'$': {'s'},
+	'5': {'s'},
+	'+': {'t'},
+	'7': {'t'},
+	'%': {'x'},
+	'2': {'z'},
+}
+
+var commonPasswordRanks = func() map[string]int {
+	ranks := make(map[string]int, len(commonPasswords))
+	for i, word := range commonPasswords {
+		if _, ok := ranks[word]; !ok {
+			ranks[word] = i + 1
+		}
+	}
+	return ranks
+}()
+
+// PasswordFeedback tells users why their password is weak, and how to make
+// it stronger.
+type PasswordFeedback struct {
+	Warning     string   `json:"warning,omitempty"`
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// PasswordStrength is the strength of a password estimated in the way of
+// zxcvbn, from the guesses an attacker trying common passwords and patterns
+// first would need to find it.
+type PasswordStrength struct {
+	Guesses float64
+
+	// Score is from 0, too guessable, to 4, very unguessable.
+	Score int
+
+	// Feedback is empty for passwords with a score of 3 or more.
+	Feedback PasswordFeedback
+}
+
+type passwordPattern int
+
+const (
+	bruteforcePattern passwordPattern = iota
+	dictionaryPattern
+	userInputPattern
+	sequencePattern
+	keyboardPattern
+	repeatPattern
+	yearPattern
+)
+
+// passwordMatch is a part of a password, from i to j inclusive, matching a
+// pattern.
+type passwordMatch struct {
+	pattern passwordPattern
+	i, j    int
+	token   string
+	guesses float64
+
+	// rank is the rank of dictionary words.
+	rank int
+	l33t bool
+
+	// base is the token repeated by repeats.
+	base string
+}
+
+// EstimatePasswordStrength estimates the strength of the password. The user
+// inputs, like the email or name of the user, are the first words attackers
+// would try.
+func EstimatePasswordStrength(password string, userInputs []string) PasswordStrength {
+	inputRanks := make(map[string]int, len(userInputs))
+	for _, input := range userInputs {
+		input = strings.ToLower(strings.TrimSpace(input))
+		if len([]rune(input)) < 3 {
+			continue
+		}
+		if _, ok := inputRanks[input]; !ok {
+			inputRanks[input] = len(inputRanks) + 1
+		}
+	}
+
+	guesses, sequence := mostGuessableMatchSequence(password, inputRanks)
+
+	strength := PasswordStrength{
+		Guesses: guesses,
+	}
+	for _, threshold := range passwordScoreThresholds {
+		if guesses >= threshold {
+			strength.Score++
+		}
+	}
+	strength.Feedback = passwordFeedback(strength.Score, sequence)
+
+	return strength
+}
+
+// mostGuessableMatchSequence splits the password into the sequence of
+// matches needing the least guesses to find it, like zxcvbn does, and
+// returns the guesses with the sequence.
+func mostGuessableMatchSequence(password string, inputRanks map[string]int) (float64, []*passwordMatch) {
+	runes := []rune(password)
+	n := len(runes)
+	if n == 0 {
+		return 1, nil
+	}
+
+	matchesByEnd := make([][]*passwordMatch, n)
+	for _, m := range omnimatch(runes, inputRanks) {
+		m.guesses = math.Max(m.guesses, minSubmatchGuesses(m, n))
+		matchesByEnd[m.j] = append(matchesByEnd[m.j], m)
+	}
+
+	// optimal[k][l] is the best sequence of l matches covering the
+	// password up to k
+	type step struct {
+		match *passwordMatch
+		pi    float64
+		g     float64
+		prev  int
+	}
+	optimal := make([]map[int]*step, n)
+	for k := range optimal {
+		optimal[k] = make(map[int]*step)
+	}
+
+	update := func(m *passwordMatch, l int) {
+		k := m.j
+		pi := m.guesses
+		if l > 1 {
+			pi *= optimal[m.i-1][l-1].pi
+		}
+		g := factorial(l)*pi + math.Pow(minGuessesBeforeGrowingSequence, float64(l-1))
+
+		for competingL, competing := range optimal[k] {
+			if competingL <= l && competing.g <= g {
+				return
+			}
+		}
+		optimal[k][l] = &step{match: m, pi: pi, g: g, prev: l - 1}
+	}
+
+	for k := 0; k < n; k++ {
+		for _, m := range matchesByEnd[k] {
+			if m.i > 0 {
+				for l := range optimal[m.i-1] {
+					update(m, l+1)
+				}
+			} else {
+				update(m, 1)
+			}
+		}
+
+		// the parts matching no pattern are brute forced, but never
+		// following another brute forced part as they'd be one
+		for i := 0; i <= k; i++ {
+			m := bruteforceMatch(runes, i, k)
+			if i == 0 {
+				update(m, 1)
+				continue
+			}
+			for l, previous := range optimal[i-1] {
+				if previous.match.pattern != bruteforcePattern {
+					update(m, l+1)
+				}
+			}
+		}
+	}
+
+	var best *step
+	bestL := 0
+	for l, candidate := range optimal[n-1] {
+		if best == nil || candidate.g < best.g {
+			best, bestL = candidate, l
+		}
+	}
+
+	sequence := make([]*passwordMatch, bestL)
+	k := n - 1
+	for l := bestL; l > 0; l-- {
+		s := optimal[k][l]
+		sequence[l-1] = s.match
+		k = s.match.i - 1
+	}
+
+	return best.g, sequence
+}
+
+// minSubmatchGuesses is the least guesses of a match that's only a part of
+// the password, as attackers don't know where the patterns are.
+func minSubmatchGuesses(m *passwordMatch, n int) float64 {
+	length := m.j - m.i + 1
+	switch {
+	case length == n:
+		return 1
+	case length == 1:
+		return 10
+	default:
+		return 50
+	}
+}
+
+func factorial(n int) float64 {
+	f := 1.0
+	for i := 2; i <= n; i++ {
+		f *= float64(i)
+	}
+	return f
+}
+
+func bruteforceMatch(runes []rune, i, j int) *passwordMatch {
+	length := j - i + 1
+	guesses := math.Pow(bruteforceCardinality, float64(length))
+	if length == 1 {
+		guesses = math.Max(guesses, 11)
+	} else {
+		guesses = math.Max(guesses, 51)
+	}
+
+	return &passwordMatch{
+		pattern: bruteforcePattern,
+		i:       i,
+		j:       j,
+		token:   string(runes[i : j+1]),
+		guesses: guesses,
+	}
+}
+
+// omnimatch returns all the matches of patterns in the password.
+func omnimatch(runes []rune, inputRanks map[string]int) []*passwordMatch {
+	var matches []*passwordMatch
+	matches = append(matches, dictionaryMatches(runes, inputRanks)...)
+	matches = append(matches, sequenceMatches(runes)...)
+	matches = append(matches, keyboardMatches(runes)...)
+	matches = append(matches, repeatMatches(runes, inputRanks)...)
+	matches = append(matches, yearMatches(runes)...)
+	return matches
+}
+
+// dictionaryMatches matches the common passwords and the user inputs, in
+// any case and with l33t substitutions.
+func dictionaryMatches(runes []rune, inputRanks map[string]int) []*passwordMatch {
+	lower := []rune(strings.ToLower(string(runes)))
+
+	var matches []*passwordMatch
+	for _, variant := range unl33tVariants(lower) {
+		for i := 0; i < len(variant); i++ {
+			for j := i + 2; j < len(variant); j++ {
+				word := string(variant[i : j+1])
+
+				pattern := userInputPattern
+				rank, ok := inputRanks[word]
+				if !ok {
+					pattern = dictionaryPattern
+					if rank, ok = commonPasswordRanks[word]; !ok {
+						continue
+					}
+				}
+
+				token := string(runes[i : j+1])
+				l33t := word != string(lower[i:j+1])
+				guesses := float64(rank) * uppercaseVariations(token)
+				if l33t {
+					guesses *= l33tVariations(lower[i:j+1], variant[i:j+1])
+				}
+
+				matches = append(matches, &passwordMatch{
+					pattern: pattern,
+					i:       i,
+					j:       j,
+					token:   token,
+					guesses: guesses,
+					rank:    rank,
+					l33t:    l33t,
+				})
+			}
+		}
+	}
+
+	return matches
+}
+
+// unl33tVariants returns the password as is, and with the l33t
+// substitutions it has replaced by the letters they stand for.
+func unl33tVariants(lower []rune) [][]rune {
+	variants := [][]rune{lower}
+
+	hasSubstitution := false
+	for _, r := range lower {
+		if _, ok := l33tSubstitutions[r]; ok {
+			hasSubstitution = true
+			break
+		}
+	}
+	if !hasSubstitution {
+		return variants
+	}
+
+	// characters standing for two letters give two variants, replaced by
+	// either letter throughout
+	for choice := 0; choice < 2; choice++ {
+		variant := make([]rune, len(lower))
+		for i, r := range lower {
+			variant[i] = r
+			if letters, ok := l33tSubstitutions[r]; ok {
+				variant[i] = letters[min(choice, len(letters)-1)]
+			}
+		}
+		variants = append(variants, variant)
+	}
+
+	return variants
+}
+
+// uppercaseVariations is how many ways the word can be capitalized like the
+// token is, as attackers try the common ones first.
+func uppercaseVariations(token string) float64 {
+	upper, lower := 0, 0
+	for _, r := range token {
+		if unicode.IsUpper(r) {
+			upper++
+		} else if unicode.IsLower(r) {
+			lower++
+		}
+	}
+
+	if upper == 0 {
+		return 1
+	}
+
+	runes := []rune(token)
+	if lower == 0 || (upper == 1 && (unicode.IsUpper(runes[0]) || unicode.IsUpper(runes[len(runes)-1]))) {
+		return 2
+	}
+
+	variations := 0.0
+	for i := 1; i <= min(upper, lower); i++ {
+		variations += binomial(upper+lower, i)
+	}
+	return variations
+}
+
+// l33tVariations is how many ways the word can have the l33t substitutions
+// of the token.
+func l33tVariations(token, word []rune) float64 {
+	substituted, unsubstituted := 0, 0
+	for i := range token {
+		if token[i] != word[i] {
+			substituted++
+		} else if _, ok := l33tSubstitutions[token[i]]; ok || strings.ContainsRune("abceghilostxz", word[i]) {
+			unsubstituted++
+		}
+	}
+
+	if substituted == 0 || unsubstituted == 0 {
+		return 2
+	}
+
+	variations := 0.0
+	for i := 1; i <= min(substituted, unsubstituted); i++ {
+		variations += binomial(substituted+unsubstituted, i)
+	}
+	return variations
+}
+
+func binomial(n, k int) float64 {
+	if k > n {
+		return 0
+	}
+	r := 1.0
+	for d := 1; d <= k; d++ {
+		r *= float64(n)
+		r /= float64(d)
+		n--
+	}
+	return r
+}
+
+// sequenceMatches matches runs of consecutive letters or digits, like abc
+// or 9876.
+func sequenceMatches(runes []rune) []*passwordMatch {
+	var matches []*passwordMatch
+
+	for i := 0; i < len(runes)-2; {
+		delta := runes[i+1] - runes[i]
+		if (delta != 1 && delta != -1) || !sameCharacterClass(runes[i], runes[i+1]) {
+			i++
+			continue
+		}
+
+		j := i + 1
+		for j+1 < len(runes) && runes[j+1]-runes[j] == delta && sameCharacterClass(runes[j], runes[j+1]) {
+			j++
+		}
+
+		if j-i+1 >= 3 {
+			first := runes[i]
+			base := 26.0
+			switch {
+			case strings.ContainsRune("aAzZ019", first):
+				base = 4
+			case unicode.IsDigit(first):
+				base = 10
+			}
+			if delta < 0 {
+				base *= 2
+			}
+
+			matches = append(matches, &passwordMatch{
+				pattern: sequencePattern,
+				i:       i,
+				j:       j,
+				token:   string(runes[i : j+1]),
+				guesses: base * float64(j-i+1),
+			})
+		}
+
+		i = j
+	}
+
+	return matches
+}
+
+func sameCharacterClass(a, b rune) bool {
+	switch {
+	case unicode.IsDigit(a):
+		return unicode.IsDigit(b)
+	case unicode.IsLower(a):
+		return unicode.IsLower(b)
+	case unicode.IsUpper(a):
+		return unicode.IsUpper(b)
+	}
+	return false
+}
+
+// keyboardMatches matches walks along the rows of the keyboard, like
+// qwerty or poiu.
+func keyboardMatches(runes []rune) []*passwordMatch {
+	lower := []rune(strings.ToLower(string(runes)))
+
+	var matches []*passwordMatch
+	for _, row := range keyboardRows {
+		keys := []rune(row)
+		position := make(map[rune]int, len(keys))
+		for p, key := range keys {
+			position[key] = p
+		}
+
+		for i := 0; i < len(lower)-2; {
+			p, ok := position[lower[i]]
+			q, okNext := position[lower[i+1]]
+			direction := q - p
+			if !ok || !okNext || (direction != 1 && direction != -1) {
+				i++
+				continue
+			}
+
+			j := i + 1
+			for j+1 < len(lower) {
+				next, ok := position[lower[j+1]]
+				if !ok || next-position[lower[j]] != direction {
+					break
+				}
+				j++
+			}
+
+			if j-i+1 >= 3 {
+				length := float64(j - i + 1)
+				matches = append(matches, &passwordMatch{
+					pattern: keyboardPattern,
+					i:       i,
+					j:       j,
+					token:   string(runes[i : j+1]),
+					guesses: float64(len(keys)) * length * length * uppercaseVariations(string(runes[i:j+1])),
+				})
+			}
+
+			i = j
+		}
+	}
+
+	return matches
+}
+
+// repeatMatches matches tokens repeated back to back, like aaa or abcabc.
+// Repeats are guessed as their token, times the number of repeats.
+func repeatMatches(runes []rune, inputRanks map[string]int) []*passwordMatch {
+	var matches []*passwordMatch
+
+	for i := 0; i < len(runes)-1; {
+		var best *passwordMatch
+		for size := 1; i+2*size <= len(runes); size++ {
+			base := runes[i : i+size]
+			count := 1
+			for i+(count+1)*size <= len(runes) && string(runes[i+count*size:i+(count+1)*size]) == string(base) {
+				count++
+			}
+			if count < 2 {
+				continue
+			}
+
+			j := i + count*size - 1
+			if best == nil || j-i > best.j-best.i {
+				baseGuesses, _ := mostGuessableMatchSequence(string(base), inputRanks)
+				best = &passwordMatch{
+					pattern: repeatPattern,
+					i:       i,
+					j:       j,
+					token:   string(runes[i : j+1]),
+					guesses: baseGuesses * float64(count),
+					base:    string(base),
+				}
+			}
+		}
+
+		if best == nil {
+			i++
+			continue
+		}
+
+		matches = append(matches, best)
+		i = best.j + 1
+	}
+
+	return matches
+}
+
+// yearMatches matches recent years, which attackers try first.
+func yearMatches(runes []rune) []*passwordMatch {
+	referenceYear := time.Now().Year()
+
+	var matches []*passwordMatch
+	for i := 0; i+4 <= len(runes); i++ {
+		token := string(runes[i : i+4])
+		year, err := strconv.Atoi(token)
+		if err != nil || year < 1900 || year > 2099 {
+			continue
+		}
+
+		space := math.Max(math.Abs(float64(year-referenceYear)), minYearSpace)
+		matches = append(matches, &passwordMatch{
+			pattern: yearPattern,
+			i:       i,
+			j:       i + 3,
+			token:   token,
+			guesses: space,
+		})
+	}
+
+	return matches
+}
+
+// passwordFeedback explains the weakness of the password from the longest
+// pattern it matches.
+func passwordFeedback(score int, sequence []*passwordMatch) PasswordFeedback {
+	if score >= 3 {
+		return PasswordFeedback{}
+	}
+
+	feedback := PasswordFeedback{
+		Suggestions: []string{"Add another word or two. Uncommon words are better."},
+	}
+
+	var longest *passwordMatch
+	for _, m := range sequence {
+		if m.pattern != bruteforcePattern && (longest == nil || m.j-m.i > longest.j-longest.i) {
+			longest = m
+		}
+	}
+	if longest == nil {
+		if len(sequence) == 0 {
+			feedback.Suggestions = []string{"Use a few words, avoid common phrases.", "No need for symbols, digits, or uppercase letters."}
+		}
+		return feedback
+	}
+
+	switch longest.pattern {
+	case dictionaryPattern:
+		switch {
+		case len(sequence) == 1 && longest.rank <= 10:
+			feedback.Warning = "This is a top-10 common password."
+		case len(sequence) == 1 && longest.rank <= 100:
+			feedback.Warning = "This is a top-100 common password."
+		case len(sequence) == 1:
+			feedback.Warning = "This is a very common password."
+		default:
+			feedback.Warning = "This is similar to a commonly used password."
+		}
+
+	case userInputPattern:
+		feedback.Warning = "Passwords containing your email, name or username are easy to guess."
+
+	case sequencePattern:
+		feedback.Warning = "Sequences like abc or 6543 are easy to guess."
+		feedback.Suggestions = append(feedback.Suggestions, "Avoid sequences.")
+
+	case keyboardPattern:
+		feedback.Warning = "Straight rows of keys are easy to guess."
+		feedback.Suggestions = append(feedback.Suggestions, "Use a longer keyboard pattern with more turns.")
+
+	case repeatPattern:
+		if len([]rune(longest.base)) == 1 {
+			feedback.Warning = "Repeats like \"aaa\" are easy to guess."
+		} else {
+			feedback.Warning = "Repeats like \"abcabcabc\" are only slightly harder to guess than \"abc\"."
+		}
+		feedback.Suggestions = append(feedback.Suggestions, "Avoid repeated words and characters.")
+
+	case yearPattern:
+		feedback.Warning = "Recent years are easy to guess."
+		feedback.Suggestions = append(feedback.Suggestions, "Avoid recent years, and years that are associated with you.")
+	}
+
+	if longest.pattern == dictionaryPattern || longest.pattern == userInputPattern {
+		if uppercaseVariations(longest.token) > 1 {
+			feedback.Suggestions = append(feedback.Suggestions, "Capitalization doesn't help very much.")
+		}
+		if longest.l33t {
+			feedback.Suggestions = append(feedback.Suggestions, "Predictable substitutions like '@' instead of 'a' don't help very much.")
+		}
+	}
+
+	return feedback
+}
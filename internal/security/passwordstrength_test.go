@@ -0,0 +1,52 @@
+package security
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimatePasswordStrength(t *testing.T) {
+	examples := []struct {
+		Password   string
+		UserInputs []string
+		Score      int
+	}{
+		{Password: "", Score: 0},
+		{Password: "password", Score: 0},
+		{Password: "P@ssw0rd", Score: 0},
+		{Password: "qwerty123", Score: 0},
+		{Password: "abcdefgh", Score: 0},
+		{Password: "aaaaaaaaaa", Score: 0},
+		{Password: "abcabcabcabc", Score: 0},
+		{Password: "2024", Score: 0},
+		{Password: "zxcvbnm123", Score: 1},
+		{Password: "janedoe", UserInputs: []string{"jane", "doe"}, Score: 1},
+		{Password: "janedoe", Score: 2},
+		{Password: "Tr0ub4dor&3", Score: 4},
+		{Password: "correcthorsebatterystaple", Score: 4},
+		{Password: "x7#kQ9!vLm2$", Score: 4},
+	}
+
+	for _, example := range examples {
+		strength := EstimatePasswordStrength(example.Password, example.UserInputs)
+		require.Equal(t, example.Score, strength.Score, "Password %q has the wrong score", example.Password)
+
+		if strength.Score >= 3 {
+			require.Empty(t, strength.Feedback.Warning, "Password %q has feedback", example.Password)
+			require.Empty(t, strength.Feedback.Suggestions, "Password %q has feedback", example.Password)
+		} else {
+			require.NotEmpty(t, strength.Feedback.Suggestions, "Password %q has no suggestions", example.Password)
+		}
+	}
+}
+
+func TestEstimatePasswordStrengthFeedback(t *testing.T) {
+	strength := EstimatePasswordStrength("P@ssw0rd", nil)
+	require.Equal(t, "This is a top-10 common password.", strength.Feedback.Warning)
+	require.Contains(t, strength.Feedback.Suggestions, "Capitalization doesn't help very much.")
+	require.Contains(t, strength.Feedback.Suggestions, "Predictable substitutions like '@' instead of 'a' don't help very much.")
+
+	strength = EstimatePasswordStrength("Jane.Doe", []string{"jane", "doe"})
+	require.Equal(t, "Passwords containing your email, name or username are easy to guess.", strength.Feedback.Warning)
+}
@@ -0,0 +1,132 @@
+package utilities
+
+import (
+	"encoding/csv"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// GeoLocation is where an IP address is, as precisely as the GeoIP
+// database tells.
+type GeoLocation struct {
+	Country string `json:"country,omitempty"`
+	Region  string `json:"region,omitempty"`
+	City    string `json:"city,omitempty"`
+}
+
+type geoIPRange struct {
+	start    netip.Addr
+	end      netip.Addr
+	location GeoLocation
+}
+
+// GeoIPCSVDatabase locates IP addresses with a local CSV file of IP ranges,
+// so no request is made to a GeoIP service.
+//
+// Each line of the file is a range of IPv4 or IPv6 addresses, with its
+// first and last address, the ISO country code and optionally the region
+// and the city, like `203.0.113.0,203.0.113.255,AU,Queensland,Brisbane`.
+// The IP to Country Lite CSV of DB-IP can be used as is.
+type GeoIPCSVDatabase struct {
+	Path string
+
+	mutex  sync.Mutex
+	ranges []geoIPRange
+}
+
+// Locate returns where the IP address is, or nil when the database doesn't
+// have it.
+func (d *GeoIPCSVDatabase) Locate(ip string) (*GeoLocation, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil, nil
+	}
+	addr = addr.Unmap()
+
+	ranges, err := d.loadRanges()
+	if err != nil {
+		return nil, err
+	}
+
+	// the first range ending at or after the address is the only one that
+	// can hold it, as the ranges don't overlap
+	i := sort.Search(len(ranges), func(i int) bool {
+		return ranges[i].end.Compare(addr) >= 0
+	})
+	if i == len(ranges) || ranges[i].start.Compare(addr) > 0 || ranges[i].start.BitLen() != addr.BitLen() {
+		return nil, nil
+	}
+
+	location := ranges[i].location
+	return &location, nil
+}
+
+// loadRanges loads the database the first time it's needed, and again
+// after it failed to load.
+func (d *GeoIPCSVDatabase) loadRanges() ([]geoIPRange, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.ranges != nil {
+		return d.ranges, nil
+	}
+
+	file, err := os.Open(d.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "geoip: unable to open the database")
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	ranges := []geoIPRange{}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "geoip: unable to read the database")
+		}
+
+		if len(record) < 3 {
+			return nil, errors.Errorf("geoip: line %d of the database has less than 3 fields", line)
+		}
+
+		start, serr := netip.ParseAddr(strings.TrimSpace(record[0]))
+		end, eerr := netip.ParseAddr(strings.TrimSpace(record[1]))
+		if serr != nil || eerr != nil || start.BitLen() != end.BitLen() || start.Compare(end) > 0 {
+			return nil, errors.Errorf("geoip: line %d of the database isn't a valid IP range", line)
+		}
+
+		r := geoIPRange{
+			start: start.Unmap(),
+			end:   end.Unmap(),
+			location: GeoLocation{
+				Country: strings.ToUpper(strings.TrimSpace(record[2])),
+			},
+		}
+		if len(record) > 3 {
+			r.location.Region = strings.TrimSpace(record[3])
+		}
+		if len(record) > 4 {
+			r.location.City = strings.TrimSpace(record[4])
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].start.Compare(ranges[j].start) < 0
+	})
+
+	d.ranges = ranges
+	return ranges, nil
+}
@@ -0,0 +1,43 @@
+package utilities
+
+import (
+	"os"
+	"path/filepath"
+	tst "testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeoIPCSVDatabase(t *tst.T) {
+	path := filepath.Join(t.TempDir(), "geoip.csv")
+	require.NoError(t, os.WriteFile(path, []byte(
+		"203.0.113.0,203.0.113.255,au,Queensland,Brisbane\n"+
+			"198.51.100.0,198.51.100.127,NZ\n"+
+			"2001:db8::,2001:db8::ffff,DE,Berlin\n",
+	), 0600))
+
+	database := &GeoIPCSVDatabase{Path: path}
+
+	examples := map[string]*GeoLocation{
+		"203.0.113.7":        {Country: "AU", Region: "Queensland", City: "Brisbane"},
+		"::ffff:203.0.113.7": {Country: "AU", Region: "Queensland", City: "Brisbane"},
+		"198.51.100.0":       {Country: "NZ"},
+		"198.51.100.127":     {Country: "NZ"},
+		"198.51.100.128":     nil,
+		"2001:db8::1":        {Country: "DE", Region: "Berlin"},
+		"2001:db8::1:0":      nil,
+		"192.0.2.1":          nil,
+		"not an ip address":  nil,
+		"":                   nil,
+	}
+
+	for ip, expected := range examples {
+		location, err := database.Locate(ip)
+		require.NoError(t, err, ip)
+		require.Equal(t, expected, location, ip)
+	}
+
+	missing := &GeoIPCSVDatabase{Path: filepath.Join(t.TempDir(), "missing.csv")}
+	_, err := missing.Locate("203.0.113.7")
+	require.Error(t, err)
+}
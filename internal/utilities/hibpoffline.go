@@ -0,0 +1,200 @@
+package utilities
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1" //#nosec G505 -- The Pwned Passwords list is of SHA1 hashes.
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bits-and-blooms/bloom/v3"
+	"github.com/pkg/errors"
+)
+
+// HIBPOfflineChecker checks passwords against local copies of the Pwned
+// Passwords list, so no request is made to HaveIBeenPwned.org.
+//
+// The bloom filter, when set, is of the upper case hex SHA1 hashes of the
+// breached passwords, as written by the hibp bloom command. It answers
+// alone when there's no ranges directory, with its false positive rate.
+//
+// The ranges directory, when set, holds a file for each hash prefix named
+// like 5BAA6.txt, with the lines of the range API responses, as downloaded
+// by the Pwned Passwords downloader. Passwords the bloom filter rules out
+// are never looked up in it.
+type HIBPOfflineChecker struct {
+	BloomFilter string
+	RangesDir   string
+
+	mutex  sync.Mutex
+	filter *bloom.BloomFilter
+}
+
+// Check reports whether the password is in the Pwned Passwords list.
+func (c *HIBPOfflineChecker) Check(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password)) //#nosec G401
+	hexsum := []byte(strings.ToUpper(hex.EncodeToString(sum[:])))
+
+	if c.BloomFilter != "" {
+		filter, err := c.loadFilter()
+		if err != nil {
+			return false, err
+		}
+
+		if !filter.Test(hexsum) {
+			return false, nil
+		}
+
+		if c.RangesDir == "" {
+			return true, nil
+		}
+	}
+
+	return c.lookupRange(hexsum[:hibpHashPrefixLength], hexsum[hibpHashPrefixLength:])
+}
+
+// loadFilter loads the bloom filter the first time it's needed, and again
+// after it failed to load.
+func (c *HIBPOfflineChecker) loadFilter() (*bloom.BloomFilter, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.filter != nil {
+		return c.filter, nil
+	}
+
+	file, err := os.Open(c.BloomFilter)
+	if err != nil {
+		return nil, errors.Wrap(err, "hibp: unable to open the bloom filter")
+	}
+	defer file.Close()
+
+	filter := &bloom.BloomFilter{}
+	if _, err := filter.ReadFrom(bufio.NewReader(file)); err != nil {
+		return nil, errors.Wrap(err, "hibp: unable to read the bloom filter")
+	}
+
+	c.filter = filter
+
+	return filter, nil
+}
+
+func (c *HIBPOfflineChecker) lookupRange(prefix, suffix []byte) (bool, error) {
+	file, err := os.Open(filepath.Join(c.RangesDir, string(prefix)+".txt"))
+	if err != nil {
+		return false, errors.Wrap(err, "hibp: unable to open the range file")
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineSuffix, count, found := bytes.Cut(bytes.TrimSpace(scanner.Bytes()), []byte(":"))
+		if !found || !bytes.EqualFold(lineSuffix, suffix) {
+			continue
+		}
+
+		// padded responses list hashes that were never breached with a
+		// count of 0
+		return len(bytes.TrimLeft(count, "0")) > 0, nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return false, errors.Wrap(err, "hibp: unable to read the range file")
+	}
+
+	return false, nil
+}
+
+// BuildHIBPBloomFilter builds the bloom filter of the hashes in the Pwned
+// Passwords list read from the source, either a file with a full hash per
+// line, or a ranges directory. The filter is sized for the number of hashes
+// and the false positive rate.
+func BuildHIBPBloomFilter(source string, falsePositives float64) (*bloom.BloomFilter, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	prefixed := false
+	if info.IsDir() {
+		if files, err = filepath.Glob(filepath.Join(source, "*.txt")); err != nil {
+			return nil, err
+		}
+		prefixed = true
+	} else {
+		files = []string{source}
+	}
+
+	// the list is read twice, to size the filter and then fill it, as it's
+	// too large to be held in memory
+	readAll := func(fn func(hash []byte)) error {
+		for _, name := range files {
+			prefix := ""
+			if prefixed {
+				prefix = strings.ToUpper(strings.TrimSuffix(filepath.Base(name), ".txt"))
+				if len(prefix) != hibpHashPrefixLength {
+					continue
+				}
+			}
+
+			if err := readHIBPHashes(name, prefix, fn); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	var count uint
+	if err := readAll(func(hash []byte) {
+		count++
+	}); err != nil {
+		return nil, err
+	}
+
+	if count == 0 {
+		return nil, fmt.Errorf("hibp: no hashes found in %q", source)
+	}
+
+	filter := bloom.NewWithEstimates(count, falsePositives)
+	if err := readAll(func(hash []byte) {
+		filter.Add(hash)
+	}); err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+// readHIBPHashes calls fn with the full hash of each breached password in
+// the file, prefixed with the prefix of its range.
+func readHIBPHashes(name, prefix string, fn func(hash []byte)) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.TrimLeft(count, "0") == "" && count != "" {
+			continue
+		}
+
+		hash := strings.ToUpper(prefix + line)
+		if len(hash) != hibpHashLength {
+			continue
+		}
+
+		fn([]byte(hash))
+	}
+
+	return scanner.Err()
+}
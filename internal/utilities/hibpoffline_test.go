@@ -0,0 +1,57 @@
+package utilities
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	tst "testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHIBPOfflineChecker(t *tst.T) {
+	ranges := t.TempDir()
+
+	// the SHA1 hash of password is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8,
+	// and of p@ssw0rd 57B2AD99044D337197C0C39FD3823568FF81E48A
+	require.NoError(t, os.WriteFile(filepath.Join(ranges, "5BAA6.txt"), []byte("003D68EB55068C33ACE09247EE4C639306B:3\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\r\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(ranges, "57B2A.txt"), []byte("D99044D337197C0C39FD3823568FF81E48A:0\r\n"), 0600))
+
+	filter, err := BuildHIBPBloomFilter(ranges, 0.000001)
+	require.NoError(t, err)
+
+	bloomFilter := filepath.Join(t.TempDir(), "pwned.bloom")
+	file, err := os.Create(bloomFilter)
+	require.NoError(t, err)
+	_, err = filter.WriteTo(file)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	checkers := []*HIBPOfflineChecker{
+		{RangesDir: ranges},
+		{BloomFilter: bloomFilter},
+		{BloomFilter: bloomFilter, RangesDir: ranges},
+	}
+
+	for i, checker := range checkers {
+		pwned, err := checker.Check(context.Background(), "password")
+		require.NoError(t, err, "Checker %d failed", i)
+		require.True(t, pwned, "Checker %d failed", i)
+
+		// padded entries with a count of 0 were never breached
+		pwned, err = checker.Check(context.Background(), "p@ssw0rd")
+		require.NoError(t, err, "Checker %d failed", i)
+		require.False(t, pwned, "Checker %d failed", i)
+	}
+
+	// passwords the bloom filter rules out don't need their range file
+	pwned, err := checkers[2].Check(context.Background(), "correct horse battery staple")
+	require.NoError(t, err)
+	require.False(t, pwned)
+
+	_, err = checkers[0].Check(context.Background(), "correct horse battery staple")
+	require.Error(t, err)
+
+	_, err = (&HIBPOfflineChecker{BloomFilter: filepath.Join(ranges, "missing.bloom")}).Check(context.Background(), "password")
+	require.Error(t, err)
+}
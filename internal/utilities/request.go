@@ -44,6 +44,24 @@ func GetIPAddress(r *http.Request) string {
 	return ip
 }
 
+// DeviceIDHeader is the header clients identify the device of the request
+// with, like an installation ID of a mobile app.
+const DeviceIDHeader = "X-Device-Id"
+
+// maxDeviceIDLength is the longest device identifier that's kept.
+const maxDeviceIDLength = 255
+
+// GetDeviceID returns the device identifier the client provided with the
+// HTTP request, or an empty string when there's none or it's too long.
+func GetDeviceID(r *http.Request) string {
+	deviceID := strings.TrimSpace(r.Header.Get(DeviceIDHeader))
+	if len(deviceID) > maxDeviceIDLength {
+		return ""
+	}
+
+	return deviceID
+}
+
 // GetBodyBytes reads the whole request body properly into a byte array.
 func GetBodyBytes(req *http.Request) ([]byte, error) {
 	if req.Body == nil || req.Body == http.NoBody {
@@ -3,6 +3,7 @@ package utilities
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	tst "testing"
 
 	"github.com/stretchr/testify/require"
@@ -88,6 +89,17 @@ func TestGetIPAddress(t *tst.T) {
 	}
 }
 
+func TestGetDeviceID(t *tst.T) {
+	r := httptest.NewRequest("GET", "http://localhost", nil)
+	require.Equal(t, "", GetDeviceID(r))
+
+	r.Header.Set(DeviceIDHeader, " 4f1c2a7e-ios ")
+	require.Equal(t, "4f1c2a7e-ios", GetDeviceID(r))
+
+	r.Header.Set(DeviceIDHeader, strings.Repeat("a", maxDeviceIDLength+1))
+	require.Equal(t, "", GetDeviceID(r))
+}
+
 func TestGetReferrer(t *tst.T) {
 	config := conf.GlobalConfiguration{
 		SiteURL:      "https://example.com",
@@ -0,0 +1,15 @@
+-- adds web3_nonces table for Sign-In with Ethereum
+
+do $$ begin
+  create table if not exists {{ index .Options "Namespace" }}.web3_nonces (
+    id uuid primary key,
+    nonce text not null,
+    created_at timestamptz not null default now(),
+    check (char_length(nonce) >= 8)
+  );
+
+  create unique index if not exists web3_nonces_nonce_key on {{ index .Options "Namespace" }}.web3_nonces (nonce);
+  create index if not exists web3_nonces_created_at_idx on {{ index .Options "Namespace" }}.web3_nonces (created_at desc);
+
+  comment on table {{ index .Options "Namespace" }}.web3_nonces is 'Auth: Stores nonces issued for Sign-In with Ethereum messages.';
+end $$;
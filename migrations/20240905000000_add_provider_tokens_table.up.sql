@@ -0,0 +1,20 @@
+-- adds provider_tokens table to store the tokens of external providers
+
+do $$ begin
+  create table if not exists {{ index .Options "Namespace" }}.provider_tokens (
+    id uuid primary key,
+    identity_id uuid not null references {{ index .Options "Namespace" }}.identities(id) on delete cascade,
+    user_id uuid not null references {{ index .Options "Namespace" }}.users(id) on delete cascade,
+    access_token text not null,
+    refresh_token text null,
+    token_type text null,
+    expires_at timestamptz null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now()
+  );
+
+  create unique index if not exists provider_tokens_identity_id_key on {{ index .Options "Namespace" }}.provider_tokens (identity_id);
+  create index if not exists provider_tokens_user_id_idx on {{ index .Options "Namespace" }}.provider_tokens (user_id);
+
+  comment on table {{ index .Options "Namespace" }}.provider_tokens is 'Auth: Stores the encrypted access and refresh tokens of external providers, one row per identity.';
+end $$;
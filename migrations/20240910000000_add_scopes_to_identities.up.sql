@@ -0,0 +1,8 @@
+-- adds scopes to identities to record the scopes granted by external providers
+
+do $$ begin
+  alter table {{ index .Options "Namespace" }}.identities
+    add column if not exists scopes text null;
+
+  comment on column {{ index .Options "Namespace" }}.identities.scopes is 'Auth: Space separated scopes the external provider granted on the last sign in.';
+end $$;
@@ -0,0 +1,28 @@
+-- adds SAML Single Logout support
+
+do $$ begin
+  alter table {{ index .Options "Namespace" }}.saml_providers
+    add column if not exists single_logout_enabled boolean not null default false;
+
+  comment on column {{ index .Options "Namespace" }}.saml_providers.single_logout_enabled is 'Auth: Whether SAML Single Logout is used with this identity provider.';
+end $$;
+
+create table if not exists {{ index .Options "Namespace" }}.saml_sessions (
+  id uuid not null,
+  user_id uuid not null,
+  sso_provider_id uuid not null,
+  name_id text not null,
+  name_id_format text null,
+  session_index text null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint saml_sessions_pkey primary key (id),
+  constraint saml_sessions_user_id_fkey foreign key (user_id) references {{ index .Options "Namespace" }}.users(id) on delete cascade,
+  constraint saml_sessions_sso_provider_id_fkey foreign key (sso_provider_id) references {{ index .Options "Namespace" }}.sso_providers(id) on delete cascade,
+  constraint "name_id not empty" check (char_length(name_id) > 0)
+);
+
+create index if not exists saml_sessions_user_id_idx on {{ index .Options "Namespace" }}.saml_sessions (user_id);
+create index if not exists saml_sessions_sso_provider_id_name_id_idx on {{ index .Options "Namespace" }}.saml_sessions (sso_provider_id, name_id);
+
+comment on table {{ index .Options "Namespace" }}.saml_sessions is 'Auth: Records the SAML Subject NameID and SessionIndex of sign ins with SAML identity providers that have Single Logout enabled.';
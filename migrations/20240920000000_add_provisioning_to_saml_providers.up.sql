@@ -0,0 +1,8 @@
+-- adds just-in-time provisioning rules to SAML providers
+
+do $$ begin
+  alter table {{ index .Options "Namespace" }}.saml_providers
+    add column if not exists provisioning jsonb null;
+
+  comment on column {{ index .Options "Namespace" }}.saml_providers.provisioning is 'Auth: Role and app_metadata given to users signing in with this identity provider.';
+end $$;
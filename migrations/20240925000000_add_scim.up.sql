@@ -0,0 +1,39 @@
+-- adds SCIM 2.0 provisioning for SSO providers
+
+do $$ begin
+  alter table {{ index .Options "Namespace" }}.sso_providers
+    add column if not exists scim_token_hash text null;
+
+  comment on column {{ index .Options "Namespace" }}.sso_providers.scim_token_hash is 'Auth: SHA-256 hash of the bearer token the identity provider uses with the SCIM endpoints.';
+end $$;
+
+create unique index if not exists sso_providers_scim_token_hash_idx on {{ index .Options "Namespace" }}.sso_providers (scim_token_hash);
+
+create table if not exists {{ index .Options "Namespace" }}.scim_groups (
+  id uuid not null,
+  sso_provider_id uuid not null,
+  display_name text not null,
+  external_id text null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint scim_groups_pkey primary key (id),
+  constraint scim_groups_sso_provider_id_fkey foreign key (sso_provider_id) references {{ index .Options "Namespace" }}.sso_providers(id) on delete cascade,
+  constraint "display_name not empty" check (char_length(display_name) > 0)
+);
+
+create index if not exists scim_groups_sso_provider_id_idx on {{ index .Options "Namespace" }}.scim_groups (sso_provider_id);
+
+comment on table {{ index .Options "Namespace" }}.scim_groups is 'Auth: Groups provisioned by SSO providers with SCIM.';
+
+create table if not exists {{ index .Options "Namespace" }}.scim_group_members (
+  group_id uuid not null,
+  user_id uuid not null,
+  created_at timestamptz null,
+  constraint scim_group_members_pkey primary key (group_id, user_id),
+  constraint scim_group_members_group_id_fkey foreign key (group_id) references {{ index .Options "Namespace" }}.scim_groups(id) on delete cascade,
+  constraint scim_group_members_user_id_fkey foreign key (user_id) references {{ index .Options "Namespace" }}.users(id) on delete cascade
+);
+
+create index if not exists scim_group_members_user_id_idx on {{ index .Options "Namespace" }}.scim_group_members (user_id);
+
+comment on table {{ index .Options "Namespace" }}.scim_group_members is 'Auth: Members of groups provisioned with SCIM.';
@@ -0,0 +1,23 @@
+-- adds assertion validation policies to SAML providers and a replay cache of
+-- accepted assertions
+
+do $$ begin
+  alter table {{ index .Options "Namespace" }}.saml_providers
+    add column if not exists assertion_policy jsonb null;
+
+  comment on column {{ index .Options "Namespace" }}.saml_providers.assertion_policy is 'Auth: IdP initiated sign in, InResponseTo, clock skew and replay window policy for assertions from this identity provider.';
+end $$;
+
+create table if not exists {{ index .Options "Namespace" }}.saml_assertion_replays (
+  sso_provider_id uuid not null,
+  assertion_id text not null,
+  expires_at timestamptz not null,
+  created_at timestamptz null,
+  constraint saml_assertion_replays_pkey primary key (sso_provider_id, assertion_id),
+  constraint saml_assertion_replays_sso_provider_id_fkey foreign key (sso_provider_id) references {{ index .Options "Namespace" }}.sso_providers(id) on delete cascade,
+  constraint "assertion_id not empty" check (char_length(assertion_id) > 0)
+);
+
+create index if not exists saml_assertion_replays_expires_at_idx on {{ index .Options "Namespace" }}.saml_assertion_replays (expires_at);
+
+comment on table {{ index .Options "Namespace" }}.saml_assertion_replays is 'Auth: Records the IDs of accepted SAML assertions until they expire, to reject replayed assertions when the replay cache is stored in the database.';
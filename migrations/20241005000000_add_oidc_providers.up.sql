@@ -0,0 +1,21 @@
+-- adds OpenID Connect connections for SSO providers
+
+create table if not exists {{ index .Options "Namespace" }}.oidc_providers (
+  id uuid not null,
+  sso_provider_id uuid not null,
+  issuer text not null unique,
+  client_id text not null,
+  client_secret text not null,
+  scopes text null,
+  provisioning jsonb null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint oidc_providers_pkey primary key (id),
+  constraint oidc_providers_sso_provider_id_fkey foreign key (sso_provider_id) references {{ index .Options "Namespace" }}.sso_providers(id) on delete cascade,
+  constraint "issuer not empty" check (char_length(issuer) > 0),
+  constraint "client_id not empty" check (char_length(client_id) > 0)
+);
+
+create unique index if not exists oidc_providers_sso_provider_id_idx on {{ index .Options "Namespace" }}.oidc_providers (sso_provider_id);
+
+comment on table {{ index .Options "Namespace" }}.oidc_providers is 'Auth: Manages OpenID Connect connections of SSO providers, for identity providers that don''t support SAML.';
@@ -0,0 +1,14 @@
+-- adds the authentication request policy of SAML providers and the
+-- authentication context of SSO sessions
+
+do $$ begin
+  alter table {{ index .Options "Namespace" }}.saml_providers
+    add column if not exists authn_request_policy jsonb null;
+
+  comment on column {{ index .Options "Namespace" }}.saml_providers.authn_request_policy is 'Auth: ForceAuthn, RequestedAuthnContext and the authentication contexts making sessions AAL2 for this identity provider.';
+
+  alter table {{ index .Options "Namespace" }}.sessions
+    add column if not exists authn_context_class_ref text null;
+
+  comment on column {{ index .Options "Namespace" }}.sessions.authn_context_class_ref is 'Auth: The authentication context class the SSO identity provider authenticated the user with.';
+end $$;
@@ -0,0 +1,10 @@
+-- adds DNS verification of the ownership of SSO domains
+
+do $$ begin
+  alter table {{ index .Options "Namespace" }}.sso_domains
+    add column if not exists verified_at timestamptz null,
+    add column if not exists verification_token text null;
+
+  comment on column {{ index .Options "Namespace" }}.sso_domains.verified_at is 'Auth: When the ownership of the domain was verified with a DNS TXT record. Unverified domains are not used to find the SSO provider of a sign in when domain verification is enabled.';
+  comment on column {{ index .Options "Namespace" }}.sso_domains.verification_token is 'Auth: Value expected in the DNS TXT record verifying the ownership of the domain.';
+end $$;
@@ -0,0 +1,56 @@
+-- adds organizations, their members with roles and invitations to join them
+
+create table if not exists {{ index .Options "Namespace" }}.organizations (
+  id uuid not null,
+  name text not null,
+  slug text not null,
+  metadata jsonb null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint organizations_pkey primary key (id),
+  constraint "name not empty" check (char_length(name) > 0),
+  constraint "slug not empty" check (char_length(slug) > 0)
+);
+
+create unique index if not exists organizations_slug_idx on {{ index .Options "Namespace" }}.organizations (slug);
+
+comment on table {{ index .Options "Namespace" }}.organizations is 'Auth: Organizations, like companies or teams, whose members have roles included in their access tokens.';
+
+create table if not exists {{ index .Options "Namespace" }}.organization_members (
+  id uuid not null,
+  organization_id uuid not null,
+  user_id uuid not null,
+  role text not null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint organization_members_pkey primary key (id),
+  constraint organization_members_organization_id_fkey foreign key (organization_id) references {{ index .Options "Namespace" }}.organizations(id) on delete cascade,
+  constraint organization_members_user_id_fkey foreign key (user_id) references {{ index .Options "Namespace" }}.users(id) on delete cascade,
+  constraint "role is valid" check (role in ('owner', 'admin', 'member'))
+);
+
+create unique index if not exists organization_members_organization_id_user_id_idx on {{ index .Options "Namespace" }}.organization_members (organization_id, user_id);
+create index if not exists organization_members_user_id_idx on {{ index .Options "Namespace" }}.organization_members (user_id);
+
+comment on table {{ index .Options "Namespace" }}.organization_members is 'Auth: Members of organizations and their roles.';
+
+create table if not exists {{ index .Options "Namespace" }}.organization_invitations (
+  id uuid not null,
+  organization_id uuid not null,
+  email text not null,
+  role text not null,
+  invited_by uuid null,
+  expires_at timestamptz not null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint organization_invitations_pkey primary key (id),
+  constraint organization_invitations_organization_id_fkey foreign key (organization_id) references {{ index .Options "Namespace" }}.organizations(id) on delete cascade,
+  constraint organization_invitations_invited_by_fkey foreign key (invited_by) references {{ index .Options "Namespace" }}.users(id) on delete set null,
+  constraint "email not empty" check (char_length(email) > 0),
+  constraint "role is valid" check (role in ('owner', 'admin', 'member'))
+);
+
+create unique index if not exists organization_invitations_organization_id_email_idx on {{ index .Options "Namespace" }}.organization_invitations (organization_id, email);
+create index if not exists organization_invitations_email_idx on {{ index .Options "Namespace" }}.organization_invitations (email);
+
+comment on table {{ index .Options "Namespace" }}.organization_invitations is 'Auth: Pending invitations to join organizations, accepted by users signed in with the invited email address.';
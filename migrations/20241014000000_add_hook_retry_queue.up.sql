@@ -0,0 +1,39 @@
+-- adds the retry queue and dead letters of the send email and send SMS hooks
+
+create table if not exists {{ index .Options "Namespace" }}.hook_deliveries (
+  id uuid not null,
+  hook_name text not null,
+  user_id uuid null,
+  payload text not null,
+  attempts integer not null default 0,
+  last_error text null,
+  next_attempt_at timestamptz not null,
+  expires_at timestamptz not null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint hook_deliveries_pkey primary key (id),
+  constraint "hook name is valid" check (hook_name in ('send_email', 'send_sms'))
+);
+
+create index if not exists hook_deliveries_next_attempt_at_idx on {{ index .Options "Namespace" }}.hook_deliveries (next_attempt_at);
+
+comment on table {{ index .Options "Namespace" }}.hook_deliveries is 'Auth: Messages of the send email and send SMS hooks waiting to be retried.';
+
+create table if not exists {{ index .Options "Namespace" }}.hook_dead_letters (
+  id uuid not null,
+  hook_name text not null,
+  user_id uuid null,
+  payload text not null,
+  attempts integer not null,
+  last_error text null,
+  reason text not null,
+  created_at timestamptz null,
+  dead_at timestamptz not null,
+  constraint hook_dead_letters_pkey primary key (id),
+  constraint "hook name is valid" check (hook_name in ('send_email', 'send_sms')),
+  constraint "reason is valid" check (reason in ('max_attempts', 'expired', 'rejected'))
+);
+
+create index if not exists hook_dead_letters_dead_at_idx on {{ index .Options "Namespace" }}.hook_dead_letters (dead_at desc);
+
+comment on table {{ index .Options "Namespace" }}.hook_dead_letters is 'Auth: Messages of the send email and send SMS hooks that could not be delivered.';
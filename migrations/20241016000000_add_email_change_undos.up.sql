@@ -0,0 +1,18 @@
+-- adds the tokens to undo email changes, sent to the old email address
+
+create table if not exists {{ index .Options "Namespace" }}.email_change_undos (
+  id uuid not null,
+  user_id uuid not null,
+  old_email text not null,
+  new_email text not null,
+  token_hash text not null,
+  expires_at timestamptz not null,
+  created_at timestamptz null,
+  constraint email_change_undos_pkey primary key (id),
+  constraint email_change_undos_token_hash_key unique (token_hash),
+  constraint email_change_undos_user_id_fkey foreign key (user_id) references {{ index .Options "Namespace" }}.users(id) on delete cascade
+);
+
+create index if not exists email_change_undos_user_id_idx on {{ index .Options "Namespace" }}.email_change_undos (user_id);
+
+comment on table {{ index .Options "Namespace" }}.email_change_undos is 'Auth: Tokens to undo email changes, sent to the old email address.';
@@ -0,0 +1,26 @@
+-- adds the outbox of emails and SMS messages sent in the background
+
+create table if not exists {{ index .Options "Namespace" }}.outbox_messages (
+  id uuid not null,
+  channel text not null,
+  provider text not null,
+  recipient text not null,
+  user_id uuid null,
+  payload text not null,
+  status text not null default 'pending',
+  attempts integer not null default 0,
+  last_error text null,
+  provider_message_id text null,
+  next_attempt_at timestamptz not null,
+  sent_at timestamptz null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint outbox_messages_pkey primary key (id),
+  constraint "channel is valid" check (channel in ('email', 'sms')),
+  constraint "status is valid" check (status in ('pending', 'sent', 'failed'))
+);
+
+create index if not exists outbox_messages_pending_next_attempt_at_idx on {{ index .Options "Namespace" }}.outbox_messages (next_attempt_at) where status = 'pending';
+create index if not exists outbox_messages_created_at_idx on {{ index .Options "Namespace" }}.outbox_messages (created_at desc);
+
+comment on table {{ index .Options "Namespace" }}.outbox_messages is 'Auth: Emails and SMS messages sent in the background, with their delivery status.';
@@ -0,0 +1,16 @@
+-- adds the suppression list of email addresses that bounced or complained
+
+create table if not exists {{ index .Options "Namespace" }}.email_suppressions (
+  id uuid not null,
+  email text not null,
+  reason text not null,
+  provider text not null,
+  details text null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint email_suppressions_pkey primary key (id),
+  constraint email_suppressions_email_key unique (email),
+  constraint "reason is valid" check (reason in ('bounce', 'complaint', 'manual'))
+);
+
+comment on table {{ index .Options "Namespace" }}.email_suppressions is 'Auth: Email addresses that bounced or complained, which emails are not sent to.';
@@ -0,0 +1,6 @@
+-- adds an index of the Gmail addresses of users without their dots and label,
+-- to find duplicates when Gmail addresses are normalized
+
+create index if not exists users_gmail_folded_email_idx
+  on {{ index .Options "Namespace" }}.users (replace(split_part(split_part(lower(email), '@', 1), '+', 1), '.', ''))
+  where split_part(lower(email), '@', 2) in ('gmail.com', 'googlemail.com');
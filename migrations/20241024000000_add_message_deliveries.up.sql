@@ -0,0 +1,26 @@
+-- adds the delivery status of emails and SMS messages, as reported by the
+-- webhooks of their providers
+
+create table if not exists {{ index .Options "Namespace" }}.message_deliveries (
+  id uuid not null,
+  channel text not null,
+  provider text not null,
+  provider_message_id text not null,
+  recipient text not null,
+  user_id uuid null,
+  message_type text not null,
+  status text not null default 'sent',
+  details text null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint message_deliveries_pkey primary key (id),
+  constraint message_deliveries_provider_message_id_key unique (channel, provider_message_id),
+  constraint "channel is valid" check (channel in ('email', 'sms')),
+  constraint "status is valid" check (status in ('sent', 'delivered', 'failed'))
+);
+
+create index if not exists message_deliveries_created_at_idx on {{ index .Options "Namespace" }}.message_deliveries (created_at desc);
+create index if not exists message_deliveries_recipient_idx on {{ index .Options "Namespace" }}.message_deliveries (recipient);
+create index if not exists message_deliveries_user_id_idx on {{ index .Options "Namespace" }}.message_deliveries (user_id) where user_id is not null;
+
+comment on table {{ index .Options "Namespace" }}.message_deliveries is 'Auth: Emails and SMS messages sent by the providers, with their delivery status.';
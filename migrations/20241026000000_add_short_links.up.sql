@@ -0,0 +1,16 @@
+-- adds the short links sent in emails instead of the long links with tokens
+
+create table if not exists {{ index .Options "Namespace" }}.short_links (
+  id uuid not null,
+  slug text not null,
+  target_url text not null,
+  expires_at timestamptz not null,
+  used_at timestamptz null,
+  created_at timestamptz null,
+  constraint short_links_pkey primary key (id),
+  constraint short_links_slug_key unique (slug)
+);
+
+create index if not exists short_links_expires_at_idx on {{ index .Options "Namespace" }}.short_links (expires_at);
+
+comment on table {{ index .Options "Namespace" }}.short_links is 'Auth: Short links sent in emails, which redirect once to the long links with tokens.';
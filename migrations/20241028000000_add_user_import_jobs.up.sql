@@ -0,0 +1,23 @@
+-- adds the jobs importing users in bulk in the background
+
+create table if not exists {{ index .Options "Namespace" }}.user_import_jobs (
+  id uuid not null,
+  status text not null,
+  aud varchar(255) null,
+  rows text not null,
+  total_rows integer not null default 0,
+  processed_rows integer not null default 0,
+  imported_rows integer not null default 0,
+  failed_rows integer not null default 0,
+  errors jsonb not null default '[]',
+  lease_expires_at timestamptz null,
+  completed_at timestamptz null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint user_import_jobs_pkey primary key (id),
+  constraint user_import_jobs_status_check check (status in ('pending', 'running', 'completed', 'failed'))
+);
+
+create index if not exists user_import_jobs_status_idx on {{ index .Options "Namespace" }}.user_import_jobs (status, created_at);
+
+comment on table {{ index .Options "Namespace" }}.user_import_jobs is 'Auth: Jobs importing users in bulk, with their progress and the errors of the rows that failed.';
@@ -0,0 +1,14 @@
+-- adds the indexes of the users sorted by when they were created and last
+-- signed in, and of their metadata matched by the admin user filters
+
+create index if not exists users_created_at_id_idx
+  on {{ index .Options "Namespace" }}.users (created_at, id);
+
+create index if not exists users_last_sign_in_at_id_idx
+  on {{ index .Options "Namespace" }}.users (last_sign_in_at nulls first, id);
+
+create index if not exists users_raw_app_meta_data_idx
+  on {{ index .Options "Namespace" }}.users using gin (raw_app_meta_data jsonb_path_ops);
+
+create index if not exists users_raw_user_meta_data_idx
+  on {{ index .Options "Namespace" }}.users using gin (raw_user_meta_data jsonb_path_ops);
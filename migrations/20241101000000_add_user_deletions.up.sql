@@ -0,0 +1,19 @@
+-- adds the soft deletions of users, with what's needed to undelete them
+-- until they're purged
+
+create table if not exists {{ index .Options "Namespace" }}.user_deletions (
+  id uuid not null,
+  user_id uuid not null,
+  snapshot text not null,
+  deleted_at timestamptz not null,
+  purge_at timestamptz not null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint user_deletions_pkey primary key (id),
+  constraint user_deletions_user_id_key unique (user_id),
+  constraint user_deletions_user_id_fkey foreign key (user_id) references {{ index .Options "Namespace" }}.users (id) on delete cascade
+);
+
+create index if not exists user_deletions_purge_at_idx on {{ index .Options "Namespace" }}.user_deletions (purge_at);
+
+comment on table {{ index .Options "Namespace" }}.user_deletions is 'Auth: Soft deleted users, with the personal data removed from them so they can be undeleted until they are purged.';
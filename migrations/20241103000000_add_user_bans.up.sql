@@ -0,0 +1,21 @@
+-- adds the history of the bans of users, with their reasons
+
+create table if not exists {{ index .Options "Namespace" }}.user_bans (
+  id uuid not null,
+  user_id uuid not null,
+  reason text null,
+  banned_by uuid null,
+  banned_until timestamptz not null,
+  lifted_at timestamptz null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint user_bans_pkey primary key (id),
+  constraint user_bans_user_id_fkey foreign key (user_id) references {{ index .Options "Namespace" }}.users (id) on delete cascade
+);
+
+create index if not exists user_bans_user_id_created_at_idx on {{ index .Options "Namespace" }}.user_bans (user_id, created_at);
+
+-- the bans to lift when they expire
+create index if not exists user_bans_banned_until_idx on {{ index .Options "Namespace" }}.user_bans (banned_until) where lifted_at is null;
+
+comment on table {{ index .Options "Namespace" }}.user_bans is 'Auth: History of the bans of users, with their reasons.';
@@ -0,0 +1,5 @@
+-- adds the admin that started impersonation sessions on behalf of users
+
+alter table {{ index .Options "Namespace" }}.sessions add column if not exists impersonated_by text null;
+
+comment on column {{ index .Options "Namespace" }}.sessions.impersonated_by is 'Auth: The admin that started the session on behalf of the user, for impersonation sessions.';
@@ -0,0 +1,14 @@
+-- adds the cursors of the sinks the audit log entries are streamed to
+
+create table if not exists {{ index .Options "Namespace" }}.audit_log_sink_cursors (
+  sink text not null,
+  last_created_at timestamptz not null,
+  last_id uuid not null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint audit_log_sink_cursors_pkey primary key (sink)
+);
+
+create index if not exists audit_log_entries_created_at_id_idx on {{ index .Options "Namespace" }}.audit_log_entries (created_at, id);
+
+comment on table {{ index .Options "Namespace" }}.audit_log_sink_cursors is 'Auth: The last audit log entries streamed to the sinks.';
@@ -0,0 +1,6 @@
+-- adds indexes for the filters of the audit log entries
+
+create index if not exists audit_log_entries_actor_id_idx on {{ index .Options "Namespace" }}.audit_log_entries ((payload->>'actor_id'));
+create index if not exists audit_log_entries_user_id_idx on {{ index .Options "Namespace" }}.audit_log_entries ((payload->'traits'->>'user_id'));
+create index if not exists audit_log_entries_action_idx on {{ index .Options "Namespace" }}.audit_log_entries ((payload->>'action'));
+create index if not exists audit_log_entries_ip_address_idx on {{ index .Options "Namespace" }}.audit_log_entries (ip_address);
@@ -0,0 +1,32 @@
+-- adds roles with permissions, and their assignments to users
+
+create table if not exists {{ index .Options "Namespace" }}.roles (
+  id uuid not null,
+  name text not null,
+  description text null,
+  permissions jsonb not null default '[]'::jsonb,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint roles_pkey primary key (id),
+  constraint "name not empty" check (char_length(name) > 0)
+);
+
+create unique index if not exists roles_name_idx on {{ index .Options "Namespace" }}.roles (name);
+
+comment on table {{ index .Options "Namespace" }}.roles is 'Auth: Roles of users, with the permissions they grant, included in access tokens.';
+
+create table if not exists {{ index .Options "Namespace" }}.user_roles (
+  id uuid not null,
+  user_id uuid not null,
+  role_id uuid not null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint user_roles_pkey primary key (id),
+  constraint user_roles_user_id_fkey foreign key (user_id) references {{ index .Options "Namespace" }}.users(id) on delete cascade,
+  constraint user_roles_role_id_fkey foreign key (role_id) references {{ index .Options "Namespace" }}.roles(id) on delete cascade
+);
+
+create unique index if not exists user_roles_user_id_role_id_idx on {{ index .Options "Namespace" }}.user_roles (user_id, role_id);
+create index if not exists user_roles_role_id_idx on {{ index .Options "Namespace" }}.user_roles (role_id);
+
+comment on table {{ index .Options "Namespace" }}.user_roles is 'Auth: Roles assigned to users.';
@@ -0,0 +1,19 @@
+-- adds admin credentials, bearer tokens limited to the admin endpoints of their scopes
+
+create table if not exists {{ index .Options "Namespace" }}.admin_credentials (
+  id uuid not null,
+  name text not null,
+  token_hash text not null,
+  scopes jsonb not null default '[]'::jsonb,
+  created_by text null,
+  expires_at timestamptz null,
+  last_used_at timestamptz null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint admin_credentials_pkey primary key (id),
+  constraint "name not empty" check (char_length(name) > 0)
+);
+
+create unique index if not exists admin_credentials_token_hash_idx on {{ index .Options "Namespace" }}.admin_credentials (token_hash);
+
+comment on table {{ index .Options "Namespace" }}.admin_credentials is 'Auth: Scoped admin credentials, whose tokens can only use the admin endpoints of their scopes.';
@@ -0,0 +1,18 @@
+-- adds the deletions of accounts scheduled by their users
+
+create table if not exists {{ index .Options "Namespace" }}.account_deletions (
+  id uuid not null,
+  user_id uuid not null,
+  token_hash text not null,
+  delete_at timestamptz not null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint account_deletions_pkey primary key (id),
+  constraint account_deletions_user_id_key unique (user_id),
+  constraint account_deletions_token_hash_key unique (token_hash),
+  constraint account_deletions_user_id_fkey foreign key (user_id) references {{ index .Options "Namespace" }}.users(id) on delete cascade
+);
+
+create index if not exists account_deletions_delete_at_idx on {{ index .Options "Namespace" }}.account_deletions (delete_at);
+
+comment on table {{ index .Options "Namespace" }}.account_deletions is 'Auth: Deletions of accounts scheduled by their users, which can be cancelled until they are due.';
@@ -0,0 +1,22 @@
+-- adds the exports of all the data held about users, generated in the background
+
+create table if not exists {{ index .Options "Namespace" }}.data_exports (
+  id uuid not null,
+  user_id uuid not null,
+  status text not null,
+  archive text null,
+  lease_expires_at timestamptz null,
+  completed_at timestamptz null,
+  expires_at timestamptz null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint data_exports_pkey primary key (id),
+  constraint data_exports_user_id_fkey foreign key (user_id) references {{ index .Options "Namespace" }}.users(id) on delete cascade,
+  constraint data_exports_status_check check (status in ('pending', 'running', 'completed', 'failed'))
+);
+
+create index if not exists data_exports_user_id_idx on {{ index .Options "Namespace" }}.data_exports (user_id, created_at);
+create index if not exists data_exports_status_idx on {{ index .Options "Namespace" }}.data_exports (status, created_at);
+create index if not exists data_exports_expires_at_idx on {{ index .Options "Namespace" }}.data_exports (expires_at);
+
+comment on table {{ index .Options "Namespace" }}.data_exports is 'Auth: Exports of all the data held about users, which can be downloaded until they expire.';
@@ -0,0 +1,9 @@
+-- adds the usernames users can sign up and sign in with
+
+alter table {{ index .Options "Namespace" }}.users add column if not exists username text null;
+
+create unique index if not exists users_username_key
+  on {{ index .Options "Namespace" }}.users (username)
+  where username is not null;
+
+comment on column {{ index .Options "Namespace" }}.users.username is 'Auth: The normalized username of the user, which it can sign in with instead of an email or phone.';
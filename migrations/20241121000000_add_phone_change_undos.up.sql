@@ -0,0 +1,23 @@
+-- adds the confirmation of phone changes by the current phone number, and
+-- the tokens to undo phone changes, sent to the old phone number
+
+alter table {{ index .Options "Namespace" }}.users
+  add column if not exists phone_change_token_current text null default '',
+  add column if not exists phone_change_confirm_status smallint default 0 check (phone_change_confirm_status >= 0 and phone_change_confirm_status <= 2);
+
+create table if not exists {{ index .Options "Namespace" }}.phone_change_undos (
+  id uuid not null,
+  user_id uuid not null,
+  old_phone text not null,
+  new_phone text not null,
+  token_hash text not null,
+  expires_at timestamptz not null,
+  created_at timestamptz null,
+  constraint phone_change_undos_pkey primary key (id),
+  constraint phone_change_undos_token_hash_key unique (token_hash),
+  constraint phone_change_undos_user_id_fkey foreign key (user_id) references {{ index .Options "Namespace" }}.users(id) on delete cascade
+);
+
+create index if not exists phone_change_undos_user_id_idx on {{ index .Options "Namespace" }}.phone_change_undos (user_id);
+
+comment on table {{ index .Options "Namespace" }}.phone_change_undos is 'Auth: Tokens to undo phone changes, sent to the old phone number.';
@@ -0,0 +1,20 @@
+-- adds the secondary email addresses of users, which once verified can be
+-- used to sign in and recover the account like the primary one
+
+create table if not exists {{ index .Options "Namespace" }}.user_emails (
+  id uuid not null,
+  user_id uuid not null,
+  email text not null,
+  token_hash text null,
+  sent_at timestamptz null,
+  verified_at timestamptz null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint user_emails_pkey primary key (id),
+  constraint user_emails_user_id_email_key unique (user_id, email),
+  constraint user_emails_user_id_fkey foreign key (user_id) references {{ index .Options "Namespace" }}.users(id) on delete cascade
+);
+
+create unique index if not exists user_emails_verified_email_key on {{ index .Options "Namespace" }}.user_emails (email) where verified_at is not null;
+
+comment on table {{ index .Options "Namespace" }}.user_emails is 'Auth: Secondary email addresses of users.';
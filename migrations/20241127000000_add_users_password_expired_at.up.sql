@@ -0,0 +1,5 @@
+-- adds the expiry of user passwords, which have to be reset before signing in with them again
+
+alter table {{ index .Options "Namespace" }}.users add column if not exists password_expired_at timestamptz null;
+
+comment on column {{ index .Options "Namespace" }}.users.password_expired_at is 'Auth: When the password was marked as expired, it has to be reset before signing in with it again.';
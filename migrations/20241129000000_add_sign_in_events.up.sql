@@ -0,0 +1,20 @@
+-- adds the history of the sign-ins of users
+
+create table if not exists {{ index .Options "Namespace" }}.sign_in_events (
+  id uuid not null,
+  user_id uuid not null,
+  method text not null,
+  provider text null,
+  ip text null,
+  user_agent text null,
+  success boolean not null,
+  failure_reason text null,
+  created_at timestamptz null,
+  constraint sign_in_events_pkey primary key (id),
+  constraint sign_in_events_user_id_fkey foreign key (user_id) references {{ index .Options "Namespace" }}.users(id) on delete cascade
+);
+
+create index if not exists sign_in_events_user_id_created_at_idx on {{ index .Options "Namespace" }}.sign_in_events (user_id, created_at desc);
+create index if not exists sign_in_events_created_at_idx on {{ index .Options "Namespace" }}.sign_in_events (created_at);
+
+comment on table {{ index .Options "Namespace" }}.sign_in_events is 'Auth: History of the sign-ins of users, successful or not.';
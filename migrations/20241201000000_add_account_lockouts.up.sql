@@ -0,0 +1,20 @@
+-- adds the lockout of password sign-ins to accounts after repeated failed attempts
+
+create table if not exists {{ index .Options "Namespace" }}.account_lockouts (
+  id uuid not null,
+  user_id uuid not null,
+  failed_attempts integer not null default 0,
+  first_failed_at timestamptz not null,
+  locked_until timestamptz null,
+  token_hash text null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint account_lockouts_pkey primary key (id),
+  constraint account_lockouts_user_id_key unique (user_id),
+  constraint account_lockouts_user_id_fkey foreign key (user_id) references {{ index .Options "Namespace" }}.users(id) on delete cascade
+);
+
+create index if not exists account_lockouts_token_hash_idx on {{ index .Options "Namespace" }}.account_lockouts (token_hash) where token_hash is not null;
+create index if not exists account_lockouts_first_failed_at_idx on {{ index .Options "Namespace" }}.account_lockouts (first_failed_at);
+
+comment on table {{ index .Options "Namespace" }}.account_lockouts is 'Auth: Failed password attempts on accounts, and their lockouts.';
@@ -0,0 +1,22 @@
+-- adds the invitations of users in bulk by admins
+
+create table if not exists {{ index .Options "Namespace" }}.bulk_invites (
+  id uuid not null,
+  batch_id uuid not null,
+  position integer not null,
+  email text not null,
+  redirect_to text null,
+  status text not null,
+  error text null,
+  user_id uuid null,
+  outbox_message_id uuid null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint bulk_invites_pkey primary key (id),
+  constraint bulk_invites_user_id_fkey foreign key (user_id) references {{ index .Options "Namespace" }}.users(id) on delete set null
+);
+
+create index if not exists bulk_invites_batch_id_idx on {{ index .Options "Namespace" }}.bulk_invites (batch_id);
+create index if not exists bulk_invites_created_at_idx on {{ index .Options "Namespace" }}.bulk_invites (created_at);
+
+comment on table {{ index .Options "Namespace" }}.bulk_invites is 'Auth: Invitations of users in bulk, with their status.';
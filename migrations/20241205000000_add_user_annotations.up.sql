@@ -0,0 +1,33 @@
+-- adds the notes and tags admins keep on users
+
+create table if not exists {{ index .Options "Namespace" }}.user_notes (
+  id uuid not null,
+  user_id uuid not null,
+  author_id uuid null,
+  body text not null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint user_notes_pkey primary key (id),
+  constraint user_notes_user_id_fkey foreign key (user_id) references {{ index .Options "Namespace" }}.users(id) on delete cascade
+);
+
+create index if not exists user_notes_user_id_created_at_idx on {{ index .Options "Namespace" }}.user_notes (user_id, created_at desc);
+
+comment on table {{ index .Options "Namespace" }}.user_notes is 'Auth: Notes admins keep on users.';
+
+create table if not exists {{ index .Options "Namespace" }}.user_tags (
+  id uuid not null,
+  user_id uuid not null,
+  name text not null,
+  value text not null default '',
+  set_by uuid null,
+  created_at timestamptz null,
+  updated_at timestamptz null,
+  constraint user_tags_pkey primary key (id),
+  constraint user_tags_user_id_name_key unique (user_id, name),
+  constraint user_tags_user_id_fkey foreign key (user_id) references {{ index .Options "Namespace" }}.users(id) on delete cascade
+);
+
+create index if not exists user_tags_name_value_idx on {{ index .Options "Namespace" }}.user_tags (name, value);
+
+comment on table {{ index .Options "Namespace" }}.user_tags is 'Auth: Labeled tags admins put on users, to find them by.';
@@ -0,0 +1,9 @@
+-- adds the versions of the terms of service and privacy policy users accepted
+
+alter table {{ index .Options "Namespace" }}.users add column if not exists terms_version text null;
+alter table {{ index .Options "Namespace" }}.users add column if not exists terms_accepted_at timestamptz null;
+alter table {{ index .Options "Namespace" }}.users add column if not exists privacy_policy_version text null;
+alter table {{ index .Options "Namespace" }}.users add column if not exists privacy_policy_accepted_at timestamptz null;
+
+comment on column {{ index .Options "Namespace" }}.users.terms_version is 'Auth: Version of the terms of service the user accepted.';
+comment on column {{ index .Options "Namespace" }}.users.privacy_policy_version is 'Auth: Version of the privacy policy the user accepted.';
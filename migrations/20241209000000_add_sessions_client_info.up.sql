@@ -0,0 +1,9 @@
+-- adds the device identifier and the location of the IP address of sessions
+
+alter table {{ index .Options "Namespace" }}.sessions add column if not exists device_id text null;
+alter table {{ index .Options "Namespace" }}.sessions add column if not exists country text null;
+alter table {{ index .Options "Namespace" }}.sessions add column if not exists region text null;
+alter table {{ index .Options "Namespace" }}.sessions add column if not exists city text null;
+
+comment on column {{ index .Options "Namespace" }}.sessions.device_id is 'Auth: Identifier of the device of the session, as provided by the client.';
+comment on column {{ index .Options "Namespace" }}.sessions.country is 'Auth: Country of the IP address of the session, as resolved by the GeoIP database.';
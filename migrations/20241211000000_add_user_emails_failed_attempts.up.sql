@@ -0,0 +1,6 @@
+-- adds the count of the wrong codes entered to verify secondary email
+-- addresses, whose codes are cleared once there are too many
+
+alter table {{ index .Options "Namespace" }}.user_emails add column if not exists failed_attempts integer not null default 0;
+
+comment on column {{ index .Options "Namespace" }}.user_emails.failed_attempts is 'Auth: Number of wrong codes entered since the last code was sent.';